@@ -17,6 +17,7 @@ package analyzers
 import (
 	"istio.io/istio/galley/pkg/config/analysis"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/annotations"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/authn"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/authz"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/deployment"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/deprecation"
@@ -28,6 +29,7 @@ import (
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/service"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/serviceentry"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/sidecar"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/traffic"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/virtualservice"
 )
 
@@ -36,6 +38,8 @@ func All() []analysis.Analyzer {
 	analyzers := []analysis.Analyzer{
 		// Please keep this list sorted alphabetically by pkg.name for convenience
 		&annotations.K8sAnalyzer{},
+		&authn.UDPPortAnalyzer{},
+		&authn.PortLevelMtlsAnalyzer{},
 		&authz.AuthorizationPoliciesAnalyzer{},
 		&deployment.ServiceAssociationAnalyzer{},
 		&deprecation.FieldAnalyzer{},
@@ -47,6 +51,7 @@ func All() []analysis.Analyzer {
 		&multicluster.MeshNetworksAnalyzer{},
 		&service.PortNameAnalyzer{},
 		&sidecar.DefaultSelectorAnalyzer{},
+		&sidecar.IngressMtlsAnalyzer{},
 		&sidecar.SelectorAnalyzer{},
 		&virtualservice.ConflictingMeshGatewayHostsAnalyzer{},
 		&virtualservice.DestinationHostAnalyzer{},
@@ -67,3 +72,12 @@ func All() []analysis.Analyzer {
 func AllCombined() *analysis.CombinedAnalyzer {
 	return analysis.Combine("all", All()...)
 }
+
+// AllWithTraffic returns all analyzers, and the analyzers checking the configuration against the observed traffic.
+func AllWithTraffic(o *traffic.Observations) []analysis.Analyzer {
+	return append(All(),
+		&traffic.DestinationRuleAnalyzer{Traffic: o},
+		&traffic.PlaintextAnalyzer{Traffic: o},
+		&traffic.RouteAnalyzer{Traffic: o},
+	)
+}
@@ -26,6 +26,7 @@ import (
 
 	"istio.io/istio/galley/pkg/config/analysis"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/annotations"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/authn"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/authz"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/deployment"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/deprecation"
@@ -36,12 +37,14 @@ import (
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/service"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/serviceentry"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/sidecar"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/traffic"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/virtualservice"
 	"istio.io/istio/galley/pkg/config/analysis/diag"
 	"istio.io/istio/galley/pkg/config/analysis/local"
 	"istio.io/istio/galley/pkg/config/analysis/msg"
 	"istio.io/istio/galley/pkg/config/processing/snapshotter"
 	"istio.io/istio/galley/pkg/config/scope"
+	"istio.io/istio/pkg/config/resource"
 	"istio.io/istio/pkg/config/schema"
 	"istio.io/istio/pkg/config/schema/collection"
 	"istio.io/pkg/log"
@@ -82,6 +85,26 @@ var testGrid = []testCase{
 			{msg.DeprecatedAnnotation, "Deployment fortio-deploy"},
 		},
 	},
+	{
+		name:       "peerAuthenticationUDPPort",
+		inputFiles: []string{"testdata/peerauthentication-udp-port.yaml"},
+		analyzer:   &authn.UDPPortAnalyzer{},
+		expected: []message{
+			{msg.PeerAuthenticationUDPPort, "PeerAuthentication dns.default"},
+			{msg.PeerAuthenticationUDPPort, "PeerAuthentication quic.default"},
+		},
+	},
+	{
+		name:       "peerAuthenticationPortLevel",
+		inputFiles: []string{"testdata/peerauthentication-port-level.yaml"},
+		analyzer:   &authn.PortLevelMtlsAnalyzer{},
+		expected: []message{
+			{msg.PeerAuthenticationPortNotExposed, "PeerAuthentication web.default"},
+			{msg.PeerAuthenticationPassthroughDisable, "PeerAuthentication web.default"},
+			{msg.ConflictingPeerAuthenticationSelectors, "PeerAuthentication web.default"},
+			{msg.ConflictingPeerAuthenticationSelectors, "PeerAuthentication web-permissive.default"},
+		},
+	},
 	{
 		name:       "deprecation",
 		inputFiles: []string{"testdata/deprecation.yaml"},
@@ -212,6 +235,14 @@ var testGrid = []testCase{
 			{msg.MultipleSidecarsWithoutWorkloadSelectors, "Sidecar has-conflict-1.ns2"},
 		},
 	},
+	{
+		name:       "sidecarIngressMtls",
+		inputFiles: []string{"testdata/sidecar-ingress-mtls.yaml"},
+		analyzer:   &sidecar.IngressMtlsAnalyzer{},
+		expected: []message{
+			{msg.SidecarIngressMtlsConflict, "Sidecar weaker.default"},
+		},
+	},
 	{
 		name:       "sidecarSelector",
 		inputFiles: []string{"testdata/sidecar-selector.yaml"},
@@ -459,6 +490,45 @@ var testGrid = []testCase{
 			{msg.GatewayDuplicateCertificate, "Gateway gateway-01-test-03.default"},
 		},
 	},
+	{
+		name:       "routes without traffic",
+		inputFiles: []string{"testdata/traffic.yaml"},
+		analyzer:   &traffic.RouteAnalyzer{Traffic: testTraffic},
+		expected: []message{
+			{msg.VirtualServiceRouteWithoutTraffic, "VirtualService reviews.default"},
+			{msg.VirtualServiceRouteWithoutTraffic, "VirtualService details.default"},
+		},
+	},
+	{
+		name:       "destination rules without traffic",
+		inputFiles: []string{"testdata/traffic.yaml"},
+		analyzer:   &traffic.DestinationRuleAnalyzer{Traffic: testTraffic},
+		expected: []message{
+			{msg.DestinationRuleWithoutTraffic, "DestinationRule details.default"},
+		},
+	},
+	{
+		name:       "plaintext rejected by STRICT ports",
+		inputFiles: []string{"testdata/traffic.yaml"},
+		analyzer:   &traffic.PlaintextAnalyzer{Traffic: testTraffic},
+		expected: []message{
+			{msg.PlaintextOnStrictPort, "Pod reviews-v1-1.default"},
+		},
+	},
+}
+
+// testTraffic is the traffic observed by the test cases of the traffic analyzers.
+var testTraffic = &traffic.Observations{
+	Window: "1h",
+	Requests: map[string]map[string]float64{
+		"reviews.default.svc.cluster.local": {"v1": 120, "v2": 0},
+		"ratings.default.svc.cluster.local": {"unknown": 12},
+	},
+	PlaintextRejections: map[resource.FullName]map[uint32]int{
+		resource.NewFullName("default", "reviews-v1-1"):     {9080: 3, 15020: 1},
+		resource.NewFullName("default", "productpage-v1-1"): {9080: 0},
+		resource.NewFullName("default", "ratings-v1-1"):     {9080: 2},
+	},
 }
 
 // regex patterns for analyzer names that should be explicitly ignored for testing
@@ -509,7 +579,7 @@ func TestAnalyzers(t *testing.T) {
 	t.Run("CheckMetadataInputs", func(t *testing.T) {
 		g := NewWithT(t)
 	outer:
-		for _, a := range All() {
+		for _, a := range AllWithTraffic(testTraffic) {
 			analyzerName := a.Metadata().Name
 
 			// Skip this check for explicitly ignored analyzers
@@ -535,12 +605,13 @@ func TestAnalyzers(t *testing.T) {
 	})
 }
 
-// Verify that all of the analyzers tested here are also registered in All()
+// Verify that all of the analyzers tested here are also registered in All(), or AllWithTraffic() for the traffic
+// analyzers
 func TestAnalyzersInAll(t *testing.T) {
 	g := NewWithT(t)
 
 	var allNames []string
-	for _, a := range All() {
+	for _, a := range AllWithTraffic(testTraffic) {
 		allNames = append(allNames, a.Metadata().Name)
 	}
 
@@ -553,7 +624,7 @@ func TestAnalyzersHaveUniqueNames(t *testing.T) {
 	g := NewWithT(t)
 
 	existingNames := make(map[string]struct{})
-	for _, a := range All() {
+	for _, a := range AllWithTraffic(testTraffic) {
 		n := a.Metadata().Name
 		_, ok := existingNames[n]
 		// TODO (Nino-K): remove this condition once metadata is clean up
@@ -570,7 +641,7 @@ func TestAnalyzersHaveUniqueNames(t *testing.T) {
 func TestAnalyzersHaveDescription(t *testing.T) {
 	g := NewWithT(t)
 
-	for _, a := range All() {
+	for _, a := range AllWithTraffic(testTraffic) {
 		g.Expect(a.Metadata().Description).ToNot(Equal(""))
 	}
 }
@@ -0,0 +1,201 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authn
+
+import (
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"istio.io/api/security/v1beta1"
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/util"
+	"istio.io/istio/galley/pkg/config/analysis/diag"
+	"istio.io/istio/galley/pkg/config/analysis/msg"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// PortLevelMtlsAnalyzer checks the PeerAuthentication settings the sidecars silently ignore: port level mTLS
+// settings of ports not exposed by any Service, which are passed through with the mode of the workload, and
+// PeerAuthentications selecting the same workloads with conflicting modes, of which only the oldest applies.
+type PortLevelMtlsAnalyzer struct{}
+
+var _ analysis.Analyzer = &PortLevelMtlsAnalyzer{}
+
+// Metadata implements Analyzer
+func (a *PortLevelMtlsAnalyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name: "authn.PortLevelMtlsAnalyzer",
+		Description: "Checks that PeerAuthentication port level mTLS settings target ports of Services, and that " +
+			"PeerAuthentications selecting the same workloads do not conflict",
+		Inputs: collection.Names{
+			collections.IstioSecurityV1Beta1Peerauthentications.Name(),
+			collections.K8SCoreV1Pods.Name(),
+			collections.K8SCoreV1Services.Name(),
+		},
+	}
+}
+
+// Analyze implements Analyzer
+func (a *PortLevelMtlsAnalyzer) Analyze(c analysis.Context) {
+	podsToPolicies := map[resource.FullName][]*resource.Instance{}
+
+	c.ForEach(collections.IstioSecurityV1Beta1Peerauthentications.Name(), func(r *resource.Instance) bool {
+		pa := r.Message.(*v1beta1.PeerAuthentication)
+
+		// Only workload level policies select pods, and have port level settings.
+		if pa.Selector == nil || len(pa.Selector.MatchLabels) == 0 {
+			return true
+		}
+		ns := r.Metadata.FullName.Namespace
+		sel := labels.SelectorFromSet(pa.Selector.MatchLabels)
+
+		c.ForEach(collections.K8SCoreV1Pods.Name(), func(rp *resource.Instance) bool {
+			pod := rp.Message.(*v1.Pod)
+			if rp.Metadata.FullName.Namespace != ns || !sel.Matches(labels.Set(pod.ObjectMeta.Labels)) {
+				return true
+			}
+			podsToPolicies[rp.Metadata.FullName] = append(podsToPolicies[rp.Metadata.FullName], r)
+			if len(pa.PortLevelMtls) == 0 {
+				return true
+			}
+
+			exposed := servicePortsForPod(c, rp)
+			// UDP ports are reported by the UDPPortAnalyzer.
+			udp := udpPortsForPod(c, rp)
+			ports := make([]uint32, 0, len(pa.PortLevelMtls))
+			for port := range pa.PortLevelMtls {
+				_, isExposed := exposed[port]
+				_, isUDP := udp[port]
+				if !isExposed && !isUDP {
+					ports = append(ports, port)
+				}
+			}
+			sort.Slice(ports, func(i, j int) bool { return ports[i] < ports[j] })
+			for _, port := range ports {
+				var m diag.Message
+				if pa.PortLevelMtls[port].GetMode() == v1beta1.PeerAuthentication_MutualTLS_DISABLE {
+					m = msg.NewPeerAuthenticationPassthroughDisable(r, port, rp.Metadata.FullName.String())
+				} else {
+					m = msg.NewPeerAuthenticationPortNotExposed(r, port, rp.Metadata.FullName.String())
+				}
+
+				if line, ok := util.ErrorLine(r, util.MetadataName); ok {
+					m.Line = line
+				}
+
+				c.Report(collections.IstioSecurityV1Beta1Peerauthentications.Name(), m)
+			}
+			return true
+		})
+
+		return true
+	})
+
+	for p, policies := range podsToPolicies {
+		if !conflictingPolicies(policies) {
+			continue
+		}
+		names := getNames(policies)
+		for _, r := range policies {
+			m := msg.NewConflictingPeerAuthenticationSelectors(r, names, p.Namespace.String(), p.Name.String())
+
+			if line, ok := util.ErrorLine(r, util.MetadataName); ok {
+				m.Line = line
+			}
+
+			c.Report(collections.IstioSecurityV1Beta1Peerauthentications.Name(), m)
+		}
+	}
+}
+
+// conflictingPolicies returns true if some of the PeerAuthentications set different modes for the workload, or for
+// one of its ports.
+func conflictingPolicies(policies []*resource.Instance) bool {
+	for i, ri := range policies {
+		a := ri.Message.(*v1beta1.PeerAuthentication)
+		for _, rj := range policies[i+1:] {
+			b := rj.Message.(*v1beta1.PeerAuthentication)
+			if conflictingModes(a.GetMtls(), b.GetMtls()) {
+				return true
+			}
+			for port, mtls := range a.PortLevelMtls {
+				if conflictingModes(mtls, b.PortLevelMtls[port]) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// conflictingModes returns true if both settings set a mode, and the modes differ. An unset mode inherits the mode
+// of the parent policy.
+func conflictingModes(a, b *v1beta1.PeerAuthentication_MutualTLS) bool {
+	unset := v1beta1.PeerAuthentication_MutualTLS_UNSET
+	return a.GetMode() != unset && b.GetMode() != unset && a.GetMode() != b.GetMode()
+}
+
+func getNames(entries []*resource.Instance) []string {
+	names := make([]string, 0, len(entries))
+	for _, rs := range entries {
+		names = append(names, string(rs.Metadata.FullName.Name))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// servicePortsForPod returns the TCP ports of the pod targeted by the services selecting it.
+func servicePortsForPod(c analysis.Context, rp *resource.Instance) map[uint32]struct{} {
+	pod := rp.Message.(*v1.Pod)
+	namedPorts := map[string]uint32{}
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			if port.Name != "" {
+				namedPorts[port.Name] = uint32(port.ContainerPort)
+			}
+		}
+	}
+
+	ports := map[uint32]struct{}{}
+	c.ForEach(collections.K8SCoreV1Services.Name(), func(rs *resource.Instance) bool {
+		svc := rs.Message.(*v1.ServiceSpec)
+		if rs.Metadata.FullName.Namespace != rp.Metadata.FullName.Namespace || len(svc.Selector) == 0 ||
+			!labels.SelectorFromSet(svc.Selector).Matches(labels.Set(pod.ObjectMeta.Labels)) {
+			return true
+		}
+		for _, port := range svc.Ports {
+			if port.Protocol != "" && port.Protocol != v1.ProtocolTCP {
+				continue
+			}
+			switch {
+			case port.TargetPort.StrVal != "":
+				if p, f := namedPorts[port.TargetPort.StrVal]; f {
+					ports[p] = struct{}{}
+				}
+			case port.TargetPort.IntVal != 0:
+				ports[uint32(port.TargetPort.IntVal)] = struct{}{}
+			default:
+				// By default, the targetPort is the same as the port
+				ports[uint32(port.Port)] = struct{}{}
+			}
+		}
+		return true
+	})
+	return ports
+}
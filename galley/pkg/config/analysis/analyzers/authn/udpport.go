@@ -0,0 +1,152 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authn
+
+import (
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"istio.io/api/security/v1beta1"
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/util"
+	"istio.io/istio/galley/pkg/config/analysis/msg"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// UDPPortAnalyzer checks that PeerAuthentication port level mTLS settings do not target UDP ports. UDP
+// traffic is not intercepted by the sidecar, so these settings can never be enforced.
+type UDPPortAnalyzer struct{}
+
+var _ analysis.Analyzer = &UDPPortAnalyzer{}
+
+// Metadata implements Analyzer
+func (a *UDPPortAnalyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name:        "authn.UDPPortAnalyzer",
+		Description: "Checks that PeerAuthentication port level mTLS settings do not target UDP ports",
+		Inputs: collection.Names{
+			collections.IstioSecurityV1Beta1Peerauthentications.Name(),
+			collections.K8SCoreV1Pods.Name(),
+			collections.K8SCoreV1Services.Name(),
+		},
+	}
+}
+
+// Analyze implements Analyzer
+func (a *UDPPortAnalyzer) Analyze(c analysis.Context) {
+	c.ForEach(collections.IstioSecurityV1Beta1Peerauthentications.Name(), func(r *resource.Instance) bool {
+		pa := r.Message.(*v1beta1.PeerAuthentication)
+
+		// Port level settings are only allowed on workload level policies
+		if pa.Selector == nil || len(pa.Selector.MatchLabels) == 0 || len(pa.PortLevelMtls) == 0 {
+			return true
+		}
+		ns := r.Metadata.FullName.Namespace
+		sel := labels.SelectorFromSet(pa.Selector.MatchLabels)
+
+		c.ForEach(collections.K8SCoreV1Pods.Name(), func(rp *resource.Instance) bool {
+			pod := rp.Message.(*v1.Pod)
+			if rp.Metadata.FullName.Namespace != ns || !sel.Matches(labels.Set(pod.ObjectMeta.Labels)) {
+				return true
+			}
+
+			udpPorts := udpPortsForPod(c, rp)
+			ports := make([]uint32, 0, len(udpPorts))
+			for port := range udpPorts {
+				if _, f := pa.PortLevelMtls[port]; f {
+					ports = append(ports, port)
+				}
+			}
+			sort.Slice(ports, func(i, j int) bool { return ports[i] < ports[j] })
+			for _, port := range ports {
+				m := msg.NewPeerAuthenticationUDPPort(r, port, rp.Metadata.FullName.String())
+
+				if line, ok := util.ErrorLine(r, util.MetadataName); ok {
+					m.Line = line
+				}
+
+				c.Report(collections.IstioSecurityV1Beta1Peerauthentications.Name(), m)
+			}
+			return true
+		})
+
+		return true
+	})
+}
+
+// udpPortsForPod returns the UDP ports of the pod, as declared on its containers or by the
+// target ports of the services selecting it. Ports also serving TCP are excluded, as port level
+// mTLS settings apply to their TCP traffic.
+func udpPortsForPod(c analysis.Context, rp *resource.Instance) map[uint32]struct{} {
+	pod := rp.Message.(*v1.Pod)
+	udp := map[uint32]struct{}{}
+	tcp := map[uint32]struct{}{}
+	portsOf := func(protocol v1.Protocol) map[uint32]struct{} {
+		if protocol == v1.ProtocolUDP {
+			return udp
+		}
+		// The protocol defaults to TCP
+		if protocol == "" || protocol == v1.ProtocolTCP {
+			return tcp
+		}
+		return nil
+	}
+	namedPorts := map[string]uint32{}
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			if port.Name != "" {
+				namedPorts[port.Name] = uint32(port.ContainerPort)
+			}
+			if ports := portsOf(port.Protocol); ports != nil {
+				ports[uint32(port.ContainerPort)] = struct{}{}
+			}
+		}
+	}
+
+	c.ForEach(collections.K8SCoreV1Services.Name(), func(rs *resource.Instance) bool {
+		svc := rs.Message.(*v1.ServiceSpec)
+		if rs.Metadata.FullName.Namespace != rp.Metadata.FullName.Namespace || len(svc.Selector) == 0 ||
+			!labels.SelectorFromSet(svc.Selector).Matches(labels.Set(pod.ObjectMeta.Labels)) {
+			return true
+		}
+		for _, port := range svc.Ports {
+			ports := portsOf(port.Protocol)
+			if ports == nil {
+				continue
+			}
+			switch {
+			case port.TargetPort.StrVal != "":
+				if p, f := namedPorts[port.TargetPort.StrVal]; f {
+					ports[p] = struct{}{}
+				}
+			case port.TargetPort.IntVal != 0:
+				ports[uint32(port.TargetPort.IntVal)] = struct{}{}
+			default:
+				// By default, the targetPort is the same as the port
+				ports[uint32(port.Port)] = struct{}{}
+			}
+		}
+		return true
+	})
+
+	for port := range tcp {
+		delete(udp, port)
+	}
+	return udp
+}
@@ -0,0 +1,119 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sidecar
+
+import (
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"istio.io/api/networking/v1alpha3"
+	"istio.io/api/security/v1beta1"
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/util"
+	"istio.io/istio/galley/pkg/config/analysis/msg"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/config/security"
+)
+
+// IngressMtlsAnalyzer checks that the ingress mTLS settings of Sidecars do not set a weaker mode than the port
+// level mTLS settings of the PeerAuthentications selecting the same pods. Such settings are ignored by istiod.
+type IngressMtlsAnalyzer struct{}
+
+var _ analysis.Analyzer = &IngressMtlsAnalyzer{}
+
+// Metadata implements Analyzer
+func (a *IngressMtlsAnalyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name: "sidecar.IngressMtlsAnalyzer",
+		Description: "Checks that the ingress mTLS settings of Sidecars do not weaken the port level mTLS settings " +
+			"of PeerAuthentications",
+		Inputs: collection.Names{
+			collections.IstioNetworkingV1Alpha3Sidecars.Name(),
+			collections.IstioSecurityV1Beta1Peerauthentications.Name(),
+			collections.K8SCoreV1Pods.Name(),
+		},
+	}
+}
+
+// Analyze implements Analyzer
+func (a *IngressMtlsAnalyzer) Analyze(c analysis.Context) {
+	c.ForEach(collections.IstioNetworkingV1Alpha3Sidecars.Name(), func(rs *resource.Instance) bool {
+		value, f := rs.Metadata.Annotations[security.SidecarIngressMtlsAnnotation]
+		if !f {
+			return true
+		}
+		// Invalid settings are reported by the validation of the Sidecar.
+		modes, err := security.ParseSidecarIngressMtls(value)
+		if err != nil || len(modes) == 0 {
+			return true
+		}
+		s := rs.Message.(*v1alpha3.Sidecar)
+		ns := rs.Metadata.FullName.Namespace
+		sidecarSel := labels.Everything()
+		if s.WorkloadSelector != nil && len(s.WorkloadSelector.Labels) > 0 {
+			sidecarSel = labels.SelectorFromSet(s.WorkloadSelector.Labels)
+		}
+
+		c.ForEach(collections.IstioSecurityV1Beta1Peerauthentications.Name(), func(r *resource.Instance) bool {
+			pa := r.Message.(*v1beta1.PeerAuthentication)
+			// Port level settings are only allowed on workload level policies
+			if r.Metadata.FullName.Namespace != ns || pa.Selector == nil || len(pa.Selector.MatchLabels) == 0 ||
+				len(pa.PortLevelMtls) == 0 || !selectSamePod(c, ns, sidecarSel, labels.SelectorFromSet(pa.Selector.MatchLabels)) {
+				return true
+			}
+
+			ports := make([]uint32, 0, len(modes))
+			for port, mode := range modes {
+				// The modes are ordered from the weakest to the strictest, after UNSET.
+				if portMtls, f := pa.PortLevelMtls[port]; f && mode < portMtls.GetMode() {
+					ports = append(ports, port)
+				}
+			}
+			sort.Slice(ports, func(i, j int) bool { return ports[i] < ports[j] })
+			for _, port := range ports {
+				m := msg.NewSidecarIngressMtlsConflict(rs, modes[port].String(), port, r.Metadata.FullName.String(),
+					pa.PortLevelMtls[port].GetMode().String())
+
+				if line, ok := util.ErrorLine(rs, util.MetadataName); ok {
+					m.Line = line
+				}
+
+				c.Report(collections.IstioNetworkingV1Alpha3Sidecars.Name(), m)
+			}
+			return true
+		})
+
+		return true
+	})
+}
+
+// selectSamePod returns true if a pod of the namespace is selected by both selectors.
+func selectSamePod(c analysis.Context, ns resource.Namespace, a, b labels.Selector) bool {
+	found := false
+	c.ForEach(collections.K8SCoreV1Pods.Name(), func(rp *resource.Instance) bool {
+		pod := rp.Message.(*v1.Pod)
+		podLabels := labels.Set(pod.ObjectMeta.Labels)
+		if rp.Metadata.FullName.Namespace == ns && a.Matches(podLabels) && b.Matches(podLabels) {
+			found = true
+			return false
+		}
+		return true
+	})
+	return found
+}
@@ -0,0 +1,69 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"strings"
+
+	"istio.io/api/networking/v1alpha3"
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/util"
+	"istio.io/istio/galley/pkg/config/analysis/msg"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// DestinationRuleAnalyzer checks that the host of each destination rule received traffic.
+type DestinationRuleAnalyzer struct {
+	Traffic *Observations
+}
+
+var _ analysis.Analyzer = &DestinationRuleAnalyzer{}
+
+// Metadata implements Analyzer
+func (a *DestinationRuleAnalyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name:        "traffic.DestinationRuleAnalyzer",
+		Description: "Checks that the host of each destination rule received traffic",
+		Inputs: collection.Names{
+			collections.IstioNetworkingV1Alpha3Destinationrules.Name(),
+		},
+	}
+}
+
+// Analyze implements Analyzer
+func (a *DestinationRuleAnalyzer) Analyze(c analysis.Context) {
+	if a.Traffic == nil {
+		return
+	}
+	c.ForEach(collections.IstioNetworkingV1Alpha3Destinationrules.Name(), func(r *resource.Instance) bool {
+		dr := r.Message.(*v1alpha3.DestinationRule)
+		// The traffic is observed per service, wildcard hosts cannot be checked.
+		if strings.HasPrefix(dr.GetHost(), "*") {
+			return true
+		}
+		if received, _ := a.Traffic.hostTraffic(r.Metadata.FullName.Namespace, dr.GetHost()); received {
+			return true
+		}
+
+		m := msg.NewDestinationRuleWithoutTraffic(r, dr.GetHost(), a.Traffic.Window)
+		if line, ok := util.ErrorLine(r, util.MetadataName); ok {
+			m.Line = line
+		}
+		c.Report(collections.IstioNetworkingV1Alpha3Destinationrules.Name(), m)
+		return true
+	})
+}
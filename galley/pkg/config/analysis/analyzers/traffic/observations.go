@@ -0,0 +1,54 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package traffic contains the analyzers checking the configuration against the traffic observed in the mesh. They
+// are not part of the default analyzers, as they require the traffic to be collected beforehand.
+package traffic
+
+import (
+	"strings"
+
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/util"
+	"istio.io/istio/pkg/config/resource"
+)
+
+// Observations is the traffic observed in the mesh over a period of time.
+type Observations struct {
+	// Window is the period the traffic was observed over, e.g. 1h.
+	Window string
+	// Requests counts the requests and TCP connections received by each destination service, keyed by the FQDN of
+	// the service then the version of the destination workloads.
+	Requests map[string]map[string]float64
+	// PlaintextRejections counts the plaintext connections rejected by the sidecar of each pod, by port.
+	PlaintextRejections map[resource.FullName]map[uint32]int
+}
+
+// hostTraffic returns whether the host received traffic, and the versions of the workloads which received it. The
+// host may be a short name or FQDN, relative to the namespace.
+func (o *Observations) hostTraffic(ns resource.Namespace, host string) (bool, map[string]float64) {
+	fqdn := util.ConvertHostToFQDN(ns, host)
+	versions, f := o.Requests[fqdn]
+	if !f && strings.Count(fqdn, ".") == 1 {
+		// <name>.<namespace> hosts
+		versions, f = o.Requests[fqdn+"."+util.DefaultKubernetesDomain]
+	}
+	if !f {
+		return false, nil
+	}
+	total := 0.0
+	for _, count := range versions {
+		total += count
+	}
+	return total > 0, versions
+}
@@ -0,0 +1,161 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/api/security/v1beta1"
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/util"
+	"istio.io/istio/galley/pkg/config/analysis/msg"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// PlaintextAnalyzer checks that the ports of the pods whose mTLS mode is STRICT did not reject plaintext
+// connections, e.g. from clients without sidecar. The mTLS mode of the ports is resolved from the
+// PeerAuthentications applying to the pods.
+type PlaintextAnalyzer struct {
+	Traffic *Observations
+}
+
+var _ analysis.Analyzer = &PlaintextAnalyzer{}
+
+// Metadata implements Analyzer
+func (a *PlaintextAnalyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name:        "traffic.PlaintextAnalyzer",
+		Description: "Checks that the ports of the pods in STRICT mTLS mode did not reject plaintext connections",
+		Inputs: collection.Names{
+			collections.IstioMeshV1Alpha1MeshConfig.Name(),
+			collections.IstioSecurityV1Beta1Peerauthentications.Name(),
+			collections.K8SCoreV1Pods.Name(),
+		},
+	}
+}
+
+// Analyze implements Analyzer
+func (a *PlaintextAnalyzer) Analyze(c analysis.Context) {
+	if a.Traffic == nil {
+		return
+	}
+	rootNamespace := meshRootNamespace(c)
+	c.ForEach(collections.K8SCoreV1Pods.Name(), func(r *resource.Instance) bool {
+		rejections := a.Traffic.PlaintextRejections[r.Metadata.FullName]
+		if len(rejections) == 0 {
+			return true
+		}
+		// Plaintext connections are also rejected for other reasons, e.g. by ports without listener.
+		policy := peerAuthentication(c, rootNamespace, r)
+		ports := make([]uint32, 0, len(rejections))
+		for port, count := range rejections {
+			if count > 0 && policy.mode(port) == v1beta1.PeerAuthentication_MutualTLS_STRICT {
+				ports = append(ports, port)
+			}
+		}
+		sort.Slice(ports, func(i, j int) bool { return ports[i] < ports[j] })
+		for _, port := range ports {
+			m := msg.NewPlaintextOnStrictPort(r, port, rejections[port], a.Traffic.Window)
+			if line, ok := util.ErrorLine(r, util.MetadataName); ok {
+				m.Line = line
+			}
+			c.Report(collections.K8SCoreV1Pods.Name(), m)
+		}
+		return true
+	})
+}
+
+// effectivePeerAuthentication is the mTLS mode of a pod, and of its ports, resolved from the PeerAuthentications
+// as done by istiod.
+type effectivePeerAuthentication struct {
+	workload v1beta1.PeerAuthentication_MutualTLS_Mode
+	ports    map[uint32]*v1beta1.PeerAuthentication_MutualTLS
+}
+
+// mode returns the mTLS mode of the port. Port level settings take precedence over the workload mode, and UNSET
+// port level settings inherit it.
+func (p effectivePeerAuthentication) mode(port uint32) v1beta1.PeerAuthentication_MutualTLS_Mode {
+	if mtls, f := p.ports[port]; f && mtls.GetMode() != v1beta1.PeerAuthentication_MutualTLS_UNSET {
+		return mtls.GetMode()
+	}
+	return p.workload
+}
+
+// peerAuthentication resolves the PeerAuthentications applying to the pod: the workload level policy takes
+// precedence over the namespace level policy, which takes precedence over the mesh level policy in the root
+// namespace. When several policies apply at the same level, the oldest one wins. UNSET modes inherit the mode of
+// the parent level, and the mode defaults to PERMISSIVE.
+func peerAuthentication(c analysis.Context, rootNamespace string, pod *resource.Instance) effectivePeerAuthentication {
+	podLabels := labels.Set(pod.Message.(*v1.Pod).ObjectMeta.Labels)
+	ns := pod.Metadata.FullName.Namespace.String()
+	var mesh, namespace, workload *resource.Instance
+	older := func(current, r *resource.Instance) bool {
+		return current == nil || r.Metadata.CreateTime.Before(current.Metadata.CreateTime)
+	}
+	c.ForEach(collections.IstioSecurityV1Beta1Peerauthentications.Name(), func(r *resource.Instance) bool {
+		pa := r.Message.(*v1beta1.PeerAuthentication)
+		paNamespace := r.Metadata.FullName.Namespace.String()
+		switch {
+		case len(pa.GetSelector().GetMatchLabels()) == 0 && paNamespace == rootNamespace:
+			if older(mesh, r) {
+				mesh = r
+			}
+		case len(pa.GetSelector().GetMatchLabels()) == 0 && paNamespace == ns:
+			if older(namespace, r) {
+				namespace = r
+			}
+		case paNamespace == ns && paNamespace != rootNamespace &&
+			labels.SelectorFromSet(pa.GetSelector().GetMatchLabels()).Matches(podLabels):
+			if older(workload, r) {
+				workload = r
+			}
+		}
+		return true
+	})
+
+	res := effectivePeerAuthentication{workload: v1beta1.PeerAuthentication_MutualTLS_PERMISSIVE}
+	for _, r := range []*resource.Instance{mesh, namespace, workload} {
+		if r == nil {
+			continue
+		}
+		if mode := r.Message.(*v1beta1.PeerAuthentication).GetMtls().GetMode(); mode != v1beta1.PeerAuthentication_MutualTLS_UNSET {
+			res.workload = mode
+		}
+	}
+	if workload != nil {
+		res.ports = workload.Message.(*v1beta1.PeerAuthentication).GetPortLevelMtls()
+	}
+	return res
+}
+
+// meshRootNamespace returns the root namespace of the mesh config named istio, or of the last one found.
+func meshRootNamespace(c analysis.Context) string {
+	var mesh *meshconfig.MeshConfig
+	c.ForEach(collections.IstioMeshV1Alpha1MeshConfig.Name(), func(r *resource.Instance) bool {
+		mesh = r.Message.(*meshconfig.MeshConfig)
+		return r.Metadata.FullName.Name != util.MeshConfigName
+	})
+	if mesh.GetRootNamespace() == "" {
+		return constants.IstioSystemNamespace
+	}
+	return mesh.GetRootNamespace()
+}
@@ -0,0 +1,161 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"fmt"
+
+	"istio.io/api/networking/v1alpha3"
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/util"
+	"istio.io/istio/galley/pkg/config/analysis/msg"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// versionLabel is the label of the workloads reported as the destination version of the requests.
+const versionLabel = "version"
+
+// RouteAnalyzer checks that the destinations of each VirtualService route received traffic. A route none of whose
+// destinations received traffic likely never matches, e.g. being shadowed by a previous route.
+type RouteAnalyzer struct {
+	Traffic *Observations
+}
+
+var _ analysis.Analyzer = &RouteAnalyzer{}
+
+// Metadata implements Analyzer
+func (a *RouteAnalyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name:        "traffic.RouteAnalyzer",
+		Description: "Checks that the destinations of each virtual service route received traffic",
+		Inputs: collection.Names{
+			collections.IstioNetworkingV1Alpha3Virtualservices.Name(),
+			collections.IstioNetworkingV1Alpha3Destinationrules.Name(),
+		},
+	}
+}
+
+// Analyze implements Analyzer
+func (a *RouteAnalyzer) Analyze(c analysis.Context) {
+	if a.Traffic == nil {
+		return
+	}
+	subsetVersions := initSubsetVersions(c)
+
+	c.ForEach(collections.IstioNetworkingV1Alpha3Virtualservices.Name(), func(r *resource.Instance) bool {
+		vs := r.Message.(*v1alpha3.VirtualService)
+		ns := r.Metadata.FullName.Namespace
+		for _, route := range routes(vs) {
+			received := false
+			var destinations []string
+			for _, d := range route.destinations {
+				if a.destinationTraffic(ns, d, subsetVersions) {
+					received = true
+					break
+				}
+				name := d.GetHost()
+				if d.GetSubset() != "" {
+					name += "+" + d.GetSubset()
+				}
+				destinations = append(destinations, name)
+			}
+			if received || len(destinations) == 0 {
+				continue
+			}
+
+			m := msg.NewVirtualServiceRouteWithoutTraffic(r, destinations, route.name, a.Traffic.Window)
+			key := fmt.Sprintf(util.DestinationHost, route.rule, route.index, 0)
+			if line, ok := util.ErrorLine(r, key); ok {
+				m.Line = line
+			}
+			c.Report(collections.IstioNetworkingV1Alpha3Virtualservices.Name(), m)
+		}
+		return true
+	})
+}
+
+// destinationTraffic returns true if the destination received traffic. If the destination is a subset setting the
+// version label, the workloads of this version must have received traffic.
+func (a *RouteAnalyzer) destinationTraffic(ns resource.Namespace, d *v1alpha3.Destination,
+	subsetVersions map[string]string) bool {
+	received, versions := a.Traffic.hostTraffic(ns, d.GetHost())
+	if !received || d.GetSubset() == "" {
+		return received
+	}
+	version, f := subsetVersions[util.ConvertHostToFQDN(ns, d.GetHost())+"+"+d.GetSubset()]
+	if !f {
+		return true
+	}
+	return versions[version] > 0
+}
+
+type route struct {
+	// rule is the type of the route, http, tls or tcp.
+	rule         string
+	index        int
+	name         string
+	destinations []*v1alpha3.Destination
+}
+
+func routes(vs *v1alpha3.VirtualService) []route {
+	var out []route
+	add := func(rule string, i int, name string, destinations []*v1alpha3.Destination) {
+		if name == "" {
+			name = fmt.Sprintf("%s[%d]", rule, i)
+		}
+		out = append(out, route{rule: rule, index: i, name: name, destinations: destinations})
+	}
+	for i, r := range vs.GetHttp() {
+		var destinations []*v1alpha3.Destination
+		for _, rd := range r.GetRoute() {
+			destinations = append(destinations, rd.GetDestination())
+		}
+		add("http", i, r.GetName(), destinations)
+	}
+	for i, r := range vs.GetTls() {
+		add("tls", i, "", routeDestinations(r.GetRoute()))
+	}
+	for i, r := range vs.GetTcp() {
+		add("tcp", i, "", routeDestinations(r.GetRoute()))
+	}
+	return out
+}
+
+func routeDestinations(rds []*v1alpha3.RouteDestination) []*v1alpha3.Destination {
+	destinations := make([]*v1alpha3.Destination, 0, len(rds))
+	for _, rd := range rds {
+		destinations = append(destinations, rd.GetDestination())
+	}
+	return destinations
+}
+
+// initSubsetVersions returns the version label of the subsets of the destination rules setting it, keyed by host
+// FQDN and subset name.
+func initSubsetVersions(c analysis.Context) map[string]string {
+	out := map[string]string{}
+	c.ForEach(collections.IstioNetworkingV1Alpha3Destinationrules.Name(), func(r *resource.Instance) bool {
+		dr := r.Message.(*v1alpha3.DestinationRule)
+		fqdn := util.ConvertHostToFQDN(r.Metadata.FullName.Namespace, dr.GetHost())
+		for _, ss := range dr.GetSubsets() {
+			if v, f := ss.GetLabels()[versionLabel]; f {
+				out[fqdn+"+"+ss.GetName()] = v
+			}
+		}
+		return true
+	})
+	return out
+}
@@ -0,0 +1,328 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package external runs analyzers implemented by external executables, so that organizations can enforce their own
+// conventions alongside the built-in analyzers without rebuilding Istio. An external analyzer is an executable
+// supporting two commands:
+//
+//	<analyzer> describe
+//
+// prints the Description of the analyzer as JSON, and
+//
+//	<analyzer> analyze
+//
+// reads the resources of the input collections of the analyzer as a JSON Request on its standard input, and prints
+// the diagnostic messages it reports as a JSON Response.
+package external
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/diag"
+	"istio.io/istio/galley/pkg/config/scope"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/util/gogoprotomarshal"
+)
+
+const (
+	// DescribeCommand is the command printing the description of an external analyzer.
+	DescribeCommand = "describe"
+	// AnalyzeCommand is the command analyzing the resources of an external analyzer.
+	AnalyzeCommand = "analyze"
+)
+
+// Timeout bounds the duration of a command of an external analyzer.
+var Timeout = 30 * time.Second
+
+// Description is the description of an external analyzer.
+type Description struct {
+	// Name of the analyzer, unique among the built-in and external analyzers.
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Inputs are the names of the collections analyzed, such as istio/networking/v1alpha3/virtualservices.
+	Inputs []string `json:"inputs"`
+	// Messages are the types of the messages reported by the analyzer.
+	Messages []MessageType `json:"messages,omitempty"`
+}
+
+// MessageType is the type of the messages reported by an external analyzer.
+type MessageType struct {
+	// Code of the messages, outside of the IST range of the built-in messages.
+	Code string `json:"code"`
+	// Level of the messages: Error, Warning or Info.
+	Level string `json:"level"`
+}
+
+// Request is the input of the analyze command.
+type Request struct {
+	Resources []Resource `json:"resources"`
+}
+
+// Resource is a resource of an input collection.
+type Resource struct {
+	Collection  string            `json:"collection"`
+	Name        string            `json:"name"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Spec        json.RawMessage   `json:"spec,omitempty"`
+}
+
+// Response is the output of the analyze command.
+type Response struct {
+	Messages []Message `json:"messages"`
+}
+
+// Message is a diagnostic message reported by an external analyzer.
+type Message struct {
+	Code string `json:"code"`
+	// Collection and Name identify the resource the message is about, in the namespace/name form.
+	Collection string `json:"collection"`
+	Name       string `json:"name,omitempty"`
+	Message    string `json:"message"`
+}
+
+// Analyzer is an analysis.Analyzer running an external analyzer.
+type Analyzer struct {
+	path     string
+	metadata analysis.Metadata
+	messages map[string]*diag.MessageType
+}
+
+var _ analysis.Analyzer = &Analyzer{}
+
+// Load describes the external analyzers at the paths. A path is either an analyzer, or a directory whose
+// executables are analyzers.
+func Load(paths []string) ([]*Analyzer, error) {
+	var files []string
+	for _, p := range paths {
+		expanded, err := analyzerFiles(p)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, expanded...)
+	}
+
+	var out []*Analyzer
+	for _, f := range files {
+		a, err := describe(f)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+func analyzerFiles(path string) ([]string, error) {
+	infos, err := ioutil.ReadDir(path)
+	if err != nil {
+		// Not a directory, it must be an analyzer.
+		return []string{path}, nil
+	}
+	var out []string
+	for _, info := range infos {
+		if info.Mode().IsRegular() && info.Mode().Perm()&0o111 != 0 {
+			out = append(out, filepath.Join(path, info.Name()))
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func describe(path string) (*Analyzer, error) {
+	out, err := run(path, DescribeCommand, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe the external analyzer %s: %v", path, err)
+	}
+	var d Description
+	if err := json.Unmarshal(out, &d); err != nil {
+		return nil, fmt.Errorf("invalid description of the external analyzer %s: %v", path, err)
+	}
+	if d.Name == "" {
+		return nil, fmt.Errorf("the external analyzer %s has no name", path)
+	}
+	a := &Analyzer{
+		path:     path,
+		metadata: analysis.Metadata{Name: d.Name, Description: d.Description},
+		messages: map[string]*diag.MessageType{},
+	}
+	for _, in := range d.Inputs {
+		if _, f := collections.All.Find(in); !f {
+			return nil, fmt.Errorf("input %q of the external analyzer %s is not a known collection", in, path)
+		}
+		a.metadata.Inputs = append(a.metadata.Inputs, collection.NewName(in))
+	}
+	levels := diag.GetUppercaseStringToLevelMap()
+	for _, m := range d.Messages {
+		level, f := levels[strings.ToUpper(m.Level)]
+		if !f {
+			return nil, fmt.Errorf("message %s of the external analyzer %s has an invalid level %q", m.Code, path, m.Level)
+		}
+		a.messages[m.Code] = diag.NewMessageType(level, m.Code, "%s")
+	}
+	return a, nil
+}
+
+// Metadata implements analysis.Analyzer
+func (a *Analyzer) Metadata() analysis.Metadata {
+	return a.metadata
+}
+
+// Analyze implements analysis.Analyzer
+func (a *Analyzer) Analyze(ctx analysis.Context) {
+	var req Request
+	for _, in := range a.metadata.Inputs {
+		ctx.ForEach(in, func(r *resource.Instance) bool {
+			res, err := toResource(in, r)
+			if err != nil {
+				scope.Analysis.Errorf("External analyzer %q skipped %s %s: %v", a.metadata.Name, in, r.Metadata.FullName, err)
+				return true
+			}
+			req.Resources = append(req.Resources, res)
+			return true
+		})
+	}
+	if ctx.Canceled() {
+		return
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		scope.Analysis.Errorf("External analyzer %q failed: %v", a.metadata.Name, err)
+		return
+	}
+	out, err := run(a.path, AnalyzeCommand, body)
+	if err != nil {
+		scope.Analysis.Errorf("External analyzer %q failed: %v", a.metadata.Name, err)
+		return
+	}
+	var resp Response
+	if err := json.Unmarshal(out, &resp); err != nil {
+		scope.Analysis.Errorf("External analyzer %q returned an invalid response: %v", a.metadata.Name, err)
+		return
+	}
+	for _, m := range resp.Messages {
+		if err := a.report(ctx, m); err != nil {
+			scope.Analysis.Errorf("External analyzer %q reported an invalid message: %v", a.metadata.Name, err)
+		}
+	}
+}
+
+func (a *Analyzer) report(ctx analysis.Context, m Message) error {
+	mt, f := a.messages[m.Code]
+	if !f {
+		return fmt.Errorf("code %q is not in the description of the analyzer", m.Code)
+	}
+	c := collection.NewName(m.Collection)
+	if !a.isInput(c) {
+		return fmt.Errorf("collection %q is not an input of the analyzer", m.Collection)
+	}
+	var r *resource.Instance
+	if m.Name != "" {
+		name, err := resource.ParseFullName(m.Name)
+		if err != nil {
+			return err
+		}
+		if r = ctx.Find(c, name); r == nil {
+			return fmt.Errorf("%s %s not found", m.Collection, m.Name)
+		}
+	}
+	ctx.Report(c, diag.NewMessage(mt, r, m.Message))
+	return nil
+}
+
+func (a *Analyzer) isInput(c collection.Name) bool {
+	for _, in := range a.metadata.Inputs {
+		if in == c {
+			return true
+		}
+	}
+	return false
+}
+
+func toResource(c collection.Name, r *resource.Instance) (Resource, error) {
+	res := Resource{
+		Collection:  c.String(),
+		Name:        r.Metadata.FullName.String(),
+		Labels:      r.Metadata.Labels,
+		Annotations: r.Metadata.Annotations,
+	}
+	if r.Message != nil {
+		spec, err := gogoprotomarshal.ToJSON(r.Message)
+		if err != nil {
+			return Resource{}, err
+		}
+		res.Spec = json.RawMessage(spec)
+	}
+	return res, nil
+}
+
+func run(path, command string, stdin []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path, command)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%v: %s", err, msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// Analyzers returns the built-in analyzers followed by the external analyzers. The names of the analyzers must be
+// unique, as they identify the analyzers in the output and the status of the resources.
+func Analyzers(builtin []analysis.Analyzer, external []*Analyzer) ([]analysis.Analyzer, error) {
+	origins := map[string]string{}
+	for _, a := range builtin {
+		origins[a.Metadata().Name] = "a built-in analyzer"
+	}
+	out := append([]analysis.Analyzer{}, builtin...)
+	for _, a := range external {
+		name := a.metadata.Name
+		if origin, f := origins[name]; f {
+			return nil, fmt.Errorf("external analyzer %s is named %q, the name of %s", a.path, name, origin)
+		}
+		origins[name] = "the external analyzer " + a.path
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+// Messages returns the message types of the external analyzers.
+func Messages(external []*Analyzer) []*diag.MessageType {
+	var out []*diag.MessageType
+	for _, a := range external {
+		for _, mt := range a.messages {
+			out = append(out, mt)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Code() < out[j].Code() })
+	return out
+}
@@ -0,0 +1,182 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package external
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/diag"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+type namedAnalyzer string
+
+func (a namedAnalyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{Name: string(a)}
+}
+
+func (a namedAnalyzer) Analyze(analysis.Context) {}
+
+type fakeContext struct {
+	resources map[collection.Name][]*resource.Instance
+	reports   []diag.Message
+}
+
+func (ctx *fakeContext) Report(_ collection.Name, m diag.Message) {
+	ctx.reports = append(ctx.reports, m)
+}
+
+func (ctx *fakeContext) Find(c collection.Name, name resource.FullName) *resource.Instance {
+	for _, r := range ctx.resources[c] {
+		if r.Metadata.FullName == name {
+			return r
+		}
+	}
+	return nil
+}
+
+func (ctx *fakeContext) Exists(c collection.Name, name resource.FullName) bool {
+	return ctx.Find(c, name) != nil
+}
+
+func (ctx *fakeContext) ForEach(c collection.Name, fn analysis.IteratorFn) {
+	for _, r := range ctx.resources[c] {
+		if !fn(r) {
+			return
+		}
+	}
+}
+
+func (ctx *fakeContext) Canceled() bool { return false }
+
+// writeAnalyzer writes an external analyzer printing the description, and saving its request before printing the
+// response when analyzing.
+func writeAnalyzer(t *testing.T, dir, name, description, response string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	script := `#!/bin/sh
+case "$1" in
+describe) echo '` + description + `' ;;
+analyze) cat > "$0.request"; echo '` + response + `' ;;
+*) echo "unknown command $1" >&2; exit 1 ;;
+esac
+`
+	if err := ioutil.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExternalAnalyzer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "external-analyzers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	vs := collections.IstioNetworkingV1Alpha3Virtualservices.Name()
+	path := writeAnalyzer(t, dir, "labels",
+		`{"name": "org.labels", "inputs": ["`+vs.String()+`"], "messages": [{"code": "ORG0001", "level": "Warning"}]}`,
+		`{"messages": [{"code": "ORG0001", "collection": "`+vs.String()+`", "name": "default/reviews", "message": "missing owner"},
+		{"code": "ORG0002", "collection": "`+vs.String()+`", "message": "undeclared code"}]}`)
+	if err := ioutil.WriteFile(filepath.Join(dir, "README.md"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := Analyzers([]analysis.Analyzer{namedAnalyzer("builtin")}, loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[1].Metadata().Name != "org.labels" ||
+		!reflect.DeepEqual(all[1].Metadata().Inputs, collection.Names{vs}) {
+		t.Fatalf("unexpected analyzers %v", all)
+	}
+	messages := Messages(loaded)
+	if len(messages) != 1 || messages[0].Code() != "ORG0001" || messages[0].Level() != diag.Warning {
+		t.Fatalf("unexpected messages %v", messages)
+	}
+	if _, err := Analyzers([]analysis.Analyzer{namedAnalyzer("org.labels")}, loaded); err == nil ||
+		!strings.Contains(err.Error(), "built-in analyzer") {
+		t.Errorf("expected a conflict with the built-in analyzer, got %v", err)
+	}
+
+	reviews := &resource.Instance{
+		Metadata: resource.Metadata{
+			FullName: resource.NewFullName("default", "reviews"),
+			Labels:   resource.StringMap{"app": "reviews"},
+		},
+		Message: &v1alpha3.VirtualService{Hosts: []string{"reviews"}},
+	}
+	ctx := &fakeContext{resources: map[collection.Name][]*resource.Instance{vs: {reviews}}}
+	loaded[0].Analyze(ctx)
+
+	if len(ctx.reports) != 1 || ctx.reports[0].Resource != reviews || ctx.reports[0].Type.Code() != "ORG0001" ||
+		!reflect.DeepEqual(ctx.reports[0].Parameters, []interface{}{"missing owner"}) {
+		t.Errorf("unexpected reports %v", ctx.reports)
+	}
+	body, err := ioutil.ReadFile(path + ".request")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req Request
+	if err := json.Unmarshal(body, &req); err != nil {
+		t.Fatal(err)
+	}
+	if len(req.Resources) != 1 || req.Resources[0].Name != "default/reviews" || req.Resources[0].Collection != vs.String() ||
+		req.Resources[0].Labels["app"] != "reviews" || string(req.Resources[0].Spec) != `{"hosts":["reviews"]}` {
+		t.Errorf("unexpected request %s", body)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "external-analyzers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, c := range map[string]struct {
+		description string
+		wantErr     string
+	}{
+		"unnamed":  {`{"inputs": []}`, "has no name"},
+		"invalid":  {`not json`, "invalid description"},
+		"unknown":  {`{"name": "org.unknown", "inputs": ["org/unknown"]}`, "not a known collection"},
+		"severity": {`{"name": "org.severity", "messages": [{"code": "ORG0001", "level": "Fatal"}]}`, "invalid level"},
+	} {
+		path := writeAnalyzer(t, dir, name, c.description, "")
+		if _, err := Load([]string{path}); err == nil || !strings.Contains(err.Error(), c.wantErr) {
+			t.Errorf("%s: got error %v, want %q", name, err, c.wantErr)
+		}
+	}
+	if _, err := Load([]string{filepath.Join(dir, "missing")}); err == nil || !strings.Contains(err.Error(), "failed to describe") {
+		t.Errorf("got error %v, want a failure to describe", err)
+	}
+}
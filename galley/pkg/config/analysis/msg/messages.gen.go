@@ -148,6 +148,38 @@ var (
 	// GatewayDuplicateCertificate defines a diag.MessageType for message "GatewayDuplicateCertificate".
 	// Description: Duplicate certificate in multiple gateways may cause 404s if clients re-use HTTP2 connections.
 	GatewayDuplicateCertificate = diag.NewMessageType(diag.Warning, "IST0138", "Duplicate certificate in multiple gateways %v may cause 404s if clients re-use HTTP2 connections.")
+
+	// PeerAuthenticationUDPPort defines a diag.MessageType for message "PeerAuthenticationUDPPort".
+	// Description: A PeerAuthentication port level mTLS setting targets a UDP port, which is not intercepted by the sidecar.
+	PeerAuthenticationUDPPort = diag.NewMessageType(diag.Warning, "IST0139", "Port level mTLS setting for port %d has no effect: it is a UDP port of pod %s, and UDP traffic is not intercepted by the sidecar.")
+
+	// SidecarIngressMtlsConflict defines a diag.MessageType for message "SidecarIngressMtlsConflict".
+	// Description: The ingress mTLS setting of a Sidecar is weaker than the port level mTLS setting of a PeerAuthentication, and is ignored.
+	SidecarIngressMtlsConflict = diag.NewMessageType(diag.Error, "IST0140", "Ingress mTLS setting %s for port %d is ignored: it is weaker than the %s port level mTLS setting of PeerAuthentication %s.")
+
+	// PeerAuthenticationPortNotExposed defines a diag.MessageType for message "PeerAuthenticationPortNotExposed".
+	// Description: A PeerAuthentication port level mTLS setting targets a port not exposed by any Service, and is ignored.
+	PeerAuthenticationPortNotExposed = diag.NewMessageType(diag.Warning, "IST0141", "Port level mTLS setting for port %d has no effect: it is not a target port of any Service selecting pod %s, and port level settings only apply to the ports of Services.")
+
+	// PeerAuthenticationPassthroughDisable defines a diag.MessageType for message "PeerAuthenticationPassthroughDisable".
+	// Description: A PeerAuthentication port level DISABLE mTLS setting targets a port not exposed by any Service, whose plaintext traffic is still subject to the mTLS mode of the workload.
+	PeerAuthenticationPassthroughDisable = diag.NewMessageType(diag.Warning, "IST0142", "Port level mTLS setting DISABLE for port %d is ignored: it is not a target port of any Service selecting pod %s, so the port is passed through with the mTLS mode of the workload, which rejects plaintext traffic if it is STRICT. Expose the port with a Service or disable mTLS for the workload.")
+
+	// ConflictingPeerAuthenticationSelectors defines a diag.MessageType for message "ConflictingPeerAuthenticationSelectors".
+	// Description: PeerAuthentications selecting the same workloads set conflicting mTLS modes, and only the oldest of them applies.
+	ConflictingPeerAuthenticationSelectors = diag.NewMessageType(diag.Warning, "IST0143", "The PeerAuthentications %v in namespace %q select the same workload pod %q with conflicting mTLS modes, and only the oldest of them applies. Merge them into a single PeerAuthentication.")
+
+	// VirtualServiceRouteWithoutTraffic defines a diag.MessageType for message "VirtualServiceRouteWithoutTraffic".
+	// Description: None of the destinations of a VirtualService route received traffic over the observed period, so the route may never match.
+	VirtualServiceRouteWithoutTraffic = diag.NewMessageType(diag.Info, "IST0144", "None of the destinations %v of route %s received traffic in the last %s, so the route may never match.")
+
+	// DestinationRuleWithoutTraffic defines a diag.MessageType for message "DestinationRuleWithoutTraffic".
+	// Description: The host of a DestinationRule received no traffic over the observed period.
+	DestinationRuleWithoutTraffic = diag.NewMessageType(diag.Info, "IST0145", "Host %s received no traffic in the last %s, so the DestinationRule may be unused.")
+
+	// PlaintextOnStrictPort defines a diag.MessageType for message "PlaintextOnStrictPort".
+	// Description: A port of a workload whose mTLS mode is STRICT rejected plaintext connections over the observed period.
+	PlaintextOnStrictPort = diag.NewMessageType(diag.Warning, "IST0146", "Port %d rejected %d plaintext connections in the last %s because its mTLS mode is STRICT. Inject sidecars into the clients, or set the mode of the port to PERMISSIVE while migrating them.")
 )
 
 // All returns a list of all known message types.
@@ -188,6 +220,14 @@ func All() []*diag.MessageType {
 		AlphaAnnotation,
 		DeploymentConflictingPorts,
 		GatewayDuplicateCertificate,
+		PeerAuthenticationUDPPort,
+		SidecarIngressMtlsConflict,
+		PeerAuthenticationPortNotExposed,
+		PeerAuthenticationPassthroughDisable,
+		ConflictingPeerAuthenticationSelectors,
+		VirtualServiceRouteWithoutTraffic,
+		DestinationRuleWithoutTraffic,
+		PlaintextOnStrictPort,
 	}
 }
 
@@ -543,3 +583,88 @@ func NewGatewayDuplicateCertificate(r *resource.Instance, gateways []string) dia
 		gateways,
 	)
 }
+
+// NewPeerAuthenticationUDPPort returns a new diag.Message based on PeerAuthenticationUDPPort.
+func NewPeerAuthenticationUDPPort(r *resource.Instance, port uint32, pod string) diag.Message {
+	return diag.NewMessage(
+		PeerAuthenticationUDPPort,
+		r,
+		port,
+		pod,
+	)
+}
+
+// NewSidecarIngressMtlsConflict returns a new diag.Message based on SidecarIngressMtlsConflict.
+func NewSidecarIngressMtlsConflict(r *resource.Instance, mode string, port uint32, peerAuthentication string, peerAuthenticationMode string) diag.Message {
+	return diag.NewMessage(
+		SidecarIngressMtlsConflict,
+		r,
+		mode,
+		port,
+		peerAuthentication,
+		peerAuthenticationMode,
+	)
+}
+
+// NewPeerAuthenticationPortNotExposed returns a new diag.Message based on PeerAuthenticationPortNotExposed.
+func NewPeerAuthenticationPortNotExposed(r *resource.Instance, port uint32, pod string) diag.Message {
+	return diag.NewMessage(
+		PeerAuthenticationPortNotExposed,
+		r,
+		port,
+		pod,
+	)
+}
+
+// NewPeerAuthenticationPassthroughDisable returns a new diag.Message based on PeerAuthenticationPassthroughDisable.
+func NewPeerAuthenticationPassthroughDisable(r *resource.Instance, port uint32, pod string) diag.Message {
+	return diag.NewMessage(
+		PeerAuthenticationPassthroughDisable,
+		r,
+		port,
+		pod,
+	)
+}
+
+// NewConflictingPeerAuthenticationSelectors returns a new diag.Message based on ConflictingPeerAuthenticationSelectors.
+func NewConflictingPeerAuthenticationSelectors(r *resource.Instance, conflictingPeerAuthentications []string, namespace string, workloadPod string) diag.Message {
+	return diag.NewMessage(
+		ConflictingPeerAuthenticationSelectors,
+		r,
+		conflictingPeerAuthentications,
+		namespace,
+		workloadPod,
+	)
+}
+
+// NewVirtualServiceRouteWithoutTraffic returns a new diag.Message based on VirtualServiceRouteWithoutTraffic.
+func NewVirtualServiceRouteWithoutTraffic(r *resource.Instance, destinations []string, route string, window string) diag.Message {
+	return diag.NewMessage(
+		VirtualServiceRouteWithoutTraffic,
+		r,
+		destinations,
+		route,
+		window,
+	)
+}
+
+// NewDestinationRuleWithoutTraffic returns a new diag.Message based on DestinationRuleWithoutTraffic.
+func NewDestinationRuleWithoutTraffic(r *resource.Instance, host string, window string) diag.Message {
+	return diag.NewMessage(
+		DestinationRuleWithoutTraffic,
+		r,
+		host,
+		window,
+	)
+}
+
+// NewPlaintextOnStrictPort returns a new diag.Message based on PlaintextOnStrictPort.
+func NewPlaintextOnStrictPort(r *resource.Instance, port uint32, connections int, window string) diag.Message {
+	return diag.NewMessage(
+		PlaintextOnStrictPort,
+		r,
+		port,
+		connections,
+		window,
+	)
+}
@@ -15,7 +15,9 @@
 package components
 
 import (
+	"istio.io/istio/galley/pkg/config/analysis"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers"
+	"istio.io/istio/galley/pkg/config/analysis/external"
 	"istio.io/istio/galley/pkg/config/processing"
 	"istio.io/istio/galley/pkg/config/processing/snapshotter"
 	"istio.io/istio/galley/pkg/config/processor"
@@ -83,7 +85,15 @@ func (p *Processing) Start() (err error) {
 	var distributor snapshotter.Distributor = snapshotter.NewMCPDistributor(p.mcpCache)
 
 	if p.args.EnableConfigAnalysis {
-		combinedAnalyzer := analyzers.AllCombined()
+		var externalAnalyzers []*external.Analyzer
+		if externalAnalyzers, err = external.Load(p.args.ExternalAnalyzers); err != nil {
+			return
+		}
+		var all []analysis.Analyzer
+		if all, err = external.Analyzers(analyzers.All(), externalAnalyzers); err != nil {
+			return
+		}
+		combinedAnalyzer := analysis.Combine("all", all...)
 		combinedAnalyzer.RemoveSkipped(colsInSnapshots, kubeResources.DisabledCollectionNames(), transformProviders)
 
 		distributor = snapshotter.NewAnalyzingDistributor(snapshotter.AnalyzingDistributorSettings{
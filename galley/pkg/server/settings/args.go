@@ -58,6 +58,10 @@ type Args struct { // nolint:maligned
 	// Enable Config Analysis service, that will analyze and update CRD status. UseOldProcessor must be set to false.
 	EnableConfigAnalysis bool
 
+	// ExternalAnalyzers are the paths of the external analyzers, or directories of external analyzers, run alongside
+	// the built-in analyzers.
+	ExternalAnalyzers []string
+
 	Snapshots       []string
 	TriggerSnapshot string
 }
@@ -307,9 +307,13 @@ func setupParameters(sidecarTemplate *inject.Templates, valuesConfig *string) (*
 		if err != nil {
 			return nil, multierror.Append(err, fmt.Errorf("loading --injectConfigFile"))
 		}
-		*sidecarTemplate = injectConfig
-	} else if *sidecarTemplate, err = getInjectConfigFromConfigMap(kubeconfig); err != nil {
-		return nil, err
+		*sidecarTemplate = injectConfig.Templates
+	} else {
+		injectConfig, err := getInjectConfigFromConfigMap(kubeconfig) // nolint: vetshadow
+		if err != nil {
+			return nil, err
+		}
+		*sidecarTemplate = injectConfig.Templates
 	}
 	if valuesFile != "" {
 		valuesConfigBytes, err := ioutil.ReadFile(valuesFile) // nolint: vetshadow
@@ -328,7 +332,7 @@ func injectSideCarIntoDeployment(client kubernetes.Interface, dep *appsv1.Deploy
 	var errs error
 	log.Debugf("updating deployment %s.%s with Istio sidecar injected",
 		dep.Name, dep.Namespace)
-	newDep, err := inject.IntoObject(sidecarTemplate, valuesConfig, revision, meshConfig, dep, warningHandler)
+	newDep, err := inject.IntoObject(&inject.Config{Templates: sidecarTemplate}, valuesConfig, revision, meshConfig, dep, warningHandler)
 	if err != nil {
 		errs = multierror.Append(errs, fmt.Errorf("failed to inject sidecar to deployment resource %s.%s for service %s.%s due to %v",
 			dep.Name, dep.Namespace, svcName, svcNamespace, err))
@@ -29,6 +29,7 @@ import (
 	"istio.io/istio/galley/pkg/config/analysis"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers"
 	"istio.io/istio/galley/pkg/config/analysis/diag"
+	"istio.io/istio/galley/pkg/config/analysis/external"
 	"istio.io/istio/galley/pkg/config/analysis/local"
 	"istio.io/istio/galley/pkg/config/analysis/msg"
 	"istio.io/istio/galley/pkg/config/processing/snapshotter"
@@ -73,6 +74,9 @@ var (
 	suppress          []string
 	analysisTimeout   time.Duration
 	recursive         bool
+	withTraffic       bool
+	trafficWindow     time.Duration
+	externalAnalyzers []string
 
 	fileExtensions = []string{".json", ".yaml", ".yml"}
 )
@@ -101,6 +105,18 @@ func Analyze() *cobra.Command {
   # and suppress MisplacedAnnotation on deployment foobar in namespace default.
   istioctl analyze -S "IST0103=Pod *.testing" -S "IST0107=Deployment foobar.default"
 
+  # Analyze the current live cluster, and check the configuration against the traffic observed over the last day
+  istioctl analyze --with-traffic --traffic-window 24h
+
+  # Analyze yaml files and report the findings to GitHub code scanning
+  istioctl analyze --use-kube=false -o sarif my-app-config/ > istio.sarif
+
+  # Analyze yaml files and report the findings as a JUnit test report for CI
+  istioctl analyze --use-kube=false -o junit my-app-config/ > istio-analyze.xml
+
+  # Analyze the current live cluster with the built-in analyzers and the external analyzers of a directory
+  istioctl analyze --external-analyzers /etc/istio/analyzers
+
   # List available analyzers
   istioctl analyze -L`,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -112,8 +128,21 @@ func Analyze() *cobra.Command {
 				}
 			}
 
+			extAnalyzers, err := external.Load(externalAnalyzers)
+			if err != nil {
+				return err
+			}
+
 			if listAnalyzers {
-				fmt.Print(AnalyzersAsString(analyzers.All()))
+				builtin := analyzers.All()
+				if withTraffic {
+					builtin = analyzers.AllWithTraffic(nil)
+				}
+				all, err := external.Analyzers(builtin, extAnalyzers)
+				if err != nil {
+					return err
+				}
+				fmt.Print(AnalyzersAsString(all))
 				return nil
 			}
 
@@ -132,7 +161,24 @@ func Analyze() *cobra.Command {
 				selectedNamespace = ""
 			}
 
-			sa := local.NewSourceAnalyzer(schema.MustGet(), analyzers.AllCombined(),
+			builtin := analyzers.All()
+			if withTraffic {
+				if !useKube {
+					return CommandParseError{fmt.Errorf("--with-traffic requires a live cluster, it cannot be used with --use-kube=false")}
+				}
+				observations, err := collectTraffic(selectedNamespace, trafficWindow)
+				if err != nil {
+					return fmt.Errorf("failed to collect the traffic of the mesh: %v", err)
+				}
+				builtin = analyzers.AllWithTraffic(observations)
+			}
+			all, err := external.Analyzers(builtin, extAnalyzers)
+			if err != nil {
+				return err
+			}
+			combinedAnalyzers := analysis.Combine("all", all...)
+
+			sa := local.NewSourceAnalyzer(schema.MustGet(), combinedAnalyzers,
 				resource.Namespace(selectedNamespace), resource.Namespace(istioNamespace), nil, true, analysisTimeout)
 
 			// Check for suppressions and add them to our SourceAnalyzer
@@ -145,7 +191,7 @@ func Analyze() *cobra.Command {
 				// Check to see if the supplied code is valid. If not, emit a
 				// warning but continue.
 				codeIsValid := false
-				for _, at := range msg.All() {
+				for _, at := range append(msg.All(), external.Messages(extAnalyzers)...) {
 					if at.Code() == parts[0] {
 						codeIsValid = true
 						break
@@ -289,6 +335,16 @@ func Analyze() *cobra.Command {
 		"The duration to wait before failing")
 	analysisCmd.PersistentFlags().BoolVarP(&recursive, "recursive", "R", false,
 		"Process directory arguments recursively. Useful when you want to analyze related manifests organized within the same directory.")
+	analysisCmd.PersistentFlags().BoolVar(&withTraffic, "with-traffic", false,
+		"Check the configuration against the traffic observed in the mesh, from the Istio metrics in Prometheus and the access logs "+
+			"of the sidecars: routes without traffic, DestinationRules of hosts without traffic and plaintext connections rejected by "+
+			"ports in STRICT mTLS mode.")
+	analysisCmd.PersistentFlags().DurationVar(&trafficWindow, "traffic-window", time.Hour,
+		"The period of the traffic checked with --with-traffic")
+	analysisCmd.PersistentFlags().StringSliceVar(&externalAnalyzers, "external-analyzers", nil,
+		"Executables implementing additional analyzers, or directories of such executables. The executables must "+
+			"print their description when run with \"describe\", and analyze the resources read from their standard input "+
+			"when run with \"analyze\".")
 	return analysisCmd
 }
 
@@ -299,7 +355,7 @@ func gatherFiles(cmd *cobra.Command, args []string) ([]local.ReaderSource, error
 
 		// Handle "-" as stdin as a special case.
 		if f == "-" {
-			if isatty.IsTerminal(os.Stdin.Fd()) && !isJSONorYAMLOutputFormat() {
+			if isatty.IsTerminal(os.Stdin.Fd()) && !isStructuredOutputFormat() {
 				fmt.Fprint(cmd.OutOrStdout(), "Reading from stdin:\n")
 			}
 			r = os.Stdin
@@ -427,6 +483,7 @@ func analyzeTargetAsString() string {
 }
 
 // TODO: Refactor output writer so that it is smart enough to know when to output what.
-func isJSONorYAMLOutputFormat() bool {
-	return msgOutputFormat == formatting.JSONFormat || msgOutputFormat == formatting.YAMLFormat
+// isStructuredOutputFormat returns true if the output is meant to be parsed, rather than read on a terminal.
+func isStructuredOutputFormat() bool {
+	return msgOutputFormat != formatting.LogFormat
 }
@@ -0,0 +1,196 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/traffic"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/kube"
+	"istio.io/pkg/log"
+)
+
+// observedRequestsQuery sums the requests and TCP connections received by each destination service and version.
+const observedRequestsQuery = `sum by (destination_service, destination_version) (increase(istio_requests_total[%[1]s]))
+or sum by (destination_service, destination_version) (increase(istio_tcp_connections_opened_total[%[1]s]))`
+
+// collectTraffic collects the traffic observed in the namespace, or in all namespaces if empty, over the window: the
+// requests received by each service from the Istio standard metrics in Prometheus, and the plaintext connections
+// rejected by the sidecars from their access logs.
+func collectTraffic(namespace string, window time.Duration) (*traffic.Observations, error) {
+	client, err := newKubeClient(kubeconfig, configContext)
+	if err != nil {
+		return nil, err
+	}
+	promAPI, fw, err := forwardPrometheus(client)
+	if err != nil {
+		return nil, err
+	}
+	defer fw.Close()
+
+	o := &traffic.Observations{Window: model.Duration(window).String()}
+	if o.Requests, err = observedRequests(promAPI, o.Window); err != nil {
+		return nil, err
+	}
+	if o.PlaintextRejections, err = plaintextRejections(client, namespace, window); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func observedRequests(promAPI promv1.API, window string) (map[string]map[string]float64, error) {
+	query := fmt.Sprintf(observedRequestsQuery, window)
+	log.Debugf("executing query: %s", query)
+	val, _, err := promAPI.Query(context.Background(), query, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("query() failure for '%s': %v", query, err)
+	}
+	v, ok := val.(model.Vector)
+	if !ok {
+		return nil, fmt.Errorf("bad metric value type returned for query")
+	}
+	out := map[string]map[string]float64{}
+	for _, s := range v {
+		svc := string(s.Metric["destination_service"])
+		if _, f := out[svc]; !f {
+			out[svc] = map[string]float64{}
+		}
+		out[svc][string(s.Metric["destination_version"])] += float64(s.Value)
+	}
+	return out, nil
+}
+
+// plaintextRejections counts the plaintext connections rejected by the sidecars of the pods, by port, from their
+// access logs over the window.
+func plaintextRejections(client kube.ExtendedClient, namespace string, window time.Duration) (
+	map[resource.FullName]map[uint32]int, error) {
+	pods, err := client.Kube().CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	since := int64(window.Seconds())
+	out := map[resource.FullName]map[uint32]int{}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != v1.PodRunning || !hasProxyContainer(&pod) {
+			continue
+		}
+		logs, err := client.Kube().CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &v1.PodLogOptions{
+			Container:    proxyContainerName,
+			SinceSeconds: &since,
+		}).DoRaw(context.TODO())
+		if err != nil {
+			log.Debugf("failed to retrieve the access logs of %s.%s: %v", pod.Name, pod.Namespace, err)
+			continue
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(logs))
+		for scanner.Scan() {
+			port, ok := plaintextRejectionPort(scanner.Text())
+			if !ok {
+				continue
+			}
+			name := resource.NewFullName(resource.Namespace(pod.Namespace), resource.LocalName(pod.Name))
+			if _, f := out[name]; !f {
+				out[name] = map[uint32]int{}
+			}
+			out[name][port]++
+		}
+	}
+	return out, nil
+}
+
+func hasProxyContainer(pod *v1.Pod) bool {
+	for _, c := range pod.Spec.Containers {
+		if c.Name == proxyContainerName {
+			return true
+		}
+	}
+	return false
+}
+
+// plaintextRejectionPort returns the destination port of the connection logged by the access log line, if the
+// connection was rejected for matching no inbound filter chain, as plaintext connections to ports in STRICT mTLS mode.
+// Both the default text and JSON access log formats are supported.
+func plaintextRejectionPort(line string) (uint32, bool) {
+	if !strings.Contains(line, "filter_chain_not_found") {
+		return 0, false
+	}
+	var address string
+	if strings.HasPrefix(line, "{") {
+		entry := struct {
+			Details string `json:"response_code_details"`
+			Address string `json:"downstream_local_address"`
+		}{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil || entry.Details != "filter_chain_not_found" {
+			return 0, false
+		}
+		address = entry.Address
+	} else {
+		// The text format ends with the downstream local and remote addresses, the requested server name and the
+		// route name.
+		fields := accessLogFields(line)
+		if len(fields) < 4 {
+			return 0, false
+		}
+		address = fields[len(fields)-4]
+	}
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return 0, false
+	}
+	p, err := strconv.ParseUint(port, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(p), true
+}
+
+// accessLogFields splits the text access log line into its space separated fields, keeping the quoted fields whole.
+func accessLogFields(line string) []string {
+	var fields []string
+	var field strings.Builder
+	quoted := false
+	for _, r := range strings.TrimSpace(line) {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			field.WriteRune(r)
+		case r == ' ' && !quoted:
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+		default:
+			field.WriteRune(r)
+		}
+	}
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
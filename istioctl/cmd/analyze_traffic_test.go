@@ -0,0 +1,62 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+)
+
+func TestPlaintextRejectionPort(t *testing.T) {
+	cases := []struct {
+		name     string
+		line     string
+		wantPort uint32
+		wantOK   bool
+	}{
+		{
+			name: "text",
+			line: `[2021-02-19T10:00:00.000Z] "- - -" 0 NR filter_chain_not_found - "-" 0 0 0 - "-" "-" "-" "-" "-" - - ` +
+				`10.0.0.5:9080 10.0.0.9:52312 - -`,
+			wantPort: 9080,
+			wantOK:   true,
+		},
+		{
+			name: "json",
+			line: `{"response_code_details":"filter_chain_not_found","downstream_local_address":"10.0.0.5:8443",` +
+				`"response_flags":"NR"}`,
+			wantPort: 8443,
+			wantOK:   true,
+		},
+		{
+			name: "accepted request",
+			line: `[2021-02-19T10:00:00.000Z] "GET /reviews HTTP/1.1" 200 - via_upstream - "-" 0 295 5 4 "-" "curl/7.64.0" ` +
+				`"8b3c" "reviews:9080" "127.0.0.1:9080" inbound|9080|| 127.0.0.1:41248 10.0.0.5:9080 10.0.0.9:52312 ` +
+				`outbound_.9080_._.reviews.default.svc.cluster.local default`,
+		},
+		{
+			name: "json with another reason",
+			line: `{"response_code_details":"via_upstream","downstream_local_address":"10.0.0.5:8443",` +
+				`"path":"/filter_chain_not_found"}`,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			port, ok := plaintextRejectionPort(tt.line)
+			if port != tt.wantPort || ok != tt.wantOK {
+				t.Errorf("got %v, %v, want %v, %v", port, ok, tt.wantPort, tt.wantOK)
+			}
+		})
+	}
+}
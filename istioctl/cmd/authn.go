@@ -0,0 +1,148 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8s_labels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+
+	clientsecurity "istio.io/client-go/pkg/apis/security/v1beta1"
+	istioclient "istio.io/client-go/pkg/clientset/versioned"
+	"istio.io/istio/istioctl/pkg/authn"
+	"istio.io/istio/istioctl/pkg/util/handlers"
+	pilotcontroller "istio.io/istio/pilot/pkg/serviceregistry/kube/controller"
+)
+
+func effectiveCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "effective <pod-name>[.<pod-namespace>]",
+		Short: "Show the effective mTLS mode of each port of a pod.",
+		Long: `Effective merges the mesh, namespace, workload and port level PeerAuthentication
+policies applied to a pod, and prints the resulting mTLS mode of each of its ports along with
+the policy that contributed the setting. The mesh level policies are read from the Istio
+namespace, which is assumed to be the mesh root namespace.`,
+		Example: `  # Show the effective mTLS mode of pod httpbin-88ddbcfdd-nt5jb:
+  istioctl x authn effective httpbin-88ddbcfdd-nt5jb.default`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("effective requires <pod-name>[.<pod-namespace>]")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			podName, ns := handlers.InferPodInfo(args[0], handlers.HandleNamespace(namespace, defaultNamespace))
+
+			client, err := interfaceFactory(kubeconfig)
+			if err != nil {
+				return err
+			}
+			pod, err := client.CoreV1().Pods(ns).Get(context.TODO(), podName, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			ports, err := workloadPorts(client, pod)
+			if err != nil {
+				return err
+			}
+
+			configClient, err := configStoreFactory()
+			if err != nil {
+				return err
+			}
+			policies, err := peerAuthentications(configClient, istioNamespace, ns)
+			if err != nil {
+				return err
+			}
+
+			authn.ComputeEffective(istioNamespace, pod, ports, policies).Print(cmd.OutOrStdout())
+			return nil
+		},
+	}
+	return cmd
+}
+
+// workloadPorts returns the ports of the pod, keyed by number. Ports targeted by a Service are named after
+// the Service port, as that is the name PeerAuthentication port names are resolved against.
+func workloadPorts(client kubernetes.Interface, pod *v1.Pod) (map[uint32]string, error) {
+	ports := map[uint32]string{}
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			if port.Protocol == "" || port.Protocol == v1.ProtocolTCP {
+				ports[uint32(port.ContainerPort)] = ""
+			}
+		}
+	}
+
+	svcs, err := client.CoreV1().Services(pod.Namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, svc := range svcs.Items {
+		if len(svc.Spec.Selector) == 0 || !k8s_labels.SelectorFromSet(svc.Spec.Selector).Matches(k8s_labels.Set(pod.Labels)) {
+			continue
+		}
+		for i := range svc.Spec.Ports {
+			svcPort := &svc.Spec.Ports[i]
+			if svcPort.Protocol != "" && svcPort.Protocol != v1.ProtocolTCP {
+				continue
+			}
+			port, err := pilotcontroller.FindPort(pod, svcPort)
+			if err != nil {
+				continue
+			}
+			ports[uint32(port)] = svcPort.Name
+		}
+	}
+	return ports, nil
+}
+
+// peerAuthentications returns the PeerAuthentication policies that may apply to workloads in the namespace.
+func peerAuthentications(client istioclient.Interface, rootNamespace, ns string) ([]*clientsecurity.PeerAuthentication, error) {
+	namespaces := []string{rootNamespace}
+	if ns != rootNamespace {
+		namespaces = append(namespaces, ns)
+	}
+	var res []*clientsecurity.PeerAuthentication
+	for _, n := range namespaces {
+		pas, err := client.SecurityV1beta1().PeerAuthentications(n).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for i := range pas.Items {
+			res = append(res, &pas.Items[i])
+		}
+	}
+	return res, nil
+}
+
+// AuthN groups commands used for inspecting the authentication policy.
+// Note: this is still under active development and is not ready for real use.
+func AuthN() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "authn",
+		Short: "Inspect Istio PeerAuthentication",
+	}
+
+	cmd.AddCommand(effectiveCmd())
+	cmd.Long += "\n\n" + ExperimentalMsg
+	return cmd
+}
@@ -0,0 +1,81 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestAuthNEffective(t *testing.T) {
+	k8sConfigs := []runtime.Object{
+		&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "httpbin",
+				Namespace: "default",
+				Labels:    map[string]string{"app": "httpbin"},
+			},
+			Spec: v1.PodSpec{
+				Containers: []v1.Container{{
+					Name:  "httpbin",
+					Ports: []v1.ContainerPort{{ContainerPort: 8080}, {ContainerPort: 9090}},
+				}},
+			},
+		},
+		&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "httpbin",
+				Namespace: "default",
+			},
+			Spec: v1.ServiceSpec{
+				Selector: map[string]string{"app": "httpbin"},
+				Ports:    []v1.ServicePort{{Name: "http", Port: 80, TargetPort: intstr.FromInt(8080)}},
+			},
+		},
+	}
+	cases := []execAndK8sConfigTestCase{
+		{
+			args:           strings.Split("x authn effective", " "),
+			expectedString: "effective requires <pod-name>[.<pod-namespace>]",
+			wantException:  true,
+		},
+		{
+			args:           strings.Split("x authn effective not-a-pod", " "),
+			expectedString: `pods "not-a-pod" not found`,
+			wantException:  true,
+		},
+		{
+			k8sConfigs: k8sConfigs,
+			args:       strings.Split("x authn effective httpbin.default", " "),
+			expectedOutput: `PORT NAME MODE       SOURCE
+*         PERMISSIVE default
+8080 http PERMISSIVE default
+9090 -    PERMISSIVE default
+`,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("case %d %s", i, strings.Join(c.args, " ")), func(t *testing.T) {
+			verifyExecAndK8sConfigTestCaseTestOutput(t, c)
+		})
+	}
+}
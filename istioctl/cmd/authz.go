@@ -19,9 +19,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 
+	clientsecurity "istio.io/client-go/pkg/apis/security/v1beta1"
 	"istio.io/istio/istioctl/pkg/authz"
 	"istio.io/istio/istioctl/pkg/util/configdump"
 	"istio.io/istio/istioctl/pkg/util/handlers"
@@ -140,6 +145,208 @@ func getConfigDumpFromPod(podName, podNamespace string) (*configdump.Wrapper, er
 	return envoyConfig, nil
 }
 
+func migrateTrustDomainCmd() *cobra.Command {
+	var migration authz.TrustDomainMigration
+	var write bool
+	cmd := &cobra.Command{
+		Use:   "migrate-trust-domain",
+		Short: "Migrate AuthorizationPolicy principals from one trust domain to another.",
+		Long: `Migrate-trust-domain reports, per namespace, how many AuthorizationPolicies still reference
+principals of the old trust domain without the corresponding principals of the new trust domain.
+With --write, the pending policies are rewritten to allow the principals of both trust domains.
+
+The migration is driven by the trust domain settings of the mesh config and of istiod: --to defaults
+to the trust domain of the mesh, and --from to the PILOT_TRUST_DOMAIN_MIGRATION_FROM environment
+variable of istiod, or else to the only trust domain alias of the mesh. A trust domain migration is
+typically done as follows:
+  1. Set the trustDomain of the mesh config to <new>, and either set PILOT_TRUST_DOMAIN_MIGRATION_FROM=<old>
+     on istiod or add <old> to the trustDomainAliases, so both trust domains are accepted.
+  2. Rewrite the policies with --write, and check that no namespace has pending policies.
+  3. Restart the workloads to get certificates of the new trust domain.
+  4. Unset PILOT_TRUST_DOMAIN_MIGRATION_FROM or remove <old> from the trustDomainAliases, and remove
+     the old principals with --write --remove-from.`,
+		Example: `  # Show the migration progress of all namespaces:
+  istioctl x authz migrate-trust-domain
+
+  # Add the principals of the new trust domain to the policies of namespace foo:
+  istioctl x authz migrate-trust-domain --from old-td --to new-td -n foo --write`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("migrate-trust-domain takes no arguments")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			meshConfig, err := getMeshConfigFromConfigMap(kubeconfig, "migrate-trust-domain")
+			if err != nil {
+				return err
+			}
+			kubeClient, err := interfaceFactory(kubeconfig)
+			if err != nil {
+				return err
+			}
+			istiodFrom, err := istiodTrustDomainMigrationFrom(kubeClient)
+			if err != nil {
+				return err
+			}
+			if err := migration.ApplyMeshConfig(meshConfig, istiodFrom); err != nil {
+				return err
+			}
+			client, err := configStoreFactory()
+			if err != nil {
+				return err
+			}
+			policies, err := client.SecurityV1beta1().AuthorizationPolicies(namespace).List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				return err
+			}
+			res := make([]*clientsecurity.AuthorizationPolicy, 0, len(policies.Items))
+			for i := range policies.Items {
+				policy := &policies.Items[i]
+				if write && migration.RewritePolicy(&policy.Spec) {
+					updated, err := client.SecurityV1beta1().AuthorizationPolicies(policy.Namespace).Update(
+						context.TODO(), policy, metav1.UpdateOptions{})
+					if err != nil {
+						return fmt.Errorf("failed to update AuthorizationPolicy %s/%s: %v", policy.Namespace, policy.Name, err)
+					}
+					cmd.Printf("updated AuthorizationPolicy %s/%s\n", policy.Namespace, policy.Name)
+					policy = updated
+				}
+				res = append(res, policy)
+			}
+			authz.PrintProgress(cmd.OutOrStdout(), migration.Progress(res))
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&migration.From, "from", "",
+		"The trust domain to migrate from. Defaults to the trust domain istiod migrates from, "+
+			"or the only trust domain alias of the mesh config")
+	cmd.Flags().StringVar(&migration.To, "to", "",
+		"The trust domain to migrate to. Defaults to the trust domain of the mesh config")
+	cmd.Flags().BoolVar(&migration.RemoveFrom, "remove-from", false,
+		"Remove the principals of the old trust domain. Only use once all workloads use the new trust domain")
+	cmd.Flags().BoolVar(&write, "write", false, "Update the pending AuthorizationPolicies in the cluster")
+	return cmd
+}
+
+// istiodTrustDomainMigrationFrom returns the PILOT_TRUST_DOMAIN_MIGRATION_FROM environment variable of
+// the istiod deployment, or an empty string if istiod is not migrating the trust domain.
+func istiodTrustDomainMigrationFrom(client kubernetes.Interface) (string, error) {
+	deployment, err := client.AppsV1().Deployments(istioNamespace).Get(context.TODO(), "istiod", metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get the istiod deployment: %v", err)
+	}
+	for _, c := range deployment.Spec.Template.Spec.Containers {
+		if c.Name != "discovery" {
+			continue
+		}
+		for _, e := range c.Env {
+			if e.Name == "PILOT_TRUST_DOMAIN_MIGRATION_FROM" {
+				return e.Value, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+func traceCmd() *cobra.Command {
+	var req authz.Request
+	var headers, claims []string
+	var port int
+	var file string
+	cmd := &cobra.Command{
+		Use:   "trace [<type>/]<name>[.<namespace>]",
+		Short: "Trace the AuthorizationPolicy rules allowing or denying a request to the pod.",
+		Long: `Trace evaluates a hypothetical request against the RBAC filters generated from the
+AuthorizationPolicies in the Envoy configuration of the pod, and prints which rule of which policy
+allowed or denied it. The request is evaluated offline, in the inbound filter chain handling its port.
+
+The request is sent over mTLS when the source principal is set. The JWT attributes of the request
+are the ones set by a RequestAuthentication validating its token.`,
+		Example: `  # Trace a GET request from the sleep service account to port 8000 of pod httpbin-88ddbcfdd-nt5jb:
+  istioctl x authz trace httpbin-88ddbcfdd-nt5jb --port 8000 --principal cluster.local/ns/default/sa/sleep \
+    --method GET --path /headers
+
+  # Trace a plaintext request with a header and a JWT claim, from an Envoy config dump file:
+  istioctl x authz trace -f httpbin_config_dump.json --port 8000 --path /admin \
+    --header x-user=alice --request-principal issuer/alice --claim groups=admin`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if (len(args) == 1) == (file != "") {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("trace requires <pod-name>[.<pod-namespace>] or --file parameter")
+			}
+			if port <= 0 {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("trace requires the --port of the request")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req.Port = uint32(port)
+			req.Headers = map[string]string{}
+			for _, h := range headers {
+				kv := strings.SplitN(h, "=", 2)
+				if len(kv) != 2 {
+					return fmt.Errorf("invalid header %q, expecting <name>=<value>", h)
+				}
+				req.Headers[kv[0]] = kv[1]
+			}
+			req.Claims = map[string][]string{}
+			for _, c := range claims {
+				kv := strings.SplitN(c, "=", 2)
+				if len(kv) != 2 {
+					return fmt.Errorf("invalid claim %q, expecting <name>=<value>", c)
+				}
+				req.Claims[kv[0]] = append(req.Claims[kv[0]], kv[1])
+			}
+
+			var configDump *configdump.Wrapper
+			var err error
+			if file != "" {
+				if configDump, err = getConfigDumpFromFile(file); err != nil {
+					return fmt.Errorf("failed to get config dump from file %s: %s", file, err)
+				}
+			} else {
+				podName, podNamespace, err := getPodName(args[0])
+				if err != nil {
+					return err
+				}
+				if configDump, err = getConfigDumpFromPod(podName, podNamespace); err != nil {
+					return fmt.Errorf("failed to get config dump from pod %s in %s: %v", podName, podNamespace, err)
+				}
+			}
+
+			tracer, err := authz.NewTracer(configDump, &req)
+			if err != nil {
+				return err
+			}
+			tracer.Trace(&req).Print(cmd.OutOrStdout())
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&file, "file", "f", "", "The json file with Envoy config dump to trace the request in")
+	cmd.Flags().IntVar(&port, "port", 0, "The destination port of the request")
+	cmd.Flags().StringVar(&req.Principal, "principal", "",
+		"The identity of the source, e.g. cluster.local/ns/default/sa/sleep. Empty for plaintext requests")
+	cmd.Flags().StringVar(&req.SourceIP, "source-ip", "", "The IP address of the source")
+	cmd.Flags().StringVar(&req.DestinationIP, "destination-ip", "", "The IP address of the pod")
+	cmd.Flags().StringVar(&req.Method, "method", "", "The HTTP method of the request")
+	cmd.Flags().StringVar(&req.Path, "path", "", "The HTTP path of the request")
+	cmd.Flags().StringVar(&req.Host, "host", "", "The HTTP host of the request")
+	cmd.Flags().StringArrayVar(&headers, "header", nil, "An HTTP header of the request, as <name>=<value>")
+	cmd.Flags().StringVar(&req.RequestPrincipal, "request-principal", "",
+		"The principal of the JWT of the request, as <issuer>/<subject>")
+	cmd.Flags().StringVar(&req.Audiences, "audiences", "", "The audiences of the JWT of the request")
+	cmd.Flags().StringVar(&req.Presenter, "presenter", "", "The authorized presenter of the JWT of the request")
+	cmd.Flags().StringArrayVar(&claims, "claim", nil,
+		"A claim of the JWT of the request, as <name>=<value>. Repeat the flag for list claims")
+	return cmd
+}
+
 // AuthZ groups commands used for inspecting and interacting the authorization policy.
 // Note: this is still under active development and is not ready for real use.
 func AuthZ() *cobra.Command {
@@ -149,6 +356,8 @@ func AuthZ() *cobra.Command {
 	}
 
 	cmd.AddCommand(checkCmd)
+	cmd.AddCommand(migrateTrustDomainCmd())
+	cmd.AddCommand(traceCmd())
 	cmd.Long += "\n\n" + ExperimentalMsg
 	return cmd
 }
@@ -0,0 +1,294 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	admit_v1 "k8s.io/api/admissionregistration/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	api_pkg_labels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/api/label"
+	"istio.io/istio/istioctl/pkg/util/handlers"
+	"istio.io/istio/pkg/kube/inject"
+)
+
+// injectionWebhookSuffix is the suffix of the names of the webhooks of the sidecar injector.
+const injectionWebhookSuffix = "sidecar-injector.istio.io"
+
+// injectionCheck is the evaluation of an injection webhook for a pod.
+type injectionCheck struct {
+	webhook  string
+	revision string
+	tag      string
+	injected bool
+	reason   string
+}
+
+// injectionDiagnosis is the evaluation of the injection of a pod by all the injection webhooks.
+type injectionDiagnosis struct {
+	checks []injectionCheck
+	// hints explain why the pod is not injected as expected.
+	hints []string
+}
+
+func checkInjectCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check-inject [<pod-name>[.<namespace>]]",
+		Short: "Explains why the sidecar is injected or not in a pod",
+		Long: `Evaluates the sidecar injection webhooks for a pod, or a pod created in a namespace, and explains the
+decision of each webhook: whether its rules, namespaceSelector and objectSelector match the pod, and whether the
+injector skips the pod because of its annotations, the host network or the injection policy. It also reports the
+injection webhook failures found in the events of the namespace.`,
+		Example: `  # Explain why the sidecar is not injected in the productpage pod
+  istioctl x check-inject productpage-v1-7f44c4d57c-ccq6n.default
+
+  # Explain whether the pods created in the bookinfo namespace are injected
+  istioctl x check-inject -n bookinfo`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := kubeClient(kubeconfig, configContext)
+			if err != nil {
+				return fmt.Errorf("failed to create k8s client: %v", err)
+			}
+			ctx := context.Background()
+			ns := handlers.HandleNamespace(namespace, defaultNamespace)
+			pod := &v1.Pod{}
+			existing := len(args) == 1
+			if existing {
+				var podName string
+				podName, ns = handlers.InferPodInfo(args[0], ns)
+				if pod, err = client.Kube().CoreV1().Pods(ns).Get(ctx, podName, metav1.GetOptions{}); err != nil {
+					return fmt.Errorf("failed to get pod %s.%s: %v", podName, ns, err)
+				}
+			} else {
+				pod.Namespace = ns
+			}
+			nsObj, err := client.Kube().CoreV1().Namespaces().Get(ctx, ns, metav1.GetOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to get namespace %s: %v", ns, err)
+			}
+			hooks, err := client.Kube().AdmissionregistrationV1().MutatingWebhookConfigurations().List(ctx, metav1.ListOptions{})
+			if err != nil {
+				return err
+			}
+			configs := injectorConfigs(ctx, client.Kube(), hooks.Items)
+			events, err := client.Kube().CoreV1().Events(ns).List(ctx, metav1.ListOptions{FieldSelector: "type=Warning"})
+			if err != nil {
+				return err
+			}
+
+			d := diagnoseInjection(pod, nsObj, hooks.Items, configs, events.Items)
+			if existing {
+				fmt.Fprintf(cmd.OutOrStdout(), "Pod %s.%s:\n", pod.Name, ns)
+				if hasSidecar(*pod) {
+					fmt.Fprintf(cmd.OutOrStdout(), "The pod has a sidecar injected by revision %q.\n",
+						pod.Labels[label.IoIstioRev.Name])
+				}
+			} else {
+				fmt.Fprintf(cmd.OutOrStdout(), "Pods created in namespace %s:\n", ns)
+			}
+			return d.print(cmd.OutOrStdout(), existing && !hasSidecar(*pod))
+		},
+	}
+	return cmd
+}
+
+// injectorConfigs returns the injection configuration of the revisions of the webhooks, by revision. The revisions
+// without injection configuration are omitted.
+func injectorConfigs(ctx context.Context, client kubernetes.Interface, hooks []admit_v1.MutatingWebhookConfiguration) map[string]*inject.Config {
+	out := map[string]*inject.Config{}
+	for _, hook := range hooks {
+		rev, err := getWebhookRevision(hook)
+		if err != nil {
+			continue
+		}
+		if _, f := out[rev]; f {
+			continue
+		}
+		name := defaultInjectConfigMapName
+		if rev != defaultRevisionName {
+			name += "-" + rev
+		}
+		cm, err := client.CoreV1().ConfigMaps(istioNamespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			continue
+		}
+		cfg, err := inject.UnmarshalConfig([]byte(cm.Data[injectConfigMapKey]))
+		if err != nil {
+			continue
+		}
+		out[rev] = &cfg
+	}
+	return out
+}
+
+// diagnoseInjection evaluates the injection webhooks for the pod, as the API server and the sidecar injector do.
+func diagnoseInjection(pod *v1.Pod, ns *v1.Namespace, hooks []admit_v1.MutatingWebhookConfiguration,
+	configs map[string]*inject.Config, events []v1.Event) *injectionDiagnosis {
+	d := &injectionDiagnosis{}
+	revisions := map[string]struct{}{}
+	for _, hook := range hooks {
+		rev, _ := getWebhookRevision(hook)
+		tag := hook.Labels[istioTagLabel]
+		for _, wh := range hook.Webhooks {
+			if !strings.HasSuffix(wh.Name, injectionWebhookSuffix) {
+				continue
+			}
+			revisions[rev] = struct{}{}
+			if tag != "" {
+				revisions[tag] = struct{}{}
+			}
+			check := injectionCheck{webhook: hook.Name + "/" + wh.Name, revision: rev, tag: tag}
+			check.injected, check.reason = evaluateInjectionWebhook(wh, pod, ns, configs[rev])
+			d.checks = append(d.checks, check)
+		}
+	}
+	sort.SliceStable(d.checks, func(i, j int) bool {
+		return d.checks[i].webhook < d.checks[j].webhook
+	})
+
+	var injecting []string
+	for _, c := range d.checks {
+		if c.injected {
+			injecting = append(injecting, c.webhook)
+		}
+	}
+	nsRev, hasRev := ns.Labels[label.IoIstioRev.Name]
+	nsInjection, hasInjection := ns.Labels[injectionLabel]
+	switch {
+	case len(d.checks) == 0:
+		d.hints = append(d.hints, "No sidecar injection webhook is installed.")
+	case len(injecting) > 1:
+		d.hints = append(d.hints, fmt.Sprintf("The pod is matched by several injection webhooks, which conflict: %s.",
+			strings.Join(injecting, ", ")))
+	case len(injecting) == 0:
+		if hasRev {
+			if _, f := revisions[nsRev]; !f {
+				d.hints = append(d.hints, fmt.Sprintf("The namespace is labeled %s=%s, but no revision or tag %q is installed.",
+					label.IoIstioRev.Name, nsRev, nsRev))
+			}
+			if hasInjection {
+				d.hints = append(d.hints, fmt.Sprintf("The namespace is labeled both %s=%s and %s=%s; the %s label takes precedence.",
+					injectionLabel, nsInjection, label.IoIstioRev.Name, nsRev, injectionLabel))
+			}
+		}
+		if !hasRev && !hasInjection && pod.Labels[label.IoIstioRev.Name] == "" {
+			d.hints = append(d.hints, fmt.Sprintf("Neither the namespace nor the pod is labeled with %s or %s.",
+				injectionLabel, label.IoIstioRev.Name))
+		}
+	}
+	for _, e := range events {
+		if strings.Contains(e.Message, injectionWebhookSuffix) {
+			d.hints = append(d.hints, fmt.Sprintf("Injection webhook failure for %s/%s: %s", strings.ToLower(e.InvolvedObject.Kind),
+				e.InvolvedObject.Name, e.Message))
+		}
+	}
+	return d
+}
+
+// evaluateInjectionWebhook returns whether the webhook injects the pod, and why.
+func evaluateInjectionWebhook(wh admit_v1.MutatingWebhook, pod *v1.Pod, ns *v1.Namespace, config *inject.Config) (bool, string) {
+	if !webhookRulesMatchPods(wh.Rules) {
+		return false, "the webhook rules do not match the creation of pods"
+	}
+	if wh.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(wh.NamespaceSelector)
+		if err != nil {
+			return false, fmt.Sprintf("the namespaceSelector is invalid: %v", err)
+		}
+		if !selector.Matches(api_pkg_labels.Set(ns.Labels)) {
+			return false, fmt.Sprintf("the namespaceSelector {%s} does not match the namespace labels {%s}", selector,
+				api_pkg_labels.Set(ns.Labels))
+		}
+	}
+	if wh.ObjectSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(wh.ObjectSelector)
+		if err != nil {
+			return false, fmt.Sprintf("the objectSelector is invalid: %v", err)
+		}
+		if !selector.Matches(api_pkg_labels.Set(pod.Labels)) {
+			return false, fmt.Sprintf("the objectSelector {%s} does not match the pod labels {%s}", selector,
+				api_pkg_labels.Set(pod.Labels))
+		}
+	}
+	if config == nil {
+		return true, "the webhook matches the pod, the injection configuration of the revision was not found"
+	}
+	injected, reason := inject.InjectionDecision(config, &pod.Spec, pod.ObjectMeta)
+	if !injected {
+		return false, "the webhook matches the pod, but the injector skips it: " + reason
+	}
+	return true, "the webhook matches the pod: " + reason
+}
+
+// webhookRulesMatchPods returns whether the rules match the creation of core v1 pods.
+func webhookRulesMatchPods(rules []admit_v1.RuleWithOperations) bool {
+	contains := func(values []string, want ...string) bool {
+		for _, v := range values {
+			for _, w := range want {
+				if v == w {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	for _, r := range rules {
+		ops := make([]string, 0, len(r.Operations))
+		for _, op := range r.Operations {
+			ops = append(ops, string(op))
+		}
+		if contains(ops, string(admit_v1.Create), string(admit_v1.OperationAll)) && contains(r.APIGroups, "", "*") &&
+			contains(r.APIVersions, "v1", "*") && contains(r.Resources, "pods", "*", "*/*") {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *injectionDiagnosis) print(writer io.Writer, missingSidecar bool) error {
+	w := new(tabwriter.Writer).Init(writer, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(w, "WEBHOOK\tREVISION\tTAG\tINJECTED\tREASON")
+	injected := false
+	for _, c := range d.checks {
+		tag := c.tag
+		if tag == "" {
+			tag = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\n", c.webhook, c.revision, tag, c.injected, c.reason)
+		injected = injected || c.injected
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if missingSidecar && injected {
+		fmt.Fprintln(writer, "The pod would be injected if created now, but has no sidecar: it was created before the "+
+			"injection was enabled, or the injection webhook failed. Restart it to inject it.")
+	}
+	for _, h := range d.hints {
+		fmt.Fprintln(writer, h)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	admit_v1 "k8s.io/api/admissionregistration/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/api/annotation"
+	"istio.io/api/label"
+	"istio.io/istio/pkg/kube/inject"
+)
+
+func TestDiagnoseInjection(t *testing.T) {
+	hook := admit_v1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "istio-sidecar-injector-1-9", Labels: map[string]string{label.IoIstioRev.Name: "1-9"}},
+		Webhooks: []admit_v1.MutatingWebhook{{
+			Name: "rev.namespace.sidecar-injector.istio.io",
+			Rules: []admit_v1.RuleWithOperations{{
+				Operations: []admit_v1.OperationType{admit_v1.Create},
+				Rule:       admit_v1.Rule{APIGroups: []string{""}, APIVersions: []string{"v1"}, Resources: []string{"pods"}},
+			}},
+			NamespaceSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: label.IoIstioRev.Name, Operator: metav1.LabelSelectorOpIn, Values: []string{"1-9"}},
+				{Key: injectionLabel, Operator: metav1.LabelSelectorOpDoesNotExist},
+			}},
+			ObjectSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: annotation.SidecarInject.Name, Operator: metav1.LabelSelectorOpNotIn, Values: []string{"false"}},
+			}},
+		}},
+	}
+	configs := map[string]*inject.Config{"1-9": {Policy: inject.InjectionPolicyEnabled}}
+	ns := func(labels map[string]string) *v1.Namespace {
+		return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test", Labels: labels}}
+	}
+	pod := func(f func(p *v1.Pod)) *v1.Pod {
+		p := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "test"}}
+		if f != nil {
+			f(p)
+		}
+		return p
+	}
+
+	cases := []struct {
+		name       string
+		pod        *v1.Pod
+		ns         *v1.Namespace
+		events     []v1.Event
+		wantInject bool
+		wantReason string
+		wantHint   string
+	}{
+		{
+			name:       "injected",
+			pod:        pod(nil),
+			ns:         ns(map[string]string{label.IoIstioRev.Name: "1-9"}),
+			wantInject: true,
+			wantReason: "the injection policy is enabled",
+		},
+		{
+			name:       "unknown revision",
+			pod:        pod(nil),
+			ns:         ns(map[string]string{label.IoIstioRev.Name: "1-10"}),
+			wantReason: "namespaceSelector",
+			wantHint:   `no revision or tag "1-10" is installed`,
+		},
+		{
+			name:       "injection label precedence",
+			pod:        pod(nil),
+			ns:         ns(map[string]string{label.IoIstioRev.Name: "1-9", injectionLabel: "enabled"}),
+			wantReason: "namespaceSelector",
+			wantHint:   "label takes precedence",
+		},
+		{
+			name: "object selector",
+			pod: pod(func(p *v1.Pod) {
+				p.Labels = map[string]string{annotation.SidecarInject.Name: "false"}
+			}),
+			ns:         ns(map[string]string{label.IoIstioRev.Name: "1-9"}),
+			wantReason: "objectSelector",
+		},
+		{
+			name: "annotation opt-out",
+			pod: pod(func(p *v1.Pod) {
+				p.Annotations = map[string]string{annotation.SidecarInject.Name: "false"}
+			}),
+			ns:         ns(map[string]string{label.IoIstioRev.Name: "1-9"}),
+			wantReason: "the injector skips it: the pod has the annotation",
+		},
+		{
+			name: "host network",
+			pod: pod(func(p *v1.Pod) {
+				p.Spec.HostNetwork = true
+			}),
+			ns:         ns(map[string]string{label.IoIstioRev.Name: "1-9"}),
+			wantReason: "host network",
+		},
+		{
+			name: "webhook failure",
+			pod:  pod(nil),
+			ns:   ns(map[string]string{label.IoIstioRev.Name: "1-9"}),
+			events: []v1.Event{{
+				InvolvedObject: v1.ObjectReference{Kind: "ReplicaSet", Name: "app-5d4f"},
+				Message: `Error creating: Internal error occurred: failed calling webhook "rev.namespace.sidecar-injector.istio.io": ` +
+					"context deadline exceeded",
+			}},
+			wantInject: true,
+			wantReason: "the injection policy is enabled",
+			wantHint:   "Injection webhook failure for replicaset/app-5d4f",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			d := diagnoseInjection(tt.pod, tt.ns, []admit_v1.MutatingWebhookConfiguration{hook}, configs, tt.events)
+			if len(d.checks) != 1 {
+				t.Fatalf("got checks %+v, want one", d.checks)
+			}
+			c := d.checks[0]
+			if c.injected != tt.wantInject || !strings.Contains(c.reason, tt.wantReason) {
+				t.Errorf("got injected %t (%s), want %t (%s)", c.injected, c.reason, tt.wantInject, tt.wantReason)
+			}
+			if tt.wantHint != "" && !strings.Contains(strings.Join(d.hints, "\n"), tt.wantHint) {
+				t.Errorf("got hints %v, want %q", d.hints, tt.wantHint)
+			}
+		})
+	}
+}
@@ -39,7 +39,9 @@ import (
 
 	"istio.io/api/networking/v1alpha3"
 	clientnetworking "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	clientsecurity "istio.io/client-go/pkg/apis/security/v1beta1"
 	istioclient "istio.io/client-go/pkg/clientset/versioned"
+	"istio.io/istio/istioctl/pkg/authn"
 	"istio.io/istio/istioctl/pkg/clioptions"
 	"istio.io/istio/istioctl/pkg/util/configdump"
 	"istio.io/istio/istioctl/pkg/util/handlers"
@@ -81,7 +83,9 @@ func podDescribeCmd() *cobra.Command {
 		Use:   "pod <pod>",
 		Short: "Describe pods and their Istio configuration [kube-only]",
 		Long: `Analyzes pod, its Services, DestinationRules, and VirtualServices and reports
-the configuration objects that affect that pod.`,
+the configuration objects that affect that pod. For each inbound port of the pod, it reports the
+effective mTLS mode and the PeerAuthentication setting it, whether the port has its own filter
+chain or is passed through, and the AuthorizationPolicies applying to it.`,
 		Example: `  istioctl experimental describe pod productpage-v1-c7765c886-7zzd4`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if len(args) != 1 {
@@ -139,11 +143,18 @@ the configuration objects that affect that pod.`,
 
 			podsLabels := []k8s_labels.Set{k8s_labels.Set(pod.ObjectMeta.Labels)}
 			fmt.Fprintf(writer, "--------------------\n")
-			err = describePodServices(writer, kubeClient, configClient, pod, matchingServices, podsLabels)
+			err = describePodServices(writer, kubeClient, configClient, pod, matchingServices, podsLabels, false)
 			if err != nil {
 				return err
 			}
 
+			if isMeshed(pod) {
+				fmt.Fprintf(writer, "--------------------\n")
+				if err := describeInboundSecurity(writer, client, kubeClient, configClient, pod); err != nil {
+					return err
+				}
+			}
+
 			// TODO find sidecar configs that select this workload and render them
 
 			// Now look for ingress gateways
@@ -1085,7 +1096,7 @@ the configuration objects that affect that service.`,
 			// Only consider the service invoked with this command, not other services that might select the pod
 			svcs := []v1.Service{*svc}
 
-			err = describePodServices(writer, kubeClient, configClient, &pod, svcs, podsLabels)
+			err = describePodServices(writer, kubeClient, configClient, &pod, svcs, podsLabels, true)
 			if err != nil {
 				return err
 			}
@@ -1101,7 +1112,9 @@ the configuration objects that affect that service.`,
 	return cmd
 }
 
-func describePodServices(writer io.Writer, kubeClient kube.ExtendedClient, configClient istioclient.Interface, pod *v1.Pod, matchingServices []v1.Service, podsLabels []k8s_labels.Set) error { // nolint: lll
+// describePodServices prints the Istio configuration of the services of the pod. The RBAC policies of each port are
+// only printed when showRBAC is set, as the pod description prints them along with the rest of the inbound security.
+func describePodServices(writer io.Writer, kubeClient kube.ExtendedClient, configClient istioclient.Interface, pod *v1.Pod, matchingServices []v1.Service, podsLabels []k8s_labels.Set, showRBAC bool) error { // nolint: lll
 	var err error
 
 	byConfigDump, err := kubeClient.EnvoyDo(context.TODO(), pod.ObjectMeta.Name, pod.ObjectMeta.Namespace, "GET", "config_dump", nil)
@@ -1165,6 +1178,9 @@ func describePodServices(writer io.Writer, kubeClient kube.ExtendedClient, confi
 				}
 			}
 
+			if !showRBAC {
+				continue
+			}
 			policies, err := getIstioRBACPolicies(&cd, port.Port)
 			if err != nil {
 				log.Errorf("error getting rbac policies: %v", err)
@@ -1183,6 +1199,91 @@ func describePodServices(writer io.Writer, kubeClient kube.ExtendedClient, confi
 	return nil
 }
 
+// describeInboundSecurity prints, for each inbound port of the pod, the effective mTLS mode and the PeerAuthentication
+// setting it, whether the port has its own filter chain or is passed through, and the AuthorizationPolicies applying
+// to it.
+func describeInboundSecurity(writer io.Writer, client kubernetes.Interface, kubeClient kube.ExtendedClient,
+	configClient istioclient.Interface, pod *v1.Pod) error {
+	ports, err := workloadPorts(client, pod)
+	if err != nil {
+		return err
+	}
+	pas, err := peerAuthentications(configClient, istioNamespace, pod.Namespace)
+	if err != nil {
+		return err
+	}
+	aps, err := authorizationPolicies(configClient, istioNamespace, pod.Namespace)
+	if err != nil {
+		return err
+	}
+
+	// Without the configuration of the proxy, the inbound filter chains of the ports are unknown.
+	var servicePorts map[uint32]bool
+	byConfigDump, err := kubeClient.EnvoyDo(context.TODO(), pod.ObjectMeta.Name, pod.ObjectMeta.Namespace, "GET", "config_dump", nil)
+	if err == nil {
+		cd := configdump.Wrapper{}
+		if err = cd.UnmarshalJSON(byConfigDump); err == nil {
+			servicePorts, err = getInboundServicePorts(&cd)
+		}
+	}
+	if err != nil {
+		log.Errorf("failed to get the inbound filter chains of %s: %v", kname(pod.ObjectMeta), err)
+	}
+
+	fmt.Fprintf(writer, "Inbound security:\n")
+	authn.ComputeInbound(istioNamespace, pod, ports, pas, aps, servicePorts).Print(writer)
+	return nil
+}
+
+// getInboundServicePorts returns the ports with their own filter chain in the inbound listener, the other ports being
+// handled by the passthrough filter chains.
+func getInboundServicePorts(cd *configdump.Wrapper) (map[uint32]bool, error) {
+	listeners, err := cd.GetListenerConfigDump()
+	if err != nil {
+		return nil, err
+	}
+	ports := map[uint32]bool{}
+	for _, l := range listeners.DynamicListeners {
+		if l.ActiveState == nil {
+			continue
+		}
+		// Support v2 or v3 in config dump. See ads.go:RequestedTypes for more info.
+		l.ActiveState.Listener.TypeUrl = v3.ListenerType
+		listenerTyped := &listener.Listener{}
+		if err := ptypes.UnmarshalAny(l.ActiveState.Listener, listenerTyped); err != nil {
+			return nil, err
+		}
+		if listenerTyped.Name != pilot_v1alpha3.VirtualInboundListenerName {
+			continue
+		}
+		for _, filterChain := range listenerTyped.FilterChains {
+			if port := filterChain.GetFilterChainMatch().GetDestinationPort(); port != nil {
+				ports[port.GetValue()] = true
+			}
+		}
+	}
+	return ports, nil
+}
+
+// authorizationPolicies returns the AuthorizationPolicies that may apply to workloads in the namespace.
+func authorizationPolicies(client istioclient.Interface, rootNamespace, ns string) ([]*clientsecurity.AuthorizationPolicy, error) {
+	namespaces := []string{rootNamespace}
+	if ns != rootNamespace {
+		namespaces = append(namespaces, ns)
+	}
+	var res []*clientsecurity.AuthorizationPolicy
+	for _, n := range namespaces {
+		aps, err := client.SecurityV1beta1().AuthorizationPolicies(n).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for i := range aps.Items {
+			res = append(res, &aps.Items[i])
+		}
+	}
+	return res, nil
+}
+
 func containerReady(pod *v1.Pod, containerName string) (bool, error) {
 	for _, containerStatus := range pod.Status.ContainerStatuses {
 		if containerStatus.Name == containerName {
@@ -21,6 +21,8 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/ghodss/yaml"
 	"github.com/hashicorp/go-multierror"
@@ -100,21 +102,24 @@ func getValuesFromConfigMap(kubeconfig string) (string, error) {
 	return valuesData, nil
 }
 
-func readInjectConfigFile(f []byte) (inject.Templates, error) {
+func readInjectConfigFile(f []byte) (*inject.Config, error) {
 	var injectConfig inject.Config
 	err := yaml.Unmarshal(f, &injectConfig)
 	if err != nil || len(injectConfig.Templates) == 0 {
 		// This must be a direct template, instead of an inject.Config. We support both formats
-		return map[string]string{inject.SidecarTemplateName: string(f)}, nil
+		return &inject.Config{
+			Templates:        map[string]string{inject.SidecarTemplateName: string(f)},
+			DefaultTemplates: []string{inject.SidecarTemplateName},
+		}, nil
 	}
 	cfg, err := inject.UnmarshalConfig(f)
 	if err != nil {
 		return nil, err
 	}
-	return cfg.Templates, err
+	return &cfg, err
 }
 
-func getInjectConfigFromConfigMap(kubeconfig string) (inject.Templates, error) {
+func getInjectConfigFromConfigMap(kubeconfig string) (*inject.Config, error) {
 	client, err := createInterface(kubeconfig)
 	if err != nil {
 		return nil, err
@@ -140,7 +145,7 @@ func getInjectConfigFromConfigMap(kubeconfig string) (inject.Templates, error) {
 			injectConfigMapName, err)
 	}
 	log.Debugf("using inject template from configmap %q", injectConfigMapName)
-	return injectConfig.Templates, nil
+	return &injectConfig, nil
 }
 
 func validateFlags() error {
@@ -154,19 +159,57 @@ func validateFlags() error {
 	if meshConfigFile == "" && meshConfigMapName == "" {
 		err = multierror.Append(err, errors.New("--meshConfigFile or --meshConfigMapName must be set"))
 	}
+	switch inject.TemplateConflictPolicy(injectTemplateConflicts) {
+	case "", inject.TemplateConflictsWarn, inject.TemplateConflictsDeny, inject.TemplateConflictsAllow:
+	default:
+		err = multierror.Append(err, fmt.Errorf("invalid --templateConflicts %q, expected one of %s, %s or %s",
+			injectTemplateConflicts, inject.TemplateConflictsWarn, inject.TemplateConflictsDeny, inject.TemplateConflictsAllow))
+	}
 	return err
 }
 
+// composeTemplates adds the templates of the files to the injection configuration, each named after its file name
+// without extension, and sets the templates applied in order to the pods which do not select their templates with
+// the inject.istio.io/templates annotation: the given templates if set, otherwise the default templates of the
+// configuration followed by the templates of the files.
+func composeTemplates(cfg *inject.Config, files, names []string, conflicts string) error {
+	var added []string
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		if _, f := cfg.Templates[name]; f {
+			return fmt.Errorf("template %q of %s is already defined", name, file)
+		}
+		cfg.Templates[name] = string(b)
+		added = append(added, name)
+	}
+	if len(names) > 0 {
+		cfg.DefaultTemplates = names
+	} else {
+		cfg.DefaultTemplates = append(cfg.DefaultTemplates, added...)
+	}
+	if conflicts != "" {
+		cfg.TemplateConflicts = inject.TemplateConflictPolicy(conflicts)
+	}
+	return nil
+}
+
 var (
 	emitTemplate bool
 
-	inFilename          string
-	outFilename         string
-	meshConfigFile      string
-	meshConfigMapName   string
-	valuesFile          string
-	injectConfigFile    string
-	injectConfigMapName string
+	inFilename              string
+	outFilename             string
+	meshConfigFile          string
+	meshConfigMapName       string
+	valuesFile              string
+	injectConfigFile        string
+	injectConfigMapName     string
+	injectTemplateFiles     []string
+	injectTemplates         []string
+	injectTemplateConflicts string
 )
 
 const (
@@ -215,6 +258,13 @@ kube-inject on deployments to get the most up-to-date changes.
     --injectConfigFile /tmp/inj-template.tmpl \
     --meshConfigFile /tmp/mesh.yaml \
     --valuesFile /tmp/values.json
+
+  # Overlay custom templates on the sidecar template, in order, failing if they set conflicting values
+  istioctl kube-inject -f deployment.yaml --templateFile org-policy.yaml --templateFile team-overrides.yaml \
+    --templateConflicts deny
+
+  # Apply the templates of the injection configuration in order
+  istioctl kube-inject -f deployment.yaml --templates sidecar,org-policy
 `,
 		RunE: func(c *cobra.Command, _ []string) (err error) {
 			if err = validateFlags(); err != nil {
@@ -276,20 +326,21 @@ kube-inject on deployments to get the most up-to-date changes.
 				}
 			}
 
-			var sidecarTemplate inject.Templates
+			var injectConfig *inject.Config
 			if injectConfigFile != "" {
 				injectionConfig, err := ioutil.ReadFile(injectConfigFile) // nolint: vetshadow
 				if err != nil {
 					return err
 				}
-				injectConfig, err := readInjectConfigFile(injectionConfig)
-				if err != nil {
+				if injectConfig, err = readInjectConfigFile(injectionConfig); err != nil {
 					return multierror.Append(err, fmt.Errorf("loading --injectConfigFile"))
 				}
-				sidecarTemplate = injectConfig
-			} else if sidecarTemplate, err = getInjectConfigFromConfigMap(kubeconfig); err != nil {
+			} else if injectConfig, err = getInjectConfigFromConfigMap(kubeconfig); err != nil {
 				return err
 			}
+			if err = composeTemplates(injectConfig, injectTemplateFiles, injectTemplates, injectTemplateConflicts); err != nil {
+				return multierror.Append(err, fmt.Errorf("loading --templateFile"))
+			}
 
 			var valuesConfig string
 			if valuesFile != "" {
@@ -304,8 +355,11 @@ kube-inject on deployments to get the most up-to-date changes.
 
 			if emitTemplate {
 				cfg := inject.Config{
-					Policy:    inject.InjectionPolicyEnabled,
-					Templates: sidecarTemplate,
+					Policy:            inject.InjectionPolicyEnabled,
+					DefaultTemplates:  injectConfig.DefaultTemplates,
+					Templates:         injectConfig.Templates,
+					Aliases:           injectConfig.Aliases,
+					TemplateConflicts: injectConfig.TemplateConflicts,
 				}
 				out, err := yaml.Marshal(&cfg)
 				if err != nil {
@@ -316,7 +370,7 @@ kube-inject on deployments to get the most up-to-date changes.
 			}
 
 			var warnings []string
-			retval := inject.IntoResourceFile(sidecarTemplate, valuesConfig, revision, meshConfig,
+			retval := inject.IntoResourceFile(injectConfig, valuesConfig, revision, meshConfig,
 				reader, writer, func(warning string) {
 					warnings = append(warnings, warning)
 				})
@@ -343,6 +397,16 @@ kube-inject on deployments to get the most up-to-date changes.
 		"Injection configuration filename. Cannot be used with --injectConfigMapName")
 	injectCmd.PersistentFlags().StringVar(&valuesFile, "valuesFile", "",
 		"injection values configuration filename.")
+	injectCmd.PersistentFlags().StringArrayVar(&injectTemplateFiles, "templateFile", nil,
+		"Additional injection template filename, named after the filename without extension. Can be repeated; "+
+			"the templates are applied in order after the default templates unless --templates is set")
+	injectCmd.PersistentFlags().StringSliceVar(&injectTemplates, "templates", nil,
+		"Comma separated list of the injection templates applied in order, replacing the default templates. "+
+			"The inject.istio.io/templates annotation of the pods takes precedence")
+	injectCmd.PersistentFlags().StringVar(&injectTemplateConflicts, "templateConflicts", "",
+		"How templates setting a field to a different value than an earlier template are handled: "+
+			"one of warn, deny or allow. Defaults to the policy of the injection configuration, warn if unset. "+
+			"The inject.istio.io/template-conflicts annotation of the pods takes precedence")
 
 	injectCmd.PersistentFlags().BoolVar(&emitTemplate, "emitTemplate", false,
 		"Emit sidecar template based on parameterized flags")
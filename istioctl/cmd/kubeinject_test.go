@@ -48,6 +48,44 @@ func TestKubeInject(t *testing.T) {
 				" "),
 			goldenFilename: "testdata/deployment/hello.yaml.injected",
 		},
+		{ // case 4
+			args: strings.Split(
+				"kube-inject --meshConfigFile testdata/mesh-config.yaml"+
+					" --injectConfigFile testdata/inject-config.yaml -f testdata/deployment/hello.yaml"+
+					" --valuesFile testdata/inject-values.yaml --templateFile testdata/inject-templates/org-policy.yaml"+
+					" --templateFile testdata/inject-templates/team-overrides.yaml",
+				" "),
+			goldenFilename: "testdata/deployment/hello-templates.yaml.injected",
+		},
+		{ // case 5
+			args: strings.Split(
+				"kube-inject --meshConfigFile testdata/mesh-config.yaml"+
+					" --injectConfigFile testdata/inject-config.yaml -f testdata/deployment/hello.yaml"+
+					" --valuesFile testdata/inject-values.yaml --templateFile testdata/inject-templates/org-policy.yaml"+
+					" --templateFile testdata/inject-templates/policy-relaxed.yaml --templateConflicts deny",
+				" "),
+			expectedRegexp: regexp.MustCompile(`conflicting injection templates: metadata.labels.policy is set to "strict" ` +
+				`by template "org-policy" and to "relaxed" by template "policy-relaxed"`),
+			wantException: true,
+		},
+		{ // case 6
+			args: strings.Split(
+				"kube-inject --meshConfigFile testdata/mesh-config.yaml"+
+					" --injectConfigFile testdata/inject-config.yaml -f testdata/deployment/hello.yaml"+
+					" --valuesFile testdata/inject-values.yaml --templateFile testdata/inject-templates/org-policy.yaml"+
+					" --templates sidecar,missing",
+				" "),
+			expectedRegexp: regexp.MustCompile(`requested template "missing" not found`),
+			wantException:  true,
+		},
+		{ // case 7
+			args: strings.Split(
+				"kube-inject --meshConfigFile testdata/mesh-config.yaml -f testdata/deployment/hello.yaml"+
+					" --templateConflicts ignore",
+				" "),
+			expectedRegexp: regexp.MustCompile(`invalid --templateConflicts "ignore"`),
+			wantException:  true,
+		},
 	}
 
 	for i, c := range cases {
@@ -30,6 +30,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"istio.io/istio/istioctl/pkg/clioptions"
+	"istio.io/istio/pkg/kube"
 	"istio.io/pkg/log"
 )
 
@@ -92,49 +93,59 @@ func run(c *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to create k8s client: %v", err)
 	}
 
+	promAPI, fw, err := forwardPrometheus(client)
+	if err != nil {
+		return err
+	}
+	// Close the forwarder either when we exit or when an this processes is interrupted.
+	defer fw.Close()
+	closePortForwarderOnInterrupt(fw)
+
+	printHeader(c.OutOrStdout())
+
+	workloads := args
+	for _, workload := range workloads {
+		sm, err := metrics(promAPI, workload)
+		if err != nil {
+			return fmt.Errorf("could not build metrics for workload '%s': %v", workload, err)
+		}
+
+		printMetrics(c.OutOrStdout(), sm)
+	}
+	return nil
+}
+
+// forwardPrometheus starts a port forward to the Prometheus pod of the istio system namespace, and returns the
+// Prometheus API served through it. The caller must close the forwarder.
+func forwardPrometheus(client kube.ExtendedClient) (promv1.API, kube.PortForwarder, error) {
 	pl, err := client.PodsForSelector(context.TODO(), istioNamespace, "app=prometheus")
 	if err != nil {
-		return fmt.Errorf("not able to locate Prometheus pod: %v", err)
+		return nil, nil, fmt.Errorf("not able to locate Prometheus pod: %v", err)
 	}
 
 	if len(pl.Items) < 1 {
-		return errors.New("no Prometheus pods found")
+		return nil, nil, errors.New("no Prometheus pods found")
 	}
 
 	// only use the first pod in the list
 	promPod := pl.Items[0]
 	fw, err := client.NewPortForwarder(promPod.Name, istioNamespace, "", 0, 9090)
 	if err != nil {
-		return fmt.Errorf("could not build port forwarder for prometheus: %v", err)
+		return nil, nil, fmt.Errorf("could not build port forwarder for prometheus: %v", err)
 	}
 
 	if err = fw.Start(); err != nil {
-		return fmt.Errorf("failure running port forward process: %v", err)
+		return nil, nil, fmt.Errorf("failure running port forward process: %v", err)
 	}
 
-	// Close the forwarder either when we exit or when an this processes is interrupted.
-	defer fw.Close()
-	closePortForwarderOnInterrupt(fw)
-
 	log.Debugf("port-forward to prometheus pod ready")
 
 	promAPI, err := prometheusAPI(fmt.Sprintf("http://%s", fw.Address()))
 	if err != nil {
-		return fmt.Errorf("failure running port forward process: %v", err)
-	}
-
-	printHeader(c.OutOrStdout())
-
-	workloads := args
-	for _, workload := range workloads {
-		sm, err := metrics(promAPI, workload)
-		if err != nil {
-			return fmt.Errorf("could not build metrics for workload '%s': %v", workload, err)
-		}
-
-		printMetrics(c.OutOrStdout(), sm)
+		fw.Close()
+		return nil, nil, fmt.Errorf("failure running port forward process: %v", err)
 	}
-	return nil
+	return promAPI, fw, nil
 }
 
 func prometheusAPI(address string) (promv1.API, error) {
@@ -21,13 +21,18 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"os/signal"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/ghodss/yaml"
 	"github.com/spf13/cobra"
 
 	"istio.io/istio/istioctl/pkg/util/handlers"
+	"istio.io/istio/istioctl/pkg/writer/compare"
 	"istio.io/istio/istioctl/pkg/writer/envoy/clusters"
 	"istio.io/istio/istioctl/pkg/writer/envoy/configdump"
 	"istio.io/istio/pilot/pkg/model"
@@ -38,6 +43,7 @@ import (
 const (
 	jsonOutput    = "json"
 	summaryOutput = "short"
+	chainOutput   = "chain"
 )
 
 var (
@@ -164,6 +170,14 @@ func setupPodConfigdumpWriter(podName, podNamespace string, out io.Writer) (*con
 }
 
 func setupFileConfigdumpWriter(filename string, out io.Writer) (*configdump.ConfigWriter, error) {
+	data, err := readConfigFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return setupConfigdumpEnvoyConfigWriter(data, out)
+}
+
+func readConfigFile(filename string) ([]byte, error) {
 	file := os.Stdin
 	if filename != "-" {
 		var err error
@@ -177,11 +191,21 @@ func setupFileConfigdumpWriter(filename string, out io.Writer) (*configdump.Conf
 			log.Errorf("failed to close %s: %s", filename, err)
 		}
 	}()
-	data, err := ioutil.ReadAll(file)
+	return ioutil.ReadAll(file)
+}
+
+func getPodConfigDump(podName, podNamespace string) ([]byte, error) {
+	kubeClient, err := kubeClient(kubeconfig, configContext)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to create k8s client: %v", err)
 	}
-	return setupConfigdumpEnvoyConfigWriter(data, out)
+	// The endpoints are only included in the config dump when requested.
+	path := "config_dump?include_eds"
+	debug, err := kubeClient.EnvoyDo(context.TODO(), podName, podNamespace, "GET", path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute command on %s.%s sidecar: %v", podName, podNamespace, err)
+	}
+	return debug, nil
 }
 
 func setupConfigdumpEnvoyConfigWriter(debug []byte, out io.Writer) (*configdump.ConfigWriter, error) {
@@ -414,11 +438,13 @@ func listenerConfigCmd() *cobra.Command {
 func logCmd() *cobra.Command {
 	var podName, podNamespace string
 	var podNames []string
+	var duration time.Duration
 
 	logCmd := &cobra.Command{
 		Use:   "log [<type>/]<name>[.<namespace>]",
 		Short: "(experimental) Retrieves logging levels of the Envoy in the specified pod",
-		Long:  "(experimental) Retrieve information about logging levels of the Envoy instance in the specified pod, and update optionally",
+		Long: "(experimental) Retrieve information about logging levels of the Envoy instance in the specified pod, " +
+			"or in all the pods matching the selector, and update optionally",
 		Example: `  # Retrieve information about logging levels for a given pod from Envoy.
   istioctl proxy-config log <pod-name[.namespace]>
 
@@ -430,6 +456,12 @@ func logCmd() *cobra.Command {
 
   # Reset levels of all the loggers to default value (warning).
   istioctl proxy-config log <pod-name[.namespace]> -r
+
+  # Update levels of the specified loggers of all the pods matching the selector, reporting the result of each pod.
+  istioctl proxy-config log --selector app=foo -n ns --level rbac:debug
+
+  # Update levels of the specified loggers for 10 minutes, then revert them to their previous levels.
+  istioctl proxy-config log --selector app=foo -n ns --level rbac:debug --duration 10m
 `,
 		Aliases: []string{"o"},
 		Args: func(cmd *cobra.Command, args []string) error {
@@ -441,31 +473,32 @@ func logCmd() *cobra.Command {
 				cmd.Println(cmd.UsageString())
 				return fmt.Errorf("--level cannot be combined with --reset")
 			}
+			if duration < 0 || (duration > 0 && loggerLevelString == "") {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("--duration must be positive and requires --level")
+			}
 			return nil
 		},
 		RunE: func(c *cobra.Command, args []string) error {
 			var err error
-			var loggerNames []string
+			var targets []logTarget
 			if labelSelector != "" {
 				if podNames, podNamespace, err = getPodNameBySelector(labelSelector); err != nil {
 					return err
 				}
 				for _, pod := range podNames {
-					name, err = setupEnvoyLogConfig("", pod, podNamespace)
-					loggerNames = append(loggerNames, name)
-				}
-				if err != nil {
-					return err
+					targets = append(targets, logTarget{name: pod, namespace: podNamespace})
 				}
 			} else {
 				if podName, podNamespace, err = getPodName(args[0]); err != nil {
 					return err
 				}
-				name, err := setupEnvoyLogConfig("", podName, podNamespace)
-				loggerNames = append(loggerNames, name)
-				if err != nil {
-					return err
-				}
+				targets = append(targets, logTarget{name: podName, namespace: podNamespace})
+			}
+			// The loggers are checked against the loggers of the first pod.
+			loggerNames, err := setupEnvoyLogConfig("", targets[0].name, targets[0].namespace)
+			if err != nil {
+				return err
 			}
 
 			destLoggerLevels := map[string]Level{}
@@ -494,10 +527,8 @@ func logCmd() *cobra.Command {
 						}
 					} else {
 						loggerLevel := regexp.MustCompile(`[:=]`).Split(ol, 2)
-						for _, logName := range loggerNames {
-							if !strings.Contains(logName, loggerLevel[0]) {
-								return fmt.Errorf("unrecognized logger name: %v", loggerLevel[0])
-							}
+						if !strings.Contains(loggerNames, loggerLevel[0]) {
+							return fmt.Errorf("unrecognized logger name: %v", loggerLevel[0])
 						}
 						level, ok := stringToLevel[loggerLevel[1]]
 						if !ok {
@@ -508,23 +539,73 @@ func logCmd() *cobra.Command {
 				}
 			}
 
-			var resp string
-			if len(destLoggerLevels) == 0 {
-				resp, err = setupEnvoyLogConfig("", podName, podNamespace)
-			} else {
+			// The parameters of the requests to the logging endpoint, applied in order.
+			params := []string{""}
+			if len(destLoggerLevels) > 0 {
+				params = nil
 				if ll, ok := destLoggerLevels[defaultLoggerName]; ok {
 					// update levels of all loggers first
-					resp, err = setupEnvoyLogConfig(defaultLoggerName+"="+levelToString[ll], podName, podNamespace)
+					params = append(params, defaultLoggerName+"="+levelToString[ll])
 					delete(destLoggerLevels, defaultLoggerName)
 				}
+				var loggers []string
 				for lg, ll := range destLoggerLevels {
-					resp, err = setupEnvoyLogConfig(lg+"="+levelToString[ll], podName, podNamespace)
+					loggers = append(loggers, lg+"="+levelToString[ll])
 				}
+				sort.Strings(loggers)
+				params = append(params, loggers...)
 			}
-			if err != nil {
-				return err
+
+			var mu sync.Mutex
+			previous := map[logTarget]map[string]string{}
+			results := forEachLogTarget(targets, func(t logTarget) (string, error) {
+				if duration > 0 {
+					current, err := setupEnvoyLogConfig("", t.name, t.namespace)
+					if err != nil {
+						return "", err
+					}
+					mu.Lock()
+					previous[t] = parseLoggerLevels(current)
+					mu.Unlock()
+				}
+				var resp string
+				for _, p := range params {
+					r, err := setupEnvoyLogConfig(p, t.name, t.namespace)
+					if err != nil {
+						return "", err
+					}
+					resp = r
+				}
+				return resp, nil
+			})
+
+			if labelSelector == "" && duration == 0 {
+				if results[0].err != nil {
+					return results[0].err
+				}
+				_, _ = fmt.Fprint(c.OutOrStdout(), results[0].resp)
+				return nil
+			}
+			failed := printLogResults(c.OutOrStdout(), results, params[0] == "", "log levels updated")
+			if duration > 0 {
+				// Only the pods which were updated are reverted.
+				var updated []logTarget
+				for _, r := range results {
+					if r.err == nil {
+						updated = append(updated, r.target)
+					}
+				}
+				if len(updated) > 0 {
+					_, _ = fmt.Fprintf(c.OutOrStdout(), "Reverting the log levels in %v, interrupt to revert them now\n", duration)
+					waitForRevert(duration)
+					failed += printLogResults(c.OutOrStdout(), forEachLogTarget(updated, func(t logTarget) (string, error) {
+						return "", revertLogLevels(t, previous[t])
+					}), false, "log levels reverted")
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d pods failed", failed, len(targets))
 			}
-			_, _ = fmt.Fprint(c.OutOrStdout(), resp)
 			return nil
 		},
 	}
@@ -545,10 +626,126 @@ func logCmd() *cobra.Command {
 		fmt.Sprintf("Comma-separated minimum per-logger level of messages to output, in the form of"+
 			" [<logger>:]<level>,[<logger>:]<level>,... where logger can be one of %s and level can be one of %s",
 			s, levelListString))
+	logCmd.PersistentFlags().DurationVar(&duration, "duration", 0,
+		"Duration after which the levels updated with --level are reverted to their previous values, "+
+			"e.g. 10m. istioctl waits for the duration; interrupting it reverts the levels immediately")
 
 	return logCmd
 }
 
+// maxConcurrentLogRequests is the maximum number of pods whose logging levels are updated concurrently.
+const maxConcurrentLogRequests = 10
+
+// logTarget is a pod whose logging levels are retrieved or updated.
+type logTarget struct {
+	name, namespace string
+}
+
+func (t logTarget) String() string {
+	return t.name + "." + t.namespace
+}
+
+// logResult is the result of retrieving or updating the logging levels of a pod.
+type logResult struct {
+	target logTarget
+	resp   string
+	err    error
+}
+
+// forEachLogTarget runs f for each pod concurrently, returning the results in the order of the pods.
+func forEachLogTarget(targets []logTarget, f func(t logTarget) (string, error)) []logResult {
+	results := make([]logResult, len(targets))
+	sem := make(chan struct{}, maxConcurrentLogRequests)
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, t logTarget) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			resp, err := f(t)
+			results[i] = logResult{target: t, resp: resp, err: err}
+		}(i, t)
+	}
+	wg.Wait()
+	return results
+}
+
+// printLogResults prints the result of each pod, the logging levels if showLevels is set, and returns the number
+// of failures.
+func printLogResults(w io.Writer, results []logResult, showLevels bool, success string) int {
+	failed := 0
+	for _, r := range results {
+		switch {
+		case r.err != nil:
+			failed++
+			_, _ = fmt.Fprintf(w, "%v: failed: %v\n", r.target, r.err)
+		case showLevels:
+			_, _ = fmt.Fprintf(w, "%v:\n%s", r.target, r.resp)
+		default:
+			_, _ = fmt.Fprintf(w, "%v: %s\n", r.target, success)
+		}
+	}
+	return failed
+}
+
+// parseLoggerLevels parses the levels of the loggers, from the response of the Envoy logging endpoint:
+//
+//	active loggers:
+//	  admin: warning
+//	  ...
+func parseLoggerLevels(resp string) map[string]string {
+	levels := map[string]string{}
+	for _, line := range strings.Split(resp, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), ": ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if _, ok := stringToLevel[parts[1]]; ok {
+			levels[parts[0]] = parts[1]
+		}
+	}
+	return levels
+}
+
+// revertLogLevels sets the loggers of the pod whose levels differ from the previous levels back to them.
+func revertLogLevels(t logTarget, previous map[string]string) error {
+	resp, err := setupEnvoyLogConfig("", t.name, t.namespace)
+	if err != nil {
+		return err
+	}
+	current := parseLoggerLevels(resp)
+	for _, lg := range sortedLoggers(previous) {
+		if current[lg] == previous[lg] {
+			continue
+		}
+		if _, err := setupEnvoyLogConfig(lg+"="+previous[lg], t.name, t.namespace); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitForRevert waits for the duration, or until the command is interrupted.
+func waitForRevert(duration time.Duration) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+	select {
+	case <-time.After(duration):
+	case <-signals:
+	}
+}
+
+func sortedLoggers(levels map[string]string) []string {
+	keys := make([]string, 0, len(levels))
+	for k := range levels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func routeConfigCmd() *cobra.Command {
 	var podName, podNamespace string
 
@@ -738,8 +935,73 @@ func bootstrapConfigCmd() *cobra.Command {
 	return bootstrapConfigCmd
 }
 
+func ecdsConfigCmd() *cobra.Command {
+	var podName, podNamespace, extensionConfigName string
+
+	ecdsConfigCmd := &cobra.Command{
+		Use:   "ecds [<type>/]<name>[.<namespace>]",
+		Short: "Retrieves the extension configs received through ECDS by the Envoy in the specified pod",
+		Long: `Retrieve information about the extension configs, like Wasm filters, received through the extension config
+discovery service (ECDS) by the Envoy instance in the specified pod. These configs are not part of the listener
+configuration, which only references them.`,
+		Example: `  # Retrieve summary about the extension configs for a given pod from Envoy.
+  istioctl proxy-config ecds <pod-name[.namespace]>
+
+  # Retrieve the full extension config with a given name.
+  istioctl proxy-config ecds <pod-name[.namespace]> --name my-wasm-filter -o json
+
+  # Retrieve the extension configs without using Kubernetes API
+  ssh <user@hostname> 'curl localhost:15000/config_dump' > envoy-config.json
+  istioctl proxy-config ecds --file envoy-config.json
+`,
+		Aliases: []string{"extensions"},
+		Args: func(cmd *cobra.Command, args []string) error {
+			if (len(args) == 1) != (configDumpFile == "") {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("ecds requires pod name or --file parameter")
+			}
+			return nil
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			var configWriter *configdump.ConfigWriter
+			var err error
+			if len(args) == 1 {
+				if podName, podNamespace, err = getPodName(args[0]); err != nil {
+					return err
+				}
+				configWriter, err = setupPodConfigdumpWriter(podName, podNamespace, c.OutOrStdout())
+			} else {
+				configWriter, err = setupFileConfigdumpWriter(configDumpFile, c.OutOrStdout())
+			}
+			if err != nil {
+				return err
+			}
+			filter := configdump.EcdsFilter{
+				Name: extensionConfigName,
+			}
+
+			switch outputFormat {
+			case summaryOutput:
+				return configWriter.PrintEcdsSummary(filter)
+			case jsonOutput:
+				return configWriter.PrintEcdsDump(filter)
+			default:
+				return fmt.Errorf("output format %q not supported", outputFormat)
+			}
+		},
+	}
+
+	ecdsConfigCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", summaryOutput, "Output format: one of json|short")
+	ecdsConfigCmd.PersistentFlags().StringVar(&extensionConfigName, "name", "", "Filter extension configs by name field")
+	ecdsConfigCmd.PersistentFlags().StringVarP(&configDumpFile, "file", "f", "",
+		"Envoy config dump JSON file")
+
+	return ecdsConfigCmd
+}
+
 func secretConfigCmd() *cobra.Command {
 	var podName, podNamespace string
+	var warnExpiry time.Duration
 
 	secretConfigCmd := &cobra.Command{
 		Use:   "secret [<type>/]<name>[.<namespace>]",
@@ -748,6 +1010,9 @@ func secretConfigCmd() *cobra.Command {
 		Example: `  # Retrieve full secret configuration for a given pod from Envoy.
   istioctl proxy-config secret <pod-name[.namespace]>
 
+  # Retrieve the decoded certificate chains, and exit with code 80 if a certificate expires within 72 hours.
+  istioctl proxy-config secret <pod-name[.namespace]> -o chain --warn-expiry 72h
+
   # Retrieve full bootstrap without using Kubernetes API
   ssh <user@hostname> 'curl localhost:15000/config_dump' > envoy-config.json
   istioctl proxy-config secret --file envoy-config.json`,
@@ -775,29 +1040,138 @@ func secretConfigCmd() *cobra.Command {
 			}
 			switch outputFormat {
 			case summaryOutput:
-				return configWriter.PrintSecretSummary()
+				err = configWriter.PrintSecretSummary()
 			case jsonOutput:
-				return configWriter.PrintSecretDump()
+				err = configWriter.PrintSecretDump()
+			case chainOutput:
+				err = configWriter.PrintSecretChains()
 			default:
 				return fmt.Errorf("output format %q not supported", outputFormat)
 			}
+			if err != nil || warnExpiry <= 0 {
+				return err
+			}
+			expiring, err := configWriter.ExpiringSecretCertificates(warnExpiry)
+			if err != nil {
+				return err
+			}
+			if len(expiring) > 0 {
+				return CertificateExpiryError{Threshold: warnExpiry, Certificates: expiring}
+			}
+			return nil
 		},
 	}
 
-	secretConfigCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", summaryOutput, "Output format: one of json|short")
+	secretConfigCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", summaryOutput, "Output format: one of json|short|chain")
+	secretConfigCmd.PersistentFlags().DurationVar(&warnExpiry, "warn-expiry", 0,
+		fmt.Sprintf("Exit with code %d if a certificate expires within this duration, or has expired", ExitCertificateExpiring))
 	secretConfigCmd.PersistentFlags().StringVarP(&configDumpFile, "file", "f", "",
 		"Envoy config dump JSON file")
 	secretConfigCmd.Long += "\n\n" + ExperimentalMsg
 	return secretConfigCmd
 }
 
+// CertificateExpiryError indicates that certificates of the secrets of a proxy expire within the threshold.
+type CertificateExpiryError struct {
+	Threshold    time.Duration
+	Certificates []string
+}
+
+func (e CertificateExpiryError) Error() string {
+	return fmt.Sprintf("%d certificates expire within %v:\n  %s", len(e.Certificates), e.Threshold,
+		strings.Join(e.Certificates, "\n  "))
+}
+
+func diffConfigCmd() *cobra.Command {
+	var resourceTypes []string
+
+	diffConfigCmd := &cobra.Command{
+		Use:   "diff <pod-name[.namespace]> [<pod-name[.namespace]>]",
+		Short: "Diffs the configuration of the Envoys in the specified pods",
+		Long: `Diff the listeners, clusters, routes and endpoints of the Envoy instances in the specified pods, or of the
+Envoy in the specified pod and a config dump file.
+The resources are matched by name and their differing fields are reported, ignoring their versions, update times
+and ordering.`,
+		Example: `  # Diff the configuration of the Envoys of two pods.
+  istioctl proxy-config diff <pod-name-a[.namespace]> <pod-name-b[.namespace]>
+
+  # Diff the clusters and routes of the Envoys of two pods.
+  istioctl proxy-config diff <pod-name-a[.namespace]> <pod-name-b[.namespace]> --types cluster,route
+
+  # Diff the configuration of the Envoy of a pod with a config dump
+  ssh <user@hostname> 'curl localhost:15000/config_dump?include_eds' > envoy-config.json
+  istioctl proxy-config diff <pod-name[.namespace]> --file envoy-config.json -o json
+`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			expected := 2
+			if configDumpFile != "" {
+				expected = 1
+			}
+			if len(args) != expected {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("diff requires two pod names, or a pod name and --file parameter")
+			}
+			return nil
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			podName, podNamespace, err := getPodName(args[0])
+			if err != nil {
+				return err
+			}
+			aName := podName + "." + podNamespace
+			aDump, err := getPodConfigDump(podName, podNamespace)
+			if err != nil {
+				return err
+			}
+			var bName string
+			var bDump []byte
+			if len(args) == 2 {
+				if podName, podNamespace, err = getPodName(args[1]); err != nil {
+					return err
+				}
+				bName = podName + "." + podNamespace
+				bDump, err = getPodConfigDump(podName, podNamespace)
+			} else {
+				bName = configDumpFile
+				bDump, err = readConfigFile(configDumpFile)
+			}
+			if err != nil {
+				return err
+			}
+			comparator, err := compare.NewProxyComparator(c.OutOrStdout(), aName, aDump, bName, bDump)
+			if err != nil {
+				return err
+			}
+			switch outputFormat {
+			case summaryOutput:
+				return comparator.PrintDiff(resourceTypes)
+			case jsonOutput:
+				return comparator.PrintDiffJSON(resourceTypes)
+			default:
+				return fmt.Errorf("output format %q not supported", outputFormat)
+			}
+		},
+	}
+
+	diffConfigCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", summaryOutput, "Output format: one of json|short")
+	diffConfigCmd.PersistentFlags().StringSliceVar(&resourceTypes, "types", compare.ProxyResourceTypes,
+		"Types of resources to diff: any of cluster|listener|route|endpoint")
+	diffConfigCmd.PersistentFlags().StringVarP(&configDumpFile, "file", "f", "",
+		"Envoy config dump JSON file to diff the Envoy of the pod with")
+
+	return diffConfigCmd
+}
+
 func proxyConfig() *cobra.Command {
 	configCmd := &cobra.Command{
 		Use:   "proxy-config",
 		Short: "Retrieve information about proxy configuration from Envoy [kube only]",
 		Long:  `A group of commands used to retrieve information about proxy configuration from the Envoy config dump`,
 		Example: `  # Retrieve information about proxy configuration from an Envoy instance.
-  istioctl proxy-config <clusters|listeners|routes|endpoints|bootstrap|log|secret> <pod-name[.namespace]>`,
+  istioctl proxy-config <clusters|listeners|routes|endpoints|bootstrap|log|secret|ecds> <pod-name[.namespace]>
+
+  # Diff the configuration of two Envoy instances.
+  istioctl proxy-config diff <pod-name-a[.namespace]> <pod-name-b[.namespace]>`,
 		Aliases: []string{"pc"},
 	}
 
@@ -810,6 +1184,8 @@ func proxyConfig() *cobra.Command {
 	configCmd.AddCommand(bootstrapConfigCmd())
 	configCmd.AddCommand(endpointConfigCmd())
 	configCmd.AddCommand(secretConfigCmd())
+	configCmd.AddCommand(ecdsConfigCmd())
+	configCmd.AddCommand(diffConfigCmd())
 
 	return configCmd
 }
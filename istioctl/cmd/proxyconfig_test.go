@@ -20,6 +20,9 @@ import (
 	"strings"
 	"testing"
 
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
 	"istio.io/istio/pilot/test/util"
 	"istio.io/istio/pkg/kube"
 )
@@ -94,6 +97,17 @@ func TestProxyConfig(t *testing.T) {
 			expectedString:   "unrecognized logger name: xxx",
 			wantException:    true,
 		},
+		{ // duration without level
+			execClientConfig: loggingConfig,
+			args:             strings.Split("proxy-config log details-v1-5b7f94f9bc-wp5tb --duration 10m", " "),
+			expectedString:   "--duration must be positive and requires --level",
+			wantException:    true,
+		},
+		{ // levels updated, then reverted
+			execClientConfig: loggingConfig,
+			args:             strings.Split("proxy-config log details-v1-5b7f94f9bc-wp5tb --level http:debug --duration 1ms", " "),
+			expectedString:   "log levels reverted",
+		},
 		{ // routes invalid
 			args:           strings.Split("proxy-config routes invalid", " "),
 			expectedString: "unable to retrieve Pod: pods \"invalid\" not found",
@@ -168,6 +182,77 @@ func TestProxyConfig(t *testing.T) {
 	}
 }
 
+func TestForEachLogTarget(t *testing.T) {
+	kubeClient = mockEnvoyClientFactoryGenerator(map[string][]byte{
+		"details-v1-5b7f94f9bc-wp5tb": util.ReadFile("../pkg/writer/envoy/logging/testdata/logging.txt", t),
+	})
+	targets := []logTarget{
+		{name: "details-v1-5b7f94f9bc-wp5tb", namespace: "default"},
+		{name: "missing", namespace: "default"},
+	}
+	results := forEachLogTarget(targets, func(t logTarget) (string, error) {
+		return setupEnvoyLogConfig("rbac=debug", t.name, t.namespace)
+	})
+	if len(results) != 2 || results[0].target != targets[0] || results[1].target != targets[1] {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if results[0].err != nil || results[1].err == nil {
+		t.Fatalf("expected only the missing pod to fail, got %+v", results)
+	}
+
+	var out bytes.Buffer
+	if failed := printLogResults(&out, results, false, "log levels updated"); failed != 1 {
+		t.Errorf("got %d failures, want 1", failed)
+	}
+	if !strings.Contains(out.String(), "details-v1-5b7f94f9bc-wp5tb.default: log levels updated\nmissing.default: failed: ") {
+		t.Errorf("unexpected output %q", out.String())
+	}
+
+	levels := parseLoggerLevels(results[0].resp)
+	if levels["admin"] != "warning" || levels["rbac"] == "" {
+		t.Errorf("unexpected logger levels %v", levels)
+	}
+	if _, ok := levels["active loggers"]; ok {
+		t.Errorf("unexpected logger levels %v", levels)
+	}
+}
+
+func TestLogSelector(t *testing.T) {
+	logging := util.ReadFile("../pkg/writer/envoy/logging/testdata/logging.txt", t)
+	results := map[string][]byte{}
+	pods := &v1.PodList{}
+	for i := 0; i < 2*maxConcurrentLogRequests; i++ {
+		name := fmt.Sprintf("details-%d", i)
+		results[name] = logging
+		pods.Items = append(pods.Items, v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}})
+	}
+	// The pod with no proxy fails, while the requests of the other pods run concurrently.
+	pods.Items = append(pods.Items, v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "missing", Namespace: "default"}})
+	kubeClient = func(_, _ string) (kube.ExtendedClient, error) {
+		return kube.MockClient{
+			Results:          results,
+			DiscoverablePods: map[string]map[string]*v1.PodList{"default": {"app=details": pods}},
+		}, nil
+	}
+
+	var out bytes.Buffer
+	rootCmd := GetRootCmd(strings.Split("proxy-config log -n default -l app=details --level http:debug", " "))
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&out)
+	err := rootCmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("1 of %d pods failed", len(pods.Items))) {
+		t.Fatalf("got error %v, want only the missing pod to fail", err)
+	}
+	for i := 0; i < 2*maxConcurrentLogRequests; i++ {
+		if want := fmt.Sprintf("details-%d.default: log levels updated\n", i); !strings.Contains(out.String(), want) {
+			t.Errorf("output %q does not contain %q", out.String(), want)
+		}
+	}
+	if !strings.Contains(out.String(), "missing.default: failed: ") {
+		t.Errorf("output %q does not report the missing pod", out.String())
+	}
+}
+
 func verifyExecTestOutput(t *testing.T, c execTestCase) {
 	t.Helper()
 
@@ -15,13 +15,21 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
+	adminapi "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
 	envoy_corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	xdsapi "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/ptypes"
 	"github.com/spf13/cobra"
 
 	"istio.io/istio/istioctl/pkg/clioptions"
@@ -36,6 +44,7 @@ import (
 
 func statusCommand() *cobra.Command {
 	var opts clioptions.ControlPlaneOptions
+	var certExpiry bool
 
 	statusCmd := &cobra.Command{
 		Use:   "proxy-status [<type>/]<name>[.<namespace>]",
@@ -43,6 +52,8 @@ func statusCommand() *cobra.Command {
 		Long: `
 Retrieves last sent and last acknowledged xDS sync from Istiod to each Envoy in the mesh
 
+The configuration last rejected by each Envoy and the Envoys whose version is skewed from the version of their
+Istiod are reported after the synchronization status.
 `,
 		Example: `  # Retrieve sync status for all Envoys in a mesh
   istioctl proxy-status
@@ -53,6 +64,9 @@ Retrieves last sent and last acknowledged xDS sync from Istiod to each Envoy in
   # Retrieve sync diff between Istiod and one pod under a deployment
   istioctl proxy-status deployment/productpage-v1
 
+  # Retrieve sync status for all Envoys in a mesh, with the expiration time of their workload certificates
+  istioctl proxy-status --cert-expiry
+
   # Write proxy config-dump to file, and compare to Istio control plane
   kubectl port-forward -n istio-system istio-egressgateway-59585c5b9c-ndc59 15000 &
   curl localhost:15000/config_dump > cd.json
@@ -105,6 +119,11 @@ Retrieves last sent and last acknowledged xDS sync from Istiod to each Envoy in
 				return err
 			}
 			sw := pilot.StatusWriter{Writer: c.OutOrStdout()}
+			if certExpiry {
+				if sw.CertExpiry, err = proxyCertExpiry(kubeClient, statuses); err != nil {
+					return err
+				}
+			}
 			return sw.PrintAll(statuses)
 		},
 	}
@@ -112,10 +131,87 @@ Retrieves last sent and last acknowledged xDS sync from Istiod to each Envoy in
 	opts.AttachControlPlaneFlags(statusCmd)
 	statusCmd.PersistentFlags().StringVarP(&configDumpFile, "file", "f", "",
 		"Envoy config dump JSON file")
+	statusCmd.PersistentFlags().BoolVar(&certExpiry, "cert-expiry", false,
+		"Retrieve the expiration time of the workload certificate of each Envoy, requires a request to each Envoy")
 
 	return statusCmd
 }
 
+// maxConcurrentCertRequests bounds the number of Envoys whose certificates are requested concurrently.
+const maxConcurrentCertRequests = 10
+
+// proxyCertExpiry returns the earliest expiration time of the workload certificate chain of each proxy of the
+// statuses, keyed by proxy ID. The proxies whose certificates cannot be read, e.g. not running in a pod of this
+// cluster or without workload certificate, are missing.
+func proxyCertExpiry(kubeClient kube.ExtendedClient, statuses map[string][]byte) (map[string]time.Time, error) {
+	var proxies []string
+	for _, status := range statuses {
+		var ss []pilotxds.SyncStatus
+		if err := json.Unmarshal(status, &ss); err != nil {
+			return nil, err
+		}
+		for _, s := range ss {
+			proxies = append(proxies, s.ProxyID)
+		}
+	}
+
+	out := make(map[string]time.Time, len(proxies))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentCertRequests)
+	for _, proxy := range proxies {
+		i := strings.LastIndex(proxy, ".")
+		if i < 0 {
+			continue
+		}
+		podName, ns := proxy[:i], proxy[i+1:]
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(proxy string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			certs, err := kubeClient.EnvoyDo(context.TODO(), podName, ns, "GET", "certs", nil)
+			if err != nil {
+				log.Debugf("failed to retrieve the certificates of %s: %v", proxy, err)
+				return
+			}
+			if expiry, ok := certChainExpiry(certs); ok {
+				mu.Lock()
+				out[proxy] = expiry
+				mu.Unlock()
+			}
+		}(proxy)
+	}
+	wg.Wait()
+	return out, nil
+}
+
+// certChainExpiry returns the earliest expiration time of the certificate chains in the Envoy certs admin response.
+func certChainExpiry(certs []byte) (time.Time, bool) {
+	parsed := &adminapi.Certificates{}
+	if err := (&jsonpb.Unmarshaler{AllowUnknownFields: true}).Unmarshal(bytes.NewReader(certs), parsed); err != nil {
+		return time.Time{}, false
+	}
+	var earliest time.Time
+	for _, c := range parsed.Certificates {
+		for _, cert := range c.CertChain {
+			if cert.ExpirationTime == nil {
+				continue
+			}
+			expiry, err := ptypes.Timestamp(cert.ExpirationTime)
+			if err != nil {
+				continue
+			}
+			if earliest.IsZero() || expiry.Before(earliest) {
+				earliest = expiry
+			}
+		}
+	}
+	return earliest, !earliest.IsZero()
+}
+
 func readConfigFile(filename string) ([]byte, error) {
 	file := os.Stdin
 	if filename != "-" {
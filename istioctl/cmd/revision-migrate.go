@@ -0,0 +1,424 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/api/annotation"
+	"istio.io/api/label"
+	"istio.io/istio/pilot/pkg/xds"
+)
+
+const (
+	// migratedFromAnnotation records the injection label of a namespace before its migration, to roll it back.
+	migratedFromAnnotation = "istio.io/migrated-from"
+	restartedAtAnnotation  = "kubectl.kubernetes.io/restartedAt"
+	injectionLabel         = "istio-injection"
+	injectionLabelEnabled  = injectionLabel + "=enabled"
+)
+
+// migratePollInterval is the interval between the checks of the convergence of the sidecars of a wave.
+var migratePollInterval = 2 * time.Second
+
+type migrateArgs struct {
+	from              string
+	to                string
+	batchSize         int
+	restartInterval   time.Duration
+	waveInterval      time.Duration
+	timeout           time.Duration
+	rollback          bool
+	rollbackOnFailure bool
+	dryRun            bool
+}
+
+// migrationNamespace is a namespace to migrate, with its injection label before the migration, either
+// istio-injection=enabled or istio.io/rev=<revision>.
+type migrationNamespace struct {
+	name     string
+	original string
+}
+
+// revisionMigrator moves the workloads of namespaces from a revision or tag to another, in waves.
+type revisionMigrator struct {
+	client kubernetes.Interface
+	args   migrateArgs
+	out    io.Writer
+	// syncStatus returns whether the proxies connected to the revision, by ID, are synced.
+	syncStatus func(ctx context.Context, revision string) (map[string]bool, error)
+}
+
+func revisionMigrateCommand() *cobra.Command {
+	args := migrateArgs{}
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Moves the workloads of the namespaces using a revision to another revision, in waves",
+		Long: `Moves the namespaces whose injection label references the source revision or tag to the target revision
+or tag, in waves of namespaces. For each wave, the injection labels of the namespaces are switched, their
+deployments, statefulsets and daemonsets are restarted, and the command waits until all their sidecars are
+injected by the target revision, connected to it and synced.
+
+If the sidecars of a wave do not converge within the timeout, the wave is rolled back and the migration stops.
+The namespaces of the previous waves are annotated with their original injection label, and can be rolled back
+with --rollback.`,
+		Example: `  # Move the namespaces using the 1-19 revision to the 1-20 revision, five namespaces at a time
+  istioctl x revision migrate --from 1-19 --to 1-20 --batch-size 5
+
+  # Show the waves of the migration of the namespaces using the default injection label to the prod tag
+  istioctl x revision migrate --from default --to prod --dry-run
+
+  # Move the namespaces migrated from the 1-19 revision back to it
+  istioctl x revision migrate --from 1-19 --to 1-20 --rollback`,
+		Args: cobra.NoArgs,
+		RunE: func(c *cobra.Command, _ []string) error {
+			if args.from == "" || args.to == "" || args.from == args.to {
+				return CommandParseError{fmt.Errorf("--from and --to must be set to different revisions or tags")}
+			}
+			if args.batchSize < 1 {
+				return CommandParseError{fmt.Errorf("--batch-size must be positive")}
+			}
+			client, err := kubeClient(kubeconfig, configContext)
+			if err != nil {
+				return fmt.Errorf("failed to create k8s client: %v", err)
+			}
+			m := &revisionMigrator{client: client.Kube(), args: args, out: c.OutOrStdout(), syncStatus: proxySyncStatus}
+			return m.run(context.Background())
+		},
+	}
+	cmd.PersistentFlags().StringVar(&args.from, "from", "", "The revision or tag the namespaces are moved from, default for the "+
+		"namespaces labeled with istio-injection=enabled")
+	cmd.PersistentFlags().StringVar(&args.to, "to", "", "The revision or tag the namespaces are moved to")
+	cmd.PersistentFlags().IntVar(&args.batchSize, "batch-size", 1, "The number of namespaces moved in each wave")
+	cmd.PersistentFlags().DurationVar(&args.restartInterval, "restart-interval", 0,
+		"The time to wait between the restarts of the workloads of a wave")
+	cmd.PersistentFlags().DurationVar(&args.waveInterval, "wave-interval", 0, "The time to wait between waves")
+	cmd.PersistentFlags().DurationVar(&args.timeout, "timeout", 5*time.Minute,
+		"The time to wait for the sidecars of a wave to converge to the target revision")
+	cmd.PersistentFlags().BoolVar(&args.rollback, "rollback", false,
+		"Move the namespaces migrated from --from to --to back to their original injection label")
+	cmd.PersistentFlags().BoolVar(&args.rollbackOnFailure, "rollback-on-failure", true,
+		"Roll back the wave whose sidecars do not converge within the timeout")
+	cmd.PersistentFlags().BoolVar(&args.dryRun, "dry-run", false, "Print the waves without moving the namespaces")
+	return cmd
+}
+
+func (m *revisionMigrator) run(ctx context.Context) error {
+	namespaces, err := m.namespaces(ctx)
+	if err != nil {
+		return err
+	}
+	if len(namespaces) == 0 {
+		fmt.Fprintln(m.out, "No namespace to migrate.")
+		return nil
+	}
+	target := m.args.to
+	if m.args.rollback {
+		target = m.args.from
+	}
+	revision, err := m.resolveRevision(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	var waves [][]migrationNamespace
+	for i := 0; i < len(namespaces); i += m.args.batchSize {
+		end := i + m.args.batchSize
+		if end > len(namespaces) {
+			end = len(namespaces)
+		}
+		waves = append(waves, namespaces[i:end])
+	}
+	for i, wave := range waves {
+		if i > 0 && m.args.waveInterval > 0 && !m.args.dryRun {
+			time.Sleep(m.args.waveInterval)
+		}
+		fmt.Fprintf(m.out, "Wave %d/%d: %s\n", i+1, len(waves), strings.Join(namespaceNames(wave), ", "))
+		if m.args.dryRun {
+			continue
+		}
+		if err := m.migrateWave(ctx, wave, !m.args.rollback); err != nil {
+			return err
+		}
+		if err := m.restartWorkloads(ctx, wave); err != nil {
+			return err
+		}
+		if err := m.waitForConvergence(ctx, wave, revision); err != nil {
+			fmt.Fprintf(m.out, "Wave %d/%d failed: %v\n", i+1, len(waves), err)
+			if m.args.rollbackOnFailure {
+				fmt.Fprintf(m.out, "Rolling back wave %d/%d\n", i+1, len(waves))
+				if rerr := m.migrateWave(ctx, wave, m.args.rollback); rerr != nil {
+					return fmt.Errorf("%v, and failed to roll back the wave: %v", err, rerr)
+				}
+				if rerr := m.restartWorkloads(ctx, wave); rerr != nil {
+					return fmt.Errorf("%v, and failed to roll back the wave: %v", err, rerr)
+				}
+			}
+			return fmt.Errorf("wave %d/%d did not converge to revision %q: %v", i+1, len(waves), revision, err)
+		}
+		fmt.Fprintf(m.out, "Wave %d/%d converged to revision %q\n", i+1, len(waves), revision)
+	}
+	return nil
+}
+
+// namespaces returns the namespaces to migrate, sorted by name: the namespaces using the source revision, or when
+// rolling back, the namespaces migrated from the source revision to the target revision.
+func (m *revisionMigrator) namespaces(ctx context.Context) ([]migrationNamespace, error) {
+	nsList, err := m.client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var out []migrationNamespace
+	for _, ns := range nsList.Items {
+		rev, hasRev := ns.Labels[label.IoIstioRev.Name]
+		if m.args.rollback {
+			original := ns.Annotations[migratedFromAnnotation]
+			if hasRev && rev == m.args.to && original != "" && originalRevision(original) == m.args.from {
+				out = append(out, migrationNamespace{name: ns.Name, original: original})
+			}
+			continue
+		}
+		switch {
+		case hasRev && rev == m.args.from:
+			out = append(out, migrationNamespace{name: ns.Name, original: label.IoIstioRev.Name + "=" + rev})
+		case !hasRev && m.args.from == defaultRevisionName && ns.Labels[injectionLabel] == "enabled":
+			out = append(out, migrationNamespace{name: ns.Name, original: injectionLabelEnabled})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].name < out[j].name
+	})
+	return out, nil
+}
+
+// originalRevision returns the revision of the original injection label of a namespace.
+func originalRevision(original string) string {
+	if original == injectionLabelEnabled {
+		return defaultRevisionName
+	}
+	return strings.TrimPrefix(original, label.IoIstioRev.Name+"=")
+}
+
+// resolveRevision returns the revision of the tag, or the revision itself if it is not a tag.
+func (m *revisionMigrator) resolveRevision(ctx context.Context, revisionOrTag string) (string, error) {
+	webhooks, err := getWebhooksWithTag(ctx, m.client, revisionOrTag)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the tag %q: %v", revisionOrTag, err)
+	}
+	if len(webhooks) == 0 {
+		return revisionOrTag, nil
+	}
+	return getWebhookRevision(webhooks[0])
+}
+
+// migrateWave switches the injection labels of the namespaces to the target revision, or back to their original
+// injection label.
+func (m *revisionMigrator) migrateWave(ctx context.Context, wave []migrationNamespace, forward bool) error {
+	for _, ns := range wave {
+		labels := map[string]interface{}{injectionLabel: nil, label.IoIstioRev.Name: m.args.to}
+		annotations := map[string]interface{}{migratedFromAnnotation: ns.original}
+		if !forward {
+			labels = map[string]interface{}{injectionLabel: nil, label.IoIstioRev.Name: nil}
+			if ns.original == injectionLabelEnabled {
+				labels[injectionLabel] = "enabled"
+			} else {
+				labels[label.IoIstioRev.Name] = originalRevision(ns.original)
+			}
+			annotations = map[string]interface{}{migratedFromAnnotation: nil}
+		}
+		patch, err := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{"labels": labels, "annotations": annotations},
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := m.client.CoreV1().Namespaces().Patch(ctx, ns.name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+			return fmt.Errorf("failed to relabel namespace %s: %v", ns.name, err)
+		}
+	}
+	return nil
+}
+
+// restartWorkloads restarts the deployments, statefulsets and daemonsets of the namespaces whose pods are injected,
+// like kubectl rollout restart.
+func (m *revisionMigrator) restartWorkloads(ctx context.Context, wave []migrationNamespace) error {
+	patch := []byte(fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{%q:%q}}}}}`,
+		restartedAtAnnotation, time.Now().Format(time.RFC3339)))
+	first := true
+	restart := func(kind, ns, name string, template metav1.ObjectMeta, patchFunc func() error) error {
+		if template.Annotations[annotation.SidecarInject.Name] == "false" || template.Labels[annotation.SidecarInject.Name] == "false" {
+			return nil
+		}
+		if !first && m.args.restartInterval > 0 {
+			time.Sleep(m.args.restartInterval)
+		}
+		first = false
+		if err := patchFunc(); err != nil {
+			return fmt.Errorf("failed to restart %s/%s.%s: %v", kind, name, ns, err)
+		}
+		fmt.Fprintf(m.out, "  restarted %s/%s.%s\n", kind, name, ns)
+		return nil
+	}
+	for _, ns := range wave {
+		apps := m.client.AppsV1()
+		deployments, err := apps.Deployments(ns.name).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		for _, d := range deployments.Items {
+			name := d.Name
+			if err := restart("deployment", ns.name, name, d.Spec.Template.ObjectMeta, func() error {
+				_, err := apps.Deployments(ns.name).Patch(ctx, name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+				return err
+			}); err != nil {
+				return err
+			}
+		}
+		statefulSets, err := apps.StatefulSets(ns.name).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		for _, s := range statefulSets.Items {
+			name := s.Name
+			if err := restart("statefulset", ns.name, name, s.Spec.Template.ObjectMeta, func() error {
+				_, err := apps.StatefulSets(ns.name).Patch(ctx, name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+				return err
+			}); err != nil {
+				return err
+			}
+		}
+		daemonSets, err := apps.DaemonSets(ns.name).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		for _, d := range daemonSets.Items {
+			name := d.Name
+			if err := restart("daemonset", ns.name, name, d.Spec.Template.ObjectMeta, func() error {
+				_, err := apps.DaemonSets(ns.name).Patch(ctx, name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+				return err
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// waitForConvergence waits until the sidecars of the pods of the namespaces are injected by the revision, connected
+// to it and synced.
+func (m *revisionMigrator) waitForConvergence(ctx context.Context, wave []migrationNamespace, revision string) error {
+	deadline := time.Now().Add(m.args.timeout)
+	for {
+		synced, err := m.syncStatus(ctx, revision)
+		if err != nil {
+			return err
+		}
+		var pods []v1.Pod
+		for _, ns := range wave {
+			l, err := m.client.CoreV1().Pods(ns.name).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				return err
+			}
+			pods = append(pods, l.Items...)
+		}
+		pending := unconvergedSidecars(pods, revision, synced)
+		if len(pending) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("sidecars not converged after %v: %s", m.args.timeout, strings.Join(pending, ", "))
+		}
+		time.Sleep(migratePollInterval)
+	}
+}
+
+// unconvergedSidecars returns the running pods with a sidecar not injected by the revision, or not synced.
+func unconvergedSidecars(pods []v1.Pod, revision string, synced map[string]bool) []string {
+	var out []string
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed ||
+			!hasSidecar(pod) {
+			continue
+		}
+		id := pod.Name + "." + pod.Namespace
+		if rev := pod.Labels[label.IoIstioRev.Name]; rev != revision {
+			out = append(out, fmt.Sprintf("%s (revision %q)", id, rev))
+		} else if !synced[id] {
+			out = append(out, fmt.Sprintf("%s (not synced)", id))
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func hasSidecar(pod v1.Pod) bool {
+	for _, c := range pod.Spec.Containers {
+		if c.Name == "istio-proxy" {
+			return true
+		}
+	}
+	return false
+}
+
+// proxySyncStatus returns whether the proxies connected to the revision are synced, as reported by proxy-status.
+func proxySyncStatus(ctx context.Context, revision string) (map[string]bool, error) {
+	if revision == defaultRevisionName {
+		revision = ""
+	}
+	client, err := kubeClientWithRevision(kubeconfig, configContext, revision)
+	if err != nil {
+		return nil, err
+	}
+	responses, err := client.AllDiscoveryDo(ctx, istioNamespace, "/debug/syncz")
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]bool{}
+	for _, response := range responses {
+		var statuses []xds.SyncStatus
+		if err := json.Unmarshal(response, &statuses); err != nil {
+			return nil, err
+		}
+		for _, s := range statuses {
+			out[s.ProxyID] = xdsSynced(s.ClusterSent, s.ClusterAcked) && xdsSynced(s.ListenerSent, s.ListenerAcked) &&
+				xdsSynced(s.RouteSent, s.RouteAcked) && xdsSynced(s.EndpointSent, s.EndpointAcked)
+		}
+	}
+	return out, nil
+}
+
+func xdsSynced(sent, acked string) bool {
+	return sent == "" || sent == acked
+}
+
+func namespaceNames(wave []migrationNamespace) []string {
+	out := make([]string, 0, len(wave))
+	for _, ns := range wave {
+		out = append(out, ns.name)
+	}
+	return out
+}
@@ -0,0 +1,166 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/api/label"
+)
+
+func migrationPod(name, ns, revision string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns, Labels: map[string]string{label.IoIstioRev.Name: revision}},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "app"}, {Name: "istio-proxy"}}},
+		Status:     v1.PodStatus{Phase: v1.PodRunning},
+	}
+}
+
+func TestUnconvergedSidecars(t *testing.T) {
+	noSidecar := migrationPod("nosidecar", "a", "1-19")
+	noSidecar.Spec.Containers = noSidecar.Spec.Containers[:1]
+	completed := migrationPod("completed", "a", "1-19")
+	completed.Status.Phase = v1.PodSucceeded
+	pods := []v1.Pod{
+		*migrationPod("synced", "a", "1-20"),
+		*migrationPod("old", "a", "1-19"),
+		*migrationPod("unsynced", "a", "1-20"),
+		*noSidecar,
+		*completed,
+	}
+	got := unconvergedSidecars(pods, "1-20", map[string]bool{"synced.a": true, "unsynced.a": false})
+	want := []string{`old.a (revision "1-19")`, "unsynced.a (not synced)"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRevisionMigrate(t *testing.T) {
+	migratePollInterval = time.Millisecond
+	newNamespace := func(name string, labels map[string]string) *v1.Namespace {
+		return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "a"}}
+
+	cases := []struct {
+		name       string
+		args       migrateArgs
+		converged  bool
+		wantErr    string
+		wantLabels map[string]map[string]string
+		wantOutput []string
+	}{
+		{
+			name:      "migrate",
+			args:      migrateArgs{from: "default", to: "1-20", batchSize: 2, rollbackOnFailure: true},
+			converged: true,
+			wantLabels: map[string]map[string]string{
+				"a":     {label.IoIstioRev.Name: "1-20"},
+				"b":     {label.IoIstioRev.Name: "1-20"},
+				"c":     {label.IoIstioRev.Name: "1-20"},
+				"other": {label.IoIstioRev.Name: "1-19"},
+			},
+			wantOutput: []string{"Wave 1/2: a, b", `Wave 1/2 converged to revision "1-20"`, "Wave 2/2: c", "restarted deployment/app.a"},
+		},
+		{
+			name:    "rollback on failure",
+			args:    migrateArgs{from: "default", to: "1-20", batchSize: 2, rollbackOnFailure: true},
+			wantErr: "wave 1/2 did not converge",
+			wantLabels: map[string]map[string]string{
+				"a":     {injectionLabel: "enabled"},
+				"b":     {label.IoIstioRev.Name: "default"},
+				"c":     {injectionLabel: "enabled"},
+				"other": {label.IoIstioRev.Name: "1-19"},
+			},
+			wantOutput: []string{"Wave 1/2: a, b", "Rolling back wave 1/2"},
+		},
+		{
+			name: "dry run",
+			args: migrateArgs{from: "default", to: "1-20", batchSize: 1, dryRun: true},
+			wantLabels: map[string]map[string]string{
+				"a":     {injectionLabel: "enabled"},
+				"b":     {label.IoIstioRev.Name: "default"},
+				"c":     {injectionLabel: "enabled"},
+				"other": {label.IoIstioRev.Name: "1-19"},
+			},
+			wantOutput: []string{"Wave 1/3: a", "Wave 2/3: b", "Wave 3/3: c"},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(
+				newNamespace("a", map[string]string{injectionLabel: "enabled"}),
+				newNamespace("b", map[string]string{label.IoIstioRev.Name: "default"}),
+				newNamespace("c", map[string]string{injectionLabel: "enabled"}),
+				newNamespace("other", map[string]string{label.IoIstioRev.Name: "1-19"}),
+				deployment,
+			)
+			out := &bytes.Buffer{}
+			m := &revisionMigrator{
+				client: client,
+				args:   tt.args,
+				out:    out,
+				syncStatus: func(ctx context.Context, revision string) (map[string]bool, error) {
+					// The restarted pods are injected by the revision of their namespace.
+					pods, _ := client.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+					for _, p := range pods.Items {
+						_ = client.CoreV1().Pods(p.Namespace).Delete(ctx, p.Name, metav1.DeleteOptions{})
+					}
+					for _, ns := range []string{"a", "b", "c"} {
+						n, _ := client.CoreV1().Namespaces().Get(ctx, ns, metav1.GetOptions{})
+						rev := n.Labels[label.IoIstioRev.Name]
+						if rev == "" {
+							rev = defaultRevisionName
+						}
+						_, _ = client.CoreV1().Pods(ns).Create(ctx, migrationPod("app", ns, rev), metav1.CreateOptions{})
+					}
+					return map[string]bool{"app.a": tt.converged, "app.b": tt.converged, "app.c": tt.converged}, nil
+				},
+			}
+			err := m.run(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			for ns, want := range tt.wantLabels {
+				n, err := client.CoreV1().Namespaces().Get(context.Background(), ns, metav1.GetOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(n.Labels, want) {
+					t.Errorf("got labels %v for namespace %s, want %v", n.Labels, ns, want)
+				}
+			}
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("got output %q, want %q", out.String(), want)
+				}
+			}
+		})
+	}
+}
@@ -24,14 +24,17 @@ import (
 	"github.com/spf13/cobra/doc"
 	"github.com/spf13/viper"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 
 	"istio.io/istio/istioctl/pkg/install"
 	"istio.io/istio/istioctl/pkg/multicluster"
+	"istio.io/istio/istioctl/pkg/util/handlers"
 	"istio.io/istio/istioctl/pkg/validate"
 	"istio.io/istio/operator/cmd/mesh"
 	"istio.io/istio/pilot/pkg/serviceregistry/kube/controller"
 	"istio.io/istio/pkg/cmd"
+	"istio.io/istio/pkg/kube"
 	"istio.io/istio/tools/bug-report/pkg/bugreport"
 	"istio.io/pkg/collateral"
 	"istio.io/pkg/env"
@@ -214,10 +217,12 @@ debug and diagnose their Istio mesh.
 
 	rootCmd.AddCommand(install.NewVerifyCommand())
 	experimentalCmd.AddCommand(install.NewPrecheckCommand())
+	experimentalCmd.AddCommand(AuthN())
 	experimentalCmd.AddCommand(AuthZ())
 	rootCmd.AddCommand(seeExperimentalCmd("authz"))
 	experimentalCmd.AddCommand(uninjectCommand())
 	experimentalCmd.AddCommand(metricsCmd)
+	experimentalCmd.AddCommand(sidecarScopeCommand())
 	experimentalCmd.AddCommand(describe())
 	experimentalCmd.AddCommand(addToMeshCmd())
 	experimentalCmd.AddCommand(removeFromMeshCmd())
@@ -226,6 +231,10 @@ debug and diagnose their Istio mesh.
 	experimentalCmd.AddCommand(configCmd())
 	experimentalCmd.AddCommand(workloadCommands())
 	experimentalCmd.AddCommand(revisionCommand())
+	experimentalCmd.AddCommand(upgradePlanCmd())
+	experimentalCmd.AddCommand(topologyCmd())
+	experimentalCmd.AddCommand(tapCmd())
+	experimentalCmd.AddCommand(checkInjectCommand())
 
 	analyzeCmd := Analyze()
 	hideInheritedFlags(analyzeCmd, "istioNamespace")
@@ -267,8 +276,10 @@ debug and diagnose their Istio mesh.
 		Manual:  "Istio Control",
 	}))
 
-	validateCmd := validate.NewValidateCommand(&istioNamespace)
-	hideInheritedFlags(validateCmd, "kubeconfig")
+	validateCmd := validate.NewValidateCommand(&istioNamespace, func() (*rest.Config, string, error) {
+		config, err := kube.DefaultRestConfig(kubeconfig, configContext)
+		return config, handlers.HandleNamespace(namespace, defaultNamespace), err
+	})
 	rootCmd.AddCommand(validateCmd)
 
 	rootCmd.AddCommand(optionsCommand(rootCmd))
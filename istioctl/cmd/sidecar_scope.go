@@ -0,0 +1,269 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/ghodss/yaml"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/spf13/cobra"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkingv1alpha3 "istio.io/api/networking/v1alpha3"
+	clientv1alpha3 "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	"istio.io/istio/istioctl/pkg/clioptions"
+	"istio.io/istio/pkg/kube"
+	"istio.io/pkg/log"
+)
+
+const (
+	// sidecarScopeLabel marks the Sidecars generated by sidecar-scope, which are the only ones it updates.
+	sidecarScopeLabel      = "istio.io/generated-by"
+	sidecarScopeLabelValue = "istioctl-sidecar-scope"
+
+	tcpOpened = "istio_tcp_connections_opened_total"
+)
+
+func sidecarScopeCommand() *cobra.Command {
+	var (
+		opts   clioptions.ControlPlaneOptions
+		window time.Duration
+		name   string
+		dryRun bool
+	)
+	cmd := &cobra.Command{
+		Use:   "sidecar-scope <namespace>...",
+		Short: "Restricts the egress scope of namespaces to the services their workloads were observed talking to",
+		Long: `
+Generates a namespace wide Sidecar restricting the egress scope of the workloads of each namespace to the
+services they were observed talking to, reducing the configuration pushed to their proxies.
+
+This command finds a Prometheus pod running in the specified istio system namespace, and queries the
+client-side request and TCP connection metrics of the workloads of each namespace over the observation
+window. The services of the istio system namespace are always kept in scope.
+
+Only the Sidecars generated by this command are updated. With --dry-run, the changes to the egress scope
+of each namespace and the generated Sidecars are printed instead of being applied.
+`,
+		Example: `  # Report the egress scope observed for the default namespace over the last week
+  istioctl experimental sidecar-scope default --dry-run
+
+  # Restrict the egress scope of the foo and bar namespaces to the services observed over the last day
+  istioctl experimental sidecar-scope foo bar --window 24h`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("sidecar-scope requires a namespace")
+			}
+			if window < time.Minute {
+				return fmt.Errorf("invalid observation window %v, must be at least 1m", window)
+			}
+			return nil
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			client, err := kubeClientWithRevision(kubeconfig, configContext, opts.Revision)
+			if err != nil {
+				return fmt.Errorf("failed to create k8s client: %v", err)
+			}
+
+			promAPI, fw, err := forwardPrometheus(client)
+			if err != nil {
+				return err
+			}
+			defer fw.Close()
+			closePortForwarderOnInterrupt(fw)
+
+			for _, ns := range args {
+				hosts, err := observedEgressHosts(promAPI, ns, window)
+				if err != nil {
+					return fmt.Errorf("could not observe the egress of namespace %s: %v", ns, err)
+				}
+				if err := scopeNamespace(c.OutOrStdout(), client, ns, name, hosts, dryRun); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		DisableFlagsInUseLine: true,
+	}
+	cmd.PersistentFlags().DurationVar(&window, "window", 7*24*time.Hour,
+		"The window over which the traffic of the workloads is observed")
+	cmd.PersistentFlags().StringVar(&name, "name", "default", "The name of the generated Sidecars")
+	cmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false,
+		"Print the changes to the egress scope and the generated Sidecars instead of applying them")
+	opts.AttachControlPlaneFlags(cmd)
+	return cmd
+}
+
+// observedEgressHosts returns the egress hosts, in the "<namespace>/<host>" format of Sidecar, of the services the
+// workloads of the namespace were observed talking to over the window, including the istio system namespace.
+func observedEgressHosts(promAPI promv1.API, namespace string, window time.Duration) ([]string, error) {
+	set := map[string]bool{istioNamespace + "/*": true}
+	for _, metric := range []string{reqTot, tcpOpened} {
+		query := fmt.Sprintf(`sum(increase(%s{reporter="source",source_workload_namespace="%s"}[%s])) by (destination_service, destination_service_namespace) > 0`,
+			metric, namespace, model.Duration(window))
+		log.Debugf("executing query: %s", query)
+		val, _, err := promAPI.Query(context.Background(), query, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("query() failure for '%s': %v", query, err)
+		}
+		v, ok := val.(model.Vector)
+		if !ok {
+			return nil, errors.New("bad metric value type returned for query")
+		}
+		for _, sample := range v {
+			if host := egressHost(string(sample.Metric["destination_service"]), string(sample.Metric["destination_service_namespace"])); host != "" {
+				set[host] = true
+			}
+		}
+	}
+	hosts := make([]string, 0, len(set))
+	for host := range set {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts, nil
+}
+
+// egressHost returns the Sidecar egress host of a destination service, or "" if the destination is unknown.
+func egressHost(service, serviceNamespace string) string {
+	switch service {
+	case "", "unknown", "PassthroughCluster", "BlackHoleCluster":
+		return ""
+	}
+	// Services out of the registry, such as the ones of ServiceEntries without namespace, may be in any namespace.
+	if serviceNamespace == "" || serviceNamespace == "unknown" {
+		serviceNamespace = "*"
+	}
+	return serviceNamespace + "/" + service
+}
+
+// buildScopedSidecar returns the namespace wide Sidecar restricting the egress scope to the hosts.
+func buildScopedSidecar(namespace, name string, hosts []string) *clientv1alpha3.Sidecar {
+	return &clientv1alpha3.Sidecar{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Sidecar",
+			APIVersion: clientv1alpha3.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{sidecarScopeLabel: sidecarScopeLabelValue},
+		},
+		Spec: networkingv1alpha3.Sidecar{
+			Egress: []*networkingv1alpha3.IstioEgressListener{{Hosts: hosts}},
+		},
+	}
+}
+
+// egressHosts returns the egress hosts of all the listeners of the Sidecar.
+func egressHosts(sc *clientv1alpha3.Sidecar) []string {
+	var hosts []string
+	for _, egress := range sc.Spec.Egress {
+		hosts = append(hosts, egress.Hosts...)
+	}
+	return hosts
+}
+
+// scopeNamespace creates or updates the generated Sidecar of the namespace, or only reports the changes to its egress
+// scope in dry run.
+func scopeNamespace(w io.Writer, client kube.ExtendedClient, namespace, name string, hosts []string, dryRun bool) error {
+	sidecars := client.Istio().NetworkingV1alpha3().Sidecars(namespace)
+	existing, err := sidecars.Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		if !kerrors.IsNotFound(err) {
+			return fmt.Errorf("could not get Sidecar %s/%s: %v", namespace, name, err)
+		}
+		existing = nil
+	}
+	if existing != nil && existing.Labels[sidecarScopeLabel] != sidecarScopeLabelValue {
+		fmt.Fprintf(w, "Sidecar %s/%s was not generated by sidecar-scope, skipped\n", namespace, name)
+		return nil
+	}
+	generated := buildScopedSidecar(namespace, name, hosts)
+	var previous []string
+	if existing != nil {
+		previous = egressHosts(existing)
+	}
+	printScopeReport(w, namespace, previous, hosts, existing != nil)
+	if dryRun {
+		b, err := yaml.Marshal(generated)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "---\n%s", b)
+		return nil
+	}
+	if existing == nil {
+		_, err = sidecars.Create(context.TODO(), generated, metav1.CreateOptions{})
+	} else {
+		existing.Spec = generated.Spec
+		_, err = sidecars.Update(context.TODO(), existing, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("could not apply Sidecar %s/%s: %v", namespace, name, err)
+	}
+	return nil
+}
+
+// printScopeReport prints the hosts added to and removed from the egress scope of the namespace.
+func printScopeReport(w io.Writer, namespace string, previous, hosts []string, exists bool) {
+	if !exists {
+		fmt.Fprintf(w, "Namespace %s: egress scope restricted to %d hosts\n", namespace, len(hosts))
+		for _, host := range hosts {
+			fmt.Fprintf(w, "  + %s\n", host)
+		}
+		return
+	}
+	prev := map[string]bool{}
+	for _, host := range previous {
+		prev[host] = true
+	}
+	cur := map[string]bool{}
+	for _, host := range hosts {
+		cur[host] = true
+	}
+	var added, removed []string
+	for _, host := range hosts {
+		if !prev[host] {
+			added = append(added, host)
+		}
+	}
+	for _, host := range previous {
+		if !cur[host] {
+			removed = append(removed, host)
+		}
+	}
+	sort.Strings(removed)
+	if len(added) == 0 && len(removed) == 0 {
+		fmt.Fprintf(w, "Namespace %s: egress scope of %d hosts unchanged\n", namespace, len(hosts))
+		return
+	}
+	fmt.Fprintf(w, "Namespace %s: egress scope changed to %d hosts\n", namespace, len(hosts))
+	for _, host := range added {
+		fmt.Fprintf(w, "  + %s\n", host)
+	}
+	for _, host := range removed {
+		fmt.Fprintf(w, "  - %s\n", host)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	prometheus_model "github.com/prometheus/common/model"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pkg/kube"
+)
+
+func TestObservedEgressHosts(t *testing.T) {
+	istioNamespace = "istio-system"
+	destination := func(service, namespace string) *prometheus_model.Sample {
+		return &prometheus_model.Sample{
+			Metric: prometheus_model.Metric{
+				"destination_service":           prometheus_model.LabelValue(service),
+				"destination_service_namespace": prometheus_model.LabelValue(namespace),
+			},
+			Value: 1,
+		}
+	}
+	mockProm := mockPromAPI{
+		cannedResponse: map[string]prometheus_model.Value{
+			"sum(increase(istio_requests_total{reporter=\"source\",source_workload_namespace=\"default\"}[1d])) by (destination_service, destination_service_namespace) > 0": prometheus_model.Vector{ // nolint: lll
+				destination("reviews.default.svc.cluster.local", "default"),
+				destination("httpbin.org", "unknown"),
+				destination("unknown", "unknown"),
+			},
+			"sum(increase(istio_tcp_connections_opened_total{reporter=\"source\",source_workload_namespace=\"default\"}[1d])) by (destination_service, destination_service_namespace) > 0": prometheus_model.Vector{ // nolint: lll
+				destination("mysql.db.svc.cluster.local", "db"),
+				destination("PassthroughCluster", ""),
+			},
+		},
+	}
+
+	hosts, err := observedEgressHosts(mockProm, "default", 24*time.Hour)
+	if err != nil {
+		t.Fatalf("Unwanted exception %v", err)
+	}
+	want := []string{"*/httpbin.org", "db/mysql.db.svc.cluster.local", "default/reviews.default.svc.cluster.local", "istio-system/*"}
+	if !reflect.DeepEqual(hosts, want) {
+		t.Fatalf("got hosts %v, want %v", hosts, want)
+	}
+}
+
+func TestScopeNamespace(t *testing.T) {
+	client := kube.NewFakeClient()
+	sidecars := client.Istio().NetworkingV1alpha3().Sidecars("default")
+
+	// A dry run only reports the changes.
+	var out bytes.Buffer
+	if err := scopeNamespace(&out, client, "default", "default", []string{"istio-system/*", "default/a"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sidecars.Get(context.TODO(), "default", metav1.GetOptions{}); err == nil {
+		t.Fatalf("expected no Sidecar to be created in dry run")
+	}
+	if got := out.String(); !bytes.HasPrefix(out.Bytes(), []byte("Namespace default: egress scope restricted to 2 hosts\n  + istio-system/*\n  + default/a\n---\n")) {
+		t.Fatalf("unexpected report %q", got)
+	}
+
+	if err := scopeNamespace(&out, client, "default", "default", []string{"istio-system/*", "default/a"}, false); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := scopeNamespace(&out, client, "default", "default", []string{"istio-system/*", "default/b"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "Namespace default: egress scope changed to 2 hosts\n  + default/b\n  - default/a\n"; got != want {
+		t.Fatalf("got report %q, want %q", got, want)
+	}
+	sc, err := sidecars.Get(context.TODO(), "default", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := egressHosts(sc), []string{"istio-system/*", "default/b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got egress hosts %v, want %v", got, want)
+	}
+
+	// Sidecars not generated by sidecar-scope are left alone.
+	manual := buildScopedSidecar("other", "default", []string{"./*"})
+	manual.Labels = nil
+	if _, err := client.Istio().NetworkingV1alpha3().Sidecars("other").Create(context.TODO(), manual, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := scopeNamespace(&out, client, "other", "default", []string{"istio-system/*"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "Sidecar other/default was not generated by sidecar-scope, skipped\n"; got != want {
+		t.Fatalf("got report %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,318 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/gogo/protobuf/jsonpb"
+	"github.com/gogo/protobuf/types"
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networking "istio.io/api/networking/v1alpha3"
+	clientnetworking "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	"istio.io/istio/istioctl/pkg/util/handlers"
+	"istio.io/istio/pkg/kube"
+)
+
+const (
+	tapFilterName  = "envoy.filters.http.tap"
+	tapFilterType  = "type.googleapis.com/envoy.extensions.filters.http.tap.v3.Tap"
+	tapRouterName  = "envoy.filters.http.router"
+	envoyAdminPort = 15000
+)
+
+type tapOptions struct {
+	outbound   bool
+	pathPrefix string
+	maxTraces  int
+	duration   time.Duration
+	timeout    time.Duration
+}
+
+func tapCmd() *cobra.Command {
+	opts := tapOptions{}
+	cmd := &cobra.Command{
+		Use:   "tap <pod-name[.namespace]>",
+		Short: "Streams the HTTP requests handled by the Envoy sidecar of a pod",
+		Long: `Configures the Envoy tap filter on the sidecar of the pod with a temporary EnvoyFilter, and streams the
+requests and responses it matches to the terminal, until the command is interrupted. The EnvoyFilter is deleted
+afterwards.
+
+The EnvoyFilter selects the pods with the labels of the pod, so the filter is also added to the other replicas of
+the workload, but only the traffic of the pod is tapped.`,
+		Example: `  # Stream the inbound requests of the productpage pod
+  istioctl x tap productpage-v1-7b7f7b7d5-2p4xz
+
+  # Stream the first 10 inbound and outbound requests whose path starts with /api, as JSON
+  istioctl x tap productpage-v1-7b7f7b7d5-2p4xz.default --outbound --path /api --max-requests 10 -o json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			if outputFormat != summaryOutput && outputFormat != jsonOutput {
+				return fmt.Errorf("output format %q not supported", outputFormat)
+			}
+			client, err := kubeClient(kubeconfig, configContext)
+			if err != nil {
+				return fmt.Errorf("failed to create k8s client: %v", err)
+			}
+			podName, ns, err := handlers.InferPodInfoFromTypedResource(args[0],
+				handlers.HandleNamespace(namespace, defaultNamespace), client.UtilFactory())
+			if err != nil {
+				return err
+			}
+			pod, err := client.Kube().CoreV1().Pods(ns).Get(context.TODO(), podName, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			return tap(c, client, pod, opts)
+		},
+	}
+
+	cmd.PersistentFlags().BoolVar(&opts.outbound, "outbound", false, "Tap the outbound requests as well as the inbound requests")
+	cmd.PersistentFlags().StringVar(&opts.pathPrefix, "path", "", "Only tap the requests whose path starts with this prefix")
+	cmd.PersistentFlags().IntVar(&opts.maxTraces, "max-requests", 0, "Stop after this number of requests, 0 for no limit")
+	cmd.PersistentFlags().DurationVar(&opts.duration, "duration", 0, "Stop after this duration, 0 for no limit")
+	cmd.PersistentFlags().DurationVar(&opts.timeout, "timeout", 30*time.Second,
+		"The time to wait for the sidecar to apply the tap filter")
+	cmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", summaryOutput, "Output format: one of json|short")
+	return cmd
+}
+
+func tap(c *cobra.Command, client kube.ExtendedClient, pod *v1.Pod, opts tapOptions) error {
+	configID := "istioctl-tap-" + pod.Name
+	ef, err := tapEnvoyFilter(pod, configID, opts.outbound)
+	if err != nil {
+		return err
+	}
+	filters := client.Istio().NetworkingV1alpha3().EnvoyFilters(pod.Namespace)
+	if _, err := filters.Create(context.TODO(), ef, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create the EnvoyFilter %s/%s: %v", pod.Namespace, ef.Name, err)
+	}
+	defer func() {
+		if err := filters.Delete(context.TODO(), ef.Name, metav1.DeleteOptions{}); err != nil {
+			c.PrintErrf("Failed to delete the EnvoyFilter %s/%s: %v\n", pod.Namespace, ef.Name, err)
+		}
+	}()
+
+	fw, err := client.NewPortForwarder(pod.Name, pod.Namespace, "", 0, envoyAdminPort)
+	if err != nil {
+		return fmt.Errorf("could not build port forwarder for %s: %v", pod.Name, err)
+	}
+	if err := fw.Start(); err != nil {
+		return fmt.Errorf("could not start port forwarder for %s: %v", pod.Name, err)
+	}
+	defer fw.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if opts.duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, opts.duration)
+		defer cancel()
+	}
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt)
+		defer signal.Stop(signals)
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	body, err := startTap(ctx, "http://"+fw.Address()+"/tap", tapRequest(configID, opts.pathPrefix), opts.timeout)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	c.PrintErrf("Tapping the requests of %s.%s, press Ctrl+C to stop\n", pod.Name, pod.Namespace)
+	err = streamTaps(body, c.OutOrStdout(), opts.maxTraces, outputFormat == jsonOutput)
+	if ctx.Err() != nil {
+		// The stream was closed by the interruption or the end of the duration.
+		return nil
+	}
+	return err
+}
+
+// tapEnvoyFilter returns the EnvoyFilter adding the tap filter, configured through the admin endpoint of Envoy with
+// the config ID, before the router filter of the HTTP listeners of the pod.
+func tapEnvoyFilter(pod *v1.Pod, configID string, outbound bool) (*clientnetworking.EnvoyFilter, error) {
+	value := &types.Struct{}
+	if err := jsonpb.UnmarshalString(fmt.Sprintf(`{"name": %q, "typed_config": {"@type": %q, "common_config": {"admin_config": {"config_id": %q}}}}`,
+		tapFilterName, tapFilterType, configID), value); err != nil {
+		return nil, err
+	}
+	contexts := []networking.EnvoyFilter_PatchContext{networking.EnvoyFilter_SIDECAR_INBOUND}
+	if outbound {
+		contexts = append(contexts, networking.EnvoyFilter_SIDECAR_OUTBOUND)
+	}
+	var patches []*networking.EnvoyFilter_EnvoyConfigObjectPatch
+	for _, ctx := range contexts {
+		patches = append(patches, &networking.EnvoyFilter_EnvoyConfigObjectPatch{
+			ApplyTo: networking.EnvoyFilter_HTTP_FILTER,
+			Match: &networking.EnvoyFilter_EnvoyConfigObjectMatch{
+				Context: ctx,
+				ObjectTypes: &networking.EnvoyFilter_EnvoyConfigObjectMatch_Listener{
+					Listener: &networking.EnvoyFilter_ListenerMatch{
+						FilterChain: &networking.EnvoyFilter_ListenerMatch_FilterChainMatch{
+							Filter: &networking.EnvoyFilter_ListenerMatch_FilterMatch{
+								Name:      "envoy.filters.network.http_connection_manager",
+								SubFilter: &networking.EnvoyFilter_ListenerMatch_SubFilterMatch{Name: tapRouterName},
+							},
+						},
+					},
+				},
+			},
+			Patch: &networking.EnvoyFilter_Patch{
+				Operation: networking.EnvoyFilter_Patch_INSERT_BEFORE,
+				Value:     value,
+			},
+		})
+	}
+	return &clientnetworking.EnvoyFilter{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      configID,
+			Namespace: pod.Namespace,
+			Labels:    map[string]string{"istio.io/generated-by": "istioctl-tap"},
+		},
+		Spec: networking.EnvoyFilter{
+			WorkloadSelector: &networking.WorkloadSelector{Labels: pod.Labels},
+			ConfigPatches:    patches,
+		},
+	}, nil
+}
+
+// tapRequest returns the body of the request to the tap admin endpoint, matching the requests whose path starts with
+// the prefix, or all the requests.
+func tapRequest(configID, pathPrefix string) []byte {
+	match := map[string]interface{}{"any_match": true}
+	if pathPrefix != "" {
+		match = map[string]interface{}{"http_request_headers_match": map[string]interface{}{
+			"headers": []interface{}{map[string]interface{}{"name": ":path", "prefix_match": pathPrefix}},
+		}}
+	}
+	out, _ := json.Marshal(map[string]interface{}{
+		"config_id": configID,
+		"tap_config": map[string]interface{}{
+			"match": match,
+			"output_config": map[string]interface{}{
+				"sinks": []interface{}{map[string]interface{}{"streaming_admin": map[string]interface{}{}}},
+			},
+		},
+	})
+	return out
+}
+
+// startTap starts streaming the taps from the admin endpoint, retrying until the tap filter is applied by the
+// sidecar or the timeout expires.
+func startTap(ctx context.Context, url string, request []byte, timeout time.Duration) (io.ReadCloser, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(request))
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusOK {
+			return resp.Body, nil
+		}
+		msg, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("the tap filter was not applied by the sidecar within %v: %s", timeout,
+				strings.TrimSpace(string(msg)))
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+type tapHeader struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type tapMessage struct {
+	Headers []tapHeader `json:"headers"`
+}
+
+func (m tapMessage) header(key string) string {
+	for _, h := range m.Headers {
+		if h.Key == key {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// tapTrace is a trace streamed by the tap admin endpoint.
+type tapTrace struct {
+	HTTPBufferedTrace *struct {
+		Request  tapMessage `json:"request"`
+		Response tapMessage `json:"response"`
+	} `json:"http_buffered_trace"`
+}
+
+// streamTaps writes the traces read from r, as JSON or as a line per request, until r is closed or max traces were
+// written if max is positive.
+func streamTaps(r io.Reader, w io.Writer, max int, raw bool) error {
+	dec := json.NewDecoder(r)
+	for n := 0; max <= 0 || n < max; n++ {
+		var msg json.RawMessage
+		if err := dec.Decode(&msg); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if raw {
+			out := &bytes.Buffer{}
+			if err := json.Indent(out, msg, "", "  "); err != nil {
+				return err
+			}
+			fmt.Fprintln(w, out.String())
+			continue
+		}
+		var t tapTrace
+		if err := json.Unmarshal(msg, &t); err != nil {
+			return err
+		}
+		if t.HTTPBufferedTrace == nil {
+			continue
+		}
+		req, resp := t.HTTPBufferedTrace.Request, t.HTTPBufferedTrace.Response
+		fmt.Fprintf(w, "%s %s %s%s %s\n", time.Now().Format("15:04:05.000"), req.header(":method"),
+			req.header(":authority"), req.header(":path"), resp.header(":status"))
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networking "istio.io/api/networking/v1alpha3"
+)
+
+func TestTapEnvoyFilter(t *testing.T) {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "productpage-1", Namespace: "default", Labels: map[string]string{"app": "productpage"}}}
+	ef, err := tapEnvoyFilter(pod, "istioctl-tap-productpage-1", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ef.Namespace != "default" || ef.Spec.WorkloadSelector.Labels["app"] != "productpage" {
+		t.Errorf("unexpected EnvoyFilter %v", ef)
+	}
+	if len(ef.Spec.ConfigPatches) != 2 || ef.Spec.ConfigPatches[1].Match.Context != networking.EnvoyFilter_SIDECAR_OUTBOUND {
+		t.Fatalf("expected inbound and outbound patches, got %v", ef.Spec.ConfigPatches)
+	}
+	cfg := ef.Spec.ConfigPatches[0].Patch.Value.Fields["typed_config"].GetStructValue()
+	id := cfg.Fields["common_config"].GetStructValue().Fields["admin_config"].GetStructValue().Fields["config_id"].GetStringValue()
+	if id != "istioctl-tap-productpage-1" {
+		t.Errorf("got config ID %q", id)
+	}
+}
+
+func TestTapRequest(t *testing.T) {
+	var req map[string]interface{}
+	if err := json.Unmarshal(tapRequest("id", "/api"), &req); err != nil {
+		t.Fatal(err)
+	}
+	match := req["tap_config"].(map[string]interface{})["match"].(map[string]interface{})
+	if _, f := match["http_request_headers_match"]; !f || req["config_id"] != "id" {
+		t.Errorf("unexpected tap request %v", req)
+	}
+}
+
+func TestStartTap(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("Unknown config id 'id'. No extension has registered with this id."))
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	body, err := startTap(context.Background(), server.URL, tapRequest("id", ""), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	if out, _ := ioutil.ReadAll(body); string(out) != "{}" || attempts != 2 {
+		t.Errorf("got %q after %d attempts", out, attempts)
+	}
+
+	attempts = 0
+	if _, err := startTap(context.Background(), server.URL, tapRequest("id", ""), 0); err == nil ||
+		!strings.Contains(err.Error(), "Unknown config id") {
+		t.Errorf("expected the error of the admin endpoint, got %v", err)
+	}
+}
+
+func TestStreamTaps(t *testing.T) {
+	trace := func(path, status string) string {
+		return `{"http_buffered_trace": {"request": {"headers": [{"key": ":method", "value": "GET"},
+{"key": ":authority", "value": "productpage:9080"}, {"key": ":path", "value": "` + path + `"}]},
+"response": {"headers": [{"key": ":status", "value": "` + status + `"}]}}}`
+	}
+	in := trace("/productpage", "200") + trace("/login", "302") + trace("/logout", "200")
+
+	out := &bytes.Buffer{}
+	if err := streamTaps(strings.NewReader(in), out, 2, false); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], " GET productpage:9080/productpage 200") ||
+		!strings.HasSuffix(lines[1], " GET productpage:9080/login 302") {
+		t.Errorf("unexpected output %q", out.String())
+	}
+
+	out.Reset()
+	if err := streamTaps(strings.NewReader(in), out, 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(out.String(), "http_buffered_trace"); n != 3 {
+		t.Errorf("got %d JSON traces, want 3", n)
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"istio.io/istio/istioctl/pkg/topology"
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/kube"
+)
+
+const dotOutput = "dot"
+
+// topologySchemas are the schemas of the configs shown in the topology.
+var topologySchemas = []collection.Schema{
+	collections.IstioNetworkingV1Alpha3Gateways,
+	collections.IstioNetworkingV1Alpha3Serviceentries,
+	collections.IstioNetworkingV1Alpha3Virtualservices,
+}
+
+func topologyCmd() *cobra.Command {
+	var revision string
+	cmd := &cobra.Command{
+		Use:   "topology",
+		Short: "Exports the topology of the mesh as seen by the control plane",
+		Long: `Builds a graph of the namespaces, services, gateways, ServiceEntries and clusters of the mesh from the
+registries and the configuration of the control plane, and exports it in the DOT language of Graphviz or as JSON.
+
+The edges link the gateways to the services their VirtualServices route to, the ServiceEntries to the services they
+define, the services to the clusters running their endpoints, and the clusters sharing services. The output is
+sorted, so that the topology can be reviewed offline and diffed over time.`,
+		Example: `  # Render the topology of the mesh as an SVG image
+  istioctl x topology | dot -Tsvg > mesh.svg
+
+  # Export the topology seen by the canary revision as JSON
+  istioctl x topology --revision canary -o json`,
+		Args: cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			client, err := kubeClientWithRevision(kubeconfig, configContext, revision)
+			if err != nil {
+				return fmt.Errorf("failed to create k8s client: %v", err)
+			}
+			services, err := topologyServices(client)
+			if err != nil {
+				return err
+			}
+			configs, err := topologyConfigs(client)
+			if err != nil {
+				return err
+			}
+			endpoints, err := topologyEndpoints(client)
+			if err != nil {
+				return err
+			}
+			g := topology.Build(services, configs, endpoints)
+
+			switch outputFormat {
+			case jsonOutput:
+				return g.WriteJSON(c.OutOrStdout())
+			case dotOutput:
+				return g.WriteDOT(c.OutOrStdout())
+			default:
+				return fmt.Errorf("output format %q not supported", outputFormat)
+			}
+		},
+	}
+
+	cmd.PersistentFlags().StringVarP(&revision, "revision", "r", "", "The control plane revision")
+	cmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", dotOutput, "Output format: one of dot|json")
+	return cmd
+}
+
+// topologyServices returns the services of the registries of all the Istiod instances, without duplicates.
+func topologyServices(client kube.ExtendedClient) ([]topology.Service, error) {
+	responses, err := client.AllDiscoveryDo(context.TODO(), istioNamespace, "/debug/registryz")
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]struct{}{}
+	var out []topology.Service
+	for _, istiod := range sortedResponseKeys(responses) {
+		var services []topology.Service
+		if err := json.Unmarshal(responses[istiod], &services); err != nil {
+			return nil, fmt.Errorf("failed to parse the registry of %s: %v", istiod, err)
+		}
+		for _, svc := range services {
+			key := svc.Attributes.Namespace + "/" + svc.Hostname
+			if _, f := seen[key]; f || svc.Hostname == "" {
+				continue
+			}
+			seen[key] = struct{}{}
+			out = append(out, svc)
+		}
+	}
+	return out, nil
+}
+
+// topologyConfigs returns the Gateways, VirtualServices and ServiceEntries known to the Istiod instances.
+func topologyConfigs(client kube.ExtendedClient) ([]config.Config, error) {
+	responses, err := client.AllDiscoveryDo(context.TODO(), istioNamespace, "/debug/configz")
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]struct{}{}
+	var out []config.Config
+	for _, istiod := range sortedResponseKeys(responses) {
+		var objs []crd.IstioKind
+		if err := json.Unmarshal(responses[istiod], &objs); err != nil {
+			return nil, fmt.Errorf("failed to parse the configuration of %s: %v", istiod, err)
+		}
+		for i := range objs {
+			obj := &objs[i]
+			for _, s := range topologySchemas {
+				if obj.Kind != s.Resource().Kind() || obj.GroupVersionKind().Group != s.Resource().Group() {
+					continue
+				}
+				key := obj.Kind + "/" + obj.Namespace + "/" + obj.Name
+				if _, f := seen[key]; f {
+					break
+				}
+				seen[key] = struct{}{}
+				cfg, err := crd.ConvertObject(s, obj, constants.DefaultKubernetesDomain)
+				if err != nil {
+					return nil, fmt.Errorf("failed to convert %s: %v", key, err)
+				}
+				out = append(out, *cfg)
+			}
+		}
+	}
+	return out, nil
+}
+
+// topologyEndpoints returns the number of endpoints of the services in each cluster, as tracked by the Istiod
+// instances. The count of the Istiod tracking the most endpoints is kept.
+func topologyEndpoints(client kube.ExtendedClient) ([]topology.Endpoints, error) {
+	responses, err := client.AllDiscoveryDo(context.TODO(), istioNamespace, "/debug/endpointShardz")
+	if err != nil {
+		return nil, err
+	}
+	counts := map[topology.Endpoints]int{}
+	for istiod, response := range responses {
+		var shards map[string]map[string]struct {
+			Shards map[string][]json.RawMessage
+		}
+		if err := json.Unmarshal(response, &shards); err != nil {
+			return nil, fmt.Errorf("failed to parse the endpoints of %s: %v", istiod, err)
+		}
+		for hostname, byNamespace := range shards {
+			for ns, s := range byNamespace {
+				for cluster, eps := range s.Shards {
+					key := topology.Endpoints{Hostname: hostname, Namespace: ns, Cluster: cluster}
+					if len(eps) > counts[key] {
+						counts[key] = len(eps)
+					}
+				}
+			}
+		}
+	}
+	out := make([]topology.Endpoints, 0, len(counts))
+	for key, count := range counts {
+		key.Count = count
+		out = append(out, key)
+	}
+	return out, nil
+}
+
+func sortedResponseKeys(responses map[string][]byte) []string {
+	out := make([]string, 0, len(responses))
+	for k := range responses {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
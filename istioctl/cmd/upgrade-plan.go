@@ -0,0 +1,251 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/istioctl/pkg/writer/compare"
+	"istio.io/istio/pilot/pkg/xds"
+)
+
+// upgradeProxy is a proxy connected to a revision, considered by upgrade-plan.
+type upgradeProxy struct {
+	id        string
+	proxyType string
+	pod       *v1.Pod
+}
+
+func upgradePlanCmd() *cobra.Command {
+	var currentRevision, targetRevision string
+	cmd := &cobra.Command{
+		Use:   "upgrade-plan",
+		Short: "Reports the impact of an upgrade on the configuration of the proxies",
+		Long: `Compares the configuration generated for the proxies by the current revision of the control plane with
+the configuration generated by the target revision, and reports the behavior changing differences: filters ordered
+differently, fields whose values or defaults changed, deprecated fields in use and EnvoyFilter patches which no
+longer apply.
+
+The target revision must be installed, and for each type of proxy, at least a proxy must be connected to each
+revision. Proxies of the same workload are compared when possible, otherwise the differences between the workloads
+are reported as well.`,
+		Example: `  # Report the impact of moving the proxies of the default revision to the canary revision
+  istioctl x upgrade-plan --revision canary
+
+  # Report the impact of moving the proxies from the 1-8 revision to the 1-9 revision
+  istioctl x upgrade-plan --current-revision 1-8 --revision 1-9 -o json`,
+		Args: cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			if targetRevision == currentRevision {
+				return fmt.Errorf("the target revision must differ from the current revision %q", currentRevision)
+			}
+			current, err := upgradeProxies(currentRevision)
+			if err != nil {
+				return fmt.Errorf("failed to list the proxies of the current revision: %v", err)
+			}
+			target, err := upgradeProxies(targetRevision)
+			if err != nil {
+				return fmt.Errorf("failed to list the proxies of the target revision: %v", err)
+			}
+
+			var plans []*compare.UpgradePlan
+			for _, pair := range pairUpgradeProxies(current, target) {
+				if pair[0] == nil || pair[1] == nil {
+					connected := pair[0]
+					revision := targetRevision
+					if connected == nil {
+						connected = pair[1]
+						revision = currentRevision
+					}
+					c.PrintErrf("Skipping proxy type %s: no proxy is connected to the revision %q\n",
+						connected.proxyType, revisionName(revision))
+					continue
+				}
+				cur, err := fetchUpgradeProxy(currentRevision, pair[0])
+				if err != nil {
+					return err
+				}
+				tgt, err := fetchUpgradeProxy(targetRevision, pair[1])
+				if err != nil {
+					return err
+				}
+				plan, err := compare.PlanUpgrade(pair[0].proxyType, cur, tgt)
+				if err != nil {
+					return err
+				}
+				plans = append(plans, plan)
+			}
+
+			switch outputFormat {
+			case jsonOutput:
+				out, err := json.MarshalIndent(plans, "", "  ")
+				if err != nil {
+					return err
+				}
+				_, err = fmt.Fprintln(c.OutOrStdout(), string(out))
+				return err
+			case summaryOutput:
+				for i, plan := range plans {
+					if i > 0 {
+						fmt.Fprintln(c.OutOrStdout())
+					}
+					plan.Print(c.OutOrStdout())
+				}
+				return nil
+			default:
+				return fmt.Errorf("output format %q not supported", outputFormat)
+			}
+		},
+	}
+
+	cmd.PersistentFlags().StringVarP(&targetRevision, "revision", "r", "",
+		"The target control plane revision")
+	cmd.PersistentFlags().StringVar(&currentRevision, "current-revision", "",
+		"The current control plane revision, the default revision if unset")
+	cmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", summaryOutput, "Output format: one of json|short")
+	return cmd
+}
+
+func revisionName(revision string) string {
+	if revision == "" {
+		return "default"
+	}
+	return revision
+}
+
+// upgradeProxies returns the proxies connected to the revision, sorted by ID.
+func upgradeProxies(revision string) ([]*upgradeProxy, error) {
+	client, err := kubeClientWithRevision(kubeconfig, configContext, revision)
+	if err != nil {
+		return nil, err
+	}
+	responses, err := client.AllDiscoveryDo(context.TODO(), istioNamespace, "/debug/config_sizez")
+	if err != nil {
+		return nil, err
+	}
+	var out []*upgradeProxy
+	for _, response := range responses {
+		var sizes []xds.ConfigSize
+		if err := json.Unmarshal(response, &sizes); err != nil {
+			return nil, err
+		}
+		for _, s := range sizes {
+			i := strings.LastIndex(s.ProxyID, ".")
+			if i < 0 {
+				continue
+			}
+			pod, err := client.Kube().CoreV1().Pods(s.ProxyID[i+1:]).Get(context.TODO(), s.ProxyID[:i], metav1.GetOptions{})
+			if err != nil {
+				// The proxy may not run in a pod of this cluster.
+				continue
+			}
+			out = append(out, &upgradeProxy{id: s.ProxyID, proxyType: string(s.ProxyType), pod: pod})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].id < out[j].id
+	})
+	return out, nil
+}
+
+// workloadKey identifies the workload of the pod, to compare the proxies of the same workload.
+func workloadKey(pod *v1.Pod) string {
+	for _, l := range []string{"app", "istio"} {
+		if v, f := pod.Labels[l]; f {
+			return pod.Namespace + "/" + l + "=" + v
+		}
+	}
+	return pod.Namespace + "/" + pod.GenerateName
+}
+
+// pairUpgradeProxies returns, for each proxy type, a proxy of the current revision and a proxy of the target
+// revision, nil if no proxy of the type is connected to the revision. The proxies of a workload having proxies
+// connected to both revisions are preferred.
+func pairUpgradeProxies(current, target []*upgradeProxy) [][2]*upgradeProxy {
+	byType := map[string]*[2]*upgradeProxy{}
+	var types []string
+	get := func(t string) *[2]*upgradeProxy {
+		if _, f := byType[t]; !f {
+			byType[t] = &[2]*upgradeProxy{}
+			types = append(types, t)
+		}
+		return byType[t]
+	}
+	for _, t := range target {
+		pair := get(t.proxyType)
+		for _, c := range current {
+			if c.proxyType == t.proxyType && workloadKey(c.pod) == workloadKey(t.pod) && pair[0] == nil {
+				pair[0], pair[1] = c, t
+			}
+		}
+		if pair[1] == nil {
+			pair[1] = t
+		}
+	}
+	for _, c := range current {
+		if pair := get(c.proxyType); pair[0] == nil {
+			pair[0] = c
+		}
+	}
+	sort.Strings(types)
+	out := make([][2]*upgradeProxy, 0, len(types))
+	for _, t := range types {
+		out = append(out, *byType[t])
+	}
+	return out
+}
+
+// fetchUpgradeProxy fetches the configuration generated for the proxy by its revision, and the results of the
+// EnvoyFilter patches.
+func fetchUpgradeProxy(revision string, p *upgradeProxy) (*compare.UpgradeProxy, error) {
+	client, err := kubeClientWithRevision(kubeconfig, configContext, revision)
+	if err != nil {
+		return nil, err
+	}
+	out := &compare.UpgradeProxy{Name: p.id, Revision: revisionName(revision), IP: p.pod.Status.PodIP}
+	dumps, err := client.AllDiscoveryDo(context.TODO(), istioNamespace, "/debug/config_dump?proxyID="+p.id)
+	if err != nil {
+		return nil, err
+	}
+	if out.ConfigDump = connectedResponse(dumps, &map[string]interface{}{}); out.ConfigDump == nil {
+		return nil, fmt.Errorf("unable to find the config dump of %s in the responses of the revision %q", p.id,
+			revisionName(revision))
+	}
+	patches, err := client.AllDiscoveryDo(context.TODO(), istioNamespace, "/debug/envoyfilterz?proxyID="+p.id)
+	if err != nil {
+		return nil, err
+	}
+	connectedResponse(patches, &out.EnvoyFilterPatches)
+	return out, nil
+}
+
+// connectedResponse returns the response of the Istiod the proxy is connected to, the only one with a JSON body,
+// unmarshalled into v. It returns nil if none is found.
+func connectedResponse(responses map[string][]byte, v interface{}) []byte {
+	for _, r := range responses {
+		if err := json.Unmarshal(r, v); err == nil {
+			return r
+		}
+	}
+	return nil
+}
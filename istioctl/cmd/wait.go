@@ -44,6 +44,7 @@ var (
 	timeout      time.Duration
 	generation   string
 	verbose      bool
+	proxyType    string
 	targetSchema collection.Schema
 	clientGetter func(string, string) (dynamic.Interface, error)
 )
@@ -62,10 +63,16 @@ func waitCmd() *cobra.Command {
 
   # Wait until 99% of the proxies receive the distribution, timing out after 5 minutes
   istioctl experimental wait --for=distribution --threshold=.99 --timeout=300 virtualservice bookinfo.default
+
+  # Wait until the bookinfo gateway virtual service has been distributed to all gateways
+  istioctl experimental wait --for=distribution --proxy-type=router virtualservice bookinfo-gateway.default
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			printVerbosef(cmd, "kubeconfig %s", kubeconfig)
 			printVerbosef(cmd, "ctx %s", configContext)
+			if proxyType != "" && proxyType != "sidecar" && proxyType != "router" {
+				return fmt.Errorf("--proxy-type must be 'sidecar' or 'router', got: %s", proxyType)
+			}
 			if forFlag == "delete" {
 				return errors.New("wait for delete is not yet implemented")
 			} else if forFlag != "distribution" {
@@ -100,8 +107,8 @@ func waitCmd() *cobra.Command {
 				if err != nil {
 					return err
 				} else if float32(present)/float32(present+notpresent) >= threshold {
-					_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Resource %s present on %d out of %d sidecars\n",
-						targetResource, present, present+notpresent)
+					_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Resource %s present on %d out of %d %s\n",
+						targetResource, present, present+notpresent, proxyNoun())
 					return nil
 				}
 				select {
@@ -117,8 +124,8 @@ func waitCmd() *cobra.Command {
 					printVerbosef(cmd, "timeout")
 					// I think this means the timeout has happened:
 					t.Stop()
-					return fmt.Errorf("timeout expired before resource %s became effective on all sidecars",
-						targetResource)
+					return fmt.Errorf("timeout expired before resource %s became effective on all %s",
+						targetResource, proxyNoun())
 				}
 			}
 		},
@@ -139,6 +146,8 @@ func waitCmd() *cobra.Command {
 	cmd.PersistentFlags().StringVar(&generation, "generation", "",
 		"Wait for a specific generation of config to become current, rather than using whatever is latest in "+
 			"Kubernetes")
+	cmd.PersistentFlags().StringVar(&proxyType, "proxy-type", "",
+		"Only wait for proxies of this type, 'sidecar' or 'router' (gateways). By default all proxies are counted")
 	cmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "enables verbose output")
 	_ = cmd.PersistentFlags().MarkHidden("verbose")
 	opts.AttachControlPlaneFlags(cmd)
@@ -166,12 +175,20 @@ func validateType(kind string) error {
 	return fmt.Errorf("type %s is not recognized", originalKind)
 }
 
-func countVersions(versionCount map[string]int, configVersion string) {
-	if count, ok := versionCount[configVersion]; ok {
-		versionCount[configVersion] = count + 1
-	} else {
-		versionCount[configVersion] = 1
+// proxyNoun names the proxies being waited for in messages.
+func proxyNoun() string {
+	if proxyType == "router" {
+		return "gateways"
 	}
+	return "sidecars"
+}
+
+// proxySynced returns true if the proxy acknowledged an accepted generation of the resource in all of
+// its clusters, listeners and routes.
+func proxySynced(versions xds.SyncedVersions, acceptedVersions []string) bool {
+	return contains(acceptedVersions, versions.ClusterVersion) &&
+		contains(acceptedVersions, versions.ListenerVersion) &&
+		contains(acceptedVersions, versions.RouteVersion)
 }
 
 func poll(cmd *cobra.Command, acceptedVersions []string, targetResource string, opts clioptions.ControlPlaneOptions) (present, notpresent int, err error) {
@@ -180,12 +197,15 @@ func poll(cmd *cobra.Command, acceptedVersions []string, targetResource string,
 		return 0, 0, err
 	}
 	path := fmt.Sprintf("/debug/config_distribution?resource=%s", targetResource)
+	if proxyType != "" {
+		path += "&proxy_type=" + proxyType
+	}
 	pilotResponses, err := kubeClient.AllDiscoveryDo(context.TODO(), istioNamespace, path)
 	if err != nil {
 		return 0, 0, fmt.Errorf("unable to query pilot for distribution "+
 			"(are you using pilot version >= 1.4 with config distribution tracking on): %s", err)
 	}
-	versionCount := make(map[string]int)
+	var pending []string
 	for _, response := range pilotResponses {
 		var configVersions []xds.SyncedVersions
 		err = json.Unmarshal(response, &configVersions)
@@ -194,18 +214,16 @@ func poll(cmd *cobra.Command, acceptedVersions []string, targetResource string,
 		}
 		printVerbosef(cmd, "sync status: %v", configVersions)
 		for _, configVersion := range configVersions {
-			countVersions(versionCount, configVersion.ClusterVersion)
-			countVersions(versionCount, configVersion.RouteVersion)
-			countVersions(versionCount, configVersion.ListenerVersion)
+			if proxySynced(configVersion, acceptedVersions) {
+				present++
+			} else {
+				notpresent++
+				pending = append(pending, configVersion.ProxyID)
+			}
 		}
 	}
-
-	for version, count := range versionCount {
-		if contains(acceptedVersions, version) {
-			present += count
-		} else {
-			notpresent += count
-		}
+	if len(pending) > 0 {
+		printVerbosef(cmd, "%s without the resource: %v", proxyNoun(), pending)
 	}
 	return present, notpresent, nil
 }
@@ -46,6 +46,16 @@ func TestWaitCmd(t *testing.T) {
 	cannedResponse, _ := json.Marshal(cannedResponseObj)
 	cannedResponseMap := map[string][]byte{"onlyonepilot": cannedResponse}
 
+	// The gateway has not acknowledged generation 1 in its routes yet.
+	partialResponse, _ := json.Marshal(append(cannedResponseObj, xds.SyncedVersions{
+		ProxyID:         "gateway",
+		ProxyType:       "router",
+		ClusterVersion:  "1",
+		ListenerVersion: "1",
+		RouteVersion:    "0",
+	}))
+	partialResponseMap := map[string][]byte{"onlyonepilot": partialResponse}
+
 	cases := []execTestCase{
 		{
 			execClientConfig: cannedResponseMap,
@@ -83,6 +93,23 @@ func TestWaitCmd(t *testing.T) {
 			args:             strings.Split("x wait --timeout 2s --revision canary virtualservice foo.default", " "),
 			wantException:    false,
 		},
+		{
+			execClientConfig: partialResponseMap,
+			args:             strings.Split("x wait --generation=1 --timeout=2s virtualservice foo.default", " "),
+			wantException:    true,
+		},
+		{
+			execClientConfig: partialResponseMap,
+			args:             strings.Split("x wait --generation=1 --threshold=.5 virtualservice foo.default", " "),
+			wantException:    false,
+			expectedOutput:   "Resource VirtualService/default/foo present on 1 out of 2 sidecars\n",
+		},
+		{
+			execClientConfig: cannedResponseMap,
+			args:             strings.Split("x wait --generation=1 --proxy-type=gateway virtualservice foo.default", " "),
+			wantException:    true,
+			expectedOutput:   "Error: --proxy-type must be 'sidecar' or 'router', got: gateway\n",
+		},
 	}
 
 	for i, c := range cases {
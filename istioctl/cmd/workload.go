@@ -21,6 +21,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"sort"
 	"strconv"
@@ -67,12 +68,30 @@ var (
 	labels         []string
 	annotations    []string
 	svcAcctAnn     string
+	vmAddress      string
+	vmInterface    string
+	sshHost        string
+	sshJumpHosts   []string
+	sshIdentity    string
+	remoteDir      string
+	systemdUnits   bool
 )
 
 const (
 	filePerms = os.FileMode(0744)
+
+	// defaultStatusPort is the port of the health endpoint of the sidecar if the proxy config does not set one.
+	defaultStatusPort = 15021
 )
 
+// runCommand runs an external command, used to deliver the generated files over SSH. Replaced in tests.
+var runCommand = func(out io.Writer, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	return cmd.Run()
+}
+
 func workloadCommands() *cobra.Command {
 	workloadCmd := &cobra.Command{
 		Use:   "workload",
@@ -185,12 +204,20 @@ func configureCommand() *cobra.Command {
 		Short: "Generates all the required configuration files for a workload instance running on a VM or non-Kubernetes environment",
 		Long: `Generates all the required configuration files for workload instance on a VM or non-Kubernetes environment from a WorkloadGroup artifact.
 This includes a MeshConfig resource, the cluster.env file, and necessary certificates and security tokens.
-Configure requires either the WorkloadGroup artifact path or its location on the API server.`,
+Configure requires either the WorkloadGroup artifact path or its location on the API server.
+
+By default the workload instance advertises the first address of the VM. On VMs with several network interfaces, the
+address reachable from the cluster can be set with --address, or resolved from a network interface when the sidecar
+starts with --interface. The generated files can optionally be copied to the VM over SSH, through jump hosts if the VM
+is not directly reachable, along with systemd units running the sidecar and reporting its health.`,
 		Example: `  # configure example using a local WorkloadGroup artifact
   configure -f workloadgroup.yaml -o config
 
   # configure example using the API server
-  configure --name foo --namespace bar -o config`,
+  configure --name foo --namespace bar -o config
+
+  # configure example advertising the address of the eth1 interface, with systemd units copied to the VM through a jump host
+  configure -f workloadgroup.yaml -o config --interface eth1 --systemd --ssh admin@10.0.0.5 --ssh-jump admin@bastion.example.com`,
 		Args: func(cmd *cobra.Command, args []string) error {
 			if filename == "" && (name == "" || namespace == "") {
 				return fmt.Errorf("expecting a WorkloadGroup artifact file or the name and namespace of an existing WorkloadGroup")
@@ -198,6 +225,17 @@ Configure requires either the WorkloadGroup artifact path or its location on the
 			if outputDir == "" {
 				return fmt.Errorf("expecting an output directory")
 			}
+			if vmAddress != "" && vmInterface != "" {
+				return fmt.Errorf("--address and --interface cannot be used together")
+			}
+			if vmAddress != "" {
+				if err := validation.ValidateIPAddress(vmAddress); err != nil {
+					return fmt.Errorf("invalid --address: %v", err)
+				}
+			}
+			if sshHost == "" && (len(sshJumpHosts) > 0 || sshIdentity != "") {
+				return fmt.Errorf("--ssh-jump and --ssh-identity require --ssh")
+			}
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -222,6 +260,12 @@ Configure requires either the WorkloadGroup artifact path or its location on the
 				return err
 			}
 			fmt.Printf("configuration generation into directory %s was successful\n", outputDir)
+			if sshHost != "" {
+				if err := deliverFiles(outputDir, cmd.OutOrStderr()); err != nil {
+					return fmt.Errorf("failed to copy the generated files to %s: %v", sshHost, err)
+				}
+				fmt.Printf("generated files were copied to %s:%s\n", sshHost, remoteDir)
+			}
 			return nil
 		},
 	}
@@ -236,6 +280,17 @@ Configure requires either the WorkloadGroup artifact path or its location on the
 	configureCmd.PersistentFlags().StringVar(&ingressIP, "ingressIP", "", "IP address of the ingress gateway")
 	configureCmd.PersistentFlags().BoolVar(&autoRegister, "autoregister", false, "Creates a WorkloadEntry upon connection to istiod (if enabled in pilot).")
 	configureCmd.PersistentFlags().BoolVar(&dnsCapture, "capture-dns", true, "Enables the capture of outgoing DNS packets on port 53, redirecting to istio-agent")
+	configureCmd.PersistentFlags().StringVar(&vmAddress, "address", "", "IP address of the workload instance reachable from the cluster. "+
+		"Defaults to the first address of the VM")
+	configureCmd.PersistentFlags().StringVar(&vmInterface, "interface", "", "Network interface of the VM whose address is advertised, "+
+		"resolved when the sidecar starts")
+	configureCmd.PersistentFlags().StringVar(&sshHost, "ssh", "", "Copy the generated files to the VM over SSH, in the format [user@]host")
+	configureCmd.PersistentFlags().StringSliceVar(&sshJumpHosts, "ssh-jump", nil, "Jump hosts to reach the VM over SSH, in the format [user@]host[:port]")
+	configureCmd.PersistentFlags().StringVar(&sshIdentity, "ssh-identity", "", "Private key file used to authenticate over SSH")
+	configureCmd.PersistentFlags().StringVar(&remoteDir, "remote-dir", "istio-vm", "Directory of the VM the generated files are copied to, "+
+		"relative to the home directory of the SSH user if not absolute")
+	configureCmd.PersistentFlags().BoolVar(&systemdUnits, "systemd", false, "Generates systemd units running the sidecar "+
+		"and periodically reporting its health")
 	opts.AttachControlPlaneFlags(configureCmd)
 	return configureCmd
 }
@@ -286,6 +341,11 @@ func createConfig(kubeClient kube.ExtendedClient, wg *clientv1alpha3.WorkloadGro
 	if err := createHosts(kubeClient, ingressIP, outputDir); err != nil {
 		return err
 	}
+	if systemdUnits {
+		if err := createSystemdUnits(proxyConfig, outputDir); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -310,6 +370,13 @@ func createClusterEnv(wg *clientv1alpha3.WorkloadGroup, config *meshconfig.Proxy
 		"ISTIO_SERVICE_CIDR":  "*",
 		"SERVICE_ACCOUNT":     we.ServiceAccount,
 	}
+	// the address advertised by the workload instance, by default the first address of the VM
+	if vmAddress != "" {
+		overrides["ISTIO_SVC_IP"] = vmAddress
+	}
+	if vmInterface != "" {
+		overrides["ISTIO_SVC_INTERFACE"] = vmInterface
+	}
 
 	// clusterEnv will use proxyMetadata from the proxyConfig + overrides specific to the WorkloadGroup and cmd args
 	// this is similar to the way the injector sets all values proxyConfig.proxyMetadata to the Pod's env
@@ -520,6 +587,90 @@ func createHosts(kubeClient kube.ExtendedClient, ingressIP, dir string) error {
 	return ioutil.WriteFile(filepath.Join(dir, "hosts"), []byte(hosts), filePerms)
 }
 
+const sidecarUnit = `[Unit]
+Description=istio-sidecar: The Istio sidecar
+Documentation=https://istio.io/
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+ExecStart=/usr/local/bin/istio-start.sh
+# The unit is only reported as started once the sidecar is ready
+ExecStartPost=/bin/sh -c 'until curl -fsS -o /dev/null %[1]s; do sleep 1; done'
+TimeoutStartSec=120
+Restart=always
+StartLimitInterval=0
+RestartSec=10
+
+[Install]
+WantedBy=multi-user.target
+`
+
+const healthUnit = `[Unit]
+Description=istio-sidecar: Health check of the Istio sidecar
+Requisite=istio.service
+After=istio.service
+
+[Service]
+Type=oneshot
+ExecStart=/usr/bin/curl -fsS -o /dev/null --max-time 5 %[1]s
+`
+
+const healthTimerUnit = `[Unit]
+Description=istio-sidecar: Periodic health check of the Istio sidecar
+
+[Timer]
+OnActiveSec=30s
+OnUnitActiveSec=30s
+
+[Install]
+WantedBy=timers.target
+`
+
+// Write the systemd units running the sidecar and checking its health into the given directory
+func createSystemdUnits(config *meshconfig.ProxyConfig, dir string) error {
+	statusPort := config.StatusPort
+	if statusPort == 0 {
+		statusPort = defaultStatusPort
+	}
+	readyURL := fmt.Sprintf("http://localhost:%d/healthz/ready", statusPort)
+	for file, unit := range map[string]string{
+		"istio.service":        sidecarUnit,
+		"istio-health.service": healthUnit,
+		"istio-health.timer":   healthTimerUnit,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, file), []byte(fmt.Sprintf(unit, readyURL)), filePerms); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Copies the generated files to the VM over SSH
+func deliverFiles(dir string, out io.Writer) error {
+	var opts []string
+	if sshIdentity != "" {
+		opts = append(opts, "-i", sshIdentity)
+	}
+	if len(sshJumpHosts) > 0 {
+		opts = append(opts, "-J", strings.Join(sshJumpHosts, ","))
+	}
+	mkdir := append(append([]string{}, opts...), sshHost, "mkdir -p "+shellescape.Quote(remoteDir))
+	if err := runCommand(out, "ssh", mkdir...); err != nil {
+		return err
+	}
+	files := []string{"cluster.env", "mesh.yaml", "root-cert.pem", "istio-token", "hosts"}
+	if systemdUnits {
+		files = append(files, "istio.service", "istio-health.service", "istio-health.timer")
+	}
+	scp := append([]string{}, opts...)
+	for _, f := range files {
+		scp = append(scp, filepath.Join(dir, f))
+	}
+	scp = append(scp, sshHost+":"+remoteDir+"/")
+	return runCommand(out, "scp", scp...)
+}
+
 // Returns a map with each k,v entry on a new line
 func mapToString(m map[string]string) string {
 	lines := []string{}
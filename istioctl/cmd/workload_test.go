@@ -17,8 +17,10 @@ package cmd
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"path"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -142,6 +144,24 @@ func TestWorkloadEntryConfigureInvalidArgs(t *testing.T) {
 			expectedException: true,
 			expectedOutput:    "Error: expecting an output directory\n",
 		},
+		{
+			description:       "Invalid command args - both address and interface",
+			args:              strings.Split("experimental workload entry configure -f file -o temp --address 10.0.0.5 --interface eth1", " "),
+			expectedException: true,
+			expectedOutput:    "Error: --address and --interface cannot be used together\n",
+		},
+		{
+			description:       "Invalid command args - invalid address",
+			args:              strings.Split("experimental workload entry configure -f file -o temp --address vm.example.com", " "),
+			expectedException: true,
+			expectedOutput:    "Error: invalid --address: vm.example.com is not a valid IP\n",
+		},
+		{
+			description:       "Invalid command args - jump host without SSH host",
+			args:              strings.Split("experimental workload entry configure -f file -o temp --ssh-jump bastion", " "),
+			expectedException: true,
+			expectedOutput:    "Error: --ssh-jump and --ssh-identity require --ssh\n",
+		},
 	}
 
 	for i, c := range cases {
@@ -155,7 +175,7 @@ const goldenSuffix = ".golden"
 
 // TestWorkloadEntryConfigure enumerates test cases based on subdirectories of testdata/vmconfig.
 // Each subdirectory contains two input files: workloadgroup.yaml and meshconfig.yaml that are used
-// to generate golden outputs from the VM command.
+// to generate golden outputs from the VM command, and optionally an args file with additional command arguments.
 func TestWorkloadEntryConfigure(t *testing.T) {
 	files, err := ioutil.ReadDir("testdata/vmconfig")
 	if err != nil {
@@ -192,6 +212,9 @@ func TestWorkloadEntryConfigure(t *testing.T) {
 				"-f", path.Join("testdata/vmconfig", dir.Name(), "workloadgroup.yaml"),
 				"-o", testdir,
 			}
+			if args, err := ioutil.ReadFile(path.Join(testdir, "args")); err == nil {
+				cmd = append(cmd, strings.Fields(string(args))...)
+			}
 			if _, err := runTestCmd(t, cmd); err != nil {
 				t.Fatal(err)
 			}
@@ -199,8 +222,9 @@ func TestWorkloadEntryConfigure(t *testing.T) {
 			checkFiles := map[string]bool{
 				// outputs to check
 				"mesh.yaml": true, "istio-token": true, "hosts": true, "root-cert.pem": true, "cluster.env": true,
+				"istio.service": true, "istio-health.service": true, "istio-health.timer": true,
 				// inputs that we allow to exist, if other files seep in unexpectedly we fail the test
-				".gitignore": false, "meshconfig.yaml": false, "workloadgroup.yaml": false,
+				".gitignore": false, "meshconfig.yaml": false, "workloadgroup.yaml": false, "args": false,
 			}
 
 			outputFiles, err := ioutil.ReadDir(testdir)
@@ -229,6 +253,55 @@ func TestWorkloadEntryConfigure(t *testing.T) {
 	}
 }
 
+func TestWorkloadEntryConfigureSSH(t *testing.T) {
+	testdir := "testdata/vmconfig/simple"
+	kubeClientWithRevision = func(_, _, _ string) (kube.ExtendedClient, error) {
+		return &kube.MockClient{
+			Interface: fake.NewSimpleClientset(
+				&v1.ServiceAccount{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "bar", Name: "vm-serviceaccount"},
+				},
+				&v1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "bar", Name: "istio-ca-root-cert"},
+					Data:       map[string]string{"root-cert.pem": string(fakeCACert)},
+				},
+				&v1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "istio-system", Name: "istio"},
+					Data: map[string]string{
+						"mesh": string(util.ReadFile(path.Join(testdir, "meshconfig.yaml"), t)),
+					},
+				},
+			),
+		}, nil
+	}
+	var commands []string
+	defer func(orig func(io.Writer, string, ...string) error) { runCommand = orig }(runCommand)
+	runCommand = func(_ io.Writer, name string, args ...string) error {
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		return nil
+	}
+
+	cmd := []string{
+		"x", "workload", "entry", "configure",
+		"-f", path.Join(testdir, "workloadgroup.yaml"),
+		"-o", testdir,
+		"--ssh", "admin@10.0.0.5", "--ssh-jump", "admin@bastion,admin@bastion2", "--ssh-identity", "id_rsa",
+	}
+	if _, err := runTestCmd(t, cmd); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"ssh -i id_rsa -J admin@bastion,admin@bastion2 admin@10.0.0.5 mkdir -p 'istio-vm'",
+		"scp -i id_rsa -J admin@bastion,admin@bastion2 " + strings.Join([]string{
+			path.Join(testdir, "cluster.env"), path.Join(testdir, "mesh.yaml"), path.Join(testdir, "root-cert.pem"),
+			path.Join(testdir, "istio-token"), path.Join(testdir, "hosts"),
+		}, " ") + " admin@10.0.0.5:istio-vm/",
+	}
+	if !reflect.DeepEqual(commands, want) {
+		t.Errorf("got commands %q, want %q", commands, want)
+	}
+}
+
 func runTestCmd(t *testing.T, args []string) (string, error) {
 	t.Helper()
 	// TODO there is already probably something else that does this
@@ -0,0 +1,163 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The authn package provides support for inspecting the authentication policy applied to workloads.
+// Note: this is still under active development and is not ready for real use.
+package authn
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"istio.io/api/security/v1beta1"
+	clientsecurity "istio.io/client-go/pkg/apis/security/v1beta1"
+	authnv1beta1 "istio.io/istio/pilot/pkg/security/authn/v1beta1"
+)
+
+const (
+	// sourceDefault is reported when no PeerAuthentication sets the mode.
+	sourceDefault = "default"
+)
+
+// Setting is an mTLS mode, along with the PeerAuthentication that set it.
+type Setting struct {
+	Mode v1beta1.PeerAuthentication_MutualTLS_Mode
+	// Source describes the scope and name of the policy that set the mode, for example "namespace foo/default".
+	Source string
+}
+
+// PortSetting is the effective mTLS mode of a single workload port.
+type PortSetting struct {
+	Setting
+	Port uint32
+	Name string
+}
+
+// Effective is the merged PeerAuthentication of a workload.
+type Effective struct {
+	// Workload is the workload level setting, which applies to all ports without port level settings.
+	Workload Setting
+	// Ports contains the settings of the known ports of the workload, sorted by port number.
+	Ports []PortSetting
+}
+
+// ComputeEffective merges the given PeerAuthentication policies for the pod, following the same rules as
+// Istiod: the narrowest scope wins, the oldest policy wins within a scope, and UNSET inherits from the
+// parent scope. ports maps the workload's port numbers to their names, which may be empty.
+func ComputeEffective(rootNamespace string, pod *v1.Pod, ports map[uint32]string,
+	policies []*clientsecurity.PeerAuthentication) *Effective {
+	var meshPolicy, namespacePolicy, workloadPolicy *clientsecurity.PeerAuthentication
+	older := func(a, b *clientsecurity.PeerAuthentication) bool {
+		return b == nil || a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	for _, pa := range policies {
+		if pa.Spec.Selector == nil || len(pa.Spec.Selector.MatchLabels) == 0 {
+			if pa.Namespace == rootNamespace {
+				if older(pa, meshPolicy) {
+					meshPolicy = pa
+				}
+			} else if pa.Namespace == pod.Namespace && older(pa, namespacePolicy) {
+				namespacePolicy = pa
+			}
+			continue
+		}
+		// Workload level policies in the root namespace are ignored
+		if pa.Namespace != pod.Namespace || pa.Namespace == rootNamespace {
+			continue
+		}
+		if labels.SelectorFromSet(pa.Spec.Selector.MatchLabels).Matches(labels.Set(pod.Labels)) && older(pa, workloadPolicy) {
+			workloadPolicy = pa
+		}
+	}
+
+	res := &Effective{
+		Workload: Setting{Mode: v1beta1.PeerAuthentication_MutualTLS_PERMISSIVE, Source: sourceDefault},
+	}
+	for _, s := range []struct {
+		scope  string
+		policy *clientsecurity.PeerAuthentication
+	}{{"mesh", meshPolicy}, {"namespace", namespacePolicy}, {"workload", workloadPolicy}} {
+		if s.policy != nil && !isUnset(s.policy.Spec.Mtls) {
+			res.Workload = Setting{Mode: s.policy.Spec.Mtls.Mode, Source: source(s.scope, s.policy)}
+		}
+	}
+
+	var namedPorts map[string]v1beta1.PeerAuthentication_MutualTLS_Mode
+	if workloadPolicy != nil {
+		namedPorts, _ = authnv1beta1.ParsePortLevelMtlsByName(workloadPolicy.Annotations[authnv1beta1.PortLevelMtlsByNameAnnotation])
+	}
+	allPorts := map[uint32]string{}
+	for port, name := range ports {
+		allPorts[port] = name
+	}
+	if workloadPolicy != nil {
+		// Port level settings may refer to ports not declared by the workload, include them too.
+		for port := range workloadPolicy.Spec.PortLevelMtls {
+			if _, f := allPorts[port]; !f {
+				allPorts[port] = ""
+			}
+		}
+	}
+
+	for port, name := range allPorts {
+		ps := PortSetting{Setting: res.Workload, Port: port, Name: name}
+		if mode, f := namedPorts[name]; f && name != "" {
+			ps.Setting = Setting{Mode: mode, Source: source("named port level", workloadPolicy)}
+			if mode == v1beta1.PeerAuthentication_MutualTLS_UNSET {
+				ps.Mode = res.Workload.Mode
+			}
+		}
+		if workloadPolicy != nil {
+			if mtls, f := workloadPolicy.Spec.PortLevelMtls[port]; f {
+				ps.Setting = Setting{Mode: res.Workload.Mode, Source: source("port level", workloadPolicy)}
+				if !isUnset(mtls) {
+					ps.Mode = mtls.Mode
+				}
+			}
+		}
+		res.Ports = append(res.Ports, ps)
+	}
+	sort.Slice(res.Ports, func(i, j int) bool {
+		return res.Ports[i].Port < res.Ports[j].Port
+	})
+	return res
+}
+
+// Print prints the effective settings in a table.
+func (e *Effective) Print(writer io.Writer) {
+	w := new(tabwriter.Writer).Init(writer, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(w, "PORT\tNAME\tMODE\tSOURCE")
+	fmt.Fprintf(w, "*\t\t%s\t%s\n", e.Workload.Mode, e.Workload.Source)
+	for _, p := range e.Ports {
+		name := p.Name
+		if name == "" {
+			name = "-"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", p.Port, name, p.Mode, p.Source)
+	}
+	_ = w.Flush()
+}
+
+func source(scope string, pa *clientsecurity.PeerAuthentication) string {
+	return fmt.Sprintf("%s %s/%s", scope, pa.Namespace, pa.Name)
+}
+
+func isUnset(mtls *v1beta1.PeerAuthentication_MutualTLS) bool {
+	return mtls == nil || mtls.Mode == v1beta1.PeerAuthentication_MutualTLS_UNSET
+}
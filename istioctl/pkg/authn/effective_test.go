@@ -0,0 +1,142 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authn
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/api/security/v1beta1"
+	typev1beta1 "istio.io/api/type/v1beta1"
+	clientsecurity "istio.io/client-go/pkg/apis/security/v1beta1"
+	authnv1beta1 "istio.io/istio/pilot/pkg/security/authn/v1beta1"
+)
+
+func newPeerAuthentication(name, namespace string, created time.Time, selector map[string]string,
+	mode v1beta1.PeerAuthentication_MutualTLS_Mode, ports map[uint32]v1beta1.PeerAuthentication_MutualTLS_Mode) *clientsecurity.PeerAuthentication {
+	pa := &clientsecurity.PeerAuthentication{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         namespace,
+			CreationTimestamp: metav1.NewTime(created),
+		},
+	}
+	pa.Spec.Mtls = &v1beta1.PeerAuthentication_MutualTLS{Mode: mode}
+	if selector != nil {
+		pa.Spec.Selector = &typev1beta1.WorkloadSelector{MatchLabels: selector}
+	}
+	if ports != nil {
+		pa.Spec.PortLevelMtls = map[uint32]*v1beta1.PeerAuthentication_MutualTLS{}
+		for port, m := range ports {
+			pa.Spec.PortLevelMtls[port] = &v1beta1.PeerAuthentication_MutualTLS{Mode: m}
+		}
+	}
+	return pa
+}
+
+func TestComputeEffective(t *testing.T) {
+	now := time.Now()
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "httpbin",
+			Namespace: "foo",
+			Labels:    map[string]string{"app": "httpbin"},
+		},
+	}
+	ports := map[uint32]string{8080: "http", 9090: ""}
+	strict := v1beta1.PeerAuthentication_MutualTLS_STRICT
+	permissive := v1beta1.PeerAuthentication_MutualTLS_PERMISSIVE
+	disable := v1beta1.PeerAuthentication_MutualTLS_DISABLE
+	unset := v1beta1.PeerAuthentication_MutualTLS_UNSET
+
+	named := newPeerAuthentication("named", "foo", now, map[string]string{"app": "httpbin"}, unset, nil)
+	named.Annotations = map[string]string{authnv1beta1.PortLevelMtlsByNameAnnotation: "http=DISABLE"}
+
+	cases := []struct {
+		name     string
+		policies []*clientsecurity.PeerAuthentication
+		want     *Effective
+	}{
+		{
+			name: "no policy",
+			want: &Effective{
+				Workload: Setting{permissive, "default"},
+				Ports: []PortSetting{
+					{Setting{permissive, "default"}, 8080, "http"},
+					{Setting{permissive, "default"}, 9090, ""},
+				},
+			},
+		},
+		{
+			name: "namespace overrides mesh",
+			policies: []*clientsecurity.PeerAuthentication{
+				newPeerAuthentication("default", "istio-system", now, nil, strict, nil),
+				newPeerAuthentication("default", "foo", now, nil, disable, nil),
+				newPeerAuthentication("other", "bar", now, nil, permissive, nil),
+			},
+			want: &Effective{
+				Workload: Setting{disable, "namespace foo/default"},
+				Ports: []PortSetting{
+					{Setting{disable, "namespace foo/default"}, 8080, "http"},
+					{Setting{disable, "namespace foo/default"}, 9090, ""},
+				},
+			},
+		},
+		{
+			name: "unset inherits and oldest workload policy wins",
+			policies: []*clientsecurity.PeerAuthentication{
+				newPeerAuthentication("default", "istio-system", now, nil, strict, nil),
+				newPeerAuthentication("newer", "foo", now, map[string]string{"app": "httpbin"}, disable, nil),
+				newPeerAuthentication("older", "foo", now.Add(-time.Hour), map[string]string{"app": "httpbin"}, unset,
+					map[uint32]v1beta1.PeerAuthentication_MutualTLS_Mode{9090: disable, 7070: unset}),
+				newPeerAuthentication("unmatched", "foo", now.Add(-2*time.Hour), map[string]string{"app": "other"}, disable, nil),
+			},
+			want: &Effective{
+				Workload: Setting{strict, "mesh istio-system/default"},
+				Ports: []PortSetting{
+					{Setting{strict, "port level foo/older"}, 7070, ""},
+					{Setting{strict, "mesh istio-system/default"}, 8080, "http"},
+					{Setting{disable, "port level foo/older"}, 9090, ""},
+				},
+			},
+		},
+		{
+			name: "named port",
+			policies: []*clientsecurity.PeerAuthentication{
+				newPeerAuthentication("default", "istio-system", now, nil, strict, nil),
+				named,
+			},
+			want: &Effective{
+				Workload: Setting{strict, "mesh istio-system/default"},
+				Ports: []PortSetting{
+					{Setting{disable, "named port level foo/named"}, 8080, "http"},
+					{Setting{strict, "mesh istio-system/default"}, 9090, ""},
+				},
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ComputeEffective("istio-system", pod, ports, tt.policies)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
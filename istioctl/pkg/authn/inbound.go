@@ -0,0 +1,197 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authn
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"istio.io/api/security/v1beta1"
+	clientsecurity "istio.io/client-go/pkg/apis/security/v1beta1"
+)
+
+// The kinds of inbound filter chains handling the traffic of a port.
+const (
+	// ChainService is reported for the ports with their own filter chain, the ports of the Services of the workload.
+	ChainService = "service"
+	// ChainPassthrough is reported for the ports handled by the passthrough filter chains.
+	ChainPassthrough = "passthrough"
+	// ChainUnknown is reported when the configuration of the proxy is not available.
+	ChainUnknown = "unknown"
+)
+
+// InboundPort is the security applied to the traffic of an inbound port of a workload.
+type InboundPort struct {
+	PortSetting
+	// Chain is the kind of inbound filter chain handling the port.
+	Chain string
+	// PortLevelIgnored is set when a port level mTLS setting targets the port, which is ignored because the port
+	// is passed through with the mode of the workload.
+	PortLevelIgnored bool
+	// AuthorizationPolicies are the policies applying to the port, formatted as "<action> <namespace>/<name>".
+	AuthorizationPolicies []string
+}
+
+// Inbound is the security applied to the inbound traffic of a workload.
+type Inbound struct {
+	// Workload is the workload level mTLS setting, which applies to the ports without port level settings.
+	Workload Setting
+	// AuthorizationPolicies are the policies applying to the workload, regardless of the port.
+	AuthorizationPolicies []string
+	// Ports contains the security of the known ports of the workload, sorted by port number.
+	Ports []InboundPort
+}
+
+// ComputeInbound returns the security applied to the inbound ports of the pod: the effective mTLS mode of each
+// port and the policy setting it, the AuthorizationPolicies matching the port, and whether the port has its own
+// filter chain. servicePorts are the ports with their own inbound filter chain in the configuration of the proxy,
+// nil if unknown.
+func ComputeInbound(rootNamespace string, pod *v1.Pod, ports map[uint32]string,
+	peerAuthentications []*clientsecurity.PeerAuthentication, authorizationPolicies []*clientsecurity.AuthorizationPolicy,
+	servicePorts map[uint32]bool) *Inbound {
+	effective := ComputeEffective(rootNamespace, pod, ports, peerAuthentications)
+
+	var policies []*clientsecurity.AuthorizationPolicy
+	for _, ap := range authorizationPolicies {
+		if ap.Namespace != rootNamespace && ap.Namespace != pod.Namespace {
+			continue
+		}
+		if ap.Spec.Selector != nil &&
+			!labels.SelectorFromSet(ap.Spec.Selector.MatchLabels).Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		policies = append(policies, ap)
+	}
+	sort.Slice(policies, func(i, j int) bool {
+		return authorizationPolicyName(policies[i]) < authorizationPolicyName(policies[j])
+	})
+
+	res := &Inbound{Workload: effective.Workload}
+	for _, ap := range policies {
+		if !restrictsPorts(ap.Spec.Rules) {
+			res.AuthorizationPolicies = append(res.AuthorizationPolicies, authorizationPolicyName(ap))
+		}
+	}
+	for _, p := range effective.Ports {
+		port := InboundPort{PortSetting: p, Chain: ChainUnknown}
+		if servicePorts != nil {
+			port.Chain = ChainPassthrough
+			if servicePorts[p.Port] {
+				port.Chain = ChainService
+			} else if strings.Contains(p.Source, "port level") {
+				port.Setting = effective.Workload
+				port.PortLevelIgnored = true
+			}
+		}
+		for _, ap := range policies {
+			if matchesPort(ap.Spec.Rules, p.Port) {
+				port.AuthorizationPolicies = append(port.AuthorizationPolicies, authorizationPolicyName(ap))
+			}
+		}
+		res.Ports = append(res.Ports, port)
+	}
+	return res
+}
+
+// Print prints the inbound security in a table.
+func (i *Inbound) Print(writer io.Writer) {
+	w := new(tabwriter.Writer).Init(writer, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(w, "PORT\tNAME\tMTLS\tPEER AUTHENTICATION\tINBOUND CHAIN\tAUTHORIZATION POLICIES")
+	fmt.Fprintf(w, "*\t\t%s\t%s\t\t%s\n", i.Workload.Mode, i.Workload.Source, joinOrNone(i.AuthorizationPolicies))
+	for _, p := range i.Ports {
+		name := p.Name
+		if name == "" {
+			name = "-"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", p.Port, name, p.Mode, p.Source, p.Chain,
+			joinOrNone(p.AuthorizationPolicies))
+	}
+	_ = w.Flush()
+	for _, p := range i.Ports {
+		if p.PortLevelIgnored {
+			fmt.Fprintf(writer, "Warning: the port level mTLS setting of port %d is ignored, the port is not exposed "+
+				"by a Service and is passed through with the mTLS mode of the workload.\n", p.Port)
+		}
+	}
+}
+
+// restrictsPorts returns true if the rules only match some ports. Policies without rules match all the ports.
+func restrictsPorts(rules []*v1beta1.Rule) bool {
+	if len(rules) == 0 {
+		return false
+	}
+	for _, rule := range rules {
+		if !ruleRestrictsPorts(rule) {
+			return false
+		}
+	}
+	return true
+}
+
+func ruleRestrictsPorts(rule *v1beta1.Rule) bool {
+	if len(rule.To) == 0 {
+		return false
+	}
+	for _, to := range rule.To {
+		if op := to.GetOperation(); op == nil || (len(op.Ports) == 0 && len(op.NotPorts) == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesPort returns true if any of the rules may match the traffic of the port.
+func matchesPort(rules []*v1beta1.Rule, port uint32) bool {
+	if !restrictsPorts(rules) {
+		return true
+	}
+	p := strconv.Itoa(int(port))
+	for _, rule := range rules {
+		for _, to := range rule.To {
+			op := to.GetOperation()
+			if (len(op.Ports) == 0 || containsString(op.Ports, p)) && !containsString(op.NotPorts, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func authorizationPolicyName(ap *clientsecurity.AuthorizationPolicy) string {
+	return fmt.Sprintf("%s %s/%s", ap.Spec.Action, ap.Namespace, ap.Name)
+}
+
+func joinOrNone(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return strings.Join(values, ", ")
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authn
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/api/security/v1beta1"
+	typev1beta1 "istio.io/api/type/v1beta1"
+	clientsecurity "istio.io/client-go/pkg/apis/security/v1beta1"
+)
+
+func newAuthorizationPolicy(name, namespace string, selector map[string]string, action v1beta1.AuthorizationPolicy_Action,
+	rules ...*v1beta1.Rule) *clientsecurity.AuthorizationPolicy {
+	ap := &clientsecurity.AuthorizationPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+	}
+	ap.Spec.Action = action
+	ap.Spec.Rules = rules
+	if selector != nil {
+		ap.Spec.Selector = &typev1beta1.WorkloadSelector{MatchLabels: selector}
+	}
+	return ap
+}
+
+func toPorts(ports, notPorts []string) *v1beta1.Rule {
+	return &v1beta1.Rule{To: []*v1beta1.Rule_To{{Operation: &v1beta1.Operation{Ports: ports, NotPorts: notPorts}}}}
+}
+
+func TestComputeInbound(t *testing.T) {
+	now := time.Now()
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "httpbin",
+			Namespace: "foo",
+			Labels:    map[string]string{"app": "httpbin"},
+		},
+	}
+	ports := map[uint32]string{8080: "http", 9090: ""}
+	strict := v1beta1.PeerAuthentication_MutualTLS_STRICT
+	disable := v1beta1.PeerAuthentication_MutualTLS_DISABLE
+	allow := v1beta1.AuthorizationPolicy_ALLOW
+	deny := v1beta1.AuthorizationPolicy_DENY
+
+	pas := []*clientsecurity.PeerAuthentication{
+		newPeerAuthentication("default", "istio-system", now, nil, strict, nil),
+		newPeerAuthentication("httpbin", "foo", now, map[string]string{"app": "httpbin"}, strict,
+			map[uint32]v1beta1.PeerAuthentication_MutualTLS_Mode{8080: disable, 9090: disable}),
+	}
+	aps := []*clientsecurity.AuthorizationPolicy{
+		newAuthorizationPolicy("deny-all", "istio-system", nil, allow),
+		newAuthorizationPolicy("http", "foo", map[string]string{"app": "httpbin"}, allow, toPorts([]string{"8080"}, nil)),
+		newAuthorizationPolicy("not-http", "foo", nil, deny, toPorts(nil, []string{"8080"})),
+		newAuthorizationPolicy("other", "foo", map[string]string{"app": "other"}, deny),
+		newAuthorizationPolicy("other-namespace", "bar", nil, deny),
+	}
+
+	cases := []struct {
+		name         string
+		servicePorts map[uint32]bool
+		want         *Inbound
+	}{
+		{
+			name:         "service and passthrough ports",
+			servicePorts: map[uint32]bool{8080: true},
+			want: &Inbound{
+				Workload:              Setting{strict, "workload foo/httpbin"},
+				AuthorizationPolicies: []string{"ALLOW istio-system/deny-all"},
+				Ports: []InboundPort{
+					{
+						PortSetting:           PortSetting{Setting{disable, "port level foo/httpbin"}, 8080, "http"},
+						Chain:                 ChainService,
+						AuthorizationPolicies: []string{"ALLOW foo/http", "ALLOW istio-system/deny-all"},
+					},
+					{
+						PortSetting:           PortSetting{Setting{strict, "workload foo/httpbin"}, 9090, ""},
+						Chain:                 ChainPassthrough,
+						PortLevelIgnored:      true,
+						AuthorizationPolicies: []string{"ALLOW istio-system/deny-all", "DENY foo/not-http"},
+					},
+				},
+			},
+		},
+		{
+			name: "unknown proxy configuration",
+			want: &Inbound{
+				Workload:              Setting{strict, "workload foo/httpbin"},
+				AuthorizationPolicies: []string{"ALLOW istio-system/deny-all"},
+				Ports: []InboundPort{
+					{
+						PortSetting:           PortSetting{Setting{disable, "port level foo/httpbin"}, 8080, "http"},
+						Chain:                 ChainUnknown,
+						AuthorizationPolicies: []string{"ALLOW foo/http", "ALLOW istio-system/deny-all"},
+					},
+					{
+						PortSetting:           PortSetting{Setting{disable, "port level foo/httpbin"}, 9090, ""},
+						Chain:                 ChainUnknown,
+						AuthorizationPolicies: []string{"ALLOW istio-system/deny-all", "DENY foo/not-http"},
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ComputeInbound("istio-system", pod, ports, pas, aps, tt.servicePorts)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	out := &bytes.Buffer{}
+	ComputeInbound("istio-system", pod, ports, pas, aps, map[uint32]bool{8080: true}).Print(out)
+	if !strings.Contains(out.String(), "port level mTLS setting of port 9090 is ignored") {
+		t.Errorf("expected a warning for the ignored port level setting, got %s", out.String())
+	}
+}
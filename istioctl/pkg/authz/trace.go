@@ -0,0 +1,509 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authz
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	rbacpb "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	rbac_http_filter "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	rbac_tcp_filter "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/rbac/v3"
+	matcherpb "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/golang/protobuf/ptypes"
+
+	"istio.io/istio/istioctl/pkg/util/configdump"
+	"istio.io/istio/pilot/pkg/networking/core/v1alpha3"
+	authn_model "istio.io/istio/pilot/pkg/security/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/spiffe"
+)
+
+// The keys of the metadata set by the Istio authentication filter, matched by the generated RBAC filters.
+const (
+	metadataSourcePrincipal  = "source.principal"
+	metadataRequestPrincipal = "request.auth.principal"
+	metadataAudiences        = "request.auth.audiences"
+	metadataPresenter        = "request.auth.presenter"
+	metadataClaims           = "request.auth.claims"
+)
+
+// Request is a hypothetical request to a workload, evaluated by the Tracer.
+type Request struct {
+	// Principal is the identity of the source, e.g. "cluster.local/ns/default/sa/sleep". The request is sent over
+	// mTLS only when the principal is set.
+	Principal     string
+	SourceIP      string
+	DestinationIP string
+	Port          uint32
+	// The attributes of HTTP requests.
+	Method  string
+	Path    string
+	Host    string
+	Headers map[string]string
+	// The attributes of the JWT authenticating the request.
+	RequestPrincipal string
+	Audiences        string
+	Presenter        string
+	Claims           map[string][]string
+}
+
+// rbacFilter is the configuration of an RBAC filter, HTTP or network.
+type rbacFilter struct {
+	rules       *rbacpb.RBAC
+	shadowRules *rbacpb.RBAC
+}
+
+// Tracer evaluates requests against the RBAC filters of an inbound filter chain of a workload, as generated from
+// the AuthorizationPolicies applied to it, and explains which rules allowed or denied them.
+type Tracer struct {
+	// chain is the name of the filter chain, or a description of its match when it has none.
+	chain   string
+	http    bool
+	filters []rbacFilter
+}
+
+// TraceStep is the evaluation of a request by one RBAC filter.
+type TraceStep struct {
+	Action rbacpb.RBAC_Action
+	// Shadow is set for the shadow rules of the filter, which are evaluated but not enforced.
+	Shadow bool
+	// Matched are the rules matching the request, as "<policy>.<namespace> rule[<index>]".
+	Matched []string
+	// Denied is set when the filter denied the request.
+	Denied bool
+}
+
+// TraceResult is the evaluation of a request by all the RBAC filters of the filter chain.
+type TraceResult struct {
+	Chain   string
+	Allowed bool
+	Steps   []TraceStep
+}
+
+// NewTracer creates a Tracer for the inbound filter chain handling the request in the config dump of a workload.
+func NewTracer(envoyConfig *configdump.Wrapper, req *Request) (*Tracer, error) {
+	listeners, err := envoyConfig.GetDynamicListenerDump(true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dynamic listener dump: %s", err)
+	}
+	for _, l := range listeners.DynamicListeners {
+		l.ActiveState.Listener.TypeUrl = v3.ListenerType
+		listenerTyped := &listener.Listener{}
+		if err := ptypes.UnmarshalAny(l.ActiveState.Listener, listenerTyped); err != nil {
+			return nil, err
+		}
+		if listenerTyped.Name != v1alpha3.VirtualInboundListenerName {
+			continue
+		}
+		fc := selectFilterChain(listenerTyped.FilterChains, req)
+		if fc == nil {
+			return nil, fmt.Errorf("no inbound filter chain matches port %d", req.Port)
+		}
+		return newTracerForFilterChain(fc), nil
+	}
+	return nil, fmt.Errorf("no %s listener found", v1alpha3.VirtualInboundListenerName)
+}
+
+// selectFilterChain returns the inbound filter chain handling the request, preferring the filter chains of the port
+// over the passthrough filter chains, and the HTTP filter chains over the TCP ones when the request has HTTP
+// attributes.
+func selectFilterChain(chains []*listener.FilterChain, req *Request) *listener.FilterChain {
+	transport := "raw_buffer"
+	if req.Principal != "" {
+		transport = "tls"
+	}
+	isHTTP := req.Method != "" || req.Path != "" || req.Host != "" || len(req.Headers) > 0
+	var best *listener.FilterChain
+	bestScore := -1
+	for _, fc := range chains {
+		match := fc.GetFilterChainMatch()
+		if match.GetDestinationPort() != nil && match.GetDestinationPort().GetValue() != req.Port {
+			continue
+		}
+		if match.GetTransportProtocol() != "" && match.GetTransportProtocol() != transport {
+			continue
+		}
+		score := 0
+		if match.GetDestinationPort() != nil {
+			score += 2
+		}
+		if hasHTTPConnectionManager(fc) == isHTTP {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = fc, score
+		}
+	}
+	return best
+}
+
+func hasHTTPConnectionManager(fc *listener.FilterChain) bool {
+	for _, filter := range fc.Filters {
+		if filter.Name == wellknown.HTTPConnectionManager || filter.Name == "envoy.http_connection_manager" {
+			return true
+		}
+	}
+	return false
+}
+
+func newTracerForFilterChain(fc *listener.FilterChain) *Tracer {
+	t := &Tracer{chain: fc.Name}
+	if t.chain == "" {
+		t.chain = fmt.Sprintf("port %d %s", fc.GetFilterChainMatch().GetDestinationPort().GetValue(),
+			fc.GetFilterChainMatch().GetTransportProtocol())
+	}
+	for _, filter := range fc.Filters {
+		switch filter.Name {
+		case wellknown.HTTPConnectionManager, "envoy.http_connection_manager":
+			t.http = true
+			cm := getHTTPConnectionManager(filter)
+			for _, httpFilter := range cm.GetHttpFilters() {
+				if httpFilter.GetName() != wellknown.HTTPRoleBasedAccessControl {
+					continue
+				}
+				rbac := &rbac_http_filter.RBAC{}
+				if err := getHTTPFilterConfig(httpFilter, rbac); err == nil {
+					t.filters = append(t.filters, rbacFilter{rules: rbac.GetRules(), shadowRules: rbac.GetShadowRules()})
+				}
+			}
+		case wellknown.RoleBasedAccessControl:
+			rbac := &rbac_tcp_filter.RBAC{}
+			if err := getFilterConfig(filter, rbac); err == nil {
+				t.filters = append(t.filters, rbacFilter{rules: rbac.GetRules(), shadowRules: rbac.GetShadowRules()})
+			}
+		}
+	}
+	return t
+}
+
+// Trace evaluates the request against the RBAC filters in order, as Envoy does: the request is denied by the first
+// filter denying it, a DENY filter denying the requests matching any of its rules, and an ALLOW filter the requests
+// matching none of its rules.
+func (t *Tracer) Trace(req *Request) *TraceResult {
+	res := &TraceResult{Chain: t.chain, Allowed: true}
+	for _, f := range t.filters {
+		if f.shadowRules != nil {
+			res.Steps = append(res.Steps, t.evaluate(f.shadowRules, req, true))
+		}
+		if f.rules == nil {
+			continue
+		}
+		step := t.evaluate(f.rules, req, false)
+		res.Steps = append(res.Steps, step)
+		if step.Denied {
+			res.Allowed = false
+			break
+		}
+	}
+	return res
+}
+
+func (t *Tracer) evaluate(rbac *rbacpb.RBAC, req *Request, shadow bool) TraceStep {
+	step := TraceStep{Action: rbac.Action, Shadow: shadow}
+	names := make([]string, 0, len(rbac.Policies))
+	for name := range rbac.Policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		policy := rbac.Policies[name]
+		if t.anyPermission(policy.Permissions, req) && t.anyPrincipal(policy.Principals, req) {
+			step.Matched = append(step.Matched, ruleName(name))
+		}
+	}
+	switch rbac.Action {
+	case rbacpb.RBAC_ALLOW:
+		step.Denied = len(step.Matched) == 0
+	case rbacpb.RBAC_DENY:
+		step.Denied = len(step.Matched) > 0
+	}
+	return step
+}
+
+// Print prints the evaluation of the request by each RBAC filter, and the final decision.
+func (r *TraceResult) Print(writer io.Writer) {
+	fmt.Fprintf(writer, "Filter chain: %s\n", r.Chain)
+	if len(r.Steps) == 0 {
+		fmt.Fprintln(writer, "No AuthorizationPolicy applies to the request.")
+	}
+	for i, step := range r.Steps {
+		kind := "RBAC filter"
+		if step.Shadow {
+			kind = "RBAC shadow rules (not enforced)"
+		}
+		fmt.Fprintf(writer, "%d. %s, action %s:\n", i+1, kind, step.Action)
+		for _, m := range step.Matched {
+			fmt.Fprintf(writer, "   matched %s\n", m)
+		}
+		switch {
+		case len(step.Matched) == 0 && step.Action == rbacpb.RBAC_ALLOW:
+			fmt.Fprintln(writer, "   no rule matched, the request is denied")
+		case len(step.Matched) == 0:
+			fmt.Fprintln(writer, "   no rule matched")
+		case step.Denied:
+			fmt.Fprintln(writer, "   the request is denied")
+		}
+	}
+	if r.Allowed {
+		fmt.Fprintln(writer, "Result: ALLOWED")
+	} else {
+		fmt.Fprintln(writer, "Result: DENIED")
+	}
+}
+
+func ruleName(name string) string {
+	policy, rule := extractName(name)
+	if policy == "" {
+		return name
+	}
+	return fmt.Sprintf("%s rule[%s]", policy, rule)
+}
+
+func (t *Tracer) anyPermission(permissions []*rbacpb.Permission, req *Request) bool {
+	for _, p := range permissions {
+		if t.permission(p, req) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Tracer) permission(p *rbacpb.Permission, req *Request) bool {
+	switch rule := p.Rule.(type) {
+	case *rbacpb.Permission_Any:
+		return rule.Any
+	case *rbacpb.Permission_AndRules:
+		for _, r := range rule.AndRules.Rules {
+			if !t.permission(r, req) {
+				return false
+			}
+		}
+		return true
+	case *rbacpb.Permission_OrRules:
+		return t.anyPermission(rule.OrRules.Rules, req)
+	case *rbacpb.Permission_NotRule:
+		return !t.permission(rule.NotRule, req)
+	case *rbacpb.Permission_Header:
+		return t.http && matchHeader(rule.Header, req)
+	case *rbacpb.Permission_UrlPath:
+		return t.http && matchPath(rule.UrlPath, req.Path)
+	case *rbacpb.Permission_DestinationIp:
+		return matchCidr(rule.DestinationIp, req.DestinationIP)
+	case *rbacpb.Permission_DestinationPort:
+		return rule.DestinationPort == req.Port
+	case *rbacpb.Permission_Metadata:
+		return matchMetadata(rule.Metadata, req)
+	default:
+		return false
+	}
+}
+
+func (t *Tracer) anyPrincipal(principals []*rbacpb.Principal, req *Request) bool {
+	for _, p := range principals {
+		if t.principal(p, req) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Tracer) principal(p *rbacpb.Principal, req *Request) bool {
+	switch id := p.Identifier.(type) {
+	case *rbacpb.Principal_Any:
+		return id.Any
+	case *rbacpb.Principal_AndIds:
+		for _, i := range id.AndIds.Ids {
+			if !t.principal(i, req) {
+				return false
+			}
+		}
+		return true
+	case *rbacpb.Principal_OrIds:
+		return t.anyPrincipal(id.OrIds.Ids, req)
+	case *rbacpb.Principal_NotId:
+		return !t.principal(id.NotId, req)
+	case *rbacpb.Principal_Authenticated_:
+		if req.Principal == "" {
+			return false
+		}
+		return id.Authenticated.PrincipalName == nil ||
+			matchString(id.Authenticated.PrincipalName, spiffe.URIPrefix+req.Principal)
+	case *rbacpb.Principal_SourceIp:
+		return matchCidr(id.SourceIp, req.SourceIP)
+	case *rbacpb.Principal_DirectRemoteIp:
+		return matchCidr(id.DirectRemoteIp, req.SourceIP)
+	case *rbacpb.Principal_RemoteIp:
+		return matchCidr(id.RemoteIp, req.SourceIP)
+	case *rbacpb.Principal_Header:
+		return t.http && matchHeader(id.Header, req)
+	case *rbacpb.Principal_UrlPath:
+		return t.http && matchPath(id.UrlPath, req.Path)
+	case *rbacpb.Principal_Metadata:
+		return matchMetadata(id.Metadata, req)
+	default:
+		return false
+	}
+}
+
+func headerValue(req *Request, name string) (string, bool) {
+	switch name {
+	case ":method":
+		return req.Method, req.Method != ""
+	case ":path":
+		return req.Path, req.Path != ""
+	case ":authority", "host":
+		return req.Host, req.Host != ""
+	}
+	for k, v := range req.Headers {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func matchHeader(h *route.HeaderMatcher, req *Request) bool {
+	value, present := headerValue(req, h.Name)
+	var matched bool
+	switch m := h.HeaderMatchSpecifier.(type) {
+	case *route.HeaderMatcher_PresentMatch:
+		matched = present == m.PresentMatch
+	case *route.HeaderMatcher_ExactMatch:
+		matched = present && value == m.ExactMatch
+	case *route.HeaderMatcher_PrefixMatch:
+		matched = present && strings.HasPrefix(value, m.PrefixMatch)
+	case *route.HeaderMatcher_SuffixMatch:
+		matched = present && strings.HasSuffix(value, m.SuffixMatch)
+	case *route.HeaderMatcher_SafeRegexMatch:
+		matched = present && matchRegex(m.SafeRegexMatch.GetRegex(), value)
+	case nil:
+		matched = present
+	}
+	// An inverted matcher still requires the header to be present.
+	if h.InvertMatch {
+		return present && !matched
+	}
+	return matched
+}
+
+func matchPath(p *matcherpb.PathMatcher, path string) bool {
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	return path != "" && matchString(p.GetPath(), path)
+}
+
+func matchString(m *matcherpb.StringMatcher, value string) bool {
+	if m.GetIgnoreCase() {
+		value = strings.ToLower(value)
+	}
+	lower := func(s string) string {
+		if m.GetIgnoreCase() {
+			return strings.ToLower(s)
+		}
+		return s
+	}
+	switch p := m.GetMatchPattern().(type) {
+	case *matcherpb.StringMatcher_Exact:
+		return value == lower(p.Exact)
+	case *matcherpb.StringMatcher_Prefix:
+		return strings.HasPrefix(value, lower(p.Prefix))
+	case *matcherpb.StringMatcher_Suffix:
+		return strings.HasSuffix(value, lower(p.Suffix))
+	case *matcherpb.StringMatcher_SafeRegex:
+		return matchRegex(p.SafeRegex.GetRegex(), value)
+	default:
+		return false
+	}
+}
+
+// matchRegex returns true if the regex matches the whole value, as RE2 regexes do in Envoy.
+func matchRegex(regex, value string) bool {
+	re, err := regexp.Compile("^(?:" + regex + ")$")
+	return err == nil && re.MatchString(value)
+}
+
+func matchCidr(cidr *core.CidrRange, ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	_, ipNet, err := net.ParseCIDR(fmt.Sprintf("%s/%d", cidr.GetAddressPrefix(), cidr.GetPrefixLen().GetValue()))
+	return err == nil && ipNet.Contains(parsed)
+}
+
+// metadataValues returns the values of the dynamic metadata of the Istio authentication filter at the path.
+func metadataValues(path []*matcherpb.MetadataMatcher_PathSegment, req *Request) []string {
+	keys := make([]string, 0, len(path))
+	for _, segment := range path {
+		keys = append(keys, segment.GetKey())
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	single := func(v string) []string {
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	}
+	switch keys[0] {
+	case metadataSourcePrincipal:
+		return single(req.Principal)
+	case metadataRequestPrincipal:
+		return single(req.RequestPrincipal)
+	case metadataAudiences:
+		return single(req.Audiences)
+	case metadataPresenter:
+		return single(req.Presenter)
+	case metadataClaims:
+		if len(keys) > 1 {
+			return req.Claims[strings.Join(keys[1:], ".")]
+		}
+	}
+	return nil
+}
+
+func matchMetadata(m *matcherpb.MetadataMatcher, req *Request) bool {
+	if m.Filter != authn_model.AuthnFilterName {
+		return false
+	}
+	values := metadataValues(m.Path, req)
+	switch p := m.GetValue().GetMatchPattern().(type) {
+	case *matcherpb.ValueMatcher_StringMatch:
+		return len(values) == 1 && matchString(p.StringMatch, values[0])
+	case *matcherpb.ValueMatcher_ListMatch:
+		oneOf := p.ListMatch.GetOneOf().GetStringMatch()
+		for _, v := range values {
+			if oneOf != nil && matchString(oneOf, v) {
+				return true
+			}
+		}
+		return false
+	case *matcherpb.ValueMatcher_PresentMatch:
+		return (len(values) > 0) == p.PresentMatch
+	default:
+		return false
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authz
+
+import (
+	"reflect"
+	"testing"
+
+	rbacpb "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
+
+	"istio.io/istio/pilot/pkg/security/authz/matcher"
+	authn_model "istio.io/istio/pilot/pkg/security/model"
+)
+
+func TestTrace(t *testing.T) {
+	sleep := &rbacpb.Principal{Identifier: &rbacpb.Principal_Metadata{
+		Metadata: matcher.MetadataStringMatcher(authn_model.AuthnFilterName, metadataSourcePrincipal,
+			matcher.StringMatcher("cluster.local/ns/default/sa/sleep")),
+	}}
+	admins := &rbacpb.Principal{Identifier: &rbacpb.Principal_Metadata{
+		Metadata: matcher.MetadataListMatcher(authn_model.AuthnFilterName, []string{metadataClaims, "groups"}, "admin"),
+	}}
+	anyPrincipal := &rbacpb.Principal{Identifier: &rbacpb.Principal_Any{Any: true}}
+	get := &rbacpb.Permission{Rule: &rbacpb.Permission_Header{Header: matcher.HeaderMatcher(":method", "GET")}}
+	admin := &rbacpb.Permission{Rule: &rbacpb.Permission_UrlPath{UrlPath: matcher.PathMatcher("/admin*")}}
+
+	tracer := &Tracer{
+		chain: "inbound",
+		http:  true,
+		filters: []rbacFilter{
+			{
+				rules: &rbacpb.RBAC{Action: rbacpb.RBAC_DENY, Policies: map[string]*rbacpb.Policy{
+					"ns[foo]-policy[deny-admin]-rule[0]": {
+						Permissions: []*rbacpb.Permission{admin},
+						Principals: []*rbacpb.Principal{
+							{Identifier: &rbacpb.Principal_NotId{NotId: admins}},
+						},
+					},
+				}},
+			},
+			{
+				rules: &rbacpb.RBAC{Action: rbacpb.RBAC_ALLOW, Policies: map[string]*rbacpb.Policy{
+					"ns[foo]-policy[httpbin]-rule[0]": {
+						Permissions: []*rbacpb.Permission{get},
+						Principals:  []*rbacpb.Principal{sleep},
+					},
+					"ns[foo]-policy[httpbin]-rule[1]": {
+						Permissions: []*rbacpb.Permission{admin},
+						Principals:  []*rbacpb.Principal{anyPrincipal},
+					},
+				}},
+			},
+		},
+	}
+
+	cases := []struct {
+		name    string
+		req     *Request
+		allowed bool
+		matched [][]string
+	}{
+		{
+			name:    "allowed source",
+			req:     &Request{Principal: "cluster.local/ns/default/sa/sleep", Method: "GET", Path: "/headers"},
+			allowed: true,
+			matched: [][]string{nil, {"httpbin.foo rule[0]"}},
+		},
+		{
+			name:    "other source",
+			req:     &Request{Principal: "cluster.local/ns/default/sa/other", Method: "GET", Path: "/headers"},
+			allowed: false,
+			matched: [][]string{nil, nil},
+		},
+		{
+			name:    "denied admin path",
+			req:     &Request{Principal: "cluster.local/ns/default/sa/sleep", Method: "GET", Path: "/admin/users?x=y"},
+			allowed: false,
+			matched: [][]string{{"deny-admin.foo rule[0]"}},
+		},
+		{
+			name: "admin path with admin claim",
+			req: &Request{Method: "POST", Path: "/admin/users",
+				Claims: map[string][]string{"groups": {"dev", "admin"}}},
+			allowed: true,
+			matched: [][]string{nil, {"httpbin.foo rule[1]"}},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			res := tracer.Trace(tt.req)
+			if res.Allowed != tt.allowed {
+				t.Errorf("got allowed %v, want %v", res.Allowed, tt.allowed)
+			}
+			var matched [][]string
+			for _, step := range res.Steps {
+				matched = append(matched, step.Matched)
+			}
+			if !reflect.DeepEqual(matched, tt.matched) {
+				t.Errorf("got matched rules %v, want %v", matched, tt.matched)
+			}
+		})
+	}
+}
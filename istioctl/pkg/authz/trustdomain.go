@@ -0,0 +1,229 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authz
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gogo/protobuf/proto"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/api/security/v1beta1"
+	clientsecurity "istio.io/client-go/pkg/apis/security/v1beta1"
+)
+
+// sourcePrincipalKey is the condition key matching the principal of the peer.
+const sourcePrincipalKey = "source.principal"
+
+// TrustDomainMigration rewrites the principals of AuthorizationPolicies from one trust domain to another.
+type TrustDomainMigration struct {
+	From string
+	To   string
+	// RemoveFrom removes the principals of the old trust domain, instead of adding the principals of the new
+	// trust domain next to them. This should only be done once all workloads use the new trust domain.
+	RemoveFrom bool
+}
+
+// ApplyMeshConfig drives the migration from the trust domain settings of the mesh config and of istiod: To
+// defaults to the trust domain of the mesh, and From to the trust domain istiod migrates from, or else to the
+// only trust domain alias of the mesh. Both trust domains must be accepted by the mesh while the principals of
+// the old trust domain are kept, i.e. From must be a trust domain alias of To or the trust domain istiod
+// migrates from, as set by PILOT_TRUST_DOMAIN_MIGRATION_FROM.
+func (m *TrustDomainMigration) ApplyMeshConfig(mesh *meshconfig.MeshConfig, istiodFrom string) error {
+	if m.To == "" {
+		m.To = mesh.GetTrustDomain()
+	}
+	aliases := mesh.GetTrustDomainAliases()
+	if istiodFrom != "" && istiodFrom != mesh.GetTrustDomain() && !contains(aliases, istiodFrom) {
+		aliases = append(append([]string{}, aliases...), istiodFrom)
+	}
+	if m.From == "" && istiodFrom != "" {
+		m.From = istiodFrom
+	}
+	if m.From == "" {
+		if len(aliases) != 1 {
+			return fmt.Errorf("the mesh config has %d trust domain aliases, --from must be set", len(aliases))
+		}
+		m.From = aliases[0]
+	}
+	if m.From == m.To {
+		return fmt.Errorf("cannot migrate trust domain %s to itself", m.From)
+	}
+	if m.RemoveFrom {
+		return nil
+	}
+	if m.To != mesh.GetTrustDomain() || !contains(aliases, m.From) {
+		return fmt.Errorf("the mesh config must have trust domain %s with the alias %s, or istiod must run with "+
+			"PILOT_TRUST_DOMAIN_MIGRATION_FROM=%s, during the migration, got trust domain %s with aliases %v",
+			m.To, m.From, m.From, mesh.GetTrustDomain(), mesh.GetTrustDomainAliases())
+	}
+	return nil
+}
+
+// NamespaceProgress is the trust domain migration progress of the AuthorizationPolicies of a namespace.
+type NamespaceProgress struct {
+	Namespace string
+	// Total is the number of policies referencing a principal of either trust domain.
+	Total int
+	// Pending is the number of policies that still need to be rewritten.
+	Pending int
+}
+
+// RewritePrincipals returns the principals rewritten for the migration, and whether they were changed.
+// Principals of other trust domains, and principals matching any trust domain, are kept as-is.
+func (m TrustDomainMigration) RewritePrincipals(principals []string) ([]string, bool) {
+	var res []string
+	add := func(p string) {
+		if !contains(res, p) {
+			res = append(res, p)
+		}
+	}
+	for _, p := range principals {
+		if trustDomainOf(p) != m.From {
+			add(p)
+			continue
+		}
+		if !m.RemoveFrom {
+			add(p)
+		}
+		add(m.To + strings.TrimPrefix(p, m.From))
+	}
+	if len(res) != len(principals) {
+		return res, true
+	}
+	for i := range res {
+		if res[i] != principals[i] {
+			return res, true
+		}
+	}
+	return principals, false
+}
+
+// RewritePolicy rewrites the source principals of the rules of the policy in place, and returns whether
+// the policy was changed.
+func (m TrustDomainMigration) RewritePolicy(policy *v1beta1.AuthorizationPolicy) bool {
+	changed := false
+	rewrite := func(principals *[]string) {
+		if res, c := m.RewritePrincipals(*principals); c {
+			*principals = res
+			changed = true
+		}
+	}
+	for _, rule := range policy.GetRules() {
+		for _, from := range rule.GetFrom() {
+			if from.GetSource() == nil {
+				continue
+			}
+			rewrite(&from.Source.Principals)
+			rewrite(&from.Source.NotPrincipals)
+		}
+		for _, cond := range rule.GetWhen() {
+			if cond.GetKey() != sourcePrincipalKey {
+				continue
+			}
+			rewrite(&cond.Values)
+			rewrite(&cond.NotValues)
+		}
+	}
+	return changed
+}
+
+// references returns whether the policy references a principal of either trust domain.
+func (m TrustDomainMigration) references(policy *v1beta1.AuthorizationPolicy) bool {
+	found := false
+	check := func(principals []string) {
+		for _, p := range principals {
+			if td := trustDomainOf(p); td == m.From || td == m.To {
+				found = true
+			}
+		}
+	}
+	for _, rule := range policy.GetRules() {
+		for _, from := range rule.GetFrom() {
+			check(from.GetSource().GetPrincipals())
+			check(from.GetSource().GetNotPrincipals())
+		}
+		for _, cond := range rule.GetWhen() {
+			if cond.GetKey() == sourcePrincipalKey {
+				check(cond.GetValues())
+				check(cond.GetNotValues())
+			}
+		}
+	}
+	return found
+}
+
+// Progress returns the migration progress of the policies, per namespace, sorted by namespace.
+// The policies are not modified.
+func (m TrustDomainMigration) Progress(policies []*clientsecurity.AuthorizationPolicy) []NamespaceProgress {
+	byNamespace := map[string]*NamespaceProgress{}
+	for _, policy := range policies {
+		p, f := byNamespace[policy.Namespace]
+		if !f {
+			p = &NamespaceProgress{Namespace: policy.Namespace}
+			byNamespace[policy.Namespace] = p
+		}
+		if !m.references(&policy.Spec) {
+			continue
+		}
+		p.Total++
+		spec := proto.Clone(&policy.Spec).(*v1beta1.AuthorizationPolicy)
+		if m.RewritePolicy(spec) {
+			p.Pending++
+		}
+	}
+	res := make([]NamespaceProgress, 0, len(byNamespace))
+	for _, p := range byNamespace {
+		res = append(res, *p)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Namespace < res[j].Namespace
+	})
+	return res
+}
+
+// PrintProgress prints the migration progress in a table.
+func PrintProgress(w io.Writer, progress []NamespaceProgress) {
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NAMESPACE\tPOLICIES\tMIGRATED\tPENDING")
+	for _, p := range progress {
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\t%d\n", p.Namespace, p.Total, p.Total-p.Pending, p.Pending)
+	}
+	_ = tw.Flush()
+}
+
+// trustDomainOf returns the trust domain of a principal in the <trust-domain>/ns/<namespace>/sa/<service-account>
+// format, including the principals with a suffix match such as <trust-domain>/ns/<namespace>/*, or an empty
+// string if the principal has no trust domain.
+func trustDomainOf(principal string) string {
+	parts := strings.SplitN(principal, "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return ""
+	}
+	return parts[0]
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
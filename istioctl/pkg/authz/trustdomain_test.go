@@ -0,0 +1,208 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authz
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/api/security/v1beta1"
+	clientsecurity "istio.io/client-go/pkg/apis/security/v1beta1"
+)
+
+func TestRewritePrincipals(t *testing.T) {
+	cases := []struct {
+		name        string
+		removeFrom  bool
+		principals  []string
+		want        []string
+		wantChanged bool
+	}{
+		{
+			name:        "add new trust domain",
+			principals:  []string{"old-td/ns/foo/sa/bar", "other-td/ns/foo/sa/bar", "*/ns/foo/sa/baz"},
+			want:        []string{"old-td/ns/foo/sa/bar", "new-td/ns/foo/sa/bar", "other-td/ns/foo/sa/bar", "*/ns/foo/sa/baz"},
+			wantChanged: true,
+		},
+		{
+			name:       "already migrated",
+			principals: []string{"old-td/ns/foo/sa/bar", "new-td/ns/foo/sa/bar"},
+			want:       []string{"old-td/ns/foo/sa/bar", "new-td/ns/foo/sa/bar"},
+		},
+		{
+			name:        "remove old trust domain",
+			removeFrom:  true,
+			principals:  []string{"old-td/ns/foo/sa/bar", "new-td/ns/foo/sa/bar"},
+			want:        []string{"new-td/ns/foo/sa/bar"},
+			wantChanged: true,
+		},
+		{
+			name:       "already removed",
+			removeFrom: true,
+			principals: []string{"new-td/ns/foo/sa/bar", "other-td/ns/foo/sa/bar"},
+			want:       []string{"new-td/ns/foo/sa/bar", "other-td/ns/foo/sa/bar"},
+		},
+		{
+			name:        "suffix match",
+			principals:  []string{"old-td/ns/foo/*"},
+			want:        []string{"old-td/ns/foo/*", "new-td/ns/foo/*"},
+			wantChanged: true,
+		},
+		{
+			name:       "no trust domain",
+			principals: []string{"old-td", "old-td/", "*"},
+			want:       []string{"old-td", "old-td/", "*"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := TrustDomainMigration{From: "old-td", To: "new-td", RemoveFrom: c.removeFrom}
+			got, changed := m.RewritePrincipals(c.principals)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+			if changed != c.wantChanged {
+				t.Errorf("got changed %v, want %v", changed, c.wantChanged)
+			}
+		})
+	}
+}
+
+func TestApplyMeshConfig(t *testing.T) {
+	mesh := &meshconfig.MeshConfig{TrustDomain: "new-td", TrustDomainAliases: []string{"old-td"}}
+	cases := []struct {
+		name       string
+		migration  TrustDomainMigration
+		mesh       *meshconfig.MeshConfig
+		istiodFrom string
+		want       TrustDomainMigration
+		wantErr    bool
+	}{
+		{
+			name: "defaults",
+			mesh: mesh,
+			want: TrustDomainMigration{From: "old-td", To: "new-td"},
+		},
+		{
+			name:      "explicit",
+			migration: TrustDomainMigration{From: "old-td", To: "new-td"},
+			mesh:      mesh,
+			want:      TrustDomainMigration{From: "old-td", To: "new-td"},
+		},
+		{
+			name:      "from is not an alias",
+			migration: TrustDomainMigration{From: "other-td"},
+			mesh:      mesh,
+			wantErr:   true,
+		},
+		{
+			name:       "istiod migration",
+			mesh:       &meshconfig.MeshConfig{TrustDomain: "new-td"},
+			istiodFrom: "old-td",
+			want:       TrustDomainMigration{From: "old-td", To: "new-td"},
+		},
+		{
+			name:       "istiod migration with alias",
+			migration:  TrustDomainMigration{From: "old-td"},
+			mesh:       mesh,
+			istiodFrom: "other-td",
+			want:       TrustDomainMigration{From: "old-td", To: "new-td"},
+		},
+		{
+			name:       "from is neither an alias nor the istiod migration",
+			migration:  TrustDomainMigration{From: "other-td"},
+			mesh:       &meshconfig.MeshConfig{TrustDomain: "new-td"},
+			istiodFrom: "old-td",
+			wantErr:    true,
+		},
+		{
+			name:    "alias not set",
+			mesh:    &meshconfig.MeshConfig{TrustDomain: "new-td"},
+			wantErr: true,
+		},
+		{
+			name:      "remove after the alias is removed",
+			migration: TrustDomainMigration{From: "old-td", RemoveFrom: true},
+			mesh:      &meshconfig.MeshConfig{TrustDomain: "new-td"},
+			want:      TrustDomainMigration{From: "old-td", To: "new-td", RemoveFrom: true},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := c.migration
+			err := m.ApplyMeshConfig(c.mesh, c.istiodFrom)
+			if gotErr := err != nil; gotErr != c.wantErr {
+				t.Fatalf("got error %v, want error %v", err, c.wantErr)
+			}
+			if err == nil && m != c.want {
+				t.Errorf("got %+v, want %+v", m, c.want)
+			}
+		})
+	}
+}
+
+func TestTrustDomainMigrationProgress(t *testing.T) {
+	newPolicy := func(ns, name string, principals ...string) *clientsecurity.AuthorizationPolicy {
+		return &clientsecurity.AuthorizationPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+			Spec: v1beta1.AuthorizationPolicy{
+				Rules: []*v1beta1.Rule{{
+					From: []*v1beta1.Rule_From{{Source: &v1beta1.Source{Principals: principals}}},
+					When: []*v1beta1.Condition{{Key: "source.principal", Values: principals}},
+				}},
+			},
+		}
+	}
+	policies := []*clientsecurity.AuthorizationPolicy{
+		newPolicy("foo", "pending", "old-td/ns/foo/sa/bar"),
+		newPolicy("foo", "migrated", "old-td/ns/foo/sa/bar", "new-td/ns/foo/sa/bar"),
+		newPolicy("foo", "unrelated", "other-td/ns/foo/sa/bar"),
+		newPolicy("bar", "pending", "old-td/ns/bar/sa/bar"),
+	}
+	m := TrustDomainMigration{From: "old-td", To: "new-td"}
+
+	want := []NamespaceProgress{
+		{Namespace: "bar", Total: 1, Pending: 1},
+		{Namespace: "foo", Total: 2, Pending: 1},
+	}
+	if got := m.Progress(policies); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := policies[0].Spec.Rules[0].From[0].Source.Principals; len(got) != 1 {
+		t.Errorf("progress modified the policy: %v", got)
+	}
+
+	if !m.RewritePolicy(&policies[0].Spec) {
+		t.Fatalf("expected the policy to be rewritten")
+	}
+	rule := policies[0].Spec.Rules[0]
+	want2 := []string{"old-td/ns/foo/sa/bar", "new-td/ns/foo/sa/bar"}
+	if !reflect.DeepEqual(rule.From[0].Source.Principals, want2) || !reflect.DeepEqual(rule.When[0].Values, want2) {
+		t.Errorf("unexpected rewritten policy: %v", rule)
+	}
+
+	var out bytes.Buffer
+	PrintProgress(&out, m.Progress(policies))
+	if !strings.Contains(out.String(), "foo       2        2        0") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+}
@@ -0,0 +1,451 @@
+// Copyright Istio Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package install
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	admissionv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	jsonOutput = "json"
+	yamlOutput = "yaml"
+)
+
+type precheckStatus string
+
+const (
+	precheckPassed  precheckStatus = "passed"
+	precheckWarning precheckStatus = "warning"
+	precheckFailed  precheckStatus = "failed"
+	precheckSkipped precheckStatus = "skipped"
+)
+
+// precheckResult is the result of a check, as reported by the machine-readable output.
+type precheckResult struct {
+	Check    string         `json:"check"`
+	Status   precheckStatus `json:"status"`
+	Messages []string       `json:"messages,omitempty"`
+}
+
+// precheckReport is the machine-readable output of precheck.
+type precheckReport struct {
+	Passed  bool              `json:"passed"`
+	Results []*precheckResult `json:"results"`
+}
+
+func (r *precheckReport) add(check string, status precheckStatus, messages ...string) {
+	if r != nil {
+		r.Results = append(r.Results, &precheckResult{Check: check, Status: status, Messages: messages})
+	}
+}
+
+// writeResult writes the result as a section of the text output, and adds it to the report.
+func writeResult(writer io.Writer, report *precheckReport, number int, res *precheckResult) {
+	fmt.Fprintf(writer, "\n")
+	fmt.Fprintf(writer, "#%d. %s\n", number, res.Check)
+	fmt.Fprintf(writer, "-----------------------\n")
+	for _, m := range res.Messages {
+		switch res.Status {
+		case precheckWarning:
+			fmt.Fprintf(writer, "Warning: %s\n", m)
+		case precheckFailed:
+			fmt.Fprintf(writer, "Error: %s\n", m)
+		default:
+			fmt.Fprintf(writer, "%s\n", m)
+		}
+	}
+	report.add(res.Check, res.Status, res.Messages...)
+}
+
+var (
+	// cniPlugins maps the names of the DaemonSets deploying the common CNI plugins to the plugins.
+	cniPlugins = map[string]string{
+		"cilium":          "Cilium",
+		"calico-node":     "Calico",
+		"canal":           "Canal",
+		"kube-flannel-ds": "Flannel",
+		"weave-net":       "Weave Net",
+		"aws-node":        "Amazon VPC CNI",
+		"istio-cni-node":  "Istio CNI",
+	}
+
+	calicoGlobalPolicies = schema.GroupVersionResource{Group: "crd.projectcalico.org", Version: "v1", Resource: "globalnetworkpolicies"}
+)
+
+const (
+	podSecurityEnforceLabel = "pod-security.kubernetes.io/enforce"
+
+	// minKernelVersion is the oldest kernel shipping the netfilter modules used to redirect the traffic to the
+	// sidecars, as in RHEL 7.
+	minKernelVersion = "3.10"
+)
+
+// checkCNI detects the CNI plugins of the cluster, and the configurations of the plugins known to conflict with
+// the redirection of the traffic to the sidecars.
+func checkCNI(c preCheckExecClient, cniEnabled bool) *precheckResult {
+	res := &precheckResult{Check: "CNI", Status: precheckPassed}
+	daemonSets, err := c.listDaemonSets()
+	if err != nil {
+		res.Status = precheckWarning
+		res.Messages = []string{fmt.Sprintf("Could not list the DaemonSets to detect the CNI plugins: %v.", err)}
+		return res
+	}
+	plugins := map[string]appsv1.DaemonSet{}
+	for _, ds := range daemonSets {
+		for prefix, plugin := range cniPlugins {
+			if ds.Name == prefix || strings.HasPrefix(ds.Name, prefix+"-") {
+				plugins[plugin] = ds
+			}
+		}
+	}
+	_, istioCNI := plugins["Istio CNI"]
+	delete(plugins, "Istio CNI")
+	names := make([]string, 0, len(plugins))
+	for p := range plugins {
+		names = append(names, p)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		res.Messages = append(res.Messages, "No known CNI plugin was detected.")
+	} else {
+		res.Messages = append(res.Messages, fmt.Sprintf("Detected CNI plugins: %s.", strings.Join(names, ", ")))
+	}
+	if len(names) > 1 {
+		res.Status = precheckFailed
+		res.Messages = append(res.Messages, fmt.Sprintf("Multiple CNI plugins are deployed (%s), their configurations may conflict.",
+			strings.Join(names, ", ")))
+	}
+
+	if ds, f := plugins["Cilium"]; f {
+		cm, err := c.getConfigMap(ds.Namespace, "cilium-config")
+		if err == nil {
+			if (cniEnabled || istioCNI) && cm.Data["cni-exclusive"] != "false" {
+				res.warn("Cilium removes the configuration of the other CNI plugins unless cni-exclusive is set to false in " +
+					"cilium-config, which prevents the chaining of the Istio CNI plugin.")
+			}
+			switch cm.Data["kube-proxy-replacement"] {
+			case "strict", "partial", "true":
+				if cm.Data["bpf-lb-sock-hostns-only"] != "true" {
+					res.warn("Cilium replaces kube-proxy with socket load balancing, which bypasses the redirection to the " +
+						"sidecars unless bpf-lb-sock-hostns-only is set to true in cilium-config.")
+				}
+			}
+		}
+	}
+
+	if _, f := plugins["Calico"]; f {
+		policies, err := c.listCalicoGlobalPolicies()
+		if err != nil {
+			res.warn(fmt.Sprintf("Could not list the Calico GlobalNetworkPolicies: %v.", err))
+		}
+		for _, p := range policies {
+			if denies(p) {
+				res.warn(fmt.Sprintf("Calico GlobalNetworkPolicy %s denies traffic, it must allow the traffic to istiod on "+
+					"ports 15010, 15012, 15014 and 15017, and to the sidecars on ports 15006, 15008 and 15090.", p.GetName()))
+			}
+		}
+	}
+	return res
+}
+
+func (r *precheckResult) warn(msg string) {
+	if r.Status == precheckPassed {
+		r.Status = precheckWarning
+	}
+	r.Messages = append(r.Messages, msg)
+}
+
+func (r *precheckResult) fail(msg string) {
+	r.Status = precheckFailed
+	r.Messages = append(r.Messages, msg)
+}
+
+// denies returns whether the Calico policy has a rule denying traffic.
+func denies(policy unstructured.Unstructured) bool {
+	for _, direction := range []string{"ingress", "egress"} {
+		rules, _, _ := unstructured.NestedSlice(policy.Object, "spec", direction)
+		for _, r := range rules {
+			if rule, ok := r.(map[string]interface{}); ok && rule["action"] == "Deny" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkPodSecurity checks that the PodSecurity admission levels of the namespaces allow the injected pods: the
+// baseline level forbids the NET_ADMIN and NET_RAW capabilities of the istio-init container, and the restricted level
+// forbids the sidecar as well.
+func checkPodSecurity(c preCheckExecClient, cniEnabled bool) *precheckResult {
+	res := &precheckResult{Check: "PodSecurity", Status: precheckPassed}
+	namespaces, err := c.listNamespaces()
+	if err != nil {
+		res.warn(fmt.Sprintf("Could not list the namespaces: %v.", err))
+		return res
+	}
+	for _, ns := range namespaces {
+		level := ns.Labels[podSecurityEnforceLabel]
+		var msg string
+		switch {
+		case level == "restricted":
+			msg = fmt.Sprintf("Namespace %s enforces the restricted PodSecurity level, which forbids the injected sidecars.", ns.Name)
+		case level == "baseline" && !cniEnabled:
+			msg = fmt.Sprintf("Namespace %s enforces the baseline PodSecurity level, which forbids the NET_ADMIN and NET_RAW "+
+				"capabilities of the istio-init container. Install the Istio CNI plugin to inject the pods of the namespace.", ns.Name)
+		default:
+			continue
+		}
+		if injectionEnabled(ns) {
+			res.fail(msg)
+		} else {
+			res.warn(msg)
+		}
+	}
+	if res.Status == precheckPassed {
+		res.Messages = append(res.Messages, "No PodSecurity admission level forbids the injected pods.")
+	}
+	return res
+}
+
+func injectionEnabled(ns v1.Namespace) bool {
+	_, rev := ns.Labels["istio.io/rev"]
+	return rev || ns.Labels["istio-injection"] == "enabled"
+}
+
+// checkKernel checks that the nodes can redirect the traffic to the sidecars. The kernel modules can not be checked
+// through the Kubernetes API, so only the kernel versions and operating systems of the nodes are checked.
+func checkKernel(c preCheckExecClient) *precheckResult {
+	res := &precheckResult{Check: "Kernel", Status: precheckPassed}
+	nodes, err := c.listNodes()
+	if err != nil {
+		res.warn(fmt.Sprintf("Could not list the nodes: %v.", err))
+		return res
+	}
+	ipv6 := false
+	for _, n := range nodes {
+		info := n.Status.NodeInfo
+		if info.OperatingSystem != "" && info.OperatingSystem != "linux" {
+			res.warn(fmt.Sprintf("Node %s runs %s, the sidecars can only be injected in the pods of Linux nodes.", n.Name,
+				info.OperatingSystem))
+			continue
+		}
+		if info.KernelVersion != "" && compareKernelVersions(info.KernelVersion, minKernelVersion) < 0 {
+			res.fail(fmt.Sprintf("Node %s runs kernel %s, older than %s.", n.Name, info.KernelVersion, minKernelVersion))
+		}
+		for _, a := range n.Status.Addresses {
+			if a.Type == v1.NodeInternalIP && strings.Contains(a.Address, ":") {
+				ipv6 = true
+			}
+		}
+	}
+	modules := "br_netfilter, nf_nat, xt_REDIRECT, xt_owner, iptable_nat and iptable_mangle"
+	if ipv6 {
+		modules = "br_netfilter, nf_nat, xt_REDIRECT, xt_owner, iptable_nat, iptable_mangle, ip6table_nat and ip6table_mangle"
+	}
+	res.Messages = append(res.Messages, fmt.Sprintf("The nodes must load the kernel modules %s to redirect the traffic, "+
+		"they can not be checked through the Kubernetes API.", modules))
+	return res
+}
+
+// compareKernelVersions compares the major and minor versions of the kernel versions, such as 5.4.0-1036-gke.
+func compareKernelVersions(a, b string) int {
+	pa, pb := kernelVersion(a), kernelVersion(b)
+	for i := range pa {
+		if pa[i] != pb[i] {
+			if pa[i] < pb[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func kernelVersion(v string) [2]int {
+	var out [2]int
+	parts := strings.SplitN(v, ".", 3)
+	for i := 0; i < len(parts) && i < 2; i++ {
+		digits := strings.IndexFunc(parts[i], func(r rune) bool { return r < '0' || r > '9' })
+		if digits >= 0 {
+			parts[i] = parts[i][:digits]
+		}
+		out[i], _ = strconv.Atoi(parts[i])
+	}
+	return out
+}
+
+// webhookService is a service of an Istio webhook configuration.
+type webhookService struct {
+	webhook   string
+	namespace string
+	name      string
+	port      int32
+	path      string
+}
+
+// checkWebhookReachability checks that the API server can reach the services of the Istio webhooks, through the
+// service proxy of the API server.
+func checkWebhookReachability(c preCheckExecClient) *precheckResult {
+	res := &precheckResult{Check: "Webhook-reachability", Status: precheckPassed}
+	services, err := c.webhookServices()
+	if err != nil {
+		res.warn(fmt.Sprintf("Could not list the webhook configurations: %v.", err))
+		return res
+	}
+	if len(services) == 0 {
+		res.Status = precheckSkipped
+		res.Messages = []string{"No Istio webhook is installed. Once installed, the API server must reach istiod on port 15017."}
+		return res
+	}
+	for _, svc := range services {
+		err := c.probeService(svc)
+		if kerrors.IsForbidden(err) {
+			res.warn(fmt.Sprintf("Could not probe the service %s/%s:%d of the webhook %s: %v.", svc.namespace, svc.name, svc.port,
+				svc.webhook, err))
+			continue
+		}
+		if err != nil && webhookUnreachable(err) {
+			res.fail(fmt.Sprintf("The API server can not reach the service %s/%s:%d of the webhook %s: %v. The firewall of private "+
+				"clusters must allow the control plane to reach the nodes on port 15017.", svc.namespace, svc.name, svc.port, svc.webhook, err))
+			continue
+		}
+		res.Messages = append(res.Messages, fmt.Sprintf("The API server can reach the service %s/%s:%d of the webhook %s.",
+			svc.namespace, svc.name, svc.port, svc.webhook))
+	}
+	return res
+}
+
+// webhookUnreachable returns whether the error of a request through the service proxy of the API server was
+// returned by the API server failing to reach the service, rather than by the service.
+func webhookUnreachable(err error) bool {
+	statusErr, ok := err.(*kerrors.StatusError)
+	if !ok {
+		return true
+	}
+	msg := statusErr.ErrStatus.Message
+	return strings.Contains(msg, "error trying to reach service") || strings.Contains(msg, "no endpoints available")
+}
+
+func (c *preCheckClient) listDaemonSets() ([]appsv1.DaemonSet, error) {
+	l, err := c.client.AppsV1().DaemonSets("").List(context.TODO(), meta_v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return l.Items, nil
+}
+
+func (c *preCheckClient) getConfigMap(ns, name string) (*v1.ConfigMap, error) {
+	return c.client.CoreV1().ConfigMaps(ns).Get(context.TODO(), name, meta_v1.GetOptions{})
+}
+
+func (c *preCheckClient) listCalicoGlobalPolicies() ([]unstructured.Unstructured, error) {
+	l, err := c.dclient.Resource(calicoGlobalPolicies).List(context.TODO(), meta_v1.ListOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return l.Items, nil
+}
+
+func (c *preCheckClient) listNamespaces() ([]v1.Namespace, error) {
+	l, err := c.client.CoreV1().Namespaces().List(context.TODO(), meta_v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return l.Items, nil
+}
+
+func (c *preCheckClient) listNodes() ([]v1.Node, error) {
+	l, err := c.client.CoreV1().Nodes().List(context.TODO(), meta_v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return l.Items, nil
+}
+
+func (c *preCheckClient) webhookServices() ([]webhookService, error) {
+	var configs []webhookClientConfig
+	mutating, err := c.client.AdmissionregistrationV1().MutatingWebhookConfigurations().List(context.TODO(), meta_v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, mwc := range mutating.Items {
+		for _, wh := range mwc.Webhooks {
+			configs = append(configs, webhookClientConfig{mwc.Name, wh.ClientConfig})
+		}
+	}
+	validating, err := c.client.AdmissionregistrationV1().ValidatingWebhookConfigurations().List(context.TODO(), meta_v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, vwc := range validating.Items {
+		for _, wh := range vwc.Webhooks {
+			configs = append(configs, webhookClientConfig{vwc.Name, wh.ClientConfig})
+		}
+	}
+	return istioWebhookServices(configs), nil
+}
+
+type webhookClientConfig struct {
+	webhook string
+	config  admissionv1.WebhookClientConfig
+}
+
+// istioWebhookServices returns the services of the Istio webhook configurations, without duplicates.
+func istioWebhookServices(configs []webhookClientConfig) []webhookService {
+	var out []webhookService
+	seen := map[webhookService]bool{}
+	for _, c := range configs {
+		ref := c.config.Service
+		if !strings.HasPrefix(c.webhook, "istio") || ref == nil {
+			continue
+		}
+		svc := webhookService{namespace: ref.Namespace, name: ref.Name, port: 443}
+		if ref.Port != nil {
+			svc.port = *ref.Port
+		}
+		if ref.Path != nil {
+			svc.path = *ref.Path
+		}
+		if seen[svc] {
+			continue
+		}
+		seen[svc] = true
+		svc.webhook = c.webhook
+		out = append(out, svc)
+	}
+	return out
+}
+
+func (c *preCheckClient) probeService(svc webhookService) error {
+	_, err := c.client.CoreV1().Services(svc.namespace).ProxyGet("https", svc.name, strconv.Itoa(int(svc.port)), svc.path, nil).
+		DoRaw(context.TODO())
+	return err
+}
@@ -16,6 +16,7 @@ package install
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -25,6 +26,7 @@ import (
 	"github.com/ghodss/yaml"
 	multierror "github.com/hashicorp/go-multierror"
 	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
 	authorizationapi "k8s.io/api/authorization/v1beta1"
 	v1 "k8s.io/api/core/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -62,12 +64,21 @@ type preCheckExecClient interface {
 	checkAuthorization(s *authorizationapi.SelfSubjectAccessReview) (result *authorizationapi.SelfSubjectAccessReview, err error)
 	checkMutatingWebhook() error
 	getIstioInstalls() ([]istioInstall, error)
+	listDaemonSets() ([]appsv1.DaemonSet, error)
+	getConfigMap(ns, name string) (*v1.ConfigMap, error)
+	listCalicoGlobalPolicies() ([]unstructured.Unstructured, error)
+	listNamespaces() ([]v1.Namespace, error)
+	listNodes() ([]v1.Node, error)
+	webhookServices() ([]webhookService, error)
+	probeService(svc webhookService) error
 }
 
 // Tell the user if Istio can be installed, and if not give the reason.
 // Note: this doesn't check the IstioOperator options.  It only checks a few things every
 // Istio install needs.  It does not check the Revision.
-func installPreCheck(istioNamespaceFlag string, restClientGetter genericclioptions.RESTClientGetter, writer io.Writer) error {
+// The results of the checks are added to the report, if not nil.
+func installPreCheck(istioNamespaceFlag string, cniEnabled bool, restClientGetter genericclioptions.RESTClientGetter,
+	writer io.Writer, report *precheckReport) error {
 	fmt.Fprintf(writer, "\n")
 	fmt.Fprintf(writer, "Checking the cluster to make sure it is ready for Istio installation...\n")
 	fmt.Fprintf(writer, "\n")
@@ -78,6 +89,7 @@ func installPreCheck(istioNamespaceFlag string, restClientGetter genericclioptio
 	if err != nil {
 		errs = multierror.Append(errs, fmt.Errorf("failed to initialize the Kubernetes client: %v", err))
 		fmt.Fprintf(writer, "Failed to initialize the Kubernetes client: %v.\n", err)
+		report.add("Kubernetes-api", precheckFailed, fmt.Sprintf("Failed to initialize the Kubernetes client: %v.", err))
 		return errs
 	}
 	fmt.Fprintf(writer, "Can initialize the Kubernetes client.\n")
@@ -86,9 +98,11 @@ func installPreCheck(istioNamespaceFlag string, restClientGetter genericclioptio
 		errs = multierror.Append(errs, fmt.Errorf("failed to query the Kubernetes API Server: %v", err))
 		fmt.Fprintf(writer, "Failed to query the Kubernetes API Server: %v.\n", err)
 		fmt.Fprintf(writer, "Istio install NOT verified because the cluster is unreachable.\n")
+		report.add("Kubernetes-api", precheckFailed, fmt.Sprintf("Failed to query the Kubernetes API Server: %v.", err))
 		return errs
 	}
 	fmt.Fprintf(writer, "Can query the Kubernetes API Server.\n")
+	report.add("Kubernetes-api", precheckPassed, "Can query the Kubernetes API Server.")
 
 	fmt.Fprintf(writer, "\n")
 	fmt.Fprintf(writer, "#2. Kubernetes-version\n")
@@ -97,12 +111,15 @@ func installPreCheck(istioNamespaceFlag string, restClientGetter genericclioptio
 	if err != nil {
 		errs = multierror.Append(errs, err)
 		fmt.Fprint(writer, err)
+		report.add("Kubernetes-version", precheckFailed, err.Error())
 	} else if !res {
 		msg := fmt.Sprintf("The Kubernetes API version: %v is lower than the minimum version: 1.%d", v, k8sversion.MinK8SVersion)
 		errs = multierror.Append(errs, errors.New(msg))
 		fmt.Fprintf(writer, msg+"\n")
+		report.add("Kubernetes-version", precheckFailed, msg)
 	} else {
 		fmt.Fprintf(writer, "Istio is compatible with Kubernetes: %v.\n", v)
+		report.add("Kubernetes-version", precheckPassed, fmt.Sprintf("Istio is compatible with Kubernetes: %v.", v))
 	}
 
 	fmt.Fprintf(writer, "\n")
@@ -110,6 +127,7 @@ func installPreCheck(istioNamespaceFlag string, restClientGetter genericclioptio
 	fmt.Fprintf(writer, "-----------------------\n")
 	_, _ = c.getNameSpace(istioNamespaceFlag)
 	fmt.Fprintf(writer, "Istio will be installed in the %v namespace.\n", istioNamespaceFlag)
+	report.add("Istio-existence", precheckPassed, fmt.Sprintf("Istio will be installed in the %v namespace.", istioNamespaceFlag))
 
 	fmt.Fprintf(writer, "\n")
 	fmt.Fprintf(writer, "#4. Kubernetes-setup\n")
@@ -189,8 +207,12 @@ func installPreCheck(istioNamespaceFlag string, restClientGetter genericclioptio
 	}
 	if createErrors == nil {
 		fmt.Fprintf(writer, "Can create necessary Kubernetes configurations: %v. \n", strings.Join(resourceNames, ","))
+		report.add("Kubernetes-setup", precheckPassed,
+			fmt.Sprintf("Can create necessary Kubernetes configurations: %v.", strings.Join(resourceNames, ",")))
 	} else {
 		fmt.Fprintf(writer, "Can not create necessary Kubernetes configurations: %v. \n", strings.Join(errResourceNames, ","))
+		report.add("Kubernetes-setup", precheckFailed,
+			fmt.Sprintf("Can not create necessary Kubernetes configurations: %v.", strings.Join(errResourceNames, ",")))
 	}
 
 	fmt.Fprintf(writer, "\n")
@@ -200,10 +222,25 @@ func installPreCheck(istioNamespaceFlag string, restClientGetter genericclioptio
 	if err != nil {
 		fmt.Fprintf(writer, "This Kubernetes cluster deployed without MutatingAdmissionWebhook support."+
 			"See "+url.SidecarInjection+"\n")
+		report.add("SideCar-Injector", precheckWarning, "This Kubernetes cluster deployed without MutatingAdmissionWebhook support.")
 	} else {
 		fmt.Fprintf(writer, "This Kubernetes cluster supports automatic sidecar injection."+
 			" To enable automatic sidecar injection see "+url.SidecarDeployingApp+"\n")
+		report.add("SideCar-Injector", precheckPassed, "This Kubernetes cluster supports automatic sidecar injection.")
 	}
+
+	for i, res := range []*precheckResult{
+		checkCNI(c, cniEnabled),
+		checkPodSecurity(c, cniEnabled),
+		checkKernel(c),
+		checkWebhookReachability(c),
+	} {
+		writeResult(writer, report, i+6, res)
+		if res.Status == precheckFailed {
+			errs = multierror.Append(errs, fmt.Errorf("%s: %s", res.Check, strings.Join(res.Messages, " ")))
+		}
+	}
+
 	fmt.Fprintf(writer, "\n")
 	fmt.Fprintf(writer, "-----------------------\n")
 	if errs == nil {
@@ -298,6 +335,7 @@ func NewPrecheckCommand() *cobra.Command {
 			Usage:     "Istio YAML installation file.",
 		}
 		istioNamespace string
+		outputFormat   string
 		opts           clioptions.ControlPlaneOptions
 	)
 	precheckCmd := &cobra.Command{
@@ -313,71 +351,35 @@ func NewPrecheckCommand() *cobra.Command {
   istioctl x precheck --set profile=demo
 
   # Verify the deployment matches the Istio Operator deployment definition
-  istioctl x precheck -f iop.yaml`,
+  istioctl x precheck -f iop.yaml
+
+  # Report the results of the checks as JSON
+  istioctl x precheck -o json`,
 		Args: cobra.ExactArgs(0),
 		RunE: func(c *cobra.Command, args []string) error {
-			targetNamespace := istioNamespace
-			targetRevision := opts.Revision
-			specific := c.Flags().Changed("istioNamespace") // is user asking about a specific Istio System ns or revision
-
-			// Check if we can install the IOP specified with -f
-			if len(fileNameFlags.ToOptions().Filenames) > 0 {
-				iop, err := getIOPFromFile(fileNameFlags.ToOptions().Filenames[0])
-				if err != nil {
-					// Failure here means EITHER the file wasn't an IOP, or we can't parse
-					// the IOP yet.
-					return err
-				}
-				// Currently we don't look at specific IOP options, just the namespace and Revision
-				targetNamespace = iop.GetNamespace()
-				targetRevision = iop.Spec.Revision
-				specific = true
+			var report *precheckReport
+			writer := c.OutOrStdout()
+			switch outputFormat {
+			case "":
+			case jsonOutput, yamlOutput:
+				report = &precheckReport{}
+				writer = ioutil.Discard
+			default:
+				return fmt.Errorf("output format %q not supported", outputFormat)
 			}
-
-			cli, err := clientFactory(kubeConfigFlags)
-			if err != nil {
+			err := runPrecheck(c, writer, report, istioNamespace, opts.Revision, fileNameFlags.ToOptions().Filenames, kubeConfigFlags)
+			if report == nil {
 				return err
 			}
-
-			installs, err := cli.getIstioInstalls()
-			if err == nil && len(installs) > 0 {
-				matched := false
-				for _, install := range installs {
-					if !specific || targetNamespace == install.namespace && targetRevision == install.revision {
-						revision := install.revision
-						if revision == "" {
-							revision = "default"
-						}
-						c.Printf("%q revision of Istio is already installed in %q namespace\n", revision, install.namespace)
-					}
-					if targetNamespace == install.namespace && targetRevision == install.revision {
-						matched = true
-					}
-				}
-				// The user has Istio, but wants to install a new revision
-				if !matched {
-					return installPreCheck(targetNamespace, kubeConfigFlags, c.OutOrStdout())
-				}
-				return nil
+			report.Passed = err == nil
+			var out []byte
+			if outputFormat == jsonOutput {
+				out, _ = json.MarshalIndent(report, "", "  ")
+			} else {
+				out, _ = yaml.Marshal(report)
 			}
-
-			// No IstioOperator was found.  In 1.6.0 we fall back to checking for Istio namespace
-			nsExists, err := namespaceExists(targetNamespace, kubeConfigFlags)
-			if err != nil {
-				return err
-			}
-			if !nsExists {
-				return installPreCheck(targetNamespace, kubeConfigFlags, c.OutOrStdout())
-			}
-			if specific {
-				return installPreCheck(targetNamespace, kubeConfigFlags, c.OutOrStdout())
-			}
-
-			// The Istio namespace does exist, but it wasn't installed by 1.6.0+ because no
-			// IstioOperator is there.
-			c.Printf("Istio is already installed in the %q namespace. Skipping pre-check. Confirm with 'istioctl verify-install'.\n", targetNamespace)
-			c.Printf("Use 'istioctl upgrade' to upgrade or 'istioctl install --set revision=<revision>' to install another control plane.\n")
-			return nil
+			fmt.Fprintln(c.OutOrStdout(), string(out))
+			return err
 		},
 	}
 
@@ -387,9 +389,89 @@ func NewPrecheckCommand() *cobra.Command {
 	kubeConfigFlags.AddFlags(flags)
 	fileNameFlags.AddFlags(flags)
 	opts.AttachControlPlaneFlags(precheckCmd)
+	flags.StringVarP(&outputFormat, "output", "o", "", "Output format: one of json|yaml, text if unset")
 	return precheckCmd
 }
 
+// runPrecheck checks whether Istio can be installed, in the namespace and with the revision of the IstioOperator
+// file if specified.
+func runPrecheck(c *cobra.Command, writer io.Writer, report *precheckReport, istioNamespace, revision string,
+	filenames []string, kubeConfigFlags *genericclioptions.ConfigFlags) error {
+	targetNamespace := istioNamespace
+	targetRevision := revision
+	specific := c.Flags().Changed("istioNamespace") // is user asking about a specific Istio System ns or revision
+	cniEnabled := false
+	// The messages about the installed revisions are printed like the text output, but on the standard error
+	info := c.OutOrStderr()
+	if report != nil {
+		info = ioutil.Discard
+	}
+
+	// Check if we can install the IOP specified with -f
+	if len(filenames) > 0 {
+		iop, err := getIOPFromFile(filenames[0])
+		if err != nil {
+			// Failure here means EITHER the file wasn't an IOP, or we can't parse
+			// the IOP yet.
+			return err
+		}
+		// Currently we don't look at specific IOP options, just the namespace, Revision and CNI
+		targetNamespace = iop.GetNamespace()
+		targetRevision = iop.Spec.Revision
+		if iop.Spec.Components != nil && iop.Spec.Components.Cni != nil {
+			cniEnabled = iop.Spec.Components.Cni.Enabled.GetValue()
+		}
+		specific = true
+	}
+
+	cli, err := clientFactory(kubeConfigFlags)
+	if err != nil {
+		return err
+	}
+
+	installs, err := cli.getIstioInstalls()
+	if err == nil && len(installs) > 0 {
+		matched := false
+		for _, install := range installs {
+			if !specific || targetNamespace == install.namespace && targetRevision == install.revision {
+				revision := install.revision
+				if revision == "" {
+					revision = "default"
+				}
+				msg := fmt.Sprintf("%q revision of Istio is already installed in %q namespace", revision, install.namespace)
+				fmt.Fprintln(info, msg)
+				report.add("Istio-existence", precheckSkipped, msg)
+			}
+			if targetNamespace == install.namespace && targetRevision == install.revision {
+				matched = true
+			}
+		}
+		// The user has Istio, but wants to install a new revision
+		if !matched {
+			return installPreCheck(targetNamespace, cniEnabled, kubeConfigFlags, writer, report)
+		}
+		return nil
+	}
+
+	// No IstioOperator was found.  In 1.6.0 we fall back to checking for Istio namespace
+	nsExists, err := namespaceExists(targetNamespace, kubeConfigFlags)
+	if err != nil {
+		return err
+	}
+	if !nsExists || specific {
+		return installPreCheck(targetNamespace, cniEnabled, kubeConfigFlags, writer, report)
+	}
+
+	// The Istio namespace does exist, but it wasn't installed by 1.6.0+ because no
+	// IstioOperator is there.
+	msg := fmt.Sprintf("Istio is already installed in the %q namespace. Skipping pre-check. Confirm with 'istioctl verify-install'.",
+		targetNamespace)
+	fmt.Fprintln(info, msg)
+	fmt.Fprintf(info, "Use 'istioctl upgrade' to upgrade or 'istioctl install --set revision=<revision>' to install another control plane.\n")
+	report.add("Istio-existence", precheckSkipped, msg)
+	return nil
+}
+
 func findIstios(client dynamic.Interface) ([]istioInstall, error) {
 	retval := make([]istioInstall, 0)
 
@@ -16,20 +16,33 @@ package install
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
 
+	appsv1 "k8s.io/api/apps/v1"
 	authorizationapi "k8s.io/api/authorization/v1beta1"
 	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/version"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 )
 
 type mockClientExecPreCheckConfig struct {
-	namespace  string
-	version    *version.Info
-	authConfig *authorizationapi.SelfSubjectAccessReview
+	namespace      string
+	version        *version.Info
+	authConfig     *authorizationapi.SelfSubjectAccessReview
+	daemonSets     []appsv1.DaemonSet
+	configMaps     []v1.ConfigMap
+	calicoPolicies []unstructured.Unstructured
+	namespaces     []v1.Namespace
+	nodes          []v1.Node
+	webhooks       []webhookService
+	probeErr       error
 }
 
 type testcase struct {
@@ -142,6 +155,34 @@ func TestPreCheck(t *testing.T) {
 				namespace: "test",
 			},
 		},
+		{
+			description: "Conflicting CNI plugins",
+			config: &mockClientExecPreCheckConfig{
+				version:    version1_17,
+				namespace:  "test",
+				daemonSets: []appsv1.DaemonSet{daemonSet("kube-system", "cilium"), daemonSet("kube-system", "calico-node")},
+			},
+			expectedException: true,
+		},
+		{
+			description: "Restricted PodSecurity level of an injected namespace",
+			config: &mockClientExecPreCheckConfig{
+				version:    version1_17,
+				namespace:  "test",
+				namespaces: []v1.Namespace{podSecurityNamespace("default", "restricted", "enabled")},
+			},
+			expectedException: true,
+		},
+		{
+			description: "Unreachable webhook",
+			config: &mockClientExecPreCheckConfig{
+				version:   version1_17,
+				namespace: "test",
+				webhooks:  []webhookService{{webhook: "istio-sidecar-injector", namespace: "istio-system", name: "istiod", port: 443}},
+				probeErr:  kerrors.NewServiceUnavailable("error trying to reach service: dial tcp 10.0.0.1:15017: i/o timeout"),
+			},
+			expectedException: true,
+		},
 	}
 
 	for i, c := range cases {
@@ -229,3 +270,174 @@ func (m *mockClientExecPreCheckConfig) checkMutatingWebhook() error {
 func (m *mockClientExecPreCheckConfig) getIstioInstalls() ([]istioInstall, error) {
 	return []istioInstall{}, nil
 }
+
+func (m *mockClientExecPreCheckConfig) listDaemonSets() ([]appsv1.DaemonSet, error) {
+	return m.daemonSets, nil
+}
+
+func (m *mockClientExecPreCheckConfig) getConfigMap(ns, name string) (*v1.ConfigMap, error) {
+	for i := range m.configMaps {
+		if cm := &m.configMaps[i]; cm.Namespace == ns && cm.Name == name {
+			return cm, nil
+		}
+	}
+	return nil, kerrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, name)
+}
+
+func (m *mockClientExecPreCheckConfig) listCalicoGlobalPolicies() ([]unstructured.Unstructured, error) {
+	return m.calicoPolicies, nil
+}
+
+func (m *mockClientExecPreCheckConfig) listNamespaces() ([]v1.Namespace, error) {
+	return m.namespaces, nil
+}
+
+func (m *mockClientExecPreCheckConfig) listNodes() ([]v1.Node, error) {
+	return m.nodes, nil
+}
+
+func (m *mockClientExecPreCheckConfig) webhookServices() ([]webhookService, error) {
+	return m.webhooks, nil
+}
+
+func (m *mockClientExecPreCheckConfig) probeService(webhookService) error {
+	return m.probeErr
+}
+
+func daemonSet(ns, name string) appsv1.DaemonSet {
+	return appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}}
+}
+
+func podSecurityNamespace(name, podSecurityLevel, injection string) v1.Namespace {
+	labels := map[string]string{podSecurityEnforceLabel: podSecurityLevel}
+	if injection != "" {
+		labels["istio-injection"] = injection
+	}
+	return v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func TestCheckCNI(t *testing.T) {
+	ciliumConfig := func(data map[string]string) v1.ConfigMap {
+		return v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "cilium-config"}, Data: data}
+	}
+	denyAll := unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "deny-all"},
+		"spec":     map[string]interface{}{"ingress": []interface{}{map[string]interface{}{"action": "Deny"}}},
+	}}
+	cases := []struct {
+		name       string
+		client     *mockClientExecPreCheckConfig
+		cniEnabled bool
+		want       precheckStatus
+		wantMsg    string
+	}{
+		{
+			name:   "no plugin",
+			client: &mockClientExecPreCheckConfig{},
+			want:   precheckPassed,
+		},
+		{
+			name:   "canal",
+			client: &mockClientExecPreCheckConfig{daemonSets: []appsv1.DaemonSet{daemonSet("kube-system", "canal")}},
+			want:   precheckPassed,
+		},
+		{
+			name: "exclusive cilium with istio cni",
+			client: &mockClientExecPreCheckConfig{
+				daemonSets: []appsv1.DaemonSet{daemonSet("kube-system", "cilium")},
+				configMaps: []v1.ConfigMap{ciliumConfig(map[string]string{})},
+			},
+			cniEnabled: true,
+			want:       precheckWarning,
+			wantMsg:    "cni-exclusive",
+		},
+		{
+			name: "cilium socket load balancing",
+			client: &mockClientExecPreCheckConfig{
+				daemonSets: []appsv1.DaemonSet{daemonSet("kube-system", "cilium")},
+				configMaps: []v1.ConfigMap{ciliumConfig(map[string]string{"kube-proxy-replacement": "strict"})},
+			},
+			want:    precheckWarning,
+			wantMsg: "bpf-lb-sock-hostns-only",
+		},
+		{
+			name: "calico deny policy",
+			client: &mockClientExecPreCheckConfig{
+				daemonSets:     []appsv1.DaemonSet{daemonSet("kube-system", "calico-node")},
+				calicoPolicies: []unstructured.Unstructured{denyAll},
+			},
+			want:    precheckWarning,
+			wantMsg: "GlobalNetworkPolicy deny-all",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			res := checkCNI(tt.client, tt.cniEnabled)
+			if res.Status != tt.want || !strings.Contains(strings.Join(res.Messages, "\n"), tt.wantMsg) {
+				t.Errorf("got %v %v, want %v %q", res.Status, res.Messages, tt.want, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckPodSecurity(t *testing.T) {
+	client := &mockClientExecPreCheckConfig{namespaces: []v1.Namespace{
+		podSecurityNamespace("default", "baseline", "enabled"),
+		podSecurityNamespace("other", "restricted", ""),
+		podSecurityNamespace("privileged", "privileged", "enabled"),
+	}}
+	if res := checkPodSecurity(client, false); res.Status != precheckFailed || len(res.Messages) != 2 {
+		t.Errorf("got %v %v, want the baseline and restricted namespaces to be reported", res.Status, res.Messages)
+	}
+	if res := checkPodSecurity(client, true); res.Status != precheckWarning || len(res.Messages) != 1 {
+		t.Errorf("got %v %v, want the restricted namespace to be reported", res.Status, res.Messages)
+	}
+}
+
+func TestCheckKernel(t *testing.T) {
+	node := func(name, os, kernel string) v1.Node {
+		return v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     v1.NodeStatus{NodeInfo: v1.NodeSystemInfo{OperatingSystem: os, KernelVersion: kernel}},
+		}
+	}
+	res := checkKernel(&mockClientExecPreCheckConfig{nodes: []v1.Node{
+		node("a", "linux", "5.4.0-1036-gke"),
+		node("b", "linux", "3.10.0-1160.el7.x86_64"),
+		node("c", "windows", "10.0.17763.1637"),
+	}})
+	if res.Status != precheckWarning {
+		t.Errorf("got %v %v, want a warning for the windows node", res.Status, res.Messages)
+	}
+	res = checkKernel(&mockClientExecPreCheckConfig{nodes: []v1.Node{node("a", "linux", "2.6.32-754.el6.x86_64")}})
+	if res.Status != precheckFailed {
+		t.Errorf("got %v %v, want the old kernel to fail", res.Status, res.Messages)
+	}
+}
+
+func TestWebhookUnreachable(t *testing.T) {
+	if !webhookUnreachable(kerrors.NewServiceUnavailable("no endpoints available for service \"istiod\"")) {
+		t.Errorf("expected the webhook without endpoints to be unreachable")
+	}
+	if webhookUnreachable(kerrors.NewBadRequest("unexpected request")) {
+		t.Errorf("expected the webhook responding to be reachable")
+	}
+}
+
+func TestPreCheckOutput(t *testing.T) {
+	clientFactory = mockPreCheckClient(&mockClientExecPreCheckConfig{version: version1_17, namespace: "test"})
+	var out bytes.Buffer
+	precheckCmd := NewPrecheckCommand()
+	precheckCmd.SetArgs([]string{"-o", "json"})
+	precheckCmd.SetOut(&out)
+	if err := precheckCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	report := &precheckReport{}
+	if err := json.Unmarshal(out.Bytes(), report); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", out.String(), err)
+	}
+	if !report.Passed || len(report.Results) != 9 || report.Results[8].Check != "Webhook-reachability" {
+		t.Errorf("unexpected report %s", out.String())
+	}
+}
@@ -40,6 +40,8 @@ func NewVerifyCommand() *cobra.Command {
 		istioNamespace string
 		opts           clioptions.ControlPlaneOptions
 		manifestsPath  string
+		detectDrift    bool
+		remediate      bool
 	)
 	verifyInstallCmd := &cobra.Command{
 		Use:   "verify-install [-f <deployment or istio operator file>] [--revision <revision>]",
@@ -53,6 +55,11 @@ in ready status. It will report failure when any of them are not ready.
 If you do not specify an installation it will check for an IstioOperator resource
 and will verify if pods and services defined in it are present.
 
+With --detect-drift, it also compares the live resources with the installation
+resources, and reports the resources which were edited after the installation.
+With --remediate, the missing resources are created and the edited fields are
+reset to their installation values.
+
 Note: For verifying whether your cluster is ready for Istio installation, see
 istioctl experimental precheck.
 `,
@@ -63,7 +70,10 @@ istioctl experimental precheck.
   istioctl verify-install -f $HOME/istio.yaml
 
   # Verify the deployment matches the Istio Operator deployment definition
-  istioctl verify-install --revision <canary>`,
+  istioctl verify-install --revision <canary>
+
+  # Verify that the installed resources were not edited, and reset the edited ones
+  istioctl verify-install --detect-drift --remediate`,
 		Args: func(cmd *cobra.Command, args []string) error {
 			if len(filenames) > 0 && opts.Revision != "" {
 				cmd.Println(cmd.UsageString())
@@ -77,6 +87,9 @@ istioctl experimental precheck.
 			if formatting.IstioctlColorDefault(c.OutOrStdout()) {
 				installationVerifier.Colorize()
 			}
+			if detectDrift || remediate {
+				installationVerifier.DetectDrift(remediate)
+			}
 			return installationVerifier.Verify()
 		},
 	}
@@ -87,6 +100,10 @@ istioctl experimental precheck.
 	kubeConfigFlags.AddFlags(flags)
 	flags.StringSliceVarP(&filenames, "filename", "f", filenames, "Istio YAML installation file.")
 	verifyInstallCmd.PersistentFlags().StringVarP(&manifestsPath, "manifests", "d", "", mesh.ManifestsFlagHelpStr)
+	flags.BoolVar(&detectDrift, "detect-drift", false,
+		"Report the installed resources whose fields differ from the installation resources")
+	flags.BoolVar(&remediate, "remediate", false,
+		"Create the missing installed resources and reset the fields which differ from the installation resources. Implies --detect-drift")
 	opts.AttachControlPlaneFlags(verifyInstallCmd)
 	return verifyInstallCmd
 }
@@ -20,6 +20,8 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/url"
+	"sort"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -98,6 +100,16 @@ func NewCreateRemoteSecretCommand() *cobra.Command {
 
   # Create a secret access a remote cluster with an auth plugin
   istioctl --kubeconfig=c0.yaml x create-remote-secret --name c0 --auth-type=plugin --auth-plugin-name=gcp \
+    | kubectl --kubeconfig=c1.yaml apply -f -
+
+  # Create a secret to access a remote cluster through a private endpoint with its own certificate authority,
+  # authenticating with an exec credential plugin available in the Istiod container
+  istioctl --kubeconfig=c0.yaml x create-remote-secret --name c0 --server https://c0.private.example.com \
+    --certificate-authority private-ca.pem --auth-type=exec --auth-exec-command=aws-iam-authenticator \
+    --auth-exec-args=token,-i,c0 | kubectl --kubeconfig=c1.yaml apply -f -
+
+  # Create a secret to access a remote cluster through an HTTPS proxy
+  istioctl --kubeconfig=c0.yaml x create-remote-secret --name c0 --proxy-url https://proxy.example.com:3128 \
     | kubectl --kubeconfig=c1.yaml apply -f -`,
 		Args: cobra.NoArgs,
 		RunE: func(c *cobra.Command, args []string) error {
@@ -147,13 +159,11 @@ func createRemoteServiceAccountSecret(kubeconfig *api.Config, clusterName, secNa
 	return out, nil
 }
 
-func createBaseKubeconfig(caData []byte, clusterName, server string) *api.Config {
+// createBaseKubeconfig creates a kubeconfig for the cluster, whose certificate authority data must be set.
+func createBaseKubeconfig(cluster *api.Cluster, clusterName string) *api.Config {
 	return &api.Config{
 		Clusters: map[string]*api.Cluster{
-			clusterName: {
-				CertificateAuthorityData: caData,
-				Server:                   server,
-			},
+			clusterName: cluster,
 		},
 		AuthInfos: map[string]*api.AuthInfo{},
 		Contexts: map[string]*api.Context{
@@ -166,34 +176,70 @@ func createBaseKubeconfig(caData []byte, clusterName, server string) *api.Config
 	}
 }
 
-func createBearerTokenKubeconfig(caData, token []byte, clusterName, server string) *api.Config {
-	c := createBaseKubeconfig(caData, clusterName, server)
+func createBearerTokenKubeconfig(cluster *api.Cluster, token []byte, clusterName string) *api.Config {
+	c := createBaseKubeconfig(cluster, clusterName)
 	c.AuthInfos[c.CurrentContext] = &api.AuthInfo{
 		Token: string(token),
 	}
 	return c
 }
 
-func createPluginKubeconfig(caData []byte, clusterName, server string, authProviderConfig *api.AuthProviderConfig) *api.Config {
-	c := createBaseKubeconfig(caData, clusterName, server)
-	c.AuthInfos[c.CurrentContext] = &api.AuthInfo{
-		AuthProvider: authProviderConfig,
-	}
+func createAuthInfoKubeconfig(cluster *api.Cluster, clusterName string, authInfo *api.AuthInfo) *api.Config {
+	c := createBaseKubeconfig(cluster, clusterName)
+	c.AuthInfos[c.CurrentContext] = authInfo
 	return c
 }
 
+// withCertificateAuthority returns a copy of the cluster, with the certificate authority data of the service account
+// secret unless the cluster sets its own, e.g. for API servers reached through a private endpoint or a proxy.
+func withCertificateAuthority(tokenSecret *v1.Secret, cluster *api.Cluster) (*api.Cluster, error) {
+	out := cluster.DeepCopy()
+	if len(out.CertificateAuthorityData) > 0 {
+		return out, nil
+	}
+	caData, ok := tokenSecret.Data[v1.ServiceAccountRootCAKey]
+	if !ok {
+		return nil, errMissingRootCAKey
+	}
+	out.CertificateAuthorityData = caData
+	return out, nil
+}
+
 func createRemoteSecretFromPlugin(
 	tokenSecret *v1.Secret,
-	server, clusterName, secName string,
+	cluster *api.Cluster,
+	clusterName, secName string,
 	authProviderConfig *api.AuthProviderConfig,
 ) (*v1.Secret, error) {
-	caData, ok := tokenSecret.Data[v1.ServiceAccountRootCAKey]
-	if !ok {
-		return nil, errMissingRootCAKey
+	return createRemoteSecretFromAuthInfo(tokenSecret, cluster, clusterName, secName, &api.AuthInfo{
+		AuthProvider: authProviderConfig,
+	})
+}
+
+func createRemoteSecretFromExec(
+	tokenSecret *v1.Secret,
+	cluster *api.Cluster,
+	clusterName, secName string,
+	execConfig *api.ExecConfig,
+) (*v1.Secret, error) {
+	return createRemoteSecretFromAuthInfo(tokenSecret, cluster, clusterName, secName, &api.AuthInfo{
+		Exec: execConfig,
+	})
+}
+
+func createRemoteSecretFromAuthInfo(
+	tokenSecret *v1.Secret,
+	cluster *api.Cluster,
+	clusterName, secName string,
+	authInfo *api.AuthInfo,
+) (*v1.Secret, error) {
+	cluster, err := withCertificateAuthority(tokenSecret, cluster)
+	if err != nil {
+		return nil, err
 	}
 
-	// Create a Kubeconfig to access the remote cluster using the auth provider plugin.
-	kubeconfig := createPluginKubeconfig(caData, clusterName, server, authProviderConfig)
+	// Create a Kubeconfig to access the remote cluster using the auth provider or exec plugin.
+	kubeconfig := createAuthInfoKubeconfig(cluster, clusterName, authInfo)
 	if err := clientcmd.Validate(*kubeconfig); err != nil {
 		return nil, fmt.Errorf("invalid kubeconfig: %v", err)
 	}
@@ -207,10 +253,11 @@ var (
 	errMissingTokenKey  = fmt.Errorf("no %q data found", v1.ServiceAccountTokenKey)
 )
 
-func createRemoteSecretFromTokenAndServer(tokenSecret *v1.Secret, clusterName, server, secName string) (*v1.Secret, error) {
-	caData, ok := tokenSecret.Data[v1.ServiceAccountRootCAKey]
-	if !ok {
-		return nil, errMissingRootCAKey
+func createRemoteSecretFromTokenAndServer(tokenSecret *v1.Secret, clusterName string, cluster *api.Cluster,
+	secName string) (*v1.Secret, error) {
+	cluster, err := withCertificateAuthority(tokenSecret, cluster)
+	if err != nil {
+		return nil, err
 	}
 	token, ok := tokenSecret.Data[v1.ServiceAccountTokenKey]
 	if !ok {
@@ -218,7 +265,7 @@ func createRemoteSecretFromTokenAndServer(tokenSecret *v1.Secret, clusterName, s
 	}
 
 	// Create a Kubeconfig to access the remote cluster using the remote service account credentials.
-	kubeconfig := createBearerTokenKubeconfig(caData, token, clusterName, server)
+	kubeconfig := createBearerTokenKubeconfig(cluster, token, clusterName)
 	if err := clientcmd.Validate(*kubeconfig); err != nil {
 		return nil, fmt.Errorf("invalid kubeconfig: %v", err)
 	}
@@ -435,6 +482,13 @@ const (
 	// User a custom custom authentication plugin for the remote kubernetes cluster.
 	RemoteSecretAuthTypePlugin RemoteSecretAuthType = "plugin"
 
+	// Use an exec credential plugin for the remote kubernetes cluster. The command must be available in the
+	// Istiod container.
+	RemoteSecretAuthTypeExec RemoteSecretAuthType = "exec"
+
+	// defaultExecAPIVersion is the default version of the ExecCredential resource of the exec credential plugin.
+	defaultExecAPIVersion = "client.authentication.k8s.io/v1beta1"
+
 	// Secret generated from remote cluster
 	SecretTypeRemote SecretType = "remote"
 
@@ -462,6 +516,11 @@ type RemoteSecretOptions struct {
 	// Authenticator plugin configuration
 	AuthPluginName   string
 	AuthPluginConfig map[string]string
+	// Exec credential plugin configuration
+	AuthExecCommand    string
+	AuthExecArgs       []string
+	AuthExecEnv        map[string]string
+	AuthExecAPIVersion string
 
 	// Type of the generated secret
 	Type SecretType
@@ -474,6 +533,15 @@ type RemoteSecretOptions struct {
 	// ServerOverride overrides the server IP/hostname field from the Kubeconfig
 	ServerOverride string
 
+	// ProxyURL is the URL of the proxy the API server is reached through.
+	ProxyURL string
+	// TLSServerName overrides the name the certificate of the API server is verified against, e.g. when it is
+	// reached through a private endpoint.
+	TLSServerName string
+	// CertificateAuthority is the path of the certificate authority bundle verifying the API server, replacing the
+	// certificate authority of the service account.
+	CertificateAuthority string
+
 	// SecretName selects a specific secret from the remote service account, if there are multiple
 	SecretName string
 }
@@ -495,7 +563,7 @@ func (o *RemoteSecretOptions) addFlags(flagset *pflag.FlagSet) {
 	flagset.StringVar(&o.SecretName, "secret-name", "",
 		"The name of the specific secret to use from the service-account. Needed when there are multiple secrets in the service account.")
 	var supportedAuthType []string
-	for _, at := range []RemoteSecretAuthType{RemoteSecretAuthTypeBearerToken, RemoteSecretAuthTypePlugin} {
+	for _, at := range []RemoteSecretAuthType{RemoteSecretAuthTypeBearerToken, RemoteSecretAuthTypePlugin, RemoteSecretAuthTypeExec} {
 		supportedAuthType = append(supportedAuthType, string(at))
 	}
 	var supportedSecretType []string
@@ -511,6 +579,26 @@ func (o *RemoteSecretOptions) addFlags(flagset *pflag.FlagSet) {
 	flagset.StringToString("auth-plugin-config", o.AuthPluginConfig,
 		fmt.Sprintf("Authenticator plug-in configuration. --auth-type=%v must be set with this option",
 			RemoteSecretAuthTypePlugin))
+	flagset.StringVar(&o.AuthExecCommand, "auth-exec-command", "",
+		fmt.Sprintf("Command of the exec credential plugin, which must be available in the Istiod container. "+
+			"--auth-type=%v must be set with this option", RemoteSecretAuthTypeExec))
+	flagset.StringSliceVar(&o.AuthExecArgs, "auth-exec-args", nil,
+		fmt.Sprintf("Arguments of the exec credential plugin. --auth-type=%v must be set with this option",
+			RemoteSecretAuthTypeExec))
+	flagset.StringToStringVar(&o.AuthExecEnv, "auth-exec-env", nil,
+		fmt.Sprintf("Environment variables of the exec credential plugin. --auth-type=%v must be set with this option",
+			RemoteSecretAuthTypeExec))
+	flagset.StringVar(&o.AuthExecAPIVersion, "auth-exec-api-version", defaultExecAPIVersion,
+		fmt.Sprintf("Version of the ExecCredential resource of the exec credential plugin. --auth-type=%v must be set "+
+			"with this option", RemoteSecretAuthTypeExec))
+	flagset.StringVar(&o.ProxyURL, "proxy-url", "",
+		"URL of the HTTP, HTTPS or SOCKS5 proxy the Kubernetes API server is reached through.")
+	flagset.StringVar(&o.TLSServerName, "tls-server-name", "",
+		"Name the certificate of the Kubernetes API server is verified against, if it differs from the host of --server, "+
+			"e.g. for private endpoints.")
+	flagset.StringVar(&o.CertificateAuthority, "certificate-authority", "",
+		"Path of the certificate authority bundle verifying the Kubernetes API server, replacing the certificate "+
+			"authority of the service account, e.g. for private endpoints.")
 	flagset.Var(&o.Type, "type",
 		fmt.Sprintf("Type of the generated secret. supported values = %v", supportedSecretType))
 	flagset.StringVarP(&o.ManifestsPath, "manifests", "d", "", mesh.ManifestsFlagHelpStr)
@@ -524,9 +612,40 @@ func (o *RemoteSecretOptions) prepare(flags *pflag.FlagSet) error {
 			return fmt.Errorf("%v is not a valid DNS 1123 label", o.ClusterName)
 		}
 	}
+	if o.ProxyURL != "" {
+		u, err := url.Parse(o.ProxyURL)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid proxy URL %q", o.ProxyURL)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return fmt.Errorf("unsupported proxy URL scheme %q, supported schemes are http, https and socks5", u.Scheme)
+		}
+	}
+	if o.AuthType == RemoteSecretAuthTypeExec && o.AuthExecCommand == "" {
+		return fmt.Errorf("--auth-exec-command must be set with --auth-type=%v", RemoteSecretAuthTypeExec)
+	}
 	return nil
 }
 
+// remoteCluster returns the cluster of the remote kubeconfig, reaching the API server at the server address.
+func (o *RemoteSecretOptions) remoteCluster(server string) (*api.Cluster, error) {
+	cluster := &api.Cluster{
+		Server:        server,
+		ProxyURL:      o.ProxyURL,
+		TLSServerName: o.TLSServerName,
+	}
+	if o.CertificateAuthority != "" {
+		caData, err := ioutil.ReadFile(o.CertificateAuthority)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the certificate authority: %v", err)
+		}
+		cluster.CertificateAuthorityData = caData
+	}
+	return cluster, nil
+}
+
 func createRemoteSecret(opt RemoteSecretOptions, client kube.ExtendedClient, env Environment) (*v1.Secret, error) {
 	// generate the clusterName if not specified
 	if opt.ClusterName == "" {
@@ -567,17 +686,32 @@ func createRemoteSecret(opt RemoteSecretOptions, client kube.ExtendedClient, env
 		}
 	}
 
+	cluster, err := opt.remoteCluster(server)
+	if err != nil {
+		return nil, err
+	}
+
 	var remoteSecret *v1.Secret
 	switch opt.AuthType {
 	case RemoteSecretAuthTypeBearerToken:
-		remoteSecret, err = createRemoteSecretFromTokenAndServer(tokenSecret, opt.ClusterName, server, secretName)
+		remoteSecret, err = createRemoteSecretFromTokenAndServer(tokenSecret, opt.ClusterName, cluster, secretName)
 	case RemoteSecretAuthTypePlugin:
 		authProviderConfig := &api.AuthProviderConfig{
 			Name:   opt.AuthPluginName,
 			Config: opt.AuthPluginConfig,
 		}
-		remoteSecret, err = createRemoteSecretFromPlugin(tokenSecret, server, opt.ClusterName, secretName,
+		remoteSecret, err = createRemoteSecretFromPlugin(tokenSecret, cluster, opt.ClusterName, secretName,
 			authProviderConfig)
+	case RemoteSecretAuthTypeExec:
+		execConfig := &api.ExecConfig{
+			Command:    opt.AuthExecCommand,
+			Args:       opt.AuthExecArgs,
+			APIVersion: opt.AuthExecAPIVersion,
+		}
+		for _, name := range sortedKeys(opt.AuthExecEnv) {
+			execConfig.Env = append(execConfig.Env, api.ExecEnvVar{Name: name, Value: opt.AuthExecEnv[name]})
+		}
+		remoteSecret, err = createRemoteSecretFromExec(tokenSecret, cluster, opt.ClusterName, secretName, execConfig)
 	default:
 		err = fmt.Errorf("unsupported authentication type: %v", opt.AuthType)
 	}
@@ -616,3 +750,12 @@ func CreateRemoteSecret(opt RemoteSecretOptions, env Environment) (string, error
 	}
 	return w.String(), nil
 }
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
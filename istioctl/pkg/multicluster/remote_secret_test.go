@@ -29,6 +29,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/clientcmd/api"
 
 	"istio.io/istio/pkg/kube"
@@ -586,7 +587,7 @@ users:
 		c := &cases[i]
 		secName := remoteSecretNameFromClusterName(c.clusterName)
 		t.Run(fmt.Sprintf("[%v] %v", i, c.name), func(tt *testing.T) {
-			got, err := createRemoteSecretFromTokenAndServer(c.in, c.clusterName, c.server, secName)
+			got, err := createRemoteSecretFromTokenAndServer(c.in, c.clusterName, &api.Cluster{Server: c.server}, secName)
 			if c.wantErrStr != "" {
 				if err == nil {
 					tt.Fatalf("wanted error including %q but none", c.wantErrStr)
@@ -744,7 +745,8 @@ users:
 		c := &cases[i]
 		secName := remoteSecretNameFromClusterName(c.clusterName)
 		t.Run(fmt.Sprintf("[%v] %v", i, c.name), func(tt *testing.T) {
-			got, err := createRemoteSecretFromPlugin(c.in, c.server, c.clusterName, secName, c.authProviderConfig)
+			got, err := createRemoteSecretFromPlugin(c.in, &api.Cluster{Server: c.server}, c.clusterName, secName,
+				c.authProviderConfig)
 			if c.wantErrStr != "" {
 				if err == nil {
 					tt.Fatalf("wanted error including %q but none", c.wantErrStr)
@@ -760,6 +762,49 @@ users:
 	}
 }
 
+func TestCreateRemoteSecretFromExec(t *testing.T) {
+	g := NewWithT(t)
+
+	fakeClusterName := "fake-clusterName-0"
+	execConfig := &api.ExecConfig{
+		Command:    "aws-iam-authenticator",
+		Args:       []string{"token", "-i", fakeClusterName},
+		Env:        []api.ExecEnvVar{{Name: "AWS_PROFILE", Value: "prod"}},
+		APIVersion: defaultExecAPIVersion,
+	}
+	cluster := &api.Cluster{
+		Server:                   "https://private.example.com",
+		ProxyURL:                 "https://proxy.example.com:3128",
+		TLSServerName:            "api.example.com",
+		CertificateAuthorityData: []byte("customCA"),
+	}
+
+	got, err := createRemoteSecretFromExec(makeSecret("", "caData", ""), cluster, fakeClusterName,
+		remoteSecretNameFromClusterName(fakeClusterName), execConfig)
+	g.Expect(err).Should(Succeed())
+	kubeconfig, err := clientcmd.Load(got.Data[fakeClusterName])
+	g.Expect(err).Should(Succeed())
+	gotCluster := kubeconfig.Clusters[fakeClusterName]
+	g.Expect(gotCluster.Server).To(Equal(cluster.Server))
+	g.Expect(gotCluster.ProxyURL).To(Equal(cluster.ProxyURL))
+	g.Expect(gotCluster.TLSServerName).To(Equal(cluster.TLSServerName))
+	g.Expect(gotCluster.CertificateAuthorityData).To(Equal([]byte("customCA")))
+	gotExec := kubeconfig.AuthInfos[fakeClusterName].Exec
+	g.Expect(gotExec.Command).To(Equal(execConfig.Command))
+	g.Expect(gotExec.Args).To(Equal(execConfig.Args))
+	g.Expect(gotExec.Env).To(Equal(execConfig.Env))
+	g.Expect(gotExec.APIVersion).To(Equal(execConfig.APIVersion))
+	g.Expect(kubeconfig.AuthInfos[fakeClusterName].Token).To(BeEmpty())
+
+	// The certificate authority of the service account is used unless the cluster sets its own.
+	got, err = createRemoteSecretFromExec(makeSecret("", "caData", ""), &api.Cluster{Server: cluster.Server},
+		fakeClusterName, remoteSecretNameFromClusterName(fakeClusterName), execConfig)
+	g.Expect(err).Should(Succeed())
+	kubeconfig, err = clientcmd.Load(got.Data[fakeClusterName])
+	g.Expect(err).Should(Succeed())
+	g.Expect(kubeconfig.Clusters[fakeClusterName].CertificateAuthorityData).To(Equal([]byte("caData")))
+}
+
 func TestRemoteSecretOptions(t *testing.T) {
 	g := NewWithT(t)
 
@@ -780,4 +825,26 @@ func TestRemoteSecretOptions(t *testing.T) {
 		"?-invalid-name",
 	})).Should(Succeed())
 	g.Expect(o.prepare(flags)).Should(Not(Succeed()))
+
+	for _, c := range []struct {
+		args    []string
+		wantErr bool
+	}{
+		{args: []string{"--proxy-url", "https://proxy.example.com:3128"}},
+		{args: []string{"--proxy-url", "socks5://proxy.example.com:1080"}},
+		{args: []string{"--proxy-url", "ftp://proxy.example.com"}, wantErr: true},
+		{args: []string{"--proxy-url", "proxy.example.com"}, wantErr: true},
+		{args: []string{"--auth-type", "exec", "--auth-exec-command", "aws-iam-authenticator"}},
+		{args: []string{"--auth-type", "exec"}, wantErr: true},
+	} {
+		o = RemoteSecretOptions{}
+		flags = pflag.NewFlagSet("test", pflag.ContinueOnError)
+		o.addFlags(flags)
+		g.Expect(flags.Parse(c.args)).Should(Succeed())
+		if c.wantErr {
+			g.Expect(o.prepare(flags)).Should(Not(Succeed()), "%v", c.args)
+		} else {
+			g.Expect(o.prepare(flags)).Should(Succeed(), "%v", c.args)
+		}
+	}
 }
@@ -0,0 +1,368 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package topology builds a graph of the mesh from the view of the control plane: the namespaces, services,
+// gateways, ServiceEntries and clusters, and the links between them.
+package topology
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+// NodeKind is the kind of a node of the graph.
+type NodeKind string
+
+const (
+	NamespaceNode    NodeKind = "namespace"
+	ServiceNode      NodeKind = "service"
+	GatewayNode      NodeKind = "gateway"
+	ServiceEntryNode NodeKind = "serviceentry"
+	ClusterNode      NodeKind = "cluster"
+	// HostNode is a host routed to by a VirtualService, which is not a service of the registries.
+	HostNode NodeKind = "host"
+)
+
+// EdgeKind is the kind of an edge of the graph.
+type EdgeKind string
+
+const (
+	// Contains links a namespace to the services, gateways and ServiceEntries of the namespace.
+	Contains EdgeKind = "contains"
+	// Routes links a gateway to the services and hosts its VirtualServices route to.
+	Routes EdgeKind = "routes"
+	// Defines links a ServiceEntry to the services it defines.
+	Defines EdgeKind = "defines"
+	// RunsIn links a service to the clusters its endpoints run in.
+	RunsIn EdgeKind = "runs-in"
+	// CrossCluster links two clusters having endpoints of the same services.
+	CrossCluster EdgeKind = "cross-cluster"
+)
+
+// Node is a node of the graph.
+type Node struct {
+	ID         string            `json:"id"`
+	Kind       NodeKind          `json:"kind"`
+	Name       string            `json:"name"`
+	Namespace  string            `json:"namespace,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// Edge is a directed edge of the graph.
+type Edge struct {
+	From  string   `json:"from"`
+	To    string   `json:"to"`
+	Kind  EdgeKind `json:"kind"`
+	Label string   `json:"label,omitempty"`
+}
+
+// Graph is the topology of the mesh. The nodes and edges are sorted, so that graphs can be diffed.
+type Graph struct {
+	Nodes []*Node `json:"nodes"`
+	Edges []*Edge `json:"edges"`
+}
+
+// Service is a service of the registries, as returned by /debug/registryz.
+type Service struct {
+	Hostname   string `json:"hostname"`
+	Address    string `json:"address,omitempty"`
+	Attributes struct {
+		ServiceRegistry string
+		Name            string
+		Namespace       string
+	}
+	MeshExternal bool
+}
+
+// Endpoints is the number of endpoints of a service in a cluster.
+type Endpoints struct {
+	Hostname  string
+	Namespace string
+	Cluster   string
+	Count     int
+}
+
+type builder struct {
+	nodes map[string]*Node
+	edges map[string]*Edge
+}
+
+func (b *builder) node(kind NodeKind, namespace, name string) *Node {
+	id := string(kind) + "/" + name
+	if namespace != "" {
+		id = string(kind) + "/" + namespace + "/" + name
+	}
+	if n, f := b.nodes[id]; f {
+		return n
+	}
+	n := &Node{ID: id, Kind: kind, Name: name, Namespace: namespace, Attributes: map[string]string{}}
+	b.nodes[id] = n
+	if namespace != "" && kind != NamespaceNode {
+		b.edge(b.node(NamespaceNode, "", namespace), n, Contains, "")
+	}
+	return n
+}
+
+func (b *builder) edge(from, to *Node, kind EdgeKind, label string) {
+	key := from.ID + "|" + to.ID + "|" + string(kind)
+	if e, f := b.edges[key]; f {
+		if label != "" && !containsLabel(e.Label, label) {
+			e.Label += ", " + label
+		}
+		return
+	}
+	b.edges[key] = &Edge{From: from.ID, To: to.ID, Kind: kind, Label: label}
+}
+
+func containsLabel(labels, label string) bool {
+	for _, l := range strings.Split(labels, ", ") {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// Build builds the graph of the services, the Gateway, VirtualService and ServiceEntry configs, and the endpoints
+// of the services in each cluster.
+func Build(services []Service, configs []config.Config, endpoints []Endpoints) *Graph {
+	b := &builder{nodes: map[string]*Node{}, edges: map[string]*Edge{}}
+
+	// The services, indexed by hostname, to resolve the hosts of the configs.
+	byHost := map[string][]*Node{}
+	for _, svc := range services {
+		if svc.Hostname == "" {
+			continue
+		}
+		n := b.node(ServiceNode, svc.Attributes.Namespace, svc.Hostname)
+		if svc.Attributes.ServiceRegistry != "" {
+			n.Attributes["registry"] = svc.Attributes.ServiceRegistry
+		}
+		if svc.Address != "" && svc.Address != "0.0.0.0" {
+			n.Attributes["address"] = svc.Address
+		}
+		if svc.MeshExternal {
+			n.Attributes["meshExternal"] = "true"
+		}
+		byHost[svc.Hostname] = append(byHost[svc.Hostname], n)
+	}
+
+	for _, cfg := range configs {
+		switch cfg.GroupVersionKind {
+		case gvk.Gateway:
+			gw := b.node(GatewayNode, cfg.Namespace, cfg.Name)
+			var hosts []string
+			for _, s := range cfg.Spec.(*networking.Gateway).Servers {
+				hosts = append(hosts, s.Hosts...)
+			}
+			if len(hosts) > 0 {
+				gw.Attributes["hosts"] = strings.Join(hosts, ",")
+			}
+		case gvk.ServiceEntry:
+			se := b.node(ServiceEntryNode, cfg.Namespace, cfg.Name)
+			for _, h := range cfg.Spec.(*networking.ServiceEntry).Hosts {
+				for _, svc := range byHost[h] {
+					if svc.Namespace == cfg.Namespace {
+						b.edge(se, svc, Defines, "")
+					}
+				}
+			}
+		}
+	}
+	// The gateways of all the configs are known, to link the VirtualServices to them.
+	for _, cfg := range configs {
+		if cfg.GroupVersionKind != gvk.VirtualService {
+			continue
+		}
+		vs := cfg.Spec.(*networking.VirtualService)
+		for _, gwName := range vs.Gateways {
+			if gwName == "mesh" {
+				continue
+			}
+			ns, name := cfg.Namespace, gwName
+			if i := strings.Index(gwName, "/"); i >= 0 {
+				ns, name = gwName[:i], gwName[i+1:]
+			}
+			gw, f := b.nodes[string(GatewayNode)+"/"+ns+"/"+name]
+			if !f {
+				continue
+			}
+			for _, h := range destinationHosts(vs) {
+				fqdn := string(model.ResolveShortnameToFQDN(h, cfg.Meta))
+				targets := byHost[fqdn]
+				if len(targets) == 0 {
+					targets = []*Node{b.node(HostNode, "", fqdn)}
+				}
+				for _, t := range targets {
+					b.edge(gw, t, Routes, cfg.Namespace+"/"+cfg.Name)
+				}
+			}
+		}
+	}
+
+	// The clusters of each service, and the clusters sharing services.
+	clustersByService := map[string]map[string]struct{}{}
+	for _, ep := range endpoints {
+		if ep.Count == 0 {
+			continue
+		}
+		svc := b.node(ServiceNode, ep.Namespace, ep.Hostname)
+		cluster := b.node(ClusterNode, "", ep.Cluster)
+		b.edge(svc, cluster, RunsIn, fmt.Sprintf("%d endpoints", ep.Count))
+		if clustersByService[svc.ID] == nil {
+			clustersByService[svc.ID] = map[string]struct{}{}
+		}
+		clustersByService[svc.ID][cluster.ID] = struct{}{}
+	}
+	shared := map[[2]string]int{}
+	for _, clusters := range clustersByService {
+		ids := make([]string, 0, len(clusters))
+		for id := range clusters {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for i := range ids {
+			for j := i + 1; j < len(ids); j++ {
+				shared[[2]string{ids[i], ids[j]}]++
+			}
+		}
+	}
+	for pair, count := range shared {
+		b.edge(b.nodes[pair[0]], b.nodes[pair[1]], CrossCluster, fmt.Sprintf("%d shared services", count))
+	}
+
+	g := &Graph{}
+	for _, n := range b.nodes {
+		if len(n.Attributes) == 0 {
+			n.Attributes = nil
+		}
+		g.Nodes = append(g.Nodes, n)
+	}
+	for _, e := range b.edges {
+		g.Edges = append(g.Edges, e)
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool {
+		return g.Nodes[i].ID < g.Nodes[j].ID
+	})
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From != g.Edges[j].From {
+			return g.Edges[i].From < g.Edges[j].From
+		}
+		if g.Edges[i].To != g.Edges[j].To {
+			return g.Edges[i].To < g.Edges[j].To
+		}
+		return g.Edges[i].Kind < g.Edges[j].Kind
+	})
+	return g
+}
+
+// destinationHosts returns the hosts of the destinations of the routes of the VirtualService.
+func destinationHosts(vs *networking.VirtualService) []string {
+	var hosts []string
+	for _, r := range vs.Http {
+		for _, d := range r.Route {
+			hosts = append(hosts, d.GetDestination().GetHost())
+		}
+		if r.Mirror != nil {
+			hosts = append(hosts, r.Mirror.Host)
+		}
+	}
+	for _, r := range vs.Tcp {
+		for _, d := range r.Route {
+			hosts = append(hosts, d.GetDestination().GetHost())
+		}
+	}
+	for _, r := range vs.Tls {
+		for _, d := range r.Route {
+			hosts = append(hosts, d.GetDestination().GetHost())
+		}
+	}
+	return hosts
+}
+
+// WriteJSON writes the graph as JSON.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	out, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(out))
+	return err
+}
+
+var dotShapes = map[NodeKind]string{
+	ServiceNode:      "ellipse",
+	GatewayNode:      "house",
+	ServiceEntryNode: "note",
+	ClusterNode:      "box3d",
+	HostNode:         "plaintext",
+}
+
+// WriteDOT writes the graph in the DOT language of Graphviz. The namespaces are drawn as subgraphs containing their
+// nodes, rather than as nodes.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("digraph mesh {\n  rankdir=LR;\n")
+	byNamespace := map[string][]*Node{}
+	var namespaces []string
+	for _, n := range g.Nodes {
+		if n.Kind == NamespaceNode {
+			namespaces = append(namespaces, n.Name)
+			continue
+		}
+		byNamespace[n.Namespace] = append(byNamespace[n.Namespace], n)
+	}
+	writeNode := func(indent string, n *Node) {
+		fmt.Fprintf(&sb, "%s%q [label=%q, shape=%s];\n", indent, n.ID, dotLabel(n), dotShapes[n.Kind])
+	}
+	for _, n := range byNamespace[""] {
+		writeNode("  ", n)
+	}
+	for _, ns := range namespaces {
+		fmt.Fprintf(&sb, "  subgraph %q {\n    label=%q;\n", "cluster_"+ns, ns)
+		for _, n := range byNamespace[ns] {
+			writeNode("    ", n)
+		}
+		sb.WriteString("  }\n")
+	}
+	for _, e := range g.Edges {
+		if e.Kind == Contains {
+			continue
+		}
+		label := string(e.Kind)
+		if e.Label != "" {
+			label += ": " + e.Label
+		}
+		fmt.Fprintf(&sb, "  %q -> %q [label=%q];\n", e.From, e.To, label)
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func dotLabel(n *Node) string {
+	label := n.Name
+	if n.Kind == GatewayNode || n.Kind == ServiceEntryNode {
+		label = string(n.Kind) + "\n" + n.Name
+	}
+	return label
+}
@@ -0,0 +1,121 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topology
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+func service(hostname, namespace, registry string) Service {
+	s := Service{Hostname: hostname}
+	s.Attributes.Namespace = namespace
+	s.Attributes.ServiceRegistry = registry
+	return s
+}
+
+func TestBuild(t *testing.T) {
+	services := []Service{
+		service("reviews.default.svc.cluster.local", "default", "Kubernetes"),
+		service("api.example.com", "default", "External"),
+	}
+	configs := []config.Config{
+		{
+			Meta: config.Meta{GroupVersionKind: gvk.Gateway, Name: "ingress", Namespace: "istio-system"},
+			Spec: &networking.Gateway{Servers: []*networking.Server{{Hosts: []string{"*.example.com"}}}},
+		},
+		{
+			Meta: config.Meta{GroupVersionKind: gvk.VirtualService, Name: "reviews", Namespace: "default"},
+			Spec: &networking.VirtualService{
+				Gateways: []string{"istio-system/ingress", "mesh"},
+				Http: []*networking.HTTPRoute{{
+					Route: []*networking.HTTPRouteDestination{
+						{Destination: &networking.Destination{Host: "reviews"}},
+						{Destination: &networking.Destination{Host: "ratings.other.svc.cluster.local"}},
+					},
+				}},
+			},
+		},
+		{
+			Meta: config.Meta{GroupVersionKind: gvk.ServiceEntry, Name: "api", Namespace: "default"},
+			Spec: &networking.ServiceEntry{Hosts: []string{"api.example.com"}},
+		},
+	}
+	endpoints := []Endpoints{
+		{Hostname: "reviews.default.svc.cluster.local", Namespace: "default", Cluster: "west", Count: 2},
+		{Hostname: "reviews.default.svc.cluster.local", Namespace: "default", Cluster: "east", Count: 1},
+		{Hostname: "api.example.com", Namespace: "default", Cluster: "west", Count: 0},
+	}
+
+	g := Build(services, configs, endpoints)
+	var nodes []string
+	for _, n := range g.Nodes {
+		nodes = append(nodes, n.ID)
+	}
+	wantNodes := []string{
+		"cluster/east",
+		"cluster/west",
+		"gateway/istio-system/ingress",
+		"host/ratings.other.svc.cluster.local",
+		"namespace/default",
+		"namespace/istio-system",
+		"service/default/api.example.com",
+		"service/default/reviews.default.svc.cluster.local",
+		"serviceentry/default/api",
+	}
+	if !reflect.DeepEqual(nodes, wantNodes) {
+		t.Errorf("got nodes %v, want %v", nodes, wantNodes)
+	}
+	wantEdges := []*Edge{
+		{From: "cluster/east", To: "cluster/west", Kind: CrossCluster, Label: "1 shared services"},
+		{From: "gateway/istio-system/ingress", To: "host/ratings.other.svc.cluster.local", Kind: Routes, Label: "default/reviews"},
+		{From: "gateway/istio-system/ingress", To: "service/default/reviews.default.svc.cluster.local", Kind: Routes, Label: "default/reviews"},
+		{From: "namespace/default", To: "service/default/api.example.com", Kind: Contains},
+		{From: "namespace/default", To: "service/default/reviews.default.svc.cluster.local", Kind: Contains},
+		{From: "namespace/default", To: "serviceentry/default/api", Kind: Contains},
+		{From: "namespace/istio-system", To: "gateway/istio-system/ingress", Kind: Contains},
+		{From: "service/default/reviews.default.svc.cluster.local", To: "cluster/east", Kind: RunsIn, Label: "1 endpoints"},
+		{From: "service/default/reviews.default.svc.cluster.local", To: "cluster/west", Kind: RunsIn, Label: "2 endpoints"},
+		{From: "serviceentry/default/api", To: "service/default/api.example.com", Kind: Defines},
+	}
+	if !reflect.DeepEqual(g.Edges, wantEdges) {
+		for _, e := range g.Edges {
+			t.Logf("%+v", e)
+		}
+		t.Errorf("unexpected edges")
+	}
+
+	var dot bytes.Buffer
+	if err := g.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`subgraph "cluster_default" {`,
+		`"cluster/east" -> "cluster/west" [label="cross-cluster: 1 shared services"];`,
+	} {
+		if !strings.Contains(dot.String(), want) {
+			t.Errorf("DOT output %s does not contain %q", dot.String(), want)
+		}
+	}
+	if strings.Contains(dot.String(), string(Contains)) {
+		t.Errorf("DOT output %s contains the namespace edges", dot.String())
+	}
+}
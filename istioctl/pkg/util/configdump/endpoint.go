@@ -0,0 +1,106 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configdump
+
+import (
+	"fmt"
+	"sort"
+
+	adminapi "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	"github.com/golang/protobuf/ptypes"
+
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+// GetDynamicEndpointDump retrieves an endpoint dump with just dynamic endpoints in it, which Envoy only includes
+// in the config dump when requested with the include_eds parameter.
+func (w *Wrapper) GetDynamicEndpointDump(stripVersions bool) (*adminapi.EndpointsConfigDump, error) {
+	endpointDump, err := w.GetEndpointConfigDump()
+	if err != nil {
+		return nil, err
+	}
+	dec := endpointDump.GetDynamicEndpointConfigs()
+	cla := make([]*endpoint.ClusterLoadAssignment, len(dec))
+	for i := range dec {
+		cla[i] = &endpoint.ClusterLoadAssignment{}
+		dec[i].EndpointConfig.TypeUrl = v3.EndpointType
+		if err := ptypes.UnmarshalAny(dec[i].EndpointConfig, cla[i]); err != nil {
+			return nil, err
+		}
+		// The order of the endpoints is not significant, sort them so that dumps can be compared.
+		sort.Slice(cla[i].Endpoints, func(a, b int) bool {
+			return cla[i].Endpoints[a].GetLocality().String() < cla[i].Endpoints[b].GetLocality().String()
+		})
+		for _, locality := range cla[i].Endpoints {
+			sort.Slice(locality.LbEndpoints, func(a, b int) bool {
+				return lbEndpointAddress(locality.LbEndpoints[a]) < lbEndpointAddress(locality.LbEndpoints[b])
+			})
+		}
+		if dec[i].EndpointConfig, err = ptypes.MarshalAny(cla[i]); err != nil {
+			return nil, err
+		}
+	}
+	sort.Sort(endpointsByCluster{dec, cla})
+
+	if stripVersions {
+		for i := range dec {
+			dec[i].VersionInfo = ""
+			dec[i].LastUpdated = nil
+		}
+	}
+	return &adminapi.EndpointsConfigDump{DynamicEndpointConfigs: dec}, nil
+}
+
+// GetEndpointConfigDump retrieves the endpoint config dump from the ConfigDump
+func (w *Wrapper) GetEndpointConfigDump() (*adminapi.EndpointsConfigDump, error) {
+	endpointDumpAny, err := w.getSection(endpoints)
+	if err != nil {
+		return nil, err
+	}
+	endpointDump := &adminapi.EndpointsConfigDump{}
+	err = ptypes.UnmarshalAny(endpointDumpAny, endpointDump)
+	if err != nil {
+		return nil, err
+	}
+	return endpointDump, nil
+}
+
+func lbEndpointAddress(ep *endpoint.LbEndpoint) string {
+	addr := ep.GetEndpoint().GetAddress()
+	if pipe := addr.GetPipe(); pipe != nil {
+		return pipe.Path
+	}
+	return fmt.Sprintf("%s:%d", addr.GetSocketAddress().GetAddress(), addr.GetSocketAddress().GetPortValue())
+}
+
+// endpointsByCluster sorts the dynamic endpoint configs by the name of their cluster.
+type endpointsByCluster struct {
+	configs     []*adminapi.EndpointsConfigDump_DynamicEndpointConfig
+	assignments []*endpoint.ClusterLoadAssignment
+}
+
+func (e endpointsByCluster) Len() int {
+	return len(e.configs)
+}
+
+func (e endpointsByCluster) Less(i, j int) bool {
+	return e.assignments[i].ClusterName < e.assignments[j].ClusterName
+}
+
+func (e endpointsByCluster) Swap(i, j int) {
+	e.configs[i], e.configs[j] = e.configs[j], e.configs[i]
+	e.assignments[i], e.assignments[j] = e.assignments[j], e.assignments[i]
+}
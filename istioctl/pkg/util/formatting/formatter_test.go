@@ -15,11 +15,15 @@
 package formatting
 
 import (
+	"encoding/json"
+	"encoding/xml"
 	"testing"
 
 	. "github.com/onsi/gomega"
 
 	"istio.io/istio/galley/pkg/config/analysis/diag"
+	"istio.io/istio/galley/pkg/config/source/kube/rt"
+	"istio.io/istio/pkg/config/resource"
 	"istio.io/istio/pkg/url"
 )
 
@@ -151,4 +155,111 @@ func TestFormatter_PrintEmpty(t *testing.T) {
 
 	yamlOutput, _ := Print(msgs, YAMLFormat, false)
 	g.Expect(yamlOutput).To(Equal("[]\n"))
+
+	sarifOutput, _ := Print(msgs, SARIFFormat, false)
+	g.Expect(sarifOutput).To(ContainSubstring(`"results": []`))
+
+	junitOutput, _ := Print(msgs, JUnitFormat, false)
+	g.Expect(junitOutput).To(ContainSubstring(`<testsuites name="istioctl analyze" tests="1" failures="0">`))
+}
+
+func fileResource(name, filename string, line int) *resource.Instance {
+	return &resource.Instance{
+		Origin: &rt.Origin{
+			Kind:     "VirtualService",
+			FullName: resource.NewFullName("default", resource.LocalName(name)),
+			Ref:      &rt.Position{Filename: filename, Line: line},
+		},
+	}
+}
+
+func TestFormatter_PrintSARIF(t *testing.T) {
+	g := NewWithT(t)
+
+	firstMsg := diag.NewMessage(
+		diag.NewMessageType(diag.Warning, "C1", "Collapse danger: %v"),
+		fileResource("castle", "config/castle.yaml", 3),
+		"the castle is too old",
+	)
+	secondMsg := diag.NewMessage(
+		diag.NewMessageType(diag.Error, "B1", "Explosion accident: %v"),
+		diag.MockResource("SoapBubble"),
+		"the bubble is too big",
+	)
+	thirdMsg := diag.NewMessage(
+		diag.NewMessageType(diag.Warning, "C1", "Collapse danger: %v"),
+		fileResource("tower", "config/tower.yaml", 1),
+		"the tower is too old",
+	)
+	thirdMsg.Line = 12
+
+	output, err := Print(diag.Messages{firstMsg, secondMsg, thirdMsg}, SARIFFormat, false)
+	g.Expect(err).To(BeNil())
+
+	log := sarifLog{}
+	g.Expect(json.Unmarshal([]byte(output), &log)).To(Succeed())
+	g.Expect(log.Version).To(Equal("2.1.0"))
+	g.Expect(log.Runs).To(HaveLen(1))
+
+	rules := log.Runs[0].Tool.Driver.Rules
+	g.Expect(rules).To(HaveLen(2))
+	g.Expect(rules[0].ID).To(Equal("B1"))
+	g.Expect(rules[0].HelpURI).To(Equal(url.ConfigAnalysis + "/b1/"))
+	g.Expect(rules[0].DefaultConfiguration.Level).To(Equal("error"))
+	g.Expect(rules[1].ID).To(Equal("C1"))
+	g.Expect(rules[1].DefaultConfiguration.Level).To(Equal("warning"))
+
+	results := log.Runs[0].Results
+	g.Expect(results).To(HaveLen(3))
+	g.Expect(results[0].RuleID).To(Equal("C1"))
+	g.Expect(results[0].RuleIndex).To(Equal(1))
+	g.Expect(results[0].Message.Text).To(Equal("Collapse danger: the castle is too old"))
+	g.Expect(results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI).To(Equal("config/castle.yaml"))
+	g.Expect(results[0].Locations[0].PhysicalLocation.Region.StartLine).To(Equal(3))
+	g.Expect(results[0].Locations[0].LogicalLocations[0].FullyQualifiedName).To(Equal("VirtualService castle.default"))
+
+	// Resources not read from files have no physical location.
+	g.Expect(results[1].RuleIndex).To(Equal(0))
+	g.Expect(results[1].Level).To(Equal("error"))
+	g.Expect(results[1].Locations[0].PhysicalLocation).To(BeNil())
+	g.Expect(results[1].Locations[0].LogicalLocations[0].FullyQualifiedName).To(Equal("SoapBubble"))
+
+	// The line of the message takes precedence over the line of the resource.
+	g.Expect(results[2].Locations[0].PhysicalLocation.Region.StartLine).To(Equal(12))
+}
+
+func TestFormatter_PrintJUnit(t *testing.T) {
+	g := NewWithT(t)
+
+	firstMsg := diag.NewMessage(
+		diag.NewMessageType(diag.Error, "B1", "Explosion accident: %v"),
+		fileResource("bubble", "bubble.yaml", 5),
+		"the bubble is too big",
+	)
+	secondMsg := diag.NewMessage(
+		diag.NewMessageType(diag.Info, "I1", "Calm: %v"),
+		diag.MockResource("Lake"),
+		"the lake is quiet",
+	)
+
+	output, err := Print(diag.Messages{firstMsg, secondMsg}, JUnitFormat, false)
+	g.Expect(err).To(BeNil())
+	g.Expect(output).To(HavePrefix(xml.Header))
+
+	suites := junitTestSuites{}
+	g.Expect(xml.Unmarshal([]byte(output), &suites)).To(Succeed())
+	g.Expect(suites.Tests).To(Equal(2))
+	g.Expect(suites.Failures).To(Equal(1))
+	g.Expect(suites.Suites).To(HaveLen(1))
+
+	cases := suites.Suites[0].TestCases
+	g.Expect(cases).To(HaveLen(2))
+	g.Expect(cases[0].Classname).To(Equal("B1"))
+	g.Expect(cases[0].Name).To(Equal("VirtualService bubble.default"))
+	g.Expect(cases[0].File).To(Equal("bubble.yaml"))
+	g.Expect(cases[0].Line).To(Equal(5))
+	g.Expect(cases[0].Failure.Message).To(Equal("Explosion accident: the bubble is too big"))
+	g.Expect(cases[0].Failure.Type).To(Equal("Error"))
+	g.Expect(cases[1].Failure).To(BeNil())
+	g.Expect(cases[1].SystemOut).To(Equal("Calm: the lake is quiet"))
 }
@@ -0,0 +1,93 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formatting
+
+import (
+	"encoding/xml"
+	"fmt"
+
+	"istio.io/istio/galley/pkg/config/analysis/diag"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Classname string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Line      int           `xml:"line,attr,omitempty"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// printJUnit prints the messages as a JUnit XML report, with a test case per message. Error and warning messages are
+// reported as failures, info messages as passing test cases. A single passing test case is reported if there are no
+// messages, so that CI test reporting shows the analysis ran.
+func printJUnit(ms diag.Messages) (string, error) {
+	suite := junitTestSuite{Name: "istioctl analyze"}
+	for _, m := range ms {
+		msg := fmt.Sprintf(m.Type.Template(), m.Parameters...)
+		tc := junitTestCase{
+			Classname: m.Type.Code(),
+			Name:      m.Type.Code(),
+		}
+		if m.Resource != nil {
+			tc.Name = m.Resource.Origin.FriendlyName()
+		}
+		tc.File, tc.Line = messagePosition(m)
+		if m.Type.Level() == diag.Info {
+			tc.SystemOut = msg
+		} else {
+			tc.Failure = &junitFailure{
+				Message: msg,
+				Type:    m.Type.Level().String(),
+				Text:    fmt.Sprintf("%v [%v]%s %s\nSee %s", m.Type.Level(), m.Type.Code(), m.Origin(), msg, documentationURL(m.Type.Code())),
+			}
+			suite.Failures++
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	if len(suite.TestCases) == 0 {
+		suite.TestCases = append(suite.TestCases, junitTestCase{Classname: "analyze", Name: "no validation issues found"})
+	}
+	suite.Tests = len(suite.TestCases)
+
+	out, err := xml.MarshalIndent(junitTestSuites{
+		Name:     suite.Name,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Suites:   []junitTestSuite{suite},
+	}, "", "\t")
+	return xml.Header + string(out), err
+}
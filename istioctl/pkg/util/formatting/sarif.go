@@ -0,0 +1,187 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formatting
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"istio.io/istio/galley/pkg/config/analysis/diag"
+	"istio.io/istio/galley/pkg/config/source/kube/rt"
+	"istio.io/istio/pkg/url"
+)
+
+// sarifSchema is the schema of the SARIF 2.1.0 logs, as used by GitHub code scanning.
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	HelpURI              string             `json:"helpUri"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// sarifLevels maps the message levels to the SARIF result levels.
+var sarifLevels = map[diag.Level]string{
+	diag.Error:   "error",
+	diag.Warning: "warning",
+	diag.Info:    "note",
+}
+
+// printSARIF prints the messages as a SARIF log, with a rule per message code.
+func printSARIF(ms diag.Messages) (string, error) {
+	ruleIndexes := map[string]int{}
+	var codes []string
+	types := map[string]*diag.MessageType{}
+	for _, m := range ms {
+		if _, f := types[m.Type.Code()]; !f {
+			types[m.Type.Code()] = m.Type
+			codes = append(codes, m.Type.Code())
+		}
+	}
+	sort.Strings(codes)
+	rules := make([]sarifRule, 0, len(codes))
+	for i, code := range codes {
+		ruleIndexes[code] = i
+		rules = append(rules, sarifRule{
+			ID:                   code,
+			ShortDescription:     sarifMessage{Text: types[code].Template()},
+			HelpURI:              documentationURL(code),
+			DefaultConfiguration: sarifConfiguration{Level: sarifLevels[types[code].Level()]},
+		})
+	}
+
+	results := make([]sarifResult, 0, len(ms))
+	for _, m := range ms {
+		r := sarifResult{
+			RuleID:    m.Type.Code(),
+			RuleIndex: ruleIndexes[m.Type.Code()],
+			Level:     sarifLevels[m.Type.Level()],
+			Message:   sarifMessage{Text: fmt.Sprintf(m.Type.Template(), m.Parameters...)},
+		}
+		if m.Resource != nil {
+			loc := sarifLocation{LogicalLocations: []sarifLogicalLocation{{
+				FullyQualifiedName: m.Resource.Origin.FriendlyName(),
+				Kind:               "resource",
+			}}}
+			if file, line := messagePosition(m); file != "" {
+				loc.PhysicalLocation = &sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(file)}}
+				if line > 0 {
+					loc.PhysicalLocation.Region = &sarifRegion{StartLine: line}
+				}
+			}
+			r.Locations = []sarifLocation{loc}
+		}
+		results = append(results, r)
+	}
+
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "istioctl analyze",
+				InformationURI: url.ConfigAnalysis,
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+	out, err := json.MarshalIndent(log, "", "\t")
+	return string(out), err
+}
+
+// messagePosition returns the file and line of the resource of the message, if it was read from a file.
+func messagePosition(m diag.Message) (string, int) {
+	if m.Resource == nil || m.Resource.Origin == nil {
+		return "", 0
+	}
+	pos, ok := m.Resource.Origin.Reference().(*rt.Position)
+	if !ok || pos == nil || pos.Filename == "" {
+		return "", 0
+	}
+	line := pos.Line
+	if m.Line != 0 {
+		line = m.Line
+	}
+	return pos.Filename, line
+}
+
+func documentationURL(code string) string {
+	return fmt.Sprintf("%s/%s/", url.ConfigAnalysis, strings.ToLower(code))
+}
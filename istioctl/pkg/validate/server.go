@@ -0,0 +1,136 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+
+	"istio.io/istio/pkg/config/validation"
+)
+
+// serverFieldManager is the field manager of the dry-run applies of the server-side validation.
+const serverFieldManager = "istioctl-validate"
+
+// warningCollector collects the warnings returned by the API server, including the warnings of the validating
+// webhooks.
+type warningCollector struct {
+	mu       sync.Mutex
+	warnings []string
+}
+
+var _ rest.WarningHandler = &warningCollector{}
+
+func (w *warningCollector) HandleWarningHeader(code int, _ string, text string) {
+	if code != 299 || text == "" {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.warnings = append(w.warnings, text)
+}
+
+// take returns the warnings collected since the last call.
+func (w *warningCollector) take() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	out := w.warnings
+	w.warnings = nil
+	return out
+}
+
+// serverValidator validates the resources by applying them in dry-run to the API server: they are checked by the
+// schemas and the validating webhooks of the cluster, exactly as they would be when applied, without being persisted.
+type serverValidator struct {
+	client    dynamic.Interface
+	mapper    meta.RESTMapper
+	warnings  *warningCollector
+	namespace string
+}
+
+// newServerValidator creates a validator for the cluster of the configuration. Namespaced resources without a
+// namespace are validated in the given namespace.
+func newServerValidator(restConfig *rest.Config, namespace string) (*serverValidator, error) {
+	warnings := &warningCollector{}
+	restConfig = rest.CopyConfig(restConfig)
+	restConfig.WarningHandler = warnings
+	client, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &serverValidator{
+		client:    client,
+		mapper:    restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient)),
+		warnings:  warnings,
+		namespace: namespace,
+	}, nil
+}
+
+func (v *serverValidator) validateResource(un *unstructured.Unstructured) (validation.Warning, error) {
+	gvk := un.GroupVersionKind()
+	mapping, err := v.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, fmt.Errorf("%v is not served by the cluster: %v", gvk, err)
+	}
+	var resource dynamic.ResourceInterface = v.client.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		namespace := un.GetNamespace()
+		if namespace == "" {
+			namespace = v.namespace
+		}
+		resource = v.client.Resource(mapping.Resource).Namespace(namespace)
+	}
+
+	// Resources with a name are applied, to be validated whether they exist or not; resources with a generated name
+	// can only be created.
+	if un.GetName() != "" {
+		var data []byte
+		if data, err = json.Marshal(un.Object); err != nil {
+			return nil, err
+		}
+		force := true
+		_, err = resource.Patch(context.TODO(), un.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
+			DryRun:       []string{metav1.DryRunAll},
+			FieldManager: serverFieldManager,
+			Force:        &force,
+		})
+	} else {
+		_, err = resource.Create(context.TODO(), un, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
+	}
+
+	var warning validation.Warning
+	for _, w := range v.warnings.take() {
+		warning = multierror.Append(warning, errors.New(w))
+	}
+	return warning, err
+}
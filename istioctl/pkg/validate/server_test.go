@@ -0,0 +1,77 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+func TestWarningCollector(t *testing.T) {
+	w := &warningCollector{}
+	w.HandleWarningHeader(299, "istiod", "first")
+	w.HandleWarningHeader(199, "istiod", "ignored")
+	w.HandleWarningHeader(299, "istiod", "")
+	w.HandleWarningHeader(299, "istiod", "second")
+	if got := w.take(); len(got) != 2 || got[0] != "first" || got[1] != "second" {
+		t.Errorf("got warnings %v, want [first second]", got)
+	}
+	if got := w.take(); len(got) != 0 {
+		t.Errorf("got warnings %v after take, want none", got)
+	}
+}
+
+func TestServerValidateResource(t *testing.T) {
+	gv := schema.GroupVersion{Group: "networking.istio.io", Version: "v1alpha3"}
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{gv})
+	mapper.Add(gv.WithKind("VirtualService"), meta.RESTScopeNamespace)
+	v := &serverValidator{
+		client:    fake.NewSimpleDynamicClient(runtime.NewScheme()),
+		mapper:    mapper,
+		warnings:  &warningCollector{},
+		namespace: "default",
+	}
+
+	vs := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "networking.istio.io/v1alpha3",
+		"kind":       "VirtualService",
+		"metadata":   map[string]interface{}{"generateName": "reviews-"},
+		"spec":       map[string]interface{}{"hosts": []interface{}{"reviews"}},
+	}}
+	// Warnings returned by the API server during the request are reported with the resource.
+	v.warnings.HandleWarningHeader(299, "", "deprecated field")
+	warning, err := v.validateResource(vs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if warning == nil || !strings.Contains(warning.Error(), "deprecated field") {
+		t.Errorf("got warning %v, want the warning of the API server", warning)
+	}
+
+	unknown := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "networking.istio.io/v1alpha3",
+		"kind":       "Unknown",
+		"metadata":   map[string]interface{}{"name": "unknown"},
+	}}
+	if _, err := v.validateResource(unknown); err == nil || !strings.Contains(err.Error(), "is not served by the cluster") {
+		t.Errorf("got error %v, want an unknown kind error", err)
+	}
+}
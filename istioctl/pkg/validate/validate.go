@@ -28,6 +28,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
 
 	operator_istio "istio.io/istio/operator/pkg/apis/istio"
 	"istio.io/istio/operator/pkg/name"
@@ -63,7 +64,10 @@ Example resource specifications include:
 	serviceProtocolUDP = "UDP"
 )
 
-type validator struct{}
+type validator struct {
+	// server, if set, validates the resources with the API server instead of the client-side schemas.
+	server *serverValidator
+}
 
 func checkFields(un *unstructured.Unstructured) error {
 	var errs error
@@ -241,7 +245,12 @@ func (v *validator) validateFile(istioNamespace *string, reader io.Reader, write
 		}
 		out := transformInterfaceMap(raw)
 		un := unstructured.Unstructured{Object: out}
-		warning, err := v.validateResource(*istioNamespace, &un, writer)
+		var warning validation.Warning
+		if v.server != nil {
+			warning, err = v.server.validateResource(&un)
+		} else {
+			warning, err = v.validateResource(*istioNamespace, &un, writer)
+		}
 		if err != nil {
 			errs = multierror.Append(errs, multierror.Prefix(err, fmt.Sprintf("%s/%s/%s:",
 				un.GetKind(), un.GetNamespace(), un.GetName())))
@@ -253,12 +262,12 @@ func (v *validator) validateFile(istioNamespace *string, reader io.Reader, write
 	}
 }
 
-func validateFiles(istioNamespace *string, filenames []string, writer io.Writer) error {
+func validateFiles(istioNamespace *string, filenames []string, server *serverValidator, writer io.Writer) error {
 	if len(filenames) == 0 {
 		return errMissingFilename
 	}
 
-	v := &validator{}
+	v := &validator{server: server}
 
 	var errs, err error
 	var reader io.Reader
@@ -314,10 +323,12 @@ func validateFiles(istioNamespace *string, filenames []string, writer io.Writer)
 	return nil
 }
 
-// NewValidateCommand creates a new command for validating Istio k8s resources.
-func NewValidateCommand(istioNamespace *string) *cobra.Command {
+// NewValidateCommand creates a new command for validating Istio k8s resources. restConfig returns the
+// configuration of the cluster and the default namespace used by the server-side validation.
+func NewValidateCommand(istioNamespace *string, restConfig func() (*rest.Config, string, error)) *cobra.Command {
 	var filenames []string
 	var referential bool
+	var serverSide bool
 
 	c := &cobra.Command{
 		Use:     "validate -f FILENAME [options]",
@@ -335,18 +346,38 @@ func NewValidateCommand(istioNamespace *string) *cobra.Command {
   # Validate current services under 'default' namespace within the cluster
   kubectl get services -o yaml | istioctl validate -f -
 
+  # Validate bookinfo-gateway.yaml with the schemas and validating webhooks of the cluster, in dry-run
+  istioctl validate --server-side -f samples/bookinfo/networking/bookinfo-gateway.yaml
+
   # Also see the related command 'istioctl analyze'
   istioctl analyze samples/bookinfo/networking/bookinfo-gateway.yaml
 `,
 		Args: cobra.NoArgs,
 		RunE: func(c *cobra.Command, _ []string) error {
-			return validateFiles(istioNamespace, filenames, c.OutOrStderr())
+			var server *serverValidator
+			if serverSide {
+				if restConfig == nil {
+					return errors.New("server-side validation is not supported")
+				}
+				config, namespace, err := restConfig()
+				if err != nil {
+					return fmt.Errorf("failed to create the Kubernetes client: %v", err)
+				}
+				if server, err = newServerValidator(config, namespace); err != nil {
+					return fmt.Errorf("failed to create the Kubernetes client: %v", err)
+				}
+			}
+			return validateFiles(istioNamespace, filenames, server, c.OutOrStderr())
 		},
 	}
 
 	flags := c.PersistentFlags()
 	flags.StringSliceVarP(&filenames, "filename", "f", nil, "Names of files to validate")
 	flags.BoolVarP(&referential, "referential", "x", true, "Enable structural validation for policy and telemetry")
+	flags.BoolVar(&serverSide, "server-side", false,
+		"Validate the resources by applying them in dry-run to the cluster, so that they are checked by the schemas "+
+			"and validating webhooks of the running control plane instead of the schemas of istioctl. "+
+			"Warnings and errors of all the resources are reported")
 
 	return c
 }
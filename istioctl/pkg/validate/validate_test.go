@@ -594,7 +594,7 @@ Error: 1 error occurred:
 	istioNamespace := "istio-system"
 	for i, c := range cases {
 		t.Run(fmt.Sprintf("[%v] %v", i, c.name), func(t *testing.T) {
-			validateCmd := NewValidateCommand(&istioNamespace)
+			validateCmd := NewValidateCommand(&istioNamespace, nil)
 			validateCmd.SilenceUsage = true
 			validateCmd.SetArgs(c.args)
 
@@ -23,10 +23,12 @@ import (
 	admit_v1 "k8s.io/api/admissionregistration/v1"
 	appsv1 "k8s.io/api/apps/v1"
 	v1batch "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	apimachinery_schema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/cli-runtime/pkg/resource"
 	"k8s.io/client-go/dynamic"
@@ -37,6 +39,7 @@ import (
 	operator_istio "istio.io/istio/operator/pkg/apis/istio"
 	"istio.io/istio/operator/pkg/apis/istio/v1alpha1"
 	"istio.io/istio/operator/pkg/controlplane"
+	"istio.io/istio/operator/pkg/drift"
 	"istio.io/istio/operator/pkg/translate"
 	"istio.io/istio/operator/pkg/util"
 	"istio.io/istio/operator/pkg/util/clog"
@@ -62,6 +65,8 @@ type StatusVerifier struct {
 	iop              *v1alpha1.IstioOperator
 	successMarker    string
 	failureMarker    string
+	detectDrift      bool
+	remediate        bool
 }
 
 // NewStatusVerifier creates a new instance of post-install verifier
@@ -92,6 +97,13 @@ func (v *StatusVerifier) Colorize() {
 	v.failureMarker = color.New(color.FgRed).Sprint(v.failureMarker)
 }
 
+// DetectDrift makes the verification report the resources whose live fields differ from the installation manifest.
+// If remediate is set, the missing resources are created and the drifted fields are reset instead.
+func (v *StatusVerifier) DetectDrift(remediate bool) {
+	v.detectDrift = true
+	v.remediate = remediate
+}
+
 // Verify implements Verifier interface. Here we check status of deployment
 // and jobs, count various resources for verification.
 func (v *StatusVerifier) Verify() error {
@@ -260,6 +272,11 @@ func (v *StatusVerifier) verifyPostInstall(visitor resource.Visitor, filename st
 		if namespace == "" {
 			namespace = v.istioNamespace
 		}
+		if v.detectDrift && kind != "IstioOperator" {
+			if err := v.verifyDrift(info, un, namespace, filename); err != nil {
+				return err
+			}
+		}
 		switch kind {
 		case "Deployment":
 			deployment := &appsv1.Deployment{}
@@ -359,6 +376,55 @@ func (v *StatusVerifier) verifyPostInstall(visitor resource.Visitor, filename st
 	return crdCount, istioDeploymentCount, err
 }
 
+// verifyDrift compares the live resource with the resource of the manifest, and remediates the drift if enabled.
+func (v *StatusVerifier) verifyDrift(info *resource.Info, rendered *unstructured.Unstructured, namespace, filename string) error {
+	helper := resource.NewHelper(info.Client, info.Mapping)
+	if !helper.NamespaceScoped {
+		namespace = ""
+	}
+	kind, name := rendered.GetKind(), rendered.GetName()
+	obj, err := helper.Get(namespace, name)
+	if errors.IsNotFound(err) {
+		if !v.remediate {
+			// The missing resource is reported by the status verification.
+			return nil
+		}
+		if _, err := helper.Create(namespace, true, rendered); err != nil {
+			v.reportFailure(kind, name, namespace, err)
+			return err
+		}
+		v.logger.LogAndPrintf("%s %s: %s.%s was missing and has been created", v.successMarker, kind, name, namespace)
+		return nil
+	} else if err != nil {
+		v.reportFailure(kind, name, namespace, err)
+		return err
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	diffs := drift.Compare(rendered, &unstructured.Unstructured{Object: content})
+	if len(diffs) == 0 {
+		return nil
+	}
+	if !v.remediate {
+		ivf := istioVerificationFailureError(filename, fmt.Errorf("%s %s.%s drifted from the manifest: %s",
+			kind, name, namespace, strings.Join(diffs, "; ")))
+		v.reportFailure(kind, name, namespace, ivf)
+		return ivf
+	}
+	patch, err := rendered.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	if _, err := helper.Patch(namespace, name, types.MergePatchType, patch, nil); err != nil {
+		v.reportFailure(kind, name, namespace, err)
+		return err
+	}
+	v.logger.LogAndPrintf("%s %s: %s.%s drift remediated: %s", v.successMarker, kind, name, namespace, strings.Join(diffs, "; "))
+	return nil
+}
+
 // Find Istio injector matching revision.  ("" matches any revision.)
 func (v *StatusVerifier) injectorFromCluster(revision string) (*admit_v1.MutatingWebhookConfiguration, error) {
 	kubeClient, err := v.createClient()
@@ -0,0 +1,332 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compare
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/ptypes/any"
+
+	"istio.io/istio/istioctl/pkg/util/configdump"
+)
+
+// The types of resources diffed by the ProxyComparator.
+const (
+	ClusterResource  = "cluster"
+	ListenerResource = "listener"
+	RouteResource    = "route"
+	EndpointResource = "endpoint"
+)
+
+// ProxyResourceTypes are the types of resources diffed by default, in order.
+var ProxyResourceTypes = []string{ClusterResource, ListenerResource, RouteResource, EndpointResource}
+
+// ProxyComparator diffs the config dumps of two proxies, matching the resources of each type by name and
+// reporting the fields whose values differ. The versions and update times of the resources are ignored, as well
+// as the order of the resources, of the endpoints and of the lists whose elements are named.
+type ProxyComparator struct {
+	a, b         *configdump.Wrapper
+	aName, bName string
+	w            io.Writer
+}
+
+// ProxyDiff is the difference between the resources of a type of two proxies.
+type ProxyDiff struct {
+	Type string `json:"type"`
+	// Error is set when the resources could not be read from one of the config dumps, e.g. when the endpoints
+	// were not included in a config dump.
+	Error     string         `json:"error,omitempty"`
+	Resources []ResourceDiff `json:"resources,omitempty"`
+}
+
+// ResourceDiff is the difference between a resource of two proxies.
+type ResourceDiff struct {
+	Name string `json:"name"`
+	// OnlyIn is the name of the proxy having the resource, when the other proxy does not have it.
+	OnlyIn string      `json:"only_in,omitempty"`
+	Fields []FieldDiff `json:"fields,omitempty"`
+}
+
+// FieldDiff is a field whose value differs between the proxies, A or B being nil when the field is not set.
+type FieldDiff struct {
+	Path string      `json:"path"`
+	A    interface{} `json:"a,omitempty"`
+	B    interface{} `json:"b,omitempty"`
+}
+
+// NewProxyComparator is a ProxyComparator constructor, taking the names and config dumps of the proxies.
+func NewProxyComparator(w io.Writer, aName string, aDump []byte, bName string, bDump []byte) (*ProxyComparator, error) {
+	a, b := &configdump.Wrapper{}, &configdump.Wrapper{}
+	if err := json.Unmarshal(aDump, a); err != nil {
+		return nil, fmt.Errorf("failed to parse the config dump of %s: %v", aName, err)
+	}
+	if err := json.Unmarshal(bDump, b); err != nil {
+		return nil, fmt.Errorf("failed to parse the config dump of %s: %v", bName, err)
+	}
+	return &ProxyComparator{a: a, b: b, aName: aName, bName: bName, w: w}, nil
+}
+
+// Diff returns the differences between the resources of the types.
+func (c *ProxyComparator) Diff(types []string) ([]ProxyDiff, error) {
+	out := make([]ProxyDiff, 0, len(types))
+	for _, t := range types {
+		diff := ProxyDiff{Type: t}
+		a, err := proxyResources(c.a, t)
+		if err == nil {
+			var b map[string]interface{}
+			if b, err = proxyResources(c.b, t); err == nil {
+				diff.Resources = c.diffResources(a, b)
+			}
+		}
+		if err != nil {
+			if _, ok := err.(unsupportedTypeError); ok {
+				return nil, err
+			}
+			diff.Error = err.Error()
+		}
+		out = append(out, diff)
+	}
+	return out, nil
+}
+
+// PrintDiff prints a summary of the differences between the resources of the types.
+func (c *ProxyComparator) PrintDiff(types []string) error {
+	diffs, err := c.Diff(types)
+	if err != nil {
+		return err
+	}
+	for _, diff := range diffs {
+		switch {
+		case diff.Error != "":
+			fmt.Fprintf(c.w, "%ss not compared: %s\n", diff.Type, diff.Error)
+			continue
+		case len(diff.Resources) == 0:
+			fmt.Fprintf(c.w, "%ss match\n", diff.Type)
+			continue
+		}
+		fmt.Fprintf(c.w, "%ss don't match:\n", diff.Type)
+		for _, r := range diff.Resources {
+			if r.OnlyIn != "" {
+				fmt.Fprintf(c.w, "  %s: only in %s\n", r.Name, r.OnlyIn)
+				continue
+			}
+			fmt.Fprintf(c.w, "  %s:\n", r.Name)
+			for _, f := range r.Fields {
+				fmt.Fprintf(c.w, "    %s: %s -> %s\n", f.Path, fieldValue(f.A), fieldValue(f.B))
+			}
+		}
+	}
+	return nil
+}
+
+// PrintDiffJSON prints the differences between the resources of the types as JSON.
+func (c *ProxyComparator) PrintDiffJSON(types []string) error {
+	diffs, err := c.Diff(types)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(diffs, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(c.w, string(out))
+	return err
+}
+
+func (c *ProxyComparator) diffResources(a, b map[string]interface{}) []ResourceDiff {
+	var out []ResourceDiff
+	for _, name := range unionKeys(a, b) {
+		ra, inA := a[name]
+		rb, inB := b[name]
+		switch {
+		case !inB:
+			out = append(out, ResourceDiff{Name: name, OnlyIn: c.aName})
+		case !inA:
+			out = append(out, ResourceDiff{Name: name, OnlyIn: c.bName})
+		default:
+			var fields []FieldDiff
+			diffFields("", ra, rb, &fields)
+			if len(fields) > 0 {
+				out = append(out, ResourceDiff{Name: name, Fields: fields})
+			}
+		}
+	}
+	return out
+}
+
+type unsupportedTypeError string
+
+func (e unsupportedTypeError) Error() string {
+	return fmt.Sprintf("unsupported resource type %q, must be one of %v", string(e), ProxyResourceTypes)
+}
+
+// proxyResources returns the dynamic resources of the type in the config dump, as JSON values keyed by name.
+func proxyResources(w *configdump.Wrapper, t string) (map[string]interface{}, error) {
+	var resources []*any.Any
+	nameField := "name"
+	switch t {
+	case ClusterResource:
+		dump, err := w.GetDynamicClusterDump(true)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range dump.DynamicActiveClusters {
+			resources = append(resources, c.Cluster)
+		}
+	case ListenerResource:
+		dump, err := w.GetDynamicListenerDump(true)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range dump.DynamicListeners {
+			resources = append(resources, l.ActiveState.Listener)
+		}
+	case RouteResource:
+		dump, err := w.GetDynamicRouteDump(true)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range dump.DynamicRouteConfigs {
+			resources = append(resources, r.RouteConfig)
+		}
+	case EndpointResource:
+		dump, err := w.GetDynamicEndpointDump(true)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range dump.DynamicEndpointConfigs {
+			resources = append(resources, e.EndpointConfig)
+		}
+		nameField = "cluster_name"
+	default:
+		return nil, unsupportedTypeError(t)
+	}
+
+	jsonm := &jsonpb.Marshaler{OrigName: true}
+	out := make(map[string]interface{}, len(resources))
+	for _, r := range resources {
+		buf := &bytes.Buffer{}
+		if err := jsonm.Marshal(buf, r); err != nil {
+			return nil, err
+		}
+		var value map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &value); err != nil {
+			return nil, err
+		}
+		name, _ := value[nameField].(string)
+		out[name] = value
+	}
+	return out, nil
+}
+
+// diffFields appends the paths of the fields whose values differ between a and b. Objects are compared field by
+// field, and lists element by element, the elements being matched by name when all of them are named.
+func diffFields(path string, a, b interface{}, out *[]FieldDiff) {
+	switch va := a.(type) {
+	case map[string]interface{}:
+		if vb, ok := b.(map[string]interface{}); ok {
+			for _, k := range unionKeys(va, vb) {
+				p := k
+				if path != "" {
+					p = path + "." + k
+				}
+				diffFields(p, va[k], vb[k], out)
+			}
+			return
+		}
+	case []interface{}:
+		if vb, ok := b.([]interface{}); ok {
+			na, okA := namedElements(va)
+			nb, okB := namedElements(vb)
+			if okA && okB {
+				for _, name := range unionKeys(na, nb) {
+					diffFields(fmt.Sprintf("%s[name=%s]", path, name), na[name], nb[name], out)
+				}
+				return
+			}
+			for i := 0; i < len(va) || i < len(vb); i++ {
+				var ea, eb interface{}
+				if i < len(va) {
+					ea = va[i]
+				}
+				if i < len(vb) {
+					eb = vb[i]
+				}
+				diffFields(path+"["+strconv.Itoa(i)+"]", ea, eb, out)
+			}
+			return
+		}
+	}
+	if !jsonEqual(a, b) {
+		*out = append(*out, FieldDiff{Path: path, A: a, B: b})
+	}
+}
+
+// namedElements returns the elements of the list keyed by name, if all of them have a distinct name.
+func namedElements(list []interface{}) (map[string]interface{}, bool) {
+	out := make(map[string]interface{}, len(list))
+	for _, e := range list {
+		obj, ok := e.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		name, ok := obj["name"].(string)
+		if !ok || name == "" {
+			return nil, false
+		}
+		if _, f := out[name]; f {
+			return nil, false
+		}
+		out[name] = e
+	}
+	return out, true
+}
+
+func jsonEqual(a, b interface{}) bool {
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return bytes.Equal(ja, jb)
+}
+
+func fieldValue(v interface{}) string {
+	if v == nil {
+		return "<unset>"
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(out)
+}
+
+func unionKeys(a, b map[string]interface{}) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, f := a[k]; !f {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,117 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compare
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	adminapi "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+func configDump(t *testing.T, version string, clusters ...*cluster.Cluster) []byte {
+	t.Helper()
+	dump := &adminapi.ClustersConfigDump{}
+	for _, c := range clusters {
+		a, err := ptypes.MarshalAny(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dump.DynamicActiveClusters = append(dump.DynamicActiveClusters,
+			&adminapi.ClustersConfigDump_DynamicCluster{VersionInfo: version, Cluster: a})
+	}
+	a, err := ptypes.MarshalAny(dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	if err := (&jsonpb.Marshaler{}).Marshal(out, &adminapi.ConfigDump{Configs: []*any.Any{a}}); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestProxyComparator(t *testing.T) {
+	newCluster := func(name string, timeout time.Duration, filters ...string) *cluster.Cluster {
+		c := &cluster.Cluster{Name: name, ConnectTimeout: ptypes.DurationProto(timeout)}
+		for _, f := range filters {
+			c.Filters = append(c.Filters, &cluster.Filter{Name: f})
+		}
+		return c
+	}
+	a := configDump(t, "1",
+		newCluster("outbound|80||a.default.svc.cluster.local", time.Second, "x", "y"),
+		newCluster("outbound|80||b.default.svc.cluster.local", time.Second))
+	b := configDump(t, "2",
+		newCluster("outbound|80||c.default.svc.cluster.local", time.Second),
+		// The filters are matched by name, so their order is ignored.
+		newCluster("outbound|80||a.default.svc.cluster.local", 5*time.Second, "y", "x"))
+
+	out := &bytes.Buffer{}
+	c, err := NewProxyComparator(out, "pod-a", a, "pod-b", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs, err := c.Diff([]string{ClusterResource, ListenerResource})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ResourceDiff{
+		{
+			Name:   "outbound|80||a.default.svc.cluster.local",
+			Fields: []FieldDiff{{Path: "connect_timeout", A: "1s", B: "5s"}},
+		},
+		{Name: "outbound|80||b.default.svc.cluster.local", OnlyIn: "pod-a"},
+		{Name: "outbound|80||c.default.svc.cluster.local", OnlyIn: "pod-b"},
+	}
+	if len(diffs) != 2 || !reflect.DeepEqual(diffs[0].Resources, want) {
+		t.Fatalf("got diffs %+v, want %+v", diffs, want)
+	}
+	if diffs[1].Error == "" {
+		t.Errorf("expected the listeners not to be compared without listeners in the config dumps")
+	}
+
+	if err := c.PrintDiff([]string{ClusterResource}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `connect_timeout: "1s" -> "5s"`) {
+		t.Errorf("expected the differing field to be printed, got %s", out.String())
+	}
+
+	if _, err := c.Diff([]string{"secret"}); err == nil {
+		t.Errorf("expected an error for an unsupported type")
+	}
+
+	// Identical config dumps of different versions match.
+	c, err = NewProxyComparator(out, "pod-a", a, "pod-c", configDump(t, "3",
+		newCluster("outbound|80||b.default.svc.cluster.local", time.Second),
+		newCluster("outbound|80||a.default.svc.cluster.local", time.Second, "y", "x")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diffs, err = c.Diff([]string{ClusterResource}); err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs[0].Resources) != 0 {
+		t.Errorf("expected no difference, got %+v", diffs[0].Resources)
+	}
+}
@@ -44,6 +44,19 @@ type SecretItem struct {
 	Destination string `json:"destination"`
 	State       string `json:"state"`
 	SecretMeta
+	// Certificates are the decoded certificates of the chain, the leaf first.
+	Certificates []CertificateMeta `json:"certificates,omitempty"`
+}
+
+// CertificateMeta holds the fields of a certificate of a chain
+type CertificateMeta struct {
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SANs         []string  `json:"sans,omitempty"`
+	SerialNumber string    `json:"serial_number"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+	IsCA         bool      `json:"is_ca"`
 }
 
 // SecretMeta holds selected fields which can be extracted from parsed x509 cert
@@ -134,6 +147,11 @@ func (s *secretItemBuilder) Build() (SecretItem, error) {
 		}
 		result.SecretMeta = meta
 		result.Valid = true
+		result.Certificates, err = certificatesFromPEM([]byte(s.data))
+		if err != nil {
+			log.Debugf("failed to parse the certificate chain of secret resource %s from source %s: %v",
+				s.name, s.source, err)
+		}
 		return result, nil
 	}
 	result.Valid = false
@@ -226,3 +244,61 @@ func secretMetaFromCert(rawCert []byte) (SecretMeta, error) {
 		Type:         certType,
 	}, nil
 }
+
+// certificatesFromPEM decodes all the certificates of the PEM data.
+func certificatesFromPEM(data []byte) ([]CertificateMeta, error) {
+	var out []CertificateMeta
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return out, nil
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return out, err
+		}
+		meta := CertificateMeta{
+			Subject:      cert.Subject.String(),
+			Issuer:       cert.Issuer.String(),
+			SerialNumber: fmt.Sprintf("%d", cert.SerialNumber),
+			NotBefore:    cert.NotBefore,
+			NotAfter:     cert.NotAfter,
+			IsCA:         cert.IsCA,
+		}
+		meta.SANs = append(meta.SANs, cert.DNSNames...)
+		for _, u := range cert.URIs {
+			meta.SANs = append(meta.SANs, u.String())
+		}
+		for _, ip := range cert.IPAddresses {
+			meta.SANs = append(meta.SANs, ip.String())
+		}
+		meta.SANs = append(meta.SANs, cert.EmailAddresses...)
+		out = append(out, meta)
+	}
+}
+
+// ExpiringCertificates returns the descriptions of the certificates of the secrets expiring within the duration
+// from now, including the expired certificates.
+func ExpiringCertificates(secrets []SecretItem, now time.Time, within time.Duration) []string {
+	var out []string
+	for _, s := range secrets {
+		for i, c := range s.Certificates {
+			if left := c.NotAfter.Sub(now); left < within {
+				out = append(out, fmt.Sprintf("certificate %d of %s (%s) %s", i, s.Name, c.Subject, expiry(left)))
+			}
+		}
+	}
+	return out
+}
+
+// expiry describes the time left before the expiry of a certificate.
+func expiry(left time.Duration) string {
+	if left <= 0 {
+		return fmt.Sprintf("expired %v ago", (-left).Round(time.Second))
+	}
+	return fmt.Sprintf("expires in %v", left.Round(time.Second))
+}
@@ -20,6 +20,7 @@ import (
 	"io"
 	"strings"
 	"text/tabwriter"
+	"time"
 )
 
 // SDSWriter takes lists of SecretItem or SecretItemDiff and prints them through supplied output writer
@@ -33,6 +34,8 @@ type Format int
 const (
 	JSON Format = iota
 	TABULAR
+	// CHAIN prints the decoded certificates of the chains of the secrets
+	CHAIN
 )
 
 // NewSDSWriter generates a new instance which conforms to SDSWriter interface
@@ -40,6 +43,7 @@ func NewSDSWriter(w io.Writer, format Format) SDSWriter {
 	return &sdsWriter{
 		w:      w,
 		output: format,
+		now:    time.Now,
 	}
 }
 
@@ -47,6 +51,8 @@ func NewSDSWriter(w io.Writer, format Format) SDSWriter {
 type sdsWriter struct {
 	w      io.Writer
 	output Format
+	// now returns the time the expiry of the certificates is computed from
+	now func() time.Time
 }
 
 // PrintSecretItems uses the user supplied output format to determine how to display the diffed secrets
@@ -57,6 +63,8 @@ func (w *sdsWriter) PrintSecretItems(secrets []SecretItem) error {
 		err = w.printSecretItemsJSON(secrets)
 	case TABULAR:
 		err = w.printSecretItemsTabular(secrets)
+	case CHAIN:
+		err = w.printSecretItemsChain(secrets)
 	}
 	return err
 }
@@ -81,6 +89,41 @@ func (w *sdsWriter) printSecretItemsTabular(secrets []SecretItem) error {
 	return tw.Flush()
 }
 
+// printSecretItemsChain prints the certificates of the chain of each secret, with the time left before their expiry
+func (w *sdsWriter) printSecretItemsChain(secrets []SecretItem) error {
+	if len(secrets) == 0 {
+		fmt.Fprintln(w.w, "No secret items to show.")
+		return nil
+	}
+	now := w.now()
+	for i, s := range secrets {
+		if i > 0 {
+			fmt.Fprintln(w.w)
+		}
+		fmt.Fprintf(w.w, "RESOURCE NAME: %s (%s)\n", s.Name, s.State)
+		if len(s.Certificates) == 0 {
+			fmt.Fprintln(w.w, "  No valid certificate.")
+			continue
+		}
+		for j, c := range s.Certificates {
+			tw := new(tabwriter.Writer).Init(w.w, 0, 5, 1, ' ', 0)
+			fmt.Fprintf(tw, "  [%d]\tSubject:\t%s\n", j, c.Subject)
+			fmt.Fprintf(tw, "  \tIssuer:\t%s\n", c.Issuer)
+			if len(c.SANs) > 0 {
+				fmt.Fprintf(tw, "  \tSANs:\t%s\n", strings.Join(c.SANs, ", "))
+			}
+			fmt.Fprintf(tw, "  \tSerial Number:\t%s\n", c.SerialNumber)
+			fmt.Fprintf(tw, "  \tCA:\t%t\n", c.IsCA)
+			fmt.Fprintf(tw, "  \tValidity:\t%s to %s (%s)\n", c.NotBefore.Format(time.RFC3339), c.NotAfter.Format(time.RFC3339),
+				expiry(c.NotAfter.Sub(now)))
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // printSecretItemsJSON prints secret in JSON format, and dumps the raw certificate data with the output
 func (w *sdsWriter) printSecretItemsJSON(secrets []SecretItem) error {
 	out, err := json.MarshalIndent(secrets, "", " ")
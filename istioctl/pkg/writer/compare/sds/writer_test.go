@@ -16,8 +16,17 @@ package sdscompare
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/url"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestSDSWriterSecretItems(t *testing.T) {
@@ -172,6 +181,81 @@ func TestSDSWriterSecretDiff(t *testing.T) {
 	}
 }
 
+// certChain returns the PEM of a workload certificate expiring at notAfter, followed by its issuing CA.
+func certChain(t *testing.T, notAfter time.Time) string {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"cluster.local"}},
+		NotBefore:             notAfter.Add(-48 * time.Hour),
+		NotAfter:              notAfter.Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spiffe, _ := url.Parse("spiffe://cluster.local/ns/default/sa/productpage")
+	leaf := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		NotBefore:    notAfter.Add(-24 * time.Hour),
+		NotAfter:     notAfter,
+		URIs:         []*url.URL{spiffe},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leaf, caTemplate, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER})) +
+		string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}))
+}
+
+func TestSDSWriterSecretChain(t *testing.T) {
+	now := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	item, err := NewSecretItemBuilder().Name("default").State("ACTIVE").Data(certChain(t, now.Add(12*time.Hour))).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(item.Certificates) != 2 || !item.Certificates[1].IsCA {
+		t.Fatalf("expected the workload and CA certificates, got %v", item.Certificates)
+	}
+
+	w := &bytes.Buffer{}
+	writer := NewSDSWriter(w, CHAIN).(*sdsWriter)
+	writer.now = func() time.Time { return now }
+	if err := writer.PrintSecretItems([]SecretItem{item}); err != nil {
+		t.Fatal(err)
+	}
+	checkOutput(t, w.String(), []string{
+		"RESOURCE NAME: default (ACTIVE)",
+		"SANs:          spiffe://cluster.local/ns/default/sa/productpage",
+		"Issuer:        O=cluster.local",
+		"2021-03-01T12:00:00Z (expires in 12h0m0s)",
+	}, nil)
+
+	if got := ExpiringCertificates([]SecretItem{item}, now, 24*time.Hour); len(got) != 1 ||
+		!strings.Contains(got[0], "certificate 0 of default") {
+		t.Errorf("expected the workload certificate to expire within 24h, got %v", got)
+	}
+	if got := ExpiringCertificates([]SecretItem{item}, now.Add(13*time.Hour), time.Hour); len(got) != 1 ||
+		!strings.Contains(got[0], "expired 1h0m0s ago") {
+		t.Errorf("expected the workload certificate to be expired, got %v", got)
+	}
+	if got := ExpiringCertificates([]SecretItem{item}, now, time.Hour); len(got) != 0 {
+		t.Errorf("expected no certificate to expire within 1h, got %v", got)
+	}
+}
+
 func checkOutput(t *testing.T, output string, expected, unexpected []string) {
 	t.Helper()
 	for _, expected := range expected {
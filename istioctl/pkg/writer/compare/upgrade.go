@@ -0,0 +1,422 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compare
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"istio.io/istio/istioctl/pkg/util/configdump"
+	"istio.io/istio/pilot/pkg/model"
+)
+
+// The kinds of behavior changing differences reported in an UpgradePlan.
+const (
+	// FindingFilterOrder is reported when the filters common to both revisions are ordered differently.
+	FindingFilterOrder = "FilterOrder"
+	// FindingDefault is reported for the fields set by one of the revisions only, typically a changed default.
+	FindingDefault = "Default"
+	// FindingValue is reported for the fields set to different values by the revisions.
+	FindingValue = "Value"
+	// FindingDeprecated is reported for the deprecated Envoy fields in use in the configuration of a revision.
+	FindingDeprecated = "Deprecated"
+	// FindingEnvoyFilter is reported for the EnvoyFilter patches applied with the current revision but not with
+	// the target revision.
+	FindingEnvoyFilter = "EnvoyFilter"
+)
+
+// upgradeResourceTypes are the types of resources compared by PlanUpgrade. The endpoints do not depend on the
+// revision generating the configuration.
+var upgradeResourceTypes = []string{ClusterResource, ListenerResource, RouteResource}
+
+// UpgradeProxy is a proxy whose configuration is compared by PlanUpgrade.
+type UpgradeProxy struct {
+	// Name is the name of the proxy, <pod>.<namespace>.
+	Name string
+	// Revision is the control plane revision the proxy is connected to.
+	Revision string
+	// IP is the address of the proxy. It is ignored in the comparison, as it differs between the proxies.
+	IP string
+	// ConfigDump is the configuration generated for the proxy by the control plane.
+	ConfigDump []byte
+	// EnvoyFilterPatches are the results of the EnvoyFilter patches for the proxy.
+	EnvoyFilterPatches []model.EnvoyFilterPatchResult
+}
+
+// Finding is a behavior changing difference between the configurations generated by two revisions.
+type Finding struct {
+	Kind     string `json:"kind"`
+	Resource string `json:"resource,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Message  string `json:"message"`
+}
+
+// UpgradePlan is the impact of an upgrade on the configuration of a type of proxy.
+type UpgradePlan struct {
+	ProxyType string    `json:"proxy_type"`
+	Current   string    `json:"current"`
+	Target    string    `json:"target"`
+	Findings  []Finding `json:"findings,omitempty"`
+}
+
+// PlanUpgrade compares the configuration generated for a proxy by the current revision with the configuration
+// generated for a proxy of the same type by the target revision, and reports the behavior changing differences.
+// The proxies should run the same workload, as the differences between workloads are reported as well.
+func PlanUpgrade(proxyType string, current, target *UpgradeProxy) (*UpgradePlan, error) {
+	c, err := NewProxyComparator(ioutil.Discard, current.Name, normalizeDump(current), target.Name, normalizeDump(target))
+	if err != nil {
+		return nil, err
+	}
+	plan := &UpgradePlan{ProxyType: proxyType, Current: current.Name, Target: target.Name}
+
+	diffs, err := c.Diff(upgradeResourceTypes)
+	if err != nil {
+		return nil, err
+	}
+	for _, diff := range diffs {
+		if diff.Error != "" {
+			return nil, fmt.Errorf("failed to compare the %ss: %s", diff.Type, diff.Error)
+		}
+		for _, r := range diff.Resources {
+			resource := diff.Type + " " + r.Name
+			if r.OnlyIn != "" {
+				revision := current.Revision
+				if r.OnlyIn == target.Name {
+					revision = target.Revision
+				}
+				plan.Findings = append(plan.Findings, Finding{Kind: FindingDefault, Resource: resource,
+					Message: fmt.Sprintf("only generated by revision %s", revision)})
+				continue
+			}
+			for _, f := range r.Fields {
+				finding := Finding{Kind: FindingValue, Resource: resource, Path: f.Path,
+					Message: fmt.Sprintf("%s -> %s", fieldValue(f.A), fieldValue(f.B))}
+				switch {
+				case f.A == nil:
+					finding.Kind = FindingDefault
+					finding.Message = fmt.Sprintf("only set by revision %s: %s", target.Revision, fieldValue(f.B))
+				case f.B == nil:
+					finding.Kind = FindingDefault
+					finding.Message = fmt.Sprintf("only set by revision %s: %s", current.Revision, fieldValue(f.A))
+				}
+				plan.Findings = append(plan.Findings, finding)
+			}
+		}
+	}
+
+	order, err := filterOrderFindings(c)
+	if err != nil {
+		return nil, err
+	}
+	plan.Findings = append(plan.Findings, order...)
+
+	for _, p := range []struct {
+		proxy *UpgradeProxy
+		dump  *configdump.Wrapper
+	}{{current, c.a}, {target, c.b}} {
+		deprecated, err := deprecatedFieldFindings(p.dump, p.proxy.Revision)
+		if err != nil {
+			return nil, err
+		}
+		plan.Findings = append(plan.Findings, deprecated...)
+	}
+
+	plan.Findings = append(plan.Findings, envoyFilterFindings(current, target)...)
+	return plan, nil
+}
+
+// Print prints the findings of the plan in a table.
+func (p *UpgradePlan) Print(writer io.Writer) {
+	fmt.Fprintf(writer, "Proxy type %s: %s -> %s\n", p.ProxyType, p.Current, p.Target)
+	if len(p.Findings) == 0 {
+		fmt.Fprintln(writer, "No behavior changing difference found.")
+		return
+	}
+	w := new(tabwriter.Writer).Init(writer, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(w, "KIND\tRESOURCE\tPATH\tDETAILS")
+	for _, f := range p.Findings {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", f.Kind, orDash(f.Resource), orDash(f.Path), f.Message)
+	}
+	_ = w.Flush()
+}
+
+// normalizeDump replaces the address of the proxy in its config dump, so that it does not differ between proxies.
+func normalizeDump(p *UpgradeProxy) []byte {
+	if p.IP == "" {
+		return p.ConfigDump
+	}
+	return bytes.ReplaceAll(p.ConfigDump, []byte(p.IP), []byte("<proxy ip>"))
+}
+
+// filterOrderFindings reports the listener and HTTP filters common to both proxies but ordered differently.
+func filterOrderFindings(c *ProxyComparator) ([]Finding, error) {
+	a, err := proxyResources(c.a, ListenerResource)
+	if err != nil {
+		return nil, err
+	}
+	b, err := proxyResources(c.b, ListenerResource)
+	if err != nil {
+		return nil, err
+	}
+	var out []Finding
+	for _, name := range unionKeys(a, b) {
+		la, okA := a[name].(map[string]interface{})
+		lb, okB := b[name].(map[string]interface{})
+		if !okA || !okB {
+			continue
+		}
+		oa, ob := filterOrders(la), filterOrders(lb)
+		paths := make([]string, 0, len(oa))
+		for p := range oa {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		for _, p := range paths {
+			fb, f := ob[p]
+			if !f {
+				continue
+			}
+			ca, cb := commonOrder(oa[p], fb), commonOrder(fb, oa[p])
+			if strings.Join(ca, ",") != strings.Join(cb, ",") {
+				out = append(out, Finding{Kind: FindingFilterOrder, Resource: ListenerResource + " " + name, Path: p,
+					Message: fmt.Sprintf("%s -> %s", strings.Join(ca, ", "), strings.Join(cb, ", "))})
+			}
+		}
+	}
+	return out, nil
+}
+
+// filterOrders returns the names of the filters of the listener, in order, keyed by the path of the filter list.
+func filterOrders(l map[string]interface{}) map[string][]string {
+	out := map[string][]string{}
+	if names := elementNames(l["listener_filters"]); len(names) > 0 {
+		out["listener_filters"] = names
+	}
+	addChain := func(path string, chain map[string]interface{}) {
+		filters, _ := chain["filters"].([]interface{})
+		if names := elementNames(filters); len(names) > 0 {
+			out[path+".filters"] = names
+		}
+		for _, f := range filters {
+			fm, _ := f.(map[string]interface{})
+			tc, _ := fm["typed_config"].(map[string]interface{})
+			if names := elementNames(tc["http_filters"]); len(names) > 0 {
+				out[fmt.Sprintf("%s.filters[name=%s].typed_config.http_filters", path, fm["name"])] = names
+			}
+		}
+	}
+	chains, _ := l["filter_chains"].([]interface{})
+	for i, fc := range chains {
+		chain, _ := fc.(map[string]interface{})
+		path := fmt.Sprintf("filter_chains[%d]", i)
+		if name, ok := chain["name"].(string); ok && name != "" {
+			path = fmt.Sprintf("filter_chains[name=%s]", name)
+		}
+		addChain(path, chain)
+	}
+	if chain, ok := l["default_filter_chain"].(map[string]interface{}); ok {
+		addChain("default_filter_chain", chain)
+	}
+	return out
+}
+
+func elementNames(list interface{}) []string {
+	elements, _ := list.([]interface{})
+	var out []string
+	for _, e := range elements {
+		if m, ok := e.(map[string]interface{}); ok {
+			if name, ok := m["name"].(string); ok {
+				out = append(out, name)
+			}
+		}
+	}
+	return out
+}
+
+// commonOrder returns the names of a also in b, in the order of a.
+func commonOrder(a, b []string) []string {
+	in := make(map[string]struct{}, len(b))
+	for _, n := range b {
+		in[n] = struct{}{}
+	}
+	var out []string
+	for _, n := range a {
+		if _, f := in[n]; f {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// deprecatedFieldFindings reports the deprecated fields set in the dynamic resources of the config dump.
+func deprecatedFieldFindings(w *configdump.Wrapper, revision string) ([]Finding, error) {
+	resources := map[string]*any.Any{}
+	clusters, err := w.GetDynamicClusterDump(false)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range clusters.DynamicActiveClusters {
+		resources[ClusterResource+" "+resourceName(c.Cluster)] = c.Cluster
+	}
+	listeners, err := w.GetDynamicListenerDump(false)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range listeners.DynamicListeners {
+		resources[ListenerResource+" "+resourceName(l.ActiveState.Listener)] = l.ActiveState.Listener
+	}
+	routes, err := w.GetDynamicRouteDump(false)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range routes.DynamicRouteConfigs {
+		resources[RouteResource+" "+resourceName(r.RouteConfig)] = r.RouteConfig
+	}
+
+	names := make([]string, 0, len(resources))
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var out []Finding
+	for _, name := range names {
+		fields := map[string]struct{}{}
+		deprecatedFields(resources[name], fields)
+		for _, f := range sortedSet(fields) {
+			out = append(out, Finding{Kind: FindingDeprecated, Resource: name, Path: f,
+				Message: fmt.Sprintf("deprecated field in use with revision %s", revision)})
+		}
+	}
+	return out, nil
+}
+
+func resourceName(a *any.Any) string {
+	m, err := ptypes.Empty(a)
+	if err != nil || ptypes.UnmarshalAny(a, m) != nil {
+		return a.TypeUrl
+	}
+	f := proto.MessageReflect(m).Descriptor().Fields().ByName("name")
+	if f == nil {
+		return a.TypeUrl
+	}
+	return proto.MessageReflect(m).Get(f).String()
+}
+
+// deprecatedFields adds the full names of the deprecated fields set in the message, including in the messages
+// embedded in Any fields of known types, to out.
+func deprecatedFields(a *any.Any, out map[string]struct{}) {
+	m, err := ptypes.Empty(a)
+	if err != nil {
+		return
+	}
+	if err := ptypes.UnmarshalAny(a, m); err != nil {
+		return
+	}
+	walkDeprecatedFields(proto.MessageReflect(m), out)
+}
+
+func walkDeprecatedFields(m protoreflect.Message, out map[string]struct{}) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if opts, ok := fd.Options().(*descriptorpb.FieldOptions); ok && opts.GetDeprecated() {
+			out[string(fd.FullName())] = struct{}{}
+		}
+		walk := func(v protoreflect.Value) {
+			if a, ok := v.Message().Interface().(*any.Any); ok {
+				deprecatedFields(a, out)
+				return
+			}
+			walkDeprecatedFields(v.Message(), out)
+		}
+		switch {
+		case fd.IsList() && fd.Kind() == protoreflect.MessageKind:
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				walk(list.Get(i))
+			}
+		case fd.IsMap() && fd.MapValue().Kind() == protoreflect.MessageKind:
+			v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+				walk(mv)
+				return true
+			})
+		case !fd.IsList() && !fd.IsMap() && fd.Kind() == protoreflect.MessageKind:
+			walk(v)
+		}
+		return true
+	})
+}
+
+// envoyFilterFindings reports the EnvoyFilter patches applied for the current proxy, which do not apply for the
+// target proxy, for example because the configuration they match changed.
+func envoyFilterFindings(current, target *UpgradeProxy) []Finding {
+	key := func(r model.EnvoyFilterPatchResult) string {
+		return fmt.Sprintf("%s/%s", r.Namespace, r.Name)
+	}
+	targetResults := map[string]model.EnvoyFilterPatchResult{}
+	for _, r := range target.EnvoyFilterPatches {
+		targetResults[fmt.Sprintf("%s[%d]", key(r), r.Index)] = r
+	}
+	var out []Finding
+	for _, r := range current.EnvoyFilterPatches {
+		if r.State != model.EnvoyFilterPatchApplied {
+			continue
+		}
+		path := fmt.Sprintf("configPatches[%d]", r.Index)
+		tr, f := targetResults[fmt.Sprintf("%s[%d]", key(r), r.Index)]
+		switch {
+		case !f:
+			out = append(out, Finding{Kind: FindingEnvoyFilter, Resource: key(r), Path: path,
+				Message: fmt.Sprintf("applied with revision %s, not evaluated with revision %s",
+					current.Revision, target.Revision)})
+		case tr.State != model.EnvoyFilterPatchApplied:
+			msg := fmt.Sprintf("applied with revision %s, %s with revision %s", current.Revision, tr.State,
+				target.Revision)
+			if tr.Message != "" {
+				msg += ": " + tr.Message
+			}
+			out = append(out, Finding{Kind: FindingEnvoyFilter, Resource: key(r), Path: path, Message: msg})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Resource < out[j].Resource
+	})
+	return out
+}
+
+func sortedSet(s map[string]struct{}) []string {
+	out := make([]string, 0, len(s))
+	for k := range s {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,177 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compare
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	adminapi "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+func marshalAny(t *testing.T, m proto.Message) *any.Any {
+	t.Helper()
+	a, err := ptypes.MarshalAny(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// fullConfigDump returns a config dump with clusters, listeners and routes, as generated by Istiod.
+func fullConfigDump(t *testing.T, clusters []*cluster.Cluster, listeners []*listener.Listener) []byte {
+	t.Helper()
+	cd := &adminapi.ClustersConfigDump{}
+	for _, c := range clusters {
+		cd.DynamicActiveClusters = append(cd.DynamicActiveClusters,
+			&adminapi.ClustersConfigDump_DynamicCluster{Cluster: marshalAny(t, c)})
+	}
+	ld := &adminapi.ListenersConfigDump{}
+	for _, l := range listeners {
+		ld.DynamicListeners = append(ld.DynamicListeners, &adminapi.ListenersConfigDump_DynamicListener{
+			Name:        l.Name,
+			ActiveState: &adminapi.ListenersConfigDump_DynamicListenerState{Listener: marshalAny(t, l)},
+		})
+	}
+	dump := &adminapi.ConfigDump{Configs: []*any.Any{
+		marshalAny(t, cd), marshalAny(t, ld), marshalAny(t, &adminapi.RoutesConfigDump{}),
+	}}
+	out := &bytes.Buffer{}
+	if err := (&jsonpb.Marshaler{}).Marshal(out, dump); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestPlanUpgrade(t *testing.T) {
+	newListener := func(ip string, httpFilters ...string) *listener.Listener {
+		h := &hcm.HttpConnectionManager{StatPrefix: "inbound"}
+		for _, f := range httpFilters {
+			h.HttpFilters = append(h.HttpFilters, &hcm.HttpFilter{Name: f})
+		}
+		return &listener.Listener{
+			Name: "virtualInbound",
+			FilterChains: []*listener.FilterChain{{
+				Name: "inbound|8080||",
+				Filters: []*listener.Filter{{
+					Name:       "envoy.filters.network.http_connection_manager",
+					ConfigType: &listener.Filter_TypedConfig{TypedConfig: marshalAny(t, h)},
+				}},
+				FilterChainMatch: &listener.FilterChainMatch{ServerNames: []string{ip}},
+			}},
+		}
+	}
+	current := &UpgradeProxy{
+		Name:     "httpbin-1.default",
+		Revision: "1-8",
+		IP:       "10.0.0.1",
+		ConfigDump: fullConfigDump(t,
+			[]*cluster.Cluster{
+				{Name: "outbound|80||a.default.svc.cluster.local", ConnectTimeout: ptypes.DurationProto(time.Second)},
+				{Name: "outbound|80||b.default.svc.cluster.local"},
+			},
+			[]*listener.Listener{newListener("10.0.0.1", "istio.metadata_exchange", "envoy.filters.http.rbac",
+				"envoy.filters.http.fault", "envoy.filters.http.router")}),
+		EnvoyFilterPatches: []model.EnvoyFilterPatchResult{
+			{Namespace: "default", Name: "lua", Index: 0, State: model.EnvoyFilterPatchApplied},
+			{Namespace: "default", Name: "lua", Index: 1, State: model.EnvoyFilterPatchApplied},
+			{Namespace: "default", Name: "old", Index: 0, State: model.EnvoyFilterPatchNoMatch},
+		},
+	}
+	target := &UpgradeProxy{
+		Name:     "httpbin-2.default",
+		Revision: "1-9",
+		IP:       "10.0.0.2",
+		ConfigDump: fullConfigDump(t,
+			[]*cluster.Cluster{
+				{
+					Name:           "outbound|80||a.default.svc.cluster.local",
+					ConnectTimeout: ptypes.DurationProto(10 * time.Second),
+				},
+				{
+					Name:        "outbound|80||b.default.svc.cluster.local",
+					AltStatName: "b",
+				},
+			},
+			// The addresses of the proxies differ, but are not reported.
+			[]*listener.Listener{newListener("10.0.0.2", "istio.metadata_exchange", "envoy.filters.http.fault",
+				"envoy.filters.http.rbac", "envoy.filters.http.router")}),
+		EnvoyFilterPatches: []model.EnvoyFilterPatchResult{
+			{Namespace: "default", Name: "lua", Index: 0, State: model.EnvoyFilterPatchApplied},
+			{Namespace: "default", Name: "lua", Index: 1, State: model.EnvoyFilterPatchNoMatch},
+		},
+	}
+
+	plan, err := PlanUpgrade("sidecar", current, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Finding{
+		{
+			Kind:     FindingValue,
+			Resource: "cluster outbound|80||a.default.svc.cluster.local",
+			Path:     "connect_timeout",
+			Message:  `"1s" -> "10s"`,
+		},
+		{
+			Kind:     FindingDefault,
+			Resource: "cluster outbound|80||b.default.svc.cluster.local",
+			Path:     "alt_stat_name",
+			Message:  `only set by revision 1-9: "b"`,
+		},
+		{
+			Kind:     FindingFilterOrder,
+			Resource: "listener virtualInbound",
+			Path: "filter_chains[name=inbound|8080||].filters[name=envoy.filters.network.http_connection_manager]" +
+				".typed_config.http_filters",
+			Message: "istio.metadata_exchange, envoy.filters.http.rbac, envoy.filters.http.fault, envoy.filters.http.router -> " +
+				"istio.metadata_exchange, envoy.filters.http.fault, envoy.filters.http.rbac, envoy.filters.http.router",
+		},
+		{
+			Kind:     FindingEnvoyFilter,
+			Resource: "default/lua",
+			Path:     "configPatches[1]",
+			Message:  "applied with revision 1-8, NoMatch with revision 1-9",
+		},
+	}
+	if !reflect.DeepEqual(plan.Findings, want) {
+		t.Errorf("got findings %+v, want %+v", plan.Findings, want)
+	}
+}
+
+func TestDeprecatedFields(t *testing.T) {
+	m := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("a.proto"),
+		Options: &descriptorpb.FileOptions{JavaGenerateEqualsAndHash: proto.Bool(true)},
+	}
+	got := map[string]struct{}{}
+	deprecatedFields(marshalAny(t, m), got)
+	want := map[string]struct{}{"google.protobuf.FileOptions.java_generate_equals_and_hash": {}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got deprecated fields %v, want %v", got, want)
+	}
+}
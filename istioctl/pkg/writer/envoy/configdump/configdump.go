@@ -18,6 +18,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/golang/protobuf/jsonpb"
 
@@ -29,6 +30,8 @@ import (
 type ConfigWriter struct {
 	Stdout     io.Writer
 	configDump *configdump.Wrapper
+	// rawConfigDump is the config dump as returned by Envoy, with the config types unknown to istioctl.
+	rawConfigDump []byte
 }
 
 // Prime loads the config dump into the writer ready for printing
@@ -41,6 +44,7 @@ func (c *ConfigWriter) Prime(b []byte) error {
 		return fmt.Errorf("error unmarshalling config dump response from Envoy: %v", err)
 	}
 	c.configDump = &cd
+	c.rawConfigDump = b
 	return nil
 }
 
@@ -78,6 +82,15 @@ func (c *ConfigWriter) PrintSecretDump() error {
 
 // PrintSecretSummary prints a summary of dynamic active secrets from the config dump
 func (c *ConfigWriter) PrintSecretSummary() error {
+	return c.printSecrets(sdscompare.TABULAR)
+}
+
+// PrintSecretChains prints the decoded certificate chains of the dynamic secrets from the config dump
+func (c *ConfigWriter) PrintSecretChains() error {
+	return c.printSecrets(sdscompare.CHAIN)
+}
+
+func (c *ConfigWriter) printSecrets(format sdscompare.Format) error {
 	secretDump, err := c.configDump.GetSecretConfigDump()
 	if err != nil {
 		return err
@@ -92,6 +105,19 @@ func (c *ConfigWriter) PrintSecretSummary() error {
 		return err
 	}
 
-	secretWriter := sdscompare.NewSDSWriter(c.Stdout, sdscompare.TABULAR)
+	secretWriter := sdscompare.NewSDSWriter(c.Stdout, format)
 	return secretWriter.PrintSecretItems(secretItems)
 }
+
+// ExpiringSecretCertificates returns the descriptions of the certificates of the dynamic secrets from the config
+// dump expiring within the duration, including the expired certificates
+func (c *ConfigWriter) ExpiringSecretCertificates(within time.Duration) ([]string, error) {
+	if c.configDump == nil {
+		return nil, fmt.Errorf("config writer has not been primed")
+	}
+	secretItems, err := sdscompare.GetEnvoySecrets(c.configDump)
+	if err != nil {
+		return nil, err
+	}
+	return sdscompare.ExpiringCertificates(secretItems, time.Now(), within), nil
+}
@@ -0,0 +1,204 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configdump
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	httpConn "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/golang/protobuf/ptypes"
+)
+
+// ecdsConfigDumpType is the type of the config dump of the extension configs received through ECDS. It is unknown
+// to the Envoy API version of istioctl, so the extension configs are read from the raw config dump.
+const ecdsConfigDumpType = "envoy.admin.v3.EcdsConfigDump"
+
+// EcdsFilter is used to pass filter information into the extension config based config writer print functions
+type EcdsFilter struct {
+	Name string
+}
+
+// Verify returns true if the passed extension config name matches the filter fields
+func (e *EcdsFilter) Verify(name string) bool {
+	return e.Name == "" || e.Name == name
+}
+
+// extensionConfig is an extension config received through ECDS, or referenced by a listener.
+type extensionConfig struct {
+	name        string
+	typeURL     string
+	versionInfo string
+	lastUpdated string
+	// listeners are the listeners whose filters reference the extension config.
+	listeners []string
+	// raw is the extension config as reported in the config dump, nil if it was not received.
+	raw json.RawMessage
+}
+
+type ecdsConfigDump struct {
+	EcdsFilters []struct {
+		VersionInfo string          `json:"version_info"`
+		EcdsFilter  json.RawMessage `json:"ecds_filter"`
+		LastUpdated string          `json:"last_updated"`
+	} `json:"ecds_filters"`
+}
+
+type typedExtensionConfig struct {
+	Name        string `json:"name"`
+	TypedConfig struct {
+		Type string `json:"@type"`
+	} `json:"typed_config"`
+}
+
+// PrintEcdsSummary prints a summary of the extension configs received through ECDS, and of the extension configs
+// referenced by the listeners, to the ConfigWriter stdout
+func (c *ConfigWriter) PrintEcdsSummary(filter EcdsFilter) error {
+	configs, reported, err := c.retrieveSortedExtensionConfigs()
+	if err != nil {
+		return err
+	}
+	w := new(tabwriter.Writer).Init(c.Stdout, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tVERSION\tLAST UPDATED\tLISTENERS")
+	for _, ec := range configs {
+		if !filter.Verify(ec.name) {
+			continue
+		}
+		version := ec.versionInfo
+		if ec.raw == nil {
+			version = "<not received>"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", ec.name, ec.typeURL, version, ec.lastUpdated, strings.Join(ec.listeners, ","))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if !reported {
+		fmt.Fprintln(c.Stdout, "The config dump does not include the extension configs received through ECDS, "+
+			"only the references of the listeners are listed.")
+	}
+	return nil
+}
+
+// PrintEcdsDump prints the extension configs received through ECDS to the ConfigWriter stdout
+func (c *ConfigWriter) PrintEcdsDump(filter EcdsFilter) error {
+	configs, _, err := c.retrieveSortedExtensionConfigs()
+	if err != nil {
+		return err
+	}
+	filtered := make([]json.RawMessage, 0, len(configs))
+	for _, ec := range configs {
+		if ec.raw != nil && filter.Verify(ec.name) {
+			filtered = append(filtered, ec.raw)
+		}
+	}
+	out, err := json.MarshalIndent(filtered, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal extension configs: %v", err)
+	}
+	fmt.Fprintln(c.Stdout, string(out))
+	return nil
+}
+
+// retrieveSortedExtensionConfigs returns the extension configs received through ECDS and referenced by the
+// listeners, sorted by name, and whether the config dump reports the extension configs received through ECDS.
+func (c *ConfigWriter) retrieveSortedExtensionConfigs() ([]*extensionConfig, bool, error) {
+	if c.configDump == nil {
+		return nil, false, fmt.Errorf("config writer has not been primed")
+	}
+	byName := map[string]*extensionConfig{}
+	get := func(name string) *extensionConfig {
+		if byName[name] == nil {
+			byName[name] = &extensionConfig{name: name}
+		}
+		return byName[name]
+	}
+
+	dump := struct {
+		Configs []json.RawMessage `json:"configs"`
+	}{}
+	if err := json.Unmarshal(c.rawConfigDump, &dump); err != nil {
+		return nil, false, fmt.Errorf("error unmarshalling config dump response from Envoy: %v", err)
+	}
+	reported := false
+	for _, raw := range dump.Configs {
+		t := struct {
+			Type string `json:"@type"`
+		}{}
+		if err := json.Unmarshal(raw, &t); err != nil || !strings.HasSuffix(t.Type, "/"+ecdsConfigDumpType) {
+			continue
+		}
+		reported = true
+		ecds := ecdsConfigDump{}
+		if err := json.Unmarshal(raw, &ecds); err != nil {
+			return nil, false, fmt.Errorf("ecds dump: %v", err)
+		}
+		for _, f := range ecds.EcdsFilters {
+			tec := typedExtensionConfig{}
+			if err := json.Unmarshal(f.EcdsFilter, &tec); err != nil {
+				return nil, false, fmt.Errorf("unmarshal extension config: %v", err)
+			}
+			ec := get(tec.Name)
+			ec.typeURL = tec.TypedConfig.Type
+			ec.versionInfo = f.VersionInfo
+			ec.lastUpdated = f.LastUpdated
+			ec.raw = f.EcdsFilter
+		}
+	}
+
+	listeners, err := c.retrieveSortedListenerSlice()
+	if err != nil {
+		return nil, false, err
+	}
+	for _, l := range listeners {
+		for _, fc := range getFilterChains(l) {
+			for _, filter := range fc.Filters {
+				if filter.Name != HTTPListener || filter.GetTypedConfig() == nil {
+					continue
+				}
+				hcm := &httpConn.HttpConnectionManager{}
+				// Allow Unmarshal to work even if Envoy and istioctl are different
+				filter.GetTypedConfig().TypeUrl = "type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v3.HttpConnectionManager"
+				if err := ptypes.UnmarshalAny(filter.GetTypedConfig(), hcm); err != nil {
+					return nil, false, fmt.Errorf("unmarshal http connection manager: %v", err)
+				}
+				for _, hf := range hcm.HttpFilters {
+					if hf.GetConfigDiscovery() == nil {
+						continue
+					}
+					ec := get(hf.Name)
+					if ec.typeURL == "" && len(hf.GetConfigDiscovery().TypeUrls) > 0 {
+						ec.typeURL = hf.GetConfigDiscovery().TypeUrls[0]
+					}
+					if len(ec.listeners) == 0 || ec.listeners[len(ec.listeners)-1] != l.Name {
+						ec.listeners = append(ec.listeners, l.Name)
+					}
+				}
+			}
+		}
+	}
+
+	out := make([]*extensionConfig, 0, len(byName))
+	for _, ec := range byName {
+		out = append(out, ec)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].name < out[j].name
+	})
+	return out, reported, nil
+}
@@ -0,0 +1,133 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configdump
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const ecdsConfigDumpJSON = `{
+  "configs": [
+    {
+      "@type": "type.googleapis.com/envoy.admin.v3.ListenersConfigDump",
+      "dynamic_listeners": [{
+        "name": "virtualInbound",
+        "active_state": {
+          "listener": {
+            "@type": "type.googleapis.com/envoy.config.listener.v3.Listener",
+            "name": "virtualInbound",
+            "filter_chains": [{
+              "filters": [{
+                "name": "envoy.filters.network.http_connection_manager",
+                "typed_config": {
+                  "@type": "type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v3.HttpConnectionManager",
+                  "stat_prefix": "inbound",
+                  "http_filters": [
+                    {
+                      "name": "default.stats",
+                      "config_discovery": {
+                        "config_source": {"ads": {}},
+                        "type_urls": ["type.googleapis.com/envoy.extensions.filters.http.wasm.v3.Wasm"]
+                      }
+                    },
+                    {
+                      "name": "default.authz",
+                      "config_discovery": {
+                        "config_source": {"ads": {}},
+                        "type_urls": ["type.googleapis.com/envoy.extensions.filters.http.ext_authz.v3.ExtAuthz"]
+                      }
+                    },
+                    {"name": "envoy.filters.http.router"}
+                  ]
+                }
+              }]
+            }]
+          }
+        }
+      }]
+    },
+    {
+      "@type": "type.googleapis.com/envoy.admin.v3.EcdsConfigDump",
+      "ecds_filters": [{
+        "version_info": "2021-03-01T10:00:00Z/7",
+        "ecds_filter": {
+          "@type": "type.googleapis.com/envoy.config.core.v3.TypedExtensionConfig",
+          "name": "default.stats",
+          "typed_config": {
+            "@type": "type.googleapis.com/envoy.extensions.filters.http.wasm.v3.Wasm",
+            "config": {"root_id": "stats"}
+          }
+        },
+        "last_updated": "2021-03-01T10:00:01Z"
+      }]
+    }
+  ]
+}`
+
+func TestPrintEcds(t *testing.T) {
+	out := &bytes.Buffer{}
+	cw := &ConfigWriter{Stdout: out}
+	if err := cw.Prime([]byte(ecdsConfigDumpJSON)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cw.PrintEcdsSummary(EcdsFilter{}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got summary %q, want a header and two extension configs", out.String())
+	}
+	for i, want := range [][]string{
+		{"default.authz", "ExtAuthz", "<not received>", "virtualInbound"},
+		{"default.stats", "wasm.v3.Wasm", "2021-03-01T10:00:00Z/7", "2021-03-01T10:00:01Z", "virtualInbound"},
+	} {
+		for _, w := range want {
+			if !strings.Contains(lines[i+1], w) {
+				t.Errorf("got summary line %q, want %q", lines[i+1], w)
+			}
+		}
+	}
+
+	out.Reset()
+	if err := cw.PrintEcdsDump(EcdsFilter{Name: "default.stats"}); err != nil {
+		t.Fatal(err)
+	}
+	var dump []map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &dump); err != nil {
+		t.Fatal(err)
+	}
+	if len(dump) != 1 || dump[0]["name"] != "default.stats" || dump[0]["typed_config"] == nil {
+		t.Errorf("got dump %v, want the default.stats extension config", dump)
+	}
+}
+
+func TestPrintEcdsSummaryNotReported(t *testing.T) {
+	out := &bytes.Buffer{}
+	cw := &ConfigWriter{Stdout: out}
+	dump := strings.Replace(ecdsConfigDumpJSON, "envoy.admin.v3.EcdsConfigDump", "envoy.admin.v3.Unknown", 1)
+	if err := cw.Prime([]byte(dump)); err != nil {
+		t.Fatal(err)
+	}
+	if err := cw.PrintEcdsSummary(EcdsFilter{Name: "default.stats"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "<not received>") || !strings.Contains(out.String(), "does not include the extension configs") {
+		t.Errorf("got summary %q", out.String())
+	}
+}
@@ -18,9 +18,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	xdsapi "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	xdsstatus "github.com/envoyproxy/go-control-plane/envoy/service/status/v3"
@@ -34,6 +37,9 @@ import (
 // StatusWriter enables printing of sync status using multiple []byte Istiod responses
 type StatusWriter struct {
 	Writer io.Writer
+	// CertExpiry, if set, contains the expiration time of the workload certificate of each proxy, keyed by proxy ID,
+	// and adds a column to the output. The proxies whose certificate could not be read are missing.
+	CertExpiry map[string]time.Time
 }
 
 type writerStatus struct {
@@ -63,11 +69,14 @@ func (s *StatusWriter) PrintAll(statuses map[string][]byte) error {
 		return err
 	}
 	for _, status := range fullStatus {
-		if err := statusPrintln(w, status); err != nil {
+		if err := s.statusPrintln(w, status); err != nil {
 			return err
 		}
 	}
-	return w.Flush()
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return s.printWarnings(fullStatus)
 }
 
 // PrintSingle takes a slice of Pilot syncz responses and outputs them using a tabwriter filtering for a specific pod
@@ -76,19 +85,101 @@ func (s *StatusWriter) PrintSingle(statuses map[string][]byte, proxyName string)
 	if err != nil {
 		return err
 	}
+	var matching []*writerStatus
 	for _, status := range fullStatus {
 		if strings.Contains(status.ProxyID, proxyName) {
-			if err := statusPrintln(w, status); err != nil {
+			if err := s.statusPrintln(w, status); err != nil {
 				return err
 			}
+			matching = append(matching, status)
 		}
 	}
-	return w.Flush()
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return s.printWarnings(matching)
+}
+
+// printWarnings prints the errors reported by the proxies for the configuration they rejected, and the proxies
+// whose version is skewed from the version of their Istiod, if any.
+func (s *StatusWriter) printWarnings(statuses []*writerStatus) error {
+	var nacks, skews []string
+	for _, status := range statuses {
+		rejecting := false
+		for _, nack := range []struct{ xdsType, message string }{
+			{"CDS", status.ClusterNack},
+			{"LDS", status.ListenerNack},
+			{"EDS", status.EndpointNack},
+			{"RDS", status.RouteNack},
+		} {
+			if nack.message != "" {
+				nacks = append(nacks, fmt.Sprintf("%v %v: %v", status.ProxyID, nack.xdsType, nack.message))
+				rejecting = true
+			}
+		}
+		// The last rejection of a proxy having accepted the configuration since is reported with its time.
+		if !rejecting && status.LastNack != "" {
+			at := ""
+			if status.LastNackTime != nil {
+				at = " at " + status.LastNackTime.UTC().Format(time.RFC3339)
+			}
+			nacks = append(nacks, fmt.Sprintf("%v last rejected%s, %v", status.ProxyID, at, status.LastNack))
+		}
+		if skew := versionSkew(status.IstioVersion, status.IstiodVersion); skew != "" {
+			skews = append(skews, fmt.Sprintf("%v runs %v and is connected to %v running %v: %v",
+				status.ProxyID, status.IstioVersion, status.pilot, status.IstiodVersion, skew))
+		}
+	}
+	for _, section := range []struct {
+		header string
+		lines  []string
+	}{
+		{"Rejected configuration:", nacks},
+		{"Version skew:", skews},
+	} {
+		if len(section.lines) == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(s.Writer, "\n%s\n%s\n", section.header, strings.Join(section.lines, "\n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var minorVersionRegexp = regexp.MustCompile(`^(\d+)\.(\d+)`)
+
+// versionSkew describes the skew between the Istio versions of a proxy and of its Istiod, if their minor versions
+// differ. It returns "" if the versions match or one of them is unknown.
+func versionSkew(proxy, istiod string) string {
+	pm := minorVersionRegexp.FindStringSubmatch(proxy)
+	im := minorVersionRegexp.FindStringSubmatch(istiod)
+	if pm == nil || im == nil {
+		return ""
+	}
+	pMajor, _ := strconv.Atoi(pm[1])
+	pMinor, _ := strconv.Atoi(pm[2])
+	iMajor, _ := strconv.Atoi(im[1])
+	iMinor, _ := strconv.Atoi(im[2])
+	switch {
+	case pMajor == iMajor && pMinor == iMinor:
+		return ""
+	case pMajor > iMajor || (pMajor == iMajor && pMinor > iMinor):
+		return "the proxy is newer than Istiod, which is not supported"
+	case pMajor == iMajor && pMinor == iMinor-1:
+		return "the proxy is one minor version behind Istiod"
+	default:
+		return "the proxy is more than one minor version behind Istiod, which is not supported"
+	}
 }
 
 func (s *StatusWriter) setupStatusPrint(statuses map[string][]byte) (*tabwriter.Writer, []*writerStatus, error) {
 	w := new(tabwriter.Writer).Init(s.Writer, 0, 8, 5, ' ', 0)
-	_, _ = fmt.Fprintln(w, "NAME\tCDS\tLDS\tEDS\tRDS\tISTIOD\tVERSION")
+	header := "NAME\tCDS\tLDS\tEDS\tRDS\tISTIOD\tVERSION"
+	if s.CertExpiry != nil {
+		header += "\tCERT EXPIRY"
+	}
+	_, _ = fmt.Fprintln(w, header)
 	fullStatus := make([]*writerStatus, 0, len(statuses))
 	for pilot, status := range statuses {
 		var ss []*writerStatus
@@ -107,11 +198,11 @@ func (s *StatusWriter) setupStatusPrint(statuses map[string][]byte) (*tabwriter.
 	return w, fullStatus, nil
 }
 
-func statusPrintln(w io.Writer, status *writerStatus) error {
-	clusterSynced := xdsStatus(status.ClusterSent, status.ClusterAcked)
-	listenerSynced := xdsStatus(status.ListenerSent, status.ListenerAcked)
-	routeSynced := xdsStatus(status.RouteSent, status.RouteAcked)
-	endpointSynced := xdsStatus(status.EndpointSent, status.EndpointAcked)
+func (s *StatusWriter) statusPrintln(w io.Writer, status *writerStatus) error {
+	clusterSynced := xdsStatus(status.ClusterSent, status.ClusterAcked, status.ClusterNack)
+	listenerSynced := xdsStatus(status.ListenerSent, status.ListenerAcked, status.ListenerNack)
+	routeSynced := xdsStatus(status.RouteSent, status.RouteAcked, status.RouteNack)
+	endpointSynced := xdsStatus(status.EndpointSent, status.EndpointAcked, status.EndpointNack)
 	version := status.IstioVersion
 	if version == "" {
 		// If we can't find an Istio version (talking to a 1.1 pilot), fallback to the proxy version
@@ -119,18 +210,34 @@ func statusPrintln(w io.Writer, status *writerStatus) error {
 		// but it is better than not providing any information.
 		version = status.ProxyVersion + "*"
 	}
-	_, _ = fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
-		status.ProxyID, clusterSynced, listenerSynced, endpointSynced, routeSynced, status.pilot, version)
+	if s.CertExpiry == nil {
+		_, _ = fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+			status.ProxyID, clusterSynced, listenerSynced, endpointSynced, routeSynced, status.pilot, version)
+		return nil
+	}
+	expiry := "UNKNOWN"
+	if t, f := s.CertExpiry[status.ProxyID]; f {
+		expiry = t.UTC().Format(time.RFC3339)
+		if t.Before(time.Now()) {
+			expiry += " (EXPIRED)"
+		}
+	}
+	_, _ = fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+		status.ProxyID, clusterSynced, listenerSynced, endpointSynced, routeSynced, status.pilot, version, expiry)
 	return nil
 }
 
-func xdsStatus(sent, acked string) string {
+func xdsStatus(sent, acked, nack string) string {
 	if sent == "" {
 		return "NOT SENT"
 	}
 	if sent == acked {
 		return "SYNCED"
 	}
+	// nack is only set when the last sent response was rejected
+	if nack != "" {
+		return "NACKED"
+	}
 	// acked will be empty string when there is never Acknowledged
 	if acked == "" {
 		return "STALE (Never Acknowledged)"
@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -94,11 +95,12 @@ func TestStatusWriter_PrintAll(t *testing.T) {
 
 func TestStatusWriter_PrintSingle(t *testing.T) {
 	tests := []struct {
-		name      string
-		input     map[string][]xds.SyncStatus
-		filterPod string
-		want      string
-		wantErr   bool
+		name       string
+		input      map[string][]xds.SyncStatus
+		filterPod  string
+		certExpiry map[string]time.Time
+		want       string
+		wantErr    bool
 	}{
 		{
 			name: "prints multiple istiod inputs to buffer filtering for pod",
@@ -125,6 +127,23 @@ func TestStatusWriter_PrintSingle(t *testing.T) {
 			filterPod: "proxy2",
 			want:      "testdata/singleStatusFallback.txt",
 		},
+		{
+			name: "prints rejected configuration",
+			input: map[string][]xds.SyncStatus{
+				"istiod1": append(statusInput1(), statusInputNack()...),
+			},
+			filterPod: "proxy2",
+			want:      "testdata/singleStatusNack.txt",
+		},
+		{
+			name: "prints last rejection, version skew and certificate expiry",
+			input: map[string][]xds.SyncStatus{
+				"istiod1": statusInputLastNack(),
+			},
+			filterPod:  "proxy2",
+			certExpiry: map[string]time.Time{"proxy2": time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)},
+			want:       "testdata/singleStatusLastNack.txt",
+		},
 		{
 			name: "error if given non-syncstatus info",
 			input: map[string][]xds.SyncStatus{
@@ -136,7 +155,7 @@ func TestStatusWriter_PrintSingle(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			got := &bytes.Buffer{}
-			sw := StatusWriter{Writer: got}
+			sw := StatusWriter{Writer: got, CertExpiry: tt.certExpiry}
 			input := map[string][]byte{}
 			for key, ss := range tt.input {
 				b, _ := json.Marshal(ss)
@@ -176,6 +195,61 @@ func statusInput1() []xds.SyncStatus {
 	}
 }
 
+func statusInputNack() []xds.SyncStatus {
+	return []xds.SyncStatus{
+		{
+			ProxyID:       "proxy2",
+			IstioVersion:  "1.1",
+			ClusterSent:   preDefinedNonce,
+			ClusterAcked:  newNonce(),
+			ClusterNack:   "Error adding/updating cluster(s) outbound|80||foo.com: invalid cluster",
+			ListenerSent:  preDefinedNonce,
+			ListenerAcked: preDefinedNonce,
+			EndpointSent:  preDefinedNonce,
+			EndpointAcked: preDefinedNonce,
+		},
+	}
+}
+
+func statusInputLastNack() []xds.SyncStatus {
+	lastNack := time.Date(2020, 10, 1, 10, 0, 0, 0, time.UTC)
+	return []xds.SyncStatus{
+		{
+			ProxyID:       "proxy2",
+			IstioVersion:  "1.8",
+			IstiodVersion: "1.9.0",
+			ClusterSent:   preDefinedNonce,
+			ClusterAcked:  preDefinedNonce,
+			ListenerSent:  preDefinedNonce,
+			ListenerAcked: preDefinedNonce,
+			EndpointSent:  preDefinedNonce,
+			EndpointAcked: preDefinedNonce,
+			RouteSent:     preDefinedNonce,
+			RouteAcked:    preDefinedNonce,
+			LastNack:      "LDS: Error adding/updating listener(s) virtualInbound: invalid filter",
+			LastNackTime:  &lastNack,
+		},
+	}
+}
+
+func TestVersionSkew(t *testing.T) {
+	tests := []struct {
+		proxy, istiod string
+		want          string
+	}{
+		{"1.9.1", "1.9.0", ""},
+		{"1.9", "", ""},
+		{"1.8.2", "1.9.0", "the proxy is one minor version behind Istiod"},
+		{"1.7.0", "1.9.0", "the proxy is more than one minor version behind Istiod, which is not supported"},
+		{"1.10.0", "1.9.0", "the proxy is newer than Istiod, which is not supported"},
+	}
+	for _, tt := range tests {
+		if got := versionSkew(tt.proxy, tt.istiod); got != tt.want {
+			t.Errorf("versionSkew(%q, %q) = %q, want %q", tt.proxy, tt.istiod, got, tt.want)
+		}
+	}
+}
+
 func statusInput2() []xds.SyncStatus {
 	return []xds.SyncStatus{
 		{
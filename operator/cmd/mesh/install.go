@@ -31,6 +31,7 @@ import (
 	"istio.io/istio/istioctl/pkg/install/k8sversion"
 	"istio.io/istio/istioctl/pkg/verifier"
 	v1alpha12 "istio.io/istio/operator/pkg/apis/istio/v1alpha1"
+	"istio.io/istio/operator/pkg/bundle"
 	"istio.io/istio/operator/pkg/cache"
 	"istio.io/istio/operator/pkg/controller/istiocontrolplane"
 	"istio.io/istio/operator/pkg/helmreconciler"
@@ -71,6 +72,9 @@ type installArgs struct {
 	manifestsPath string
 	// revision is the Istio control plane revision the command targets.
 	revision string
+	// bundlePath is a path to a local install bundle. If set, the charts, profiles and images of the bundle are used
+	// and the installation does not reach the network.
+	bundlePath string
 }
 
 func addInstallFlags(cmd *cobra.Command, args *installArgs) {
@@ -86,6 +90,7 @@ func addInstallFlags(cmd *cobra.Command, args *installArgs) {
 	cmd.PersistentFlags().StringVarP(&args.manifestsPath, "charts", "", "", ChartsDeprecatedStr)
 	cmd.PersistentFlags().StringVarP(&args.manifestsPath, "manifests", "d", "", ManifestsFlagHelpStr)
 	cmd.PersistentFlags().StringVarP(&args.revision, "revision", "r", "", revisionFlagHelpStr)
+	cmd.PersistentFlags().StringVar(&args.bundlePath, "bundle", "", BundleFlagHelpStr)
 }
 
 // InstallCmd generates an Istio install manifest and applies it to a cluster
@@ -110,12 +115,18 @@ func InstallCmd(logOpts *log.Options) *cobra.Command {
 
   # To override a setting that includes dots, escape them with a backslash (\).  Your shell may require enclosing quotes.
   istioctl install --set "values.sidecarInjectorWebhook.injectedAnnotations.container\.apparmor\.security\.beta\.kubernetes\.io/istio-proxy=runtime/default"
+
+  # Install from a local bundle in an environment without network access
+  istioctl install --bundle ~/istio-bundle
 `,
 		Args: cobra.ExactArgs(0),
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			if !labels.IsDNS1123Label(iArgs.revision) && cmd.PersistentFlags().Changed("revision") {
 				return fmt.Errorf("invalid revision specified: %v", iArgs.revision)
 			}
+			if iArgs.bundlePath != "" && iArgs.manifestsPath != "" {
+				return fmt.Errorf("--bundle and --manifests cannot be used together")
+			}
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -146,10 +157,28 @@ func runApplyCmd(cmd *cobra.Command, rootArgs *rootArgs, iArgs *installArgs, log
 	if err != nil {
 		return err
 	}
+	var b *bundle.Bundle
+	if iArgs.bundlePath != "" {
+		if b, err = bundle.Load(iArgs.bundlePath); err != nil {
+			return err
+		}
+		// The flags of the bundle come first, so that they can be overridden with --set.
+		iArgs.set = append(b.SetFlags(), iArgs.set...)
+		iArgs.manifestsPath = b.ManifestsPath()
+	}
 	setFlags := applyFlagAliases(iArgs.set, iArgs.manifestsPath, iArgs.revision)
 
-	_, iop, err := manifest.GenerateConfig(iArgs.inFilenames, setFlags, iArgs.force, restConfig, l)
-	if err != nil {
+	var iop *v1alpha12.IstioOperator
+	var manifests name.ManifestMap
+	if b != nil {
+		// The manifests are rendered once, and installed with their images pinned to the digests of the bundle.
+		if manifests, iop, err = manifest.GenManifests(iArgs.inFilenames, setFlags, iArgs.force, restConfig, l); err != nil {
+			return err
+		}
+		if manifests, err = b.PinImages(manifests); err != nil {
+			return fmt.Errorf("failed to pin the images to the install bundle: %v", err)
+		}
+	} else if _, iop, err = manifest.GenerateConfig(iArgs.inFilenames, setFlags, iArgs.force, restConfig, l); err != nil {
 		return err
 	}
 
@@ -176,7 +205,7 @@ func runApplyCmd(cmd *cobra.Command, rootArgs *rootArgs, iArgs *installArgs, log
 	if err := configLogs(logOpts); err != nil {
 		return fmt.Errorf("could not configure logs: %s", err)
 	}
-	iop, err = InstallManifests(iop, iArgs.force, rootArgs.dryRun, restConfig, client, iArgs.readinessTimeout, l)
+	iop, err = installManifests(iop, manifests, iArgs.force, rootArgs.dryRun, restConfig, client, iArgs.readinessTimeout, l)
 	if err != nil {
 		return fmt.Errorf("failed to install manifests: %v", err)
 	}
@@ -204,6 +233,12 @@ func runApplyCmd(cmd *cobra.Command, rootArgs *rootArgs, iArgs *installArgs, log
 // Returns final IstioOperator after installation if successful.
 func InstallManifests(iop *v1alpha12.IstioOperator, force bool, dryRun bool, restConfig *rest.Config, client client.Client,
 	waitTimeout time.Duration, l clog.Logger) (*v1alpha12.IstioOperator, error) {
+	return installManifests(iop, nil, force, dryRun, restConfig, client, waitTimeout, l)
+}
+
+// installManifests applies the manifests already generated from iop, or generates them if nil, to the cluster.
+func installManifests(iop *v1alpha12.IstioOperator, manifests name.ManifestMap, force bool, dryRun bool, restConfig *rest.Config,
+	client client.Client, waitTimeout time.Duration, l clog.Logger) (*v1alpha12.IstioOperator, error) {
 	// Needed in case we are running a test through this path that doesn't start a new process.
 	cache.FlushObjectCaches()
 	opts := &helmreconciler.Options{
@@ -214,7 +249,12 @@ func InstallManifests(iop *v1alpha12.IstioOperator, force bool, dryRun bool, res
 	if err != nil {
 		return iop, err
 	}
-	status, err := reconciler.Reconcile()
+	var status *v1alpha1.InstallStatus
+	if manifests != nil {
+		status, err = reconciler.ReconcileManifests(manifests)
+	} else {
+		status, err = reconciler.Reconcile()
+	}
 	if err != nil {
 		return iop, fmt.Errorf("errors occurred during operation: %v", err)
 	}
@@ -34,6 +34,12 @@ settings (--set meshConfig.enableTracing=true). See documentation for more info:
 	ManifestsFlagHelpStr = `Specify a path to a directory of charts and profiles
 (e.g. ~/Downloads/istio-` + baseVersion + `/manifests)
 or release tar URL (e.g. ` + url.ReleaseTar + `).
+`
+	// BundleFlagHelpStr is the command line description for --bundle
+	BundleFlagHelpStr = `Specify a path to a local install bundle: a directory with the charts and profiles
+under manifests/ and a bundle.yaml manifest listing the digests of the images. The installation does not reach
+the network, installs the images by the digests of the bundle, and fails if an image of the generated manifests
+is not in the bundle.
 `
 )
 
@@ -0,0 +1,261 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bundle reads the local install bundles used to install Istio without network access. A bundle is a
+// directory containing the charts and profiles of a release under manifests/, and a bundle.yaml manifest listing
+// the digests of the images of the release, as mirrored in the registry reachable from the cluster:
+//
+//	hub: registry.local/istio
+//	images:
+//	- image: registry.local/istio/pilot:1.9.0
+//	  digest: sha256:...
+package bundle
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+
+	"istio.io/istio/operator/pkg/name"
+	"istio.io/istio/operator/pkg/object"
+	"istio.io/istio/operator/pkg/util"
+)
+
+const (
+	// ManifestFile is the name of the bundle manifest in the bundle directory.
+	ManifestFile = "bundle.yaml"
+	// ManifestsDir is the name of the directory of charts and profiles in the bundle directory.
+	ManifestsDir = "manifests"
+)
+
+var digestRegexp = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// Image is an image of the bundle.
+type Image struct {
+	// Image is the reference of the image, without digest, e.g. registry.local/istio/pilot:1.9.0.
+	Image string `json:"image"`
+	// Digest is the digest of the image, e.g. sha256:<hex>.
+	Digest string `json:"digest"`
+}
+
+// Manifest is the content of the bundle manifest.
+type Manifest struct {
+	// Hub, if set, is the hub the images are pulled from, overriding values.global.hub.
+	Hub string `json:"hub,omitempty"`
+	// Tag, if set, is the tag of the images, overriding values.global.tag.
+	Tag string `json:"tag,omitempty"`
+	// Images are the images of the bundle.
+	Images []Image `json:"images"`
+}
+
+// Bundle is a local install bundle.
+type Bundle struct {
+	dir      string
+	manifest *Manifest
+	// digests maps the image references of the bundle to their digests.
+	digests map[string]string
+}
+
+// Load reads and validates the bundle in the given directory.
+func Load(dir string) (*Bundle, error) {
+	if isURL, err := util.IsHTTPURL(dir); err != nil || isURL {
+		return nil, fmt.Errorf("install bundle %s must be a local directory", dir)
+	}
+	if fi, err := os.Stat(filepath.Join(dir, ManifestsDir)); err != nil || !fi.IsDir() {
+		return nil, fmt.Errorf("install bundle %s does not contain a %s directory", dir, ManifestsDir)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("could not read the install bundle manifest: %v", err)
+	}
+	m := &Manifest{}
+	if err := yaml.UnmarshalStrict(b, m); err != nil {
+		return nil, fmt.Errorf("could not parse the install bundle manifest: %v", err)
+	}
+	if len(m.Images) == 0 {
+		return nil, fmt.Errorf("install bundle manifest %s lists no images", filepath.Join(dir, ManifestFile))
+	}
+	digests := make(map[string]string)
+	for _, img := range m.Images {
+		if img.Image == "" || strings.Contains(img.Image, "@") {
+			return nil, fmt.Errorf("invalid image %q in the install bundle manifest, expected a reference without digest",
+				img.Image)
+		}
+		if !digestRegexp.MatchString(img.Digest) {
+			return nil, fmt.Errorf("invalid digest %q of image %s in the install bundle manifest", img.Digest, img.Image)
+		}
+		if d, f := digests[img.Image]; f && d != img.Digest {
+			return nil, fmt.Errorf("image %s is listed with different digests in the install bundle manifest", img.Image)
+		}
+		digests[img.Image] = img.Digest
+	}
+	return &Bundle{dir: dir, manifest: m, digests: digests}, nil
+}
+
+// ManifestsPath returns the path of the charts and profiles of the bundle.
+func (b *Bundle) ManifestsPath() string {
+	return filepath.Join(b.dir, ManifestsDir)
+}
+
+// SetFlags returns the --set flags pointing the installation to the bundle: its charts and profiles, and the hub
+// and tag of its images if set.
+func (b *Bundle) SetFlags() []string {
+	flags := []string{"installPackagePath=" + b.ManifestsPath()}
+	if b.manifest.Hub != "" {
+		flags = append(flags, "hub="+b.manifest.Hub)
+	}
+	if b.manifest.Tag != "" {
+		flags = append(flags, "tag="+b.manifest.Tag)
+	}
+	return flags
+}
+
+// PinImages returns the manifests with the container images pinned to the digests of the bundle, so that the
+// installation runs the images of the bundle even if their tags are moved. Images must be in the bundle, and images
+// referenced by digest must have the digest listed in the bundle manifest.
+func (b *Bundle) PinImages(manifests name.ManifestMap) (name.ManifestMap, error) {
+	out := make(name.ManifestMap, len(manifests))
+	var errs util.Errors
+	for c, ms := range manifests {
+		for _, m := range ms {
+			objs, err := object.ParseK8sObjectsFromYAMLManifest(m)
+			if err != nil {
+				return nil, err
+			}
+			pinned := false
+			for i, o := range objs {
+				u := o.UnstructuredObject()
+				changed, err := b.pinContainerImages(u.Object)
+				errs = util.AppendErrs(errs, err)
+				if changed {
+					// The object caches its YAML, which is stale once the images are pinned.
+					objs[i] = object.NewK8sObject(u, nil, nil)
+					pinned = true
+				}
+			}
+			if pinned {
+				if m, err = objs.YAMLManifest(); err != nil {
+					return nil, err
+				}
+			}
+			out[c] = append(out[c], m)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, uniqueSorted(errs).ToError()
+	}
+	return out, nil
+}
+
+// uniqueSorted returns the errors sorted and without duplicates, as an image may be used by several containers.
+func uniqueSorted(errs util.Errors) util.Errors {
+	seen := make(map[string]bool)
+	var out util.Errors
+	for _, err := range errs {
+		if !seen[err.Error()] {
+			seen[err.Error()] = true
+			out = append(out, err)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Error() < out[j].Error() })
+	return out
+}
+
+// pinContainerImages pins the images of the containers found in the object tree, and returns whether any image
+// was changed.
+func (b *Bundle) pinContainerImages(node interface{}) (bool, []error) {
+	changed := false
+	var errs []error
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for k, v := range n {
+			if k == "containers" || k == "initContainers" {
+				if cs, ok := v.([]interface{}); ok {
+					for _, c := range cs {
+						cm, ok := c.(map[string]interface{})
+						if !ok {
+							continue
+						}
+						img, ok := cm["image"].(string)
+						if !ok || img == "" {
+							continue
+						}
+						pinned, err := b.pinImage(img)
+						if err != nil {
+							errs = append(errs, err)
+							continue
+						}
+						if pinned != img {
+							cm["image"] = pinned
+							changed = true
+						}
+					}
+				}
+				continue
+			}
+			c, e := b.pinContainerImages(v)
+			changed = changed || c
+			errs = append(errs, e...)
+		}
+	case []interface{}:
+		for _, v := range n {
+			c, e := b.pinContainerImages(v)
+			changed = changed || c
+			errs = append(errs, e...)
+		}
+	}
+	return changed, errs
+}
+
+// pinImage returns the image referenced by the digest of the bundle. Images already referenced by digest are
+// returned unchanged if the digest is in the bundle.
+func (b *Bundle) pinImage(img string) (string, error) {
+	ref, digest := img, ""
+	if i := strings.Index(img, "@"); i >= 0 {
+		ref, digest = img[:i], img[i+1:]
+	}
+	want, f := b.digests[ref]
+	if !f && digest != "" {
+		// The image may be referenced by digest only, without the tag of the bundle manifest.
+		for bundleRef, d := range b.digests {
+			if d == digest && imageName(bundleRef) == imageName(ref) {
+				return img, nil
+			}
+		}
+	}
+	if !f {
+		return "", fmt.Errorf("image %s is not in the install bundle", img)
+	}
+	if digest == "" {
+		return ref + "@" + want, nil
+	}
+	if digest != want {
+		return "", fmt.Errorf("image %s does not match the digest %s of the install bundle", img, want)
+	}
+	return img, nil
+}
+
+// imageName returns the image reference without tag.
+func imageName(ref string) string {
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref[:i]
+	}
+	return ref
+}
@@ -0,0 +1,212 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bundle
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"istio.io/istio/operator/pkg/name"
+	"istio.io/istio/operator/pkg/object"
+)
+
+var (
+	pilotDigest = "sha256:" + strings.Repeat("a", 64)
+	proxyDigest = "sha256:" + strings.Repeat("b", 64)
+)
+
+func writeBundle(t *testing.T, manifest string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	if err := os.Mkdir(filepath.Join(dir, ManifestsDir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, ManifestFile), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	cases := []struct {
+		name     string
+		manifest string
+		wantErr  string
+	}{
+		{
+			name: "valid",
+			manifest: `hub: registry.local/istio
+images:
+- image: registry.local/istio/pilot:1.9.0
+  digest: ` + pilotDigest,
+		},
+		{
+			name:     "no images",
+			manifest: `hub: registry.local/istio`,
+			wantErr:  "lists no images",
+		},
+		{
+			name: "invalid digest",
+			manifest: `images:
+- image: registry.local/istio/pilot:1.9.0
+  digest: latest`,
+			wantErr: "invalid digest",
+		},
+		{
+			name: "conflicting digests",
+			manifest: `images:
+- image: registry.local/istio/pilot:1.9.0
+  digest: ` + pilotDigest + `
+- image: registry.local/istio/pilot:1.9.0
+  digest: ` + proxyDigest,
+			wantErr: "different digests",
+		},
+		{
+			name: "unknown field",
+			manifest: `registry: registry.local/istio
+images:
+- image: registry.local/istio/pilot:1.9.0
+  digest: ` + pilotDigest,
+			wantErr: "could not parse",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeBundle(t, tt.manifest)
+			b, err := Load(dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []string{"installPackagePath=" + filepath.Join(dir, ManifestsDir), "hub=registry.local/istio"}
+			if got := b.SetFlags(); !reflect.DeepEqual(got, want) {
+				t.Errorf("got set flags %v, want %v", got, want)
+			}
+		})
+	}
+
+	if _, err := Load("https://example.com/istio.tar.gz"); err == nil {
+		t.Errorf("expected an error for a remote bundle")
+	}
+}
+
+func TestPinImages(t *testing.T) {
+	b, err := Load(writeBundle(t, `images:
+- image: registry.local/istio/pilot:1.9.0
+  digest: `+pilotDigest+`
+- image: registry.local/istio/proxyv2:1.9.0
+  digest: `+proxyDigest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deployment := func(images ...string) string {
+		out := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: istiod
+spec:
+  template:
+    spec:
+      containers:
+`
+		for _, img := range images {
+			out += "      - name: c\n        image: " + img + "\n"
+		}
+		return out
+	}
+	service := `apiVersion: v1
+kind: Service
+metadata:
+  name: istiod
+`
+
+	cases := []struct {
+		name    string
+		images  []string
+		want    []string
+		wantErr []string
+	}{
+		{
+			name:   "tags",
+			images: []string{"registry.local/istio/pilot:1.9.0", "registry.local/istio/proxyv2:1.9.0"},
+			want:   []string{"registry.local/istio/pilot:1.9.0@" + pilotDigest, "registry.local/istio/proxyv2:1.9.0@" + proxyDigest},
+		},
+		{
+			name:   "digest",
+			images: []string{"registry.local/istio/pilot@" + pilotDigest, "registry.local/istio/proxyv2:1.9.0@" + proxyDigest},
+			want:   []string{"registry.local/istio/pilot@" + pilotDigest, "registry.local/istio/proxyv2:1.9.0@" + proxyDigest},
+		},
+		{
+			name:    "not in bundle",
+			images:  []string{"docker.io/istio/pilot:1.9.0", "registry.local/istio/pilot:1.9.1"},
+			wantErr: []string{"docker.io/istio/pilot:1.9.0 is not in", "registry.local/istio/pilot:1.9.1 is not in"},
+		},
+		{
+			name:    "digest mismatch",
+			images:  []string{"registry.local/istio/pilot:1.9.0@" + proxyDigest, "registry.local/istio/proxyv2@" + pilotDigest},
+			wantErr: []string{"does not match the digest", "registry.local/istio/proxyv2@" + pilotDigest + " is not in"},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := b.PinImages(name.ManifestMap{name.PilotComponentName: {deployment(tt.images...), service}})
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				ms := got[name.PilotComponentName]
+				if len(ms) != 2 || ms[1] != service {
+					t.Fatalf("unexpected manifests %v", ms)
+				}
+				objs, err := object.ParseK8sObjectsFromYAMLManifest(ms[0])
+				if err != nil {
+					t.Fatal(err)
+				}
+				containers, _, _ := unstructured.NestedSlice(objs[0].Unstructured(), "spec", "template", "spec", "containers")
+				var images []string
+				for _, c := range containers {
+					images = append(images, c.(map[string]interface{})["image"].(string))
+				}
+				if !reflect.DeepEqual(images, tt.want) {
+					t.Errorf("got images %v, want %v", images, tt.want)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected errors %v", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("got error %v, want %q", err, want)
+				}
+			}
+		})
+	}
+}
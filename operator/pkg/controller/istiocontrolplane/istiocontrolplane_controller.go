@@ -19,7 +19,9 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -338,6 +340,12 @@ func (r *ReconcileIstioOperator) Reconcile(_ context.Context, request reconcile.
 	if err != nil {
 		return reconcile.Result{}, err
 	}
+	driftInterval := driftCheckInterval()
+	if driftInterval > 0 {
+		if _, err := reconciler.DetectDrift(driftRemediation()); err != nil {
+			scope.Warnf("Failed to detect the drift of IstioOperator %s: %s", iopName, err)
+		}
+	}
 	if err := reconciler.SetStatusBegin(); err != nil {
 		return reconcile.Result{}, err
 	}
@@ -349,7 +357,30 @@ func (r *ReconcileIstioOperator) Reconcile(_ context.Context, request reconcile.
 		return reconcile.Result{}, err
 	}
 
-	return reconcile.Result{}, err
+	// With drift detection, the IstioOperator is reconciled periodically to detect the resources edited manually.
+	return reconcile.Result{RequeueAfter: driftInterval}, err
+}
+
+// driftCheckInterval returns the interval between the drift detections of the IstioOperators, set by the
+// DRIFT_CHECK_INTERVAL env variable. The drift detection is disabled if it is not set.
+func driftCheckInterval() time.Duration {
+	interval, found := os.LookupEnv("DRIFT_CHECK_INTERVAL")
+	if !found {
+		return 0
+	}
+	d, err := time.ParseDuration(interval)
+	if err != nil {
+		scope.Warnf("invalid env variable value: %s for 'DRIFT_CHECK_INTERVAL'! drift detection is disabled", interval)
+		return 0
+	}
+	return d
+}
+
+// driftRemediation returns whether the drifted resources are applied again, set by the DRIFT_REMEDIATION env
+// variable. Otherwise the drift is only reported.
+func driftRemediation() bool {
+	remediate, _ := strconv.ParseBool(os.Getenv("DRIFT_REMEDIATION"))
+	return remediate
 }
 
 // mergeIOPSWithProfile overlays the values in iop on top of the defaults for the profile given by iop.profile and
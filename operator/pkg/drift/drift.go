@@ -0,0 +1,259 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package drift compares the live resources of an installation with the resources rendered from its IstioOperator,
+// to detect the resources which were edited manually or deleted.
+package drift
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"istio.io/istio/operator/pkg/name"
+	"istio.io/istio/operator/pkg/object"
+)
+
+// Kind is the kind of drift of a resource.
+type Kind string
+
+const (
+	// Missing is a rendered resource which does not exist in the cluster.
+	Missing Kind = "Missing"
+	// Modified is a resource whose live fields differ from the rendered fields.
+	Modified Kind = "Modified"
+)
+
+// Finding is a resource which drifted from its rendered manifest.
+type Finding struct {
+	Kind Kind
+	// Object is the rendered object.
+	Object *object.K8sObject
+	// Diffs are the paths of the fields which differ, and their rendered and live values.
+	Diffs []string
+}
+
+func (f Finding) String() string {
+	if f.Kind == Missing {
+		return fmt.Sprintf("%s is missing", f.Object.Hash())
+	}
+	return fmt.Sprintf("%s is modified: %s", f.Object.Hash(), strings.Join(f.Diffs, "; "))
+}
+
+// ignoredPaths are the fields, by kind, which are set by other controllers than the installer and never reported as
+// drift. The list indices are elided from the paths.
+var ignoredPaths = map[string][]string{
+	// The replicas of the deployments are managed by their HorizontalPodAutoscaler.
+	name.DeploymentStr: {"spec.replicas"},
+	// Istiod patches the CA bundle of its webhooks, and the failure policy of the validating webhook.
+	name.MutatingWebhookConfigurationStr:   {"webhooks[].clientConfig.caBundle"},
+	name.ValidatingWebhookConfigurationStr: {"webhooks[].clientConfig.caBundle", "webhooks[].failurePolicy"},
+}
+
+var listIndexRegexp = regexp.MustCompile(`\[[^\]]*\]`)
+
+// Detect returns the drift of the rendered objects. get returns the live object, or nil if it does not exist.
+func Detect(rendered object.K8sObjects, get func(*object.K8sObject) (*unstructured.Unstructured, error)) ([]Finding, error) {
+	var out []Finding
+	for _, obj := range rendered {
+		live, err := get(obj)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s: %v", obj.Hash(), err)
+		}
+		if live == nil {
+			out = append(out, Finding{Kind: Missing, Object: obj})
+			continue
+		}
+		if diffs := Compare(obj.UnstructuredObject(), live); len(diffs) > 0 {
+			out = append(out, Finding{Kind: Modified, Object: obj, Diffs: diffs})
+		}
+	}
+	return out, nil
+}
+
+// Compare returns the fields of the rendered object whose live values differ, in the form
+// "path: rendered value -> live value". Only the fields set in the rendered object are compared: the fields added
+// by the API server or by other controllers are not drift. The metadata other than labels and annotations, and the
+// status, are ignored.
+func Compare(rendered, live *unstructured.Unstructured) []string {
+	var diffs []string
+	for _, field := range sortedKeys(rendered.Object) {
+		switch field {
+		case "apiVersion", "kind", "status":
+			continue
+		case "metadata":
+			for _, m := range []string{"labels", "annotations"} {
+				r, _, _ := unstructured.NestedFieldNoCopy(rendered.Object, "metadata", m)
+				l, _, _ := unstructured.NestedFieldNoCopy(live.Object, "metadata", m)
+				compareValues("metadata."+m, r, l, true, &diffs)
+			}
+		default:
+			l, found := live.Object[field]
+			compareValues(field, rendered.Object[field], l, found, &diffs)
+		}
+	}
+	ignored := ignoredPaths[rendered.GetKind()]
+	out := diffs[:0]
+	for _, d := range diffs {
+		path := listIndexRegexp.ReplaceAllString(d[:strings.Index(d, ": ")], "[]")
+		if !containsPath(ignored, path) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func compareValues(path string, rendered, live interface{}, found bool, diffs *[]string) {
+	if rendered == nil {
+		return
+	}
+	if !found || live == nil {
+		if !isZero(rendered) {
+			*diffs = append(*diffs, fmt.Sprintf("%s: %s -> <unset>", path, format(rendered)))
+		}
+		return
+	}
+	switch r := rendered.(type) {
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for _, k := range sortedKeys(r) {
+			lv, f := l[k]
+			compareValues(path+"."+k, r[k], lv, f, diffs)
+		}
+		return
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if !ok {
+			break
+		}
+		if rn, ok := namedItems(r); ok {
+			if ln, ok := namedItems(l); ok {
+				for _, n := range sortedKeys(rn) {
+					lv, f := ln[n]
+					compareValues(fmt.Sprintf("%s[name=%s]", path, n), rn[n], lv, f, diffs)
+				}
+				return
+			}
+		}
+		if len(r) != len(l) {
+			*diffs = append(*diffs, fmt.Sprintf("%s: %d items -> %d items", path, len(r), len(l)))
+			return
+		}
+		for i := range r {
+			compareValues(fmt.Sprintf("%s[%d]", path, i), r[i], l[i], true, diffs)
+		}
+		return
+	default:
+		if scalarEqual(rendered, live) {
+			return
+		}
+	}
+	*diffs = append(*diffs, fmt.Sprintf("%s: %s -> %s", path, format(rendered), format(live)))
+}
+
+// namedItems returns the items of the list by name, if they all are objects with a name.
+func namedItems(items []interface{}) (map[string]interface{}, bool) {
+	out := make(map[string]interface{}, len(items))
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		n, ok := m["name"].(string)
+		if !ok {
+			return nil, false
+		}
+		out[n] = item
+	}
+	return out, true
+}
+
+// scalarEqual compares scalars as the API server normalizes them: numbers regardless of their type, and quantities
+// regardless of their format, e.g. 1000m and 1.
+func scalarEqual(rendered, live interface{}) bool {
+	if reflect.DeepEqual(rendered, live) || fmt.Sprint(rendered) == fmt.Sprint(live) {
+		return true
+	}
+	rs, rok := rendered.(string)
+	ls, lok := live.(string)
+	if !rok || !lok {
+		return false
+	}
+	rq, err := resource.ParseQuantity(rs)
+	if err != nil {
+		return false
+	}
+	lq, err := resource.ParseQuantity(ls)
+	if err != nil {
+		return false
+	}
+	return rq.Cmp(lq) == 0
+}
+
+// isZero returns whether the value is the zero value of its type, which the API server omits.
+func isZero(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		return len(t) == 0
+	case []interface{}:
+		return len(t) == 0
+	case string:
+		return t == ""
+	case bool:
+		return !t
+	case int64:
+		return t == 0
+	case float64:
+		return t == 0
+	}
+	return false
+}
+
+func format(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return fmt.Sprintf("%q", t)
+	case map[string]interface{}, []interface{}:
+		return "{...}"
+	}
+	return fmt.Sprint(v)
+}
+
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,167 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drift
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"istio.io/istio/operator/pkg/object"
+)
+
+func parse(t *testing.T, y string) *object.K8sObject {
+	t.Helper()
+	o, err := object.ParseYAMLToK8sObject([]byte(y))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+const renderedDeployment = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: istiod
+  namespace: istio-system
+  labels:
+    app: istiod
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: discovery
+        image: docker.io/istio/pilot:1.9.0
+        args: ["discovery", "--monitoringAddr=:15014"]
+        resources:
+          requests:
+            cpu: 500m
+            memory: 2048Mi
+      hostNetwork: false
+`
+
+func TestCompare(t *testing.T) {
+	cases := []struct {
+		name string
+		live string
+		want []string
+	}{
+		{
+			name: "unchanged",
+			live: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: istiod
+  namespace: istio-system
+  resourceVersion: "42"
+  labels:
+    app: istiod
+    install.operator.istio.io/owning-resource: installed-state
+spec:
+  replicas: 3
+  progressDeadlineSeconds: 600
+  template:
+    spec:
+      containers:
+      - name: discovery
+        image: docker.io/istio/pilot:1.9.0
+        args: ["discovery", "--monitoringAddr=:15014"]
+        imagePullPolicy: IfNotPresent
+        resources:
+          requests:
+            cpu: "0.5"
+            memory: 2Gi
+status:
+  readyReplicas: 3
+`,
+		},
+		{
+			name: "edited",
+			live: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: istiod
+  namespace: istio-system
+  labels:
+    app: pilot
+spec:
+  template:
+    spec:
+      containers:
+      - name: discovery
+        image: docker.io/istio/pilot:1.9.1
+        args: ["discovery"]
+      - name: debug
+        image: busybox
+`,
+			want: []string{
+				`metadata.labels.app: "istiod" -> "pilot"`,
+				"spec.template.spec.containers[name=discovery].args: 2 items -> 1 items",
+				`spec.template.spec.containers[name=discovery].image: "docker.io/istio/pilot:1.9.0" -> "docker.io/istio/pilot:1.9.1"`,
+				"spec.template.spec.containers[name=discovery].resources: {...} -> <unset>",
+			},
+		},
+	}
+	rendered := parse(t, renderedDeployment).UnstructuredObject()
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Compare(rendered, parse(t, tt.live).UnstructuredObject())
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got diffs %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	rendered := object.K8sObjects{
+		parse(t, renderedDeployment),
+		parse(t, `
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: istiod
+  namespace: istio-system
+`),
+	}
+	live := parse(t, strings.Replace(renderedDeployment, "pilot:1.9.0", "pilot:1.9.1", 1)).UnstructuredObject()
+	findings, err := Detect(rendered, func(o *object.K8sObject) (*unstructured.Unstructured, error) {
+		if o.Kind == "Deployment" {
+			return live, nil
+		}
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`Deployment:istio-system:istiod is modified: spec.template.spec.containers[name=discovery].image: ` +
+			`"docker.io/istio/pilot:1.9.0" -> "docker.io/istio/pilot:1.9.1"`,
+		"ServiceAccount:istio-system:istiod is missing",
+	}
+	var got []string
+	for _, f := range findings {
+		got = append(got, f.String())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got findings %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmreconciler
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"istio.io/istio/operator/pkg/drift"
+	"istio.io/istio/operator/pkg/metrics"
+	"istio.io/istio/operator/pkg/name"
+	"istio.io/istio/operator/pkg/object"
+	"istio.io/istio/operator/pkg/util"
+)
+
+// DetectDrift compares the live resources with the resources rendered for the IstioOperator, and returns the
+// resources which are missing or were edited. If remediate is set, the drifted resources are removed from the object
+// cache, for the next Reconcile to apply them again.
+func (h *HelmReconciler) DetectDrift(remediate bool) ([]drift.Finding, error) {
+	manifests, err := h.RenderCharts()
+	if err != nil {
+		return nil, err
+	}
+	var out []drift.Finding
+	for c, ms := range manifests {
+		objs, err := object.ParseK8sObjectsFromYAMLManifest(name.MergeManifestSlices(ms))
+		if err != nil {
+			return nil, err
+		}
+		findings, err := drift.Detect(objs, h.liveObject)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range findings {
+			scope.Warnf("Component %s drifted from the IstioOperator %s: %s", c, h.iop.Name, f)
+			metrics.DriftedResourceTotal.
+				With(metrics.ResourceKindLabel.Value(util.GKString(f.Object.GroupVersionKind().GroupKind()))).
+				Increment()
+			if remediate {
+				h.removeFromObjectCache(string(c), f.Object.Hash())
+			}
+		}
+		out = append(out, findings...)
+	}
+	return out, nil
+}
+
+// liveObject returns the live object of the rendered object, or nil if it does not exist.
+func (h *HelmReconciler) liveObject(obj *object.K8sObject) (*unstructured.Unstructured, error) {
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(obj.GroupVersionKind())
+	err := h.client.Get(context.TODO(), client.ObjectKey{Namespace: obj.Namespace, Name: obj.Name}, live)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	return live, err
+}
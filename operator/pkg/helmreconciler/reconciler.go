@@ -146,13 +146,20 @@ func initDependencies() map[name.ComponentName]chan struct{} {
 
 // Reconcile reconciles the associated resources.
 func (h *HelmReconciler) Reconcile() (*v1alpha1.InstallStatus, error) {
-	if err := h.createNamespace(valuesv1alpha1.Namespace(h.iop.Spec), h.networkName()); err != nil {
-		return nil, err
-	}
 	manifestMap, err := h.RenderCharts()
 	if err != nil {
 		return nil, err
 	}
+	return h.ReconcileManifests(manifestMap)
+}
+
+// ReconcileManifests reconciles the resources of manifests already rendered for h, such as manifests whose images
+// were pinned to the digests of an install bundle.
+func (h *HelmReconciler) ReconcileManifests(manifestMap name.ManifestMap) (*v1alpha1.InstallStatus, error) {
+	if err := h.createNamespace(valuesv1alpha1.Namespace(h.iop.Spec), h.networkName()); err != nil {
+		return nil, err
+	}
+	h.manifests = manifestMap
 
 	status := h.processRecursive(manifestMap)
 
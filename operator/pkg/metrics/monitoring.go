@@ -179,6 +179,14 @@ var (
 		monitoring.WithLabels(ResourceKindLabel),
 	)
 
+	// DriftedResourceTotal counts the resources found missing or edited
+	// by the drift detection of the operator.
+	DriftedResourceTotal = monitoring.NewSum(
+		"drifted_resource_total",
+		"Number of times a resource was found drifted from the rendered manifest",
+		monitoring.WithLabels(ResourceKindLabel),
+	)
+
 	// ManifestPatchErrorTotal counts the total number of K8S patch errors.
 	ManifestPatchErrorTotal = monitoring.NewSum(
 		"manifest_patch_error_total",
@@ -220,6 +228,7 @@ func init() {
 		ResourceUpdateTotal,
 		ResourceDeletionTotal,
 		ResourcePruneTotal,
+		DriftedResourceTotal,
 
 		ManifestPatchErrorTotal,
 		ManifestRenderErrorTotal,
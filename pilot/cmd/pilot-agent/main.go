@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"path"
 	"strings"
 	"time"
 
@@ -30,6 +31,7 @@ import (
 	"istio.io/istio/pilot/cmd/pilot-agent/config"
 	secopt "istio.io/istio/pilot/cmd/pilot-agent/security"
 	"istio.io/istio/pilot/cmd/pilot-agent/status"
+	"istio.io/istio/pilot/pkg/dns"
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/util/network"
@@ -124,7 +126,11 @@ var (
 		"The grace period ratio for the cert rotation, by default 0.5.").Get()
 	pkcs8KeysEnv = env.RegisterBoolVar("PKCS8_KEY", false,
 		"Whether to generate PKCS#8 private keys").Get()
-	eccSigAlgEnv        = env.RegisterStringVar("ECC_SIGNATURE_ALGORITHM", "", "The type of ECC signature algorithm to use when generating private keys").Get()
+	eccSigAlgEnv = env.RegisterStringVar("ECC_SIGNATURE_ALGORITHM", "", "The type of ECC signature algorithm to use when generating private keys").Get()
+	eccCurveEnv  = env.RegisterStringVar("ECC_CURVE", "P256",
+		"The elliptic curve to use when generating EC private keys. Currently supported curves include P256 and P384").Get()
+	workloadRSAKeySizeEnv = env.RegisterIntVar("WORKLOAD_RSA_KEY_SIZE", 2048,
+		"The size of the RSA private keys of the workload certificates. Currently supported sizes include 2048, 3072 and 4096").Get()
 	fileMountedCertsEnv = env.RegisterBoolVar("FILE_MOUNTED_CERTS", false, "").Get()
 	credFetcherTypeEnv  = env.RegisterStringVar("CREDENTIAL_FETCHER_TYPE", "",
 		"The type of the credential fetcher. Currently supported types include GoogleComputeEngine").Get()
@@ -136,6 +142,17 @@ var (
 	// This is a copy of the env var in the init code.
 	dnsCaptureByAgent = env.RegisterBoolVar("ISTIO_META_DNS_CAPTURE", false,
 		"If set to true, enable the capture of outgoing DNS packets on port 53, redirecting to istio-agent on :15053").Get()
+	dnsUpstreamsVar = env.RegisterStringVar("DNS_UPSTREAMS", "",
+		"Comma separated list of the upstream resolvers of the DNS proxy, tried in order until one answers, in the "+
+			"format <address>[:<port>][/<timeout>]. Defaults to the nameservers of /etc/resolv.conf.")
+	dnsSearchDomainsVar = env.RegisterStringVar("DNS_SEARCH_DOMAINS", "",
+		"Comma separated list of the search domains the DNS proxy expands the known hosts with. "+
+			"Defaults to the search domains of /etc/resolv.conf.")
+	dnsNdotsVar = env.RegisterIntVar("DNS_NDOTS", 0,
+		"If set, the DNS proxy only expands the known hosts with fewer dots with the search domains, "+
+			"as the application resolver queries the other hosts as is first.")
+	dnsSearchExpansionVar = env.RegisterStringVar("DNS_SEARCH_EXPANSION", string(dns.SearchExpansionFirst),
+		"How the DNS proxy expands the known hosts with the search domains: first, all or none.")
 
 	rootCmd = &cobra.Command{
 		Use:          "pilot-agent",
@@ -234,8 +251,11 @@ var (
 				TrustDomain:                    trustDomainEnv,
 				Pkcs8Keys:                      pkcs8KeysEnv,
 				ECCSigAlg:                      eccSigAlgEnv,
+				ECCCurve:                       eccCurveEnv,
+				WorkloadRSAKeySize:             workloadRSAKeySizeEnv,
 				SecretTTL:                      secretTTLEnv,
 				SecretRotationGracePeriodRatio: secretRotationGracePeriodRatioEnv,
+				CRLFilePath:                    path.Join(istio_agent.CitadelCACertPath, constants.CACRLNamespaceConfigMapDataName),
 			}
 			secOpts, err := secopt.SetupSecurityOptions(proxyConfig, sop, jwtPolicy.Get(),
 				credFetcherTypeEnv, credIdentityProvider)
@@ -276,6 +296,9 @@ var (
 				XdsUdsPath:   constants.DefaultXdsUdsPath,
 				IsIPv6:       proxyIPv6,
 				ProxyType:    role.Type,
+
+				SpiffeBundleEndpoints:       features.SpiffeBundleEndpoints,
+				SpiffeBundleRefreshInterval: features.SpiffeBundleRefreshInterval,
 			}
 			extractXDSHeadersFromEnv(agentConfig)
 			if proxyXDSViaAgent {
@@ -283,6 +306,9 @@ var (
 				agentConfig.DNSCapture = dnsCaptureByAgent
 				agentConfig.ProxyNamespace = podNamespace
 				agentConfig.ProxyDomain = role.DNSDomain
+				if agentConfig.DNSOptions, err = buildDNSOptions(); err != nil {
+					return err
+				}
 			}
 			sa := istio_agent.NewAgent(&proxyConfig, agentConfig, secOpts)
 
@@ -371,6 +397,27 @@ func extractXDSHeadersFromEnv(config *istio_agent.AgentConfig) {
 	}
 }
 
+// buildDNSOptions returns the settings of the DNS proxy overriding /etc/resolv.conf.
+func buildDNSOptions() (dns.Options, error) {
+	var opts dns.Options
+	var err error
+	if opts.Upstreams, err = dns.ParseUpstreams(dnsUpstreamsVar.Get()); err != nil {
+		return opts, err
+	}
+	for _, domain := range strings.Split(dnsSearchDomainsVar.Get(), ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			opts.SearchDomains = append(opts.SearchDomains, domain)
+		}
+	}
+	if opts.Ndots = dnsNdotsVar.Get(); opts.Ndots < 0 {
+		return opts, fmt.Errorf("invalid DNS_NDOTS %d", opts.Ndots)
+	}
+	if opts.SearchExpansion, err = dns.ParseSearchExpansion(dnsSearchExpansionVar.Get()); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
 func initStatusServer(ctx context.Context, proxyIPv6 bool, proxyConfig meshconfig.ProxyConfig) error {
 	localHostAddr := localHostIPv4
 	if proxyIPv6 {
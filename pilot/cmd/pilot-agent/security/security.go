@@ -25,6 +25,7 @@ import (
 	"istio.io/istio/pkg/security"
 	"istio.io/istio/security/pkg/credentialfetcher"
 	"istio.io/istio/security/pkg/nodeagent/plugin/providers/google/stsclient"
+	pkiutil "istio.io/istio/security/pkg/pki/util"
 	"istio.io/pkg/log"
 )
 
@@ -68,5 +69,15 @@ func SetupSecurityOptions(proxyConfig meshconfig.ProxyConfig, secOpt security.Op
 	if o.ProvCert != "" && o.FileMountedCerts {
 		return security.Options{}, fmt.Errorf("invalid options: PROV_CERT and FILE_MOUNTED_CERTS are mutually exclusive")
 	}
+	switch o.WorkloadRSAKeySize {
+	case 0, 2048, 3072, 4096:
+	default:
+		return security.Options{}, fmt.Errorf("invalid options: unsupported WORKLOAD_RSA_KEY_SIZE %d", o.WorkloadRSAKeySize)
+	}
+	switch pkiutil.SupportedEllipticCurves(o.ECCCurve) {
+	case "", pkiutil.P256Curve, pkiutil.P384Curve:
+	default:
+		return security.Options{}, fmt.Errorf("invalid options: unsupported ECC_CURVE %q", o.ECCCurve)
+	}
 	return o, nil
 }
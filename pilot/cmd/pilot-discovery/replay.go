@@ -0,0 +1,80 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/spf13/cobra"
+
+	"istio.io/istio/pilot/pkg/xds"
+)
+
+var (
+	replaySnapshot  string
+	replayEndpoints bool
+
+	replayCmd = &cobra.Command{
+		Use:   "replay <proxy-id>",
+		Short: "Generates offline the configuration of a proxy from a snapshot",
+		Long: "Generates offline the configuration of a proxy from a snapshot of the inputs of the xDS generation, " +
+			"taken with `pilot-discovery request GET /debug/snapshotz > snapshot.json`, and prints it as an Envoy config dump.",
+		Example: "pilot-discovery replay --snapshot snapshot.json productpage-v1-6b746f74dc-9stvs.default",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			b, err := ioutil.ReadFile(replaySnapshot)
+			if err != nil {
+				return err
+			}
+			snapshot := &xds.Snapshot{}
+			if err := json.Unmarshal(b, snapshot); err != nil {
+				return fmt.Errorf("failed to parse snapshot %s: %v", replaySnapshot, err)
+			}
+			result, err := xds.Replay(snapshot, args[0])
+			if err != nil {
+				return err
+			}
+			m := &jsonpb.Marshaler{Indent: "  "}
+			if replayEndpoints {
+				for _, cla := range result.Endpoints {
+					if err := m.Marshal(c.OutOrStdout(), cla); err != nil {
+						return err
+					}
+					fmt.Fprintln(c.OutOrStdout())
+				}
+			} else {
+				if err := m.Marshal(c.OutOrStdout(), result.ConfigDump); err != nil {
+					return err
+				}
+				fmt.Fprintln(c.OutOrStdout())
+			}
+			if !result.Identical {
+				return fmt.Errorf("the configuration of %s differs from the one generated when the snapshot was taken", args[0])
+			}
+			return nil
+		},
+	}
+)
+
+func init() {
+	replayCmd.PersistentFlags().StringVar(&replaySnapshot, "snapshot", "snapshot.json",
+		"File holding the snapshot returned by /debug/snapshotz")
+	replayCmd.PersistentFlags().BoolVar(&replayEndpoints, "endpoints", false,
+		"Print the endpoints of the EDS clusters instead of the config dump")
+	rootCmd.AddCommand(replayCmd)
+}
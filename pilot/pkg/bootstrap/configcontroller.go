@@ -17,6 +17,7 @@ package bootstrap
 import (
 	"fmt"
 	"net/url"
+	"strings"
 	"time"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
@@ -219,6 +220,9 @@ func (s *Server) initInprocessAnalysisController(args *PilotArgs) error {
 	processingArgs.WatchedNamespaces = args.RegistryOptions.KubeOptions.WatchedNamespaces
 	processingArgs.MeshConfigFile = args.MeshConfigFile
 	processingArgs.EnableConfigAnalysis = true
+	if features.ExternalAnalyzers != "" {
+		processingArgs.ExternalAnalyzers = strings.Split(features.ExternalAnalyzers, ",")
+	}
 
 	processing := components.NewProcessing(processingArgs)
 
@@ -259,6 +263,10 @@ func (s *Server) initStatusController(args *PilotArgs, writeStatus bool) {
 	s.statusReporter = &status.Reporter{
 		UpdateInterval: time.Millisecond * 500, // TODO: use args here?
 		PodName:        args.PodName,
+		// the results of the EnvoyFilter patches are reported for the leader to write them in their status
+		EnvoyFilterPatches: s.XDSServer.EnvoyFilterPatchSummaries,
+		// so are the configs whose configuration was rejected by the proxies
+		ConfigRejections: s.XDSServer.ConfigRejections,
 	}
 	s.statusReporter.Init(s.environment.GetLedger())
 	s.addTerminatingStartFunc(func(stop <-chan struct{}) error {
@@ -17,6 +17,7 @@ package bootstrap
 import (
 	"bytes"
 	"context"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -27,14 +28,17 @@ import (
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 
 	"istio.io/api/security/v1beta1"
 	"istio.io/istio/pilot/pkg/features"
+	securityModel "istio.io/istio/pilot/pkg/security/model"
 	"istio.io/istio/pilot/pkg/serviceregistry/kube/controller"
 	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/jwt"
 	kubelib "istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/security"
 	"istio.io/istio/security/pkg/cmd"
@@ -140,6 +144,37 @@ var (
 	// TODO: Likely to be removed and added to mesh config
 	k8sSigner = env.RegisterStringVar("K8S_SIGNER", "",
 		"Kubernates CA Signer type. Valid from Kubernates 1.18").Get()
+
+	k8sNamespaceSigners = env.RegisterStringVar("K8S_NAMESPACE_SIGNERS", "",
+		"Comma separated list of <namespace>=<signer> pairs, selecting the Kubernetes CA Signer of the workload "+
+			"certificates of the namespace. Namespaces not listed use K8S_SIGNER. The signer can also be selected "+
+			"by the "+ra.CaSignerAnnotation+" annotation of the namespace or service account of the workload, "+
+			"among K8S_ALLOWED_SIGNERS. Used when EXTERNAL_CA is ISTIOD_RA_KUBERNETES_API.")
+
+	k8sAllowedSigners = env.RegisterStringVar("K8S_ALLOWED_SIGNERS", "",
+		"Comma separated list of the Kubernetes CA Signers that the "+ra.CaSignerAnnotation+" annotation of "+
+			"namespaces and service accounts can select. The annotation is ignored if empty. istiod must be "+
+			"allowed to approve the CSRs of these signers. Used when EXTERNAL_CA is ISTIOD_RA_KUBERNETES_API.")
+
+	externalCaAddress = env.RegisterStringVar("EXTERNAL_CA_ADDR", "",
+		"Address of the external CA implementing the Istio CA gRPC API. Used when EXTERNAL_CA is ISTIOD_RA_ISTIO_API.")
+
+	externalCaNamespaceAddresses = env.RegisterStringVar("EXTERNAL_CA_NAMESPACE_ADDRS", "",
+		"Comma separated list of <namespace>=<address> pairs, selecting the external CA signing the workload "+
+			"certificates of the namespace. Namespaces not listed use EXTERNAL_CA_ADDR. Used when EXTERNAL_CA "+
+			"is ISTIOD_RA_ISTIO_API.")
+
+	externalCaHealthCheckInterval = env.RegisterDurationVar("EXTERNAL_CA_HEALTH_CHECK_INTERVAL", ra.DefaultHealthCheckInterval,
+		"The interval of the health checks of the external CAs. Used when EXTERNAL_CA is ISTIOD_RA_ISTIO_API.")
+
+	externalCaHealthCheck = env.RegisterBoolVar("EXTERNAL_CA_HEALTH_CHECK", true,
+		"If enabled, the external CAs are health checked with the gRPC health checking protocol, and signing fails "+
+			"fast while they are unhealthy. Disable it for the external CAs not implementing the protocol. Used "+
+			"when EXTERNAL_CA is ISTIOD_RA_ISTIO_API.")
+
+	externalCaTokenFile = env.RegisterStringVar("EXTERNAL_CA_TOKEN_FILE", "",
+		"File containing the token sent as bearer token in the requests to the external CAs. Defaults to the "+
+			"token of the istiod service account. Used when EXTERNAL_CA is ISTIOD_RA_ISTIO_API.")
 )
 
 // EnableCA returns whether CA functionality is enabled in istiod.
@@ -202,6 +237,8 @@ func (s *Server) RunCA(grpc *grpc.Server, ca caserver.CertificateAuthority, opts
 	if startErr != nil {
 		log.Fatalf("failed to create istio ca server: %v", startErr)
 	}
+	// Track the rotated workload certificates of the connected proxies.
+	caServer.CertIssued = s.XDSServer.RecordIssuedCert
 
 	// TODO: if not set, parse Istiod's own token (if present) and get the issuer. The same issuer is used
 	// for all tokens - no need to configure twice. The token may also include cluster info to auto-configure
@@ -430,5 +467,85 @@ func (s *Server) createIstioRA(client kubelib.Client,
 		K8sClient:      client.CertificatesV1beta1(),
 		TrustDomain:    opts.TrustDomain,
 	}
-	return ra.NewIstioRA(raOpts)
+	if opts.ExternalCAType == ra.ExtCAK8s {
+		namespaceSigners, err := parseNamespaceMap(k8sNamespaceSigners.Get())
+		if err != nil {
+			return nil, err
+		}
+		raOpts.NamespaceCaSigners = namespaceSigners
+		for _, signer := range strings.Split(k8sAllowedSigners.Get(), ",") {
+			if signer = strings.TrimSpace(signer); signer != "" {
+				raOpts.AllowedCaSigners = append(raOpts.AllowedCaSigners, signer)
+			}
+		}
+		// The annotations are only looked up if they can select a signer.
+		if len(raOpts.AllowedCaSigners) > 0 {
+			raOpts.NamespaceLister = client.KubeInformer().Core().V1().Namespaces().Lister()
+			raOpts.ServiceAccountLister = client.KubeInformer().Core().V1().ServiceAccounts().Lister()
+		}
+	}
+	if opts.ExternalCAType == ra.ExtCAGrpc {
+		namespaceAddresses, err := parseNamespaceMap(externalCaNamespaceAddresses.Get())
+		if err != nil {
+			return nil, err
+		}
+		creds, err := externalCATransportCredentials(caCertFile)
+		if err != nil {
+			return nil, err
+		}
+		raOpts.CaAddress = externalCaAddress.Get()
+		raOpts.NamespaceCaAddresses = namespaceAddresses
+		raOpts.HealthCheckInterval = externalCaHealthCheckInterval.Get()
+		raOpts.DisableHealthCheck = !externalCaHealthCheck.Get()
+		raOpts.CaTokenFile = externalCaTokenFile.Get()
+		if raOpts.CaTokenFile == "" {
+			raOpts.CaTokenFile = securityModel.K8sSAJwtFileName
+			if features.JwtPolicy.Get() == jwt.PolicyThirdParty {
+				raOpts.CaTokenFile = securityModel.K8sSATrustworthyJwtFileName
+			}
+		}
+		raOpts.ClusterID = s.clusterID
+		raOpts.DialOptions = []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	}
+	istioRA, err := ra.NewIstioRA(raOpts)
+	if err != nil {
+		return nil, err
+	}
+	if grpcRA, ok := istioRA.(*ra.GrpcRA); ok {
+		s.addStartFunc(func(stop <-chan struct{}) error {
+			go grpcRA.Run(stop)
+			return nil
+		})
+	}
+	return istioRA, nil
+}
+
+// parseNamespaceMap parses a comma separated list of <namespace>=<value> pairs.
+func parseNamespaceMap(value string) (map[string]string, error) {
+	res := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("invalid namespace value %q: expected format <namespace>=<value>", pair)
+		}
+		res[kv[0]] = kv[1]
+	}
+	return res, nil
+}
+
+// externalCATransportCredentials returns the credentials used to connect to the external CAs, which are
+// trusted if signed by either a public CA or the external CA root certificate.
+func externalCATransportCredentials(caCertFile string) (credentials.TransportCredentials, error) {
+	certPool, err := x509.SystemCertPool()
+	if err != nil {
+		return nil, err
+	}
+	if rootCert, err := ioutil.ReadFile(caCertFile); err == nil {
+		certPool.AppendCertsFromPEM(rootCert)
+	}
+	return credentials.NewClientTLSFromCert(certPool, ""), nil
 }
@@ -31,6 +31,27 @@ import (
 
 const namespace = "istio-system"
 
+func TestParseNamespaceMap(t *testing.T) {
+	g := NewWithT(t)
+
+	got, err := parseNamespaceMap("foo=vault-adapter.foo:8080, bar=pca-adapter.bar:8080,")
+	g.Expect(err).Should(BeNil())
+	g.Expect(got).Should(Equal(map[string]string{
+		"foo": "vault-adapter.foo:8080",
+		"bar": "pca-adapter.bar:8080",
+	}))
+
+	got, err = parseNamespaceMap("")
+	g.Expect(err).Should(BeNil())
+	g.Expect(got).Should(BeEmpty())
+
+	_, err = parseNamespaceMap("foo")
+	g.Expect(err).NotTo(BeNil())
+
+	_, err = parseNamespaceMap("=vault-adapter.foo:8080")
+	g.Expect(err).NotTo(BeNil())
+}
+
 func TestRemoteCerts(t *testing.T) {
 	g := NewWithT(t)
 
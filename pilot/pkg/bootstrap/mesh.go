@@ -18,6 +18,9 @@ import (
 	"encoding/json"
 	"os"
 
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/security/authz/ipset"
 	"istio.io/istio/pkg/config/mesh"
 	"istio.io/istio/pkg/config/mesh/kubemesh"
 	"istio.io/istio/pkg/util/gogoprotomarshal"
@@ -89,6 +92,28 @@ func (s *Server) initMeshNetworks(args *PilotArgs, fileWatcher filewatcher.FileW
 	}
 }
 
+// initIPSetWatcher watches the IP sets referenced by AuthorizationPolicies, and pushes the listeners
+// of the proxies when they change.
+func (s *Server) initIPSetWatcher(args *PilotArgs) {
+	if s.kubeClient == nil || features.AuthzIPSetConfigMap == "" {
+		return
+	}
+	log.Info("initializing authorization IP set watcher")
+	w := ipset.NewWatcher(s.kubeClient, args.Namespace, features.AuthzIPSetConfigMap)
+	// The IP sets can be referenced by any policy, so their changes are pushed like the mesh config ones.
+	w.AddHandler(func() {
+		s.XDSServer.ConfigUpdate(&model.PushRequest{
+			Full:   true,
+			Reason: []model.TriggerReason{model.GlobalUpdate},
+		})
+	})
+	s.environment.IPSetWatcher = w
+	s.addStartFunc(func(stop <-chan struct{}) error {
+		go w.Run(stop)
+		return nil
+	})
+}
+
 func getMeshConfigMapName(revision string) string {
 	name := defaultMeshConfigMapName
 	if revision == "" || revision == "default" {
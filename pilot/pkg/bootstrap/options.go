@@ -62,6 +62,7 @@ type PilotArgs struct {
 	KeepaliveOptions   *keepalive.Options
 	ShutdownDuration   time.Duration
 	JwtRule            string
+	XDSJwtIssuers      string
 }
 
 // DiscoveryServerOptions contains options for create a new discovery server instance.
@@ -112,6 +113,11 @@ var (
 	podNameVar      = env.RegisterStringVar("POD_NAME", "", "")
 	jwtRuleVar      = env.RegisterStringVar("JWT_RULE", "",
 		"The JWT rule used by istiod authentication")
+	xdsJwtIssuersVar = env.RegisterStringVar("XDS_JWT_ISSUERS", "",
+		"The JSON list of the JWT issuers authenticating the proxies running out of the mesh to the XDS server, "+
+			"with the audiences accepted in their tokens and the rules mapping the subjects of the tokens to the namespace "+
+			"and service account the proxies can act as. "+
+			"Requires XDS_AUTH.")
 )
 
 // RevisionVar is the value of the Istio control plane revision, e.g. "canary",
@@ -146,6 +152,7 @@ func (p *PilotArgs) applyDefaults() {
 	p.PodName = podNameVar.Get()
 	p.Revision = RevisionVar.Get()
 	p.JwtRule = jwtRuleVar.Get()
+	p.XDSJwtIssuers = xdsJwtIssuersVar.Get()
 	p.KeepaliveOptions = keepalive.DefaultOption()
 	p.RegistryOptions.DistributionTrackingEnabled = features.EnableDistributionTracking
 	p.RegistryOptions.DistributionCacheRetention = features.DistributionHistoryRetention
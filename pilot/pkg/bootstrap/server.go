@@ -45,6 +45,7 @@ import (
 	"k8s.io/client-go/tools/cache"
 
 	"istio.io/api/security/v1beta1"
+	"istio.io/istio/pilot/pkg/certexpiry"
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	modelstatus "istio.io/istio/pilot/pkg/model/status"
@@ -63,6 +64,7 @@ import (
 	"istio.io/istio/pkg/config/mesh"
 	"istio.io/istio/pkg/config/schema/collections"
 	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/file"
 	"istio.io/istio/pkg/jwt"
 	istiokeepalive "istio.io/istio/pkg/keepalive"
 	kubelib "istio.io/istio/pkg/kube"
@@ -72,6 +74,7 @@ import (
 	"istio.io/istio/security/pkg/k8s/chiron"
 	"istio.io/istio/security/pkg/pki/ca"
 	"istio.io/istio/security/pkg/pki/ra"
+	"istio.io/istio/security/pkg/pki/util"
 	"istio.io/istio/security/pkg/server/ca/authenticate"
 	"istio.io/istio/security/pkg/server/ca/authenticate/kubeauth"
 	"istio.io/pkg/ctrlz"
@@ -237,6 +240,7 @@ func NewServer(args *PilotArgs) (*Server, error) {
 
 	s.initMeshNetworks(args, s.fileWatcher)
 	s.initMeshHandlers()
+	s.initIPSetWatcher(args)
 
 	// Options based on the current 'defaults' in istio.
 	caOpts := &caOptions{
@@ -255,6 +259,10 @@ func NewServer(args *PilotArgs) (*Server, error) {
 		return nil, err
 	}
 
+	if err := s.initPushTracing(); err != nil {
+		return nil, err
+	}
+
 	if err := s.initControllers(args); err != nil {
 		return nil, err
 	}
@@ -300,6 +308,7 @@ func NewServer(args *PilotArgs) (*Server, error) {
 	s.initRegistryEventHandlers()
 
 	s.initDiscoveryService(args)
+	s.initProxySharding(args)
 
 	s.initSDSServer(args)
 
@@ -323,6 +332,16 @@ func NewServer(args *PilotArgs) (*Server, error) {
 	}
 	if features.XDSAuth {
 		s.XDSServer.Authenticators = authenticators
+		if args.XDSJwtIssuers != "" {
+			xdsJwtAuthns, err := initXDSJwtAuthenticators(args, s.environment.Mesh().TrustDomain)
+			if err != nil {
+				return nil, fmt.Errorf("error initializing XDS JWT issuers: %v", err)
+			}
+			// The tokens of the proxies out of the mesh are only accepted by the XDS server, not by the CA.
+			s.XDSServer.Authenticators = append(append([]security.Authenticator{}, authenticators...), xdsJwtAuthns...)
+		}
+	} else if args.XDSJwtIssuers != "" {
+		log.Warnf("XDS_JWT_ISSUERS is ignored since XDS_AUTH is disabled")
 	}
 	caOpts.Authenticators = authenticators
 
@@ -366,6 +385,23 @@ func initOIDC(args *PilotArgs, trustDomain string) (security.Authenticator, erro
 	return jwtAuthn, nil
 }
 
+func initXDSJwtAuthenticators(args *PilotArgs, trustDomain string) ([]security.Authenticator, error) {
+	issuers, err := authenticate.ParseXDSJwtIssuers(args.XDSJwtIssuers)
+	if err != nil {
+		return nil, err
+	}
+	authenticators := make([]security.Authenticator, 0, len(issuers))
+	for _, issuer := range issuers {
+		log.Infof("XDS server authenticating the tokens of %s", issuer.Issuer)
+		authn, err := authenticate.NewXDSJwtAuthenticator(issuer, trustDomain)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the XDS JWT authenticator of %s: %v", issuer.Issuer, err)
+		}
+		authenticators = append(authenticators, authn)
+	}
+	return authenticators, nil
+}
+
 func getClusterID(args *PilotArgs) string {
 	clusterID := args.RegistryOptions.KubeOptions.ClusterID
 	if clusterID == "" {
@@ -459,6 +495,8 @@ func (s *Server) WaitUntilCompletion() {
 // initSDSServer starts the SDS server
 func (s *Server) initSDSServer(args *PilotArgs) {
 	if s.kubeClient != nil {
+		// Report the certificates about to expire on their pod or secret.
+		s.XDSServer.CertExpiry.EventSink = certexpiry.NewKubeEventSink(s.kubeClient.Kube())
 		if !features.EnableXDSIdentityCheck {
 			// Make sure we have security
 			log.Warnf("skipping Kubernetes credential reader; PILOT_ENABLE_XDS_IDENTITY_CHECK must be set to true for this feature.")
@@ -478,7 +516,7 @@ func (s *Server) initSDSServer(args *PilotArgs) {
 					Reason: []model.TriggerReason{model.SecretTrigger},
 				})
 			})
-			s.XDSServer.Generators[v3.SecretType] = xds.NewSecretGen(sc, s.XDSServer.Cache)
+			s.XDSServer.Generators[v3.SecretType] = xds.NewSecretGen(sc, s.XDSServer.Cache, s.XDSServer.CertExpiry)
 		}
 	}
 }
@@ -706,6 +744,15 @@ func (s *Server) initSecureDiscoveryService(args *PilotArgs) error {
 			return err
 		},
 	}
+	if features.SpiffeBundleEndpoints != "" {
+		// The federated SPIFFE bundles are refreshed, so each handshake uses the current pool of root certificates.
+		cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			c := cfg.Clone()
+			c.GetConfigForClient = nil
+			c.ClientCAs = s.peerCertVerifier.GetGeneralCertPool()
+			return c, nil
+		}
+	}
 
 	tlsCreds := credentials.NewTLS(cfg)
 
@@ -877,6 +924,7 @@ func (s *Server) initRegistryEventHandlers() {
 				}: {}},
 				Reason: []model.TriggerReason{model.ConfigUpdate},
 			}
+			pushReq.TraceConfigChange(curr, event)
 			s.XDSServer.ConfigUpdate(pushReq)
 			if event != model.EventDelete {
 				s.statusReporter.AddInProgressResource(curr)
@@ -1059,12 +1107,24 @@ func (s *Server) setPeerCertVerifier(tlsOptions TLSOptions) error {
 	}
 
 	if features.SpiffeBundleEndpoints != "" {
-		certMap, err := spiffe.RetrieveSpiffeBundleRootCertsFromStringInput(
-			features.SpiffeBundleEndpoints, []*x509.Certificate{})
+		endpoints, err := spiffe.ParseSpiffeBundleEndpoints(features.SpiffeBundleEndpoints)
 		if err != nil {
 			return err
 		}
-		s.peerCertVerifier.AddMappings(certMap)
+		refresher := spiffe.NewBundleRefresher(endpoints, []*x509.Certificate{}, features.SpiffeBundleRefreshInterval)
+		refresher.Refresh()
+		for _, status := range refresher.Status() {
+			if status.Stale {
+				return fmt.Errorf("failed to fetch the SPIFFE bundle of trust domain %s: %v", status.TrustDomain, status.LastError)
+			}
+		}
+		s.peerCertVerifier.AddMappings(refresher.Bundles())
+		refresher.AddHandler(s.peerCertVerifier.UpdateMappings)
+		s.XDSServer.SpiffeBundles = refresher
+		s.addStartFunc(func(stop <-chan struct{}) error {
+			go refresher.Run(stop)
+			return nil
+		})
 	}
 
 	return nil
@@ -1136,6 +1196,9 @@ func (s *Server) maybeCreateCA(caOpts *caOptions) error {
 		if s.CA, err = s.createIstioCA(corev1, caOpts); err != nil {
 			return fmt.Errorf("failed to create CA: %v", err)
 		}
+		if s.CA != nil {
+			s.initCRLWatch()
+		}
 		if caOpts.ExternalCAType != "" {
 			if s.RA, err = s.createIstioRA(s.kubeClient, caOpts); err != nil {
 				return fmt.Errorf("failed to create RA: %v", err)
@@ -1174,9 +1237,62 @@ func (s *Server) fetchCARoot() map[string]string {
 	if s.CA == nil {
 		return nil
 	}
-	return map[string]string{
+	data := map[string]string{
 		constants.CACertNamespaceConfigMapDataName: string(s.CA.GetCAKeyCertBundle().GetRootCertPem()),
 	}
+	// The certificate revocation list is optionally provided in the 'cacerts' Secret, along with the CA certificates.
+	// It is only distributed if signed by the CA, as proxies reject the whole trust anchor with an invalid list.
+	crlFile := path.Join(LocalCertDir.Get(), constants.CACRLNamespaceConfigMapDataName)
+	if crl, err := ioutil.ReadFile(crlFile); err == nil {
+		signingCert, _, _, _ := s.CA.GetCAKeyCertBundle().GetAll()
+		if signingCert == nil {
+			log.Errorf("ignoring certificate revocation list %s: the CA has no signing certificate", crlFile)
+		} else if err := util.VerifyCRL(crl, signingCert); err != nil {
+			log.Errorf("ignoring certificate revocation list %s: %v", crlFile, err)
+		} else {
+			data[constants.CACRLNamespaceConfigMapDataName] = string(crl)
+		}
+	}
+	return data
+}
+
+// initCRLWatch watches the certificate revocation list of the CA, refreshing the ConfigMaps distributing it to the
+// namespaces when it changes.
+func (s *Server) initCRLWatch() {
+	if !file.Exists(LocalCertDir.Get()) {
+		return
+	}
+	// The file watcher watches the parent directory, so the list is picked up even if created later on.
+	crlFile := path.Join(LocalCertDir.Get(), constants.CACRLNamespaceConfigMapDataName)
+	log.Infof("adding watcher for certificate revocation list %s", crlFile)
+	if err := s.fileWatcher.Add(crlFile); err != nil {
+		log.Errorf("could not watch %v: %v", crlFile, err)
+		return
+	}
+	s.addStartFunc(func(stop <-chan struct{}) error {
+		go func() {
+			var crlTimerC <-chan time.Time
+			for {
+				select {
+				case <-crlTimerC:
+					crlTimerC = nil
+					log.Info("certificate revocation list changed, refreshing namespace ConfigMaps")
+					if s.multicluster != nil {
+						s.multicluster.RefreshNamespaceData()
+					}
+				case <-s.fileWatcher.Events(crlFile):
+					if crlTimerC == nil {
+						crlTimerC = time.After(watchDebounceDelay)
+					}
+				case err := <-s.fileWatcher.Errors(crlFile):
+					log.Errorf("error watching %v: %v", crlFile, err)
+				case <-stop:
+					return
+				}
+			}
+		}()
+		return nil
+	})
 }
 
 // initMeshHandlers initializes mesh and network handlers.
@@ -0,0 +1,73 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/xds"
+	"istio.io/pkg/log"
+)
+
+// initProxySharding assigns the proxies to the ready istiod replicas, tracked from the endpoints of the istiod
+// Service of the revision.
+func (s *Server) initProxySharding(args *PilotArgs) {
+	if !features.EnableProxySharding {
+		return
+	}
+	if s.kubeClient == nil || args.PodName == "" {
+		log.Warnf("proxy sharding requires Kubernetes and POD_NAME, ignoring PILOT_ENABLE_PROXY_SHARDING")
+		return
+	}
+	shards := xds.NewProxyShards(args.PodName)
+	s.XDSServer.ProxyShards = shards
+
+	service := "istiod"
+	if args.Revision != "" {
+		service += "-" + args.Revision
+	}
+	update := func(obj interface{}, deleted bool) {
+		ep, ok := obj.(*v1.Endpoints)
+		if !ok || ep.Namespace != args.Namespace || ep.Name != service {
+			return
+		}
+		replicas := map[string]string{}
+		if !deleted {
+			replicas = shardReplicas(ep)
+		}
+		log.Infof("assigning proxies to %d istiod replicas", len(replicas))
+		shards.SetReplicas(replicas)
+	}
+	s.kubeClient.KubeInformer().Core().V1().Endpoints().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { update(obj, false) },
+		UpdateFunc: func(_, obj interface{}) { update(obj, false) },
+		DeleteFunc: func(obj interface{}) { update(obj, true) },
+	})
+}
+
+// shardReplicas returns the IPs of the ready istiod pods, keyed by name.
+func shardReplicas(ep *v1.Endpoints) map[string]string {
+	replicas := map[string]string{}
+	for _, subset := range ep.Subsets {
+		for _, addr := range subset.Addresses {
+			if addr.TargetRef != nil && addr.TargetRef.Kind == "Pod" {
+				replicas[addr.TargetRef.Name] = addr.IP
+			}
+		}
+	}
+	return replicas
+}
@@ -0,0 +1,74 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"google.golang.org/grpc/credentials"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/pkg/log"
+)
+
+// pushTracingShutdownTimeout is the time given to export the remaining spans when istiod stops.
+const pushTracingShutdownTimeout = 5 * time.Second
+
+// initPushTracing exports the spans of the xDS pushes to the configured OTLP collector. Without a collector, the
+// pushes are not traced.
+func (s *Server) initPushTracing() error {
+	if features.PushTracingCollector == "" {
+		return nil
+	}
+	if features.PushTracingSampling < 0 || features.PushTracingSampling > 1 {
+		return fmt.Errorf("invalid PILOT_PUSH_TRACING_SAMPLING %v, must be between 0.0 and 1.0", features.PushTracingSampling)
+	}
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(features.PushTracingCollector)}
+	if features.PushTracingCollectorInsecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	} else {
+		opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})))
+	}
+	// The connection to the collector is established in the background, so istiod starts without it.
+	exporter, err := otlptracegrpc.New(context.Background(), opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create the push tracing exporter: %v", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		// Spans are exported in batches, and dropped when the collector cannot keep up.
+		sdktrace.WithBatcher(exporter),
+		// Spans of a push follow the sampling decision of the span starting it.
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(features.PushTracingSampling))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String("istiod"))),
+	)
+	model.SetPushTracerProvider(provider)
+	log.Infof("exporting push traces to %s", features.PushTracingCollector)
+
+	s.addTerminatingStartFunc(func(stop <-chan struct{}) error {
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), pushTracingShutdownTimeout)
+		defer cancel()
+		return provider.Shutdown(ctx)
+	})
+	return nil
+}
@@ -0,0 +1,69 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certexpiry
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// EventReason is the reason of the Kubernetes events emitted for expiring certificates.
+const EventReason = "CertificateExpiring"
+
+// KubeEventSink emits a Kubernetes warning event on the pod of an expiring workload certificate, or on the
+// secret of an expiring gateway certificate.
+type KubeEventSink struct {
+	client kubernetes.Interface
+}
+
+var _ EventSink = &KubeEventSink{}
+
+// NewKubeEventSink returns an event sink creating events with the given client.
+func NewKubeEventSink(client kubernetes.Interface) *KubeEventSink {
+	return &KubeEventSink{client: client}
+}
+
+func (s *KubeEventSink) Warn(cert Cert, message string) {
+	kind := "Pod"
+	if cert.Kind == Gateway {
+		kind = "Secret"
+	}
+	now := metav1.Now()
+	event := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: cert.Name + ".",
+			Namespace:    cert.Namespace,
+		},
+		InvolvedObject: v1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       kind,
+			Namespace:  cert.Namespace,
+			Name:       cert.Name,
+		},
+		Reason:         EventReason,
+		Message:        message,
+		Type:           v1.EventTypeWarning,
+		Source:         v1.EventSource{Component: "istiod"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	if _, err := s.client.CoreV1().Events(cert.Namespace).Create(context.TODO(), event, metav1.CreateOptions{}); err != nil {
+		log.Warnf("failed to create event for the expiring certificate of %s/%s: %v", cert.Namespace, cert.Name, err)
+	}
+}
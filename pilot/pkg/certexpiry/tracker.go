@@ -0,0 +1,296 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package certexpiry tracks the expiration of the certificates used by the proxies connected to Istiod, and
+// warns before they expire.
+package certexpiry
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	istiolog "istio.io/pkg/log"
+	"istio.io/pkg/monitoring"
+)
+
+var log = istiolog.RegisterScope("certexpiry", "certificate expiry tracking", 0)
+
+// checkInterval is the interval at which the tracked certificates are checked.
+const checkInterval = time.Minute
+
+var (
+	kindTag = monitoring.MustCreateLabel("kind")
+
+	expiringCerts = monitoring.NewGauge(
+		"pilot_cert_expiring",
+		"Number of certificates expiring within the warning window of their kind.",
+		monitoring.WithLabels(kindTag),
+	)
+
+	earliestExpiry = monitoring.NewGauge(
+		"pilot_cert_earliest_expiry_seconds",
+		"Unix timestamp in seconds of the earliest expiration of the certificates of a kind.",
+		monitoring.WithLabels(kindTag),
+	)
+)
+
+func init() {
+	monitoring.MustRegister(expiringCerts, earliestExpiry)
+}
+
+// Kind is the kind of a tracked certificate.
+type Kind string
+
+const (
+	// Workload is the certificate presented by a proxy when connecting to Istiod. It is usually issued by Istiod.
+	Workload Kind = "workload"
+	// Gateway is a user provided certificate served to gateways through SDS.
+	Gateway Kind = "gateway"
+)
+
+// Cert is a tracked certificate.
+type Cert struct {
+	Kind      Kind   `json:"kind"`
+	Namespace string `json:"namespace"`
+	// Name is the name of the pod using a workload certificate, or the name of the secret of a gateway certificate.
+	Name string `json:"name"`
+	// Identity is the first URI or DNS SAN of the certificate, or its common name if it has none.
+	Identity string    `json:"identity,omitempty"`
+	Issuer   string    `json:"issuer,omitempty"`
+	NotAfter time.Time `json:"notAfter"`
+	// Expiring is set when the certificate expires within the warning window of its kind.
+	Expiring bool `json:"expiring"`
+}
+
+// FromX509 returns the tracked certificate of a parsed certificate.
+func FromX509(kind Kind, namespace, name string, cert *x509.Certificate) Cert {
+	identity := cert.Subject.CommonName
+	if len(cert.URIs) > 0 {
+		identity = cert.URIs[0].String()
+	} else if len(cert.DNSNames) > 0 {
+		identity = cert.DNSNames[0]
+	}
+	return Cert{
+		Kind:      kind,
+		Namespace: namespace,
+		Name:      name,
+		Identity:  identity,
+		Issuer:    cert.Issuer.String(),
+		NotAfter:  cert.NotAfter,
+	}
+}
+
+// FromPEM returns the tracked certificate of the leaf certificate of a PEM encoded certificate chain.
+func FromPEM(kind Kind, namespace, name string, certChain []byte) (Cert, error) {
+	block, _ := pem.Decode(certChain)
+	if block == nil {
+		return Cert{}, fmt.Errorf("failed to decode the certificate of %s/%s", namespace, name)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return Cert{}, fmt.Errorf("failed to parse the certificate of %s/%s: %v", namespace, name, err)
+	}
+	return FromX509(kind, namespace, name, cert), nil
+}
+
+// EventSink is notified when a certificate enters the warning window of its kind.
+type EventSink interface {
+	Warn(cert Cert, message string)
+}
+
+// Tracker tracks the expiration of certificates, and reports the ones expiring within the warning window of
+// their kind in metrics, logs and the EventSink.
+type Tracker struct {
+	windows map[Kind]time.Duration
+	// EventSink, if set, is notified once per certificate when it enters the warning window.
+	EventSink EventSink
+
+	now func() time.Time
+
+	mu    sync.RWMutex
+	certs map[string]Cert
+	// warned holds the expiration of the certificates already reported, so that a certificate is only reported
+	// again once it has been replaced.
+	warned map[string]time.Time
+	// refs holds the keys of the certificates referenced by each owner, and owners the owners referencing each key.
+	// The certificates tracked by reference are removed once no owner references them anymore.
+	refs   map[string]map[string]struct{}
+	owners map[string]map[string]struct{}
+}
+
+// NewTracker returns a tracker with the given warning windows. Certificates of a kind without a window are
+// tracked but never reported.
+func NewTracker(windows map[Kind]time.Duration) *Tracker {
+	return &Tracker{
+		windows: windows,
+		now:     time.Now,
+		certs:   map[string]Cert{},
+		warned:  map[string]time.Time{},
+		refs:    map[string]map[string]struct{}{},
+		owners:  map[string]map[string]struct{}{},
+	}
+}
+
+// Record starts or updates the tracking of a certificate under the given key.
+func (t *Tracker) Record(key string, cert Cert) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.certs[key] = cert
+}
+
+// Remove stops the tracking of the certificate under the given key.
+func (t *Tracker) Remove(key string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.certs, key)
+	delete(t.warned, key)
+}
+
+// Reference replaces the keys of the certificates referenced by the owner. The certificates no owner references
+// anymore stop being tracked.
+func (t *Tracker) Reference(owner string, keys []string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	refs := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		refs[key] = struct{}{}
+		if t.owners[key] == nil {
+			t.owners[key] = map[string]struct{}{}
+		}
+		t.owners[key][owner] = struct{}{}
+	}
+	for key := range t.refs[owner] {
+		if _, f := refs[key]; f {
+			continue
+		}
+		delete(t.owners[key], owner)
+		if len(t.owners[key]) == 0 {
+			delete(t.owners, key)
+			delete(t.certs, key)
+			delete(t.warned, key)
+		}
+	}
+	if len(refs) == 0 {
+		delete(t.refs, owner)
+	} else {
+		t.refs[owner] = refs
+	}
+}
+
+// Release drops all the references of the owner, per Reference.
+func (t *Tracker) Release(owner string) {
+	t.Reference(owner, nil)
+}
+
+// Run checks the tracked certificates periodically until the stop channel is closed.
+func (t *Tracker) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			t.Check()
+		}
+	}
+}
+
+// Check updates the metrics, and reports the certificates that entered the warning window since the last check.
+func (t *Tracker) Check() {
+	now := t.now()
+	expiring := map[Kind]int{}
+	earliest := map[Kind]time.Time{}
+	var toWarn []Cert
+
+	t.mu.Lock()
+	for key, cert := range t.certs {
+		if e, f := earliest[cert.Kind]; !f || cert.NotAfter.Before(e) {
+			earliest[cert.Kind] = cert.NotAfter
+		}
+		if !t.isExpiring(cert, now) {
+			delete(t.warned, key)
+			continue
+		}
+		expiring[cert.Kind]++
+		if warned, f := t.warned[key]; f && warned.Equal(cert.NotAfter) {
+			continue
+		}
+		t.warned[key] = cert.NotAfter
+		toWarn = append(toWarn, cert)
+	}
+	t.mu.Unlock()
+
+	for _, kind := range []Kind{Workload, Gateway} {
+		expiringCerts.With(kindTag.Value(string(kind))).Record(float64(expiring[kind]))
+		if e, f := earliest[kind]; f {
+			earliestExpiry.With(kindTag.Value(string(kind))).Record(float64(e.Unix()))
+		}
+	}
+	for _, cert := range toWarn {
+		msg := fmt.Sprintf("%s certificate of %s (issued by %s) expires at %v",
+			cert.Kind, cert.Identity, cert.Issuer, cert.NotAfter.UTC().Format(time.RFC3339))
+		if !cert.NotAfter.After(now) {
+			msg = fmt.Sprintf("%s certificate of %s (issued by %s) expired at %v",
+				cert.Kind, cert.Identity, cert.Issuer, cert.NotAfter.UTC().Format(time.RFC3339))
+		}
+		log.Warnf("%s/%s: %s", cert.Namespace, cert.Name, msg)
+		if t.EventSink != nil {
+			t.EventSink.Warn(cert, msg)
+		}
+	}
+}
+
+// Certs returns the tracked certificates, sorted by expiration.
+func (t *Tracker) Certs() []Cert {
+	now := t.now()
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	res := make([]Cert, 0, len(t.certs))
+	for _, cert := range t.certs {
+		cert.Expiring = t.isExpiring(cert, now)
+		res = append(res, cert)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if !res[i].NotAfter.Equal(res[j].NotAfter) {
+			return res[i].NotAfter.Before(res[j].NotAfter)
+		}
+		if res[i].Namespace != res[j].Namespace {
+			return res[i].Namespace < res[j].Namespace
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+func (t *Tracker) isExpiring(cert Cert, now time.Time) bool {
+	window, f := t.windows[cert.Kind]
+	if !f || window <= 0 {
+		return false
+	}
+	return cert.NotAfter.Sub(now) < window
+}
@@ -0,0 +1,131 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certexpiry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+type recordingSink struct {
+	warned []Cert
+}
+
+func (s *recordingSink) Warn(cert Cert, _ string) {
+	s.warned = append(s.warned, cert)
+}
+
+func TestTracker(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	sink := &recordingSink{}
+	tracker := NewTracker(map[Kind]time.Duration{Workload: time.Hour, Gateway: 24 * time.Hour})
+	tracker.EventSink = sink
+	tracker.now = func() time.Time { return now }
+
+	tracker.Record("pod-a", Cert{Kind: Workload, Namespace: "foo", Name: "a", NotAfter: now.Add(12 * time.Hour)})
+	tracker.Record("pod-b", Cert{Kind: Workload, Namespace: "foo", Name: "b", NotAfter: now.Add(30 * time.Minute)})
+	tracker.Record("secret-c", Cert{Kind: Gateway, Namespace: "bar", Name: "c", NotAfter: now.Add(12 * time.Hour)})
+
+	tracker.Check()
+	if len(sink.warned) != 2 || sink.warned[0].Name == "a" || sink.warned[1].Name == "a" {
+		t.Fatalf("expected b and c to be reported, got %+v", sink.warned)
+	}
+
+	// Certificates are only reported once.
+	tracker.Check()
+	if len(sink.warned) != 2 {
+		t.Fatalf("expected no new report, got %+v", sink.warned)
+	}
+
+	// A replaced certificate still expiring is reported again.
+	tracker.Record("pod-b", Cert{Kind: Workload, Namespace: "foo", Name: "b", NotAfter: now.Add(40 * time.Minute)})
+	tracker.Check()
+	if len(sink.warned) != 3 || sink.warned[2].Name != "b" {
+		t.Fatalf("expected the replaced certificate of b to be reported, got %+v", sink.warned)
+	}
+
+	tracker.Remove("secret-c")
+	certs := tracker.Certs()
+	if len(certs) != 2 || certs[0].Name != "b" || !certs[0].Expiring || certs[1].Name != "a" || certs[1].Expiring {
+		t.Errorf("unexpected certificates: %+v", certs)
+	}
+}
+
+func TestTrackerReference(t *testing.T) {
+	tracker := NewTracker(map[Kind]time.Duration{Gateway: time.Hour})
+	cert := Cert{Kind: Gateway, Namespace: "foo", Name: "c", NotAfter: time.Now()}
+	tracker.Record("secret-c", cert)
+	tracker.Reference("gateway-1", []string{"secret-c"})
+	tracker.Reference("gateway-2", []string{"secret-c"})
+
+	// The certificate is tracked as long as a gateway references it.
+	tracker.Reference("gateway-1", nil)
+	if len(tracker.Certs()) != 1 {
+		t.Fatalf("expected the certificate referenced by gateway-2 to be tracked, got %+v", tracker.Certs())
+	}
+	tracker.Release("gateway-2")
+	if len(tracker.Certs()) != 0 {
+		t.Fatalf("expected the unreferenced certificate to be removed, got %+v", tracker.Certs())
+	}
+}
+
+func TestFromPEM(t *testing.T) {
+	notBefore := time.Now().Truncate(time.Second)
+	certPEM, _, err := util.GenCertKeyFromOptions(util.CertOptions{
+		Host:         "spiffe://cluster.local/ns/foo/sa/bar",
+		NotBefore:    notBefore,
+		TTL:          time.Hour,
+		Org:          "istio",
+		RSAKeySize:   2048,
+		IsSelfSigned: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := FromPEM(Gateway, "foo", "gateway-cert", certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Identity != "spiffe://cluster.local/ns/foo/sa/bar" || !cert.NotAfter.Equal(notBefore.Add(time.Hour)) {
+		t.Errorf("unexpected certificate: %+v", cert)
+	}
+
+	if _, err := FromPEM(Gateway, "foo", "gateway-cert", []byte("invalid")); err == nil {
+		t.Errorf("expected an error for an invalid certificate")
+	}
+}
+
+func TestKubeEventSink(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	NewKubeEventSink(client).Warn(Cert{Kind: Gateway, Namespace: "foo", Name: "gateway-cert"}, "expiring")
+
+	events, err := client.CoreV1().Events("foo").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events.Items) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events.Items))
+	}
+	event := events.Items[0]
+	if event.Reason != EventReason || event.InvolvedObject.Kind != "Secret" || event.InvolvedObject.Name != "gateway-cert" {
+		t.Errorf("unexpected event: %+v", event)
+	}
+}
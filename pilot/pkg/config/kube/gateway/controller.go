@@ -87,6 +87,11 @@ func (c controller) List(typ config.GroupVersionKind, namespace string) ([]confi
 		return nil, fmt.Errorf("failed to list type BackendPolicy: %v", err)
 	}
 
+	istioGateway, err := c.cache.List(gvk.Gateway, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list type Gateway: %v", err)
+	}
+
 	input := &KubernetesResources{
 		GatewayClass:  gatewayClass,
 		Gateway:       gateway,
@@ -94,6 +99,7 @@ func (c controller) List(typ config.GroupVersionKind, namespace string) ([]confi
 		TCPRoute:      tcpRoute,
 		TLSRoute:      tlsRoute,
 		BackendPolicy: backendPolicy,
+		IstioGateway:  istioGateway,
 		Domain:        c.domain,
 	}
 
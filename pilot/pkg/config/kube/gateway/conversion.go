@@ -27,7 +27,9 @@ import (
 	istio "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/gateway"
 	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/protocol"
 	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/pkg/log"
 )
@@ -44,6 +46,8 @@ type KubernetesResources struct {
 	TLSRoute      []config.Config
 	BackendPolicy []config.Config
 	Namespaces    map[string]*corev1.Namespace
+	// IstioGateway are the Istio Gateways, whose servers take precedence over the conflicting listeners.
+	IstioGateway []config.Config
 
 	// Domain for the cluster. Typically cluster.local
 	Domain string
@@ -398,8 +402,7 @@ func buildTLSVirtualService(obj config.Config, gateways []string, domain string)
 			Domain:            domain,
 		},
 		Spec: &istio.VirtualService{
-			// TODO investigate if we should/must constrain this to avoid conflicts
-			Hosts:    []string{"*"},
+			Hosts:    tlsRouteHosts(routes),
 			Gateways: gateways,
 			Tls:      routes,
 		},
@@ -407,6 +410,30 @@ func buildTLSVirtualService(obj config.Config, gateways []string, domain string)
 	return vsConfig
 }
 
+// tlsRouteHosts returns the hosts of the virtual service of a TLSRoute: the SNI hosts matched by its rules, so that
+// routes for different SNI hosts don't conflict, or all hosts if a rule matches any SNI host.
+func tlsRouteHosts(routes []*istio.TLSRoute) []string {
+	hosts := []string{}
+	seen := map[string]bool{}
+	for _, r := range routes {
+		for _, m := range r.Match {
+			for _, sni := range m.SniHosts {
+				if sni == "*" {
+					return []string{"*"}
+				}
+				if !seen[sni] {
+					seen[sni] = true
+					hosts = append(hosts, sni)
+				}
+			}
+		}
+	}
+	if len(hosts) == 0 {
+		return []string{"*"}
+	}
+	return hosts
+}
+
 func buildTCPDestination(action []k8s.RouteForwardTo, ns string) []*istio.RouteDestination {
 	if len(action) == 0 {
 		return nil
@@ -652,6 +679,8 @@ func convertGateway(r *KubernetesResources) ([]config.Config, map[RouteKey][]str
 			continue
 		}
 		name := obj.Name + "-" + constants.KubernetesGatewayName
+		// TODO derive this from gatewayclass param ref
+		selector := labels.Instance{constants.IstioLabel: "ingressgateway"}
 		var servers []*istio.Server
 		for _, l := range kgw.Listeners {
 			server := &istio.Server{
@@ -667,6 +696,11 @@ func convertGateway(r *KubernetesResources) ([]config.Config, map[RouteKey][]str
 				// TODO support RouteOverride
 				Tls: buildTLS(l.TLS),
 			}
+			if conflict := r.conflictingIstioGateway(server, selector); conflict != nil {
+				log.Warnf("skipping listener %s.%d of gateway %s/%s: conflict with a server of Istio gateway %s/%s",
+					l.Protocol, l.Port, obj.Namespace, obj.Name, conflict.Namespace, conflict.Name)
+				continue
+			}
 
 			servers = append(servers, server)
 
@@ -693,9 +727,8 @@ func convertGateway(r *KubernetesResources) ([]config.Config, map[RouteKey][]str
 				Domain:            r.Domain,
 			},
 			Spec: &istio.Gateway{
-				Servers: servers,
-				// TODO derive this from gatewayclass param ref
-				Selector: labels.Instance{constants.IstioLabel: "ingressgateway"},
+				Servers:  servers,
+				Selector: selector,
 			},
 		}
 		result = append(result, gatewayConfig)
@@ -703,6 +736,50 @@ func convertGateway(r *KubernetesResources) ([]config.Config, map[RouteKey][]str
 	return result, routeToGateway
 }
 
+// conflictingIstioGateway returns the Istio Gateway with a server conflicting with the server of a listener, on the
+// workloads selected by the selector, if any.
+func (r *KubernetesResources) conflictingIstioGateway(server *istio.Server, selector labels.Instance) *config.Config {
+	for i, obj := range r.IstioGateway {
+		gw := obj.Spec.(*istio.Gateway)
+		gwSelector := labels.Instance(gw.Selector)
+		if !gwSelector.SubsetOf(selector) && !selector.SubsetOf(gwSelector) {
+			// The gateways select different workloads.
+			continue
+		}
+		for _, s := range gw.Servers {
+			if serversConflict(server, s) {
+				return &r.IstioGateway[i]
+			}
+		}
+	}
+	return nil
+}
+
+// serversConflict returns true if two gateway servers can't be served on the same port of a workload. As when the
+// gateways are merged, plain text servers can only share a port if both are HTTP, TLS servers if their hosts are
+// distinct, and plain text and TLS servers never share a port.
+func serversConflict(a, b *istio.Server) bool {
+	if a.GetPort().GetNumber() != b.GetPort().GetNumber() {
+		return false
+	}
+	aTLS, bTLS := gateway.IsTLSServer(a), gateway.IsTLSServer(b)
+	switch {
+	case !aTLS && !bTLS:
+		return !protocol.Parse(a.Port.Protocol).IsHTTP() || !protocol.Parse(b.Port.Protocol).IsHTTP()
+	case aTLS && bTLS:
+		for _, h := range a.Hosts {
+			for _, other := range b.Hosts {
+				if h == other {
+					return true
+				}
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
 func buildTLS(tls *k8s.GatewayTLSConfig) *istio.ServerTLSSettings {
 	if tls == nil {
 		return nil
@@ -42,6 +42,7 @@ func TestConvertResources(t *testing.T) {
 		"mismatch",
 		"weighted",
 		"backendpolicy",
+		"conflict",
 	}
 	for _, tt := range cases {
 		t.Run(tt, func(t *testing.T) {
@@ -99,6 +100,8 @@ func splitInput(configs []config.Config) *KubernetesResources {
 			out.TLSRoute = append(out.TLSRoute, c)
 		case gvk.BackendPolicy:
 			out.BackendPolicy = append(out.BackendPolicy, c)
+		case gvk.Gateway:
+			out.IstioGateway = append(out.IstioGateway, c)
 		}
 	}
 	return out
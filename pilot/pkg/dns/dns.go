@@ -36,8 +36,11 @@ type LocalDNSServer struct {
 	udpDNSProxy *dnsProxy
 	tcpDNSProxy *dnsProxy
 
-	resolvConfServers []string
-	searchNamespaces  []string
+	upstreams        []Upstream
+	searchNamespaces []string
+	// The hosts with fewer dots are expanded with the search namespaces, or all hosts when zero
+	ndots           int
+	searchExpansion SearchExpansion
 	// The namespace where the proxy resides
 	// determines the hosts used for shortname resolution
 	proxyNamespace string
@@ -72,9 +75,13 @@ const (
 	defaultTTLInSeconds = 30
 )
 
-func NewLocalDNSServer(proxyNamespace, proxyDomain string) (*LocalDNSServer, error) {
+func NewLocalDNSServer(proxyNamespace, proxyDomain string, opts Options) (*LocalDNSServer, error) {
 	h := &LocalDNSServer{
-		proxyNamespace: proxyNamespace,
+		proxyNamespace:   proxyNamespace,
+		upstreams:        opts.Upstreams,
+		searchNamespaces: opts.SearchDomains,
+		ndots:            opts.Ndots,
+		searchExpansion:  opts.SearchExpansion,
 	}
 
 	// proxyDomain could contain the namespace making it redundant.
@@ -88,11 +95,15 @@ func NewLocalDNSServer(proxyNamespace, proxyDomain string) (*LocalDNSServer, err
 		h.proxyDomain = strings.Join(parts, ".")
 	}
 
-	// We will use the local resolv.conf for resolving unknown names.
-	dnsConfig, err := dns.ClientConfigFromFile("/etc/resolv.conf")
-	if err != nil {
-		log.Warnf("failed to load /etc/resolv.conf: %v", err)
-		return nil, err
+	// We will use the local resolv.conf for resolving unknown names, unless the options override it.
+	var dnsConfig *dns.ClientConfig
+	var err error
+	if len(h.upstreams) == 0 || len(h.searchNamespaces) == 0 {
+		dnsConfig, err = dns.ClientConfigFromFile("/etc/resolv.conf")
+		if err != nil {
+			log.Warnf("failed to load /etc/resolv.conf: %v", err)
+			return nil, err
+		}
 	}
 
 	// Unlike traditional DNS resolvers, we do not need to append the search
@@ -103,13 +114,18 @@ func NewLocalDNSServer(proxyNamespace, proxyDomain string) (*LocalDNSServer, err
 	// name in our local nametable. If not, we will forward the query to the
 	// upstream resolvers as is.
 	if dnsConfig != nil {
-		for _, s := range dnsConfig.Servers {
-			h.resolvConfServers = append(h.resolvConfServers, net.JoinHostPort(s, dnsConfig.Port))
+		if len(h.upstreams) == 0 {
+			for _, s := range dnsConfig.Servers {
+				h.upstreams = append(h.upstreams, Upstream{Address: net.JoinHostPort(s, dnsConfig.Port)})
+			}
+		}
+		if len(h.searchNamespaces) == 0 {
+			h.searchNamespaces = dnsConfig.Search
 		}
-		h.searchNamespaces = dnsConfig.Search
 	}
 
-	log.WithLabels("search", h.searchNamespaces, "servers", h.resolvConfServers).Debugf("initialized DNS")
+	log.WithLabels("search", h.searchNamespaces, "servers", h.upstreams, "ndots", h.ndots,
+		"expansion", h.searchExpansion).Debugf("initialized DNS")
 
 	if h.udpDNSProxy, err = newDNSProxy("udp", h); err != nil {
 		return nil, err
@@ -150,7 +166,7 @@ func (h *LocalDNSServer) UpdateLookupTable(nt *nds.NameTable) {
 			// malformed ips
 			continue
 		}
-		lookupTable.buildDNSAnswers(altHosts, ipv4, ipv6, h.searchNamespaces)
+		lookupTable.buildDNSAnswers(altHosts, ipv4, ipv6, expansionDomains(h.searchNamespaces, h.searchExpansion), h.ndots)
 	}
 	h.lookupTable.Store(lookupTable)
 	log.Debugf("updated lookup table with %d hosts", len(lookupTable.allHosts))
@@ -204,7 +220,7 @@ func (h *LocalDNSServer) ServeDNS(proxy *dnsProxy, w dns.ResponseWriter, req *dn
 		}
 	} else {
 		// We did not find the host in our internal cache. Query upstream and return the response as is.
-		response = h.queryUpstream(proxy.upstreamClient, req)
+		response = h.queryUpstream(proxy.upstreamClients, req)
 	}
 	_ = w.WriteMsg(response)
 	log.Debugf("response for hostname %q (found=%v): %v", hostname, hostFound, response)
@@ -215,13 +231,19 @@ func (h *LocalDNSServer) Close() {
 	h.tcpDNSProxy.close()
 }
 
+// queryUpstream queries the upstream resolvers in order, failing over to the next one when a resolver
+// does not answer or answers with a server failure.
 // TODO: Figure out how to send parallel queries to all nameservers
-func (h *LocalDNSServer) queryUpstream(upstreamClient *dns.Client, req *dns.Msg) *dns.Msg {
+func (h *LocalDNSServer) queryUpstream(upstreamClients []*dns.Client, req *dns.Msg) *dns.Msg {
 	var response *dns.Msg
-	for _, upstream := range h.resolvConfServers {
-		cResponse, _, err := upstreamClient.Exchange(req, upstream)
-		if err == nil {
-			response = cResponse
+	for i, upstream := range h.upstreams {
+		cResponse, _, err := upstreamClients[i].Exchange(req, upstream.Address)
+		if err != nil {
+			log.Debugf("upstream %s failed: %v", upstream.Address, err)
+			continue
+		}
+		response = cResponse
+		if response.Rcode != dns.RcodeServerFailure {
 			break
 		}
 	}
@@ -322,12 +344,15 @@ func (table *LookupTable) lookupHost(qtype uint16, hostname string) ([]dns.RR, b
 // search process down to just two DNS queries. This will eliminate unnecessary upstream DNS queries from the
 // agent, reduce load on DNS servers and improve overall latency. This idea was borrowed and adapted from
 // the autopath plugin in coredns. The implementation here is very different from auto path though.
+// Hosts with at least ndots dots are not expanded when ndots is set, as the client's resolver
+// queries them as is first.
 // Autopath does inline computation to see if the given query could potentially match something else
 // and then returns a CNAME record. In our case, we preemptively store these random dns names as a host
 // in the lookup table with a CNAME record as the DNS response. This technique eliminates the need
 // to do string parsing, memory allocations, etc. at query time at the cost of Nx number of entries (i.e. memory) to store
 // the lookup table, where N is number of search namespaces.
-func (table *LookupTable) buildDNSAnswers(altHosts map[string]struct{}, ipv4 []net.IP, ipv6 []net.IP, searchNamespaces []string) {
+func (table *LookupTable) buildDNSAnswers(altHosts map[string]struct{}, ipv4 []net.IP, ipv6 []net.IP,
+	searchNamespaces []string, ndots int) {
 	for h := range altHosts {
 		h = strings.ToLower(h)
 		table.allHosts[h] = struct{}{}
@@ -337,15 +362,17 @@ func (table *LookupTable) buildDNSAnswers(altHosts map[string]struct{}, ipv4 []n
 		if len(ipv6) > 0 {
 			table.name6[h] = aaaa(h, ipv6)
 		}
-		if len(searchNamespaces) > 0 {
-			// NOTE: Right now, rather than storing one expanded host for each one of the search namespace
-			// entries, we are going to store just the first one (assuming that most clients will
-			// do sequential dns resolution, starting with the first search namespace)
-
+		if ndots > 0 && strings.Count(h, ".")-1 >= ndots {
+			continue
+		}
+		// NOTE: By default, rather than storing one expanded host for each one of the search namespace
+		// entries, we are going to store just the first one (assuming that most clients will
+		// do sequential dns resolution, starting with the first search namespace)
+		for _, searchNamespace := range searchNamespaces {
 			// host h already ends with a .
 			// search namespace might not. So we append one in the end if needed
-			expandedHost := strings.ToLower(h + searchNamespaces[0])
-			if !strings.HasSuffix(searchNamespaces[0], ".") {
+			expandedHost := strings.ToLower(h + searchNamespace)
+			if !strings.HasSuffix(searchNamespace, ".") {
 				expandedHost += "."
 			}
 			// make sure this is not a proper hostname
@@ -17,6 +17,7 @@ package dns
 import (
 	"net"
 	"reflect"
+	"sort"
 	"testing"
 	"time"
 
@@ -37,7 +38,7 @@ func init() {
 
 func initDNS() error {
 	var err error
-	testAgentDNS, err = NewLocalDNSServer("ns1", "ns1.svc.cluster.local")
+	testAgentDNS, err = NewLocalDNSServer("ns1", "ns1.svc.cluster.local", Options{})
 	if err != nil {
 		return err
 	}
@@ -246,6 +247,129 @@ func TestDNS(t *testing.T) {
 	testAgentDNS.Close()
 }
 
+func TestBuildDNSAnswersSearchExpansion(t *testing.T) {
+	searchNamespaces := []string{"ns1.svc.cluster.local", "svc.cluster.local"}
+	ips := []net.IP{net.ParseIP("1.1.1.1").To4()}
+	testCases := []struct {
+		name      string
+		expansion SearchExpansion
+		ndots     int
+		host      string
+		expected  []string
+	}{
+		{
+			name:     "first search namespace by default",
+			host:     "www.google.com.",
+			expected: []string{"www.google.com.ns1.svc.cluster.local."},
+		},
+		{
+			name:      "all search namespaces",
+			expansion: SearchExpansionAll,
+			host:      "www.google.com.",
+			expected:  []string{"www.google.com.ns1.svc.cluster.local.", "www.google.com.svc.cluster.local."},
+		},
+		{
+			name:      "no expansion",
+			expansion: SearchExpansionNone,
+			host:      "www.google.com.",
+		},
+		{
+			name:  "host with ndots dots is not expanded",
+			ndots: 2,
+			host:  "www.google.com.",
+		},
+		{
+			name:     "host with fewer than ndots dots is expanded",
+			ndots:    3,
+			host:     "www.google.com.",
+			expected: []string{"www.google.com.ns1.svc.cluster.local."},
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			table := &LookupTable{
+				allHosts: map[string]struct{}{},
+				name4:    map[string][]dns.RR{},
+				name6:    map[string][]dns.RR{},
+				cname:    map[string][]dns.RR{},
+			}
+			table.buildDNSAnswers(map[string]struct{}{tt.host: {}}, ips, nil,
+				expansionDomains(searchNamespaces, tt.expansion), tt.ndots)
+			var got []string
+			for h := range table.cname {
+				got = append(got, h)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected expanded hosts %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestQueryUpstreamFailover(t *testing.T) {
+	servfail := startTestUpstream(t, func(w dns.ResponseWriter, req *dns.Msg) {
+		res := new(dns.Msg)
+		res.SetRcode(req, dns.RcodeServerFailure)
+		_ = w.WriteMsg(res)
+	})
+	answer := a("www.bing.com.", []net.IP{net.ParseIP("3.3.3.3").To4()})
+	healthy := startTestUpstream(t, func(w dns.ResponseWriter, req *dns.Msg) {
+		res := new(dns.Msg)
+		res.SetReply(req)
+		res.Answer = answer
+		_ = w.WriteMsg(res)
+	})
+	// Nothing answers on this address, the query times out.
+	silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+
+	h := &LocalDNSServer{
+		upstreams: []Upstream{
+			{Address: silent.LocalAddr().String(), Timeout: 100 * time.Millisecond},
+			{Address: servfail},
+			{Address: healthy},
+		},
+	}
+	clients := make([]*dns.Client, 0, len(h.upstreams))
+	for _, upstream := range h.upstreams {
+		clients = append(clients, &dns.Client{Net: "udp", Timeout: upstream.Timeout})
+	}
+	req := new(dns.Msg)
+	req.SetQuestion("www.bing.com.", dns.TypeA)
+	res := h.queryUpstream(clients, req)
+	if res.Rcode != dns.RcodeSuccess {
+		t.Fatalf("expected the healthy upstream to answer, got rcode %d", res.Rcode)
+	}
+	if !equalsDNSrecords(res.Answer, answer) {
+		t.Errorf("expected answer %v, got %v", answer, res.Answer)
+	}
+
+	h.upstreams = h.upstreams[:2]
+	if res := h.queryUpstream(clients[:2], req); res.Rcode != dns.RcodeServerFailure {
+		t.Errorf("expected a server failure when no upstream answers, got rcode %d", res.Rcode)
+	}
+}
+
+// startTestUpstream starts a UDP DNS server answering with the handler and returns its address.
+func startTestUpstream(t *testing.T, handler dns.HandlerFunc) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &dns.Server{PacketConn: pc, Handler: handler}
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+	t.Cleanup(func() {
+		_ = server.Shutdown()
+	})
+	return pc.LocalAddr().String()
+}
+
 // reflect.DeepEqual doesn't seem to work well for dns.RR
 // as the Rdlength field is not updated in the a(), or aaaa() calls.
 // so zero them out before doing reflect.Deepequal
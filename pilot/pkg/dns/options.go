@@ -0,0 +1,121 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// SearchExpansion is how the hosts of the lookup table are expanded with the search domains, so that the
+// queries of the application resolver going through its search list are answered with a CNAME to the host.
+type SearchExpansion string
+
+const (
+	// SearchExpansionFirst expands the hosts with the first search domain only. This is the default.
+	SearchExpansionFirst SearchExpansion = "first"
+	// SearchExpansionAll expands the hosts with every search domain.
+	SearchExpansionAll SearchExpansion = "all"
+	// SearchExpansionNone does not expand the hosts, all the expanded queries are forwarded upstream.
+	SearchExpansionNone SearchExpansion = "none"
+)
+
+// defaultDNSPort is the port of the upstream resolvers given without one.
+const defaultDNSPort = "53"
+
+// Upstream is a resolver the queries for the hosts missing from the lookup table are forwarded to.
+type Upstream struct {
+	// Address of the resolver, as host:port.
+	Address string
+	// Timeout of a query to the resolver, after which the next resolver is tried.
+	// Zero uses the default timeout of the DNS client.
+	Timeout time.Duration
+}
+
+// Options override the behavior inherited from resolv.conf by the DNS proxy.
+type Options struct {
+	// Upstreams are the resolvers tried in order until one answers. Defaults to the nameservers of resolv.conf.
+	Upstreams []Upstream
+	// SearchDomains the hosts are expanded with. Defaults to the search domains of resolv.conf.
+	SearchDomains []string
+	// Ndots restricts the expansion to the hosts with fewer dots, which are the only ones the application
+	// resolver queries with its search list first. Zero expands all the hosts.
+	Ndots int
+	// SearchExpansion is how the hosts are expanded with the search domains. Defaults to SearchExpansionFirst.
+	SearchExpansion SearchExpansion
+}
+
+// ParseUpstreams parses a comma separated list of upstream resolvers, each in the format
+// <address>[:<port>][/<timeout>], e.g. "10.0.0.10/2s,[fd00::10]:5353/500ms".
+func ParseUpstreams(value string) ([]Upstream, error) {
+	var upstreams []Upstream
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		var upstream Upstream
+		address := entry
+		if i := strings.LastIndex(entry, "/"); i >= 0 {
+			timeout, err := time.ParseDuration(entry[i+1:])
+			if err != nil || timeout <= 0 {
+				return nil, fmt.Errorf("invalid timeout of DNS upstream %q", entry)
+			}
+			upstream.Timeout = timeout
+			address = entry[:i]
+		}
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			// No port, possibly an IPv6 address without brackets.
+			host, port = strings.Trim(address, "[]"), defaultDNSPort
+		}
+		if host == "" || port == "" {
+			return nil, fmt.Errorf("invalid address of DNS upstream %q", entry)
+		}
+		upstream.Address = net.JoinHostPort(host, port)
+		upstreams = append(upstreams, upstream)
+	}
+	return upstreams, nil
+}
+
+// ParseSearchExpansion parses a search expansion mode, defaulting to SearchExpansionFirst when empty.
+func ParseSearchExpansion(value string) (SearchExpansion, error) {
+	switch e := SearchExpansion(strings.ToLower(strings.TrimSpace(value))); e {
+	case "":
+		return SearchExpansionFirst, nil
+	case SearchExpansionFirst, SearchExpansionAll, SearchExpansionNone:
+		return e, nil
+	default:
+		return "", fmt.Errorf("invalid DNS search expansion %q, expected one of %q, %q or %q",
+			value, SearchExpansionFirst, SearchExpansionAll, SearchExpansionNone)
+	}
+}
+
+// expansionDomains returns the search domains the hosts are expanded with.
+func expansionDomains(searchDomains []string, expansion SearchExpansion) []string {
+	switch expansion {
+	case SearchExpansionNone:
+		return nil
+	case SearchExpansionAll:
+		return searchDomains
+	default:
+		if len(searchDomains) == 0 {
+			return nil
+		}
+		return searchDomains[:1]
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseUpstreams(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected []Upstream
+		err      bool
+	}{
+		{value: ""},
+		{
+			value: "10.0.0.10, 10.0.0.11:5353/2s",
+			expected: []Upstream{
+				{Address: "10.0.0.10:53"},
+				{Address: "10.0.0.11:5353", Timeout: 2 * time.Second},
+			},
+		},
+		{
+			value: "fd00::10/500ms,[fd00::11],[fd00::12]:5353",
+			expected: []Upstream{
+				{Address: "[fd00::10]:53", Timeout: 500 * time.Millisecond},
+				{Address: "[fd00::11]:53"},
+				{Address: "[fd00::12]:5353"},
+			},
+		},
+		{value: "dns.example.com", expected: []Upstream{{Address: "dns.example.com:53"}}},
+		{value: "10.0.0.10/fast", err: true},
+		{value: "10.0.0.10/0s", err: true},
+		{value: ":53", err: true},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseUpstreams(tt.value)
+			if (err != nil) != tt.err {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseSearchExpansion(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected SearchExpansion
+		err      bool
+	}{
+		{value: "", expected: SearchExpansionFirst},
+		{value: "first", expected: SearchExpansionFirst},
+		{value: "All", expected: SearchExpansionAll},
+		{value: "none", expected: SearchExpansionNone},
+		{value: "some", err: true},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseSearchExpansion(tt.value)
+			if (err != nil) != tt.err {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
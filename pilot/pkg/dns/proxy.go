@@ -24,22 +24,25 @@ type dnsProxy struct {
 	downstreamMux    *dns.ServeMux
 	downstreamServer *dns.Server
 
-	// This is the upstream Client used to make upstream DNS queries
-	// in case the data is not in our cache.
-	upstreamClient *dns.Client
-	protocol       string
-	resolver       *LocalDNSServer
+	// These are the upstream Clients used to make upstream DNS queries
+	// in case the data is not in our cache, one per upstream resolver.
+	upstreamClients []*dns.Client
+	protocol        string
+	resolver        *LocalDNSServer
 }
 
 func newDNSProxy(protocol string, resolver *LocalDNSServer) (*dnsProxy, error) {
 	p := &dnsProxy{
 		downstreamMux:    dns.NewServeMux(),
 		downstreamServer: &dns.Server{},
-		upstreamClient: &dns.Client{
-			Net: protocol,
-		},
-		protocol: protocol,
-		resolver: resolver,
+		protocol:         protocol,
+		resolver:         resolver,
+	}
+	for _, upstream := range resolver.upstreams {
+		p.upstreamClients = append(p.upstreamClients, &dns.Client{
+			Net:     protocol,
+			Timeout: upstream.Timeout,
+		})
 	}
 
 	var err error
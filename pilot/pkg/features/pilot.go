@@ -80,6 +80,15 @@ var (
 			"for this time, we'll trigger a push.",
 	).Get()
 
+	DebounceByKind = env.RegisterStringVar(
+		"PILOT_DEBOUNCE_BY_KIND",
+		"",
+		"Overrides PILOT_DEBOUNCE_AFTER and PILOT_DEBOUNCE_MAX for the events of some config kinds. This is a comma "+
+			"separated list of kinds with their debounce delay and optionally their max delay, such as "+
+			"\"Endpoints=5ms,EnvoyFilter=1s/30s\". The Endpoints kind stands for the endpoint updates. When events of "+
+			"different kinds are merged, the shortest delays apply.",
+	).Get()
+
 	EnableEDSDebounce = env.RegisterBoolVar(
 		"PILOT_ENABLE_EDS_DEBOUNCE",
 		true,
@@ -153,6 +162,13 @@ var (
 		"EnableThriftFilter enables injection of `envoy.filters.network.thrift_proxy` in the filter chain.",
 	).Get()
 
+	EnableQUICListeners = env.RegisterBoolVar(
+		"PILOT_ENABLE_QUIC_LISTENERS",
+		false,
+		"If enabled, gateway servers with protocol HTTP3 are also served over QUIC, with a UDP listener on the "+
+			"same port, and advertise it with an alt-svc response header. Otherwise they are served as HTTPS only.",
+	).Get()
+
 	// SkipValidateTrustDomain tells the server proxy to not to check the peer's trust domain when
 	// mTLS is enabled in authentication policy.
 	SkipValidateTrustDomain = env.RegisterBoolVar(
@@ -160,6 +176,51 @@ var (
 		false,
 		"Skip validating the peer is from the same trust domain when mTLS is enabled in authentication policy")
 
+	// TrustDomainMigrationFrom is the trust domain the mesh is being migrated from. During the migration,
+	// it is accepted as an alias of the current trust domain.
+	TrustDomainMigrationFrom = env.RegisterStringVar(
+		"PILOT_TRUST_DOMAIN_MIGRATION_FROM",
+		"",
+		"The trust domain the mesh is being migrated from. While set, identities from both this trust domain "+
+			"and the current one are accepted by peer authentication and authorization policies, so that "+
+			"workloads can be moved to the new trust domain without an outage.",
+	).Get()
+
+	EnableExtAuthzProviderFailover = env.RegisterBoolVar(
+		"PILOT_ENABLE_EXT_AUTHZ_PROVIDER_FAILOVER",
+		false,
+		"If enabled, the endpoints of the ext_authz extension providers are actively health checked, and ejected "+
+			"by a default outlier detection unless a DestinationRule configures one, so that the authorization "+
+			"requests fail over to the healthy endpoints.",
+	).Get()
+
+	EnableExtAuthzProviderStatPrefix = env.RegisterBoolVar(
+		"PILOT_ENABLE_EXT_AUTHZ_PROVIDER_STAT_PREFIX",
+		false,
+		"If enabled, the stats of the HTTP ext_authz filters are prefixed with the name of their extension provider, "+
+			"so that the failures of each provider can be told apart.",
+	).Get()
+
+	AuthzIPSetConfigMap = env.RegisterStringVar(
+		"PILOT_AUTHZ_IP_SET_CONFIGMAP",
+		"istio-ip-sets",
+		"The name of the ConfigMap in the istiod namespace holding the IP sets that can be referenced from the "+
+			"IP blocks of AuthorizationPolicies with \"ipset:<name>\". Each key is the name of an IP set, and its "+
+			"value the list of IPs and CIDRs of the set. Rules referencing a missing or empty IP set fail closed: "+
+			"ALLOW rules are skipped, and other rules match all requests. Empty disables IP sets.",
+	).Get()
+
+	// AuthzShadowComparison enables the comparison of the RBAC filter config generated by the current and the next
+	// version of the authorization policy generation on live traffic.
+	AuthzShadowComparison = env.RegisterBoolVar(
+		"PILOT_AUTHZ_SHADOW_COMPARISON",
+		false,
+		"If enabled, the RBAC filters enforce the authorization policies as generated by the current version of "+
+			"Istio, and evaluate them as generated by the next version in shadow mode. Divergences are reported by "+
+			"the rbac.denied and rbac.shadow_denied stats of Envoy, so that upgrades can be validated on live traffic "+
+			"before switching enforcement. Dry-run policies are evaluated in shadow mode as generated by the next version.",
+	).Get()
+
 	EnableProtocolSniffingForOutbound = env.RegisterBoolVar(
 		"PILOT_ENABLE_PROTOCOL_SNIFFING_FOR_OUTBOUND",
 		true,
@@ -243,6 +304,15 @@ var (
 			"To ensure proper security, PILOT_ENABLE_XDS_IDENTITY_CHECK=true is required as well.",
 	).Get()
 
+	EnableSidecarCredentialName = env.RegisterBoolVar(
+		"PILOT_ENABLE_SIDECAR_CREDENTIAL_NAME",
+		false,
+		"If enabled, sidecars originate TLS with the credentialName of DestinationRules, whose client certificate "+
+			"and CA certificate are served by Istiod over SDS as for gateways. The service accounts of the workloads "+
+			"must be allowed to read the secrets, which must be in their namespace. If disabled, sidecars ignore the "+
+			"TLS settings with a credentialName.",
+	).Get()
+
 	EnableCRDValidation = env.RegisterBoolVar(
 		"PILOT_ENABLE_CRD_VALIDATION",
 		false,
@@ -258,6 +328,13 @@ var (
 			"Istio Resources",
 	).Get()
 
+	ExternalAnalyzers = env.RegisterStringVar(
+		"PILOT_EXTERNAL_ANALYZERS",
+		"",
+		"Comma separated list of external analyzer executables, or directories of such executables, run alongside "+
+			"the built-in analyzers when PILOT_ENABLE_ANALYSIS is enabled.",
+	).Get()
+
 	EnableStatus = env.RegisterBoolVar(
 		"PILOT_ENABLE_STATUS",
 		false,
@@ -334,12 +411,51 @@ var (
 		"If enabled, pilot will authorize XDS clients, to ensure they are acting only as namespaces they have permissions for.",
 	).Get()
 
+	EnableProxySharding = env.RegisterBoolVar(
+		"PILOT_ENABLE_PROXY_SHARDING",
+		false,
+		"If enabled, proxies are assigned to the ready istiod replicas by consistent hashing of their ID. A proxy "+
+			"connecting to another replica is redirected to its own, so each replica only serves its share of the proxies.",
+	).Get()
+
 	EnableServiceEntrySelectPods = env.RegisterBoolVar("PILOT_ENABLE_SERVICEENTRY_SELECT_PODS", true,
 		"If enabled, service entries with selectors will select pods from the cluster. "+
 			"It is safe to disable it if you are quite sure you don't need this feature").Get()
 	EnableK8SServiceSelectWorkloadEntries = env.RegisterBoolVar("PILOT_ENABLE_K8S_SELECT_WORKLOAD_ENTRIES", true,
 		"If enabled, Kubernetes services with selectors will select workload entries with matching labels. "+
 			"It is safe to disable it if you are quite sure you don't need this feature").Get()
+
+	ResolveServiceEntryDNS = env.RegisterBoolVar(
+		"PILOT_RESOLVE_SERVICE_ENTRY_DNS",
+		false,
+		"If enabled, istiod resolves the endpoints of the ServiceEntries with DNS resolution and sends the resolved "+
+			"addresses with EDS, instead of having the proxies resolve them in STRICT_DNS clusters. Each host is "+
+			"resolved again when the TTL of its records expires, and only the endpoints of the hosts whose "+
+			"addresses changed are pushed.",
+	).Get()
+
+	ServiceEntryDNSMinTTL = env.RegisterDurationVar(
+		"PILOT_SERVICE_ENTRY_DNS_MIN_TTL",
+		5*time.Second,
+		"The minimum interval between two resolutions of a ServiceEntry host, whatever the TTL of its records. "+
+			"Depends on PILOT_RESOLVE_SERVICE_ENTRY_DNS.",
+	).Get()
+
+	ServiceEntryDNSMaxTTL = env.RegisterDurationVar(
+		"PILOT_SERVICE_ENTRY_DNS_MAX_TTL",
+		5*time.Minute,
+		"The maximum interval between two resolutions of a ServiceEntry host, whatever the TTL of its records. "+
+			"Depends on PILOT_RESOLVE_SERVICE_ENTRY_DNS.",
+	).Get()
+
+	EnableServiceEntryDNSSRV = env.RegisterBoolVar(
+		"PILOT_ENABLE_SERVICE_ENTRY_DNS_SRV",
+		false,
+		"If enabled, the SRV records _<port name>._<tcp|udp>.<host> of the ServiceEntry hosts are looked up first, "+
+			"their targets and ports being the endpoints of the port. The A and AAAA records of the host are used "+
+			"when there are none. Depends on PILOT_RESOLVE_SERVICE_ENTRY_DNS.",
+	).Get()
+
 	InjectionWebhookConfigName = env.RegisterStringVar("INJECTION_WEBHOOK_CONFIG_NAME", "istio-sidecar-injector",
 		"Name of the mutatingwebhookconfiguration to patch, if istioctl is not used.")
 
@@ -350,7 +466,21 @@ var (
 			"https://github.com/spiffe/spiffe/blob/master/standards/SPIFFE_Trust_Domain_and_Bundle.md . "+
 			"No need to configure this for root certificates issued via Istiod or web-PKI based root certificates. "+
 			"Use || between <trustdomain, endpoint> tuples. Use | as delimiter between trust domain and endpoint in "+
-			"each tuple. For example: foo|https://url/for/foo||bar|https://url/for/bar").Get()
+			"each tuple. For example: foo|https://url/for/foo||bar|https://url/for/bar. When set on a proxy, the "+
+			"retrieved root certificates are added to the trust bundle of the proxy.").Get()
+
+	SpiffeBundleRefreshInterval = env.RegisterDurationVar("SPIFFE_BUNDLE_REFRESH_INTERVAL", 10*time.Minute,
+		"The interval at which the SPIFFE bundles of SPIFFE_BUNDLE_ENDPOINTS are fetched again, so that root "+
+			"certificate rotations of federated trust domains are picked up. 0 disables the refresh.").Get()
+
+	WorkloadCertExpiryWarningWindow = env.RegisterDurationVar("PILOT_WORKLOAD_CERT_EXPIRY_WARNING_WINDOW", 2*time.Hour,
+		"Workload certificates presented by proxies connecting to Istiod that expire within this window are "+
+			"reported in metrics and Kubernetes events. Since certificates are rotated well before they "+
+			"expire, this usually indicates a broken rotation. 0 disables the warning.").Get()
+
+	GatewayCertExpiryWarningWindow = env.RegisterDurationVar("PILOT_GATEWAY_CERT_EXPIRY_WARNING_WINDOW", 30*24*time.Hour,
+		"Gateway certificates served through SDS from Kubernetes secrets that expire within this window are "+
+			"reported in metrics and Kubernetes events. 0 disables the warning.").Get()
 
 	EnableXDSCaching = env.RegisterBoolVar("PILOT_ENABLE_XDS_CACHE", true,
 		"If true, Pilot will cache XDS responses.").Get()
@@ -407,16 +537,139 @@ var (
 			"environments with high rates of push requests to each gateway. By default,"+
 			"this is false.").Get()
 
+	EnableClusterWarmingSequence = env.RegisterBoolVar(
+		"PILOT_ENABLE_CLUSTER_WARMING_SEQUENCE",
+		false,
+		"If enabled, the listeners and routes of a full push updating the clusters of a proxy are only pushed "+
+			"once the proxy acknowledged the endpoints pushed after the clusters, so that the new and recreated "+
+			"clusters are warmed before the routes switch to them. This avoids the 503 errors of the requests "+
+			"routed to clusters still warming.",
+	).Get()
+
+	ClusterIgnoreHealthOnHostRemoval = env.RegisterBoolVar(
+		"PILOT_CLUSTER_IGNORE_HEALTH_ON_HOST_REMOVAL",
+		false,
+		"If enabled, the endpoints removed from service discovery are removed from the clusters immediately, "+
+			"even if they still pass the active health checks. It can be set for the clusters of a "+
+			"DestinationRule with the networking.istio.io/ignoreHealthOnHostRemoval annotation.",
+	).Get()
+
 	FlowControlTimeout = env.RegisterDurationVar(
 		"PILOT_FLOW_CONTROL_TIMEOUT",
 		15*time.Second,
 		"If set, the max amount of time to delay a push by. Depends on PILOT_ENABLE_FLOW_CONTROL.",
 	).Get()
 
+	XDSRequestRateLimit = env.RegisterFloatVar(
+		"PILOT_XDS_REQUEST_RATE_LIMIT",
+		0,
+		"If set to a positive value, the number of discovery requests of each type a proxy can send per second. "+
+			"Requests over the limit are not rejected, the latest one of each type is answered once the limit allows it. "+
+			"0 disables the limit.",
+	).Get()
+
+	XDSProxyRequestRateLimit = env.RegisterFloatVar(
+		"PILOT_XDS_PROXY_REQUEST_RATE_LIMIT",
+		0,
+		"If set to a positive value, the number of discovery requests of all types a proxy can send per second. "+
+			"Requests over the limit are deferred as with PILOT_XDS_REQUEST_RATE_LIMIT. 0 disables the limit.",
+	).Get()
+
+	XDSRequestBurst = env.RegisterIntVar(
+		"PILOT_XDS_REQUEST_BURST",
+		10,
+		"The number of discovery requests a proxy can send at once before the rate limits apply. "+
+			"Depends on PILOT_XDS_REQUEST_RATE_LIMIT or PILOT_XDS_PROXY_REQUEST_RATE_LIMIT.",
+	).Get()
+
+	EndpointSubsetTopology = env.RegisterStringVar(
+		"PILOT_ENDPOINT_SUBSET_TOPOLOGY",
+		"",
+		"If set, the EDS of each proxy only has the endpoints within its topology boundaries, plus a spillover set "+
+			"with lower priorities. This is a comma separated list of the boundaries, among node, zone and network: "+
+			"for example, zone,network only sends the endpoints in the same zone and network as the proxy. "+
+			"This reduces the size of EDS in large meshes.",
+	).Get()
+
+	EndpointSubsetSpillover = env.RegisterIntVar(
+		"PILOT_ENDPOINT_SUBSET_SPILLOVER",
+		5,
+		"The number of endpoints out of the topology boundaries sent to each proxy for each cluster, the closest "+
+			"first, when PILOT_ENDPOINT_SUBSET_TOPOLOGY is set. They are used when the endpoints within the "+
+			"boundaries are unavailable.",
+	).Get()
+
+	EnablePushQueuePriority = env.RegisterBoolVar(
+		"PILOT_ENABLE_PUSH_QUEUE_PRIORITY",
+		true,
+		"If enabled, the push queue serves gateways before sidecars, and proxies affected by the change before the "+
+			"others. If disabled, proxies are pushed in the order they were queued.",
+	).Get()
+
+	PushQueueGatewayWeight = env.RegisterIntVar(
+		"PILOT_PUSH_QUEUE_GATEWAY_WEIGHT",
+		10,
+		"The number of gateways pushed for each sidecar when both are queued, so that sidecars are not starved "+
+			"during mass gateway reconnects. Depends on PILOT_ENABLE_PUSH_QUEUE_PRIORITY.",
+	).Get()
+
+	PushQueueAffectedWeight = env.RegisterIntVar(
+		"PILOT_PUSH_QUEUE_AFFECTED_WEIGHT",
+		10,
+		"The number of proxies affected by the change pushed for each other proxy of the same type when both are "+
+			"queued, so that the others are not starved. Depends on PILOT_ENABLE_PUSH_QUEUE_PRIORITY.",
+	).Get()
+
+	EnableIncrementalServiceIndex = env.RegisterBoolVar(
+		"PILOT_ENABLE_INCREMENTAL_SERVICE_INDEX",
+		true,
+		"If enabled, when only ServiceEntry, WorkloadEntry or endpoint changes trigger a push, pilot rebuilds "+
+			"the instances and service accounts of the changed services only, and reuses the rest from the "+
+			"previous push context.",
+	).Get()
+
+	PushTracingCollector = env.RegisterStringVar(
+		"PILOT_PUSH_TRACING_COLLECTOR",
+		"",
+		"The address of an OpenTelemetry collector accepting OTLP over gRPC, such as otel-collector.istio-system:4317, to "+
+			"which the spans of the xDS pushes are exported, from the config change that triggered them to the "+
+			"responses sent to each proxy. Push tracing is disabled if empty.",
+	).Get()
+
+	PushTracingCollectorInsecure = env.RegisterBoolVar(
+		"PILOT_PUSH_TRACING_COLLECTOR_INSECURE",
+		true,
+		"If enabled, the spans of the xDS pushes are exported to PILOT_PUSH_TRACING_COLLECTOR over plaintext, "+
+			"otherwise over TLS.",
+	).Get()
+
+	PushTracingSampling = env.RegisterFloatVar(
+		"PILOT_PUSH_TRACING_SAMPLING",
+		1.0,
+		"The ratio, from 0.0 to 1.0, of the pushes traced when PILOT_PUSH_TRACING_COLLECTOR is set.",
+	).Get()
+
+	EnableInterning = env.RegisterBoolVar(
+		"PILOT_ENABLE_INTERNING",
+		true,
+		"If enabled, the hostnames, namespaces, service accounts and labels of the endpoints and service "+
+			"instances indexed by pilot are interned, so that equal values share the same memory.",
+	).Get()
+
 	PilotEnableLoopBlockers = env.RegisterBoolVar("PILOT_ENABLE_LOOP_BLOCKER", true,
 		"If enabled, Envoy will be configured to prevent traffic directly the the inbound/outbound "+
 			"ports (15001/15006). This prevents traffic loops. This option will be removed, and considered always enabled, in 1.9.").Get()
 
+	EnableHBONE = env.RegisterBoolVar(
+		"PILOT_ENABLE_HBONE",
+		false,
+		"If enabled, the proxies tunnel the TCP traffic to the services with strict mTLS over HTTP CONNECT (HBONE) "+
+			"to port 15008 of their endpoints, in single network meshes. The tunnels are terminated by a single filter "+
+			"chain of the inbound listener, which forwards each tunneled connection to the inbound cluster of the "+
+			"service or workload port named by the CONNECT authority, and replaces the filter chains of the ports of "+
+			"these services. All the proxies must be upgraded before it is enabled.",
+	).Get()
+
 	EnableDestinationRuleInheritance = env.RegisterBoolVar(
 		"PILOT_ENABLE_DESTINATION_RULE_INHERITANCE",
 		false,
@@ -431,9 +684,34 @@ var (
 		"If enabled, Istio agent will intercept ECDS resource update, downloads Wasm module, "+
 			"and replaces Wasm module remote load with downloaded local module file.").Get()
 
+	WasmPullSecretPath = env.RegisterStringVar("ISTIO_AGENT_WASM_PULL_SECRET_PATH", "",
+		"Path of a docker config JSON file, such as a mounted kubernetes.io/dockerconfigjson Secret, with the "+
+			"credentials of the registries serving remote Wasm modules. The file is read again when it changes.").Get()
+
+	OnDemandRoutesMaxHosts = env.RegisterIntVar(
+		"PILOT_ON_DEMAND_ROUTES_MAX_HOSTS",
+		10000,
+		"The maximum number of hosts a sidecar using on demand routes (VHDS) keeps virtual hosts for. "+
+			"When exceeded, the least recently requested hosts are evicted and fetched again on their next use. "+
+			"Set to 0 to disable eviction.",
+	).Get()
+
 	PilotJwtPubKeyRefreshInterval = env.RegisterDurationVar(
 		"PILOT_JWT_PUB_KEY_REFRESH_INTERVAL",
 		20*time.Minute,
 		"The interval for istiod to fetch the jwks_uri for the jwks public key.",
 	).Get()
+
+	PilotJwtPubKeyEvictionDuration = env.RegisterDurationVar(
+		"PILOT_JWT_PUB_KEY_EVICTION_DURATION",
+		24*7*time.Hour,
+		"The duration for which istiod keeps serving the last successfully fetched jwks public key when "+
+			"it fails to refresh it, or when it is no longer used.",
+	).Get()
+
+	PilotJwtRemoteJwksCacheDuration = env.RegisterDurationVar(
+		"PILOT_JWT_REMOTE_JWKS_CACHE_DURATION",
+		5*time.Minute,
+		"The duration for which Envoy caches the jwks public key it fetches itself, when PILOT_JWT_ENABLE_REMOTE_JWKS is enabled.",
+	).Get()
 )
@@ -23,6 +23,7 @@ import (
 	"istio.io/istio/pkg/config/labels"
 	"istio.io/istio/pkg/config/schema/collections"
 	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/config/security"
 )
 
 // MutualTLSMode is the mutule TLS mode specified by authentication policy.
@@ -67,6 +68,10 @@ type AuthenticationPolicies struct {
 
 	peerAuthentications map[string][]config.Config
 
+	// jwtRouteRequirements is the JWT route requirements of the request authentications, parsed from their
+	// JwtRouteRequirementsAnnotation.
+	jwtRouteRequirements map[ConfigKey][]security.JwtRouteRequirement
+
 	// namespaceMutualTLSMode is the MutualTLSMode correspoinding to the namespace-level PeerAuthentication.
 	// All namespace-level policies, and only them, are added to this map. If the policy mTLS mode is set
 	// to UNSET, it will be resolved to the value set by mesh policy if exist (i.e not UNKNOWN), or MTLSPermissive
@@ -86,6 +91,7 @@ func initAuthenticationPolicies(env *Environment) (*AuthenticationPolicies, erro
 	policy := &AuthenticationPolicies{
 		requestAuthentications: map[string][]config.Config{},
 		peerAuthentications:    map[string][]config.Config{},
+		jwtRouteRequirements:   map[ConfigKey][]security.JwtRouteRequirement{},
 		globalMutualTLSMode:    MTLSUnknown,
 		rootNamespace:          env.Mesh().GetRootNamespace(),
 	}
@@ -115,6 +121,21 @@ func (policy *AuthenticationPolicies) addRequestAuthentication(configs []config.
 		GetJwtKeyResolver().ResolveJwksURI(reqPolicy)
 		policy.requestAuthentications[config.Namespace] =
 			append(policy.requestAuthentications[config.Namespace], config)
+		if value := config.Annotations[security.JwtRouteRequirementsAnnotation]; value != "" {
+			issuers := make([]string, 0, len(reqPolicy.JwtRules))
+			for _, rule := range reqPolicy.JwtRules {
+				issuers = append(issuers, rule.Issuer)
+			}
+			requirements, err := security.ParseJwtRouteRequirements(value, issuers)
+			if err != nil {
+				log.Warnf("Ignoring invalid %s of %s/%s: %v", security.JwtRouteRequirementsAnnotation,
+					config.Namespace, config.Name, err)
+			}
+			if len(requirements) > 0 {
+				key := ConfigKey{Kind: gvk.RequestAuthentication, Name: config.Name, Namespace: config.Namespace}
+				policy.jwtRouteRequirements[key] = requirements
+			}
+		}
 	}
 }
 
@@ -208,6 +229,29 @@ func (policy *AuthenticationPolicies) GetJwtPoliciesForWorkload(namespace string
 	return getConfigsForWorkload(policy.requestAuthentications, policy.rootNamespace, namespace, workloadLabels)
 }
 
+// GetJwtRouteRequirements returns the JWT route requirements of the request authentication.
+func (policy *AuthenticationPolicies) GetJwtRouteRequirements(cfg *config.Config) []security.JwtRouteRequirement {
+	return policy.jwtRouteRequirements[ConfigKey{Kind: gvk.RequestAuthentication, Name: cfg.Name, Namespace: cfg.Namespace}]
+}
+
+// GetJwtRouteRequirementsForWorkload returns the JWT route requirements of the JWT policies matching to labels,
+// keyed by route name. The requirement of the first policy wins when several policies define the same route.
+func (policy *AuthenticationPolicies) GetJwtRouteRequirementsForWorkload(namespace string,
+	workloadLabels labels.Collection) map[string]security.JwtRouteRequirement {
+	var res map[string]security.JwtRouteRequirement
+	for _, cfg := range policy.GetJwtPoliciesForWorkload(namespace, workloadLabels) {
+		for _, requirement := range policy.GetJwtRouteRequirements(cfg) {
+			if res == nil {
+				res = map[string]security.JwtRouteRequirement{}
+			}
+			if _, f := res[requirement.Route]; !f {
+				res[requirement.Route] = requirement
+			}
+		}
+	}
+	return res
+}
+
 // GetPeerAuthenticationsForWorkload returns a list of peer authentication policies matching to labels.
 func (policy *AuthenticationPolicies) GetPeerAuthenticationsForWorkload(namespace string,
 	workloadLabels labels.Collection) []*config.Config {
@@ -24,9 +24,10 @@ import (
 var authzLog = istiolog.RegisterScope("authorization", "Istio Authorization Policy", 0)
 
 type AuthorizationPolicy struct {
-	Name      string                      `json:"name"`
-	Namespace string                      `json:"namespace"`
-	Spec      *authpb.AuthorizationPolicy `json:"spec"`
+	Name        string                      `json:"name"`
+	Namespace   string                      `json:"namespace"`
+	Annotations map[string]string           `json:"annotations"`
+	Spec        *authpb.AuthorizationPolicy `json:"spec"`
 }
 
 // AuthorizationPolicies organizes AuthorizationPolicy by namespace.
@@ -36,6 +37,15 @@ type AuthorizationPolicies struct {
 
 	// The name of the root namespace. Policy in the root namespace applies to workloads in all namespaces.
 	RootNamespace string `json:"root_namespace"`
+
+	// Maps from the IP set name to the IPs and CIDRs of the set, for the IP sets referenced in policies.
+	IPSets map[string][]string `json:"ip_sets"`
+}
+
+// IPSetWatcher provides the named IP sets that AuthorizationPolicies can reference from their IP blocks.
+type IPSetWatcher interface {
+	// IPSets returns the current IP sets, keyed by name. The returned map must not be modified.
+	IPSets() map[string][]string
 }
 
 // GetAuthorizationPolicies returns the AuthorizationPolicies for the given environment.
@@ -44,6 +54,9 @@ func GetAuthorizationPolicies(env *Environment) (*AuthorizationPolicies, error)
 		NamespaceToPolicies: map[string][]AuthorizationPolicy{},
 		RootNamespace:       env.Mesh().GetRootNamespace(),
 	}
+	if env.IPSetWatcher != nil {
+		policy.IPSets = env.IPSetWatcher.IPSets()
+	}
 
 	policies, err := env.List(collections.IstioSecurityV1Beta1Authorizationpolicies.Resource().GroupVersionKind(), NamespaceAll)
 	if err != nil {
@@ -52,9 +65,10 @@ func GetAuthorizationPolicies(env *Environment) (*AuthorizationPolicies, error)
 	sortConfigByCreationTime(policies)
 	for _, config := range policies {
 		authzConfig := AuthorizationPolicy{
-			Name:      config.Name,
-			Namespace: config.Namespace,
-			Spec:      config.Spec.(*authpb.AuthorizationPolicy),
+			Name:        config.Name,
+			Namespace:   config.Namespace,
+			Annotations: config.Annotations,
+			Spec:        config.Spec.(*authpb.AuthorizationPolicy),
 		}
 		policy.NamespaceToPolicies[config.Namespace] =
 			append(policy.NamespaceToPolicies[config.Namespace], authzConfig)
@@ -34,6 +34,7 @@ import (
 	structpb "github.com/golang/protobuf/ptypes/struct"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/labels"
@@ -66,6 +67,9 @@ type Environment struct {
 	// service registries.
 	mesh.NetworksWatcher
 
+	// IPSetWatcher provides the IP sets referenced by AuthorizationPolicies. Optional.
+	IPSetWatcher IPSetWatcher
+
 	// PushContext holds informations during push generation. It is reset on config change, at the beginning
 	// of the pushAll. It will hold all errors and stats and possibly caches needed during the entire cache computation.
 	// DO NOT USE EXCEPT FOR TESTS AND HANDLING OF NEW CONNECTIONS.
@@ -167,6 +171,17 @@ type XdsResourceGenerator interface {
 	Generate(proxy *Proxy, push *PushContext, w *WatchedResource, updates *PushRequest) (Resources, error)
 }
 
+// XdsDeltaResourceGenerator is implemented by the generators able to generate only the resources affected by the
+// configs updated in a push, for the incremental (delta) XDS streams.
+type XdsDeltaResourceGenerator interface {
+	XdsResourceGenerator
+	// GenerateDeltas returns the resources affected by the configs updated in the push request, and the names of
+	// the removed ones among the sent ones. It returns false if the updates cannot be scoped, in which case all the
+	// resources are generated with Generate.
+	GenerateDeltas(proxy *Proxy, push *PushContext, updates *PushRequest, w *WatchedResource,
+		sent []string) (Resources, []string, bool)
+}
+
 // Proxy contains information about an specific instance of a proxy (envoy sidecar, gateway,
 // etc). The Proxy is initialized when a sidecar connects to Pilot, and populated from
 // 'node' info in the protocol as well as data extracted from registries.
@@ -227,12 +242,20 @@ type Proxy struct {
 	// are not part of an Istio identity and thus are not verified.
 	VerifiedIdentity *spiffe.Identity
 
+	// SecretsAuthorized is true if the proxy is allowed to read the secrets of its namespace over SDS. It is
+	// only checked for sidecars when they can be served the credentialName of DestinationRules.
+	SecretsAuthorized bool
+
 	// Indicates whether proxy supports IPv6 addresses
 	ipv6Support bool
 
 	// Indicates whether proxy supports IPv4 addresses
 	ipv4Support bool
 
+	// ClientFeatures are the xDS client features reported in the node of the proxy, gating the config generated for
+	// clients supporting only part of the xDS API, such as proxyless gRPC.
+	ClientFeatures []string
+
 	// GlobalUnicastIP stores the global unicast IP if available, otherwise nil
 	GlobalUnicastIP string
 
@@ -244,6 +267,9 @@ type Proxy struct {
 
 	// WatchedResources contains the list of watched resources for the proxy, keyed by the DiscoveryRequest TypeUrl.
 	WatchedResources map[string]*WatchedResource
+
+	// envoyFilterPatches records the results of the EnvoyFilter patches evaluated for the proxy.
+	envoyFilterPatches envoyFilterPatchResults
 }
 
 // WatchedResource tracks an active DiscoveryRequest subscription.
@@ -277,6 +303,20 @@ type WatchedResource struct {
 	// NonceNacked is the last nacked message. This is reset following a successful ACK
 	NonceNacked string
 
+	// NackMessage is the error reported by the client for the last nacked message, and NackedResources the
+	// names of the resources it rejected, when they can be told from the error. Both are reset with NonceNacked.
+	NackMessage     string
+	NackedResources []string
+
+	// LastNackMessage and LastNackTime are the error reported by the client for the last nacked message and when it
+	// was received. Unlike NackMessage, they are kept following a successful ACK.
+	LastNackMessage string
+	LastNackTime    time.Time
+
+	// ResourceVersions are the versions of the resources included in the last sent response, keyed by name, for the
+	// types whose generator versions each resource. They are reset when the client rejects a response.
+	ResourceVersions map[string]string
+
 	// LastSent tracks the time of the generated push, to determine the time it takes the client to ack.
 	LastSent time.Time
 
@@ -286,6 +326,13 @@ type WatchedResource struct {
 	// LastSize tracks the size of the last update
 	LastSize int
 
+	// ConfigSize tracks the size of the full config of the type last pushed, which is larger than LastSize
+	// when only the changed resources were sent on a delta stream.
+	ConfigSize int
+
+	// FilterChains tracks the number of filter chains in the last generated listeners, for the listener type.
+	FilterChains int
+
 	// Last request contains the last DiscoveryRequest received for
 	// this type. Generators are called immediately after each request,
 	// and may use the information in DiscoveryRequest.
@@ -294,6 +341,25 @@ type WatchedResource struct {
 	LastRequest *discovery.DiscoveryRequest
 }
 
+// ConfigRejection aggregates the rejections by the proxies of the configuration generated from an Istio config.
+type ConfigRejection struct {
+	Group      string `json:"group"`
+	Version    string `json:"version"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+	Generation int64  `json:"generation"`
+	// Proxies counts the proxies that rejected the configuration.
+	Proxies int `json:"proxies"`
+	// Message is the error reported by one of the proxies.
+	Message string `json:"message,omitempty"`
+}
+
+// GroupVersionKind returns the type of the rejected config.
+func (r ConfigRejection) GroupVersionKind() config.GroupVersionKind {
+	return config.GroupVersionKind{Group: r.Group, Version: r.Version, Kind: r.Kind}
+}
+
 var istioVersionRegexp = regexp.MustCompile(`^([1-9]+)\.([0-9]+)(\.([0-9]+))?`)
 
 // StringList is a list that will be marshaled to a comma separate string in Json
@@ -476,6 +542,11 @@ type NodeMetadata struct {
 	// will be replaced with the gateway defined in the settings.
 	Network string `json:"NETWORK,omitempty"`
 
+	// NodeName is the name of the node the workload instance is running on, such as the Kubernetes node of a pod.
+	// It is an optional metadata: for Kubernetes pods, the node of the service instances of the proxy is used
+	// when it is not set.
+	NodeName string `json:"NODE_NAME,omitempty"`
+
 	// RequestedNetworkView specifies the networks that the proxy wants to see
 	RequestedNetworkView StringList `json:"REQUESTED_NETWORK_VIEW,omitempty"`
 
@@ -501,6 +572,14 @@ type NodeMetadata struct {
 	// If not set, default timeout is 1 hour.
 	IdleTimeout string `json:"IDLE_TIMEOUT,omitempty"`
 
+	// StreamIdleTimeout specifies the default idle timeout of the HTTP streams of the proxy, in duration format (5m).
+	// If not set, the streams have no idle timeout. Routes can override it with their own idle timeout.
+	StreamIdleTimeout string `json:"STREAM_IDLE_TIMEOUT,omitempty"`
+
+	// MaxStreamDuration specifies the default max duration of the HTTP streams of the proxy, in duration format (1h).
+	// If not set, the streams are only limited by the timeout of their route.
+	MaxStreamDuration string `json:"MAX_STREAM_DURATION,omitempty"`
+
 	// HTTP10 indicates the application behind the sidecar is making outbound http requests with HTTP/1.0
 	// protocol. It will enable the "AcceptHttp_10" option on the http options for outbound HTTP listeners.
 	// Alpha in 1.1, based on feedback may be turned into an API or change. Set to "1" to enable.
@@ -517,12 +596,37 @@ type NodeMetadata struct {
 	// This depends on DNSCapture.
 	DNSAutoAllocate StringBool `json:"DNS_AUTO_ALLOCATE,omitempty"`
 
+	// OnDemandRoutes indicates whether the sidecar fetches the virtual hosts of its outbound HTTP routes
+	// on first use (VHDS), instead of receiving complete route configurations.
+	// This requires Envoy to reach istiod with the delta XDS protocol.
+	OnDemandRoutes StringBool `json:"ON_DEMAND_ROUTES,omitempty"`
+
 	// AutoRegister will enable auto registration of the connected endpoint to the service registry using the given WorkloadGroup name
 	AutoRegisterGroup string `json:"AUTO_REGISTER_GROUP,omitempty"`
 
 	// UnprivilegedPod is used to determine whether a Gateway Pod can open ports < 1024
 	UnprivilegedPod string `json:"UNPRIVILEGED_POD,omitempty"`
 
+	// DownstreamTCPKeepalive configures the TCP keepalive of the connections accepted by the proxy.
+	// See TCPKeepalive for the format.
+	DownstreamTCPKeepalive string `json:"DOWNSTREAM_TCP_KEEPALIVE,omitempty"`
+	// UpstreamTCPKeepalive configures the TCP keepalive of the outbound connections of the proxy.
+	// See TCPKeepalive for the format.
+	UpstreamTCPKeepalive string `json:"UPSTREAM_TCP_KEEPALIVE,omitempty"`
+	// DownstreamTCPKeepaliveAnnotation is the value of the DownstreamTCPKeepaliveAnnotation of the workload,
+	// passed with the other annotations of the workload. It takes precedence over DownstreamTCPKeepalive.
+	DownstreamTCPKeepaliveAnnotation string `json:"proxy.istio.io/downstreamTcpKeepalive,omitempty"`
+	// UpstreamTCPKeepaliveAnnotation is the value of the UpstreamTCPKeepaliveAnnotation of the workload,
+	// passed with the other annotations of the workload. It takes precedence over UpstreamTCPKeepalive.
+	UpstreamTCPKeepaliveAnnotation string `json:"proxy.istio.io/upstreamTcpKeepalive,omitempty"`
+
+	// InboundProxyProtocol, if true, requires the inbound connections of the sidecar to start with a PROXY protocol
+	// header, so that the address of the clients is preserved through the TCP load balancers in front of it.
+	InboundProxyProtocol StringBool `json:"INBOUND_PROXY_PROTOCOL,omitempty"`
+	// InboundProxyProtocolAnnotation is the value of the InboundProxyProtocolAnnotation of the workload, passed
+	// with the other annotations of the workload. It takes precedence over InboundProxyProtocol.
+	InboundProxyProtocolAnnotation string `json:"proxy.istio.io/inboundProxyProtocol,omitempty"`
+
 	// Contains a copy of the raw metadata. This is needed to lookup arbitrary values.
 	// If a value is known ahead of time it should be added to the struct rather than reading from here,
 	Raw map[string]interface{} `json:"-"`
@@ -754,6 +858,16 @@ func (node *Proxy) DiscoverIPVersions() {
 	}
 }
 
+// SupportsClientFeature returns true if the proxy reported the xDS client feature.
+func (node *Proxy) SupportsClientFeature(feature string) bool {
+	for _, f := range node.ClientFeatures {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
 // SupportsIPv4 returns true if proxy supports IPv4 addresses.
 func (node *Proxy) SupportsIPv4() bool {
 	return node.ipv4Support
@@ -15,6 +15,7 @@ package model
 
 import (
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/gogo/protobuf/proto"
@@ -22,6 +23,7 @@ import (
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/routing"
 	"istio.io/istio/pkg/config/xds"
 )
 
@@ -29,6 +31,11 @@ import (
 type EnvoyFilterWrapper struct {
 	workloadSelector labels.Instance
 	Patches          map[networking.EnvoyFilter_ApplyTo][]*EnvoyFilterConfigPatchWrapper
+	// FilterOrder are the constraints on the order of the filters of the listeners, see routing.FilterOrderAnnotation
+	FilterOrder []routing.FilterOrder
+	// the proxy the patches are merged for, which records the results of their evaluation
+	proxy       *Proxy
+	pushVersion string
 }
 
 // EnvoyFilterConfigPatchWrapper is a wrapper over the EnvoyFilter ConfigPatch api object
@@ -44,6 +51,14 @@ type EnvoyFilterConfigPatchWrapper struct {
 	// regex match, but as an optimization we can reduce this to a prefix match for common cases.
 	// If this is set, ProxyVersionRegex is ignored.
 	ProxyPrefixMatch string
+	// Name, Namespace and Generation of the EnvoyFilter, and Index of the patch in its config patches,
+	// identifying the patch in the results of its evaluation.
+	Name       string
+	Namespace  string
+	Generation int64
+	Index      int
+	// DryRun is set when the EnvoyFilter is only evaluated, without applying its patches.
+	DryRun bool
 }
 
 // wellKnownVersions defines a mapping of well known regex matches to prefix matches
@@ -62,17 +77,37 @@ var wellKnownVersions = map[string]string{
 // convertToEnvoyFilterWrapper converts from EnvoyFilter config to EnvoyFilterWrapper object
 func convertToEnvoyFilterWrapper(local *config.Config) *EnvoyFilterWrapper {
 	localEnvoyFilter := local.Spec.(*networking.EnvoyFilter)
+	dryRun := false
+	if value, f := local.Annotations[EnvoyFilterDryRunAnnotation]; f {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			log.Warnf("ignored invalid %s annotation of EnvoyFilter %s/%s: %v", EnvoyFilterDryRunAnnotation,
+				local.Namespace, local.Name, err)
+		}
+	}
 
 	out := &EnvoyFilterWrapper{}
 	if localEnvoyFilter.WorkloadSelector != nil {
 		out.workloadSelector = localEnvoyFilter.WorkloadSelector.Labels
 	}
+	if value, f := local.Annotations[routing.FilterOrderAnnotation]; f {
+		var err error
+		if out.FilterOrder, err = routing.ParseFilterOrder(value); err != nil {
+			log.Warnf("ignored invalid %s annotation of EnvoyFilter %s/%s: %v", routing.FilterOrderAnnotation,
+				local.Namespace, local.Name, err)
+		}
+	}
 	out.Patches = make(map[networking.EnvoyFilter_ApplyTo][]*EnvoyFilterConfigPatchWrapper)
-	for _, cp := range localEnvoyFilter.ConfigPatches {
+	for i, cp := range localEnvoyFilter.ConfigPatches {
 		cpw := &EnvoyFilterConfigPatchWrapper{
-			ApplyTo:   cp.ApplyTo,
-			Match:     cp.Match,
-			Operation: cp.Patch.Operation,
+			ApplyTo:    cp.ApplyTo,
+			Match:      cp.Match,
+			Operation:  cp.Patch.Operation,
+			Name:       local.Name,
+			Namespace:  local.Namespace,
+			Generation: local.Generation,
+			Index:      i,
+			DryRun:     dryRun,
 		}
 		var err error
 		// Use non-strict building to avoid issues where EnvoyFilter is valid but meant
@@ -0,0 +1,256 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"sort"
+	"sync"
+)
+
+// EnvoyFilterDryRunAnnotation marks an EnvoyFilter as dry-run when set to "true", as for the authorization
+// policies. The patches of a dry-run EnvoyFilter are matched against the configuration of the proxies, and the
+// results reported, but they are not applied.
+const EnvoyFilterDryRunAnnotation = "istio.io/dry-run"
+
+// EnvoyFilterPatchState is the result of the evaluation of an EnvoyFilter patch against the configuration of a proxy.
+type EnvoyFilterPatchState string
+
+const (
+	// EnvoyFilterPatchApplied is the state of a patch that matched the configuration, and was applied to it
+	// unless the EnvoyFilter is dry-run.
+	EnvoyFilterPatchApplied EnvoyFilterPatchState = "Applied"
+	// EnvoyFilterPatchNoMatch is the state of a patch that matched nothing in the configuration.
+	EnvoyFilterPatchNoMatch EnvoyFilterPatchState = "NoMatch"
+	// EnvoyFilterPatchConflict is the state of a patch that matched the configuration, but could not be applied.
+	EnvoyFilterPatchConflict EnvoyFilterPatchState = "Conflict"
+)
+
+// stateOrder orders the states by precedence: a patch applied anywhere in the configuration is reported as applied,
+// unless it conflicted somewhere.
+var stateOrder = map[EnvoyFilterPatchState]int{
+	EnvoyFilterPatchNoMatch:  0,
+	EnvoyFilterPatchApplied:  1,
+	EnvoyFilterPatchConflict: 2,
+}
+
+// EnvoyFilterPatchResult is the result of the evaluation of an EnvoyFilter patch for a proxy.
+type EnvoyFilterPatchResult struct {
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+	Generation int64  `json:"generation"`
+	// Index of the patch in the config patches of the EnvoyFilter.
+	Index     int                   `json:"index"`
+	ApplyTo   string                `json:"applyTo"`
+	Operation string                `json:"operation"`
+	DryRun    bool                  `json:"dryRun,omitempty"`
+	State     EnvoyFilterPatchState `json:"state"`
+	// Message describes why the patch conflicted.
+	Message string `json:"message,omitempty"`
+}
+
+// envoyFilterPatchKey identifies a patch of an EnvoyFilter.
+type envoyFilterPatchKey struct {
+	namespace string
+	name      string
+	index     int
+}
+
+// envoyFilterPatchResults records the results of the EnvoyFilter patches evaluated for a proxy. They are reset by
+// each push, as the configuration of the proxy is generated again.
+type envoyFilterPatchResults struct {
+	mu          sync.RWMutex
+	pushVersion string
+	results     map[envoyFilterPatchKey]*EnvoyFilterPatchResult
+}
+
+// record records the state of the patch for the push, unless the patch already has a state of higher precedence.
+func (r *envoyFilterPatchResults) record(pushVersion string, cp *EnvoyFilterConfigPatchWrapper,
+	state EnvoyFilterPatchState, message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.results == nil || r.pushVersion != pushVersion {
+		r.pushVersion = pushVersion
+		r.results = make(map[envoyFilterPatchKey]*EnvoyFilterPatchResult)
+	}
+	key := envoyFilterPatchKey{namespace: cp.Namespace, name: cp.Name, index: cp.Index}
+	if prev, f := r.results[key]; f && stateOrder[prev.State] >= stateOrder[state] {
+		return
+	}
+	r.results[key] = &EnvoyFilterPatchResult{
+		Namespace:  cp.Namespace,
+		Name:       cp.Name,
+		Generation: cp.Generation,
+		Index:      cp.Index,
+		ApplyTo:    cp.ApplyTo.String(),
+		Operation:  cp.Operation.String(),
+		DryRun:     cp.DryRun,
+		State:      state,
+		Message:    message,
+	}
+}
+
+// EnvoyFilterPatchResults returns the results of the EnvoyFilter patches evaluated for the last configuration
+// generated for the proxy, sorted by EnvoyFilter and patch.
+func (node *Proxy) EnvoyFilterPatchResults() []EnvoyFilterPatchResult {
+	r := &node.envoyFilterPatches
+	r.mu.RLock()
+	out := make([]EnvoyFilterPatchResult, 0, len(r.results))
+	for _, result := range r.results {
+		out = append(out, *result)
+	}
+	r.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Namespace != out[j].Namespace {
+			return out[i].Namespace < out[j].Namespace
+		}
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].Index < out[j].Index
+	})
+	return out
+}
+
+// recordEvaluated records the patches merged for the proxy as evaluated, matching nothing until recorded otherwise.
+func (efw *EnvoyFilterWrapper) recordEvaluated() {
+	if efw.proxy == nil {
+		return
+	}
+	for _, cps := range efw.Patches {
+		for _, cp := range cps {
+			efw.proxy.envoyFilterPatches.record(efw.pushVersion, cp, EnvoyFilterPatchNoMatch, "")
+		}
+	}
+}
+
+// Matched records that the patch matched the configuration of the proxy, and returns whether it must be applied,
+// which it is not when the EnvoyFilter is dry-run.
+func (efw *EnvoyFilterWrapper) Matched(cp *EnvoyFilterConfigPatchWrapper) bool {
+	if efw != nil && efw.proxy != nil {
+		efw.proxy.envoyFilterPatches.record(efw.pushVersion, cp, EnvoyFilterPatchApplied, "")
+	}
+	return !cp.DryRun
+}
+
+// Conflicted records that the patch matched the configuration of the proxy, but could not be applied.
+func (efw *EnvoyFilterWrapper) Conflicted(cp *EnvoyFilterConfigPatchWrapper, message string) {
+	if efw != nil && efw.proxy != nil {
+		efw.proxy.envoyFilterPatches.record(efw.pushVersion, cp, EnvoyFilterPatchConflict, message)
+	}
+}
+
+// EnvoyFilterPatchSummary aggregates the results of the evaluation of an EnvoyFilter patch for the proxies.
+type EnvoyFilterPatchSummary struct {
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+	Generation int64  `json:"generation"`
+	Index      int    `json:"index"`
+	ApplyTo    string `json:"applyTo"`
+	Operation  string `json:"operation"`
+	DryRun     bool   `json:"dryRun,omitempty"`
+	// Applied, NoMatch and Conflict count the proxies for which the patch is in each state.
+	Applied  int `json:"applied"`
+	NoMatch  int `json:"noMatch"`
+	Conflict int `json:"conflict"`
+	// Message describes one of the conflicts.
+	Message string `json:"message,omitempty"`
+}
+
+// Add adds the counts of the other summary of the patch.
+func (s *EnvoyFilterPatchSummary) Add(other EnvoyFilterPatchSummary) {
+	s.Applied += other.Applied
+	s.NoMatch += other.NoMatch
+	s.Conflict += other.Conflict
+	if s.Message == "" {
+		s.Message = other.Message
+	}
+}
+
+// State returns the state of the patch for the proxies: conflicting if it conflicted for any proxy, else applied
+// if it was applied for any proxy.
+func (s *EnvoyFilterPatchSummary) State() EnvoyFilterPatchState {
+	switch {
+	case s.Conflict > 0:
+		return EnvoyFilterPatchConflict
+	case s.Applied > 0:
+		return EnvoyFilterPatchApplied
+	default:
+		return EnvoyFilterPatchNoMatch
+	}
+}
+
+// SummarizeEnvoyFilterPatches aggregates the results of the EnvoyFilter patches evaluated for the proxies. All the
+// patches of the EnvoyFilters of the push context are summarized, including those that were evaluated for none of
+// the proxies, while the results of the previous generations of the EnvoyFilters are ignored.
+func (ps *PushContext) SummarizeEnvoyFilterPatches(proxies []*Proxy) []EnvoyFilterPatchSummary {
+	var out []EnvoyFilterPatchSummary
+	index := make(map[envoyFilterPatchKey]int)
+	for _, namespace := range sortedKeys(ps.envoyFiltersByNamespace) {
+		for _, efw := range ps.envoyFiltersByNamespace[namespace] {
+			for _, cps := range efw.Patches {
+				for _, cp := range cps {
+					index[envoyFilterPatchKey{namespace: cp.Namespace, name: cp.Name, index: cp.Index}] = len(out)
+					out = append(out, EnvoyFilterPatchSummary{
+						Namespace:  cp.Namespace,
+						Name:       cp.Name,
+						Generation: cp.Generation,
+						Index:      cp.Index,
+						ApplyTo:    cp.ApplyTo.String(),
+						Operation:  cp.Operation.String(),
+						DryRun:     cp.DryRun,
+					})
+				}
+			}
+		}
+	}
+	for _, proxy := range proxies {
+		for _, result := range proxy.EnvoyFilterPatchResults() {
+			i, f := index[envoyFilterPatchKey{namespace: result.Namespace, name: result.Name, index: result.Index}]
+			if !f || out[i].Generation != result.Generation {
+				continue
+			}
+			switch result.State {
+			case EnvoyFilterPatchApplied:
+				out[i].Applied++
+			case EnvoyFilterPatchConflict:
+				out[i].Conflict++
+				if out[i].Message == "" {
+					out[i].Message = result.Message
+				}
+			default:
+				out[i].NoMatch++
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Namespace != out[j].Namespace {
+			return out[i].Namespace < out[j].Namespace
+		}
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].Index < out[j].Index
+	})
+	return out
+}
+
+func sortedKeys(m map[string][]*EnvoyFilterWrapper) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
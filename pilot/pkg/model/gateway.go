@@ -25,6 +25,7 @@ import (
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/gateway"
 	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/routing"
 	"istio.io/pkg/monitoring"
 )
 
@@ -64,6 +65,10 @@ type MergedGateway struct {
 
 	// TLSServerInfo maps from server to a corresponding TLS information like TLS Routename and SNIHosts.
 	TLSServerInfo map[*networking.Server]*TLSServerInfo
+
+	// RateLimits maps from route names to the global rate limiting of their servers, set by the oldest gateway
+	// setting it.
+	RateLimits map[string]*routing.RateLimit
 }
 
 var (
@@ -95,6 +100,7 @@ func MergeGateways(gateways ...config.Config) *MergedGateway {
 	serversByRouteName := make(map[string][]*networking.Server)
 	tlsServerInfo := make(map[*networking.Server]*TLSServerInfo)
 	gatewayNameForServer := make(map[*networking.Server]string)
+	rateLimits := make(map[string]*routing.RateLimit)
 	tlsHostsByPort := map[uint32]sets.Set{} // port -> host set
 
 	log.Debugf("MergeGateways: merging %d gateways", len(gateways))
@@ -102,6 +108,13 @@ func MergeGateways(gateways ...config.Config) *MergedGateway {
 		gatewayName := gatewayConfig.Namespace + "/" + gatewayConfig.Name // Format: %s/%s
 		gatewayCfg := gatewayConfig.Spec.(*networking.Gateway)
 		log.Debugf("MergeGateways: merging gateway %q :\n%v", gatewayName, gatewayCfg)
+		var rateLimit *routing.RateLimit
+		if value, f := gatewayConfig.Annotations[routing.RateLimitAnnotation]; f {
+			var err error
+			if rateLimit, err = routing.ParseRateLimit(value); err != nil {
+				log.Warnf("ignored invalid %s annotation of gateway %s: %v", routing.RateLimitAnnotation, gatewayName, err)
+			}
+		}
 		snames := sets.Set{}
 		for _, s := range gatewayCfg.Servers {
 			if len(s.Name) > 0 {
@@ -132,7 +145,7 @@ func MergeGateways(gateways ...config.Config) *MergedGateway {
 				tlsServerInfo[s] = &TLSServerInfo{SNIHosts: GetSNIHostsForServer(s), RouteName: routeName}
 			}
 			serverPort := ServerPort{s.Port.Number, s.Port.Protocol}
-			serverProtocol := protocol.Parse(serverPort.Protocol)
+			serverProtocol := protocol.ParseGatewayProtocol(serverPort.Protocol)
 			if gatewayPorts[s.Port.Number] {
 				// We have two servers on the same port. Should we merge?
 				// 1. Yes if both servers are plain text and HTTP
@@ -141,7 +154,7 @@ func MergeGateways(gateways ...config.Config) *MergedGateway {
 				//    for each server (as each server ends up as a separate http connection manager due to filter chain match)
 				// 3. No for everything else.
 				if current, exists := plainTextServers[s.Port.Number]; exists {
-					if !canMergeProtocols(serverProtocol, protocol.Parse(current.Protocol)) {
+					if !canMergeProtocols(serverProtocol, protocol.ParseGatewayProtocol(current.Protocol)) {
 						log.Infof("skipping server on gateway %s port %s.%d.%s: conflict with existing server %d.%s",
 							gatewayConfig.Name, s.Port.Name, s.Port.Number, s.Port.Protocol, serverPort.Number, serverPort.Protocol)
 						RecordRejectedConfig(gatewayName)
@@ -206,6 +219,9 @@ func MergeGateways(gateways ...config.Config) *MergedGateway {
 				}
 				mergedServers[serverPort] = &MergedServers{Servers: []*networking.Server{s}, RouteName: routeName}
 			}
+			if rateLimit != nil && routeName != "" && rateLimits[routeName] == nil {
+				rateLimits[routeName] = rateLimit
+			}
 			log.Debugf("MergeGateways: gateway %q merged server %v", gatewayName, s.Hosts)
 		}
 	}
@@ -215,6 +231,7 @@ func MergeGateways(gateways ...config.Config) *MergedGateway {
 		GatewayNameForServer: gatewayNameForServer,
 		TLSServerInfo:        tlsServerInfo,
 		ServersByRouteName:   serversByRouteName,
+		RateLimits:           rateLimits,
 	}
 }
 
@@ -292,12 +309,12 @@ func CheckDuplicates(hosts []string, knownHosts sets.Set) []string {
 // different ports, the optimization (one RDS instead of two) could quickly become useless the moment the set of
 // hosts on the two servers start differing -- necessitating the need for two different RDS routes.
 func gatewayRDSRouteName(server *networking.Server, cfg config.Config) string {
-	p := protocol.Parse(server.Port.Protocol)
+	p := protocol.ParseGatewayProtocol(server.Port.Protocol)
 	if p.IsHTTP() {
 		return fmt.Sprintf("http.%d", server.Port.Number)
 	}
 
-	if p == protocol.HTTPS && server.Tls != nil && !gateway.IsPassThroughServer(server) {
+	if (p == protocol.HTTPS || p == protocol.HTTP3) && server.Tls != nil && !gateway.IsPassThroughServer(server) {
 		return fmt.Sprintf("https.%d.%s.%s.%s",
 			server.Port.Number, server.Port.Name, cfg.Name, cfg.Namespace)
 	}
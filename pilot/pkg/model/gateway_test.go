@@ -20,6 +20,7 @@ import (
 
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/routing"
 )
 
 func TestMergeGateways(t *testing.T) {
@@ -135,6 +136,28 @@ func TestMergeGateways(t *testing.T) {
 	}
 }
 
+func TestMergeGatewaysRateLimits(t *testing.T) {
+	withRateLimit := func(c config.Config, domain string) config.Config {
+		c.Annotations = map[string]string{
+			routing.RateLimitAnnotation: fmt.Sprintf(`{"domain": %q, "service": "ratelimit.default.svc.cluster.local:8081",
+				"descriptors": [[{"remoteAddress": true}]]}`, domain),
+		}
+		return c
+	}
+	gwFoo := makeConfig("foo", "default", "foo.bar.com", "http", "http", 80, "ingressgateway")
+	gwBar := withRateLimit(makeConfig("bar", "default", "bar.foo.com", "http", "http", 80, "ingressgateway"), "bar")
+	gwBaz := withRateLimit(makeConfig("baz", "default", "baz.foo.com", "http", "http", 80, "ingressgateway"), "baz")
+	gwTCP := withRateLimit(makeConfig("tcp", "default", "*", "tcp", "tcp", 9000, "ingressgateway"), "tcp")
+
+	merged := MergeGateways(gwFoo, gwBar, gwBaz, gwTCP)
+	if len(merged.RateLimits) != 1 {
+		t.Fatalf("expected the rate limit of a single route, got %v", merged.RateLimits)
+	}
+	if got := merged.RateLimits["http.80"]; got == nil || got.Domain != "bar" {
+		t.Errorf("expected the rate limit of the oldest gateway setting it, got %v", got)
+	}
+}
+
 func makeConfig(name, namespace, host, portName, portProtocol string, portNumber uint32, gw string) config.Config {
 	c := config.Config{
 		Meta: config.Meta{
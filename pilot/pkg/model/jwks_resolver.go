@@ -52,11 +52,6 @@ const (
 	// jwksURICacheEviction specifies the frequency at which eviction activities take place.
 	jwksURICacheEviction = time.Minute * 30
 
-	// JwtPubKeyEvictionDuration is the life duration for cached item.
-	// Cached item will be removed from the cache if it hasn't been used longer than JwtPubKeyEvictionDuration or if pilot
-	// has failed to refresh it for more than JwtPubKeyEvictionDuration.
-	JwtPubKeyEvictionDuration = 24 * 7 * time.Hour
-
 	// JwtPubKeyRetryInterval is the retry interval between the attempt to retry getting the remote
 	// content from network.
 	JwtPubKeyRetryInterval = time.Second
@@ -94,6 +89,12 @@ var (
 
 	// JwtPubKeyRefreshInterval is the running interval of JWT pubKey refresh job.
 	JwtPubKeyRefreshInterval = features.PilotJwtPubKeyRefreshInterval
+
+	// JwtPubKeyEvictionDuration is the life duration for cached item.
+	// Cached item will be removed from the cache if it hasn't been used longer than JwtPubKeyEvictionDuration or if pilot
+	// has failed to refresh it for more than JwtPubKeyEvictionDuration. Until then, the last successfully fetched
+	// public key keeps being served when the refresh fails.
+	JwtPubKeyEvictionDuration = features.PilotJwtPubKeyEvictionDuration
 )
 
 // jwtPubKeyEntry is a single cached entry for jwt public key.
@@ -136,6 +137,19 @@ type JwksResolver struct {
 
 	// How many times refresh job failed to fetch the public key from network, used in unit test.
 	refreshJobFetchFailedCount uint64
+
+	// jwksURIs of the public keys that failed to be fetched on main flow, and are being fetched in background.
+	// map key is jwksURI, map value is *pendingFetch.
+	pendingFetches sync.Map
+
+	// Closed when the resolver is closed, to stop the background fetches.
+	stop chan struct{}
+}
+
+// pendingFetch is a public key being fetched in background.
+type pendingFetch struct {
+	// lastRequestedTime is the last time the key was requested on main flow, in unix nanoseconds.
+	lastRequestedTime int64
 }
 
 func init() {
@@ -166,6 +180,7 @@ func newJwksResolverWithCABundlePaths(evictionDuration, refreshInterval, retryIn
 		evictionDuration: evictionDuration,
 		refreshInterval:  refreshInterval,
 		retryInterval:    retryInterval,
+		stop:             make(chan struct{}),
 		httpClient: &http.Client{
 			Timeout: jwksHTTPTimeOutInSec * time.Second,
 			Transport: &http.Transport{
@@ -236,6 +251,14 @@ func (r *JwksResolver) GetPublicKey(jwksURI string) (string, error) {
 	resp, err := r.getRemoteContentWithRetry(jwksURI, networkFetchRetryCountOnMainFlow)
 	if err != nil {
 		log.Errorf("Failed to fetch public key from %q: %v", jwksURI, err)
+		// Keep trying in background, so that the key is pushed as soon as it becomes available
+		// instead of waiting for the next config push.
+		val, loaded := r.pendingFetches.LoadOrStore(jwksURI, &pendingFetch{lastRequestedTime: now.UnixNano()})
+		if loaded {
+			atomic.StoreInt64(&val.(*pendingFetch).lastRequestedTime, now.UnixNano())
+		} else {
+			go r.fetchInBackground(jwksURI, val.(*pendingFetch))
+		}
 		return "", err
 	}
 
@@ -249,6 +272,49 @@ func (r *JwksResolver) GetPublicKey(jwksURI string) (string, error) {
 	return pubKey, nil
 }
 
+// fetchInBackground fetches the public key that failed to be fetched on main flow, retrying with an exponential
+// backoff capped by the refresh interval, and pushes it to the proxies once fetched. It gives up when the key has
+// not been requested for the eviction duration, as a cached key would have been evicted, or the resolver is closed.
+func (r *JwksResolver) fetchInBackground(jwksURI string, pending *pendingFetch) {
+	defer r.pendingFetches.Delete(jwksURI)
+	backoff := r.retryInterval
+	for {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-r.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if lastRequested := time.Unix(0, atomic.LoadInt64(&pending.lastRequestedTime)); time.Since(lastRequested) >= r.evictionDuration {
+			log.Infof("Stopped fetching JWT public key from %q in background: not requested since %v", jwksURI, lastRequested)
+			return
+		}
+		if _, found := r.keyEntries.Load(jwksURI); found {
+			// The key has been fetched on main flow in the meantime.
+			return
+		}
+		resp, err := r.getRemoteContentWithRetry(jwksURI, networkFetchRetryCountOnMainFlow)
+		if err == nil {
+			now := time.Now()
+			r.keyEntries.Store(jwksURI, jwtPubKeyEntry{
+				pubKey:            string(resp),
+				lastRefreshedTime: now,
+				lastUsedTime:      now,
+			})
+			log.Infof("Fetched JWT public key from %q in background", jwksURI)
+			if r.PushFunc != nil {
+				r.PushFunc()
+			}
+			return
+		}
+		if backoff *= 2; backoff > r.refreshInterval {
+			backoff = r.refreshInterval
+		}
+		log.Warnf("Failed to fetch JWT public key from %q in background: %v. Retry in %v", jwksURI, err, backoff)
+	}
+}
+
 // Resolve jwks_uri through openID discovery and cache the jwks_uri for future use.
 func (r *JwksResolver) resolveJwksURIUsingOpenID(issuer string) (string, error) {
 	// Set policyJwt.JwksUri if the JwksUri could be found in cache.
@@ -421,6 +487,7 @@ func (r *JwksResolver) refresh() {
 // TODO: may need to figure out the right place to call this function.
 // (right now calls it from initDiscoveryService in pkg/bootstrap/server.go).
 func (r *JwksResolver) Close() {
+	close(r.stop)
 	closeChan <- true
 }
 
@@ -16,6 +16,7 @@ package model
 
 import (
 	"fmt"
+	"math"
 	"reflect"
 	"sync/atomic"
 	"testing"
@@ -418,6 +419,65 @@ func TestJwtPubKeyRefreshWithNetworkError(t *testing.T) {
 	verifyKeyLastRefreshedTime(t, r, ms, false /* wantChanged */)
 }
 
+func TestGetPublicKeyFetchInBackground(t *testing.T) {
+	r := NewJwksResolver(JwtPubKeyEvictionDuration, JwtPubKeyRefreshInterval, testRetryInterval)
+	defer r.Close()
+	var pushed uint64
+	r.PushFunc = func() {
+		atomic.AddUint64(&pushed, 1)
+	}
+
+	ms := startMockServer(t)
+	defer ms.Stop()
+
+	// Configures the mock server to return error for the first requests.
+	ms.ReturnErrorForFirstNumHits = 3
+
+	mockCertURL := ms.URL + "/oauth2/v3/certs"
+	if _, err := r.GetPublicKey(mockCertURL); err == nil {
+		t.Fatalf("GetPublicKey(%+v) did not fail: expected network error, got no error", mockCertURL)
+	}
+
+	// The public key should be fetched in background and pushed.
+	retry.UntilSuccessOrFail(t, func() error {
+		if atomic.LoadUint64(&pushed) == 0 {
+			return fmt.Errorf("public key is not pushed")
+		}
+		return nil
+	})
+	pk, err := r.GetPublicKey(mockCertURL)
+	if err != nil {
+		t.Fatalf("GetPublicKey(%+v) fails: expected no error, got (%v)", mockCertURL, err)
+	}
+	if test.JwtPubKey1 != pk {
+		t.Fatalf("GetPublicKey(%+v): expected (%s), got (%s)", mockCertURL, test.JwtPubKey1, pk)
+	}
+}
+
+func TestGetPublicKeyFetchInBackgroundStopsWhenNotRequested(t *testing.T) {
+	r := NewJwksResolver(100*time.Millisecond, JwtPubKeyRefreshInterval, testRetryInterval)
+	defer r.Close()
+
+	ms := startMockServer(t)
+	defer ms.Stop()
+
+	// Configures the mock server to always return error.
+	ms.ReturnErrorForFirstNumHits = math.MaxUint64
+
+	mockCertURL := ms.URL + "/oauth2/v3/certs"
+	if _, err := r.GetPublicKey(mockCertURL); err == nil {
+		t.Fatalf("GetPublicKey(%+v) did not fail: expected network error, got no error", mockCertURL)
+	}
+
+	// The background fetch should give up once the key has not been requested for the eviction duration.
+	retry.UntilSuccessOrFail(t, func() error {
+		if _, found := r.pendingFetches.Load(mockCertURL); found {
+			return fmt.Errorf("public key is still fetched in background")
+		}
+		return nil
+	})
+}
+
 func getCounterValue(counterName string, t *testing.T) float64 {
 	counterValue := 0.0
 	if data, err := view.RetrieveData(counterName); err == nil {
@@ -0,0 +1,39 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"strconv"
+)
+
+// InboundProxyProtocolAnnotation requires the inbound connections of the sidecar of a workload to start with a
+// PROXY protocol header when set to "true". It takes precedence over the INBOUND_PROXY_PROTOCOL proxy metadata.
+const InboundProxyProtocolAnnotation = "proxy.istio.io/inboundProxyProtocol"
+
+// InboundProxyProtocol returns true if the inbound connections of the sidecar start with a PROXY protocol header.
+func (node *Proxy) InboundProxyProtocol() bool {
+	if node.Metadata == nil {
+		return false
+	}
+	if value := node.Metadata.InboundProxyProtocolAnnotation; value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Debugf("ignoring %s of proxy %s: %v", InboundProxyProtocolAnnotation, node.ID, err)
+			return bool(node.Metadata.InboundProxyProtocol)
+		}
+		return enabled
+	}
+	return bool(node.Metadata.InboundProxyProtocol)
+}
@@ -22,17 +22,20 @@ import (
 	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/atomic"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/util/intern"
 	"istio.io/istio/pilot/pkg/util/sets"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/labels"
 	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/routing"
 	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/istio/pkg/config/visibility"
 	"istio.io/pkg/monitoring"
@@ -98,6 +101,11 @@ type virtualServiceIndex struct {
 	publicByGateway map[string][]config.Config
 	// root vs namespace/name ->delegate vs virtualservice gvk/namespace/name
 	delegates map[ConfigKey][]ConfigKey
+	// hasRouteLocalRateLimits is true if a virtual service limits the rate of its routes locally
+	hasRouteLocalRateLimits bool
+	// routeConnectionPools contains the connection pool overrides of the HTTP routes of the virtual services setting
+	// routing.ConnectionPoolAnnotation, keyed by route name, parsed once per push context.
+	routeConnectionPools map[ConfigKey]map[string]*networking.ConnectionPoolSettings
 }
 
 func newVirtualServiceIndex() virtualServiceIndex {
@@ -106,6 +114,7 @@ func newVirtualServiceIndex() virtualServiceIndex {
 		privateByNamespaceAndGateway: map[string]map[string][]config.Config{},
 		exportedToNamespaceByGateway: map[string]map[string][]config.Config{},
 		delegates:                    map[ConfigKey][]ConfigKey{},
+		routeConnectionPools:         map[ConfigKey]map[string]*networking.ConnectionPoolSettings{},
 	}
 }
 
@@ -307,6 +316,13 @@ type PushRequest struct {
 	// There should only be multiple reasons if the push request is the result of two distinct triggers, rather than
 	// classifying a single trigger as having multiple reasons.
 	Reason []TriggerReason
+
+	// Triggers are the spans of the config changes that triggered the push. The spans of the push are linked to
+	// them, so that a slow push can be traced back to the change that caused it.
+	Triggers []trace.SpanContext
+
+	// Span is the span of the current stage of the push, parent of the spans of the next stage.
+	Span trace.SpanContext
 }
 
 type TriggerReason string
@@ -356,6 +372,12 @@ func (first *PushRequest) Merge(other *PushRequest) *PushRequest {
 
 		// Merge the two reasons. Note that we shouldn't deduplicate here, or we would under count
 		Reason: reason,
+
+		Triggers: mergeTriggers(first.Triggers, other.Triggers),
+		Span:     first.Span,
+	}
+	if other.Span.IsValid() {
+		merged.Span = other.Span
 	}
 
 	// Do not merge when any one is empty
@@ -565,13 +587,15 @@ func (ps *PushContext) UpdateMetrics() {
 	}
 }
 
-func virtualServiceDestinations(v *networking.VirtualService) []*networking.Destination {
-	if v == nil {
+func virtualServiceDestinations(vs config.Config) []*networking.Destination {
+	v, ok := vs.Spec.(*networking.VirtualService)
+	if !ok || v == nil {
 		return nil
 	}
 
 	var ds []*networking.Destination
 
+	mirrors := RouteMirrors(vs)
 	for _, h := range v.Http {
 		for _, r := range h.Route {
 			if r.Destination != nil {
@@ -581,6 +605,11 @@ func virtualServiceDestinations(v *networking.VirtualService) []*networking.Dest
 		if h.Mirror != nil {
 			ds = append(ds, h.Mirror)
 		}
+		if h.Name != "" {
+			for _, m := range mirrors[h.Name] {
+				ds = append(ds, m.Destination)
+			}
+		}
 	}
 	for _, t := range v.Tcp {
 		for _, r := range t.Route {
@@ -614,13 +643,12 @@ func (ps *PushContext) GatewayServices(proxy *Proxy) []*Service {
 
 	for _, gw := range proxy.MergedGateway.GatewayNameForServer {
 		for _, vsConfig := range ps.VirtualServicesForGateway(proxy, gw) {
-			vs, ok := vsConfig.Spec.(*networking.VirtualService)
-			if !ok { // should never happen
+			if _, ok := vsConfig.Spec.(*networking.VirtualService); !ok { // should never happen
 				log.Errorf("Failed in getting a virtual service: %v", vsConfig.Labels)
 				return svcs
 			}
 
-			for _, d := range virtualServiceDestinations(vs) {
+			for _, d := range virtualServiceDestinations(vsConfig) {
 				hostsFromGateways[d.Host] = struct{}{}
 			}
 		}
@@ -705,6 +733,18 @@ func (ps *PushContext) IsServiceVisible(service *Service, namespace string) bool
 		service.Attributes.ExportTo[visibility.Instance(namespace)]
 }
 
+// HasRouteLocalRateLimits returns true if a virtual service limits the rate of its routes locally, which requires
+// the local rate limit filter in the HTTP connection managers serving routes.
+func (ps *PushContext) HasRouteLocalRateLimits() bool {
+	return ps.virtualServiceIndex.hasRouteLocalRateLimits
+}
+
+// RouteConnectionPools returns the connection pool overrides of the HTTP routes of the virtual service set with
+// routing.ConnectionPoolAnnotation, keyed by route name, or nil if it sets none or an invalid one.
+func (ps *PushContext) RouteConnectionPools(vs config.Config) map[string]*networking.ConnectionPoolSettings {
+	return ps.virtualServiceIndex.routeConnectionPools[ConfigKey{Kind: gvk.VirtualService, Name: vs.Name, Namespace: vs.Namespace}]
+}
+
 // VirtualServicesForGateway lists all virtual services bound to the specified gateways
 // This replaces store.VirtualServices. Used only by the gateways
 // Sidecars use the egressListener.VirtualServices().
@@ -1005,11 +1045,14 @@ func (ps *PushContext) updateContext(
 	pushReq *PushRequest) error {
 	var servicesChanged, virtualServicesChanged, destinationRulesChanged, gatewayChanged,
 		authnChanged, authzChanged, envoyFiltersChanged, sidecarsChanged bool
+	// ServiceEntry keys name the hostname of the changed service.
+	changedServices := map[host.Name]struct{}{}
 
 	for conf := range pushReq.ConfigsUpdated {
 		switch conf.Kind {
 		case gvk.ServiceEntry:
 			servicesChanged = true
+			changedServices[host.Name(conf.Name)] = struct{}{}
 		case gvk.DestinationRule:
 			destinationRulesChanged = true
 		case gvk.VirtualService:
@@ -1032,8 +1075,12 @@ func (ps *PushContext) updateContext(
 	}
 
 	if servicesChanged {
-		// Services have changed. initialize service registry
-		if err := ps.initServiceRegistry(env); err != nil {
+		// Services have changed. Only rebuild the changed ones, unless disabled.
+		if features.EnableIncrementalServiceIndex {
+			if err := ps.updateServiceRegistry(env, oldPushContext, changedServices); err != nil {
+				return err
+			}
+		} else if err := ps.initServiceRegistry(env); err != nil {
 			return err
 		}
 	} else {
@@ -1107,6 +1154,14 @@ func (ps *PushContext) updateContext(
 // Caches list of services in the registry, and creates a map
 // of hostname to service
 func (ps *PushContext) initServiceRegistry(env *Environment) error {
+	return ps.updateServiceRegistry(env, nil, nil)
+}
+
+// updateServiceRegistry builds the service index like initServiceRegistry, but reuses the cached instances
+// and service accounts of the old push context for services that are not in changed. Services are matched
+// by identity, so a service replaced by its registry is always rebuilt. A nil oldPushContext rebuilds
+// everything.
+func (ps *PushContext) updateServiceRegistry(env *Environment, oldPushContext *PushContext, changed map[host.Name]struct{}) error {
 	services, err := env.Services()
 	if err != nil {
 		return err
@@ -1122,9 +1177,17 @@ func (ps *PushContext) initServiceRegistry(env *Environment) error {
 		s.Mutex.RUnlock()
 
 		// Precache instances
+		if _, ok := ps.ServiceIndex.instancesByPort[s]; !ok {
+			ps.ServiceIndex.instancesByPort[s] = make(map[int][]*ServiceInstance)
+		}
+		if oldPushContext.unchangedService(s, changed) {
+			for port, instances := range oldPushContext.ServiceIndex.instancesByPort[s] {
+				ps.ServiceIndex.instancesByPort[s][port] = instances
+			}
+		}
 		for _, port := range s.Ports {
-			if _, ok := ps.ServiceIndex.instancesByPort[s]; !ok {
-				ps.ServiceIndex.instancesByPort[s] = make(map[int][]*ServiceInstance)
+			if _, f := ps.ServiceIndex.instancesByPort[s][port.Port]; f {
+				continue
 			}
 			instances := make([]*ServiceInstance, 0)
 			instances = append(instances, ps.InstancesByPort(s, port.Port, nil)...)
@@ -1167,11 +1230,22 @@ func (ps *PushContext) initServiceRegistry(env *Environment) error {
 		}
 	}
 
-	ps.initServiceAccounts(env, allServices)
+	ps.initServiceAccounts(env, allServices, oldPushContext, changed)
 
 	return nil
 }
 
+// unchangedService returns true if the service is indexed in this push context, and its hostname is not in changed.
+func (ps *PushContext) unchangedService(s *Service, changed map[host.Name]struct{}) bool {
+	if ps == nil {
+		return false
+	}
+	if _, f := changed[s.Hostname]; f {
+		return false
+	}
+	return ps.ServiceIndex.HostnameAndNamespace[s.Hostname][s.Attributes.Namespace] == s
+}
+
 // sortServicesByCreationTime sorts the list of services in ascending order by their creation time (if available).
 func sortServicesByCreationTime(services []*Service) []*Service {
 	sort.SliceStable(services, func(i, j int) bool {
@@ -1180,17 +1254,27 @@ func sortServicesByCreationTime(services []*Service) []*Service {
 	return services
 }
 
-// Caches list of service accounts in the registry
-func (ps *PushContext) initServiceAccounts(env *Environment, services []*Service) {
+// Caches list of service accounts in the registry. Service accounts of services unchanged since
+// oldPushContext are reused.
+func (ps *PushContext) initServiceAccounts(env *Environment, services []*Service,
+	oldPushContext *PushContext, changed map[host.Name]struct{}) {
 	for _, svc := range services {
 		if ps.ServiceAccounts[svc.Hostname] == nil {
 			ps.ServiceAccounts[svc.Hostname] = map[int][]string{}
 		}
+		if oldPushContext.unchangedService(svc, changed) {
+			if accounts, f := oldPushContext.ServiceAccounts[svc.Hostname]; f {
+				for port, sa := range accounts {
+					ps.ServiceAccounts[svc.Hostname][port] = sa
+				}
+				continue
+			}
+		}
 		for _, port := range svc.Ports {
 			if port.Protocol == protocol.UDP {
 				continue
 			}
-			ps.ServiceAccounts[svc.Hostname][port.Port] = env.GetIstioServiceAccounts(svc, []int{port.Port})
+			ps.ServiceAccounts[svc.Hostname][port.Port] = intern.Default.Strings(env.GetIstioServiceAccounts(svc, []int{port.Port}))
 		}
 	}
 }
@@ -1208,6 +1292,8 @@ func (ps *PushContext) initVirtualServices(env *Environment) error {
 	ps.virtualServiceIndex.exportedToNamespaceByGateway = map[string]map[string][]config.Config{}
 	ps.virtualServiceIndex.privateByNamespaceAndGateway = map[string]map[string][]config.Config{}
 	ps.virtualServiceIndex.publicByGateway = map[string][]config.Config{}
+	ps.virtualServiceIndex.hasRouteLocalRateLimits = false
+	ps.virtualServiceIndex.routeConnectionPools = map[ConfigKey]map[string]*networking.ConnectionPoolSettings{}
 
 	virtualServices, err := env.List(gvk.VirtualService, NamespaceAll)
 	if err != nil {
@@ -1240,6 +1326,17 @@ func (ps *PushContext) initVirtualServices(env *Environment) error {
 	for _, virtualService := range vservices {
 		ns := virtualService.Namespace
 		rule := virtualService.Spec.(*networking.VirtualService)
+		if _, f := virtualService.Annotations[routing.RouteLocalRateLimitAnnotation]; f {
+			ps.virtualServiceIndex.hasRouteLocalRateLimits = true
+		}
+		if value, f := virtualService.Annotations[routing.ConnectionPoolAnnotation]; f {
+			if pools, err := routing.ParseConnectionPools(value); err != nil {
+				log.Debugf("ignored %s of virtual service %s/%s: %v", routing.ConnectionPoolAnnotation, ns, virtualService.Name, err)
+			} else {
+				key := ConfigKey{Kind: gvk.VirtualService, Name: virtualService.Name, Namespace: ns}
+				ps.virtualServiceIndex.routeConnectionPools[key] = pools
+			}
+		}
 		gwNames := getGatewayNames(rule, virtualService.Meta)
 		if len(rule.ExportTo) == 0 {
 			// No exportTo in virtualService. Use the global default
@@ -1625,10 +1722,13 @@ func (ps *PushContext) EnvoyFilters(proxy *Proxy) *EnvoyFilterWrapper {
 	if len(matchedEnvoyFilters) > 0 {
 		out = &EnvoyFilterWrapper{
 			// no need populate workloadSelector, as it is not used later.
-			Patches: make(map[networking.EnvoyFilter_ApplyTo][]*EnvoyFilterConfigPatchWrapper),
+			Patches:     make(map[networking.EnvoyFilter_ApplyTo][]*EnvoyFilterConfigPatchWrapper),
+			proxy:       proxy,
+			pushVersion: ps.PushVersion,
 		}
 		// merge EnvoyFilterWrapper
 		for _, efw := range matchedEnvoyFilters {
+			out.FilterOrder = append(out.FilterOrder, efw.FilterOrder...)
 			for applyTo, cps := range efw.Patches {
 				if out.Patches[applyTo] == nil {
 					out.Patches[applyTo] = []*EnvoyFilterConfigPatchWrapper{}
@@ -1640,6 +1740,7 @@ func (ps *PushContext) EnvoyFilters(proxy *Proxy) *EnvoyFilterWrapper {
 				}
 			}
 		}
+		out.recordEvaluated()
 	}
 
 	return out
@@ -1807,7 +1908,7 @@ func (ps *PushContext) BestEffortInferServiceMTLSMode(service *Service, port *Po
 	}
 
 	// 1. Check service instances' tls mode, mainly used for headless service.
-	if service.Resolution == Passthrough {
+	if service.ResolutionForPort(port.Port) == Passthrough {
 		instances := ps.ServiceInstancesByPort(service, port.Port, nil)
 		if len(instances) == 0 {
 			return MTLSDisable
@@ -575,6 +575,61 @@ func TestInitPushContext(t *testing.T) {
 	}
 }
 
+func TestIncrementalServiceIndex(t *testing.T) {
+	env := &Environment{}
+	store := istioConfigStore{ConfigStore: NewFakeStore()}
+	env.IstioConfigStore = &store
+	sd := &localServiceDiscovery{
+		services: []*Service{
+			{
+				Hostname:   "svc1",
+				Ports:      allPorts,
+				Attributes: ServiceAttributes{Namespace: "test1"},
+			},
+			{
+				Hostname:   "svc2",
+				Ports:      allPorts,
+				Attributes: ServiceAttributes{Namespace: "test1"},
+			},
+		},
+		serviceInstances: []*ServiceInstance{{
+			Endpoint: &IstioEndpoint{Address: "192.168.1.2", EndpointPort: 8000},
+		}},
+	}
+	env.ServiceDiscovery = sd
+	m := mesh.DefaultMeshConfig()
+	env.Watcher = mesh.NewFixedWatcher(&m)
+
+	old := NewPushContext()
+	if err := old.InitContext(env, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only svc1 changed, so only its instances should be recomputed.
+	sd.serviceInstances = []*ServiceInstance{{
+		Endpoint: &IstioEndpoint{Address: "192.168.1.3", EndpointPort: 8000},
+	}}
+	newPush := NewPushContext()
+	if err := newPush.InitContext(env, old, &PushRequest{
+		ConfigsUpdated: map[ConfigKey]struct{}{
+			{Kind: gvk.ServiceEntry, Name: "svc1", Namespace: "test1"}: {},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	port := allPorts[0].Port
+	if got := newPush.ServiceIndex.instancesByPort[sd.services[0]][port][0].Endpoint.Address; got != "192.168.1.3" {
+		t.Errorf("expected changed service to be rebuilt, got address %v", got)
+	}
+	if got := newPush.ServiceIndex.instancesByPort[sd.services[1]][port][0].Endpoint.Address; got != "192.168.1.2" {
+		t.Errorf("expected unchanged service to be reused, got address %v", got)
+	}
+	if got := old.ServiceIndex.instancesByPort[sd.services[0]][port][0].Endpoint.Address; got != "192.168.1.2" {
+		t.Errorf("expected old push context to be unmodified, got address %v", got)
+	}
+}
+
 func TestSidecarScope(t *testing.T) {
 	ps := NewPushContext()
 	env := &Environment{Watcher: mesh.NewFixedWatcher(&meshconfig.MeshConfig{RootNamespace: "istio-system"})}
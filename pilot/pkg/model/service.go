@@ -101,6 +101,10 @@ type Service struct {
 	// by the caller)
 	Resolution Resolution
 
+	// PortResolutions overrides the Resolution of the service for individual port numbers.
+	// It is only set for service entries.
+	PortResolutions map[int]Resolution `json:"portResolutions,omitempty"`
+
 	// MeshExternal (if true) indicates that the service is external to the mesh.
 	// These services are defined using Istio's ServiceEntry spec.
 	MeshExternal bool
@@ -191,6 +195,8 @@ const (
 	TrafficDirectionInbound TrafficDirection = "inbound"
 	// TrafficDirectionOutbound indicates outbound traffic
 	TrafficDirectionOutbound TrafficDirection = "outbound"
+	// TrafficDirectionOutboundHBONE indicates outbound traffic tunneled over HTTP CONNECT (HBONE)
+	TrafficDirectionOutboundHBONE TrafficDirection = "outbound-hbone"
 
 	// trafficDirectionOutboundSrvPrefix the prefix for a DNS SRV type subset key
 	trafficDirectionOutboundSrvPrefix = string(TrafficDirectionOutbound) + "_"
@@ -399,6 +405,9 @@ type IstioEndpoint struct {
 	// from the service port.
 	EndpointPort uint32
 
+	// NodeName is the name of the node where the endpoint is present, if known.
+	NodeName string
+
 	// The load balancing weight associated with this endpoint.
 	LbWeight uint32
 
@@ -552,6 +561,27 @@ func (s *Service) External() bool {
 	return s.MeshExternal
 }
 
+// ResolutionForPort returns the resolution of the service instances for the given port number.
+func (s *Service) ResolutionForPort(port int) Resolution {
+	if r, f := s.PortResolutions[port]; f {
+		return r
+	}
+	return s.Resolution
+}
+
+// HasResolution returns true if the service instances of any port of the service are resolved with the given resolution.
+func (s *Service) HasResolution(r Resolution) bool {
+	if len(s.PortResolutions) == 0 {
+		return s.Resolution == r
+	}
+	for _, port := range s.Ports {
+		if s.ResolutionForPort(port.Port) == r {
+			return true
+		}
+	}
+	return false
+}
+
 // BuildSubsetKey generates a unique string referencing service instances for a given service name, a subset and a port.
 // The proxy queries Pilot with this key to obtain the list of instances in a subset.
 func BuildSubsetKey(direction TrafficDirection, subsetName string, hostname host.Name, port int) string {
@@ -658,7 +688,7 @@ func (s *Service) DeepCopy() *Service {
 	accounts := copyInternal(s.ServiceAccounts)
 	clusterVIPs := copyInternal(s.ClusterVIPs)
 
-	return &Service{
+	out := &Service{
 		Attributes:      attrs.(ServiceAttributes),
 		Ports:           ports.(PortList),
 		ServiceAccounts: accounts.([]string),
@@ -669,6 +699,13 @@ func (s *Service) DeepCopy() *Service {
 		Resolution:      s.Resolution,
 		MeshExternal:    s.MeshExternal,
 	}
+	if s.PortResolutions != nil {
+		out.PortResolutions = make(map[int]Resolution, len(s.PortResolutions))
+		for port, r := range s.PortResolutions {
+			out.PortResolutions[port] = r
+		}
+	}
+	return out
 }
 
 // DeepCopy creates a clone of IstioEndpoint.
@@ -35,6 +35,24 @@ func TestGetByPort(t *testing.T) {
 	}
 }
 
+func TestServiceHasResolution(t *testing.T) {
+	svc := &Service{
+		Ports:           PortList{{Name: "http", Port: 80}, {Name: "tls", Port: 443}},
+		Resolution:      DNSLB,
+		PortResolutions: map[int]Resolution{443: Passthrough},
+	}
+	if svc.ResolutionForPort(80) != DNSLB || svc.ResolutionForPort(443) != Passthrough {
+		t.Errorf("unexpected port resolutions %v and %v", svc.ResolutionForPort(80), svc.ResolutionForPort(443))
+	}
+	if !svc.HasResolution(DNSLB) || !svc.HasResolution(Passthrough) || svc.HasResolution(ClientSideLB) {
+		t.Errorf("unexpected resolutions of %v", svc.PortResolutions)
+	}
+	svc.PortResolutions = map[int]Resolution{80: Passthrough, 443: Passthrough}
+	if svc.HasResolution(DNSLB) {
+		t.Errorf("expected no DNS resolution when all ports are overridden")
+	}
+}
+
 func BenchmarkParseSubsetKey(b *testing.B) {
 	for n := 0; n < b.N; n++ {
 		ParseSubsetKey("outbound|80|v1|example.com")
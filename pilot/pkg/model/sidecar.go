@@ -20,11 +20,14 @@ import (
 	"strings"
 
 	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/api/security/v1beta1"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/routing"
 	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/config/security"
 )
 
 const (
@@ -91,6 +94,22 @@ type SidecarScope struct {
 	// listeners from the proxy service instances
 	HasCustomIngressListeners bool
 
+	// IngressMtls is the inbound mTLS mode of the ingress listener ports, from the
+	// security.istio.io/ingressMtls annotation of the Sidecar.
+	IngressMtls map[uint32]v1beta1.PeerAuthentication_MutualTLS_Mode
+
+	// LocalRateLimit is the local rate limiting of the inbound traffic, from the
+	// networking.istio.io/localRateLimit annotation of the Sidecar.
+	LocalRateLimit *routing.LocalRateLimit
+
+	// ProtocolDetection is the protocol detection of the outbound listener ports, from the
+	// networking.istio.io/protocolDetection annotation of the Sidecar.
+	ProtocolDetection map[uint32]routing.ProtocolDetection
+
+	// AccessLog is the access logging of the workloads, from the networking.istio.io/accessLog
+	// annotation of the Sidecar.
+	AccessLog *routing.AccessLog
+
 	// Union of services imported across all egress listeners for use by CDS code.
 	services           []*Service
 	servicesByHostname map[host.Name]*Service
@@ -270,7 +289,7 @@ func ConvertToSidecarScope(ps *PushContext, sidecarConfig *config.Config, config
 	}
 
 	out.EgressListeners = make([]*IstioEgressListenerWrapper, 0)
-	egressConfigs := sidecar.Egress
+	egressConfigs := sidecarEgressListeners(sidecarConfig, sidecar)
 	// If egress not set, setup a default listener
 	if len(egressConfigs) == 0 {
 		egressConfigs = append(egressConfigs, &networking.IstioEgressListener{Hosts: []string{"*/*"}})
@@ -334,14 +353,13 @@ func ConvertToSidecarScope(ps *PushContext, sidecarConfig *config.Config, config
 		// That way, if there is ambiguity around what hostname to pick, a user can specify the one they
 		// want in the hosts field, and the potentially random choice below won't matter
 		for _, vs := range listener.virtualServices {
-			v := vs.Spec.(*networking.VirtualService)
 			out.AddConfigDependencies(ConfigKey{
 				Kind:      gvk.VirtualService,
 				Name:      vs.Name,
 				Namespace: vs.Namespace,
 			})
 
-			for _, d := range virtualServiceDestinations(v) {
+			for _, d := range virtualServiceDestinations(vs) {
 				// Default to this hostname in our config namespace
 				if s, ok := ps.ServiceIndex.HostnameAndNamespace[host.Name(d.Host)][configNamespace]; ok {
 					// This won't overwrite hostnames that have already been found eg because they were requested in hosts
@@ -401,11 +419,101 @@ func ConvertToSidecarScope(ps *PushContext, sidecarConfig *config.Config, config
 
 	if len(sidecar.Ingress) > 0 {
 		out.HasCustomIngressListeners = true
+		out.IngressMtls = ingressMtls(sidecarConfig, sidecar)
 	}
+	out.LocalRateLimit = sidecarLocalRateLimit(sidecarConfig)
+	out.ProtocolDetection = sidecarProtocolDetection(sidecarConfig)
+	out.AccessLog = sidecarAccessLog(sidecarConfig)
 
 	return out
 }
 
+// sidecarLocalRateLimit returns the local rate limiting set by the Sidecar annotation, if any.
+func sidecarLocalRateLimit(sidecarConfig *config.Config) *routing.LocalRateLimit {
+	value, f := sidecarConfig.Annotations[routing.LocalRateLimitAnnotation]
+	if !f {
+		return nil
+	}
+	rateLimit, err := routing.ParseLocalRateLimit(value)
+	if err != nil {
+		log.Warnf("Sidecar %s/%s has an invalid %s: %v", sidecarConfig.Namespace, sidecarConfig.Name,
+			routing.LocalRateLimitAnnotation, err)
+		return nil
+	}
+	return rateLimit
+}
+
+// sidecarProtocolDetection returns the protocol detection of the outbound ports set by the Sidecar annotation, if
+// any.
+func sidecarProtocolDetection(sidecarConfig *config.Config) map[uint32]routing.ProtocolDetection {
+	value, f := sidecarConfig.Annotations[routing.ProtocolDetectionAnnotation]
+	if !f {
+		return nil
+	}
+	detection, err := routing.ParseProtocolDetection(value)
+	if err != nil {
+		log.Warnf("Sidecar %s/%s has an invalid %s: %v", sidecarConfig.Namespace, sidecarConfig.Name,
+			routing.ProtocolDetectionAnnotation, err)
+		return nil
+	}
+	return detection
+}
+
+// sidecarAccessLog returns the access logging set by the Sidecar annotation, if any.
+func sidecarAccessLog(sidecarConfig *config.Config) *routing.AccessLog {
+	value, f := sidecarConfig.Annotations[routing.AccessLogAnnotation]
+	if !f {
+		return nil
+	}
+	accessLog, err := routing.ParseAccessLog(value)
+	if err != nil {
+		log.Warnf("Sidecar %s/%s has an invalid %s: %v", sidecarConfig.Namespace, sidecarConfig.Name,
+			routing.AccessLogAnnotation, err)
+		return nil
+	}
+	return accessLog
+}
+
+// ingressMtls returns the mTLS modes of the ingress listener ports set by the Sidecar annotation. Invalid
+// settings are rejected by validation, and ignored here.
+func ingressMtls(sidecarConfig *config.Config, sidecar *networking.Sidecar) map[uint32]v1beta1.PeerAuthentication_MutualTLS_Mode {
+	value, f := sidecarConfig.Annotations[security.SidecarIngressMtlsAnnotation]
+	if !f {
+		return nil
+	}
+	modes, err := security.ParseSidecarIngressMtls(value)
+	if err != nil {
+		log.Warnf("Sidecar %s/%s has an invalid %s: %v", sidecarConfig.Namespace, sidecarConfig.Name,
+			security.SidecarIngressMtlsAnnotation, err)
+	}
+	res := make(map[uint32]v1beta1.PeerAuthentication_MutualTLS_Mode, len(modes))
+	for _, ingress := range sidecar.Ingress {
+		if mode, f := modes[ingress.GetPort().GetNumber()]; f {
+			res[ingress.GetPort().GetNumber()] = mode
+		}
+	}
+	return res
+}
+
+// sidecarEgressListeners returns the egress listeners of the sidecar, with the listeners of the ranges of its
+// PortRangesAnnotation, if any.
+func sidecarEgressListeners(sidecarConfig *config.Config, sidecar *networking.Sidecar) []*networking.IstioEgressListener {
+	value, f := sidecarConfig.Annotations[routing.PortRangesAnnotation]
+	if !f {
+		return sidecar.Egress
+	}
+	ranges, err := routing.ParsePortRanges(value)
+	if err == nil {
+		var listeners []*networking.IstioEgressListener
+		if listeners, err = routing.ExpandEgressListeners(sidecar.Egress, ranges); err == nil {
+			return listeners
+		}
+	}
+	log.Warnf("Sidecar %s/%s has an invalid %s: %v", sidecarConfig.Namespace, sidecarConfig.Name,
+		routing.PortRangesAnnotation, err)
+	return sidecar.Egress
+}
+
 func convertIstioListenerToWrapper(ps *PushContext, configNamespace string,
 	istioListener *networking.IstioEgressListener) *IstioEgressListenerWrapper {
 	out := &IstioEgressListenerWrapper{
@@ -0,0 +1,125 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DownstreamTCPKeepaliveAnnotation configures the TCP keepalive of the connections accepted by the proxy of a
+	// workload. It takes precedence over the DOWNSTREAM_TCP_KEEPALIVE proxy metadata.
+	DownstreamTCPKeepaliveAnnotation = "proxy.istio.io/downstreamTcpKeepalive"
+	// UpstreamTCPKeepaliveAnnotation configures the TCP keepalive of the outbound connections of the proxy of a
+	// workload. It takes precedence over the UPSTREAM_TCP_KEEPALIVE proxy metadata.
+	UpstreamTCPKeepaliveAnnotation = "proxy.istio.io/upstreamTcpKeepalive"
+)
+
+// TCPKeepalive holds the TCP keepalive and related socket options of the connections of a proxy.
+// The value of the annotations and proxy metadata is a comma separated list of settings, for example
+// "time=300s,interval=75s,probes=9,userTimeout=30s". Unset settings use the OS defaults.
+type TCPKeepalive struct {
+	// Time is the idle duration of a connection before keepalive probes are sent (TCP_KEEPIDLE).
+	Time time.Duration
+	// Interval is the duration between keepalive probes (TCP_KEEPINTVL).
+	Interval time.Duration
+	// Probes is the number of unanswered probes before the connection is dropped (TCP_KEEPCNT).
+	Probes uint32
+	// UserTimeout is the maximum duration transmitted data may remain unacknowledged before the connection is
+	// dropped (TCP_USER_TIMEOUT). It is only supported for downstream connections.
+	UserTimeout time.Duration
+}
+
+// ParseTCPKeepalive parses the TCP keepalive settings of an annotation or proxy metadata.
+func ParseTCPKeepalive(value string) (*TCPKeepalive, error) {
+	keepalive := &TCPKeepalive{}
+	for _, setting := range strings.Split(value, ",") {
+		setting = strings.TrimSpace(setting)
+		if setting == "" {
+			continue
+		}
+		parts := strings.SplitN(setting, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid TCP keepalive setting %q: expected <name>=<value>", setting)
+		}
+		name, v := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if name == "probes" {
+			probes, err := strconv.ParseUint(v, 10, 32)
+			if err != nil || probes == 0 {
+				return nil, fmt.Errorf("invalid TCP keepalive probes %q: must be a positive integer", v)
+			}
+			keepalive.Probes = uint32(probes)
+			continue
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TCP keepalive %s %q: %v", name, v, err)
+		}
+		switch name {
+		case "time", "interval":
+			if d < time.Second || d%time.Second != 0 {
+				return nil, fmt.Errorf("invalid TCP keepalive %s %q: must be a whole number of seconds", name, v)
+			}
+			if name == "time" {
+				keepalive.Time = d
+			} else {
+				keepalive.Interval = d
+			}
+		case "userTimeout":
+			if d < time.Millisecond {
+				return nil, fmt.Errorf("invalid TCP keepalive userTimeout %q: must be at least 1ms", v)
+			}
+			keepalive.UserTimeout = d
+		default:
+			return nil, fmt.Errorf("unknown TCP keepalive setting %q", name)
+		}
+	}
+	return keepalive, nil
+}
+
+// DownstreamTCPKeepalive returns the TCP keepalive of the connections accepted by the proxy, or nil if not configured.
+func (node *Proxy) DownstreamTCPKeepalive() *TCPKeepalive {
+	if node.Metadata == nil {
+		return nil
+	}
+	return node.tcpKeepalive(node.Metadata.DownstreamTCPKeepaliveAnnotation, node.Metadata.DownstreamTCPKeepalive)
+}
+
+// UpstreamTCPKeepalive returns the TCP keepalive of the outbound connections of the proxy, or nil if not configured.
+func (node *Proxy) UpstreamTCPKeepalive() *TCPKeepalive {
+	if node.Metadata == nil {
+		return nil
+	}
+	return node.tcpKeepalive(node.Metadata.UpstreamTCPKeepaliveAnnotation, node.Metadata.UpstreamTCPKeepalive)
+}
+
+func (node *Proxy) tcpKeepalive(annotation, metadata string) *TCPKeepalive {
+	value := annotation
+	if value == "" {
+		value = metadata
+	}
+	if value == "" {
+		return nil
+	}
+	keepalive, err := ParseTCPKeepalive(value)
+	if err != nil {
+		log.Debugf("ignoring TCP keepalive of proxy %s: %v", node.ID, err)
+		return nil
+	}
+	return keepalive
+}
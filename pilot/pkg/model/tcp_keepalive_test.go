@@ -0,0 +1,80 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTCPKeepalive(t *testing.T) {
+	cases := []struct {
+		value string
+		want  *TCPKeepalive
+		err   bool
+	}{
+		{
+			value: "time=300s, interval=75s,probes=9,userTimeout=30s",
+			want:  &TCPKeepalive{Time: 300 * time.Second, Interval: 75 * time.Second, Probes: 9, UserTimeout: 30 * time.Second},
+		},
+		{value: "time=5m", want: &TCPKeepalive{Time: 5 * time.Minute}},
+		{value: "", want: &TCPKeepalive{}},
+		{value: "time", err: true},
+		{value: "time=1500ms", err: true},
+		{value: "interval=0s", err: true},
+		{value: "probes=0", err: true},
+		{value: "probes=many", err: true},
+		{value: "userTimeout=0s", err: true},
+		{value: "idle=10s", err: true},
+	}
+	for _, c := range cases {
+		got, err := ParseTCPKeepalive(c.value)
+		if c.err {
+			if err == nil {
+				t.Errorf("%q: expected an error", c.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", c.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: got %+v, want %+v", c.value, got, c.want)
+		}
+	}
+}
+
+func TestProxyTCPKeepalive(t *testing.T) {
+	node := &Proxy{Metadata: &NodeMetadata{
+		DownstreamTCPKeepalive:           "time=60s",
+		DownstreamTCPKeepaliveAnnotation: "time=30s",
+		UpstreamTCPKeepalive:             "probes=3",
+	}}
+	if got := node.DownstreamTCPKeepalive(); got == nil || got.Time != 30*time.Second {
+		t.Errorf("expected the annotation to take precedence, got %+v", got)
+	}
+	if got := node.UpstreamTCPKeepalive(); got == nil || got.Probes != 3 {
+		t.Errorf("expected the proxy metadata keepalive, got %+v", got)
+	}
+	node.Metadata.UpstreamTCPKeepaliveAnnotation = "probes=none"
+	if got := node.UpstreamTCPKeepalive(); got != nil {
+		t.Errorf("expected an invalid keepalive to be ignored, got %+v", got)
+	}
+	if got := (&Proxy{}).DownstreamTCPKeepalive(); got != nil {
+		t.Errorf("expected no keepalive without metadata, got %+v", got)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"context"
+	"sort"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"istio.io/istio/pkg/config"
+)
+
+// tracerName is the name of the tracer of the push pipeline.
+const tracerName = "istio.io/istio/pilot"
+
+const (
+	// maxPushTriggers bounds the number of config changes a push request is linked to, as debouncing may merge
+	// a large number of changes.
+	maxPushTriggers = 32
+	// maxTracedConfigs bounds the number of updated configs recorded in the attributes of a span.
+	maxTracedConfigs = 10
+)
+
+// pushTracer is the tracer of the push pipeline. It does not record spans until push tracing is enabled.
+var pushTracer = trace.NewNoopTracerProvider().Tracer(tracerName)
+
+// SetPushTracerProvider sets the provider of the spans of the push pipeline. The global OpenTelemetry provider
+// is left untouched, so push tracing does not change the tracing of the other components of istiod.
+func SetPushTracerProvider(provider trace.TracerProvider) {
+	pushTracer = provider.Tracer(tracerName)
+}
+
+// StartSpan starts a span of the push pipeline, child of the parent span if valid.
+func StartSpan(parent trace.SpanContext, name string, opts ...trace.SpanStartOption) trace.Span {
+	ctx := context.Background()
+	if parent.IsValid() {
+		ctx = trace.ContextWithSpanContext(ctx, parent)
+	}
+	_, span := pushTracer.Start(ctx, name, opts...)
+	return span
+}
+
+// WithSpan returns a copy of the push request whose child spans are children of the span.
+func (pr *PushRequest) WithSpan(span trace.SpanContext) *PushRequest {
+	out := *pr
+	out.Span = span
+	return &out
+}
+
+// TraceConfigChange records a span for the change of a config, and links the push request to it.
+func (pr *PushRequest) TraceConfigChange(cfg config.Config, event Event) {
+	span := StartSpan(trace.SpanContext{}, "config change", trace.WithAttributes(
+		attribute.String("config.kind", cfg.GroupVersionKind.Kind),
+		attribute.String("config.namespace", cfg.Namespace),
+		attribute.String("config.name", cfg.Name),
+		attribute.String("config.resource_version", cfg.ResourceVersion),
+		attribute.Int64("config.generation", cfg.Generation),
+		attribute.String("config.event", event.String()),
+	))
+	span.End()
+	pr.addTrigger(span.SpanContext())
+}
+
+// TraceUpdate records a span for a push request not traced by the change that triggered it, such as endpoint
+// and service updates, and links the push request to it.
+func (pr *PushRequest) TraceUpdate() {
+	if len(pr.Triggers) > 0 {
+		return
+	}
+	span := StartSpan(trace.SpanContext{}, "config update")
+	if span.IsRecording() {
+		reasons := make([]string, 0, len(pr.Reason))
+		for _, reason := range pr.Reason {
+			reasons = append(reasons, string(reason))
+		}
+		configs := make([]string, 0, len(pr.ConfigsUpdated))
+		for key := range pr.ConfigsUpdated {
+			configs = append(configs, key.Kind.Kind+"/"+key.Namespace+"/"+key.Name)
+		}
+		sort.Strings(configs)
+		if len(configs) > maxTracedConfigs {
+			configs = configs[:maxTracedConfigs]
+		}
+		span.SetAttributes(
+			attribute.Bool("push.full", pr.Full),
+			attribute.StringSlice("push.reasons", reasons),
+			attribute.Int("push.configs_updated", len(pr.ConfigsUpdated)),
+			attribute.StringSlice("push.configs", configs),
+		)
+	}
+	span.End()
+	pr.addTrigger(span.SpanContext())
+}
+
+// TriggerLinks returns the links from the spans of the push to the changes that triggered it.
+func (pr *PushRequest) TriggerLinks() trace.SpanStartOption {
+	links := make([]trace.Link, 0, len(pr.Triggers))
+	for _, sc := range pr.Triggers {
+		links = append(links, trace.Link{SpanContext: sc})
+	}
+	return trace.WithLinks(links...)
+}
+
+func (pr *PushRequest) addTrigger(sc trace.SpanContext) {
+	if sc.IsValid() && len(pr.Triggers) < maxPushTriggers {
+		pr.Triggers = append(pr.Triggers, sc)
+	}
+}
+
+// mergeTriggers merges the triggers of two push requests, keeping the oldest ones when there are too many.
+func mergeTriggers(first, other []trace.SpanContext) []trace.SpanContext {
+	if len(first)+len(other) == 0 {
+		return nil
+	}
+	merged := make([]trace.SpanContext, 0, len(first)+len(other))
+	merged = append(merged, first...)
+	merged = append(merged, other...)
+	if len(merged) > maxPushTriggers {
+		merged = merged[:maxPushTriggers]
+	}
+	return merged
+}
@@ -0,0 +1,106 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+func TestPushRequestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	SetPushTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { SetPushTracerProvider(trace.NewNoopTracerProvider()) })
+
+	cfg := config.Config{Meta: config.Meta{
+		GroupVersionKind: gvk.VirtualService,
+		Namespace:        "default",
+		Name:             "reviews",
+		ResourceVersion:  "42",
+		Generation:       3,
+	}}
+	first := &PushRequest{Full: true}
+	first.TraceConfigChange(cfg, EventUpdate)
+	second := &PushRequest{Full: true, Reason: []TriggerReason{ServiceUpdate}}
+	second.TraceUpdate()
+
+	merged := first.Merge(second)
+	if len(merged.Triggers) != 2 {
+		t.Fatalf("got %d triggers, want 2", len(merged.Triggers))
+	}
+	spans := recorder.Ended()
+	if len(spans) != 2 || spans[0].Name() != "config change" || spans[1].Name() != "config update" {
+		t.Fatalf("unexpected spans %v", spans)
+	}
+	found := false
+	for _, a := range spans[0].Attributes() {
+		if a == attribute.String("config.name", "reviews") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("config.name not found in %v", spans[0].Attributes())
+	}
+	for i, sc := range merged.Triggers {
+		if sc.SpanID() != spans[i].SpanContext().SpanID() {
+			t.Errorf("trigger %d: got span %v, want %v", i, sc.SpanID(), spans[i].SpanContext().SpanID())
+		}
+	}
+
+	// Already traced requests are not traced again.
+	first.TraceUpdate()
+	if len(first.Triggers) != 1 {
+		t.Errorf("got %d triggers, want 1", len(first.Triggers))
+	}
+
+	span := StartSpan(merged.Span, "push", merged.TriggerLinks())
+	span.End()
+	merged = merged.WithSpan(span.SpanContext())
+	child := StartSpan(merged.Span, "push proxy")
+	child.End()
+	pushSpans := recorder.Ended()[2:]
+	if len(pushSpans) != 2 || len(pushSpans[0].Links()) != 2 ||
+		pushSpans[1].Parent().SpanID() != pushSpans[0].SpanContext().SpanID() {
+		t.Errorf("unexpected push spans %v", pushSpans)
+	}
+}
+
+func TestPushTracingDisabled(t *testing.T) {
+	pr := &PushRequest{Full: true}
+	pr.TraceUpdate()
+	if len(pr.Triggers) != 0 {
+		t.Errorf("got triggers %v, want none when push tracing is disabled", pr.Triggers)
+	}
+	if span := StartSpan(trace.SpanContext{}, "push"); span.IsRecording() {
+		t.Errorf("span recorded when push tracing is disabled")
+	}
+}
+
+func TestMergeTriggersBounded(t *testing.T) {
+	triggers := make([]trace.SpanContext, maxPushTriggers)
+	if got := mergeTriggers(triggers, triggers); len(got) != maxPushTriggers {
+		t.Errorf("got %d triggers, want %d", len(got), maxPushTriggers)
+	}
+	if got := mergeTriggers(nil, nil); got != nil {
+		t.Errorf("got %v, want nil", got)
+	}
+}
@@ -24,10 +24,31 @@ import (
 	"istio.io/istio/pilot/pkg/util/sets"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/routing"
 	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/istio/pkg/config/visibility"
 )
 
+// RouteMirrors returns the mirrors added to the HTTP routes of the virtual service with routing.MirrorsAnnotation,
+// keyed by route name. The hosts of the mirrors are resolved like the ones of the virtual service.
+func RouteMirrors(vs config.Config) map[string][]*routing.Mirror {
+	value, f := vs.Annotations[routing.MirrorsAnnotation]
+	if !f {
+		return nil
+	}
+	mirrors, err := routing.ParseMirrors(value)
+	if err != nil {
+		log.Debugf("ignored %s of virtual service %s/%s: %v", routing.MirrorsAnnotation, vs.Namespace, vs.Name, err)
+		return nil
+	}
+	for _, routeMirrors := range mirrors {
+		for _, m := range routeMirrors {
+			m.Destination.Host = string(ResolveShortnameToFQDN(m.Destination.Host, vs.Meta))
+		}
+	}
+	return mirrors
+}
+
 func resolveVirtualServiceShortnames(rule *networking.VirtualService, meta config.Meta) {
 	// resolve top level hosts
 	for i, h := range rule.Hosts {
@@ -37,6 +37,12 @@ type ConfigGenerator interface {
 	// BuildClusters returns the list of clusters for the given proxy. This is the CDS output
 	BuildClusters(node *model.Proxy, push *model.PushContext) []*cluster.Cluster
 
+	// BuildDeltaClusters returns the clusters affected by the configs updated in the push request, and the names
+	// of the sent clusters that were removed. It returns false if the updates cannot be scoped to some clusters,
+	// in which case all the clusters are built with BuildClusters.
+	BuildDeltaClusters(node *model.Proxy, push *model.PushContext, updates *model.PushRequest,
+		sent []string) ([]*cluster.Cluster, []string, bool)
+
 	// BuildHTTPRoutes returns the list of HTTP routes for the given proxy. This is the RDS output
 	BuildHTTPRoutes(node *model.Proxy, push *model.PushContext, routeNames []string) []*route.RouteConfiguration
 
@@ -30,6 +30,7 @@ import (
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/config/routing"
 	"istio.io/istio/pkg/util/protomarshal"
 	"istio.io/pkg/log"
 )
@@ -45,7 +46,7 @@ const (
 		"%DOWNSTREAM_REMOTE_ADDRESS% %REQUESTED_SERVER_NAME% %ROUTE_NAME%\n"
 	// EnvoyTextLogFormatIstio19 format for envoy text based access logs for Istio 1.9 onwards.
 	// This includes the additional new operator RESPONSE_CODE_DETAILS and CONNECTION_TERMINATION_DETAILS that tells
-	// the reason why Envoy rejects a request.
+	// the reason why Envoy rejects a request, and the results of the dry-run ALLOW and DENY authorization policies.
 	EnvoyTextLogFormatIstio19 = "[%START_TIME%] \"%REQ(:METHOD)% %REQ(X-ENVOY-ORIGINAL-PATH?:PATH)% " +
 		"%PROTOCOL%\" %RESPONSE_CODE% %RESPONSE_FLAGS% " +
 		"%RESPONSE_CODE_DETAILS% %CONNECTION_TERMINATION_DETAILS% " +
@@ -53,7 +54,9 @@ const (
 		"%DURATION% %RESP(X-ENVOY-UPSTREAM-SERVICE-TIME)% \"%REQ(X-FORWARDED-FOR)%\" " +
 		"\"%REQ(USER-AGENT)%\" \"%REQ(X-REQUEST-ID)%\" \"%REQ(:AUTHORITY)%\" \"%UPSTREAM_HOST%\" " +
 		"%UPSTREAM_CLUSTER% %UPSTREAM_LOCAL_ADDRESS% %DOWNSTREAM_LOCAL_ADDRESS% " +
-		"%DOWNSTREAM_REMOTE_ADDRESS% %REQUESTED_SERVER_NAME% %ROUTE_NAME%\n"
+		"%DOWNSTREAM_REMOTE_ADDRESS% %REQUESTED_SERVER_NAME% %ROUTE_NAME% " +
+		"%DYNAMIC_METADATA(envoy.filters.http.rbac:istio_dry_run_allow_shadow_engine_result)% " +
+		"%DYNAMIC_METADATA(envoy.filters.http.rbac:istio_dry_run_deny_shadow_engine_result)%\n"
 
 	// EnvoyServerName for istio's envoy
 	EnvoyServerName = "istio-envoy"
@@ -67,6 +70,9 @@ const (
 	// EnvoyAccessLogCluster is the cluster name that has details for server implementing Envoy ALS.
 	// This cluster is created in bootstrap.
 	EnvoyAccessLogCluster = "envoy_accesslog_service"
+
+	// defaultAccessLogFile is the file access log of the workloads enabling it without path, when the mesh has none.
+	defaultAccessLogFile = "/dev/stdout"
 )
 
 var (
@@ -101,7 +107,7 @@ var (
 
 	// EnvoyJSONLogFormatIstio19 map of values for envoy json based access logs for Istio 1.9 onwards.
 	// This includes the additional log operator RESPONSE_CODE_DETAILS and CONNECTION_TERMINATION_DETAILS that tells
-	// the reason why Envoy rejects a request.
+	// the reason why Envoy rejects a request, and the results of the dry-run ALLOW and DENY authorization policies.
 	EnvoyJSONLogFormatIstio19 = &structpb.Struct{
 		Fields: map[string]*structpb.Value{
 			"start_time":                        {Kind: &structpb.Value_StringValue{StringValue: "%START_TIME%"}},
@@ -128,6 +134,18 @@ var (
 			"downstream_remote_address":         {Kind: &structpb.Value_StringValue{StringValue: "%DOWNSTREAM_REMOTE_ADDRESS%"}},
 			"requested_server_name":             {Kind: &structpb.Value_StringValue{StringValue: "%REQUESTED_SERVER_NAME%"}},
 			"upstream_transport_failure_reason": {Kind: &structpb.Value_StringValue{StringValue: "%UPSTREAM_TRANSPORT_FAILURE_REASON%"}},
+			"authz_dry_run_allow_result": {
+				Kind: &structpb.Value_StringValue{StringValue: "%DYNAMIC_METADATA(envoy.filters.http.rbac:istio_dry_run_allow_shadow_engine_result)%"},
+			},
+			"authz_dry_run_allow_policy": {
+				Kind: &structpb.Value_StringValue{StringValue: "%DYNAMIC_METADATA(envoy.filters.http.rbac:istio_dry_run_allow_shadow_effective_policy_id)%"},
+			},
+			"authz_dry_run_deny_result": {
+				Kind: &structpb.Value_StringValue{StringValue: "%DYNAMIC_METADATA(envoy.filters.http.rbac:istio_dry_run_deny_shadow_engine_result)%"},
+			},
+			"authz_dry_run_deny_policy": {
+				Kind: &structpb.Value_StringValue{StringValue: "%DYNAMIC_METADATA(envoy.filters.http.rbac:istio_dry_run_deny_shadow_effective_policy_id)%"},
+			},
 		},
 	}
 
@@ -165,22 +183,75 @@ func newAccessLogBuilder() *AccessLogBuilder {
 	}
 }
 
+// accessLogSettings are the access log settings of a proxy: those of the mesh, overridden by the Sidecar of the
+// proxy, if any.
+type accessLogSettings struct {
+	file     bool
+	als      bool
+	path     string
+	encoding meshconfig.MeshConfig_AccessLogEncoding
+	format   string
+	// overridden is true if the file access log differs from the one of the mesh, so it can't be cached.
+	overridden bool
+}
+
+func buildAccessLogSettings(mesh *meshconfig.MeshConfig, node *model.Proxy) accessLogSettings {
+	s := accessLogSettings{
+		file:     mesh.AccessLogFile != "",
+		als:      mesh.EnableEnvoyAccessLogService,
+		path:     mesh.AccessLogFile,
+		encoding: mesh.AccessLogEncoding,
+		format:   mesh.AccessLogFormat,
+	}
+	if node == nil || node.SidecarScope == nil || node.SidecarScope.AccessLog == nil {
+		return s
+	}
+	override := node.SidecarScope.AccessLog
+	if override.Providers != nil {
+		s.file, s.als = false, false
+		for _, provider := range override.Providers {
+			switch provider {
+			case routing.AccessLogProviderFile:
+				s.file = true
+			case routing.AccessLogProviderEnvoyALS:
+				s.als = true
+			}
+		}
+	}
+	if override.Path != "" {
+		s.path = override.Path
+	}
+	if s.file && s.path == "" {
+		s.path = defaultAccessLogFile
+	}
+	if override.Encoding != "" {
+		s.encoding = meshconfig.MeshConfig_AccessLogEncoding(meshconfig.MeshConfig_AccessLogEncoding_value[override.Encoding])
+	}
+	if override.Format != "" {
+		s.format = override.Format
+	}
+	s.overridden = s.path != mesh.AccessLogFile || s.encoding != mesh.AccessLogEncoding || s.format != mesh.AccessLogFormat
+	return s
+}
+
 func (b *AccessLogBuilder) setTCPAccessLog(mesh *meshconfig.MeshConfig, config *tcp.TcpProxy, node *model.Proxy) {
-	if mesh.AccessLogFile != "" {
-		config.AccessLog = append(config.AccessLog, b.buildFileAccessLog(mesh, node))
+	settings := buildAccessLogSettings(mesh, node)
+	if settings.file {
+		config.AccessLog = append(config.AccessLog, b.buildFileAccessLog(settings, node))
 	}
 
-	if mesh.EnableEnvoyAccessLogService {
+	if settings.als {
 		config.AccessLog = append(config.AccessLog, b.tcpGrpcAccessLog)
 	}
 }
 
 func (b *AccessLogBuilder) setHTTPAccessLog(mesh *meshconfig.MeshConfig, connectionManager *hcm.HttpConnectionManager, node *model.Proxy) {
-	if mesh.AccessLogFile != "" {
-		connectionManager.AccessLog = append(connectionManager.AccessLog, b.buildFileAccessLog(mesh, node))
+	settings := buildAccessLogSettings(mesh, node)
+	if settings.file {
+		connectionManager.AccessLog = append(connectionManager.AccessLog, b.buildFileAccessLog(settings, node))
 	}
 
-	if mesh.EnableEnvoyAccessLogService {
+	if settings.als {
 		connectionManager.AccessLog = append(connectionManager.AccessLog, b.httpGrpcAccessLog)
 	}
 }
@@ -189,30 +260,31 @@ func (b *AccessLogBuilder) setListenerAccessLog(mesh *meshconfig.MeshConfig, lis
 	if mesh.DisableEnvoyListenerLog {
 		return
 	}
-	if mesh.AccessLogFile != "" {
-		listener.AccessLog = append(listener.AccessLog, b.buildListenerFileAccessLog(mesh, node))
+	settings := buildAccessLogSettings(mesh, node)
+	if settings.file {
+		listener.AccessLog = append(listener.AccessLog, b.buildListenerFileAccessLog(settings, node))
 	}
 
-	if mesh.EnableEnvoyAccessLogService {
+	if settings.als {
 		// Setting it to TCP as the low level one.
 		listener.AccessLog = append(listener.AccessLog, b.tcpGrpcListenerAccessLog)
 	}
 }
 
-func buildFileAccessLogHelper(mesh *meshconfig.MeshConfig, isVersionGE19 bool) *accesslog.AccessLog {
+func buildFileAccessLogHelper(settings accessLogSettings, isVersionGE19 bool) *accesslog.AccessLog {
 	// We need to build access log. This is needed either on first access or when mesh config changes.
 	fl := &fileaccesslog.FileAccessLog{
-		Path: mesh.AccessLogFile,
+		Path: settings.path,
 	}
 
-	switch mesh.AccessLogEncoding {
+	switch settings.encoding {
 	case meshconfig.MeshConfig_TEXT:
 		formatString := EnvoyTextLogFormat
 		if isVersionGE19 {
 			formatString = EnvoyTextLogFormatIstio19
 		}
-		if mesh.AccessLogFormat != "" {
-			formatString = mesh.AccessLogFormat
+		if settings.format != "" {
+			formatString = settings.format
 		}
 		fl.AccessLogFormat = &fileaccesslog.FileAccessLog_LogFormat{
 			LogFormat: &core.SubstitutionFormatString{
@@ -226,9 +298,9 @@ func buildFileAccessLogHelper(mesh *meshconfig.MeshConfig, isVersionGE19 bool) *
 		if isVersionGE19 {
 			jsonLogStruct = EnvoyJSONLogFormatIstio19
 		}
-		if len(mesh.AccessLogFormat) > 0 {
+		if len(settings.format) > 0 {
 			parsedJSONLogStruct := structpb.Struct{}
-			if err := protomarshal.ApplyJSON(mesh.AccessLogFormat, &parsedJSONLogStruct); err != nil {
+			if err := protomarshal.ApplyJSON(settings.format, &parsedJSONLogStruct); err != nil {
 				log.Errorf("error parsing provided json log format, default log format will be used: %v", err)
 			} else {
 				jsonLogStruct = &parsedJSONLogStruct
@@ -242,7 +314,7 @@ func buildFileAccessLogHelper(mesh *meshconfig.MeshConfig, isVersionGE19 bool) *
 			},
 		}
 	default:
-		log.Warnf("unsupported access log format %v", mesh.AccessLogEncoding)
+		log.Warnf("unsupported access log format %v", settings.encoding)
 	}
 
 	al := &accesslog.AccessLog{
@@ -253,15 +325,19 @@ func buildFileAccessLogHelper(mesh *meshconfig.MeshConfig, isVersionGE19 bool) *
 	return al
 }
 
-func (b *AccessLogBuilder) buildFileAccessLog(mesh *meshconfig.MeshConfig, node *model.Proxy) *accesslog.AccessLog {
-	// Check if cached config is available, and return immediately.
+func (b *AccessLogBuilder) buildFileAccessLog(settings accessLogSettings, node *model.Proxy) *accesslog.AccessLog {
 	isVersionGE19 := util.IsIstioVersionGE19(node)
+	// The access logs overridden by the Sidecar of the proxy are specific to its workloads.
+	if settings.overridden {
+		return buildFileAccessLogHelper(settings, isVersionGE19)
+	}
+	// Check if cached config is available, and return immediately.
 	if cal := b.cachedFileAccessLog(isVersionGE19); cal != nil {
 		return cal
 	}
 
 	// We need to build access log. This is needed either on first access or when mesh config changes.
-	al := buildFileAccessLogHelper(mesh, isVersionGE19)
+	al := buildFileAccessLogHelper(settings, isVersionGE19)
 
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
@@ -282,18 +358,23 @@ func addAccessLogFilter() *accesslog.AccessLogFilter {
 	}
 }
 
-func (b *AccessLogBuilder) buildListenerFileAccessLog(mesh *meshconfig.MeshConfig, node *model.Proxy) *accesslog.AccessLog {
-	// Check if cached config is available, and return immediately.
+func (b *AccessLogBuilder) buildListenerFileAccessLog(settings accessLogSettings, node *model.Proxy) *accesslog.AccessLog {
 	isVersionGE19 := util.IsIstioVersionGE19(node)
-	if cal := b.cachedListenerFileAccessLog(isVersionGE19); cal != nil {
-		return cal
+	if !settings.overridden {
+		// Check if cached config is available, and return immediately.
+		if cal := b.cachedListenerFileAccessLog(isVersionGE19); cal != nil {
+			return cal
+		}
 	}
 
 	// We need to build access log. This is needed either on first access or when mesh config changes.
-	lal := buildFileAccessLogHelper(mesh, isVersionGE19)
+	lal := buildFileAccessLogHelper(settings, isVersionGE19)
 	// We add ResponseFlagFilter here, as we want to get listener access logs only on scenarios where we might
 	// not get filter Access Logs like in cases like NR to upstream.
 	lal.Filter = addAccessLogFilter()
+	if settings.overridden {
+		return lal
+	}
 
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
@@ -26,6 +26,7 @@ import (
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/config/routing"
 	"istio.io/istio/pkg/util/protomarshal"
 )
 
@@ -157,6 +158,69 @@ func TestListenerAccessLog(t *testing.T) {
 	}
 }
 
+func TestAccessLogSettingsOverride(t *testing.T) {
+	mesh := &meshconfig.MeshConfig{
+		AccessLogFile:     "/dev/stdout",
+		AccessLogEncoding: meshconfig.MeshConfig_TEXT,
+	}
+	for _, tc := range []struct {
+		name      string
+		mesh      *meshconfig.MeshConfig
+		accessLog *routing.AccessLog
+		want      accessLogSettings
+	}{
+		{
+			name: "no override",
+			mesh: mesh,
+			want: accessLogSettings{file: true, path: "/dev/stdout", encoding: meshconfig.MeshConfig_TEXT},
+		},
+		{
+			name:      "json format",
+			mesh:      mesh,
+			accessLog: &routing.AccessLog{Encoding: "JSON", Format: `{"path":"%REQ(:PATH)%"}`},
+			want: accessLogSettings{file: true, path: "/dev/stdout", encoding: meshconfig.MeshConfig_JSON,
+				format: `{"path":"%REQ(:PATH)%"}`, overridden: true},
+		},
+		{
+			name:      "access log service only",
+			mesh:      mesh,
+			accessLog: &routing.AccessLog{Providers: []string{routing.AccessLogProviderEnvoyALS}},
+			want:      accessLogSettings{als: true, path: "/dev/stdout", encoding: meshconfig.MeshConfig_TEXT},
+		},
+		{
+			name:      "disabled",
+			mesh:      mesh,
+			accessLog: &routing.AccessLog{Providers: []string{}},
+			want:      accessLogSettings{path: "/dev/stdout", encoding: meshconfig.MeshConfig_TEXT},
+		},
+		{
+			name:      "file enabled without mesh file",
+			mesh:      &meshconfig.MeshConfig{},
+			accessLog: &routing.AccessLog{Providers: []string{routing.AccessLogProviderFile}},
+			want:      accessLogSettings{file: true, path: defaultAccessLogFile, overridden: true},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			node := &model.Proxy{SidecarScope: &model.SidecarScope{AccessLog: tc.accessLog}}
+			if got := buildAccessLogSettings(tc.mesh, node); got != tc.want {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAccessLogOverrideNotCached(t *testing.T) {
+	accessLogBuilder.reset()
+	defer accessLogBuilder.reset()
+	mesh := &meshconfig.MeshConfig{AccessLogFile: "/dev/stdout", AccessLogEncoding: meshconfig.MeshConfig_TEXT}
+	overridden := &model.Proxy{SidecarScope: &model.SidecarScope{AccessLog: &routing.AccessLog{Format: "%REQ(:PATH)%\n"}}}
+
+	al := accessLogBuilder.buildFileAccessLog(buildAccessLogSettings(mesh, overridden), overridden)
+	verify(t, meshconfig.MeshConfig_TEXT, al, "%REQ(:PATH)%\n")
+	al = accessLogBuilder.buildFileAccessLog(buildAccessLogSettings(mesh, &model.Proxy{}), &model.Proxy{})
+	verify(t, meshconfig.MeshConfig_TEXT, al, EnvoyTextLogFormatIstio19)
+}
+
 func verify(t *testing.T, encoding meshconfig.MeshConfig_AccessLogEncoding, got *accesslog.AccessLog, wantFormat string) {
 	cfg, _ := conversion.MessageToStruct(got.GetTypedConfig())
 	if encoding == meshconfig.MeshConfig_JSON {
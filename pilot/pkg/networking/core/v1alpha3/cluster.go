@@ -19,10 +19,12 @@ import (
 	"math"
 	"strconv"
 	"strings"
+	"time"
 
 	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	proxyprotocol "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/proxy_protocol/v3"
 	auth "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	xdstype "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/gogo/protobuf/types"
@@ -36,11 +38,15 @@ import (
 	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/envoyfilter"
 	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/loadbalancer"
 	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/security/authn/factory"
 	authn_model "istio.io/istio/pilot/pkg/security/model"
 	"istio.io/istio/pilot/pkg/serviceregistry"
 	"istio.io/istio/pilot/pkg/util/sets"
 	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/labels"
 	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/routing"
+	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/istio/pkg/util/gogo"
 	"istio.io/pkg/log"
 )
@@ -48,6 +54,9 @@ import (
 const (
 	// DefaultLbType set to round robin
 	DefaultLbType = networking.LoadBalancerSettings_ROUND_ROBIN
+
+	// activeRequestBiasRuntimeKey is the runtime key that can override the active request bias of the clusters.
+	activeRequestBiasRuntimeKey = "upstream.least_request.active_request_bias"
 )
 
 var (
@@ -125,6 +134,55 @@ func (configgen *ConfigGeneratorImpl) BuildClusters(proxy *model.Proxy, push *mo
 	return clusters
 }
 
+// BuildDeltaClusters returns the outbound clusters of the services updated by the push request, and the names of
+// the sent clusters of these services that are no longer built. Only ServiceEntry updates of a sidecar, which do
+// not update its own services, are scoped: the other configs, such as DestinationRules, may affect any cluster.
+func (configgen *ConfigGeneratorImpl) BuildDeltaClusters(proxy *model.Proxy, push *model.PushContext,
+	updates *model.PushRequest, sent []string) ([]*cluster.Cluster, []string, bool) {
+	if proxy.Type != model.SidecarProxy || len(updates.ConfigsUpdated) == 0 {
+		return nil, nil, false
+	}
+	updated := map[host.Name]struct{}{}
+	for key := range updates.ConfigsUpdated {
+		if key.Kind != gvk.ServiceEntry {
+			return nil, nil, false
+		}
+		updated[host.Name(key.Name)] = struct{}{}
+	}
+	// The inbound clusters are built from the services of the proxy.
+	for _, instance := range proxy.ServiceInstances {
+		if _, f := updated[instance.Service.Hostname]; f {
+			return nil, nil, false
+		}
+	}
+
+	var services []*model.Service
+	for _, service := range push.Services(proxy) {
+		if _, f := updated[service.Hostname]; f {
+			services = append(services, service)
+		}
+	}
+	cb := NewClusterBuilder(proxy, push)
+	patcher := clusterPatcher{push.EnvoyFilters(proxy), networking.EnvoyFilter_SIDECAR_OUTBOUND}
+	clusters := normalizeClusters(push, proxy, configgen.buildOutboundClustersForServices(cb, patcher, services))
+
+	built := sets.NewSet()
+	for _, c := range clusters {
+		built.Insert(c.Name)
+	}
+	var removed []string
+	for _, name := range sent {
+		direction, _, hostname, _ := model.ParseSubsetKey(name)
+		if direction != model.TrafficDirectionOutbound && direction != model.TrafficDirectionOutboundHBONE {
+			continue
+		}
+		if _, f := updated[hostname]; f && !built.Contains(name) {
+			removed = append(removed, name)
+		}
+	}
+	return clusters, removed, true
+}
+
 // resolves cluster name conflicts. there can be duplicate cluster names if there are conflicting service definitions.
 // for any clusters that share the same name the first cluster is kept and the others are discarded.
 func normalizeClusters(metrics model.Metrics, proxy *model.Proxy, clusters []*cluster.Cluster) []*cluster.Cluster {
@@ -143,15 +201,21 @@ func normalizeClusters(metrics model.Metrics, proxy *model.Proxy, clusters []*cl
 }
 
 func (configgen *ConfigGeneratorImpl) buildOutboundClusters(cb *ClusterBuilder, cp clusterPatcher) []*cluster.Cluster {
-	clusters := make([]*cluster.Cluster, 0)
-	networkView := model.GetNetworkView(cb.proxy)
-
 	var services []*model.Service
 	if features.FilterGatewayClusterConfig && cb.proxy.Type == model.Router {
 		services = cb.push.GatewayServices(cb.proxy)
 	} else {
 		services = cb.push.Services(cb.proxy)
 	}
+	return configgen.buildOutboundClustersForServices(cb, cp, services)
+}
+
+// buildOutboundClustersForServices builds the outbound clusters of the given services.
+func (configgen *ConfigGeneratorImpl) buildOutboundClustersForServices(cb *ClusterBuilder, cp clusterPatcher,
+	services []*model.Service) []*cluster.Cluster {
+	clusters := make([]*cluster.Cluster, 0)
+	networkView := model.GetNetworkView(cb.proxy)
+	routePools := cb.routeConnectionPools()
 	for _, service := range services {
 		for _, port := range service.Ports {
 			if port.Protocol == protocol.UDP {
@@ -160,7 +224,7 @@ func (configgen *ConfigGeneratorImpl) buildOutboundClusters(cb *ClusterBuilder,
 			lbEndpoints := cb.buildLocalityLbEndpoints(networkView, service, port.Port, nil)
 
 			// create default cluster
-			discoveryType := convertResolution(cb.proxy, service)
+			discoveryType := convertResolution(cb.proxy, service, port.Port)
 			clusterName := model.BuildSubsetKey(model.TrafficDirectionOutbound, "", service.Hostname, port.Port)
 			defaultCluster := cb.buildDefaultCluster(clusterName, discoveryType, lbEndpoints, model.TrafficDirectionOutbound, port, service, nil)
 			if defaultCluster == nil {
@@ -175,8 +239,13 @@ func (configgen *ConfigGeneratorImpl) buildOutboundClusters(cb *ClusterBuilder,
 
 			subsetClusters := cb.applyDestinationRule(defaultCluster, DefaultClusterMode, service, port, networkView)
 
+			routeClusters := cb.buildRouteClusters(routePools[service.Hostname], service, port,
+				append([]*cluster.Cluster{defaultCluster}, subsetClusters...))
+
 			clusters = cp.conditionallyAppend(clusters, nil, defaultCluster)
 			clusters = cp.conditionallyAppend(clusters, nil, subsetClusters...)
+			clusters = cp.conditionallyAppend(clusters, nil, routeClusters...)
+			clusters = cp.conditionallyAppend(clusters, nil, cb.buildOutboundHBONEClusters(service, port)...)
 		}
 	}
 
@@ -223,7 +292,7 @@ func (configgen *ConfigGeneratorImpl) buildOutboundSniDnatClusters(proxy *model.
 			lbEndpoints := cb.buildLocalityLbEndpoints(networkView, service, port.Port, nil)
 
 			// create default cluster
-			discoveryType := convertResolution(proxy, service)
+			discoveryType := convertResolution(proxy, service, port.Port)
 
 			clusterName := model.BuildDNSSrvSubsetKey(model.TrafficDirectionOutbound, "", service.Hostname, port.Port)
 			defaultCluster := cb.buildDefaultCluster(clusterName, discoveryType, lbEndpoints, model.TrafficDirectionOutbound, port, service, nil)
@@ -272,6 +341,8 @@ func (configgen *ConfigGeneratorImpl) buildInboundClusters(cb *ClusterBuilder, i
 	noneMode := cb.proxy.GetInterceptionMode() == model.InterceptionNone
 
 	_, actualLocalHost := getActualWildcardAndLocalHost(cb.proxy)
+	// The effective mTLS mode of each port is reported in the cluster metadata, for both HTTP and TCP listeners.
+	applier := factory.NewPolicyApplier(cb.push, cb.proxy.Metadata.Namespace, labels.Collection{cb.proxy.Metadata.Labels})
 
 	if !sidecarScope.HasCustomIngressListeners {
 		// No user supplied sidecar scope or the user supplied one has no ingress listeners
@@ -302,6 +373,8 @@ func (configgen *ConfigGeneratorImpl) buildInboundClusters(cb *ClusterBuilder, i
 		for _, instances := range clustersToBuild {
 			instance := instances[0]
 			localCluster := cb.buildInboundClusterForPortOrUDS(cb.proxy, int(instance.Endpoint.EndpointPort), actualLocalHost, instance, instances)
+			localCluster.Metadata = util.AddMTLSModeToMetadata(localCluster.Metadata,
+				applier.MutualTLSMode(instance.Endpoint.EndpointPort, cb.proxy).String())
 			// If inbound cluster match has service, we should see if it matches with any host name across all instances.
 			var hosts []host.Name
 			for _, si := range instances {
@@ -356,6 +429,8 @@ func (configgen *ConfigGeneratorImpl) buildInboundClusters(cb *ClusterBuilder, i
 		instance.Endpoint.EndpointPort = uint32(port)
 
 		localCluster := cb.buildInboundClusterForPortOrUDS(cb.proxy, int(ingressListener.Port.Number), endpointAddress, instance, nil)
+		localCluster.Metadata = util.AddMTLSModeToMetadata(localCluster.Metadata,
+			applier.MutualTLSMode(ingressListener.Port.Number, cb.proxy).String())
 		if instanceIPCluster {
 			// IPTables will redirect our own traffic back to us if we do not use the "magic" upstream bind
 			// config which will be skipped. This mirrors the "passthrough" clusters.
@@ -401,8 +476,8 @@ func (configgen *ConfigGeneratorImpl) findOrCreateServiceInstance(instances []*m
 	}
 }
 
-func convertResolution(proxy *model.Proxy, service *model.Service) cluster.Cluster_DiscoveryType {
-	switch service.Resolution {
+func convertResolution(proxy *model.Proxy, service *model.Service, port int) cluster.Cluster_DiscoveryType {
+	switch service.ResolutionForPort(port) {
 	case model.ClientSideLB:
 		return cluster.Cluster_EDS
 	case model.DNSLB:
@@ -617,6 +692,10 @@ func applyTrafficPolicy(opts buildClusterOpts) {
 	// Connection pool settings are applicable for both inbound and outbound clusters.
 	applyConnectionPool(opts.mesh, opts.cluster, connectionPool)
 	if opts.direction != model.TrafficDirectionInbound {
+		// The TCP keepalive of a destination rule takes precedence over the one of the proxy.
+		if connectionPool.GetTcp().GetTcpKeepalive() == nil && opts.proxy != nil {
+			applyUpstreamTCPKeepalive(opts.cluster, opts.proxy.UpstreamTCPKeepalive())
+		}
 		applyH2Upgrade(opts, connectionPool)
 		applyOutlierDetection(opts.cluster, outlierDetection)
 		applyLoadBalancer(opts.cluster, loadBalancer, opts.port, opts.proxy, opts.mesh)
@@ -636,8 +715,40 @@ func applyConnectionPool(mesh *meshconfig.MeshConfig, c *cluster.Cluster, settin
 	if settings == nil {
 		return
 	}
+	applyConnectionPoolThreshold(mesh, c, settings, getDefaultCircuitBreakerThresholds())
+}
 
+// applyRouteConnectionPool applies the connection pool overrides of an HTTP route to a copy of the cluster of its
+// destination. Unlike applyConnectionPool, the circuit breaker thresholds of the cluster are kept for the fields
+// not overridden.
+func applyRouteConnectionPool(mesh *meshconfig.MeshConfig, c *cluster.Cluster, settings *networking.ConnectionPoolSettings) {
 	threshold := getDefaultCircuitBreakerThresholds()
+	if thresholds := c.GetCircuitBreakers().GetThresholds(); len(thresholds) > 0 {
+		threshold = thresholds[0]
+	}
+	applyConnectionPoolThreshold(mesh, c, settings, threshold)
+}
+
+// applyRetryBudget limits the concurrent retries of the cluster to a percentage of its active and pending requests.
+// The budget takes precedence over the max retries of the circuit breaker thresholds of the cluster.
+func applyRetryBudget(c *cluster.Cluster, budget *routing.RetryBudget) {
+	threshold := getDefaultCircuitBreakerThresholds()
+	if thresholds := c.GetCircuitBreakers().GetThresholds(); len(thresholds) > 0 {
+		threshold = thresholds[0]
+	}
+	threshold.RetryBudget = &cluster.CircuitBreakers_Thresholds_RetryBudget{
+		BudgetPercent: &xdstype.Percent{Value: budget.Percent},
+	}
+	if budget.MinRetryConcurrency > 0 {
+		threshold.RetryBudget.MinRetryConcurrency = &wrappers.UInt32Value{Value: budget.MinRetryConcurrency}
+	}
+	c.CircuitBreakers = &cluster.CircuitBreakers{
+		Thresholds: []*cluster.CircuitBreakers_Thresholds{threshold},
+	}
+}
+
+func applyConnectionPoolThreshold(mesh *meshconfig.MeshConfig, c *cluster.Cluster, settings *networking.ConnectionPoolSettings,
+	threshold *cluster.CircuitBreakers_Thresholds) {
 	var idleTimeout *types.Duration
 
 	if settings.Http != nil {
@@ -708,6 +819,29 @@ func applyTCPKeepalive(mesh *meshconfig.MeshConfig, c *cluster.Cluster, settings
 	}
 }
 
+// applyUpstreamTCPKeepalive applies the upstream TCP keepalive of a proxy to an outbound cluster, overriding the mesh
+// wide TCP keepalive.
+func applyUpstreamTCPKeepalive(c *cluster.Cluster, keepalive *model.TCPKeepalive) {
+	if keepalive == nil {
+		return
+	}
+	if c.UpstreamConnectionOptions == nil {
+		c.UpstreamConnectionOptions = &cluster.UpstreamConnectionOptions{}
+	}
+	if c.UpstreamConnectionOptions.TcpKeepalive == nil {
+		c.UpstreamConnectionOptions.TcpKeepalive = &core.TcpKeepalive{}
+	}
+	if keepalive.Probes > 0 {
+		c.UpstreamConnectionOptions.TcpKeepalive.KeepaliveProbes = &wrappers.UInt32Value{Value: keepalive.Probes}
+	}
+	if keepalive.Time > 0 {
+		c.UpstreamConnectionOptions.TcpKeepalive.KeepaliveTime = &wrappers.UInt32Value{Value: uint32(keepalive.Time / time.Second)}
+	}
+	if keepalive.Interval > 0 {
+		c.UpstreamConnectionOptions.TcpKeepalive.KeepaliveInterval = &wrappers.UInt32Value{Value: uint32(keepalive.Interval / time.Second)}
+	}
+}
+
 func setKeepAliveSettings(cluster *cluster.Cluster, keepalive *networking.ConnectionPoolSettings_TCPSettings_TcpKeepalive) {
 	if keepalive.Probes > 0 {
 		cluster.UpstreamConnectionOptions.TcpKeepalive.KeepaliveProbes = &wrappers.UInt32Value{Value: keepalive.Probes}
@@ -781,6 +915,55 @@ func applyOutlierDetection(c *cluster.Cluster, outlier *networking.OutlierDetect
 	}
 }
 
+// applyUpstreamProxyProtocol wraps the transport sockets of the cluster so that a PROXY protocol header of the given
+// version is sent at the start of the connections. Plaintext connections get a raw buffer socket wrapped.
+func applyUpstreamProxyProtocol(c *cluster.Cluster, version core.ProxyProtocolConfig_Version) {
+	wrap := func(inner *core.TransportSocket) *core.TransportSocket {
+		if inner == nil {
+			inner = &core.TransportSocket{Name: util.EnvoyRawBufferSocketName}
+		}
+		return &core.TransportSocket{
+			Name: util.EnvoyProxyProtocolSocketName,
+			ConfigType: &core.TransportSocket_TypedConfig{TypedConfig: util.MessageToAny(&proxyprotocol.ProxyProtocolUpstreamTransport{
+				Config:          &core.ProxyProtocolConfig{Version: version},
+				TransportSocket: inner,
+			})},
+		}
+	}
+	if len(c.TransportSocketMatches) == 0 {
+		c.TransportSocket = wrap(c.TransportSocket)
+		return
+	}
+	// The matches may be shared, like defaultTransportSocketMatch, so they are copied rather than modified.
+	matches := make([]*cluster.Cluster_TransportSocketMatch, 0, len(c.TransportSocketMatches))
+	for _, m := range c.TransportSocketMatches {
+		matches = append(matches, &cluster.Cluster_TransportSocketMatch{
+			Name:            m.Name,
+			Match:           m.Match,
+			TransportSocket: wrap(m.TransportSocket),
+		})
+	}
+	c.TransportSocketMatches = matches
+}
+
+// applyLocalOriginOutlierDetection splits the local origin errors from the errors of the upstream hosts in the
+// outlier detection of the cluster, ejecting the hosts after the given number of consecutive local origin failures.
+// It does nothing if the cluster has no outlier detection.
+func applyLocalOriginOutlierDetection(c *cluster.Cluster, failures uint32) {
+	if c.OutlierDetection == nil {
+		return
+	}
+	c.OutlierDetection.SplitExternalLocalOriginErrors = true
+	// SuccessRate based outlier detection should be disabled for local origin errors too.
+	c.OutlierDetection.EnforcingLocalOriginSuccessRate = &wrappers.UInt32Value{Value: 0}
+	c.OutlierDetection.ConsecutiveLocalOriginFailure = &wrappers.UInt32Value{Value: failures}
+	enforcing := uint32(0)
+	if failures > 0 {
+		enforcing = 100
+	}
+	c.OutlierDetection.EnforcingConsecutiveLocalOriginFailure = &wrappers.UInt32Value{Value: enforcing}
+}
+
 func applyLoadBalancer(c *cluster.Cluster, lb *networking.LoadBalancerSettings, port *model.Port, proxy *model.Proxy, meshConfig *meshconfig.MeshConfig) {
 	localityLbSetting := loadbalancer.GetLocalityLbSetting(meshConfig.GetLocalityLbSetting(), lb.GetLocalityLbSetting())
 	if localityLbSetting != nil && (localityLbSetting.Distribute != nil || localityLbSetting.Failover != nil) {
@@ -846,6 +1029,21 @@ func applyLoadBalancer(c *cluster.Cluster, lb *networking.LoadBalancerSettings,
 	}
 }
 
+// applyActiveRequestBias sets the active request bias of the cluster if it uses the LEAST_REQUEST load balancer.
+func applyActiveRequestBias(c *cluster.Cluster, bias float64) {
+	if c.LbPolicy != cluster.Cluster_LEAST_REQUEST {
+		return
+	}
+	c.LbConfig = &cluster.Cluster_LeastRequestLbConfig_{
+		LeastRequestLbConfig: &cluster.Cluster_LeastRequestLbConfig{
+			ActiveRequestBias: &core.RuntimeDouble{
+				DefaultValue: bias,
+				RuntimeKey:   activeRequestBiasRuntimeKey,
+			},
+		},
+	}
+}
+
 func applyLocalityLBSetting(locality *core.Locality, cluster *cluster.Cluster, localityLB *networking.LocalityLoadBalancerSetting) {
 	if locality == nil || localityLB == nil {
 		return
@@ -903,13 +1101,25 @@ var istioMtlsTransportSocketMatch = &structpb.Struct{
 	},
 }
 
+// sidecarCanFetchCredential returns true if the sidecar is served the secret of the credentialName over SDS: it must
+// be allowed to read secrets, and the secret must be in its namespace.
+func sidecarCanFetchCredential(proxy *model.Proxy, credentialName string) bool {
+	if !features.EnableSidecarCredentialName || !proxy.SecretsAuthorized {
+		return false
+	}
+	if parts := strings.SplitN(credentialName, "/", 2); len(parts) == 2 {
+		return parts[0] == proxy.ConfigNamespace
+	}
+	return true
+}
+
 func buildUpstreamClusterTLSContext(opts *buildClusterOpts, tls *networking.ClientTLSSettings) (*auth.UpstreamTlsContext, error) {
 	c := opts.cluster
 	proxy := opts.proxy
 
 	// Hack to avoid egress sds cluster config generation for sidecar when
-	// CredentialName is set in DestinationRule
-	if tls.CredentialName != "" && proxy.Type == model.SidecarProxy {
+	// CredentialName is set in DestinationRule, unless the sidecar can fetch it from Istiod.
+	if tls.CredentialName != "" && proxy.Type == model.SidecarProxy && !sidecarCanFetchCredential(proxy, tls.CredentialName) {
 		if tls.Mode == networking.ClientTLSSettings_SIMPLE || tls.Mode == networking.ClientTLSSettings_MUTUAL {
 			return nil, nil
 		}
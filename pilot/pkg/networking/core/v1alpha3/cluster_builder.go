@@ -21,19 +21,35 @@ import (
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	"github.com/gogo/protobuf/types"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/duration"
 	"github.com/golang/protobuf/ptypes/wrappers"
 
+	meshconfig "istio.io/api/mesh/v1alpha1"
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/routing"
 	"istio.io/istio/pkg/util/gogo"
+	"istio.io/pkg/log"
 )
 
 var defaultDestinationRule = networking.DestinationRule{}
 
+// defaultExtAuthzOutlierDetection is applied to the clusters of the ext_authz extension providers that have no
+// outlier detection configured, so that unhealthy provider endpoints are ejected and the authorization requests
+// fail over to the healthy ones. The failure mode of the policies applies once all of them are ejected.
+var defaultExtAuthzOutlierDetection = &networking.OutlierDetection{
+	ConsecutiveGatewayErrors: &types.UInt32Value{Value: 5},
+	Interval:                 &types.Duration{Seconds: 10},
+	BaseEjectionTime:         &types.Duration{Seconds: 30},
+	MaxEjectionPercent:       100,
+}
+
 // ClusterBuilder interface provides an abstraction for building Envoy Clusters.
 type ClusterBuilder struct {
 	proxy *model.Proxy
@@ -75,8 +91,34 @@ func (cb *ClusterBuilder) applyDestinationRule(c *cluster.Cluster, clusterMode C
 
 	// merge with applicable port level traffic policy settings
 	opts.policy = MergeTrafficPolicy(nil, opts.policy, opts.port)
+	isExtAuthzProvider, isGrpcProvider := false, false
+	if features.EnableExtAuthzProviderFailover && clusterMode == DefaultClusterMode {
+		isExtAuthzProvider, isGrpcProvider = extAuthzProvider(cb.push.Mesh, service, port)
+	}
+	if isExtAuthzProvider && opts.policy.GetOutlierDetection() == nil {
+		opts.policy = MergeTrafficPolicy(opts.policy, &networking.TrafficPolicy{OutlierDetection: defaultExtAuthzOutlierDetection}, nil)
+	}
 	// Apply traffic policy for the main default cluster.
 	applyTrafficPolicy(opts)
+	if isExtAuthzProvider {
+		applyExtAuthzHealthCheck(c, isGrpcProvider)
+	}
+	biases := activeRequestBiases(destRule)
+	if bias, f := biases[""]; f {
+		applyActiveRequestBias(c, bias)
+	}
+	localOriginFailures := localOriginOutlierFailures(destRule)
+	if failures, f := localOriginFailures[""]; f {
+		applyLocalOriginOutlierDetection(c, failures)
+	}
+	proxyProtocol, hasProxyProtocol := upstreamProxyProtocol(destRule)
+	if hasProxyProtocol {
+		applyUpstreamProxyProtocol(c, proxyProtocol)
+	}
+	ignoreHealthOnRemoval := ignoreHealthOnHostRemoval(destRule)
+	c.IgnoreHealthOnHostRemoval = ignoreHealthOnRemoval
+	serviceAccounts := opts.serviceAccounts
+	subjectAltNames := subsetSubjectAltNames(destRule)
 
 	// Apply EdsConfig if needed. This should be called after traffic policy is applied because, traffic policy might change
 	// discovery type.
@@ -129,11 +171,31 @@ func (cb *ClusterBuilder) applyDestinationRule(c *cluster.Cluster, clusterMode C
 		// Apply traffic policy for subset cluster with the destination rule traffic policy.
 		opts.cluster = subsetCluster
 		opts.istioMtlsSni = defaultSni
+		// The identities pinned for the subset replace those of the service in the validation of its ISTIO_MUTUAL
+		// connections.
+		opts.serviceAccounts = serviceAccounts
+		if names, f := subjectAltNames[subset.Name]; f && clusterMode == DefaultClusterMode {
+			opts.serviceAccounts = names
+		}
 
 		// If subset has a traffic policy, apply it so that it overrides the destination rule traffic policy.
 		opts.policy = MergeTrafficPolicy(destinationRule.TrafficPolicy, subset.TrafficPolicy, opts.port)
 		// Apply traffic policy for the subset cluster.
 		applyTrafficPolicy(opts)
+		if bias, f := biases[subset.Name]; f {
+			applyActiveRequestBias(subsetCluster, bias)
+		} else if bias, f := biases[""]; f {
+			applyActiveRequestBias(subsetCluster, bias)
+		}
+		if failures, f := localOriginFailures[subset.Name]; f {
+			applyLocalOriginOutlierDetection(subsetCluster, failures)
+		} else if failures, f := localOriginFailures[""]; f {
+			applyLocalOriginOutlierDetection(subsetCluster, failures)
+		}
+		if hasProxyProtocol {
+			applyUpstreamProxyProtocol(subsetCluster, proxyProtocol)
+		}
+		subsetCluster.IgnoreHealthOnHostRemoval = ignoreHealthOnRemoval
 
 		maybeApplyEdsConfig(subsetCluster)
 
@@ -143,6 +205,263 @@ func (cb *ClusterBuilder) applyDestinationRule(c *cluster.Cluster, clusterMode C
 	return subsetClusters
 }
 
+// activeRequestBiases returns the active request biases set on the destination rule, keyed by subset.
+func activeRequestBiases(destRule *config.Config) map[string]float64 {
+	if destRule == nil {
+		return nil
+	}
+	value, f := destRule.Annotations[routing.ActiveRequestBiasAnnotation]
+	if !f {
+		return nil
+	}
+	biases, err := routing.ParseActiveRequestBias(value)
+	if err != nil {
+		log.Debugf("ignored %s of destination rule %s/%s: %v", routing.ActiveRequestBiasAnnotation,
+			destRule.Namespace, destRule.Name, err)
+		return nil
+	}
+	return biases
+}
+
+// localOriginOutlierFailures returns the consecutive local origin failures set on the destination rule, keyed by
+// subset.
+func localOriginOutlierFailures(destRule *config.Config) map[string]uint32 {
+	if destRule == nil {
+		return nil
+	}
+	value, f := destRule.Annotations[routing.LocalOriginOutlierDetectionAnnotation]
+	if !f {
+		return nil
+	}
+	failures, err := routing.ParseLocalOriginFailures(value)
+	if err != nil {
+		log.Debugf("ignored %s of destination rule %s/%s: %v", routing.LocalOriginOutlierDetectionAnnotation,
+			destRule.Namespace, destRule.Name, err)
+		return nil
+	}
+	return failures
+}
+
+// subsetSubjectAltNames returns the subject alternative names pinned on the destination rule, keyed by subset.
+func subsetSubjectAltNames(destRule *config.Config) map[string][]string {
+	if destRule == nil {
+		return nil
+	}
+	value, f := destRule.Annotations[routing.SubjectAltNamesAnnotation]
+	if !f {
+		return nil
+	}
+	names, err := routing.ParseSubjectAltNames(value)
+	if err != nil {
+		log.Debugf("ignored %s of destination rule %s/%s: %v", routing.SubjectAltNamesAnnotation,
+			destRule.Namespace, destRule.Name, err)
+		return nil
+	}
+	return names
+}
+
+// upstreamProxyProtocol returns the version of the PROXY protocol sent to the hosts of the destination rule, and
+// whether it is set.
+func upstreamProxyProtocol(destRule *config.Config) (core.ProxyProtocolConfig_Version, bool) {
+	if destRule == nil {
+		return 0, false
+	}
+	value, f := destRule.Annotations[routing.UpstreamProxyProtocolAnnotation]
+	if !f {
+		return 0, false
+	}
+	version, err := routing.ParseProxyProtocolVersion(value)
+	if err != nil {
+		log.Debugf("ignored %s of destination rule %s/%s: %v", routing.UpstreamProxyProtocolAnnotation,
+			destRule.Namespace, destRule.Name, err)
+		return 0, false
+	}
+	if version == 1 {
+		return core.ProxyProtocolConfig_V1, true
+	}
+	return core.ProxyProtocolConfig_V2, true
+}
+
+// ignoreHealthOnHostRemoval returns whether the endpoints removed from service discovery are removed from the clusters
+// of the destination rule immediately, even if they still pass the active health checks.
+func ignoreHealthOnHostRemoval(destRule *config.Config) bool {
+	if destRule == nil {
+		return features.ClusterIgnoreHealthOnHostRemoval
+	}
+	value, f := destRule.Annotations[routing.IgnoreHealthOnHostRemovalAnnotation]
+	if !f {
+		return features.ClusterIgnoreHealthOnHostRemoval
+	}
+	ignore, err := routing.ParseIgnoreHealthOnHostRemoval(value)
+	if err != nil {
+		log.Debugf("ignored %s of destination rule %s/%s: %v", routing.IgnoreHealthOnHostRemovalAnnotation,
+			destRule.Namespace, destRule.Name, err)
+		return features.ClusterIgnoreHealthOnHostRemoval
+	}
+	return ignore
+}
+
+// extAuthzProvider returns true if the service port is used by one of the ext_authz extension providers of the
+// mesh, and whether the provider is a gRPC one.
+func extAuthzProvider(mesh *meshconfig.MeshConfig, service *model.Service, port *model.Port) (found bool, grpc bool) {
+	for _, provider := range mesh.GetExtensionProviders() {
+		var svc string
+		var p uint32
+		switch pr := provider.Provider.(type) {
+		case *meshconfig.MeshConfig_ExtensionProvider_EnvoyExtAuthzHttp:
+			svc, p = pr.EnvoyExtAuthzHttp.GetService(), pr.EnvoyExtAuthzHttp.GetPort()
+		case *meshconfig.MeshConfig_ExtensionProvider_EnvoyExtAuthzGrpc:
+			svc, p, grpc = pr.EnvoyExtAuthzGrpc.GetService(), pr.EnvoyExtAuthzGrpc.GetPort(), true
+		default:
+			continue
+		}
+		// The service of a provider is either <Hostname> or <Namespace>/<Hostname>.
+		if int(p) == port.Port &&
+			(svc == string(service.Hostname) || svc == service.Attributes.Namespace+"/"+string(service.Hostname)) {
+			return true, grpc
+		}
+	}
+	return false, false
+}
+
+// applyExtAuthzHealthCheck actively health checks the endpoints of the cluster of an ext_authz provider: with the
+// gRPC health checking protocol for gRPC providers, and by opening connections for HTTP ones.
+func applyExtAuthzHealthCheck(c *cluster.Cluster, grpc bool) {
+	hc := &core.HealthCheck{
+		Timeout:            &duration.Duration{Seconds: 1},
+		Interval:           &duration.Duration{Seconds: 10},
+		UnhealthyThreshold: &wrappers.UInt32Value{Value: 3},
+		HealthyThreshold:   &wrappers.UInt32Value{Value: 1},
+	}
+	if grpc {
+		hc.HealthChecker = &core.HealthCheck_GrpcHealthCheck_{GrpcHealthCheck: &core.HealthCheck_GrpcHealthCheck{}}
+	} else {
+		hc.HealthChecker = &core.HealthCheck_TcpHealthCheck_{TcpHealthCheck: &core.HealthCheck_TcpHealthCheck{}}
+	}
+	c.HealthChecks = []*core.HealthCheck{hc}
+}
+
+// routeConnectionPool is the connection pool override of a destination of an HTTP route.
+type routeConnectionPool struct {
+	// subset is the subset of the route cluster, see routing.Subset.
+	subset string
+	// port is the port of the destination, or 0 if the route applies to all the ports of the service.
+	port int
+	// settings is nil if the route only sets a retry budget.
+	settings    *networking.ConnectionPoolSettings
+	retryBudget *routing.RetryBudget
+}
+
+// routeConnectionPools returns the connection pool overrides and retry budgets of the HTTP routes of the virtual
+// services used by the proxy, keyed by destination host.
+func (cb *ClusterBuilder) routeConnectionPools() map[host.Name][]routeConnectionPool {
+	var vses []config.Config
+	if cb.proxy.Type == model.Router {
+		if cb.proxy.MergedGateway != nil {
+			gateways := map[string]bool{}
+			for _, gw := range cb.proxy.MergedGateway.GatewayNameForServer {
+				if !gateways[gw] {
+					gateways[gw] = true
+					vses = append(vses, cb.push.VirtualServicesForGateway(cb.proxy, gw)...)
+				}
+			}
+		}
+	} else if cb.proxy.SidecarScope != nil {
+		for _, listener := range cb.proxy.SidecarScope.EgressListeners {
+			vses = append(vses, listener.VirtualServices()...)
+		}
+	}
+
+	res := map[host.Name][]routeConnectionPool{}
+	seen := map[string]bool{}
+	for _, vs := range vses {
+		if seen[vs.Namespace+"/"+vs.Name] {
+			continue
+		}
+		seen[vs.Namespace+"/"+vs.Name] = true
+		pools := cb.push.RouteConnectionPools(vs)
+		var retryPolicies map[string]*routing.RetryPolicy
+		if value, f := vs.Annotations[routing.RetryPolicyAnnotation]; f {
+			var err error
+			if retryPolicies, err = routing.ParseRetryPolicies(value); err != nil {
+				log.Debugf("ignored %s of virtual service %s/%s: %v", routing.RetryPolicyAnnotation, vs.Namespace, vs.Name, err)
+			}
+		}
+		if len(pools) == 0 && len(retryPolicies) == 0 {
+			continue
+		}
+		for _, httpRoute := range vs.Spec.(*networking.VirtualService).Http {
+			settings := pools[httpRoute.Name]
+			var retryBudget *routing.RetryBudget
+			if policy := retryPolicies[httpRoute.Name]; policy != nil {
+				retryBudget = policy.Budget
+			}
+			if (settings == nil && retryBudget == nil) || httpRoute.Name == "" {
+				continue
+			}
+			for _, dst := range httpRoute.Route {
+				if dst.Destination == nil {
+					continue
+				}
+				hostname := host.Name(dst.Destination.Host)
+				res[hostname] = append(res[hostname], routeConnectionPool{
+					subset:      routing.Subset(dst.Destination.Subset, httpRoute.Name, vs.Name, vs.Namespace),
+					port:        int(dst.Destination.GetPort().GetNumber()),
+					settings:    settings,
+					retryBudget: retryBudget,
+				})
+			}
+		}
+	}
+	return res
+}
+
+// buildRouteClusters returns the clusters dedicated to the HTTP routes overriding the connection pool or setting a
+// retry budget for the service on the given port. Each is a copy of the cluster of the destination of the route, among the given
+// default and subset clusters, with the overrides applied on top of the settings of the destination rule.
+func (cb *ClusterBuilder) buildRouteClusters(pools []routeConnectionPool, service *model.Service, port *model.Port,
+	clusters []*cluster.Cluster) []*cluster.Cluster {
+	if len(pools) == 0 {
+		return nil
+	}
+	byName := make(map[string]*cluster.Cluster, len(clusters))
+	for _, c := range clusters {
+		byName[c.Name] = c
+	}
+	var routeClusters []*cluster.Cluster
+	for _, pool := range pools {
+		if pool.port != 0 && pool.port != port.Port {
+			continue
+		}
+		name := model.BuildSubsetKey(model.TrafficDirectionOutbound, pool.subset, service.Hostname, port.Port)
+		if _, f := byName[name]; f {
+			continue
+		}
+		base := byName[model.BuildSubsetKey(model.TrafficDirectionOutbound, routing.BaseSubset(pool.subset), service.Hostname, port.Port)]
+		if base == nil {
+			// The subset of the destination does not exist, the route cluster would have no endpoints either.
+			continue
+		}
+		c := proto.Clone(base).(*cluster.Cluster)
+		c.Name = name
+		if c.EdsClusterConfig != nil {
+			c.EdsClusterConfig.ServiceName = name
+		}
+		if c.LoadAssignment != nil {
+			c.LoadAssignment.ClusterName = name
+		}
+		if pool.settings != nil {
+			applyRouteConnectionPool(cb.push.Mesh, c, pool.settings)
+		}
+		if pool.retryBudget != nil {
+			applyRetryBudget(c, pool.retryBudget)
+		}
+		byName[name] = c
+		routeClusters = append(routeClusters, c)
+	}
+	return routeClusters
+}
+
 // MergeTrafficPolicy returns the merged TrafficPolicy for a destination-level and subset-level policy on a given port.
 func MergeTrafficPolicy(original, subsetPolicy *networking.TrafficPolicy, port *model.Port) *networking.TrafficPolicy {
 	if subsetPolicy == nil {
@@ -291,7 +610,7 @@ func (cb *ClusterBuilder) buildInboundClusterForPortOrUDS(proxy *model.Proxy, cl
 
 func (cb *ClusterBuilder) buildLocalityLbEndpoints(proxyNetworkView map[string]bool, service *model.Service,
 	port int, labels labels.Collection) []*endpoint.LocalityLbEndpoints {
-	if service.Resolution != model.DNSLB {
+	if service.ResolutionForPort(port) != model.DNSLB {
 		return nil
 	}
 
@@ -410,6 +729,7 @@ func (cb *ClusterBuilder) buildDefaultPassthroughCluster() *cluster.Cluster {
 	}
 	passthroughSettings := &networking.ConnectionPoolSettings{}
 	applyConnectionPool(cb.push.Mesh, cluster, passthroughSettings)
+	applyUpstreamTCPKeepalive(cluster, cb.proxy.UpstreamTCPKeepalive())
 	return cluster
 }
 
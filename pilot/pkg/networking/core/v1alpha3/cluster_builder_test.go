@@ -23,6 +23,8 @@ import (
 	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/duration"
 	structpb "github.com/golang/protobuf/ptypes/struct"
 	"github.com/golang/protobuf/ptypes/wrappers"
@@ -38,6 +40,7 @@ import (
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/routing"
 	"istio.io/istio/pkg/config/schema/gvk"
 )
 
@@ -296,6 +299,173 @@ func TestApplyDestinationRule(t *testing.T) {
 	}
 }
 
+func TestApplyDestinationRuleExtAuthzProvider(t *testing.T) {
+	failover := features.EnableExtAuthzProviderFailover
+	features.EnableExtAuthzProviderFailover = true
+	defer func() { features.EnableExtAuthzProviderFailover = failover }()
+
+	port := &model.Port{Name: "grpc", Port: 9000, Protocol: protocol.GRPC}
+	service := &model.Service{
+		Hostname:   host.Name("ext-authz.default.svc.cluster.local"),
+		Address:    "1.1.1.1",
+		Ports:      model.PortList{port},
+		Resolution: model.ClientSideLB,
+		Attributes: model.ServiceAttributes{Namespace: TestServiceNamespace},
+	}
+	m := testMesh
+	m.ExtensionProviders = []*meshconfig.MeshConfig_ExtensionProvider{
+		{
+			Name: "ext-authz",
+			Provider: &meshconfig.MeshConfig_ExtensionProvider_EnvoyExtAuthzGrpc{
+				EnvoyExtAuthzGrpc: &meshconfig.MeshConfig_ExtensionProvider_EnvoyExternalAuthorizationGrpcProvider{
+					Service: "ext-authz.default.svc.cluster.local",
+					Port:    9000,
+				},
+			},
+		},
+	}
+
+	other := &model.Service{
+		Hostname:   host.Name("foo.default.svc.cluster.local"),
+		Address:    "1.1.1.2",
+		Ports:      model.PortList{port},
+		Resolution: model.ClientSideLB,
+		Attributes: model.ServiceAttributes{Namespace: TestServiceNamespace},
+	}
+
+	cases := []struct {
+		name            string
+		service         *model.Service
+		destRule        *networking.DestinationRule
+		want            *cluster.OutlierDetection
+		wantHealthCheck bool
+	}{
+		{
+			name:    "default outlier detection for provider",
+			service: service,
+			want: &cluster.OutlierDetection{
+				EnforcingSuccessRate:               &wrappers.UInt32Value{Value: 0},
+				ConsecutiveGatewayFailure:          &wrappers.UInt32Value{Value: 5},
+				EnforcingConsecutiveGatewayFailure: &wrappers.UInt32Value{Value: 100},
+				Interval:                           &duration.Duration{Seconds: 10},
+				BaseEjectionTime:                   &duration.Duration{Seconds: 30},
+				MaxEjectionPercent:                 &wrappers.UInt32Value{Value: 100},
+			},
+			wantHealthCheck: true,
+		},
+		{
+			name:    "destination rule outlier detection for provider",
+			service: service,
+			destRule: &networking.DestinationRule{
+				Host: "ext-authz.default.svc.cluster.local",
+				TrafficPolicy: &networking.TrafficPolicy{
+					OutlierDetection: &networking.OutlierDetection{MaxEjectionPercent: 10},
+				},
+			},
+			want: &cluster.OutlierDetection{
+				EnforcingSuccessRate: &wrappers.UInt32Value{Value: 0},
+				MaxEjectionPercent:   &wrappers.UInt32Value{Value: 10},
+			},
+			wantHealthCheck: true,
+		},
+		{
+			name:    "no outlier detection for other clusters",
+			service: other,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg *config.Config
+			if tt.destRule != nil {
+				cfg = &config.Config{
+					Meta: config.Meta{
+						GroupVersionKind: gvk.DestinationRule,
+						Name:             "acme",
+						Namespace:        "default",
+					},
+					Spec: tt.destRule,
+				}
+			}
+			cg := NewConfigGenTest(t, TestOptions{
+				ConfigPointers: []*config.Config{cfg},
+				Services:       []*model.Service{service, other},
+				MeshConfig:     &m,
+			})
+			cb := NewClusterBuilder(cg.SetupProxy(nil), cg.PushContext())
+
+			c := &cluster.Cluster{
+				Name:                 model.BuildSubsetKey(model.TrafficDirectionOutbound, "", tt.service.Hostname, port.Port),
+				ClusterDiscoveryType: &cluster.Cluster_Type{Type: cluster.Cluster_EDS},
+			}
+			cb.applyDestinationRule(c, DefaultClusterMode, tt.service, port, map[string]bool{})
+			if diff := cmp.Diff(tt.want, c.OutlierDetection, protocmp.Transform()); diff != "" {
+				t.Errorf("unexpected outlier detection: %v", diff)
+			}
+			if got := len(c.HealthChecks) == 1 && c.HealthChecks[0].GetGrpcHealthCheck() != nil; got != tt.wantHealthCheck {
+				t.Errorf("unexpected health checks: %v", c.HealthChecks)
+			}
+		})
+	}
+}
+
+func TestApplyDestinationRuleSubsetSubjectAltNames(t *testing.T) {
+	port := &model.Port{Name: "http", Port: 8080, Protocol: protocol.HTTP}
+	service := &model.Service{
+		Hostname:        host.Name("reviews.default.svc.cluster.local"),
+		Address:         "1.1.1.1",
+		Ports:           model.PortList{port},
+		Resolution:      model.ClientSideLB,
+		Attributes:      model.ServiceAttributes{Namespace: TestServiceNamespace},
+		ServiceAccounts: []string{"spiffe://cluster.local/ns/default/sa/reviews"},
+	}
+	cfg := &config.Config{
+		Meta: config.Meta{
+			GroupVersionKind: gvk.DestinationRule,
+			Name:             "acme",
+			Namespace:        "default",
+			Annotations: map[string]string{
+				routing.SubjectAltNamesAnnotation: `{"canary": ["spiffe://cluster.local/ns/default/sa/reviews-canary"]}`,
+			},
+		},
+		Spec: &networking.DestinationRule{
+			Host: "reviews.default.svc.cluster.local",
+			TrafficPolicy: &networking.TrafficPolicy{
+				Tls: &networking.ClientTLSSettings{Mode: networking.ClientTLSSettings_ISTIO_MUTUAL},
+			},
+			Subsets: []*networking.Subset{
+				{Name: "canary", Labels: map[string]string{"version": "canary"}},
+				{Name: "stable", Labels: map[string]string{"version": "stable"}},
+			},
+		},
+	}
+	cg := NewConfigGenTest(t, TestOptions{
+		ConfigPointers: []*config.Config{cfg},
+		Services:       []*model.Service{service},
+	})
+	cb := NewClusterBuilder(cg.SetupProxy(nil), cg.PushContext())
+
+	c := &cluster.Cluster{
+		Name:                 "outbound|8080||reviews.default.svc.cluster.local",
+		ClusterDiscoveryType: &cluster.Cluster_Type{Type: cluster.Cluster_EDS},
+	}
+	subsetClusters := cb.applyDestinationRule(c, DefaultClusterMode, service, port, map[string]bool{})
+	want := map[string]string{
+		"outbound|8080||reviews.default.svc.cluster.local":       "spiffe://cluster.local/ns/default/sa/reviews",
+		"outbound|8080|canary|reviews.default.svc.cluster.local": "spiffe://cluster.local/ns/default/sa/reviews-canary",
+		"outbound|8080|stable|reviews.default.svc.cluster.local": "spiffe://cluster.local/ns/default/sa/reviews",
+	}
+	for _, cl := range append([]*cluster.Cluster{c}, subsetClusters...) {
+		ctx := &tls.UpstreamTlsContext{}
+		if err := ptypes.UnmarshalAny(cl.GetTransportSocket().GetTypedConfig(), ctx); err != nil {
+			t.Fatalf("%s: %v", cl.Name, err)
+		}
+		sans := ctx.GetCommonTlsContext().GetCombinedValidationContext().GetDefaultValidationContext().GetMatchSubjectAltNames()
+		if len(sans) != 1 || sans[0].GetExact() != want[cl.Name] {
+			t.Errorf("%s: expected subject alternative names [%s], got %v", cl.Name, want[cl.Name], sans)
+		}
+	}
+}
+
 func compareClusters(t *testing.T, ec *cluster.Cluster, gc *cluster.Cluster) {
 	// TODO(ramaraochavali): Expand the comparison to more fields.
 	t.Helper()
@@ -25,6 +25,7 @@ import (
 
 	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	proxyprotocol "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/proxy_protocol/v3"
 	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	"github.com/gogo/protobuf/proto"
 	"github.com/gogo/protobuf/types"
@@ -76,6 +77,489 @@ var testMesh = meshconfig.MeshConfig{
 	},
 }
 
+func TestBuildRouteClusters(t *testing.T) {
+	g := NewWithT(t)
+	cg := NewConfigGenTest(t, TestOptions{ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: se
+  namespace: default
+spec:
+  hosts:
+  - foo.bar
+  addresses: [1.2.3.4]
+  location: MESH_INTERNAL
+  resolution: STATIC
+  endpoints:
+  - address: 2.3.4.5
+    labels:
+      version: v1
+  ports:
+  - name: http
+    number: 80
+    protocol: HTTP
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: dr
+  namespace: default
+spec:
+  host: foo.bar
+  trafficPolicy:
+    connectionPool:
+      http:
+        http1MaxPendingRequests: 5
+        maxRetries: 2
+  subsets:
+  - name: v1
+    labels:
+      version: v1
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: vs
+  namespace: default
+  annotations:
+    networking.istio.io/routeConnectionPool: '{"chatty": {"http": {"maxRequestsPerConnection": 1, "maxRetries": 10}}}'
+spec:
+  hosts:
+  - foo.bar
+  http:
+  - name: chatty
+    match:
+    - uri:
+        prefix: /chatty
+    route:
+    - destination:
+        host: foo.bar
+        subset: v1
+  - name: default
+    route:
+    - destination:
+        host: foo.bar
+`})
+	clusters := xdstest.ExtractClusters(cg.Clusters(cg.SetupProxy(nil)))
+
+	base := clusters["outbound|80|v1|foo.bar"]
+	route := clusters["outbound|80|v1~chatty.vs.default|foo.bar"]
+	if base == nil || route == nil {
+		t.Fatalf("expected the subset and route clusters, got %v", xdstest.MapKeys(clusters))
+	}
+	if _, f := clusters["outbound|80|~default.vs.default|foo.bar"]; f {
+		t.Fatalf("unexpected route cluster for a route without override")
+	}
+	g.Expect(base.MaxRequestsPerConnection).To(BeNil())
+	g.Expect(base.CircuitBreakers.Thresholds[0].MaxRetries.Value).To(Equal(uint32(2)))
+
+	g.Expect(route.MaxRequestsPerConnection.GetValue()).To(Equal(uint32(1)))
+	g.Expect(route.CircuitBreakers.Thresholds[0].MaxRetries.Value).To(Equal(uint32(10)))
+	// Settings of the destination rule not overridden by the route are kept.
+	g.Expect(route.CircuitBreakers.Thresholds[0].MaxPendingRequests.Value).To(Equal(uint32(5)))
+	g.Expect(route.EdsClusterConfig.ServiceName).To(Equal(route.Name))
+}
+
+func TestRouteRetryBudget(t *testing.T) {
+	g := NewWithT(t)
+	cg := NewConfigGenTest(t, TestOptions{ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: se
+  namespace: default
+spec:
+  hosts:
+  - foo.bar
+  addresses: [1.2.3.4]
+  location: MESH_INTERNAL
+  resolution: STATIC
+  endpoints:
+  - address: 2.3.4.5
+  ports:
+  - name: http
+    number: 80
+    protocol: HTTP
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: dr
+  namespace: default
+spec:
+  host: foo.bar
+  trafficPolicy:
+    connectionPool:
+      http:
+        maxRetries: 2
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: vs
+  namespace: default
+  annotations:
+    networking.istio.io/routeConnectionPool: '{"both": {"http": {"maxRetries": 10}}}'
+    networking.istio.io/routeRetryPolicy: |
+      {"checkout": {"budget": {"percent": 25, "minRetryConcurrency": 5}},
+       "both": {"budget": {"percent": 10}},
+       "headers": {"retriableHeaders": {"x-retry": {"exact": "true"}}}}
+spec:
+  hosts:
+  - foo.bar
+  http:
+  - name: checkout
+    match:
+    - uri:
+        prefix: /checkout
+    route:
+    - destination:
+        host: foo.bar
+  - name: both
+    match:
+    - uri:
+        prefix: /both
+    route:
+    - destination:
+        host: foo.bar
+  - name: headers
+    route:
+    - destination:
+        host: foo.bar
+`})
+	clusters := xdstest.ExtractClusters(cg.Clusters(cg.SetupProxy(nil)))
+
+	checkout := clusters["outbound|80|~checkout.vs.default|foo.bar"]
+	both := clusters["outbound|80|~both.vs.default|foo.bar"]
+	if checkout == nil || both == nil {
+		t.Fatalf("expected the route clusters, got %v", xdstest.MapKeys(clusters))
+	}
+	if _, f := clusters["outbound|80|~headers.vs.default|foo.bar"]; f {
+		t.Fatalf("unexpected route cluster for a route without retry budget")
+	}
+	g.Expect(clusters["outbound|80||foo.bar"].CircuitBreakers.Thresholds[0].RetryBudget).To(BeNil())
+
+	budget := checkout.CircuitBreakers.Thresholds[0].RetryBudget
+	g.Expect(budget.GetBudgetPercent().GetValue()).To(Equal(float64(25)))
+	g.Expect(budget.GetMinRetryConcurrency().GetValue()).To(Equal(uint32(5)))
+	g.Expect(checkout.CircuitBreakers.Thresholds[0].MaxRetries.Value).To(Equal(uint32(2)))
+
+	// The connection pool override and the retry budget of a route share its cluster.
+	g.Expect(both.CircuitBreakers.Thresholds[0].MaxRetries.Value).To(Equal(uint32(10)))
+	g.Expect(both.CircuitBreakers.Thresholds[0].RetryBudget.GetBudgetPercent().GetValue()).To(Equal(float64(10)))
+	g.Expect(both.CircuitBreakers.Thresholds[0].RetryBudget.MinRetryConcurrency).To(BeNil())
+}
+
+func TestActiveRequestBias(t *testing.T) {
+	cg := NewConfigGenTest(t, TestOptions{ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: se
+  namespace: default
+spec:
+  hosts:
+  - foo.bar
+  addresses: [1.2.3.4]
+  location: MESH_INTERNAL
+  resolution: STATIC
+  endpoints:
+  - address: 2.3.4.5
+  ports:
+  - name: http
+    number: 80
+    protocol: HTTP
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: dr
+  namespace: default
+  annotations:
+    networking.istio.io/activeRequestBias: "1.5,v1=2"
+spec:
+  host: foo.bar
+  trafficPolicy:
+    loadBalancer:
+      simple: LEAST_CONN
+  subsets:
+  - name: v1
+    labels:
+      version: v1
+  - name: v2
+    labels:
+      version: v2
+  - name: v3
+    labels:
+      version: v3
+    trafficPolicy:
+      loadBalancer:
+        simple: ROUND_ROBIN
+`})
+	clusters := xdstest.ExtractClusters(cg.Clusters(cg.SetupProxy(nil)))
+
+	cases := map[string]float64{
+		"outbound|80||foo.bar":   1.5,
+		"outbound|80|v1|foo.bar": 2,
+		"outbound|80|v2|foo.bar": 1.5,
+	}
+	for name, want := range cases {
+		c := clusters[name]
+		if c == nil {
+			t.Fatalf("cluster %s not found", name)
+		}
+		got := c.GetLeastRequestLbConfig().GetActiveRequestBias()
+		if got.GetDefaultValue() != want || got.GetRuntimeKey() != activeRequestBiasRuntimeKey {
+			t.Errorf("%s: got active request bias %v, want %v", name, got, want)
+		}
+	}
+	if c := clusters["outbound|80|v3|foo.bar"]; c == nil || c.LbConfig != nil {
+		t.Errorf("expected no load balancer config for the ROUND_ROBIN subset, got %v", c)
+	}
+}
+
+func TestLocalOriginOutlierDetection(t *testing.T) {
+	cg := NewConfigGenTest(t, TestOptions{ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: se
+  namespace: default
+spec:
+  hosts:
+  - foo.bar
+  addresses: [1.2.3.4]
+  location: MESH_INTERNAL
+  resolution: STATIC
+  endpoints:
+  - address: 2.3.4.5
+  ports:
+  - name: http
+    number: 80
+    protocol: HTTP
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: dr
+  namespace: default
+  annotations:
+    networking.istio.io/localOriginOutlierDetection: "5,v1=0"
+spec:
+  host: foo.bar
+  trafficPolicy:
+    outlierDetection:
+      consecutive5xxErrors: 10
+  subsets:
+  - name: v1
+    labels:
+      version: v1
+  - name: v2
+    labels:
+      version: v2
+`})
+	clusters := xdstest.ExtractClusters(cg.Clusters(cg.SetupProxy(nil)))
+
+	cases := map[string]uint32{
+		"outbound|80||foo.bar":   5,
+		"outbound|80|v1|foo.bar": 0,
+		"outbound|80|v2|foo.bar": 5,
+	}
+	for name, want := range cases {
+		c := clusters[name]
+		if c == nil {
+			t.Fatalf("cluster %s not found", name)
+		}
+		od := c.GetOutlierDetection()
+		if !od.GetSplitExternalLocalOriginErrors() {
+			t.Errorf("%s: expected local origin errors to be split", name)
+		}
+		if got := od.GetConsecutiveLocalOriginFailure().GetValue(); got != want {
+			t.Errorf("%s: got %d consecutive local origin failures, want %d", name, got, want)
+		}
+		enforcing := uint32(100)
+		if want == 0 {
+			enforcing = 0
+		}
+		if got := od.GetEnforcingConsecutiveLocalOriginFailure().GetValue(); got != enforcing {
+			t.Errorf("%s: got enforcing consecutive local origin failure %d, want %d", name, got, enforcing)
+		}
+		if got := od.GetConsecutive_5Xx().GetValue(); got != 10 {
+			t.Errorf("%s: got %d consecutive 5xx, want 10", name, got)
+		}
+	}
+}
+
+func TestUpstreamProxyProtocol(t *testing.T) {
+	cg := NewConfigGenTest(t, TestOptions{ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: se
+  namespace: default
+spec:
+  hosts:
+  - foo.bar
+  - baz.bar
+  addresses: [1.2.3.4]
+  location: MESH_INTERNAL
+  resolution: STATIC
+  endpoints:
+  - address: 2.3.4.5
+  ports:
+  - name: tcp
+    number: 80
+    protocol: TCP
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: foo
+  namespace: default
+  annotations:
+    networking.istio.io/upstreamProxyProtocol: V2
+spec:
+  host: foo.bar
+  subsets:
+  - name: v1
+    labels:
+      version: v1
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: baz
+  namespace: default
+  annotations:
+    networking.istio.io/upstreamProxyProtocol: V1
+spec:
+  host: baz.bar
+  trafficPolicy:
+    tls:
+      mode: SIMPLE
+`})
+	clusters := xdstest.ExtractClusters(cg.Clusters(cg.SetupProxy(nil)))
+
+	g := NewWithT(t)
+	unwrap := func(ts *core.TransportSocket) *proxyprotocol.ProxyProtocolUpstreamTransport {
+		g.Expect(ts.GetName()).To(Equal(util.EnvoyProxyProtocolSocketName))
+		pp := &proxyprotocol.ProxyProtocolUpstreamTransport{}
+		g.Expect(ptypes.UnmarshalAny(ts.GetTypedConfig(), pp)).To(Succeed())
+		return pp
+	}
+	for _, name := range []string{"outbound|80||foo.bar", "outbound|80|v1|foo.bar"} {
+		c := clusters[name]
+		if c == nil {
+			t.Fatalf("cluster %s not found", name)
+		}
+		// Auto mTLS: both the mTLS and the plaintext sockets are wrapped.
+		g.Expect(c.TransportSocketMatches).To(HaveLen(2))
+		for _, m := range c.TransportSocketMatches {
+			pp := unwrap(m.TransportSocket)
+			g.Expect(pp.Config.Version).To(Equal(core.ProxyProtocolConfig_V2))
+		}
+		g.Expect(unwrap(c.TransportSocketMatches[0].TransportSocket).TransportSocket.Name).To(Equal(util.EnvoyTLSSocketName))
+		g.Expect(unwrap(c.TransportSocketMatches[1].TransportSocket).TransportSocket.Name).To(Equal(util.EnvoyRawBufferSocketName))
+	}
+	// The shared plaintext match is not modified.
+	g.Expect(defaultTransportSocketMatch.TransportSocket.Name).To(Equal(util.EnvoyRawBufferSocketName))
+
+	pp := unwrap(clusters["outbound|80||baz.bar"].TransportSocket)
+	g.Expect(pp.Config.Version).To(Equal(core.ProxyProtocolConfig_V1))
+	g.Expect(pp.TransportSocket.Name).To(Equal(util.EnvoyTLSSocketName))
+}
+
+func TestPortResolution(t *testing.T) {
+	cg := NewConfigGenTest(t, TestOptions{ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: se
+  namespace: default
+  annotations:
+    networking.istio.io/portResolution: "443=NONE"
+spec:
+  hosts:
+  - api.example.com
+  location: MESH_EXTERNAL
+  resolution: DNS
+  ports:
+  - name: http
+    number: 80
+    protocol: HTTP
+  - name: tls
+    number: 443
+    protocol: TLS
+`})
+	clusters := xdstest.ExtractClusters(cg.Clusters(cg.SetupProxy(nil)))
+
+	dns := clusters["outbound|80||api.example.com"]
+	if dns.GetType() != cluster.Cluster_STRICT_DNS {
+		t.Fatalf("expected STRICT_DNS cluster for port 80, got %v", dns)
+	}
+	if eps := dns.GetLoadAssignment().GetEndpoints(); len(eps) != 1 ||
+		eps[0].LbEndpoints[0].GetEndpoint().GetAddress().GetSocketAddress().GetAddress() != "api.example.com" {
+		t.Errorf("expected the host as endpoint of port 80, got %v", eps)
+	}
+	passthrough := clusters["outbound|443||api.example.com"]
+	if passthrough.GetType() != cluster.Cluster_ORIGINAL_DST {
+		t.Fatalf("expected ORIGINAL_DST cluster for port 443, got %v", passthrough)
+	}
+	if passthrough.GetLoadAssignment() != nil {
+		t.Errorf("expected no endpoints for port 443, got %v", passthrough.GetLoadAssignment())
+	}
+}
+
+func TestUpstreamTCPKeepalive(t *testing.T) {
+	cg := NewConfigGenTest(t, TestOptions{ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: se
+  namespace: default
+spec:
+  hosts:
+  - foo.bar
+  - baz.bar
+  location: MESH_EXTERNAL
+  resolution: DNS
+  ports:
+  - name: http
+    number: 80
+    protocol: HTTP
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: dr
+  namespace: default
+spec:
+  host: baz.bar
+  trafficPolicy:
+    connectionPool:
+      tcp:
+        tcpKeepalive:
+          time: 10s
+`})
+	proxy := cg.SetupProxy(&model.Proxy{Metadata: &model.NodeMetadata{
+		UpstreamTCPKeepalive: "time=300s,interval=75s,probes=9",
+	}})
+	clusters := xdstest.ExtractClusters(cg.Clusters(proxy))
+
+	g := NewWithT(t)
+	foo := clusters["outbound|80||foo.bar"].GetUpstreamConnectionOptions().GetTcpKeepalive()
+	g.Expect(foo.GetKeepaliveTime().GetValue()).To(Equal(uint32(300)))
+	g.Expect(foo.GetKeepaliveInterval().GetValue()).To(Equal(uint32(75)))
+	g.Expect(foo.GetKeepaliveProbes().GetValue()).To(Equal(uint32(9)))
+	// The destination rule takes precedence over the proxy keepalive.
+	baz := clusters["outbound|80||baz.bar"].GetUpstreamConnectionOptions().GetTcpKeepalive()
+	g.Expect(baz.GetKeepaliveTime().GetValue()).To(Equal(uint32(10)))
+	g.Expect(baz.GetKeepaliveProbes()).To(BeNil())
+	passthrough := clusters[util.PassthroughCluster].GetUpstreamConnectionOptions().GetTcpKeepalive()
+	g.Expect(passthrough.GetKeepaliveTime().GetValue()).To(Equal(uint32(300)))
+}
+
 func TestHTTPCircuitBreakerThresholds(t *testing.T) {
 	checkClusters := []string{"outbound|8080||*.example.org", "inbound|10001||"}
 	settings := []*networking.ConnectionPoolSettings{
@@ -749,6 +1233,9 @@ func TestClusterMetadata(t *testing.T) {
 				_, ok := istio.Fields["subset"]
 				g.Expect(ok).To(Equal(false))
 			}
+			if strings.HasPrefix(cluster.Name, "inbound") {
+				g.Expect(istio.Fields["mtls_mode"].GetStringValue()).To(Equal(model.MTLSPermissive.String()))
+			}
 		} else {
 			g.Expect(cluster.Metadata).To(BeNil())
 		}
@@ -2988,6 +3475,70 @@ func TestBuildUpstreamClusterTLSContext(t *testing.T) {
 	}
 }
 
+func TestBuildUpstreamClusterTLSContextSidecarCredentialName(t *testing.T) {
+	opts := &buildClusterOpts{
+		cluster: &cluster.Cluster{Name: "test-cluster"},
+		proxy:   &model.Proxy{Metadata: &model.NodeMetadata{}, Type: model.SidecarProxy},
+	}
+	tlsSettings := &networking.ClientTLSSettings{
+		Mode:            networking.ClientTLSSettings_MUTUAL,
+		CredentialName:  "client-credential",
+		SubjectAltNames: []string{"SAN"},
+		Sni:             "some-sni.com",
+	}
+
+	ret, err := buildUpstreamClusterTLSContext(opts, tlsSettings)
+	if err != nil || ret != nil {
+		t.Fatalf("expected no TLS context for a sidecar by default, got %v, %v", ret, err)
+	}
+
+	defer func(enabled bool) { features.EnableSidecarCredentialName = enabled }(features.EnableSidecarCredentialName)
+	features.EnableSidecarCredentialName = true
+	ret, err = buildUpstreamClusterTLSContext(opts, tlsSettings)
+	if err != nil || ret != nil {
+		t.Fatalf("expected no TLS context for a sidecar not allowed to read secrets, got %v, %v", ret, err)
+	}
+
+	opts.proxy.SecretsAuthorized = true
+	opts.proxy.ConfigNamespace = "default"
+	otherNamespace := &networking.ClientTLSSettings{
+		Mode:           networking.ClientTLSSettings_MUTUAL,
+		CredentialName: "other/client-credential",
+	}
+	ret, err = buildUpstreamClusterTLSContext(opts, otherNamespace)
+	if err != nil || ret != nil {
+		t.Fatalf("expected no TLS context for a secret of another namespace, got %v, %v", ret, err)
+	}
+
+	ret, err = buildUpstreamClusterTLSContext(opts, tlsSettings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &tls.UpstreamTlsContext{
+		CommonTlsContext: &tls.CommonTlsContext{
+			TlsCertificateSdsSecretConfigs: []*tls.SdsSecretConfig{{
+				Name:      "kubernetes://client-credential",
+				SdsConfig: authn_model.SDSAdsConfig,
+			}},
+			ValidationContextType: &tls.CommonTlsContext_CombinedValidationContext{
+				CombinedValidationContext: &tls.CommonTlsContext_CombinedCertificateValidationContext{
+					DefaultValidationContext: &tls.CertificateValidationContext{
+						MatchSubjectAltNames: util.StringToExactMatch([]string{"SAN"}),
+					},
+					ValidationContextSdsSecretConfig: &tls.SdsSecretConfig{
+						Name:      "kubernetes://client-credential" + authn_model.SdsCaSuffix,
+						SdsConfig: authn_model.SDSAdsConfig,
+					},
+				},
+			},
+		},
+		Sni: "some-sni.com",
+	}
+	if diff := cmp.Diff(want, ret, protocmp.Transform()); diff != "" {
+		t.Errorf("got diff: `%v", diff)
+	}
+}
+
 // Helper function to extract TLS context from a cluster
 func getTLSContext(t *testing.T, c *cluster.Cluster) *tls.UpstreamTlsContext {
 	t.Helper()
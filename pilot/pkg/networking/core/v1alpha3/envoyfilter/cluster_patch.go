@@ -26,6 +26,7 @@ import (
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/pkg/util/runtime"
 	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/routing"
 	"istio.io/pkg/log"
 )
 
@@ -43,11 +44,12 @@ func ApplyClusterMerge(pctx networking.EnvoyFilter_PatchContext, efw *model.Envo
 		if cp.Operation != networking.EnvoyFilter_Patch_MERGE {
 			continue
 		}
-		if commonConditionMatch(pctx, cp) && clusterMatch(c, cp, hosts) {
+		if commonConditionMatch(pctx, cp) && clusterMatch(c, cp, hosts) && efw.Matched(cp) {
 
 			ret, err := mergeTransportSocketCluster(c, cp)
 			if err != nil {
 				log.Debugf("Merge of transport socket failed for cluster: %v", err)
+				efw.Conflicted(cp, err.Error())
 				continue
 			}
 			if !ret {
@@ -112,7 +114,7 @@ func ShouldKeepCluster(pctx networking.EnvoyFilter_PatchContext, efw *model.Envo
 		if cp.Operation != networking.EnvoyFilter_Patch_REMOVE {
 			continue
 		}
-		if commonConditionMatch(pctx, cp) && clusterMatch(c, cp, hosts) {
+		if commonConditionMatch(pctx, cp) && clusterMatch(c, cp, hosts) && efw.Matched(cp) {
 			return false
 		}
 	}
@@ -128,7 +130,7 @@ func InsertedClusters(pctx networking.EnvoyFilter_PatchContext, efw *model.Envoy
 	// Add cluster if the operation is add, and patch context matches
 	for _, cp := range efw.Patches[networking.EnvoyFilter_CLUSTER] {
 		if cp.Operation == networking.EnvoyFilter_Patch_ADD {
-			if commonConditionMatch(pctx, cp) {
+			if commonConditionMatch(pctx, cp) && efw.Matched(cp) {
 				result = append(result, proto.Clone(cp.Value).(*cluster.Cluster))
 			}
 		}
@@ -154,7 +156,8 @@ func clusterMatch(cluster *cluster.Cluster, cp *model.EnvoyFilterConfigPatchWrap
 		hostMatches = hosts
 	}
 
-	if cMatch.Subset != "" && cMatch.Subset != subset {
+	// Clusters dedicated to a route are matched by the subset of their destination.
+	if cMatch.Subset != "" && cMatch.Subset != routing.BaseSubset(subset) {
 		return false
 	}
 
@@ -24,10 +24,14 @@ import (
 	"google.golang.org/protobuf/testing/protocmp"
 
 	networking "istio.io/api/networking/v1alpha3"
+	configmemory "istio.io/istio/pilot/pkg/config/memory"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/pkg/serviceregistry/memory"
+	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/config/schema/gvk"
 )
 
 func Test_clusterMatch(t *testing.T) {
@@ -490,3 +494,93 @@ func TestClusterPatching(t *testing.T) {
 		})
 	}
 }
+
+func TestClusterPatchingDryRun(t *testing.T) {
+	store := model.MakeIstioStore(configmemory.Make(collections.Pilot))
+	_, _ = store.Create(config.Config{
+		Meta: config.Meta{
+			Name:             "dry-run",
+			Namespace:        "not-default",
+			GroupVersionKind: gvk.EnvoyFilter,
+			Annotations:      map[string]string{model.EnvoyFilterDryRunAnnotation: "true"},
+		},
+		Spec: &networking.EnvoyFilter{
+			ConfigPatches: []*networking.EnvoyFilter_EnvoyConfigObjectPatch{
+				{
+					ApplyTo: networking.EnvoyFilter_CLUSTER,
+					Match: &networking.EnvoyFilter_EnvoyConfigObjectMatch{
+						Context: networking.EnvoyFilter_SIDECAR_OUTBOUND,
+						ObjectTypes: &networking.EnvoyFilter_EnvoyConfigObjectMatch_Cluster{
+							Cluster: &networking.EnvoyFilter_ClusterMatch{Name: "cluster1"},
+						},
+					},
+					Patch: &networking.EnvoyFilter_Patch{
+						Operation: networking.EnvoyFilter_Patch_MERGE,
+						Value:     buildPatchStruct(`{"dns_lookup_family":"V6_ONLY"}`),
+					},
+				},
+				{
+					ApplyTo: networking.EnvoyFilter_CLUSTER,
+					Match: &networking.EnvoyFilter_EnvoyConfigObjectMatch{
+						Context: networking.EnvoyFilter_SIDECAR_OUTBOUND,
+						ObjectTypes: &networking.EnvoyFilter_EnvoyConfigObjectMatch_Cluster{
+							Cluster: &networking.EnvoyFilter_ClusterMatch{Name: "cluster2"},
+						},
+					},
+					Patch: &networking.EnvoyFilter_Patch{Operation: networking.EnvoyFilter_Patch_REMOVE},
+				},
+				{
+					ApplyTo: networking.EnvoyFilter_CLUSTER,
+					Match: &networking.EnvoyFilter_EnvoyConfigObjectMatch{
+						Context: networking.EnvoyFilter_SIDECAR_OUTBOUND,
+					},
+					Patch: &networking.EnvoyFilter_Patch{
+						Operation: networking.EnvoyFilter_Patch_ADD,
+						Value:     buildPatchStruct(`{"name":"new-cluster"}`),
+					},
+				},
+			},
+		},
+	})
+
+	serviceDiscovery := memory.NewServiceDiscovery(nil)
+	env := newTestEnvironment(serviceDiscovery, testMesh, store)
+	push := model.NewPushContext()
+	push.InitContext(env, nil, nil)
+
+	proxy := &model.Proxy{Type: model.SidecarProxy, ConfigNamespace: "not-default"}
+	efw := push.EnvoyFilters(proxy)
+	input := []*cluster.Cluster{
+		{Name: "cluster1", DnsLookupFamily: cluster.Cluster_V4_ONLY},
+		{Name: "cluster3", DnsLookupFamily: cluster.Cluster_V4_ONLY},
+	}
+	want := []*cluster.Cluster{
+		{Name: "cluster1", DnsLookupFamily: cluster.Cluster_V4_ONLY},
+		{Name: "cluster3", DnsLookupFamily: cluster.Cluster_V4_ONLY},
+	}
+	output := []*cluster.Cluster{}
+	for _, c := range input {
+		if ShouldKeepCluster(networking.EnvoyFilter_SIDECAR_OUTBOUND, efw, c, nil) {
+			output = append(output, ApplyClusterMerge(networking.EnvoyFilter_SIDECAR_OUTBOUND, efw, c, nil))
+		}
+	}
+	output = append(output, InsertedClusters(networking.EnvoyFilter_SIDECAR_OUTBOUND, efw)...)
+	if diff := cmp.Diff(want, output, protocmp.Transform()); diff != "" {
+		t.Errorf("dry-run patches were applied (-want +got):\n%s", diff)
+	}
+
+	wantStates := []model.EnvoyFilterPatchState{
+		model.EnvoyFilterPatchApplied,
+		model.EnvoyFilterPatchNoMatch,
+		model.EnvoyFilterPatchApplied,
+	}
+	results := proxy.EnvoyFilterPatchResults()
+	if len(results) != len(wantStates) {
+		t.Fatalf("got %d patch results, want %d: %+v", len(results), len(wantStates), results)
+	}
+	for i, result := range results {
+		if result.Index != i || result.State != wantStates[i] || !result.DryRun {
+			t.Errorf("patch %d: got %+v, want dry-run state %s", i, result, wantStates[i])
+		}
+	}
+}
@@ -42,7 +42,7 @@ func InsertedExtensionConfigurations(efw *model.EnvoyFilterWrapper, names []stri
 			log.Errorf("extension config patch %+v does not match TypeExtensionConfig type", p.Value)
 			continue
 		}
-		if _, ok := hasName[ec.GetName()]; ok {
+		if _, ok := hasName[ec.GetName()]; ok && efw.Matched(p) {
 			result = append(result, proto.Clone(p.Value).(*core.TypedExtensionConfig))
 		}
 	}
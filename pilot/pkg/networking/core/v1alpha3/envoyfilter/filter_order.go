@@ -0,0 +1,140 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envoyfilter
+
+import (
+	xdslistener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	http_conn "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+
+	"istio.io/istio/pkg/config/routing"
+	"istio.io/pkg/log"
+)
+
+// orderNetworkFilters reorders the network filters to satisfy the filter order constraints of the EnvoyFilters.
+func orderNetworkFilters(orders []routing.FilterOrder, filters []*xdslistener.Filter) []*xdslistener.Filter {
+	names := make([]string, len(filters))
+	for i, filter := range filters {
+		names[i] = filter.Name
+	}
+	permutation := orderFilters(orders, names)
+	if permutation == nil {
+		return filters
+	}
+	out := make([]*xdslistener.Filter, len(filters))
+	for i, j := range permutation {
+		out[i] = filters[j]
+	}
+	return out
+}
+
+// orderHTTPFilters reorders the HTTP filters to satisfy the filter order constraints of the EnvoyFilters.
+func orderHTTPFilters(orders []routing.FilterOrder, filters []*http_conn.HttpFilter) []*http_conn.HttpFilter {
+	names := make([]string, len(filters))
+	for i, filter := range filters {
+		names[i] = filter.Name
+	}
+	permutation := orderFilters(orders, names)
+	if permutation == nil {
+		return filters
+	}
+	out := make([]*http_conn.HttpFilter, len(filters))
+	for i, j := range permutation {
+		out[i] = filters[j]
+	}
+	return out
+}
+
+// orderFilters returns the order of the filters satisfying the constraints, as the indexes of the filters, or nil
+// if they are already ordered. The filters keep their relative order unless a constraint requires otherwise, and
+// the last one, which terminates the chain, always stays last. The constraints are added in order, those
+// contradicting the previous ones being ignored.
+func orderFilters(orders []routing.FilterOrder, names []string) []int {
+	// the terminal filter is not ordered
+	n := len(names) - 1
+	if len(orders) == 0 || n < 2 {
+		return nil
+	}
+	index := func(name string) int {
+		for i := 0; i < n; i++ {
+			if nameMatches(name, names[i]) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	after := make([][]int, n)
+	inDegree := make([]int, n)
+	for _, o := range orders {
+		first, second := o.Ordered()
+		i, j := index(first), index(second)
+		if i < 0 || j < 0 || i == j {
+			continue
+		}
+		if reaches(after, j, i) {
+			log.Debugf("ignored filter order %q contradicting the previous constraints", o.String())
+			continue
+		}
+		after[i] = append(after[i], j)
+		inDegree[j]++
+	}
+
+	// Stable topological sort: the first filter, in the current order, with no filter left to be placed before it
+	// is placed next.
+	out := make([]int, 0, len(names))
+	placed := make([]bool, n)
+	changed := false
+	for len(out) < n {
+		for i := 0; i < n; i++ {
+			if placed[i] || inDegree[i] > 0 {
+				continue
+			}
+			if i != len(out) {
+				changed = true
+			}
+			placed[i] = true
+			out = append(out, i)
+			for _, j := range after[i] {
+				inDegree[j]--
+			}
+			break
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return append(out, n)
+}
+
+// reaches returns true if the filter is ordered, directly or not, before the target.
+func reaches(after [][]int, filter, target int) bool {
+	seen := make([]bool, len(after))
+	seen[filter] = true
+	pending := []int{filter}
+	for len(pending) > 0 {
+		current := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if current == target {
+			return true
+		}
+		for _, next := range after[current] {
+			if !seen[next] {
+				seen[next] = true
+				pending = append(pending, next)
+			}
+		}
+	}
+	return false
+}
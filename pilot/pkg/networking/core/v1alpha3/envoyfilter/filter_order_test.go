@@ -0,0 +1,89 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envoyfilter
+
+import (
+	"reflect"
+	"testing"
+
+	http_conn "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+
+	"istio.io/istio/pkg/config/routing"
+)
+
+func TestOrderHTTPFilters(t *testing.T) {
+	istioFilters := []string{"istio.metadata_exchange", "envoy.filters.http.rbac", "my.authz", "istio.stats", "envoy.filters.http.router"}
+	cases := []struct {
+		name   string
+		orders []routing.FilterOrder
+		want   []string
+	}{
+		{
+			name:   "no constraints",
+			orders: nil,
+			want:   istioFilters,
+		},
+		{
+			name:   "already ordered",
+			orders: []routing.FilterOrder{{Filter: "my.authz", Anchor: "envoy.filters.http.rbac"}},
+			want:   istioFilters,
+		},
+		{
+			name:   "moved before",
+			orders: []routing.FilterOrder{{Filter: "my.authz", Anchor: "envoy.filters.http.rbac", Before: true}},
+			want:   []string{"istio.metadata_exchange", "my.authz", "envoy.filters.http.rbac", "istio.stats", "envoy.filters.http.router"},
+		},
+		{
+			name:   "moved after",
+			orders: []routing.FilterOrder{{Filter: "envoy.filters.http.rbac", Anchor: "istio.stats"}},
+			want:   []string{"istio.metadata_exchange", "my.authz", "istio.stats", "envoy.filters.http.rbac", "envoy.filters.http.router"},
+		},
+		{
+			name:   "transitive constraints",
+			orders: []routing.FilterOrder{{Filter: "istio.stats", Anchor: "my.authz", Before: true}, {Filter: "my.authz", Anchor: "istio.metadata_exchange", Before: true}},
+			want:   []string{"envoy.filters.http.rbac", "istio.stats", "my.authz", "istio.metadata_exchange", "envoy.filters.http.router"},
+		},
+		{
+			name:   "contradicting constraint ignored",
+			orders: []routing.FilterOrder{{Filter: "my.authz", Anchor: "envoy.filters.http.rbac", Before: true}, {Filter: "envoy.filters.http.rbac", Anchor: "istio.stats", Before: true}, {Filter: "istio.stats", Anchor: "my.authz", Before: true}},
+			want:   []string{"istio.metadata_exchange", "my.authz", "envoy.filters.http.rbac", "istio.stats", "envoy.filters.http.router"},
+		},
+		{
+			name:   "missing filters ignored",
+			orders: []routing.FilterOrder{{Filter: "other.filter", Anchor: "istio.metadata_exchange", Before: true}},
+			want:   istioFilters,
+		},
+		{
+			name:   "terminal filter stays last",
+			orders: []routing.FilterOrder{{Filter: "envoy.filters.http.router", Anchor: "istio.stats", Before: true}},
+			want:   istioFilters,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			filters := make([]*http_conn.HttpFilter, 0, len(istioFilters))
+			for _, name := range istioFilters {
+				filters = append(filters, &http_conn.HttpFilter{Name: name})
+			}
+			var got []string
+			for _, filter := range orderHTTPFilters(c.orders, filters) {
+				got = append(got, filter.Name)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
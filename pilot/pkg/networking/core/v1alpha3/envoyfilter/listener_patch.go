@@ -77,7 +77,7 @@ func doListenerListOperation(
 			// removed by another op
 			continue
 		}
-		doListenerOperation(patchContext, envoyFilterWrapper.Patches, listener, &listenersRemoved)
+		doListenerOperation(patchContext, envoyFilterWrapper, listener, &listenersRemoved)
 	}
 	// adds at listener level if enabled
 	if !skipAdds {
@@ -86,6 +86,13 @@ func doListenerListOperation(
 				if !commonConditionMatch(patchContext, cp) {
 					continue
 				}
+				if l, ok := cp.Value.(*xdslistener.Listener); ok && l.Name != "" && hasListener(listeners, l.Name) {
+					envoyFilterWrapper.Conflicted(cp, fmt.Sprintf("listener %s already exists", l.Name))
+					continue
+				}
+				if !envoyFilterWrapper.Matched(cp) {
+					continue
+				}
 
 				// clone before append. Otherwise, subsequent operations on this listener will corrupt
 				// the master value stored in CP..
@@ -107,11 +114,12 @@ func doListenerListOperation(
 }
 
 func doListenerOperation(patchContext networking.EnvoyFilter_PatchContext,
-	patches map[networking.EnvoyFilter_ApplyTo][]*model.EnvoyFilterConfigPatchWrapper,
+	efw *model.EnvoyFilterWrapper,
 	listener *xdslistener.Listener, listenersRemoved *bool) {
-	for _, cp := range patches[networking.EnvoyFilter_LISTENER] {
+	for _, cp := range efw.Patches[networking.EnvoyFilter_LISTENER] {
 		if !commonConditionMatch(patchContext, cp) ||
-			!listenerMatch(listener, cp) {
+			!listenerMatch(listener, cp) ||
+			!efw.Matched(cp) {
 			continue
 		}
 
@@ -125,30 +133,31 @@ func doListenerOperation(patchContext networking.EnvoyFilter_PatchContext,
 		}
 	}
 
-	doFilterChainListOperation(patchContext, patches, listener)
+	doFilterChainListOperation(patchContext, efw, listener)
 }
 
 func doFilterChainListOperation(patchContext networking.EnvoyFilter_PatchContext,
-	patches map[networking.EnvoyFilter_ApplyTo][]*model.EnvoyFilterConfigPatchWrapper,
+	efw *model.EnvoyFilterWrapper,
 	listener *xdslistener.Listener) {
 	filterChainsRemoved := false
 	for i, fc := range listener.FilterChains {
 		if fc.Filters == nil {
 			continue
 		}
-		doFilterChainOperation(patchContext, patches, listener, listener.FilterChains[i], &filterChainsRemoved)
+		doFilterChainOperation(patchContext, efw, listener, listener.FilterChains[i], &filterChainsRemoved)
 	}
 	if fc := listener.GetDefaultFilterChain(); fc.GetFilters() != nil {
 		removed := false
-		doFilterChainOperation(patchContext, patches, listener, fc, &removed)
+		doFilterChainOperation(patchContext, efw, listener, fc, &removed)
 		if removed {
 			listener.DefaultFilterChain = nil
 		}
 	}
-	for _, cp := range patches[networking.EnvoyFilter_FILTER_CHAIN] {
+	for _, cp := range efw.Patches[networking.EnvoyFilter_FILTER_CHAIN] {
 		if cp.Operation == networking.EnvoyFilter_Patch_ADD {
 			if !commonConditionMatch(patchContext, cp) ||
-				!listenerMatch(listener, cp) {
+				!listenerMatch(listener, cp) ||
+				!efw.Matched(cp) {
 				continue
 			}
 			listener.FilterChains = append(listener.FilterChains, proto.Clone(cp.Value).(*xdslistener.FilterChain))
@@ -166,13 +175,14 @@ func doFilterChainListOperation(patchContext networking.EnvoyFilter_PatchContext
 }
 
 func doFilterChainOperation(patchContext networking.EnvoyFilter_PatchContext,
-	patches map[networking.EnvoyFilter_ApplyTo][]*model.EnvoyFilterConfigPatchWrapper,
+	efw *model.EnvoyFilterWrapper,
 	listener *xdslistener.Listener,
 	fc *xdslistener.FilterChain, filterChainRemoved *bool) {
-	for _, cp := range patches[networking.EnvoyFilter_FILTER_CHAIN] {
+	for _, cp := range efw.Patches[networking.EnvoyFilter_FILTER_CHAIN] {
 		if !commonConditionMatch(patchContext, cp) ||
 			!listenerMatch(listener, cp) ||
-			!filterChainMatch(listener, fc, cp) {
+			!filterChainMatch(listener, fc, cp) ||
+			!efw.Matched(cp) {
 			continue
 		}
 		if cp.Operation == networking.EnvoyFilter_Patch_REMOVE {
@@ -185,6 +195,7 @@ func doFilterChainOperation(patchContext networking.EnvoyFilter_PatchContext,
 			ret, err := mergeTransportSocketListener(fc, cp)
 			if err != nil {
 				log.Debugf("merge of transport socket failed for listener: %v", err)
+				efw.Conflicted(cp, err.Error())
 				continue
 			}
 			if !ret {
@@ -192,7 +203,7 @@ func doFilterChainOperation(patchContext networking.EnvoyFilter_PatchContext,
 			}
 		}
 	}
-	doNetworkFilterListOperation(patchContext, patches, listener, fc)
+	doNetworkFilterListOperation(patchContext, efw, listener, fc)
 }
 
 // Test if the patch contains a config for TransportSocket
@@ -232,16 +243,16 @@ func mergeTransportSocketListener(fc *xdslistener.FilterChain, cp *model.EnvoyFi
 }
 
 func doNetworkFilterListOperation(patchContext networking.EnvoyFilter_PatchContext,
-	patches map[networking.EnvoyFilter_ApplyTo][]*model.EnvoyFilterConfigPatchWrapper,
+	efw *model.EnvoyFilterWrapper,
 	listener *xdslistener.Listener, fc *xdslistener.FilterChain) {
 	networkFiltersRemoved := false
 	for i, filter := range fc.Filters {
 		if filter.Name == "" {
 			continue
 		}
-		doNetworkFilterOperation(patchContext, patches, listener, fc, fc.Filters[i], &networkFiltersRemoved)
+		doNetworkFilterOperation(patchContext, efw, listener, fc, fc.Filters[i], &networkFiltersRemoved)
 	}
-	for _, cp := range patches[networking.EnvoyFilter_NETWORK_FILTER] {
+	for _, cp := range efw.Patches[networking.EnvoyFilter_NETWORK_FILTER] {
 		if !commonConditionMatch(patchContext, cp) ||
 			!listenerMatch(listener, cp) ||
 			!filterChainMatch(listener, fc, cp) {
@@ -249,11 +260,15 @@ func doNetworkFilterListOperation(patchContext networking.EnvoyFilter_PatchConte
 		}
 
 		if cp.Operation == networking.EnvoyFilter_Patch_ADD {
-			fc.Filters = append(fc.Filters, proto.Clone(cp.Value).(*xdslistener.Filter))
+			if efw.Matched(cp) {
+				fc.Filters = append(fc.Filters, proto.Clone(cp.Value).(*xdslistener.Filter))
+			}
 		} else if cp.Operation == networking.EnvoyFilter_Patch_INSERT_AFTER {
 			// Insert after without a filter match is same as ADD in the end
 			if !hasNetworkFilterMatch(cp) {
-				fc.Filters = append(fc.Filters, proto.Clone(cp.Value).(*xdslistener.Filter))
+				if efw.Matched(cp) {
+					fc.Filters = append(fc.Filters, proto.Clone(cp.Value).(*xdslistener.Filter))
+				}
 				continue
 			}
 			// find the matching filter first
@@ -265,7 +280,7 @@ func doNetworkFilterListOperation(patchContext networking.EnvoyFilter_PatchConte
 				}
 			}
 
-			if insertPosition == -1 {
+			if insertPosition == -1 || !efw.Matched(cp) {
 				continue
 			}
 
@@ -278,7 +293,9 @@ func doNetworkFilterListOperation(patchContext networking.EnvoyFilter_PatchConte
 		} else if cp.Operation == networking.EnvoyFilter_Patch_INSERT_BEFORE || cp.Operation == networking.EnvoyFilter_Patch_INSERT_FIRST {
 			// insert before/first without a filter match is same as insert in the beginning
 			if !hasNetworkFilterMatch(cp) {
-				fc.Filters = append([]*xdslistener.Filter{proto.Clone(cp.Value).(*xdslistener.Filter)}, fc.Filters...)
+				if efw.Matched(cp) {
+					fc.Filters = append([]*xdslistener.Filter{proto.Clone(cp.Value).(*xdslistener.Filter)}, fc.Filters...)
+				}
 				continue
 			}
 			// find the matching filter first
@@ -291,7 +308,7 @@ func doNetworkFilterListOperation(patchContext networking.EnvoyFilter_PatchConte
 			}
 
 			// If matching filter is not found, then don't insert and continue.
-			if insertPosition == -1 {
+			if insertPosition == -1 || !efw.Matched(cp) {
 				continue
 			}
 
@@ -320,6 +337,9 @@ func doNetworkFilterListOperation(patchContext networking.EnvoyFilter_PatchConte
 				log.Debugf("EnvoyFilter patch %v is not applied because no matching network filter found.", cp)
 				continue
 			}
+			if !efw.Matched(cp) {
+				continue
+			}
 			fc.Filters[replacePosition] = proto.Clone(cp.Value).(*xdslistener.Filter)
 		}
 	}
@@ -332,17 +352,19 @@ func doNetworkFilterListOperation(patchContext networking.EnvoyFilter_PatchConte
 		}
 		fc.Filters = tempArray
 	}
+	fc.Filters = orderNetworkFilters(efw.FilterOrder, fc.Filters)
 }
 
 func doNetworkFilterOperation(patchContext networking.EnvoyFilter_PatchContext,
-	patches map[networking.EnvoyFilter_ApplyTo][]*model.EnvoyFilterConfigPatchWrapper,
+	efw *model.EnvoyFilterWrapper,
 	listener *xdslistener.Listener, fc *xdslistener.FilterChain,
 	filter *xdslistener.Filter, networkFilterRemoved *bool) {
-	for _, cp := range patches[networking.EnvoyFilter_NETWORK_FILTER] {
+	for _, cp := range efw.Patches[networking.EnvoyFilter_NETWORK_FILTER] {
 		if !commonConditionMatch(patchContext, cp) ||
 			!listenerMatch(listener, cp) ||
 			!filterChainMatch(listener, fc, cp) ||
-			!networkFilterMatch(filter, cp) {
+			!networkFilterMatch(filter, cp) ||
+			!efw.Matched(cp) {
 			continue
 		}
 		if cp.Operation == networking.EnvoyFilter_Patch_REMOVE {
@@ -359,6 +381,7 @@ func doNetworkFilterOperation(patchContext networking.EnvoyFilter_PatchContext,
 				// TODO(rshriram): fixme
 				// skip this op as we would possibly have to do a merge of Any with struct
 				// which doesn't seem to work well.
+				efw.Conflicted(cp, fmt.Sprintf("network filter %s has no typed config to merge into", filter.Name))
 				continue
 			}
 			userFilter := cp.Value.(*xdslistener.Filter)
@@ -380,6 +403,7 @@ func doNetworkFilterOperation(patchContext networking.EnvoyFilter_PatchContext,
 					userFilter.ConfigType.(*xdslistener.Filter_TypedConfig).TypedConfig.TypeUrl = filter.GetTypedConfig().TypeUrl
 				}
 				if retVal, err = util.MergeAnyWithAny(filter.GetTypedConfig(), userFilter.GetTypedConfig()); err != nil {
+					efw.Conflicted(cp, err.Error())
 					retVal = filter.GetTypedConfig()
 				}
 			}
@@ -390,12 +414,12 @@ func doNetworkFilterOperation(patchContext networking.EnvoyFilter_PatchContext,
 		}
 	}
 	if filter.Name == wellknown.HTTPConnectionManager {
-		doHTTPFilterListOperation(patchContext, patches, listener, fc, filter)
+		doHTTPFilterListOperation(patchContext, efw, listener, fc, filter)
 	}
 }
 
 func doHTTPFilterListOperation(patchContext networking.EnvoyFilter_PatchContext,
-	patches map[networking.EnvoyFilter_ApplyTo][]*model.EnvoyFilterConfigPatchWrapper,
+	efw *model.EnvoyFilterWrapper,
 	listener *xdslistener.Listener, fc *xdslistener.FilterChain, filter *xdslistener.Filter) {
 	hcm := &http_conn.HttpConnectionManager{}
 	if filter.GetTypedConfig() != nil {
@@ -410,9 +434,9 @@ func doHTTPFilterListOperation(patchContext networking.EnvoyFilter_PatchContext,
 		if httpFilter.Name == "" {
 			continue
 		}
-		doHTTPFilterOperation(patchContext, patches, listener, fc, filter, httpFilter, &httpFiltersRemoved)
+		doHTTPFilterOperation(patchContext, efw, listener, fc, filter, httpFilter, &httpFiltersRemoved)
 	}
-	for _, cp := range patches[networking.EnvoyFilter_HTTP_FILTER] {
+	for _, cp := range efw.Patches[networking.EnvoyFilter_HTTP_FILTER] {
 		if !commonConditionMatch(patchContext, cp) ||
 			!listenerMatch(listener, cp) ||
 			!filterChainMatch(listener, fc, cp) ||
@@ -421,11 +445,15 @@ func doHTTPFilterListOperation(patchContext networking.EnvoyFilter_PatchContext,
 		}
 
 		if cp.Operation == networking.EnvoyFilter_Patch_ADD {
-			hcm.HttpFilters = append(hcm.HttpFilters, proto.Clone(cp.Value).(*http_conn.HttpFilter))
+			if efw.Matched(cp) {
+				hcm.HttpFilters = append(hcm.HttpFilters, proto.Clone(cp.Value).(*http_conn.HttpFilter))
+			}
 		} else if cp.Operation == networking.EnvoyFilter_Patch_INSERT_AFTER {
 			// Insert after without a filter match is same as ADD in the end
 			if !hasHTTPFilterMatch(cp) {
-				hcm.HttpFilters = append(hcm.HttpFilters, proto.Clone(cp.Value).(*http_conn.HttpFilter))
+				if efw.Matched(cp) {
+					hcm.HttpFilters = append(hcm.HttpFilters, proto.Clone(cp.Value).(*http_conn.HttpFilter))
+				}
 				continue
 			}
 
@@ -438,7 +466,7 @@ func doHTTPFilterListOperation(patchContext networking.EnvoyFilter_PatchContext,
 				}
 			}
 
-			if insertPosition == -1 {
+			if insertPosition == -1 || !efw.Matched(cp) {
 				continue
 			}
 
@@ -451,7 +479,9 @@ func doHTTPFilterListOperation(patchContext networking.EnvoyFilter_PatchContext,
 		} else if cp.Operation == networking.EnvoyFilter_Patch_INSERT_BEFORE {
 			// insert before without a filter match is same as insert in the beginning
 			if !hasHTTPFilterMatch(cp) {
-				hcm.HttpFilters = append([]*http_conn.HttpFilter{proto.Clone(cp.Value).(*http_conn.HttpFilter)}, hcm.HttpFilters...)
+				if efw.Matched(cp) {
+					hcm.HttpFilters = append([]*http_conn.HttpFilter{proto.Clone(cp.Value).(*http_conn.HttpFilter)}, hcm.HttpFilters...)
+				}
 				continue
 			}
 
@@ -464,7 +494,7 @@ func doHTTPFilterListOperation(patchContext networking.EnvoyFilter_PatchContext,
 				}
 			}
 
-			if insertPosition == -1 {
+			if insertPosition == -1 || !efw.Matched(cp) {
 				continue
 			}
 
@@ -490,6 +520,9 @@ func doHTTPFilterListOperation(patchContext networking.EnvoyFilter_PatchContext,
 				log.Debugf("EnvoyFilter patch %v is not applied because no matching HTTP filter found.", cp)
 				continue
 			}
+			if !efw.Matched(cp) {
+				continue
+			}
 
 			clonedVal := proto.Clone(cp.Value).(*http_conn.HttpFilter)
 			hcm.HttpFilters[replacePosition] = clonedVal
@@ -504,6 +537,7 @@ func doHTTPFilterListOperation(patchContext networking.EnvoyFilter_PatchContext,
 		}
 		hcm.HttpFilters = tempArray
 	}
+	hcm.HttpFilters = orderHTTPFilters(efw.FilterOrder, hcm.HttpFilters)
 	if filter.GetTypedConfig() != nil {
 		// convert to any type
 		filter.ConfigType = &xdslistener.Filter_TypedConfig{TypedConfig: util.MessageToAny(hcm)}
@@ -511,15 +545,16 @@ func doHTTPFilterListOperation(patchContext networking.EnvoyFilter_PatchContext,
 }
 
 func doHTTPFilterOperation(patchContext networking.EnvoyFilter_PatchContext,
-	patches map[networking.EnvoyFilter_ApplyTo][]*model.EnvoyFilterConfigPatchWrapper,
+	efw *model.EnvoyFilterWrapper,
 	listener *xdslistener.Listener, fc *xdslistener.FilterChain, filter *xdslistener.Filter,
 	httpFilter *http_conn.HttpFilter, httpFilterRemoved *bool) {
-	for _, cp := range patches[networking.EnvoyFilter_HTTP_FILTER] {
+	for _, cp := range efw.Patches[networking.EnvoyFilter_HTTP_FILTER] {
 		if !commonConditionMatch(patchContext, cp) ||
 			!listenerMatch(listener, cp) ||
 			!filterChainMatch(listener, fc, cp) ||
 			!networkFilterMatch(filter, cp) ||
-			!httpFilterMatch(httpFilter, cp) {
+			!httpFilterMatch(httpFilter, cp) ||
+			!efw.Matched(cp) {
 			continue
 		}
 		if cp.Operation == networking.EnvoyFilter_Patch_REMOVE {
@@ -536,6 +571,7 @@ func doHTTPFilterOperation(patchContext networking.EnvoyFilter_PatchContext,
 				// TODO(rshriram): fixme
 				// skip this op as we would possibly have to do a merge of Any with struct
 				// which doesn't seem to work well.
+				efw.Conflicted(cp, fmt.Sprintf("HTTP filter %s has no typed config to merge into", httpFilter.Name))
 				continue
 			}
 			userHTTPFilter := cp.Value.(*http_conn.HttpFilter)
@@ -557,6 +593,7 @@ func doHTTPFilterOperation(patchContext networking.EnvoyFilter_PatchContext,
 					userHTTPFilter.ConfigType.(*http_conn.HttpFilter_TypedConfig).TypedConfig.TypeUrl = httpFilter.GetTypedConfig().TypeUrl
 				}
 				if retVal, err = util.MergeAnyWithAny(httpFilter.GetTypedConfig(), userHTTPFilter.GetTypedConfig()); err != nil {
+					efw.Conflicted(cp, err.Error())
 					retVal = httpFilter.GetTypedConfig()
 				}
 			}
@@ -568,6 +605,16 @@ func doHTTPFilterOperation(patchContext networking.EnvoyFilter_PatchContext,
 	}
 }
 
+// hasListener returns true if a listener has the name, ignoring the listeners removed by other patches.
+func hasListener(listeners []*xdslistener.Listener, name string) bool {
+	for _, l := range listeners {
+		if l.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 func listenerMatch(listener *xdslistener.Listener, cp *model.EnvoyFilterConfigPatchWrapper) bool {
 	cMatch := cp.Match.GetListener()
 	if cMatch == nil {
@@ -50,32 +50,34 @@ func ApplyRouteConfigurationPatches(
 		}
 
 		if commonConditionMatch(patchContext, cp) &&
-			routeConfigurationMatch(patchContext, routeConfiguration, cp) {
+			routeConfigurationMatch(patchContext, routeConfiguration, cp) &&
+			efw.Matched(cp) {
 			proto.Merge(routeConfiguration, cp.Value)
 		}
 	}
 
-	doVirtualHostListOperation(patchContext, efw.Patches, routeConfiguration)
+	doVirtualHostListOperation(patchContext, efw, routeConfiguration)
 
 	return routeConfiguration
 }
 
 func doVirtualHostListOperation(patchContext networking.EnvoyFilter_PatchContext,
-	patches map[networking.EnvoyFilter_ApplyTo][]*model.EnvoyFilterConfigPatchWrapper,
+	efw *model.EnvoyFilterWrapper,
 	routeConfiguration *route.RouteConfiguration) {
 	virtualHostsRemoved := false
 	// first do removes/merges
 	for _, vhost := range routeConfiguration.VirtualHosts {
-		doVirtualHostOperation(patchContext, patches, routeConfiguration, vhost, &virtualHostsRemoved)
+		doVirtualHostOperation(patchContext, efw, routeConfiguration, vhost, &virtualHostsRemoved)
 	}
 
 	// now for the adds
-	for _, cp := range patches[networking.EnvoyFilter_VIRTUAL_HOST] {
+	for _, cp := range efw.Patches[networking.EnvoyFilter_VIRTUAL_HOST] {
 		if cp.Operation != networking.EnvoyFilter_Patch_ADD {
 			continue
 		}
 		if commonConditionMatch(patchContext, cp) &&
-			routeConfigurationMatch(patchContext, routeConfiguration, cp) {
+			routeConfigurationMatch(patchContext, routeConfiguration, cp) &&
+			efw.Matched(cp) {
 			routeConfiguration.VirtualHosts = append(routeConfiguration.VirtualHosts, proto.Clone(cp.Value).(*route.VirtualHost))
 		}
 	}
@@ -93,12 +95,13 @@ func doVirtualHostListOperation(patchContext networking.EnvoyFilter_PatchContext
 }
 
 func doVirtualHostOperation(patchContext networking.EnvoyFilter_PatchContext,
-	patches map[networking.EnvoyFilter_ApplyTo][]*model.EnvoyFilterConfigPatchWrapper,
+	efw *model.EnvoyFilterWrapper,
 	routeConfiguration *route.RouteConfiguration, virtualHost *route.VirtualHost, virtualHostRemoved *bool) {
-	for _, cp := range patches[networking.EnvoyFilter_VIRTUAL_HOST] {
+	for _, cp := range efw.Patches[networking.EnvoyFilter_VIRTUAL_HOST] {
 		if commonConditionMatch(patchContext, cp) &&
 			routeConfigurationMatch(patchContext, routeConfiguration, cp) &&
-			virtualHostMatch(virtualHost, cp) {
+			virtualHostMatch(virtualHost, cp) &&
+			efw.Matched(cp) {
 			if cp.Operation == networking.EnvoyFilter_Patch_REMOVE {
 				virtualHost.Name = ""
 				*virtualHostRemoved = true
@@ -109,7 +112,7 @@ func doVirtualHostOperation(patchContext networking.EnvoyFilter_PatchContext,
 			}
 		}
 	}
-	doHTTPRouteListOperation(patchContext, patches, routeConfiguration, virtualHost)
+	doHTTPRouteListOperation(patchContext, efw, routeConfiguration, virtualHost)
 }
 
 func hasRouteMatch(cp *model.EnvoyFilterConfigPatchWrapper) bool {
@@ -127,16 +130,16 @@ func hasRouteMatch(cp *model.EnvoyFilterConfigPatchWrapper) bool {
 }
 
 func doHTTPRouteListOperation(patchContext networking.EnvoyFilter_PatchContext,
-	patches map[networking.EnvoyFilter_ApplyTo][]*model.EnvoyFilterConfigPatchWrapper,
+	efw *model.EnvoyFilterWrapper,
 	routeConfiguration *route.RouteConfiguration, virtualHost *route.VirtualHost) {
 	routesRemoved := false
 	// Apply the route level removes/merges if any.
 	for index := range virtualHost.Routes {
-		doHTTPRouteOperation(patchContext, patches, routeConfiguration, virtualHost, index, &routesRemoved)
+		doHTTPRouteOperation(patchContext, efw, routeConfiguration, virtualHost, index, &routesRemoved)
 	}
 
 	// now for the adds
-	for _, cp := range patches[networking.EnvoyFilter_HTTP_ROUTE] {
+	for _, cp := range efw.Patches[networking.EnvoyFilter_HTTP_ROUTE] {
 		if !commonConditionMatch(patchContext, cp) ||
 			!routeConfigurationMatch(patchContext, routeConfiguration, cp) ||
 			!virtualHostMatch(virtualHost, cp) {
@@ -144,11 +147,15 @@ func doHTTPRouteListOperation(patchContext networking.EnvoyFilter_PatchContext,
 		}
 
 		if cp.Operation == networking.EnvoyFilter_Patch_ADD {
-			virtualHost.Routes = append(virtualHost.Routes, proto.Clone(cp.Value).(*route.Route))
+			if efw.Matched(cp) {
+				virtualHost.Routes = append(virtualHost.Routes, proto.Clone(cp.Value).(*route.Route))
+			}
 		} else if cp.Operation == networking.EnvoyFilter_Patch_INSERT_AFTER {
 			// Insert after without a route match is same as ADD in the end
 			if !hasRouteMatch(cp) {
-				virtualHost.Routes = append(virtualHost.Routes, proto.Clone(cp.Value).(*route.Route))
+				if efw.Matched(cp) {
+					virtualHost.Routes = append(virtualHost.Routes, proto.Clone(cp.Value).(*route.Route))
+				}
 				continue
 			}
 			// find the matching route first
@@ -160,7 +167,7 @@ func doHTTPRouteListOperation(patchContext networking.EnvoyFilter_PatchContext,
 				}
 			}
 
-			if insertPosition == -1 {
+			if insertPosition == -1 || !efw.Matched(cp) {
 				continue
 			}
 
@@ -173,7 +180,9 @@ func doHTTPRouteListOperation(patchContext networking.EnvoyFilter_PatchContext,
 		} else if cp.Operation == networking.EnvoyFilter_Patch_INSERT_BEFORE || cp.Operation == networking.EnvoyFilter_Patch_INSERT_FIRST {
 			// insert before/first without a route match is same as insert in the beginning
 			if !hasRouteMatch(cp) {
-				virtualHost.Routes = append([]*route.Route{proto.Clone(cp.Value).(*route.Route)}, virtualHost.Routes...)
+				if efw.Matched(cp) {
+					virtualHost.Routes = append([]*route.Route{proto.Clone(cp.Value).(*route.Route)}, virtualHost.Routes...)
+				}
 				continue
 			}
 			// find the matching route first
@@ -186,7 +195,7 @@ func doHTTPRouteListOperation(patchContext networking.EnvoyFilter_PatchContext,
 			}
 
 			// If matching route is not found, then don't insert and continue.
-			if insertPosition == -1 {
+			if insertPosition == -1 || !efw.Matched(cp) {
 				continue
 			}
 
@@ -215,13 +224,14 @@ func doHTTPRouteListOperation(patchContext networking.EnvoyFilter_PatchContext,
 }
 
 func doHTTPRouteOperation(patchContext networking.EnvoyFilter_PatchContext,
-	patches map[networking.EnvoyFilter_ApplyTo][]*model.EnvoyFilterConfigPatchWrapper,
+	efw *model.EnvoyFilterWrapper,
 	routeConfiguration *route.RouteConfiguration, virtualHost *route.VirtualHost, routeIndex int, routesRemoved *bool) {
-	for _, cp := range patches[networking.EnvoyFilter_HTTP_ROUTE] {
+	for _, cp := range efw.Patches[networking.EnvoyFilter_HTTP_ROUTE] {
 		if commonConditionMatch(patchContext, cp) &&
 			routeConfigurationMatch(patchContext, routeConfiguration, cp) &&
 			virtualHostMatch(virtualHost, cp) &&
-			routeMatch(virtualHost.Routes[routeIndex], cp) {
+			routeMatch(virtualHost.Routes[routeIndex], cp) &&
+			efw.Matched(cp) {
 
 			// different virtualHosts may share same routes pointer
 			virtualHost.Routes = cloneVhostRoutes(virtualHost.Routes)
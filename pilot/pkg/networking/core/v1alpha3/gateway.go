@@ -23,6 +23,7 @@ import (
 	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	quic "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/quic/v3"
 	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	"github.com/hashicorp/go-multierror"
 
@@ -43,6 +44,18 @@ import (
 	"istio.io/pkg/log"
 )
 
+const (
+	// quicListenerPrefix prefixes the name of the QUIC listener of a gateway port, which shares its address with the
+	// HTTPS listener of the port.
+	quicListenerPrefix = "udp_"
+	// quicListenerName is the name of the Envoy UDP listener factory for QUIC.
+	quicListenerName = "quiche_quic_listener"
+
+	altSvcHeader = "alt-svc"
+	// altSvcMaxAge is the number of seconds clients may remember that HTTP/3 is available.
+	altSvcMaxAge = 86400
+)
+
 func (configgen *ConfigGeneratorImpl) buildGatewayListeners(builder *ListenerBuilder) *ListenerBuilder {
 	if builder.node.MergedGateway == nil {
 		log.Debug("buildGatewayListeners: no gateways for router ", builder.node.ID)
@@ -60,20 +73,8 @@ func (configgen *ConfigGeneratorImpl) buildGatewayListeners(builder *ListenerBui
 	proxyConfig := builder.node.Metadata.ProxyConfigOrDefault(builder.push.Mesh.DefaultConfig)
 	for port, ms := range mergedGateway.MergedServers {
 		servers := ms.Servers
-		var si *model.ServiceInstance
-		services := make(map[host.Name]struct{}, len(builder.node.ServiceInstances))
-		for _, w := range builder.node.ServiceInstances {
-			if w.ServicePort.Port == int(port.Number) {
-				if si == nil {
-					si = w
-				}
-				services[w.Service.Hostname] = struct{}{}
-			}
-		}
-		if len(services) != 1 {
-			log.Warnf("buildGatewayListeners: found %d services on port %d: %v",
-				len(services), port.Number, services)
-		}
+		serverPort := port.Number
+		si := gatewayServiceInstance(builder.node, serverPort, false)
 		// if we found a ServiceInstance with matching ServicePort, listen on TargetPort
 		if si != nil && si.Endpoint != nil {
 			port.Number = si.Endpoint.EndpointPort
@@ -95,7 +96,7 @@ func (configgen *ConfigGeneratorImpl) buildGatewayListeners(builder *ListenerBui
 			class:      ListenerClassGateway,
 		}
 
-		p := protocol.Parse(port.Protocol)
+		p := protocol.ParseGatewayProtocol(port.Protocol)
 		listenerProtocol := istionetworking.ModelProtocolToListenerProtocol(p, core.TrafficDirection_OUTBOUND)
 		filterChains := make([]istionetworking.FilterChain, 0)
 		if p.IsHTTP() {
@@ -168,6 +169,15 @@ func (configgen *ConfigGeneratorImpl) buildGatewayListeners(builder *ListenerBui
 				len(mutable.Listener.FilterChains), mutable.Listener)
 		}
 		listeners = append(listeners, mutable.Listener)
+
+		if features.EnableQUICListeners && p == protocol.HTTP3 {
+			quicListener, err := configgen.buildGatewayQUICListener(builder, opts, servers, serverPort, si, proxyConfig)
+			if err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("gateway omitting QUIC listener %q due to: %v", quicListener.Name, err.Error()))
+			} else if quicListener != nil {
+				listeners = append(listeners, quicListener)
+			}
+		}
 	}
 	// We'll try to return any listeners we successfully marshaled; if we have none, we'll emit the error we built up
 	err := errs.ErrorOrNil()
@@ -185,6 +195,122 @@ func (configgen *ConfigGeneratorImpl) buildGatewayListeners(builder *ListenerBui
 	return builder
 }
 
+// gatewayServiceInstance returns the service instance of the gateway exposing the server port, over UDP if udp is
+// set and over TCP otherwise, as the Service of a gateway serving HTTP3 may declare the port for both protocols.
+func gatewayServiceInstance(node *model.Proxy, serverPort uint32, udp bool) *model.ServiceInstance {
+	var si *model.ServiceInstance
+	services := make(map[host.Name]struct{}, len(node.ServiceInstances))
+	for _, w := range node.ServiceInstances {
+		if w.ServicePort.Port != int(serverPort) || (w.ServicePort.Protocol == protocol.UDP) != udp {
+			continue
+		}
+		if si == nil {
+			si = w
+		}
+		services[w.Service.Hostname] = struct{}{}
+	}
+	if len(services) != 1 && !udp {
+		log.Warnf("buildGatewayListeners: found %d services on port %d: %v",
+			len(services), serverPort, services)
+	}
+	return si
+}
+
+// buildGatewayQUICListener builds the UDP listener serving HTTP/3 over QUIC for the HTTP3 servers of a gateway port.
+// The servers keep their HTTPS listener on the same port, whose TLS settings and routes are shared with this listener,
+// so that clients can connect over TCP first and upgrade to HTTP/3 with the alt-svc header added to the routes.
+// The listener binds to the target port of the UDP port of the gateway Service, or to the one of the HTTPS listener
+// if the Service has no UDP port for the server port. Returns nil if that port is privileged on an unprivileged pod.
+func (configgen *ConfigGeneratorImpl) buildGatewayQUICListener(builder *ListenerBuilder, opts buildListenerOpts,
+	servers []*networking.Server, serverPort uint32, si *model.ServiceInstance, proxyConfig *meshconfig.ProxyConfig) (*listener.Listener, error) {
+	if udpInstance := gatewayServiceInstance(builder.node, serverPort, true); udpInstance != nil && udpInstance.Endpoint != nil {
+		si = udpInstance
+		opts.port = &model.Port{Port: int(si.Endpoint.EndpointPort)}
+	} else if len(builder.node.ServiceInstances) > 0 {
+		log.Warnf("buildGatewayQUICListener: no UDP service port %d for node %s, QUIC is not reachable through the gateway service",
+			serverPort, builder.node.ID)
+	}
+	if builder.node.Metadata.UnprivilegedPod != "" && opts.port.Port < 1024 {
+		log.Warnf("buildGatewayQUICListener: skipping privileged gateway port %d for node %s as it is an unprivileged pod",
+			opts.port.Port, builder.node.ID)
+		return nil, nil
+	}
+	mergedGateway := builder.node.MergedGateway
+	filterChainOpts := make([]*filterChainOpts, 0, len(servers))
+	filterChains := make([]istionetworking.FilterChain, 0, len(servers))
+	for _, server := range servers {
+		if !gateway.IsHTTP3Server(server) {
+			continue
+		}
+		chainOpts := configgen.createGatewayHTTPFilterChainOpts(builder.node, server.Port, server,
+			mergedGateway.TLSServerInfo[server].RouteName, proxyConfig)
+		chainOpts.httpOpts.http3 = true
+		// QUIC negotiates the HTTP/3 ALPN itself, the ALPN of the HTTPS listener doesn't apply.
+		if chainOpts.tlsContext != nil && chainOpts.tlsContext.CommonTlsContext != nil {
+			chainOpts.tlsContext.CommonTlsContext.AlpnProtocols = nil
+		}
+		filterChainOpts = append(filterChainOpts, chainOpts)
+		filterChains = append(filterChains, istionetworking.FilterChain{
+			ListenerProtocol:   istionetworking.ListenerProtocolHTTP,
+			IstioMutualGateway: server.Tls.Mode == networking.ServerTLSSettings_ISTIO_MUTUAL,
+		})
+	}
+	opts.filterChainOpts = filterChainOpts
+
+	l := buildListener(opts, core.TrafficDirection_OUTBOUND)
+	l.Name = quicListenerPrefix + l.Name
+	l.Address.GetSocketAddress().Protocol = core.SocketAddress_UDP
+	// The server names are read from the QUIC handshake, so no listener filter is needed.
+	l.ListenerFilters = nil
+	// Envoy relies on the kernel to dispatch the QUIC connections of the port to the same worker.
+	l.ReusePort = true
+	l.UdpListenerConfig = &listener.UdpListenerConfig{
+		UdpListenerName: quicListenerName,
+		ConfigType: &listener.UdpListenerConfig_TypedConfig{
+			TypedConfig: util.MessageToAny(&listener.QuicProtocolOptions{}),
+		},
+	}
+	for i, chain := range filterChainOpts {
+		l.FilterChains[i].TransportSocket = &core.TransportSocket{
+			Name: util.EnvoyQUICSocketName,
+			ConfigType: &core.TransportSocket_TypedConfig{TypedConfig: util.MessageToAny(&quic.QuicDownstreamTransport{
+				DownstreamTlsContext: chain.tlsContext,
+			})},
+		}
+	}
+
+	mutable := &istionetworking.MutableObjects{
+		Listener:     l,
+		FilterChains: filterChains,
+	}
+	pluginParams := &plugin.InputParams{
+		ListenerProtocol: istionetworking.ListenerProtocolHTTP,
+		Node:             builder.node,
+		Push:             builder.push,
+		ServiceInstance:  si,
+	}
+	for _, p := range configgen.Plugins {
+		if err := p.OnOutboundListener(pluginParams, mutable); err != nil {
+			log.Warn("buildGatewayQUICListener: failed to build listener for gateway: ", err.Error())
+		}
+	}
+	if err := buildCompleteFilterChain(mutable, opts); err != nil {
+		return l, err
+	}
+	return mutable.Listener, nil
+}
+
+// buildAltSvcHeader returns the alt-svc header advertising HTTP/3 on the given gateway port.
+func buildAltSvcHeader(port uint32) *core.HeaderValueOption {
+	return &core.HeaderValueOption{
+		Header: &core.HeaderValue{
+			Key:   altSvcHeader,
+			Value: fmt.Sprintf(`h3=":%d"; ma=%d,h3-29=":%d"; ma=%d`, port, altSvcMaxAge, port, altSvcMaxAge),
+		},
+		Append: proto.BoolFalse,
+	}
+}
+
 func buildNameToServiceMapForHTTPRoutes(node *model.Proxy, push *model.PushContext,
 	virtualService config.Config) map[host.Name]*model.Service {
 	vs := virtualService.Spec.(*networking.VirtualService)
@@ -354,6 +480,9 @@ func (configgen *ConfigGeneratorImpl) buildGatewayHTTPRouteConfig(node *model.Pr
 		virtualHosts = make([]*route.VirtualHost, 0, len(vHostDedupMap))
 		for _, v := range vHostDedupMap {
 			v.Routes = istio_route.CombineVHostRoutes(v.Routes)
+			if rateLimit := merged.RateLimits[routeName]; rateLimit != nil {
+				v.RateLimits = buildRateLimits(rateLimit)
+			}
 			virtualHosts = append(virtualHosts, v)
 		}
 	}
@@ -366,6 +495,11 @@ func (configgen *ConfigGeneratorImpl) buildGatewayHTTPRouteConfig(node *model.Pr
 		VirtualHosts:     virtualHosts,
 		ValidateClusters: proto.BoolFalse,
 	}
+	// Advertise the QUIC listener of HTTP3 servers, so that clients connected over TCP can upgrade to HTTP/3.
+	// All the servers of a HTTPS route share the same protocol.
+	if features.EnableQUICListeners && gateway.IsHTTP3Server(servers[0]) {
+		routeCfg.ResponseHeadersToAdd = append(routeCfg.ResponseHeadersToAdd, buildAltSvcHeader(uint32(port)))
+	}
 
 	return routeCfg
 }
@@ -373,7 +507,7 @@ func (configgen *ConfigGeneratorImpl) buildGatewayHTTPRouteConfig(node *model.Pr
 // builds a HTTP connection manager for servers of type HTTP or HTTPS (mode: simple/mutual)
 func (configgen *ConfigGeneratorImpl) createGatewayHTTPFilterChainOpts(node *model.Proxy, port *networking.Port, server *networking.Server,
 	routeName string, proxyConfig *meshconfig.ProxyConfig) *filterChainOpts {
-	serverProto := protocol.Parse(port.Protocol)
+	serverProto := protocol.ParseGatewayProtocol(port.Protocol)
 
 	httpProtoOpts := &core.Http1ProtocolOptions{}
 
@@ -415,6 +549,7 @@ func (configgen *ConfigGeneratorImpl) createGatewayHTTPFilterChainOpts(node *mod
 					HttpProtocolOptions: httpProtoOpts,
 				},
 				addGRPCWebFilter: serverProto == protocol.GRPCWeb,
+				rateLimit:        node.MergedGateway.RateLimits[routeName],
 			},
 		}
 	}
@@ -446,6 +581,7 @@ func (configgen *ConfigGeneratorImpl) createGatewayHTTPFilterChainOpts(node *mod
 			},
 			addGRPCWebFilter: serverProto == protocol.GRPCWeb,
 			statPrefix:       server.Name,
+			rateLimit:        node.MergedGateway.RateLimits[routeName],
 		},
 	}
 }
@@ -15,6 +15,7 @@
 package v1alpha3_test
 
 import (
+	"strings"
 	"testing"
 
 	"istio.io/istio/pilot/pkg/model"
@@ -798,16 +799,131 @@ spec:
 	})
 }
 
+func TestGatewayProxyProtocol(t *testing.T) {
+	httpServer := `port:
+  number: 80
+  name: http
+  protocol: HTTP
+hosts:
+- "foo.bar"`
+	tcpServer := `port:
+  number: 90
+  name: tcp
+  protocol: TCP
+hosts:
+- "*"`
+	routes := `apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: vs
+spec:
+  hosts:
+  - "*"
+  gateways:
+  - gateway
+  http:
+  - route:
+    - destination:
+        host: a
+  tcp:
+  - route:
+    - destination:
+        host: b
+---
+`
+	proxyProtocol := `apiVersion: networking.istio.io/v1alpha3
+kind: EnvoyFilter
+metadata:
+  name: proxy-protocol
+spec:
+  workloadSelector:
+    labels:
+      istio: ingressgateway
+  configPatches:
+  - applyTo: LISTENER
+    patch:
+      operation: MERGE
+      value:
+        listener_filters:
+        - name: envoy.filters.listener.proxy_protocol
+---
+`
+	runGatewayTest(t,
+		simulationTest{
+			name:   "without proxy protocol",
+			config: createGateway("gateway", "", httpServer, tcpServer) + routes,
+			calls: []simulation.Expect{
+				{
+					"http",
+					simulation.Call{Port: 80, HostHeader: "foo.bar", Protocol: simulation.HTTP},
+					simulation.Result{ClusterMatched: "outbound|80||a.default"},
+				},
+				{
+					"http with preamble",
+					simulation.Call{Port: 80, HostHeader: "foo.bar", Protocol: simulation.HTTP, ProxyProtocol: simulation.ProxyProtocolV1},
+					simulation.Result{Error: simulation.ErrProtocolError},
+				},
+				{
+					"tcp with preamble",
+					// The preamble is forwarded to the upstream as part of the stream
+					simulation.Call{Port: 90, Protocol: simulation.TCP, ProxyProtocol: simulation.ProxyProtocolV2},
+					simulation.Result{ClusterMatched: "outbound|90||b.default"},
+				},
+			},
+		},
+		simulationTest{
+			name:   "with proxy protocol",
+			config: createGateway("gateway", "", httpServer, tcpServer) + routes + proxyProtocol,
+			calls: []simulation.Expect{
+				{
+					"http",
+					simulation.Call{Port: 80, HostHeader: "foo.bar", Protocol: simulation.HTTP},
+					simulation.Result{Error: simulation.ErrProxyProtocol},
+				},
+				{
+					"http with v1 preamble",
+					simulation.Call{Port: 80, HostHeader: "foo.bar", Protocol: simulation.HTTP, ProxyProtocol: simulation.ProxyProtocolV1},
+					simulation.Result{ClusterMatched: "outbound|80||a.default"},
+				},
+				{
+					"http with v2 preamble",
+					simulation.Call{Port: 80, HostHeader: "foo.bar", Protocol: simulation.HTTP, ProxyProtocol: simulation.ProxyProtocolV2},
+					simulation.Result{ClusterMatched: "outbound|80||a.default"},
+				},
+				{
+					"tcp",
+					simulation.Call{Port: 90, Protocol: simulation.TCP},
+					simulation.Result{Error: simulation.ErrProxyProtocol},
+				},
+				{
+					"tcp with preamble",
+					simulation.Call{Port: 90, Protocol: simulation.TCP, ProxyProtocol: simulation.ProxyProtocolV2},
+					simulation.Result{ClusterMatched: "outbound|90||b.default"},
+				},
+			},
+		},
+	)
+}
+
 type simulationTest struct {
 	name       string
 	config     string
 	kubeConfig string
 	// skipValidation disables validation of XDS resources. Should be used only when we expect a failure (regression catching)
 	skipValidation bool
-	calls          []simulation.Expect
+	// coverage reports filter chains that were not matched by any call. This can also be enabled for all
+	// tests with SIMULATION_COVERAGE. The report is only shown in verbose output.
+	coverage bool
+	// parallel evaluates the calls concurrently. This is useful for tests with a large number of calls.
+	parallel bool
+	calls    []simulation.Expect
 }
 
-var debugMode = env.RegisterBoolVar("SIMULATION_DEBUG", true, "if enabled, will dump verbose output").Get()
+var (
+	debugMode    = env.RegisterBoolVar("SIMULATION_DEBUG", true, "if enabled, will dump verbose output").Get()
+	coverageMode = env.RegisterBoolVar("SIMULATION_COVERAGE", false,
+		"if enabled, will report filter chains not matched by any call").Get()
+)
 
 func runGatewayTest(t *testing.T, cases ...simulationTest) {
 	for _, tt := range cases {
@@ -827,7 +943,18 @@ func runSimulationTest(t *testing.T, proxy *model.Proxy, o xds.FakeOptions, tt s
 		o.KubernetesObjectString = tt.kubeConfig
 		s := xds.NewFakeDiscoveryServer(t, o)
 		sim := simulation.NewSimulation(t, s, s.SetupProxy(proxy))
-		sim.RunExpectations(tt.calls)
+		if tt.parallel {
+			sim.RunExpectationsParallel(tt.calls)
+		} else {
+			sim.RunExpectations(tt.calls)
+		}
+		if tt.coverage || coverageMode {
+			if unmatched := sim.UnmatchedFilterChains(); len(unmatched) > 0 {
+				t.Logf("%d filter chains not matched by any call:\n%s", len(unmatched), strings.Join(unmatched, "\n"))
+			} else {
+				t.Logf("all filter chains matched")
+			}
+		}
 		if t.Failed() && debugMode {
 			t.Log(xdstest.MapKeys(xdstest.ExtractClusters(sim.Clusters)))
 			t.Log(xdstest.ExtractListenerNames(sim.Listeners))
@@ -17,6 +17,7 @@ package v1alpha3
 import (
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -30,6 +31,7 @@ import (
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/features"
 	pilot_model "istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/plugin"
 	"istio.io/istio/pilot/pkg/networking/util"
@@ -37,6 +39,7 @@ import (
 	"istio.io/istio/pilot/test/xdstest"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/protocol"
 	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/istio/pkg/config/visibility"
 	"istio.io/istio/pkg/proto"
@@ -1512,6 +1515,21 @@ func TestBuildGatewayListeners(t *testing.T) {
 			},
 			[]string{"0.0.0.0_80", "0.0.0.0_8080"},
 		},
+		{
+			"http3 server",
+			&pilot_model.Proxy{},
+			&networking.Gateway{
+				Servers: []*networking.Server{
+					{
+						Hosts: []string{"example.com"},
+						Port:  &networking.Port{Name: "http3", Number: 443, Protocol: "HTTP3"},
+						Tls:   &networking.ServerTLSSettings{Mode: networking.ServerTLSSettings_SIMPLE, CredentialName: "example-cert"},
+					},
+				},
+			},
+			// QUIC listeners are disabled by default.
+			[]string{"0.0.0.0_443"},
+		},
 	}
 
 	for _, tt := range cases {
@@ -1537,6 +1555,75 @@ func TestBuildGatewayListeners(t *testing.T) {
 	}
 }
 
+func TestBuildGatewayQUICListener(t *testing.T) {
+	defer func(enabled bool) { features.EnableQUICListeners = enabled }(features.EnableQUICListeners)
+	features.EnableQUICListeners = true
+
+	gw := &networking.Gateway{
+		Servers: []*networking.Server{
+			{
+				Hosts: []string{"example.com"},
+				Port:  &networking.Port{Name: "http3", Number: 443, Protocol: "HTTP3"},
+				Tls:   &networking.ServerTLSSettings{Mode: networking.ServerTLSSettings_SIMPLE, CredentialName: "example-cert"},
+			},
+		},
+	}
+	cg := NewConfigGenTest(t, TestOptions{
+		Configs: []config.Config{{
+			Meta: config.Meta{GroupVersionKind: gvk.Gateway, Name: "gw", Namespace: "default"},
+			Spec: gw,
+		}},
+	})
+	proxy := cg.SetupProxy(&proxyGateway)
+	proxy.Metadata = &proxyGatewayMetadata
+	builder := cg.ConfigGen.buildGatewayListeners(&ListenerBuilder{node: proxy, push: cg.PushContext()})
+
+	l := xdstest.ExtractListener("udp_0.0.0.0_443", builder.gatewayListeners)
+	if l == nil {
+		t.Fatalf("expected a QUIC listener, got %v", xdstest.ExtractListenerNames(builder.gatewayListeners))
+	}
+	if l.Address.GetSocketAddress().Protocol != core.SocketAddress_UDP || l.UdpListenerConfig.GetUdpListenerName() != quicListenerName {
+		t.Errorf("expected a UDP QUIC listener, got %v", l)
+	}
+	if len(l.FilterChains) != 1 || l.FilterChains[0].TransportSocket.GetName() != util.EnvoyQUICSocketName {
+		t.Fatalf("expected a single filter chain with QUIC transport socket, got %v", l.FilterChains)
+	}
+	if codec := xdstest.ExtractHTTPConnectionManager(t, l.FilterChains[0]).CodecType; codec != hcm.HttpConnectionManager_HTTP3 {
+		t.Errorf("expected HTTP3 codec, got %v", codec)
+	}
+	https := xdstest.ExtractListener("0.0.0.0_443", builder.gatewayListeners)
+	if codec := xdstest.ExtractHTTPConnectionManager(t, https.FilterChains[0]).CodecType; codec != hcm.HttpConnectionManager_AUTO {
+		t.Errorf("expected AUTO codec on the HTTPS listener, got %v", codec)
+	}
+
+	rc := cg.ConfigGen.buildGatewayHTTPRouteConfig(proxy, cg.PushContext(), "https.443.http3.gw.default")
+	if rc == nil || len(rc.ResponseHeadersToAdd) != 1 || rc.ResponseHeadersToAdd[0].Header.Key != altSvcHeader ||
+		!strings.HasPrefix(rc.ResponseHeadersToAdd[0].Header.Value, `h3=":443"`) {
+		t.Errorf("expected alt-svc header advertising HTTP/3 on port 443, got %v", rc)
+	}
+
+	// The QUIC listener binds to the target port of the UDP port of the gateway Service.
+	svc := &pilot_model.Service{Hostname: "gateway"}
+	proxy.ServiceInstances = []*pilot_model.ServiceInstance{
+		{
+			Service:     svc,
+			ServicePort: &pilot_model.Port{Name: "https", Port: 443, Protocol: protocol.HTTPS},
+			Endpoint:    &pilot_model.IstioEndpoint{EndpointPort: 8443},
+		},
+		{
+			Service:     svc,
+			ServicePort: &pilot_model.Port{Name: "quic", Port: 443, Protocol: protocol.UDP},
+			Endpoint:    &pilot_model.IstioEndpoint{EndpointPort: 8444},
+		},
+	}
+	builder = cg.ConfigGen.buildGatewayListeners(&ListenerBuilder{node: proxy, push: cg.PushContext()})
+	listeners := xdstest.ExtractListenerNames(builder.gatewayListeners)
+	sort.Strings(listeners)
+	if want := []string{"0.0.0.0_8443", "udp_0.0.0.0_8444"}; !reflect.DeepEqual(listeners, want) {
+		t.Errorf("expected listeners %v, got %v", want, listeners)
+	}
+}
+
 func TestBuildNameToServiceMapForHttpRoutes(t *testing.T) {
 	virtualServiceSpec := &networking.VirtualService{
 		Hosts: []string{"*.example.org"},
@@ -0,0 +1,367 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha3
+
+import (
+	"net"
+	"strconv"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	tcp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
+	wellknown "github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/wrappers"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	istionetworking "istio.io/istio/pilot/pkg/networking"
+	istio_route "istio.io/istio/pilot/pkg/networking/core/v1alpha3/route"
+	"istio.io/istio/pilot/pkg/networking/plugin"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/security/authn/factory"
+	authn_utils "istio.io/istio/pilot/pkg/security/authn/utils"
+	"istio.io/istio/pilot/pkg/security/trustdomain"
+	xdsfilters "istio.io/istio/pilot/pkg/xds/filters"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/routing"
+	"istio.io/istio/pkg/proto"
+	"istio.io/pkg/log"
+)
+
+const (
+	// HBONEInboundListenPort is the port on which the sidecars accept the mTLS traffic tunneled over HTTP CONNECT.
+	HBONEInboundListenPort = 15008
+
+	// virtualInboundHBONEFilterChainName is the name of the filter chain terminating the HBONE tunnels
+	virtualInboundHBONEFilterChainName = "virtualInbound-hbone"
+
+	// hboneRouteName is the name of the route configuration of the HBONE tunnel terminator
+	hboneRouteName = "inbound-hbone"
+
+	// hboneVirtualHostPrefix is the prefix of the virtual hosts of the HBONE tunnel terminator
+	hboneVirtualHostPrefix = "inbound|hbone|"
+
+	// connectUpgradeType is the upgrade type terminating the CONNECT requests
+	connectUpgradeType = "CONNECT"
+)
+
+// hboneTunnelsService returns true if the TCP traffic to the service port is tunneled over HBONE. Only the traffic
+// to the ports of the services of the mesh with strict mutual TLS, which are load balanced by the proxies, is
+// tunneled: all their endpoints have a sidecar terminating the tunnels. The endpoints of other networks are
+// reached through their gateways, which do not terminate the tunnels, so nothing is tunneled in multi-network meshes.
+func hboneTunnelsService(push *model.PushContext, service *model.Service, port *model.Port) bool {
+	if !features.EnableHBONE || service == nil || port == nil {
+		return false
+	}
+	if service.MeshExternal || port.Protocol != protocol.TCP || service.ResolutionForPort(port.Port) != model.ClientSideLB {
+		return false
+	}
+	if len(push.NetworkGateways()) > 0 {
+		return false
+	}
+	return push.BestEffortInferServiceMTLSMode(service, port) == model.MTLSStrict
+}
+
+// buildOutboundHBONEClusters returns the clusters tunneling the TCP traffic to the service port over HBONE, for the
+// service and each subset of its destination rule. The endpoints of the clusters are the HBONE ports of the
+// endpoints of the service, reached with Istio mutual TLS and HTTP/2, and the traffic policies of the destination
+// rule do not apply to the tunnels.
+func (cb *ClusterBuilder) buildOutboundHBONEClusters(service *model.Service, port *model.Port) []*cluster.Cluster {
+	if !hboneTunnelsService(cb.push, service, port) {
+		return nil
+	}
+	subsets := []string{""}
+	for _, subset := range castDestinationRuleOrDefault(cb.push.DestinationRule(cb.proxy, service)).Subsets {
+		subsets = append(subsets, subset.Name)
+	}
+	serviceAccounts := cb.push.ServiceAccounts[service.Hostname][port.Port]
+	clusters := make([]*cluster.Cluster, 0, len(subsets))
+	for _, subset := range subsets {
+		clusterName := model.BuildSubsetKey(model.TrafficDirectionOutboundHBONE, subset, service.Hostname, port.Port)
+		c := cb.buildDefaultCluster(clusterName, cluster.Cluster_EDS, nil, model.TrafficDirectionOutbound, port, service, nil)
+		if c == nil {
+			continue
+		}
+		maybeApplyEdsConfig(c)
+		setH2Options(c)
+		opts := buildClusterOpts{
+			mesh:      cb.push.Mesh,
+			cluster:   c,
+			port:      port,
+			direction: model.TrafficDirectionOutbound,
+			proxy:     cb.proxy,
+		}
+		sni := model.BuildDNSSrvSubsetKey(model.TrafficDirectionOutbound, subset, service.Hostname, port.Port)
+		applyUpstreamTLSSettings(&opts, buildIstioMutualTLS(serviceAccounts, sni, cb.proxy), userSupplied)
+		clusters = append(clusters, c)
+	}
+	return clusters
+}
+
+// applyHBONETunnel tunnels the traffic of the TCP proxy over HBONE, if the traffic to all its clusters is tunneled.
+// The CONNECT requests of the proxy have a single authority, so the weighted clusters must all be subsets of the
+// same service port.
+func applyHBONETunnel(push *model.PushContext, node *model.Proxy, tcpProxy *tcp.TcpProxy) {
+	switch specifier := tcpProxy.ClusterSpecifier.(type) {
+	case *tcp.TcpProxy_Cluster:
+		if clusterName, authority := hboneTunnelCluster(push, node, specifier.Cluster); clusterName != "" {
+			tcpProxy.ClusterSpecifier = &tcp.TcpProxy_Cluster{Cluster: clusterName}
+			tcpProxy.TunnelingConfig = &tcp.TcpProxy_TunnelingConfig{Hostname: authority}
+		}
+	case *tcp.TcpProxy_WeightedClusters:
+		weighted := specifier.WeightedClusters.GetClusters()
+		if len(weighted) == 0 {
+			return
+		}
+		clusterNames := make([]string, 0, len(weighted))
+		tunnelAuthority := ""
+		for _, w := range weighted {
+			clusterName, authority := hboneTunnelCluster(push, node, w.Name)
+			if clusterName == "" || (tunnelAuthority != "" && authority != tunnelAuthority) {
+				return
+			}
+			clusterNames = append(clusterNames, clusterName)
+			tunnelAuthority = authority
+		}
+		for i, w := range weighted {
+			w.Name = clusterNames[i]
+		}
+		tcpProxy.TunnelingConfig = &tcp.TcpProxy_TunnelingConfig{Hostname: tunnelAuthority}
+	}
+}
+
+// hboneTunnelCluster returns the HBONE cluster tunneling the traffic to the outbound cluster, and the authority of
+// the CONNECT requests, <service-host>:<service-port>. Empty strings are returned if the traffic is not tunneled.
+func hboneTunnelCluster(push *model.PushContext, node *model.Proxy, clusterName string) (string, string) {
+	direction, subset, hostname, port := model.ParseSubsetKey(clusterName)
+	// The clusters dedicated to the routes have no HBONE counterpart.
+	if direction != model.TrafficDirectionOutbound || subset != routing.BaseSubset(subset) {
+		return "", ""
+	}
+	service := push.ServiceForHostname(node, hostname)
+	if service == nil {
+		return "", ""
+	}
+	servicePort, f := service.Ports.GetByPort(port)
+	if !f || !hboneTunnelsService(push, service, servicePort) {
+		return "", ""
+	}
+	return model.BuildSubsetKey(model.TrafficDirectionOutboundHBONE, subset, hostname, port), domainName(string(hostname), port)
+}
+
+// hboneCollapsedPorts returns the workload ports whose inbound filter chains are collapsed into the HBONE filter
+// chain: the ports of the services whose TCP traffic is tunneled, when mutual TLS is strict for the whole workload.
+// The connections which are not tunneled to these ports, such as the ones of the proxies not upgraded yet, are
+// still authenticated and authorized by the passthrough filter chains.
+func hboneCollapsedPorts(node *model.Proxy, push *model.PushContext) map[uint32]bool {
+	if !features.EnableHBONE || node.GetInterceptionMode() == model.InterceptionNone {
+		return nil
+	}
+	applier := factory.NewPolicyApplier(push, node.Metadata.Namespace, labels.Collection{node.Metadata.Labels})
+	// The default passthrough filter chains apply the mode of the workload, so the port level modes must match it.
+	if applier.MutualTLSMode(0, node) != model.MTLSStrict {
+		return nil
+	}
+	collapsed := make(map[uint32]bool)
+	for _, instance := range node.ServiceInstances {
+		port := instance.Endpoint.EndpointPort
+		tunneled := hboneTunnelsService(push, instance.Service, instance.ServicePort) &&
+			applier.MutualTLSMode(port, node) == model.MTLSStrict
+		// All the services on the port must be tunneled.
+		if c, f := collapsed[port]; !f || c {
+			collapsed[port] = tunneled
+		}
+	}
+	for port, c := range collapsed {
+		if !c {
+			delete(collapsed, port)
+		}
+	}
+	return collapsed
+}
+
+// buildInboundHBONEFilterChain returns the filter chain of the virtual inbound listener terminating the mTLS traffic
+// tunneled over HTTP CONNECT to the HBONE port. The tunnel always requires mutual TLS, whatever the mode set by the
+// PeerAuthentication policies on the ports of the workload, and the authorization policies apply to the CONNECT
+// requests as for the passthrough traffic. Each tunneled connection is forwarded to the inbound cluster of the port
+// named by the authority of the request, either <service-host>:<service-port> or <workload-ip>:<workload-port>.
+// Nil is returned when HBONE is disabled or the inbound traffic is not captured.
+func buildInboundHBONEFilterChain(configgen *ConfigGeneratorImpl, node *model.Proxy, push *model.PushContext) *listener.FilterChain {
+	if !features.EnableHBONE || node.GetInterceptionMode() == model.InterceptionNone {
+		return nil
+	}
+
+	in := &plugin.InputParams{
+		ListenerProtocol: istionetworking.ListenerProtocolHTTP,
+		Node:             node,
+		ServiceInstance:  dummyServiceInstance,
+		Push:             push,
+	}
+	chains := authn_utils.BuildInboundFilterChain(model.MTLSStrict, node, istionetworking.ListenerProtocolHTTP,
+		hboneTrustDomains(push))
+	chain := chains[0]
+	chain.ListenerProtocol = istionetworking.ListenerProtocolHTTP
+	mutable := &istionetworking.MutableObjects{
+		FilterChains: []istionetworking.FilterChain{chain},
+	}
+	// Call plugins to install authn/authz policies.
+	for _, p := range configgen.Plugins {
+		if err := p.OnInboundPassthrough(in, mutable); err != nil {
+			log.Errorf("Build inbound HBONE filter chain error: %v", err)
+		}
+	}
+	chain = mutable.FilterChains[0]
+
+	listenerOpts := buildListenerOpts{
+		push:  push,
+		proxy: node,
+		port: &model.Port{
+			Name:     "hbone",
+			Port:     HBONEInboundListenPort,
+			Protocol: protocol.HTTP2,
+		},
+	}
+	httpOpts := &httpListenerOpts{
+		routeConfig:      buildHBONERouteConfig(configgen, node),
+		useRemoteAddress: false,
+		statPrefix:       virtualInboundHBONEFilterChainName,
+		connectionManager: &hcm.HttpConnectionManager{
+			Http2ProtocolOptions: &core.Http2ProtocolOptions{},
+			ServerName:           EnvoyServerName,
+		},
+	}
+	connectionManager := buildHTTPConnectionManager(listenerOpts, httpOpts, chain.HTTP)
+	connectionManager.UpgradeConfigs = append(connectionManager.UpgradeConfigs,
+		&hcm.HttpConnectionManager_UpgradeConfig{UpgradeType: connectUpgradeType})
+
+	return &listener.FilterChain{
+		Name: virtualInboundHBONEFilterChainName,
+		FilterChainMatch: &listener.FilterChainMatch{
+			DestinationPort:   &wrappers.UInt32Value{Value: HBONEInboundListenPort},
+			TransportProtocol: xdsfilters.TLSTransportProtocol,
+		},
+		Filters: []*listener.Filter{{
+			Name:       wellknown.HTTPConnectionManager,
+			ConfigType: &listener.Filter_TypedConfig{TypedConfig: util.MessageToAny(connectionManager)},
+		}},
+		TransportSocket: &core.TransportSocket{
+			Name:       util.EnvoyTLSSocketName,
+			ConfigType: &core.TransportSocket_TypedConfig{TypedConfig: util.MessageToAny(chain.TLSContext)},
+		},
+	}
+}
+
+// buildHBONERouteConfig returns the routes of the HBONE tunnel terminator, with a virtual host per workload port.
+// As for the inbound clusters, the first service instance on a workload port is the one whose cluster is used, and
+// the workload ports are the ones of the ingress listeners of the Sidecar, if any.
+func buildHBONERouteConfig(configgen *ConfigGeneratorImpl, node *model.Proxy) *route.RouteConfiguration {
+	byPort := make(map[uint32]*route.VirtualHost)
+	seen := make(map[string]bool)
+	var virtualHosts []*route.VirtualHost
+	addVirtualHost := func(port uint32, clusterName string, addr string) {
+		vhost := &route.VirtualHost{
+			Name:   hboneVirtualHostPrefix + strconv.Itoa(int(port)), // Format: "inbound|hbone|%d"
+			Routes: []*route.Route{buildHBONERoute(clusterName)},
+		}
+		byPort[port] = vhost
+		virtualHosts = append(virtualHosts, vhost)
+		if addr != "" {
+			vhost.Domains = appendHBONEDomain(vhost.Domains, seen, net.JoinHostPort(addr, strconv.Itoa(int(port))))
+		}
+	}
+
+	if sidecarScope := node.SidecarScope; sidecarScope != nil && sidecarScope.HasCustomIngressListeners {
+		for _, ingressListener := range sidecarScope.Sidecar.Ingress {
+			port := ingressListener.Port.Number
+			if protocol.Parse(ingressListener.Port.Protocol) == protocol.UDP || byPort[port] != nil {
+				continue
+			}
+			instance := configgen.findOrCreateServiceInstance(node.ServiceInstances, ingressListener,
+				sidecarScope.Name, sidecarScope.Namespace)
+			clusterName := util.BuildInboundSubsetKey(node, ingressListener.Port.Name,
+				instance.Service.Hostname, int(port), int(port))
+			addr := ""
+			if len(node.IPAddresses) > 0 {
+				addr = node.IPAddresses[0]
+			}
+			addVirtualHost(port, clusterName, addr)
+		}
+	} else {
+		for _, instance := range node.ServiceInstances {
+			port := instance.Endpoint.EndpointPort
+			if instance.ServicePort.Protocol == protocol.UDP || byPort[port] != nil {
+				continue
+			}
+			clusterName := util.BuildInboundSubsetKey(node, instance.ServicePort.Name,
+				instance.Service.Hostname, instance.ServicePort.Port, int(port))
+			addVirtualHost(port, clusterName, instance.Endpoint.Address)
+		}
+	}
+	for _, instance := range node.ServiceInstances {
+		if vhost := byPort[instance.Endpoint.EndpointPort]; vhost != nil && instance.ServicePort.Protocol != protocol.UDP {
+			vhost.Domains = appendHBONEDomain(vhost.Domains, seen,
+				domainName(string(instance.Service.Hostname), instance.ServicePort.Port))
+		}
+	}
+
+	return &route.RouteConfiguration{
+		Name:             hboneRouteName,
+		VirtualHosts:     virtualHosts,
+		ValidateClusters: proto.BoolFalse,
+	}
+}
+
+// buildHBONERoute returns the route terminating the CONNECT requests and forwarding their payload to the cluster.
+func buildHBONERoute(clusterName string) *route.Route {
+	return &route.Route{
+		Name: istio_route.DefaultRouteName,
+		Match: &route.RouteMatch{
+			PathSpecifier: &route.RouteMatch_ConnectMatcher_{ConnectMatcher: &route.RouteMatch_ConnectMatcher{}},
+		},
+		Action: &route.Route_Route{
+			Route: &route.RouteAction{
+				ClusterSpecifier: &route.RouteAction_Cluster{Cluster: clusterName},
+				// The tunnels are long lived connections, which must not be closed by the route timeout.
+				Timeout: ptypes.DurationProto(0),
+				UpgradeConfigs: []*route.RouteAction_UpgradeConfig{{
+					UpgradeType:   connectUpgradeType,
+					ConnectConfig: &route.RouteAction_UpgradeConfig_ConnectConfig{},
+				}},
+			},
+		},
+	}
+}
+
+// appendHBONEDomain appends the domain if no virtual host has it already, as Envoy rejects duplicate domains.
+func appendHBONEDomain(domains []string, seen map[string]bool, domain string) []string {
+	if seen[domain] {
+		return domains
+	}
+	seen[domain] = true
+	return append(domains, domain)
+}
+
+// hboneTrustDomains returns the trust domains of the peers allowed to open HBONE tunnels.
+func hboneTrustDomains(push *model.PushContext) []string {
+	if features.SkipValidateTrustDomain.Get() {
+		return nil
+	}
+	return append([]string{push.Mesh.TrustDomain}, trustdomain.MeshAliases(push.Mesh)...)
+}
@@ -0,0 +1,250 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha3
+
+import (
+	"reflect"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/test/xdstest"
+	"istio.io/istio/pkg/config/protocol"
+)
+
+const hboneServiceEntry = `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: tcp
+  namespace: default
+spec:
+  hosts:
+  - tcp.example.com
+  addresses:
+  - 10.0.0.1
+  ports:
+  - number: 9000
+    name: tcp
+    protocol: TCP
+  - number: 8080
+    name: http
+    protocol: HTTP
+  location: MESH_INTERNAL
+  resolution: STATIC
+  endpoints:
+  - address: 10.0.0.10
+    serviceAccount: tcp
+    labels:
+      version: v1
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: tcp
+  namespace: default
+spec:
+  host: tcp.example.com
+  subsets:
+  - name: v1
+    labels:
+      version: v1
+---
+`
+
+func TestOutboundHBONETunnel(t *testing.T) {
+	defaultValue := features.EnableHBONE
+	defer func() { features.EnableHBONE = defaultValue }()
+	features.EnableHBONE = true
+
+	cases := []struct {
+		name     string
+		config   string
+		tunneled bool
+	}{
+		{
+			name:     "strict",
+			config:   hboneServiceEntry + strictMode,
+			tunneled: true,
+		},
+		{
+			name:     "permissive",
+			config:   hboneServiceEntry,
+			tunneled: false,
+		},
+		{
+			name:     "disable",
+			config:   hboneServiceEntry + disableMode,
+			tunneled: false,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			cg := NewConfigGenTest(t, TestOptions{ConfigString: tt.config})
+			proxy := cg.SetupProxy(nil)
+
+			clusters := xdstest.ExtractClusters(cg.Clusters(proxy))
+			for _, name := range []string{"outbound-hbone|9000||tcp.example.com", "outbound-hbone|9000|v1|tcp.example.com"} {
+				c := clusters[name]
+				if !tt.tunneled {
+					if c != nil {
+						t.Fatalf("expected no cluster %s", name)
+					}
+					continue
+				}
+				if c == nil {
+					t.Fatalf("expected cluster %s, found %v", name, xdstest.MapKeys(clusters))
+				}
+				if c.GetEdsClusterConfig().GetServiceName() != name || c.Http2ProtocolOptions == nil || c.TransportSocket == nil {
+					t.Fatalf("expected an HTTP/2 EDS cluster with mutual TLS, found %v", c)
+				}
+			}
+			if c := clusters["outbound-hbone|8080||tcp.example.com"]; c != nil {
+				t.Fatalf("expected no HBONE cluster for the HTTP port")
+			}
+
+			l := xdstest.ExtractListener("10.0.0.1_9000", cg.Listeners(proxy))
+			if l == nil {
+				t.Fatalf("expected the outbound listener of the TCP port")
+			}
+			tcpProxy := xdstest.ExtractTCPProxy(t, l.FilterChains[len(l.FilterChains)-1])
+			cluster, hostname := "outbound|9000||tcp.example.com", ""
+			if tt.tunneled {
+				cluster, hostname = "outbound-hbone|9000||tcp.example.com", "tcp.example.com:9000"
+			}
+			if tcpProxy.GetCluster() != cluster || tcpProxy.GetTunnelingConfig().GetHostname() != hostname {
+				t.Fatalf("expected cluster %q tunneled to %q, found %q tunneled to %q", cluster, hostname,
+					tcpProxy.GetCluster(), tcpProxy.GetTunnelingConfig().GetHostname())
+			}
+		})
+	}
+}
+
+func TestHBONECollapsedInboundFilterChains(t *testing.T) {
+	defaultValue := features.EnableHBONE
+	defer func() { features.EnableHBONE = defaultValue }()
+
+	services := []*model.Service{
+		buildServiceWithPort("test1.com", 80, protocol.HTTP, tnow),
+		buildServiceWithPort("test2.com", 82, protocol.TCP, tnow),
+	}
+	instances := make([]*model.ServiceInstance, 0, len(services))
+	for _, s := range services {
+		instances = append(instances, &model.ServiceInstance{
+			Service: s,
+			Endpoint: &model.IstioEndpoint{
+				EndpointPort: uint32(s.Ports[0].Port),
+				Address:      "1.1.1.1",
+			},
+			ServicePort: s.Ports[0],
+		})
+	}
+	cases := []struct {
+		name   string
+		hbone  bool
+		config string
+		ports  map[uint32]bool
+	}{
+		{
+			name:   "hbone disabled",
+			hbone:  false,
+			config: strictMode,
+			ports:  map[uint32]bool{80: true, 82: true},
+		},
+		{
+			name:   "strict",
+			hbone:  true,
+			config: strictMode,
+			ports:  map[uint32]bool{80: true, 82: false, HBONEInboundListenPort: true},
+		},
+		{
+			name:   "permissive",
+			hbone:  true,
+			config: "",
+			ports:  map[uint32]bool{80: true, 82: true, HBONEInboundListenPort: true},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			features.EnableHBONE = tt.hbone
+			cg := NewConfigGenTest(t, TestOptions{
+				Services:     services,
+				Instances:    instances,
+				ConfigString: tt.config,
+			})
+			virtualInbound := xdstest.ExtractListener(VirtualInboundListenerName, cg.Listeners(cg.SetupProxy(nil)))
+			ports := map[uint32]bool{}
+			hbone := false
+			for _, fc := range virtualInbound.FilterChains {
+				if port := fc.GetFilterChainMatch().GetDestinationPort().GetValue(); port != 0 {
+					ports[port] = true
+				}
+				if fc.Name == virtualInboundHBONEFilterChainName {
+					hbone = true
+				}
+			}
+			for port, expected := range tt.ports {
+				if ports[port] != expected {
+					t.Fatalf("expected filter chains for port %d: %v, found ports %v", port, expected, ports)
+				}
+			}
+			if hbone != tt.hbone {
+				t.Fatalf("expected the HBONE filter chain: %v, found %v", tt.hbone, hbone)
+			}
+		})
+	}
+}
+
+func TestHBONERouteConfigIngressListeners(t *testing.T) {
+	sidecar := `
+apiVersion: networking.istio.io/v1alpha3
+kind: Sidecar
+metadata:
+  name: sidecar
+  namespace: default
+spec:
+  ingress:
+  - port:
+      number: 9080
+      protocol: HTTP
+      name: http
+    defaultEndpoint: 127.0.0.1:8080
+`
+	service := buildServiceWithPort("test.com", 80, protocol.HTTP, tnow)
+	instance := &model.ServiceInstance{
+		Service:     service,
+		ServicePort: service.Ports[0],
+		Endpoint: &model.IstioEndpoint{
+			EndpointPort: 9080,
+			Address:      "1.1.1.1",
+		},
+	}
+	cg := NewConfigGenTest(t, TestOptions{
+		Services:     []*model.Service{service},
+		Instances:    []*model.ServiceInstance{instance},
+		ConfigString: sidecar,
+	})
+	proxy := cg.SetupProxy(nil)
+	rc := buildHBONERouteConfig(cg.ConfigGen, proxy)
+	if len(rc.VirtualHosts) != 1 {
+		t.Fatalf("expected 1 virtual host, found %v", rc.VirtualHosts)
+	}
+	if expected := []string{"1.1.1.1:9080", "test.com:80"}; !reflect.DeepEqual(rc.VirtualHosts[0].Domains, expected) {
+		t.Fatalf("expected domains %v, found %v", expected, rc.VirtualHosts[0].Domains)
+	}
+	if expected := "inbound|9080||"; rc.VirtualHosts[0].Routes[0].GetRoute().GetCluster() != expected {
+		t.Fatalf("expected cluster %s, found %s", expected, rc.VirtualHosts[0].Routes[0].GetRoute().GetCluster())
+	}
+}
@@ -93,6 +93,9 @@ func (configgen *ConfigGeneratorImpl) buildSidecarInboundHTTPRouteConfig(
 		Domains: []string{"*"},
 		Routes:  []*route.Route{defaultRoute},
 	}
+	if rateLimit := inboundLocalRateLimit(node); rateLimit != nil {
+		inboundVHost.RateLimits = istio_route.BuildLocalRateLimitActions(rateLimit)
+	}
 
 	r := &route.RouteConfiguration{
 		Name:             clusterName,
@@ -379,7 +382,7 @@ func generateVirtualHostDomains(service *model.Service, port int, node *model.Pr
 	domains := []string{string(service.Hostname), domainName(string(service.Hostname), port)}
 	domains = append(domains, altHosts...)
 
-	if service.Resolution == model.Passthrough &&
+	if service.ResolutionForPort(port) == model.Passthrough &&
 		service.Attributes.ServiceRegistry == string(serviceregistry.Kubernetes) {
 		for _, domain := range domains {
 			domains = append(domains, wildcardDomainPrefix+domain)
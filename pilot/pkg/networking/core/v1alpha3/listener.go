@@ -17,6 +17,7 @@ package v1alpha3
 import (
 	"fmt"
 	"net"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
@@ -34,6 +35,7 @@ import (
 	tracing "github.com/envoyproxy/go-control-plane/envoy/type/tracing/v3"
 	xdstype "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	golangproto "github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/wrappers"
 
@@ -50,6 +52,7 @@ import (
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/routing"
 	"istio.io/istio/pkg/proto"
 	"istio.io/istio/pkg/util/gogo"
 	"istio.io/istio/pkg/util/protomarshal"
@@ -244,6 +247,7 @@ func (configgen *ConfigGeneratorImpl) BuildListeners(node *model.Proxy,
 		builder = configgen.buildGatewayListeners(builder)
 	}
 
+	builder.applyDownstreamTCPKeepalive()
 	builder.patchListeners()
 	return builder.getListeners()
 }
@@ -304,8 +308,13 @@ func (configgen *ConfigGeneratorImpl) buildSidecarInboundListeners(
 		//
 		//	The pilot will generate three listeners, the last one will use protocol sniffing.
 		//
+		collapsed := hboneCollapsedPorts(node, push)
 		for _, instance := range node.ServiceInstances {
 			endpoint := instance.Endpoint
+			// The tunneled traffic to the port is terminated by the HBONE filter chain.
+			if collapsed[endpoint.EndpointPort] {
+				continue
+			}
 			// Inbound listeners will be aggregated into a single virtual listener (port 15006)
 			// As a result, we don't need to worry about binding to the endpoint IP; we already know
 			// all traffic for these listeners is inbound.
@@ -434,6 +443,7 @@ func (configgen *ConfigGeneratorImpl) buildSidecarInboundHTTPListenerOptsForPort
 			},
 			ServerName: EnvoyServerName,
 		},
+		localRateLimit: inboundLocalRateLimit(node),
 	}
 	// See https://github.com/grpc/grpc-web/tree/master/net/grpc/gateway/examples/helloworld#configure-the-proxy
 	if pluginParams.ServiceInstance.ServicePort.Protocol.IsHTTP2() {
@@ -868,7 +878,7 @@ func (configgen *ConfigGeneratorImpl) buildSidecarOutboundListeners(node *model.
 					// Instead of generating a single 0.0.0.0:Port listener, generate a listener
 					// for each instance. HTTP services can happily reside on 0.0.0.0:PORT and use the
 					// wildcard route match to get to the appropriate IP through original dst clusters.
-					if features.EnableHeadlessService && bind == "" && service.Resolution == model.Passthrough &&
+					if features.EnableHeadlessService && bind == "" && service.ResolutionForPort(servicePort.Port) == model.Passthrough &&
 						saddress == constants.UnspecifiedIP && (servicePort.Protocol.IsTCP() || servicePort.Protocol.IsUnsupported()) {
 						instances := push.ServiceInstancesByPort(service, servicePort.Port, nil)
 						if service.Attributes.ServiceRegistry != string(serviceregistry.Kubernetes) && len(instances) == 0 && service.Attributes.LabelSelectors == nil {
@@ -928,6 +938,9 @@ func (configgen *ConfigGeneratorImpl) buildSidecarOutboundListeners(node *model.
 		configgen.appendListenerFallthroughRouteForCompleteListener(listener, node, push)
 	}
 	removeListenerFilterTimeout(tcpListeners)
+	if node.SidecarScope != nil {
+		applyProtocolDetection(tcpListeners, node.SidecarScope.ProtocolDetection)
+	}
 	return tcpListeners
 }
 
@@ -1538,6 +1551,12 @@ type httpListenerOpts struct {
 	// should be added.
 	addGRPCWebFilter bool
 	useRemoteAddress bool
+	// http3 specifies whether the connection manager serves HTTP/3 over QUIC.
+	http3 bool
+	// rateLimit is the global rate limiting of the routes, if any.
+	rateLimit *routing.RateLimit
+	// localRateLimit is the local rate limiting of all the requests of the listener, if any.
+	localRateLimit *routing.LocalRateLimit
 }
 
 // thriftListenerOpts are options for a Thrift listener
@@ -1592,6 +1611,14 @@ func buildHTTPConnectionManager(listenerOpts buildListenerOpts, httpOpts *httpLi
 	filters := make([]*hcm.HttpFilter, len(httpFilters))
 	copy(filters, httpFilters)
 
+	if filter := buildLocalRateLimitFilter(listenerOpts.push, httpOpts.localRateLimit); filter != nil {
+		filters = append(filters, filter)
+	}
+
+	if httpOpts.rateLimit != nil {
+		filters = append(filters, buildRateLimitFilter(httpOpts.rateLimit))
+	}
+
 	if httpOpts.addGRPCWebFilter {
 		filters = append(filters, xdsfilters.GrpcWeb)
 	}
@@ -1605,6 +1632,12 @@ func buildHTTPConnectionManager(listenerOpts buildListenerOpts, httpOpts *httpLi
 		filters = append(filters, xdsfilters.Alpn)
 	}
 
+	// Sidecars using on demand routes receive their virtual hosts with VHDS on first use.
+	if listenerOpts.class == ListenerClassSidecarOutbound && httpOpts.rds != "" &&
+		bool(listenerOpts.proxy.Metadata.OnDemandRoutes) {
+		filters = append(filters, xdsfilters.OnDemand)
+	}
+
 	filters = append(filters, xdsfilters.Cors, xdsfilters.Fault, xdsfilters.Router)
 
 	if httpOpts.connectionManager == nil {
@@ -1613,6 +1646,9 @@ func buildHTTPConnectionManager(listenerOpts buildListenerOpts, httpOpts *httpLi
 
 	connectionManager := httpOpts.connectionManager
 	connectionManager.CodecType = hcm.HttpConnectionManager_AUTO
+	if httpOpts.http3 {
+		connectionManager.CodecType = hcm.HttpConnectionManager_HTTP3
+	}
 	connectionManager.AccessLog = []*accesslog.AccessLog{}
 	connectionManager.HttpFilters = filters
 	connectionManager.StatPrefix = httpOpts.statPrefix
@@ -1623,9 +1659,11 @@ func buildHTTPConnectionManager(listenerOpts buildListenerOpts, httpOpts *httpLi
 		connectionManager.UseRemoteAddress = proto.BoolFalse
 	}
 
-	// Allow websocket upgrades
-	websocketUpgrade := &hcm.HttpConnectionManager_UpgradeConfig{UpgradeType: "websocket"}
-	connectionManager.UpgradeConfigs = []*hcm.HttpConnectionManager_UpgradeConfig{websocketUpgrade}
+	// Allow websocket upgrades. They rely on HTTP/1.1 upgrades, which don't exist in HTTP/3.
+	if !httpOpts.http3 {
+		websocketUpgrade := &hcm.HttpConnectionManager_UpgradeConfig{UpgradeType: "websocket"}
+		connectionManager.UpgradeConfigs = []*hcm.HttpConnectionManager_UpgradeConfig{websocketUpgrade}
+	}
 
 	idleTimeout, err := time.ParseDuration(listenerOpts.proxy.Metadata.IdleTimeout)
 	if err == nil {
@@ -1634,8 +1672,19 @@ func buildHTTPConnectionManager(listenerOpts buildListenerOpts, httpOpts *httpLi
 		}
 	}
 
-	notimeout := ptypes.DurationProto(0 * time.Second)
-	connectionManager.StreamIdleTimeout = notimeout
+	if maxStreamDuration, err := time.ParseDuration(listenerOpts.proxy.Metadata.MaxStreamDuration); err == nil {
+		if connectionManager.CommonHttpProtocolOptions == nil {
+			connectionManager.CommonHttpProtocolOptions = &core.HttpProtocolOptions{}
+		}
+		connectionManager.CommonHttpProtocolOptions.MaxStreamDuration = ptypes.DurationProto(maxStreamDuration)
+	}
+
+	if streamIdleTimeout, err := time.ParseDuration(listenerOpts.proxy.Metadata.StreamIdleTimeout); err == nil {
+		connectionManager.StreamIdleTimeout = ptypes.DurationProto(streamIdleTimeout)
+	} else {
+		notimeout := ptypes.DurationProto(0 * time.Second)
+		connectionManager.StreamIdleTimeout = notimeout
+	}
 
 	if httpOpts.rds != "" {
 		rds := &hcm.HttpConnectionManager_Rds{
@@ -2348,21 +2397,79 @@ func removeListenerFilterTimeout(listeners []*listener.Listener) {
 		// Remove listener filter timeout for
 		// 	1. outbound listeners AND
 		// 	2. without HTTP inspector
-		hasHTTPInspector := false
-		for _, lf := range l.ListenerFilters {
-			if lf.Name == wellknown.HttpInspector {
-				hasHTTPInspector = true
-				break
-			}
-		}
-
-		if !hasHTTPInspector && l.TrafficDirection == core.TrafficDirection_OUTBOUND {
+		if !hasHTTPInspector(l) && l.TrafficDirection == core.TrafficDirection_OUTBOUND {
 			l.ListenerFiltersTimeout = nil
 			l.ContinueOnListenerFiltersTimeout = false
 		}
 	}
 }
 
+func hasHTTPInspector(l *listener.Listener) bool {
+	for _, lf := range l.ListenerFilters {
+		if lf.Name == wellknown.HttpInspector {
+			return true
+		}
+	}
+	return false
+}
+
+// applyProtocolDetection applies the protocol detection of the ports set by the Sidecar to the outbound listeners
+// detecting the protocol of their traffic.
+func applyProtocolDetection(listeners []*listener.Listener, detection map[uint32]routing.ProtocolDetection) {
+	if len(detection) == 0 {
+		return
+	}
+	for _, l := range listeners {
+		d, f := detection[l.GetAddress().GetSocketAddress().GetPortValue()]
+		if !f || !hasHTTPInspector(l) {
+			continue
+		}
+		if d.Timeout != nil {
+			l.ListenerFiltersTimeout = ptypes.DurationProto(*d.Timeout)
+			l.ContinueOnListenerFiltersTimeout = true
+		}
+		if d.FallbackHTTP {
+			fallbackToHTTP(l)
+		}
+	}
+}
+
+// fallbackToHTTP sends the plaintext traffic of the listener whose protocol is not detected to its HTTP filter
+// chains, by removing their application protocol match. The other filter chains left with the same match are
+// removed, as Envoy rejects duplicate matches.
+func fallbackToHTTP(l *listener.Listener) {
+	httpChains := map[*listener.FilterChain]bool{}
+	for _, fc := range l.FilterChains {
+		match := fc.GetFilterChainMatch()
+		if match.GetTransportProtocol() != xdsfilters.RawBufferTransportProtocol ||
+			!reflect.DeepEqual(match.GetApplicationProtocols(), plaintextHTTPALPNs) {
+			continue
+		}
+		match.ApplicationProtocols = nil
+		httpChains[fc] = true
+	}
+	if len(httpChains) == 0 {
+		return
+	}
+	filterChains := make([]*listener.FilterChain, 0, len(l.FilterChains))
+	for _, fc := range l.FilterChains {
+		if !httpChains[fc] && hasSameMatch(fc, httpChains) {
+			continue
+		}
+		filterChains = append(filterChains, fc)
+	}
+	l.FilterChains = filterChains
+}
+
+func hasSameMatch(fc *listener.FilterChain, chains map[*listener.FilterChain]bool) bool {
+	for c := range chains {
+		if golangproto.Equal(fc.GetFilterChainMatch(), c.GetFilterChainMatch()) {
+			return true
+		}
+	}
+	return false
+}
+
 // listenerKey builds the key for a given bind and port
 func listenerKey(bind string, port int) string {
 	return bind + ":" + strconv.Itoa(port)
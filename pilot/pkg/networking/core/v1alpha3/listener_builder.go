@@ -16,6 +16,7 @@ package v1alpha3
 
 import (
 	"sort"
+	"time"
 
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
@@ -167,6 +168,11 @@ func (lb *ListenerBuilder) aggregateVirtualInboundListener(needTLSForPassThrough
 	lb.virtualInboundListener.ListenerFilters = append(lb.virtualInboundListener.ListenerFilters,
 		xdsfilters.OriginalDestination,
 	)
+	// The PROXY protocol header must be consumed before the inspectors read the connection.
+	if lb.node.InboundProxyProtocol() {
+		lb.virtualInboundListener.ListenerFilters =
+			append(lb.virtualInboundListener.ListenerFilters, xdsfilters.ProxyProtocol)
+	}
 	if lb.node.GetInterceptionMode() == model.InterceptionTproxy {
 		lb.virtualInboundListener.ListenerFilters =
 			append(lb.virtualInboundListener.ListenerFilters, xdsfilters.OriginalSrc)
@@ -356,6 +362,10 @@ func (lb *ListenerBuilder) buildVirtualInboundListener(configgen *ConfigGenerato
 		needTLSForPassThroughFilterChain = needTLSForPassThroughFilterChain || needTLS
 		filterChains = append(filterChains, fc...)
 	}
+	if fc := buildInboundHBONEFilterChain(configgen, lb.node, lb.push); fc != nil {
+		needTLSForPassThroughFilterChain = true
+		filterChains = append(filterChains, fc)
+	}
 	lb.virtualInboundListener = &listener.Listener{
 		Name:             VirtualInboundListenerName,
 		Address:          util.BuildAddress(actualWildcard, ProxyInboundListenPort),
@@ -383,6 +393,75 @@ func (lb *ListenerBuilder) patchOneListener(l *listener.Listener, ctx networking
 	return tempArray[0]
 }
 
+// Linux socket options configuring the TCP keepalive of the connections accepted by a listener. They are set on the
+// listening socket, and inherited by the sockets it accepts.
+const (
+	solSocket      = 1
+	soKeepalive    = 9
+	ipprotoTCP     = 6
+	tcpKeepidle    = 4
+	tcpKeepintvl   = 5
+	tcpKeepcnt     = 6
+	tcpUserTimeout = 18
+)
+
+// applyDownstreamTCPKeepalive sets the downstream TCP keepalive of the proxy, if any, on the listeners binding to a
+// TCP port. The other listeners receive their connections from the virtual listeners, which already set it.
+func (lb *ListenerBuilder) applyDownstreamTCPKeepalive() {
+	keepalive := lb.node.DownstreamTCPKeepalive()
+	if keepalive == nil {
+		return
+	}
+	apply := func(l *listener.Listener) {
+		if l == nil || l.GetAddress().GetSocketAddress() == nil ||
+			l.Address.GetSocketAddress().Protocol == core.SocketAddress_UDP {
+			return
+		}
+		if bind := l.GetDeprecatedV1().GetBindToPort(); bind != nil && !bind.Value {
+			return
+		}
+		l.SocketOptions = append(l.SocketOptions, buildTCPKeepaliveSocketOptions(keepalive)...)
+	}
+	for _, l := range lb.inboundListeners {
+		apply(l)
+	}
+	for _, l := range lb.outboundListeners {
+		apply(l)
+	}
+	for _, l := range lb.gatewayListeners {
+		apply(l)
+	}
+	apply(lb.httpProxyListener)
+	apply(lb.virtualOutboundListener)
+	apply(lb.virtualInboundListener)
+}
+
+func buildTCPKeepaliveSocketOptions(keepalive *model.TCPKeepalive) []*core.SocketOption {
+	intOption := func(description string, level, name, value int64) *core.SocketOption {
+		return &core.SocketOption{
+			Description: description,
+			Level:       level,
+			Name:        name,
+			Value:       &core.SocketOption_IntValue{IntValue: value},
+			State:       core.SocketOption_STATE_PREBIND,
+		}
+	}
+	options := []*core.SocketOption{intOption("SO_KEEPALIVE", solSocket, soKeepalive, 1)}
+	if keepalive.Time > 0 {
+		options = append(options, intOption("TCP_KEEPIDLE", ipprotoTCP, tcpKeepidle, int64(keepalive.Time/time.Second)))
+	}
+	if keepalive.Interval > 0 {
+		options = append(options, intOption("TCP_KEEPINTVL", ipprotoTCP, tcpKeepintvl, int64(keepalive.Interval/time.Second)))
+	}
+	if keepalive.Probes > 0 {
+		options = append(options, intOption("TCP_KEEPCNT", ipprotoTCP, tcpKeepcnt, int64(keepalive.Probes)))
+	}
+	if keepalive.UserTimeout > 0 {
+		options = append(options, intOption("TCP_USER_TIMEOUT", ipprotoTCP, tcpUserTimeout, int64(keepalive.UserTimeout/time.Millisecond)))
+	}
+	return options
+}
+
 func (lb *ListenerBuilder) patchListeners() {
 	lb.envoyFilterWrapper = lb.push.EnvoyFilters(lb.node)
 	if lb.envoyFilterWrapper == nil {
@@ -527,6 +606,14 @@ func buildInboundCatchAllNetworkFilterChains(configgen *ConfigGeneratorImpl,
 			}
 		}
 
+		// Track the ports that terminate TLS. Port 0 stands for the default, port-less, filter chains.
+		tlsPorts := map[uint32]struct{}{}
+		for _, chain := range allChains {
+			if chain.TLSContext != nil {
+				tlsPorts[chain.FilterChainMatch.GetDestinationPort().GetValue()] = struct{}{}
+			}
+		}
+
 		// Construct the actual filter chains for each of the filter chain from the plugin.
 		for _, chain := range allChains {
 			filterChain := &listener.FilterChain{
@@ -546,6 +633,23 @@ func buildInboundCatchAllNetworkFilterChains(configgen *ConfigGeneratorImpl,
 			}
 			filterChain.Name = VirtualInboundListenerName
 			filterChains = append(filterChains, filterChain)
+
+			if len(tlsPorts) == 0 || chain.TLSContext != nil {
+				continue
+			}
+			if _, f := tlsPorts[chain.FilterChainMatch.GetDestinationPort().GetValue()]; f {
+				continue
+			}
+			// The TLS inspector is enabled for some other port, so TLS traffic will no longer match the raw buffer
+			// chain above. For ports with mTLS disabled, tunnel the TLS traffic through as-is instead of dropping it.
+			// See https://github.com/istio/istio/issues/29538.
+			tunnelMatch := golangproto.Clone(chain.FilterChainMatch).(*listener.FilterChainMatch)
+			tunnelMatch.TransportProtocol = xdsfilters.TLSTransportProtocol
+			filterChains = append(filterChains, &listener.FilterChain{
+				Name:             VirtualInboundListenerName,
+				FilterChainMatch: tunnelMatch,
+				Filters:          filterChain.Filters,
+			})
 		}
 	}
 
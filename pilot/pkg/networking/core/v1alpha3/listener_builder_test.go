@@ -296,6 +296,65 @@ func TestVirtualInboundHasPassthroughClusters(t *testing.T) {
 	}
 }
 
+func TestVirtualInboundHBONEFilterChain(t *testing.T) {
+	defaultValue := features.EnableHBONE
+	defer func() { features.EnableHBONE = defaultValue }()
+
+	features.EnableHBONE = false
+	listeners := prepareListeners(t, testServices, model.InterceptionRedirect)
+	for _, fc := range listeners[1].FilterChains {
+		if fc.Name == virtualInboundHBONEFilterChainName {
+			t.Fatalf("expected no HBONE filter chain when HBONE is disabled")
+		}
+	}
+
+	features.EnableHBONE = true
+	listeners = prepareListeners(t, testServices, model.InterceptionRedirect)
+	l := listeners[1]
+	var hbone *listener.FilterChain
+	for _, fc := range l.FilterChains {
+		if fc.Name == virtualInboundHBONEFilterChainName {
+			hbone = fc
+		}
+	}
+	if hbone == nil {
+		t.Fatalf("expected the HBONE filter chain in listener %v", l)
+	}
+	if hbone.FilterChainMatch.GetDestinationPort().GetValue() != HBONEInboundListenPort ||
+		hbone.FilterChainMatch.TransportProtocol != xdsfilters.TLSTransportProtocol {
+		t.Fatalf("expected the HBONE filter chain to match TLS on port %d, found %v", HBONEInboundListenPort, hbone.FilterChainMatch)
+	}
+	if hbone.TransportSocket == nil {
+		t.Fatalf("expected the HBONE filter chain to terminate mTLS")
+	}
+	if _, f := xdstest.ExtractListenerFilters(l)[wellknown.TlsInspector]; !f {
+		t.Fatalf("expected the TLS inspector for the HBONE filter chain")
+	}
+
+	cm := xdstest.ExtractHTTPConnectionManager(t, hbone)
+	if cm.HttpFilters[0].Name != fakePluginHTTPFilter {
+		t.Fatalf("expected the fake plugin HTTP filter first, found %v", cm.HttpFilters)
+	}
+	vhosts := cm.GetRouteConfig().GetVirtualHosts()
+	if len(vhosts) != 1 {
+		t.Fatalf("expected 1 virtual host, found %v", vhosts)
+	}
+	if !reflect.DeepEqual(vhosts[0].Domains, []string{"test.com:8080"}) {
+		t.Fatalf("expected domains [test.com:8080], found %v", vhosts[0].Domains)
+	}
+	r := vhosts[0].Routes[0]
+	if r.Match.GetConnectMatcher() == nil {
+		t.Fatalf("expected a CONNECT route, found %v", r.Match)
+	}
+	expectedCluster := util.BuildInboundSubsetKey(getDefaultProxy(), "default", "test.com", 8080, 8080)
+	if r.GetRoute().GetCluster() != expectedCluster {
+		t.Fatalf("expected cluster %s, found %s", expectedCluster, r.GetRoute().GetCluster())
+	}
+	if r.GetRoute().UpgradeConfigs[0].UpgradeType != "CONNECT" || r.GetRoute().UpgradeConfigs[0].ConnectConfig == nil {
+		t.Fatalf("expected the route to terminate CONNECT, found %v", r.GetRoute().UpgradeConfigs)
+	}
+}
+
 func TestSidecarInboundListenerWithOriginalSrc(t *testing.T) {
 	// prepare
 	t.Helper()
@@ -55,6 +55,7 @@ import (
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/mesh"
 	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/routing"
 	"istio.io/istio/pkg/config/schema/collections"
 	"istio.io/istio/pkg/config/schema/gvk"
 )
@@ -1406,6 +1407,45 @@ func testOutboundListenerConfigWithSidecarWithSniffingDisabled(t *testing.T, ser
 	}
 }
 
+func TestOutboundListenerProtocolDetection(t *testing.T) {
+	p := &fakePlugin{}
+	sidecarConfig := &config.Config{
+		Meta: config.Meta{
+			Name:             "foo",
+			Namespace:        "not-default",
+			GroupVersionKind: gvk.Sidecar,
+			Annotations:      map[string]string{routing.ProtocolDetectionAnnotation: "8080=2s/HTTP"},
+		},
+		Spec: &networking.Sidecar{
+			Egress: []*networking.IstioEgressListener{{Hosts: []string{"*/*"}}},
+		},
+	}
+	services := []*model.Service{buildService("test.com", wildcardIP, protocol.Unsupported, tnow)}
+
+	listeners := buildOutboundListeners(t, p, getProxy(), sidecarConfig, nil, services...)
+	l := findListenerByPort(listeners, 8080)
+	if l == nil {
+		t.Fatalf("expected a listener on port 8080")
+	}
+	if got := l.ListenerFiltersTimeout.AsDuration(); got != 2*time.Second {
+		t.Errorf("expected a listener filters timeout of 2s, got %v", got)
+	}
+	httpChains := 0
+	for _, fc := range l.FilterChains {
+		if !isHTTPFilterChain(fc) {
+			continue
+		}
+		httpChains++
+		if len(fc.FilterChainMatch.GetApplicationProtocols()) != 0 {
+			t.Errorf("expected the HTTP filter chain to match any application protocol, got %v",
+				fc.FilterChainMatch.ApplicationProtocols)
+		}
+	}
+	if httpChains == 0 {
+		t.Errorf("expected an HTTP filter chain")
+	}
+}
+
 func testOutboundListenerConfigWithSidecarWithUseRemoteAddress(t *testing.T, services ...*model.Service) {
 	t.Helper()
 	p := &fakePlugin{}
@@ -1651,6 +1691,34 @@ func TestHttpProxyListener(t *testing.T) {
 	}
 }
 
+func TestHttpProxyListenerStreamTimeouts(t *testing.T) {
+	p := &fakePlugin{}
+	configgen := NewConfigGenerator([]plugin.Plugin{p}, &model.DisabledCache{})
+
+	env := buildListenerEnv(nil)
+	if err := env.PushContext.InitContext(&env, nil, nil); err != nil {
+		t.Fatalf("error in initializing push context: %s", err)
+	}
+
+	proxy := getProxy()
+	proxy.ServiceInstances = nil
+	proxy.Metadata.StreamIdleTimeout = "5m"
+	proxy.Metadata.MaxStreamDuration = "1h"
+	env.Mesh().ProxyHttpPort = 15007
+	proxy.SidecarScope = model.DefaultSidecarScopeForNamespace(env.PushContext, "not-default")
+	httpProxy := configgen.buildHTTPProxy(proxy, env.PushContext)
+	cmgr := &hcm.HttpConnectionManager{}
+	if err := getFilterConfig(httpProxy.FilterChains[0].Filters[0], cmgr); err != nil {
+		t.Fatal(err)
+	}
+	if got := cmgr.StreamIdleTimeout.AsDuration(); got != 5*time.Minute {
+		t.Errorf("expected stream idle timeout of 5m, got %v", got)
+	}
+	if got := cmgr.GetCommonHttpProtocolOptions().GetMaxStreamDuration().AsDuration(); got != time.Hour {
+		t.Errorf("expected max stream duration of 1h, got %v", got)
+	}
+}
+
 func TestHttpProxyListener_Tracing(t *testing.T) {
 	customTagsTest := []struct {
 		name             string
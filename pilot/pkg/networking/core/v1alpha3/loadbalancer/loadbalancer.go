@@ -24,9 +24,18 @@ import (
 	"github.com/golang/protobuf/ptypes/wrappers"
 
 	"istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
 )
 
+// The labels of the locality and network of the proxies and endpoints, for the failover priority.
+const (
+	RegionLabel  = "topology.kubernetes.io/region"
+	ZoneLabel    = "topology.kubernetes.io/zone"
+	SubzoneLabel = "topology.istio.io/subzone"
+	NetworkLabel = "topology.istio.io/network"
+)
+
 func GetLocalityLbSetting(
 	mesh *v1alpha3.LocalityLoadBalancerSetting,
 	destrule *v1alpha3.LocalityLoadBalancerSetting,
@@ -168,13 +177,19 @@ func applyLocalityFailover(
 
 	// since Priorities should range from 0 (highest) to N (lowest) without skipping.
 	// 2. adjust the priorities in order
-	// 2.1 sort all priorities in increasing order.
+	adjustPriorities(loadAssignment, priorityMap)
+}
+
+// adjustPriorities makes the priorities of the LocalityLbEndpoints, indexed by priority, range from 0 without
+// skipping.
+func adjustPriorities(loadAssignment *endpoint.ClusterLoadAssignment, priorityMap map[int][]int) {
+	// 1. sort all priorities in increasing order.
 	priorities := []int{}
 	for priority := range priorityMap {
 		priorities = append(priorities, priority)
 	}
 	sort.Ints(priorities)
-	// 2.2 adjust LocalityLbEndpoints priority
+	// 2. adjust LocalityLbEndpoints priority
 	// if the index and value of priorities array is not equal.
 	for i, priority := range priorities {
 		if i != priority {
@@ -185,3 +200,42 @@ func applyLocalityFailover(
 		}
 	}
 }
+
+// FailoverLabels returns the labels of a proxy or endpoint compared by the failover priority: the labels of the
+// workload, and the labels of its locality and network.
+func FailoverLabels(workloadLabels map[string]string, locality, network string) map[string]string {
+	out := make(map[string]string, len(workloadLabels)+4)
+	for k, v := range workloadLabels {
+		out[k] = v
+	}
+	region, zone, subzone := model.SplitLocalityLabel(locality)
+	for k, v := range map[string]string{RegionLabel: region, ZoneLabel: zone, SubzoneLabel: subzone, NetworkLabel: network} {
+		if v != "" {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// FailoverPriority returns the priority of an endpoint for a proxy, given the label keys of the failover priority:
+// 0 if the endpoint has the same values as the proxy for all the labels, 1 if it has the same values for all the
+// labels but the last one, and so on up to the number of labels.
+func FailoverPriority(failoverPriority []string, proxyLabels, endpointLabels map[string]string) int {
+	for i, key := range failoverPriority {
+		value, f := proxyLabels[key]
+		if !f || endpointLabels[key] != value {
+			return len(failoverPriority) - i
+		}
+	}
+	return 0
+}
+
+// ApplyFailoverPriority adjusts the priorities of the LocalityLbEndpoints set from the failover priority, so that
+// they range from 0 without skipping.
+func ApplyFailoverPriority(loadAssignment *endpoint.ClusterLoadAssignment) {
+	priorityMap := map[int][]int{}
+	for i, localityEndpoint := range loadAssignment.Endpoints {
+		priorityMap[int(localityEndpoint.Priority)] = append(priorityMap[int(localityEndpoint.Priority)], i)
+	}
+	adjustPriorities(loadAssignment, priorityMap)
+}
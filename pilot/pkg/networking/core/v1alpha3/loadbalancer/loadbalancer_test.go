@@ -239,6 +239,46 @@ func TestGetLocalityLbSetting(t *testing.T) {
 	}
 }
 
+func TestFailoverPriority(t *testing.T) {
+	failoverPriority := []string{NetworkLabel, ZoneLabel, "rack"}
+	proxyLabels := FailoverLabels(map[string]string{"rack": "r1", "app": "productpage"}, "region1/zone1/subzone1", "network1")
+	cases := []struct {
+		name     string
+		labels   map[string]string
+		locality string
+		network  string
+		want     int
+	}{
+		{name: "all labels match", labels: map[string]string{"rack": "r1"}, locality: "region1/zone1", network: "network1", want: 0},
+		{name: "rack mismatch", labels: map[string]string{"rack": "r2"}, locality: "region1/zone1", network: "network1", want: 1},
+		{name: "rack missing", locality: "region1/zone1", network: "network1", want: 1},
+		{name: "zone mismatch", labels: map[string]string{"rack": "r1"}, locality: "region1/zone2", network: "network1", want: 2},
+		{name: "network mismatch", labels: map[string]string{"rack": "r1"}, locality: "region1/zone1", network: "network2", want: 3},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := FailoverPriority(failoverPriority, proxyLabels, FailoverLabels(c.labels, c.locality, c.network))
+			if got != c.want {
+				t.Errorf("got priority %d, want %d", got, c.want)
+			}
+		})
+	}
+}
+
+func TestApplyFailoverPriority(t *testing.T) {
+	loadAssignment := &endpoint.ClusterLoadAssignment{
+		Endpoints: []*endpoint.LocalityLbEndpoints{{Priority: 3}, {Priority: 1}, {Priority: 3}, {Priority: 0}},
+	}
+	ApplyFailoverPriority(loadAssignment)
+	var got []uint32
+	for _, e := range loadAssignment.Endpoints {
+		got = append(got, e.Priority)
+	}
+	if want := []uint32{2, 1, 2, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got priorities %v, want %v", got, want)
+	}
+}
+
 func buildEnvForClustersWithDistribute(distribute []*networking.LocalityLoadBalancerSetting_Distribute) *model.Environment {
 	serviceDiscovery := memregistry.NewServiceDiscovery([]*model.Service{
 		{
@@ -77,7 +77,7 @@ func (configgen *ConfigGeneratorImpl) BuildNameTable(node *model.Proxy, push *mo
 			// the stateful set object, associate the object with the appropriate kubernetes headless service
 			// and then derive the stable network identities.
 			if svc.Attributes.ServiceRegistry == string(serviceregistry.Kubernetes) &&
-				svc.Resolution == model.Passthrough && len(svc.Ports) > 0 {
+				len(svc.Ports) > 0 && svc.ResolutionForPort(svc.Ports[0].Port) == model.Passthrough {
 				// TODO: this is used in two places now. Needs to be cached as part of the headless service
 				// object to avoid the costly lookup in the registry code
 				for _, instance := range push.ServiceInstancesByPort(svc, svc.Ports[0].Port, nil) {
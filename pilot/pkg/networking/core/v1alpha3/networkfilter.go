@@ -53,7 +53,11 @@ func buildInboundNetworkFilters(push *model.PushContext, instance *model.Service
 		ClusterSpecifier: &tcp.TcpProxy_Cluster{Cluster: clusterName},
 	}
 	tcpFilter := setAccessLogAndBuildTCPFilter(push, tcpProxy, node)
-	return buildNetworkFiltersStack(instance.ServicePort, tcpFilter, statPrefix, clusterName)
+	filters := buildNetworkFiltersStack(instance.ServicePort, tcpFilter, statPrefix, clusterName)
+	if rateLimit := inboundLocalRateLimit(node); rateLimit != nil {
+		filters = append([]*listener.Filter{buildLocalRateLimitNetworkFilter(rateLimit)}, filters...)
+	}
+	return filters
 }
 
 // setAccessLogAndBuildTCPFilter sets the AccessLog configuration in the given
@@ -82,6 +86,7 @@ func buildOutboundNetworkFiltersWithSingleDestination(push *model.PushContext, n
 	if err == nil {
 		tcpProxy.IdleTimeout = ptypes.DurationProto(idleTimeout)
 	}
+	applyHBONETunnel(push, node, tcpProxy)
 
 	tcpFilter := setAccessLogAndBuildTCPFilter(push, tcpProxy, node)
 	return buildNetworkFiltersStack(port, tcpFilter, statPrefix, clusterName)
@@ -118,6 +123,8 @@ func buildOutboundNetworkFiltersWithWeightedClusters(node *model.Proxy, routes [
 		}
 	}
 
+	applyHBONETunnel(push, node, proxyConfig)
+
 	// TODO: Need to handle multiple cluster names for Redis
 	clusterName := clusterSpecifier.WeightedClusters.Clusters[0].Name
 	tcpFilter := setAccessLogAndBuildTCPFilter(push, proxyConfig, node)
@@ -17,9 +17,12 @@ package v1alpha3_test
 import (
 	"testing"
 
+	meshconfig "istio.io/api/mesh/v1alpha1"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/simulation"
 	"istio.io/istio/pilot/pkg/xds"
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/spiffe"
 )
 
 // TestPeerAuthenticationPassthrough tests the PeerAuthentication policy applies correctly on the passthrough filter chain,
@@ -105,6 +108,20 @@ spec:
   portLevelMtls:
     9000:
       mode: PERMISSIVE
+---`
+	paDisableWithStrictOnNamedPortHTTP := `
+apiVersion: security.istio.io/v1beta1
+kind: PeerAuthentication
+metadata:
+ name: default
+ annotations:
+   security.istio.io/portLevelMtlsByName: http=STRICT
+spec:
+ selector:
+   matchLabels:
+     app: foo
+ mtls:
+   mode: DISABLE
 ---`
 	sePort8000 := `
 apiVersion: networking.istio.io/v1alpha3
@@ -124,8 +141,7 @@ spec:
    protocol: HTTP
 ---`
 	mkCall := func(port int, tls simulation.TLSMode) simulation.Call {
-		// TODO https://github.com/istio/istio/issues/28506 address should not be required here
-		r := simulation.Call{Protocol: simulation.HTTP, Port: port, CallMode: simulation.CallModeInbound, TLS: tls, Address: "1.1.1.1"}
+		r := simulation.Call{Protocol: simulation.HTTP, Port: port, CallMode: simulation.CallModeInbound, TLS: tls}
 		if tls == simulation.MTLS {
 			r.Alpn = "istio"
 		}
@@ -194,13 +210,9 @@ spec:
 					Result: simulation.Result{ClusterMatched: "InboundPassthroughClusterIpv4"},
 				},
 				{
-					Name: "mtls on port 8000",
-					Call: mkCall(8000, simulation.MTLS),
-					Result: simulation.Result{
-						// This is broken, we should pass it through
-						Error: simulation.ErrNoFilterChain,
-						Skip:  "https://github.com/istio/istio/issues/29538#issuecomment-743283641",
-					},
+					Name:   "mtls on port 8000",
+					Call:   mkCall(8000, simulation.MTLS),
+					Result: simulation.Result{ClusterMatched: "InboundPassthroughClusterIpv4"},
 				},
 				{
 					Name:   "plaintext port 9000",
@@ -261,13 +273,9 @@ spec:
 					Result: simulation.Result{ClusterMatched: "InboundPassthroughClusterIpv4"},
 				},
 				{
-					Name: "mtls port 9000",
-					Call: mkCall(9000, simulation.MTLS),
-					Result: simulation.Result{
-						// This is broken, we should be passing it through
-						Error: simulation.ErrNoFilterChain,
-						Skip:  "https://github.com/istio/istio/issues/29538#issuecomment-743286797",
-					},
+					Name:   "mtls port 9000",
+					Call:   mkCall(9000, simulation.MTLS),
+					Result: simulation.Result{ClusterMatched: "InboundPassthroughClusterIpv4"},
 				},
 			},
 		},
@@ -291,13 +299,9 @@ spec:
 					Result: simulation.Result{ClusterMatched: "InboundPassthroughClusterIpv4"},
 				},
 				{
-					Name: "mtls port 9000",
-					Call: mkCall(9000, simulation.MTLS),
-					Result: simulation.Result{
-						// This is broken, we should be passing it through
-						Error: simulation.ErrNoFilterChain,
-						Skip:  "https://github.com/istio/istio/issues/29538#issuecomment-743286797",
-					},
+					Name:   "mtls port 9000",
+					Call:   mkCall(9000, simulation.MTLS),
+					Result: simulation.Result{ClusterMatched: "InboundPassthroughClusterIpv4"},
 				},
 			},
 		},
@@ -327,6 +331,27 @@ spec:
 				},
 			},
 		},
+		{
+			name:   "global disable and named port strict",
+			config: paDisableWithStrictOnNamedPortHTTP + sePort8000,
+			calls: []simulation.Expect{
+				{
+					Name:   "plaintext on port 8000",
+					Call:   mkCall(8000, simulation.Plaintext),
+					Result: simulation.Result{Error: simulation.ErrNoFilterChain},
+				},
+				{
+					Name:   "mtls on port 8000",
+					Call:   mkCall(8000, simulation.MTLS),
+					Result: simulation.Result{ClusterMatched: "inbound|8000||"},
+				},
+				{
+					Name:   "plaintext port 9000",
+					Call:   mkCall(9000, simulation.Plaintext),
+					Result: simulation.Result{ClusterMatched: "InboundPassthroughClusterIpv4"},
+				},
+			},
+		},
 		{
 			name:   "global plaintext and port 9000 permissive not in service",
 			config: paDisableWithPermissiveOnPort9000 + sePort8000,
@@ -429,6 +454,62 @@ spec:
   workloadSelector:
     labels:
       app: foo
+---`
+	sidecarWithIngressMtls := `
+apiVersion: networking.istio.io/v1alpha3
+kind: Sidecar
+metadata:
+  labels:
+    app: foo
+  name: sidecar
+  annotations:
+    security.istio.io/ingressMtls: 8080=STRICT
+spec:
+  ingress:
+  - defaultEndpoint: 127.0.0.1:8080
+    port:
+      name: tls
+      number: 8080
+      protocol: TCP
+  - defaultEndpoint: 127.0.0.1:9090
+    port:
+      name: plaintext
+      number: 9090
+      protocol: TCP
+  egress:
+  - hosts:
+    - "*/*"
+  workloadSelector:
+    labels:
+      app: foo
+---`
+	sidecarWithConflictingIngressMtls := `
+apiVersion: networking.istio.io/v1alpha3
+kind: Sidecar
+metadata:
+  labels:
+    app: foo
+  name: sidecar
+  annotations:
+    security.istio.io/ingressMtls: 8080=DISABLE,9090=STRICT
+spec:
+  ingress:
+  - defaultEndpoint: 127.0.0.1:8080
+    port:
+      name: tls
+      number: 8080
+      protocol: TCP
+  - defaultEndpoint: 127.0.0.1:9090
+    port:
+      name: plaintext
+      number: 9090
+      protocol: TCP
+  egress:
+  - hosts:
+    - "*/*"
+  workloadSelector:
+    labels:
+      app: foo
 ---`
 	instancePorts := `
 apiVersion: networking.istio.io/v1alpha3
@@ -472,8 +553,7 @@ spec:
     protocol: TCP
 ---`
 	mkCall := func(port int, tls simulation.TLSMode) simulation.Call {
-		// TODO https://github.com/istio/istio/issues/28506 address should not be required here
-		return simulation.Call{Protocol: simulation.TCP, Port: port, CallMode: simulation.CallModeInbound, TLS: tls, Address: "1.1.1.1"}
+		return simulation.Call{Protocol: simulation.TCP, Port: port, CallMode: simulation.CallModeInbound, TLS: tls}
 	}
 	cases := []struct {
 		name   string
@@ -590,6 +670,51 @@ spec:
 				},
 			},
 		},
+		{
+			name:   "no peer authentication, sidecar ingress mtls",
+			config: sidecarWithIngressMtls + instanceNoPorts,
+			calls: []simulation.Expect{
+				{
+					Name:   "plaintext on tls port",
+					Call:   mkCall(8080, simulation.Plaintext),
+					Result: simulation.Result{Error: simulation.ErrNoFilterChain},
+				},
+				{
+					Name:   "tls on tls port",
+					Call:   mkCall(8080, simulation.MTLS),
+					Result: simulation.Result{ClusterMatched: "inbound|8080||"},
+				},
+				{
+					Name: "plaintext on plaintext port",
+					Call: mkCall(9090, simulation.Plaintext),
+					// not set in the Sidecar, defaults to permissive
+					Result: simulation.Result{ClusterMatched: "inbound|9090||"},
+				},
+			},
+		},
+		{
+			name:   "peer authentication, sidecar ingress mtls",
+			config: pa + sidecarWithConflictingIngressMtls + instanceNoPorts,
+			calls: []simulation.Expect{
+				{
+					Name: "plaintext on tls port",
+					Call: mkCall(8080, simulation.Plaintext),
+					// the Sidecar cannot weaken the workload level mode of the PeerAuthentication
+					Result: simulation.Result{Error: simulation.ErrNoFilterChain},
+				},
+				{
+					Name: "plaintext on plaintext port",
+					Call: mkCall(9090, simulation.Plaintext),
+					// the Sidecar makes the port level mode of the PeerAuthentication stricter
+					Result: simulation.Result{Error: simulation.ErrNoFilterChain},
+				},
+				{
+					Name:   "tls on plaintext port",
+					Call:   mkCall(9090, simulation.MTLS),
+					Result: simulation.Result{ClusterMatched: "inbound|9090||"},
+				},
+			},
+		},
 		{
 			name:   "service, partial sidecar",
 			config: pa + partialSidecar + instancePorts,
@@ -629,3 +754,152 @@ spec:
 		})
 	}
 }
+
+// TestPeerAuthenticationSourceIdentity tests the peer certificate of the caller is validated against the mesh trust
+// domains, and the caller identity is used to evaluate AuthorizationPolicy principals.
+func TestPeerAuthenticationSourceIdentity(t *testing.T) {
+	pa := `
+apiVersion: security.istio.io/v1beta1
+kind: PeerAuthentication
+metadata:
+  name: default
+spec:
+  selector:
+    matchLabels:
+      app: foo
+  mtls:
+    mode: STRICT
+---`
+	authz := `
+apiVersion: security.istio.io/v1beta1
+kind: AuthorizationPolicy
+metadata:
+  name: allow-bar
+spec:
+  selector:
+    matchLabels:
+      app: foo
+  action: ALLOW
+  rules:
+  - from:
+    - source:
+        principals: ["cluster.local/ns/default/sa/bar"]
+---`
+	se := `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: se
+spec:
+  hosts:
+  - foo.bar
+  endpoints:
+  - address: 1.1.1.1
+    labels:
+      app: foo
+  location: MESH_INTERNAL
+  resolution: STATIC
+  ports:
+  - name: http
+    number: 8080
+    protocol: HTTP
+  - name: tcp
+    number: 9090
+    protocol: TCP
+---`
+	mkCall := func(port int, protocol simulation.Protocol, source *spiffe.Identity) simulation.Call {
+		return simulation.Call{
+			Protocol: protocol,
+			Port:     port,
+			CallMode: simulation.CallModeInbound,
+			TLS:      simulation.MTLS,
+			Source:   source,
+		}
+	}
+	bar := &spiffe.Identity{TrustDomain: "cluster.local", Namespace: "default", ServiceAccount: "bar"}
+	baz := &spiffe.Identity{TrustDomain: "cluster.local", Namespace: "default", ServiceAccount: "baz"}
+	foreign := &spiffe.Identity{TrustDomain: "other.domain", Namespace: "default", ServiceAccount: "bar"}
+	cases := []struct {
+		name   string
+		config string
+		mesh   *meshconfig.MeshConfig
+		calls  []simulation.Expect
+	}{
+		{
+			name:   "trust domain",
+			config: pa + se,
+			calls: []simulation.Expect{
+				{
+					Name:   "same trust domain",
+					Call:   mkCall(8080, simulation.HTTP, bar),
+					Result: simulation.Result{ClusterMatched: "inbound|8080||"},
+				},
+				{
+					Name:   "other trust domain",
+					Call:   mkCall(8080, simulation.HTTP, foreign),
+					Result: simulation.Result{Error: simulation.ErrPeerCertificate},
+				},
+				{
+					Name:   "other trust domain passthrough",
+					Call:   mkCall(8000, simulation.HTTP, foreign),
+					Result: simulation.Result{Error: simulation.ErrPeerCertificate},
+				},
+			},
+		},
+		{
+			name:   "trust domain alias",
+			config: pa + se,
+			mesh: func() *meshconfig.MeshConfig {
+				m := mesh.DefaultMeshConfig()
+				m.TrustDomainAliases = []string{"other.domain"}
+				return &m
+			}(),
+			calls: []simulation.Expect{
+				{
+					Name:   "alias trust domain",
+					Call:   mkCall(8080, simulation.HTTP, foreign),
+					Result: simulation.Result{ClusterMatched: "inbound|8080||"},
+				},
+			},
+		},
+		{
+			name:   "source principal",
+			config: pa + authz + se,
+			calls: []simulation.Expect{
+				{
+					Name:   "http allowed principal",
+					Call:   mkCall(8080, simulation.HTTP, bar),
+					Result: simulation.Result{ClusterMatched: "inbound|8080||"},
+				},
+				{
+					Name:   "http denied principal",
+					Call:   mkCall(8080, simulation.HTTP, baz),
+					Result: simulation.Result{Error: simulation.ErrRBACDenied},
+				},
+				{
+					Name:   "http no principal",
+					Call:   mkCall(8080, simulation.HTTP, nil),
+					Result: simulation.Result{Error: simulation.ErrRBACDenied},
+				},
+				{
+					Name:   "tcp allowed principal",
+					Call:   mkCall(9090, simulation.TCP, bar),
+					Result: simulation.Result{ClusterMatched: "inbound|9090||"},
+				},
+				{
+					Name:   "tcp denied principal",
+					Call:   mkCall(9090, simulation.TCP, baz),
+					Result: simulation.Result{Error: simulation.ErrRBACDenied},
+				},
+			},
+		},
+	}
+	proxy := &model.Proxy{Metadata: &model.NodeMetadata{Labels: map[string]string{"app": "foo"}}}
+	for _, tt := range cases {
+		runSimulationTest(t, proxy, xds.FakeOptions{MeshConfig: tt.mesh}, simulationTest{
+			name:   tt.name,
+			config: tt.config,
+			calls:  tt.calls,
+		})
+	}
+}
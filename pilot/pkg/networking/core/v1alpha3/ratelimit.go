@@ -0,0 +1,151 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha3
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	ratelimitconfig "github.com/envoyproxy/go-control-plane/envoy/config/ratelimit/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	localratelimit "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/local_ratelimit/v3"
+	ratelimit "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ratelimit/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	networklocalratelimit "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/local_ratelimit/v3"
+	wellknown "github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/golang/protobuf/ptypes"
+
+	"istio.io/istio/pilot/pkg/model"
+	istio_route "istio.io/istio/pilot/pkg/networking/core/v1alpha3/route"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/routing"
+)
+
+// buildRateLimitFilter returns the HTTP filter calling the rate limit service of the global rate limiting with the
+// descriptors of the rate limits of the virtual hosts.
+func buildRateLimitFilter(rateLimit *routing.RateLimit) *hcm.HttpFilter {
+	timeout := ptypes.DurationProto(rateLimit.Timeout)
+	config := &ratelimit.RateLimit{
+		Domain:          rateLimit.Domain,
+		Timeout:         timeout,
+		FailureModeDeny: rateLimit.FailureModeDeny,
+		RateLimitService: &ratelimitconfig.RateLimitServiceConfig{
+			GrpcService: &core.GrpcService{
+				TargetSpecifier: &core.GrpcService_EnvoyGrpc_{
+					EnvoyGrpc: &core.GrpcService_EnvoyGrpc{
+						ClusterName: model.BuildSubsetKey(model.TrafficDirectionOutbound, "", host.Name(rateLimit.Host), rateLimit.Port),
+					},
+				},
+				Timeout: timeout,
+			},
+			TransportApiVersion: core.ApiVersion_V3,
+		},
+	}
+	return &hcm.HttpFilter{
+		Name:       wellknown.HTTPRateLimit,
+		ConfigType: &hcm.HttpFilter_TypedConfig{TypedConfig: util.MessageToAny(config)},
+	}
+}
+
+// buildRateLimits returns the rate limits of a virtual host, each sending a descriptor of the global rate limiting.
+func buildRateLimits(rateLimit *routing.RateLimit) []*route.RateLimit {
+	out := make([]*route.RateLimit, 0, len(rateLimit.Descriptors))
+	for _, descriptor := range rateLimit.Descriptors {
+		actions := make([]*route.RateLimit_Action, 0, len(descriptor))
+		for _, entry := range descriptor {
+			actions = append(actions, buildRateLimitAction(entry))
+		}
+		out = append(out, &route.RateLimit{Actions: actions})
+	}
+	return out
+}
+
+// buildRateLimitAction returns the action adding the entry to a descriptor.
+func buildRateLimitAction(entry routing.RateLimitEntry) *route.RateLimit_Action {
+	switch {
+	case entry.Header != "":
+		return &route.RateLimit_Action{
+			ActionSpecifier: &route.RateLimit_Action_RequestHeaders_{
+				RequestHeaders: &route.RateLimit_Action_RequestHeaders{
+					HeaderName:    entry.Header,
+					DescriptorKey: entry.Key,
+				},
+			},
+		}
+	case entry.RemoteAddress:
+		return &route.RateLimit_Action{
+			ActionSpecifier: &route.RateLimit_Action_RemoteAddress_{
+				RemoteAddress: &route.RateLimit_Action_RemoteAddress{},
+			},
+		}
+	default:
+		return &route.RateLimit_Action{
+			ActionSpecifier: &route.RateLimit_Action_GenericKey_{
+				GenericKey: &route.RateLimit_Action_GenericKey{
+					DescriptorValue: entry.Value,
+					DescriptorKey:   entry.Key,
+				},
+			},
+		}
+	}
+}
+
+const (
+	// localRateLimitNetworkFilterName is the name of the network filter of the local rate limiting.
+	localRateLimitNetworkFilterName = "envoy.filters.network.local_ratelimit"
+	// inboundLocalRateLimitStatPrefix is the stat prefix of the local rate limiting of the inbound traffic.
+	inboundLocalRateLimitStatPrefix = "inbound_local_rate_limiter"
+)
+
+// inboundLocalRateLimit returns the local rate limiting of the inbound traffic of the proxy, if any.
+func inboundLocalRateLimit(node *model.Proxy) *routing.LocalRateLimit {
+	if node.SidecarScope == nil {
+		return nil
+	}
+	return node.SidecarScope.LocalRateLimit
+}
+
+// buildLocalRateLimitFilter returns the HTTP filter of the local rate limiting. The filter enforces the local rate
+// limit of the listener if any, and else only the local rate limits of the routes, configured per route. It returns
+// nil if neither is set.
+func buildLocalRateLimitFilter(push *model.PushContext, rateLimit *routing.LocalRateLimit) *hcm.HttpFilter {
+	var config *localratelimit.LocalRateLimit
+	switch {
+	case rateLimit != nil:
+		config = istio_route.BuildLocalRateLimit(inboundLocalRateLimitStatPrefix, rateLimit)
+	case push.HasRouteLocalRateLimits():
+		// The filter is disabled unless enabled by the configuration of a route.
+		config = &localratelimit.LocalRateLimit{StatPrefix: istio_route.RouteLocalRateLimitStatPrefix}
+	default:
+		return nil
+	}
+	return &hcm.HttpFilter{
+		Name:       istio_route.LocalRateLimitFilterName,
+		ConfigType: &hcm.HttpFilter_TypedConfig{TypedConfig: util.MessageToAny(config)},
+	}
+}
+
+// buildLocalRateLimitNetworkFilter returns the network filter limiting the rate of the connections with the token
+// bucket of the local rate limit.
+func buildLocalRateLimitNetworkFilter(rateLimit *routing.LocalRateLimit) *listener.Filter {
+	config := &networklocalratelimit.LocalRateLimit{
+		StatPrefix:  inboundLocalRateLimitStatPrefix,
+		TokenBucket: istio_route.BuildTokenBucket(rateLimit.TokenBucket),
+	}
+	return &listener.Filter{
+		Name:       localRateLimitNetworkFilterName,
+		ConfigType: &listener.Filter_TypedConfig{TypedConfig: util.MessageToAny(config)},
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package route
+
+import (
+	"sort"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	ratelimitcommon "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	localratelimit "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/local_ratelimit/v3"
+	xdstype "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/wrappers"
+
+	"istio.io/istio/pkg/config/routing"
+)
+
+// LocalRateLimitFilterName is the name of the HTTP filter of the local rate limiting.
+const LocalRateLimitFilterName = "envoy.filters.http.local_ratelimit"
+
+// localRateLimitStage is the stage of the rate limit actions of the local rate limiting, which keeps them apart from
+// the actions of the global rate limiting using the stage 0.
+const localRateLimitStage = 1
+
+// RouteLocalRateLimitStatPrefix is the stat prefix of the local rate limits of the routes.
+const RouteLocalRateLimitStatPrefix = "http_local_rate_limiter"
+
+// BuildLocalRateLimit returns the configuration of the HTTP local rate limit filter enforcing the local rate limit.
+func BuildLocalRateLimit(statPrefix string, rateLimit *routing.LocalRateLimit) *localratelimit.LocalRateLimit {
+	out := &localratelimit.LocalRateLimit{
+		StatPrefix:     statPrefix,
+		TokenBucket:    BuildTokenBucket(rateLimit.TokenBucket),
+		FilterEnabled:  fullRuntimeFraction(),
+		FilterEnforced: fullRuntimeFraction(),
+		Stage:          localRateLimitStage,
+	}
+	for name, value := range rateLimit.ResponseHeaders {
+		out.ResponseHeadersToAdd = append(out.ResponseHeadersToAdd, &core.HeaderValueOption{
+			Header: &core.HeaderValue{Key: name, Value: value},
+			Append: &wrappers.BoolValue{Value: false},
+		})
+	}
+	sort.Stable(SortHeaderValueOption(out.ResponseHeadersToAdd))
+	for _, descriptor := range rateLimit.Descriptors {
+		entries := make([]*ratelimitcommon.RateLimitDescriptor_Entry, 0, len(descriptor.Entries))
+		for _, entry := range descriptor.Entries {
+			entries = append(entries, &ratelimitcommon.RateLimitDescriptor_Entry{
+				Key:   localRateLimitEntryKey(entry),
+				Value: entry.Value,
+			})
+		}
+		out.Descriptors = append(out.Descriptors, &ratelimitcommon.LocalRateLimitDescriptor{
+			Entries:     entries,
+			TokenBucket: BuildTokenBucket(descriptor.TokenBucket),
+		})
+	}
+	return out
+}
+
+// BuildLocalRateLimitActions returns the rate limit actions generating the descriptors of the local rate limit.
+func BuildLocalRateLimitActions(rateLimit *routing.LocalRateLimit) []*route.RateLimit {
+	if len(rateLimit.Descriptors) == 0 {
+		return nil
+	}
+	out := make([]*route.RateLimit, 0, len(rateLimit.Descriptors))
+	for _, descriptor := range rateLimit.Descriptors {
+		actions := make([]*route.RateLimit_Action, 0, len(descriptor.Entries))
+		for _, entry := range descriptor.Entries {
+			actions = append(actions, localRateLimitAction(entry))
+		}
+		out = append(out, &route.RateLimit{
+			Stage:   &wrappers.UInt32Value{Value: localRateLimitStage},
+			Actions: actions,
+		})
+	}
+	return out
+}
+
+// BuildTokenBucket returns the token bucket of a local rate limit.
+func BuildTokenBucket(bucket routing.TokenBucket) *xdstype.TokenBucket {
+	out := &xdstype.TokenBucket{
+		MaxTokens:    bucket.MaxTokens,
+		FillInterval: ptypes.DurationProto(bucket.FillInterval),
+	}
+	if bucket.TokensPerFill > 0 {
+		out.TokensPerFill = &wrappers.UInt32Value{Value: bucket.TokensPerFill}
+	}
+	return out
+}
+
+func localRateLimitAction(entry routing.RateLimitEntry) *route.RateLimit_Action {
+	switch {
+	case entry.Header != "":
+		return &route.RateLimit_Action{
+			ActionSpecifier: &route.RateLimit_Action_RequestHeaders_{
+				RequestHeaders: &route.RateLimit_Action_RequestHeaders{
+					HeaderName:    entry.Header,
+					DescriptorKey: entry.Key,
+				},
+			},
+		}
+	case entry.RemoteAddress:
+		return &route.RateLimit_Action{
+			ActionSpecifier: &route.RateLimit_Action_RemoteAddress_{
+				RemoteAddress: &route.RateLimit_Action_RemoteAddress{},
+			},
+		}
+	default:
+		return &route.RateLimit_Action{
+			ActionSpecifier: &route.RateLimit_Action_GenericKey_{
+				GenericKey: &route.RateLimit_Action_GenericKey{
+					DescriptorValue: entry.Value,
+					DescriptorKey:   entry.Key,
+				},
+			},
+		}
+	}
+}
+
+// localRateLimitEntryKey returns the key of the descriptor entry generated by the action of the entry.
+func localRateLimitEntryKey(entry routing.RateLimitEntry) string {
+	if entry.RemoteAddress {
+		return "remote_address"
+	}
+	return entry.Key
+}
+
+// fullRuntimeFraction returns a runtime fraction of 100%.
+func fullRuntimeFraction() *core.RuntimeFractionalPercent {
+	return &core.RuntimeFractionalPercent{
+		DefaultValue: &xdstype.FractionalPercent{
+			Numerator:   100,
+			Denominator: xdstype.FractionalPercent_HUNDRED,
+		},
+	}
+}
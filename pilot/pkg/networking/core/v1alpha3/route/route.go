@@ -24,6 +24,8 @@ import (
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	xdsfault "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/common/fault/v3"
 	xdshttpfault "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/fault/v3"
+	envoy_jwt "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
+	xdsratelimit "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ratelimit/v3"
 	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
 	xdstype "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	wellknown "github.com/envoyproxy/go-control-plane/pkg/wellknown"
@@ -37,10 +39,13 @@ import (
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/route/retry"
 	"istio.io/istio/pilot/pkg/networking/util"
+	authn_model "istio.io/istio/pilot/pkg/security/model"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/routing"
+	"istio.io/istio/pkg/config/security"
 	"istio.io/istio/pkg/util/gogo"
 	"istio.io/pkg/log"
 )
@@ -52,6 +57,9 @@ const (
 	HeaderScheme    = ":scheme"
 )
 
+// retriableHeadersRetryOn is the retry condition of the responses with a retriable header.
+const retriableHeadersRetryOn = "retriable-headers"
+
 // DefaultRouteName is the name assigned to a route generated by default in absence of a virtual service.
 const DefaultRouteName = "default"
 
@@ -284,18 +292,19 @@ func BuildHTTPRoutesForVirtualService(
 	}
 
 	out := make([]*route.Route, 0, len(vs.Http))
+	jwtRouteRequirements := gatewayJwtRouteRequirements(node, push)
 
 allroutes:
 	for _, http := range vs.Http {
 		if len(http.Match) == 0 {
-			if r := translateRoute(push, node, http, nil, listenPort, virtualService, serviceRegistry, gatewayNames); r != nil {
+			if r := translateRoute(push, node, http, nil, listenPort, virtualService, serviceRegistry, gatewayNames, jwtRouteRequirements); r != nil {
 				out = append(out, r)
 			}
 			// We have a rule with catch all match. Other rules are of no use.
 			break
 		} else {
 			for _, match := range http.Match {
-				if r := translateRoute(push, node, http, match, listenPort, virtualService, serviceRegistry, gatewayNames); r != nil {
+				if r := translateRoute(push, node, http, match, listenPort, virtualService, serviceRegistry, gatewayNames, jwtRouteRequirements); r != nil {
 					out = append(out, r)
 					// This is a catch all path. Routes are matched in order, so we will never go beyond this match
 					// As an optimization, we can just top sending any more routes here.
@@ -339,7 +348,8 @@ func translateRoute(push *model.PushContext, node *model.Proxy, in *networking.H
 	match *networking.HTTPMatchRequest, port int,
 	virtualService config.Config,
 	serviceRegistry map[host.Name]*model.Service,
-	gatewayNames map[string]bool) *route.Route {
+	gatewayNames map[string]bool,
+	jwtRouteRequirements map[string]security.JwtRouteRequirement) *route.Route {
 	// When building routes, its okay if the target cluster cannot be
 	// resolved Traffic to such clusters will blackhole.
 
@@ -354,7 +364,7 @@ func translateRoute(push *model.PushContext, node *model.Proxy, in *networking.H
 	}
 
 	out := &route.Route{
-		Match:    translateRouteMatch(match, node),
+		Match:    TranslateRouteMatch(match, node),
 		Metadata: util.BuildConfigInfoMetadata(virtualService.Meta),
 	}
 
@@ -404,6 +414,13 @@ func translateRoute(push *model.PushContext, node *model.Proxy, in *networking.H
 			Cors:        translateCORSPolicy(in.CorsPolicy, node),
 			RetryPolicy: retry.ConvertPolicy(in.Retries),
 		}
+		retryPolicy := routeRetryPolicy(virtualService, in.Name)
+		if action.RetryPolicy != nil && retryPolicy != nil {
+			applyRetriableHeaders(action.RetryPolicy, retryPolicy, node)
+		}
+		if retryPolicy != nil && retryPolicy.Hedge != nil {
+			action.HedgePolicy = translateHedgePolicy(retryPolicy.Hedge)
+		}
 
 		// Configure timeouts specified by Virtual Service if they are provided, otherwise set it to defaults.
 		var d *duration.Duration
@@ -430,6 +447,9 @@ func translateRoute(push *model.PushContext, node *model.Proxy, in *networking.H
 			// nolint: staticcheck
 			action.MaxGrpcTimeout = d
 		}
+		if timeouts := routeTimeouts(virtualService, in.Name); timeouts != nil {
+			applyRouteTimeouts(action, timeouts, node)
+		}
 		out.Action = &route.Route_Route{Route: action}
 
 		if rewrite := in.Rewrite; rewrite != nil {
@@ -438,6 +458,16 @@ func translateRoute(push *model.PushContext, node *model.Proxy, in *networking.H
 				HostRewriteLiteral: rewrite.Authority,
 			}
 		}
+		if rewrite := routeRegexRewrite(virtualService, in.Name); rewrite != nil && action.PrefixRewrite == "" {
+			action.RegexRewrite = &matcher.RegexMatchAndSubstitute{
+				Pattern: &matcher.RegexMatcher{
+					// nolint: staticcheck
+					EngineType: regexMatcher(node),
+					Regex:      rewrite.Match,
+				},
+				Substitution: rewrite.Substitution,
+			}
+		}
 
 		if in.Mirror != nil {
 			if mp := mirrorPercent(in); mp != nil {
@@ -448,6 +478,19 @@ func translateRoute(push *model.PushContext, node *model.Proxy, in *networking.H
 				}}
 			}
 		}
+		if in.Name != "" {
+			for _, mirror := range model.RouteMirrors(virtualService)[in.Name] {
+				if mp := routeMirrorPercent(mirror); mp != nil {
+					action.RequestMirrorPolicies = append(action.RequestMirrorPolicies, &route.RouteAction_RequestMirrorPolicy{
+						Cluster:         GetDestinationCluster(mirror.Destination, serviceRegistry[host.Name(mirror.Destination.Host)], port),
+						RuntimeFraction: mp,
+						TraceSampled:    &wrappers.BoolValue{Value: false},
+					})
+				}
+			}
+		}
+
+		hasRouteCluster := hasRouteConnectionPool(push, virtualService, in.Name) || (retryPolicy != nil && retryPolicy.Budget != nil)
 
 		// TODO: eliminate this logic and use the total_weight option in envoy route
 		weighted := make([]*route.WeightedCluster_ClusterWeight, 0)
@@ -467,6 +510,9 @@ func translateRoute(push *model.PushContext, node *model.Proxy, in *networking.H
 
 			hostname := host.Name(dst.GetDestination().GetHost())
 			n := GetDestinationCluster(dst.Destination, serviceRegistry[hostname], port)
+			if hasRouteCluster {
+				n = routeCluster(n, in.Name, virtualService)
+			}
 
 			clusterWeight := &route.WeightedCluster_ClusterWeight{
 				Name:                    n,
@@ -509,12 +555,177 @@ func translateRoute(push *model.PushContext, node *model.Proxy, in *networking.H
 		Operation: getRouteOperation(out, virtualService.Name, port),
 	}
 	if fault := in.Fault; fault != nil {
-		out.TypedPerFilterConfig[wellknown.Fault] = util.MessageToAny(translateFault(in.Fault))
+		out.TypedPerFilterConfig[wellknown.Fault] = util.MessageToAny(TranslateFault(in.Fault))
+	}
+	if rateLimit := routeLocalRateLimit(virtualService, in.Name); rateLimit != nil {
+		out.TypedPerFilterConfig[LocalRateLimitFilterName] = util.MessageToAny(BuildLocalRateLimit(RouteLocalRateLimitStatPrefix,
+			rateLimit))
+		if action := out.GetRoute(); action != nil && len(rateLimit.Descriptors) > 0 {
+			action.RateLimits = append(action.RateLimits, BuildLocalRateLimitActions(rateLimit)...)
+			// The rate limits of the route would otherwise replace those of its virtual host in the global rate limiting.
+			out.TypedPerFilterConfig[wellknown.HTTPRateLimit] = util.MessageToAny(&xdsratelimit.RateLimitPerRoute{
+				VhRateLimits: xdsratelimit.RateLimitPerRoute_INCLUDE,
+			})
+		}
+	}
+	if requirement, f := jwtRouteRequirements[in.Name]; f && in.Name != "" {
+		out.TypedPerFilterConfig[authn_model.EnvoyJwtFilterName] = util.MessageToAny(buildJwtPerRouteConfig(requirement))
 	}
 
 	return out
 }
 
+// gatewayJwtRouteRequirements returns the JWT route requirements of the request authentications of the gateway,
+// keyed by route name. The JWT filter is only on the listeners of the routes of VirtualServices on gateways.
+func gatewayJwtRouteRequirements(node *model.Proxy, push *model.PushContext) map[string]security.JwtRouteRequirement {
+	if node.Type != model.Router || push.AuthnPolicies == nil {
+		return nil
+	}
+	return push.AuthnPolicies.GetJwtRouteRequirementsForWorkload(node.Metadata.Namespace, labels.Collection{node.Metadata.Labels})
+}
+
+// buildJwtPerRouteConfig builds the JWT filter config of a route with a requirement override, either disabling the
+// filter or selecting the requirement of the route from the requirement map of the filter.
+func buildJwtPerRouteConfig(requirement security.JwtRouteRequirement) *envoy_jwt.PerRouteConfig {
+	if requirement.Skip {
+		return &envoy_jwt.PerRouteConfig{
+			RequirementSpecifier: &envoy_jwt.PerRouteConfig_Disabled{Disabled: true},
+		}
+	}
+	return &envoy_jwt.PerRouteConfig{
+		RequirementSpecifier: &envoy_jwt.PerRouteConfig_RequirementName{RequirementName: requirement.Route},
+	}
+}
+
+// hasRouteConnectionPool returns true if the virtual service overrides the connection pool of the destinations of
+// the named HTTP route, which are then sent to the clusters dedicated to the route.
+func hasRouteConnectionPool(push *model.PushContext, virtualService config.Config, routeName string) bool {
+	if routeName == "" {
+		return false
+	}
+	_, f := push.RouteConnectionPools(virtualService)[routeName]
+	return f
+}
+
+// routeRetryPolicy returns the retry policy of the named HTTP route set on the virtual service, or nil.
+func routeRetryPolicy(virtualService config.Config, routeName string) *routing.RetryPolicy {
+	value, f := virtualService.Annotations[routing.RetryPolicyAnnotation]
+	if !f || routeName == "" {
+		return nil
+	}
+	policies, err := routing.ParseRetryPolicies(value)
+	if err != nil {
+		log.Debugf("ignored %s of virtual service %s/%s: %v", routing.RetryPolicyAnnotation,
+			virtualService.Namespace, virtualService.Name, err)
+		return nil
+	}
+	return policies[routeName]
+}
+
+// routeLocalRateLimit returns the local rate limit of the named HTTP route set on the virtual service, or nil.
+func routeLocalRateLimit(virtualService config.Config, routeName string) *routing.LocalRateLimit {
+	value, f := virtualService.Annotations[routing.RouteLocalRateLimitAnnotation]
+	if !f || routeName == "" {
+		return nil
+	}
+	rateLimits, err := routing.ParseRouteLocalRateLimits(value)
+	if err != nil {
+		log.Debugf("ignored %s of virtual service %s/%s: %v", routing.RouteLocalRateLimitAnnotation,
+			virtualService.Namespace, virtualService.Name, err)
+		return nil
+	}
+	return rateLimits[routeName]
+}
+
+// routeTimeouts returns the stream timeouts of the named HTTP route set on the virtual service, or nil.
+func routeTimeouts(virtualService config.Config, routeName string) *routing.RouteTimeouts {
+	value, f := virtualService.Annotations[routing.RouteTimeoutsAnnotation]
+	if !f || routeName == "" {
+		return nil
+	}
+	timeouts, err := routing.ParseRouteTimeouts(value)
+	if err != nil {
+		log.Debugf("ignored %s of virtual service %s/%s: %v", routing.RouteTimeoutsAnnotation,
+			virtualService.Namespace, virtualService.Name, err)
+		return nil
+	}
+	return timeouts[routeName]
+}
+
+// routeRegexRewrite returns the regex rewrite of the named HTTP route set on the virtual service, or nil.
+func routeRegexRewrite(virtualService config.Config, routeName string) *routing.RegexRewrite {
+	value, f := virtualService.Annotations[routing.RegexRewriteAnnotation]
+	if !f || routeName == "" {
+		return nil
+	}
+	rewrites, err := routing.ParseRegexRewrites(value)
+	if err != nil {
+		log.Debugf("ignored %s of virtual service %s/%s: %v", routing.RegexRewriteAnnotation,
+			virtualService.Namespace, virtualService.Name, err)
+		return nil
+	}
+	return rewrites[routeName]
+}
+
+// applyRouteTimeouts overrides the stream timeouts of a route action. The max stream duration is only supported by
+// proxies of 1.8 and later, which otherwise keep the route timeout.
+func applyRouteTimeouts(action *route.RouteAction, timeouts *routing.RouteTimeouts, node *model.Proxy) {
+	if timeouts.IdleTimeout != nil {
+		action.IdleTimeout = ptypes.DurationProto(*timeouts.IdleTimeout)
+	}
+	if timeouts.MaxStreamDuration != nil && util.IsIstioVersionGE18(node) {
+		if action.MaxStreamDuration == nil {
+			action.MaxStreamDuration = &route.RouteAction_MaxStreamDuration{}
+		}
+		action.MaxStreamDuration.MaxStreamDuration = ptypes.DurationProto(*timeouts.MaxStreamDuration)
+	}
+}
+
+// translateHedgePolicy translates the hedge policy of a route.
+func translateHedgePolicy(in *routing.HedgePolicy) *route.HedgePolicy {
+	out := &route.HedgePolicy{HedgeOnPerTryTimeout: in.HedgeOnPerTryTimeout}
+	if in.InitialRequests > 0 {
+		out.InitialRequests = &wrappers.UInt32Value{Value: in.InitialRequests}
+	}
+	return out
+}
+
+// applyRetriableHeaders adds the retriable response and request headers of the retry policy of a route to its
+// Envoy retry policy. Responses with a retriable header are only retried with the retriable-headers retry condition,
+// which is added if missing.
+func applyRetriableHeaders(out *route.RetryPolicy, in *routing.RetryPolicy, node *model.Proxy) {
+	for _, name := range sortedHeaderNames(in.RetriableHeaders) {
+		out.RetriableHeaders = append(out.RetriableHeaders, translateHeaderMatch(name, in.RetriableHeaders[name], node))
+	}
+	for _, name := range sortedHeaderNames(in.RetriableRequestHeaders) {
+		out.RetriableRequestHeaders = append(out.RetriableRequestHeaders,
+			translateHeaderMatch(name, in.RetriableRequestHeaders[name], node))
+	}
+	if len(out.RetriableHeaders) > 0 && !strings.Contains(out.RetryOn, retriableHeadersRetryOn) {
+		if out.RetryOn == "" {
+			out.RetryOn = retriableHeadersRetryOn
+		} else {
+			out.RetryOn += "," + retriableHeadersRetryOn
+		}
+	}
+}
+
+func sortedHeaderNames(headers map[string]*networking.StringMatch) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// routeCluster returns the name of the cluster dedicated to an HTTP route for the given destination cluster.
+func routeCluster(clusterName, routeName string, virtualService config.Config) string {
+	direction, subset, hostname, port := model.ParseSubsetKey(clusterName)
+	subset = routing.Subset(subset, routeName, virtualService.Name, virtualService.Namespace)
+	return model.BuildSubsetKey(direction, subset, hostname, port)
+}
+
 // SortHeaderValueOption type and the functions below (Len, Less and Swap) are for sort.Stable for type HeaderValueOption
 type SortHeaderValueOption []*core.HeaderValueOption
 
@@ -545,6 +756,22 @@ func mirrorPercent(in *networking.HTTPRoute) *core.RuntimeFractionalPercent {
 	}
 }
 
+// routeMirrorPercent returns the percentage of the traffic sent to a mirror added with routing.MirrorsAnnotation,
+// or nil if it is explicitly set to zero.
+func routeMirrorPercent(mirror *routing.Mirror) *core.RuntimeFractionalPercent {
+	if mirror.Percentage == nil {
+		return &core.RuntimeFractionalPercent{
+			DefaultValue: translateIntegerToFractionalPercent(100),
+		}
+	}
+	if mirror.Percentage.GetValue() > 0 {
+		return &core.RuntimeFractionalPercent{
+			DefaultValue: translatePercentToFractionalPercent(mirror.Percentage),
+		}
+	}
+	return nil
+}
+
 // Len is i the sort.Interface for SortHeaderValueOption
 func (b SortHeaderValueOption) Len() int {
 	return len(b)
@@ -610,8 +837,8 @@ func translateHeadersOperations(headers *networking.Headers) headersOperations {
 	}
 }
 
-// translateRouteMatch translates match condition
-func translateRouteMatch(in *networking.HTTPMatchRequest, node *model.Proxy) *route.RouteMatch {
+// TranslateRouteMatch translates match condition
+func TranslateRouteMatch(in *networking.HTTPMatchRequest, node *model.Proxy) *route.RouteMatch {
 	out := &route.RouteMatch{PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"}}
 	if in == nil {
 		return out
@@ -861,7 +1088,7 @@ func BuildDefaultHTTPInboundRoute(node *model.Proxy, clusterName string, operati
 		routeAction.MaxGrpcTimeout = notimeout
 	}
 	val := &route.Route{
-		Match: translateRouteMatch(nil, node),
+		Match: TranslateRouteMatch(nil, node),
 		Decorator: &route.Decorator{
 			Operation: operation,
 		},
@@ -902,8 +1129,8 @@ func translateIntegerToFractionalPercent(p int32) *xdstype.FractionalPercent {
 	}
 }
 
-// translateFault translates networking.HTTPFaultInjection into Envoy's HTTPFault
-func translateFault(in *networking.HTTPFaultInjection) *xdshttpfault.HTTPFault {
+// TranslateFault translates networking.HTTPFaultInjection into Envoy's HTTPFault
+func TranslateFault(in *networking.HTTPFaultInjection) *xdshttpfault.HTTPFault {
 	if in == nil {
 		return nil
 	}
@@ -23,6 +23,7 @@ import (
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoyroute "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	"github.com/gogo/protobuf/types"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/wrappers"
@@ -37,6 +38,7 @@ import (
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/mesh"
 	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/routing"
 	"istio.io/istio/pkg/config/schema/collections"
 	"istio.io/istio/pkg/util/gogo"
 )
@@ -116,6 +118,89 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		g.Expect(routes[0].GetRoute().MaxStreamDuration.GrpcTimeoutHeaderMax.Seconds).To(gomega.Equal(int64(10)))
 	})
 
+	t.Run("for virtual service with route retry policy", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+
+		routes, err := route.BuildHTTPRoutesForVirtualService(node, nil, virtualServiceWithRouteRetryPolicy, serviceRegistry, 8080, gatewayNames)
+		xdstest.ValidateRoutes(t, routes)
+
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		g.Expect(len(routes)).To(gomega.Equal(1))
+		policy := routes[0].GetRoute().RetryPolicy
+		g.Expect(policy.RetryOn).To(gomega.Equal("5xx,retriable-headers"))
+		g.Expect(policy.RetriableHeaders).To(gomega.HaveLen(1))
+		g.Expect(policy.RetriableHeaders[0].Name).To(gomega.Equal("x-retry"))
+		g.Expect(policy.RetriableHeaders[0].GetExactMatch()).To(gomega.Equal("true"))
+		g.Expect(policy.RetriableRequestHeaders).To(gomega.HaveLen(1))
+		g.Expect(policy.RetriableRequestHeaders[0].GetPresentMatch()).To(gomega.BeTrue())
+		hedge := routes[0].GetRoute().HedgePolicy
+		g.Expect(hedge.GetInitialRequests().GetValue()).To(gomega.Equal(uint32(2)))
+		g.Expect(hedge.HedgeOnPerTryTimeout).To(gomega.BeTrue())
+		// The retry budget is applied to the cluster dedicated to the route.
+		g.Expect(routes[0].GetRoute().GetCluster()).To(gomega.Equal("outbound|8484|~checkout.acme.|*.example.org"))
+	})
+
+	t.Run("for virtual service with route mirrors", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+
+		routes, err := route.BuildHTTPRoutesForVirtualService(node, nil, virtualServiceWithRouteMirrors, serviceRegistry, 8080, gatewayNames)
+		xdstest.ValidateRoutes(t, routes)
+
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		g.Expect(len(routes)).To(gomega.Equal(1))
+		mirrors := routes[0].GetRoute().RequestMirrorPolicies
+		g.Expect(mirrors).To(gomega.HaveLen(3))
+		g.Expect(mirrors[0].Cluster).To(gomega.Equal("outbound|9999||mirror.foo"))
+		g.Expect(mirrors[0].RuntimeFraction.DefaultValue.Numerator).To(gomega.Equal(uint32(500000)))
+		g.Expect(mirrors[1].Cluster).To(gomega.Equal("outbound|8080|v2|staging.foo"))
+		g.Expect(mirrors[1].RuntimeFraction.DefaultValue.Numerator).To(gomega.Equal(uint32(125000)))
+		g.Expect(mirrors[2].Cluster).To(gomega.Equal("outbound|8080||test.bar"))
+		g.Expect(mirrors[2].RuntimeFraction.DefaultValue.Numerator).To(gomega.Equal(uint32(100)))
+	})
+
+	t.Run("for virtual service with route local rate limit", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+
+		routes, err := route.BuildHTTPRoutesForVirtualService(node, nil, virtualServiceWithRouteLocalRateLimit, serviceRegistry, 8080, gatewayNames)
+		xdstest.ValidateRoutes(t, routes)
+
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		g.Expect(len(routes)).To(gomega.Equal(1))
+		g.Expect(routes[0].TypedPerFilterConfig).To(gomega.HaveKey(route.LocalRateLimitFilterName))
+		g.Expect(routes[0].TypedPerFilterConfig).To(gomega.HaveKey(wellknown.HTTPRateLimit))
+		rateLimits := routes[0].GetRoute().RateLimits
+		g.Expect(rateLimits).To(gomega.HaveLen(1))
+		g.Expect(rateLimits[0].Stage.GetValue()).To(gomega.Equal(uint32(1)))
+		g.Expect(rateLimits[0].Actions[0].GetRequestHeaders().GetHeaderName()).To(gomega.Equal("x-user"))
+	})
+
+	t.Run("for virtual service with route timeouts", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+
+		routes, err := route.BuildHTTPRoutesForVirtualService(node, nil, virtualServiceWithRouteTimeouts, serviceRegistry, 8080, gatewayNames)
+		xdstest.ValidateRoutes(t, routes)
+
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		g.Expect(len(routes)).To(gomega.Equal(1))
+		g.Expect(routes[0].GetRoute().Timeout.Seconds).To(gomega.Equal(int64(10)))
+		g.Expect(routes[0].GetRoute().IdleTimeout.Seconds).To(gomega.Equal(int64(300)))
+		g.Expect(routes[0].GetRoute().MaxStreamDuration.MaxStreamDuration.Seconds).To(gomega.Equal(int64(0)))
+	})
+
+	t.Run("for virtual service with route regex rewrite", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+
+		routes, err := route.BuildHTTPRoutesForVirtualService(node, nil, virtualServiceWithRouteRegexRewrite, serviceRegistry, 8080, gatewayNames)
+		xdstest.ValidateRoutes(t, routes)
+
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		g.Expect(len(routes)).To(gomega.Equal(1))
+		rewrite := routes[0].GetRoute().RegexRewrite
+		g.Expect(rewrite.GetPattern().GetRegex()).To(gomega.Equal("^/api/v1/(.*)$"))
+		g.Expect(rewrite.GetSubstitution()).To(gomega.Equal(`/\1`))
+		g.Expect(routes[0].GetRoute().PrefixRewrite).To(gomega.BeEmpty())
+	})
+
 	t.Run("for virtual service with disabled timeout", func(t *testing.T) {
 		g := gomega.NewWithT(t)
 
@@ -786,6 +871,166 @@ var virtualServiceWithTimeout = config.Config{
 	},
 }
 
+var virtualServiceWithRouteRetryPolicy = config.Config{
+	Meta: config.Meta{
+		GroupVersionKind: collections.IstioNetworkingV1Alpha3Virtualservices.Resource().GroupVersionKind(),
+		Name:             "acme",
+		Annotations: map[string]string{
+			routing.RetryPolicyAnnotation: `{"checkout": {"budget": {"percent": 20},
+				"retriableHeaders": {"x-retry": {"exact": "true"}}, "retriableRequestHeaders": {"x-idempotent": {}},
+				"hedge": {"initialRequests": 2, "hedgeOnPerTryTimeout": true}}}`,
+		},
+	},
+	Spec: &networking.VirtualService{
+		Hosts:    []string{},
+		Gateways: []string{"some-gateway"},
+		Http: []*networking.HTTPRoute{
+			{
+				Name: "checkout",
+				Route: []*networking.HTTPRouteDestination{
+					{
+						Destination: &networking.Destination{
+							Host: "*.example.org",
+							Port: &networking.PortSelector{
+								Number: 8484,
+							},
+						},
+					},
+				},
+				Retries: &networking.HTTPRetry{
+					Attempts: 3,
+					RetryOn:  "5xx",
+				},
+			},
+		},
+	},
+}
+
+var virtualServiceWithRouteLocalRateLimit = config.Config{
+	Meta: config.Meta{
+		GroupVersionKind: collections.IstioNetworkingV1Alpha3Virtualservices.Resource().GroupVersionKind(),
+		Name:             "acme",
+		Annotations: map[string]string{
+			routing.RouteLocalRateLimitAnnotation: `{"checkout": {"maxTokens": 100, "fillInterval": "1s",
+				"descriptors": [{"entries": [{"key": "user", "header": "x-user", "value": "batch"}], "maxTokens": 10,
+				"fillInterval": "1s"}]}}`,
+		},
+	},
+	Spec: &networking.VirtualService{
+		Hosts:    []string{},
+		Gateways: []string{"some-gateway"},
+		Http: []*networking.HTTPRoute{
+			{
+				Name: "checkout",
+				Route: []*networking.HTTPRouteDestination{
+					{
+						Destination: &networking.Destination{
+							Host: "*.example.org",
+							Port: &networking.PortSelector{
+								Number: 8484,
+							},
+						},
+					},
+				},
+			},
+		},
+	},
+}
+
+var virtualServiceWithRouteTimeouts = config.Config{
+	Meta: config.Meta{
+		GroupVersionKind: collections.IstioNetworkingV1Alpha3Virtualservices.Resource().GroupVersionKind(),
+		Name:             "acme",
+		Annotations: map[string]string{
+			routing.RouteTimeoutsAnnotation: `{"events": {"idleTimeout": "5m", "maxStreamDuration": "0s"}}`,
+		},
+	},
+	Spec: &networking.VirtualService{
+		Hosts:    []string{},
+		Gateways: []string{"some-gateway"},
+		Http: []*networking.HTTPRoute{
+			{
+				Name:    "events",
+				Timeout: &types.Duration{Seconds: 10},
+				Route: []*networking.HTTPRouteDestination{
+					{
+						Destination: &networking.Destination{
+							Host: "*.example.org",
+							Port: &networking.PortSelector{
+								Number: 8484,
+							},
+						},
+					},
+				},
+			},
+		},
+	},
+}
+
+var virtualServiceWithRouteRegexRewrite = config.Config{
+	Meta: config.Meta{
+		GroupVersionKind: collections.IstioNetworkingV1Alpha3Virtualservices.Resource().GroupVersionKind(),
+		Name:             "acme",
+		Annotations: map[string]string{
+			routing.RegexRewriteAnnotation: `{"api": {"match": "^/api/v1/(.*)$", "substitution": "/\\1"}}`,
+		},
+	},
+	Spec: &networking.VirtualService{
+		Hosts:    []string{},
+		Gateways: []string{"some-gateway"},
+		Http: []*networking.HTTPRoute{
+			{
+				Name: "api",
+				Route: []*networking.HTTPRouteDestination{
+					{
+						Destination: &networking.Destination{
+							Host: "*.example.org",
+							Port: &networking.PortSelector{
+								Number: 8484,
+							},
+						},
+					},
+				},
+			},
+		},
+	},
+}
+
+var virtualServiceWithRouteMirrors = config.Config{
+	Meta: config.Meta{
+		GroupVersionKind: collections.IstioNetworkingV1Alpha3Virtualservices.Resource().GroupVersionKind(),
+		Name:             "acme",
+		Namespace:        "foo",
+		Annotations: map[string]string{
+			routing.MirrorsAnnotation: `{"checkout": [{"destination": {"host": "staging", "subset": "v2"}, "percentage": 12.5},
+				{"destination": {"host": "test.bar"}}, {"destination": {"host": "off.bar"}, "percentage": 0}]}`,
+		},
+	},
+	Spec: &networking.VirtualService{
+		Hosts:    []string{},
+		Gateways: []string{"some-gateway"},
+		Http: []*networking.HTTPRoute{
+			{
+				Name: "checkout",
+				Route: []*networking.HTTPRouteDestination{
+					{
+						Destination: &networking.Destination{
+							Host: "*.example.org",
+						},
+					},
+				},
+				Mirror: &networking.Destination{
+					Host: "mirror.foo",
+					Port: &networking.PortSelector{
+						Number: 9999,
+					},
+				},
+				MirrorPercentage: &networking.Percent{Value: 50},
+			},
+		},
+	},
+}
+
 var virtualServiceWithTimeoutDisabled = config.Config{
 	Meta: config.Meta{
 		GroupVersionKind: collections.IstioNetworkingV1Alpha3Virtualservices.Resource().GroupVersionKind(),
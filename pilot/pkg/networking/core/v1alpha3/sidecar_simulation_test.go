@@ -21,15 +21,21 @@ import (
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	fault "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/fault/v3"
+	xdstype "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/gogo/protobuf/types"
 	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/ptypes"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/core/v1alpha3"
+	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/route/retry"
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/pkg/simulation"
 	"istio.io/istio/pilot/pkg/xds"
@@ -528,7 +534,6 @@ func TestInboundClusters(t *testing.T) {
 				sim.Run(simulation.Call{
 					Port:     port,
 					Protocol: simulation.HTTP,
-					Address:  "1.2.3.4",
 					CallMode: simulation.CallModeInbound,
 				}).Matches(t, simulation.Result{
 					ClusterMatched: cname,
@@ -1320,7 +1325,8 @@ spec:
   addresses: [1.2.3.4]
   location: MESH_EXTERNAL
   resolution: DNS` + ports,
-						calls: testCalls,
+						calls:    testCalls,
+						parallel: true,
 					})
 			})
 			t.Run("without VIP", func(t *testing.T) {
@@ -1362,7 +1368,8 @@ spec:
   - istio.io
   location: MESH_EXTERNAL
   resolution: DNS` + ports,
-						calls: testCalls,
+						calls:    testCalls,
+						parallel: true,
 					})
 			})
 		})
@@ -1397,3 +1404,198 @@ func TestLoop(t *testing.T) {
 		},
 	})
 }
+
+func TestRoutePolicies(t *testing.T) {
+	retries := &networking.HTTPRetry{Attempts: 3, PerTryTimeout: &types.Duration{Seconds: 1}, RetryOn: "5xx"}
+	runSimulationTest(t, nil, xds.FakeOptions{}, simulationTest{
+		config: `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: se
+spec:
+  hosts:
+  - foo.bar
+  addresses: [1.2.3.4]
+  location: MESH_INTERNAL
+  resolution: DNS
+  ports:
+  - name: http
+    number: 80
+    protocol: HTTP
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: vs
+spec:
+  hosts:
+  - foo.bar
+  http:
+  - name: fault
+    match:
+    - uri:
+        prefix: /fault
+    fault:
+      abort:
+        httpStatus: 503
+        percentage:
+          value: 50
+    route:
+    - destination:
+        host: foo.bar
+  - name: policies
+    timeout: 5s
+    retries:
+      attempts: 3
+      perTryTimeout: 1s
+      retryOn: 5xx
+    route:
+    - destination:
+        host: foo.bar
+`,
+		calls: []simulation.Expect{
+			{
+				Name: "fault",
+				Call: simulation.Call{Address: "1.2.3.4", Port: 80, Protocol: simulation.HTTP, HostHeader: "foo.bar", Path: "/fault"},
+				Result: simulation.Result{
+					RouteMatched: "fault",
+					Timeout:      ptypes.DurationProto(0),
+					Fault: &fault.HTTPFault{Abort: &fault.FaultAbort{
+						ErrorType:  &fault.FaultAbort_HttpStatus{HttpStatus: 503},
+						Percentage: &xdstype.FractionalPercent{Numerator: 500000, Denominator: xdstype.FractionalPercent_MILLION},
+					}},
+				},
+			},
+			{
+				Name: "retry and timeout",
+				Call: simulation.Call{Address: "1.2.3.4", Port: 80, Protocol: simulation.HTTP, HostHeader: "foo.bar"},
+				Result: simulation.Result{
+					RouteMatched: "policies",
+					Timeout:      ptypes.DurationProto(5 * time.Second),
+					RetryPolicy:  retry.ConvertPolicy(retries),
+				},
+			},
+		},
+	})
+}
+
+func TestRouteConnectionPool(t *testing.T) {
+	runSimulationTest(t, nil, xds.FakeOptions{}, simulationTest{
+		config: `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: se
+spec:
+  hosts:
+  - foo.bar
+  addresses: [1.2.3.4]
+  location: MESH_INTERNAL
+  resolution: DNS
+  ports:
+  - name: http
+    number: 80
+    protocol: HTTP
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: vs
+  annotations:
+    networking.istio.io/routeConnectionPool: '{"chatty": {"http": {"maxRequestsPerConnection": 1}}}'
+spec:
+  hosts:
+  - foo.bar
+  http:
+  - name: chatty
+    match:
+    - uri:
+        prefix: /chatty
+    route:
+    - destination:
+        host: foo.bar
+  - name: default
+    route:
+    - destination:
+        host: foo.bar
+`,
+		calls: []simulation.Expect{
+			{
+				Name: "route with connection pool",
+				Call: simulation.Call{Address: "1.2.3.4", Port: 80, Protocol: simulation.HTTP, HostHeader: "foo.bar", Path: "/chatty"},
+				Result: simulation.Result{
+					RouteMatched:   "chatty",
+					ClusterMatched: "outbound|80|~chatty.vs.default|foo.bar",
+				},
+			},
+			{
+				Name: "route without connection pool",
+				Call: simulation.Call{Address: "1.2.3.4", Port: 80, Protocol: simulation.HTTP, HostHeader: "foo.bar"},
+				Result: simulation.Result{
+					RouteMatched:   "default",
+					ClusterMatched: "outbound|80||foo.bar",
+				},
+			},
+		},
+	})
+}
+
+func TestInboundProxyProtocol(t *testing.T) {
+	svc := `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: se
+spec:
+  hosts:
+  - foo.bar
+  endpoints:
+  - address: 1.1.1.1
+  location: MESH_INTERNAL
+  resolution: STATIC
+  ports:
+  - name: tcp
+    number: 70
+    protocol: TCP
+  - name: http
+    number: 80
+    protocol: HTTP
+`
+	calls := []simulation.Expect{
+		{
+			Name:   "tcp with proxy protocol",
+			Call:   simulation.Call{Port: 70, Protocol: simulation.TCP, CallMode: simulation.CallModeInbound, ProxyProtocol: simulation.ProxyProtocolV1},
+			Result: simulation.Result{ClusterMatched: "inbound|70||"},
+		},
+		{
+			Name: "http with proxy protocol",
+			Call: simulation.Call{Port: 80, Protocol: simulation.HTTP, CallMode: simulation.CallModeInbound, ProxyProtocol: simulation.ProxyProtocolV2},
+			Result: simulation.Result{
+				VirtualHostMatched: "inbound|http|80",
+				ClusterMatched:     "inbound|80||",
+			},
+		},
+		{
+			Name:   "tcp without proxy protocol",
+			Call:   simulation.Call{Port: 70, Protocol: simulation.TCP, CallMode: simulation.CallModeInbound},
+			Result: simulation.Result{Error: simulation.ErrProxyProtocol},
+		},
+	}
+	t.Run("metadata", func(t *testing.T) {
+		proxy := &model.Proxy{Metadata: &model.NodeMetadata{InboundProxyProtocol: true}}
+		runSimulationTest(t, proxy, xds.FakeOptions{}, simulationTest{config: svc, calls: calls})
+	})
+	t.Run("annotation", func(t *testing.T) {
+		proxy := &model.Proxy{Metadata: &model.NodeMetadata{InboundProxyProtocolAnnotation: "true"}}
+		runSimulationTest(t, proxy, xds.FakeOptions{}, simulationTest{config: svc, calls: calls})
+	})
+	t.Run("annotation disabling metadata", func(t *testing.T) {
+		proxy := &model.Proxy{Metadata: &model.NodeMetadata{InboundProxyProtocol: true, InboundProxyProtocolAnnotation: "false"}}
+		runSimulationTest(t, proxy, xds.FakeOptions{}, simulationTest{config: svc, calls: []simulation.Expect{{
+			Name:   "tcp without proxy protocol",
+			Call:   simulation.Call{Port: 70, Protocol: simulation.TCP, CallMode: simulation.CallModeInbound},
+			Result: simulation.Result{ClusterMatched: "inbound|70||"},
+		}}})
+	})
+}
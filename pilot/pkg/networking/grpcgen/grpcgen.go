@@ -24,11 +24,17 @@ import (
 	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	"github.com/golang/protobuf/ptypes/any"
+	"github.com/golang/protobuf/ptypes/wrappers"
 
+	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/model"
+	istioroute "istio.io/istio/pilot/pkg/networking/core/v1alpha3/route"
 	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/xds/filters"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/pkg/log"
 )
@@ -49,6 +55,26 @@ import (
 // using the generic structures. "Classical" CDS/LDS/RDS/EDS use separate logic -
 // this is used for the API-based LDS and generic messages.
 
+// The client features reported by the proxyless gRPC clients supporting the routing features of their version. The
+// clients not reporting them are only sent the default route of each service.
+const (
+	// ClientFeatureRouting enables the matches and weighted destinations of the VirtualService routes.
+	ClientFeatureRouting = "istio.io/grpc.routing"
+	// ClientFeatureRetries enables the retry policies of the VirtualService routes.
+	ClientFeatureRetries = "istio.io/grpc.retries"
+	// ClientFeatureFaultInjection enables the fault injection of the VirtualService routes.
+	ClientFeatureFaultInjection = "istio.io/grpc.fault-injection"
+)
+
+// grpcRetryOn are the retry conditions supported by gRPC, which only retries on status codes.
+var grpcRetryOn = map[string]bool{
+	"cancelled":          true,
+	"deadline-exceeded":  true,
+	"internal":           true,
+	"resource-exhausted": true,
+	"unavailable":        true,
+}
+
 type GrpcConfigGenerator struct{}
 
 func (g *GrpcConfigGenerator) Generate(proxy *model.Proxy, push *model.PushContext,
@@ -107,7 +133,7 @@ func (g *GrpcConfigGenerator) BuildListeners(node *model.Proxy, push *model.Push
 						},
 					},
 				}
-				hcm := &hcm.HttpConnectionManager{
+				manager := &hcm.HttpConnectionManager{
 					RouteSpecifier: &hcm.HttpConnectionManager_Rds{
 						Rds: &hcm.Rds{
 							ConfigSource: &core.ConfigSource{
@@ -119,7 +145,11 @@ func (g *GrpcConfigGenerator) BuildListeners(node *model.Proxy, push *model.Push
 						},
 					},
 				}
-				hcmAny := util.MessageToAny(hcm)
+				if node.SupportsClientFeature(ClientFeatureFaultInjection) {
+					// gRPC only applies the faults of the routes with the fault filter in the filter chain.
+					manager.HttpFilters = []*hcm.HttpFilter{filters.Fault, filters.Router}
+				}
+				hcmAny := util.MessageToAny(manager)
 				// TODO: for TCP listeners don't generate RDS, but some indication of cluster name.
 				ll.ApiListener = &listener.ApiListener{
 					ApiListener: hcmAny,
@@ -139,16 +169,22 @@ func (g *GrpcConfigGenerator) BuildClusters(node *model.Proxy, push *model.PushC
 	// gRPC doesn't currently support any of the APIs - returning just the expected EDS result.
 	// Since the code is relatively strict - we'll add info as needed.
 	for _, n := range names {
-		hn, portn, err := net.SplitHostPort(n)
-		if err != nil {
-			log.Warn("Failed to parse ", n, " ", err)
-			continue
+		serviceName := n
+		// The clusters of the VirtualService routes are named after the subset of their destination, the default
+		// route of a service uses its host:port.
+		if dir, _, hn, _ := model.ParseSubsetKey(n); dir != model.TrafficDirectionOutbound || hn == "" {
+			hn, portn, err := net.SplitHostPort(n)
+			if err != nil {
+				log.Warn("Failed to parse ", n, " ", err)
+				continue
+			}
+			serviceName = "outbound|" + portn + "||" + hn
 		}
 		rc := &cluster.Cluster{
 			Name:                 n,
 			ClusterDiscoveryType: &cluster.Cluster_Type{Type: cluster.Cluster_EDS},
 			EdsClusterConfig: &cluster.Cluster_EdsClusterConfig{
-				ServiceName: "outbound|" + portn + "||" + hn,
+				ServiceName: serviceName,
 				EdsConfig: &core.ConfigSource{
 					ConfigSourceSpecifier: &core.ConfigSource_Ads{
 						Ads: &core.AggregatedConfigSource{},
@@ -181,34 +217,24 @@ func (g *GrpcConfigGenerator) BuildHTTPRoutes(node *model.Proxy, push *model.Pus
 			continue
 		}
 		el := node.SidecarScope.GetEgressListenerForRDS(port, "")
-		// TODO: use VirtualServices instead !
-		// Currently gRPC doesn't support matching the path.
 		svc := el.Services()
 		for _, s := range svc {
 			if s.Hostname.Matches(host.Name(hn)) {
-				// Only generate the required route for grpc. Will need to generate more
-				// as GRPC adds more features.
+				// Clients without routing support only get the default route of the service.
+				var routes []*route.Route
+				if node.SupportsClientFeature(ClientFeatureRouting) {
+					routes = buildVirtualServiceRoutes(node, push, s, port)
+				}
+				if len(routes) == 0 {
+					routes = []*route.Route{defaultRoute(n)}
+				}
 				rc := &route.RouteConfiguration{
 					Name: n,
 					VirtualHosts: []*route.VirtualHost{
 						{
 							Name:    hn,
 							Domains: []string{hn, n},
-
-							Routes: []*route.Route{
-								{
-									Match: &route.RouteMatch{
-										PathSpecifier: &route.RouteMatch_Prefix{Prefix: ""},
-									},
-									Action: &route.Route_Route{
-										Route: &route.RouteAction{
-											ClusterSpecifier: &route.RouteAction_Cluster{
-												Cluster: n,
-											},
-										},
-									},
-								},
-							},
+							Routes:  routes,
 						},
 					},
 				}
@@ -218,3 +244,136 @@ func (g *GrpcConfigGenerator) BuildHTTPRoutes(node *model.Proxy, push *model.Pus
 	}
 	return resp
 }
+
+// defaultRoute routes all the requests to the cluster of the service, named after its host:port.
+func defaultRoute(clusterName string) *route.Route {
+	return &route.Route{
+		Match: &route.RouteMatch{
+			PathSpecifier: &route.RouteMatch_Prefix{Prefix: ""},
+		},
+		Action: &route.Route_Route{
+			Route: &route.RouteAction{
+				ClusterSpecifier: &route.RouteAction_Cluster{
+					Cluster: clusterName,
+				},
+			},
+		},
+	}
+}
+
+// buildVirtualServiceRoutes builds the routes of the first VirtualService of the service visible to the proxy,
+// limited to the features supported by gRPC and reported by the client.
+func buildVirtualServiceRoutes(node *model.Proxy, push *model.PushContext, svc *model.Service, port int) []*route.Route {
+	for _, cfg := range push.VirtualServicesForGateway(node, constants.IstioMeshGateway) {
+		vs := cfg.Spec.(*networking.VirtualService)
+		matched := false
+		for _, h := range vs.Hosts {
+			if host.Name(h).Matches(svc.Hostname) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		var routes []*route.Route
+		for _, http := range vs.Http {
+			action := buildRouteAction(node, http, svc, port)
+			if action == nil {
+				continue
+			}
+			matches := http.Match
+			if len(matches) == 0 {
+				matches = []*networking.HTTPMatchRequest{nil}
+			}
+			for _, match := range matches {
+				if match.GetPort() != 0 && int(match.GetPort()) != port {
+					continue
+				}
+				r := &route.Route{
+					Name:   http.Name,
+					Match:  istioroute.TranslateRouteMatch(match, node),
+					Action: &route.Route_Route{Route: action},
+				}
+				if http.Fault != nil && node.SupportsClientFeature(ClientFeatureFaultInjection) {
+					if f := istioroute.TranslateFault(http.Fault); f != nil {
+						r.TypedPerFilterConfig = map[string]*any.Any{wellknown.Fault: util.MessageToAny(f)}
+					}
+				}
+				routes = append(routes, r)
+			}
+		}
+		return routes
+	}
+	return nil
+}
+
+// buildRouteAction routes to the destinations of the route, weighted when there are several of them. It returns nil
+// for the routes gRPC does not support, such as redirects.
+func buildRouteAction(node *model.Proxy, http *networking.HTTPRoute, svc *model.Service, port int) *route.RouteAction {
+	var clusters []*route.WeightedCluster_ClusterWeight
+	total := uint32(0)
+	for _, dst := range http.Route {
+		weight := uint32(dst.Weight)
+		if weight == 0 && len(http.Route) > 1 {
+			continue
+		}
+		if weight == 0 {
+			weight = 100
+		}
+		clusters = append(clusters, &route.WeightedCluster_ClusterWeight{
+			Name:   istioroute.GetDestinationCluster(dst.Destination, svc, port),
+			Weight: &wrappers.UInt32Value{Value: weight},
+		})
+		total += weight
+	}
+	if len(clusters) == 0 {
+		return nil
+	}
+	action := &route.RouteAction{}
+	if len(clusters) == 1 {
+		action.ClusterSpecifier = &route.RouteAction_Cluster{Cluster: clusters[0].Name}
+	} else {
+		action.ClusterSpecifier = &route.RouteAction_WeightedClusters{
+			WeightedClusters: &route.WeightedCluster{
+				Clusters:    clusters,
+				TotalWeight: &wrappers.UInt32Value{Value: total},
+			},
+		}
+	}
+	if node.SupportsClientFeature(ClientFeatureRetries) {
+		action.RetryPolicy = buildRetryPolicy(http.Retries)
+	}
+	return action
+}
+
+// buildRetryPolicy converts the retries of a route to the retries on status codes of gRPC. As for the sidecars,
+// routes without retries are retried twice, and setting zero attempts disables them.
+func buildRetryPolicy(in *networking.HTTPRetry) *route.RetryPolicy {
+	if in == nil {
+		return &route.RetryPolicy{
+			RetryOn:    "cancelled,unavailable",
+			NumRetries: &wrappers.UInt32Value{Value: 2},
+		}
+	}
+	if in.Attempts <= 0 {
+		return nil
+	}
+	retryOn := []string{}
+	for _, on := range strings.Split(in.RetryOn, ",") {
+		if on = strings.TrimSpace(on); grpcRetryOn[on] {
+			retryOn = append(retryOn, on)
+		}
+	}
+	if len(retryOn) == 0 {
+		if in.RetryOn != "" {
+			// None of the conditions apply to gRPC.
+			return nil
+		}
+		retryOn = []string{"cancelled", "unavailable"}
+	}
+	return &route.RetryPolicy{
+		RetryOn:    strings.Join(retryOn, ","),
+		NumRetries: &wrappers.UInt32Value{Value: uint32(in.Attempts)},
+	}
+}
@@ -19,7 +19,10 @@ import (
 	"testing"
 	"time"
 
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/golang/protobuf/ptypes"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/balancer"
 	"google.golang.org/grpc/resolver"
@@ -33,6 +36,8 @@ import (
 
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/core/v1alpha3"
+	"istio.io/istio/pilot/pkg/networking/grpcgen"
 	"istio.io/istio/pilot/pkg/xds"
 
 	"istio.io/istio/pkg/config"
@@ -151,6 +156,104 @@ func TestGRPC(t *testing.T) {
 
 }
 
+func TestGRPCRoutes(t *testing.T) {
+	cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: echo
+  namespace: default
+spec:
+  hosts:
+  - echo.default.svc.cluster.local
+  addresses:
+  - 1.2.3.4
+  ports:
+  - number: 7070
+    name: grpc
+    protocol: GRPC
+  resolution: STATIC
+  endpoints:
+  - address: 10.0.0.1
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: echo
+  namespace: default
+spec:
+  hosts:
+  - echo.default.svc.cluster.local
+  http:
+  - match:
+    - uri:
+        prefix: /echo.Echo/
+    fault:
+      abort:
+        httpStatus: 503
+        percentage:
+          value: 10
+    retries:
+      attempts: 3
+      retryOn: unavailable,5xx
+    route:
+    - destination:
+        host: echo.default.svc.cluster.local
+        subset: v1
+      weight: 80
+    - destination:
+        host: echo.default.svc.cluster.local
+        subset: v2
+      weight: 20
+`})
+	routeName := "echo.default.svc.cluster.local:7070"
+	routes := func(features ...string) []*route.Route {
+		proxy := cg.SetupProxy(&model.Proxy{ClientFeatures: features})
+		resources := (&grpcgen.GrpcConfigGenerator{}).BuildHTTPRoutes(proxy, cg.PushContext(), []string{routeName})
+		if len(resources) != 1 {
+			t.Fatalf("expected a route configuration, got %d", len(resources))
+		}
+		rc := &route.RouteConfiguration{}
+		if err := ptypes.UnmarshalAny(resources[0], rc); err != nil {
+			t.Fatal(err)
+		}
+		return rc.VirtualHosts[0].Routes
+	}
+
+	t.Run("default route without routing support", func(t *testing.T) {
+		got := routes()
+		if len(got) != 1 || got[0].GetRoute().GetCluster() != routeName {
+			t.Fatalf("expected the default route to %s, got %v", routeName, got)
+		}
+	})
+
+	t.Run("weighted routes", func(t *testing.T) {
+		got := routes(grpcgen.ClientFeatureRouting)
+		if len(got) != 1 || got[0].Match.GetPrefix() != "/echo.Echo/" {
+			t.Fatalf("expected the route of the virtual service, got %v", got)
+		}
+		clusters := got[0].GetRoute().GetWeightedClusters().GetClusters()
+		if len(clusters) != 2 || clusters[0].Name != "outbound|7070|v1|echo.default.svc.cluster.local" ||
+			clusters[0].Weight.GetValue() != 80 || clusters[1].Weight.GetValue() != 20 {
+			t.Errorf("unexpected weighted clusters %v", clusters)
+		}
+		if got[0].GetRoute().RetryPolicy != nil || got[0].TypedPerFilterConfig != nil {
+			t.Errorf("expected no retries nor faults for a client not supporting them")
+		}
+	})
+
+	t.Run("retries and faults", func(t *testing.T) {
+		got := routes(grpcgen.ClientFeatureRouting, grpcgen.ClientFeatureRetries, grpcgen.ClientFeatureFaultInjection)
+		retries := got[0].GetRoute().RetryPolicy
+		if retries.GetRetryOn() != "unavailable" || retries.GetNumRetries().GetValue() != 3 {
+			t.Errorf("expected the gRPC retry conditions of the route, got %v", retries)
+		}
+		if _, f := got[0].TypedPerFilterConfig[wellknown.Fault]; !f {
+			t.Errorf("expected the fault of the route")
+		}
+	})
+}
+
 type testLBClientConn struct {
 	balancer.ClientConn
 }
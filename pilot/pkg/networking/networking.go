@@ -63,7 +63,7 @@ func ModelProtocolToListenerProtocol(p protocol.Instance,
 	switch p {
 	case protocol.HTTP, protocol.HTTP2, protocol.GRPC, protocol.GRPCWeb:
 		return ListenerProtocolHTTP
-	case protocol.TCP, protocol.HTTPS, protocol.TLS,
+	case protocol.TCP, protocol.HTTPS, protocol.HTTP3, protocol.TLS,
 		protocol.Mongo, protocol.Redis, protocol.MySQL:
 		return ListenerProtocolTCP
 	case protocol.Thrift:
@@ -123,6 +123,8 @@ type MutableObjects struct {
 const (
 	NoTunnelTypeName = "notunnel"
 	H2TunnelTypeName = "H2Tunnel"
+	// HBONETunnelTypeName is the name of the tunnel of the TCP traffic over HTTP CONNECT to the HBONE port.
+	HBONETunnelTypeName = "HBONE"
 )
 
 type (
@@ -135,6 +137,8 @@ const (
 	NoTunnel TunnelType = 0
 	// Enumeration of tunnel type below. Each type should own a unique bit field.
 	H2Tunnel TunnelType = 1 << 0
+	// HBONETunnel is the tunnel terminated by the sidecars on the HBONE port.
+	HBONETunnel TunnelType = 1 << 1
 )
 
 func MakeTunnelAbility(ttypes ...TunnelType) TunnelAbility {
@@ -149,6 +153,8 @@ func (t TunnelType) ToString() string {
 	switch t {
 	case H2Tunnel:
 		return H2TunnelTypeName
+	case HBONETunnel:
+		return HBONETunnelTypeName
 	default:
 		return NoTunnelTypeName
 	}
@@ -157,3 +163,7 @@ func (t TunnelType) ToString() string {
 func (t TunnelAbility) SupportH2Tunnel() bool {
 	return (int(t) & int(H2Tunnel)) != 0
 }
+
+func (t TunnelAbility) SupportHBONETunnel() bool {
+	return (int(t) & int(HBONETunnel)) != 0
+}
@@ -85,7 +85,7 @@ func buildFilter(in *plugin.InputParams, mutable *networking.MutableObjects, isP
 				mutable.FilterChains[i].HTTP = append(mutable.FilterChains[i].HTTP, filter)
 			}
 			istioMutualGateway := (in.Node.Type == model.Router) && mutable.FilterChains[i].IstioMutualGateway
-			if filter := applier.AuthNFilter(in.Node.Type, endpointPort, istioMutualGateway); filter != nil {
+			if filter := applier.AuthNFilter(in.Node, endpointPort, istioMutualGateway); filter != nil {
 				mutable.FilterChains[i].HTTP = append(mutable.FilterChains[i].HTTP, filter)
 			}
 		}
@@ -112,7 +112,7 @@ func (Plugin) OnInboundPassthroughFilterChains(in *plugin.InputParams) []network
 	filterChains := applier.InboundFilterChain(0, in.Node, in.ListenerProtocol, trustDomains)
 
 	// Then generate the per-port passthrough filter chains.
-	for port := range applier.PortLevelSetting() {
+	for port := range applier.PortLevelSetting(in.Node) {
 		// Skip the per-port passthrough filterchain if the port is already handled by OnInboundFilterChains().
 		if !needPerPortPassthroughFilterChain(port, in.Node) {
 			continue
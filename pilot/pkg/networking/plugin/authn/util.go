@@ -17,11 +17,12 @@ package authn
 import (
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/security/trustdomain"
 )
 
 func trustDomainsForValidation(meshConfig *meshconfig.MeshConfig) []string {
 	if features.SkipValidateTrustDomain.Get() {
 		return nil
 	}
-	return append([]string{meshConfig.TrustDomain}, meshConfig.TrustDomainAliases...)
+	return append([]string{meshConfig.TrustDomain}, trustdomain.MeshAliases(meshConfig)...)
 }
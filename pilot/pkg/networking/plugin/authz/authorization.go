@@ -83,7 +83,7 @@ func (p Plugin) buildFilter(in *plugin.InputParams, mutable *networking.MutableO
 
 	// TODO: Get trust domain from MeshConfig instead.
 	// https://github.com/istio/istio/issues/17873
-	tdBundle := trustdomain.NewBundle(spiffe.GetTrustDomain(), in.Push.Mesh.TrustDomainAliases)
+	tdBundle := trustdomain.NewBundle(spiffe.GetTrustDomain(), trustdomain.MeshAliases(in.Push.Mesh))
 	option := builder.Option{
 		IsCustomBuilder: p.actionType == Custom,
 		Logger:          &builder.AuthzLogger{},
@@ -90,6 +90,14 @@ const (
 	// level tls transport socket configuration
 	EnvoyTLSSocketName = wellknown.TransportSocketTls
 
+	// EnvoyQUICSocketName matched with hardcoded built-in Envoy transport name which determines
+	// listener level QUIC transport socket configuration
+	EnvoyQUICSocketName = wellknown.TransportSocketQuic
+
+	// EnvoyProxyProtocolSocketName matched with hardcoded built-in Envoy transport name which determines
+	// cluster level transport socket sending a PROXY protocol header before the data of the wrapped socket
+	EnvoyProxyProtocolSocketName = "envoy.transport_sockets.upstream_proxy_protocol"
+
 	// StatName patterns
 	serviceStatPattern         = "%SERVICE%"
 	serviceFQDNStatPattern     = "%SERVICE_FQDN%"
@@ -457,6 +465,29 @@ func AddSubsetToMetadata(md *core.Metadata, subset string) *core.Metadata {
 	return updatedMeta
 }
 
+// AddMTLSModeToMetadata adds the effective mTLS mode of the inbound port to the "istio" metadata of an
+// inbound cluster. This is used for telemetry reporting: combined with the TLS state of the connection,
+// it identifies requests and connections that arrived as plaintext while the PERMISSIVE mode was active,
+// e.g. with "cluster_metadata.filter_metadata['istio'].mtls_mode == 'PERMISSIVE' && !connection.mtls".
+func AddMTLSModeToMetadata(metadata *core.Metadata, mode string) *core.Metadata {
+	if metadata == nil {
+		metadata = &core.Metadata{
+			FilterMetadata: map[string]*pstruct.Struct{},
+		}
+	}
+	if _, ok := metadata.FilterMetadata[IstioMetadataKey]; !ok {
+		metadata.FilterMetadata[IstioMetadataKey] = &pstruct.Struct{
+			Fields: map[string]*pstruct.Value{},
+		}
+	}
+	metadata.FilterMetadata[IstioMetadataKey].Fields["mtls_mode"] = &pstruct.Value{
+		Kind: &pstruct.Value_StringValue{
+			StringValue: mode,
+		},
+	}
+	return metadata
+}
+
 // IsHTTPFilterChain returns true if the filter chain contains a HTTP connection manager filter
 func IsHTTPFilterChain(filterChain *listener.FilterChain) bool {
 	for _, f := range filterChain.Filters {
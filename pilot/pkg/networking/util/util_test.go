@@ -599,6 +599,26 @@ func TestAddSubsetToMetadata(t *testing.T) {
 	}
 }
 
+func TestAddMTLSModeToMetadata(t *testing.T) {
+	want := &core.Metadata{
+		FilterMetadata: map[string]*structpb.Struct{
+			IstioMetadataKey: {
+				Fields: map[string]*structpb.Value{
+					"mtls_mode": {
+						Kind: &structpb.Value_StringValue{
+							StringValue: "PERMISSIVE",
+						},
+					},
+				},
+			},
+		},
+	}
+	got := AddMTLSModeToMetadata(nil, "PERMISSIVE")
+	if diff := cmp.Diff(got, want, protocmp.Transform()); diff != "" {
+		t.Errorf("AddMTLSModeToMetadata produced incorrect result:\ngot: %v\nwant: %v\nDiff: %s", got, want, diff)
+	}
+}
+
 func TestIsHTTPFilterChain(t *testing.T) {
 	httpFilterChain := &listener.FilterChain{
 		Filters: []*listener.Filter{
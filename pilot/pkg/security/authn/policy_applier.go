@@ -36,8 +36,11 @@ type PolicyApplier interface {
 
 	// AuthNFilter returns the (authn) HTTP filter to enforce the underlying authentication policy.
 	// It may return nil, if no authentication is needed.
-	AuthNFilter(proxyType model.NodeType, port uint32, istioMutualGateway bool) *http_conn.HttpFilter
+	AuthNFilter(node *model.Proxy, port uint32, istioMutualGateway bool) *http_conn.HttpFilter
 
-	// PortLevelSetting returns port level mTLS settings.
-	PortLevelSetting() map[uint32]*v1beta1.PeerAuthentication_MutualTLS
+	// PortLevelSetting returns port level mTLS settings, with named port settings resolved for the given proxy.
+	PortLevelSetting(node *model.Proxy) map[uint32]*v1beta1.PeerAuthentication_MutualTLS
+
+	// MutualTLSMode returns the effective mTLS mode of the given endpoint (aka workload) port.
+	MutualTLSMode(endpointPort uint32, node *model.Proxy) model.MutualTLSMode
 }
@@ -26,6 +26,7 @@ import (
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	envoy_jwt "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
 	http_conn "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/golang/protobuf/ptypes"
 	duration "github.com/golang/protobuf/ptypes/duration"
 	"github.com/golang/protobuf/ptypes/empty"
 
@@ -39,6 +40,7 @@ import (
 	authn_utils "istio.io/istio/pilot/pkg/security/authn/utils"
 	authn_model "istio.io/istio/pilot/pkg/security/model"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/security"
 	authn_alpha "istio.io/istio/pkg/envoy/config/authentication/v1alpha1"
 	authn_filter "istio.io/istio/pkg/envoy/config/filter/http/authn/v2alpha1"
 	"istio.io/pkg/log"
@@ -46,6 +48,13 @@ import (
 
 var authnLog = log.RegisterScope("authn", "authn debugging", 0)
 
+// PortLevelMtlsByNameAnnotation allows a workload level PeerAuthentication to set port level mTLS by port name,
+// as a comma separated list of name=MODE pairs, for example "http-web=STRICT,metrics=DISABLE". Names are
+// resolved against the ports the workload declares in Services, WorkloadGroups and Sidecars, so the same
+// policy applies to workloads using different numbers for the same logical port. Numeric portLevelMtls
+// settings take precedence over named ones.
+const PortLevelMtlsByNameAnnotation = "security.istio.io/portLevelMtlsByName"
+
 // Implemenation of authn.PolicyApplier with v1beta1 API.
 type v1beta1PolicyApplier struct {
 	jwtPolicies []*config.Config
@@ -55,8 +64,15 @@ type v1beta1PolicyApplier struct {
 	// processedJwtRules is the consolidate JWT rules from all jwtPolicies.
 	processedJwtRules []*v1beta1.JWTRule
 
+	// jwtRouteRequirements is the JWT requirement overrides of the named routes from all jwtPolicies, the first
+	// policy winning for each route.
+	jwtRouteRequirements []security.JwtRouteRequirement
+
 	consolidatedPeerPolicy *v1beta1.PeerAuthentication
 
+	// namedPortLevelMtls is the port level mTLS settings keyed by port name, from PortLevelMtlsByNameAnnotation.
+	namedPortLevelMtls map[string]*v1beta1.PeerAuthentication_MutualTLS
+
 	push *model.PushContext
 }
 
@@ -70,6 +86,9 @@ func (a *v1beta1PolicyApplier) JwtFilter() *http_conn.HttpFilter {
 	if filterConfigProto == nil {
 		return nil
 	}
+	if len(a.jwtRouteRequirements) > 0 {
+		filterConfigProto.RequirementMap = buildJwtRouteRequirementMap(a.jwtRouteRequirements, a.processedJwtRules)
+	}
 	return &http_conn.HttpFilter{
 		Name:       authn_model.EnvoyJwtFilterName,
 		ConfigType: &http_conn.HttpFilter_TypedConfig{TypedConfig: util.MessageToAny(filterConfigProto)},
@@ -84,8 +103,9 @@ func defaultAuthnFilter() *authn_filter.FilterConfig {
 	}
 }
 
-func (a *v1beta1PolicyApplier) setAuthnFilterForPeerAuthn(proxyType model.NodeType, port uint32, istioMutualGateway bool,
+func (a *v1beta1PolicyApplier) setAuthnFilterForPeerAuthn(node *model.Proxy, port uint32, istioMutualGateway bool,
 	config *authn_filter.FilterConfig) *authn_filter.FilterConfig {
+	proxyType := node.Type
 	if proxyType != model.SidecarProxy && !istioMutualGateway {
 		authnLog.Debugf("AuthnFilter: skip setting peer for type %v", proxyType)
 		return config
@@ -99,7 +119,7 @@ func (a *v1beta1PolicyApplier) setAuthnFilterForPeerAuthn(proxyType model.NodeTy
 
 	var effectiveMTLSMode model.MutualTLSMode
 	if proxyType == model.SidecarProxy {
-		effectiveMTLSMode = a.getMutualTLSModeForPort(port)
+		effectiveMTLSMode = a.getMutualTLSModeForPort(port, node)
 	} else {
 		// this is for gateway with a server whose TLS mode is ISTIO_MUTUAL
 		// this is effectively the same as strict mode. We dont really
@@ -168,11 +188,11 @@ func (a *v1beta1PolicyApplier) setAuthnFilterForRequestAuthn(config *authn_filte
 // - If PeerAuthentication is used, it overwrite the settings for peer principal validation and extraction based on the new API.
 // - If RequestAuthentication is used, it overwrite the settings for request principal validation and extraction based on the new API.
 // - If RequestAuthentication is used, principal binding is always set to ORIGIN.
-func (a *v1beta1PolicyApplier) AuthNFilter(proxyType model.NodeType, port uint32, istioMutualGateway bool) *http_conn.HttpFilter {
+func (a *v1beta1PolicyApplier) AuthNFilter(node *model.Proxy, port uint32, istioMutualGateway bool) *http_conn.HttpFilter {
 	var filterConfigProto *authn_filter.FilterConfig
 
 	// Override the config with peer authentication, if applicable.
-	filterConfigProto = a.setAuthnFilterForPeerAuthn(proxyType, port, istioMutualGateway, filterConfigProto)
+	filterConfigProto = a.setAuthnFilterForPeerAuthn(node, port, istioMutualGateway, filterConfigProto)
 	// Override the config with request authentication, if applicable.
 	filterConfigProto = a.setAuthnFilterForRequestAuthn(filterConfigProto)
 
@@ -188,7 +208,7 @@ func (a *v1beta1PolicyApplier) AuthNFilter(proxyType model.NodeType, port uint32
 
 func (a *v1beta1PolicyApplier) InboundFilterChain(endpointPort uint32, node *model.Proxy,
 	listenerProtocol networking.ListenerProtocol, trustDomainAliases []string) []networking.FilterChain {
-	effectiveMTLSMode := a.getMutualTLSModeForPort(endpointPort)
+	effectiveMTLSMode := a.getMutualTLSModeForPort(endpointPort, node)
 	authnLog.Debugf("InboundFilterChain: build inbound filter change for %v:%d in %s mode", node.ID, endpointPort, effectiveMTLSMode)
 	return authn_utils.BuildInboundFilterChain(effectiveMTLSMode, node, listenerProtocol, trustDomainAliases)
 }
@@ -199,12 +219,23 @@ func NewPolicyApplier(rootNamespace string,
 	peerPolicies []*config.Config,
 	push *model.PushContext) authn.PolicyApplier {
 	processedJwtRules := []*v1beta1.JWTRule{}
+	var jwtRouteRequirements []security.JwtRouteRequirement
+	seenRoutes := map[string]bool{}
 
 	// TODO(diemtvu) should we need to deduplicate JWT with the same issuer.
 	// https://github.com/istio/istio/issues/19245
 	for idx := range jwtPolicies {
 		spec := jwtPolicies[idx].Spec.(*v1beta1.RequestAuthentication)
 		processedJwtRules = append(processedJwtRules, spec.JwtRules...)
+		if jwtPolicies[idx].Annotations[security.JwtRouteRequirementsAnnotation] == "" {
+			continue
+		}
+		for _, requirement := range push.AuthnPolicies.GetJwtRouteRequirements(jwtPolicies[idx]) {
+			if !seenRoutes[requirement.Route] {
+				seenRoutes[requirement.Route] = true
+				jwtRouteRequirements = append(jwtRouteRequirements, requirement)
+			}
+		}
 	}
 
 	// Sort the jwt rules by the issuer alphabetically to make the later-on generated filter
@@ -214,15 +245,80 @@ func NewPolicyApplier(rootNamespace string,
 			processedJwtRules[i].GetIssuer(), processedJwtRules[j].GetIssuer()) < 0
 	})
 
+	consolidatedPeerPolicy := composePeerAuthentication(rootNamespace, peerPolicies)
+	var namedPortLevelMtls map[string]*v1beta1.PeerAuthentication_MutualTLS
+	if consolidatedPeerPolicy != nil {
+		namedPortLevelMtls = composeNamedPortLevelMtls(rootNamespace, peerPolicies, consolidatedPeerPolicy.Mtls)
+	}
+
 	return &v1beta1PolicyApplier{
 		jwtPolicies:            jwtPolicies,
 		peerPolices:            peerPolicies,
 		processedJwtRules:      processedJwtRules,
-		consolidatedPeerPolicy: composePeerAuthentication(rootNamespace, peerPolicies),
+		jwtRouteRequirements:   jwtRouteRequirements,
+		consolidatedPeerPolicy: consolidatedPeerPolicy,
+		namedPortLevelMtls:     namedPortLevelMtls,
 		push:                   push,
 	}
 }
 
+// buildJwtRouteRequirementMap builds the Envoy JWT filter requirements of the route requirement overrides, keyed by
+// the route names referenced by the per route config of the routes. The skipped routes disable the filter instead,
+// and have no requirement. The providers are named after the index of the JWT rules, as in convertToEnvoyJwtConfig.
+func buildJwtRouteRequirementMap(requirements []security.JwtRouteRequirement,
+	jwtRules []*v1beta1.JWTRule) map[string]*envoy_jwt.JwtRequirement {
+	res := map[string]*envoy_jwt.JwtRequirement{}
+	for _, requirement := range requirements {
+		if requirement.Skip {
+			continue
+		}
+		var providers []*envoy_jwt.JwtRequirement
+		for i, jwtRule := range jwtRules {
+			if !contains(requirement.Issuers, jwtRule.Issuer) {
+				continue
+			}
+			name := fmt.Sprintf("origins-%d", i)
+			if len(requirement.Audiences) > 0 {
+				providers = append(providers, &envoy_jwt.JwtRequirement{
+					RequiresType: &envoy_jwt.JwtRequirement_ProviderAndAudiences{
+						ProviderAndAudiences: &envoy_jwt.ProviderWithAudiences{
+							ProviderName: name,
+							Audiences:    requirement.Audiences,
+						},
+					},
+				})
+			} else {
+				providers = append(providers, &envoy_jwt.JwtRequirement{
+					RequiresType: &envoy_jwt.JwtRequirement_ProviderName{
+						ProviderName: name,
+					},
+				})
+			}
+		}
+		requires := providers[0]
+		if len(providers) > 1 {
+			requires = &envoy_jwt.JwtRequirement{
+				RequiresType: &envoy_jwt.JwtRequirement_RequiresAny{
+					RequiresAny: &envoy_jwt.JwtRequirementOrList{
+						Requirements: providers,
+					},
+				},
+			}
+		}
+		res[requirement.Route] = requires
+	}
+	return res
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 func createFakeJwks(jwksURI string) string {
 	// Encode jwksURI with base64 to make dynamic n in jwks
 	encodedString := base64.RawURLEncoding.EncodeToString([]byte(jwksURI))
@@ -295,7 +391,7 @@ func convertToEnvoyJwtConfig(jwtRules []*v1beta1.JWTRule, push *model.PushContex
 							},
 							Timeout: &duration.Duration{Seconds: 5}, // TODO: Make this configurable.
 						},
-						CacheDuration: &duration.Duration{Seconds: 5 * 60}, // TODO: Make this configurable if needed.
+						CacheDuration: ptypes.DurationProto(features.PilotJwtRemoteJwksCacheDuration),
 					},
 				}
 			} else {
@@ -409,24 +505,85 @@ func buildLocalJwks(jwksURI, jwtPubKey string) *envoy_jwt.JwtProvider_LocalJwks
 	}
 }
 
-func (a *v1beta1PolicyApplier) PortLevelSetting() map[uint32]*v1beta1.PeerAuthentication_MutualTLS {
-	if a.consolidatedPeerPolicy != nil {
+func (a *v1beta1PolicyApplier) PortLevelSetting(node *model.Proxy) map[uint32]*v1beta1.PeerAuthentication_MutualTLS {
+	if a.consolidatedPeerPolicy == nil {
+		return nil
+	}
+	if len(a.namedPortLevelMtls) == 0 {
 		return a.consolidatedPeerPolicy.PortLevelMtls
 	}
-	return nil
+	res := map[uint32]*v1beta1.PeerAuthentication_MutualTLS{}
+	for name, port := range workloadPortNames(node) {
+		if mtls, f := a.namedPortLevelMtls[name]; f {
+			res[port] = mtls
+		}
+	}
+	for port, mtls := range a.consolidatedPeerPolicy.PortLevelMtls {
+		res[port] = mtls
+	}
+	return res
+}
+
+// MutualTLSMode returns the effective mTLS mode of the given endpoint port.
+func (a *v1beta1PolicyApplier) MutualTLSMode(endpointPort uint32, node *model.Proxy) model.MutualTLSMode {
+	return a.getMutualTLSModeForPort(endpointPort, node)
 }
 
-func (a *v1beta1PolicyApplier) getMutualTLSModeForPort(endpointPort uint32) model.MutualTLSMode {
+// getMutualTLSModeForPort returns the mTLS mode of the port. The port level settings of PeerAuthentication take
+// precedence over the workload, namespace and mesh level settings of PeerAuthentication. The ingress mTLS settings
+// of the Sidecar can only make the mode of the port stricter than the one of PeerAuthentication.
+func (a *v1beta1PolicyApplier) getMutualTLSModeForPort(endpointPort uint32, node *model.Proxy) model.MutualTLSMode {
+	sidecarMode, hasSidecarMode := sidecarIngressMtls(node)[endpointPort]
 	if a.consolidatedPeerPolicy == nil {
+		if hasSidecarMode {
+			return getMutualTLSMode(&v1beta1.PeerAuthentication_MutualTLS{Mode: sidecarMode})
+		}
 		return model.MTLSPermissive
 	}
-	if a.consolidatedPeerPolicy.PortLevelMtls != nil {
-		if portMtls, ok := a.consolidatedPeerPolicy.PortLevelMtls[endpointPort]; ok {
-			return getMutualTLSMode(portMtls)
-		}
+	mode := getMutualTLSMode(a.consolidatedPeerPolicy.Mtls)
+	if portMtls, ok := a.PortLevelSetting(node)[endpointPort]; ok {
+		mode = getMutualTLSMode(portMtls)
+	}
+	if !hasSidecarMode {
+		return mode
+	}
+	if sidecar := getMutualTLSMode(&v1beta1.PeerAuthentication_MutualTLS{Mode: sidecarMode}); sidecar < mode {
+		authnLog.Debugf("%s of Sidecar %s/%s for port %d is ignored: %v is weaker than the %v mode of PeerAuthentication",
+			security.SidecarIngressMtlsAnnotation, node.SidecarScope.Namespace, node.SidecarScope.Name, endpointPort, sidecarMode, mode)
+	} else {
+		mode = sidecar
+	}
+	return mode
+}
+
+// sidecarIngressMtls returns the ingress mTLS settings of the Sidecar of the proxy, keyed by port.
+func sidecarIngressMtls(node *model.Proxy) map[uint32]v1beta1.PeerAuthentication_MutualTLS_Mode {
+	if node == nil || node.SidecarScope == nil {
+		return nil
 	}
+	return node.SidecarScope.IngressMtls
+}
 
-	return getMutualTLSMode(a.consolidatedPeerPolicy.Mtls)
+// workloadPortNames returns the endpoint port for each port name declared for the workload. Ports declared in
+// a Sidecar with custom ingress listeners take precedence over the ports of the services selecting the workload.
+func workloadPortNames(node *model.Proxy) map[string]uint32 {
+	res := map[string]uint32{}
+	if node == nil {
+		return res
+	}
+	for _, si := range node.ServiceInstances {
+		if si.ServicePort != nil && si.ServicePort.Name != "" {
+			res[si.ServicePort.Name] = si.Endpoint.EndpointPort
+		}
+	}
+	if node.SidecarScope != nil && node.SidecarScope.HasCustomIngressListeners {
+		for _, ingressListener := range node.SidecarScope.Sidecar.Ingress {
+			if ingressListener.Port != nil && ingressListener.Port.Name != "" {
+				res[ingressListener.Port.Name] = ingressListener.Port.Number
+			}
+		}
+	}
+	return res
 }
 
 // getMutualTLSMode returns the MutualTLSMode enum corresponding peer MutualTLS settings.
@@ -537,6 +694,66 @@ func composePeerAuthentication(rootNamespace string, configs []*config.Config) *
 	return &outputPolicy
 }
 
+// composeNamedPortLevelMtls returns the port level mTLS settings keyed by port name, as configured by
+// PortLevelMtlsByNameAnnotation on the workload level PeerAuthentication selected by the same rules as
+// composePeerAuthentication. UNSET modes inherit the workload level setting, passed as parent.
+func composeNamedPortLevelMtls(rootNamespace string, configs []*config.Config,
+	parent *v1beta1.PeerAuthentication_MutualTLS) map[string]*v1beta1.PeerAuthentication_MutualTLS {
+	var workloadCfg *config.Config
+	for _, cfg := range configs {
+		spec := cfg.Spec.(*v1beta1.PeerAuthentication)
+		if spec.Selector == nil || len(spec.Selector.MatchLabels) == 0 || cfg.Namespace == rootNamespace {
+			continue
+		}
+		if workloadCfg == nil || cfg.CreationTimestamp.Before(workloadCfg.CreationTimestamp) {
+			workloadCfg = cfg
+		}
+	}
+	if workloadCfg == nil || workloadCfg.Annotations[PortLevelMtlsByNameAnnotation] == "" {
+		return nil
+	}
+
+	modes, invalid := ParsePortLevelMtlsByName(workloadCfg.Annotations[PortLevelMtlsByNameAnnotation])
+	for _, setting := range invalid {
+		authnLog.Warnf("Ignoring invalid %s setting %q on %s.%s",
+			PortLevelMtlsByNameAnnotation, setting, workloadCfg.Name, workloadCfg.Namespace)
+	}
+	res := make(map[string]*v1beta1.PeerAuthentication_MutualTLS, len(modes))
+	for name, mode := range modes {
+		mtls := &v1beta1.PeerAuthentication_MutualTLS{Mode: mode}
+		if isMtlsModeUnset(mtls) {
+			// Inherit from workload level.
+			mtls = parent
+		}
+		res[name] = mtls
+	}
+	return res
+}
+
+// ParsePortLevelMtlsByName parses the value of PortLevelMtlsByNameAnnotation into mTLS modes keyed by port name.
+// Invalid settings are skipped, and returned separately.
+func ParsePortLevelMtlsByName(value string) (map[string]v1beta1.PeerAuthentication_MutualTLS_Mode, []string) {
+	res := map[string]v1beta1.PeerAuthentication_MutualTLS_Mode{}
+	var invalid []string
+	if value == "" {
+		return res, invalid
+	}
+	for _, setting := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(setting), "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			invalid = append(invalid, setting)
+			continue
+		}
+		mode, ok := v1beta1.PeerAuthentication_MutualTLS_Mode_value[strings.ToUpper(strings.TrimSpace(parts[1]))]
+		if !ok {
+			invalid = append(invalid, setting)
+			continue
+		}
+		res[strings.TrimSpace(parts[0])] = v1beta1.PeerAuthentication_MutualTLS_Mode(mode)
+	}
+	return res, invalid
+}
+
 func isMtlsModeUnset(mtls *v1beta1.PeerAuthentication_MutualTLS) bool {
 	return mtls == nil || mtls.Mode == v1beta1.PeerAuthentication_MutualTLS_UNSET
 }
@@ -40,6 +40,7 @@ import (
 	xdsfilters "istio.io/istio/pilot/pkg/xds/filters"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/security"
 	authn_alpha "istio.io/istio/pkg/envoy/config/authentication/v1alpha1"
 	authn_filter "istio.io/istio/pkg/envoy/config/filter/http/authn/v2alpha1"
 	protovalue "istio.io/istio/pkg/proto"
@@ -1429,7 +1430,8 @@ func TestAuthnFilterConfig(t *testing.T) {
 			if c.isGateway {
 				proxyType = model.Router
 			}
-			got := NewPolicyApplier("root-namespace", c.jwtIn, c.peerIn, &model.PushContext{}).AuthNFilter(proxyType, 80, c.gatewayServerUsesIstioMutual)
+			got := NewPolicyApplier("root-namespace", c.jwtIn, c.peerIn, &model.PushContext{}).AuthNFilter(&model.Proxy{Type: proxyType}, 80,
+				c.gatewayServerUsesIstioMutual)
 			if !reflect.DeepEqual(c.expected, got) {
 				t.Errorf("got:\n%v\nwanted:\n%v\n", humanReadableAuthnFilterDump(got), humanReadableAuthnFilterDump(c.expected))
 			}
@@ -2268,3 +2270,51 @@ func TestGetMutualTLSMode(t *testing.T) {
 		})
 	}
 }
+
+func TestJwtRouteRequirementMap(t *testing.T) {
+	jwtRules := []*v1beta1.JWTRule{
+		{Issuer: "https://bar.com"},
+		{Issuer: "https://foo.com"},
+	}
+	requirements := []security.JwtRouteRequirement{
+		{Route: "healthz", Skip: true},
+		{Route: "admin", Issuers: []string{"https://bar.com", "https://foo.com"}, Audiences: []string{"admin"}},
+		{Route: "api", Issuers: []string{"https://bar.com"}},
+	}
+
+	got := buildJwtRouteRequirementMap(requirements, jwtRules)
+	expected := map[string]*envoy_jwt.JwtRequirement{
+		"admin": {
+			RequiresType: &envoy_jwt.JwtRequirement_RequiresAny{
+				RequiresAny: &envoy_jwt.JwtRequirementOrList{
+					Requirements: []*envoy_jwt.JwtRequirement{
+						{
+							RequiresType: &envoy_jwt.JwtRequirement_ProviderAndAudiences{
+								ProviderAndAudiences: &envoy_jwt.ProviderWithAudiences{
+									ProviderName: "origins-0",
+									Audiences:    []string{"admin"},
+								},
+							},
+						},
+						{
+							RequiresType: &envoy_jwt.JwtRequirement_ProviderAndAudiences{
+								ProviderAndAudiences: &envoy_jwt.ProviderWithAudiences{
+									ProviderName: "origins-1",
+									Audiences:    []string{"admin"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		"api": {
+			RequiresType: &envoy_jwt.JwtRequirement_ProviderName{
+				ProviderName: "origins-0",
+			},
+		},
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("got:\n%s\nwanted:\n%s\n", spew.Sdump(got), spew.Sdump(expected))
+	}
+}
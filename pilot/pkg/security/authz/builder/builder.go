@@ -16,6 +16,9 @@ package builder
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 
 	tcppb "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	rbacpb "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
@@ -23,16 +26,68 @@ import (
 	httppb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	rbactcppb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/rbac/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/golang/protobuf/proto"
 	"github.com/hashicorp/go-multierror"
 
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/plugin"
 	"istio.io/istio/pilot/pkg/networking/util"
 	authzmodel "istio.io/istio/pilot/pkg/security/authz/model"
 	"istio.io/istio/pilot/pkg/security/trustdomain"
 	"istio.io/istio/pkg/config/labels"
+	"istio.io/pkg/monitoring"
 )
 
+var (
+	actionTag = monitoring.MustCreateLabel("action")
+
+	shadowDivergentRules = monitoring.NewGauge(
+		"pilot_authz_shadow_divergent_rules",
+		"Number of rules currently generated differently by the next version of the RBAC filter config in shadow "+
+			"comparison mode.",
+		monitoring.WithLabels(actionTag),
+	)
+
+	divergence = &shadowDivergence{rules: map[rbacpb.RBAC_Action]map[string]struct{}{}}
+)
+
+// shadowDivergence tracks the rules generated differently by the next version of the RBAC filter config, so that
+// the gauge reports the current divergence. The rules are reset whenever the authorization policies change, as
+// all the proxies are then pushed and their rules compared again.
+type shadowDivergence struct {
+	mu       sync.Mutex
+	policies *model.AuthorizationPolicies
+	rules    map[rbacpb.RBAC_Action]map[string]struct{}
+}
+
+func (d *shadowDivergence) record(policies *model.AuthorizationPolicies, action rbacpb.RBAC_Action, name string, divergent bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.policies != policies {
+		d.policies = policies
+		for a := range d.rules {
+			delete(d.rules, a)
+			shadowDivergentRules.With(actionTag.Value(a.String())).Record(0)
+		}
+	}
+	rules := d.rules[action]
+	if rules == nil {
+		rules = map[string]struct{}{}
+		d.rules[action] = rules
+	}
+	if divergent {
+		rules[name] = struct{}{}
+	} else {
+		delete(rules, name)
+	}
+	shadowDivergentRules.With(actionTag.Value(action.String())).Record(float64(len(rules)))
+}
+
+func init() {
+	monitoring.MustRegister(shadowDivergentRules)
+}
+
 var rbacPolicyMatchNever = &rbacpb.Policy{
 	Permissions: []*rbacpb.Permission{{Rule: &rbacpb.Permission_NotRule{
 		NotRule: &rbacpb.Permission{Rule: &rbacpb.Permission_Any{Any: true}},
@@ -51,6 +106,8 @@ type Option struct {
 // Builder builds Istio authorization policy to Envoy filters.
 type Builder struct {
 	trustDomainBundle trustdomain.Bundle
+	ipSets            map[string][]string
+	policies          *model.AuthorizationPolicies
 	option            Option
 
 	// populated when building for CUSTOM action.
@@ -80,6 +137,7 @@ func New(trustDomainBundle trustdomain.Bundle, in *plugin.InputParams, option Op
 			customPolicies:    policies.Custom,
 			extensions:        extAuthzExtensions,
 			trustDomainBundle: trustDomainBundle,
+			ipSets:            in.Push.AuthzPolicies.IPSets,
 			option:            option,
 		}
 	}
@@ -93,6 +151,8 @@ func New(trustDomainBundle trustdomain.Bundle, in *plugin.InputParams, option Op
 		allowPolicies:     policies.Allow,
 		auditPolicies:     policies.Audit,
 		trustDomainBundle: trustDomainBundle,
+		ipSets:            in.Push.AuthzPolicies.IPSets,
+		policies:          in.Push.AuthzPolicies,
 		option:            option,
 	}
 }
@@ -164,8 +224,17 @@ func (b Builder) build(policies []model.AuthorizationPolicy, action rbacpb.RBAC_
 		Action:   action,
 		Policies: map[string]*rbacpb.Policy{},
 	}
+	// Dry-run policies are generated as shadow rules that are evaluated but never enforced. In shadow comparison
+	// mode, the shadow rules also hold the enforced policies as generated by the next version.
+	shadowComparison := features.AuthzShadowComparison && !b.option.IsCustomBuilder
+	shadowRules := &rbacpb.RBAC{
+		Action:   action,
+		Policies: map[string]*rbacpb.Policy{},
+	}
+	hasEnforced := false
 
 	var providers []string
+	var failOpen *bool
 	filterType := "HTTP"
 	if forTCP {
 		filterType = "TCP"
@@ -173,6 +242,14 @@ func (b Builder) build(policies []model.AuthorizationPolicy, action rbacpb.RBAC_
 	for _, policy := range policies {
 		if b.option.IsCustomBuilder {
 			providers = append(providers, policy.Spec.GetProvider().GetName())
+			failOpen = b.mergeFailureMode(policy, failOpen)
+		}
+		target := rules
+		dryRun := b.isDryRun(policy)
+		if dryRun {
+			target = shadowRules
+		} else {
+			hasEnforced = true
 		}
 		for i, rule := range policy.Spec.Rules {
 			// The name will later be used by ext_authz filter to get the evaluation result from dynamic metadata.
@@ -186,37 +263,137 @@ func (b Builder) build(policies []model.AuthorizationPolicy, action rbacpb.RBAC_
 				b.option.Logger.AppendError(multierror.Prefix(err, fmt.Sprintf("skipped invalid rule %s:", name)))
 				continue
 			}
+			if err := m.ExpandIPSets(b.ipSets); err != nil {
+				// Fail closed: an ALLOW rule allows nothing, other rules match everything.
+				if action == rbacpb.RBAC_ALLOW {
+					b.option.Logger.AppendError(multierror.Prefix(err, fmt.Sprintf("skipped rule %s with invalid IP set:", name)))
+				} else {
+					b.option.Logger.AppendError(multierror.Prefix(err, fmt.Sprintf("rule %s with invalid IP set matches all requests:", name)))
+					target.Policies[name] = rbacPolicyMatchAll
+				}
+				continue
+			}
 			m.MigrateTrustDomain(b.trustDomainBundle)
 			if len(b.trustDomainBundle.TrustDomains) > 1 {
 				b.option.Logger.AppendDebugf("patched source principal with trust domain aliases %v", b.trustDomainBundle.TrustDomains)
 			}
+			if dryRun && shadowComparison {
+				// Dry-run policies are only evaluated in the shadow rules, which are generated by the next version.
+				m.UseNextVersion()
+			}
 			generated, err := m.Generate(forTCP, action)
 			if err != nil {
 				b.option.Logger.AppendDebugf("skipped rule %s on TCP filter chain: %v", name, err)
 				continue
 			}
 			if generated != nil {
-				rules.Policies[name] = generated
+				target.Policies[name] = generated
 				b.option.Logger.AppendDebugf("generated config from rule %s on %s filter chain successfully", name, filterType)
 			}
+			if shadowComparison && !dryRun {
+				b.buildShadowComparison(m, name, generated, forTCP, action, shadowRules)
+			}
 		}
 		if len(policy.Spec.Rules) == 0 {
 			// Generate an explicit policy that never matches.
 			name := policyName(policy.Namespace, policy.Name, 0, b.option)
 			b.option.Logger.AppendDebugf("generated config from policy %s on %s filter chain successfully", name, filterType)
-			rules.Policies[name] = rbacPolicyMatchNever
+			target.Policies[name] = rbacPolicyMatchNever
+			if shadowComparison && !dryRun {
+				shadowRules.Policies[name] = rbacPolicyMatchNever
+			}
 		}
 	}
 
+	if !hasEnforced {
+		// Leave the rules unset so that the filter does not enforce anything.
+		rules = nil
+	}
+	if len(shadowRules.Policies) == 0 {
+		shadowRules = nil
+	}
+	if rules == nil && shadowRules == nil {
+		return nil
+	}
 	if forTCP {
-		return &builtConfigs{tcp: b.buildTCP(rules, providers)}
+		return &builtConfigs{tcp: b.buildTCP(rules, shadowRules, providers, failOpen)}
 	}
-	return &builtConfigs{http: b.buildHTTP(rules, providers)}
+	return &builtConfigs{http: b.buildHTTP(rules, shadowRules, providers, failOpen)}
 }
 
-func (b Builder) buildHTTP(rules *rbacpb.RBAC, providers []string) []*httppb.HttpFilter {
+// buildShadowComparison adds the rule generated by the next version of the RBAC filter config to the shadow rules,
+// and records whether it differs from the enforced one.
+func (b Builder) buildShadowComparison(m *authzmodel.Model, name string, enforced *rbacpb.Policy, forTCP bool,
+	action rbacpb.RBAC_Action, shadowRules *rbacpb.RBAC) {
+	m.UseNextVersion()
+	next, err := m.Generate(forTCP, action)
+	if err != nil {
+		b.option.Logger.AppendDebugf("skipped shadow comparison of rule %s: %v", name, err)
+		return
+	}
+	if next == nil {
+		return
+	}
+	shadowRules.Policies[name] = next
+	divergent := !proto.Equal(enforced, next)
+	divergence.record(b.policies, action, name, divergent)
+	if divergent {
+		b.option.Logger.AppendDebugf("rule %s is generated differently by the next version", name)
+	}
+}
+
+// isDryRun returns true if the policy should only be evaluated in shadow mode.
+func (b Builder) isDryRun(policy model.AuthorizationPolicy) bool {
+	val, found := policy.Annotations[authzmodel.DryRunAnnotation]
+	if !found {
+		return false
+	}
+	dryRun, err := strconv.ParseBool(val)
+	if err != nil {
+		b.option.Logger.AppendError(fmt.Errorf("failed to parse the value of %s in policy %s.%s: %v",
+			authzmodel.DryRunAnnotation, policy.Namespace, policy.Name, err))
+		return false
+	}
+	if dryRun && b.option.IsCustomBuilder {
+		// The CUSTOM action already uses the shadow rules to trigger the ext_authz filter.
+		b.option.Logger.AppendDebugf("ignored %s in policy %s.%s with CUSTOM action",
+			authzmodel.DryRunAnnotation, policy.Namespace, policy.Name)
+		return false
+	}
+	return dryRun
+}
+
+// mergeFailureMode returns the ext_authz failure mode after merging the one set on the policy with CUSTOM action.
+// Returns nil if no policy overrides the failure mode of the provider. The closed failure mode takes precedence
+// when the policies of the workload disagree.
+func (b Builder) mergeFailureMode(policy model.AuthorizationPolicy, failOpen *bool) *bool {
+	val, found := policy.Annotations[authzmodel.ExtAuthzFailureModeAnnotation]
+	if !found {
+		return failOpen
+	}
+	var open bool
+	switch strings.ToLower(val) {
+	case "open":
+		open = true
+	case "closed":
+		open = false
+	default:
+		b.option.Logger.AppendError(fmt.Errorf("ignored invalid value %q of %s in policy %s.%s, must be open or closed",
+			val, authzmodel.ExtAuthzFailureModeAnnotation, policy.Namespace, policy.Name))
+		return failOpen
+	}
+	if failOpen != nil && !*failOpen {
+		return failOpen
+	}
+	return &open
+}
+
+func (b Builder) buildHTTP(rules, shadowRules *rbacpb.RBAC, providers []string, failOpen *bool) []*httppb.HttpFilter {
 	if !b.option.IsCustomBuilder {
-		rbac := &rbachttppb.RBAC{Rules: rules}
+		rbac := &rbachttppb.RBAC{Rules: rules, ShadowRules: shadowRules}
+		if shadowRules != nil {
+			rbac.ShadowRulesStatPrefix = shadowRulesStatPrefix(shadowRules.Action)
+		}
 		return []*httppb.HttpFilter{
 			{
 				Name:       authzmodel.RBACHTTPFilterName,
@@ -249,14 +426,17 @@ func (b Builder) buildHTTP(rules *rbacpb.RBAC, providers []string) []*httppb.Htt
 		},
 		{
 			Name:       wellknown.HTTPExternalAuthorization,
-			ConfigType: &httppb.HttpFilter_TypedConfig{TypedConfig: util.MessageToAny(extauthz.http)},
+			ConfigType: &httppb.HttpFilter_TypedConfig{TypedConfig: util.MessageToAny(extauthz.withFailureMode(failOpen).http)},
 		},
 	}
 }
 
-func (b Builder) buildTCP(rules *rbacpb.RBAC, providers []string) []*tcppb.Filter {
+func (b Builder) buildTCP(rules, shadowRules *rbacpb.RBAC, providers []string, failOpen *bool) []*tcppb.Filter {
 	if !b.option.IsCustomBuilder {
-		rbac := &rbactcppb.RBAC{Rules: rules, StatPrefix: authzmodel.RBACTCPFilterStatPrefix}
+		rbac := &rbactcppb.RBAC{Rules: rules, ShadowRules: shadowRules, StatPrefix: authzmodel.RBACTCPFilterStatPrefix}
+		if shadowRules != nil {
+			rbac.ShadowRulesStatPrefix = shadowRulesStatPrefix(shadowRules.Action)
+		}
 		return []*tcppb.Filter{
 			{
 				Name:       authzmodel.RBACTCPFilterName,
@@ -286,12 +466,25 @@ func (b Builder) buildTCP(rules *rbacpb.RBAC, providers []string) []*tcppb.Filte
 			},
 			{
 				Name:       wellknown.ExternalAuthorization,
-				ConfigType: &tcppb.Filter_TypedConfig{TypedConfig: util.MessageToAny(extauthz.tcp)},
+				ConfigType: &tcppb.Filter_TypedConfig{TypedConfig: util.MessageToAny(extauthz.withFailureMode(failOpen).tcp)},
 			},
 		}
 	}
 }
 
+// shadowRulesStatPrefix returns the stat prefix of the shadow rules of the action, so that the shadow results of the
+// ALLOW, DENY and AUDIT filters do not overwrite each other in the dynamic metadata.
+func shadowRulesStatPrefix(action rbacpb.RBAC_Action) string {
+	switch action {
+	case rbacpb.RBAC_DENY:
+		return authzmodel.RBACShadowRulesDenyStatPrefix
+	case rbacpb.RBAC_LOG:
+		return authzmodel.RBACShadowRulesAuditStatPrefix
+	default:
+		return authzmodel.RBACShadowRulesAllowStatPrefix
+	}
+}
+
 func policyName(namespace, name string, rule int, option Option) string {
 	prefix := ""
 	if option.IsCustomBuilder {
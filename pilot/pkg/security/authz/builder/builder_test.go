@@ -19,12 +19,14 @@ import (
 	"testing"
 
 	tcppb "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	rbacpb "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
 	httppb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	"github.com/gogo/protobuf/proto"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	"istio.io/istio/pilot/pkg/config/kube/crd"
 	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/plugin"
 	"istio.io/istio/pilot/pkg/security/trustdomain"
@@ -141,6 +143,12 @@ func TestGenerator_GenerateHTTP(t *testing.T) {
 			input:      "action-custom-in.yaml",
 			want:       []string{"action-custom-http-provider-out1.yaml", "action-custom-http-provider-out2.yaml"},
 		},
+		{
+			name:       "action-custom-failure-mode",
+			meshConfig: meshConfigGRPC,
+			input:      "action-custom-failure-mode-in.yaml",
+			want:       []string{"action-custom-grpc-provider-out1.yaml", "action-custom-failure-mode-out2.yaml"},
+		},
 		{
 			name:       "action-custom-bad-multiple-providers",
 			meshConfig: meshConfigHTTP,
@@ -220,6 +228,16 @@ func TestGenerator_GenerateHTTP(t *testing.T) {
 			input: "audit-all-in.yaml",
 			want:  []string{"audit-all-out.yaml"},
 		},
+		{
+			name:  "dry-run",
+			input: "dry-run-in.yaml",
+			want:  []string{"dry-run-deny-out.yaml", "dry-run-allow-out.yaml"},
+		},
+		{
+			name:  "ip-set-missing",
+			input: "ip-set-missing-in.yaml",
+			want:  []string{"ip-set-missing-deny-out.yaml", "ip-set-missing-allow-out.yaml"},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -240,6 +258,41 @@ func TestGenerator_GenerateHTTP(t *testing.T) {
 	}
 }
 
+func TestGenerator_ShadowComparison(t *testing.T) {
+	features.AuthzShadowComparison = true
+	defer func() { features.AuthzShadowComparison = false }()
+
+	option := Option{Logger: &AuthzLogger{}}
+	in := inputParams(t, "shadow-comparison-in.yaml", nil)
+	defer option.Logger.Report(in)
+	g := New(trustdomain.Bundle{}, in, option)
+	if g == nil {
+		t.Fatalf("failed to create generator")
+	}
+	verify(t, convertHTTP(g.BuildHTTP()), []string{"shadow-comparison-out.yaml"}, false /* forTCP */)
+}
+
+func TestShadowDivergence(t *testing.T) {
+	d := &shadowDivergence{rules: map[rbacpb.RBAC_Action]map[string]struct{}{}}
+	policies := &model.AuthorizationPolicies{}
+	d.record(policies, rbacpb.RBAC_ALLOW, "rule-0", true)
+	d.record(policies, rbacpb.RBAC_ALLOW, "rule-0", true)
+	d.record(policies, rbacpb.RBAC_ALLOW, "rule-1", true)
+	d.record(policies, rbacpb.RBAC_DENY, "rule-2", true)
+	if got := len(d.rules[rbacpb.RBAC_ALLOW]); got != 2 {
+		t.Errorf("got %d divergent ALLOW rules, want 2", got)
+	}
+	d.record(policies, rbacpb.RBAC_ALLOW, "rule-1", false)
+	if got := len(d.rules[rbacpb.RBAC_ALLOW]); got != 1 {
+		t.Errorf("got %d divergent ALLOW rules after convergence, want 1", got)
+	}
+	// The rules are compared again once the policies change.
+	d.record(&model.AuthorizationPolicies{}, rbacpb.RBAC_ALLOW, "rule-3", false)
+	if got := len(d.rules[rbacpb.RBAC_ALLOW]) + len(d.rules[rbacpb.RBAC_DENY]); got != 0 {
+		t.Errorf("got %d divergent rules after the policies changed, want 0", got)
+	}
+}
+
 func TestGenerator_GenerateTCP(t *testing.T) {
 	testCases := []struct {
 		name       string
@@ -275,6 +328,11 @@ func TestGenerator_GenerateTCP(t *testing.T) {
 			input: "action-audit-HTTP-for-TCP-filter-in.yaml",
 			want:  []string{"action-audit-HTTP-for-TCP-filter-out.yaml"},
 		},
+		{
+			name:  "dry-run",
+			input: "dry-run-in.yaml",
+			want:  []string{"dry-run-deny-tcp-out.yaml", "dry-run-allow-tcp-out.yaml"},
+		},
 	}
 
 	for _, tc := range testCases {
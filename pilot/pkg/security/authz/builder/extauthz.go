@@ -28,10 +28,12 @@ import (
 	extauthztcp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/ext_authz/v3"
 	envoy_type_matcher_v3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
 	envoytypev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes/duration"
 	"github.com/hashicorp/go-multierror"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/networking/plugin"
 	"istio.io/istio/pilot/pkg/security"
 	authzmodel "istio.io/istio/pilot/pkg/security/authz/model"
@@ -67,6 +69,24 @@ type builtExtAuthz struct {
 	tcp  *extauthztcp.ExtAuthz
 }
 
+// withFailureMode returns a copy of the config with the failure mode overridden, or the config itself if
+// failOpen is nil.
+func (e *builtExtAuthz) withFailureMode(failOpen *bool) *builtExtAuthz {
+	if failOpen == nil {
+		return e
+	}
+	ret := &builtExtAuthz{}
+	if e.http != nil {
+		ret.http = proto.Clone(e.http).(*extauthzhttp.ExtAuthz)
+		ret.http.FailureModeAllow = *failOpen
+	}
+	if e.tcp != nil {
+		ret.tcp = proto.Clone(e.tcp).(*extauthztcp.ExtAuthz)
+		ret.tcp.FailureModeAllow = *failOpen
+	}
+	return ret
+}
+
 func processExtensionProvider(in *plugin.InputParams) (map[string]*builtExtAuthz, error) {
 	var errs error
 	configs := in.Push.Mesh.ExtensionProviders
@@ -93,6 +113,9 @@ func processExtensionProvider(in *plugin.InputParams) (map[string]*builtExtAuthz
 		}
 		if err != nil {
 			errs = multierror.Append(errs, multierror.Prefix(err, fmt.Sprintf("failed to parse extension provider %q:", config.Name)))
+		} else if parsed.http != nil && features.EnableExtAuthzProviderStatPrefix {
+			// Report the ext_authz stats per provider, so that an unavailable provider can be told apart.
+			parsed.http.StatPrefix = config.Name
 		}
 		resolved[config.Name] = parsed
 	}
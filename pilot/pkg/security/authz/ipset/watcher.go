@@ -0,0 +1,132 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ipset provides the named IP sets that can be referenced from the IP blocks of AuthorizationPolicies,
+// so that large and frequently updated IP lists don't need to be inlined in the policies.
+package ipset
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/kube/configmapwatcher"
+	istiolog "istio.io/pkg/log"
+)
+
+var log = istiolog.RegisterScope("authorization", "Istio Authorization Policy", 0)
+
+// Watcher watches a ConfigMap holding IP sets. Each key of the ConfigMap is the name of an IP set, and its value
+// is the list of IPs and CIDRs of the set, separated by commas or whitespace. Lines starting with # are ignored.
+type Watcher struct {
+	controller *configmapwatcher.Controller
+
+	mu       sync.RWMutex
+	ipSets   map[string][]string
+	handlers []func()
+}
+
+// NewWatcher returns a watcher of the IP sets of the given ConfigMap.
+func NewWatcher(client kube.Client, namespace, name string) *Watcher {
+	w := &Watcher{ipSets: map[string][]string{}}
+	w.controller = configmapwatcher.NewController(client, namespace, name, w.handleConfigMap)
+	return w
+}
+
+// Run watches the ConfigMap until the stop channel is closed.
+func (w *Watcher) Run(stop <-chan struct{}) {
+	w.controller.Run(stop)
+}
+
+// HasSynced returns whether the IP sets have been initially loaded.
+func (w *Watcher) HasSynced() bool {
+	return w.controller.HasSynced()
+}
+
+// IPSets returns the current IP sets, keyed by name.
+func (w *Watcher) IPSets() map[string][]string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.ipSets
+}
+
+// AddHandler registers a handler called when the IP sets change.
+func (w *Watcher) AddHandler(h func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers = append(w.handlers, h)
+}
+
+func (w *Watcher) handleConfigMap(cm *v1.ConfigMap) {
+	if cm == nil {
+		// Keep the last known IP sets, so that deleting the ConfigMap by mistake doesn't change the policies using them.
+		if len(w.IPSets()) > 0 {
+			log.Warnf("IP set ConfigMap not found, keeping the last known IP sets")
+		}
+		return
+	}
+	ipSets := map[string][]string{}
+	for name, data := range cm.Data {
+		ips, err := Parse(data)
+		if err != nil {
+			// Keep the last known IP set, so that a typo doesn't open (or close) the policies using it.
+			log.Warnf("failed to parse IP set %s in ConfigMap %s/%s: %v", name, cm.Namespace, cm.Name, err)
+			ips = w.IPSets()[name]
+		}
+		if len(ips) > 0 {
+			ipSets[name] = ips
+		}
+	}
+
+	w.mu.Lock()
+	if reflect.DeepEqual(w.ipSets, ipSets) {
+		w.mu.Unlock()
+		return
+	}
+	w.ipSets = ipSets
+	handlers := append([]func(){}, w.handlers...)
+	w.mu.Unlock()
+
+	log.Infof("loaded %d IP sets", len(ipSets))
+	for _, h := range handlers {
+		h()
+	}
+}
+
+// Parse parses the IPs and CIDRs of an IP set.
+func Parse(data string) ([]string, error) {
+	var ips []string
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, v := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+			if strings.Contains(v, "/") {
+				if _, _, err := net.ParseCIDR(v); err != nil {
+					return nil, fmt.Errorf("bad CIDR range (%s): %v", v, err)
+				}
+			} else if net.ParseIP(v) == nil {
+				return nil, fmt.Errorf("bad IP address (%s)", v)
+			}
+			ips = append(ips, v)
+		}
+	}
+	return ips, nil
+}
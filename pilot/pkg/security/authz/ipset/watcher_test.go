@@ -0,0 +1,93 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipset
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name    string
+		data    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "separators and comments",
+			data: "# blocked clients\n10.0.0.1, 10.1.0.0/16\n\n  2001:db8::/32 192.168.1.1\n",
+			want: []string{"10.0.0.1", "10.1.0.0/16", "2001:db8::/32", "192.168.1.1"},
+		},
+		{name: "bad IP", data: "10.0.0.1\nfoo", wantErr: true},
+		{name: "bad CIDR", data: "10.0.0.0/33", wantErr: true},
+		{name: "empty", data: "# nothing yet"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Parse(tc.data)
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("got error %v, want error %v", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWatcherHandleConfigMap(t *testing.T) {
+	w := &Watcher{ipSets: map[string][]string{}}
+	pushes := 0
+	w.AddHandler(func() { pushes++ })
+
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "istio-ip-sets", Namespace: "istio-system"},
+		Data: map[string]string{
+			"blocked": "10.0.0.1\n10.1.0.0/16",
+			"empty":   "",
+		},
+	}
+	w.handleConfigMap(cm)
+	want := map[string][]string{"blocked": {"10.0.0.1", "10.1.0.0/16"}}
+	if got := w.IPSets(); !reflect.DeepEqual(got, want) || pushes != 1 {
+		t.Fatalf("got %v with %d pushes, want %v with 1 push", got, pushes, want)
+	}
+
+	// An unchanged ConfigMap doesn't trigger a push.
+	w.handleConfigMap(cm.DeepCopy())
+	if pushes != 1 {
+		t.Errorf("got %d pushes, want 1", pushes)
+	}
+
+	// An invalid IP set keeps its last known value.
+	cm = cm.DeepCopy()
+	cm.Data["blocked"] = "10.0.0.1\nfoo"
+	cm.Data["office"] = "192.168.0.0/24"
+	w.handleConfigMap(cm)
+	want = map[string][]string{"blocked": {"10.0.0.1", "10.1.0.0/16"}, "office": {"192.168.0.0/24"}}
+	if got := w.IPSets(); !reflect.DeepEqual(got, want) || pushes != 2 {
+		t.Fatalf("got %v with %d pushes, want %v with 2 pushes", got, pushes, want)
+	}
+
+	// A deleted ConfigMap keeps the last known IP sets.
+	w.handleConfigMap(nil)
+	if got := w.IPSets(); !reflect.DeepEqual(got, want) || pushes != 2 {
+		t.Errorf("got %v with %d pushes, want %v with 2 pushes", got, pushes, want)
+	}
+}
@@ -150,6 +150,20 @@ func (srcPrincipalGenerator) principal(key, value string, forTCP bool) (*rbacpb.
 	return principalMetadata(metadata), nil
 }
 
+// authenticatedGenerator generates the principal of the wrapped generator from the peer certificate, regardless of
+// the filter chain.
+type authenticatedGenerator struct {
+	g generator
+}
+
+func (a authenticatedGenerator) permission(key, value string, forTCP bool) (*rbacpb.Permission, error) {
+	return a.g.permission(key, value, forTCP)
+}
+
+func (a authenticatedGenerator) principal(key, value string, _ bool) (*rbacpb.Principal, error) {
+	return a.g.principal(key, value, true)
+}
+
 type requestPrincipalGenerator struct{}
 
 func (requestPrincipalGenerator) permission(_, _ string, _ bool) (*rbacpb.Permission, error) {
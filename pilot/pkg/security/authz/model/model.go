@@ -22,6 +22,7 @@ import (
 
 	authzpb "istio.io/api/security/v1beta1"
 	"istio.io/istio/pilot/pkg/security/trustdomain"
+	"istio.io/istio/pkg/config/security"
 )
 
 const (
@@ -32,6 +33,20 @@ const (
 	RBACTCPFilterName       = "envoy.filters.network.rbac"
 	RBACTCPFilterStatPrefix = "tcp."
 
+	// DryRunAnnotation marks an authorization policy as dry-run when set to "true". A dry-run policy is only
+	// evaluated in shadow mode: the result is reported in stats and dynamic metadata but never enforced.
+	DryRunAnnotation = "istio.io/dry-run"
+
+	// The shadow rules of each action are evaluated with their own stat prefix, which also prefixes the keys of the
+	// shadow result in the dynamic metadata, e.g. "istio_dry_run_allow_shadow_engine_result".
+	RBACShadowRulesAllowStatPrefix = "istio_dry_run_allow_"
+	RBACShadowRulesDenyStatPrefix  = "istio_dry_run_deny_"
+	RBACShadowRulesAuditStatPrefix = "istio_dry_run_audit_"
+
+	// ExtAuthzFailureModeAnnotation overrides the failure mode of the extension provider of a policy with CUSTOM
+	// action when set to "open" or "closed". With "open", requests are allowed when the provider can't be reached.
+	ExtAuthzFailureModeAnnotation = "istio.io/ext-authz-failure-mode"
+
 	attrRequestHeader    = "request.headers"             // header name is surrounded by brackets, e.g. "request.headers[User-Agent]".
 	attrSrcIP            = "source.ip"                   // supports both single ip and cidr, e.g. "10.1.2.3" or "10.1.0.0/16".
 	attrRemoteIP         = "remote.ip"                   // original client ip determined from x-forwarded-for or proxy protocol.
@@ -160,6 +175,76 @@ func (m *Model) MigrateTrustDomain(tdBundle trustdomain.Bundle) {
 	}
 }
 
+// NextVersionChange is a change of the generation introduced by the next version of the RBAC filter config.
+type NextVersionChange struct {
+	Name  string
+	Apply func(m *Model)
+}
+
+// NextVersionChanges are the changes of the next version of the RBAC filter config, applied in order on top of the
+// current generation in shadow comparison mode.
+var NextVersionChanges = []NextVersionChange{
+	{Name: "authenticated-principal", Apply: (*Model).UseAuthenticatedPrincipal},
+}
+
+// UseNextVersion applies all the changes of the next version of the RBAC filter config to the model.
+func (m *Model) UseNextVersion() {
+	for _, change := range NextVersionChanges {
+		change.Apply(m)
+	}
+}
+
+// UseAuthenticatedPrincipal generates the source principals and namespaces on HTTP filter chains from the principal
+// of the peer certificate instead of the metadata of the Istio authn filter, as already done on TCP filter chains.
+func (m *Model) UseAuthenticatedPrincipal() {
+	for _, p := range m.principals {
+		for _, r := range p.rules {
+			switch r.g.(type) {
+			case srcPrincipalGenerator, srcNamespaceGenerator:
+				r.g = authenticatedGenerator{g: r.g}
+			}
+		}
+	}
+}
+
+// ExpandIPSets replaces the IP set references in the IP conditions with the IPs of the referenced sets.
+// Returns an error if a referenced IP set does not exist or is empty.
+func (m *Model) ExpandIPSets(ipSets map[string][]string) error {
+	expand := func(values []string) ([]string, error) {
+		var res []string
+		for _, v := range values {
+			name, ok := security.IPSetName(v)
+			if !ok {
+				res = append(res, v)
+				continue
+			}
+			ips := ipSets[name]
+			if len(ips) == 0 {
+				return nil, fmt.Errorf("IP set %q not found or empty", name)
+			}
+			res = append(res, ips...)
+		}
+		return res, nil
+	}
+	for _, rls := range [][]ruleList{m.principals, m.permissions} {
+		for _, rl := range rls {
+			for _, r := range rl.rules {
+				if r.key != attrSrcIP && r.key != attrRemoteIP && r.key != attrDestIP {
+					continue
+				}
+				var err error
+				if r.values, err = expand(r.values); err != nil {
+					return err
+				}
+				if r.notValues, err = expand(r.notValues); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
 // Generate generates the Envoy RBAC config from the model.
 func (m Model) Generate(forTCP bool, action rbacpb.RBAC_Action) (*rbacpb.Policy, error) {
 	var permissions []*rbacpb.Permission
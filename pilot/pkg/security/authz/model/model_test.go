@@ -99,6 +99,77 @@ when:
 	}
 }
 
+func TestModel_ExpandIPSets(t *testing.T) {
+	ipSets := map[string][]string{
+		"blocked": {"10.0.0.1", "10.1.0.0/16"},
+		"office":  {"192.168.0.0/24"},
+	}
+	cases := []struct {
+		name    string
+		rule    *authzpb.Rule
+		want    []string
+		notWant []string
+		wantErr bool
+	}{
+		{
+			name: "ip-blocks",
+			rule: yamlRule(t, `
+from:
+- source:
+    ipBlocks: ["ipset:blocked", "1.2.3.4"]
+    notRemoteIpBlocks: ["ipset:office"]
+`),
+			want:    []string{"10.0.0.1", "10.1.0.0/16", "1.2.3.4", "192.168.0.0/24"},
+			notWant: []string{"ipset:"},
+		},
+		{
+			name: "ip-attributes",
+			rule: yamlRule(t, `
+when:
+- key: source.ip
+  values: ["ipset:blocked"]
+- key: destination.ip
+  notValues: ["ipset:office"]
+`),
+			want:    []string{"10.0.0.1", "10.1.0.0/16", "192.168.0.0/24"},
+			notWant: []string{"ipset:"},
+		},
+		{
+			name: "not-found",
+			rule: yamlRule(t, `
+from:
+- source:
+    ipBlocks: ["ipset:unknown"]
+`),
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := New(tc.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = got.ExpandIPSets(ipSets)
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("got error %v, want error %v", err, tc.wantErr)
+			}
+			gotStr := spew.Sdump(got)
+			for _, want := range tc.want {
+				if !strings.Contains(gotStr, want) {
+					t.Errorf("got %s but not found %s", gotStr, want)
+				}
+			}
+			for _, notWant := range tc.notWant {
+				if strings.Contains(gotStr, notWant) {
+					t.Errorf("got %s but not want %s", gotStr, notWant)
+				}
+			}
+		})
+	}
+}
+
 func TestModel_Generate(t *testing.T) {
 	rule := yamlRule(t, `
 from:
@@ -18,6 +18,8 @@ import (
 	"fmt"
 	"strings"
 
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pkg/config/constants"
 	istiolog "istio.io/pkg/log"
 )
@@ -150,3 +152,15 @@ func getTrustDomainFromSpiffeIdentity(principal string) (string, error) {
 	trustDomain := identityParts[0]
 	return trustDomain, nil
 }
+
+// MeshAliases returns the trust domain aliases of the mesh. While a trust domain migration is in progress,
+// the trust domain being migrated from is also returned, so that workloads with certificates from either
+// trust domain are accepted during the migration window.
+func MeshAliases(mesh *meshconfig.MeshConfig) []string {
+	aliases := mesh.GetTrustDomainAliases()
+	from := features.TrustDomainMigrationFrom
+	if from == "" || from == mesh.GetTrustDomain() || isKeyInList(from, aliases) {
+		return aliases
+	}
+	return append(append([]string{}, aliases...), from)
+}
@@ -17,6 +17,9 @@ package trustdomain
 import (
 	"reflect"
 	"testing"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pilot/pkg/features"
 )
 
 func TestReplaceTrustDomainAliases(t *testing.T) {
@@ -180,3 +183,31 @@ func TestIsTrustDomainBeingEnforced(t *testing.T) {
 		}
 	}
 }
+
+func TestMeshAliases(t *testing.T) {
+	cases := []struct {
+		name    string
+		from    string
+		aliases []string
+		want    []string
+	}{
+		{name: "no migration", aliases: []string{"td1"}, want: []string{"td1"}},
+		{name: "migration", from: "old-td", aliases: []string{"td1"}, want: []string{"td1", "old-td"}},
+		{name: "migration from alias", from: "td1", aliases: []string{"td1"}, want: []string{"td1"}},
+		{name: "migration from current trust domain", from: "cluster.local", want: nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			defer func(from string) { features.TrustDomainMigrationFrom = from }(features.TrustDomainMigrationFrom)
+			features.TrustDomainMigrationFrom = c.from
+			mesh := &meshconfig.MeshConfig{TrustDomain: "cluster.local", TrustDomainAliases: c.aliases}
+			if got := MeshAliases(mesh); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+			if !reflect.DeepEqual(mesh.TrustDomainAliases, c.aliases) {
+				t.Errorf("mesh config was modified: %v", mesh.TrustDomainAliases)
+			}
+		})
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/hashicorp/go-multierror"
 
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/security/trustdomain"
 	"istio.io/istio/pilot/pkg/serviceregistry"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/labels"
@@ -331,7 +332,7 @@ func (c *Controller) GetIstioServiceAccounts(svc *model.Service, ports []int) []
 	if c.meshHolder != nil {
 		mesh := c.meshHolder.Mesh()
 		if mesh != nil {
-			tds = mesh.TrustDomainAliases
+			tds = trustdomain.MeshAliases(mesh)
 		}
 	}
 	expanded := spiffe.ExpandWithTrustDomains(result, tds)
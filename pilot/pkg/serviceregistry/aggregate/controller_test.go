@@ -23,6 +23,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/serviceregistry"
 	"istio.io/istio/pilot/pkg/serviceregistry/mock"
@@ -357,6 +358,7 @@ func TestGetIstioServiceAccounts(t *testing.T) {
 		name               string
 		svc                *model.Service
 		trustDomainAliases []string
+		migrationFrom      string
 		want               []string
 	}{
 		{
@@ -392,10 +394,24 @@ func TestGetIstioServiceAccounts(t *testing.T) {
 				"spiffe://example.com/ns/default/sa/world2",
 			},
 		},
+		{
+			name:               "ExpansionByTrustDomainMigration",
+			trustDomainAliases: []string{"cluster.local"},
+			migrationFrom:      "example.com",
+			svc:                mock.WorldService,
+			want: []string{
+				"spiffe://cluster.local/ns/default/sa/world1",
+				"spiffe://cluster.local/ns/default/sa/world2",
+				"spiffe://example.com/ns/default/sa/world1",
+				"spiffe://example.com/ns/default/sa/world2",
+			},
+		},
 	}
+	defer func(from string) { features.TrustDomainMigrationFrom = from }(features.TrustDomainMigrationFrom)
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			meshHolder.trustDomainAliases = tc.trustDomainAliases
+			features.TrustDomainMigrationFrom = tc.migrationFrom
 			accounts := aggregateCtl.GetIstioServiceAccounts(tc.svc, []int{})
 			if diff := cmp.Diff(accounts, tc.want); diff != "" {
 				t.Errorf("unexpected service account, diff %v", diff)
@@ -23,6 +23,7 @@ import (
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/pkg/serviceregistry/kube"
+	"istio.io/istio/pilot/pkg/util/intern"
 	"istio.io/istio/pkg/config/labels"
 	kubeUtil "istio.io/istio/pkg/kube"
 	"istio.io/pkg/log"
@@ -39,33 +40,38 @@ type EndpointBuilder struct {
 	tlsMode        string
 	workloadName   string
 	namespace      string
+	nodeName       string
 }
 
 func NewEndpointBuilder(c controllerInterface, pod *v1.Pod) *EndpointBuilder {
-	locality, sa, wn, namespace := "", "", "", ""
+	locality, sa, wn, namespace, node := "", "", "", "", ""
 	var podLabels labels.Instance
 	if pod != nil {
 		locality = c.getPodLocality(pod)
 		sa = kube.SecureNamingSAN(pod)
 		podLabels = pod.Labels
 		namespace = pod.Namespace
+		node = pod.Spec.NodeName
 	}
 	dm, _ := kubeUtil.GetDeployMetaFromPod(pod)
 	if dm != nil {
 		wn = dm.Name
 	}
 
+	// The metadata is shared by all the endpoints of the pod, and is interned as it is mostly the same for all
+	// the pods of a workload.
 	return &EndpointBuilder{
 		controller:     c,
-		labels:         augmentLabels(podLabels, c.Cluster(), locality),
-		serviceAccount: sa,
+		labels:         intern.Default.Labels(augmentLabels(podLabels, c.Cluster(), locality)),
+		serviceAccount: intern.Default.String(sa),
 		locality: model.Locality{
-			Label:     locality,
+			Label:     intern.Default.String(locality),
 			ClusterID: c.Cluster(),
 		},
 		tlsMode:      kube.PodTLSMode(pod),
-		workloadName: wn,
-		namespace:    namespace,
+		workloadName: intern.Default.String(wn),
+		namespace:    intern.Default.String(namespace),
+		nodeName:     intern.Default.String(node),
 	}
 }
 
@@ -80,7 +86,8 @@ func NewEndpointBuilderFromMetadata(c controllerInterface, proxy *model.Proxy) *
 			Label:     locality,
 			ClusterID: c.Cluster(),
 		},
-		tlsMode: model.GetTLSModeFromEndpointLabels(proxy.Metadata.Labels),
+		tlsMode:  model.GetTLSModeFromEndpointLabels(proxy.Metadata.Labels),
+		nodeName: proxy.Metadata.NodeName,
 	}
 }
 
@@ -124,10 +131,11 @@ func (b *EndpointBuilder) buildIstioEndpoint(
 		TLSMode:         b.tlsMode,
 		Address:         endpointAddress,
 		EndpointPort:    uint32(endpointPort),
-		ServicePortName: svcPortName,
-		Network:         b.endpointNetwork(endpointAddress),
+		ServicePortName: intern.Default.String(svcPortName),
+		Network:         intern.Default.String(b.endpointNetwork(endpointAddress)),
 		WorkloadName:    b.workloadName,
 		Namespace:       b.namespace,
+		NodeName:        b.nodeName,
 	}
 }
 
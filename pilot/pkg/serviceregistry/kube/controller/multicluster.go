@@ -71,8 +71,9 @@ type Multicluster struct {
 	serviceEntryStore *serviceentry.ServiceEntryStore
 	XDSUpdater        model.XDSUpdater
 
-	m                     sync.Mutex // protects remoteKubeControllers
+	m                     sync.Mutex // protects remoteKubeControllers and namespaceControllers
 	remoteKubeControllers map[string]*kubeController
+	namespaceControllers  map[string]*NamespaceController
 	networksWatcher       mesh.NetworksWatcher
 
 	// fetchCaRoot maps the certificate name to the certificate
@@ -116,6 +117,7 @@ func NewMulticluster(
 		fetchCaRoot:           fetchCaRoot,
 		XDSUpdater:            opts.XDSUpdater,
 		remoteKubeControllers: remoteKubeController,
+		namespaceControllers:  make(map[string]*NamespaceController),
 		networksWatcher:       networksWatcher,
 		secretNamespace:       secretNamespace,
 		syncInterval:          opts.GetSyncInterval(),
@@ -196,6 +198,17 @@ func (m *Multicluster) AddMemberCluster(client kubelib.Client, clusterID string)
 				// recreate it again.
 				client.RunAndWait(stopCh)
 				nc.Run(leaderStop)
+				m.m.Lock()
+				m.namespaceControllers[clusterID] = nc
+				m.m.Unlock()
+				go func() {
+					<-leaderStop
+					m.m.Lock()
+					if m.namespaceControllers[clusterID] == nc {
+						delete(m.namespaceControllers, clusterID)
+					}
+					m.m.Unlock()
+				}()
 			}).Run(stopCh)
 	}
 
@@ -295,6 +308,16 @@ func (m *Multicluster) GetRemoteKubeClient(clusterID string) kubernetes.Interfac
 	return nil
 }
 
+// RefreshNamespaceData updates the ConfigMaps of all namespaces with the data fetched from fetchCaRoot, on the
+// clusters this instance runs the namespace controller of.
+func (m *Multicluster) RefreshNamespaceData() {
+	m.m.Lock()
+	defer m.m.Unlock()
+	for _, nc := range m.namespaceControllers {
+		nc.Refresh()
+	}
+}
+
 func (m *Multicluster) InitSecretController(stop <-chan struct{}) {
 	m.secretController = secretcontroller.StartSecretController(
 		m.client, m.AddMemberCluster, m.UpdateMemberCluster, m.DeleteMemberCluster,
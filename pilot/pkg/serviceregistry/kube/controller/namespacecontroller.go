@@ -123,6 +123,16 @@ func (nc *NamespaceController) Run(stopCh <-chan struct{}) {
 	go nc.queue.Run(stopCh)
 }
 
+// Refresh updates the config map of every namespace with the current data.
+func (nc *NamespaceController) Refresh() {
+	for _, obj := range nc.namespacesInformer.GetStore().List() {
+		ns := obj.(*v1.Namespace)
+		nc.queue.Push(func() error {
+			return nc.namespaceChange(ns)
+		})
+	}
+}
+
 // insertDataForNamespace will add data into the configmap for the specified namespace
 // If the configmap is not found, it will be created.
 // If you know the current contents of the configmap, using UpdateDataInConfigMap is more efficient.
@@ -60,6 +60,7 @@ func TestConvertProtocol(t *testing.T) {
 		{27017, "httptest", nil, coreV1.ProtocolTCP, protocol.TCP},
 		{8888, "https", nil, coreV1.ProtocolTCP, protocol.HTTPS},
 		{8888, "https-test", nil, coreV1.ProtocolTCP, protocol.HTTPS},
+		{8888, "http3-test", nil, coreV1.ProtocolTCP, protocol.Unsupported},
 		{8888, "http2", nil, coreV1.ProtocolTCP, protocol.HTTP2},
 		{8888, "http2-test", nil, coreV1.ProtocolTCP, protocol.HTTP2},
 		{8888, "grpc", nil, coreV1.ProtocolTCP, protocol.GRPC},
@@ -16,19 +16,25 @@ package serviceentry
 
 import (
 	"net"
+	"sort"
+	"strconv"
 	"strings"
 
 	"istio.io/api/label"
 	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/serviceregistry"
+	"istio.io/istio/pilot/pkg/util/intern"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/routing"
 	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/istio/pkg/config/visibility"
 	"istio.io/istio/pkg/spiffe"
+	"istio.io/pkg/log"
 )
 
 // TODO: rename 'external' to service_entries or other specific name, the term 'external' is too broad
@@ -86,18 +92,7 @@ func ServiceToServiceEntry(svc *model.Service) *config.Config {
 		se.Location = networking.ServiceEntry_MESH_INTERNAL
 	}
 
-	// Reverse in convertServices. Note that enum values are different
-	// TODO: make the enum match, should be safe (as long as they're used as enum)
-	var resolution networking.ServiceEntry_Resolution
-	switch svc.Resolution {
-	case model.Passthrough: // 2
-		resolution = networking.ServiceEntry_NONE // 0
-	case model.DNSLB: // 1
-		resolution = networking.ServiceEntry_DNS // 2
-	case model.ClientSideLB: // 0
-		resolution = networking.ServiceEntry_STATIC // 1
-	}
-	se.Resolution = resolution
+	se.Resolution = serviceEntryResolution(svc.Resolution)
 
 	// Port is mapped from ServicePort
 	for _, p := range svc.Ports {
@@ -119,6 +114,9 @@ func ServiceToServiceEntry(svc *model.Service) *config.Config {
 		},
 		Spec: se,
 	}
+	if len(svc.PortResolutions) > 0 {
+		cfg.Annotations = map[string]string{routing.PortResolutionAnnotation: portResolutionsAnnotation(svc.PortResolutions)}
+	}
 
 	// TODO: WorkloadSelector
 
@@ -133,6 +131,125 @@ func ServiceToServiceEntry(svc *model.Service) *config.Config {
 	return cfg
 }
 
+func convertResolution(resolution networking.ServiceEntry_Resolution) model.Resolution {
+	switch resolution {
+	case networking.ServiceEntry_NONE:
+		return model.Passthrough
+	case networking.ServiceEntry_DNS:
+		// The hosts resolved by istiod are sent with EDS, like static endpoints.
+		if features.ResolveServiceEntryDNS {
+			return model.ClientSideLB
+		}
+		return model.DNSLB
+	default:
+		return model.ClientSideLB
+	}
+}
+
+// serviceEntryResolution is the reverse of convertResolution. Note that enum values are different.
+// TODO: make the enum match, should be safe (as long as they're used as enum)
+func serviceEntryResolution(resolution model.Resolution) networking.ServiceEntry_Resolution {
+	switch resolution {
+	case model.Passthrough: // 2
+		return networking.ServiceEntry_NONE // 0
+	case model.DNSLB: // 1
+		return networking.ServiceEntry_DNS // 2
+	default: // model.ClientSideLB: 0
+		return networking.ServiceEntry_STATIC // 1
+	}
+}
+
+// portResolutionsAnnotation is the reverse of convertPortResolutions.
+func portResolutionsAnnotation(resolutions map[int]model.Resolution) string {
+	ports := make([]int, 0, len(resolutions))
+	for port := range resolutions {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+	entries := make([]string, 0, len(ports))
+	for _, port := range ports {
+		entries = append(entries, strconv.Itoa(port)+"="+serviceEntryResolution(resolutions[port]).String())
+	}
+	return strings.Join(entries, ",")
+}
+
+// convertPortResolutions returns the resolutions overridden for individual ports of the service entry, if any.
+func convertPortResolutions(cfg config.Config) map[int]model.Resolution {
+	resolutions := portResolutions(cfg)
+	if resolutions == nil {
+		return nil
+	}
+	out := make(map[int]model.Resolution, len(resolutions))
+	for port, resolution := range resolutions {
+		out[port] = convertResolution(resolution)
+	}
+	return out
+}
+
+// portResolutions returns the resolutions of the PortResolutionAnnotation of the service entry, if any.
+func portResolutions(cfg config.Config) map[int]networking.ServiceEntry_Resolution {
+	value, f := cfg.Annotations[routing.PortResolutionAnnotation]
+	if !f {
+		return nil
+	}
+	resolutions, err := routing.ParsePortResolutions(value)
+	if err != nil {
+		log.Warnf("ignoring port resolutions of service entry %s/%s: %v", cfg.Namespace, cfg.Name, err)
+		return nil
+	}
+	out := make(map[int]networking.ServiceEntry_Resolution, len(resolutions))
+	for port, resolution := range resolutions {
+		out[int(port)] = resolution
+	}
+	// The ports of a range have the resolution of the port the range starts at.
+	if value, f := cfg.Annotations[routing.PortRangesAnnotation]; f {
+		ranges, _ := routing.ParsePortRanges(value)
+		for _, r := range ranges {
+			if resolution, f := out[int(r.Start)]; f {
+				for port := r.Start + 1; port <= r.End; port++ {
+					out[int(port)] = resolution
+				}
+			}
+		}
+	}
+	return out
+}
+
+// expandPortRanges returns the service entry with the ports of the ranges of its PortRangesAnnotation, if any, so
+// that they are converted like the declared ports.
+func expandPortRanges(cfg config.Config) config.Config {
+	value, f := cfg.Annotations[routing.PortRangesAnnotation]
+	if !f {
+		return cfg
+	}
+	ranges, err := routing.ParsePortRanges(value)
+	if err != nil {
+		log.Warnf("ignoring port ranges of service entry %s/%s: %v", cfg.Namespace, cfg.Name, err)
+		return cfg
+	}
+	serviceEntry := cfg.Spec.(*networking.ServiceEntry)
+	ports, err := routing.ExpandPorts(serviceEntry.Ports, ranges)
+	if err != nil {
+		log.Warnf("ignoring port ranges of service entry %s/%s: %v", cfg.Namespace, cfg.Name, err)
+		return cfg
+	}
+	expanded := *serviceEntry
+	expanded.Ports = ports
+	cfg.Spec = &expanded
+	return cfg
+}
+
+// hasDNSResolution returns true if any port of the services is resolved with DNS, in which case their endpoints are
+// sent in the clusters rather than with EDS.
+func hasDNSResolution(services []*model.Service) bool {
+	for _, svc := range services {
+		if svc.HasResolution(model.DNSLB) {
+			return true
+		}
+	}
+	return false
+}
+
 // convertServices transforms a ServiceEntry config to a list of internal Service objects.
 func convertServices(cfg config.Config) []*model.Service {
 	serviceEntry := cfg.Spec.(*networking.ServiceEntry)
@@ -140,15 +257,8 @@ func convertServices(cfg config.Config) []*model.Service {
 
 	out := make([]*model.Service, 0)
 
-	var resolution model.Resolution
-	switch serviceEntry.Resolution {
-	case networking.ServiceEntry_NONE:
-		resolution = model.Passthrough
-	case networking.ServiceEntry_DNS:
-		resolution = model.DNSLB
-	case networking.ServiceEntry_STATIC:
-		resolution = model.ClientSideLB
-	}
+	resolution := convertResolution(serviceEntry.Resolution)
+	portResolutions := convertPortResolutions(cfg)
 
 	svcPorts := make(model.PortList, 0, len(serviceEntry.Ports))
 	for _, port := range serviceEntry.Ports {
@@ -178,12 +288,13 @@ func convertServices(cfg config.Config) []*model.Service {
 						newAddress = ip.String()
 					}
 					out = append(out, &model.Service{
-						CreationTime: creationTime,
-						MeshExternal: serviceEntry.Location == networking.ServiceEntry_MESH_EXTERNAL,
-						Hostname:     host.Name(hostname),
-						Address:      newAddress,
-						Ports:        svcPorts,
-						Resolution:   resolution,
+						CreationTime:    creationTime,
+						MeshExternal:    serviceEntry.Location == networking.ServiceEntry_MESH_EXTERNAL,
+						Hostname:        host.Name(hostname),
+						Address:         newAddress,
+						Ports:           svcPorts,
+						Resolution:      resolution,
+						PortResolutions: portResolutions,
 						Attributes: model.ServiceAttributes{
 							ServiceRegistry: string(serviceregistry.External),
 							Name:            hostname,
@@ -195,12 +306,13 @@ func convertServices(cfg config.Config) []*model.Service {
 					})
 				} else if net.ParseIP(address) != nil {
 					out = append(out, &model.Service{
-						CreationTime: creationTime,
-						MeshExternal: serviceEntry.Location == networking.ServiceEntry_MESH_EXTERNAL,
-						Hostname:     host.Name(hostname),
-						Address:      address,
-						Ports:        svcPorts,
-						Resolution:   resolution,
+						CreationTime:    creationTime,
+						MeshExternal:    serviceEntry.Location == networking.ServiceEntry_MESH_EXTERNAL,
+						Hostname:        host.Name(hostname),
+						Address:         address,
+						Ports:           svcPorts,
+						Resolution:      resolution,
+						PortResolutions: portResolutions,
 						Attributes: model.ServiceAttributes{
 							ServiceRegistry: string(serviceregistry.External),
 							Name:            hostname,
@@ -214,12 +326,13 @@ func convertServices(cfg config.Config) []*model.Service {
 			}
 		} else {
 			out = append(out, &model.Service{
-				CreationTime: creationTime,
-				MeshExternal: serviceEntry.Location == networking.ServiceEntry_MESH_EXTERNAL,
-				Hostname:     host.Name(hostname),
-				Address:      constants.UnspecifiedIP,
-				Ports:        svcPorts,
-				Resolution:   resolution,
+				CreationTime:    creationTime,
+				MeshExternal:    serviceEntry.Location == networking.ServiceEntry_MESH_EXTERNAL,
+				Hostname:        host.Name(hostname),
+				Address:         constants.UnspecifiedIP,
+				Ports:           svcPorts,
+				Resolution:      resolution,
+				PortResolutions: portResolutions,
 				Attributes: model.ServiceAttributes{
 					ServiceRegistry: string(serviceregistry.External),
 					Name:            hostname,
@@ -263,19 +376,19 @@ func convertEndpoint(service *model.Service, servicePort *networking.Port,
 		Endpoint: &model.IstioEndpoint{
 			Address:         addr,
 			EndpointPort:    instancePort,
-			ServicePortName: servicePort.Name,
-			Network:         endpoint.Network,
+			ServicePortName: intern.Default.String(servicePort.Name),
+			Network:         intern.Default.String(endpoint.Network),
 			Locality: model.Locality{
-				Label: endpoint.Locality,
+				Label: intern.Default.String(endpoint.Locality),
 			},
 			LbWeight:       endpoint.Weight,
-			Labels:         endpoint.Labels,
+			Labels:         intern.Default.Labels(endpoint.Labels),
 			TLSMode:        tlsMode,
-			ServiceAccount: sa,
+			ServiceAccount: intern.Default.String(sa),
 			// Workload entry config name is used as workload name, which will appear in metric label.
 			// After VM auto registry is introduced, workload group annotation should be used for workload name.
-			WorkloadName: wleck.name,
-			Namespace:    wleck.namespace,
+			WorkloadName: intern.Default.String(wleck.name),
+			Namespace:    intern.Default.String(wleck.namespace),
 		},
 		Service:     service,
 		ServicePort: convertPort(servicePort),
@@ -301,10 +414,15 @@ func convertServiceEntryToInstances(cfg config.Config, services []*model.Service
 	if services == nil {
 		services = convertServices(cfg)
 	}
+	resolutions := portResolutions(cfg)
 	for _, service := range services {
 		for _, serviceEntryPort := range serviceEntry.Ports {
+			resolution, f := resolutions[int(serviceEntryPort.Number)]
+			if !f {
+				resolution = serviceEntry.Resolution
+			}
 			if len(serviceEntry.Endpoints) == 0 && serviceEntry.WorkloadSelector == nil &&
-				serviceEntry.Resolution == networking.ServiceEntry_DNS {
+				resolution == networking.ServiceEntry_DNS {
 				// Note: only convert the hostname to service instance if WorkloadSelector is not set
 				// when service entry has discovery type DNS and no endpoints
 				// we create endpoints from service's host
@@ -0,0 +1,390 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serviceentry
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/pkg/log"
+)
+
+// dnsQueryTimeout is the timeout of each DNS query sent to the upstream servers.
+const dnsQueryTimeout = 5 * time.Second
+
+// dnsEndpoint is an address resolved for a DNS name. The port and weight are set for the targets of SRV records.
+type dnsEndpoint struct {
+	address string
+	port    uint32
+	weight  uint32
+}
+
+// dnsName is a name resolved by the dnsResolver: the A and AAAA records of a host, or the SRV records of a port.
+type dnsName struct {
+	name string
+	srv  bool
+}
+
+// dnsRecords are the endpoints resolved for a name, which are resolved again once expired.
+type dnsRecords struct {
+	endpoints []dnsEndpoint
+	expiry    time.Time
+	// services are the services whose instances use the records.
+	services map[instancesKey]struct{}
+}
+
+// dnsLookupFunc resolves a name, returning its endpoints and the TTL of its records.
+type dnsLookupFunc func(name dnsName) ([]dnsEndpoint, time.Duration, error)
+
+// dnsResolver resolves the hosts of the instances of the ServiceEntries with DNS resolution, so that their addresses
+// are sent with EDS. Each name is resolved again when the TTL of its records expires, bounded by minTTL and maxTTL,
+// and only the services using the names whose endpoints changed are reported to onChange.
+type dnsResolver struct {
+	minTTL   time.Duration
+	maxTTL   time.Duration
+	srv      bool
+	lookup   dnsLookupFunc
+	onChange func(map[instancesKey]struct{})
+
+	mu    sync.Mutex
+	names map[dnsName]*dnsRecords
+	// wakeup is signaled when new names must be resolved.
+	wakeup chan struct{}
+}
+
+func newDNSResolver(minTTL, maxTTL time.Duration, srv bool, lookup dnsLookupFunc,
+	onChange func(map[instancesKey]struct{})) *dnsResolver {
+	if maxTTL < minTTL {
+		maxTTL = minTTL
+	}
+	return &dnsResolver{
+		minTTL:   minTTL,
+		maxTTL:   maxTTL,
+		srv:      srv,
+		lookup:   lookup,
+		onChange: onChange,
+		names:    map[dnsName]*dnsRecords{},
+		wakeup:   make(chan struct{}, 1),
+	}
+}
+
+// needsResolution returns true if the address is a host name rather than an IP or a unix domain socket.
+func needsResolution(address string) bool {
+	return address != "" && net.ParseIP(address) == nil && !strings.HasPrefix(address, "/") &&
+		!strings.HasPrefix(address, "@")
+}
+
+// srvName returns the name of the SRV records of the port of the host.
+func srvName(hostname string, port *model.Port) string {
+	proto := "tcp"
+	if port.Protocol == protocol.UDP {
+		proto = "udp"
+	}
+	return "_" + port.Name + "._" + proto + "." + hostname
+}
+
+// resolveInstances replaces the instances whose address is a host name with an instance for each of its resolved
+// endpoints. The names not resolved yet are queued for resolution, and their instances are dropped until then.
+func (r *dnsResolver) resolveInstances(instances []*model.ServiceInstance) []*model.ServiceInstance {
+	out := make([]*model.ServiceInstance, 0, len(instances))
+	for _, instance := range instances {
+		if !needsResolution(instance.Endpoint.Address) {
+			out = append(out, instance)
+			continue
+		}
+		key := makeInstanceKey(instance)
+		var endpoints []dnsEndpoint
+		if r.srv && instance.ServicePort != nil && instance.ServicePort.Name != "" {
+			endpoints = r.get(dnsName{name: srvName(instance.Endpoint.Address, instance.ServicePort), srv: true}, key)
+		}
+		if len(endpoints) == 0 {
+			endpoints = r.get(dnsName{name: instance.Endpoint.Address}, key)
+		}
+		for _, ep := range endpoints {
+			resolved := *instance.Endpoint
+			resolved.Address = ep.address
+			if ep.port != 0 {
+				resolved.EndpointPort = ep.port
+			}
+			if ep.weight != 0 {
+				resolved.LbWeight = ep.weight
+			}
+			out = append(out, &model.ServiceInstance{
+				Service:     instance.Service,
+				ServicePort: instance.ServicePort,
+				Endpoint:    &resolved,
+			})
+		}
+	}
+	return out
+}
+
+// get returns the endpoints of the name, recording that the service uses them.
+func (r *dnsResolver) get(name dnsName, service instancesKey) []dnsEndpoint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	records, f := r.names[name]
+	if !f {
+		records = &dnsRecords{services: map[instancesKey]struct{}{}}
+		r.names[name] = records
+		select {
+		case r.wakeup <- struct{}{}:
+		default:
+		}
+	}
+	records.services[service] = struct{}{}
+	return records.endpoints
+}
+
+// clearServices forgets the services using the names, before the instances of all the services are resolved again.
+func (r *dnsResolver) clearServices() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, records := range r.names {
+		records.services = map[instancesKey]struct{}{}
+	}
+}
+
+// prune stops resolving the names no longer used by any service.
+func (r *dnsResolver) prune() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, records := range r.names {
+		if len(records.services) == 0 {
+			delete(r.names, name)
+		}
+	}
+}
+
+// Run resolves the names as they expire until the stop channel is closed.
+func (r *dnsResolver) Run(stop <-chan struct{}) {
+	for {
+		timer := time.NewTimer(r.refresh())
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-r.wakeup:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// refresh resolves the expired names, reports the services whose endpoints changed, and returns the duration until
+// the next name expires.
+func (r *dnsResolver) refresh() time.Duration {
+	now := time.Now()
+	var expired []dnsName
+	r.mu.Lock()
+	for name, records := range r.names {
+		if !records.expiry.After(now) {
+			expired = append(expired, name)
+		}
+	}
+	r.mu.Unlock()
+
+	changed := map[instancesKey]struct{}{}
+	for _, name := range expired {
+		endpoints, ttl, err := r.lookup(name)
+		if err != nil {
+			log.Warnf("failed to resolve %s: %v", name.name, err)
+		}
+		if ttl < r.minTTL {
+			ttl = r.minTTL
+		} else if ttl > r.maxTTL {
+			ttl = r.maxTTL
+		}
+		sort.Slice(endpoints, func(i, j int) bool {
+			if endpoints[i].address != endpoints[j].address {
+				return endpoints[i].address < endpoints[j].address
+			}
+			return endpoints[i].port < endpoints[j].port
+		})
+
+		r.mu.Lock()
+		records, f := r.names[name]
+		if f {
+			records.expiry = time.Now().Add(ttl)
+			// The last endpoints are kept when the resolution fails.
+			if err == nil && (len(records.endpoints) > 0 || len(endpoints) > 0) &&
+				!reflect.DeepEqual(records.endpoints, endpoints) {
+				records.endpoints = endpoints
+				for service := range records.services {
+					changed[service] = struct{}{}
+				}
+			}
+		}
+		r.mu.Unlock()
+	}
+	if len(changed) > 0 {
+		r.onChange(changed)
+	}
+
+	next := r.maxTTL
+	now = time.Now()
+	r.mu.Lock()
+	for _, records := range r.names {
+		if d := records.expiry.Sub(now); d < next {
+			next = d
+		}
+	}
+	r.mu.Unlock()
+	if next < 0 {
+		next = 0
+	}
+	return next
+}
+
+// newDNSLookup returns a lookup sending the queries to the name servers of /etc/resolv.conf.
+func newDNSLookup() dnsLookupFunc {
+	config, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+	if err != nil {
+		log.Errorf("failed to load /etc/resolv.conf, ServiceEntry hosts will not be resolved: %v", err)
+		return func(dnsName) ([]dnsEndpoint, time.Duration, error) {
+			return nil, 0, err
+		}
+	}
+	servers := make([]string, 0, len(config.Servers))
+	for _, s := range config.Servers {
+		servers = append(servers, net.JoinHostPort(s, config.Port))
+	}
+	l := &dnsLookup{client: &dns.Client{Timeout: dnsQueryTimeout}, servers: servers}
+	return l.lookup
+}
+
+type dnsLookup struct {
+	client  *dns.Client
+	servers []string
+}
+
+func (l *dnsLookup) lookup(name dnsName) ([]dnsEndpoint, time.Duration, error) {
+	if name.srv {
+		return l.lookupSRV(name.name)
+	}
+	return l.lookupHost(name.name)
+}
+
+// lookupHost returns the addresses of the A and AAAA records of the host, and their lowest TTL.
+func (l *dnsLookup) lookupHost(host string) ([]dnsEndpoint, time.Duration, error) {
+	var endpoints []dnsEndpoint
+	var ttl uint32
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		answer, _, err := l.query(host, qtype)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, rr := range answer {
+			switch record := rr.(type) {
+			case *dns.A:
+				endpoints = append(endpoints, dnsEndpoint{address: record.A.String()})
+			case *dns.AAAA:
+				endpoints = append(endpoints, dnsEndpoint{address: record.AAAA.String()})
+			default:
+				continue
+			}
+			ttl = minTTL(ttl, rr.Header().Ttl)
+		}
+	}
+	return endpoints, time.Duration(ttl) * time.Second, nil
+}
+
+// lookupSRV returns the addresses and ports of the targets of the SRV records, and the lowest TTL of the records.
+func (l *dnsLookup) lookupSRV(name string) ([]dnsEndpoint, time.Duration, error) {
+	answer, extra, err := l.query(name, dns.TypeSRV)
+	if err != nil {
+		return nil, 0, err
+	}
+	// The addresses of the targets are usually sent in the additional section.
+	addresses := map[string][]string{}
+	for _, rr := range extra {
+		switch record := rr.(type) {
+		case *dns.A:
+			addresses[record.Hdr.Name] = append(addresses[record.Hdr.Name], record.A.String())
+		case *dns.AAAA:
+			addresses[record.Hdr.Name] = append(addresses[record.Hdr.Name], record.AAAA.String())
+		}
+	}
+	var endpoints []dnsEndpoint
+	var ttl uint32
+	for _, rr := range answer {
+		record, ok := rr.(*dns.SRV)
+		if !ok {
+			continue
+		}
+		ttl = minTTL(ttl, record.Hdr.Ttl)
+		targets, f := addresses[record.Target]
+		if !f {
+			resolved, targetTTL, err := l.lookupHost(record.Target)
+			if err != nil {
+				return nil, 0, err
+			}
+			if targetTTL > 0 {
+				ttl = minTTL(ttl, uint32(targetTTL/time.Second))
+			}
+			for _, ep := range resolved {
+				targets = append(targets, ep.address)
+			}
+		}
+		for _, address := range targets {
+			endpoints = append(endpoints, dnsEndpoint{address: address, port: uint32(record.Port), weight: uint32(record.Weight)})
+		}
+	}
+	return endpoints, time.Duration(ttl) * time.Second, nil
+}
+
+// query sends the query to the name servers in order, until one of them answers. A name that does not exist
+// has no records.
+func (l *dnsLookup) query(name string, qtype uint16) ([]dns.RR, []dns.RR, error) {
+	req := new(dns.Msg)
+	req.SetQuestion(dns.Fqdn(name), qtype)
+	var lastErr error
+	for _, server := range l.servers {
+		resp, _, err := l.client.Exchange(req, server)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		switch resp.Rcode {
+		case dns.RcodeSuccess:
+			return resp.Answer, resp.Extra, nil
+		case dns.RcodeNameError:
+			return nil, nil, nil
+		default:
+			lastErr = fmt.Errorf("%s query failed: %s", dns.TypeToString[qtype], dns.RcodeToString[resp.Rcode])
+		}
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no name server configured")
+	}
+	return nil, nil, lastErr
+}
+
+// minTTL returns the lowest of the TTLs, 0 meaning unset.
+func minTTL(a, b uint32) uint32 {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,104 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serviceentry
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/protocol"
+)
+
+func TestDNSResolver(t *testing.T) {
+	records := map[dnsName][]dnsEndpoint{
+		{name: "a.example.com"}:                       {{address: "10.0.0.2"}, {address: "10.0.0.1"}},
+		{name: "_grpc._tcp.b.example.com", srv: true}: {{address: "10.0.1.1", port: 9090, weight: 5}},
+	}
+	ttls := map[dnsName]time.Duration{
+		{name: "a.example.com"}: time.Hour,
+	}
+	lookup := func(name dnsName) ([]dnsEndpoint, time.Duration, error) {
+		return append([]dnsEndpoint{}, records[name]...), ttls[name], nil
+	}
+	var changed map[instancesKey]struct{}
+	r := newDNSResolver(time.Second, time.Minute, true, lookup, func(keys map[instancesKey]struct{}) {
+		changed = keys
+	})
+
+	instance := func(hostname string, port *model.Port) *model.ServiceInstance {
+		return &model.ServiceInstance{
+			Service:     &model.Service{Hostname: "svc.example.com", Attributes: model.ServiceAttributes{Namespace: "default"}},
+			ServicePort: port,
+			Endpoint:    &model.IstioEndpoint{Address: hostname, EndpointPort: uint32(port.Port), ServicePortName: port.Name},
+		}
+	}
+	httpPort := &model.Port{Name: "http", Port: 80, Protocol: protocol.HTTP}
+	grpcPort := &model.Port{Name: "grpc", Port: 8080, Protocol: protocol.GRPC}
+	instances := []*model.ServiceInstance{
+		instance("a.example.com", httpPort),
+		instance("b.example.com", grpcPort),
+		instance("10.0.2.1", httpPort),
+	}
+	addresses := func(instances []*model.ServiceInstance) []string {
+		var out []string
+		for _, i := range instances {
+			out = append(out, i.Endpoint.Address)
+		}
+		return out
+	}
+
+	// The hosts are not resolved yet.
+	if got, want := addresses(r.resolveInstances(instances)), []string{"10.0.2.1"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got addresses %v, want %v", got, want)
+	}
+
+	if next := r.refresh(); next > time.Second {
+		t.Errorf("expected the names without TTL to be resolved again within the minimum TTL, got %v", next)
+	}
+	key := instancesKey{hostname: "svc.example.com", namespace: "default"}
+	if _, f := changed[key]; !f {
+		t.Fatalf("expected the service to be updated, got %v", changed)
+	}
+	resolved := r.resolveInstances(instances)
+	if got, want := addresses(resolved), []string{"10.0.0.1", "10.0.0.2", "10.0.1.1", "10.0.2.1"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got addresses %v, want %v", got, want)
+	}
+	if ep := resolved[2].Endpoint; ep.EndpointPort != 9090 || ep.LbWeight != 5 {
+		t.Errorf("expected the port and weight of the SRV record, got %v and %v", ep.EndpointPort, ep.LbWeight)
+	}
+	if expiry := r.names[dnsName{name: "a.example.com"}].expiry; time.Until(expiry) > time.Minute {
+		t.Errorf("expected the TTL to be bounded by the maximum TTL, expires at %v", expiry)
+	}
+
+	// Unchanged records do not update the service.
+	changed = nil
+	for _, records := range r.names {
+		records.expiry = time.Time{}
+	}
+	r.refresh()
+	if changed != nil {
+		t.Errorf("expected no update, got %v", changed)
+	}
+
+	// The names no longer used are no longer resolved.
+	r.clearServices()
+	r.resolveInstances(instances[:1])
+	r.prune()
+	if _, f := r.names[dnsName{name: "_grpc._tcp.b.example.com", srv: true}]; f {
+		t.Errorf("expected the unused SRV name to be pruned")
+	}
+}
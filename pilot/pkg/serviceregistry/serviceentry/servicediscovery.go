@@ -81,6 +81,8 @@ type ServiceEntryStore struct { // nolint:golint
 	seWithSelectorByNamespace map[string][]servicesWithEntry
 	refreshIndexes            *atomic.Bool
 	workloadHandlers          []func(*model.WorkloadInstance, model.Event)
+	// dnsResolver resolves the hosts of the ServiceEntries with DNS resolution, if resolved by istiod.
+	dnsResolver *dnsResolver
 
 	processServiceEntry bool
 }
@@ -113,6 +115,10 @@ func NewServiceDiscovery(
 	for _, o := range options {
 		o(s)
 	}
+	if s.processServiceEntry && features.ResolveServiceEntryDNS {
+		s.dnsResolver = newDNSResolver(features.ServiceEntryDNSMinTTL, features.ServiceEntryDNSMaxTTL,
+			features.EnableServiceEntryDNSSRV, newDNSLookup(), s.dnsUpdate)
+	}
 
 	if configController != nil {
 		if s.processServiceEntry {
@@ -190,7 +196,7 @@ func (s *ServiceEntryStore) workloadEntryHandler(old, curr config.Config, event
 		if selected {
 			// If serviceentry's resolution is DNS, make a full push
 			// TODO: maybe cds?
-			if se.entry.Resolution == networking.ServiceEntry_DNS {
+			if hasDNSResolution(se.services) {
 				fullPush = true
 				for key, value := range getUpdatedConfigs(se.services) {
 					configsUpdated[key] = value
@@ -245,6 +251,7 @@ func getUpdatedConfigs(services []*model.Service) map[model.ConfigKey]struct{} {
 
 // serviceEntryHandler defines the handler for service entries
 func (s *ServiceEntryStore) serviceEntryHandler(old, curr config.Config, event model.Event) {
+	old, curr = expandPortRanges(old), expandPortRanges(curr)
 	cs := convertServices(curr)
 	configsUpdated := map[model.ConfigKey]struct{}{}
 
@@ -302,7 +309,7 @@ func (s *ServiceEntryStore) serviceEntryHandler(old, curr config.Config, event m
 		// If the service entry had endpoints with FQDNs (i.e. resolution DNS), then we need to do
 		// full push (as fqdn endpoints go via strict_dns clusters in cds).
 		// Non DNS service entries are sent via EDS. So we should compare and update if such endpoints change.
-		if currentServiceEntry.Resolution == networking.ServiceEntry_DNS {
+		if hasDNSResolution(unchangedSvcs) {
 			if !reflect.DeepEqual(currentServiceEntry.Endpoints, oldServiceEntry.Endpoints) {
 				// fqdn endpoints have changed. Need full push
 				for _, svc := range unchangedSvcs {
@@ -320,7 +327,7 @@ func (s *ServiceEntryStore) serviceEntryHandler(old, curr config.Config, event m
 		// If will do full-push, leave the edsUpdate to that.
 		// XXX We should do edsUpdate for all unchangedSvcs since we begin to calculate service
 		// data according to this "configsUpdated" and thus remove the "!willFullPush" condition.
-		instances := convertServiceEntryToInstances(curr, unchangedSvcs)
+		instances := s.resolveInstances(convertServiceEntryToInstances(curr, unchangedSvcs))
 		key := configKey{
 			kind:      serviceEntryConfigType,
 			name:      curr.Name,
@@ -346,7 +353,7 @@ func (s *ServiceEntryStore) serviceEntryHandler(old, curr config.Config, event m
 	allServices = append(allServices, updatedSvcs...)
 	allServices = append(allServices, unchangedSvcs...)
 	for _, svc := range allServices {
-		if svc.Resolution != model.DNSLB {
+		if svc.HasResolution(model.ClientSideLB) || svc.HasResolution(model.Passthrough) {
 			nonDNSServices = append(nonDNSServices, svc)
 		}
 	}
@@ -472,7 +479,27 @@ func (s *ServiceEntryStore) AppendWorkloadHandler(h func(*model.WorkloadInstance
 }
 
 // Run is used by some controllers to execute background jobs after init is done.
-func (s *ServiceEntryStore) Run(_ <-chan struct{}) {}
+func (s *ServiceEntryStore) Run(stop <-chan struct{}) {
+	if s.dnsResolver != nil {
+		s.dnsResolver.Run(stop)
+	}
+}
+
+// resolveInstances replaces the host names of the instances with their resolved addresses, if resolved by istiod.
+func (s *ServiceEntryStore) resolveInstances(instances []*model.ServiceInstance) []*model.ServiceInstance {
+	if s.dnsResolver == nil {
+		return instances
+	}
+	return s.dnsResolver.resolveInstances(instances)
+}
+
+// dnsUpdate pushes the endpoints of the services whose hosts were resolved to different addresses.
+func (s *ServiceEntryStore) dnsUpdate(keys map[instancesKey]struct{}) {
+	s.storeMutex.Lock()
+	s.refreshIndexes.Store(true)
+	s.storeMutex.Unlock()
+	s.edsUpdateByKeys(keys, true)
+}
 
 // HasSynced always returns true for SE
 func (s *ServiceEntryStore) HasSynced() bool {
@@ -486,7 +513,7 @@ func (s *ServiceEntryStore) Services() ([]*model.Service, error) {
 	}
 	services := make([]*model.Service, 0)
 	for _, cfg := range s.store.ServiceEntries() {
-		services = append(services, convertServices(cfg)...)
+		services = append(services, convertServices(expandPortRanges(cfg))...)
 	}
 
 	return autoAllocateIPs(services), nil
@@ -515,7 +542,7 @@ func (s *ServiceEntryStore) getServices() []*model.Service {
 	}
 	services := make([]*model.Service, 0)
 	for _, cfg := range s.store.ServiceEntries() {
-		services = append(services, convertServices(cfg)...)
+		services = append(services, convertServices(expandPortRanges(cfg))...)
 	}
 	return services
 }
@@ -661,13 +688,19 @@ func (s *ServiceEntryStore) maybeRefreshIndexes() {
 	// First refresh service entry
 	seWithSelectorByNamespace := map[string][]servicesWithEntry{}
 	if s.processServiceEntry {
+		// The hosts no longer used by any service entry are no longer resolved.
+		if s.dnsResolver != nil {
+			s.dnsResolver.clearServices()
+			defer s.dnsResolver.prune()
+		}
 		for _, cfg := range s.store.ServiceEntries() {
+			cfg = expandPortRanges(cfg)
 			key := configKey{
 				kind:      serviceEntryConfigType,
 				name:      cfg.Name,
 				namespace: cfg.Namespace,
 			}
-			updateInstances(key, convertServiceEntryToInstances(cfg, nil), instanceMap, ip2instances)
+			updateInstances(key, s.resolveInstances(convertServiceEntryToInstances(cfg, nil)), instanceMap, ip2instances)
 			services := convertServices(cfg)
 
 			se := cfg.Spec.(*networking.ServiceEntry)
@@ -905,12 +938,12 @@ func autoAllocateIPs(services []*model.Service) []*model.Service {
 	x := 0
 	for _, svc := range services {
 		// we can allocate IPs only if
-		// 1. the service has resolution set to static/dns. We cannot allocate
+		// 1. a port of the service has resolution set to static/dns. We cannot allocate
 		//   for NONE because we will not know the original DST IP that the application requested.
 		// 2. the address is not set (0.0.0.0)
 		// 3. the hostname is not a wildcard
 		if svc.Address == constants.UnspecifiedIP && !svc.Hostname.IsWildCarded() &&
-			svc.Resolution != model.Passthrough {
+			(svc.HasResolution(model.ClientSideLB) || svc.HasResolution(model.DNSLB)) {
 			x++
 			if x%255 == 0 {
 				x++
@@ -0,0 +1,342 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulation
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	rbacpb "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	rbachttp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	rbactcp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/rbac/v3"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"github.com/golang/protobuf/ptypes"
+
+	authzmodel "istio.io/istio/pilot/pkg/security/authz/model"
+)
+
+// httpRBAC returns the enforced RBAC rules of the HTTP filters, in filter order.
+func (sim *Simulation) httpRBAC(h *hcm.HttpConnectionManager) []*rbacpb.RBAC {
+	res := []*rbacpb.RBAC{}
+	for _, f := range h.GetHttpFilters() {
+		if f.Name != authzmodel.RBACHTTPFilterName {
+			continue
+		}
+		r := &rbachttp.RBAC{}
+		if err := ptypes.UnmarshalAny(f.GetTypedConfig(), r); err != nil {
+			sim.t.Fatal(err)
+		}
+		if r.GetRules() != nil {
+			res = append(res, r.GetRules())
+		}
+	}
+	return res
+}
+
+// tcpRBAC returns the enforced RBAC rules of the network filters, in filter order.
+func (sim *Simulation) tcpRBAC(fc *listener.FilterChain) []*rbacpb.RBAC {
+	res := []*rbacpb.RBAC{}
+	for _, f := range fc.GetFilters() {
+		if f.Name != authzmodel.RBACTCPFilterName {
+			continue
+		}
+		r := &rbactcp.RBAC{}
+		if err := ptypes.UnmarshalAny(f.GetTypedConfig(), r); err != nil {
+			sim.t.Fatal(err)
+		}
+		if r.GetRules() != nil {
+			res = append(res, r.GetRules())
+		}
+	}
+	return res
+}
+
+// matchResult is the result of matching a call against an RBAC matcher.
+type matchResult int
+
+const (
+	noMatch matchResult = iota
+	match
+	// unsupported is returned for the matchers not supported by the simulation, which can't be evaluated.
+	unsupported
+)
+
+func matchOf(b bool) matchResult {
+	if b {
+		return match
+	}
+	return noMatch
+}
+
+// and combines the results of matchers which must all match.
+func and(results ...matchResult) matchResult {
+	res := match
+	for _, r := range results {
+		if r == noMatch {
+			return noMatch
+		}
+		if r == unsupported {
+			res = unsupported
+		}
+	}
+	return res
+}
+
+func not(r matchResult) matchResult {
+	switch r {
+	case match:
+		return noMatch
+	case noMatch:
+		return match
+	}
+	return unsupported
+}
+
+// allowedByRBAC evaluates the RBAC rules in order, returning false if any of them would deny the call. The
+// policies using matchers the simulation does not support are skipped, and reported in the result.
+func (sim *Simulation) allowedByRBAC(rules []*rbacpb.RBAC, input Call, result *Result) bool {
+	for _, r := range rules {
+		matched := false
+		names := make([]string, 0, len(r.GetPolicies()))
+		for name := range r.GetPolicies() {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			m := sim.matchPolicy(r.GetPolicies()[name], input)
+			if m == unsupported {
+				result.UnsupportedRBACPolicies = append(result.UnsupportedRBACPolicies, name)
+				continue
+			}
+			if m == match {
+				matched = true
+				break
+			}
+		}
+		switch r.GetAction() {
+		case rbacpb.RBAC_ALLOW:
+			if !matched {
+				return false
+			}
+		case rbacpb.RBAC_DENY:
+			if matched {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (sim *Simulation) matchPolicy(p *rbacpb.Policy, input Call) matchResult {
+	permission := noMatch
+	for _, perm := range p.GetPermissions() {
+		if m := sim.matchPermission(perm, input); m != noMatch {
+			permission = m
+			if m == match {
+				break
+			}
+		}
+	}
+	principal := noMatch
+	for _, id := range p.GetPrincipals() {
+		if m := sim.matchPrincipal(id, input); m != noMatch {
+			principal = m
+			if m == match {
+				break
+			}
+		}
+	}
+	return and(permission, principal)
+}
+
+func (sim *Simulation) matchPermission(p *rbacpb.Permission, input Call) matchResult {
+	switch r := p.GetRule().(type) {
+	case *rbacpb.Permission_Any:
+		return matchOf(r.Any)
+	case *rbacpb.Permission_AndRules:
+		res := match
+		for _, rule := range r.AndRules.GetRules() {
+			if res = and(res, sim.matchPermission(rule, input)); res == noMatch {
+				return noMatch
+			}
+		}
+		return res
+	case *rbacpb.Permission_OrRules:
+		res := noMatch
+		for _, rule := range r.OrRules.GetRules() {
+			switch sim.matchPermission(rule, input) {
+			case match:
+				return match
+			case unsupported:
+				res = unsupported
+			}
+		}
+		return res
+	case *rbacpb.Permission_NotRule:
+		return not(sim.matchPermission(r.NotRule, input))
+	case *rbacpb.Permission_DestinationPort:
+		return matchOf(int(r.DestinationPort) == input.Port)
+	case *rbacpb.Permission_Header:
+		return sim.matchHeader(r.Header, input)
+	case *rbacpb.Permission_UrlPath:
+		return sim.matchString(r.UrlPath.GetPath(), strings.SplitN(input.Path, "?", 2)[0])
+	case *rbacpb.Permission_RequestedServerName:
+		return sim.matchString(r.RequestedServerName, input.Sni)
+	case *rbacpb.Permission_DestinationIp:
+		return sim.matchCIDR(r.DestinationIp, input.Address)
+	}
+	return unsupported
+}
+
+func (sim *Simulation) matchPrincipal(p *rbacpb.Principal, input Call) matchResult {
+	switch id := p.GetIdentifier().(type) {
+	case *rbacpb.Principal_Any:
+		return matchOf(id.Any)
+	case *rbacpb.Principal_AndIds:
+		res := match
+		for _, i := range id.AndIds.GetIds() {
+			if res = and(res, sim.matchPrincipal(i, input)); res == noMatch {
+				return noMatch
+			}
+		}
+		return res
+	case *rbacpb.Principal_OrIds:
+		res := noMatch
+		for _, i := range id.OrIds.GetIds() {
+			switch sim.matchPrincipal(i, input) {
+			case match:
+				return match
+			case unsupported:
+				res = unsupported
+			}
+		}
+		return res
+	case *rbacpb.Principal_NotId:
+		return not(sim.matchPrincipal(id.NotId, input))
+	case *rbacpb.Principal_Authenticated_:
+		// Only mTLS connections with a known peer certificate are authenticated
+		if input.TLS != MTLS || input.Source == nil {
+			return noMatch
+		}
+		if id.Authenticated.GetPrincipalName() == nil {
+			return match
+		}
+		return sim.matchString(id.Authenticated.GetPrincipalName(), input.Source.String())
+	case *rbacpb.Principal_DirectRemoteIp:
+		return sim.matchCIDR(id.DirectRemoteIp, input.SourceAddress)
+	case *rbacpb.Principal_RemoteIp:
+		return sim.matchCIDR(id.RemoteIp, input.SourceAddress)
+	case *rbacpb.Principal_SourceIp:
+		return sim.matchCIDR(id.SourceIp, input.SourceAddress)
+	case *rbacpb.Principal_Header:
+		return sim.matchHeader(id.Header, input)
+	case *rbacpb.Principal_UrlPath:
+		return sim.matchString(id.UrlPath.GetPath(), strings.SplitN(input.Path, "?", 2)[0])
+	}
+	return unsupported
+}
+
+func (sim *Simulation) matchHeader(h *route.HeaderMatcher, input Call) matchResult {
+	var value string
+	var present bool
+	switch strings.ToLower(h.GetName()) {
+	case ":path":
+		value, present = input.Path, true
+	case ":method":
+		value, present = input.Method, true
+	case ":authority", "host":
+		if len(input.Headers["Host"]) > 0 {
+			value, present = input.Headers["Host"][0], true
+		}
+	default:
+		if v := input.Headers.Values(h.GetName()); len(v) > 0 {
+			value, present = strings.Join(v, ","), true
+		}
+	}
+	matched := noMatch
+	switch m := h.GetHeaderMatchSpecifier().(type) {
+	case *route.HeaderMatcher_ExactMatch:
+		matched = matchOf(present && value == m.ExactMatch)
+	case *route.HeaderMatcher_PrefixMatch:
+		matched = matchOf(present && strings.HasPrefix(value, m.PrefixMatch))
+	case *route.HeaderMatcher_SuffixMatch:
+		matched = matchOf(present && strings.HasSuffix(value, m.SuffixMatch))
+	case *route.HeaderMatcher_PresentMatch:
+		matched = matchOf(present && m.PresentMatch)
+	case *route.HeaderMatcher_SafeRegexMatch:
+		matched = and(matchOf(present), sim.matchRegex(m.SafeRegexMatch.GetRegex(), value))
+	default:
+		return unsupported
+	}
+	if h.GetInvertMatch() {
+		return not(matched)
+	}
+	return matched
+}
+
+func (sim *Simulation) matchString(m *matcher.StringMatcher, value string) matchResult {
+	if m.GetIgnoreCase() {
+		value = strings.ToLower(value)
+	}
+	lower := func(s string) string {
+		if m.GetIgnoreCase() {
+			return strings.ToLower(s)
+		}
+		return s
+	}
+	switch p := m.GetMatchPattern().(type) {
+	case *matcher.StringMatcher_Exact:
+		return matchOf(value == lower(p.Exact))
+	case *matcher.StringMatcher_Prefix:
+		return matchOf(strings.HasPrefix(value, lower(p.Prefix)))
+	case *matcher.StringMatcher_Suffix:
+		return matchOf(strings.HasSuffix(value, lower(p.Suffix)))
+	case *matcher.StringMatcher_Contains:
+		return matchOf(strings.Contains(value, lower(p.Contains)))
+	case *matcher.StringMatcher_SafeRegex:
+		return sim.matchRegex(p.SafeRegex.GetRegex(), value)
+	}
+	return unsupported
+}
+
+func (sim *Simulation) matchCIDR(c *core.CidrRange, address string) matchResult {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		// Unknown address will never match
+		return noMatch
+	}
+	_, cidr, err := net.ParseCIDR(fmt.Sprintf("%s/%d", c.GetAddressPrefix(), c.GetPrefixLen().GetValue()))
+	if err != nil {
+		sim.t.Fatalf("failed to parse cidr %v: %v", c, err)
+	}
+	return matchOf(cidr.Contains(ip))
+}
+
+// matchRegex matches the value against the full regex, like Envoy does.
+// Regexes using RE2 syntax not supported by Go are unsupported.
+func (sim *Simulation) matchRegex(regex string, value string) matchResult {
+	r, err := regexp.Compile("^(?:" + regex + ")$")
+	if err != nil {
+		return unsupported
+	}
+	return matchOf(r.MatchString(value))
+}
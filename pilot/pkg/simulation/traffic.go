@@ -19,19 +19,27 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"reflect"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 
 	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	fault "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/fault/v3"
 	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/duration"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/yl2chen/cidranger"
+	"google.golang.org/protobuf/testing/protocmp"
 
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/core/v1alpha3"
@@ -40,6 +48,7 @@ import (
 	xdsfilters "istio.io/istio/pilot/pkg/xds/filters"
 	"istio.io/istio/pilot/test/xdstest"
 	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/spiffe"
 	"istio.io/istio/pkg/test"
 )
 
@@ -51,6 +60,13 @@ const (
 	TCP   Protocol = "tcp"
 )
 
+type ProxyProtocol string
+
+const (
+	ProxyProtocolV1 ProxyProtocol = "v1"
+	ProxyProtocolV2 ProxyProtocol = "v2"
+)
+
 type TLSMode string
 
 const (
@@ -76,6 +92,13 @@ var (
 	ErrProtocolError = errors.New("protocol error")
 	ErrTLSError      = errors.New("invalid TLS")
 	ErrMTLSError     = errors.New("invalid mTLS")
+	// ErrPeerCertificate happens when the peer certificate does not match the subject alt names accepted by the
+	// server, for example when the Source of the call is from an unknown trust domain
+	ErrPeerCertificate = errors.New("peer certificate rejected")
+	// ErrRBACDenied happens when an RBAC filter denies the call
+	ErrRBACDenied = errors.New("RBAC: access denied")
+	// ErrProxyProtocol happens when the listener requires a PROXY protocol preamble, but it was not sent
+	ErrProxyProtocol = errors.New("PROXY protocol required")
 )
 
 type Expect struct {
@@ -96,9 +119,11 @@ var (
 )
 
 type Call struct {
+	// Address is the destination address. Inbound calls default to the proxy's own IP.
 	Address string
 	Port    int
 	Path    string
+	Method  string
 
 	// Protocol describes the protocol type. TLS encapsulation is separate
 	Protocol Protocol
@@ -115,6 +140,17 @@ type Call struct {
 
 	// CallMode describes the type of call to make.
 	CallMode CallMode
+
+	// ProxyProtocol, if set, indicates the connection starts with a PROXY protocol preamble of the given version.
+	ProxyProtocol ProxyProtocol
+	// SourceAddress is the address of the client. When a PROXY protocol preamble is sent, this is the
+	// source address in the preamble, which the proxy will only see if it accepts PROXY protocol.
+	SourceAddress string
+
+	// Source describes the identity of the caller, as presented in its mTLS certificate. This is
+	// used to evaluate peer certificate validation and RBAC principals. If unset, the caller is
+	// considered unauthenticated.
+	Source *spiffe.Identity
 }
 
 func (c Call) FillDefaults() Call {
@@ -131,6 +167,9 @@ func (c Call) FillDefaults() Call {
 	if c.Path == "" {
 		c.Path = "/"
 	}
+	if c.Method == "" {
+		c.Method = "GET"
+	}
 	if c.TLS == "" {
 		c.TLS = Plaintext
 	}
@@ -155,6 +194,14 @@ type Result struct {
 	RouteConfigMatched string
 	VirtualHostMatched string
 	ClusterMatched     string
+	// RetryPolicy is the effective retry policy of the matched route
+	RetryPolicy *route.RetryPolicy
+	// Timeout is the timeout of the matched route
+	Timeout *duration.Duration
+	// Fault is the fault injection configured on the matched route
+	Fault *fault.HTTPFault
+	// UnsupportedRBACPolicies are the RBAC policies skipped as they use matchers the simulation does not support
+	UnsupportedRBACPolicies []string
 	// StrictMatch controls whether we will strictly match the result. If unset, empty fields will
 	// be ignored, allowing testing only fields we care about This allows asserting that the result
 	// is *exactly* equal, allowing asserting a field is empty
@@ -169,7 +216,7 @@ type Result struct {
 func (r Result) Matches(t *testing.T, want Result) {
 	r.StrictMatch = want.StrictMatch // to make diff pass
 	r.Skip = want.Skip               // to make diff pass
-	diff := cmp.Diff(want, r, cmpopts.IgnoreUnexported(Result{}), cmpopts.EquateErrors())
+	diff := cmp.Diff(want, r, cmpopts.IgnoreUnexported(Result{}), cmpopts.EquateErrors(), protocmp.Transform())
 	if want.StrictMatch && diff != "" {
 		t.Errorf("Diff: %v", diff)
 		return
@@ -195,6 +242,18 @@ func (r Result) Matches(t *testing.T, want Result) {
 	if want.ClusterMatched != "" && want.ClusterMatched != r.ClusterMatched {
 		t.Errorf("want cluster matched %q got %q", want.ClusterMatched, r.ClusterMatched)
 	}
+	if want.RetryPolicy != nil && !proto.Equal(want.RetryPolicy, r.RetryPolicy) {
+		t.Errorf("want retry policy %v got %v", want.RetryPolicy, r.RetryPolicy)
+	}
+	if want.Timeout != nil && !proto.Equal(want.Timeout, r.Timeout) {
+		t.Errorf("want timeout %v got %v", want.Timeout, r.Timeout)
+	}
+	if want.Fault != nil && !proto.Equal(want.Fault, r.Fault) {
+		t.Errorf("want fault %v got %v", want.Fault, r.Fault)
+	}
+	if want.UnsupportedRBACPolicies != nil && !reflect.DeepEqual(want.UnsupportedRBACPolicies, r.UnsupportedRBACPolicies) {
+		t.Errorf("want unsupported RBAC policies %v got %v", want.UnsupportedRBACPolicies, r.UnsupportedRBACPolicies)
+	}
 	if t.Failed() {
 		t.Logf("Diff: %+v", diff)
 	} else if want.Skip != "" {
@@ -203,23 +262,65 @@ func (r Result) Matches(t *testing.T, want Result) {
 }
 
 type Simulation struct {
-	t         *testing.T
+	t         test.Failer
 	Listeners []*listener.Listener
 	Clusters  []*cluster.Cluster
 	Routes    []*route.RouteConfiguration
+
+	// coverage records the filter chains matched by calls. It is shared between copies of the Simulation.
+	coverage *coverage
+	// proxyIPs are the addresses of the proxy, used as the destination of inbound calls without an Address
+	proxyIPs []string
 }
 
-func NewSimulationFromConfigGen(t *testing.T, s *v1alpha3.ConfigGenTest, proxy *model.Proxy) *Simulation {
+// coverage tracks which filter chains have been matched by calls
+type coverage struct {
+	mu      sync.Mutex
+	matched map[*listener.FilterChain]struct{}
+}
+
+func (c *coverage) record(fc *listener.FilterChain) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.matched[fc] = struct{}{}
+}
+
+func (c *coverage) contains(fc *listener.FilterChain) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, f := c.matched[fc]
+	return f
+}
+
+func NewSimulationFromConfigGen(t test.Failer, s *v1alpha3.ConfigGenTest, proxy *model.Proxy) *Simulation {
+	// Build the listeners once, and reuse them for the routes
+	listeners := s.Listeners(proxy)
 	sim := &Simulation{
 		t:         t,
-		Listeners: s.Listeners(proxy),
+		Listeners: listeners,
 		Clusters:  s.Clusters(proxy),
-		Routes:    s.Routes(proxy),
+		Routes:    s.ConfigGen.BuildHTTPRoutes(proxy, s.PushContext(), xdstest.ExtractRoutesFromListeners(listeners)),
+		coverage:  &coverage{matched: map[*listener.FilterChain]struct{}{}},
+		proxyIPs:  proxy.IPAddresses,
 	}
 	return sim
 }
 
-func NewSimulation(t *testing.T, s *xds.FakeDiscoveryServer, proxy *model.Proxy) *Simulation {
+// NewSimulationFromConfig creates a Simulation of a proxy with the given configuration, for example read from the
+// config dump of a running proxy, rather than generated.
+func NewSimulationFromConfig(t test.Failer, listeners []*listener.Listener, clusters []*cluster.Cluster,
+	routes []*route.RouteConfiguration, proxyIPs []string) *Simulation {
+	return &Simulation{
+		t:         t,
+		Listeners: listeners,
+		Clusters:  clusters,
+		Routes:    routes,
+		coverage:  &coverage{matched: map[*listener.FilterChain]struct{}{}},
+		proxyIPs:  proxyIPs,
+	}
+}
+
+func NewSimulation(t test.Failer, s *xds.FakeDiscoveryServer, proxy *model.Proxy) *Simulation {
 	return NewSimulationFromConfigGen(t, s.ConfigGenTest, proxy)
 }
 
@@ -230,11 +331,39 @@ func (sim *Simulation) withT(t *testing.T) *Simulation {
 	return &cpy
 }
 
+// RunExpectations runs each expectation as a sub test. This requires the Simulation to be created with a *testing.T.
 func (sim *Simulation) RunExpectations(es []Expect) {
-	for _, e := range es {
-		sim.t.Run(e.Name, func(t *testing.T) {
-			sim.withT(t).Run(e.Call).Matches(t, e.Result)
-		})
+	sim.runExpectations(es, false)
+}
+
+// RunExpectationsParallel is like RunExpectations, but evaluates the calls concurrently against the same
+// generated configuration. The calls are run as parallel sub tests of a single "calls" sub test, so this
+// returns once all of them have completed.
+func (sim *Simulation) RunExpectationsParallel(es []Expect) {
+	sim.runExpectations(es, true)
+}
+
+func (sim *Simulation) runExpectations(es []Expect, parallel bool) {
+	t, ok := sim.t.(*testing.T)
+	if !ok {
+		sim.t.Fatalf("RunExpectations requires a *testing.T, got %T", sim.t)
+		return
+	}
+	run := func(t *testing.T) {
+		for _, e := range es {
+			e := e
+			t.Run(e.Name, func(t *testing.T) {
+				if parallel {
+					t.Parallel()
+				}
+				sim.withT(t).Run(e.Call).Matches(t, e.Result)
+			})
+		}
+	}
+	if parallel {
+		t.Run("calls", run)
+	} else {
+		run(t)
 	}
 }
 
@@ -251,6 +380,10 @@ func hasFilterOnPort(l *listener.Listener, filter string, port int) bool {
 
 func (sim *Simulation) Run(input Call) (result Result) {
 	result = Result{t: sim.t}
+	if input.CallMode == CallModeInbound && input.Address == "" && len(sim.proxyIPs) > 0 {
+		// Inbound traffic is sent to one of the proxy's own IPs
+		input.Address = sim.proxyIPs[0]
+	}
 	input = input.FillDefaults()
 	if input.Alpn != "" && input.TLS == Plaintext {
 		result.Error = fmt.Errorf("invalid call, ALPN can only be sent in TLS requests")
@@ -265,28 +398,43 @@ func (sim *Simulation) Run(input Call) (result Result) {
 	}
 	result.ListenerMatched = l.Name
 
+	hasProxyProtocol := hasFilterOnPort(l, wellknown.ProxyProtocol, input.Port)
+	if hasProxyProtocol && input.ProxyProtocol == "" {
+		// The connection will be closed as the preamble could not be parsed
+		result.Error = ErrProxyProtocol
+		return
+	}
+	// If the preamble is not consumed, it is treated as part of the stream, and the connection cannot be
+	// detected as TLS or HTTP.
+	unreadPreamble := input.ProxyProtocol != "" && !hasProxyProtocol
+	if unreadPreamble {
+		// Without PROXY protocol, the actual source address is not known
+		input.SourceAddress = ""
+	}
+
 	hasTLSInspector := hasFilterOnPort(l, xdsfilters.TLSInspector.Name, input.Port)
-	if !hasTLSInspector {
+	if !hasTLSInspector || unreadPreamble {
 		// Without tls inspector, Envoy would not read the ALPN in the TLS handshake
 		// HTTP inspector still may set it though
 		input.Alpn = ""
 	}
 
 	// Apply listener filters
-	if hasFilterOnPort(l, xdsfilters.HTTPInspector.Name, input.Port) {
+	if hasFilterOnPort(l, xdsfilters.HTTPInspector.Name, input.Port) && !unreadPreamble {
 		if alpn := protocolToAlpn(input.Protocol); alpn != "" && input.TLS == Plaintext {
 			input.Alpn = alpn
 		}
 	}
 
-	fc, err := sim.matchFilterChain(l.FilterChains, l.DefaultFilterChain, input, hasTLSInspector)
+	fc, err := sim.matchFilterChain(l.FilterChains, l.DefaultFilterChain, input, hasTLSInspector && !unreadPreamble)
 	if err != nil {
 		result.Error = err
 		return
 	}
 	result.FilterChainMatched = fc.Name
-	// Plaintext to TLS is an error
-	if fc.TransportSocket != nil && input.TLS == Plaintext {
+	sim.coverage.record(fc)
+	// Plaintext to TLS is an error. A preamble that is not consumed will also break the TLS handshake.
+	if fc.TransportSocket != nil && (input.TLS == Plaintext || unreadPreamble) {
 		result.Error = ErrTLSError
 		return
 	}
@@ -296,6 +444,10 @@ func (sim *Simulation) Run(input Call) (result Result) {
 		result.Error = ErrMTLSError
 		return
 	}
+	if fc.TransportSocket != nil && input.Source != nil && !sim.acceptsPeer(fc, input.Source.String()) {
+		result.Error = ErrPeerCertificate
+		return
+	}
 
 	if hcm := xdstest.ExtractHTTPConnectionManager(sim.t, fc); hcm != nil {
 		// We matched HCM and didn't terminate TLS, but we are sending TLS traffic - decoding will fail
@@ -303,11 +455,15 @@ func (sim *Simulation) Run(input Call) (result Result) {
 			result.Error = ErrProtocolError
 			return
 		}
-		// TCP to HCM is invalid
-		if input.Protocol != HTTP && input.Protocol != HTTP2 {
+		// TCP to HCM is invalid, as is a preamble that was not consumed
+		if (input.Protocol != HTTP && input.Protocol != HTTP2) || unreadPreamble {
 			result.Error = ErrProtocolError
 			return
 		}
+		if !sim.allowedByRBAC(sim.tcpRBAC(fc), input, &result) || !sim.allowedByRBAC(sim.httpRBAC(hcm), input, &result) {
+			result.Error = ErrRBACDenied
+			return
+		}
 
 		// Fetch inline route
 		rc := hcm.GetRouteConfig()
@@ -341,21 +497,62 @@ func (sim *Simulation) Run(input Call) (result Result) {
 		switch t := r.GetAction().(type) {
 		case *route.Route_Route:
 			result.ClusterMatched = t.Route.GetCluster()
+			result.Timeout = t.Route.GetTimeout()
+			// The route level retry policy overrides the virtual host level policy
+			result.RetryPolicy = vh.GetRetryPolicy()
+			if t.Route.GetRetryPolicy() != nil {
+				result.RetryPolicy = t.Route.GetRetryPolicy()
+			}
 		}
+		result.Fault = sim.routeFault(r)
 	} else if tcp := xdstest.ExtractTCPProxy(sim.t, fc); tcp != nil {
+		if !sim.allowedByRBAC(sim.tcpRBAC(fc), input, &result) {
+			result.Error = ErrRBACDenied
+			return
+		}
 		result.ClusterMatched = tcp.GetCluster()
 	}
 	return
 }
 
-func (sim *Simulation) requiresMTLS(fc *listener.FilterChain) bool {
-	if fc.TransportSocket == nil {
-		return false
+// UnmatchedFilterChains returns a description of all filter chains that have not been matched by any call
+// made so far. This can be used to find configuration that is not exercised by a test.
+func (sim *Simulation) UnmatchedFilterChains() []string {
+	res := []string{}
+	for _, l := range sim.Listeners {
+		for i, fc := range l.FilterChains {
+			if !sim.coverage.contains(fc) {
+				res = append(res, describeFilterChain(l, fc, fmt.Sprint(i)))
+			}
+		}
+		if fc := l.DefaultFilterChain; fc != nil && !sim.coverage.contains(fc) {
+			res = append(res, describeFilterChain(l, fc, "default"))
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+func describeFilterChain(l *listener.Listener, fc *listener.FilterChain, index string) string {
+	if fc.Name != "" {
+		return fmt.Sprintf("%s/%s[%s]", l.Name, fc.Name, index)
 	}
+	return fmt.Sprintf("%s[%s]", l.Name, index)
+}
+
+func (sim *Simulation) downstreamTLSContext(fc *listener.FilterChain) *tls.DownstreamTlsContext {
 	t := &tls.DownstreamTlsContext{}
 	if err := ptypes.UnmarshalAny(fc.GetTransportSocket().GetTypedConfig(), t); err != nil {
 		sim.t.Fatal(err)
 	}
+	return t
+}
+
+func (sim *Simulation) requiresMTLS(fc *listener.FilterChain) bool {
+	if fc.TransportSocket == nil {
+		return false
+	}
+	t := sim.downstreamTLSContext(fc)
 
 	if len(t.GetCommonTlsContext().GetTlsCertificateSdsSecretConfigs()) == 0 {
 		return false
@@ -364,6 +561,37 @@ func (sim *Simulation) requiresMTLS(fc *listener.FilterChain) bool {
 	return t.GetCommonTlsContext().GetTlsCertificateSdsSecretConfigs()[0].Name == "default"
 }
 
+// routeFault returns the fault injection configured for the route, if any.
+func (sim *Simulation) routeFault(r *route.Route) *fault.HTTPFault {
+	cfg, f := r.GetTypedPerFilterConfig()[wellknown.Fault]
+	if !f {
+		return nil
+	}
+	res := &fault.HTTPFault{}
+	if err := ptypes.UnmarshalAny(cfg, res); err != nil {
+		sim.t.Fatal(err)
+	}
+	return res
+}
+
+// acceptsPeer checks if the peer certificate SAN is accepted by the filter chain's validation context.
+func (sim *Simulation) acceptsPeer(fc *listener.FilterChain, san string) bool {
+	ctx := sim.downstreamTLSContext(fc).GetCommonTlsContext()
+	sans := ctx.GetValidationContext().GetMatchSubjectAltNames()
+	if combined := ctx.GetCombinedValidationContext(); combined != nil {
+		sans = combined.GetDefaultValidationContext().GetMatchSubjectAltNames()
+	}
+	if len(sans) == 0 {
+		return true
+	}
+	for _, m := range sans {
+		if sim.matchString(m, san) {
+			return true
+		}
+	}
+	return false
+}
+
 func (sim *Simulation) matchRoute(vh *route.VirtualHost, input Call) *route.Route {
 	for _, r := range vh.Routes {
 		// check path
@@ -592,25 +820,36 @@ func protocolToAlpn(s Protocol) string {
 
 func matchListener(listeners []*listener.Listener, input Call) *listener.Listener {
 	if input.CallMode == CallModeInbound {
-		return xdstest.ExtractListener(v1alpha3.VirtualInboundListenerName, listeners)
+		if l := xdstest.ExtractListener(v1alpha3.VirtualInboundListenerName, listeners); l != nil {
+			return l
+		}
+		// Without the virtual inbound listener, traffic is not redirected and must be accepted by
+		// a listener bound to the address or the wildcard address.
+		return matchBoundListener(listeners, input)
+	}
+	if l := matchBoundListener(listeners, input); l != nil {
+		return l
 	}
-	// First find exact match for the IP/Port, then fallback to wildcard IP/Port
-	// There is no wildcard port
+
+	// Fallback to the outbound listener
 	for _, l := range listeners {
-		if matchAddress(l.GetAddress(), input.Address, input.Port) {
+		if l.Name == v1alpha3.VirtualOutboundListenerName {
 			return l
 		}
 	}
+	return nil
+}
+
+// matchBoundListener finds an exact match for the IP/Port, then falls back to wildcard IP/Port
+// There is no wildcard port
+func matchBoundListener(listeners []*listener.Listener, input Call) *listener.Listener {
 	for _, l := range listeners {
-		if matchAddress(l.GetAddress(), "0.0.0.0", input.Port) {
+		if matchAddress(l.GetAddress(), input.Address, input.Port) {
 			return l
 		}
 	}
-
-	// Fallback to the outbound listener
-	// TODO - support inbound
 	for _, l := range listeners {
-		if l.Name == v1alpha3.VirtualOutboundListenerName {
+		if matchAddress(l.GetAddress(), "0.0.0.0", input.Port) {
 			return l
 		}
 	}
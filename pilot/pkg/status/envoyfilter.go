@@ -0,0 +1,172 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/api/meta/v1alpha1"
+	"istio.io/istio/pilot/pkg/model"
+	modelstatus "istio.io/istio/pilot/pkg/model/status"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+// EnvoyFilterAppliedCondition is the condition of the status of an EnvoyFilter telling whether its patches were
+// applied to the configuration of the proxies. Its reason is the worst state of the patches, and its message
+// details the state of each patch.
+const EnvoyFilterAppliedCondition = "Applied"
+
+// handleEnvoyFilterPatches records the summaries of the EnvoyFilter patches of the report, replacing those of the
+// previous report of the reporter. It must be called with the lock held.
+func (c *DistributionController) handleEnvoyFilterPatches(d DistributionReport) {
+	byResource := make(map[Resource][]model.EnvoyFilterPatchSummary)
+	for _, patch := range d.EnvoyFilterPatches {
+		res := envoyFilterResource(patch)
+		if res == nil {
+			continue
+		}
+		byResource[*res] = append(byResource[*res], patch)
+		// The status of the EnvoyFilter is written as long as it is reported, even after its distribution completed.
+		if _, ok := c.CurrentState[*res]; !ok {
+			c.CurrentState[*res] = make(map[string]Progress)
+		}
+		if _, ok := c.CurrentState[*res][d.Reporter]; !ok {
+			c.CurrentState[*res][d.Reporter] = Progress{}
+		}
+	}
+	c.envoyFilterPatches[d.Reporter] = byResource
+}
+
+// hasEnvoyFilterPatches returns true if a reporter reported patches of the resource. It must be called with the
+// lock held.
+func (c *DistributionController) hasEnvoyFilterPatches(res Resource) bool {
+	for _, byResource := range c.envoyFilterPatches {
+		if _, f := byResource[res]; f {
+			return true
+		}
+	}
+	return false
+}
+
+// aggregateEnvoyFilterPatches returns the summaries of the patches of the resource, aggregated for all reporters.
+func (c *DistributionController) aggregateEnvoyFilterPatches(res Resource) []model.EnvoyFilterPatchSummary {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	byIndex := make(map[int]*model.EnvoyFilterPatchSummary)
+	for _, byResource := range c.envoyFilterPatches {
+		for _, patch := range byResource[res] {
+			if prev, f := byIndex[patch.Index]; f {
+				prev.Add(patch)
+			} else {
+				p := patch
+				byIndex[patch.Index] = &p
+			}
+		}
+	}
+	out := make([]model.EnvoyFilterPatchSummary, 0, len(byIndex))
+	for _, patch := range byIndex {
+		out = append(out, *patch)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Index < out[j].Index
+	})
+	return out
+}
+
+// envoyFilterResource returns the resource of the EnvoyFilter of the patch.
+func envoyFilterResource(patch model.EnvoyFilterPatchSummary) *Resource {
+	gvr := GVKtoGVR(gvk.EnvoyFilter)
+	if gvr == nil {
+		return nil
+	}
+	return &Resource{
+		GroupVersionResource: *gvr,
+		Namespace:            patch.Namespace,
+		Name:                 patch.Name,
+		Generation:           strconv.FormatInt(patch.Generation, 10),
+	}
+}
+
+// ReconcileEnvoyFilterStatus sets the EnvoyFilterAppliedCondition of the status of the EnvoyFilter from the
+// summaries of its patches, and returns true if the status changed.
+func ReconcileEnvoyFilterStatus(current *config.Config, patches []model.EnvoyFilterPatchSummary) bool {
+	currentStatus, err := GetTypedStatus(current.Status)
+	if err != nil {
+		currentStatus = &v1alpha1.IstioStatus{}
+	}
+	desired := envoyFilterCondition(patches)
+	existing := modelstatus.GetCondition(currentStatus.Conditions, EnvoyFilterAppliedCondition)
+	if existing != nil && existing.Status == desired.Status {
+		if existing.Reason == desired.Reason && existing.Message == desired.Message {
+			return false
+		}
+		desired.LastTransitionTime = existing.LastTransitionTime
+	}
+	currentStatus.Conditions = modelstatus.UpdateCondition(currentStatus.Conditions, desired)
+	currentStatus.ObservedGeneration = current.Generation
+	current.Status = currentStatus
+	return true
+}
+
+// envoyFilterCondition returns the EnvoyFilterAppliedCondition of an EnvoyFilter, true when all its patches were
+// applied without conflict.
+func envoyFilterCondition(patches []model.EnvoyFilterPatchSummary) *v1alpha1.IstioCondition {
+	reason := model.EnvoyFilterPatchApplied
+	messages := make([]string, 0, len(patches))
+	for _, patch := range patches {
+		state := patch.State()
+		if state == model.EnvoyFilterPatchConflict ||
+			(state == model.EnvoyFilterPatchNoMatch && reason == model.EnvoyFilterPatchApplied) {
+			reason = state
+		}
+		messages = append(messages, envoyFilterPatchMessage(patch))
+	}
+	now := types.TimestampNow()
+	return &v1alpha1.IstioCondition{
+		Type:               EnvoyFilterAppliedCondition,
+		Status:             boolToConditionStatus(reason == model.EnvoyFilterPatchApplied),
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+		Reason:             string(reason),
+		Message:            strings.Join(messages, "; "),
+	}
+}
+
+// envoyFilterPatchMessage describes the state of a patch, for example "patch 0 (HTTP_FILTER INSERT_BEFORE):
+// applied to 3/4 proxies".
+func envoyFilterPatchMessage(patch model.EnvoyFilterPatchSummary) string {
+	total := patch.Applied + patch.NoMatch + patch.Conflict
+	prefix := fmt.Sprintf("patch %d (%s %s)", patch.Index, patch.ApplyTo, patch.Operation)
+	if patch.DryRun {
+		prefix += " [dry-run]"
+	}
+	switch patch.State() {
+	case model.EnvoyFilterPatchConflict:
+		return fmt.Sprintf("%s: conflicted on %d/%d proxies: %s", prefix, patch.Conflict, total, patch.Message)
+	case model.EnvoyFilterPatchApplied:
+		if patch.DryRun {
+			return fmt.Sprintf("%s: would apply to %d/%d proxies", prefix, patch.Applied, total)
+		}
+		return fmt.Sprintf("%s: applied to %d/%d proxies", prefix, patch.Applied, total)
+	default:
+		return fmt.Sprintf("%s: matched none of %d proxies", prefix, total)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"testing"
+
+	"istio.io/api/meta/v1alpha1"
+	"istio.io/istio/pilot/pkg/model"
+	modelstatus "istio.io/istio/pilot/pkg/model/status"
+	"istio.io/istio/pkg/config"
+)
+
+func TestReconcileEnvoyFilterStatus(t *testing.T) {
+	applied := model.EnvoyFilterPatchSummary{Index: 0, ApplyTo: "CLUSTER", Operation: "MERGE", Applied: 2, NoMatch: 1}
+	noMatch := model.EnvoyFilterPatchSummary{Index: 1, ApplyTo: "HTTP_FILTER", Operation: "INSERT_BEFORE", NoMatch: 3}
+	conflict := model.EnvoyFilterPatchSummary{Index: 2, ApplyTo: "NETWORK_FILTER", Operation: "MERGE", Applied: 1, Conflict: 2,
+		Message: "network filter envoy.tcp_proxy has no typed config to merge into"}
+	dryRun := model.EnvoyFilterPatchSummary{Index: 0, ApplyTo: "CLUSTER", Operation: "REMOVE", DryRun: true, Applied: 1}
+
+	tests := []struct {
+		name        string
+		patches     []model.EnvoyFilterPatchSummary
+		wantStatus  string
+		wantReason  string
+		wantMessage string
+	}{
+		{
+			name:        "applied",
+			patches:     []model.EnvoyFilterPatchSummary{applied},
+			wantStatus:  modelstatus.StatusTrue,
+			wantReason:  "Applied",
+			wantMessage: "patch 0 (CLUSTER MERGE): applied to 2/3 proxies",
+		},
+		{
+			name:        "no match",
+			patches:     []model.EnvoyFilterPatchSummary{applied, noMatch},
+			wantStatus:  modelstatus.StatusFalse,
+			wantReason:  "NoMatch",
+			wantMessage: "patch 0 (CLUSTER MERGE): applied to 2/3 proxies; patch 1 (HTTP_FILTER INSERT_BEFORE): matched none of 3 proxies",
+		},
+		{
+			name:       "conflict",
+			patches:    []model.EnvoyFilterPatchSummary{noMatch, conflict},
+			wantStatus: modelstatus.StatusFalse,
+			wantReason: "Conflict",
+			wantMessage: "patch 1 (HTTP_FILTER INSERT_BEFORE): matched none of 3 proxies; " +
+				"patch 2 (NETWORK_FILTER MERGE): conflicted on 2/3 proxies: network filter envoy.tcp_proxy has no typed config to merge into",
+		},
+		{
+			name:        "dry-run",
+			patches:     []model.EnvoyFilterPatchSummary{dryRun},
+			wantStatus:  modelstatus.StatusTrue,
+			wantReason:  "Applied",
+			wantMessage: "patch 0 (CLUSTER REMOVE) [dry-run]: would apply to 1/1 proxies",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Meta: config.Meta{Generation: 3}}
+			if !ReconcileEnvoyFilterStatus(cfg, tt.patches) {
+				t.Fatalf("expected the status to be reconciled")
+			}
+			status := cfg.Status.(*v1alpha1.IstioStatus)
+			if status.ObservedGeneration != 3 {
+				t.Errorf("got observed generation %d, want 3", status.ObservedGeneration)
+			}
+			cond := modelstatus.GetCondition(status.Conditions, EnvoyFilterAppliedCondition)
+			if cond == nil {
+				t.Fatalf("missing %s condition", EnvoyFilterAppliedCondition)
+			}
+			if cond.Status != tt.wantStatus || cond.Reason != tt.wantReason || cond.Message != tt.wantMessage {
+				t.Errorf("got condition %s/%s %q, want %s/%s %q", cond.Status, cond.Reason, cond.Message,
+					tt.wantStatus, tt.wantReason, tt.wantMessage)
+			}
+			if ReconcileEnvoyFilterStatus(cfg, tt.patches) {
+				t.Errorf("expected the status to be unchanged when the patches are unchanged")
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/api/meta/v1alpha1"
+	"istio.io/istio/pilot/pkg/model"
+	modelstatus "istio.io/istio/pilot/pkg/model/status"
+	"istio.io/istio/pkg/config"
+)
+
+// ConfigRejectedCondition is the condition of the status of a config telling whether the configuration generated
+// from it was rejected by proxies. It is only set once a rejection was reported, and reset to false afterwards.
+const ConfigRejectedCondition = "Rejected"
+
+const (
+	// ProxyRejectedReason is the reason of the ConfigRejectedCondition when proxies rejected the configuration.
+	ProxyRejectedReason = "ProxyRejected"
+	// ProxyAcceptedReason is the reason of the ConfigRejectedCondition once no proxy rejects the configuration.
+	ProxyAcceptedReason = "ProxyAccepted"
+)
+
+// handleConfigRejections records the rejected configs of the report, replacing those of the previous report of the
+// reporter. It must be called with the lock held.
+func (c *DistributionController) handleConfigRejections(d DistributionReport) {
+	byResource := make(map[Resource]model.ConfigRejection)
+	for _, rejection := range d.ConfigRejections {
+		res := rejectionResource(rejection)
+		if res == nil {
+			continue
+		}
+		byResource[*res] = rejection
+		// The status of the config is written as long as it is reported, even after its distribution completed.
+		if _, ok := c.CurrentState[*res]; !ok {
+			c.CurrentState[*res] = make(map[string]Progress)
+		}
+		if _, ok := c.CurrentState[*res][d.Reporter]; !ok {
+			c.CurrentState[*res][d.Reporter] = Progress{}
+		}
+	}
+	c.configRejections[d.Reporter] = byResource
+}
+
+// hasConfigRejections returns true if a reporter reported the rejection of the resource. It must be called with
+// the lock held.
+func (c *DistributionController) hasConfigRejections(res Resource) bool {
+	for _, byResource := range c.configRejections {
+		if _, f := byResource[res]; f {
+			return true
+		}
+	}
+	return false
+}
+
+// aggregateConfigRejections returns the rejection of the resource aggregated for all reporters, or nil if none
+// reported it.
+func (c *DistributionController) aggregateConfigRejections(res Resource) *model.ConfigRejection {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var out *model.ConfigRejection
+	for _, byResource := range c.configRejections {
+		rejection, f := byResource[res]
+		if !f {
+			continue
+		}
+		if out == nil {
+			out = &rejection
+			continue
+		}
+		out.Proxies += rejection.Proxies
+		if out.Message == "" {
+			out.Message = rejection.Message
+		}
+	}
+	return out
+}
+
+// rejectionResource returns the resource of the rejected config.
+func rejectionResource(rejection model.ConfigRejection) *Resource {
+	gvr := GVKtoGVR(rejection.GroupVersionKind())
+	if gvr == nil {
+		return nil
+	}
+	return &Resource{
+		GroupVersionResource: *gvr,
+		Namespace:            rejection.Namespace,
+		Name:                 rejection.Name,
+		Generation:           strconv.FormatInt(rejection.Generation, 10),
+	}
+}
+
+// ReconcileRejectionStatus sets the ConfigRejectedCondition of the status of the config from its rejection, which
+// is nil if no proxy rejects it, and returns true if the status changed.
+func ReconcileRejectionStatus(current *config.Config, rejection *model.ConfigRejection) bool {
+	currentStatus, err := GetTypedStatus(current.Status)
+	if err != nil {
+		currentStatus = &v1alpha1.IstioStatus{}
+	}
+	existing := modelstatus.GetCondition(currentStatus.Conditions, ConfigRejectedCondition)
+	if rejection == nil && existing == nil {
+		return false
+	}
+	desired := rejectionCondition(rejection)
+	if existing != nil && existing.Status == desired.Status {
+		if existing.Reason == desired.Reason && existing.Message == desired.Message {
+			return false
+		}
+		desired.LastTransitionTime = existing.LastTransitionTime
+	}
+	currentStatus.Conditions = modelstatus.UpdateCondition(currentStatus.Conditions, desired)
+	currentStatus.ObservedGeneration = current.Generation
+	current.Status = currentStatus
+	return true
+}
+
+// rejectionCondition returns the ConfigRejectedCondition of a config, true when proxies reject it.
+func rejectionCondition(rejection *model.ConfigRejection) *v1alpha1.IstioCondition {
+	now := types.TimestampNow()
+	condition := &v1alpha1.IstioCondition{
+		Type:               ConfigRejectedCondition,
+		Status:             boolToConditionStatus(rejection != nil),
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+		Reason:             ProxyAcceptedReason,
+	}
+	if rejection != nil {
+		condition.Reason = ProxyRejectedReason
+		condition.Message = fmt.Sprintf("rejected by %d proxies: %s", rejection.Proxies, rejection.Message)
+	}
+	return condition
+}
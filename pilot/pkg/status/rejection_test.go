@@ -0,0 +1,65 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"testing"
+
+	"istio.io/api/meta/v1alpha1"
+	"istio.io/istio/pilot/pkg/model"
+	modelstatus "istio.io/istio/pilot/pkg/model/status"
+	"istio.io/istio/pkg/config"
+)
+
+func TestReconcileRejectionStatus(t *testing.T) {
+	cfg := &config.Config{Meta: config.Meta{Generation: 2}}
+	if ReconcileRejectionStatus(cfg, nil) {
+		t.Fatalf("expected no condition to be set for a config that was never rejected")
+	}
+
+	rejection := &model.ConfigRejection{
+		Group:      "networking.istio.io",
+		Version:    "v1alpha3",
+		Kind:       "DestinationRule",
+		Namespace:  "default",
+		Name:       "reviews",
+		Generation: 2,
+		Proxies:    3,
+		Message:    "Error adding/updating cluster(s) outbound|9080||reviews.default.svc.cluster.local: invalid",
+	}
+	if !ReconcileRejectionStatus(cfg, rejection) {
+		t.Fatalf("expected the status to be reconciled")
+	}
+	cond := modelstatus.GetCondition(cfg.Status.(*v1alpha1.IstioStatus).Conditions, ConfigRejectedCondition)
+	if cond == nil {
+		t.Fatalf("missing %s condition", ConfigRejectedCondition)
+	}
+	wantMessage := "rejected by 3 proxies: " + rejection.Message
+	if cond.Status != modelstatus.StatusTrue || cond.Reason != ProxyRejectedReason || cond.Message != wantMessage {
+		t.Errorf("got condition %s/%s %q, want %s/%s %q", cond.Status, cond.Reason, cond.Message,
+			modelstatus.StatusTrue, ProxyRejectedReason, wantMessage)
+	}
+	if ReconcileRejectionStatus(cfg, rejection) {
+		t.Errorf("expected the status to be unchanged when the rejection is unchanged")
+	}
+
+	if !ReconcileRejectionStatus(cfg, nil) {
+		t.Fatalf("expected the condition to be reset once the rejection is cleared")
+	}
+	cond = modelstatus.GetCondition(cfg.Status.(*v1alpha1.IstioStatus).Conditions, ConfigRejectedCondition)
+	if cond.Status != modelstatus.StatusFalse || cond.Reason != ProxyAcceptedReason {
+		t.Errorf("got condition %s/%s, want %s/%s", cond.Status, cond.Reason, modelstatus.StatusFalse, ProxyAcceptedReason)
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"gopkg.in/yaml.v2"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
+	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/schema/collections"
 )
@@ -29,6 +30,10 @@ type DistributionReport struct {
 	Reporter            string         `json:"reporter"`
 	DataPlaneCount      int            `json:"dataPlaneCount"`
 	InProgressResources map[string]int `json:"inProgressResources"`
+	// EnvoyFilterPatches summarizes the results of the EnvoyFilter patches for the dataplanes of the reporter.
+	EnvoyFilterPatches []model.EnvoyFilterPatchSummary `json:"envoyFilterPatches,omitempty" yaml:"envoyFilterPatches,omitempty"`
+	// ConfigRejections lists the configs whose generated configuration was rejected by the dataplanes of the reporter.
+	ConfigRejections []model.ConfigRejection `json:"configRejections,omitempty" yaml:"configRejections,omitempty"`
 }
 
 func ReportFromYaml(content []byte) (DistributionReport, error) {
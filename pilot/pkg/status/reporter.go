@@ -29,6 +29,7 @@ import (
 	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/utils/clock"
 
+	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/xds"
 	"istio.io/istio/pkg/config"
 	"istio.io/pkg/ledger"
@@ -66,6 +67,12 @@ type Reporter struct {
 	ledger                 ledger.Ledger
 	distributionEventQueue chan distributionEvent
 	controller             *DistributionController
+	// EnvoyFilterPatches, if set, summarizes the results of the EnvoyFilter patches for the dataplanes, which are
+	// included in the reports for the leader to write them in the status of the EnvoyFilters.
+	EnvoyFilterPatches func() []model.EnvoyFilterPatchSummary
+	// ConfigRejections, if set, lists the configs whose generated configuration was rejected by the dataplanes,
+	// which are included in the reports for the leader to write them in the status of the configs.
+	ConfigRejections func() []model.ConfigRejection
 }
 
 var _ xds.DistributionStatusCache = &Reporter{}
@@ -148,6 +155,12 @@ func (r *Reporter) buildReport() (DistributionReport, []Resource) {
 		DataPlaneCount:      len(r.status),
 		InProgressResources: map[string]int{},
 	}
+	if r.EnvoyFilterPatches != nil {
+		out.EnvoyFilterPatches = r.EnvoyFilterPatches()
+	}
+	if r.ConfigRejections != nil {
+		out.ConfigRejections = r.ConfigRejections()
+	}
 	// for every resource in flight
 	for _, ipr := range r.inProgressResources {
 		res := ipr.Resource
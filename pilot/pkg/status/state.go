@@ -64,16 +64,22 @@ type DistributionController struct {
 	workers         WorkerQueue
 	StaleInterval   time.Duration
 	cmInformer      cache.SharedIndexInformer
+	// envoyFilterPatches holds the summaries of the EnvoyFilter patches of the last report of each reporter
+	envoyFilterPatches map[string]map[Resource][]model.EnvoyFilterPatchSummary
+	// configRejections holds the rejected configs of the last report of each reporter
+	configRejections map[string]map[Resource]model.ConfigRejection
 }
 
 func NewController(restConfig rest.Config, namespace string, cs model.ConfigStore) *DistributionController {
 	c := &DistributionController{
-		CurrentState:    make(map[Resource]map[string]Progress),
-		ObservationTime: make(map[string]time.Time),
-		UpdateInterval:  200 * time.Millisecond,
-		StaleInterval:   time.Minute,
-		clock:           clock.RealClock{},
-		configStore:     cs,
+		CurrentState:       make(map[Resource]map[string]Progress),
+		ObservationTime:    make(map[string]time.Time),
+		UpdateInterval:     200 * time.Millisecond,
+		StaleInterval:      time.Minute,
+		clock:              clock.RealClock{},
+		configStore:        cs,
+		envoyFilterPatches: make(map[string]map[Resource][]model.EnvoyFilterPatchSummary),
+		configRejections:   make(map[string]map[Resource]model.ConfigRejection),
 	}
 
 	// client-go defaults to 5 QPS, with 10 Boost, which is insufficient for updating status on all the config
@@ -138,6 +144,8 @@ func (c *DistributionController) handleReport(d DistributionReport) {
 		}
 		c.CurrentState[res][d.Reporter] = Progress{d.InProgressResources[resstr], d.DataPlaneCount}
 	}
+	c.handleEnvoyFilterPatches(d)
+	c.handleConfigRejections(d)
 	c.ObservationTime[d.Reporter] = c.clock.Now()
 }
 
@@ -156,7 +164,8 @@ func (c *DistributionController) writeAllStatus() (staleReporters []string) {
 				distributionState.PlusEquals(w)
 			}
 		}
-		if distributionState.TotalInstances > 0 { // this is necessary when all reports are stale.
+		// this is necessary when all reports are stale.
+		if distributionState.TotalInstances > 0 || c.hasEnvoyFilterPatches(config) || c.hasConfigRejections(config) {
 			c.queueWriteStatus(config, distributionState)
 		}
 	}
@@ -188,10 +197,19 @@ func (c *DistributionController) writeStatus(config Resource, distributionState
 	}
 
 	// check if status needs updating
-	if needsReconcile, desiredStatus := ReconcileStatuses(current, distributionState, current.Generation); needsReconcile {
+	needsReconcile := false
+	if distributionState.TotalInstances > 0 {
+		var desiredStatus *v1alpha1.IstioStatus
+		needsReconcile, desiredStatus = ReconcileStatuses(current, distributionState, current.Generation)
+		current.Status = desiredStatus
+	}
+	if patches := c.aggregateEnvoyFilterPatches(config); len(patches) > 0 {
+		needsReconcile = ReconcileEnvoyFilterStatus(current, patches) || needsReconcile
+	}
+	needsReconcile = ReconcileRejectionStatus(current, c.aggregateConfigRejections(config)) || needsReconcile
+	if needsReconcile {
 		// technically, we should be updating probe time even when reconciling isn't needed, but
 		// I'm skipping that for efficiency.
-		current.Status = desiredStatus
 		_, err := c.configStore.UpdateStatus(*current)
 		if err != nil {
 			scope.Errorf("Encountered unexpected error updating status for %v, will try again later: %s", config, err)
@@ -215,6 +233,10 @@ func (c *DistributionController) removeStaleReporters(staleReporters []string) {
 		}
 		c.CurrentState[key] = fractions
 	}
+	for _, staleReporter := range staleReporters {
+		delete(c.envoyFilterPatches, staleReporter)
+		delete(c.configRejections, staleReporter)
+	}
 }
 
 func (c *DistributionController) queueWriteStatus(config Resource, state Progress) {
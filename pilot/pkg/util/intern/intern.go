@@ -0,0 +1,186 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package intern deduplicates the strings and labels held by the pilot indexes. In large meshes the same
+// hostnames, namespaces, service accounts and pod labels are repeated for every endpoint and service instance,
+// and interning them makes equal values share the same memory.
+package intern
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/labels"
+)
+
+// defaultRotationInterval is the minimum duration between two rotations of the Default table.
+const defaultRotationInterval = 10 * time.Minute
+
+// Default is the table shared by the pilot indexes, from the service registries to the PushContext and the EDS
+// shards. It is nil, and interning a no-op, when interning is disabled.
+var Default = newDefaultTable()
+
+func newDefaultTable() *Table {
+	if !features.EnableInterning {
+		return nil
+	}
+	return NewTable(defaultRotationInterval)
+}
+
+// Table interns strings and labels. Its entries are kept in two generations: entries not used since the
+// previous rotation are dropped on the next one, so that the values of removed workloads are released.
+// Values dropped from the table stay valid, they are only no longer shared with new equal values.
+type Table struct {
+	mu sync.Mutex
+	// minRotationInterval is the minimum duration between two rotations.
+	minRotationInterval time.Duration
+	lastRotation        time.Time
+
+	strings     map[string]string
+	prevStrings map[string]string
+
+	labels     map[string]labels.Instance
+	prevLabels map[string]labels.Instance
+}
+
+// NewTable creates a table rotated at most once per minRotationInterval.
+func NewTable(minRotationInterval time.Duration) *Table {
+	return &Table{
+		minRotationInterval: minRotationInterval,
+		lastRotation:        time.Now(),
+		strings:             map[string]string{},
+		labels:              map[string]labels.Instance{},
+	}
+}
+
+// String returns the interned value of s.
+func (t *Table) String(s string) string {
+	if t == nil || s == "" {
+		return s
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.internString(s)
+}
+
+// Strings returns a copy of in holding the interned values of its strings.
+func (t *Table) Strings(in []string) []string {
+	if t == nil || in == nil {
+		return in
+	}
+	out := make([]string, len(in))
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, s := range in {
+		if s != "" {
+			out[i] = t.internString(s)
+		}
+	}
+	return out
+}
+
+// Host returns the interned value of the hostname h.
+func (t *Table) Host(h host.Name) host.Name {
+	return host.Name(t.String(string(h)))
+}
+
+// Labels returns a labels instance equal to l, shared by all the callers interning equal labels. The returned
+// labels must not be modified.
+func (t *Table) Labels(l labels.Instance) labels.Instance {
+	if t == nil || len(l) == 0 {
+		return l
+	}
+	key := labelsKey(l)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if out, f := t.labels[key]; f {
+		return out
+	}
+	if out, f := t.prevLabels[key]; f {
+		t.labels[key] = out
+		return out
+	}
+	out := make(labels.Instance, len(l))
+	for k, v := range l {
+		out[t.internString(k)] = t.internString(v)
+	}
+	t.labels[key] = out
+	return out
+}
+
+// internString interns s. It must be called with the lock held.
+func (t *Table) internString(s string) string {
+	if out, f := t.strings[s]; f {
+		return out
+	}
+	if out, f := t.prevStrings[s]; f {
+		t.strings[s] = out
+		return out
+	}
+	t.strings[s] = s
+	return s
+}
+
+// Rotate drops the entries not used since the previous rotation, unless the table was rotated less than its
+// minimum rotation interval ago. It returns true if the table was rotated.
+func (t *Table) Rotate() bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	if now.Sub(t.lastRotation) < t.minRotationInterval {
+		return false
+	}
+	t.lastRotation = now
+	t.prevStrings, t.strings = t.strings, make(map[string]string, len(t.strings))
+	t.prevLabels, t.labels = t.labels, make(map[string]labels.Instance, len(t.labels))
+	return true
+}
+
+// Len returns the number of strings and labels held by the table.
+func (t *Table) Len() (int, int) {
+	if t == nil {
+		return 0, 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.strings) + len(t.prevStrings), len(t.labels) + len(t.prevLabels)
+}
+
+// labelsKey returns a key identifying the labels. Unlike labels.Instance.String, it does not collide for
+// labels whose keys or values hold the separators.
+func labelsKey(l labels.Instance) string {
+	keys := make([]string, 0, len(l))
+	size := 0
+	for k, v := range l {
+		keys = append(keys, k)
+		size += len(k) + len(v) + 2
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.Grow(size)
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte(0)
+		b.WriteString(l[k])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
@@ -0,0 +1,135 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intern
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/labels"
+)
+
+// sameString returns true if a and b share the same memory.
+func sameString(a, b string) bool {
+	return (*reflect.StringHeader)(unsafe.Pointer(&a)).Data == (*reflect.StringHeader)(unsafe.Pointer(&b)).Data
+}
+
+func TestTable(t *testing.T) {
+	table := NewTable(0)
+	first := table.String(strings.Repeat("a", 3))
+	if got := table.String(strings.Repeat("a", 3)); !sameString(got, first) {
+		t.Errorf("expected equal strings to be shared")
+	}
+	if got := table.Host(host.Name(strings.Repeat("a", 3))); !sameString(string(got), first) {
+		t.Errorf("expected hosts to be shared with equal strings")
+	}
+
+	l := labels.Instance{"app": "reviews", "version": "v1"}
+	interned := table.Labels(labels.Instance{"app": "reviews", "version": "v1"})
+	if !interned.Equals(l) {
+		t.Fatalf("got labels %v, want %v", interned, l)
+	}
+	if got := table.Labels(l); reflect.ValueOf(got).Pointer() != reflect.ValueOf(interned).Pointer() {
+		t.Errorf("expected equal labels to be shared")
+	}
+	if got := table.Labels(labels.Instance{"app": "reviews"}); reflect.ValueOf(got).Pointer() == reflect.ValueOf(interned).Pointer() {
+		t.Errorf("expected different labels not to be shared")
+	}
+	if got := table.Labels(labels.Instance{"app=reviews\x00version": "v1"}); got.Equals(l) {
+		t.Errorf("expected labels with separators not to collide")
+	}
+
+	// Entries used since the previous rotation are kept by the next one.
+	table.Rotate()
+	table.String(first)
+	table.Rotate()
+	if got := table.String(strings.Repeat("a", 3)); !sameString(got, first) {
+		t.Errorf("expected used strings to be kept by the rotation")
+	}
+	table.Rotate()
+	table.Rotate()
+	if strs, lbls := table.Len(); strs != 0 || lbls != 0 {
+		t.Errorf("expected unused entries to be dropped, got %d strings and %d labels", strs, lbls)
+	}
+
+	if got := table.Strings(nil); got != nil {
+		t.Errorf("got %v, want nil", got)
+	}
+	var disabled *Table
+	if got := disabled.Labels(l); reflect.ValueOf(got).Pointer() != reflect.ValueOf(l).Pointer() {
+		t.Errorf("expected a nil table to return its input")
+	}
+}
+
+func TestRotationInterval(t *testing.T) {
+	table := NewTable(defaultRotationInterval)
+	if table.Rotate() {
+		t.Errorf("expected the table not to be rotated before its rotation interval")
+	}
+}
+
+// podLabels returns the labels of the replica of a workload, which share all their values.
+func podLabels(workload int) labels.Instance {
+	return labels.Instance{
+		"app":                         fmt.Sprintf("app-%d", workload),
+		"version":                     fmt.Sprintf("v%d", workload%3),
+		"pod-template-hash":           fmt.Sprintf("%x", workload*7919),
+		"security.istio.io/tlsMode":   "istio",
+		"service.istio.io/canonical":  fmt.Sprintf("app-%d", workload),
+		"topology.kubernetes.io/zone": fmt.Sprintf("zone-%d", workload%3),
+		"topology.istio.io/cluster":   "Kubernetes",
+	}
+}
+
+// BenchmarkLabels reports the memory retained by the labels of the endpoints of 100 workloads of 100 replicas,
+// with and without interning.
+func BenchmarkLabels(b *testing.B) {
+	const workloads, replicas = 100, 100
+	for _, interned := range []bool{false, true} {
+		b.Run(fmt.Sprintf("interned=%v", interned), func(b *testing.B) {
+			b.ReportAllocs()
+			var retained int64
+			for n := 0; n < b.N; n++ {
+				var table *Table
+				if interned {
+					table = NewTable(0)
+				}
+				before := heapAlloc()
+				endpoints := make([]labels.Instance, 0, workloads*replicas)
+				for w := 0; w < workloads; w++ {
+					for r := 0; r < replicas; r++ {
+						endpoints = append(endpoints, table.Labels(podLabels(w)))
+					}
+				}
+				retained += heapAlloc() - before
+				runtime.KeepAlive(endpoints)
+				runtime.KeepAlive(table)
+			}
+			b.ReportMetric(float64(retained)/float64(b.N*workloads*replicas), "retained-B/endpoint")
+		})
+	}
+}
+
+func heapAlloc() int64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return int64(stats.HeapAlloc)
+}
@@ -15,10 +15,15 @@
 package xds
 
 import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -29,6 +34,7 @@ import (
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 
+	"istio.io/istio/pilot/pkg/certexpiry"
 	"istio.io/istio/pilot/pkg/controller/workloadentry"
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
@@ -70,6 +76,9 @@ type Connection struct {
 	// Defines associated identities for the connection
 	Identities []string
 
+	// peerCert is the certificate presented by the client, if connected over TLS.
+	peerCert *x509.Certificate
+
 	// Time of connection, for debugging
 	Connect time.Time
 
@@ -86,6 +95,14 @@ type Connection struct {
 	// Both ADS and SDS streams implement this interface
 	stream DiscoveryStream
 
+	// deltaStream is set instead of stream for connections using the incremental (delta) variant
+	// of the protocol.
+	deltaStream DeltaDiscoveryStream
+
+	// sentResources is a map of TypeUrl to the name and version of each resource last sent on a
+	// delta stream. It is only accessed from the connection's main loop.
+	sentResources map[string]map[string]string
+
 	// Original node metadata, to avoid unmarshal/marshal.
 	// This is included in internal events.
 	node *core.Node
@@ -101,6 +118,14 @@ type Connection struct {
 	// (last push not ACKed). When we get an ACK from Envoy, if the type is populated here, we will trigger
 	// the push.
 	blockedPushes map[string]*model.PushRequest
+
+	// warmingPushes is a map of TypeUrl to the listener and route push requests deferred until the clusters
+	// pushed before them are warmed, when the cluster warming sequence is enabled.
+	warmingPushes map[string]*model.PushRequest
+
+	// requestLimiter defers the requests of the proxy over the configured rate limits. It is nil if
+	// requests are not rate limited.
+	requestLimiter *requestLimiter
 }
 
 // Event represents a config or registry event that results in a push.
@@ -121,6 +146,9 @@ func newConnection(peerAddr string, stream DiscoveryStream) *Connection {
 		Connect:       time.Now(),
 		stream:        stream,
 		blockedPushes: map[string]*model.PushRequest{},
+		warmingPushes: map[string]*model.PushRequest{},
+		requestLimiter: newRequestLimiter(features.XDSProxyRequestRateLimit, features.XDSRequestRateLimit,
+			features.XDSRequestBurst),
 	}
 }
 
@@ -221,9 +249,8 @@ func (s *DiscoveryServer) processRequest(req *discovery.DiscoveryRequest, con *C
 		// of what we would have pushed from the blocked push.
 		request = &model.PushRequest{Full: true}
 	} else if !haveBlockedPush {
-		// This is an ACK, no delayed push
-		// Return immediately, no action needed
-		return nil
+		// This is an ACK, no delayed push. It may complete the warming of the clusters.
+		return s.pushWarmed(con)
 	} else {
 		// we have a blocked push which we will use
 		adsLog.Debugf("%s: DEQUEUE for node:%s", v3.GetShortType(req.TypeUrl), con.proxy.ID)
@@ -285,6 +312,7 @@ func (s *DiscoveryServer) Stream(stream DiscoveryStream) error {
 	}
 	con := newConnection(peerAddr, stream)
 	con.Identities = ids
+	con.peerCert = peerCertificate(ctx)
 
 	// Do not call: defer close(con.pushChannel). The push channel will be garbage collected
 	// when the connection is no longer used. Closing the channel can cause subtle race conditions
@@ -320,12 +348,22 @@ func (s *DiscoveryServer) Stream(stream DiscoveryStream) error {
 				// Remote side closed connection or error processing the request.
 				return receiveError
 			}
+			if con.requestLimiter.throttle(req, con.stream.Context().Done()) {
+				adsLog.Debugf("ADS:%s: throttled request for node:%s", v3.GetShortType(req.TypeUrl), con.ConID)
+				xdsThrottledRequests.With(nodeTag.Value(con.proxy.ID), typeTag.Value(v3.GetMetricType(req.TypeUrl))).Increment()
+				continue
+			}
 			// processRequest is calling pushXXX, accessing common structs with pushConnection.
 			// Adding sync is the second issue to be resolved if we want to save 1/2 of the threads.
 			err := s.processRequest(req, con)
 			if err != nil {
 				return err
 			}
+		case req := <-con.requestLimiter.requests():
+			// A request deferred by the rate limits, now allowed.
+			if err := s.processRequest(req, con); err != nil {
+				return err
+			}
 
 		case pushEv := <-con.pushChannel:
 			err := s.pushConnection(con, pushEv)
@@ -355,7 +393,7 @@ func (s *DiscoveryServer) shouldRespond(con *Connection, request *discovery.Disc
 			s.StatusGen.OnNack(con.proxy, request)
 		}
 		con.proxy.Lock()
-		con.proxy.WatchedResources[request.TypeUrl].NonceNacked = request.ResponseNonce
+		recordNack(con.proxy.WatchedResources[request.TypeUrl], request.ResponseNonce, request.ErrorDetail.GetMessage())
 		con.proxy.Unlock()
 		return false
 	}
@@ -400,7 +438,7 @@ func (s *DiscoveryServer) shouldRespond(con *Connection, request *discovery.Disc
 			con.ConID, request.ResponseNonce, previousInfo.NonceSent)
 		xdsExpiredNonce.With(typeTag.Value(v3.GetMetricType(request.TypeUrl))).Increment()
 		con.proxy.Lock()
-		con.proxy.WatchedResources[request.TypeUrl].NonceNacked = ""
+		clearNack(con.proxy.WatchedResources[request.TypeUrl])
 		con.proxy.WatchedResources[request.TypeUrl].LastRequest = request
 		con.proxy.Unlock()
 		return false
@@ -412,7 +450,7 @@ func (s *DiscoveryServer) shouldRespond(con *Connection, request *discovery.Disc
 	previousResources := con.proxy.WatchedResources[request.TypeUrl].ResourceNames
 	con.proxy.WatchedResources[request.TypeUrl].VersionAcked = request.VersionInfo
 	con.proxy.WatchedResources[request.TypeUrl].NonceAcked = request.ResponseNonce
-	con.proxy.WatchedResources[request.TypeUrl].NonceNacked = ""
+	clearNack(con.proxy.WatchedResources[request.TypeUrl])
 	con.proxy.WatchedResources[request.TypeUrl].ResourceNames = request.ResourceNames
 	con.proxy.WatchedResources[request.TypeUrl].LastRequest = request
 	con.proxy.Unlock()
@@ -447,7 +485,7 @@ func shouldUnsubscribe(request *discovery.DiscoveryRequest) bool {
 // resource names.
 func isWildcardTypeURL(typeURL string) bool {
 	switch typeURL {
-	case v3.SecretType, v3.EndpointType, v3.RouteType:
+	case v3.SecretType, v3.EndpointType, v3.RouteType, v3.VirtualHostType:
 		// By XDS spec, these are not wildcard
 		return false
 	case v3.ClusterType, v3.ListenerType:
@@ -499,6 +537,12 @@ func (s *DiscoveryServer) initConnection(node *core.Node, con *Connection) error
 		}
 		con.proxy.VerifiedIdentity = id
 	}
+	if err := s.checkProxyShard(con); err != nil {
+		return err
+	}
+	if features.EnableSidecarCredentialName && proxy.Type == model.SidecarProxy {
+		proxy.SecretsAuthorized = s.secretsAuthorized(proxy)
+	}
 
 	// Register the connection; this allows pushes to be triggered for the proxy. Note: the timing of
 	// this an initProxyState is important. While registering for pushes *after* initialization is
@@ -507,6 +551,9 @@ func (s *DiscoveryServer) initConnection(node *core.Node, con *Connection) error
 	// triggered for the new push context, leading the proxy to have a stale state until the next
 	// full push.
 	s.addCon(con.ConID, con)
+	if con.peerCert != nil {
+		s.recordWorkloadCert(con, con.peerCert)
+	}
 
 	// Complete full initialization of the proxy
 	if err := s.initProxyState(node, con); err != nil {
@@ -559,6 +606,7 @@ func (s *DiscoveryServer) initProxyMetadata(node *core.Node) (*model.Proxy, erro
 	}
 	// Update the config namespace associated with this proxy
 	proxy.ConfigNamespace = model.GetProxyConfigNamespace(proxy)
+	proxy.ClientFeatures = node.ClientFeatures
 	return proxy, nil
 }
 
@@ -572,24 +620,7 @@ func (s *DiscoveryServer) initProxyState(node *core.Node, con *Connection) error
 		return err
 	}
 	s.setProxyState(proxy, s.globalPushContext())
-
-	// Get the locality from the proxy's service instances.
-	// We expect all instances to have the same IP and therefore the same locality.
-	// So its enough to look at the first instance.
-	if len(proxy.ServiceInstances) > 0 {
-		proxy.Locality = util.ConvertLocality(proxy.ServiceInstances[0].Endpoint.Locality.Label)
-	}
-
-	// If there is no locality in the registry then use the one sent as part of the discovery request.
-	// This is not preferable as only the connected Pilot is aware of this proxies location, but it
-	// can still help provide some client-side Envoy context when load balancing based on location.
-	if util.IsLocalityEmpty(proxy.Locality) {
-		proxy.Locality = &core.Locality{
-			Region:  node.Locality.GetRegion(),
-			Zone:    node.Locality.GetZone(),
-			SubZone: node.Locality.GetSubZone(),
-		}
-	}
+	setProxyLocality(proxy, node)
 
 	// Discover supported IP Versions of proxy so that appropriate config can be delivered.
 	proxy.DiscoverIPVersions()
@@ -615,6 +646,27 @@ func (s *DiscoveryServer) updateProxy(proxy *model.Proxy, push *model.PushContex
 	}
 }
 
+// setProxyLocality sets the locality of the proxy from its service instances, or from its node.
+func setProxyLocality(proxy *model.Proxy, node *core.Node) {
+	// Get the locality from the proxy's service instances.
+	// We expect all instances to have the same IP and therefore the same locality.
+	// So its enough to look at the first instance.
+	if len(proxy.ServiceInstances) > 0 {
+		proxy.Locality = util.ConvertLocality(proxy.ServiceInstances[0].Endpoint.Locality.Label)
+	}
+
+	// If there is no locality in the registry then use the one sent as part of the discovery request.
+	// This is not preferable as only the connected Pilot is aware of this proxies location, but it
+	// can still help provide some client-side Envoy context when load balancing based on location.
+	if util.IsLocalityEmpty(proxy.Locality) {
+		proxy.Locality = &core.Locality{
+			Region:  node.Locality.GetRegion(),
+			Zone:    node.Locality.GetZone(),
+			SubZone: node.Locality.GetSubZone(),
+		}
+	}
+}
+
 func (s *DiscoveryServer) setProxyState(proxy *model.Proxy, push *model.PushContext) {
 	proxy.SetWorkloadLabels(s.Env)
 	proxy.SetServiceInstances(push.ServiceDiscovery)
@@ -645,22 +697,13 @@ func (s *DiscoveryServer) preProcessRequest(proxy *model.Proxy, req *discovery.D
 	return true
 }
 
-// DeltaAggregatedResources is not implemented.
-// Instead, Generators may send only updates/add, with Delete indicated by an empty spec.
-// This works if both ends follow this model. For example EDS and the API generator follow this
-// pattern.
-//
-// The delta protocol changes the request, adding unsubscribe/subscribe instead of sending full
-// list of resources. On the response it adds 'removed resources' and sends changes for everything.
-// TODO: we could implement this method if needed, the change is not very big.
-func (s *DiscoveryServer) DeltaAggregatedResources(stream discovery.AggregatedDiscoveryService_DeltaAggregatedResourcesServer) error {
-	return status.Errorf(codes.Unimplemented, "not implemented")
-}
-
 // Compute and send the new configuration for a connection. This is blocking and may be slow
 // for large configs. The method will hold a lock on con.pushMutex.
-func (s *DiscoveryServer) pushConnection(con *Connection, pushEv *Event) error {
+func (s *DiscoveryServer) pushConnection(con *Connection, pushEv *Event) (err error) {
 	pushRequest := pushEv.pushRequest
+	span := startProxyPushSpan(con, pushRequest)
+	defer func() { endSpan(span, err) }()
+	pushRequest = pushRequest.WithSpan(span.SpanContext())
 
 	if pushRequest.Full {
 		// Update Proxy with current information.
@@ -678,9 +721,28 @@ func (s *DiscoveryServer) pushConnection(con *Connection, pushEv *Event) error {
 
 	currentVersion := versionInfo()
 
+	// The listeners and routes of a full push sending new clusters wait for the clusters to be warmed, which are
+	// pushed before them.
+	clustersNonce := con.NonceSent(v3.ClusterType)
 	// Send pushes to all generators
 	// Each Generator is responsible for determining if the push event requires a push
 	for _, w := range getWatchedResources(con.proxy.WatchedResources) {
+		if deferredUntilWarmed(w.TypeUrl) {
+			warming := features.EnableClusterWarmingSequence && pushRequest.Full &&
+				con.NonceSent(v3.ClusterType) != clustersNonce
+			con.proxy.Lock()
+			if warming {
+				con.warmingPushes[w.TypeUrl] = con.warmingPushes[w.TypeUrl].Merge(pushRequest)
+			} else {
+				// This push supersedes the one deferred by a previous push.
+				delete(con.warmingPushes, w.TypeUrl)
+			}
+			con.proxy.Unlock()
+			if warming {
+				adsLog.Debugf("%s: WARMING for node:%s", v3.GetShortType(w.TypeUrl), con.proxy.ID)
+				continue
+			}
+		}
 		if !features.EnableFlowControl {
 			// Always send the push if flow control disabled
 			if err := s.pushXds(con, pushRequest.Push, currentVersion, w, pushRequest); err != nil {
@@ -723,6 +785,51 @@ func (s *DiscoveryServer) pushConnection(con *Connection, pushEv *Event) error {
 	return nil
 }
 
+// deferredUntilWarmed returns true if the pushes of the type wait for the clusters pushed before them to be warmed.
+func deferredUntilWarmed(typeURL string) bool {
+	return typeURL == v3.ListenerType || typeURL == v3.RouteType
+}
+
+// pushWarmed sends the listener and route pushes deferred until the clusters pushed before them are warmed, once
+// the proxy acknowledged the endpoints sent after the clusters, or the clusters if it does not watch endpoints.
+func (s *DiscoveryServer) pushWarmed(con *Connection) error {
+	con.proxy.RLock()
+	pending := len(con.warmingPushes) > 0
+	_, watchesEndpoints := con.proxy.WatchedResources[v3.EndpointType]
+	con.proxy.RUnlock()
+	if !pending {
+		return nil
+	}
+	warmingType := v3.ClusterType
+	if watchesEndpoints {
+		warmingType = v3.EndpointType
+	}
+	if synced, timeout := con.Synced(warmingType); !synced && !timeout {
+		return nil
+	}
+
+	con.proxy.Lock()
+	pushes := con.warmingPushes
+	con.warmingPushes = map[string]*model.PushRequest{}
+	con.proxy.Unlock()
+	push := s.globalPushContext()
+	for _, tp := range PushOrder {
+		request, f := pushes[tp]
+		if !f {
+			continue
+		}
+		w := con.Watched(tp)
+		if w == nil {
+			continue
+		}
+		adsLog.Debugf("%s: WARMED for node:%s", v3.GetShortType(tp), con.proxy.ID)
+		if err := s.pushXds(con, push, versionInfo(), w, request); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // PushOrder defines the order that updates will be pushed in. Any types not listed here will be pushed in random
 // order after the types listed here
 var PushOrder = []string{v3.ClusterType, v3.EndpointType, v3.ListenerType, v3.RouteType, v3.SecretType}
@@ -862,6 +969,55 @@ func (s *DiscoveryServer) addCon(conID string, con *Connection) {
 	s.adsClients[conID] = con
 }
 
+// recordWorkloadCert tracks the expiration of the workload certificate of the proxy of the connection.
+func (s *DiscoveryServer) recordWorkloadCert(con *Connection, cert *x509.Certificate) {
+	podName := strings.TrimSuffix(con.proxy.ID, "."+con.proxy.ConfigNamespace)
+	s.CertExpiry.Record(con.ConID, certexpiry.FromX509(certexpiry.Workload, con.proxy.ConfigNamespace, podName, cert))
+}
+
+// RecordIssuedCert tracks a workload certificate issued by the CA, replacing the certificate the proxies of the
+// same address and identity connected with: connections outlive the rotation of the workload certificates.
+func (s *DiscoveryServer) RecordIssuedCert(peerAddr net.Addr, certPEM []byte) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil || len(cert.URIs) == 0 {
+		return
+	}
+	host, _, err := net.SplitHostPort(peerAddr.String())
+	if err != nil {
+		return
+	}
+	identity := cert.URIs[0].String()
+	for _, con := range s.Clients() {
+		if !containsString(con.proxy.IPAddresses, host) || !containsString(con.Identities, identity) {
+			continue
+		}
+		s.recordWorkloadCert(con, cert)
+	}
+}
+
+func containsString(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// hasProxyLocked returns true if a connection of the proxy is registered. The caller must hold adsClientsMutex.
+func (s *DiscoveryServer) hasProxyLocked(proxyID string) bool {
+	for _, con := range s.adsClients {
+		if con.proxy != nil && con.proxy.ID == proxyID {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *DiscoveryServer) removeCon(conID string) {
 	s.adsClientsMutex.Lock()
 	defer s.adsClientsMutex.Unlock()
@@ -872,7 +1028,12 @@ func (s *DiscoveryServer) removeCon(conID string) {
 	} else {
 		delete(s.adsClients, conID)
 		recordXDSClients(con.proxy.Metadata.IstioVersion, -1)
+		// Release the gateway certificates referenced by the proxy, unless it reconnected already.
+		if !s.hasProxyLocked(con.proxy.ID) {
+			s.CertExpiry.Release(con.proxy.ID)
+		}
 	}
+	s.CertExpiry.Remove(conID)
 
 	if s.StatusReporter != nil {
 		s.StatusReporter.RegisterDisconnect(conID, AllEventTypesList)
@@ -881,6 +1042,16 @@ func (s *DiscoveryServer) removeCon(conID string) {
 
 // Send with timeout
 func (conn *Connection) send(res *discovery.DiscoveryResponse) error {
+	sz := 0
+	for _, rc := range res.Resources {
+		sz += len(rc.Value)
+	}
+	return conn.sendWithTimeout(func() error { return conn.stream.Send(res) }, res.TypeUrl, res.Nonce, res.VersionInfo, sz)
+}
+
+// sendWithTimeout calls send, giving up after sendTimeout, and records the sent nonce and version
+// for the type on success.
+func (conn *Connection) sendWithTimeout(send func() error, typeURL, nonce, version string, sz int) error {
 	errChan := make(chan error, 1)
 
 	// sendTimeout may be modified via environment
@@ -888,7 +1059,7 @@ func (conn *Connection) send(res *discovery.DiscoveryResponse) error {
 	go func() {
 		start := time.Now()
 		defer func() { recordSendTime(time.Since(start)) }()
-		errChan <- conn.stream.Send(res)
+		errChan <- send()
 		close(errChan)
 	}()
 
@@ -899,19 +1070,15 @@ func (conn *Connection) send(res *discovery.DiscoveryResponse) error {
 		return status.Errorf(codes.DeadlineExceeded, "timeout sending")
 	case err := <-errChan:
 		if err == nil {
-			sz := 0
-			for _, rc := range res.Resources {
-				sz += len(rc.Value)
-			}
 			conn.proxy.Lock()
-			if res.Nonce != "" {
-				if conn.proxy.WatchedResources[res.TypeUrl] == nil {
-					conn.proxy.WatchedResources[res.TypeUrl] = &model.WatchedResource{TypeUrl: res.TypeUrl}
+			if nonce != "" {
+				if conn.proxy.WatchedResources[typeURL] == nil {
+					conn.proxy.WatchedResources[typeURL] = &model.WatchedResource{TypeUrl: typeURL}
 				}
-				conn.proxy.WatchedResources[res.TypeUrl].NonceSent = res.Nonce
-				conn.proxy.WatchedResources[res.TypeUrl].VersionSent = res.VersionInfo
-				conn.proxy.WatchedResources[res.TypeUrl].LastSent = time.Now()
-				conn.proxy.WatchedResources[res.TypeUrl].LastSize = sz
+				conn.proxy.WatchedResources[typeURL].NonceSent = nonce
+				conn.proxy.WatchedResources[typeURL].VersionSent = version
+				conn.proxy.WatchedResources[typeURL].LastSent = time.Now()
+				conn.proxy.WatchedResources[typeURL].LastSize = sz
 			}
 			conn.proxy.Unlock()
 		}
@@ -956,6 +1123,32 @@ func (conn *Connection) NonceSent(typeUrl string) string {
 	return ""
 }
 
+// NackMessage returns the error reported by the client if it rejected the last response of the type, or "".
+func (conn *Connection) NackMessage(typeURL string) string {
+	conn.proxy.RLock()
+	defer conn.proxy.RUnlock()
+	if w := conn.proxy.WatchedResources[typeURL]; w != nil && w.NonceNacked != "" && w.NonceNacked == w.NonceSent {
+		return w.NackMessage
+	}
+	return ""
+}
+
+// LastNack returns the error reported by the client for the last response it rejected, of any type, prefixed with
+// the short name of the type, and when it was received. It returns "" if the client never rejected a response.
+func (conn *Connection) LastNack() (string, time.Time) {
+	conn.proxy.RLock()
+	defer conn.proxy.RUnlock()
+	var message string
+	var last time.Time
+	for typeURL, w := range conn.proxy.WatchedResources {
+		if w != nil && w.LastNackMessage != "" && w.LastNackTime.After(last) {
+			message = v3.GetShortType(typeURL) + ": " + w.LastNackMessage
+			last = w.LastNackTime
+		}
+	}
+	return message, last
+}
+
 func (conn *Connection) Clusters() []string {
 	conn.proxy.RLock()
 	defer conn.proxy.RUnlock()
@@ -997,3 +1190,12 @@ func (conn *Connection) Watched(typeUrl string) *model.WatchedResource {
 func (conn *Connection) Stop() {
 	conn.stop <- struct{}{}
 }
+
+// streamContext returns the context of the underlying gRPC stream, whichever variant of the
+// protocol the connection uses.
+func (conn *Connection) streamContext() context.Context {
+	if conn.deltaStream != nil {
+		return conn.deltaStream.Context()
+	}
+	return conn.stream.Context()
+}
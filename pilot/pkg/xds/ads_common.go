@@ -48,6 +48,10 @@ func ConfigAffectsProxy(req *model.PushRequest, proxy *model.Proxy) bool {
 				}
 			}
 		}
+		// Sidecars are only affected by secrets if they may be served the credentialName of DestinationRules.
+		if config.Kind == gvk.Secret && proxy.Type == model.SidecarProxy {
+			affected = sidecarServedSecrets(proxy)
+		}
 
 		if affected && checkProxyDependencies(proxy, config) {
 			return true
@@ -80,6 +84,11 @@ func DefaultProxyNeedsPush(proxy *model.Proxy, req *model.PushRequest) bool {
 	}
 
 	// If the proxy's service updated, need push for it.
+	return proxyServiceUpdated(proxy, req)
+}
+
+// proxyServiceUpdated returns true if the push request updates the service of the proxy.
+func proxyServiceUpdated(proxy *model.Proxy, req *model.PushRequest) bool {
 	if len(proxy.ServiceInstances) > 0 && req.ConfigsUpdated != nil {
 		svc := proxy.ServiceInstances[0].Service
 		if _, ok := req.ConfigsUpdated[model.ConfigKey{
@@ -90,6 +99,5 @@ func DefaultProxyNeedsPush(proxy *model.Proxy, req *model.PushRequest) bool {
 			return true
 		}
 	}
-
 	return false
 }
@@ -16,6 +16,7 @@ package xds
 
 import (
 	"context"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"strings"
@@ -65,3 +66,16 @@ func (s *DiscoveryServer) authenticate(ctx context.Context) ([]string, error) {
 	adsLog.Errorf("Failed to authenticate client from %s: %s", peerInfo.Addr.String(), strings.Join(authFailMsgs, "; "))
 	return nil, errors.New("authentication failure")
 }
+
+// peerCertificate returns the leaf certificate presented by the client of a TLS connection, if any.
+func peerCertificate(ctx context.Context) *x509.Certificate {
+	peerInfo, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	tlsInfo, ok := peerInfo.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.PeerCertificates) == 0 {
+		return nil
+	}
+	return tlsInfo.State.PeerCertificates[0]
+}
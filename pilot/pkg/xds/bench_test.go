@@ -280,6 +280,7 @@ func BenchmarkEndpointGeneration(b *testing.B) {
 		{10, 10},
 		{100, 10},
 		{1000, 1},
+		{10000, 1},
 	}
 
 	var response *discovery.DiscoveryResponse
@@ -311,6 +312,45 @@ func BenchmarkEndpointGeneration(b *testing.B) {
 	}
 }
 
+// BenchmarkLargeServiceEndpointGeneration measures EDS generation for a single service with many endpoints
+// spread over ports and localities, where only the endpoints of the cluster port should be visited.
+func BenchmarkLargeServiceEndpointGeneration(b *testing.B) {
+	disableLogging()
+	tests := []struct {
+		endpoints  int
+		ports      int
+		localities int
+	}{
+		{10000, 1, 1},
+		{10000, 10, 10},
+		{20000, 10, 100},
+	}
+
+	var response *discovery.DiscoveryResponse
+	for _, tt := range tests {
+		b.Run(fmt.Sprintf("%d/%d/%d", tt.endpoints, tt.ports, tt.localities), func(b *testing.B) {
+			s := NewFakeDiscoveryServer(b, FakeOptions{
+				Configs: []config.Config{createLocalityEndpoints(tt.endpoints, tt.ports, tt.localities)},
+			})
+			proxy := &model.Proxy{
+				Type:            model.SidecarProxy,
+				IPAddresses:     []string{"10.3.3.3"},
+				ID:              "random",
+				ConfigNamespace: "default",
+				Metadata:        &model.NodeMetadata{},
+			}
+			push := s.Discovery.globalPushContext()
+			proxy.SetSidecarScope(push)
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				l := s.Discovery.generateEndpoints(NewEndpointBuilder("outbound|80||foo.com", proxy, push))
+				response = endpointDiscoveryResponse([]*any.Any{util.MessageToAny(l)}, version, push.LedgerVersion)
+			}
+			logDebug(b, response.GetResources())
+		})
+	}
+}
+
 // Setup test builds a mock test environment. Note: push context is not initialized, to be able to benchmark separately
 // most should just call setupAndInitializeTest
 func setupTest(t testing.TB, config ConfigInput) (*FakeDiscoveryServer, *model.Proxy) {
@@ -457,3 +497,33 @@ func createEndpoints(numEndpoints int, numServices int) []config.Config {
 	}
 	return result
 }
+
+// createLocalityEndpoints creates a service entry with numPorts ports and numEndpoints endpoints, spread over
+// numLocalities localities. Each endpoint gets an instance for every port.
+func createLocalityEndpoints(numEndpoints int, numPorts int, numLocalities int) config.Config {
+	ports := make([]*networking.Port, 0, numPorts)
+	for p := 0; p < numPorts; p++ {
+		ports = append(ports, &networking.Port{Number: uint32(80 + p), Name: fmt.Sprintf("http-port-%d", p), Protocol: "http"})
+	}
+	endpoints := make([]*networking.WorkloadEntry, 0, numEndpoints)
+	for e := 0; e < numEndpoints; e++ {
+		endpoints = append(endpoints, &networking.WorkloadEntry{
+			Address:  fmt.Sprintf("111.%d.%d.%d", e/(256*256), (e/256)%256, e%256),
+			Locality: fmt.Sprintf("region/zone-%d", e%numLocalities),
+		})
+	}
+	return config.Config{
+		Meta: config.Meta{
+			GroupVersionKind:  collections.IstioNetworkingV1Alpha3Serviceentries.Resource().GroupVersionKind(),
+			Name:              "foo",
+			Namespace:         "default",
+			CreationTimestamp: time.Now(),
+		},
+		Spec: &networking.ServiceEntry{
+			Hosts:      []string{"foo.com"},
+			Ports:      ports,
+			Endpoints:  endpoints,
+			Resolution: networking.ServiceEntry_STATIC,
+		},
+	}
+}
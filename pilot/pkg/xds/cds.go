@@ -25,7 +25,7 @@ type CdsGenerator struct {
 	Server *DiscoveryServer
 }
 
-var _ model.XdsResourceGenerator = &CdsGenerator{}
+var _ model.XdsDeltaResourceGenerator = &CdsGenerator{}
 
 // Map of all configs that do not impact CDS
 var skippedCdsConfigs = map[config.GroupVersionKind]struct{}{
@@ -80,3 +80,29 @@ func (c CdsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *m
 	}
 	return resources, nil
 }
+
+// GenerateDeltas builds only the clusters of the services updated by the push request, and names the removed
+// clusters of these services.
+func (c CdsGenerator) GenerateDeltas(proxy *model.Proxy, push *model.PushContext, updates *model.PushRequest,
+	w *model.WatchedResource, sent []string) (model.Resources, []string, bool) {
+	if !cdsNeedsPush(updates, proxy) {
+		return nil, nil, true
+	}
+	// Only the configs affecting clusters scope the push.
+	scoped := *updates
+	scoped.ConfigsUpdated = make(map[model.ConfigKey]struct{}, len(updates.ConfigsUpdated))
+	for key := range updates.ConfigsUpdated {
+		if _, f := skippedCdsConfigs[key.Kind]; !f {
+			scoped.ConfigsUpdated[key] = struct{}{}
+		}
+	}
+	rawClusters, removed, ok := c.Server.ConfigGenerator.BuildDeltaClusters(proxy, push, &scoped, sent)
+	if !ok {
+		return nil, nil, false
+	}
+	resources := model.Resources{}
+	for _, c := range rawClusters {
+		resources = append(resources, util.MessageToAny(c))
+	}
+	return resources, removed, true
+}
@@ -0,0 +1,115 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+)
+
+// endpointsDebounceKind is the kind of PILOT_DEBOUNCE_BY_KIND standing for the endpoint updates, which are not
+// full pushes.
+const endpointsDebounceKind = "Endpoints"
+
+// debounceWindow is the debounce delay and max delay of events.
+type debounceWindow struct {
+	after time.Duration
+	max   time.Duration
+}
+
+func (w debounceWindow) min(o debounceWindow) debounceWindow {
+	if o.after < w.after {
+		w.after = o.after
+	}
+	if o.max < w.max {
+		w.max = o.max
+	}
+	return w
+}
+
+// window returns the debounce window of the push request: the shortest of the windows of the kinds of its
+// updated configs, or the default window if none of them overrides it.
+func (opts debounceOptions) window(req *model.PushRequest) debounceWindow {
+	def := debounceWindow{after: opts.debounceAfter, max: opts.debounceMax}
+	if len(opts.kindWindows) == 0 {
+		return def
+	}
+	if !req.Full {
+		if w, f := opts.kindWindows[endpointsDebounceKind]; f {
+			return w
+		}
+		return def
+	}
+	if len(req.ConfigsUpdated) == 0 {
+		return def
+	}
+	var out *debounceWindow
+	for key := range req.ConfigsUpdated {
+		w, f := opts.kindWindows[key.Kind.Kind]
+		if !f {
+			w = def
+		}
+		if out == nil {
+			out = &w
+		} else {
+			*out = out.min(w)
+		}
+	}
+	return *out
+}
+
+// kindDebounceWindows returns the debounce windows of PILOT_DEBOUNCE_BY_KIND, ignoring it if invalid.
+func kindDebounceWindows(value string) map[string]debounceWindow {
+	windows, err := parseDebounceWindows(value, features.DebounceMax)
+	if err != nil {
+		adsLog.Warnf("ignored PILOT_DEBOUNCE_BY_KIND: %v", err)
+		return nil
+	}
+	return windows
+}
+
+// parseDebounceWindows parses a comma separated list of kinds with their debounce delay and optionally their max
+// delay, such as "Endpoints=5ms,EnvoyFilter=1s/30s". The max delay defaults to defaultMax.
+func parseDebounceWindows(value string, defaultMax time.Duration) (map[string]debounceWindow, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	out := map[string]debounceWindow{}
+	for _, entry := range strings.Split(value, ",") {
+		kv := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid entry %q, expected <kind>=<delay>[/<max delay>]", entry)
+		}
+		delays := strings.SplitN(kv[1], "/", 2)
+		after, err := time.ParseDuration(delays[0])
+		if err != nil || after < 0 {
+			return nil, fmt.Errorf("invalid delay %q of %s", delays[0], kv[0])
+		}
+		w := debounceWindow{after: after, max: defaultMax}
+		if len(delays) == 2 {
+			if w.max, err = time.ParseDuration(delays[1]); err != nil || w.max < after {
+				return nil, fmt.Errorf("invalid max delay %q of %s", delays[1], kv[0])
+			}
+		} else if w.max < after {
+			w.max = after
+		}
+		out[kv[0]] = w
+	}
+	return out, nil
+}
@@ -31,6 +31,7 @@ import (
 	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/any"
 
+	"istio.io/istio/pilot/pkg/certexpiry"
 	"istio.io/istio/pilot/pkg/config/kube/crd"
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
@@ -41,7 +42,9 @@ import (
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/spiffe"
 	"istio.io/pkg/log"
+	istioversion "istio.io/pkg/version"
 )
 
 var indexTmpl = template.Must(template.New("index").Parse(`<html>
@@ -112,11 +115,34 @@ type SyncStatus struct {
 	RouteAcked    string `json:"route_acked,omitempty"`
 	EndpointSent  string `json:"endpoint_sent,omitempty"`
 	EndpointAcked string `json:"endpoint_acked,omitempty"`
+	// The *Nack fields are the errors reported by the proxy if it rejected the last response of the type.
+	ClusterNack  string `json:"cluster_nack,omitempty"`
+	ListenerNack string `json:"listener_nack,omitempty"`
+	RouteNack    string `json:"route_nack,omitempty"`
+	EndpointNack string `json:"endpoint_nack,omitempty"`
+	// LastNack is the error reported by the proxy for the last response it rejected, of any type, even if it
+	// accepted a later response, and LastNackTime when it was received.
+	LastNack     string     `json:"last_nack,omitempty"`
+	LastNackTime *time.Time `json:"last_nack_time,omitempty"`
+	// IstiodVersion is the version of the Istiod the proxy is connected to, to tell the version skew.
+	IstiodVersion string `json:"istiod_version,omitempty"`
+}
+
+// ConfigSize is the size of the config last generated for a connected proxy.
+type ConfigSize struct {
+	ProxyID   string         `json:"proxy"`
+	ProxyType model.NodeType `json:"proxy_type"`
+	Namespace string         `json:"namespace"`
+	// Sizes are the sizes in bytes of the full config of each type, keyed by the type as in the metrics.
+	Sizes        map[string]int `json:"sizes"`
+	TotalSize    int            `json:"total_size"`
+	FilterChains int            `json:"filter_chains"`
 }
 
 // SyncedVersions shows what resourceVersion of a given resource has been acked by Envoy.
 type SyncedVersions struct {
 	ProxyID         string `json:"proxy,omitempty"`
+	ProxyType       string `json:"proxy_type,omitempty"`
 	ClusterVersion  string `json:"cluster_acked,omitempty"`
 	ListenerVersion string `json:"listener_acked,omitempty"`
 	RouteVersion    string `json:"route_acked,omitempty"`
@@ -165,6 +191,8 @@ func (s *DiscoveryServer) AddDebugHandlers(mux *http.ServeMux, enableProfiling b
 	s.addDebugHandler(mux, "/debug/adsz?push=true", "Initiates push of the current state to all connected endpoints", s.adsz)
 
 	s.addDebugHandler(mux, "/debug/syncz", "Synchronization status of all Envoys connected to this Pilot instance", s.Syncz)
+	s.addDebugHandler(mux, "/debug/config_sizez", "Size of the config and number of filter chains of the connected proxies, "+
+		"optionally filtered by namespace", s.ConfigSizez)
 	s.addDebugHandler(mux, "/debug/config_distribution", "Version status of all Envoys connected to this Pilot instance", s.distributedVersions)
 
 	s.addDebugHandler(mux, "/debug/registryz", "Debug support for registry", s.registryz)
@@ -173,16 +201,20 @@ func (s *DiscoveryServer) AddDebugHandlers(mux *http.ServeMux, enableProfiling b
 	s.addDebugHandler(mux, "/debug/cachez", "Info about the internal XDS caches", s.cachez)
 	s.addDebugHandler(mux, "/debug/configz", "Debug support for config", s.configz)
 	s.addDebugHandler(mux, "/debug/sidecarz", "Debug sidecar scope for a proxy", s.sidecarz)
+	s.addDebugHandler(mux, "/debug/envoyfilterz", "Results of the EnvoyFilter patches, for all proxies or a proxy", s.envoyFilterz)
 	s.addDebugHandler(mux, "/debug/resourcesz", "Debug support for watched resources", s.resourcez)
 	s.addDebugHandler(mux, "/debug/instancesz", "Debug support for service instances", s.instancesz)
 
 	s.addDebugHandler(mux, "/debug/authorizationz", "Internal authorization policies", s.Authorizationz)
 	s.addDebugHandler(mux, "/debug/config_dump", "ConfigDump in the form of the Envoy admin config dump API for passed in proxyID", s.ConfigDump)
+	s.addDebugHandler(mux, "/debug/snapshotz", "Snapshot of the configs, registries and proxies, to replay the xDS generation offline", s.snapshotz)
 	s.addDebugHandler(mux, "/debug/push_status", "Last PushContext Details", s.PushStatusHandler)
 	s.addDebugHandler(mux, "/debug/pushcontext", "Debug support for current push context", s.PushContextHandler)
 
 	s.addDebugHandler(mux, "/debug/inject", "Active inject template", s.InjectTemplateHandler(webhook))
 	s.addDebugHandler(mux, "/debug/mesh", "Active mesh config", s.MeshHandler)
+	s.addDebugHandler(mux, "/debug/spiffe_bundlez", "Fetch status of the SPIFFE bundles of federated trust domains", s.spiffeBundlez)
+	s.addDebugHandler(mux, "/debug/cert_expiryz", "Expiration of the workload and gateway certificates", s.certExpiryz)
 }
 
 func (s *DiscoveryServer) addDebugHandler(mux *http.ServeMux, path string, help string,
@@ -197,9 +229,10 @@ func (s *DiscoveryServer) Syncz(w http.ResponseWriter, _ *http.Request) {
 	for _, con := range s.Clients() {
 		node := con.proxy
 		if node != nil {
-			syncz = append(syncz, SyncStatus{
+			status := SyncStatus{
 				ProxyID:       node.ID,
 				IstioVersion:  node.Metadata.IstioVersion,
+				IstiodVersion: istioversion.Info.Version,
 				ClusterSent:   con.NonceSent(v3.ClusterType),
 				ClusterAcked:  con.NonceAcked(v3.ClusterType),
 				ListenerSent:  con.NonceSent(v3.ListenerType),
@@ -208,7 +241,16 @@ func (s *DiscoveryServer) Syncz(w http.ResponseWriter, _ *http.Request) {
 				RouteAcked:    con.NonceAcked(v3.RouteType),
 				EndpointSent:  con.NonceSent(v3.EndpointType),
 				EndpointAcked: con.NonceAcked(v3.EndpointType),
-			})
+				ClusterNack:   con.NackMessage(v3.ClusterType),
+				ListenerNack:  con.NackMessage(v3.ListenerType),
+				RouteNack:     con.NackMessage(v3.RouteType),
+				EndpointNack:  con.NackMessage(v3.EndpointType),
+			}
+			if nack, t := con.LastNack(); nack != "" {
+				status.LastNack = nack
+				status.LastNackTime = &t
+			}
+			syncz = append(syncz, status)
 		}
 	}
 	out, err := json.MarshalIndent(&syncz, "", "    ")
@@ -221,6 +263,46 @@ func (s *DiscoveryServer) Syncz(w http.ResponseWriter, _ *http.Request) {
 	_, _ = w.Write(out)
 }
 
+// ConfigSizez lists the size of the config last generated for the connected proxies, largest first. The
+// proxies can be filtered with the namespace query parameter.
+func (s *DiscoveryServer) ConfigSizez(w http.ResponseWriter, req *http.Request) {
+	namespace := req.URL.Query().Get("namespace")
+	sizes := make([]ConfigSize, 0)
+	for _, con := range s.Clients() {
+		node := con.proxy
+		if node == nil || (namespace != "" && node.ConfigNamespace != namespace) {
+			continue
+		}
+		size := ConfigSize{
+			ProxyID:   node.ID,
+			ProxyType: node.Type,
+			Namespace: node.ConfigNamespace,
+			Sizes:     map[string]int{},
+		}
+		node.RLock()
+		for typeURL, wr := range node.WatchedResources {
+			size.Sizes[v3.GetMetricType(typeURL)] = wr.ConfigSize
+			size.TotalSize += wr.ConfigSize
+			if typeURL == v3.ListenerType {
+				size.FilterChains = wr.FilterChains
+			}
+		}
+		node.RUnlock()
+		sizes = append(sizes, size)
+	}
+	sort.SliceStable(sizes, func(i, j int) bool {
+		return sizes[i].TotalSize > sizes[j].TotalSize
+	})
+	out, err := json.MarshalIndent(&sizes, "", "    ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprintf(w, "unable to marshal config sizes: %v", err)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	_, _ = w.Write(out)
+}
+
 // registryz providees debug support for registry - adding and listing model items.
 // Can be combined with the push debug interface to reproduce changes.
 func (s *DiscoveryServer) registryz(w http.ResponseWriter, req *http.Request) {
@@ -267,6 +349,37 @@ func (s *DiscoveryServer) cachez(w http.ResponseWriter, req *http.Request) {
 	_, _ = w.Write(bytes)
 }
 
+// spiffeBundlez dumps the fetch status of the SPIFFE bundle endpoints of the federated trust domains.
+func (s *DiscoveryServer) spiffeBundlez(w http.ResponseWriter, _ *http.Request) {
+	status := []spiffe.BundleEndpointStatus{}
+	if s.SpiffeBundles != nil {
+		status = s.SpiffeBundles.Status()
+	}
+	out, err := json.MarshalIndent(status, "", "    ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprintf(w, "unable to marshal SPIFFE bundle status: %v", err)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	_, _ = w.Write(out)
+}
+
+func (s *DiscoveryServer) certExpiryz(w http.ResponseWriter, _ *http.Request) {
+	certs := []certexpiry.Cert{}
+	if s.CertExpiry != nil {
+		certs = s.CertExpiry.Certs()
+	}
+	out, err := json.MarshalIndent(certs, "", "    ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprintf(w, "unable to marshal certificate expirations: %v", err)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	_, _ = w.Write(out)
+}
+
 // Endpoint debugging
 func (s *DiscoveryServer) endpointz(w http.ResponseWriter, req *http.Request) {
 	_ = req.ParseForm()
@@ -316,16 +429,19 @@ func (s *DiscoveryServer) distributedVersions(w http.ResponseWriter, req *http.R
 	}
 	if resourceID := req.URL.Query().Get("resource"); resourceID != "" {
 		proxyNamespace := req.URL.Query().Get("proxy_namespace")
+		proxyType := req.URL.Query().Get("proxy_type")
 		knownVersions := make(map[string]string)
 		var results []SyncedVersions
 		for _, con := range s.Clients() {
 			// wrap this in independent scope so that panic's don't bypass Unlock...
 			con.proxy.RLock()
 
-			if con.proxy != nil && (proxyNamespace == "" || proxyNamespace == con.proxy.ConfigNamespace) {
+			if con.proxy != nil && (proxyNamespace == "" || proxyNamespace == con.proxy.ConfigNamespace) &&
+				(proxyType == "" || proxyType == string(con.proxy.Type)) {
 				// read nonces from our statusreporter to allow for skipped nonces, etc.
 				results = append(results, SyncedVersions{
-					ProxyID: con.proxy.ID,
+					ProxyID:   con.proxy.ID,
+					ProxyType: string(con.proxy.Type),
 					ClusterVersion: s.getResourceVersion(s.StatusReporter.QueryLastNonce(con.ConID, v3.ClusterType),
 						resourceID, knownVersions),
 					ListenerVersion: s.getResourceVersion(s.StatusReporter.QueryLastNonce(con.ConID, v3.ListenerType),
@@ -432,6 +548,41 @@ func (s *DiscoveryServer) sidecarz(w http.ResponseWriter, req *http.Request) {
 	_, _ = w.Write(by)
 }
 
+// envoyFilterz returns whether each EnvoyFilter patch was applied, matched nothing or conflicted. With a proxyID,
+// the results are those of the proxy, otherwise they are summarized for all the proxies connected to this Pilot.
+func (s *DiscoveryServer) envoyFilterz(w http.ResponseWriter, req *http.Request) {
+	var results interface{}
+	if proxyID := req.URL.Query().Get("proxyID"); proxyID != "" {
+		con := s.getProxyConnection(proxyID)
+		if con == nil {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("Proxy not connected to this Pilot instance"))
+			return
+		}
+		results = con.proxy.EnvoyFilterPatchResults()
+	} else {
+		results = s.EnvoyFilterPatchSummaries()
+	}
+	by, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	_, _ = w.Write(by)
+}
+
+// EnvoyFilterPatchSummaries summarizes the results of the EnvoyFilter patches for the proxies connected to this Pilot.
+func (s *DiscoveryServer) EnvoyFilterPatchSummaries() []model.EnvoyFilterPatchSummary {
+	clients := s.Clients()
+	proxies := make([]*model.Proxy, 0, len(clients))
+	for _, c := range clients {
+		proxies = append(proxies, c.proxy)
+	}
+	return s.globalPushContext().SummarizeEnvoyFilterPatches(proxies)
+}
+
 // Resource debugging.
 func (s *DiscoveryServer) resourcez(w http.ResponseWriter, _ *http.Request) {
 	w.Header().Add("Content-Type", "application/json")
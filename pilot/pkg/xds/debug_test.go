@@ -132,6 +132,43 @@ func verifySyncStatus(t *testing.T, s *xds.DiscoveryServer, nodeID string, wantS
 	}
 }
 
+func TestConfigSizez(t *testing.T) {
+	leak.Check(t)
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{})
+	ads := s.ConnectADS()
+	ads.RequestResponseAck(&discovery.DiscoveryRequest{TypeUrl: v3.ClusterType})
+	ads.RequestResponseAck(&discovery.DiscoveryRequest{TypeUrl: v3.ListenerType})
+
+	get := func(namespace string) []xds.ConfigSize {
+		req, err := http.NewRequest("GET", "/debug/config_sizez?namespace="+namespace, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(s.Discovery.ConfigSizez).ServeHTTP(rr, req)
+		got := []xds.ConfigSize{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	sizes := get("")
+	if len(sizes) != 1 {
+		t.Fatalf("expected the size of the connected proxy, got %v", sizes)
+	}
+	size := sizes[0]
+	if size.Sizes["cds"] == 0 || size.Sizes["lds"] == 0 || size.TotalSize != size.Sizes["cds"]+size.Sizes["lds"] {
+		t.Errorf("unexpected config sizes %v (total %d)", size.Sizes, size.TotalSize)
+	}
+	if size.FilterChains == 0 {
+		t.Errorf("expected the filter chains of the listeners to be counted")
+	}
+	if sizes := get("not-" + size.Namespace); len(sizes) != 0 {
+		t.Errorf("expected the proxies of other namespaces to be filtered out, got %v", sizes)
+	}
+}
+
 func TestConfigDump(t *testing.T) {
 	leak.Check(t)
 	tests := []struct {
@@ -178,6 +215,48 @@ func TestConfigDump(t *testing.T) {
 	}
 }
 
+func TestSnapshotReplay(t *testing.T) {
+	leak.Check(t)
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{
+		ConfigString: mustReadFile(t, "tests/testdata/config/destination-rule-all.yaml"),
+	})
+	ads := s.ConnectADS()
+	ads.RequestResponseAck(&discovery.DiscoveryRequest{TypeUrl: v3.ClusterType})
+	ads.RequestResponseAck(&discovery.DiscoveryRequest{TypeUrl: v3.ListenerType})
+	ads.RequestResponseAck(&discovery.DiscoveryRequest{
+		TypeUrl:       v3.RouteType,
+		ResourceNames: []string{"80", "8080"},
+	})
+
+	snapshot, err := s.Discovery.Snapshot("test.default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshot.Proxies) != 1 {
+		t.Fatalf("expected a snapshot of 1 proxy, got %d", len(snapshot.Proxies))
+	}
+	// The snapshot is replayed from its serialized form, as written to disk by /debug/snapshotz.
+	b, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed := &xds.Snapshot{}
+	if err := json.Unmarshal(b, replayed); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := xds.Replay(replayed, "test.default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Identical {
+		t.Errorf("replay generated a configuration different from the one of the snapshot")
+	}
+	if _, err := xds.Replay(replayed, "not-found"); err == nil {
+		t.Errorf("expected an error replaying a proxy not in the snapshot")
+	}
+}
+
 func getConfigDump(t *testing.T, s *xds.DiscoveryServer, proxyID string, wantCode int) *configdump.Wrapper {
 	path := "/config_dump"
 	if proxyID != "" {
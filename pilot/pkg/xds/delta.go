@@ -0,0 +1,496 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"time"
+
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+// DeltaDiscoveryStream is a server interface for incremental (delta) XDS.
+type DeltaDiscoveryStream = discovery.AggregatedDiscoveryService_DeltaAggregatedResourcesServer
+
+// DeltaDiscoveryClient is a client interface for incremental (delta) XDS.
+type DeltaDiscoveryClient = discovery.AggregatedDiscoveryService_DeltaAggregatedResourcesClient
+
+// DeltaAggregatedResources implements the incremental variant of the ADS interface.
+//
+// When a push is caused by some configs and the generator supports it, only the resources affected by
+// these configs are generated, and the generator names the ones they removed: for example a ServiceEntry
+// change only builds and sends the clusters of its service. Otherwise configuration is generated exactly as
+// for StreamAggregatedResources, and the connection compares it with a version (hash) of every resource it
+// sent, to only send resources that were added or changed along with the names of those no longer existing.
+func (s *DiscoveryServer) DeltaAggregatedResources(stream DeltaDiscoveryStream) error {
+	return s.StreamDeltas(stream)
+}
+
+func (s *DiscoveryServer) StreamDeltas(stream DeltaDiscoveryStream) error {
+	// Check if server is ready to accept clients and process new requests. See Stream for details.
+	if !s.IsServerReady() {
+		return errors.New("server is not ready to serve discovery information")
+	}
+
+	ctx := stream.Context()
+	peerAddr := "0.0.0.0"
+	if peerInfo, ok := peer.FromContext(ctx); ok {
+		peerAddr = peerInfo.Addr.String()
+	}
+
+	ids, err := s.authenticate(ctx)
+	if err != nil {
+		return err
+	}
+	if ids != nil {
+		adsLog.Debugf("Authenticated XDS: %v with identity %v", peerAddr, ids)
+	} else {
+		adsLog.Debug("Unauthenticated XDS: ", peerAddr)
+	}
+
+	// InitContext returns immediately if the context was already initialized.
+	if err = s.globalPushContext().InitContext(s.Env, nil, nil); err != nil {
+		adsLog.Warnf("Error reading config %v", err)
+		return err
+	}
+	con := newDeltaConnection(peerAddr, stream)
+	con.Identities = ids
+	con.peerCert = peerCertificate(ctx)
+
+	var receiveError error
+	reqChannel := make(chan *discovery.DeltaDiscoveryRequest, 1)
+	go s.receiveDelta(con, reqChannel, &receiveError)
+
+	// Wait for the proxy to be fully initialized before we start serving traffic.
+	<-con.initialized
+
+	for {
+		select {
+		case req, ok := <-reqChannel:
+			if !ok {
+				// Remote side closed connection or error processing the request.
+				return receiveError
+			}
+			if err := s.processDeltaRequest(req, con); err != nil {
+				return err
+			}
+		case pushEv := <-con.pushChannel:
+			err := s.pushConnection(con, pushEv)
+			pushEv.done()
+			if err != nil {
+				return err
+			}
+		case <-con.stop:
+			return nil
+		}
+	}
+}
+
+func newDeltaConnection(peerAddr string, stream DeltaDiscoveryStream) *Connection {
+	return &Connection{
+		pushChannel:   make(chan *Event),
+		initialized:   make(chan struct{}),
+		stop:          make(chan struct{}),
+		PeerAddr:      peerAddr,
+		Connect:       time.Now(),
+		deltaStream:   stream,
+		blockedPushes: map[string]*model.PushRequest{},
+		warmingPushes: map[string]*model.PushRequest{},
+		sentResources: map[string]map[string]string{},
+	}
+}
+
+func (s *DiscoveryServer) receiveDelta(con *Connection, reqChannel chan *discovery.DeltaDiscoveryRequest, errP *error) {
+	defer func() {
+		close(reqChannel)
+		// Close the initialized channel, if its not already closed, to prevent blocking the stream
+		select {
+		case <-con.initialized:
+		default:
+			close(con.initialized)
+		}
+	}()
+	firstReq := true
+	for {
+		req, err := con.deltaStream.Recv()
+		if err != nil {
+			if isExpectedGRPCError(err) {
+				adsLog.Infof("ADS: %q %s terminated %v", con.PeerAddr, con.ConID, err)
+				return
+			}
+			*errP = err
+			adsLog.Errorf("ADS: %q %s terminated with error: %v", con.PeerAddr, con.ConID, err)
+			totalXDSInternalErrors.Increment()
+			return
+		}
+		// This should be only set for the first request. The node id may not be set - for example malicious clients.
+		if firstReq {
+			firstReq = false
+			if req.Node == nil || req.Node.Id == "" {
+				*errP = errors.New("missing node ID")
+				return
+			}
+			if err := s.initConnection(req.Node, con); err != nil {
+				*errP = err
+				return
+			}
+			adsLog.Infof("ADS: new delta connection for node:%s", con.ConID)
+			defer func() {
+				s.removeCon(con.ConID)
+				if s.StatusGen != nil {
+					s.StatusGen.OnDisconnect(con)
+				}
+				s.WorkloadEntryController.QueueUnregisterWorkload(con.proxy, con.Connect)
+			}()
+		}
+
+		select {
+		case reqChannel <- req:
+		case <-con.deltaStream.Context().Done():
+			adsLog.Infof("ADS: %q %s terminated with stream closed", con.PeerAddr, con.ConID)
+			return
+		}
+	}
+}
+
+// processDeltaRequest is the delta counterpart of processRequest.
+func (s *DiscoveryServer) processDeltaRequest(req *discovery.DeltaDiscoveryRequest, con *Connection) error {
+	if !s.preProcessRequest(con.proxy, &discovery.DiscoveryRequest{TypeUrl: req.TypeUrl, ErrorDetail: req.ErrorDetail}) {
+		return nil
+	}
+
+	if s.StatusReporter != nil {
+		s.StatusReporter.RegisterEvent(con.ConID, req.TypeUrl, req.ResponseNonce)
+	}
+	shouldRespond := s.shouldRespondDelta(con, req)
+
+	con.proxy.Lock()
+	request, haveBlockedPush := con.blockedPushes[req.TypeUrl]
+	delete(con.blockedPushes, req.TypeUrl)
+	con.proxy.Unlock()
+
+	if shouldRespond {
+		request = &model.PushRequest{Full: true}
+	} else if !haveBlockedPush {
+		return s.pushWarmed(con)
+	} else {
+		adsLog.Debugf("%s: DEQUEUE for node:%s", v3.GetShortType(req.TypeUrl), con.proxy.ID)
+	}
+
+	push := s.globalPushContext()
+
+	return s.pushXds(con, push, versionInfo(), con.Watched(req.TypeUrl), request)
+}
+
+// shouldRespondDelta applies the ack/nack rules of the incremental protocol, and updates the set of
+// subscribed resources from the request. It returns true if a response is needed.
+func (s *DiscoveryServer) shouldRespondDelta(con *Connection, req *discovery.DeltaDiscoveryRequest) bool {
+	stype := v3.GetShortType(req.TypeUrl)
+
+	if req.ErrorDetail != nil {
+		errCode := codes.Code(req.ErrorDetail.Code)
+		adsLog.Warnf("ADS:%s: ACK ERROR %s %s:%s", stype, con.ConID, errCode.String(), req.ErrorDetail.GetMessage())
+		incrementXDSRejects(req.TypeUrl, con.proxy.ID, errCode.String())
+		con.proxy.Lock()
+		if w := con.proxy.WatchedResources[req.TypeUrl]; w != nil {
+			recordNack(w, req.ResponseNonce, req.ErrorDetail.GetMessage())
+		}
+		con.proxy.Unlock()
+		// The client kept its previous version of the rejected resources; forget the versions we sent so
+		// they are resent on the next push, but keep their names so their removals are still sent.
+		for name := range con.sentResources[req.TypeUrl] {
+			con.sentResources[req.TypeUrl][name] = ""
+		}
+		return false
+	}
+
+	con.proxy.Lock()
+	defer con.proxy.Unlock()
+	w := con.proxy.WatchedResources[req.TypeUrl]
+	if w == nil {
+		adsLog.Debugf("ADS:%s: INIT %s %s", stype, con.ConID, req.ResponseNonce)
+		w = &model.WatchedResource{TypeUrl: req.TypeUrl}
+		con.proxy.WatchedResources[req.TypeUrl] = w
+		// On reconnect the client tells us what it already has; avoid resending unchanged resources.
+		if len(req.InitialResourceVersions) > 0 {
+			sent := make(map[string]string, len(req.InitialResourceVersions))
+			for name, version := range req.InitialResourceVersions {
+				sent[name] = version
+			}
+			con.sentResources[req.TypeUrl] = sent
+		}
+		w.ResourceNames = deltaResourceNames(nil, req.ResourceNamesSubscribe, req.ResourceNamesUnsubscribe)
+		// VHDS subscriptions start empty and grow as Envoy sees new hosts.
+		if len(w.ResourceNames) == 0 && !isWildcardTypeURL(req.TypeUrl) && req.TypeUrl != v3.VirtualHostType {
+			delete(con.proxy.WatchedResources, req.TypeUrl)
+			return false
+		}
+		return true
+	}
+
+	subscriptionChanged := len(req.ResourceNamesSubscribe) > 0 || len(req.ResourceNamesUnsubscribe) > 0
+	if subscriptionChanged {
+		w.ResourceNames = deltaResourceNames(w.ResourceNames, req.ResourceNamesSubscribe, req.ResourceNamesUnsubscribe)
+		if req.TypeUrl == v3.VirtualHostType {
+			w.ResourceNames = evictOnDemandHosts(w.ResourceNames)
+		}
+		for _, name := range req.ResourceNamesUnsubscribe {
+			delete(con.sentResources[req.TypeUrl], name)
+		}
+		if len(w.ResourceNames) == 0 && !isWildcardTypeURL(req.TypeUrl) {
+			adsLog.Debugf("ADS:%s: UNSUBSCRIBE %s %s", stype, con.ConID, req.ResponseNonce)
+			delete(con.proxy.WatchedResources, req.TypeUrl)
+			delete(con.sentResources, req.TypeUrl)
+			return false
+		}
+	}
+
+	if req.ResponseNonce != "" {
+		if req.ResponseNonce != w.NonceSent {
+			adsLog.Debugf("ADS:%s: REQ %s Expired nonce received %s, sent %s", stype,
+				con.ConID, req.ResponseNonce, w.NonceSent)
+			xdsExpiredNonce.With(typeTag.Value(v3.GetMetricType(req.TypeUrl))).Increment()
+			clearNack(w)
+			return subscriptionChanged
+		}
+		w.VersionAcked = w.VersionSent
+		w.NonceAcked = req.ResponseNonce
+		clearNack(w)
+	}
+	if !subscriptionChanged {
+		adsLog.Debugf("ADS:%s: ACK %s %s", stype, con.ConID, req.ResponseNonce)
+		return false
+	}
+	adsLog.Debugf("ADS:%s: RESOURCE CHANGE subscribe: %v unsubscribe: %v %s %s", stype,
+		req.ResourceNamesSubscribe, req.ResourceNamesUnsubscribe, con.ConID, req.ResponseNonce)
+	return true
+}
+
+// deltaResourceNames applies subscribe and unsubscribe to the current list of resource names. Names
+// are kept in the order they were first subscribed to.
+func deltaResourceNames(current, subscribe, unsubscribe []string) []string {
+	removed := make(map[string]struct{}, len(unsubscribe))
+	for _, n := range unsubscribe {
+		removed[n] = struct{}{}
+	}
+	seen := make(map[string]struct{}, len(current)+len(subscribe))
+	var res []string
+	for _, names := range [][]string{current, subscribe} {
+		for _, n := range names {
+			if _, f := removed[n]; f {
+				continue
+			}
+			if _, f := seen[n]; f {
+				continue
+			}
+			seen[n] = struct{}{}
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+// pushDeltaXds generates the resources for the watched type and sends only those that changed
+// since the last response on this connection.
+func (s *DiscoveryServer) pushDeltaXds(con *Connection, push *model.PushContext,
+	currentVersion string, w *model.WatchedResource, req *model.PushRequest) error {
+	gen := s.findGenerator(w.TypeUrl, con)
+	if gen == nil {
+		return nil
+	}
+
+	t0 := time.Now()
+
+	sent := con.sentResources[w.TypeUrl]
+	var res model.Resources
+	var removed []string
+	// A full push caused by some configs only generates the resources affected by them, when the generator
+	// supports it. The resources rejected by the client must all be resent, so they prevent scoping.
+	scoped := false
+	if dgen, ok := gen.(model.XdsDeltaResourceGenerator); ok && req.Full && len(req.ConfigsUpdated) > 0 {
+		if names, complete := sentResourceNames(sent); complete {
+			res, removed, scoped = dgen.GenerateDeltas(con.proxy, push, req, w, names)
+		}
+	}
+	if !scoped {
+		var err error
+		res, err = gen.Generate(con.proxy, push, w, req)
+		if err != nil || res == nil {
+			if s.StatusReporter != nil {
+				s.StatusReporter.RegisterEvent(con.ConID, w.TypeUrl, push.LedgerVersion)
+			}
+			return err
+		}
+	}
+	defer func() { recordPushTime(w.TypeUrl, time.Since(t0)) }()
+
+	current := make(map[string]string, len(sent)+len(res))
+	resources := make([]*discovery.Resource, 0, len(res))
+	deltaSize := 0
+	for _, r := range deltaResources(w, res) {
+		current[r.Name] = r.Version
+		// The resources of a scoped push are those affected by the updated configs, so they are all sent.
+		if !scoped && sent[r.Name] == r.Version {
+			continue
+		}
+		resources = append(resources, r)
+		if r.Resource != nil {
+			deltaSize += len(r.Resource.Value)
+		}
+	}
+	switch {
+	case scoped:
+		// The generator named the resources removed by the updated configs; the others are unchanged.
+		for name, version := range sent {
+			if _, f := current[name]; !f {
+				current[name] = version
+			}
+		}
+		for _, name := range removed {
+			delete(current, name)
+		}
+		sort.Strings(removed)
+	case req.Full:
+		// A full push generates every watched resource, so anything missing from it has been removed.
+		for name := range sent {
+			if _, f := current[name]; !f {
+				removed = append(removed, name)
+			}
+		}
+		sort.Strings(removed)
+	default:
+		// An incremental push (for example EDS) covers just the updated resources, so anything missing
+		// from it has not been removed.
+		for name, version := range sent {
+			if _, f := current[name]; !f {
+				current[name] = version
+			}
+		}
+	}
+
+	fullSize := ResourceSize(res)
+	recordDeltaPushSize(w.TypeUrl, deltaSize, fullSize)
+	// The resources generated for a scoped push are not the full config.
+	if !scoped {
+		recordConfigSize(con, w.TypeUrl, fullSize)
+	}
+
+	// Nothing changed. We still answer the first request for a type, so the client can complete
+	// initialization even if there are no resources.
+	if len(resources) == 0 && len(removed) == 0 && w.NonceSent != "" {
+		if s.StatusReporter != nil {
+			s.StatusReporter.RegisterEvent(con.ConID, w.TypeUrl, push.LedgerVersion)
+		}
+		return nil
+	}
+
+	resp := &discovery.DeltaDiscoveryResponse{
+		TypeUrl:           w.TypeUrl,
+		SystemVersionInfo: currentVersion,
+		Nonce:             nonce(push.LedgerVersion),
+		Resources:         resources,
+		RemovedResources:  removed,
+	}
+	if err := con.sendDelta(resp, deltaSize); err != nil {
+		recordSendError(w.TypeUrl, con.ConID, err)
+		return err
+	}
+	con.sentResources[w.TypeUrl] = current
+
+	if _, f := SkipLogTypes[w.TypeUrl]; !f {
+		adsLog.Infof("%s: DELTA PUSH for node:%s resources:%d removed:%d size:%s (full:%s)",
+			v3.GetShortType(w.TypeUrl), con.proxy.ID, len(resources), len(removed),
+			util.ByteCount(deltaSize), util.ByteCount(fullSize))
+	}
+	return nil
+}
+
+func (conn *Connection) sendDelta(res *discovery.DeltaDiscoveryResponse, sz int) error {
+	return conn.sendWithTimeout(func() error { return conn.deltaStream.Send(res) }, res.TypeUrl, res.Nonce, res.SystemVersionInfo, sz)
+}
+
+// sentResourceNames returns the sorted names of the sent resources, and false if some of them must be resent
+// because the client rejected them.
+func sentResourceNames(sent map[string]string) ([]string, bool) {
+	names := make([]string, 0, len(sent))
+	for name, version := range sent {
+		if version == "" {
+			return nil, false
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, true
+}
+
+// deltaResources names and versions the generated resources.
+func deltaResources(w *model.WatchedResource, res model.Resources) []*discovery.Resource {
+	if w.TypeUrl == v3.VirtualHostType {
+		return vhdsResources(res, w.ResourceNames)
+	}
+	resources := make([]*discovery.Resource, 0, len(res))
+	for _, r := range res {
+		name, err := resourceName(r)
+		if err != nil {
+			adsLog.Warnf("%s: failed to read resource name: %v", v3.GetShortType(w.TypeUrl), err)
+			continue
+		}
+		resources = append(resources, &discovery.Resource{Name: name, Version: resourceVersion(r), Resource: r})
+	}
+	return resources
+}
+
+// resourceName returns the name Envoy uses to identify the resource.
+func resourceName(r *any.Any) (string, error) {
+	msg, err := ptypes.Empty(r)
+	if err != nil {
+		return "", err
+	}
+	if err := ptypes.UnmarshalAny(r, msg); err != nil {
+		return "", err
+	}
+	switch m := msg.(type) {
+	case *endpoint.ClusterLoadAssignment:
+		return m.ClusterName, nil
+	case interface{ GetName() string }:
+		return m.GetName(), nil
+	}
+	return "", fmt.Errorf("unnamed resource type %s", r.TypeUrl)
+}
+
+// resourceVersion returns a version for the resource derived from its content, and any extra values
+// that are sent along with it. Resources are marshaled deterministically, so the same configuration
+// always gets the same version.
+func resourceVersion(r *any.Any, extra ...string) string {
+	h := fnv.New64a()
+	_, _ = h.Write(r.Value)
+	for _, e := range extra {
+		_, _ = h.Write([]byte(e))
+	}
+	return strconv.FormatUint(h.Sum64(), 16)
+}
@@ -0,0 +1,215 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds_test
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+func connectDelta(t *testing.T, s *xds.FakeDiscoveryServer) xds.DeltaDiscoveryClient {
+	t.Helper()
+	conn, err := grpc.Dial("buffcon", grpc.WithInsecure(), grpc.WithBlock(), grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return s.Listener.Dial()
+	}))
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		_ = conn.Close()
+	})
+	client, err := discovery.NewAggregatedDiscoveryServiceClient(conn).DeltaAggregatedResources(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func expectDeltaResponse(t *testing.T, client xds.DeltaDiscoveryClient) *discovery.DeltaDiscoveryResponse {
+	t.Helper()
+	type result struct {
+		resp *discovery.DeltaDiscoveryResponse
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		resp, err := client.Recv()
+		ch <- result{resp, err}
+	}()
+	select {
+	case <-time.After(time.Second * 5):
+		t.Fatalf("did not get response in time")
+	case r := <-ch:
+		if r.err != nil {
+			t.Fatalf("got error: %v", r.err)
+		}
+		return r.resp
+	}
+	return nil
+}
+
+func TestDeltaCDS(t *testing.T) {
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{})
+	client := connectDelta(t, s)
+
+	const hostname = "svc.delta.example.com"
+	updateService := func(add bool) {
+		if add {
+			s.Discovery.MemRegistry.AddService(hostname, &model.Service{
+				Hostname: hostname,
+				Address:  "10.11.0.1",
+				Ports: []*model.Port{
+					{
+						Name:     "http-main",
+						Port:     2080,
+						Protocol: protocol.HTTP,
+					},
+				},
+				Attributes: model.ServiceAttributes{
+					Namespace: "default",
+				},
+			})
+		} else {
+			s.Discovery.MemRegistry.RemoveService(hostname)
+		}
+		// The AuthorizationPolicy does not affect clusters, so it does not prevent scoping the push.
+		s.Discovery.ConfigUpdate(&model.PushRequest{Full: true, ConfigsUpdated: map[model.ConfigKey]struct{}{
+			{Kind: gvk.ServiceEntry, Name: hostname, Namespace: "default"}:        {},
+			{Kind: gvk.AuthorizationPolicy, Name: "policy", Namespace: "default"}: {},
+		}})
+	}
+	ack := func(resp *discovery.DeltaDiscoveryResponse) {
+		if err := client.Send(&discovery.DeltaDiscoveryRequest{TypeUrl: v3.ClusterType, ResponseNonce: resp.Nonce}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := client.Send(&discovery.DeltaDiscoveryRequest{
+		Node:    &core.Node{Id: "sidecar~1.1.1.1~test.default~default.svc.cluster.local", Metadata: model.NodeMetadata{}.ToStruct()},
+		TypeUrl: v3.ClusterType,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	initial := expectDeltaResponse(t, client)
+	if len(initial.Resources) == 0 || len(initial.RemovedResources) != 0 {
+		t.Fatalf("expected initial clusters, got %v resources, %v removed", len(initial.Resources), initial.RemovedResources)
+	}
+	ack(initial)
+
+	// Only the clusters of the new service are sent.
+	updateService(true)
+	added := expectDeltaResponse(t, client)
+	if len(added.Resources) == 0 {
+		t.Fatalf("expected clusters of the new service")
+	}
+	for _, r := range added.Resources {
+		if !strings.Contains(r.Name, hostname) {
+			t.Errorf("unexpected cluster %v in delta push", r.Name)
+		}
+	}
+	ack(added)
+
+	// Removing the service only sends the names of its clusters.
+	updateService(false)
+	removed := expectDeltaResponse(t, client)
+	if len(removed.Resources) != 0 {
+		t.Errorf("expected no clusters, got %v", len(removed.Resources))
+	}
+	if len(removed.RemovedResources) != len(added.Resources) {
+		t.Errorf("expected %v removed clusters, got %v", len(added.Resources), removed.RemovedResources)
+	}
+	for _, name := range removed.RemovedResources {
+		if !strings.Contains(name, hostname) {
+			t.Errorf("unexpected removed cluster %v", name)
+		}
+	}
+	ack(removed)
+
+	// The clusters of an updated service are sent even if unchanged.
+	updateService(true)
+	ack(expectDeltaResponse(t, client))
+	updateService(true)
+	unchanged := expectDeltaResponse(t, client)
+	if len(unchanged.Resources) != len(added.Resources) || len(unchanged.RemovedResources) != 0 {
+		t.Errorf("expected %v clusters, got %v resources, %v removed", len(added.Resources), len(unchanged.Resources),
+			unchanged.RemovedResources)
+	}
+	updateService(false)
+	ack(expectDeltaResponse(t, client))
+
+	// Rejected clusters are all resent, and the clusters of removed services are still removed.
+	updateService(true)
+	readded := expectDeltaResponse(t, client)
+	if err := client.Send(&discovery.DeltaDiscoveryRequest{
+		TypeUrl:       v3.ClusterType,
+		ResponseNonce: readded.Nonce,
+		ErrorDetail:   &status.Status{Message: "Test request NACK"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	updateService(false)
+	resent := expectDeltaResponse(t, client)
+	if len(resent.Resources) != len(initial.Resources) {
+		t.Errorf("expected %v resent clusters, got %v", len(initial.Resources), len(resent.Resources))
+	}
+	if len(resent.RemovedResources) != len(readded.Resources) {
+		t.Errorf("expected %v removed clusters, got %v", len(readded.Resources), resent.RemovedResources)
+	}
+}
+
+func TestDeltaResourceVersionsOnReconnect(t *testing.T) {
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{})
+	node := &core.Node{Id: "sidecar~1.1.1.1~test.default~default.svc.cluster.local", Metadata: model.NodeMetadata{}.ToStruct()}
+
+	client := connectDelta(t, s)
+	if err := client.Send(&discovery.DeltaDiscoveryRequest{Node: node, TypeUrl: v3.ClusterType}); err != nil {
+		t.Fatal(err)
+	}
+	initial := expectDeltaResponse(t, client)
+	versions := map[string]string{}
+	for _, r := range initial.Resources {
+		versions[r.Name] = r.Version
+	}
+
+	// A client that already has every resource gets an empty response.
+	reconnected := connectDelta(t, s)
+	if err := reconnected.Send(&discovery.DeltaDiscoveryRequest{
+		Node:                    node,
+		TypeUrl:                 v3.ClusterType,
+		InitialResourceVersions: versions,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	resp := expectDeltaResponse(t, reconnected)
+	if len(resp.Resources) != 0 || len(resp.RemovedResources) != 0 {
+		t.Fatalf("expected no changes, got %v resources, %v removed", len(resp.Resources), resp.RemovedResources)
+	}
+}
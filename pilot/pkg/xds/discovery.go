@@ -21,9 +21,12 @@ import (
 
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/atomic"
 	"google.golang.org/grpc"
 
+	"istio.io/istio/pilot/pkg/certexpiry"
 	"istio.io/istio/pilot/pkg/controller/workloadentry"
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
@@ -33,9 +36,11 @@ import (
 	"istio.io/istio/pilot/pkg/serviceregistry"
 	"istio.io/istio/pilot/pkg/serviceregistry/aggregate"
 	"istio.io/istio/pilot/pkg/serviceregistry/memory"
+	"istio.io/istio/pilot/pkg/util/intern"
 	"istio.io/istio/pilot/pkg/util/sets"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/spiffe"
 )
 
 var (
@@ -64,6 +69,10 @@ type debounceOptions struct {
 
 	// enableEDSDebounce indicates whether EDS pushes should be debounced.
 	enableEDSDebounce bool
+
+	// kindWindows overrides debounceAfter and debounceMax for the events of some config kinds, keyed by kind,
+	// or by endpointsDebounceKind for the endpoint events.
+	kindWindows map[string]debounceWindow
 }
 
 // DiscoveryServer is Pilot's gRPC implementation for Envoy's xds APIs
@@ -127,6 +136,17 @@ type DiscoveryServer struct {
 	// Authenticators for XDS requests. Should be same/subset of the CA authenticators.
 	Authenticators []security.Authenticator
 
+	// ProxyShards assigns the proxies to the istiod replicas, when proxy sharding is enabled. Proxies assigned to
+	// other replicas are redirected to them.
+	ProxyShards *ProxyShards
+
+	// SpiffeBundles refreshes the SPIFFE bundles of the federated trust domains, if any.
+	SpiffeBundles *spiffe.BundleRefresher
+
+	// CertExpiry tracks the expiration of the workload certificates of the connected proxies, and of the
+	// gateway certificates served through SDS.
+	CertExpiry *certexpiry.Tracker
+
 	// StatusGen is notified of connect/disconnect/nack on all connections
 	StatusGen               *StatusGen
 	WorkloadEntryController *workloadentry.Controller
@@ -154,6 +174,10 @@ type EndpointShards struct {
 	// name of the k8s cluster, derived from the config (secret).
 	Shards map[string][]*model.IstioEndpoint
 
+	// index groups the endpoints of each shard by service port name, then by locality and network.
+	// It is rebuilt with the shard, so that EDS generation only visits the endpoints of the cluster port.
+	index map[string]map[string][]*localityShard
+
 	// ServiceAccounts has the concatenation of all service accounts seen so far in endpoints.
 	// This is updated on push, based on shards. If the previous list is different than
 	// current list, a full push will be forced, to trigger a secure naming update.
@@ -180,9 +204,14 @@ func NewDiscoveryServer(env *model.Environment, plugins []string, instanceID str
 			debounceAfter:     features.DebounceAfter,
 			debounceMax:       features.DebounceMax,
 			enableEDSDebounce: features.EnableEDSDebounce.Get(),
+			kindWindows:       kindDebounceWindows(features.DebounceByKind),
 		},
 		Cache:      model.DisabledCache{},
 		instanceID: instanceID,
+		CertExpiry: certexpiry.NewTracker(map[certexpiry.Kind]time.Duration{
+			certexpiry.Workload: features.WorkloadCertExpiryWarningWindow,
+			certexpiry.Gateway:  features.GatewayCertExpiryWarningWindow,
+		}),
 	}
 
 	// Flush cached discovery responses when detecting jwt public key change.
@@ -224,6 +253,7 @@ func (s *DiscoveryServer) Start(stopCh <-chan struct{}) {
 	go s.handleUpdates(stopCh)
 	go s.periodicRefreshMetrics(stopCh)
 	go s.sendPushes(stopCh)
+	go s.CertExpiry.Run(stopCh)
 }
 
 func (s *DiscoveryServer) getNonK8sRegistries() []serviceregistry.Instance {
@@ -275,6 +305,11 @@ func (s *DiscoveryServer) periodicRefreshMetrics(stopCh <-chan struct{}) {
 // Push is called to push changes on config updates using ADS. This is set in DiscoveryService.Push,
 // to avoid direct dependencies.
 func (s *DiscoveryServer) Push(req *model.PushRequest) {
+	span := model.StartSpan(req.Span, "push", req.TriggerLinks(),
+		trace.WithAttributes(attribute.Bool("push.full", req.Full)))
+	defer span.End()
+	req.Span = span.SpanContext()
+
 	if !req.Full {
 		req.Push = s.globalPushContext()
 		s.AdsPushAll(versionInfo(), req)
@@ -290,7 +325,10 @@ func (s *DiscoveryServer) Push(req *model.PushRequest) {
 	t0 := time.Now()
 
 	versionLocal := time.Now().Format(time.RFC3339) + "/" + strconv.FormatUint(versionNum.Inc(), 10)
+	initSpan := model.StartSpan(req.Span, "init push context",
+		trace.WithAttributes(attribute.String("push.version", versionLocal)))
 	push, err := s.initPushContext(req, oldPushContext, versionLocal)
+	endSpan(initSpan, err)
 	if err != nil {
 		return
 	}
@@ -328,6 +366,7 @@ func (s *DiscoveryServer) globalPushContext() *model.PushContext {
 func (s *DiscoveryServer) ConfigUpdate(req *model.PushRequest) {
 	inboundConfigUpdates.Increment()
 	s.InboundUpdates.Inc()
+	req.TraceUpdate()
 	s.pushChannel <- req
 }
 
@@ -345,6 +384,8 @@ func debounce(ch chan *model.PushRequest, stopCh <-chan struct{}, opts debounceO
 	var timeChan <-chan time.Time
 	var startDebounce time.Time
 	var lastConfigUpdateTime time.Time
+	// The debounce window of the merged events, the shortest of the windows of their kinds.
+	var window debounceWindow
 
 	pushCounter := 0
 	debouncedEvents := 0
@@ -365,7 +406,7 @@ func debounce(ch chan *model.PushRequest, stopCh <-chan struct{}, opts debounceO
 		eventDelay := time.Since(startDebounce)
 		quietTime := time.Since(lastConfigUpdateTime)
 		// it has been too long or quiet enough
-		if eventDelay >= opts.debounceMax || quietTime >= opts.debounceAfter {
+		if eventDelay >= window.max || quietTime >= window.after {
 			if req != nil {
 				pushCounter++
 				adsLog.Infof("Push debounce stable[%d] %d: %v since last change, %v since last push, full=%v",
@@ -373,12 +414,13 @@ func debounce(ch chan *model.PushRequest, stopCh <-chan struct{}, opts debounceO
 					quietTime, eventDelay, req.Full)
 
 				free = false
+				req.Span = traceDebounce(req, startDebounce, debouncedEvents)
 				go push(req, debouncedEvents)
 				req = nil
 				debouncedEvents = 0
 			}
 		} else {
-			timeChan = time.After(opts.debounceAfter - quietTime)
+			timeChan = time.After(window.after - quietTime)
 		}
 	}
 
@@ -399,9 +441,15 @@ func debounce(ch chan *model.PushRequest, stopCh <-chan struct{}, opts debounceO
 			}
 
 			lastConfigUpdateTime = time.Now()
+			w := opts.window(r)
 			if debouncedEvents == 0 {
-				timeChan = time.After(opts.debounceAfter)
+				window = w
+				timeChan = time.After(window.after)
 				startDebounce = lastConfigUpdateTime
+			} else if w.after < window.after || w.max < window.max {
+				// The event shortens the window of the events it is merged with.
+				window = window.min(w)
+				timeChan = time.After(window.after)
 			}
 			debouncedEvents++
 
@@ -449,7 +497,7 @@ func doSendPushes(stopCh <-chan struct{}, semaphore chan struct{}, queue *PushQu
 				select {
 				case client.pushChannel <- pushEv:
 					return
-				case <-client.stream.Context().Done(): // grpc stream was closed
+				case <-client.streamContext().Done(): // grpc stream was closed
 					doneFunc()
 					adsLog.Infof("Client closed connection %v", client.ConID)
 				}
@@ -480,6 +528,10 @@ func (s *DiscoveryServer) initPushContext(req *model.PushRequest, oldPushContext
 	s.Env.PushContext = push
 	s.updateMutex.Unlock()
 
+	// Values of the removed workloads are released from the intern table once the new push context no longer
+	// references them.
+	intern.Default.Rotate()
+
 	return push, nil
 }
 
@@ -494,6 +546,7 @@ func (s *DiscoveryServer) initGenerators() {
 	s.Generators[v3.ClusterType] = &CdsGenerator{Server: s}
 	s.Generators[v3.ListenerType] = &LdsGenerator{Server: s}
 	s.Generators[v3.RouteType] = &RdsGenerator{Server: s}
+	s.Generators[v3.VirtualHostType] = &VhdsGenerator{Server: s}
 	s.Generators[v3.EndpointType] = edsGen
 	s.Generators[v3.NameTableType] = &NdsGenerator{Server: s}
 	s.Generators[v3.ExtensionConfigurationType] = &EcdsGenerator{Server: s}
@@ -558,6 +611,10 @@ func (s *DiscoveryServer) SendResponse(connections []*Connection, res *discovery
 		// plus few admin tools or bridges to real message brokers. The normal
 		// push expects 1000s of envoy connections.
 		con := p
+		if con.stream == nil {
+			// Internal events are only sent on state of the world streams.
+			continue
+		}
 		go func() {
 			err := con.stream.Send(res)
 			if err != nil {
@@ -29,6 +29,7 @@ import (
 
 	"istio.io/istio/pilot/pkg/model"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/istio/pkg/test/util/retry"
 	"istio.io/istio/tests/util/leak"
 )
@@ -330,6 +331,120 @@ func TestDebounce(t *testing.T) {
 	}
 }
 
+func TestParseDebounceWindows(t *testing.T) {
+	cases := []struct {
+		value   string
+		want    map[string]debounceWindow
+		wantErr bool
+	}{
+		{value: "", want: nil},
+		{
+			value: "Endpoints=5ms, EnvoyFilter=1s/30s",
+			want: map[string]debounceWindow{
+				"Endpoints":   {after: 5 * time.Millisecond, max: 10 * time.Second},
+				"EnvoyFilter": {after: time.Second, max: 30 * time.Second},
+			},
+		},
+		{value: "Sidecar=20s", want: map[string]debounceWindow{"Sidecar": {after: 20 * time.Second, max: 20 * time.Second}}},
+		{value: "Sidecar", wantErr: true},
+		{value: "Sidecar=soon", wantErr: true},
+		{value: "Sidecar=1s/100ms", wantErr: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseDebounceWindows(tt.value, 10*time.Second)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDebounceWindow(t *testing.T) {
+	opts := debounceOptions{
+		debounceAfter: 100 * time.Millisecond,
+		debounceMax:   10 * time.Second,
+		kindWindows: map[string]debounceWindow{
+			endpointsDebounceKind: {after: 0, max: 0},
+			gvk.EnvoyFilter.Kind:  {after: time.Second, max: 30 * time.Second},
+		},
+	}
+	def := debounceWindow{after: opts.debounceAfter, max: opts.debounceMax}
+	envoyFilter := model.ConfigKey{Kind: gvk.EnvoyFilter, Name: "filter", Namespace: "default"}
+	sidecar := model.ConfigKey{Kind: gvk.Sidecar, Name: "sidecar", Namespace: "default"}
+	cases := []struct {
+		name string
+		req  *model.PushRequest
+		want debounceWindow
+	}{
+		{"endpoints", &model.PushRequest{Full: false}, debounceWindow{}},
+		{"full push", &model.PushRequest{Full: true}, def},
+		{"kind", &model.PushRequest{Full: true, ConfigsUpdated: map[model.ConfigKey]struct{}{envoyFilter: {}}}, opts.kindWindows[gvk.EnvoyFilter.Kind]},
+		{"default kind", &model.PushRequest{Full: true, ConfigsUpdated: map[model.ConfigKey]struct{}{sidecar: {}}}, def},
+		{
+			"shortest of kinds",
+			&model.PushRequest{Full: true, ConfigsUpdated: map[model.ConfigKey]struct{}{envoyFilter: {}, sidecar: {}}},
+			def,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := opts.window(tt.req); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDebounceByKind(t *testing.T) {
+	leak.Check(t)
+	opts := debounceOptions{
+		debounceAfter:     50 * time.Millisecond,
+		debounceMax:       100 * time.Millisecond,
+		enableEDSDebounce: true,
+		kindWindows: map[string]debounceWindow{
+			endpointsDebounceKind: {after: time.Millisecond, max: time.Millisecond},
+			gvk.EnvoyFilter.Kind:  {after: time.Minute, max: time.Minute},
+		},
+	}
+	stopCh := make(chan struct{})
+	updateCh := make(chan *model.PushRequest)
+	pushes := make(chan *model.PushRequest, 10)
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		debounce(updateCh, stopCh, opts, func(req *model.PushRequest) { pushes <- req }, uatomic.NewInt64(0))
+		wg.Done()
+	}()
+	defer func() {
+		close(stopCh)
+		wg.Wait()
+	}()
+
+	// The EnvoyFilter waits for a minute, unless an endpoint update is merged with it.
+	updateCh <- &model.PushRequest{
+		Full:           true,
+		ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.EnvoyFilter, Name: "filter", Namespace: "default"}: {}},
+	}
+	select {
+	case <-pushes:
+		t.Fatal("unexpected push before the EnvoyFilter debounce delay")
+	case <-time.After(opts.debounceMax * 2):
+	}
+	updateCh <- &model.PushRequest{Full: false}
+	select {
+	case req := <-pushes:
+		if !req.Full {
+			t.Errorf("expected the endpoint update to be merged with the EnvoyFilter update")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a push after the endpoints debounce delay")
+	}
+}
+
 func TestShouldRespond(t *testing.T) {
 	leak.Check(t)
 	tests := []struct {
@@ -482,3 +597,33 @@ func TestShouldRespond(t *testing.T) {
 		})
 	}
 }
+
+func TestIndexEndpoints(t *testing.T) {
+	endpoint := func(address, port, locality, network string) *model.IstioEndpoint {
+		return &model.IstioEndpoint{
+			Address:         address,
+			ServicePortName: port,
+			Locality:        model.Locality{Label: locality},
+			Network:         network,
+		}
+	}
+	a := endpoint("1.1.1.1", "http", "region/zone1", "network1")
+	b := endpoint("1.1.1.2", "http", "region/zone2", "network1")
+	c := endpoint("1.1.1.3", "http", "region/zone1", "network1")
+	d := endpoint("1.1.1.4", "http", "region/zone1", "network2")
+	e := endpoint("1.1.1.1", "grpc", "region/zone1", "network1")
+
+	want := map[string][]*localityShard{
+		"http": {
+			{locality: "region/zone1", network: "network1", endpoints: []*model.IstioEndpoint{a, c}},
+			{locality: "region/zone2", network: "network1", endpoints: []*model.IstioEndpoint{b}},
+			{locality: "region/zone1", network: "network2", endpoints: []*model.IstioEndpoint{d}},
+		},
+		"grpc": {
+			{locality: "region/zone1", network: "network1", endpoints: []*model.IstioEndpoint{e}},
+		},
+	}
+	if got := indexEndpoints([]*model.IstioEndpoint{a, b, c, d, e}); !reflect.DeepEqual(got, want) {
+		t.Errorf("indexEndpoints() = %v, want %v", got, want)
+	}
+}
@@ -15,6 +15,10 @@
 package xds
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pkg/config/schema/gvk"
@@ -59,8 +63,22 @@ func (e *EcdsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w
 	}
 
 	resources := make(model.Resources, 0, len(ec))
+	versions := make(map[string]string, len(ec))
 	for _, c := range ec {
-		resources = append(resources, util.MessageToAny(c))
+		resource := util.MessageToAny(c)
+		resources = append(resources, resource)
+		sum := sha256.Sum256(resource.GetValue())
+		versions[c.Name] = hex.EncodeToString(sum[:8])
+	}
+
+	// The extension configs are versioned each, so that a config change which doesn't change them, such as a
+	// change of another EnvoyFilter, doesn't send them again: the agent would fetch their Wasm modules again, and
+	// Envoy would apply them again. Requests of the proxy are always answered.
+	proxy.Lock()
+	defer proxy.Unlock()
+	if req != nil && len(req.ConfigsUpdated) > 0 && reflect.DeepEqual(versions, w.ResourceVersions) {
+		return nil, nil
 	}
+	w.ResourceVersions = versions
 	return resources, nil
 }
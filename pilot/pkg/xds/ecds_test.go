@@ -20,8 +20,10 @@ import (
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	"github.com/golang/protobuf/ptypes"
 
+	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/xds"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config/schema/gvk"
 )
 
 func TestECDS(t *testing.T) {
@@ -48,3 +50,28 @@ func TestECDS(t *testing.T) {
 		t.Errorf("extension config name got %v want %v", ec.Name, wantExtensionConfigName)
 	}
 }
+
+func TestECDSUnchangedExtensionConfigs(t *testing.T) {
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{
+		ConfigString: mustReadFile(t, "./testdata/ecds.yaml"),
+	})
+
+	ads := s.ConnectADS().WithType(v3.ExtensionConfigurationType)
+	ads.RequestResponseAck(&discovery.DiscoveryRequest{
+		Node: &corev3.Node{
+			Id: ads.ID,
+		},
+		ResourceNames: []string{"extension-config"},
+	})
+
+	// A change of another EnvoyFilter doesn't change the extension config, which is not sent again.
+	s.Discovery.ConfigUpdate(&model.PushRequest{
+		Full:           true,
+		ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.EnvoyFilter, Name: "other", Namespace: "default"}: {}},
+	})
+	ads.ExpectNoResponse()
+
+	// A full push of all the configs sends it again.
+	s.Discovery.ConfigUpdate(&model.PushRequest{Full: true})
+	ads.ExpectResponse()
+}
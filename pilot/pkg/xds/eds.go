@@ -26,6 +26,7 @@ import (
 	networking "istio.io/istio/pilot/pkg/networking/core/v1alpha3"
 	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/loadbalancer"
 	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/util/intern"
 	"istio.io/istio/pilot/pkg/util/sets"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pkg/config"
@@ -162,18 +163,44 @@ func (s *DiscoveryServer) edsCacheUpdate(clusterID, hostname string, namespace s
 		fullPush = true
 	}
 	ep.Shards[clusterID] = istioEndpoints
+	ep.index[clusterID] = indexEndpoints(istioEndpoints)
 	ep.ServiceAccounts = serviceAccounts
 	ep.mutex.Unlock()
 
 	return fullPush
 }
 
+// localityShard holds the endpoints of a shard sharing the same service port, locality and network.
+type localityShard struct {
+	locality  string
+	network   string
+	endpoints []*model.IstioEndpoint
+}
+
+// indexEndpoints groups the endpoints by service port name, then by locality and network. The order of the
+// endpoints is preserved within each group.
+func indexEndpoints(istioEndpoints []*model.IstioEndpoint) map[string][]*localityShard {
+	index := map[string][]*localityShard{}
+	groups := map[string]*localityShard{}
+	for _, ep := range istioEndpoints {
+		key := ep.ServicePortName + "~" + ep.Locality.Label + "~" + ep.Network
+		group, f := groups[key]
+		if !f {
+			group = &localityShard{locality: ep.Locality.Label, network: ep.Network}
+			groups[key] = group
+			index[ep.ServicePortName] = append(index[ep.ServicePortName], group)
+		}
+		group.endpoints = append(group.endpoints, ep)
+	}
+	return index
+}
+
 func (s *DiscoveryServer) getOrCreateEndpointShard(serviceName, namespace string) (*EndpointShards, bool) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
 	if _, exists := s.EndpointShardsByService[serviceName]; !exists {
-		s.EndpointShardsByService[serviceName] = map[string]*EndpointShards{}
+		s.EndpointShardsByService[intern.Default.String(serviceName)] = map[string]*EndpointShards{}
 	}
 	if ep, exists := s.EndpointShardsByService[serviceName][namespace]; exists {
 		return ep, false
@@ -181,9 +208,10 @@ func (s *DiscoveryServer) getOrCreateEndpointShard(serviceName, namespace string
 	// This endpoint is for a service that was not previously loaded.
 	ep := &EndpointShards{
 		Shards:          map[string][]*model.IstioEndpoint{},
+		index:           map[string]map[string][]*localityShard{},
 		ServiceAccounts: sets.Set{},
 	}
-	s.EndpointShardsByService[serviceName][namespace] = ep
+	s.EndpointShardsByService[serviceName][intern.Default.String(namespace)] = ep
 
 	return ep, true
 }
@@ -197,6 +225,7 @@ func (s *DiscoveryServer) deleteEndpointShards(cluster, serviceName, namespace s
 		s.EndpointShardsByService[serviceName][namespace] != nil {
 		s.EndpointShardsByService[serviceName][namespace].mutex.Lock()
 		delete(s.EndpointShardsByService[serviceName][namespace].Shards, cluster)
+		delete(s.EndpointShardsByService[serviceName][namespace].index, cluster)
 		s.EndpointShardsByService[serviceName][namespace].mutex.Unlock()
 	}
 }
@@ -212,6 +241,7 @@ func (s *DiscoveryServer) deleteService(cluster, serviceName, namespace string)
 
 		s.EndpointShardsByService[serviceName][namespace].mutex.Lock()
 		delete(s.EndpointShardsByService[serviceName][namespace].Shards, cluster)
+		delete(s.EndpointShardsByService[serviceName][namespace].index, cluster)
 		shards := len(s.EndpointShardsByService[serviceName][namespace].Shards)
 		s.EndpointShardsByService[serviceName][namespace].mutex.Unlock()
 
@@ -241,8 +271,8 @@ func (s *DiscoveryServer) llbEndpointAndOptionsForCluster(b EndpointBuilder) ([]
 	// against such behavior and returns nil. When the updated cluster warms up in Envoy, it would update with new endpoints
 	// automatically.
 	// Gateways use EDS for Passthrough cluster. So we should allow Passthrough here.
-	if b.service.Resolution == model.DNSLB {
-		adsLog.Infof("cluster %s in eds cluster, but its resolution now is updated to %v, skipping it.", b.clusterName, b.service.Resolution)
+	if b.service.ResolutionForPort(b.port) == model.DNSLB {
+		adsLog.Infof("cluster %s in eds cluster, but its resolution now is updated to %v, skipping it.", b.clusterName, model.DNSLB)
 		return nil, fmt.Errorf("cluster %s in eds cluster", b.clusterName)
 	}
 
@@ -289,7 +319,18 @@ func (s *DiscoveryServer) generateEndpoints(b EndpointBuilder) *endpoint.Cluster
 	if lbSetting != nil {
 		// Make a shallow copy of the cla as we are mutating the endpoints with priorities/weights relative to the calling proxy
 		l = util.CloneClusterLoadAssignment(l)
-		loadbalancer.ApplyLocalityLBSetting(b.locality, l, lbSetting, enableFailover)
+		if len(b.failoverPriority) > 0 {
+			// The priorities were set from the labels of the endpoints.
+			loadbalancer.ApplyFailoverPriority(l)
+		} else {
+			loadbalancer.ApplyLocalityLBSetting(b.locality, l, lbSetting, enableFailover)
+		}
+	}
+	if b.subset != nil {
+		if lbSetting == nil {
+			l = util.CloneClusterLoadAssignment(l)
+		}
+		applySpilloverPriority(l, llbOpts)
 	}
 	return l
 }
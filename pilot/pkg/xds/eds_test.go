@@ -33,10 +33,12 @@ import (
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	uatomic "go.uber.org/atomic"
 
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking"
 	"istio.io/istio/pilot/pkg/xds"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pilot/test/xdstest"
 	"istio.io/istio/pkg/adsc"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/protocol"
@@ -261,6 +263,54 @@ func TestNoTunnelServerEndpointEds(t *testing.T) {
 	})
 }
 
+func TestHBONETunnelEndpointEds(t *testing.T) {
+	defaultValue := features.EnableHBONE
+	defer func() { features.EnableHBONE = defaultValue }()
+	features.EnableHBONE = true
+
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: tcp
+  namespace: default
+spec:
+  hosts:
+  - tcp.example.com
+  addresses:
+  - 10.0.0.1
+  ports:
+  - number: 9000
+    name: tcp
+    protocol: TCP
+  location: MESH_INTERNAL
+  resolution: STATIC
+  endpoints:
+  - address: 10.0.0.10
+    serviceAccount: tcp
+  - address: 10.0.0.11
+    labels:
+      security.istio.io/tlsMode: disabled
+---
+apiVersion: security.istio.io/v1beta1
+kind: PeerAuthentication
+metadata:
+  name: default
+  namespace: default
+spec:
+  mtls:
+    mode: STRICT
+`})
+	endpoints := xdstest.ExtractLoadAssignments(s.Endpoints(s.SetupProxy(nil)))
+	// Only the endpoints with a sidecar terminate the tunnels, on the HBONE port.
+	if got := endpoints["outbound-hbone|9000||tcp.example.com"]; !reflect.DeepEqual(got, []string{"10.0.0.10:15008"}) {
+		t.Fatalf("expected the HBONE endpoint 10.0.0.10:15008, got %v", got)
+	}
+	if got := endpoints["outbound|9000||tcp.example.com"]; len(got) != 2 {
+		t.Fatalf("expected the endpoints of the service unchanged, got %v", got)
+	}
+}
+
 func mustReadFile(t *testing.T, fpaths ...string) string {
 	result := ""
 	for _, fpath := range fpaths {
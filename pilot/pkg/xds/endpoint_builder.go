@@ -15,7 +15,9 @@
 package xds
 
 import (
+	"errors"
 	"sort"
+	"strconv"
 	"strings"
 
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
@@ -24,11 +26,15 @@ import (
 	"github.com/golang/protobuf/ptypes/wrappers"
 
 	networkingapi "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking"
+	"istio.io/istio/pilot/pkg/networking/core/v1alpha3"
+	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/loadbalancer"
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/routing"
 	"istio.io/istio/pkg/config/schema/gvk"
 )
 
@@ -36,8 +42,12 @@ import (
 // collection includes only the endpoints which support H2 tunnel and the non-tunnel endpoints. The latter case is to
 // support multi-cluster service.
 // Revisit non-tunnel endpoint decision once the gateways supports tunnel.
+// The HBONE clusters only include the endpoints terminating the HBONE tunnels.
 // TODO(lambdai): Propose to istio api.
 func GetTunnelBuilderType(clusterName string, proxy *model.Proxy, push *model.PushContext) networking.TunnelType {
+	if direction, _, _, _ := model.ParseSubsetKey(clusterName); direction == model.TrafficDirectionOutboundHBONE {
+		return networking.HBONETunnel
+	}
 	if proxy == nil || proxy.Metadata == nil || proxy.Metadata.ProxyConfig == nil {
 		return networking.NoTunnel
 	}
@@ -62,6 +72,12 @@ type EndpointBuilder struct {
 	destinationRule *config.Config
 	service         *model.Service
 	tunnelType      networking.TunnelType
+	// failoverPriority are the label keys prioritizing the endpoints, set when the failover applies, and
+	// failoverLabels the labels of the proxy they are compared with.
+	failoverPriority []string
+	failoverLabels   map[string]string
+	// subset selects the endpoints within the topology boundaries of the proxy, if the endpoints are subset.
+	subset *endpointSubset
 
 	// These fields are provided for convenience only
 	subsetName string
@@ -72,8 +88,10 @@ type EndpointBuilder struct {
 
 func NewEndpointBuilder(clusterName string, proxy *model.Proxy, push *model.PushContext) EndpointBuilder {
 	_, subsetName, hostname, port := model.ParseSubsetKey(clusterName)
+	// The endpoints of the cluster dedicated to a route are the ones of the subset of its destination.
+	subsetName = routing.BaseSubset(subsetName)
 	svc := push.ServiceForHostname(proxy, hostname)
-	return EndpointBuilder{
+	b := EndpointBuilder{
 		clusterName:     clusterName,
 		network:         proxy.Metadata.Network,
 		networkView:     model.GetNetworkView(proxy),
@@ -82,12 +100,41 @@ func NewEndpointBuilder(clusterName string, proxy *model.Proxy, push *model.Push
 		service:         svc,
 		destinationRule: push.DestinationRule(proxy, svc),
 		tunnelType:      GetTunnelBuilderType(clusterName, proxy, push),
+		subset:          newEndpointSubset(proxy, endpointSubsetTopology, features.EndpointSubsetSpillover),
 
 		push:       push,
 		subsetName: subsetName,
 		hostname:   hostname,
 		port:       port,
 	}
+	b.initFailoverPriority(proxy)
+	return b
+}
+
+// initFailoverPriority sets the failover priority of the destination rule, if any. As the locality failover, it
+// only applies with outlier detection and locality load balancing without distribute setting.
+func (b *EndpointBuilder) initFailoverPriority(proxy *model.Proxy) {
+	if b.destinationRule == nil {
+		return
+	}
+	value, f := b.destinationRule.Annotations[routing.FailoverPriorityAnnotation]
+	if !f {
+		return
+	}
+	failoverPriority, err := routing.ParseFailoverPriority(value)
+	if err != nil {
+		adsLog.Debugf("ignored %s of destination rule %s/%s: %v", routing.FailoverPriorityAnnotation,
+			b.destinationRule.Namespace, b.destinationRule.Name, err)
+		return
+	}
+	enableFailover, lb := getOutlierDetectionAndLoadBalancerSettings(b.DestinationRule(), b.port, b.subsetName)
+	lbSetting := loadbalancer.GetLocalityLbSetting(b.push.Mesh.GetLocalityLbSetting(), lb.GetLocalityLbSetting())
+	if !enableFailover || lbSetting == nil || lbSetting.GetDistribute() != nil {
+		return
+	}
+	b.failoverPriority = failoverPriority
+	b.failoverLabels = loadbalancer.FailoverLabels(proxy.Metadata.Labels, util.LocalityToString(proxy.Locality),
+		proxy.Metadata.Network)
 }
 
 func (b EndpointBuilder) DestinationRule() *networkingapi.DestinationRule {
@@ -114,6 +161,13 @@ func (b EndpointBuilder) Key() string {
 		sort.Strings(nv)
 		params = append(params, nv...)
 	}
+	// The priorities of the endpoints depend on the labels of the proxy.
+	for _, key := range b.failoverPriority {
+		params = append(params, key+"="+b.failoverLabels[key])
+	}
+	if b.subset != nil {
+		params = append(params, b.subset.key())
+	}
 	return strings.Join(params, "~")
 }
 
@@ -153,10 +207,17 @@ type EndpointTunnelApplier interface {
 	ApplyTunnel(lep *endpoint.LbEndpoint, tunnelType networking.TunnelType) (*endpoint.LbEndpoint, error)
 }
 
+// errNoHBONETunnel is returned for the endpoints which cannot be reached through the HBONE tunnels.
+var errNoHBONETunnel = errors.New("endpoint does not terminate HBONE tunnels")
+
 type EndpointNoTunnelApplier struct{}
 
-// Note that this will not return error if another tunnel typs requested.
+// Note that this will not return error if another tunnel typs requested, except for HBONE: the tunneled traffic
+// cannot fall back to the endpoints without sidecar.
 func (t *EndpointNoTunnelApplier) ApplyTunnel(lep *endpoint.LbEndpoint, tunnelType networking.TunnelType) (*endpoint.LbEndpoint, error) {
+	if tunnelType == networking.HBONETunnel {
+		return nil, errNoHBONETunnel
+	}
 	return lep, nil
 }
 
@@ -178,16 +239,39 @@ func (t *EndpointH2TunnelApplier) ApplyTunnel(lep *endpoint.LbEndpoint, tunnelTy
 		return lep, nil
 	case networking.NoTunnel:
 		return lep, nil
+	case networking.HBONETunnel:
+		return nil, errNoHBONETunnel
 	default:
 		panic("supported tunnel type")
 	}
 }
 
+// EndpointHBONETunnelApplier sends the tunneled traffic to the HBONE port of the endpoints with a sidecar.
+type EndpointHBONETunnelApplier struct{}
+
+func (t *EndpointHBONETunnelApplier) ApplyTunnel(lep *endpoint.LbEndpoint, tunnelType networking.TunnelType) (*endpoint.LbEndpoint, error) {
+	if tunnelType != networking.HBONETunnel {
+		return lep, nil
+	}
+	if lep.GetEndpoint().GetAddress().GetSocketAddress().GetPortValue() == 0 {
+		// Unix domain socket endpoints cannot be reached through the tunnel.
+		return nil, errNoHBONETunnel
+	}
+	// The endpoint is shared by the clusters of the service, so it is copied before it is updated.
+	newEp := proto.Clone(lep).(*endpoint.LbEndpoint)
+	newEp.GetEndpoint().Address.GetSocketAddress().PortSpecifier = &core.SocketAddress_PortValue{
+		PortValue: v1alpha3.HBONEInboundListenPort,
+	}
+	return newEp, nil
+}
+
 type LocLbEndpointsAndOptions struct {
 	// The protobuf message which contains LbEndpoint slice.
 	llbEndpoints endpoint.LocalityLbEndpoints
 	// The runtime information of the LbEndpoint slice. Each LbEndpoint has individual metadata at the same index.
 	tunnelMetadata []EndpointTunnelApplier
+	// spillover is true if the endpoints are out of the topology boundaries of the endpoint subset.
+	spillover bool
 }
 
 // Return prefer H2 tunnel metadata.
@@ -195,6 +279,9 @@ func MakeTunnelApplier(le *endpoint.LbEndpoint, tunnelOpt networking.TunnelAbili
 	if tunnelOpt.SupportH2Tunnel() {
 		return &EndpointH2TunnelApplier{}
 	}
+	if tunnelOpt.SupportHBONETunnel() {
+		return &EndpointHBONETunnelApplier{}
+	}
 	return &EndpointNoTunnelApplier{}
 }
 
@@ -241,40 +328,44 @@ func (b *EndpointBuilder) buildLocalityLbEndpointsFromShards(
 	// and should, therefore, not be accessed from outside the cluster.
 	isClusterLocal := b.push.IsClusterLocal(b.service)
 
+	// The endpoints out of the boundaries of the subset, if any, are candidates to the spillover.
+	var spillover []*spilloverCandidate
+
 	shards.mutex.Lock()
 	// The shards are updated independently, now need to filter and merge
 	// for this cluster
-	for clusterID, endpoints := range shards.Shards {
+	for clusterID, index := range shards.index {
 		// If the downstream service is configured as cluster-local, only include endpoints that
 		// reside in the same cluster.
 		if isClusterLocal && (clusterID != b.clusterID) {
 			continue
 		}
 
-		for _, ep := range endpoints {
-			if svcPort.Name != ep.ServicePortName {
+		for _, group := range index[svcPort.Name] {
+			// Endpoints of a remote network hidden behind a gateway are dropped by the network filter
+			// when the proxy cannot view the network, so skip them altogether.
+			if group.network != b.network && !b.canViewNetwork(group.network) &&
+				len(b.push.NetworkGatewaysByNetwork(group.network)) > 0 {
 				continue
 			}
-			// Port labels
-			if !epLabels.HasSubsetOf(ep.Labels) {
-				continue
-			}
-
-			locLbEps, found := localityEpMap[ep.Locality.Label]
-			if !found {
-				locLbEps = &LocLbEndpointsAndOptions{
-					endpoint.LocalityLbEndpoints{
-						Locality:    util.ConvertLocality(ep.Locality.Label),
-						LbEndpoints: make([]*endpoint.LbEndpoint, 0, len(endpoints)),
-					},
-					make([]EndpointTunnelApplier, 0, len(endpoints)),
+			for _, ep := range group.endpoints {
+				// Port labels
+				if !epLabels.HasSubsetOf(ep.Labels) {
+					continue
 				}
-				localityEpMap[ep.Locality.Label] = locLbEps
-			}
-			if ep.EnvoyEndpoint == nil {
-				ep.EnvoyEndpoint = buildEnvoyLbEndpoint(ep)
+				if b.subset != nil {
+					if distance := b.subset.distance(ep); distance > 0 {
+						spillover = append(spillover, &spilloverCandidate{ep: ep, locality: group.locality, distance: distance})
+						continue
+					}
+				}
+				b.addLocalityLbEndpoint(localityEpMap, ep, group.locality, len(group.endpoints), false)
 			}
-			locLbEps.append(ep.EnvoyEndpoint, ep.TunnelAbility)
+		}
+	}
+	if len(spillover) > 0 {
+		for _, c := range b.subset.selectSpillover(spillover) {
+			b.addLocalityLbEndpoint(localityEpMap, c.ep, c.locality, 0, true)
 		}
 	}
 	shards.mutex.Unlock()
@@ -298,19 +389,77 @@ func (b *EndpointBuilder) buildLocalityLbEndpointsFromShards(
 	return locEps
 }
 
-// TODO(lambdai): Handle ApplyTunnel error return value by filter out the failed endpoint.
+// addLocalityLbEndpoint adds the endpoint to the LocalityLbEndpoints of its locality, priority and spillover.
+func (b *EndpointBuilder) addLocalityLbEndpoint(localityEpMap map[string]*LocLbEndpointsAndOptions,
+	ep *model.IstioEndpoint, locality string, size int, spillover bool) {
+	// The endpoints of a locality with different failover priorities are grouped apart.
+	key, priority := locality, 0
+	if len(b.failoverPriority) > 0 {
+		priority = loadbalancer.FailoverPriority(b.failoverPriority, b.failoverLabels,
+			loadbalancer.FailoverLabels(ep.Labels, ep.Locality.Label, ep.Network))
+		key += "~" + strconv.Itoa(priority)
+	}
+	// The spillover endpoints are grouped apart from the endpoints within the boundaries of the subset.
+	if spillover {
+		key += "~spillover"
+	}
+	locLbEps, found := localityEpMap[key]
+	if !found {
+		locLbEps = &LocLbEndpointsAndOptions{
+			llbEndpoints: endpoint.LocalityLbEndpoints{
+				Locality:    util.ConvertLocality(locality),
+				LbEndpoints: make([]*endpoint.LbEndpoint, 0, size),
+				Priority:    uint32(priority),
+			},
+			tunnelMetadata: make([]EndpointTunnelApplier, 0, size),
+			spillover:      spillover,
+		}
+		localityEpMap[key] = locLbEps
+	}
+	if ep.EnvoyEndpoint == nil {
+		ep.EnvoyEndpoint = buildEnvoyLbEndpoint(ep)
+	}
+	locLbEps.append(ep.EnvoyEndpoint, tunnelAbility(ep))
+}
+
+// tunnelAbility returns the tunnels supported by the endpoint. The sidecars terminate the HBONE tunnels.
+func tunnelAbility(ep *model.IstioEndpoint) networking.TunnelAbility {
+	if ep.TLSMode == model.IstioMutualTLSModeLabel {
+		return ep.TunnelAbility | networking.MakeTunnelAbility(networking.HBONETunnel)
+	}
+	return ep.TunnelAbility
+}
+
+// ApplyTunnelSetting applies the tunnel type to the endpoints. The endpoints failing to apply the tunnel cannot be
+// reached through it, so they are filtered out, along with the localities left without endpoints.
 func (b *EndpointBuilder) ApplyTunnelSetting(llbOpts []*LocLbEndpointsAndOptions, tunnelType networking.TunnelType) []*LocLbEndpointsAndOptions {
+	out := make([]*LocLbEndpointsAndOptions, 0, len(llbOpts))
 	for _, llb := range llbOpts {
+		lbEndpoints := make([]*endpoint.LbEndpoint, 0, len(llb.llbEndpoints.LbEndpoints))
+		tunnelMetadata := make([]EndpointTunnelApplier, 0, len(llb.tunnelMetadata))
 		for i, ep := range llb.llbEndpoints.LbEndpoints {
 			newEp, err := llb.tunnelMetadata[i].ApplyTunnel(ep, tunnelType)
 			if err != nil {
-				panic("not implemented yet on failing to apply tunnel")
-			} else {
-				llb.llbEndpoints.LbEndpoints[i] = newEp
+				adsLog.Debugf("endpoint %s of cluster %s filtered out: %v",
+					ep.GetEndpoint().GetAddress().GetSocketAddress().GetAddress(), b.clusterName, err)
+				continue
+			}
+			lbEndpoints = append(lbEndpoints, newEp)
+			tunnelMetadata = append(tunnelMetadata, llb.tunnelMetadata[i])
+		}
+		if len(lbEndpoints) < len(llb.llbEndpoints.LbEndpoints) {
+			if len(lbEndpoints) == 0 {
+				continue
 			}
+			llb.tunnelMetadata = tunnelMetadata
+			llb.llbEndpoints.LbEndpoints = lbEndpoints
+			llb.refreshWeight()
+		} else {
+			llb.llbEndpoints.LbEndpoints = lbEndpoints
 		}
+		out = append(out, llb)
 	}
-	return llbOpts
+	return out
 }
 
 // Create the CLusterLoadAssignment. At this moment the options must have been applied to the locality lb endpoints.
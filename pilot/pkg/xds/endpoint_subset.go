@@ -0,0 +1,227 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/loadbalancer"
+	"istio.io/istio/pilot/pkg/networking/util"
+)
+
+// The topology boundaries of the endpoint subsetting.
+const (
+	topologyNode    = "node"
+	topologyZone    = "zone"
+	topologyNetwork = "network"
+)
+
+// endpointSubsetTopology are the topology boundaries of PILOT_ENDPOINT_SUBSET_TOPOLOGY, from the broadest to the
+// narrowest. Endpoint subsetting is disabled if it is empty.
+var endpointSubsetTopology = parseEndpointSubsetTopology(features.EndpointSubsetTopology)
+
+func parseEndpointSubsetTopology(value string) []string {
+	set := map[string]bool{}
+	for _, key := range strings.Split(value, ",") {
+		key = strings.TrimSpace(key)
+		switch key {
+		case "":
+		case topologyNode, topologyZone, topologyNetwork:
+			set[key] = true
+		default:
+			adsLog.Warnf("ignored unknown topology %q of PILOT_ENDPOINT_SUBSET_TOPOLOGY", key)
+		}
+	}
+	var out []string
+	for _, key := range []string{topologyNetwork, topologyZone, topologyNode} {
+		if set[key] {
+			out = append(out, key)
+		}
+	}
+	return out
+}
+
+// endpointSubset selects the endpoints sent to a proxy when endpoint subsetting is enabled: the endpoints within the
+// topology boundaries of the proxy, and a spillover set of the closest endpoints out of them, with lower priorities
+// to preserve the failover.
+type endpointSubset struct {
+	// topology are the boundaries the proxy has a value for, from the broadest to the narrowest.
+	topology  []string
+	network   string
+	region    string
+	zone      string
+	node      string
+	spillover int
+}
+
+// newEndpointSubset returns the endpoint subset of the proxy, or nil if the endpoints are not subset for it.
+func newEndpointSubset(proxy *model.Proxy, topology []string, spillover int) *endpointSubset {
+	if len(topology) == 0 {
+		return nil
+	}
+	region, zone, _ := model.SplitLocalityLabel(util.LocalityToString(proxy.Locality))
+	s := &endpointSubset{
+		network:   proxy.Metadata.Network,
+		region:    region,
+		zone:      zone,
+		node:      proxyNodeName(proxy),
+		spillover: spillover,
+	}
+	// A boundary the proxy has no value for would exclude all the endpoints, so it is ignored.
+	for _, key := range topology {
+		switch key {
+		case topologyNetwork:
+			if s.network == "" {
+				continue
+			}
+		case topologyZone:
+			if s.region == "" && s.zone == "" {
+				continue
+			}
+		case topologyNode:
+			if s.node == "" {
+				continue
+			}
+		}
+		s.topology = append(s.topology, key)
+	}
+	if len(s.topology) == 0 {
+		return nil
+	}
+	return s
+}
+
+// proxyNodeName returns the node of the proxy, from its metadata or else from its service instances.
+func proxyNodeName(proxy *model.Proxy) string {
+	if proxy.Metadata.NodeName != "" {
+		return proxy.Metadata.NodeName
+	}
+	for _, instance := range proxy.ServiceInstances {
+		if instance.Endpoint != nil && instance.Endpoint.NodeName != "" {
+			return instance.Endpoint.NodeName
+		}
+	}
+	return ""
+}
+
+// key returns the part of the EDS cache key the subset depends on.
+func (s *endpointSubset) key() string {
+	if s == nil {
+		return ""
+	}
+	params := make([]string, 0, len(s.topology))
+	for _, key := range s.topology {
+		switch key {
+		case topologyNetwork:
+			params = append(params, "network="+s.network)
+		case topologyZone:
+			params = append(params, "zone="+s.region+"/"+s.zone)
+		case topologyNode:
+			params = append(params, "node="+s.node)
+		}
+	}
+	return strings.Join(params, ",")
+}
+
+// distance returns the number of boundaries the endpoint is out of, the broadest boundaries weighing more. The
+// endpoint is within all the boundaries if it is 0.
+func (s *endpointSubset) distance(ep *model.IstioEndpoint) int {
+	distance := 0
+	for i, key := range s.topology {
+		in := true
+		switch key {
+		case topologyNetwork:
+			in = ep.Network == s.network
+		case topologyZone:
+			region, zone, _ := model.SplitLocalityLabel(ep.Locality.Label)
+			in = region == s.region && zone == s.zone
+		case topologyNode:
+			in = ep.NodeName == s.node
+		}
+		if !in {
+			distance += 1 << (len(s.topology) - i - 1)
+		}
+	}
+	return distance
+}
+
+// spilloverCandidate is an endpoint out of the boundaries of the subset, and the locality it is grouped in.
+type spilloverCandidate struct {
+	ep       *model.IstioEndpoint
+	locality string
+	distance int
+	order    uint32
+}
+
+// selectSpillover returns the closest spillover candidates, up to the spillover size. Candidates at the same
+// distance are ordered by a hash of their address seeded with the subset key, so that the proxies of different
+// boundaries spread over different endpoints while the proxies sharing the boundaries get the same ones.
+func (s *endpointSubset) selectSpillover(candidates []*spilloverCandidate) []*spilloverCandidate {
+	if s.spillover <= 0 {
+		return nil
+	}
+	seed := s.key()
+	for _, c := range candidates {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(seed))
+		_, _ = h.Write([]byte(c.ep.Address))
+		c.order = h.Sum32()
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.distance != b.distance {
+			return a.distance < b.distance
+		}
+		if a.order != b.order {
+			return a.order < b.order
+		}
+		return a.ep.Address < b.ep.Address
+	})
+	if len(candidates) > s.spillover {
+		candidates = candidates[:s.spillover]
+	}
+	return candidates
+}
+
+// applySpilloverPriority lowers the priorities of the spillover endpoints below the ones of the endpoints within
+// the boundaries, keeping their relative order, so that they are used only when the latter are unavailable. The
+// LocalityLbEndpoints of the ClusterLoadAssignment are in the order of llbOpts.
+func applySpilloverPriority(l *endpoint.ClusterLoadAssignment, llbOpts []*LocLbEndpointsAndOptions) {
+	base := uint32(0)
+	spillover := false
+	for i, opts := range llbOpts {
+		if opts.spillover {
+			spillover = true
+		} else if p := l.Endpoints[i].Priority + 1; p > base {
+			base = p
+		}
+	}
+	if !spillover {
+		return
+	}
+	for i, opts := range llbOpts {
+		if opts.spillover {
+			l.Endpoints[i].Priority += base
+		}
+	}
+	// The priorities must range from 0 without skipping.
+	loadbalancer.ApplyFailoverPriority(l)
+}
@@ -0,0 +1,139 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"reflect"
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+func TestParseEndpointSubsetTopology(t *testing.T) {
+	cases := []struct {
+		value string
+		want  []string
+	}{
+		{"", nil},
+		{"node", []string{"node"}},
+		{"node, zone,network", []string{"network", "zone", "node"}},
+		{"zone,unknown,zone", []string{"zone"}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := parseEndpointSubsetTopology(tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func subsetTestProxy(network, node string) *model.Proxy {
+	return &model.Proxy{
+		Metadata: &model.NodeMetadata{Network: network, NodeName: node},
+		Locality: &core.Locality{Region: "region1", Zone: "zone1"},
+	}
+}
+
+func TestNewEndpointSubset(t *testing.T) {
+	if s := newEndpointSubset(subsetTestProxy("nw1", "node1"), nil, 5); s != nil {
+		t.Errorf("expected no subset without topology, got %v", s)
+	}
+	if s := newEndpointSubset(subsetTestProxy("", ""), []string{topologyNetwork, topologyNode}, 5); s != nil {
+		t.Errorf("expected no subset for a proxy without network nor node, got %v", s)
+	}
+	s := newEndpointSubset(subsetTestProxy("", "node1"), []string{topologyNetwork, topologyZone, topologyNode}, 5)
+	if want := []string{topologyZone, topologyNode}; !reflect.DeepEqual(s.topology, want) {
+		t.Errorf("got topology %v, want %v", s.topology, want)
+	}
+	if got, want := s.key(), "zone=region1/zone1,node=node1"; got != want {
+		t.Errorf("got key %q, want %q", got, want)
+	}
+
+	// The node of the proxy defaults to the one of its service instances.
+	proxy := subsetTestProxy("", "")
+	proxy.ServiceInstances = []*model.ServiceInstance{{Endpoint: &model.IstioEndpoint{NodeName: "node2"}}}
+	if s := newEndpointSubset(proxy, []string{topologyNode}, 5); s == nil || s.node != "node2" {
+		t.Errorf("expected a subset on node2, got %v", s)
+	}
+}
+
+func TestEndpointSubsetDistance(t *testing.T) {
+	s := newEndpointSubset(subsetTestProxy("nw1", "node1"), []string{topologyNetwork, topologyZone, topologyNode}, 5)
+	cases := []struct {
+		name string
+		ep   *model.IstioEndpoint
+		want int
+	}{
+		{"same node", &model.IstioEndpoint{Network: "nw1", Locality: model.Locality{Label: "region1/zone1/a"}, NodeName: "node1"}, 0},
+		{"same zone", &model.IstioEndpoint{Network: "nw1", Locality: model.Locality{Label: "region1/zone1/b"}, NodeName: "node2"}, 1},
+		{"same network", &model.IstioEndpoint{Network: "nw1", Locality: model.Locality{Label: "region1/zone2"}, NodeName: "node3"}, 3},
+		{"other network", &model.IstioEndpoint{Network: "nw2", Locality: model.Locality{Label: "region1/zone1"}, NodeName: "node4"}, 5},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.distance(tt.ep); got != tt.want {
+				t.Errorf("got distance %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectSpillover(t *testing.T) {
+	s := newEndpointSubset(subsetTestProxy("", "node1"), []string{topologyZone, topologyNode}, 2)
+	candidates := []*spilloverCandidate{
+		{ep: &model.IstioEndpoint{Address: "10.0.0.1"}, distance: 3},
+		{ep: &model.IstioEndpoint{Address: "10.0.0.2"}, distance: 1},
+		{ep: &model.IstioEndpoint{Address: "10.0.0.3"}, distance: 3},
+		{ep: &model.IstioEndpoint{Address: "10.0.0.4"}, distance: 2},
+	}
+	got := s.selectSpillover(candidates)
+	if len(got) != 2 || got[0].ep.Address != "10.0.0.2" || got[1].ep.Address != "10.0.0.4" {
+		t.Fatalf("expected the 2 closest candidates, got %v", got)
+	}
+
+	s.spillover = 0
+	if got := s.selectSpillover(candidates); len(got) != 0 {
+		t.Errorf("expected no spillover, got %v", got)
+	}
+}
+
+func TestApplySpilloverPriority(t *testing.T) {
+	llbOpts := []*LocLbEndpointsAndOptions{
+		{spillover: true},
+		{},
+		{spillover: true},
+		{},
+	}
+	l := &endpoint.ClusterLoadAssignment{
+		Endpoints: []*endpoint.LocalityLbEndpoints{
+			{Priority: 0},
+			{Priority: 0},
+			{Priority: 2},
+			{Priority: 1},
+		},
+	}
+	applySpilloverPriority(l, llbOpts)
+	var got []uint32
+	for _, e := range l.Endpoints {
+		got = append(got, e.Priority)
+	}
+	if want := []uint32{2, 0, 3, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got priorities %v, want %v", got, want)
+	}
+}
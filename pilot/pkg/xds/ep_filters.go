@@ -56,6 +56,7 @@ func (b *EndpointBuilder) EndpointsByNetworkFilter(endpoints []*LocLbEndpointsAn
 				Priority: ep.llbEndpoints.Priority,
 				// Endpoints and weight will be reset below.
 			},
+			spillover: ep.spillover,
 		}
 
 		// Weight (number of endpoints) for the EDS cluster for each remote networks
@@ -20,6 +20,7 @@ import (
 
 	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"go.opentelemetry.io/otel/attribute"
 
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
@@ -93,6 +94,9 @@ func (s *DiscoveryServer) pushXds(con *Connection, push *model.PushContext,
 	if w == nil {
 		return nil
 	}
+	if con.deltaStream != nil {
+		return s.pushDeltaXds(con, push, currentVersion, w, req)
+	}
 	gen := s.findGenerator(w.TypeUrl, con)
 	if gen == nil {
 		return nil
@@ -100,7 +104,12 @@ func (s *DiscoveryServer) pushXds(con *Connection, push *model.PushContext,
 
 	t0 := time.Now()
 
+	genSpan := startTypePushSpan("generate", con, w.TypeUrl, req)
 	res, err := gen.Generate(con.proxy, push, w, req)
+	if genSpan != nil {
+		genSpan.SetAttributes(attribute.Int("xds.resources", len(res)))
+	}
+	endSpan(genSpan, err)
 	if err != nil || res == nil {
 		// If we have nothing to send, report that we got an ACK for this version.
 		if s.StatusReporter != nil {
@@ -117,10 +126,14 @@ func (s *DiscoveryServer) pushXds(con *Connection, push *model.PushContext,
 		Resources:   res,
 	}
 
-	if err := con.send(resp); err != nil {
+	sendSpan := startTypePushSpan("send", con, w.TypeUrl, req)
+	err = con.send(resp)
+	endSpan(sendSpan, err)
+	if err != nil {
 		recordSendError(w.TypeUrl, con.ConID, err)
 		return err
 	}
+	recordConfigSize(con, w.TypeUrl, ResourceSize(res))
 
 	// Some types handle logs inside Generate, skip them here
 	if _, f := SkipLogTypes[w.TypeUrl]; !f {
@@ -60,8 +60,14 @@ func (l LdsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *m
 	}
 	listeners := l.Server.ConfigGenerator.BuildListeners(proxy, push)
 	resources := model.Resources{}
+	chains := 0
 	for _, c := range listeners {
 		resources = append(resources, util.MessageToAny(c))
+		chains += len(c.FilterChains)
+		if c.DefaultFilterChain != nil {
+			chains++
+		}
 	}
+	recordFilterChains(proxy, chains)
 	return resources, nil
 }
@@ -31,6 +31,9 @@ var (
 	typeTag    = monitoring.MustCreateLabel("type")
 	versionTag = monitoring.MustCreateLabel("version")
 
+	proxyTypeTag = monitoring.MustCreateLabel("proxy_type")
+	namespaceTag = monitoring.MustCreateLabel("namespace")
+
 	// pilot_total_xds_rejects should be used instead. This is for backwards compatibility
 	cdsReject = monitoring.NewGauge(
 		"pilot_xds_cds_reject",
@@ -86,6 +89,17 @@ var (
 		monitoring.WithLabels(typeTag),
 	)
 
+	xdsThrottledRequests = monitoring.NewSum(
+		"pilot_xds_throttled_requests",
+		"Total number of XDS requests deferred because the proxy exceeded its request rate limits.",
+		monitoring.WithLabels(nodeTag, typeTag),
+	)
+
+	xdsShardRedirects = monitoring.NewSum(
+		"pilot_xds_shard_redirects",
+		"Total number of XDS connections redirected to the istiod replica the proxy is assigned to.",
+	)
+
 	monServices = monitoring.NewGauge(
 		"pilot_services",
 		"Total services known to pilot.",
@@ -125,6 +139,36 @@ var (
 		monitoring.WithLabels(typeTag),
 	)
 
+	// Sizes of pushes on delta XDS streams, compared to the size the same push would have had on a
+	// state of the world stream.
+	deltaPushSize = monitoring.NewSum(
+		"pilot_xds_delta_push_bytes",
+		"Total size in bytes of resources sent in delta XDS pushes.",
+		monitoring.WithLabels(typeTag),
+	)
+
+	deltaPushFullSize = monitoring.NewSum(
+		"pilot_xds_delta_push_full_bytes",
+		"Total size in bytes that delta XDS pushes would have sent as full state of the world pushes.",
+		monitoring.WithLabels(typeTag),
+	)
+
+	// Sizes of the full config of each type generated for the proxies, to find the namespaces generating
+	// pathological config.
+	configSize = monitoring.NewDistribution(
+		"pilot_xds_config_size_bytes",
+		"Size in bytes of the full config of lds, rds, cds and eds generated for proxies.",
+		[]float64{1000, 10000, 100000, 1000000, 4000000, 10000000, 40000000},
+		monitoring.WithLabels(typeTag, proxyTypeTag, namespaceTag),
+	)
+
+	filterChains = monitoring.NewDistribution(
+		"pilot_xds_filter_chains",
+		"Number of filter chains in the listeners generated for proxies.",
+		[]float64{10, 100, 500, 1000, 5000, 10000, 50000},
+		monitoring.WithLabels(proxyTypeTag, namespaceTag),
+	)
+
 	sendTime = monitoring.NewDistribution(
 		"pilot_xds_send_time",
 		"Total time in seconds Pilot takes to send generated configuration.",
@@ -234,6 +278,33 @@ func recordPushTime(xdsType string, duration time.Duration) {
 	pushes.With(typeTag.Value(v3.GetMetricType(xdsType))).Increment()
 }
 
+func recordDeltaPushSize(xdsType string, deltaSize, fullSize int) {
+	deltaPushSize.With(typeTag.Value(v3.GetMetricType(xdsType))).Record(float64(deltaSize))
+	deltaPushFullSize.With(typeTag.Value(v3.GetMetricType(xdsType))).Record(float64(fullSize))
+}
+
+// recordConfigSize records the size of the full config of the type generated for the proxy of the connection.
+func recordConfigSize(con *Connection, typeURL string, size int) {
+	con.proxy.Lock()
+	if w := con.proxy.WatchedResources[typeURL]; w != nil {
+		w.ConfigSize = size
+	}
+	con.proxy.Unlock()
+	configSize.With(typeTag.Value(v3.GetMetricType(typeURL)), proxyTypeTag.Value(string(con.proxy.Type)),
+		namespaceTag.Value(con.proxy.ConfigNamespace)).Record(float64(size))
+}
+
+// recordFilterChains records the number of filter chains in the listeners generated for the proxy.
+func recordFilterChains(proxy *model.Proxy, count int) {
+	proxy.Lock()
+	if w := proxy.WatchedResources[v3.ListenerType]; w != nil {
+		w.FilterChains = count
+	}
+	proxy.Unlock()
+	filterChains.With(proxyTypeTag.Value(string(proxy.Type)), namespaceTag.Value(proxy.ConfigNamespace)).
+		Record(float64(count))
+}
+
 func init() {
 	monitoring.MustRegister(
 		cdsReject,
@@ -241,6 +312,8 @@ func init() {
 		ldsReject,
 		rdsReject,
 		xdsExpiredNonce,
+		xdsThrottledRequests,
+		xdsShardRedirects,
 		totalXDSRejects,
 		monServices,
 		xdsClients,
@@ -256,5 +329,9 @@ func init() {
 		sendTime,
 		totalDelayedPushes,
 		totalDelayedPushTimeouts,
+		deltaPushSize,
+		deltaPushFullSize,
+		configSize,
+		filterChains,
 	)
 }
@@ -0,0 +1,142 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/wasm"
+)
+
+// rejectedResourcePrefixes are the prefixes of the errors Envoy reports when it rejects listeners or clusters,
+// followed by "<name>: <reason>" for each rejected resource.
+var rejectedResourcePrefixes = map[string]string{
+	v3.ListenerType: "Error adding/updating listener(s) ",
+	v3.ClusterType:  "Error adding/updating cluster(s) ",
+	// The agent rejects the extension configs whose Wasm module it can't fetch.
+	v3.ExtensionConfigurationType: wasm.FetchErrorPrefix,
+}
+
+// recordNack records that the client rejected the message with the nonce, with the error it reported.
+func recordNack(w *model.WatchedResource, nonce, message string) {
+	if w == nil {
+		return
+	}
+	w.NonceNacked = nonce
+	w.NackMessage = message
+	w.LastNackMessage = message
+	w.LastNackTime = time.Now()
+	w.NackedResources = rejectedResourceNames(w.TypeUrl, message)
+	// The rejected resources must be sent again, even if they are unchanged.
+	w.ResourceVersions = nil
+}
+
+// clearNack resets the last rejection of the client, following an ACK or a newer response.
+func clearNack(w *model.WatchedResource) {
+	w.NonceNacked = ""
+	w.NackMessage = ""
+	w.NackedResources = nil
+}
+
+// rejectedResourceNames returns the names of the resources rejected by Envoy, when they can be told from the
+// error message. Envoy reports a line for each rejected listener, and joins the rejected clusters with ", ",
+// in which case only the first one can be reliably told.
+func rejectedResourceNames(typeURL, message string) []string {
+	prefix, f := rejectedResourcePrefixes[typeURL]
+	if !f || !strings.HasPrefix(message, prefix) {
+		return nil
+	}
+	var names []string
+	for _, line := range strings.Split(strings.TrimPrefix(message, prefix), "\n") {
+		if i := strings.Index(line, ": "); i > 0 {
+			names = append(names, line[:i])
+		}
+	}
+	return names
+}
+
+// ConfigRejections aggregates the configs whose generated configuration was rejected by the connected proxies.
+// Only the rejected clusters can currently be attributed, to the DestinationRule of their service.
+func (s *DiscoveryServer) ConfigRejections() []model.ConfigRejection {
+	push := s.globalPushContext()
+	byConfig := map[model.ConfigKey]*model.ConfigRejection{}
+	for _, con := range s.Clients() {
+		con.proxy.RLock()
+		w := con.proxy.WatchedResources[v3.ClusterType]
+		var message string
+		var clusters []string
+		if w != nil && w.NonceNacked != "" {
+			message, clusters = w.NackMessage, w.NackedResources
+		}
+		con.proxy.RUnlock()
+
+		// A proxy is counted once for each config, even if it rejected several of its clusters.
+		seen := map[model.ConfigKey]struct{}{}
+		for _, cluster := range clusters {
+			cfg := clusterDestinationRule(push, con.proxy, cluster)
+			if cfg == nil {
+				continue
+			}
+			key := model.ConfigKey{Kind: cfg.GroupVersionKind, Name: cfg.Name, Namespace: cfg.Namespace}
+			if _, f := seen[key]; f {
+				continue
+			}
+			seen[key] = struct{}{}
+			rejection, f := byConfig[key]
+			if !f {
+				rejection = &model.ConfigRejection{
+					Group:      cfg.GroupVersionKind.Group,
+					Version:    cfg.GroupVersionKind.Version,
+					Kind:       cfg.GroupVersionKind.Kind,
+					Namespace:  cfg.Namespace,
+					Name:       cfg.Name,
+					Generation: cfg.Generation,
+					Message:    message,
+				}
+				byConfig[key] = rejection
+			}
+			rejection.Proxies++
+		}
+	}
+	out := make([]model.ConfigRejection, 0, len(byConfig))
+	for _, rejection := range byConfig {
+		out = append(out, *rejection)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Namespace != out[j].Namespace {
+			return out[i].Namespace < out[j].Namespace
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// clusterDestinationRule returns the DestinationRule applied to the outbound cluster for the proxy, if any.
+func clusterDestinationRule(push *model.PushContext, proxy *model.Proxy, cluster string) *config.Config {
+	direction, _, hostname, _ := model.ParseSubsetKey(cluster)
+	if direction != model.TrafficDirectionOutbound {
+		return nil
+	}
+	svc := push.ServiceForHostname(proxy, hostname)
+	if svc == nil {
+		return nil
+	}
+	return push.DestinationRule(proxy, svc)
+}
@@ -0,0 +1,81 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"reflect"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/wasm"
+)
+
+func TestRejectedResourceNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		typeURL string
+		message string
+		want    []string
+	}{
+		{
+			name:    "listeners",
+			typeURL: v3.ListenerType,
+			message: "Error adding/updating listener(s) 0.0.0.0_80: duplicate filter chain\nvirtualOutbound: invalid filter",
+			want:    []string{"0.0.0.0_80", "virtualOutbound"},
+		},
+		{
+			name:    "cluster",
+			typeURL: v3.ClusterType,
+			message: "Error adding/updating cluster(s) outbound|9080||reviews.default.svc.cluster.local: invalid lb policy",
+			want:    []string{"outbound|9080||reviews.default.svc.cluster.local"},
+		},
+		{
+			name:    "extension configs",
+			typeURL: v3.ExtensionConfigurationType,
+			message: wasm.FetchErrorPrefix + "plugin-a: cannot fetch Wasm module\nplugin-b: cannot fetch Wasm module",
+			want:    []string{"plugin-a", "plugin-b"},
+		},
+		{
+			name:    "unknown format",
+			typeURL: v3.ClusterType,
+			message: "proto: unknown field",
+		},
+		{
+			name:    "routes",
+			typeURL: v3.RouteType,
+			message: "Error adding/updating listener(s) 0.0.0.0_80: invalid",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rejectedResourceNames(tt.typeURL, tt.message); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLastNack(t *testing.T) {
+	w := &model.WatchedResource{TypeUrl: v3.ClusterType}
+	recordNack(w, "nonce-1", "invalid cluster")
+	clearNack(w)
+	if w.NackMessage != "" {
+		t.Errorf("expected the nack to be cleared, got %q", w.NackMessage)
+	}
+	if w.LastNackMessage != "invalid cluster" || w.LastNackTime.IsZero() {
+		t.Errorf("expected the last nack to be kept, got %q at %v", w.LastNackMessage, w.LastNackTime)
+	}
+}
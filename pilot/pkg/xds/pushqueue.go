@@ -17,9 +17,53 @@ package xds
 import (
 	"sync"
 
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 )
 
+// pushClass groups the connections of the push queue by priority. Lower classes are served first.
+type pushClass int
+
+const (
+	gatewayPushClass pushClass = iota
+	sidecarPushClass
+	numPushClasses
+)
+
+// classQueue maintains the ordering of the connections of a push class. The connections affected by
+// their push request are served before the others, up to affectedWeight in a row when others are pending.
+type classQueue struct {
+	affected []*Connection
+	others   []*Connection
+
+	affectedWeight int
+	served         int
+}
+
+func (q *classQueue) len() int {
+	return len(q.affected) + len(q.others)
+}
+
+func (q *classQueue) push(con *Connection, affected bool) {
+	if affected {
+		q.affected = append(q.affected, con)
+	} else {
+		q.others = append(q.others, con)
+	}
+}
+
+func (q *classQueue) pop() (con *Connection) {
+	if len(q.affected) > 0 && (q.served < q.affectedWeight || len(q.others) == 0) {
+		q.served++
+		con, q.affected = q.affected[0], q.affected[1:]
+	} else {
+		// The affected connections were served their weight, or none are pending: start a new round.
+		q.served = 0
+		con, q.others = q.others[0], q.others[1:]
+	}
+	return con
+}
+
 type PushQueue struct {
 	cond *sync.Cond
 
@@ -27,8 +71,16 @@ type PushQueue struct {
 	// the PushRequest will be merged.
 	pending map[*Connection]*model.PushRequest
 
-	// queue maintains ordering of the queue
-	queue []*Connection
+	// queues maintain ordering of the queue, for each push class.
+	queues [numPushClasses]classQueue
+
+	// weights is the number of connections served in a row for each push class, when lower classes are
+	// pending too. served counts them in the current round, so that lower classes are not starved.
+	weights [numPushClasses]int
+	served  [numPushClasses]int
+
+	// prioritized is false if all connections are served in the order they were queued.
+	prioritized bool
 
 	// processing stores all connections that have been Dequeue(), but not MarkDone().
 	// The value stored will be initially be nil, but may be populated if the connection is Enqueue().
@@ -39,11 +91,27 @@ type PushQueue struct {
 }
 
 func NewPushQueue() *PushQueue {
-	return &PushQueue{
-		pending:    make(map[*Connection]*model.PushRequest),
-		processing: make(map[*Connection]*model.PushRequest),
-		cond:       sync.NewCond(&sync.Mutex{}),
+	return newPushQueue(features.EnablePushQueuePriority, features.PushQueueGatewayWeight, features.PushQueueAffectedWeight)
+}
+
+func newPushQueue(prioritized bool, gatewayWeight, affectedWeight int) *PushQueue {
+	if gatewayWeight < 1 {
+		gatewayWeight = 1
 	}
+	if affectedWeight < 1 {
+		affectedWeight = 1
+	}
+	p := &PushQueue{
+		pending:     make(map[*Connection]*model.PushRequest),
+		processing:  make(map[*Connection]*model.PushRequest),
+		cond:        sync.NewCond(&sync.Mutex{}),
+		weights:     [numPushClasses]int{gatewayPushClass: gatewayWeight, sidecarPushClass: 1},
+		prioritized: prioritized,
+	}
+	for class := range p.queues {
+		p.queues[class].affectedWeight = affectedWeight
+	}
+	return p
 }
 
 // Enqueue will mark a proxy as pending a push. If it is already pending, pushInfo will be merged.
@@ -68,27 +136,75 @@ func (p *PushQueue) Enqueue(con *Connection, pushRequest *model.PushRequest) {
 	}
 
 	p.pending[con] = pushRequest
-	p.queue = append(p.queue, con)
+	p.push(con, pushRequest)
 	// Signal waiters on Dequeue that a new item is available
 	p.cond.Signal()
 }
 
+// push adds the connection to the queue of its push class. The class is only computed when the connection
+// is queued, merged requests do not change it.
+func (p *PushQueue) push(con *Connection, pushRequest *model.PushRequest) {
+	if !p.prioritized || con.proxy == nil {
+		p.queues[sidecarPushClass].push(con, false)
+		return
+	}
+	class := sidecarPushClass
+	if con.proxy.Type == model.Router {
+		class = gatewayPushClass
+	}
+	p.queues[class].push(con, isProxyUpdate(pushRequest) || proxyServiceUpdated(con.proxy, pushRequest))
+}
+
+// isProxyUpdate returns true if the push request targets the proxy, as its instances changed.
+func isProxyUpdate(pushRequest *model.PushRequest) bool {
+	for _, reason := range pushRequest.Reason {
+		if reason == model.ProxyUpdate {
+			return true
+		}
+	}
+	return false
+}
+
+// pop removes the next connection from the queue, which must not be empty. The highest class that has
+// not been served its weight in the current round is picked.
+func (p *PushQueue) pop() *Connection {
+	for {
+		for class := range p.queues {
+			if p.queues[class].len() > 0 && p.served[class] < p.weights[class] {
+				p.served[class]++
+				return p.queues[class].pop()
+			}
+		}
+		// All the pending classes were served their weight, start a new round.
+		p.served = [numPushClasses]int{}
+	}
+}
+
+// len returns the number of connections in the queue.
+func (p *PushQueue) len() int {
+	n := 0
+	for class := range p.queues {
+		n += p.queues[class].len()
+	}
+	return n
+}
+
 // Remove a proxy from the queue. If there are no proxies ready to be removed, this will block
 func (p *PushQueue) Dequeue() (con *Connection, request *model.PushRequest, shutdown bool) {
 	p.cond.L.Lock()
 	defer p.cond.L.Unlock()
 
 	// Block until there is one to remove. Enqueue will signal when one is added.
-	for len(p.queue) == 0 && !p.shuttingDown {
+	for p.len() == 0 && !p.shuttingDown {
 		p.cond.Wait()
 	}
 
-	if len(p.queue) == 0 {
+	if p.len() == 0 {
 		// We must be shutting down.
 		return nil, nil, true
 	}
 
-	con, p.queue = p.queue[0], p.queue[1:]
+	con = p.pop()
 
 	request = p.pending[con]
 	delete(p.pending, con)
@@ -109,7 +225,7 @@ func (p *PushQueue) MarkDone(con *Connection) {
 	// This means we need to add it back to the queue.
 	if request != nil {
 		p.pending[con] = request
-		p.queue = append(p.queue, con)
+		p.push(con, request)
 		p.cond.Signal()
 	}
 }
@@ -118,7 +234,7 @@ func (p *PushQueue) MarkDone(con *Connection) {
 func (p *PushQueue) Pending() int {
 	p.cond.L.Lock()
 	defer p.cond.L.Unlock()
-	return len(p.queue)
+	return p.len()
 }
 
 // ShutDown will cause queue to ignore all new items added to it. As soon as the
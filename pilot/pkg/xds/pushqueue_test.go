@@ -294,3 +294,84 @@ func TestProxyQueue(t *testing.T) {
 		}
 	})
 }
+
+func TestProxyQueuePriority(t *testing.T) {
+	leak.Check(t)
+	connection := func(id string, nodeType model.NodeType) *Connection {
+		return &Connection{ConID: id, proxy: &model.Proxy{ID: id, Type: nodeType}}
+	}
+	sidecars := []*Connection{connection("sidecar-0", model.SidecarProxy), connection("sidecar-1", model.SidecarProxy)}
+	gateways := []*Connection{
+		connection("gateway-0", model.Router), connection("gateway-1", model.Router), connection("gateway-2", model.Router),
+	}
+
+	t.Run("gateways first", func(t *testing.T) {
+		t.Parallel()
+		p := newPushQueue(true, 10, 10)
+		defer p.ShutDown()
+		p.Enqueue(sidecars[0], &model.PushRequest{})
+		p.Enqueue(gateways[0], &model.PushRequest{})
+		p.Enqueue(sidecars[1], &model.PushRequest{})
+		p.Enqueue(gateways[1], &model.PushRequest{})
+
+		ExpectDequeue(t, p, gateways[0])
+		ExpectDequeue(t, p, gateways[1])
+		ExpectDequeue(t, p, sidecars[0])
+		ExpectDequeue(t, p, sidecars[1])
+	})
+
+	t.Run("affected first", func(t *testing.T) {
+		t.Parallel()
+		p := newPushQueue(true, 10, 10)
+		defer p.ShutDown()
+		p.Enqueue(sidecars[0], &model.PushRequest{})
+		p.Enqueue(sidecars[1], &model.PushRequest{Reason: []model.TriggerReason{model.ProxyUpdate}})
+
+		ExpectDequeue(t, p, sidecars[1])
+		ExpectDequeue(t, p, sidecars[0])
+	})
+
+	t.Run("sidecars not starved", func(t *testing.T) {
+		t.Parallel()
+		p := newPushQueue(true, 2, 10)
+		defer p.ShutDown()
+		p.Enqueue(sidecars[0], &model.PushRequest{})
+		for _, gateway := range gateways {
+			p.Enqueue(gateway, &model.PushRequest{})
+		}
+
+		ExpectDequeue(t, p, gateways[0])
+		ExpectDequeue(t, p, gateways[1])
+		ExpectDequeue(t, p, sidecars[0])
+		ExpectDequeue(t, p, gateways[2])
+	})
+
+	t.Run("unaffected not starved", func(t *testing.T) {
+		t.Parallel()
+		p := newPushQueue(true, 10, 2)
+		defer p.ShutDown()
+		affected := []*Connection{
+			connection("sidecar-2", model.SidecarProxy), connection("sidecar-3", model.SidecarProxy), connection("sidecar-4", model.SidecarProxy),
+		}
+		p.Enqueue(sidecars[0], &model.PushRequest{})
+		for _, con := range affected {
+			p.Enqueue(con, &model.PushRequest{Reason: []model.TriggerReason{model.ProxyUpdate}})
+		}
+
+		ExpectDequeue(t, p, affected[0])
+		ExpectDequeue(t, p, affected[1])
+		ExpectDequeue(t, p, sidecars[0])
+		ExpectDequeue(t, p, affected[2])
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Parallel()
+		p := newPushQueue(false, 10, 10)
+		defer p.ShutDown()
+		p.Enqueue(sidecars[0], &model.PushRequest{})
+		p.Enqueue(gateways[0], &model.PushRequest{Reason: []model.TriggerReason{model.ProxyUpdate}})
+
+		ExpectDequeue(t, p, sidecars[0])
+		ExpectDequeue(t, p, gateways[0])
+	})
+}
@@ -0,0 +1,126 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"sync"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"golang.org/x/time/rate"
+)
+
+// requestLimiter rate limits the discovery requests of a connection, across all types and per type.
+// Requests over the limit are not rejected: the latest request of each type is deferred until the
+// limiter allows it, so that a proxy sending requests in a loop backs off without losing its connection.
+type requestLimiter struct {
+	mu sync.Mutex
+
+	// proxy limits the requests of all types. It is nil if there is no such limit.
+	proxy *rate.Limiter
+
+	// byType limits the requests of each type URL. It is nil if there is no such limit.
+	byType map[string]*rate.Limiter
+	limit  rate.Limit
+	burst  int
+
+	// deferred is the latest request of each type URL waiting for the limiters.
+	deferred map[string]*discovery.DiscoveryRequest
+
+	// ready receives the deferred requests once the limiters allow them.
+	ready chan *discovery.DiscoveryRequest
+}
+
+// newRequestLimiter returns a limiter allowing proxyLimit requests per second across all types, and
+// typeLimit requests per second for each type. A limit that is not positive is disabled. It returns nil
+// if both are.
+func newRequestLimiter(proxyLimit, typeLimit float64, burst int) *requestLimiter {
+	if proxyLimit <= 0 && typeLimit <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	l := &requestLimiter{
+		burst:    burst,
+		deferred: map[string]*discovery.DiscoveryRequest{},
+		ready:    make(chan *discovery.DiscoveryRequest),
+	}
+	if proxyLimit > 0 {
+		l.proxy = rate.NewLimiter(rate.Limit(proxyLimit), burst)
+	}
+	if typeLimit > 0 {
+		l.limit = rate.Limit(typeLimit)
+		l.byType = map[string]*rate.Limiter{}
+	}
+	return l
+}
+
+// throttle returns true if the request is over the limits. The request is then delivered on requests()
+// once the limiters allow it, unless done is closed first. A later request of the same type supersedes it.
+func (l *requestLimiter) throttle(req *discovery.DiscoveryRequest, done <-chan struct{}) bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, f := l.deferred[req.TypeUrl]; f {
+		l.deferred[req.TypeUrl] = req
+		return true
+	}
+	delay := l.reserve(req.TypeUrl, time.Now())
+	if delay == 0 {
+		return false
+	}
+	l.deferred[req.TypeUrl] = req
+	time.AfterFunc(delay, func() {
+		l.mu.Lock()
+		deferred := l.deferred[req.TypeUrl]
+		delete(l.deferred, req.TypeUrl)
+		l.mu.Unlock()
+		select {
+		case l.ready <- deferred:
+		case <-done:
+		}
+	})
+	return true
+}
+
+// reserve takes a token for a request of the type URL, and returns how long to wait before processing it.
+func (l *requestLimiter) reserve(typeURL string, now time.Time) time.Duration {
+	var delay time.Duration
+	if l.proxy != nil {
+		delay = l.proxy.ReserveN(now, 1).DelayFrom(now)
+	}
+	if l.byType != nil {
+		limiter, f := l.byType[typeURL]
+		if !f {
+			limiter = rate.NewLimiter(l.limit, l.burst)
+			l.byType[typeURL] = limiter
+		}
+		if d := limiter.ReserveN(now, 1).DelayFrom(now); d > delay {
+			delay = d
+		}
+	}
+	return delay
+}
+
+// requests returns the channel receiving the deferred requests. It is nil, and never ready, for a nil limiter.
+func (l *requestLimiter) requests() <-chan *discovery.DiscoveryRequest {
+	if l == nil {
+		return nil
+	}
+	return l.ready
+}
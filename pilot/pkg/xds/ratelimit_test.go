@@ -0,0 +1,64 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+func TestRequestLimiter(t *testing.T) {
+	if l := newRequestLimiter(0, 0, 10); l != nil {
+		t.Fatalf("expected no limiter without limits")
+	}
+	var nilLimiter *requestLimiter
+	if nilLimiter.throttle(&discovery.DiscoveryRequest{TypeUrl: v3.ClusterType}, nil) {
+		t.Fatalf("expected a nil limiter to allow all requests")
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	l := newRequestLimiter(0, 10, 1)
+	if l.throttle(&discovery.DiscoveryRequest{TypeUrl: v3.ClusterType}, done) {
+		t.Fatalf("expected the first request to be allowed")
+	}
+	if l.throttle(&discovery.DiscoveryRequest{TypeUrl: v3.ListenerType}, done) {
+		t.Fatalf("expected the first request of another type to be allowed")
+	}
+	if !l.throttle(&discovery.DiscoveryRequest{TypeUrl: v3.ClusterType, VersionInfo: "1"}, done) {
+		t.Fatalf("expected the second request to be throttled")
+	}
+	if !l.throttle(&discovery.DiscoveryRequest{TypeUrl: v3.ClusterType, VersionInfo: "2"}, done) {
+		t.Fatalf("expected the third request to be throttled")
+	}
+
+	select {
+	case req := <-l.requests():
+		if req.VersionInfo != "2" {
+			t.Fatalf("expected the latest request to be delivered, got version %q", req.VersionInfo)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for the throttled request")
+	}
+	select {
+	case req := <-l.requests():
+		t.Fatalf("expected the superseded request to be dropped, got version %q", req.VersionInfo)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -62,8 +62,12 @@ func (c RdsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *m
 		return nil, nil
 	}
 	rawRoutes := c.Server.ConfigGenerator.BuildHTTPRoutes(proxy, push, w.ResourceNames)
+	onDemand := usesOnDemandRoutes(proxy)
 	resources := model.Resources{}
 	for _, c := range rawRoutes {
+		if onDemand {
+			c = onDemandRouteConfig(c)
+		}
 		resources = append(resources, util.MessageToAny(c))
 	}
 	return resources, nil
@@ -0,0 +1,370 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	adminapi "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	"github.com/golang/protobuf/jsonpb"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/plugin"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/util/gogoprotomarshal"
+)
+
+// ReplayResult is the configuration generated for a proxy by Replay.
+type ReplayResult struct {
+	// ConfigDump is the configuration of the proxy, as returned by /debug/config_dump, without the secrets.
+	ConfigDump *adminapi.ConfigDump
+	// Endpoints are the endpoints of the EDS clusters of the proxy.
+	Endpoints []*endpoint.ClusterLoadAssignment
+	// Identical is true if the clusters, listeners and routes are identical to the ones generated when the
+	// snapshot was taken.
+	Identical bool
+}
+
+// Replay generates offline the configuration of the proxy of the snapshot with the ID, from the configs and the
+// state of the registries recorded in the snapshot. The proxy ID is either the ID of the proxy, such as
+// "productpage-v1-6b746f74dc-9stvs.default", or the ID of its node.
+func Replay(snapshot *Snapshot, proxyID string) (*ReplayResult, error) {
+	env, registry, err := replayEnvironment(snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generation runs with the plugins of istiod by default.
+	s := NewDiscoveryServer(env, []string{plugin.AuthzCustom, plugin.Authn, plugin.Authz}, "replay")
+	defer s.Shutdown()
+	push := model.NewPushContext()
+	if err := push.InitContext(env, nil, nil); err != nil {
+		return nil, fmt.Errorf("failed to initialize the push context: %v", err)
+	}
+	env.PushContext = push
+	for _, shard := range snapshot.EndpointShards {
+		s.edsCacheUpdate(shard.ClusterID, shard.Hostname, shard.Namespace, shard.Endpoints)
+	}
+	// The version is part of the generated configuration, and is restored for it to be identical.
+	versionMutex.Lock()
+	version = snapshot.Version
+	versionMutex.Unlock()
+
+	sp, node, err := findSnapshotProxy(snapshot, proxyID)
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := s.initProxyMetadata(node)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the proxy metadata: %v", err)
+	}
+	registry.setProxy(sp)
+	s.setProxyState(proxy, push)
+	setProxyLocality(proxy, node)
+	proxy.DiscoverIPVersions()
+	proxy.WatchedResources = map[string]*model.WatchedResource{
+		v3.RouteType: {TypeUrl: v3.RouteType, ResourceNames: sp.Routes},
+	}
+
+	con := &Connection{proxy: proxy, node: node}
+	dump, err := s.configDump(con)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate the configuration: %v", err)
+	}
+	digest, err := configDigest(dump)
+	if err != nil {
+		return nil, err
+	}
+	out := &ReplayResult{
+		ConfigDump: dump,
+		Identical:  digest == sp.ConfigDigest,
+	}
+	for _, c := range s.ConfigGenerator.BuildClusters(proxy, push) {
+		if c.GetType() == cluster.Cluster_EDS {
+			out.Endpoints = append(out.Endpoints, s.generateEndpoints(NewEndpointBuilder(c.Name, proxy, push)))
+		}
+	}
+	return out, nil
+}
+
+// replayEnvironment returns an environment holding the configs and the state of the registries of the snapshot.
+func replayEnvironment(snapshot *Snapshot) (*model.Environment, *replayRegistry, error) {
+	meshConfig := &meshconfig.MeshConfig{}
+	if err := gogoprotomarshal.ApplyJSON(string(snapshot.MeshConfig), meshConfig); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse the mesh config: %v", err)
+	}
+	var meshNetworks *meshconfig.MeshNetworks
+	if len(snapshot.MeshNetworks) > 0 {
+		meshNetworks = &meshconfig.MeshNetworks{}
+		if err := gogoprotomarshal.ApplyJSON(string(snapshot.MeshNetworks), meshNetworks); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse the mesh networks: %v", err)
+		}
+	}
+	store, err := newReplayConfigStore(snapshot)
+	if err != nil {
+		return nil, nil, err
+	}
+	registry := newReplayRegistry(snapshot)
+	env := &model.Environment{
+		ServiceDiscovery: registry,
+		IstioConfigStore: model.MakeIstioStore(store),
+		Watcher:          mesh.NewFixedWatcher(meshConfig),
+		NetworksWatcher:  mesh.NewFixedNetworksWatcher(meshNetworks),
+		DomainSuffix:     snapshot.DomainSuffix,
+		PushContext:      model.NewPushContext(),
+	}
+	if snapshot.IPSets != nil {
+		env.IPSetWatcher = replayIPSets(snapshot.IPSets)
+	}
+	return env, registry, nil
+}
+
+// findSnapshotProxy returns the proxy of the snapshot with the ID, and its node.
+func findSnapshotProxy(snapshot *Snapshot, proxyID string) (*SnapshotProxy, *core.Node, error) {
+	ids := make([]string, 0, len(snapshot.Proxies))
+	for _, sp := range snapshot.Proxies {
+		node := &core.Node{}
+		if err := jsonpb.Unmarshal(bytes.NewReader(sp.Node), node); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse the node of a proxy: %v", err)
+		}
+		id := node.Id
+		if proxy, err := model.ParseServiceNodeWithMetadata(node.Id, &model.NodeMetadata{}); err == nil {
+			id = proxy.ID
+		}
+		if proxyID == id || proxyID == node.Id {
+			return sp, node, nil
+		}
+		ids = append(ids, id)
+	}
+	return nil, nil, fmt.Errorf("proxy %s is not in the snapshot, which has proxies: %s", proxyID, strings.Join(ids, ", "))
+}
+
+type replayIPSets map[string][]string
+
+func (r replayIPSets) IPSets() map[string][]string {
+	return r
+}
+
+var errReplayReadOnly = errors.New("unsupported operation: the replayed configs are read-only")
+
+// replayConfigStore is a read-only config store holding the configs of a snapshot. Unlike the in-memory store,
+// it keeps their resource versions.
+type replayConfigStore struct {
+	configs map[config.GroupVersionKind][]config.Config
+}
+
+var _ model.ConfigStore = &replayConfigStore{}
+
+func newReplayConfigStore(snapshot *Snapshot) (*replayConfigStore, error) {
+	out := &replayConfigStore{configs: map[config.GroupVersionKind][]config.Config{}}
+	for _, obj := range snapshot.Configs {
+		gv := strings.SplitN(obj.APIVersion, "/", 2)
+		if len(gv) != 2 {
+			return nil, fmt.Errorf("invalid api version %q of %s/%s", obj.APIVersion, obj.Namespace, obj.Name)
+		}
+		schema, f := collections.Pilot.FindByGroupVersionKind(config.GroupVersionKind{Group: gv[0], Version: gv[1], Kind: obj.Kind})
+		if !f {
+			return nil, fmt.Errorf("unknown kind %s of %s/%s", obj.Kind, obj.Namespace, obj.Name)
+		}
+		cfg, err := crd.ConvertObject(schema, obj, snapshot.DomainSuffix)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %s %s/%s: %v", obj.Kind, obj.Namespace, obj.Name, err)
+		}
+		out.configs[cfg.GroupVersionKind] = append(out.configs[cfg.GroupVersionKind], *cfg)
+	}
+	return out, nil
+}
+
+func (r *replayConfigStore) Schemas() collection.Schemas {
+	return collections.Pilot
+}
+
+func (r *replayConfigStore) Get(typ config.GroupVersionKind, name, namespace string) *config.Config {
+	for _, cfg := range r.configs[typ] {
+		if cfg.Name == name && cfg.Namespace == namespace {
+			c := cfg
+			return &c
+		}
+	}
+	return nil
+}
+
+func (r *replayConfigStore) List(typ config.GroupVersionKind, namespace string) ([]config.Config, error) {
+	out := make([]config.Config, 0, len(r.configs[typ]))
+	for _, cfg := range r.configs[typ] {
+		if namespace == "" || cfg.Namespace == namespace {
+			out = append(out, cfg)
+		}
+	}
+	return out, nil
+}
+
+func (r *replayConfigStore) Create(config.Config) (string, error) {
+	return "", errReplayReadOnly
+}
+
+func (r *replayConfigStore) Update(config.Config) (string, error) {
+	return "", errReplayReadOnly
+}
+
+func (r *replayConfigStore) UpdateStatus(config.Config) (string, error) {
+	return "", errReplayReadOnly
+}
+
+func (r *replayConfigStore) Patch(config.Config, config.PatchFunc) (string, error) {
+	return "", errReplayReadOnly
+}
+
+func (r *replayConfigStore) Delete(config.GroupVersionKind, string, string, *string) error {
+	return errReplayReadOnly
+}
+
+// replayRegistry is a service registry returning the state recorded in a snapshot, including the state of the
+// replayed proxy.
+type replayRegistry struct {
+	services        []*model.Service
+	byHostname      map[host.Name]map[string]*model.Service
+	instances       map[host.Name]map[string]map[int][]*model.ServiceInstance
+	serviceAccounts map[host.Name]map[string]map[int][]string
+	gateways        map[string][]*model.Gateway
+
+	proxyInstances []*model.ServiceInstance
+	proxyLabels    labels.Instance
+}
+
+var _ model.ServiceDiscovery = &replayRegistry{}
+
+func newReplayRegistry(snapshot *Snapshot) *replayRegistry {
+	out := &replayRegistry{
+		byHostname:      map[host.Name]map[string]*model.Service{},
+		instances:       map[host.Name]map[string]map[int][]*model.ServiceInstance{},
+		serviceAccounts: map[host.Name]map[string]map[int][]string{},
+		gateways:        snapshot.NetworkGateways,
+	}
+	for _, ss := range snapshot.Services {
+		svc := ss.Service
+		ns := svc.Attributes.Namespace
+		out.services = append(out.services, svc)
+		if out.byHostname[svc.Hostname] == nil {
+			out.byHostname[svc.Hostname] = map[string]*model.Service{}
+			out.instances[svc.Hostname] = map[string]map[int][]*model.ServiceInstance{}
+			out.serviceAccounts[svc.Hostname] = map[string]map[int][]string{}
+		}
+		out.byHostname[svc.Hostname][ns] = svc
+		out.serviceAccounts[svc.Hostname][ns] = ss.ServiceAccounts
+	}
+	for _, ss := range snapshot.Services {
+		svc := ss.Service
+		byPort := map[int][]*model.ServiceInstance{}
+		for port, instances := range ss.Instances {
+			byPort[port] = out.serviceInstances(instances)
+		}
+		out.instances[svc.Hostname][svc.Attributes.Namespace] = byPort
+	}
+	return out
+}
+
+// serviceInstances returns the service instances of the snapshot, pointing to the services of the registry.
+func (r *replayRegistry) serviceInstances(instances []*SnapshotInstance) []*model.ServiceInstance {
+	out := make([]*model.ServiceInstance, 0, len(instances))
+	for _, instance := range instances {
+		svc := r.byHostname[instance.Hostname][instance.Namespace]
+		if svc == nil {
+			continue
+		}
+		out = append(out, &model.ServiceInstance{
+			Service:     svc,
+			ServicePort: instance.ServicePort,
+			Endpoint:    instance.Endpoint,
+		})
+	}
+	return out
+}
+
+// setProxy sets the state returned for the replayed proxy.
+func (r *replayRegistry) setProxy(sp *SnapshotProxy) {
+	r.proxyInstances = r.serviceInstances(sp.Instances)
+	r.proxyLabels = sp.WorkloadLabels
+}
+
+func (r *replayRegistry) Services() ([]*model.Service, error) {
+	return r.services, nil
+}
+
+func (r *replayRegistry) GetService(hostname host.Name) (*model.Service, error) {
+	for _, svc := range r.services {
+		if svc.Hostname == hostname {
+			return svc, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *replayRegistry) InstancesByPort(svc *model.Service, servicePort int, l labels.Collection) []*model.ServiceInstance {
+	var out []*model.ServiceInstance
+	for _, instance := range r.instances[svc.Hostname][svc.Attributes.Namespace][servicePort] {
+		if l.HasSubsetOf(instance.Endpoint.Labels) {
+			out = append(out, instance)
+		}
+	}
+	return out
+}
+
+func (r *replayRegistry) GetProxyServiceInstances(*model.Proxy) []*model.ServiceInstance {
+	return r.proxyInstances
+}
+
+func (r *replayRegistry) GetProxyWorkloadLabels(*model.Proxy) labels.Collection {
+	if len(r.proxyLabels) == 0 {
+		return nil
+	}
+	return labels.Collection{r.proxyLabels}
+}
+
+func (r *replayRegistry) GetIstioServiceAccounts(svc *model.Service, ports []int) []string {
+	byPort := r.serviceAccounts[svc.Hostname][svc.Attributes.Namespace]
+	if len(ports) == 1 {
+		return byPort[ports[0]]
+	}
+	seen := map[string]struct{}{}
+	var out []string
+	for _, port := range ports {
+		for _, sa := range byPort[port] {
+			if _, f := seen[sa]; !f {
+				seen[sa] = struct{}{}
+				out = append(out, sa)
+			}
+		}
+	}
+	return out
+}
+
+func (r *replayRegistry) NetworkGateways() map[string][]*model.Gateway {
+	return r.gateways
+}
@@ -22,10 +22,13 @@ import (
 	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	"github.com/golang/protobuf/ptypes/any"
 
+	"istio.io/istio/pilot/pkg/certexpiry"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/pkg/secrets"
 	authnmodel "istio.io/istio/pilot/pkg/security/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pkg/config/schema/gvk"
 )
 
@@ -72,8 +75,13 @@ func parseResourceName(resource, defaultNamespace string) (SecretResource, error
 	return SecretResource{}, fmt.Errorf("unknown resource type: %v", resource)
 }
 
+// sidecarServedSecrets returns true if the sidecar is served the secrets of the credentialName of DestinationRules.
+func sidecarServedSecrets(proxy *model.Proxy) bool {
+	return features.EnableSidecarCredentialName && proxy.SecretsAuthorized
+}
+
 func needsUpdate(proxy *model.Proxy, updates model.XdsUpdates) bool {
-	if proxy.Type != model.Router {
+	if proxy.Type != model.Router && !(proxy.Type == model.SidecarProxy && sidecarServedSecrets(proxy)) {
 		return false
 	}
 	if len(updates) == 0 {
@@ -94,20 +102,28 @@ func (s *SecretGen) proxyAuthorizedForSecret(proxy *model.Proxy, sr SecretResour
 	return nil
 }
 
-func (s *SecretGen) Generate(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource, req *model.PushRequest) (model.Resources, error) {
+// authorizeProxy returns the secrets of the cluster of the proxy, or an error if the proxy is not allowed to read them.
+func (s *SecretGen) authorizeProxy(proxy *model.Proxy) (secrets.Controller, error) {
 	if proxy.VerifiedIdentity == nil {
-		adsLog.Warnf("proxy %v is not authorized to receive secrets. Ensure you are connecting over TLS port and are authenticated.", proxy.ID)
-		return nil, nil
+		return nil, fmt.Errorf("proxy %v is not authorized to receive secrets. Ensure you are connecting over TLS port and are authenticated", proxy.ID)
 	}
 	secrets, err := s.secrets.ForCluster(proxy.Metadata.ClusterID)
 	if err != nil {
-		adsLog.Warnf("proxy %v is from an unknown cluster, cannot retrieve certificates: %v", proxy.ID, err)
-		return nil, nil
+		return nil, fmt.Errorf("proxy %v is from an unknown cluster, cannot retrieve certificates: %v", proxy.ID, err)
 	}
 	if err := secrets.Authorize(proxy.VerifiedIdentity.ServiceAccount, proxy.VerifiedIdentity.Namespace); err != nil {
-		adsLog.Warnf("proxy %v is not authorized to receive secrets: %v", proxy.ID, err)
+		return nil, fmt.Errorf("proxy %v is not authorized to receive secrets: %v", proxy.ID, err)
+	}
+	return secrets, nil
+}
+
+func (s *SecretGen) Generate(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource, req *model.PushRequest) (model.Resources, error) {
+	secrets, err := s.authorizeProxy(proxy)
+	if err != nil {
+		adsLog.Warnf("%v", err)
 		return nil, nil
 	}
+	s.referenceCerts(proxy, w.ResourceNames)
 	if req == nil || !needsUpdate(proxy, req.ConfigsUpdated) {
 		return nil, nil
 	}
@@ -159,8 +175,10 @@ func (s *SecretGen) Generate(proxy *model.Proxy, push *model.PushContext, w *mod
 				res := toEnvoyKeyCertSecret(sr.ResourceName, key, cert)
 				results = append(results, res)
 				s.cache.Add(sr, res)
+				s.recordCertExpiry(sr, cert)
 			} else {
 				adsLog.Warnf("failed to fetch key and certificate for %v", sr.ResourceName)
+				s.certExpiry.Remove(sr.Key())
 			}
 		}
 	}
@@ -169,6 +187,33 @@ func (s *SecretGen) Generate(proxy *model.Proxy, push *model.PushContext, w *mod
 	return results, nil
 }
 
+// referenceCerts marks the gateway certificates requested by the proxy as referenced by it, so that the expiration
+// of the certificates no proxy requests anymore stops being tracked.
+func (s *SecretGen) referenceCerts(proxy *model.Proxy, resourceNames []string) {
+	if s.certExpiry == nil {
+		return
+	}
+	keys := make([]string, 0, len(resourceNames))
+	for _, resource := range resourceNames {
+		sr, err := parseResourceName(resource, proxy.ConfigNamespace)
+		if err != nil || strings.HasSuffix(sr.Name, GatewaySdsCaSuffix) {
+			continue
+		}
+		keys = append(keys, sr.Key())
+	}
+	s.certExpiry.Reference(proxy.ID, keys)
+}
+
+// recordCertExpiry tracks the expiration of a gateway certificate read from a secret.
+func (s *SecretGen) recordCertExpiry(sr SecretResource, cert []byte) {
+	c, err := certexpiry.FromPEM(certexpiry.Gateway, sr.Namespace, sr.Name, cert)
+	if err != nil {
+		adsLog.Warnf("failed to track the expiration of %v: %v", sr.ResourceName, err)
+		return
+	}
+	s.certExpiry.Record(sr.Key(), c)
+}
+
 func toEnvoyCaSecret(name string, cert []byte) *any.Any {
 	return util.MessageToAny(&tls.Secret{
 		Name: name,
@@ -239,15 +284,31 @@ type SecretGen struct {
 	secrets secrets.MulticlusterController
 	// Cache for XDS resources
 	cache model.XdsCache
+	// certExpiry tracks the expiration of the served certificates, if set.
+	certExpiry *certexpiry.Tracker
 }
 
 var _ model.XdsResourceGenerator = &SecretGen{}
 
-func NewSecretGen(sc secrets.MulticlusterController, cache model.XdsCache) *SecretGen {
+func NewSecretGen(sc secrets.MulticlusterController, cache model.XdsCache, certExpiry *certexpiry.Tracker) *SecretGen {
 	// TODO: Currently we only have a single secrets controller (Kubernetes). In the future, we will need a mapping
 	// of resource type to secret controller (ie kubernetes:// -> KubernetesController, vault:// -> VaultController)
 	return &SecretGen{
-		secrets: sc,
-		cache:   cache,
+		secrets:    sc,
+		cache:      cache,
+		certExpiry: certExpiry,
 	}
 }
+
+// secretsAuthorized returns true if the proxy is allowed to read the secrets of its namespace over SDS.
+func (s *DiscoveryServer) secretsAuthorized(proxy *model.Proxy) bool {
+	sg, ok := s.Generators[v3.SecretType].(*SecretGen)
+	if !ok {
+		return false
+	}
+	if _, err := sg.authorizeProxy(proxy); err != nil {
+		adsLog.Debugf("sidecar is not served the credentialName of DestinationRules: %v", err)
+		return false
+	}
+	return true
+}
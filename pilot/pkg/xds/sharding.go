@@ -0,0 +1,126 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"strconv"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+// shardVirtualNodes is the number of points of each replica on the ring, spreading the proxies evenly.
+const shardVirtualNodes = 128
+
+type shardPoint struct {
+	hash    uint64
+	replica string
+}
+
+// ProxyShards assigns the proxies to the istiod replicas with consistent hashing of their ID, so that only the
+// proxies of a replica are moved when replicas are added or removed.
+type ProxyShards struct {
+	// self is the name of this replica.
+	self string
+
+	mu sync.RWMutex
+	// ring holds the points of the replicas, sorted by hash.
+	ring []shardPoint
+	// addresses are the IPs of the replicas, keyed by name.
+	addresses map[string]string
+}
+
+// NewProxyShards creates the shards of the replica named self. Until the replicas are set, all the proxies are
+// assigned to it.
+func NewProxyShards(self string) *ProxyShards {
+	return &ProxyShards{self: self}
+}
+
+// SetReplicas sets the ready replicas, mapping their name to their IP.
+func (p *ProxyShards) SetReplicas(replicas map[string]string) {
+	ring := make([]shardPoint, 0, len(replicas)*shardVirtualNodes)
+	addresses := make(map[string]string, len(replicas))
+	for name, address := range replicas {
+		addresses[name] = address
+		for i := 0; i < shardVirtualNodes; i++ {
+			ring = append(ring, shardPoint{hash: shardHash(name + "#" + strconv.Itoa(i)), replica: name})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool {
+		if ring[i].hash == ring[j].hash {
+			return ring[i].replica < ring[j].replica
+		}
+		return ring[i].hash < ring[j].hash
+	})
+	p.mu.Lock()
+	p.ring = ring
+	p.addresses = addresses
+	p.mu.Unlock()
+}
+
+// Owner returns the IP of the replica the proxy is assigned to, and whether it is this replica. Proxies are
+// assigned to this replica while it is not ready itself, so they are never turned away by all the replicas.
+func (p *ProxyShards) Owner(proxyID string) (string, bool) {
+	if p == nil {
+		return "", true
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if _, f := p.addresses[p.self]; !f {
+		return "", true
+	}
+	h := shardHash(proxyID)
+	i := sort.Search(len(p.ring), func(i int) bool { return p.ring[i].hash >= h })
+	if i == len(p.ring) {
+		i = 0
+	}
+	owner := p.ring[i].replica
+	return p.addresses[owner], owner == p.self
+}
+
+func shardHash(key string) uint64 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// checkProxyShard rejects the connection of a proxy assigned to another replica, naming the replica in the trailer
+// so that the agent of the proxy connects to it directly. Proxies without an agent reconnect through the istiod
+// Service, until they land on their replica.
+func (s *DiscoveryServer) checkProxyShard(con *Connection) error {
+	if !model.IsApplicationNodeType(con.proxy.Type) {
+		return nil
+	}
+	owner, local := s.ProxyShards.Owner(con.proxy.ID)
+	if local {
+		return nil
+	}
+	trailer := metadata.Pairs(v3.ShardRedirectTrailer, owner)
+	if con.deltaStream != nil {
+		con.deltaStream.SetTrailer(trailer)
+	} else if con.stream != nil {
+		con.stream.SetTrailer(trailer)
+	}
+	xdsShardRedirects.Increment()
+	adsLog.Debugf("ADS: redirecting %s to istiod %s", con.proxy.ID, owner)
+	return status.Errorf(codes.Unavailable, "proxy %s is assigned to istiod %s", con.proxy.ID, owner)
+}
@@ -0,0 +1,68 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestProxyShards(t *testing.T) {
+	var disabled *ProxyShards
+	if _, local := disabled.Owner("a.default"); !local {
+		t.Fatalf("expected all the proxies to be local without sharding")
+	}
+
+	shards := NewProxyShards("istiod-a")
+	if _, local := shards.Owner("a.default"); !local {
+		t.Fatalf("expected all the proxies to be local before the replicas are known")
+	}
+	shards.SetReplicas(map[string]string{"istiod-b": "10.0.0.2"})
+	if _, local := shards.Owner("a.default"); !local {
+		t.Fatalf("expected all the proxies to be local while this replica is not ready")
+	}
+
+	replicas := map[string]string{"istiod-a": "10.0.0.1", "istiod-b": "10.0.0.2", "istiod-c": "10.0.0.3"}
+	shards.SetReplicas(replicas)
+	owners := map[string]string{}
+	counts := map[string]int{}
+	for i := 0; i < 3000; i++ {
+		id := fmt.Sprintf("pod-%d.default", i)
+		owner, local := shards.Owner(id)
+		if local != (owner == "10.0.0.1") {
+			t.Fatalf("proxy %s assigned to %s, local %v", id, owner, local)
+		}
+		owners[id] = owner
+		counts[owner]++
+	}
+	for ip, count := range counts {
+		if count < 700 || count > 1300 {
+			t.Errorf("unbalanced shards: %s owns %d of 3000 proxies", ip, count)
+		}
+	}
+
+	// Removing a replica only moves its own proxies.
+	delete(replicas, "istiod-c")
+	shards.SetReplicas(replicas)
+	for id, previous := range owners {
+		owner, _ := shards.Owner(id)
+		if previous != "10.0.0.3" && owner != previous {
+			t.Fatalf("proxy %s moved from %s to %s", id, previous, owner)
+		}
+		if owner == "10.0.0.3" {
+			t.Fatalf("proxy %s assigned to the removed replica", id)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	adminapi "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
+	"github.com/golang/protobuf/jsonpb"
+
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/util/gogoprotomarshal"
+)
+
+// Snapshot holds the inputs of the xDS generation: the configs, the state of the service registries and the
+// proxies. It is taken by /debug/snapshotz, and Replay generates the configuration of a proxy from it offline,
+// identical to the configuration istiod generated when the snapshot was taken.
+type Snapshot struct {
+	// Version is the version of the configuration pushed to the proxies when the snapshot was taken.
+	Version string `json:"version"`
+	// Time is the time the snapshot was taken.
+	Time time.Time `json:"time"`
+
+	MeshConfig   json.RawMessage `json:"meshConfig"`
+	MeshNetworks json.RawMessage `json:"meshNetworks,omitempty"`
+	DomainSuffix string          `json:"domainSuffix"`
+
+	// Configs are the Istio configs, in their Kubernetes form.
+	Configs []*crd.IstioKind `json:"configs"`
+	// IPSets are the named IP sets referenced by the AuthorizationPolicies.
+	IPSets map[string][]string `json:"ipSets,omitempty"`
+
+	// Services are the services of all the registries, with the instances of each of their ports.
+	Services []*SnapshotService `json:"services"`
+	// EndpointShards are the endpoints of the services, as indexed for EDS.
+	EndpointShards []*SnapshotShard `json:"endpointShards"`
+	// NetworkGateways are the gateways of each network.
+	NetworkGateways map[string][]*model.Gateway `json:"networkGateways,omitempty"`
+
+	// Proxies are the proxies the snapshot was taken for.
+	Proxies []*SnapshotProxy `json:"proxies"`
+}
+
+// SnapshotService is a service of a Snapshot.
+type SnapshotService struct {
+	Service *model.Service `json:"service"`
+	// Instances are the instances of the service, keyed by service port.
+	Instances map[int][]*SnapshotInstance `json:"instances,omitempty"`
+	// ServiceAccounts are the service accounts of the instances of the service, keyed by service port.
+	ServiceAccounts map[int][]string `json:"serviceAccounts,omitempty"`
+}
+
+// SnapshotInstance is a service instance of a Snapshot.
+type SnapshotInstance struct {
+	Hostname    host.Name            `json:"hostname"`
+	Namespace   string               `json:"namespace"`
+	ServicePort *model.Port          `json:"servicePort"`
+	Endpoint    *model.IstioEndpoint `json:"endpoint"`
+}
+
+// SnapshotShard holds the endpoints of a service in a cluster, as indexed for EDS.
+type SnapshotShard struct {
+	Hostname  string                 `json:"hostname"`
+	Namespace string                 `json:"namespace"`
+	ClusterID string                 `json:"clusterID"`
+	Endpoints []*model.IstioEndpoint `json:"endpoints"`
+}
+
+// SnapshotProxy is a proxy of a Snapshot, with the state the registries returned for it.
+type SnapshotProxy struct {
+	// Node is the node the proxy sent in its first request.
+	Node json.RawMessage `json:"node"`
+	// Instances are the service instances of the proxy.
+	Instances []*SnapshotInstance `json:"instances,omitempty"`
+	// WorkloadLabels are the labels of the workload of the proxy.
+	WorkloadLabels labels.Instance `json:"workloadLabels,omitempty"`
+	// Routes are the route configurations watched by the proxy.
+	Routes []string `json:"routes,omitempty"`
+	// ConfigDigest is the digest of the clusters, listeners and routes generated for the proxy, to tell whether
+	// a replay generated an identical configuration.
+	ConfigDigest string `json:"configDigest"`
+}
+
+// Snapshot takes a snapshot of the inputs of the xDS generation, for the connected proxy with the ID, or for all
+// the connected proxies if the ID is empty.
+func (s *DiscoveryServer) Snapshot(proxyID string) (*Snapshot, error) {
+	push := s.globalPushContext()
+	out := &Snapshot{
+		Version:         versionInfo(),
+		Time:            time.Now(),
+		DomainSuffix:    s.Env.DomainSuffix,
+		NetworkGateways: s.Env.NetworkGateways(),
+	}
+
+	meshConfig, err := gogoprotomarshal.ToJSON(s.Env.Mesh())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the mesh config: %v", err)
+	}
+	out.MeshConfig = json.RawMessage(meshConfig)
+	if networks := s.Env.Networks(); networks != nil {
+		meshNetworks, err := gogoprotomarshal.ToJSON(networks)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal the mesh networks: %v", err)
+		}
+		out.MeshNetworks = json.RawMessage(meshNetworks)
+	}
+	if s.Env.IPSetWatcher != nil {
+		out.IPSets = s.Env.IPSetWatcher.IPSets()
+	}
+
+	var configErr error
+	s.Env.IstioConfigStore.Schemas().ForEach(func(schema collection.Schema) bool {
+		configs, err := s.Env.IstioConfigStore.List(schema.Resource().GroupVersionKind(), "")
+		if err != nil {
+			configErr = err
+			return true
+		}
+		for _, cfg := range configs {
+			obj, err := crd.ConvertConfig(cfg)
+			if err != nil {
+				configErr = err
+				return true
+			}
+			out.Configs = append(out.Configs, obj.(*crd.IstioKind))
+		}
+		return false
+	})
+	if configErr != nil {
+		return nil, fmt.Errorf("failed to list the configs: %v", configErr)
+	}
+
+	services, err := s.Env.Services()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the services: %v", err)
+	}
+	for _, svc := range services {
+		svc.Mutex.RLock()
+		copied := svc.DeepCopy()
+		svc.Mutex.RUnlock()
+		ss := &SnapshotService{
+			Service:         copied,
+			Instances:       map[int][]*SnapshotInstance{},
+			ServiceAccounts: map[int][]string{},
+		}
+		for _, port := range svc.Ports {
+			ss.Instances[port.Port] = snapshotInstances(push.ServiceInstancesByPort(svc, port.Port, nil))
+			if accounts, f := push.ServiceAccounts[svc.Hostname][port.Port]; f {
+				ss.ServiceAccounts[port.Port] = accounts
+			}
+		}
+		out.Services = append(out.Services, ss)
+	}
+
+	s.mutex.RLock()
+	for hostname, byNamespace := range s.EndpointShardsByService {
+		for namespace, shards := range byNamespace {
+			shards.mutex.RLock()
+			for clusterID, endpoints := range shards.Shards {
+				out.EndpointShards = append(out.EndpointShards, &SnapshotShard{
+					Hostname:  hostname,
+					Namespace: namespace,
+					ClusterID: clusterID,
+					Endpoints: snapshotEndpoints(endpoints),
+				})
+			}
+			shards.mutex.RUnlock()
+		}
+	}
+	s.mutex.RUnlock()
+	sort.Slice(out.EndpointShards, func(i, j int) bool {
+		a, b := out.EndpointShards[i], out.EndpointShards[j]
+		if a.Hostname != b.Hostname {
+			return a.Hostname < b.Hostname
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.ClusterID < b.ClusterID
+	})
+
+	for _, con := range s.Clients() {
+		if proxyID != "" && con.proxy.ID != proxyID {
+			continue
+		}
+		sp, err := s.snapshotProxy(con)
+		if err != nil {
+			return nil, fmt.Errorf("failed to snapshot proxy %s: %v", con.proxy.ID, err)
+		}
+		out.Proxies = append(out.Proxies, sp)
+	}
+	return out, nil
+}
+
+// snapshotProxy returns the state of the proxy of the connection, and the digest of its configuration.
+func (s *DiscoveryServer) snapshotProxy(con *Connection) (*SnapshotProxy, error) {
+	node := &bytes.Buffer{}
+	if err := (&jsonpb.Marshaler{}).Marshal(node, con.node); err != nil {
+		return nil, err
+	}
+	con.proxy.RLock()
+	instances := snapshotInstances(con.proxy.ServiceInstances)
+	workloadLabels := con.proxy.Metadata.Labels
+	con.proxy.RUnlock()
+	dump, err := s.configDump(con)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := configDigest(dump)
+	if err != nil {
+		return nil, err
+	}
+	return &SnapshotProxy{
+		Node:           json.RawMessage(node.Bytes()),
+		Instances:      instances,
+		WorkloadLabels: workloadLabels,
+		Routes:         con.Routes(),
+		ConfigDigest:   digest,
+	}, nil
+}
+
+func snapshotInstances(instances []*model.ServiceInstance) []*SnapshotInstance {
+	out := make([]*SnapshotInstance, 0, len(instances))
+	for _, instance := range instances {
+		out = append(out, &SnapshotInstance{
+			Hostname:    instance.Service.Hostname,
+			Namespace:   instance.Service.Attributes.Namespace,
+			ServicePort: instance.ServicePort,
+			Endpoint:    snapshotEndpoint(instance.Endpoint),
+		})
+	}
+	return out
+}
+
+func snapshotEndpoints(endpoints []*model.IstioEndpoint) []*model.IstioEndpoint {
+	out := make([]*model.IstioEndpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		out = append(out, snapshotEndpoint(ep))
+	}
+	return out
+}
+
+// snapshotEndpoint copies the endpoint without its cached Envoy endpoint, which is built again when needed.
+func snapshotEndpoint(ep *model.IstioEndpoint) *model.IstioEndpoint {
+	if ep == nil {
+		return nil
+	}
+	out := *ep
+	out.EnvoyEndpoint = nil
+	return &out
+}
+
+// configDigest returns the digest of the clusters, listeners and routes of the config dump. The secrets are
+// excluded, as they are not part of the snapshot.
+func configDigest(dump *adminapi.ConfigDump) (string, error) {
+	h := sha256.New()
+	m := &jsonpb.Marshaler{OrigName: true}
+	for _, cfg := range dump.Configs {
+		switch cfg.TypeUrl {
+		case "type.googleapis.com/envoy.admin.v3.ClustersConfigDump",
+			"type.googleapis.com/envoy.admin.v3.ListenersConfigDump",
+			"type.googleapis.com/envoy.admin.v3.RoutesConfigDump":
+			if err := m.Marshal(h, cfg); err != nil {
+				return "", err
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// snapshotz returns a snapshot of the inputs of the xDS generation, for the proxy in the query or all proxies.
+func (s *DiscoveryServer) snapshotz(w http.ResponseWriter, req *http.Request) {
+	snapshot, err := s.Snapshot(req.URL.Query().Get("proxyID"))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+	b, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	_, _ = w.Write(b)
+}
@@ -157,6 +157,9 @@ func debugSyncStatus(wr *model.WatchedResource) status.ConfigStatus {
 	if wr.NonceAcked == wr.NonceSent {
 		return status.ConfigStatus_SYNCED
 	}
+	if wr.NonceNacked == wr.NonceSent {
+		return status.ConfigStatus_ERROR
+	}
 	return status.ConfigStatus_STALE
 }
 
@@ -0,0 +1,82 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+// The push pipeline is traced as follows:
+//   config change / config update: the changes triggering the push, recorded when they are received
+//   debounce: from the first debounced change to the push, linked to the changes
+//   push: the push context build and the enqueuing of the proxies, child of the debounce
+//   push proxy: the push to a proxy, from its dequeue, child of the push
+//   generate / send: the generation and the sending of a type to the proxy, children of the push proxy
+
+// traceDebounce records the debouncing of the push request, started with its first change, and returns its span.
+func traceDebounce(req *model.PushRequest, start time.Time, events int) trace.SpanContext {
+	span := model.StartSpan(req.Span, "debounce",
+		trace.WithTimestamp(start),
+		req.TriggerLinks(),
+		trace.WithAttributes(
+			attribute.Int("debounce.events", events),
+			attribute.Bool("push.full", req.Full),
+		))
+	span.End()
+	return span.SpanContext()
+}
+
+// startProxyPushSpan starts the span of the push of the request to the connection.
+func startProxyPushSpan(con *Connection, req *model.PushRequest) trace.Span {
+	return model.StartSpan(req.Span, "push proxy",
+		req.TriggerLinks(),
+		trace.WithAttributes(
+			attribute.String("proxy.id", con.proxy.ID),
+			attribute.String("proxy.type", string(con.proxy.Type)),
+			attribute.Bool("push.full", req.Full),
+			attribute.Int64("push.queue_ms", time.Since(req.Start).Milliseconds()),
+		))
+}
+
+// startTypePushSpan starts a span of the push of a type to the connection. It is not recorded for responses to
+// requests, which are not part of a push, and nil is returned.
+func startTypePushSpan(name string, con *Connection, typeURL string, req *model.PushRequest) trace.Span {
+	if req == nil || !req.Span.IsValid() {
+		return nil
+	}
+	return model.StartSpan(req.Span, name, trace.WithAttributes(
+		attribute.String("proxy.id", con.proxy.ID),
+		attribute.String("xds.type", v3.GetShortType(typeURL)),
+	))
+}
+
+// endSpan ends the span, recording the error if any.
+func endSpan(span trace.Span, err error) {
+	if span == nil {
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
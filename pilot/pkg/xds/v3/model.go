@@ -30,11 +30,16 @@ const (
 	RouteType                  = resource.RouteType
 	SecretType                 = resource.SecretType
 	ExtensionConfigurationType = resource.ExtensionConfigType
+	VirtualHostType            = apiTypePrefix + "envoy.config.route.v3.VirtualHost"
 
 	NameTableType  = apiTypePrefix + "istio.networking.nds.v1.NameTable"
 	HealthInfoType = apiTypePrefix + "istio.v1.HealthInformation"
 )
 
+// ShardRedirectTrailer is the trailer of the XDS streams closed by istiod because the proxy is assigned to another
+// replica, holding the IP of that replica.
+const ShardRedirectTrailer = "x-istiod-shard"
+
 // GetShortType returns an abbreviated form of a type, useful for logging or human friendly messages
 func GetShortType(typeURL string) string {
 	switch typeURL {
@@ -44,6 +49,8 @@ func GetShortType(typeURL string) string {
 		return "LDS"
 	case RouteType:
 		return "RDS"
+	case VirtualHostType:
+		return "VHDS"
 	case EndpointType:
 		return "EDS"
 	case SecretType:
@@ -64,6 +71,8 @@ func GetMetricType(typeURL string) string {
 		return "lds"
 	case RouteType:
 		return "rds"
+	case VirtualHostType:
+		return "vhds"
 	case EndpointType:
 		return "eds"
 	case SecretType:
@@ -0,0 +1,225 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"strings"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+)
+
+// vhdsMissingVersion is the version of the placeholder resource telling Envoy a requested host has no
+// virtual host.
+const vhdsMissingVersion = "missing"
+
+// VhdsGenerator generates the virtual hosts of sidecars using on demand routes. Envoy requests them
+// as "<route configuration name>/<host>" when it first sees a host, and gets the virtual host that the
+// complete route configuration would have selected for it.
+type VhdsGenerator struct {
+	Server *DiscoveryServer
+}
+
+var _ model.XdsResourceGenerator = &VhdsGenerator{}
+
+func (c VhdsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource, req *model.PushRequest) (model.Resources, error) {
+	if !usesOnDemandRoutes(proxy) || !rdsNeedsPush(req) {
+		return nil, nil
+	}
+	routeNames := make([]string, 0)
+	hostsByRoute := map[string][]string{}
+	for _, name := range w.ResourceNames {
+		routeName, host, ok := splitVhdsResourceName(name)
+		if !ok {
+			continue
+		}
+		if _, f := hostsByRoute[routeName]; !f {
+			routeNames = append(routeNames, routeName)
+		}
+		hostsByRoute[routeName] = append(hostsByRoute[routeName], host)
+	}
+
+	resources := model.Resources{}
+	for _, rc := range c.Server.ConfigGenerator.BuildHTTPRoutes(proxy, push, routeNames) {
+		added := map[string]struct{}{}
+		for _, host := range hostsByRoute[rc.Name] {
+			vh := matchVirtualHost(rc.VirtualHosts, host)
+			if vh == nil {
+				continue
+			}
+			if _, f := added[vh.Name]; f {
+				continue
+			}
+			added[vh.Name] = struct{}{}
+			// Virtual host names only need to be unique within a route configuration.
+			out := proto.Clone(vh).(*route.VirtualHost)
+			out.Name = rc.Name + "/" + vh.Name
+			resources = append(resources, util.MessageToAny(out))
+		}
+	}
+	return resources, nil
+}
+
+// usesOnDemandRoutes returns true if the proxy fetches virtual hosts with VHDS.
+func usesOnDemandRoutes(proxy *model.Proxy) bool {
+	return proxy.Type == model.SidecarProxy && proxy.Metadata != nil && bool(proxy.Metadata.OnDemandRoutes)
+}
+
+// onDemandRouteConfig strips the virtual hosts from a route configuration, and makes Envoy fetch them
+// with VHDS instead.
+func onDemandRouteConfig(rc *route.RouteConfiguration) *route.RouteConfiguration {
+	rc.VirtualHosts = nil
+	rc.Vhds = &route.Vhds{
+		ConfigSource: &core.ConfigSource{
+			ConfigSourceSpecifier: &core.ConfigSource_ApiConfigSource{
+				ApiConfigSource: &core.ApiConfigSource{
+					// VHDS is only available with the delta protocol.
+					ApiType:             core.ApiConfigSource_DELTA_GRPC,
+					TransportApiVersion: core.ApiVersion_V3,
+					GrpcServices: []*core.GrpcService{
+						{
+							TargetSpecifier: &core.GrpcService_EnvoyGrpc_{
+								EnvoyGrpc: &core.GrpcService_EnvoyGrpc{ClusterName: "xds-grpc"},
+							},
+						},
+					},
+				},
+			},
+			ResourceApiVersion: core.ApiVersion_V3,
+		},
+	}
+	return rc
+}
+
+// splitVhdsResourceName splits a VHDS resource name into the route configuration name and the host.
+func splitVhdsResourceName(name string) (string, string, bool) {
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// matchVirtualHost returns the virtual host Envoy selects for the host: an exact domain first, then the
+// longest suffix wildcard, then the longest prefix wildcard, and finally "*".
+func matchVirtualHost(vhosts []*route.VirtualHost, host string) *route.VirtualHost {
+	host = strings.ToLower(host)
+	var suffix, prefix, wildcard *route.VirtualHost
+	suffixLen, prefixLen := 0, 0
+	for _, vh := range vhosts {
+		for _, d := range vh.Domains {
+			switch {
+			case d == host:
+				return vh
+			case d == "*":
+				if wildcard == nil {
+					wildcard = vh
+				}
+			case strings.HasPrefix(d, "*"):
+				if len(host) > len(d)-1 && strings.HasSuffix(host, d[1:]) && len(d) > suffixLen {
+					suffix, suffixLen = vh, len(d)
+				}
+			case strings.HasSuffix(d, "*"):
+				if len(host) > len(d)-1 && strings.HasPrefix(host, d[:len(d)-1]) && len(d) > prefixLen {
+					prefix, prefixLen = vh, len(d)
+				}
+			}
+		}
+	}
+	if suffix != nil {
+		return suffix
+	}
+	if prefix != nil {
+		return prefix
+	}
+	return wildcard
+}
+
+// vhdsResources turns generated virtual hosts into delta resources. Each virtual host carries the
+// requested names resolving to it as aliases, which Envoy uses to resume the requests waiting for it.
+// Requested names without a virtual host get a resource without payload, so Envoy does not keep
+// waiting for them.
+func vhdsResources(res model.Resources, subscribed []string) []*discovery.Resource {
+	vhosts := make([]*route.VirtualHost, 0, len(res))
+	byRoute := map[string][]*route.VirtualHost{}
+	for _, r := range res {
+		vh := &route.VirtualHost{}
+		if err := ptypes.UnmarshalAny(r, vh); err != nil {
+			adsLog.Warnf("VHDS: failed to read virtual host: %v", err)
+			vhosts = append(vhosts, nil)
+			continue
+		}
+		vhosts = append(vhosts, vh)
+		routeName, _, _ := splitVhdsResourceName(vh.Name)
+		byRoute[routeName] = append(byRoute[routeName], vh)
+	}
+
+	aliases := map[string][]string{}
+	var missing []string
+	for _, name := range subscribed {
+		routeName, host, ok := splitVhdsResourceName(name)
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		vh := matchVirtualHost(byRoute[routeName], host)
+		if vh == nil {
+			missing = append(missing, name)
+			continue
+		}
+		aliases[vh.Name] = append(aliases[vh.Name], name)
+	}
+
+	resources := make([]*discovery.Resource, 0, len(res)+len(missing))
+	for i, r := range res {
+		vh := vhosts[i]
+		if vh == nil {
+			continue
+		}
+		resources = append(resources, &discovery.Resource{
+			Name: vh.Name,
+			// Aliases are part of the version, so newly resolved names are sent to Envoy.
+			Version:  resourceVersion(r, aliases[vh.Name]...),
+			Aliases:  aliases[vh.Name],
+			Resource: r,
+		})
+	}
+	for _, name := range missing {
+		resources = append(resources, &discovery.Resource{
+			Name:    name,
+			Version: vhdsMissingVersion,
+			Aliases: []string{name},
+		})
+	}
+	return resources
+}
+
+// evictOnDemandHosts bounds the number of hosts a sidecar keeps virtual hosts for, dropping the ones
+// requested first. Envoy requests them again on their next use.
+func evictOnDemandHosts(names []string) []string {
+	max := features.OnDemandRoutesMaxHosts
+	if max <= 0 || len(names) <= max {
+		return names
+	}
+	adsLog.Debugf("VHDS: evicting %d hosts", len(names)-max)
+	return names[len(names)-max:]
+}
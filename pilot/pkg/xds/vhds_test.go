@@ -0,0 +1,111 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds_test
+
+import (
+	"reflect"
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/golang/protobuf/ptypes"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+const vhdsHostname = "svc.vhds.example.com"
+
+func newVhdsServer(t *testing.T) *xds.FakeDiscoveryServer {
+	return xds.NewFakeDiscoveryServer(t, xds.FakeOptions{ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: vhds
+  namespace: default
+spec:
+  hosts:
+  - ` + vhdsHostname + `
+  ports:
+  - number: 2080
+    name: http
+    protocol: HTTP
+  resolution: STATIC
+  endpoints:
+  - address: 1.1.1.1
+`})
+}
+
+func TestOnDemandRouteConfig(t *testing.T) {
+	s := newVhdsServer(t)
+	ads := s.ConnectADS().WithType(v3.RouteType).WithMetadata(model.NodeMetadata{OnDemandRoutes: true})
+	res := ads.RequestResponseAck(&discovery.DiscoveryRequest{ResourceNames: []string{"2080"}})
+	rc := &route.RouteConfiguration{}
+	if err := ptypes.UnmarshalAny(res.Resources[0], rc); err != nil {
+		t.Fatal(err)
+	}
+	if len(rc.VirtualHosts) != 0 {
+		t.Errorf("expected no virtual hosts, got %v", len(rc.VirtualHosts))
+	}
+	if rc.Vhds.GetConfigSource().GetApiConfigSource().GetApiType() != core.ApiConfigSource_DELTA_GRPC {
+		t.Errorf("expected delta VHDS config source, got %v", rc.Vhds)
+	}
+}
+
+func TestVHDS(t *testing.T) {
+	s := newVhdsServer(t)
+	client := connectDelta(t, s)
+
+	subscribe := func(names ...string) *discovery.DeltaDiscoveryResponse {
+		t.Helper()
+		if err := client.Send(&discovery.DeltaDiscoveryRequest{
+			Node: &core.Node{
+				Id:       "sidecar~1.1.1.1~test.default~default.svc.cluster.local",
+				Metadata: model.NodeMetadata{OnDemandRoutes: true}.ToStruct(),
+			},
+			TypeUrl:                v3.VirtualHostType,
+			ResourceNamesSubscribe: names,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return expectDeltaResponse(t, client)
+	}
+	expectResource := func(resp *discovery.DeltaDiscoveryResponse, name string, aliases ...string) {
+		t.Helper()
+		if len(resp.Resources) != 1 {
+			t.Fatalf("expected one virtual host, got %v", resp.Resources)
+		}
+		r := resp.Resources[0]
+		if r.Name != name {
+			t.Errorf("expected virtual host %v, got %v", name, r.Name)
+		}
+		if !reflect.DeepEqual(r.Aliases, aliases) {
+			t.Errorf("expected aliases %v, got %v", aliases, r.Aliases)
+		}
+	}
+
+	expectResource(subscribe("2080/"+vhdsHostname+":2080"),
+		"2080/"+vhdsHostname+":2080", "2080/"+vhdsHostname+":2080")
+
+	// Another name for the same virtual host resends it with both aliases.
+	expectResource(subscribe("2080/"+vhdsHostname),
+		"2080/"+vhdsHostname+":2080", "2080/"+vhdsHostname+":2080", "2080/"+vhdsHostname)
+
+	// Unknown hosts resolve to the catch all virtual host.
+	expectResource(subscribe("2080/unknown.example.com"),
+		"2080/allow_any", "2080/unknown.example.com")
+}
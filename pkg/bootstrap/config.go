@@ -185,6 +185,8 @@ var DefaultStatTags = []string{
 	"destination_canonical_service",
 	"source_canonical_revision",
 	"destination_canonical_revision",
+	"authz_dry_run_allow_result",
+	"authz_dry_run_deny_result",
 }
 
 func getStatsOptions(meta *model.BootstrapNodeMetadata, nodeIPs []string, config *meshAPI.ProxyConfig) []option.Instance {
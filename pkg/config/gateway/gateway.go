@@ -21,7 +21,7 @@ import (
 
 // IsTLSServer returns true if this server is non HTTP, with some TLS settings for termination/passthrough
 func IsTLSServer(server *v1alpha3.Server) bool {
-	if server.Tls != nil && !protocol.Parse(server.Port.Protocol).IsHTTP() {
+	if server.Tls != nil && !protocol.ParseGatewayProtocol(server.Port.Protocol).IsHTTP() {
 		return true
 	}
 	return false
@@ -29,18 +29,23 @@ func IsTLSServer(server *v1alpha3.Server) bool {
 
 // IsHTTPServer returns true if this server is using HTTP or HTTPS with termination
 func IsHTTPServer(server *v1alpha3.Server) bool {
-	p := protocol.Parse(server.Port.Protocol)
+	p := protocol.ParseGatewayProtocol(server.Port.Protocol)
 	if p.IsHTTP() {
 		return true
 	}
 
-	if p == protocol.HTTPS && server.Tls != nil && !IsPassThroughServer(server) {
+	if (p == protocol.HTTPS || p == protocol.HTTP3) && server.Tls != nil && !IsPassThroughServer(server) {
 		return true
 	}
 
 	return false
 }
 
+// IsHTTP3Server returns true if this server is using HTTP3 with termination, i.e. HTTPS also served over QUIC
+func IsHTTP3Server(server *v1alpha3.Server) bool {
+	return protocol.ParseGatewayProtocol(server.Port.Protocol) == protocol.HTTP3 && IsHTTPServer(server)
+}
+
 // IsPassThroughServer returns true if this server does TLS passthrough (auto or manual)
 func IsPassThroughServer(server *v1alpha3.Server) bool {
 	if server.Tls == nil {
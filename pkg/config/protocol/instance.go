@@ -35,6 +35,9 @@ const (
 	HTTP2 Instance = "HTTP2"
 	// HTTPS declares that the port carries HTTPS traffic.
 	HTTPS Instance = "HTTPS"
+	// HTTP3 declares that the port carries HTTPS traffic, also served over QUIC.
+	// Note that this is currently applicable only for gateway servers.
+	HTTP3 Instance = "HTTP3"
 	// Thrift declares that the port carries Thrift traffic.
 	Thrift Instance = "Thrift"
 	// TCP declares the the port uses TCP.
@@ -90,6 +93,15 @@ func Parse(s string) Instance {
 	return Unsupported
 }
 
+// ParseGatewayProtocol parses the protocol of a gateway server port ignoring case. Unlike Parse, it accepts HTTP3,
+// which is only supported on gateway servers, so that service ports named with an http3 prefix are not affected.
+func ParseGatewayProtocol(s string) Instance {
+	if strings.EqualFold(s, string(HTTP3)) {
+		return HTTP3
+	}
+	return Parse(s)
+}
+
 // IsHTTP2 is true for protocols that use HTTP/2 as transport protocol
 func (i Instance) IsHTTP2() bool {
 	switch i {
@@ -133,7 +145,7 @@ func (i Instance) IsTCP() bool {
 // IsTLS is true for protocols on top of TLS (e.g. HTTPS)
 func (i Instance) IsTLS() bool {
 	switch i {
-	case HTTPS, TLS:
+	case HTTPS, HTTP3, TLS:
 		return true
 	default:
 		return false
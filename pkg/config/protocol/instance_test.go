@@ -42,6 +42,8 @@ func TestParse(t *testing.T) {
 		{"Http_Proxy", protocol.HTTP_PROXY},
 		{"HTTP_PROXY", protocol.HTTP_PROXY},
 		{"https", protocol.HTTPS},
+		// HTTP3 is only supported on gateway servers.
+		{"http3", protocol.Unsupported},
 		{"http2", protocol.HTTP2},
 		{"grpc", protocol.GRPC},
 		{"grpc-web", protocol.GRPCWeb},
@@ -75,3 +77,24 @@ func TestParse(t *testing.T) {
 		})
 	}
 }
+
+func TestParseGatewayProtocol(t *testing.T) {
+	testPairs := []struct {
+		name string
+		out  protocol.Instance
+	}{
+		{"http3", protocol.HTTP3},
+		{"HTTP3", protocol.HTTP3},
+		{"https", protocol.HTTPS},
+		{"tcp", protocol.TCP},
+		{"SMTP", protocol.Unsupported},
+	}
+
+	for _, testPair := range testPairs {
+		t.Run(testPair.name, func(t *testing.T) {
+			if out := protocol.ParseGatewayProtocol(testPair.name); out != testPair.out {
+				t.Fatalf("ParseGatewayProtocol(%q) => %q, want %q", testPair.name, out, testPair.out)
+			}
+		})
+	}
+}
@@ -0,0 +1,1118 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package routing holds the helpers shared by the validation and the generation of the traffic settings set with
+// annotations on networking resources.
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gogo/protobuf/jsonpb"
+	"github.com/gogo/protobuf/proto"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/spiffe"
+)
+
+// ConnectionPoolAnnotation overrides the connection pool settings of the destinations of the named HTTP routes of
+// a VirtualService. The value is a JSON object mapping HTTP route names to ConnectionPoolSettings, for example
+// {"chatty": {"http": {"maxRequestsPerConnection": 1, "maxRetries": 10}}}. The settings override the ones of the
+// DestinationRule for the traffic of the route only.
+const ConnectionPoolAnnotation = "networking.istio.io/routeConnectionPool"
+
+// RetryPolicyAnnotation extends the retry policy of the named HTTP routes of a VirtualService. The value is a JSON
+// object mapping HTTP route names to RetryPolicy, for example {"checkout": {"budget": {"percent": 20,
+// "minRetryConcurrency": 3}, "retriableHeaders": {"x-retry": {"exact": "true"}}}}. The budget limits the
+// concurrent retries to the destinations of the route, so that retries can't amplify an overload. The hedge policy,
+// for example {"checkout": {"hedge": {"hedgeOnPerTryTimeout": true}}}, sends speculative retries of the requests of
+// latency sensitive routes.
+const RetryPolicyAnnotation = "networking.istio.io/routeRetryPolicy"
+
+// MirrorsAnnotation adds mirrors to the named HTTP routes of a VirtualService, each with its own percentage of the
+// traffic. The value is a JSON object mapping HTTP route names to lists of Mirror, for example
+// {"checkout": [{"destination": {"host": "checkout.staging"}, "percentage": 10}, {"destination": {"host":
+// "checkout.test"}}]}. The mirrors are added to the mirror of the route, if any.
+const MirrorsAnnotation = "networking.istio.io/routeMirrors"
+
+// ActiveRequestBiasAnnotation sets the active request bias of the LEAST_CONN load balancer of the clusters of a
+// DestinationRule. Higher values favor the endpoints with less active requests more aggressively, which helps
+// services with highly variable request cost. The value is a comma separated list of biases, each optionally
+// prefixed by the subset it applies to, for example "1.5,v1=2". A bias without subset applies to the host and to
+// the subsets without their own bias.
+const ActiveRequestBiasAnnotation = "networking.istio.io/activeRequestBias"
+
+// LocalOriginOutlierDetectionAnnotation splits the local origin errors, such as connect timeouts and resets, from
+// the errors reported by the upstream hosts in the outlier detection of the clusters of a DestinationRule. The
+// consecutive5xxErrors and consecutiveGatewayErrors of the outlier detection then only count the responses of the
+// hosts, and a host is ejected after the number of consecutive local origin failures set by the annotation. The
+// value is a comma separated list of failure counts, each optionally prefixed by the subset it applies to, for
+// example "5,v1=3". A count without subset applies to the host and to the subsets without their own count.
+const LocalOriginOutlierDetectionAnnotation = "networking.istio.io/localOriginOutlierDetection"
+
+// UpstreamProxyProtocolAnnotation sends a PROXY protocol header with the addresses of the downstream connection at
+// the start of the connections to the hosts of a DestinationRule, preserving the address of the clients through the
+// TCP load balancers only speaking the PROXY protocol. The value is the version of the protocol, "V1" or "V2".
+const UpstreamProxyProtocolAnnotation = "networking.istio.io/upstreamProxyProtocol"
+
+// IgnoreHealthOnHostRemovalAnnotation sets whether the endpoints removed from service discovery are removed from the
+// clusters of a DestinationRule immediately, even if they still pass the active health checks, overriding the
+// PILOT_CLUSTER_IGNORE_HEALTH_ON_HOST_REMOVAL default of the mesh. The value is "true" or "false".
+const IgnoreHealthOnHostRemovalAnnotation = "networking.istio.io/ignoreHealthOnHostRemoval"
+
+// PortResolutionAnnotation overrides the resolution of individual ports of a ServiceEntry. The value is a comma
+// separated list of port numbers and resolutions, for example "443=NONE,80=DNS". The ports without override keep
+// the resolution of the ServiceEntry.
+const PortResolutionAnnotation = "networking.istio.io/portResolution"
+
+// PortRangesAnnotation extends ports of a ServiceEntry, or egress listeners of a Sidecar, to ranges of ports, so that
+// the protocols using many ports, such as passive FTP or SIP media, can be modeled without declaring each port. The
+// value is a comma separated list of ranges, for example "30000-32767". Each range starts at a declared port, which
+// is repeated for each other port of the range, with the same settings and its name suffixed by the port number.
+const PortRangesAnnotation = "networking.istio.io/portRanges"
+
+// MaxPortRangePorts limits the number of ports added by the ranges of PortRangesAnnotation, as each of them gets
+// its own listener and cluster.
+const MaxPortRangePorts = 4096
+
+// FailoverPriorityAnnotation prioritizes the endpoints of the clusters of a DestinationRule by labels, instead of the
+// region/zone/subzone hierarchy of the locality failover. The value is an ordered comma separated list of label keys,
+// for example "topology.istio.io/network,topology.kubernetes.io/zone,example.com/rack". The endpoints with the same
+// values as the proxy for all the labels get the highest priority, then those with the same values for all but the
+// last label, and so on. The topology.kubernetes.io/region, topology.kubernetes.io/zone, topology.istio.io/subzone and
+// topology.istio.io/network labels are those of the locality and network of the endpoints and proxies. As the
+// locality failover, it only applies with outlier detection and locality load balancing, and it replaces the
+// failover settings of the locality load balancing.
+const FailoverPriorityAnnotation = "networking.istio.io/failoverPriority"
+
+// FilterOrderAnnotation pins the position of filters relative to other filters of the listeners of the workloads
+// selected by an EnvoyFilter, so that the filters added by EnvoyFilters keep their position relative to the filters
+// of Istio across upgrades. The value is a comma separated list of constraints, each a filter name, "before" or
+// "after", and another filter name, for example "my.wasm.authz before envoy.filters.http.rbac, istio.stats after
+// my.wasm.authz". The constraints of all the EnvoyFilters of a workload are resolved in the order the EnvoyFilters
+// are applied, those contradicting the previous ones being ignored. The terminal filters always stay last.
+const FilterOrderAnnotation = "networking.istio.io/filterOrder"
+
+// RateLimitAnnotation enables the global rate limiting of the HTTP servers of a Gateway by an external rate limit
+// service, without EnvoyFilters. The value is a JSON object, for example {"domain": "edge", "service":
+// "ratelimit.ratelimit.svc.cluster.local:8081", "timeout": "50ms", "descriptors": [[{"key": "path", "header":
+// ":path"}], [{"remoteAddress": true}]]}. Each descriptor sent to the service is composed of entries, each with the
+// value of a request header, the address of the client or a fixed value, and a request is rejected if the service
+// limits any of them. The servers of a Gateway sharing a port with the servers of other Gateways get the rate
+// limiting of the oldest one setting it.
+const RateLimitAnnotation = "networking.istio.io/rateLimit"
+
+// LocalRateLimitAnnotation limits the rate of the inbound traffic of the workloads selected by a Sidecar, with token
+// buckets local to each proxy: the requests of their HTTP ports, and the connections of their TCP ports. The value is
+// a JSON object, for example {"maxTokens": 100, "tokensPerFill": 100, "fillInterval": "1s", "responseHeaders":
+// {"x-local-rate-limit": "true"}, "descriptors": [{"entries": [{"key": "user", "header": "x-user", "value": "batch"}],
+// "maxTokens": 10, "fillInterval": "1s"}]}. The requests matching all the entries of a descriptor use the bucket of
+// the descriptor instead of the default one. The rejected requests get a 429 response with the response headers.
+const LocalRateLimitAnnotation = "networking.istio.io/localRateLimit"
+
+// RouteLocalRateLimitAnnotation limits the rate of the requests of the named HTTP routes of a VirtualService, with
+// token buckets local to each proxy. The value is a JSON object mapping HTTP route names to local rate limits, as the
+// value of LocalRateLimitAnnotation.
+const RouteLocalRateLimitAnnotation = "networking.istio.io/routeLocalRateLimit"
+
+// RouteTimeoutsAnnotation sets the stream timeouts of the named HTTP routes of a VirtualService. The value is a JSON
+// object mapping HTTP route names to RouteTimeouts, for example {"events": {"idleTimeout": "5m",
+// "maxStreamDuration": "0s"}}. A zero duration disables the timeout, so that long polling and server sent events
+// routes can coexist with strict defaults on the same service.
+const RouteTimeoutsAnnotation = "networking.istio.io/routeTimeouts"
+
+// SubjectAltNamesAnnotation pins the identities of the endpoints of the named subsets of a DestinationRule. The value
+// is a JSON object mapping subset names to lists of subject alternative names, for example {"canary":
+// ["spiffe://cluster.local/ns/default/sa/reviews-canary"]}. The names replace the service accounts of the service in
+// the validation of the ISTIO_MUTUAL connections to the subset, whether set explicitly or by auto mTLS, so that the
+// traffic of a subset can only reach the endpoints running with its identities.
+const SubjectAltNamesAnnotation = "networking.istio.io/subsetSubjectAltNames"
+
+// ProtocolDetectionAnnotation overrides the protocol detection of individual ports of the outbound listeners of the
+// workloads of a Sidecar. The value is a comma separated list of port numbers, each with a detection timeout, a
+// fallback protocol or both, for example "3306=100ms,9000=5s/TCP,8000=/HTTP". The timeout replaces the protocol
+// detection timeout of the mesh, and a zero timeout disables it. The fallback protocol is the protocol of the
+// plaintext traffic whose protocol is not detected, TCP by default.
+const ProtocolDetectionAnnotation = "networking.istio.io/protocolDetection"
+
+// RegexRewriteAnnotation rewrites the path of the requests of the named HTTP routes of a VirtualService with a
+// regular expression. The value is a JSON object mapping HTTP route names to RegexRewrite, for example {"api":
+// {"match": "^/api/v1/(.*)$", "substitution": "/\\1"}}. The match is an RE2 regular expression, and the
+// substitution can refer to its capture groups with \1 to \9. It replaces the uri rewrite of the route, which can't
+// be set as well.
+const RegexRewriteAnnotation = "networking.istio.io/routeRegexRewrite"
+
+// AccessLogAnnotation overrides the access logging of the workloads of a Sidecar, so that workloads and namespaces
+// can log with their own format or providers instead of those of the mesh. The value is a JSON object with the
+// access log providers, among "file" and "envoy-als", and the path, encoding and format of the file access log, for
+// example {"providers": ["file"], "encoding": "JSON", "format": "{\"path\": \"%REQ(:PATH)%\"}"}. The settings
+// not set keep those of the mesh, and an empty list of providers disables the access logging.
+const AccessLogAnnotation = "networking.istio.io/accessLog"
+
+// The access log providers of AccessLogAnnotation.
+const (
+	// AccessLogProviderFile logs to the file of the access log, /dev/stdout if neither the workload nor the mesh
+	// sets one.
+	AccessLogProviderFile = "file"
+	// AccessLogProviderEnvoyALS logs to the Envoy access log service of the mesh.
+	AccessLogProviderEnvoyALS = "envoy-als"
+)
+
+// MinFillInterval is the shortest fill interval of the token buckets of the local rate limits supported by Envoy.
+const MinFillInterval = 50 * time.Millisecond
+
+// terminalFilters are the filters terminating the HTTP and network filter chains, which can't be ordered before
+// another filter.
+var terminalFilters = map[string]bool{
+	"envoy.filters.http.router":                     true,
+	"envoy.router":                                  true,
+	"envoy.filters.network.http_connection_manager": true,
+	"envoy.http_connection_manager":                 true,
+	"envoy.filters.network.tcp_proxy":               true,
+	"envoy.tcp_proxy":                               true,
+}
+
+// subsetSeparator separates the subset of the destination from the route in the subset of a route cluster.
+// It can't be part of a subset name, which must be a DNS label.
+const subsetSeparator = "~"
+
+// ParseConnectionPools parses the value of ConnectionPoolAnnotation into the connection pool settings of each
+// HTTP route name.
+func ParseConnectionPools(value string) (map[string]*networking.ConnectionPoolSettings, error) {
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(value), &raw); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", ConnectionPoolAnnotation, err)
+	}
+	res := make(map[string]*networking.ConnectionPoolSettings, len(raw))
+	for name, v := range raw {
+		settings := &networking.ConnectionPoolSettings{}
+		if err := jsonpb.UnmarshalString(string(v), settings); err != nil {
+			return nil, fmt.Errorf("invalid %s for route %q: %v", ConnectionPoolAnnotation, name, err)
+		}
+		res[name] = settings
+	}
+	return res, nil
+}
+
+// RetryPolicy is the retry policy of an HTTP route set with RetryPolicyAnnotation.
+type RetryPolicy struct {
+	// Budget limits the concurrent retries to the destinations of the route. It replaces the maxRetries of the
+	// connection pool of the destinations for the traffic of the route.
+	Budget *RetryBudget
+	// RetriableHeaders are the response headers triggering a retry, keyed by header name.
+	RetriableHeaders map[string]*networking.StringMatch
+	// RetriableRequestHeaders are the request headers required for a request to be retried, keyed by header name.
+	RetriableRequestHeaders map[string]*networking.StringMatch
+	// Hedge is the hedge policy of the route, if any.
+	Hedge *HedgePolicy
+}
+
+// HedgePolicy sends speculative requests to the destinations of a route to lower its tail latency.
+type HedgePolicy struct {
+	// InitialRequests is the number of requests initially sent to the destinations, 1 if not set.
+	InitialRequests uint32 `json:"initialRequests,omitempty"`
+	// HedgeOnPerTryTimeout sends a retry when the per try timeout of a request expires, without cancelling the
+	// request: the first response of either is used. It requires a per try timeout on the retries of the route.
+	HedgeOnPerTryTimeout bool `json:"hedgeOnPerTryTimeout,omitempty"`
+}
+
+// RetryBudget limits the concurrent retries to a percentage of the active and pending requests.
+type RetryBudget struct {
+	// Percent is the limit of the concurrent retries, as a percentage of the active and pending requests.
+	Percent float64 `json:"percent"`
+	// MinRetryConcurrency is the number of concurrent retries always allowed, regardless of Percent.
+	MinRetryConcurrency uint32 `json:"minRetryConcurrency,omitempty"`
+}
+
+// ParseRetryPolicies parses the value of RetryPolicyAnnotation into the retry policy of each HTTP route name.
+func ParseRetryPolicies(value string) (map[string]*RetryPolicy, error) {
+	raw := map[string]struct {
+		Budget                  *RetryBudget               `json:"budget"`
+		RetriableHeaders        map[string]json.RawMessage `json:"retriableHeaders"`
+		RetriableRequestHeaders map[string]json.RawMessage `json:"retriableRequestHeaders"`
+		Hedge                   *HedgePolicy               `json:"hedge"`
+	}{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", RetryPolicyAnnotation, err)
+	}
+	res := make(map[string]*RetryPolicy, len(raw))
+	for name, v := range raw {
+		policy := &RetryPolicy{Budget: v.Budget, Hedge: v.Hedge}
+		if b := policy.Budget; b != nil && (b.Percent <= 0 || b.Percent > 100) {
+			return nil, fmt.Errorf("invalid %s for route %q: budget percent must be in (0, 100]", RetryPolicyAnnotation, name)
+		}
+		if h := policy.Hedge; h != nil && h.InitialRequests == 0 && !h.HedgeOnPerTryTimeout {
+			return nil, fmt.Errorf("invalid %s for route %q: empty hedge policy", RetryPolicyAnnotation, name)
+		}
+		var err error
+		if policy.RetriableHeaders, err = parseHeaderMatches(v.RetriableHeaders); err != nil {
+			return nil, fmt.Errorf("invalid %s retriable headers for route %q: %v", RetryPolicyAnnotation, name, err)
+		}
+		if policy.RetriableRequestHeaders, err = parseHeaderMatches(v.RetriableRequestHeaders); err != nil {
+			return nil, fmt.Errorf("invalid %s retriable request headers for route %q: %v", RetryPolicyAnnotation, name, err)
+		}
+		res[name] = policy
+	}
+	return res, nil
+}
+
+// parseHeaderMatches parses header matches keyed by header name. A match without value only requires the header
+// to be present.
+func parseHeaderMatches(raw map[string]json.RawMessage) (map[string]*networking.StringMatch, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	res := make(map[string]*networking.StringMatch, len(raw))
+	for name, v := range raw {
+		match := &networking.StringMatch{}
+		if s := string(v); s != "null" {
+			if err := jsonpb.UnmarshalString(s, match); err != nil {
+				return nil, fmt.Errorf("header %q: %v", name, err)
+			}
+		}
+		res[name] = match
+	}
+	return res, nil
+}
+
+// Mirror is a mirror of an HTTP route set with MirrorsAnnotation.
+type Mirror struct {
+	Destination *networking.Destination
+	// Percentage is the percentage of the traffic mirrored, or nil to mirror all of it.
+	Percentage *networking.Percent
+}
+
+// ParseMirrors parses the value of MirrorsAnnotation into the mirrors of each HTTP route name.
+func ParseMirrors(value string) (map[string][]*Mirror, error) {
+	raw := map[string][]struct {
+		Destination json.RawMessage `json:"destination"`
+		Percentage  *float64        `json:"percentage"`
+	}{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", MirrorsAnnotation, err)
+	}
+	res := make(map[string][]*Mirror, len(raw))
+	for name, mirrors := range raw {
+		for i, m := range mirrors {
+			if len(m.Destination) == 0 {
+				return nil, fmt.Errorf("invalid %s mirror %d of route %q: missing destination", MirrorsAnnotation, i, name)
+			}
+			mirror := &Mirror{Destination: &networking.Destination{}}
+			if err := jsonpb.UnmarshalString(string(m.Destination), mirror.Destination); err != nil {
+				return nil, fmt.Errorf("invalid %s mirror %d of route %q: %v", MirrorsAnnotation, i, name, err)
+			}
+			if m.Percentage != nil {
+				mirror.Percentage = &networking.Percent{Value: *m.Percentage}
+			}
+			res[name] = append(res[name], mirror)
+		}
+	}
+	return res, nil
+}
+
+// ParseActiveRequestBias parses the value of ActiveRequestBiasAnnotation into the bias of each subset. The bias of
+// the host is keyed by the empty subset.
+func ParseActiveRequestBias(value string) (map[string]float64, error) {
+	res := map[string]float64{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		subset, bias := "", entry
+		if i := strings.Index(entry, "="); i >= 0 {
+			subset, bias = strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+			if subset == "" {
+				return nil, fmt.Errorf("invalid %s entry %q: missing subset", ActiveRequestBiasAnnotation, entry)
+			}
+		}
+		b, err := strconv.ParseFloat(bias, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s entry %q: %v", ActiveRequestBiasAnnotation, entry, err)
+		}
+		if b < 0 {
+			return nil, fmt.Errorf("invalid %s entry %q: bias must be non-negative", ActiveRequestBiasAnnotation, entry)
+		}
+		if _, f := res[subset]; f {
+			return nil, fmt.Errorf("invalid %s: duplicate bias for subset %q", ActiveRequestBiasAnnotation, subset)
+		}
+		res[subset] = b
+	}
+	return res, nil
+}
+
+// ParseLocalOriginFailures parses the value of LocalOriginOutlierDetectionAnnotation into the consecutive local
+// origin failures of each subset. The failures of the host are keyed by the empty subset.
+func ParseLocalOriginFailures(value string) (map[string]uint32, error) {
+	res := map[string]uint32{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		subset, failures := "", entry
+		if i := strings.Index(entry, "="); i >= 0 {
+			subset, failures = strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+			if subset == "" {
+				return nil, fmt.Errorf("invalid %s entry %q: missing subset", LocalOriginOutlierDetectionAnnotation, entry)
+			}
+		}
+		f, err := strconv.ParseUint(failures, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s entry %q: %v", LocalOriginOutlierDetectionAnnotation, entry, err)
+		}
+		if _, dup := res[subset]; dup {
+			return nil, fmt.Errorf("invalid %s: duplicate failures for subset %q", LocalOriginOutlierDetectionAnnotation, subset)
+		}
+		res[subset] = uint32(f)
+	}
+	return res, nil
+}
+
+// ParseProxyProtocolVersion parses the value of UpstreamProxyProtocolAnnotation into the version of the PROXY
+// protocol, 1 or 2.
+func ParseProxyProtocolVersion(value string) (int, error) {
+	switch strings.ToUpper(strings.TrimSpace(value)) {
+	case "V1":
+		return 1, nil
+	case "V2":
+		return 2, nil
+	default:
+		return 0, fmt.Errorf("invalid %s %q: expected V1 or V2", UpstreamProxyProtocolAnnotation, value)
+	}
+}
+
+// ParseIgnoreHealthOnHostRemoval parses the value of IgnoreHealthOnHostRemovalAnnotation.
+func ParseIgnoreHealthOnHostRemoval(value string) (bool, error) {
+	ignore, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q: expected true or false", IgnoreHealthOnHostRemovalAnnotation, value)
+	}
+	return ignore, nil
+}
+
+// ParsePortResolutions parses the value of PortResolutionAnnotation into the resolution of each port number.
+func ParsePortResolutions(value string) (map[uint32]networking.ServiceEntry_Resolution, error) {
+	res := map[uint32]networking.ServiceEntry_Resolution{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		i := strings.Index(entry, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid %s entry %q: expected <port>=<resolution>", PortResolutionAnnotation, entry)
+		}
+		port, err := strconv.ParseUint(strings.TrimSpace(entry[:i]), 10, 32)
+		if err != nil || port == 0 || port > 65535 {
+			return nil, fmt.Errorf("invalid %s entry %q: invalid port", PortResolutionAnnotation, entry)
+		}
+		resolution, f := networking.ServiceEntry_Resolution_value[strings.ToUpper(strings.TrimSpace(entry[i+1:]))]
+		if !f {
+			return nil, fmt.Errorf("invalid %s entry %q: unknown resolution", PortResolutionAnnotation, entry)
+		}
+		if _, f := res[uint32(port)]; f {
+			return nil, fmt.Errorf("invalid %s: duplicate resolution for port %d", PortResolutionAnnotation, port)
+		}
+		res[uint32(port)] = networking.ServiceEntry_Resolution(resolution)
+	}
+	return res, nil
+}
+
+// ProtocolDetection is the protocol detection of a port set with ProtocolDetectionAnnotation.
+type ProtocolDetection struct {
+	// Timeout is the protocol detection timeout of the port, or nil to keep the one of the mesh.
+	Timeout *time.Duration
+	// FallbackHTTP is true if the plaintext traffic whose protocol is not detected is HTTP instead of TCP.
+	FallbackHTTP bool
+}
+
+// ParseProtocolDetection parses the value of ProtocolDetectionAnnotation into the protocol detection of each port.
+func ParseProtocolDetection(value string) (map[uint32]ProtocolDetection, error) {
+	res := map[uint32]ProtocolDetection{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		i := strings.Index(entry, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid %s entry %q: expected <port>=<timeout>[/<fallback>]", ProtocolDetectionAnnotation, entry)
+		}
+		port, err := strconv.ParseUint(strings.TrimSpace(entry[:i]), 10, 32)
+		if err != nil || port == 0 || port > 65535 {
+			return nil, fmt.Errorf("invalid %s entry %q: invalid port", ProtocolDetectionAnnotation, entry)
+		}
+		timeout, fallback := strings.TrimSpace(entry[i+1:]), ""
+		if j := strings.Index(timeout, "/"); j >= 0 {
+			timeout, fallback = strings.TrimSpace(timeout[:j]), strings.TrimSpace(timeout[j+1:])
+		}
+		if timeout == "" && fallback == "" {
+			return nil, fmt.Errorf("invalid %s entry %q: expected a timeout or a fallback protocol", ProtocolDetectionAnnotation, entry)
+		}
+		detection := ProtocolDetection{}
+		if timeout != "" {
+			d, err := time.ParseDuration(timeout)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("invalid %s entry %q: invalid timeout", ProtocolDetectionAnnotation, entry)
+			}
+			detection.Timeout = &d
+		}
+		switch strings.ToUpper(fallback) {
+		case "", "TCP":
+		case "HTTP":
+			detection.FallbackHTTP = true
+		default:
+			return nil, fmt.Errorf("invalid %s entry %q: the fallback protocol must be TCP or HTTP", ProtocolDetectionAnnotation, entry)
+		}
+		if _, f := res[uint32(port)]; f {
+			return nil, fmt.Errorf("invalid %s: duplicate protocol detection for port %d", ProtocolDetectionAnnotation, port)
+		}
+		res[uint32(port)] = detection
+	}
+	return res, nil
+}
+
+// RegexRewrite is the path rewrite of an HTTP route set with RegexRewriteAnnotation.
+type RegexRewrite struct {
+	// Match is the RE2 regular expression matching the portions of the path to rewrite.
+	Match string `json:"match"`
+	// Substitution replaces each match of the path, with \N referring to the N-th capture group of the match.
+	Substitution string `json:"substitution"`
+}
+
+// ParseRegexRewrites parses the value of RegexRewriteAnnotation into the path rewrite of each HTTP route name.
+func ParseRegexRewrites(value string) (map[string]*RegexRewrite, error) {
+	raw := map[string]*RegexRewrite{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", RegexRewriteAnnotation, err)
+	}
+	for name, rewrite := range raw {
+		if rewrite == nil || rewrite.Match == "" {
+			return nil, fmt.Errorf("invalid %s for route %q: missing match", RegexRewriteAnnotation, name)
+		}
+		re, err := regexp.Compile(rewrite.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s match for route %q: %v", RegexRewriteAnnotation, name, err)
+		}
+		for i := 0; i < len(rewrite.Substitution)-1; i++ {
+			if rewrite.Substitution[i] != '\\' {
+				continue
+			}
+			i++
+			if c := rewrite.Substitution[i]; c >= '0' && c <= '9' && int(c-'0') > re.NumSubexp() {
+				return nil, fmt.Errorf("invalid %s substitution for route %q: no capture group %c", RegexRewriteAnnotation,
+					name, c)
+			}
+		}
+	}
+	return raw, nil
+}
+
+// AccessLog is the access logging of the workloads of a Sidecar set with AccessLogAnnotation.
+type AccessLog struct {
+	// Providers are the access log providers of the workloads, or nil to keep those of the mesh.
+	Providers []string `json:"providers"`
+	// Path is the path of the file access log, or empty to keep the one of the mesh.
+	Path string `json:"path"`
+	// Encoding is the encoding of the file access log, TEXT or JSON, or empty to keep the one of the mesh.
+	Encoding string `json:"encoding"`
+	// Format is the format of the file access log, or empty to keep the one of the mesh. The format of the JSON
+	// encoding is a JSON object.
+	Format string `json:"format"`
+}
+
+// ParseAccessLog parses the value of AccessLogAnnotation.
+func ParseAccessLog(value string) (*AccessLog, error) {
+	res := &AccessLog{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(res); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", AccessLogAnnotation, err)
+	}
+	providers := map[string]bool{}
+	for _, provider := range res.Providers {
+		if provider != AccessLogProviderFile && provider != AccessLogProviderEnvoyALS {
+			return nil, fmt.Errorf("invalid %s: unknown provider %q", AccessLogAnnotation, provider)
+		}
+		if providers[provider] {
+			return nil, fmt.Errorf("invalid %s: duplicate provider %q", AccessLogAnnotation, provider)
+		}
+		providers[provider] = true
+	}
+	res.Encoding = strings.ToUpper(res.Encoding)
+	switch res.Encoding {
+	case "", "TEXT":
+	case "JSON":
+		if res.Format != "" {
+			if err := json.Unmarshal([]byte(res.Format), &map[string]interface{}{}); err != nil {
+				return nil, fmt.Errorf("invalid %s: the JSON format must be a JSON object: %v", AccessLogAnnotation, err)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("invalid %s: the encoding must be TEXT or JSON", AccessLogAnnotation)
+	}
+	return res, nil
+}
+
+// ParseFailoverPriority parses the value of FailoverPriorityAnnotation into the label keys, by decreasing priority.
+func ParseFailoverPriority(value string) ([]string, error) {
+	var keys []string
+	seen := map[string]bool{}
+	for _, key := range strings.Split(value, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		if err := (labels.Instance{key: ""}).Validate(); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", FailoverPriorityAnnotation, err)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("invalid %s: duplicate label %q", FailoverPriorityAnnotation, key)
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("invalid %s: no label", FailoverPriorityAnnotation)
+	}
+	return keys, nil
+}
+
+// RateLimit is the global rate limiting set with RateLimitAnnotation.
+type RateLimit struct {
+	// Domain is the domain of the descriptors, selecting the limits configured in the rate limit service.
+	Domain string
+	// Host and Port are those of the gRPC rate limit service.
+	Host string
+	Port int
+	// Timeout is the timeout of the calls to the rate limit service.
+	Timeout time.Duration
+	// FailureModeDeny rejects the requests when the rate limit service is unavailable, instead of allowing them.
+	FailureModeDeny bool
+	// Descriptors are the descriptors sent to the rate limit service for each request.
+	Descriptors [][]RateLimitEntry
+}
+
+// RateLimitEntry is an entry of a rate limit descriptor. Exactly one of Header, RemoteAddress and Value is set in the
+// descriptors of the global rate limiting, see LocalRateLimitDescriptor for those of the local rate limiting.
+type RateLimitEntry struct {
+	// Key is the key of the entry. It is required with Header, and can't be set with RemoteAddress, whose key is
+	// always "remote_address".
+	Key string `json:"key,omitempty"`
+	// Header sets the value of the entry to the value of the request header. The descriptor isn't sent if the header
+	// is missing.
+	Header string `json:"header,omitempty"`
+	// RemoteAddress sets the value of the entry to the address of the client.
+	RemoteAddress bool `json:"remoteAddress,omitempty"`
+	// Value sets the value of the entry.
+	Value string `json:"value,omitempty"`
+}
+
+// DefaultRateLimitTimeout is the timeout of the calls to the rate limit service when not set.
+const DefaultRateLimitTimeout = 20 * time.Millisecond
+
+// ParseRateLimit parses the value of RateLimitAnnotation.
+func ParseRateLimit(value string) (*RateLimit, error) {
+	raw := struct {
+		Domain          string             `json:"domain"`
+		Service         string             `json:"service"`
+		Timeout         string             `json:"timeout"`
+		FailureModeDeny bool               `json:"failureModeDeny"`
+		Descriptors     [][]RateLimitEntry `json:"descriptors"`
+	}{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", RateLimitAnnotation, err)
+	}
+	if raw.Domain == "" {
+		return nil, fmt.Errorf("invalid %s: missing domain", RateLimitAnnotation)
+	}
+	res := &RateLimit{
+		Domain:          raw.Domain,
+		Timeout:         DefaultRateLimitTimeout,
+		FailureModeDeny: raw.FailureModeDeny,
+		Descriptors:     raw.Descriptors,
+	}
+	host, port, err := net.SplitHostPort(raw.Service)
+	if err != nil || host == "" {
+		return nil, fmt.Errorf("invalid %s service %q: expected <host>:<port>", RateLimitAnnotation, raw.Service)
+	}
+	res.Host = host
+	if res.Port, err = strconv.Atoi(port); err != nil || res.Port <= 0 || res.Port > 65535 {
+		return nil, fmt.Errorf("invalid %s service %q: invalid port", RateLimitAnnotation, raw.Service)
+	}
+	if raw.Timeout != "" {
+		if res.Timeout, err = time.ParseDuration(raw.Timeout); err != nil || res.Timeout <= 0 {
+			return nil, fmt.Errorf("invalid %s timeout %q: expected a positive duration", RateLimitAnnotation, raw.Timeout)
+		}
+	}
+	if len(res.Descriptors) == 0 {
+		return nil, fmt.Errorf("invalid %s: missing descriptors", RateLimitAnnotation)
+	}
+	for i, descriptor := range res.Descriptors {
+		if len(descriptor) == 0 {
+			return nil, fmt.Errorf("invalid %s descriptor %d: missing entries", RateLimitAnnotation, i)
+		}
+		for j, entry := range descriptor {
+			if err := validateRateLimitEntry(entry); err != nil {
+				return nil, fmt.Errorf("invalid %s entry %d of descriptor %d: %v", RateLimitAnnotation, j, i, err)
+			}
+		}
+	}
+	return res, nil
+}
+
+func validateRateLimitEntry(entry RateLimitEntry) error {
+	set := 0
+	for _, f := range []bool{entry.Header != "", entry.RemoteAddress, entry.Value != ""} {
+		if f {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("exactly one of header, remoteAddress and value must be set")
+	}
+	if entry.Header != "" && entry.Key == "" {
+		return fmt.Errorf("missing key")
+	}
+	if entry.RemoteAddress && entry.Key != "" {
+		return fmt.Errorf("key can't be set with remoteAddress")
+	}
+	return nil
+}
+
+// TokenBucket is a token bucket of a local rate limit.
+type TokenBucket struct {
+	// MaxTokens is the capacity of the bucket, which starts full.
+	MaxTokens uint32
+	// TokensPerFill are the tokens added to the bucket at each fill, 1 if not set.
+	TokensPerFill uint32
+	// FillInterval is the interval between the fills of the bucket.
+	FillInterval time.Duration
+}
+
+// LocalRateLimit is the local rate limiting set with LocalRateLimitAnnotation or RouteLocalRateLimitAnnotation.
+type LocalRateLimit struct {
+	TokenBucket
+	// Descriptors are the buckets of the requests matching their entries.
+	Descriptors []LocalRateLimitDescriptor
+	// ResponseHeaders are the headers added to the responses of the rejected requests.
+	ResponseHeaders map[string]string
+}
+
+// LocalRateLimitDescriptor is a bucket of the requests matching all its entries. The Value of each entry is the value
+// matched: the value of the request header with Header, the address of the client with RemoteAddress, and else any
+// request.
+type LocalRateLimitDescriptor struct {
+	Entries []RateLimitEntry
+	TokenBucket
+}
+
+type rawTokenBucket struct {
+	MaxTokens     uint32 `json:"maxTokens"`
+	TokensPerFill uint32 `json:"tokensPerFill"`
+	FillInterval  string `json:"fillInterval"`
+}
+
+type rawLocalRateLimit struct {
+	rawTokenBucket
+	Descriptors []struct {
+		Entries []RateLimitEntry `json:"entries"`
+		rawTokenBucket
+	} `json:"descriptors"`
+	ResponseHeaders map[string]string `json:"responseHeaders"`
+}
+
+// ParseLocalRateLimit parses the value of LocalRateLimitAnnotation.
+func ParseLocalRateLimit(value string) (*LocalRateLimit, error) {
+	raw := rawLocalRateLimit{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", LocalRateLimitAnnotation, err)
+	}
+	res, err := convertLocalRateLimit(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", LocalRateLimitAnnotation, err)
+	}
+	return res, nil
+}
+
+// ParseRouteLocalRateLimits parses the value of RouteLocalRateLimitAnnotation into the local rate limit of each HTTP
+// route name.
+func ParseRouteLocalRateLimits(value string) (map[string]*LocalRateLimit, error) {
+	raw := map[string]rawLocalRateLimit{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", RouteLocalRateLimitAnnotation, err)
+	}
+	res := make(map[string]*LocalRateLimit, len(raw))
+	for name, v := range raw {
+		rateLimit, err := convertLocalRateLimit(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s for route %q: %v", RouteLocalRateLimitAnnotation, name, err)
+		}
+		res[name] = rateLimit
+	}
+	return res, nil
+}
+
+func convertLocalRateLimit(raw rawLocalRateLimit) (*LocalRateLimit, error) {
+	res := &LocalRateLimit{ResponseHeaders: raw.ResponseHeaders}
+	var err error
+	if res.TokenBucket, err = convertTokenBucket(raw.rawTokenBucket); err != nil {
+		return nil, err
+	}
+	for i, d := range raw.Descriptors {
+		descriptor := LocalRateLimitDescriptor{Entries: d.Entries}
+		if descriptor.TokenBucket, err = convertTokenBucket(d.rawTokenBucket); err != nil {
+			return nil, fmt.Errorf("descriptor %d: %v", i, err)
+		}
+		// Envoy fills the buckets of the descriptors along the default one.
+		if descriptor.FillInterval%res.FillInterval != 0 {
+			return nil, fmt.Errorf("descriptor %d: fill interval must be a multiple of %v", i, res.FillInterval)
+		}
+		if len(descriptor.Entries) == 0 {
+			return nil, fmt.Errorf("descriptor %d: missing entries", i)
+		}
+		for j, entry := range descriptor.Entries {
+			if err := validateLocalRateLimitEntry(entry); err != nil {
+				return nil, fmt.Errorf("entry %d of descriptor %d: %v", j, i, err)
+			}
+		}
+		res.Descriptors = append(res.Descriptors, descriptor)
+	}
+	return res, nil
+}
+
+func convertTokenBucket(raw rawTokenBucket) (TokenBucket, error) {
+	if raw.MaxTokens == 0 {
+		return TokenBucket{}, fmt.Errorf("maxTokens must be positive")
+	}
+	fillInterval, err := time.ParseDuration(raw.FillInterval)
+	if err != nil || fillInterval < MinFillInterval {
+		return TokenBucket{}, fmt.Errorf("invalid fill interval %q: expected a duration of at least %v", raw.FillInterval,
+			MinFillInterval)
+	}
+	return TokenBucket{MaxTokens: raw.MaxTokens, TokensPerFill: raw.TokensPerFill, FillInterval: fillInterval}, nil
+}
+
+func validateLocalRateLimitEntry(entry RateLimitEntry) error {
+	if entry.Value == "" {
+		return fmt.Errorf("missing value")
+	}
+	if entry.RemoteAddress {
+		if entry.Key != "" || entry.Header != "" {
+			return fmt.Errorf("key and header can't be set with remoteAddress")
+		}
+		return nil
+	}
+	if entry.Key == "" {
+		return fmt.Errorf("missing key")
+	}
+	return nil
+}
+
+// RouteTimeouts are the stream timeouts of an HTTP route set with RouteTimeoutsAnnotation. A nil timeout keeps the
+// default of the route.
+type RouteTimeouts struct {
+	// IdleTimeout is the longest time a stream of the route can go without activity.
+	IdleTimeout *time.Duration
+	// MaxStreamDuration is the longest time a stream of the route can last, the timeout of the route by default.
+	MaxStreamDuration *time.Duration
+}
+
+// ParseRouteTimeouts parses the value of RouteTimeoutsAnnotation into the stream timeouts of each HTTP route name.
+func ParseRouteTimeouts(value string) (map[string]*RouteTimeouts, error) {
+	raw := map[string]struct {
+		IdleTimeout       *string `json:"idleTimeout"`
+		MaxStreamDuration *string `json:"maxStreamDuration"`
+	}{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", RouteTimeoutsAnnotation, err)
+	}
+	res := make(map[string]*RouteTimeouts, len(raw))
+	for name, v := range raw {
+		timeouts := &RouteTimeouts{}
+		var err error
+		if timeouts.IdleTimeout, err = parseTimeout(v.IdleTimeout); err != nil {
+			return nil, fmt.Errorf("invalid %s idle timeout for route %q: %v", RouteTimeoutsAnnotation, name, err)
+		}
+		if timeouts.MaxStreamDuration, err = parseTimeout(v.MaxStreamDuration); err != nil {
+			return nil, fmt.Errorf("invalid %s max stream duration for route %q: %v", RouteTimeoutsAnnotation, name, err)
+		}
+		if timeouts.IdleTimeout == nil && timeouts.MaxStreamDuration == nil {
+			return nil, fmt.Errorf("invalid %s for route %q: no timeout set", RouteTimeoutsAnnotation, name)
+		}
+		res[name] = timeouts
+	}
+	return res, nil
+}
+
+// parseTimeout parses an optional timeout, which can be zero to disable it.
+func parseTimeout(value *string) (*time.Duration, error) {
+	if value == nil {
+		return nil, nil
+	}
+	d, err := time.ParseDuration(*value)
+	if err != nil {
+		return nil, err
+	}
+	if d < 0 {
+		return nil, fmt.Errorf("negative duration %q", *value)
+	}
+	return &d, nil
+}
+
+// ParseSubjectAltNames parses the value of SubjectAltNamesAnnotation into the subject alternative names of each
+// subset name. The SPIFFE names must be valid SPIFFE identities.
+func ParseSubjectAltNames(value string) (map[string][]string, error) {
+	res := map[string][]string{}
+	if err := json.Unmarshal([]byte(value), &res); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", SubjectAltNamesAnnotation, err)
+	}
+	for subset, names := range res {
+		if len(names) == 0 {
+			return nil, fmt.Errorf("invalid %s for subset %q: no subject alternative name", SubjectAltNamesAnnotation, subset)
+		}
+		for _, name := range names {
+			if name == "" {
+				return nil, fmt.Errorf("invalid %s for subset %q: empty subject alternative name", SubjectAltNamesAnnotation,
+					subset)
+			}
+			if strings.HasPrefix(name, spiffe.URIPrefix) {
+				if _, err := spiffe.ParseIdentity(name); err != nil {
+					return nil, fmt.Errorf("invalid %s for subset %q: %v", SubjectAltNamesAnnotation, subset, err)
+				}
+			}
+		}
+	}
+	return res, nil
+}
+
+// PortRange is a range of ports of PortRangesAnnotation, including both ends.
+type PortRange struct {
+	Start uint32
+	End   uint32
+}
+
+// ParsePortRanges parses the value of PortRangesAnnotation. The ranges can't overlap.
+func ParsePortRanges(value string) ([]PortRange, error) {
+	var ranges []PortRange
+	total := 0
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		i := strings.Index(entry, "-")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid %s entry %q: expected <start>-<end>", PortRangesAnnotation, entry)
+		}
+		start, err := strconv.ParseUint(strings.TrimSpace(entry[:i]), 10, 32)
+		if err != nil || start == 0 || start > 65535 {
+			return nil, fmt.Errorf("invalid %s entry %q: invalid start port", PortRangesAnnotation, entry)
+		}
+		end, err := strconv.ParseUint(strings.TrimSpace(entry[i+1:]), 10, 32)
+		if err != nil || end <= start || end > 65535 {
+			return nil, fmt.Errorf("invalid %s entry %q: invalid end port", PortRangesAnnotation, entry)
+		}
+		r := PortRange{Start: uint32(start), End: uint32(end)}
+		for _, other := range ranges {
+			if r.Start <= other.End && other.Start <= r.End {
+				return nil, fmt.Errorf("invalid %s: range %q overlaps range %d-%d", PortRangesAnnotation, entry, other.Start, other.End)
+			}
+		}
+		if total += int(r.End - r.Start); total > MaxPortRangePorts {
+			return nil, fmt.Errorf("invalid %s: the ranges add more than %d ports", PortRangesAnnotation, MaxPortRangePorts)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// ExpandPorts returns the ports with the ports of the ranges, each a copy of the port the range starts at. The
+// target port of the copies, if any, is shifted by the same offset.
+func ExpandPorts(ports []*networking.Port, ranges []PortRange) ([]*networking.Port, error) {
+	declared := make(map[uint32]*networking.Port, len(ports))
+	for _, port := range ports {
+		if port != nil {
+			declared[port.Number] = port
+		}
+	}
+	out := append([]*networking.Port{}, ports...)
+	for _, r := range ranges {
+		base, f := declared[r.Start]
+		if !f {
+			return nil, fmt.Errorf("%s: range %d-%d does not start at a declared port", PortRangesAnnotation, r.Start, r.End)
+		}
+		for number := r.Start + 1; number <= r.End; number++ {
+			if _, f := declared[number]; f {
+				return nil, fmt.Errorf("%s: port %d of range %d-%d is already declared", PortRangesAnnotation, number, r.Start, r.End)
+			}
+			port := &networking.Port{
+				Number:   number,
+				Protocol: base.Protocol,
+				Name:     rangePortName(base.Name, number),
+			}
+			if base.TargetPort != 0 {
+				port.TargetPort = base.TargetPort + number - r.Start
+			}
+			out = append(out, port)
+		}
+	}
+	return out, nil
+}
+
+// ExpandEgressListeners returns the egress listeners with the listeners of the ranges, each a copy of the listener
+// on the port the range starts at.
+func ExpandEgressListeners(listeners []*networking.IstioEgressListener, ranges []PortRange) ([]*networking.IstioEgressListener, error) {
+	declared := make(map[uint32]*networking.IstioEgressListener, len(listeners))
+	for _, listener := range listeners {
+		if listener.GetPort().GetNumber() != 0 {
+			declared[listener.Port.Number] = listener
+		}
+	}
+	out := append([]*networking.IstioEgressListener{}, listeners...)
+	for _, r := range ranges {
+		base, f := declared[r.Start]
+		if !f {
+			return nil, fmt.Errorf("%s: range %d-%d does not start at the port of an egress listener", PortRangesAnnotation, r.Start, r.End)
+		}
+		for number := r.Start + 1; number <= r.End; number++ {
+			if _, f := declared[number]; f {
+				return nil, fmt.Errorf("%s: port %d of range %d-%d already has an egress listener", PortRangesAnnotation, number, r.Start, r.End)
+			}
+			listener := proto.Clone(base).(*networking.IstioEgressListener)
+			listener.Port.Number = number
+			listener.Port.Name = rangePortName(base.Port.Name, number)
+			out = append(out, listener)
+		}
+	}
+	return out, nil
+}
+
+// rangePortName returns the name of a port of a range, derived from the name of the port the range starts at.
+func rangePortName(name string, number uint32) string {
+	if name == "" {
+		return ""
+	}
+	return name + "-" + strconv.Itoa(int(number))
+}
+
+// FilterOrder is a constraint of FilterOrderAnnotation, placing a filter before or after the anchor filter.
+type FilterOrder struct {
+	Filter string
+	Anchor string
+	Before bool
+}
+
+// Ordered returns the names of the filters of the constraint, the first one being placed before the second one.
+func (o FilterOrder) Ordered() (string, string) {
+	if o.Before {
+		return o.Filter, o.Anchor
+	}
+	return o.Anchor, o.Filter
+}
+
+func (o FilterOrder) String() string {
+	if o.Before {
+		return o.Filter + " before " + o.Anchor
+	}
+	return o.Filter + " after " + o.Anchor
+}
+
+// ParseFilterOrder parses the value of FilterOrderAnnotation. The constraints can't contradict each other, nor
+// place a terminal filter before another filter.
+func ParseFilterOrder(value string) ([]FilterOrder, error) {
+	var orders []FilterOrder
+	after := make(map[string][]string)
+	for _, entry := range strings.Split(value, ",") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 || (fields[1] != "before" && fields[1] != "after") {
+			return nil, fmt.Errorf("invalid %s entry %q: expected <filter> before|after <filter>", FilterOrderAnnotation, entry)
+		}
+		o := FilterOrder{Filter: fields[0], Anchor: fields[2], Before: fields[1] == "before"}
+		first, second := o.Ordered()
+		if first == second {
+			return nil, fmt.Errorf("invalid %s entry %q: a filter can't be ordered relative to itself", FilterOrderAnnotation, entry)
+		}
+		if terminalFilters[first] {
+			return nil, fmt.Errorf("invalid %s entry %q: the terminal filter %s must stay last", FilterOrderAnnotation, entry, first)
+		}
+		if filterOrderReaches(after, second, first) {
+			return nil, fmt.Errorf("invalid %s entry %q: contradicts the previous constraints", FilterOrderAnnotation, entry)
+		}
+		after[first] = append(after[first], second)
+		orders = append(orders, o)
+	}
+	return orders, nil
+}
+
+// filterOrderReaches returns true if the filter is ordered, directly or not, before the target.
+func filterOrderReaches(after map[string][]string, filter, target string) bool {
+	seen := map[string]bool{filter: true}
+	pending := []string{filter}
+	for len(pending) > 0 {
+		current := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if current == target {
+			return true
+		}
+		for _, next := range after[current] {
+			if !seen[next] {
+				seen[next] = true
+				pending = append(pending, next)
+			}
+		}
+	}
+	return false
+}
+
+// Subset returns the subset of the cluster dedicated to an HTTP route of a VirtualService overriding the connection
+// pool of its destination. The subset of the destination is kept as a prefix, so that the endpoints of the cluster
+// are still selected with its labels.
+func Subset(subset, route, vsName, vsNamespace string) string {
+	return subset + subsetSeparator + route + "." + vsName + "." + vsNamespace
+}
+
+// BaseSubset returns the subset of the destination of a route cluster subset, or the subset itself for any
+// other subset.
+func BaseSubset(subset string) string {
+	if i := strings.Index(subset, subsetSeparator); i >= 0 {
+		return subset[:i]
+	}
+	return subset
+}
@@ -0,0 +1,731 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routing
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	networking "istio.io/api/networking/v1alpha3"
+)
+
+func TestParseConnectionPools(t *testing.T) {
+	pools, err := ParseConnectionPools(`{"chatty": {"http": {"maxRequestsPerConnection": 1, "maxRetries": 10},
+		"tcp": {"connectTimeout": "1s"}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chatty := pools["chatty"]
+	if len(pools) != 1 || chatty == nil {
+		t.Fatalf("expected the settings of route chatty, got %v", pools)
+	}
+	if chatty.Http.MaxRequestsPerConnection != 1 || chatty.Http.MaxRetries != 10 || chatty.Tcp.ConnectTimeout.Seconds != 1 {
+		t.Errorf("unexpected settings: %v", chatty)
+	}
+
+	for _, invalid := range []string{
+		`not json`,
+		`{"chatty": {"http": {"unknown": 1}}}`,
+		`{"chatty": {"tcp": {"connectTimeout": 1}}}`,
+	} {
+		if _, err := ParseConnectionPools(invalid); err == nil {
+			t.Errorf("expected an error for %s", invalid)
+		}
+	}
+}
+
+func TestParseRetryPolicies(t *testing.T) {
+	policies, err := ParseRetryPolicies(`{"checkout": {"budget": {"percent": 25, "minRetryConcurrency": 5},
+		"retriableHeaders": {"x-retry": {"exact": "true"}, "x-overloaded": null},
+		"retriableRequestHeaders": {":method": {"regex": "GET|HEAD"}}, "hedge": {"hedgeOnPerTryTimeout": true}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkout := policies["checkout"]
+	if len(policies) != 1 || checkout == nil {
+		t.Fatalf("expected the policy of route checkout, got %v", policies)
+	}
+	if checkout.Budget == nil || checkout.Budget.Percent != 25 || checkout.Budget.MinRetryConcurrency != 5 {
+		t.Errorf("unexpected budget: %+v", checkout.Budget)
+	}
+	if checkout.RetriableHeaders["x-retry"].GetExact() != "true" || checkout.RetriableHeaders["x-overloaded"].GetMatchType() != nil {
+		t.Errorf("unexpected retriable headers: %v", checkout.RetriableHeaders)
+	}
+	if checkout.RetriableRequestHeaders[":method"].GetRegex() != "GET|HEAD" {
+		t.Errorf("unexpected retriable request headers: %v", checkout.RetriableRequestHeaders)
+	}
+	if checkout.Hedge == nil || !checkout.Hedge.HedgeOnPerTryTimeout || checkout.Hedge.InitialRequests != 0 {
+		t.Errorf("unexpected hedge policy: %+v", checkout.Hedge)
+	}
+
+	for _, invalid := range []string{
+		`not json`,
+		`{"checkout": {"unknown": 1}}`,
+		`{"checkout": {"budget": {"percent": 0}}}`,
+		`{"checkout": {"budget": {"percent": 120}}}`,
+		`{"checkout": {"retriableHeaders": {"x-retry": {"suffix": "true"}}}}`,
+		`{"checkout": {"hedge": {}}}`,
+	} {
+		if _, err := ParseRetryPolicies(invalid); err == nil {
+			t.Errorf("expected an error for %s", invalid)
+		}
+	}
+}
+
+func TestParseMirrors(t *testing.T) {
+	mirrors, err := ParseMirrors(`{"checkout": [{"destination": {"host": "checkout.staging", "port": {"number": 80}},
+		"percentage": 12.5}, {"destination": {"host": "checkout.test", "subset": "v2"}}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkout := mirrors["checkout"]
+	if len(mirrors) != 1 || len(checkout) != 2 {
+		t.Fatalf("expected the two mirrors of route checkout, got %v", mirrors)
+	}
+	if checkout[0].Destination.Host != "checkout.staging" || checkout[0].Destination.Port.GetNumber() != 80 ||
+		checkout[0].Percentage.GetValue() != 12.5 {
+		t.Errorf("unexpected first mirror: %+v", checkout[0])
+	}
+	if checkout[1].Destination.Host != "checkout.test" || checkout[1].Destination.Subset != "v2" || checkout[1].Percentage != nil {
+		t.Errorf("unexpected second mirror: %+v", checkout[1])
+	}
+
+	for _, invalid := range []string{
+		`not json`,
+		`{"checkout": {"destination": {"host": "checkout.staging"}}}`,
+		`{"checkout": [{"percentage": 10}]}`,
+		`{"checkout": [{"destination": {"host": "checkout.staging"}, "headers": {}}]}`,
+		`{"checkout": [{"destination": {"hostname": "checkout.staging"}}]}`,
+	} {
+		if _, err := ParseMirrors(invalid); err == nil {
+			t.Errorf("expected an error for %s", invalid)
+		}
+	}
+}
+
+func TestParseActiveRequestBias(t *testing.T) {
+	cases := []struct {
+		value string
+		want  map[string]float64
+		err   bool
+	}{
+		{value: "1.5", want: map[string]float64{"": 1.5}},
+		{value: "1.5, v1=2,v2 = 0", want: map[string]float64{"": 1.5, "v1": 2, "v2": 0}},
+		{value: "v1=2", want: map[string]float64{"v1": 2}},
+		{value: "", want: map[string]float64{}},
+		{value: "fast", err: true},
+		{value: "=2", err: true},
+		{value: "v1=-1", err: true},
+		{value: "v1=1,v1=2", err: true},
+	}
+	for _, c := range cases {
+		got, err := ParseActiveRequestBias(c.value)
+		if c.err {
+			if err == nil {
+				t.Errorf("%q: expected an error", c.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", c.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: got %v, want %v", c.value, got, c.want)
+		}
+	}
+}
+
+func TestParseLocalOriginFailures(t *testing.T) {
+	cases := []struct {
+		value string
+		want  map[string]uint32
+		err   bool
+	}{
+		{value: "5", want: map[string]uint32{"": 5}},
+		{value: "5, v1=3,v2 = 0", want: map[string]uint32{"": 5, "v1": 3, "v2": 0}},
+		{value: "", want: map[string]uint32{}},
+		{value: "many", err: true},
+		{value: "=3", err: true},
+		{value: "v1=-1", err: true},
+		{value: "v1=1,v1=2", err: true},
+	}
+	for _, c := range cases {
+		got, err := ParseLocalOriginFailures(c.value)
+		if c.err {
+			if err == nil {
+				t.Errorf("%q: expected an error", c.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", c.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: got %v, want %v", c.value, got, c.want)
+		}
+	}
+}
+
+func TestParseProxyProtocolVersion(t *testing.T) {
+	cases := map[string]int{"V1": 1, "v2": 2, " V2 ": 2, "V3": 0, "": 0}
+	for value, want := range cases {
+		got, err := ParseProxyProtocolVersion(value)
+		if (err != nil) != (want == 0) {
+			t.Errorf("%q: unexpected error %v", value, err)
+		}
+		if got != want {
+			t.Errorf("%q: got version %d, want %d", value, got, want)
+		}
+	}
+}
+
+func TestParsePortResolutions(t *testing.T) {
+	cases := []struct {
+		value string
+		want  map[uint32]networking.ServiceEntry_Resolution
+		err   bool
+	}{
+		{
+			value: "443=NONE, 80 = dns",
+			want:  map[uint32]networking.ServiceEntry_Resolution{443: networking.ServiceEntry_NONE, 80: networking.ServiceEntry_DNS},
+		},
+		{value: "", want: map[uint32]networking.ServiceEntry_Resolution{}},
+		{value: "443", err: true},
+		{value: "http=DNS", err: true},
+		{value: "0=DNS", err: true},
+		{value: "70000=DNS", err: true},
+		{value: "443=SOMETIMES", err: true},
+		{value: "443=NONE,443=DNS", err: true},
+	}
+	for _, c := range cases {
+		got, err := ParsePortResolutions(c.value)
+		if c.err {
+			if err == nil {
+				t.Errorf("%q: expected an error", c.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", c.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: got %v, want %v", c.value, got, c.want)
+		}
+	}
+}
+
+func TestParseFailoverPriority(t *testing.T) {
+	cases := []struct {
+		value string
+		want  []string
+		err   bool
+	}{
+		{
+			value: "topology.istio.io/network, topology.kubernetes.io/zone,rack",
+			want:  []string{"topology.istio.io/network", "topology.kubernetes.io/zone", "rack"},
+		},
+		{value: "", err: true},
+		{value: " , ", err: true},
+		{value: "rack,rack", err: true},
+		{value: "-rack", err: true},
+		{value: "example.com/rack/row", err: true},
+	}
+	for _, c := range cases {
+		got, err := ParseFailoverPriority(c.value)
+		if c.err {
+			if err == nil {
+				t.Errorf("%q: expected an error", c.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", c.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: got %v, want %v", c.value, got, c.want)
+		}
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	cases := []struct {
+		name  string
+		value string
+		want  *RateLimit
+		err   bool
+	}{
+		{
+			name: "valid",
+			value: `{"domain": "edge", "service": "ratelimit.default.svc.cluster.local:8081", "timeout": "50ms",
+				"failureModeDeny": true, "descriptors": [[{"key": "path", "header": ":path"}, {"key": "tier", "value": "gold"}],
+				[{"remoteAddress": true}]]}`,
+			want: &RateLimit{
+				Domain:          "edge",
+				Host:            "ratelimit.default.svc.cluster.local",
+				Port:            8081,
+				Timeout:         50 * time.Millisecond,
+				FailureModeDeny: true,
+				Descriptors: [][]RateLimitEntry{
+					{{Key: "path", Header: ":path"}, {Key: "tier", Value: "gold"}},
+					{{RemoteAddress: true}},
+				},
+			},
+		},
+		{
+			name:  "default timeout",
+			value: `{"domain": "edge", "service": "ratelimit:8081", "descriptors": [[{"value": "all"}]]}`,
+			want: &RateLimit{
+				Domain:      "edge",
+				Host:        "ratelimit",
+				Port:        8081,
+				Timeout:     DefaultRateLimitTimeout,
+				Descriptors: [][]RateLimitEntry{{{Value: "all"}}},
+			},
+		},
+		{name: "invalid json", value: `{"domain": }`, err: true},
+		{name: "unknown field", value: `{"domain": "edge", "service": "ratelimit:8081", "descriptors": [[{"value": "all"}]], "stage": 1}`, err: true},
+		{name: "missing domain", value: `{"service": "ratelimit:8081", "descriptors": [[{"value": "all"}]]}`, err: true},
+		{name: "missing port", value: `{"domain": "edge", "service": "ratelimit", "descriptors": [[{"value": "all"}]]}`, err: true},
+		{name: "invalid port", value: `{"domain": "edge", "service": "ratelimit:0", "descriptors": [[{"value": "all"}]]}`, err: true},
+		{name: "invalid timeout", value: `{"domain": "edge", "service": "ratelimit:8081", "timeout": "-1s", "descriptors": [[{"value": "all"}]]}`, err: true},
+		{name: "missing descriptors", value: `{"domain": "edge", "service": "ratelimit:8081"}`, err: true},
+		{name: "empty descriptor", value: `{"domain": "edge", "service": "ratelimit:8081", "descriptors": [[]]}`, err: true},
+		{name: "header without key", value: `{"domain": "edge", "service": "ratelimit:8081", "descriptors": [[{"header": "x-user"}]]}`, err: true},
+		{name: "key with remote address", value: `{"domain": "edge", "service": "ratelimit:8081", "descriptors": [[{"key": "ip", "remoteAddress": true}]]}`, err: true},
+		{name: "several values", value: `{"domain": "edge", "service": "ratelimit:8081", "descriptors": [[{"key": "k", "header": "x-user", "value": "v"}]]}`, err: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ParseRateLimit(c.value)
+			if c.err {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %+v, want %+v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseLocalRateLimit(t *testing.T) {
+	cases := []struct {
+		name  string
+		value string
+		want  *LocalRateLimit
+		err   bool
+	}{
+		{
+			name: "valid",
+			value: `{"maxTokens": 100, "tokensPerFill": 50, "fillInterval": "1s", "responseHeaders": {"x-limited": "true"},
+				"descriptors": [{"entries": [{"key": "user", "header": "x-user", "value": "batch"}], "maxTokens": 10,
+				"fillInterval": "2s"}, {"entries": [{"remoteAddress": true, "value": "10.0.0.1"}], "maxTokens": 1,
+				"fillInterval": "1s"}]}`,
+			want: &LocalRateLimit{
+				TokenBucket: TokenBucket{MaxTokens: 100, TokensPerFill: 50, FillInterval: time.Second},
+				Descriptors: []LocalRateLimitDescriptor{
+					{
+						Entries:     []RateLimitEntry{{Key: "user", Header: "x-user", Value: "batch"}},
+						TokenBucket: TokenBucket{MaxTokens: 10, FillInterval: 2 * time.Second},
+					},
+					{
+						Entries:     []RateLimitEntry{{RemoteAddress: true, Value: "10.0.0.1"}},
+						TokenBucket: TokenBucket{MaxTokens: 1, FillInterval: time.Second},
+					},
+				},
+				ResponseHeaders: map[string]string{"x-limited": "true"},
+			},
+		},
+		{
+			name:  "token bucket only",
+			value: `{"maxTokens": 10, "fillInterval": "100ms"}`,
+			want:  &LocalRateLimit{TokenBucket: TokenBucket{MaxTokens: 10, FillInterval: 100 * time.Millisecond}},
+		},
+		{name: "invalid json", value: `{"maxTokens": }`, err: true},
+		{name: "unknown field", value: `{"maxTokens": 10, "fillInterval": "1s", "stage": 1}`, err: true},
+		{name: "missing max tokens", value: `{"fillInterval": "1s"}`, err: true},
+		{name: "missing fill interval", value: `{"maxTokens": 10}`, err: true},
+		{name: "short fill interval", value: `{"maxTokens": 10, "fillInterval": "10ms"}`, err: true},
+		{name: "descriptor fill interval not a multiple", value: `{"maxTokens": 10, "fillInterval": "1s",
+			"descriptors": [{"entries": [{"key": "k", "value": "v"}], "maxTokens": 1, "fillInterval": "1500ms"}]}`, err: true},
+		{name: "descriptor without entries", value: `{"maxTokens": 10, "fillInterval": "1s",
+			"descriptors": [{"maxTokens": 1, "fillInterval": "1s"}]}`, err: true},
+		{name: "entry without value", value: `{"maxTokens": 10, "fillInterval": "1s",
+			"descriptors": [{"entries": [{"key": "user", "header": "x-user"}], "maxTokens": 1, "fillInterval": "1s"}]}`, err: true},
+		{name: "entry without key", value: `{"maxTokens": 10, "fillInterval": "1s",
+			"descriptors": [{"entries": [{"value": "v"}], "maxTokens": 1, "fillInterval": "1s"}]}`, err: true},
+		{name: "key with remote address", value: `{"maxTokens": 10, "fillInterval": "1s",
+			"descriptors": [{"entries": [{"key": "ip", "remoteAddress": true, "value": "10.0.0.1"}], "maxTokens": 1, "fillInterval": "1s"}]}`, err: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ParseLocalRateLimit(c.value)
+			if c.err {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %+v, want %+v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseRouteLocalRateLimits(t *testing.T) {
+	got, err := ParseRouteLocalRateLimits(`{"api": {"maxTokens": 10, "fillInterval": "1s"}}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]*LocalRateLimit{"api": {TokenBucket: TokenBucket{MaxTokens: 10, FillInterval: time.Second}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if _, err := ParseRouteLocalRateLimits(`{"api": {"maxTokens": 0, "fillInterval": "1s"}}`); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestParseRouteTimeouts(t *testing.T) {
+	zero, fiveMinutes := time.Duration(0), 5*time.Minute
+	cases := []struct {
+		value string
+		want  map[string]*RouteTimeouts
+		err   bool
+	}{
+		{
+			value: `{"events": {"idleTimeout": "5m", "maxStreamDuration": "0s"}, "api": {"idleTimeout": "0s"}}`,
+			want: map[string]*RouteTimeouts{
+				"events": {IdleTimeout: &fiveMinutes, MaxStreamDuration: &zero},
+				"api":    {IdleTimeout: &zero},
+			},
+		},
+		{value: `{"events": {}}`, err: true},
+		{value: `{"events": {"idleTimeout": "5"}}`, err: true},
+		{value: `{"events": {"maxStreamDuration": "-1s"}}`, err: true},
+		{value: `{"events": {"timeout": "1s"}}`, err: true},
+	}
+	for _, c := range cases {
+		got, err := ParseRouteTimeouts(c.value)
+		if c.err {
+			if err == nil {
+				t.Errorf("%q: expected an error", c.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", c.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: got %v, want %v", c.value, got, c.want)
+		}
+	}
+}
+
+func TestParseSubjectAltNames(t *testing.T) {
+	got, err := ParseSubjectAltNames(`{"canary": ["spiffe://cluster.local/ns/default/sa/canary", "canary.example.com"]}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string][]string{"canary": {"spiffe://cluster.local/ns/default/sa/canary", "canary.example.com"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, value := range []string{
+		`{"canary": []}`,
+		`{"canary": [""]}`,
+		`{"canary": ["spiffe://cluster.local/canary"]}`,
+		`["spiffe://cluster.local/ns/default/sa/canary"]`,
+	} {
+		if _, err := ParseSubjectAltNames(value); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
+
+func TestParseProtocolDetection(t *testing.T) {
+	zero, fiveSeconds := time.Duration(0), 5*time.Second
+	cases := []struct {
+		value string
+		want  map[uint32]ProtocolDetection
+		err   bool
+	}{
+		{
+			value: "3306=0s, 9000=5s/TCP,8000=/http",
+			want: map[uint32]ProtocolDetection{
+				3306: {Timeout: &zero},
+				9000: {Timeout: &fiveSeconds},
+				8000: {FallbackHTTP: true},
+			},
+		},
+		{value: "", want: map[uint32]ProtocolDetection{}},
+		{value: "3306", err: true},
+		{value: "3306=", err: true},
+		{value: "3306=/", err: true},
+		{value: "0=1s", err: true},
+		{value: "3306=fast", err: true},
+		{value: "3306=-1s", err: true},
+		{value: "3306=1s/UDP", err: true},
+		{value: "3306=1s,3306=2s", err: true},
+	}
+	for _, c := range cases {
+		got, err := ParseProtocolDetection(c.value)
+		if c.err {
+			if err == nil {
+				t.Errorf("%q: expected an error", c.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", c.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: got %v, want %v", c.value, got, c.want)
+		}
+	}
+}
+
+func TestParseRegexRewrites(t *testing.T) {
+	got, err := ParseRegexRewrites(`{"api": {"match": "^/api/v1/(.*)$", "substitution": "/\\1"}}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]*RegexRewrite{"api": {Match: "^/api/v1/(.*)$", Substitution: `/\1`}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, value := range []string{
+		`{"api": {"substitution": "/"}}`,
+		`{"api": {"match": "^/api/(", "substitution": "/"}}`,
+		`{"api": {"match": "^/api/v1/(.*)$", "substitution": "/\\2"}}`,
+		`{"api": {"match": "^/api/", "substitution": "/", "prefix": "/"}}`,
+		`{"api": null}`,
+	} {
+		if _, err := ParseRegexRewrites(value); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
+
+func TestParseAccessLog(t *testing.T) {
+	cases := []struct {
+		value string
+		want  *AccessLog
+		err   bool
+	}{
+		{
+			value: `{"providers": ["file", "envoy-als"], "encoding": "json", "format": "{\"path\": \"%REQ(:PATH)%\"}"}`,
+			want: &AccessLog{
+				Providers: []string{AccessLogProviderFile, AccessLogProviderEnvoyALS},
+				Encoding:  "JSON",
+				Format:    `{"path": "%REQ(:PATH)%"}`,
+			},
+		},
+		{value: `{"providers": []}`, want: &AccessLog{Providers: []string{}}},
+		{value: `{"path": "/dev/stderr"}`, want: &AccessLog{Path: "/dev/stderr"}},
+		{value: `{"providers": ["stackdriver"]}`, err: true},
+		{value: `{"providers": ["file", "file"]}`, err: true},
+		{value: `{"encoding": "YAML"}`, err: true},
+		{value: `{"encoding": "JSON", "format": "%REQ(:PATH)%"}`, err: true},
+		{value: `{"file": "/dev/stdout"}`, err: true},
+	}
+	for _, c := range cases {
+		got, err := ParseAccessLog(c.value)
+		if c.err {
+			if err == nil {
+				t.Errorf("%q: expected an error", c.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", c.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: got %+v, want %+v", c.value, got, c.want)
+		}
+	}
+}
+
+func TestParsePortRanges(t *testing.T) {
+	cases := []struct {
+		value string
+		want  []PortRange
+		err   bool
+	}{
+		{
+			value: "30000-32767, 5060 - 5070",
+			want:  []PortRange{{Start: 30000, End: 32767}, {Start: 5060, End: 5070}},
+		},
+		{value: ""},
+		{value: "30000", err: true},
+		{value: "0-10", err: true},
+		{value: "100-100", err: true},
+		{value: "100-70000", err: true},
+		{value: "a-b", err: true},
+		{value: "100-200,150-250", err: true},
+		{value: "1000-6000", err: true},
+	}
+	for _, c := range cases {
+		got, err := ParsePortRanges(c.value)
+		if c.err {
+			if err == nil {
+				t.Errorf("%q: expected an error", c.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", c.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: got %v, want %v", c.value, got, c.want)
+		}
+	}
+}
+
+func TestParseFilterOrder(t *testing.T) {
+	cases := []struct {
+		value string
+		want  []FilterOrder
+		err   bool
+	}{
+		{
+			value: "my.authz before envoy.filters.http.rbac, istio.stats after my.authz",
+			want: []FilterOrder{
+				{Filter: "my.authz", Anchor: "envoy.filters.http.rbac", Before: true},
+				{Filter: "istio.stats", Anchor: "my.authz"},
+			},
+		},
+		{value: ""},
+		{value: "my.authz", err: true},
+		{value: "my.authz above envoy.filters.http.rbac", err: true},
+		{value: "my.authz before my.authz", err: true},
+		{value: "my.authz after envoy.filters.http.router", err: true},
+		{value: "envoy.filters.network.tcp_proxy before my.filter", err: true},
+		{value: "a before b, b before c, a after c", err: true},
+	}
+	for _, c := range cases {
+		got, err := ParseFilterOrder(c.value)
+		if c.err {
+			if err == nil {
+				t.Errorf("%q: expected an error", c.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", c.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: got %v, want %v", c.value, got, c.want)
+		}
+	}
+}
+
+func TestExpandPorts(t *testing.T) {
+	ports := []*networking.Port{
+		{Number: 21, Name: "tcp-ftp", Protocol: "TCP"},
+		{Number: 30000, Name: "tcp-data", Protocol: "TCP", TargetPort: 40000},
+	}
+	got, err := ExpandPorts(ports, []PortRange{{Start: 30000, End: 30002}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*networking.Port{
+		ports[0],
+		ports[1],
+		{Number: 30001, Name: "tcp-data-30001", Protocol: "TCP", TargetPort: 40001},
+		{Number: 30002, Name: "tcp-data-30002", Protocol: "TCP", TargetPort: 40002},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err := ExpandPorts(ports, []PortRange{{Start: 30001, End: 30002}}); err == nil {
+		t.Errorf("expected an error for a range not starting at a declared port")
+	}
+	if _, err := ExpandPorts(ports, []PortRange{{Start: 20, End: 21}}); err == nil {
+		t.Errorf("expected an error for a range not starting at a declared port")
+	}
+	if _, err := ExpandPorts(append(ports, &networking.Port{Number: 30001, Name: "tcp-other", Protocol: "TCP"}),
+		[]PortRange{{Start: 30000, End: 30002}}); err == nil {
+		t.Errorf("expected an error for a range including a declared port")
+	}
+}
+
+func TestExpandEgressListeners(t *testing.T) {
+	listeners := []*networking.IstioEgressListener{
+		{Port: &networking.Port{Number: 5060, Name: "sip", Protocol: "TCP"}, Hosts: []string{"./sip.example.com"}},
+		{Hosts: []string{"*/*"}},
+	}
+	got, err := ExpandEgressListeners(listeners, []PortRange{{Start: 5060, End: 5061}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*networking.IstioEgressListener{
+		listeners[0],
+		listeners[1],
+		{Port: &networking.Port{Number: 5061, Name: "sip-5061", Protocol: "TCP"}, Hosts: []string{"./sip.example.com"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if listeners[0].Port.Number != 5060 {
+		t.Errorf("the declared listener was modified: %v", listeners[0])
+	}
+
+	if _, err := ExpandEgressListeners(listeners, []PortRange{{Start: 5000, End: 5001}}); err == nil {
+		t.Errorf("expected an error for a range not starting at the port of an egress listener")
+	}
+}
+
+func TestSubset(t *testing.T) {
+	cases := []struct {
+		subset string
+		want   string
+	}{
+		{subset: "", want: "~chatty.reviews.default"},
+		{subset: "v1", want: "v1~chatty.reviews.default"},
+	}
+	for _, c := range cases {
+		got := Subset(c.subset, "chatty", "reviews", "default")
+		if got != c.want {
+			t.Errorf("Subset(%q): got %q, want %q", c.subset, got, c.want)
+		}
+		if base := BaseSubset(got); base != c.subset {
+			t.Errorf("BaseSubset(%q): got %q, want %q", got, base, c.subset)
+		}
+	}
+	if base := BaseSubset("v2"); base != "v2" {
+		t.Errorf("BaseSubset(v2): got %q", base)
+	}
+}
@@ -15,6 +15,7 @@
 package security
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/url"
@@ -23,6 +24,7 @@ import (
 
 	"github.com/hashicorp/go-multierror"
 
+	"istio.io/api/security/v1beta1"
 	"istio.io/istio/pkg/config/host"
 )
 
@@ -43,7 +45,7 @@ const (
 	attrRequestPrincipal = "request.auth.principal" // authenticated principal of the request.
 	attrRequestAudiences = "request.auth.audiences" // intended audience(s) for this authentication information.
 	attrRequestPresenter = "request.auth.presenter" // authorized presenter of the credential.
-	attrRequestClaims    = "request.auth.claims"    // claim name is surrounded by brackets, e.g. "request.auth.claims[iss]".
+	attrRequestClaims    = "request.auth.claims"    // claim name is surrounded by brackets, e.g. "request.auth.claims[iss]" or "request.auth.claims[realm_access][roles]".
 	attrDestIP           = "destination.ip"         // supports both single ip and cidr, e.g. "10.1.2.3" or "10.1.0.0/16".
 	attrDestPort         = "destination.port"       // must be in the range [0, 65535].
 	attrDestLabel        = "destination.labels"     // label name is surrounded by brackets, e.g. "destination.labels[version]".
@@ -114,7 +116,7 @@ func ValidateAttribute(key string, values []string) error {
 	case isEqual(key, attrRequestAudiences):
 	case isEqual(key, attrRequestPresenter):
 	case hasPrefix(key, attrRequestClaims):
-		return validateMapKey(key)
+		return validateClaimKey(key)
 	case isEqual(key, attrDestIP):
 		return ValidateIPs(values)
 	case isEqual(key, attrDestPort):
@@ -147,10 +149,133 @@ func hasPrefix(key string, prefix string) bool {
 	return strings.HasPrefix(key, prefix)
 }
 
+// IPSetPrefix is the prefix of a reference to a named IP set in the IP blocks of an AuthorizationPolicy,
+// e.g. "ipset:blocked-ips". The IP sets are read from the IP set ConfigMap and expanded by istiod.
+const IPSetPrefix = "ipset:"
+
+// IPSetName returns the name of the IP set referenced by the value, or false if it is not an IP set reference.
+func IPSetName(v string) (string, bool) {
+	if !strings.HasPrefix(v, IPSetPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(v, IPSetPrefix), true
+}
+
+// SidecarIngressMtlsAnnotation sets the inbound mTLS mode of the ingress listeners of a Sidecar, as a comma
+// separated list of port=MODE pairs mirroring the portLevelMtls of PeerAuthentication, for example
+// "8080=STRICT,9090=DISABLE". The ports must be ports of the ingress listeners of the Sidecar. This allows
+// workloads such as VMs to control their inbound mTLS without a selector-based PeerAuthentication.
+const SidecarIngressMtlsAnnotation = "security.istio.io/ingressMtls"
+
+// ParseSidecarIngressMtls parses the value of SidecarIngressMtlsAnnotation into mTLS modes keyed by port.
+func ParseSidecarIngressMtls(value string) (map[uint32]v1beta1.PeerAuthentication_MutualTLS_Mode, error) {
+	res := map[uint32]v1beta1.PeerAuthentication_MutualTLS_Mode{}
+	if strings.TrimSpace(value) == "" {
+		return res, nil
+	}
+	var errs *multierror.Error
+	for _, setting := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(setting), "=", 2)
+		if len(parts) != 2 {
+			errs = multierror.Append(errs, fmt.Errorf("invalid setting %q, must be of form <port>=<mode>", setting))
+			continue
+		}
+		port, err := strconv.ParseUint(strings.TrimSpace(parts[0]), 10, 16)
+		if err != nil || port == 0 {
+			errs = multierror.Append(errs, fmt.Errorf("invalid port %q in setting %q", parts[0], setting))
+			continue
+		}
+		mode, ok := v1beta1.PeerAuthentication_MutualTLS_Mode_value[strings.ToUpper(strings.TrimSpace(parts[1]))]
+		if !ok || mode == int32(v1beta1.PeerAuthentication_MutualTLS_UNSET) {
+			errs = multierror.Append(errs, fmt.Errorf("invalid mode %q in setting %q, must be one of STRICT, PERMISSIVE or DISABLE",
+				parts[1], setting))
+			continue
+		}
+		if existing, f := res[uint32(port)]; f && existing != v1beta1.PeerAuthentication_MutualTLS_Mode(mode) {
+			errs = multierror.Append(errs, fmt.Errorf("conflicting modes %v and %v for port %d",
+				existing, v1beta1.PeerAuthentication_MutualTLS_Mode(mode), port))
+			continue
+		}
+		res[uint32(port)] = v1beta1.PeerAuthentication_MutualTLS_Mode(mode)
+	}
+	return res, errs.ErrorOrNil()
+}
+
+// JwtRouteRequirementsAnnotation allows a RequestAuthentication to override the JWT requirement of the requests
+// routed by the named HTTP routes of the VirtualServices bound to the selected gateways, as a JSON list of
+// JwtRouteRequirement, for example `[{"route": "healthz", "skip": true}, {"route": "admin", "audiences": ["admin"]}]`.
+// The requests of the other routes keep the default requirement of the workload.
+const JwtRouteRequirementsAnnotation = "security.istio.io/jwtRouteRequirements"
+
+// JwtRouteRequirement is the JWT requirement of the requests of a named HTTP route.
+type JwtRouteRequirement struct {
+	// Route is the name of the HTTP route of the VirtualService.
+	Route string `json:"route"`
+	// Skip disables the JWT validation for the requests of the route.
+	Skip bool `json:"skip,omitempty"`
+	// Issuers restricts the tokens accepted for the requests of the route to these issuers. By default, the issuers
+	// of the RequestAuthentication are accepted. Unless skipped, a valid token is required for the requests of the route.
+	Issuers []string `json:"issuers,omitempty"`
+	// Audiences overrides the audiences accepted for the requests of the route.
+	Audiences []string `json:"audiences,omitempty"`
+}
+
+// ParseJwtRouteRequirements parses the value of JwtRouteRequirementsAnnotation of a RequestAuthentication with the
+// given issuers. The issuers of the requirements default to these issuers. The valid requirements are returned
+// along with the errors of the invalid ones.
+func ParseJwtRouteRequirements(value string, issuers []string) ([]JwtRouteRequirement, error) {
+	var requirements []JwtRouteRequirement
+	if err := json.Unmarshal([]byte(value), &requirements); err != nil {
+		return nil, fmt.Errorf("invalid JWT route requirements: %v", err)
+	}
+	known := map[string]bool{}
+	for _, issuer := range issuers {
+		known[issuer] = true
+	}
+	seen := map[string]bool{}
+	res := make([]JwtRouteRequirement, 0, len(requirements))
+	var errs *multierror.Error
+	for _, requirement := range requirements {
+		if requirement.Route == "" {
+			errs = multierror.Append(errs, fmt.Errorf("requirement %+v has no route", requirement))
+			continue
+		}
+		if seen[requirement.Route] {
+			errs = multierror.Append(errs, fmt.Errorf("duplicate requirement for route %q", requirement.Route))
+			continue
+		}
+		if len(requirement.Issuers) == 0 {
+			requirement.Issuers = issuers
+		}
+		if len(requirement.Issuers) == 0 && !requirement.Skip {
+			errs = multierror.Append(errs, fmt.Errorf("requirement for route %q has no issuers", requirement.Route))
+			continue
+		}
+		valid := true
+		for _, issuer := range requirement.Issuers {
+			if !known[issuer] {
+				errs = multierror.Append(errs, fmt.Errorf("requirement for route %q has unknown issuer %q", requirement.Route, issuer))
+				valid = false
+				break
+			}
+		}
+		if !valid {
+			continue
+		}
+		seen[requirement.Route] = true
+		res = append(res, requirement)
+	}
+	return res, errs.ErrorOrNil()
+}
+
 func ValidateIPs(ips []string) error {
 	var errs *multierror.Error
 	for _, v := range ips {
-		if strings.Contains(v, "/") {
+		if name, ok := IPSetName(v); ok {
+			if name == "" {
+				errs = multierror.Append(errs, fmt.Errorf("empty IP set name (%s)", v))
+			}
+		} else if strings.Contains(v, "/") {
 			if _, _, err := net.ParseCIDR(v); err != nil {
 				errs = multierror.Append(errs, fmt.Errorf("bad CIDR range (%s): %v", v, err))
 			}
@@ -181,3 +306,21 @@ func validateMapKey(key string) error {
 	}
 	return fmt.Errorf("bad key (%s): should have format a[b]", key)
 }
+
+// validateClaimKey validates the key of a claim, which may refer to a nested claim with the name of each
+// level surrounded by brackets, e.g. request.auth.claims[realm_access][roles].
+func validateClaimKey(key string) error {
+	if err := validateMapKey(key); err != nil {
+		return err
+	}
+	names := strings.TrimPrefix(key, attrRequestClaims)
+	if !strings.Contains(names, "][") {
+		return nil
+	}
+	for _, name := range strings.Split(strings.TrimSuffix(strings.TrimPrefix(names, "["), "]"), "][") {
+		if name == "" {
+			return fmt.Errorf("bad key (%s): should have format a[b][c] with non-empty names", key)
+		}
+	}
+	return nil
+}
@@ -159,6 +159,20 @@ func TestValidateCondition(t *testing.T) {
 			values:    []string{"value"},
 			wantError: true,
 		},
+		{
+			key:    "request.auth.claims[realm_access][roles]",
+			values: []string{"admin"},
+		},
+		{
+			key:       "request.auth.claims[realm_access][]",
+			values:    []string{"admin"},
+			wantError: true,
+		},
+		{
+			key:       "request.auth.claims[][roles]",
+			values:    []string{"admin"},
+			wantError: true,
+		},
 		{
 			key:    "destination.ip",
 			values: []string{"1.2.3.4", "5.6.7.0/24"},
@@ -218,3 +232,30 @@ func TestValidateCondition(t *testing.T) {
 		}
 	}
 }
+
+func TestParseJwtRouteRequirements(t *testing.T) {
+	value := `[
+		{"route": "healthz", "skip": true},
+		{"route": "admin", "audiences": ["admin"]},
+		{"route": "api", "issuers": ["https://bar.com"]},
+		{"route": "api", "skip": true},
+		{"route": "unknown", "issuers": ["https://baz.com"]},
+		{"skip": true}
+	]`
+	got, err := security.ParseJwtRouteRequirements(value, []string{"https://bar.com", "https://foo.com"})
+	if err == nil {
+		t.Errorf("expected errors for the duplicate and invalid requirements")
+	}
+	want := []security.JwtRouteRequirement{
+		{Route: "healthz", Skip: true, Issuers: []string{"https://bar.com", "https://foo.com"}},
+		{Route: "admin", Issuers: []string{"https://bar.com", "https://foo.com"}, Audiences: []string{"admin"}},
+		{Route: "api", Issuers: []string{"https://bar.com"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if _, err := security.ParseJwtRouteRequirements(`{"route": "api"}`, nil); err == nil {
+		t.Errorf("expected error for invalid JSON")
+	}
+}
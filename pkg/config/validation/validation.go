@@ -46,6 +46,7 @@ import (
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/labels"
 	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/routing"
 	"istio.io/istio/pkg/config/security"
 	"istio.io/istio/pkg/config/visibility"
 	"istio.io/istio/pkg/config/xds"
@@ -362,12 +363,20 @@ var ValidateGateway = registerValidateFunc("ValidateGateway",
 					v = appendValidation(v, fmt.Errorf("port names in servers must be unique: duplicate name %s", s.Port.Name))
 				}
 				portNames[s.Port.Name] = true
-				if !protocol.Parse(s.Port.Protocol).IsHTTP() && s.GetTls().GetHttpsRedirect() {
+				if !protocol.ParseGatewayProtocol(s.Port.Protocol).IsHTTP() && s.GetTls().GetHttpsRedirect() {
 					v = appendValidation(v, WrapWarning(fmt.Errorf("tls.httpsRedirect should only be used with http servers")))
 				}
 			}
 		}
 
+		if annotation, f := cfg.Annotations[routing.RateLimitAnnotation]; f {
+			if rateLimit, err := routing.ParseRateLimit(annotation); err != nil {
+				v = appendValidation(v, err)
+			} else {
+				v = appendValidation(v, ValidateFQDN(rateLimit.Host))
+			}
+		}
+
 		return v.Unwrap()
 	})
 
@@ -390,9 +399,11 @@ func validateServer(server *networking.Server) (errs error) {
 
 	// If port is HTTPS or TLS, make sure that server has TLS options
 	if portErr == nil {
-		p := protocol.Parse(server.Port.Protocol)
+		p := protocol.ParseGatewayProtocol(server.Port.Protocol)
 		if p.IsTLS() && server.Tls == nil {
 			errs = appendErrors(errs, fmt.Errorf("server must have TLS settings for HTTPS/TLS protocols"))
+		} else if p == protocol.HTTP3 && gateway.IsPassThroughServer(server) {
+			errs = appendErrors(errs, fmt.Errorf("server must terminate TLS for HTTP3 protocol"))
 		} else if !p.IsTLS() && server.Tls != nil {
 			// only tls redirect is allowed if this is a HTTP server
 			if p.IsHTTP() {
@@ -412,7 +423,7 @@ func validateServerPort(port *networking.Port) (errs error) {
 	if port == nil {
 		return appendErrors(errs, fmt.Errorf("port is required"))
 	}
-	if protocol.Parse(port.Protocol) == protocol.Unsupported {
+	if protocol.ParseGatewayProtocol(port.Protocol) == protocol.Unsupported {
 		errs = appendErrors(errs, fmt.Errorf("invalid protocol %q, supported protocols are HTTP, HTTP2, GRPC, GRPC-WEB, MONGO, REDIS, MYSQL, TCP", port.Protocol))
 	}
 	if port.Number > 0 {
@@ -512,11 +523,169 @@ var ValidateDestinationRule = registerValidateFunc("ValidateDestinationRule",
 			}
 			v = appendValidation(v, validateSubset(subset))
 		}
+		if value, f := cfg.Annotations[routing.ActiveRequestBiasAnnotation]; f {
+			v = appendValidation(v, validateActiveRequestBias(value, rule))
+		}
+		if value, f := cfg.Annotations[routing.LocalOriginOutlierDetectionAnnotation]; f {
+			v = appendValidation(v, validateLocalOriginOutlierDetection(value, rule))
+		}
+		if value, f := cfg.Annotations[routing.SubjectAltNamesAnnotation]; f {
+			v = appendValidation(v, validateSubsetSubjectAltNames(value, rule))
+		}
+		if value, f := cfg.Annotations[routing.UpstreamProxyProtocolAnnotation]; f {
+			if _, err := routing.ParseProxyProtocolVersion(value); err != nil {
+				v = appendValidation(v, err)
+			}
+		}
+		if value, f := cfg.Annotations[routing.IgnoreHealthOnHostRemovalAnnotation]; f {
+			if _, err := routing.ParseIgnoreHealthOnHostRemoval(value); err != nil {
+				v = appendValidation(v, err)
+			}
+		}
+		if value, f := cfg.Annotations[routing.FailoverPriorityAnnotation]; f {
+			if _, err := routing.ParseFailoverPriority(value); err != nil {
+				v = appendValidation(v, err)
+			} else if rule.GetTrafficPolicy().GetLoadBalancer().GetLocalityLbSetting().GetDistribute() != nil {
+				v = appendValidation(v, fmt.Errorf("%s can't be set with the distribute setting of the locality load balancing",
+					routing.FailoverPriorityAnnotation))
+			}
+		}
 
 		v = appendValidation(v, validateExportTo(cfg.Namespace, rule.ExportTo, false))
 		return v.Unwrap()
 	})
 
+// validateActiveRequestBias validates the active request biases of a destination rule. Biases only apply to the
+// LEAST_CONN load balancer, so a warning is returned for those set on hosts and subsets using another one.
+func validateActiveRequestBias(value string, rule *networking.DestinationRule) Validation {
+	biases, err := routing.ParseActiveRequestBias(value)
+	if err != nil {
+		return WrapError(err)
+	}
+	v := Validation{}
+	leastConn := func(policy *networking.TrafficPolicy) bool {
+		if policy.GetLoadBalancer().GetSimple() == networking.LoadBalancerSettings_LEAST_CONN {
+			return true
+		}
+		for _, pls := range policy.GetPortLevelSettings() {
+			if pls.GetLoadBalancer().GetSimple() == networking.LoadBalancerSettings_LEAST_CONN {
+				return true
+			}
+		}
+		return false
+	}
+	subsets := map[string]*networking.Subset{}
+	for _, subset := range rule.Subsets {
+		if subset != nil {
+			subsets[subset.Name] = subset
+		}
+	}
+	for name := range biases {
+		if name == "" {
+			if !leastConn(rule.TrafficPolicy) {
+				v = appendValidation(v, WrapWarning(fmt.Errorf("%s: the host does not use the LEAST_CONN load balancer",
+					routing.ActiveRequestBiasAnnotation)))
+			}
+			continue
+		}
+		subset, f := subsets[name]
+		if !f {
+			v = appendValidation(v, fmt.Errorf("%s: no subset named %q", routing.ActiveRequestBiasAnnotation, name))
+			continue
+		}
+		if !leastConn(subset.TrafficPolicy) && !leastConn(rule.TrafficPolicy) {
+			v = appendValidation(v, WrapWarning(fmt.Errorf("%s: subset %q does not use the LEAST_CONN load balancer",
+				routing.ActiveRequestBiasAnnotation, name)))
+		}
+	}
+	return v
+}
+
+// validateLocalOriginOutlierDetection validates the consecutive local origin failures of a destination rule. They
+// only apply to the hosts and subsets with an outlier detection, so a warning is returned for the others.
+func validateLocalOriginOutlierDetection(value string, rule *networking.DestinationRule) Validation {
+	failures, err := routing.ParseLocalOriginFailures(value)
+	if err != nil {
+		return WrapError(err)
+	}
+	v := Validation{}
+	hasOutlierDetection := func(policy *networking.TrafficPolicy) bool {
+		if policy.GetOutlierDetection() != nil {
+			return true
+		}
+		for _, pls := range policy.GetPortLevelSettings() {
+			if pls.GetOutlierDetection() != nil {
+				return true
+			}
+		}
+		return false
+	}
+	subsets := map[string]*networking.Subset{}
+	for _, subset := range rule.Subsets {
+		if subset != nil {
+			subsets[subset.Name] = subset
+		}
+	}
+	for name := range failures {
+		if name == "" {
+			if !hasOutlierDetection(rule.TrafficPolicy) {
+				v = appendValidation(v, WrapWarning(fmt.Errorf("%s: the host has no outlier detection",
+					routing.LocalOriginOutlierDetectionAnnotation)))
+			}
+			continue
+		}
+		subset, f := subsets[name]
+		if !f {
+			v = appendValidation(v, fmt.Errorf("%s: no subset named %q", routing.LocalOriginOutlierDetectionAnnotation, name))
+			continue
+		}
+		if !hasOutlierDetection(subset.TrafficPolicy) && !hasOutlierDetection(rule.TrafficPolicy) {
+			v = appendValidation(v, WrapWarning(fmt.Errorf("%s: subset %q has no outlier detection",
+				routing.LocalOriginOutlierDetectionAnnotation, name)))
+		}
+	}
+	return v
+}
+
+// validateSubsetSubjectAltNames validates the subject alternative names pinned for the subsets of a destination
+// rule. They only apply to the ISTIO_MUTUAL connections, so a warning is returned for the subsets using another TLS
+// mode or setting their own subject alternative names.
+func validateSubsetSubjectAltNames(value string, rule *networking.DestinationRule) Validation {
+	names, err := routing.ParseSubjectAltNames(value)
+	if err != nil {
+		return WrapError(err)
+	}
+	v := Validation{}
+	subsets := map[string]*networking.Subset{}
+	for _, subset := range rule.Subsets {
+		if subset != nil {
+			subsets[subset.Name] = subset
+		}
+	}
+	for name := range names {
+		subset, f := subsets[name]
+		if !f {
+			v = appendValidation(v, fmt.Errorf("%s: no subset named %q", routing.SubjectAltNamesAnnotation, name))
+			continue
+		}
+		tls := subset.GetTrafficPolicy().GetTls()
+		if tls == nil {
+			tls = rule.GetTrafficPolicy().GetTls()
+		}
+		if tls == nil {
+			continue
+		}
+		if tls.Mode != networking.ClientTLSSettings_ISTIO_MUTUAL {
+			v = appendValidation(v, WrapWarning(fmt.Errorf("%s: subset %q does not use ISTIO_MUTUAL",
+				routing.SubjectAltNamesAnnotation, name)))
+		} else if len(tls.SubjectAltNames) > 0 {
+			v = appendValidation(v, WrapWarning(fmt.Errorf("%s: subset %q sets its own subject alternative names",
+				routing.SubjectAltNamesAnnotation, name)))
+		}
+	}
+	return v
+}
+
 func validateExportTo(namespace string, exportTo []string, isServiceEntry bool) (errs error) {
 	if len(exportTo) > 0 {
 		// Make sure there are no duplicates
@@ -601,6 +770,12 @@ var ValidateEnvoyFilter = registerValidateFunc("ValidateEnvoyFilter",
 			return nil, err
 		}
 
+		if value, f := cfg.Annotations[routing.FilterOrderAnnotation]; f {
+			if _, err := routing.ParseFilterOrder(value); err != nil {
+				errs = appendValidation(errs, fmt.Errorf("Envoy filter: %v", err)) // nolint: golint,stylecheck
+			}
+		}
+
 		for _, cp := range rule.ConfigPatches {
 			if cp == nil {
 				errs = appendValidation(errs, fmt.Errorf("Envoy filter: null config patch")) // nolint: golint,stylecheck
@@ -886,6 +1061,19 @@ var ValidateSidecar = registerValidateFunc("ValidateSidecar",
 			}
 		}
 
+		if value, f := cfg.Annotations[security.SidecarIngressMtlsAnnotation]; f {
+			modes, err := security.ParseSidecarIngressMtls(value)
+			if err != nil {
+				errs = appendErrors(errs, fmt.Errorf("sidecar: invalid %s: %v", security.SidecarIngressMtlsAnnotation, err))
+			}
+			for port := range modes {
+				if _, found := portMap[port]; !found {
+					errs = appendErrors(errs, fmt.Errorf("sidecar: %s sets the mTLS mode of port %d, which is not an ingress listener port",
+						security.SidecarIngressMtlsAnnotation, port))
+				}
+			}
+		}
+
 		portMap = make(map[uint32]struct{})
 		udsMap := make(map[string]struct{})
 		catchAllEgressListenerFound := false
@@ -936,6 +1124,36 @@ var ValidateSidecar = registerValidateFunc("ValidateSidecar",
 
 		}
 
+		if value, f := cfg.Annotations[routing.PortRangesAnnotation]; f {
+			ranges, err := routing.ParsePortRanges(value)
+			if err == nil {
+				_, err = routing.ExpandEgressListeners(rule.Egress, ranges)
+			}
+			if err != nil {
+				errs = appendErrors(errs, fmt.Errorf("sidecar: %v", err))
+			}
+		}
+
+		if value, f := cfg.Annotations[routing.LocalRateLimitAnnotation]; f {
+			if rateLimit, err := routing.ParseLocalRateLimit(value); err != nil {
+				errs = appendErrors(errs, fmt.Errorf("sidecar: %v", err))
+			} else {
+				errs = appendErrors(errs, validateLocalRateLimitHeaders(routing.LocalRateLimitAnnotation, rateLimit))
+			}
+		}
+
+		if value, f := cfg.Annotations[routing.ProtocolDetectionAnnotation]; f {
+			if _, err := routing.ParseProtocolDetection(value); err != nil {
+				errs = appendErrors(errs, fmt.Errorf("sidecar: %v", err))
+			}
+		}
+
+		if value, f := cfg.Annotations[routing.AccessLogAnnotation]; f {
+			if _, err := routing.ParseAccessLog(value); err != nil {
+				errs = appendErrors(errs, fmt.Errorf("sidecar: %v", err))
+			}
+		}
+
 		errs = appendErrors(errs, validateSidecarOutboundTrafficPolicy(rule.OutboundTrafficPolicy))
 
 		return
@@ -1840,11 +2058,287 @@ var ValidateVirtualService = registerValidateFunc("ValidateVirtualService",
 		for _, tcpRoute := range virtualService.Tcp {
 			errs = appendValidation(errs, validateTCPRoute(tcpRoute))
 		}
+		if value, f := cfg.Annotations[routing.ConnectionPoolAnnotation]; f {
+			errs = appendValidation(errs, validateRouteConnectionPools(value, virtualService))
+		}
+		if value, f := cfg.Annotations[routing.RetryPolicyAnnotation]; f {
+			errs = appendValidation(errs, validateRouteRetryPolicies(value, virtualService))
+		}
+		if value, f := cfg.Annotations[routing.MirrorsAnnotation]; f {
+			errs = appendValidation(errs, validateRouteMirrors(value, virtualService))
+		}
+		if value, f := cfg.Annotations[routing.RouteLocalRateLimitAnnotation]; f {
+			errs = appendValidation(errs, validateRouteLocalRateLimits(value, virtualService))
+		}
+		if value, f := cfg.Annotations[routing.RouteTimeoutsAnnotation]; f {
+			errs = appendValidation(errs, validateRouteTimeouts(value, virtualService))
+		}
+		if value, f := cfg.Annotations[routing.RegexRewriteAnnotation]; f {
+			errs = appendValidation(errs, validateRouteRegexRewrites(value, virtualService))
+		}
 
 		errs = appendValidation(errs, validateExportTo(cfg.Namespace, virtualService.ExportTo, false))
 		return errs.Unwrap()
 	})
 
+// validateRouteConnectionPools validates the connection pool overrides of the HTTP routes of a virtual service.
+// Routes delegated to other virtual services can't be checked here, so unknown route names are only rejected
+// when the virtual service has no delegate.
+func validateRouteConnectionPools(value string, vs *networking.VirtualService) (errs error) {
+	pools, err := routing.ParseConnectionPools(value)
+	if err != nil {
+		return err
+	}
+	routes := map[string]bool{}
+	hasDelegate := false
+	for _, httpRoute := range vs.Http {
+		if httpRoute == nil {
+			continue
+		}
+		routes[httpRoute.Name] = true
+		if httpRoute.Delegate != nil {
+			hasDelegate = true
+		}
+	}
+	for name, settings := range pools {
+		if name == "" || strings.ContainsAny(name, "|~") {
+			errs = appendErrors(errs, fmt.Errorf("%s: invalid route name %q", routing.ConnectionPoolAnnotation, name))
+			continue
+		}
+		if !routes[name] && !hasDelegate {
+			errs = appendErrors(errs, fmt.Errorf("%s: no http route named %q", routing.ConnectionPoolAnnotation, name))
+		}
+		if err := validateConnectionPool(settings); err != nil {
+			errs = appendErrors(errs, fmt.Errorf("%s: invalid connection pool of route %q: %v",
+				routing.ConnectionPoolAnnotation, name, err))
+		}
+	}
+	return
+}
+
+// validateRouteRetryPolicies validates the retry policies of the HTTP routes of a virtual service. As for the
+// connection pool overrides, unknown route names are only rejected when the virtual service has no delegate.
+func validateRouteRetryPolicies(value string, vs *networking.VirtualService) (errs error) {
+	policies, err := routing.ParseRetryPolicies(value)
+	if err != nil {
+		return err
+	}
+	routes := map[string]*networking.HTTPRoute{}
+	hasDelegate := false
+	for _, httpRoute := range vs.Http {
+		if httpRoute == nil {
+			continue
+		}
+		routes[httpRoute.Name] = httpRoute
+		if httpRoute.Delegate != nil {
+			hasDelegate = true
+		}
+	}
+	for name, policy := range policies {
+		if name == "" || strings.ContainsAny(name, "|~") {
+			errs = appendErrors(errs, fmt.Errorf("%s: invalid route name %q", routing.RetryPolicyAnnotation, name))
+			continue
+		}
+		httpRoute, f := routes[name]
+		if !f && !hasDelegate {
+			errs = appendErrors(errs, fmt.Errorf("%s: no http route named %q", routing.RetryPolicyAnnotation, name))
+		}
+		if f && httpRoute.Retries != nil && httpRoute.Retries.Attempts <= 0 &&
+			(len(policy.RetriableHeaders) > 0 || len(policy.RetriableRequestHeaders) > 0) {
+			errs = appendErrors(errs, fmt.Errorf("%s: retriable headers of route %q require retries to be enabled",
+				routing.RetryPolicyAnnotation, name))
+		}
+		if f && policy.Hedge != nil && policy.Hedge.HedgeOnPerTryTimeout &&
+			(httpRoute.Retries == nil || httpRoute.Retries.Attempts <= 0 || httpRoute.Retries.PerTryTimeout == nil) {
+			errs = appendErrors(errs, fmt.Errorf("%s: hedging on per try timeout of route %q requires retries with a per try timeout",
+				routing.RetryPolicyAnnotation, name))
+		}
+		for header, match := range policy.RetriableHeaders {
+			errs = appendErrors(errs, validateRetriableHeader(header, match))
+		}
+		for header, match := range policy.RetriableRequestHeaders {
+			errs = appendErrors(errs, validateRetriableHeader(header, match))
+		}
+	}
+	return
+}
+
+// validateRouteMirrors validates the mirrors added to the HTTP routes of a virtual service. As for the connection
+// pool overrides, unknown route names are only rejected when the virtual service has no delegate.
+func validateRouteMirrors(value string, vs *networking.VirtualService) (errs error) {
+	mirrors, err := routing.ParseMirrors(value)
+	if err != nil {
+		return err
+	}
+	routes := map[string]bool{}
+	hasDelegate := false
+	for _, httpRoute := range vs.Http {
+		if httpRoute == nil {
+			continue
+		}
+		routes[httpRoute.Name] = true
+		if httpRoute.Delegate != nil {
+			hasDelegate = true
+		}
+	}
+	for name, routeMirrors := range mirrors {
+		if name == "" {
+			errs = appendErrors(errs, fmt.Errorf("%s: invalid route name %q", routing.MirrorsAnnotation, name))
+			continue
+		}
+		if !routes[name] && !hasDelegate {
+			errs = appendErrors(errs, fmt.Errorf("%s: no http route named %q", routing.MirrorsAnnotation, name))
+		}
+		for _, mirror := range routeMirrors {
+			if err := validateDestination(mirror.Destination); err != nil {
+				errs = appendErrors(errs, fmt.Errorf("%s: invalid mirror of route %q: %v", routing.MirrorsAnnotation, name, err))
+			}
+			if err := validatePercentage(mirror.Percentage); err != nil {
+				errs = appendErrors(errs, fmt.Errorf("%s: invalid mirror of route %q: %v", routing.MirrorsAnnotation, name, err))
+			}
+		}
+	}
+	return
+}
+
+// validateRouteLocalRateLimits validates the local rate limits of the HTTP routes of a virtual service. As for the
+// connection pool overrides, unknown route names are only rejected when the virtual service has no delegate.
+func validateRouteLocalRateLimits(value string, vs *networking.VirtualService) (errs error) {
+	rateLimits, err := routing.ParseRouteLocalRateLimits(value)
+	if err != nil {
+		return err
+	}
+	routes := map[string]bool{}
+	hasDelegate := false
+	for _, httpRoute := range vs.Http {
+		if httpRoute == nil {
+			continue
+		}
+		routes[httpRoute.Name] = true
+		if httpRoute.Delegate != nil {
+			hasDelegate = true
+		}
+	}
+	for name, rateLimit := range rateLimits {
+		if name == "" {
+			errs = appendErrors(errs, fmt.Errorf("%s: invalid route name %q", routing.RouteLocalRateLimitAnnotation, name))
+			continue
+		}
+		if !routes[name] && !hasDelegate {
+			errs = appendErrors(errs, fmt.Errorf("%s: no http route named %q", routing.RouteLocalRateLimitAnnotation, name))
+		}
+		errs = appendErrors(errs, validateLocalRateLimitHeaders(routing.RouteLocalRateLimitAnnotation, rateLimit))
+	}
+	return
+}
+
+// validateRouteTimeouts validates the stream timeouts of the HTTP routes of a virtual service. As for the connection
+// pool overrides, unknown route names are only rejected when the virtual service has no delegate.
+func validateRouteTimeouts(value string, vs *networking.VirtualService) (errs error) {
+	timeouts, err := routing.ParseRouteTimeouts(value)
+	if err != nil {
+		return err
+	}
+	routes := map[string]bool{}
+	hasDelegate := false
+	for _, httpRoute := range vs.Http {
+		if httpRoute == nil {
+			continue
+		}
+		routes[httpRoute.Name] = true
+		if httpRoute.Delegate != nil {
+			hasDelegate = true
+		}
+	}
+	for name := range timeouts {
+		if name == "" {
+			errs = appendErrors(errs, fmt.Errorf("%s: invalid route name %q", routing.RouteTimeoutsAnnotation, name))
+			continue
+		}
+		if !routes[name] && !hasDelegate {
+			errs = appendErrors(errs, fmt.Errorf("%s: no http route named %q", routing.RouteTimeoutsAnnotation, name))
+		}
+	}
+	return
+}
+
+// validateRouteRegexRewrites validates the regex rewrites of the HTTP routes of a virtual service, which can't have
+// a uri rewrite as well. As for the connection pool overrides, unknown route names are only rejected when the virtual
+// service has no delegate.
+func validateRouteRegexRewrites(value string, vs *networking.VirtualService) (errs error) {
+	rewrites, err := routing.ParseRegexRewrites(value)
+	if err != nil {
+		return err
+	}
+	routes := map[string]*networking.HTTPRoute{}
+	hasDelegate := false
+	for _, httpRoute := range vs.Http {
+		if httpRoute == nil {
+			continue
+		}
+		routes[httpRoute.Name] = httpRoute
+		if httpRoute.Delegate != nil {
+			hasDelegate = true
+		}
+	}
+	for name := range rewrites {
+		if name == "" {
+			errs = appendErrors(errs, fmt.Errorf("%s: invalid route name %q", routing.RegexRewriteAnnotation, name))
+			continue
+		}
+		httpRoute, f := routes[name]
+		if !f {
+			if !hasDelegate {
+				errs = appendErrors(errs, fmt.Errorf("%s: no http route named %q", routing.RegexRewriteAnnotation, name))
+			}
+			continue
+		}
+		if httpRoute.Rewrite.GetUri() != "" {
+			errs = appendErrors(errs, fmt.Errorf("%s: http route %q already has a uri rewrite", routing.RegexRewriteAnnotation,
+				name))
+		}
+		if httpRoute.Redirect != nil {
+			errs = appendErrors(errs, fmt.Errorf("%s: http route %q is a redirect", routing.RegexRewriteAnnotation, name))
+		}
+	}
+	return
+}
+
+// validateLocalRateLimitHeaders validates the headers of a local rate limit.
+func validateLocalRateLimitHeaders(annotation string, rateLimit *routing.LocalRateLimit) (errs error) {
+	for name, value := range rateLimit.ResponseHeaders {
+		if err := ValidateHTTPHeaderName(name); err != nil {
+			errs = appendErrors(errs, fmt.Errorf("%s: invalid response header: %v", annotation, err))
+		}
+		if err := ValidateHTTPHeaderValue(value); err != nil {
+			errs = appendErrors(errs, fmt.Errorf("%s: invalid value of response header %q: %v", annotation, name, err))
+		}
+	}
+	for _, descriptor := range rateLimit.Descriptors {
+		for _, entry := range descriptor.Entries {
+			if entry.Header == "" {
+				continue
+			}
+			if err := ValidateHTTPHeaderName(entry.Header); err != nil {
+				errs = appendErrors(errs, fmt.Errorf("%s: invalid descriptor header: %v", annotation, err))
+			}
+		}
+	}
+	return
+}
+
+func validateRetriableHeader(name string, match *networking.StringMatch) (errs error) {
+	if err := ValidateHTTPHeaderName(name); err != nil {
+		errs = appendErrors(errs, fmt.Errorf("%s: %v", routing.RetryPolicyAnnotation, err))
+	}
+	if match.GetMatchType() != nil {
+		if err := validateStringMatchRegexp(match, "retriable header"); err != nil {
+			errs = appendErrors(errs, fmt.Errorf("%s: %v", routing.RetryPolicyAnnotation, err))
+		}
+	}
+	return
+}
+
 func validateTLSRoute(tls *networking.TLSRoute, context *networking.VirtualService) error {
 	var errs error
 	if tls == nil {
@@ -2511,10 +3005,106 @@ var ValidateServiceEntry = registerValidateFunc("ValidateServiceEntry",
 				ValidatePort(int(port.Number)))
 		}
 
+		if value, f := cfg.Annotations[routing.PortResolutionAnnotation]; f {
+			errs = appendErrors(errs, validatePortResolutions(value, serviceEntry, cidrFound))
+		}
+
+		if value, f := cfg.Annotations[routing.PortRangesAnnotation]; f {
+			errs = appendErrors(errs, validatePortRanges(value, serviceEntry))
+		}
+
 		errs = appendErrors(errs, validateExportTo(cfg.Namespace, serviceEntry.ExportTo, true))
 		return
 	})
 
+// validatePortResolutions validates the resolution overrides of the ports of a service entry. The endpoints and
+// hosts of the service entry are shared by all its ports, so they must be usable with each resolution.
+func validatePortResolutions(value string, serviceEntry *networking.ServiceEntry, cidrFound bool) (errs error) {
+	resolutions, err := routing.ParsePortResolutions(value)
+	if err != nil {
+		return err
+	}
+	ports := make(map[uint32]*networking.Port, len(serviceEntry.Ports))
+	for _, port := range serviceEntry.Ports {
+		if port != nil {
+			ports[port.Number] = port
+		}
+	}
+	for number, resolution := range resolutions {
+		port, f := ports[number]
+		if !f {
+			errs = appendErrors(errs, fmt.Errorf("%s: port %d is not defined by the service entry",
+				routing.PortResolutionAnnotation, number))
+			continue
+		}
+		switch resolution {
+		case networking.ServiceEntry_NONE:
+			if len(serviceEntry.Endpoints) != 0 {
+				errs = appendErrors(errs, fmt.Errorf("%s: no endpoints should be provided for port %d with resolution type none",
+					routing.PortResolutionAnnotation, number))
+			}
+		case networking.ServiceEntry_STATIC:
+			if len(serviceEntry.Endpoints) == 0 && serviceEntry.WorkloadSelector == nil {
+				errs = appendErrors(errs, fmt.Errorf("%s: endpoints or workloadSelector must be provided for port %d with resolution type static",
+					routing.PortResolutionAnnotation, number))
+			}
+			for _, endpoint := range serviceEntry.Endpoints {
+				if !strings.HasPrefix(endpoint.Address, UnixAddressPrefix) && net.ParseIP(endpoint.Address) == nil {
+					errs = appendErrors(errs, fmt.Errorf("%s: endpoint address %q of port %d with resolution type static is not an IP address",
+						routing.PortResolutionAnnotation, endpoint.Address, number))
+				}
+			}
+		case networking.ServiceEntry_DNS:
+			if cidrFound {
+				errs = appendErrors(errs, fmt.Errorf("%s: CIDR addresses are allowed only for NONE/STATIC resolution types",
+					routing.PortResolutionAnnotation))
+			}
+			if len(serviceEntry.Endpoints) == 0 {
+				for _, hostname := range serviceEntry.Hosts {
+					if err := ValidateFQDN(hostname); err != nil {
+						errs = appendErrors(errs, fmt.Errorf("%s: hosts must be FQDN if no endpoints are provided for port %d with resolution type DNS",
+							routing.PortResolutionAnnotation, number))
+						break
+					}
+				}
+			}
+			for _, endpoint := range serviceEntry.Endpoints {
+				if strings.HasPrefix(endpoint.Address, UnixAddressPrefix) {
+					errs = appendErrors(errs, fmt.Errorf("%s: unix endpoint %s can't be used by port %d with resolution type DNS",
+						routing.PortResolutionAnnotation, endpoint.Address, number))
+				}
+			}
+		}
+		// Same as for the resolution of the service entry: hosts of plain TCP ports can't be differentiated.
+		if resolution != networking.ServiceEntry_NONE && len(serviceEntry.Hosts) > 1 {
+			if p := protocol.Parse(port.Protocol); !p.IsHTTP() && !p.IsTLS() {
+				errs = appendErrors(errs, fmt.Errorf("%s: multiple hosts provided with non-HTTP, non-TLS port %d",
+					routing.PortResolutionAnnotation, number))
+			}
+		}
+	}
+	return
+}
+
+// validatePortRanges validates the port ranges of a service entry, which must start at declared ports and add
+// ports with valid names.
+func validatePortRanges(value string, serviceEntry *networking.ServiceEntry) error {
+	ranges, err := routing.ParsePortRanges(value)
+	if err != nil {
+		return err
+	}
+	ports, err := routing.ExpandPorts(serviceEntry.Ports, ranges)
+	if err != nil {
+		return err
+	}
+	for _, port := range ports[len(serviceEntry.Ports):] {
+		if err := ValidatePortName(port.Name); err != nil {
+			return fmt.Errorf("%s: %v", routing.PortRangesAnnotation, err)
+		}
+	}
+	return nil
+}
+
 // ValidatePortName validates a port name to DNS-1123
 func ValidatePortName(name string) error {
 	if !labels.IsDNS1123Label(name) {
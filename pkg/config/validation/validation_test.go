@@ -31,6 +31,8 @@ import (
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/routing"
+	"istio.io/istio/pkg/config/security"
 )
 
 const (
@@ -979,6 +981,32 @@ func TestValidateServer(t *testing.T) {
 			},
 			"must have TLS",
 		},
+		{
+			"tls on HTTP3",
+			&networking.Server{
+				Hosts: []string{"foo.bar.com"},
+				Port:  &networking.Port{Number: 443, Name: "http3", Protocol: "HTTP3"},
+				Tls:   &networking.ServerTLSSettings{Mode: networking.ServerTLSSettings_SIMPLE, CredentialName: "foo-cert"},
+			},
+			"",
+		},
+		{
+			"no tls on HTTP3",
+			&networking.Server{
+				Hosts: []string{"foo.bar.com"},
+				Port:  &networking.Port{Number: 443, Name: "http3", Protocol: "HTTP3"},
+			},
+			"must have TLS",
+		},
+		{
+			"tls passthrough on HTTP3",
+			&networking.Server{
+				Hosts: []string{"foo.bar.com"},
+				Port:  &networking.Port{Number: 443, Name: "http3", Protocol: "HTTP3"},
+				Tls:   &networking.ServerTLSSettings{Mode: networking.ServerTLSSettings_PASSTHROUGH},
+			},
+			"must terminate TLS",
+		},
 		{
 			"tls on HTTP",
 			&networking.Server{
@@ -4083,6 +4111,136 @@ func TestValidateServiceEntries(t *testing.T) {
 	}
 }
 
+func TestValidateServiceEntryPortResolution(t *testing.T) {
+	ports := []*networking.Port{
+		{Number: 80, Protocol: "http", Name: "http"},
+		{Number: 443, Protocol: "tls", Name: "tls"},
+		{Number: 3306, Protocol: "tcp", Name: "mysql"},
+	}
+	dns := &networking.ServiceEntry{
+		Hosts:      []string{"api.example.com"},
+		Ports:      ports,
+		Resolution: networking.ServiceEntry_DNS,
+	}
+	dnsWithEndpoints := &networking.ServiceEntry{
+		Hosts:      []string{"api.example.com"},
+		Ports:      ports,
+		Endpoints:  []*networking.WorkloadEntry{{Address: "lb.example.com"}},
+		Resolution: networking.ServiceEntry_DNS,
+	}
+	none := &networking.ServiceEntry{
+		Hosts:      []string{"*.example.com"},
+		Ports:      ports,
+		Resolution: networking.ServiceEntry_NONE,
+	}
+	noneMultipleHosts := &networking.ServiceEntry{
+		Hosts:      []string{"api.example.com", "www.example.com"},
+		Ports:      ports,
+		Resolution: networking.ServiceEntry_NONE,
+	}
+	static := &networking.ServiceEntry{
+		Hosts:      []string{"api.example.com"},
+		Ports:      ports,
+		Endpoints:  []*networking.WorkloadEntry{{Address: "1.1.1.1"}},
+		Resolution: networking.ServiceEntry_STATIC,
+	}
+	tests := []struct {
+		name  string
+		entry *networking.ServiceEntry
+		value string
+		valid bool
+	}{
+		{name: "none port of dns entry", entry: dns, value: "443=NONE", valid: true},
+		{name: "dns and none ports", entry: dns, value: "443=NONE,80=DNS", valid: true},
+		{name: "unknown port", entry: dns, value: "8080=NONE", valid: false},
+		{name: "invalid value", entry: dns, value: "443", valid: false},
+		{name: "none port with endpoints", entry: dnsWithEndpoints, value: "443=NONE", valid: false},
+		{name: "static port with hostname endpoints", entry: dnsWithEndpoints, value: "443=STATIC", valid: false},
+		{name: "static port without endpoints", entry: dns, value: "443=STATIC", valid: false},
+		{name: "dns port with wildcard hosts", entry: none, value: "443=DNS", valid: false},
+		{name: "dns port of static entry", entry: static, value: "443=DNS", valid: true},
+		{name: "tls port with multiple hosts", entry: noneMultipleHosts, value: "443=DNS", valid: true},
+		{name: "tcp port with multiple hosts", entry: noneMultipleHosts, value: "3306=DNS", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValidateServiceEntry(config.Config{
+				Meta: config.Meta{
+					Name:        "external",
+					Namespace:   "default",
+					Annotations: map[string]string{routing.PortResolutionAnnotation: tt.value},
+				},
+				Spec: tt.entry,
+			})
+			if err == nil && !tt.valid {
+				t.Fatalf("ValidateServiceEntry(%v) = true, wanted false", tt.value)
+			} else if err != nil && tt.valid {
+				t.Fatalf("ValidateServiceEntry(%v) = %v, wanted true", tt.value, err)
+			}
+		})
+	}
+}
+
+func TestValidatePortRanges(t *testing.T) {
+	entry := &networking.ServiceEntry{
+		Hosts:      []string{"ftp.example.com"},
+		Addresses:  []string{"10.1.1.1"},
+		Ports:      []*networking.Port{{Number: 21, Protocol: "tcp", Name: "ftp"}, {Number: 30000, Protocol: "tcp", Name: "ftp-data"}, {Number: 31000, Protocol: "tcp", Name: "ftp-alt"}},
+		Resolution: networking.ServiceEntry_NONE,
+	}
+	longName := &networking.ServiceEntry{
+		Hosts:      []string{"ftp.example.com"},
+		Addresses:  []string{"10.1.1.1"},
+		Ports:      []*networking.Port{{Number: 30000, Protocol: "tcp", Name: strings.Repeat("a", 60)}},
+		Resolution: networking.ServiceEntry_NONE,
+	}
+	sidecar := &networking.Sidecar{
+		Egress: []*networking.IstioEgressListener{
+			{Port: &networking.Port{Number: 5060, Protocol: "TCP", Name: "sip"}, Hosts: []string{"*/sip.example.com"}},
+			{Hosts: []string{"*/*"}},
+		},
+	}
+	tests := []struct {
+		name  string
+		spec  proto.Message
+		value string
+		valid bool
+	}{
+		{name: "service entry range", spec: entry, value: "30000-30999", valid: true},
+		{name: "service entry range not at a declared port", spec: entry, value: "30001-30999", valid: false},
+		{name: "service entry range including a declared port", spec: entry, value: "30000-31000", valid: false},
+		{name: "service entry invalid range", spec: entry, value: "30000", valid: false},
+		{name: "service entry range with invalid port names", spec: longName, value: "30000-30001", valid: false},
+		{name: "sidecar range", spec: sidecar, value: "5060-5080", valid: true},
+		{name: "sidecar range not at an egress listener port", spec: sidecar, value: "5000-5080", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Config{
+				Meta: config.Meta{
+					Name:        "ranges",
+					Namespace:   "default",
+					Annotations: map[string]string{routing.PortRangesAnnotation: tt.value},
+				},
+				Spec: tt.spec,
+			}
+			var err error
+			if _, ok := tt.spec.(*networking.Sidecar); ok {
+				_, err = ValidateSidecar(cfg)
+			} else {
+				_, err = ValidateServiceEntry(cfg)
+			}
+			if err == nil && !tt.valid {
+				t.Fatalf("validation of %v = true, wanted false", tt.value)
+			} else if err != nil && tt.valid {
+				t.Fatalf("validation of %v = %v, wanted true", tt.value, err)
+			}
+		})
+	}
+}
+
 func TestValidateAuthorizationPolicy(t *testing.T) {
 	cases := []struct {
 		name  string
@@ -4717,6 +4875,41 @@ func TestValidateAuthorizationPolicy(t *testing.T) {
 			},
 			valid: false,
 		},
+		{
+			name: "IP set in ipBlocks",
+			in: &security_beta.AuthorizationPolicy{
+				Rules: []*security_beta.Rule{
+					{
+						From: []*security_beta.Rule_From{
+							{
+								Source: &security_beta.Source{
+									IpBlocks:          []string{"1.2.3.4", "ipset:allowed"},
+									NotRemoteIpBlocks: []string{"ipset:blocked"},
+								},
+							},
+						},
+					},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "empty IP set name in ipBlocks",
+			in: &security_beta.AuthorizationPolicy{
+				Rules: []*security_beta.Rule{
+					{
+						From: []*security_beta.Rule_From{
+							{
+								Source: &security_beta.Source{
+									IpBlocks: []string{"ipset:"},
+								},
+							},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
 		{
 			name: "invalid ip and port in remoteIpBlocks",
 			in: &security_beta.AuthorizationPolicy{
@@ -5354,6 +5547,632 @@ func TestValidateSidecar(t *testing.T) {
 	}
 }
 
+func TestValidateSidecarIngressMtls(t *testing.T) {
+	sidecar := &networking.Sidecar{
+		Ingress: []*networking.IstioIngressListener{
+			{
+				Port: &networking.Port{
+					Protocol: "http",
+					Number:   9080,
+					Name:     "http",
+				},
+				DefaultEndpoint: "127.0.0.1:9080",
+			},
+			{
+				Port: &networking.Port{
+					Protocol: "tcp",
+					Number:   9090,
+					Name:     "tcp",
+				},
+				DefaultEndpoint: "127.0.0.1:9090",
+			},
+		},
+	}
+	tests := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{"valid", "9080=STRICT, 9090=disable", true},
+		{"empty", "", true},
+		{"invalid mode", "9080=MUTUAL", false},
+		{"unset mode", "9080=UNSET", false},
+		{"invalid port", "http=STRICT", false},
+		{"missing mode", "9080", false},
+		{"not an ingress port", "8080=STRICT", false},
+		{"conflicting modes", "9080=STRICT,9080=DISABLE", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValidateSidecar(config.Config{
+				Meta: config.Meta{
+					Name:        "foo",
+					Namespace:   "bar",
+					Annotations: map[string]string{security.SidecarIngressMtlsAnnotation: tt.value},
+				},
+				Spec: sidecar,
+			})
+			if err == nil && !tt.valid {
+				t.Fatalf("ValidateSidecar(%v) = true, wanted false", tt.value)
+			} else if err != nil && tt.valid {
+				t.Fatalf("ValidateSidecar(%v) = %v, wanted true", tt.value, err)
+			}
+		})
+	}
+}
+
+func TestValidateSidecarProtocolDetection(t *testing.T) {
+	sidecar := &networking.Sidecar{
+		Egress: []*networking.IstioEgressListener{{Hosts: []string{"*/*"}}},
+	}
+	tests := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{"valid", "3306=100ms, 8000=5s/HTTP", true},
+		{"invalid timeout", "3306=fast", false},
+		{"invalid fallback", "3306=1s/GRPC", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValidateSidecar(config.Config{
+				Meta: config.Meta{
+					Name:        "foo",
+					Namespace:   "bar",
+					Annotations: map[string]string{routing.ProtocolDetectionAnnotation: tt.value},
+				},
+				Spec: sidecar,
+			})
+			if err == nil && !tt.valid {
+				t.Fatalf("ValidateSidecar(%v) = true, wanted false", tt.value)
+			} else if err != nil && tt.valid {
+				t.Fatalf("ValidateSidecar(%v) = %v, wanted true", tt.value, err)
+			}
+		})
+	}
+}
+
+func TestValidateVirtualServiceRouteConnectionPool(t *testing.T) {
+	virtualService := &networking.VirtualService{
+		Hosts: []string{"reviews"},
+		Http: []*networking.HTTPRoute{{
+			Name: "chatty",
+			Match: []*networking.HTTPMatchRequest{{
+				Uri: &networking.StringMatch{MatchType: &networking.StringMatch_Prefix{Prefix: "/chatty"}},
+			}},
+			Route: []*networking.HTTPRouteDestination{{
+				Destination: &networking.Destination{Host: "reviews"},
+			}},
+		}},
+	}
+	tests := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{"valid", `{"chatty": {"http": {"maxRequestsPerConnection": 1, "maxRetries": 10}}}`, true},
+		{"invalid json", `{"chatty": 1}`, false},
+		{"unknown route", `{"other": {"http": {"maxRetries": 10}}}`, false},
+		{"invalid route name", `{"chatty~1": {"http": {"maxRetries": 10}}}`, false},
+		{"empty settings", `{"chatty": {}}`, false},
+		{"negative value", `{"chatty": {"http": {"maxRetries": -1}}}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValidateVirtualService(config.Config{
+				Meta: config.Meta{
+					Name:        "reviews",
+					Namespace:   "default",
+					Annotations: map[string]string{routing.ConnectionPoolAnnotation: tt.value},
+				},
+				Spec: virtualService,
+			})
+			if err == nil && !tt.valid {
+				t.Fatalf("ValidateVirtualService(%v) = true, wanted false", tt.value)
+			} else if err != nil && tt.valid {
+				t.Fatalf("ValidateVirtualService(%v) = %v, wanted true", tt.value, err)
+			}
+		})
+	}
+}
+
+func TestValidateVirtualServiceRouteLocalRateLimit(t *testing.T) {
+	virtualService := &networking.VirtualService{
+		Hosts: []string{"reviews"},
+		Http: []*networking.HTTPRoute{{
+			Name: "api",
+			Route: []*networking.HTTPRouteDestination{{
+				Destination: &networking.Destination{Host: "reviews"},
+			}},
+		}},
+	}
+	tests := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{"valid", `{"api": {"maxTokens": 10, "fillInterval": "1s", "responseHeaders": {"x-limited": "true"}}}`, true},
+		{"unknown route", `{"other": {"maxTokens": 10, "fillInterval": "1s"}}`, false},
+		{"invalid token bucket", `{"api": {"maxTokens": 10, "fillInterval": "1ms"}}`, false},
+		{"invalid response header", `{"api": {"maxTokens": 10, "fillInterval": "1s", "responseHeaders": {"x limited": "true"}}}`, false},
+		{"invalid descriptor header", `{"api": {"maxTokens": 10, "fillInterval": "1s", "descriptors": [{"entries":
+			[{"key": "user", "header": "x user", "value": "batch"}], "maxTokens": 1, "fillInterval": "1s"}]}}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValidateVirtualService(config.Config{
+				Meta: config.Meta{
+					Name:        "reviews",
+					Namespace:   "default",
+					Annotations: map[string]string{routing.RouteLocalRateLimitAnnotation: tt.value},
+				},
+				Spec: virtualService,
+			})
+			if err == nil && !tt.valid {
+				t.Fatalf("ValidateVirtualService(%v) = true, wanted false", tt.value)
+			} else if err != nil && tt.valid {
+				t.Fatalf("ValidateVirtualService(%v) = %v, wanted true", tt.value, err)
+			}
+		})
+	}
+}
+
+func TestValidateVirtualServiceRouteTimeouts(t *testing.T) {
+	virtualService := &networking.VirtualService{
+		Hosts: []string{"reviews"},
+		Http: []*networking.HTTPRoute{{
+			Name: "events",
+			Route: []*networking.HTTPRouteDestination{{
+				Destination: &networking.Destination{Host: "reviews"},
+			}},
+		}},
+	}
+	tests := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{"valid", `{"events": {"idleTimeout": "5m", "maxStreamDuration": "0s"}}`, true},
+		{"unknown route", `{"other": {"idleTimeout": "5m"}}`, false},
+		{"no timeout", `{"events": {}}`, false},
+		{"invalid duration", `{"events": {"maxStreamDuration": "forever"}}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValidateVirtualService(config.Config{
+				Meta: config.Meta{
+					Name:        "reviews",
+					Namespace:   "default",
+					Annotations: map[string]string{routing.RouteTimeoutsAnnotation: tt.value},
+				},
+				Spec: virtualService,
+			})
+			if err == nil && !tt.valid {
+				t.Fatalf("ValidateVirtualService(%v) = true, wanted false", tt.value)
+			} else if err != nil && tt.valid {
+				t.Fatalf("ValidateVirtualService(%v) = %v, wanted true", tt.value, err)
+			}
+		})
+	}
+}
+
+func TestValidateVirtualServiceRouteRegexRewrite(t *testing.T) {
+	virtualService := &networking.VirtualService{
+		Hosts: []string{"reviews"},
+		Http: []*networking.HTTPRoute{
+			{
+				Name: "api",
+				Route: []*networking.HTTPRouteDestination{{
+					Destination: &networking.Destination{Host: "reviews"},
+				}},
+			},
+			{
+				Name:    "legacy",
+				Rewrite: &networking.HTTPRewrite{Uri: "/v2"},
+				Route: []*networking.HTTPRouteDestination{{
+					Destination: &networking.Destination{Host: "reviews"},
+				}},
+			},
+		},
+	}
+	tests := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{"valid", `{"api": {"match": "^/api/v1/(.*)$", "substitution": "/\\1"}}`, true},
+		{"unknown route", `{"other": {"match": "^/api/", "substitution": "/"}}`, false},
+		{"invalid regex", `{"api": {"match": "^/api/(", "substitution": "/"}}`, false},
+		{"route with uri rewrite", `{"legacy": {"match": "^/api/", "substitution": "/"}}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValidateVirtualService(config.Config{
+				Meta: config.Meta{
+					Name:        "reviews",
+					Namespace:   "default",
+					Annotations: map[string]string{routing.RegexRewriteAnnotation: tt.value},
+				},
+				Spec: virtualService,
+			})
+			if err == nil && !tt.valid {
+				t.Fatalf("ValidateVirtualService(%v) = true, wanted false", tt.value)
+			} else if err != nil && tt.valid {
+				t.Fatalf("ValidateVirtualService(%v) = %v, wanted true", tt.value, err)
+			}
+		})
+	}
+}
+
+func TestValidateVirtualServiceRouteRetryPolicy(t *testing.T) {
+	virtualService := &networking.VirtualService{
+		Hosts: []string{"reviews"},
+		Http: []*networking.HTTPRoute{
+			{
+				Name: "checkout",
+				Match: []*networking.HTTPMatchRequest{{
+					Uri: &networking.StringMatch{MatchType: &networking.StringMatch_Prefix{Prefix: "/checkout"}},
+				}},
+				Route: []*networking.HTTPRouteDestination{{
+					Destination: &networking.Destination{Host: "reviews"},
+				}},
+			},
+			{
+				Name:    "noretry",
+				Retries: &networking.HTTPRetry{Attempts: 0},
+				Route: []*networking.HTTPRouteDestination{{
+					Destination: &networking.Destination{Host: "reviews"},
+				}},
+			},
+			{
+				Name:    "hedged",
+				Retries: &networking.HTTPRetry{Attempts: 2, PerTryTimeout: &types.Duration{Nanos: 100000000}},
+				Route: []*networking.HTTPRouteDestination{{
+					Destination: &networking.Destination{Host: "reviews"},
+				}},
+			},
+		},
+	}
+	tests := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{"valid", `{"checkout": {"budget": {"percent": 20}, "retriableHeaders": {"x-retry": {"exact": "true"}}}}`, true},
+		{"invalid json", `{"checkout": 1}`, false},
+		{"unknown route", `{"other": {"budget": {"percent": 20}}}`, false},
+		{"invalid route name", `{"checkout~1": {"budget": {"percent": 20}}}`, false},
+		{"invalid budget", `{"checkout": {"budget": {"percent": 200}}}`, false},
+		{"invalid regex", `{"checkout": {"retriableRequestHeaders": {"x-retry": {"regex": "("}}}}`, false},
+		{"budget without retries", `{"noretry": {"budget": {"percent": 20}}}`, true},
+		{"headers without retries", `{"noretry": {"retriableHeaders": {"x-retry": {}}}}`, false},
+		{"hedge", `{"hedged": {"hedge": {"initialRequests": 2, "hedgeOnPerTryTimeout": true}}}`, true},
+		{"empty hedge", `{"hedged": {"hedge": {}}}`, false},
+		{"hedge without per try timeout", `{"checkout": {"hedge": {"hedgeOnPerTryTimeout": true}}}`, false},
+		{"hedge without retries", `{"noretry": {"hedge": {"hedgeOnPerTryTimeout": true}}}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValidateVirtualService(config.Config{
+				Meta: config.Meta{
+					Name:        "reviews",
+					Namespace:   "default",
+					Annotations: map[string]string{routing.RetryPolicyAnnotation: tt.value},
+				},
+				Spec: virtualService,
+			})
+			if err == nil && !tt.valid {
+				t.Fatalf("ValidateVirtualService(%v) = true, wanted false", tt.value)
+			} else if err != nil && tt.valid {
+				t.Fatalf("ValidateVirtualService(%v) = %v, wanted true", tt.value, err)
+			}
+		})
+	}
+}
+
+func TestValidateVirtualServiceRouteMirrors(t *testing.T) {
+	virtualService := &networking.VirtualService{
+		Hosts: []string{"reviews"},
+		Http: []*networking.HTTPRoute{{
+			Name: "checkout",
+			Route: []*networking.HTTPRouteDestination{{
+				Destination: &networking.Destination{Host: "reviews"},
+			}},
+		}},
+	}
+	tests := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{"valid", `{"checkout": [{"destination": {"host": "reviews.staging"}, "percentage": 10}, {"destination": {"host": "reviews.test"}}]}`, true},
+		{"invalid json", `{"checkout": 1}`, false},
+		{"unknown route", `{"other": [{"destination": {"host": "reviews.staging"}}]}`, false},
+		{"missing destination", `{"checkout": [{"percentage": 10}]}`, false},
+		{"invalid host", `{"checkout": [{"destination": {"host": "*"}}]}`, false},
+		{"invalid subset", `{"checkout": [{"destination": {"host": "reviews.staging", "subset": "v_1"}}]}`, false},
+		{"invalid percentage", `{"checkout": [{"destination": {"host": "reviews.staging"}, "percentage": 101}]}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValidateVirtualService(config.Config{
+				Meta: config.Meta{
+					Name:        "reviews",
+					Namespace:   "default",
+					Annotations: map[string]string{routing.MirrorsAnnotation: tt.value},
+				},
+				Spec: virtualService,
+			})
+			if err == nil && !tt.valid {
+				t.Fatalf("ValidateVirtualService(%v) = true, wanted false", tt.value)
+			} else if err != nil && tt.valid {
+				t.Fatalf("ValidateVirtualService(%v) = %v, wanted true", tt.value, err)
+			}
+		})
+	}
+}
+
+func TestValidateDestinationRuleActiveRequestBias(t *testing.T) {
+	leastConn := &networking.TrafficPolicy{
+		LoadBalancer: &networking.LoadBalancerSettings{
+			LbPolicy: &networking.LoadBalancerSettings_Simple{Simple: networking.LoadBalancerSettings_LEAST_CONN},
+		},
+	}
+	rule := &networking.DestinationRule{
+		Host:          "reviews",
+		TrafficPolicy: leastConn,
+		Subsets: []*networking.Subset{
+			{Name: "v1", Labels: map[string]string{"version": "v1"}},
+			{Name: "v2", Labels: map[string]string{"version": "v2"}},
+		},
+	}
+	roundRobin := &networking.DestinationRule{
+		Host: "reviews",
+		Subsets: []*networking.Subset{
+			{Name: "v1", Labels: map[string]string{"version": "v1"}},
+			{Name: "v2", Labels: map[string]string{"version": "v2"}, TrafficPolicy: leastConn},
+		},
+	}
+	tests := []struct {
+		name    string
+		rule    *networking.DestinationRule
+		value   string
+		valid   bool
+		warning bool
+	}{
+		{name: "valid", rule: rule, value: "1.5,v1=2", valid: true},
+		{name: "unknown subset", rule: rule, value: "v3=2", valid: false},
+		{name: "invalid bias", rule: rule, value: "v1=fast", valid: false},
+		{name: "negative bias", rule: rule, value: "-1", valid: false},
+		{name: "subset using least conn", rule: roundRobin, value: "v2=2", valid: true},
+		{name: "subset not using least conn", rule: roundRobin, value: "v1=2", valid: true, warning: true},
+		{name: "host not using least conn", rule: roundRobin, value: "2", valid: true, warning: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warn, err := ValidateDestinationRule(config.Config{
+				Meta: config.Meta{
+					Name:        "reviews",
+					Namespace:   "default",
+					Annotations: map[string]string{routing.ActiveRequestBiasAnnotation: tt.value},
+				},
+				Spec: tt.rule,
+			})
+			if err == nil && !tt.valid {
+				t.Fatalf("ValidateDestinationRule(%v) = true, wanted false", tt.value)
+			} else if err != nil && tt.valid {
+				t.Fatalf("ValidateDestinationRule(%v) = %v, wanted true", tt.value, err)
+			}
+			if (warn != nil) != tt.warning {
+				t.Fatalf("ValidateDestinationRule(%v) warning = %v, wanted warning %v", tt.value, warn, tt.warning)
+			}
+		})
+	}
+}
+
+func TestValidateDestinationRuleLocalOriginOutlierDetection(t *testing.T) {
+	outlier := &networking.TrafficPolicy{
+		OutlierDetection: &networking.OutlierDetection{Consecutive_5XxErrors: &types.UInt32Value{Value: 5}},
+	}
+	rule := &networking.DestinationRule{
+		Host:          "reviews",
+		TrafficPolicy: outlier,
+		Subsets: []*networking.Subset{
+			{Name: "v1", Labels: map[string]string{"version": "v1"}},
+		},
+	}
+	noOutlier := &networking.DestinationRule{
+		Host: "reviews",
+		Subsets: []*networking.Subset{
+			{Name: "v1", Labels: map[string]string{"version": "v1"}},
+			{Name: "v2", Labels: map[string]string{"version": "v2"}, TrafficPolicy: outlier},
+		},
+	}
+	tests := []struct {
+		name    string
+		rule    *networking.DestinationRule
+		value   string
+		valid   bool
+		warning bool
+	}{
+		{name: "valid", rule: rule, value: "5,v1=3", valid: true},
+		{name: "unknown subset", rule: rule, value: "v3=3", valid: false},
+		{name: "invalid failures", rule: rule, value: "v1=many", valid: false},
+		{name: "negative failures", rule: rule, value: "-1", valid: false},
+		{name: "subset with outlier detection", rule: noOutlier, value: "v2=3", valid: true},
+		{name: "subset without outlier detection", rule: noOutlier, value: "v1=3", valid: true, warning: true},
+		{name: "host without outlier detection", rule: noOutlier, value: "3", valid: true, warning: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warn, err := ValidateDestinationRule(config.Config{
+				Meta: config.Meta{
+					Name:        "reviews",
+					Namespace:   "default",
+					Annotations: map[string]string{routing.LocalOriginOutlierDetectionAnnotation: tt.value},
+				},
+				Spec: tt.rule,
+			})
+			if err == nil && !tt.valid {
+				t.Fatalf("ValidateDestinationRule(%v) = true, wanted false", tt.value)
+			} else if err != nil && tt.valid {
+				t.Fatalf("ValidateDestinationRule(%v) = %v, wanted true", tt.value, err)
+			}
+			if (warn != nil) != tt.warning {
+				t.Fatalf("ValidateDestinationRule(%v) warning = %v, wanted warning %v", tt.value, warn, tt.warning)
+			}
+		})
+	}
+}
+
+func TestValidateDestinationRuleSubsetSubjectAltNames(t *testing.T) {
+	rule := &networking.DestinationRule{
+		Host: "reviews",
+		Subsets: []*networking.Subset{
+			{Name: "v1", Labels: map[string]string{"version": "v1"}},
+			{
+				Name:   "simple",
+				Labels: map[string]string{"version": "simple"},
+				TrafficPolicy: &networking.TrafficPolicy{
+					Tls: &networking.ClientTLSSettings{Mode: networking.ClientTLSSettings_SIMPLE},
+				},
+			},
+			{
+				Name:   "pinned",
+				Labels: map[string]string{"version": "pinned"},
+				TrafficPolicy: &networking.TrafficPolicy{
+					Tls: &networking.ClientTLSSettings{
+						Mode:            networking.ClientTLSSettings_ISTIO_MUTUAL,
+						SubjectAltNames: []string{"spiffe://cluster.local/ns/default/sa/pinned"},
+					},
+				},
+			},
+		},
+	}
+	tests := []struct {
+		name    string
+		value   string
+		valid   bool
+		warning bool
+	}{
+		{name: "valid", value: `{"v1": ["spiffe://cluster.local/ns/default/sa/canary"]}`, valid: true},
+		{name: "unknown subset", value: `{"v3": ["spiffe://cluster.local/ns/default/sa/canary"]}`, valid: false},
+		{name: "invalid spiffe identity", value: `{"v1": ["spiffe://cluster.local/canary"]}`, valid: false},
+		{name: "subset not using istio mutual", value: `{"simple": ["canary.example.com"]}`, valid: true, warning: true},
+		{name: "subset with its own names", value: `{"pinned": ["spiffe://cluster.local/ns/default/sa/canary"]}`, valid: true,
+			warning: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warn, err := ValidateDestinationRule(config.Config{
+				Meta: config.Meta{
+					Name:        "reviews",
+					Namespace:   "default",
+					Annotations: map[string]string{routing.SubjectAltNamesAnnotation: tt.value},
+				},
+				Spec: rule,
+			})
+			if err == nil && !tt.valid {
+				t.Fatalf("ValidateDestinationRule(%v) = true, wanted false", tt.value)
+			} else if err != nil && tt.valid {
+				t.Fatalf("ValidateDestinationRule(%v) = %v, wanted true", tt.value, err)
+			}
+			if (warn != nil) != tt.warning {
+				t.Fatalf("ValidateDestinationRule(%v) warning = %v, wanted warning %v", tt.value, warn, tt.warning)
+			}
+		})
+	}
+}
+
+func TestValidateDestinationRuleUpstreamProxyProtocol(t *testing.T) {
+	for value, valid := range map[string]bool{"V1": true, "v2": true, "V3": false, "true": false} {
+		_, err := ValidateDestinationRule(config.Config{
+			Meta: config.Meta{
+				Name:        "reviews",
+				Namespace:   "default",
+				Annotations: map[string]string{routing.UpstreamProxyProtocolAnnotation: value},
+			},
+			Spec: &networking.DestinationRule{Host: "reviews"},
+		})
+		if (err == nil) != valid {
+			t.Errorf("ValidateDestinationRule(%v) = %v, wanted valid %v", value, err, valid)
+		}
+	}
+}
+
+func TestValidateGatewayRateLimit(t *testing.T) {
+	cases := map[string]bool{
+		`{"domain": "edge", "service": "ratelimit.default.svc.cluster.local:8081", "descriptors": [[{"remoteAddress": true}]]}`: true,
+		`{"domain": "edge", "service": "rate_limit:8081", "descriptors": [[{"remoteAddress": true}]]}`:                          false,
+		`{"domain": "edge", "service": "ratelimit.default.svc.cluster.local:8081"}`:                                             false,
+	}
+	for value, valid := range cases {
+		_, err := ValidateGateway(config.Config{
+			Meta: config.Meta{
+				Name:        "gateway",
+				Namespace:   "default",
+				Annotations: map[string]string{routing.RateLimitAnnotation: value},
+			},
+			Spec: &networking.Gateway{
+				Servers: []*networking.Server{{
+					Hosts: []string{"foo.bar.com"},
+					Port:  &networking.Port{Name: "http", Number: 80, Protocol: "HTTP"},
+				}},
+			},
+		})
+		if (err == nil) != valid {
+			t.Errorf("ValidateGateway(%v) = %v, wanted valid %v", value, err, valid)
+		}
+	}
+}
+
+func TestValidateDestinationRuleFailoverPriority(t *testing.T) {
+	distribute := &networking.TrafficPolicy{
+		LoadBalancer: &networking.LoadBalancerSettings{
+			LocalityLbSetting: &networking.LocalityLoadBalancerSetting{
+				Distribute: []*networking.LocalityLoadBalancerSetting_Distribute{
+					{From: "a/b/*", To: map[string]uint32{"a/b/*": 100}},
+				},
+			},
+		},
+	}
+	cases := []struct {
+		value  string
+		policy *networking.TrafficPolicy
+		valid  bool
+	}{
+		{value: "topology.kubernetes.io/zone,rack", valid: true},
+		{value: "rack,rack", valid: false},
+		{value: "", valid: false},
+		{value: "rack", policy: distribute, valid: false},
+	}
+	for _, c := range cases {
+		_, err := ValidateDestinationRule(config.Config{
+			Meta: config.Meta{
+				Name:        "reviews",
+				Namespace:   "default",
+				Annotations: map[string]string{routing.FailoverPriorityAnnotation: c.value},
+			},
+			Spec: &networking.DestinationRule{Host: "reviews", TrafficPolicy: c.policy},
+		})
+		if (err == nil) != c.valid {
+			t.Errorf("ValidateDestinationRule(%v) = %v, wanted valid %v", c.value, err, c.valid)
+		}
+	}
+}
+
 func TestValidateLocalityLbSetting(t *testing.T) {
 	cases := []struct {
 		name  string
@@ -15,17 +15,20 @@
 package istioagent
 
 import (
+	"crypto/x509"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	mesh "istio.io/api/mesh/v1alpha1"
 	"istio.io/istio/pilot/pkg/dns"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/spiffe"
 	"istio.io/istio/security/pkg/nodeagent/cache"
 	"istio.io/istio/security/pkg/nodeagent/caclient"
 	citadel "istio.io/istio/security/pkg/nodeagent/caclient/providers/citadel"
@@ -89,6 +92,9 @@ type Agent struct {
 
 	// local DNS Server that processes DNS requests locally and forwards to upstream DNS if needed.
 	localDNSServer *dns.LocalDNSServer
+
+	// stopSpiffeBundles stops the refresh of the SPIFFE bundles of the federated trust domains.
+	stopSpiffeBundles chan struct{}
 }
 
 // AgentConfig contains additional config for the agent, not included in ProxyConfig.
@@ -110,6 +116,8 @@ type AgentConfig struct {
 	// ProxyDomain is the DNS domain associated with the proxy (assumed
 	// to include the namespace as well) (for local dns resolution)
 	ProxyDomain string
+	// DNSOptions override the resolv.conf settings of the local DNS server.
+	DNSOptions dns.Options
 
 	// XDSRootCerts is the location of the root CA for the XDS connection. Used for setting platform certs or
 	// using custom roots.
@@ -127,6 +135,12 @@ type AgentConfig struct {
 
 	// Path to local UDS to communicate with Envoy
 	XdsUdsPath string
+
+	// SpiffeBundleEndpoints are the SPIFFE bundle endpoints of the federated trust domains, in the format of
+	// "foo|URL1||bar|URL2". The root certificates of the bundles are added to the trust bundle of the proxy.
+	SpiffeBundleEndpoints string
+	// SpiffeBundleRefreshInterval is the interval at which the SPIFFE bundles are fetched again.
+	SpiffeBundleRefreshInterval time.Duration
 }
 
 // NewAgent hosts the functionality for local SDS and XDS. This consists of the local SDS server and
@@ -162,6 +176,12 @@ func (sa *Agent) Start() error {
 	}
 	sa.secretCache.SetUpdateCallback(sa.sdsServer.UpdateCallback)
 
+	if sa.cfg.SpiffeBundleEndpoints != "" {
+		if err = sa.initSpiffeBundleRefresher(); err != nil {
+			return fmt.Errorf("failed to start SPIFFE bundle refresher: %v", err)
+		}
+	}
+
 	if err = sa.initLocalDNSServer(sa.cfg.ProxyType == model.SidecarProxy); err != nil {
 		return fmt.Errorf("failed to start local DNS server: %v", err)
 	}
@@ -178,7 +198,7 @@ func (sa *Agent) Start() error {
 func (sa *Agent) initLocalDNSServer(isSidecar bool) (err error) {
 	// we dont need dns server on gateways
 	if sa.cfg.DNSCapture && sa.cfg.ProxyXDSViaAgent && isSidecar {
-		if sa.localDNSServer, err = dns.NewLocalDNSServer(sa.cfg.ProxyNamespace, sa.cfg.ProxyDomain); err != nil {
+		if sa.localDNSServer, err = dns.NewLocalDNSServer(sa.cfg.ProxyNamespace, sa.cfg.ProxyDomain, sa.cfg.DNSOptions); err != nil {
 			return err
 		}
 		sa.localDNSServer.StartDNS()
@@ -186,7 +206,37 @@ func (sa *Agent) initLocalDNSServer(isSidecar bool) (err error) {
 	return nil
 }
 
+// initSpiffeBundleRefresher fetches the SPIFFE bundles of the federated trust domains in the background, and adds
+// their root certificates to the trust bundle of the proxy. Updated bundles are pushed to the proxy over SDS.
+func (sa *Agent) initSpiffeBundleRefresher() error {
+	endpoints, err := spiffe.ParseSpiffeBundleEndpoints(sa.cfg.SpiffeBundleEndpoints)
+	if err != nil {
+		return err
+	}
+	refresher := spiffe.NewBundleRefresher(endpoints, nil, sa.cfg.SpiffeBundleRefreshInterval)
+	refresher.AddHandler(func(bundles map[string][]*x509.Certificate) {
+		trustBundle, err := spiffe.EncodeBundles(bundles)
+		if err != nil {
+			log.Errorf("failed to update the trust bundle with the SPIFFE bundles: %v", err)
+			return
+		}
+		if err := sa.secretCache.UpdateConfigTrustBundle(trustBundle); err != nil {
+			log.Errorf("failed to update the trust bundle with the SPIFFE bundles: %v", err)
+		}
+	})
+	sa.stopSpiffeBundles = make(chan struct{})
+	go func() {
+		// The initial fetch may be retried for a while, so it doesn't block the startup of the proxy.
+		refresher.Refresh()
+		refresher.Run(sa.stopSpiffeBundles)
+	}()
+	return nil
+}
+
 func (sa *Agent) Close() {
+	if sa.stopSpiffeBundles != nil {
+		close(sa.stopSpiffeBundles)
+	}
 	if sa.xdsProxy != nil {
 		sa.xdsProxy.close()
 	}
@@ -18,7 +18,6 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
-	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -99,6 +98,18 @@ type XdsProxy struct {
 	// in case istiod changes its behavior, or a different ECDS server is used.
 	ecdsLastAckVersion atomic.String
 	ecdsLastNonce      atomic.String
+
+	// ecdsLatest is the sequence number of the last ECDS response received from istiod. The responses are
+	// rewritten concurrently, as fetching their Wasm modules may take a while, and ecdsForwardMutex orders their
+	// forwarding, so that a response superseded while its modules were fetched is dropped rather than
+	// forwarded after the newer one.
+	ecdsLatest       atomic.Uint64
+	ecdsForwardMutex sync.Mutex
+
+	// shardIP is the IP of the istiod replica the proxy was last redirected to, when istiod shards the proxies
+	// across its replicas. It is dialed instead of the istiod address until connecting or streaming to it fails
+	// without another redirect.
+	shardIP atomic.String
 }
 
 var proxyLog = log.RegisterScope("xdsproxy", "XDS Proxy in Istio Agent", 0)
@@ -126,8 +137,13 @@ func initXdsProxy(ia *Agent) (*XdsProxy, error) {
 		healthChecker:  health.NewWorkloadHealthChecker(ia.proxyConfig.ReadinessProbe, envoyProbe),
 		xdsHeaders:     ia.cfg.XDSHeaders,
 		xdsUdsPath:     ia.cfg.XdsUdsPath,
-		wasmCache:      wasm.NewLocalFileCache(constants.IstioDataDir, wasm.DefaultWasmModulePurgeInteval, wasm.DefaultWasmModuleExpiry),
 	}
+	var wasmAuth *wasm.RegistryAuth
+	if features.WasmPullSecretPath != "" {
+		wasmAuth = wasm.NewRegistryAuth(features.WasmPullSecretPath)
+	}
+	proxy.wasmCache = wasm.NewLocalFileCache(constants.IstioDataDir, wasm.DefaultWasmModulePurgeInteval, wasm.DefaultWasmModuleExpiry,
+		wasmAuth)
 
 	proxyLog.Infof("Initializing with upstream address %q and cluster %q", proxy.istiodAddress, proxy.clusterID)
 
@@ -261,12 +277,8 @@ func (p *XdsProxy) StreamAggregatedResources(downstream discovery.AggregatedDisc
 		}
 	}()
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
-	upstreamConn, err := grpc.DialContext(ctx, p.istiodAddress, p.istiodDialOptions...)
+	upstreamConn, err := p.dialUpstream()
 	if err != nil {
-		proxyLog.Errorf("failed to connect to upstream %s: %v", p.istiodAddress, err)
-		metrics.IstiodConnectionFailures.Increment()
 		return err
 	}
 	defer upstreamConn.Close()
@@ -286,6 +298,7 @@ func (p *XdsProxy) HandleUpstream(ctx context.Context, con *ProxyConnection, xds
 	if err != nil {
 		// Envoy logs errors again, so no need to log beyond debug level
 		proxyLog.Debugf("failed to create upstream grpc client: %v", err)
+		p.clearShard()
 		return err
 	}
 	proxyLog.Infof("connected to upstream XDS server: %s", p.istiodAddress)
@@ -299,6 +312,7 @@ func (p *XdsProxy) HandleUpstream(ctx context.Context, con *ProxyConnection, xds
 			// from istiod
 			resp, err := upstream.Recv()
 			if err != nil {
+				p.recordShardRedirect(upstream.Trailer())
 				con.upstreamError <- err
 				return
 			}
@@ -391,7 +405,7 @@ func (p *XdsProxy) handleUpstreamResponse(con *ProxyConnection) {
 			case v3.ExtensionConfigurationType:
 				if features.WasmRemoteLoadConversion {
 					// If Wasm remote load conversion feature is enabled, rewrite and send.
-					go p.rewriteAndForward(con, resp)
+					go p.rewriteAndForward(con, resp, p.ecdsLatest.Inc())
 				} else {
 					// Otherwise, forward ECDS resource update directly to Envoy.
 					forwardToEnvoy(con, resp)
@@ -405,17 +419,24 @@ func (p *XdsProxy) handleUpstreamResponse(con *ProxyConnection) {
 	}
 }
 
-func (p *XdsProxy) rewriteAndForward(con *ProxyConnection, resp *discovery.DiscoveryResponse) {
-	sendNack := wasm.MaybeConvertWasmExtensionConfig(resp.Resources, p.wasmCache)
-	if sendNack {
+func (p *XdsProxy) rewriteAndForward(con *ProxyConnection, resp *discovery.DiscoveryResponse, seq uint64) {
+	failures := wasm.ConvertWasmExtensionConfig(resp.Resources, p.wasmCache)
+
+	p.ecdsForwardMutex.Lock()
+	defer p.ecdsForwardMutex.Unlock()
+	if seq != p.ecdsLatest.Load() {
+		// A newer response is being rewritten or was already forwarded, and replaces this one.
+		proxyLog.Debugf("dropping ECDS response %s superseded while fetching its Wasm modules", resp.Nonce)
+		return
+	}
+	if len(failures) > 0 {
 		proxyLog.Debugf("sending NACK for ECDS resources %+v", resp.Resources)
 		con.requestsChan <- &discovery.DiscoveryRequest{
 			VersionInfo:   p.ecdsLastAckVersion.Load(),
 			TypeUrl:       v3.ExtensionConfigurationType,
 			ResponseNonce: resp.Nonce,
 			ErrorDetail: &google_rpc.Status{
-				// TODO(bianpengyuan): make error message more informative.
-				Message: "failed to fetch wasm module",
+				Message: wasm.FetchErrorMessage(failures),
 			},
 		}
 		return
@@ -448,8 +469,83 @@ func forwardToEnvoy(con *ProxyConnection, resp *discovery.DiscoveryResponse) {
 	}
 }
 
-func (p *XdsProxy) DeltaAggregatedResources(server discovery.AggregatedDiscoveryService_DeltaAggregatedResourcesServer) error {
-	return errors.New("delta XDS is not implemented")
+// DeltaAggregatedResources forwards delta XDS streams, used by Envoy to fetch on demand routes (VHDS),
+// to istiod. Unlike StreamAggregatedResources, requests and responses are passed through unmodified.
+func (p *XdsProxy) DeltaAggregatedResources(downstream discovery.AggregatedDiscoveryService_DeltaAggregatedResourcesServer) error {
+	proxyLog.Debugf("accepted delta XDS connection from Envoy, forwarding to upstream XDS server")
+
+	upstreamConn, err := p.dialUpstream()
+	if err != nil {
+		return err
+	}
+	defer upstreamConn.Close()
+
+	xds := discovery.NewAggregatedDiscoveryServiceClient(upstreamConn)
+	ctx = metadata.AppendToOutgoingContext(context.Background(), "ClusterID", p.clusterID)
+	for k, v := range p.xdsHeaders {
+		ctx = metadata.AppendToOutgoingContext(ctx, k, v)
+	}
+	ctx, cancelUpstream := context.WithCancel(ctx)
+	defer cancelUpstream()
+	upstream, err := xds.DeltaAggregatedResources(ctx, grpc.MaxCallRecvMsgSize(defaultClientMaxReceiveMessageSize))
+	if err != nil {
+		proxyLog.Debugf("failed to create upstream delta grpc client: %v", err)
+		p.clearShard()
+		return err
+	}
+	defer upstream.CloseSend() // nolint
+
+	upstreamError := make(chan error, 1)
+	downstreamError := make(chan error, 1)
+	go func() {
+		for {
+			// From Envoy
+			req, err := downstream.Recv()
+			if err != nil {
+				downstreamError <- err
+				return
+			}
+			if err := upstream.Send(req); err != nil {
+				upstreamError <- err
+				return
+			}
+		}
+	}()
+	go func() {
+		for {
+			// From istiod
+			resp, err := upstream.Recv()
+			if err != nil {
+				p.recordShardRedirect(upstream.Trailer())
+				upstreamError <- err
+				return
+			}
+			if err := downstream.Send(resp); err != nil {
+				downstreamError <- err
+				return
+			}
+		}
+	}()
+
+	select {
+	case err := <-upstreamError:
+		if isExpectedGRPCError(err) {
+			proxyLog.Debugf("delta upstream terminated with status %v", err)
+		} else {
+			proxyLog.Warnf("delta upstream terminated with unexpected error %v", err)
+		}
+		// Propagate upstream termination to Envoy, so it resubscribes on a new connection.
+		return err
+	case err := <-downstreamError:
+		if isExpectedGRPCError(err) {
+			proxyLog.Debugf("delta downstream terminated with status %v", err)
+			return nil
+		}
+		proxyLog.Warnf("delta downstream terminated with unexpected error %v", err)
+		return err
+	case <-p.stopChan:
+		return nil
+	}
 }
 
 func (p *XdsProxy) close() {
@@ -517,6 +613,48 @@ func (p *XdsProxy) getCertKeyPaths(agent *Agent) (string, string) {
 	return key, cert
 }
 
+// dialUpstream connects to istiod, or to the istiod replica the proxy was last redirected to.
+func (p *XdsProxy) dialUpstream() (*grpc.ClientConn, error) {
+	address := p.istiodAddress
+	shardIP := p.shardIP.Load()
+	if shardIP != "" {
+		if _, port, err := net.SplitHostPort(p.istiodAddress); err == nil {
+			address = net.JoinHostPort(shardIP, port)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	upstreamConn, err := grpc.DialContext(ctx, address, p.istiodDialOptions...)
+	if err != nil {
+		proxyLog.Errorf("failed to connect to upstream %s: %v", address, err)
+		metrics.IstiodConnectionFailures.Increment()
+		// The replica may be gone, fall back to istiod on the next attempt.
+		p.clearShard()
+		return nil, err
+	}
+	return upstreamConn, nil
+}
+
+// recordShardRedirect records the istiod replica named by the trailer of a closed upstream stream, if istiod
+// redirected the proxy to it. Otherwise the stream failed for another reason, such as the replica going away or
+// no longer owning the proxy, and the proxy falls back to istiod on the next attempt.
+func (p *XdsProxy) recordShardRedirect(trailer metadata.MD) {
+	if ips := trailer.Get(v3.ShardRedirectTrailer); len(ips) > 0 && ips[0] != "" {
+		proxyLog.Infof("redirected to istiod replica %s", ips[0])
+		p.shardIP.Store(ips[0])
+		return
+	}
+	p.clearShard()
+}
+
+// clearShard stops dialing the istiod replica the proxy was redirected to.
+func (p *XdsProxy) clearShard() {
+	if shardIP := p.shardIP.Load(); shardIP != "" {
+		proxyLog.Infof("falling back from istiod replica %s to %s", shardIP, p.istiodAddress)
+		p.shardIP.Store("")
+	}
+}
+
 func (p *XdsProxy) buildUpstreamClientDialOpts(sa *Agent) ([]grpc.DialOption, error) {
 	tlsOpts, err := p.getTLSDialOption(sa)
 	if err != nil {
@@ -32,7 +32,9 @@ import (
 	"github.com/golang/protobuf/ptypes"
 	google_rpc "google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	grpcstatus "google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
 	"google.golang.org/protobuf/proto"
 
@@ -353,6 +355,87 @@ func TestXdsProxyReconnects(t *testing.T) {
 	})
 }
 
+// shardingServer closes the XDS streams it receives, redirecting the proxy to another istiod replica on the first
+// one, and records the address each stream was dialed with.
+type shardingServer struct {
+	redirect    string
+	authorities chan string
+}
+
+func (s *shardingServer) StreamAggregatedResources(stream discovery.AggregatedDiscoveryService_StreamAggregatedResourcesServer) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	s.authorities <- md.Get(":authority")[0]
+	if s.redirect != "" {
+		stream.SetTrailer(metadata.Pairs(v3.ShardRedirectTrailer, s.redirect))
+		s.redirect = ""
+	}
+	return grpcstatus.Error(codes.Unavailable, "closing the stream")
+}
+
+func (s *shardingServer) DeltaAggregatedResources(discovery.AggregatedDiscoveryService_DeltaAggregatedResourcesServer) error {
+	return nil
+}
+
+func TestXdsProxyShardRedirect(t *testing.T) {
+	proxy := setupXdsProxy(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	proxy.istiodAddress = net.JoinHostPort("localhost", port)
+	proxy.istiodDialOptions = []grpc.DialOption{grpc.WithBlock(), grpc.WithInsecure()}
+
+	server := &shardingServer{redirect: "127.0.0.1", authorities: make(chan string, 3)}
+	grpcServer := grpc.NewServer()
+	t.Cleanup(grpcServer.Stop)
+	discovery.RegisterAggregatedDiscoveryServiceServer(grpcServer, server)
+	go grpcServer.Serve(listener)
+
+	conn := setupDownstreamConnection(t, proxy)
+	expectUpstream := func(authority, shardIP string) {
+		t.Helper()
+		downstream := stream(t, conn)
+		_ = downstream.Send(&discovery.DiscoveryRequest{TypeUrl: v3.ClusterType})
+		select {
+		case got := <-server.authorities:
+			if got != authority {
+				t.Fatalf("dialed %s, want %s", got, authority)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for a stream to %s", authority)
+		}
+		retry.UntilSuccessOrFail(t, func() error {
+			if got := proxy.shardIP.Load(); got != shardIP {
+				return fmt.Errorf("got shard %q, want %q", got, shardIP)
+			}
+			return nil
+		}, retry.Timeout(5*time.Second), retry.Delay(time.Millisecond))
+	}
+
+	// istiod redirects the proxy to the replica owning it.
+	expectUpstream(proxy.istiodAddress, "127.0.0.1")
+	// The replica closes the stream without redirecting the proxy, which falls back to istiod.
+	expectUpstream(net.JoinHostPort("127.0.0.1", port), "")
+	expectUpstream(proxy.istiodAddress, "")
+}
+
+func TestRecordShardRedirect(t *testing.T) {
+	proxy := setupXdsProxy(t)
+	proxy.recordShardRedirect(metadata.Pairs(v3.ShardRedirectTrailer, "10.0.0.2"))
+	if got := proxy.shardIP.Load(); got != "10.0.0.2" {
+		t.Fatalf("got shard %q, want 10.0.0.2", got)
+	}
+	proxy.recordShardRedirect(metadata.Pairs(v3.ShardRedirectTrailer, "10.0.0.3"))
+	if got := proxy.shardIP.Load(); got != "10.0.0.3" {
+		t.Fatalf("got shard %q, want 10.0.0.3", got)
+	}
+	proxy.recordShardRedirect(nil)
+	if got := proxy.shardIP.Load(); got != "" {
+		t.Fatalf("got shard %q after a stream failure without redirect, want none", got)
+	}
+}
+
 type fakeAckCache struct{}
 
 func (f *fakeAckCache) Get(string, string, time.Duration) (string, error) {
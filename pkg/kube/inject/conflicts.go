@@ -0,0 +1,159 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inject
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// TemplateConflictPolicy defines how the conflicts between the templates applied to a pod are handled. A conflict
+// is a field set by a template and set to a different value by a later template.
+type TemplateConflictPolicy string
+
+const (
+	// TemplateConflictsWarn reports the conflicts as warnings, the later template taking precedence. This is the default.
+	TemplateConflictsWarn TemplateConflictPolicy = "warn"
+	// TemplateConflictsDeny fails the injection on conflicts.
+	TemplateConflictsDeny TemplateConflictPolicy = "deny"
+	// TemplateConflictsAllow silently applies the later template.
+	TemplateConflictsAllow TemplateConflictPolicy = "allow"
+)
+
+// templateValue is the value of a field, and the template which set it.
+type templateValue struct {
+	template string
+	value    interface{}
+}
+
+// templateFields records the fields set by the templates applied to a pod, in order, to detect the conflicts
+// between them. Lists of objects with a name, such as containers, env or volumes, are merged by name as with a
+// strategic merge patch; other lists are compared as a whole.
+type templateFields struct {
+	setBy map[string]templateValue
+	found []string
+}
+
+func newTemplateFields() *templateFields {
+	return &templateFields{setBy: map[string]templateValue{}}
+}
+
+// add records the fields of the rendered template, given as JSON.
+func (tf *templateFields) add(template string, templateJSON []byte) error {
+	var overlay interface{}
+	if err := json.Unmarshal(templateJSON, &overlay); err != nil {
+		return err
+	}
+	tf.walk(template, "", overlay)
+	return nil
+}
+
+func (tf *templateFields) walk(template, path string, v interface{}) {
+	switch n := v.(type) {
+	case map[string]interface{}:
+		for k, c := range n {
+			// Skip the strategic merge patch directives, such as $patch.
+			if strings.HasPrefix(k, "$") {
+				continue
+			}
+			tf.walk(template, joinPath(path, k), c)
+		}
+		return
+	case []interface{}:
+		if names, ok := elementNames(n); ok {
+			for i, e := range n {
+				tf.walk(template, fmt.Sprintf("%s[name=%s]", path, names[i]), e)
+			}
+			return
+		}
+	}
+	if prev, f := tf.setBy[path]; f && prev.template != template && !reflect.DeepEqual(prev.value, v) {
+		tf.found = append(tf.found, fmt.Sprintf("%s is set to %s by template %q and to %s by template %q",
+			path, jsonValue(prev.value), prev.template, jsonValue(v), template))
+	}
+	tf.setBy[path] = templateValue{template: template, value: v}
+}
+
+// conflicts returns the conflicts found, sorted.
+func (tf *templateFields) conflicts() []string {
+	sort.Strings(tf.found)
+	return tf.found
+}
+
+// elementNames returns the names of the elements of the list, if they are all objects with a name.
+func elementNames(l []interface{}) ([]string, bool) {
+	if len(l) == 0 {
+		return nil, false
+	}
+	names := make([]string, 0, len(l))
+	for _, e := range l {
+		m, ok := e.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		name, ok := m["name"].(string)
+		if !ok {
+			return nil, false
+		}
+		names = append(names, name)
+	}
+	return names, true
+}
+
+func joinPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+func jsonValue(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// handleTemplateConflicts applies the conflict policy of the pod, set by the TemplateConflictsAnnotation, or the
+// policy of the injection configuration.
+func handleTemplateConflicts(params InjectionParameters, conflicts []string) error {
+	policy := params.templateConflicts
+	if a, f := params.pod.Annotations[TemplateConflictsAnnotation]; f {
+		policy = TemplateConflictPolicy(a)
+	}
+	switch policy {
+	case "", TemplateConflictsWarn:
+		for _, c := range conflicts {
+			if params.warningHandler != nil {
+				params.warningHandler("conflicting injection templates: " + c)
+			} else {
+				log.Warnf("conflicting injection templates: %s", c)
+			}
+		}
+	case TemplateConflictsDeny:
+		if len(conflicts) > 0 {
+			return fmt.Errorf("conflicting injection templates: %s", strings.Join(conflicts, "; "))
+		}
+	case TemplateConflictsAllow:
+	default:
+		return fmt.Errorf("invalid template conflict policy %q, expected one of %s, %s or %s", policy,
+			TemplateConflictsWarn, TemplateConflictsDeny, TemplateConflictsAllow)
+	}
+	return nil
+}
@@ -113,6 +113,11 @@ type Config struct {
 	// SidecarTemplateData, and merged with the original pod spec using a strategic merge patch.
 	Templates Templates `json:"templates"`
 
+	// TemplateConflicts defines how the conflicts between the templates applied to a pod are handled, when a
+	// template sets a field to a different value than an earlier template. Defaults to warn; the pod can override it
+	// with the TemplateConflictsAnnotation.
+	TemplateConflicts TemplateConflictPolicy `json:"templateConflicts,omitempty"`
+
 	// Aliases defines a translation of a name to inject template. For example, `sidecar: [proxy,init]` could allow
 	// referencing two templates, "proxy" and "init" by a single name, "sidecar".
 	// Expansion is not recursive.
@@ -160,6 +165,39 @@ func UnmarshalConfig(yml []byte) (Config, error) {
 }
 
 func injectRequired(ignored []string, config *Config, podSpec *corev1.PodSpec, metadata metav1.ObjectMeta) bool { // nolint: lll
+	required, reason := injectionDecision(ignored, config, podSpec, metadata)
+
+	if log.DebugEnabled() {
+		annos := metadata.GetAnnotations()
+		// Build a log message for the annotations.
+		annotationStr := ""
+		for name := range AnnotationValidation {
+			value, ok := annos[name]
+			if !ok {
+				value = "(unset)"
+			}
+			annotationStr += fmt.Sprintf("%s:%s ", name, value)
+		}
+
+		log.Debugf("Sidecar injection policy for %v/%v: namespacePolicy:%v required:%v (%s) %s",
+			metadata.Namespace,
+			potentialPodName(metadata),
+			config.Policy,
+			required,
+			reason,
+			annotationStr)
+	}
+
+	return required
+}
+
+// InjectionDecision returns whether the sidecar injector configured with config injects the pod, and the reason of
+// the decision. It does not consider the selectors of the injection webhooks.
+func InjectionDecision(config *Config, podSpec *corev1.PodSpec, metadata metav1.ObjectMeta) (bool, string) {
+	return injectionDecision(ignoredNamespaces, config, podSpec, metadata)
+}
+
+func injectionDecision(ignored []string, config *Config, podSpec *corev1.PodSpec, metadata metav1.ObjectMeta) (bool, string) {
 	// Skip injection when host networking is enabled. The problem is
 	// that the iptables changes are assumed to be within the pod when,
 	// in fact, they are changing the routing at the host level. This
@@ -167,13 +205,13 @@ func injectRequired(ignored []string, config *Config, podSpec *corev1.PodSpec, m
 	// affect the network provider within the cluster causing
 	// additional pod failures.
 	if podSpec.HostNetwork {
-		return false
+		return false, "the pod uses the host network"
 	}
 
 	// skip special kubernetes system namespaces
 	for _, namespace := range ignored {
 		if metadata.Namespace == namespace {
-			return false
+			return false, fmt.Sprintf("the namespace %s is never injected", namespace)
 		}
 	}
 
@@ -184,6 +222,7 @@ func injectRequired(ignored []string, config *Config, podSpec *corev1.PodSpec, m
 
 	var useDefault bool
 	var inject bool
+	reason := fmt.Sprintf("the pod has the annotation %s=%q", annotation.SidecarInject.Name, annos[annotation.SidecarInject.Name])
 	switch strings.ToLower(annos[annotation.SidecarInject.Name]) {
 	// http://yaml.org/type/bool.html
 	case "y", "yes", "true", "on":
@@ -203,6 +242,7 @@ func injectRequired(ignored []string, config *Config, podSpec *corev1.PodSpec, m
 					metadata.Namespace, potentialPodName(metadata))
 				inject = false
 				useDefault = false
+				reason = fmt.Sprintf("the pod labels match the neverInjectSelector %s", selector)
 				break
 			}
 		}
@@ -219,53 +259,29 @@ func injectRequired(ignored []string, config *Config, podSpec *corev1.PodSpec, m
 					metadata.Namespace, potentialPodName(metadata))
 				inject = true
 				useDefault = false
+				reason = fmt.Sprintf("the pod labels match the alwaysInjectSelector %s", selector)
 				break
 			}
 		}
 	}
 
-	var required bool
 	switch config.Policy {
 	default: // InjectionPolicyOff
 		log.Errorf("Illegal value for autoInject:%s, must be one of [%s,%s]. Auto injection disabled!",
 			config.Policy, InjectionPolicyDisabled, InjectionPolicyEnabled)
-		required = false
+		return false, fmt.Sprintf("the injection policy %q is invalid", config.Policy)
 	case InjectionPolicyDisabled:
 		if useDefault {
-			required = false
-		} else {
-			required = inject
+			return false, "the injection policy is disabled, and the pod neither has the annotation " +
+				annotation.SidecarInject.Name + " nor matches the alwaysInjectSelector"
 		}
+		return inject, reason
 	case InjectionPolicyEnabled:
 		if useDefault {
-			required = true
-		} else {
-			required = inject
+			return true, "the injection policy is enabled"
 		}
+		return inject, reason
 	}
-
-	if log.DebugEnabled() {
-		// Build a log message for the annotations.
-		annotationStr := ""
-		for name := range AnnotationValidation {
-			value, ok := annos[name]
-			if !ok {
-				value = "(unset)"
-			}
-			annotationStr += fmt.Sprintf("%s:%s ", name, value)
-		}
-
-		log.Debugf("Sidecar injection policy for %v/%v: namespacePolicy:%v useDefault:%v inject:%v required:%v %s",
-			metadata.Namespace,
-			potentialPodName(metadata),
-			config.Policy,
-			useDefault,
-			inject,
-			required,
-			annotationStr)
-	}
-
-	return required
 }
 
 // RunTemplate renders the sidecar template
@@ -351,6 +367,7 @@ func RunTemplate(params InjectionParameters) (mergedPod *corev1.Pod, templatePod
 
 	mergedPod = params.pod
 	templatePod = &corev1.Pod{}
+	fields := newTemplateFields()
 	for _, templateName := range selectTemplates(params) {
 		templateYAML, f := params.templates[templateName]
 		if !f {
@@ -366,6 +383,9 @@ func RunTemplate(params InjectionParameters) (mergedPod *corev1.Pod, templatePod
 		if err != nil {
 			return nil, nil, fmt.Errorf("yaml to json: %v", err)
 		}
+		if err := fields.add(templateName, templateJSON); err != nil {
+			return nil, nil, fmt.Errorf("failed parsing injection template %q: %v", templateName, err)
+		}
 
 		mergedPod, err = applyOverlay(mergedPod, templateJSON)
 		if err != nil {
@@ -376,6 +396,9 @@ func RunTemplate(params InjectionParameters) (mergedPod *corev1.Pod, templatePod
 			return nil, nil, fmt.Errorf("failed applying injection overlay: %v", err)
 		}
 	}
+	if err := handleTemplateConflicts(params, fields.conflicts()); err != nil {
+		return nil, nil, err
+	}
 
 	return mergedPod, templatePod, nil
 }
@@ -479,9 +502,10 @@ func parseTemplate(tmplStr string, funcMap map[string]interface{}, data SidecarT
 }
 
 // IntoResourceFile injects the istio proxy into the specified
-// kubernetes YAML file.
+// kubernetes YAML file. Only the templates, default templates, aliases and template conflict policy of the injection
+// configuration are used.
 // nolint: lll
-func IntoResourceFile(sidecarTemplate Templates, valuesConfig string, revision string, meshconfig *meshconfig.MeshConfig, in io.Reader, out io.Writer, warningHandler func(string)) error {
+func IntoResourceFile(injectConfig *Config, valuesConfig string, revision string, meshconfig *meshconfig.MeshConfig, in io.Reader, out io.Writer, warningHandler func(string)) error {
 	reader := yamlDecoder.NewYAMLReader(bufio.NewReaderSize(in, 4096))
 	for {
 		raw, err := reader.Read()
@@ -499,7 +523,7 @@ func IntoResourceFile(sidecarTemplate Templates, valuesConfig string, revision s
 
 		var updated []byte
 		if err == nil {
-			outObject, err := IntoObject(sidecarTemplate, valuesConfig, revision, meshconfig, obj, warningHandler) // nolint: vetshadow
+			outObject, err := IntoObject(injectConfig, valuesConfig, revision, meshconfig, obj, warningHandler) // nolint: vetshadow
 			if err != nil {
 				return err
 			}
@@ -541,7 +565,7 @@ func FromRawToObject(raw []byte) (runtime.Object, error) {
 
 // IntoObject convert the incoming resources into Injected resources
 // nolint: lll
-func IntoObject(sidecarTemplate Templates, valuesConfig string, revision string, meshconfig *meshconfig.MeshConfig, in runtime.Object, warningHandler func(string)) (interface{}, error) {
+func IntoObject(injectConfig *Config, valuesConfig string, revision string, meshconfig *meshconfig.MeshConfig, in runtime.Object, warningHandler func(string)) (interface{}, error) {
 	out := in.DeepCopyObject()
 
 	var deploymentMetadata *metav1.ObjectMeta
@@ -562,7 +586,7 @@ func IntoObject(sidecarTemplate Templates, valuesConfig string, revision string,
 				return nil, err
 			}
 
-			r, err := IntoObject(sidecarTemplate, valuesConfig, revision, meshconfig, obj, warningHandler) // nolint: vetshadow
+			r, err := IntoObject(injectConfig, valuesConfig, revision, meshconfig, obj, warningHandler) // nolint: vetshadow
 			if err != nil {
 				return nil, err
 			}
@@ -652,18 +676,27 @@ func IntoObject(sidecarTemplate Templates, valuesConfig string, revision string,
 		warningHandler(fmt.Sprintf("===> Skipping injection because %q has sidecar injection disabled\n", name))
 		return out, nil
 	}
+	defaultTemplates := injectConfig.DefaultTemplates
+	if len(defaultTemplates) == 0 {
+		defaultTemplates = []string{SidecarTemplateName}
+	}
 	params := InjectionParameters{
 		pod:        pod,
 		deployMeta: deploymentMetadata,
 		typeMeta:   typeMeta,
 		// Todo replace with some template resolver abstraction
-		templates:           sidecarTemplate,
-		defaultTemplate:     []string{SidecarTemplateName},
+		templates:           injectConfig.Templates,
+		defaultTemplate:     defaultTemplates,
+		aliases:             injectConfig.Aliases,
+		templateConflicts:   injectConfig.TemplateConflicts,
 		meshConfig:          meshconfig,
 		valuesConfig:        valuesConfig,
 		revision:            revision,
 		proxyEnvs:           map[string]string{},
 		injectedAnnotations: nil,
+		warningHandler: func(warning string) {
+			warningHandler(fmt.Sprintf("===> %q: %s\n", name, warning))
+		},
 	}
 	patchBytes, err := injectPod(params)
 	if err != nil {
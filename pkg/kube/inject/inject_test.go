@@ -358,7 +358,7 @@ func TestInjection(t *testing.T) {
 				warn := func(s string) {
 					t.Log(s)
 				}
-				if err = IntoResourceFile(sidecarTemplate.Templates, valuesConfig, "", mc, in, &got, warn); err != nil {
+				if err = IntoResourceFile(sidecarTemplate, valuesConfig, "", mc, in, &got, warn); err != nil {
 					if c.expectedError != "" {
 						if !strings.Contains(strings.ToLower(err.Error()), c.expectedError) {
 							t.Fatalf("expected error %q got %q", c.expectedError, err)
@@ -503,6 +503,92 @@ spec:
 	runWebhook(t, webhook, []byte(inputAlias), []byte(fmt.Sprintf(expected, "both")), false)
 }
 
+func TestTemplateConflicts(t *testing.T) {
+	fields := newTemplateFields()
+	for _, tmpl := range []struct {
+		name string
+		json string
+	}{
+		{
+			name: "sidecar",
+			json: `{"metadata":{"labels":{"team":"a"}},"spec":{"containers":[{"name":"istio-proxy","image":"proxy",` +
+				`"env":[{"name":"A","value":"1"}]}]}}`,
+		},
+		{
+			name: "org-policy",
+			json: `{"spec":{"containers":[{"name":"istio-proxy","env":[{"name":"A","value":"1"},{"name":"B","value":"2"}]}]}}`,
+		},
+		{
+			name: "team-overrides",
+			json: `{"metadata":{"labels":{"team":"b"}},"spec":{"containers":[{"name":"istio-proxy","image":"proxy:debug",` +
+				`"$patch":"replace"}]}}`,
+		},
+	} {
+		if err := fields.add(tmpl.name, []byte(tmpl.json)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{
+		`metadata.labels.team is set to "a" by template "sidecar" and to "b" by template "team-overrides"`,
+		`spec.containers[name=istio-proxy].image is set to "proxy" by template "sidecar" and to "proxy:debug" by template ` +
+			`"team-overrides"`,
+	}
+	if diff := cmp.Diff(fields.conflicts(), want); diff != "" {
+		t.Fatalf("unexpected conflicts: %v", diff)
+	}
+
+	webhook := &Webhook{
+		Config: &Config{
+			Templates: map[string]string{
+				"sidecar": `
+spec:
+  containers:
+  - name: istio-proxy
+    image: proxy
+`,
+				"debug": `
+spec:
+  containers:
+  - name: istio-proxy
+    image: proxy:debug
+`,
+			},
+			DefaultTemplates: []string{"sidecar", "debug"},
+			Policy:           InjectionPolicyEnabled,
+		},
+	}
+	inject := func(annotations string) *kube.AdmissionResponse {
+		return webhook.inject(&kube.AdmissionReview{
+			Request: &kube.AdmissionRequest{
+				Object: runtime.RawExtension{
+					Raw: []byte(`{"metadata":{"name":"hello","annotations":{` + annotations + `}},` +
+						`"spec":{"containers":[{"name":"hello","image":"hello"}]}}`),
+				},
+				Namespace: "default",
+			},
+		}, "")
+	}
+
+	// Conflicts are reported as warnings by default.
+	got := inject("")
+	if !got.Allowed || len(got.Warnings) != 1 || !strings.Contains(got.Warnings[0], "spec.containers[name=istio-proxy].image") {
+		t.Fatalf("expected a conflict warning, got %+v", got)
+	}
+
+	webhook.Config.TemplateConflicts = TemplateConflictsDeny
+	if got := inject(""); got.Allowed || !strings.Contains(got.Result.Message, "conflicting injection templates") {
+		t.Fatalf("expected the injection to fail on the conflict, got %+v", got)
+	}
+
+	// The pod annotation takes precedence over the injection configuration.
+	if got := inject(`"` + TemplateConflictsAnnotation + `":"allow"`); !got.Allowed || len(got.Warnings) != 0 {
+		t.Fatalf("expected the conflict to be allowed, got %+v", got)
+	}
+	if got := inject(`"` + TemplateConflictsAnnotation + `":"ignore"`); got.Allowed {
+		t.Fatalf("expected the injection to fail on an invalid policy, got %+v", got)
+	}
+}
+
 // TestStrategicMerge ensures we can use https://github.com/kubernetes/community/blob/master/contributors/devel/sig-api-machinery/strategic-merge-patch.md
 // directives in the injection template
 func TestStrategicMerge(t *testing.T) {
@@ -48,6 +48,9 @@ var (
 		annotation.SidecarTrafficExcludeOutboundPorts.Name:        ValidateExcludeOutboundPorts,
 		annotation.PrometheusMergeMetrics.Name:                    validateBool,
 		annotation.ProxyConfig.Name:                               validateProxyConfig,
+		model.DownstreamTCPKeepaliveAnnotation:                    validateDownstreamTCPKeepalive,
+		model.UpstreamTCPKeepaliveAnnotation:                      validateUpstreamTCPKeepalive,
+		model.InboundProxyProtocolAnnotation:                      validateBool,
 	}
 )
 
@@ -59,6 +62,22 @@ func validateProxyConfig(value string) error {
 	return validation.ValidateProxyConfig(&config)
 }
 
+func validateDownstreamTCPKeepalive(value string) error {
+	_, err := model.ParseTCPKeepalive(value)
+	return err
+}
+
+func validateUpstreamTCPKeepalive(value string) error {
+	keepalive, err := model.ParseTCPKeepalive(value)
+	if err != nil {
+		return err
+	}
+	if keepalive.UserTimeout > 0 {
+		return fmt.Errorf("userTimeout is only supported for downstream connections")
+	}
+	return nil
+}
+
 func validateAnnotations(annotations map[string]string) (err error) {
 	for name, value := range annotations {
 		if v, ok := AnnotationValidation[name]; ok {
@@ -328,11 +328,14 @@ type InjectionParameters struct {
 	templates           Templates
 	defaultTemplate     []string
 	aliases             map[string][]string
+	templateConflicts   TemplateConflictPolicy
 	meshConfig          *meshconfig.MeshConfig
 	valuesConfig        string
 	revision            string
 	proxyEnvs           map[string]string
 	injectedAnnotations map[string]string
+	// warningHandler, if set, receives the warnings of the injection, such as conflicts between templates.
+	warningHandler func(string)
 }
 
 func checkPreconditions(params InjectionParameters) {
@@ -381,8 +384,8 @@ func getInjectionStatus(podSpec corev1.PodSpec) string {
 // The injection logic works by first applying the rendered injection template on
 // top of the input pod This is done using a Strategic Patch Merge
 // (https://github.com/kubernetes/community/blob/master/contributors/devel/sig-api-machinery/strategic-merge-patch.md)
-// Currently only a single template is supported, although in the future the template to use will be configurable
-// and multiple templates will be supported by applying them in successive order.
+// The templates selected by the TemplatesAnnotation, or the default templates, are applied in successive order, and
+// the conflicts between them are handled according to the TemplateConflictsAnnotation.
 //
 // In addition to the plain templating, there is some post processing done to
 // handle cases that cannot feasibly be covered in the template, such as
@@ -431,6 +434,12 @@ const OverrideAnnotation = "proxy.istio.io/overrides"
 // TODO move this to api repo
 const TemplatesAnnotation = "inject.istio.io/templates"
 
+// TemplateConflictsAnnotation declares how the conflicts between the templates applied to the pod are handled, when a
+// template sets a field to a different value than an earlier template. One of warn, deny or allow; if not specified,
+// the templateConflicts policy of the injection configuration is used, which defaults to warn.
+// TODO move this to api repo
+const TemplateConflictsAnnotation = "inject.istio.io/template-conflicts"
+
 // reapplyOverwrittenContainers enables users to provide container level overrides for settings in the injection template
 // * originalPod: the pod before injection. If needed, we will apply some configurations from this pod on top of the final pod
 // * templatePod: the rendered injection template. This is needed only to see what containers we injected
@@ -789,6 +798,7 @@ func (wh *Webhook) inject(ar *kube.AdmissionReview, path string) *kube.Admission
 		templates:           wh.Config.Templates,
 		defaultTemplate:     wh.Config.DefaultTemplates,
 		aliases:             wh.Config.Aliases,
+		templateConflicts:   wh.Config.TemplateConflicts,
 		meshConfig:          wh.meshConfig,
 		valuesConfig:        wh.valuesConfig,
 		revision:            wh.revision,
@@ -796,6 +806,11 @@ func (wh *Webhook) inject(ar *kube.AdmissionReview, path string) *kube.Admission
 		proxyEnvs:           parseInjectEnvs(path),
 	}
 	wh.mu.RUnlock()
+	var warnings []string
+	params.warningHandler = func(warning string) {
+		log.Warnf("%s/%s: %s", pod.ObjectMeta.Namespace, podName, warning)
+		warnings = append(warnings, warning)
+	}
 
 	patchBytes, err := injectPod(params)
 	if err != nil {
@@ -804,8 +819,9 @@ func (wh *Webhook) inject(ar *kube.AdmissionReview, path string) *kube.Admission
 	}
 
 	reviewResponse := kube.AdmissionResponse{
-		Allowed: true,
-		Patch:   patchBytes,
+		Allowed:  true,
+		Patch:    patchBytes,
+		Warnings: warnings,
 		PatchType: func() *string {
 			pt := "JSONPatch"
 			return &pt
@@ -119,6 +119,13 @@ type Options struct {
 	// when generating private keys. Currently only ECDSA is supported.
 	ECCSigAlg string
 
+	// The elliptic curve to use when generating EC private keys: P256 or P384.
+	ECCCurve string
+
+	// The size of the RSA private keys of the workload certificates: 2048, 3072 or 4096.
+	// Only used when ECCSigAlg is not set.
+	WorkloadRSAKeySize int
+
 	// FileMountedCerts indicates whether the proxy is using file
 	// mounted certs created by a foreign CA. Refresh is managed by the external
 	// CA, by updating the Secret or VM file. We will watch the file for changes
@@ -158,6 +165,11 @@ type Options struct {
 	// Name of the Service Account
 	ServiceAccount string
 
+	// CRLFilePath is the path of the PEM encoded certificate revocation list of the CA. If the file exists,
+	// the list is distributed to the proxy along with the root certificate, so that peer certificates
+	// revoked by the CA are rejected.
+	CRLFilePath string
+
 	// XDS auth provider
 	XdsAuthProvider string
 
@@ -240,6 +252,9 @@ type SecretItem struct {
 
 	RootCert []byte
 
+	// CRL is the PEM encoded certificate revocation list used along with the root certificate.
+	CRL []byte
+
 	// ResourceName passed from envoy SDS discovery request.
 	// "ROOTCA" for root cert request, "default" for key/cert request.
 	ResourceName string
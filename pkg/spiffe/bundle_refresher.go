@@ -0,0 +1,193 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spiffe
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BundleEndpointStatus is the fetch status of the SPIFFE bundle endpoint of a federated trust domain.
+type BundleEndpointStatus struct {
+	TrustDomain string    `json:"trustDomain"`
+	Endpoint    string    `json:"endpoint"`
+	LastAttempt time.Time `json:"lastAttempt,omitempty"`
+	LastSuccess time.Time `json:"lastSuccess,omitempty"`
+	// LastError is the error of the last fetch, if it failed.
+	LastError string `json:"lastError,omitempty"`
+	// Stale is set when the last fetch failed, and the bundle of the last successful fetch, if any, is still in use.
+	Stale bool `json:"stale"`
+	// NotAfter is the expiration time of the root certificate of the bundle in use.
+	NotAfter time.Time `json:"notAfter,omitempty"`
+}
+
+// BundleRefresher periodically fetches the SPIFFE bundles of federated trust domains. Each endpoint is
+// refreshed independently: when a fetch fails, the last successfully fetched bundle of the trust domain is kept.
+type BundleRefresher struct {
+	endpoints map[string]string
+	caCerts   []*x509.Certificate
+	interval  time.Duration
+
+	// fetch retrieves the root certificate of the trust domain. Replaced in tests.
+	fetch func(trustDomain, endpoint string, caCertPool *x509.CertPool) (*x509.Certificate, error)
+
+	mu       sync.RWMutex
+	certs    map[string][]*x509.Certificate
+	status   map[string]*BundleEndpointStatus
+	handlers []func(map[string][]*x509.Certificate)
+}
+
+// NewBundleRefresher returns a refresher of the SPIFFE bundles of the trust domain to endpoint mappings.
+// The system cert pool and the supplied certificates are used to validate the endpoints.
+func NewBundleRefresher(endpoints map[string]string, extraTrustedCerts []*x509.Certificate,
+	interval time.Duration) *BundleRefresher {
+	r := &BundleRefresher{
+		endpoints: endpoints,
+		caCerts:   extraTrustedCerts,
+		interval:  interval,
+		fetch:     retrieveSpiffeBundleRootCert,
+		certs:     map[string][]*x509.Certificate{},
+		status:    map[string]*BundleEndpointStatus{},
+	}
+	for trustDomain, endpoint := range endpoints {
+		r.status[trustDomain] = &BundleEndpointStatus{TrustDomain: trustDomain, Endpoint: endpoint}
+	}
+	return r
+}
+
+// AddHandler registers a handler called with all the bundles when any of them changes.
+func (r *BundleRefresher) AddHandler(h func(map[string][]*x509.Certificate)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers = append(r.handlers, h)
+}
+
+// Run refreshes the bundles periodically until the stop channel is closed.
+func (r *BundleRefresher) Run(stop <-chan struct{}) {
+	if r.interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			r.Refresh()
+		}
+	}
+}
+
+// Refresh fetches the bundles of all the endpoints, and calls the handlers if any of them changed.
+func (r *BundleRefresher) Refresh() {
+	caCertPool, err := x509.SystemCertPool()
+	if err != nil {
+		spiffeLog.Errorf("failed to refresh SPIFFE bundles: failed to get SystemCertPool: %v", err)
+		return
+	}
+	for _, cert := range r.caCerts {
+		caCertPool.AddCert(cert)
+	}
+
+	changed := false
+	for trustDomain, endpoint := range r.endpoints {
+		cert, err := r.fetch(trustDomain, endpoint, caCertPool)
+		now := time.Now()
+
+		r.mu.Lock()
+		status := r.status[trustDomain]
+		status.LastAttempt = now
+		if err != nil {
+			status.LastError = err.Error()
+			status.Stale = true
+			// Stale bundles break the mTLS traffic with the trust domain once it rotates its root, so make it loud.
+			spiffeLog.Warnf("failed to refresh the SPIFFE bundle of trust domain %s (last success: %v): %v",
+				trustDomain, status.LastSuccess, err)
+			r.mu.Unlock()
+			continue
+		}
+		status.LastSuccess = now
+		status.LastError = ""
+		status.Stale = false
+		status.NotAfter = cert.NotAfter
+		if old := r.certs[trustDomain]; len(old) != 1 || !old[0].Equal(cert) {
+			spiffeLog.Infof("loaded a new SPIFFE bundle for trust domain %s", trustDomain)
+			r.certs[trustDomain] = []*x509.Certificate{cert}
+			changed = true
+		}
+		r.mu.Unlock()
+	}
+	if !changed {
+		return
+	}
+
+	bundles := r.Bundles()
+	r.mu.RLock()
+	handlers := append([]func(map[string][]*x509.Certificate){}, r.handlers...)
+	r.mu.RUnlock()
+	for _, h := range handlers {
+		h(bundles)
+	}
+}
+
+// Bundles returns the current root certificates, keyed by trust domain. Trust domains that were never
+// successfully fetched are omitted.
+func (r *BundleRefresher) Bundles() map[string][]*x509.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	res := make(map[string][]*x509.Certificate, len(r.certs))
+	for trustDomain, certs := range r.certs {
+		res[trustDomain] = certs
+	}
+	return res
+}
+
+// Status returns the fetch status of all the endpoints, sorted by trust domain.
+func (r *BundleRefresher) Status() []BundleEndpointStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	res := make([]BundleEndpointStatus, 0, len(r.status))
+	for _, status := range r.status {
+		res = append(res, *status)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].TrustDomain < res[j].TrustDomain
+	})
+	return res
+}
+
+// EncodeBundles returns the PEM encoding of the root certificates of the bundles, sorted by trust domain.
+func EncodeBundles(bundles map[string][]*x509.Certificate) ([]byte, error) {
+	trustDomains := make([]string, 0, len(bundles))
+	for trustDomain := range bundles {
+		trustDomains = append(trustDomains, trustDomain)
+	}
+	sort.Strings(trustDomains)
+	var buf bytes.Buffer
+	for _, trustDomain := range trustDomains {
+		for _, cert := range bundles[trustDomain] {
+			if err := pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}); err != nil {
+				return nil, fmt.Errorf("failed to encode the SPIFFE bundle of trust domain %s: %v", trustDomain, err)
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,141 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spiffe
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"testing"
+)
+
+func parseTestCert(t *testing.T, certPEM string) *x509.Certificate {
+	t.Helper()
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		t.Fatalf("failed to decode PEM cert")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("failed to parse cert: %v", err)
+	}
+	return cert
+}
+
+func TestBundleRefresher(t *testing.T) {
+	root1 := parseTestCert(t, validRootCert)
+	root2 := parseTestCert(t, validRootCert2)
+
+	served := map[string]*x509.Certificate{"foo.domain.com": root1, "bar.domain.com": root2}
+	refresher := NewBundleRefresher(map[string]string{
+		"foo.domain.com": "foo.domain.com/bundle",
+		"bar.domain.com": "bar.domain.com/bundle",
+	}, nil, 0)
+	refresher.fetch = func(trustDomain, endpoint string, _ *x509.CertPool) (*x509.Certificate, error) {
+		if cert := served[trustDomain]; cert != nil {
+			return cert, nil
+		}
+		return nil, fmt.Errorf("endpoint %s is unavailable", endpoint)
+	}
+	var updates []map[string][]*x509.Certificate
+	refresher.AddHandler(func(bundles map[string][]*x509.Certificate) {
+		updates = append(updates, bundles)
+	})
+
+	refresher.Refresh()
+	if len(updates) != 1 || len(updates[0]) != 2 {
+		t.Fatalf("expected one update with 2 bundles, got %v", updates)
+	}
+
+	// Unchanged bundles don't trigger an update.
+	refresher.Refresh()
+	if len(updates) != 1 {
+		t.Fatalf("expected no update for unchanged bundles, got %d updates", len(updates))
+	}
+
+	// A failed fetch keeps the last bundle, and is reported in the status.
+	served["bar.domain.com"] = nil
+	refresher.Refresh()
+	if len(updates) != 1 {
+		t.Fatalf("expected no update on a failed fetch, got %d updates", len(updates))
+	}
+	if got := refresher.Bundles()["bar.domain.com"]; len(got) != 1 || !got[0].Equal(root2) {
+		t.Errorf("expected the last bundle of bar.domain.com to be kept, got %v", got)
+	}
+	status := refresher.Status()
+	if len(status) != 2 || status[0].TrustDomain != "bar.domain.com" || !status[0].Stale || status[0].LastError == "" {
+		t.Errorf("unexpected status of bar.domain.com: %+v", status)
+	}
+	if status[1].Stale || status[1].LastSuccess.IsZero() || !status[1].NotAfter.Equal(root1.NotAfter) {
+		t.Errorf("unexpected status of foo.domain.com: %+v", status[1])
+	}
+
+	// A rotated root triggers an update.
+	served["bar.domain.com"] = root1
+	refresher.Refresh()
+	if len(updates) != 2 {
+		t.Fatalf("expected an update for the rotated bundle, got %d updates", len(updates))
+	}
+	if got := updates[1]["bar.domain.com"]; len(got) != 1 || !got[0].Equal(root1) {
+		t.Errorf("expected the rotated bundle of bar.domain.com, got %v", got)
+	}
+	if refresher.Status()[0].Stale {
+		t.Errorf("expected bar.domain.com to be healthy after a successful fetch")
+	}
+}
+
+func TestEncodeBundles(t *testing.T) {
+	root1 := parseTestCert(t, validRootCert)
+	root2 := parseTestCert(t, validRootCert2)
+
+	out, err := EncodeBundles(map[string][]*x509.Certificate{"foo.domain.com": {root1}, "bar.domain.com": {root2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var certs []*x509.Certificate
+	for block, rest := pem.Decode(out); block != nil; block, rest = pem.Decode(rest) {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certs = append(certs, cert)
+	}
+	// Sorted by trust domain.
+	if len(certs) != 2 || !certs[0].Equal(root2) || !certs[1].Equal(root1) {
+		t.Errorf("unexpected encoded bundles: %s", out)
+	}
+}
+
+func TestPeerCertVerifierUpdateMappings(t *testing.T) {
+	var rawCerts [][]byte
+	for _, c := range []string{validWorkloadCert, validIntCert} {
+		rawCerts = append(rawCerts, parseTestCert(t, c).Raw)
+	}
+
+	verifier := NewPeerCertVerifier()
+	verifier.AddMapping("foo.domain.com", []*x509.Certificate{parseTestCert(t, validRootCert2)})
+	verifier.AddMapping("bar.domain.com", []*x509.Certificate{parseTestCert(t, validRootCert2)})
+	if err := verifier.VerifyPeerCert(rawCerts, nil); err == nil {
+		t.Fatalf("expected the verification to fail before the update")
+	}
+
+	verifier.UpdateMappings(map[string][]*x509.Certificate{"foo.domain.com": {parseTestCert(t, validRootCert)}})
+	if err := verifier.VerifyPeerCert(rawCerts, nil); err != nil {
+		t.Errorf("expected the verification to succeed after the update: %v", err)
+	}
+	if got := len(verifier.GetGeneralCertPool().Subjects()); got != 2 {
+		t.Errorf("expected 2 certs in the general cert pool, got %d", got)
+	}
+}
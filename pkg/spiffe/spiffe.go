@@ -163,6 +163,16 @@ func GetTrustDomainFromURISAN(uriSan string) (string, error) {
 func RetrieveSpiffeBundleRootCertsFromStringInput(inputString string, extraTrustedCerts []*x509.Certificate) (
 	map[string][]*x509.Certificate, error) {
 	spiffeLog.Infof("Processing SPIFFE bundle configuration: %v", inputString)
+	config, err := ParseSpiffeBundleEndpoints(inputString)
+	if err != nil {
+		return nil, err
+	}
+	return RetrieveSpiffeBundleRootCerts(config, extraTrustedCerts)
+}
+
+// ParseSpiffeBundleEndpoints parses the trust domain to SPIFFE bundle endpoint mappings, in the format of:
+// "foo|URL1||bar|URL2||baz|URL3..."
+func ParseSpiffeBundleEndpoints(inputString string) (map[string]string, error) {
 	config := make(map[string]string)
 	tuples := strings.Split(inputString, "||")
 	for _, tuple := range tuples {
@@ -174,14 +184,13 @@ func RetrieveSpiffeBundleRootCertsFromStringInput(inputString string, extraTrust
 		endpoint := items[1]
 		config[trustDomain] = endpoint
 	}
-	return RetrieveSpiffeBundleRootCerts(config, extraTrustedCerts)
+	return config, nil
 }
 
 // RetrieveSpiffeBundleRootCerts retrieves the trusted CA certificates from a list of SPIFFE bundle endpoints.
 // It can use the system cert pool and the supplied certificates to validate the endpoints.
 func RetrieveSpiffeBundleRootCerts(config map[string]string, extraTrustedCerts []*x509.Certificate) (
 	map[string][]*x509.Certificate, error) {
-	httpClient := &http.Client{}
 	caCertPool, err := x509.SystemCertPool()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get SystemCertPool: %v", err)
@@ -192,87 +201,95 @@ func RetrieveSpiffeBundleRootCerts(config map[string]string, extraTrustedCerts [
 
 	ret := map[string][]*x509.Certificate{}
 	for trustdomain, endpoint := range config {
-		if !strings.HasPrefix(endpoint, "https://") {
-			endpoint = "https://" + endpoint
-		}
-		u, err := url.Parse(endpoint)
+		cert, err := retrieveSpiffeBundleRootCert(trustdomain, endpoint, caCertPool)
 		if err != nil {
-			return nil, fmt.Errorf("failed to split the SPIFFE bundle URL: %v", err)
-		}
-
-		config := &tls.Config{
-			ServerName: u.Hostname(),
-			RootCAs:    caCertPool,
-		}
-
-		httpClient.Transport = &http.Transport{
-			TLSClientConfig: config,
+			return nil, err
 		}
+		ret[trustdomain] = append(ret[trustdomain], cert)
+	}
+	for trustDomain, certs := range ret {
+		spiffeLog.Infof("Loaded SPIFFE trust bundle for: %v, containing %d certs", trustDomain, len(certs))
+	}
+	return ret, nil
+}
 
-		retryBackoffTime := firstRetryBackOffTime
-		startTime := time.Now()
-		var resp *http.Response
-		for {
-			resp, err = httpClient.Get(endpoint)
-			var errMsg string
-			if err != nil {
-				errMsg = fmt.Sprintf("Calling %s failed with error: %v", endpoint, err)
-			} else if resp == nil {
-				errMsg = fmt.Sprintf("Calling %s failed with nil response", endpoint)
-			} else if resp.StatusCode != http.StatusOK {
-				b := make([]byte, 1024)
-				n, _ := resp.Body.Read(b)
-				errMsg = fmt.Sprintf("Calling %s failed with unexpected status: %v, fetching bundle: %s",
-					endpoint, resp.StatusCode, string(b[:n]))
-			} else {
-				break
-			}
+// retrieveSpiffeBundleRootCert retrieves the trusted CA certificate of the trust domain from its SPIFFE bundle endpoint.
+func retrieveSpiffeBundleRootCert(trustdomain, endpoint string, caCertPool *x509.CertPool) (*x509.Certificate, error) {
+	if !strings.HasPrefix(endpoint, "https://") {
+		endpoint = "https://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to split the SPIFFE bundle URL: %v", err)
+	}
 
-			if startTime.Add(totalRetryTimeout).Before(time.Now()) {
-				return nil, fmt.Errorf("exhausted retries to fetch the SPIFFE bundle %s from url %s. Latest error: %v",
-					trustdomain, endpoint, errMsg)
-			}
+	httpClient := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				ServerName: u.Hostname(),
+				RootCAs:    caCertPool,
+			},
+		},
+	}
 
-			spiffeLog.Warnf("%s, retry in %v", errMsg, retryBackoffTime)
-			time.Sleep(retryBackoffTime)
-			retryBackoffTime *= 2 // Exponentially increase the retry backoff time.
+	retryBackoffTime := firstRetryBackOffTime
+	startTime := time.Now()
+	var resp *http.Response
+	for {
+		resp, err = httpClient.Get(endpoint)
+		var errMsg string
+		if err != nil {
+			errMsg = fmt.Sprintf("Calling %s failed with error: %v", endpoint, err)
+		} else if resp == nil {
+			errMsg = fmt.Sprintf("Calling %s failed with nil response", endpoint)
+		} else if resp.StatusCode != http.StatusOK {
+			b := make([]byte, 1024)
+			n, _ := resp.Body.Read(b)
+			_ = resp.Body.Close()
+			errMsg = fmt.Sprintf("Calling %s failed with unexpected status: %v, fetching bundle: %s",
+				endpoint, resp.StatusCode, string(b[:n]))
+		} else {
+			break
 		}
-		defer resp.Body.Close()
 
-		doc := new(bundleDoc)
-		if err := json.NewDecoder(resp.Body).Decode(doc); err != nil {
-			return nil, fmt.Errorf("trust domain [%s] at URL [%s] failed to decode bundle: %v", trustdomain, endpoint, err)
+		if startTime.Add(totalRetryTimeout).Before(time.Now()) {
+			return nil, fmt.Errorf("exhausted retries to fetch the SPIFFE bundle %s from url %s. Latest error: %v",
+				trustdomain, endpoint, errMsg)
 		}
 
-		var cert *x509.Certificate
-		for i, key := range doc.Keys {
-			if key.Use == "x509-svid" {
-				if len(key.Certificates) != 1 {
-					return nil, fmt.Errorf("trust domain [%s] at URL [%s] expected 1 certificate in x509-svid entry %d; got %d",
-						trustdomain, endpoint, i, len(key.Certificates))
-				}
-				cert = key.Certificates[0]
+		spiffeLog.Warnf("%s, retry in %v", errMsg, retryBackoffTime)
+		time.Sleep(retryBackoffTime)
+		retryBackoffTime *= 2 // Exponentially increase the retry backoff time.
+	}
+	defer resp.Body.Close()
+
+	doc := new(bundleDoc)
+	if err := json.NewDecoder(resp.Body).Decode(doc); err != nil {
+		return nil, fmt.Errorf("trust domain [%s] at URL [%s] failed to decode bundle: %v", trustdomain, endpoint, err)
+	}
+
+	var cert *x509.Certificate
+	for i, key := range doc.Keys {
+		if key.Use == "x509-svid" {
+			if len(key.Certificates) != 1 {
+				return nil, fmt.Errorf("trust domain [%s] at URL [%s] expected 1 certificate in x509-svid entry %d; got %d",
+					trustdomain, endpoint, i, len(key.Certificates))
 			}
-		}
-		if cert == nil {
-			return nil, fmt.Errorf("trust domain [%s] at URL [%s] does not provide a X509 SVID", trustdomain, endpoint)
-		}
-		if certs, ok := ret[trustdomain]; ok {
-			ret[trustdomain] = append(certs, cert)
-		} else {
-			ret[trustdomain] = []*x509.Certificate{cert}
+			cert = key.Certificates[0]
 		}
 	}
-	for trustDomain, certs := range ret {
-		spiffeLog.Infof("Loaded SPIFFE trust bundle for: %v, containing %d certs", trustDomain, len(certs))
+	if cert == nil {
+		return nil, fmt.Errorf("trust domain [%s] at URL [%s] does not provide a X509 SVID", trustdomain, endpoint)
 	}
-	return ret, nil
+	return cert, nil
 }
 
 // PeerCertVerifier is an instance to verify the peer certificate in the SPIFFE way using the retrieved root certificates.
 type PeerCertVerifier struct {
+	mu              sync.RWMutex
 	generalCertPool *x509.CertPool
 	certPools       map[string]*x509.CertPool
+	certs           map[string][]*x509.Certificate
 }
 
 // NewPeerCertVerifier returns a new PeerCertVerifier.
@@ -280,16 +297,21 @@ func NewPeerCertVerifier() *PeerCertVerifier {
 	return &PeerCertVerifier{
 		generalCertPool: x509.NewCertPool(),
 		certPools:       make(map[string]*x509.CertPool),
+		certs:           make(map[string][]*x509.Certificate),
 	}
 }
 
 // GetGeneralCertPool returns generalCertPool containing all root certs.
 func (v *PeerCertVerifier) GetGeneralCertPool() *x509.CertPool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
 	return v.generalCertPool
 }
 
 // AddMapping adds a new trust domain to certificates mapping to the certPools map.
 func (v *PeerCertVerifier) AddMapping(trustDomain string, certs []*x509.Certificate) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
 	if v.certPools[trustDomain] == nil {
 		v.certPools[trustDomain] = x509.NewCertPool()
 	}
@@ -297,9 +319,34 @@ func (v *PeerCertVerifier) AddMapping(trustDomain string, certs []*x509.Certific
 		v.certPools[trustDomain].AddCert(cert)
 		v.generalCertPool.AddCert(cert)
 	}
+	v.certs[trustDomain] = append(v.certs[trustDomain], certs...)
 	spiffeLog.Infof("Added %d certs to trust domain %s in peer cert verifier", len(certs), trustDomain)
 }
 
+// UpdateMappings replaces the certificates of the trust domains of the map, for example after a refresh of
+// their SPIFFE bundles. The certificates of the other trust domains are kept.
+func (v *PeerCertVerifier) UpdateMappings(certMap map[string][]*x509.Certificate) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for trustDomain, certs := range certMap {
+		v.certs[trustDomain] = certs
+		pool := x509.NewCertPool()
+		for _, cert := range certs {
+			pool.AddCert(cert)
+		}
+		v.certPools[trustDomain] = pool
+	}
+	// Pools can't be modified safely while in use, so the general pool is rebuilt.
+	generalCertPool := x509.NewCertPool()
+	for _, certs := range v.certs {
+		for _, cert := range certs {
+			generalCertPool.AddCert(cert)
+		}
+	}
+	v.generalCertPool = generalCertPool
+	spiffeLog.Infof("Updated the certs of %d trust domains in peer cert verifier", len(certMap))
+}
+
 // AddMappingFromPEM adds multiple RootCA's to the spiffe Trust bundle in the trustDomain namespace
 func (v *PeerCertVerifier) AddMappingFromPEM(trustDomain string, rootCertBytes []byte) error {
 	block, rest := pem.Decode(rootCertBytes)
@@ -355,7 +402,9 @@ func (v *PeerCertVerifier) VerifyPeerCert(rawCerts [][]byte, _ [][]*x509.Certifi
 	if err != nil {
 		return err
 	}
+	v.mu.RLock()
 	rootCertPool, ok := v.certPools[trustDomain]
+	v.mu.RUnlock()
 	if !ok {
 		return fmt.Errorf("no cert pool found for trust domain %s", trustDomain)
 	}
@@ -0,0 +1,134 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RegistryAuth provides the credentials of the registries serving Wasm modules, read from a docker config JSON file
+// such as a mounted kubernetes.io/dockerconfigjson Secret. The file is read again when it changes, so that rotated
+// credentials are used without restarting the agent.
+type RegistryAuth struct {
+	path string
+
+	mux sync.Mutex
+	// modTime is the modification time of the file when it was last read.
+	modTime time.Time
+	// authorizations are the values of the Authorization header, keyed by registry host.
+	authorizations map[string]string
+}
+
+// NewRegistryAuth creates the credentials of the registries read from the docker config JSON file at the path.
+func NewRegistryAuth(path string) *RegistryAuth {
+	return &RegistryAuth{path: path}
+}
+
+type dockerConfig struct {
+	Auths map[string]struct {
+		Auth          string `json:"auth"`
+		Username      string `json:"username"`
+		Password      string `json:"password"`
+		IdentityToken string `json:"identitytoken"`
+		RegistryToken string `json:"registrytoken"`
+	} `json:"auths"`
+}
+
+// Authorization returns the value of the Authorization header of the requests to the host, or an empty string if the
+// host has no credentials.
+func (a *RegistryAuth) Authorization(host string) string {
+	if a == nil {
+		return ""
+	}
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if err := a.maybeReload(); err != nil {
+		wasmLog.Warnf("failed to read the registry credentials of Wasm modules from %s: %v", a.path, err)
+	}
+	return a.authorizations[host]
+}
+
+// maybeReload reads the file again if it was modified since it was last read. The previous credentials are kept if
+// it can't be read.
+func (a *RegistryAuth) maybeReload() error {
+	info, err := os.Stat(a.path)
+	if err != nil {
+		return err
+	}
+	if a.authorizations != nil && info.ModTime().Equal(a.modTime) {
+		return nil
+	}
+	b, err := ioutil.ReadFile(a.path)
+	if err != nil {
+		return err
+	}
+	authorizations, err := parseDockerConfig(b)
+	if err != nil {
+		return err
+	}
+	a.authorizations = authorizations
+	a.modTime = info.ModTime()
+	return nil
+}
+
+// parseDockerConfig parses a docker config JSON file, or the legacy .dockercfg format without the auths object, into
+// the values of the Authorization header keyed by registry host.
+func parseDockerConfig(b []byte) (map[string]string, error) {
+	cfg := dockerConfig{}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Auths == nil {
+		if err := json.Unmarshal(b, &cfg.Auths); err != nil {
+			return nil, err
+		}
+	}
+	res := make(map[string]string, len(cfg.Auths))
+	for registry, auth := range cfg.Auths {
+		var authorization string
+		switch {
+		case auth.RegistryToken != "":
+			authorization = "Bearer " + auth.RegistryToken
+		case auth.IdentityToken != "":
+			authorization = "Bearer " + auth.IdentityToken
+		case auth.Auth != "":
+			authorization = "Basic " + auth.Auth
+		case auth.Username != "":
+			authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(auth.Username+":"+auth.Password))
+		default:
+			return nil, fmt.Errorf("registry %s has no credentials", registry)
+		}
+		res[registryHost(registry)] = authorization
+	}
+	return res, nil
+}
+
+// registryHost returns the host of a registry of a docker config, which may be written as a URL.
+func registryHost(registry string) string {
+	if strings.Contains(registry, "://") {
+		if u, err := url.Parse(registry); err == nil {
+			return u.Host
+		}
+	}
+	return strings.SplitN(registry, "/", 2)[0]
+}
@@ -0,0 +1,111 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseDockerConfig(t *testing.T) {
+	cases := []struct {
+		name   string
+		config string
+		want   map[string]string
+		err    bool
+	}{
+		{
+			name: "auths",
+			config: `{"auths": {"registry.example.com": {"auth": "dXNlcjpwYXNz"},
+				"https://index.docker.io/v1/": {"username": "user", "password": "pass"},
+				"gcr.io": {"identitytoken": "token"}}}`,
+			want: map[string]string{
+				"registry.example.com": "Basic dXNlcjpwYXNz",
+				"index.docker.io":      "Basic dXNlcjpwYXNz",
+				"gcr.io":               "Bearer token",
+			},
+		},
+		{
+			name:   "legacy dockercfg",
+			config: `{"registry.example.com:5000": {"auth": "dXNlcjpwYXNz"}}`,
+			want:   map[string]string{"registry.example.com:5000": "Basic dXNlcjpwYXNz"},
+		},
+		{name: "invalid json", config: `{"auths": `, err: true},
+		{name: "missing credentials", config: `{"auths": {"registry.example.com": {}}}`, err: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parseDockerConfig([]byte(c.config))
+			if c.err {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(c.want) {
+				t.Fatalf("got %v, want %v", got, c.want)
+			}
+			for host, authorization := range c.want {
+				if got[host] != authorization {
+					t.Errorf("authorization of %s got %q, want %q", host, got[host], authorization)
+				}
+			}
+		})
+	}
+}
+
+func TestRegistryAuthRefresh(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(path, []byte(`{"auths": {"registry.example.com": {"auth": "b2xk"}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	auth := NewRegistryAuth(path)
+	if got := auth.Authorization("registry.example.com"); got != "Basic b2xk" {
+		t.Fatalf("got authorization %q, want %q", got, "Basic b2xk")
+	}
+
+	// Rotate the credentials.
+	if err := ioutil.WriteFile(path, []byte(`{"auths": {"registry.example.com": {"auth": "bmV3"}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if got := auth.Authorization("registry.example.com"); got != "Basic bmV3" {
+		t.Errorf("got authorization %q, want %q", got, "Basic bmV3")
+	}
+
+	// Invalid credentials don't replace the previous ones.
+	if err := ioutil.WriteFile(path, []byte(`{"auths": `), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later = later.Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if got := auth.Authorization("registry.example.com"); got != "Basic bmV3" {
+		t.Errorf("got authorization %q, want %q", got, "Basic bmV3")
+	}
+	if got := auth.Authorization("other.example.com"); got != "" {
+		t.Errorf("got authorization %q for an unknown registry", got)
+	}
+}
@@ -49,6 +49,9 @@ type LocalFileCache struct {
 	// Map from Wasm module checksum to cache entry.
 	modules map[cacheKey]cacheEntry
 
+	// downloads are the downloads in progress, shared by the concurrent Gets of a module.
+	downloads map[cacheKey]*download
+
 	// http fetcher fetches Wasm module with HTTP get.
 	httpFetcher *HTTPFetcher
 
@@ -82,11 +85,20 @@ type cacheEntry struct {
 	last time.Time
 }
 
+// download is a download of a Wasm module in progress. path and err are set once done is closed.
+type download struct {
+	done chan struct{}
+	path string
+	err  error
+}
+
 // NewLocalFileCache create a new Wasm module cache which downloads and stores Wasm module files locally.
-func NewLocalFileCache(dir string, purgeInterval, moduleExpiry time.Duration) *LocalFileCache {
+// The downloads carry the registry credentials from auth, which can be nil.
+func NewLocalFileCache(dir string, purgeInterval, moduleExpiry time.Duration, auth *RegistryAuth) *LocalFileCache {
 	cache := &LocalFileCache{
-		httpFetcher:      NewHTTPFetcher(),
+		httpFetcher:      NewHTTPFetcher(auth),
 		modules:          make(map[cacheKey]cacheEntry),
+		downloads:        make(map[cacheKey]*download),
 		dir:              dir,
 		purgeInterval:    purgeInterval,
 		wasmModuleExpiry: moduleExpiry,
@@ -118,36 +130,61 @@ func (c *LocalFileCache) Get(downloadURL, checksum string, timeout time.Duration
 		}
 
 		// If the module is not available locally, download the Wasm module with http fetcher.
-		b, err := c.httpFetcher.Fetch(downloadURL, timeout)
-		if err != nil {
-			wasmRemoteFetchCount.With(resultTag.Value(downloadFailure)).Increment()
-			return "", err
-		}
+		return c.sharedDownload(key, func() (string, error) {
+			b, err := c.httpFetcher.Fetch(downloadURL, timeout)
+			if err != nil {
+				wasmRemoteFetchCount.With(resultTag.Value(downloadFailure)).Increment()
+				return "", err
+			}
 
-		// Get sha256 checksum and check if it is the same as provided one.
-		dChecksum := fmt.Sprintf("%x", sha256.Sum256(b))
-		if checksum != "" && dChecksum != checksum {
-			wasmRemoteFetchCount.With(resultTag.Value(checksumMismatch)).Increment()
-			return "", fmt.Errorf("module downloaded from %v has checksum %v, which does not match: %v", downloadURL, dChecksum, checksum)
-		}
+			// Get sha256 checksum and check if it is the same as provided one.
+			dChecksum := fmt.Sprintf("%x", sha256.Sum256(b))
+			if checksum != "" && dChecksum != checksum {
+				wasmRemoteFetchCount.With(resultTag.Value(checksumMismatch)).Increment()
+				return "", fmt.Errorf("module downloaded from %v has checksum %v, which does not match: %v", downloadURL, dChecksum, checksum)
+			}
 
-		wasmRemoteFetchCount.With(resultTag.Value(fetchSuccess)).Increment()
+			wasmRemoteFetchCount.With(resultTag.Value(fetchSuccess)).Increment()
 
-		// TODO(bianpengyuan): Add sanity check on downloaded file to make sure it is a valid Wasm module.
+			// TODO(bianpengyuan): Add sanity check on downloaded file to make sure it is a valid Wasm module.
 
-		key.checksum = dChecksum
-		f := filepath.Join(c.dir, fmt.Sprintf("%s.wasm", dChecksum))
+			entryKey := cacheKey{downloadURL: downloadURL, checksum: dChecksum}
+			f := filepath.Join(c.dir, fmt.Sprintf("%s.wasm", dChecksum))
 
-		if err := c.addEntry(key, b, f); err != nil {
-			return "", err
-		}
+			if err := c.addEntry(entryKey, b, f); err != nil {
+				return "", err
+			}
 
-		return f, nil
+			return f, nil
+		})
 	default:
 		return "", fmt.Errorf("unsupported Wasm module downloading URL scheme: %v", url.Scheme)
 	}
 }
 
+// sharedDownload runs the download of the module with the key, unless it is already in progress, in which case
+// it waits for it and returns its result. This way, ECDS updates referencing the same module while it is
+// downloaded don't download it several times.
+func (c *LocalFileCache) sharedDownload(key cacheKey, fetch func() (string, error)) (string, error) {
+	c.mux.Lock()
+	if d, f := c.downloads[key]; f {
+		c.mux.Unlock()
+		<-d.done
+		return d.path, d.err
+	}
+	d := &download{done: make(chan struct{})}
+	c.downloads[key] = d
+	c.mux.Unlock()
+
+	d.path, d.err = fetch()
+
+	c.mux.Lock()
+	delete(c.downloads, key)
+	c.mux.Unlock()
+	close(d.done)
+	return d.path, d.err
+}
+
 // Cleanup closes background Wasm module purge routine.
 func (c *LocalFileCache) Cleanup() {
 	close(c.stopChan)
@@ -16,15 +16,19 @@ package wasm
 
 import (
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"istio.io/istio/pkg/test/util/retry"
 	"istio.io/istio/tests/util/leak"
 )
 
@@ -138,7 +142,7 @@ func TestWasmCache(t *testing.T) {
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
 			tmpDir := t.TempDir()
-			cache := NewLocalFileCache(tmpDir, c.purgeInterval, c.wasmModuleExpiry)
+			cache := NewLocalFileCache(tmpDir, c.purgeInterval, c.wasmModuleExpiry, nil)
 			defer close(cache.stopChan)
 			tsNumRequest = 0
 
@@ -191,7 +195,7 @@ func TestWasmCache(t *testing.T) {
 
 func TestWasmCacheMissChecksum(t *testing.T) {
 	tmpDir := t.TempDir()
-	cache := NewLocalFileCache(tmpDir, DefaultWasmModulePurgeInteval, DefaultWasmModuleExpiry)
+	cache := NewLocalFileCache(tmpDir, DefaultWasmModulePurgeInteval, DefaultWasmModuleExpiry, nil)
 	defer close(cache.stopChan)
 
 	gotNumRequest := 0
@@ -241,3 +245,52 @@ func TestWasmCacheMissChecksum(t *testing.T) {
 		t.Errorf("wasm download call got %v want %v", gotNumRequest, wantNumRequest)
 	}
 }
+
+func TestWasmCacheSharedDownload(t *testing.T) {
+	tmpDir := t.TempDir()
+	cache := NewLocalFileCache(tmpDir, DefaultWasmModulePurgeInteval, DefaultWasmModuleExpiry, nil)
+	defer close(cache.stopChan)
+
+	var gotNumRequest int32
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&gotNumRequest, 1)
+		<-release
+		fmt.Fprintln(w, "0")
+	}))
+	defer ts.Close()
+	checksum := fmt.Sprintf("%x", sha256.Sum256([]byte("0\n")))
+	wantFilePath := filepath.Join(tmpDir, fmt.Sprintf("%s.wasm", checksum))
+
+	// Concurrent gets of a module being downloaded share its download.
+	var wg sync.WaitGroup
+	paths := make([]string, 3)
+	errs := make([]error, 3)
+	for i := range paths {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			paths[i], errs[i] = cache.Get(ts.URL, checksum, 0)
+		}(i)
+	}
+	retry.UntilSuccessOrFail(t, func() error {
+		if atomic.LoadInt32(&gotNumRequest) == 0 {
+			return errors.New("the module is not downloaded yet")
+		}
+		return nil
+	}, retry.Timeout(5*time.Second))
+	close(release)
+	wg.Wait()
+
+	for i := range paths {
+		if errs[i] != nil {
+			t.Fatalf("failed to download Wasm module: %v", errs[i])
+		}
+		if paths[i] != wantFilePath {
+			t.Errorf("wasm download path got %v want %v", paths[i], wantFilePath)
+		}
+	}
+	if got := atomic.LoadInt32(&gotNumRequest); got != 1 {
+		t.Errorf("wasm download call got %v want 1", got)
+	}
+}
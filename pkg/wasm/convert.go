@@ -15,6 +15,9 @@
 package wasm
 
 import (
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -24,7 +27,6 @@ import (
 	"github.com/envoyproxy/go-control-plane/pkg/conversion"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/any"
-	"go.uber.org/atomic"
 )
 
 const (
@@ -36,10 +38,17 @@ const (
 // MaybeConvertWasmExtensionConfig converts any presence of module remote download to local file.
 // It downloads the Wasm module and stores the module locally in the file system.
 func MaybeConvertWasmExtensionConfig(resources []*any.Any, cache Cache) bool {
+	return len(ConvertWasmExtensionConfig(resources, cache)) > 0
+}
+
+// ConvertWasmExtensionConfig is MaybeConvertWasmExtensionConfig, returning the errors of the extension configs
+// whose Wasm module could not be fetched, keyed by name. The resources should be NACKed if there is any.
+func ConvertWasmExtensionConfig(resources []*any.Any, cache Cache) map[string]error {
 	var wg sync.WaitGroup
+	var mu sync.Mutex
 	numResources := len(resources)
 	wg.Add(numResources)
-	sendNack := atomic.NewBool(false)
+	failures := map[string]error{}
 	startTime := time.Now()
 	defer func() {
 		wasmConfigConversionDuration.Record(float64(time.Since(startTime).Milliseconds()))
@@ -49,9 +58,11 @@ func MaybeConvertWasmExtensionConfig(resources []*any.Any, cache Cache) bool {
 		go func(i int) {
 			defer wg.Done()
 
-			newExtensionConfig, nack := convert(resources[i], cache)
-			if nack {
-				sendNack.Store(true)
+			name, newExtensionConfig, err := convert(resources[i], cache)
+			if err != nil {
+				mu.Lock()
+				failures[name] = err
+				mu.Unlock()
 				return
 			}
 			resources[i] = newExtensionConfig
@@ -59,23 +70,43 @@ func MaybeConvertWasmExtensionConfig(resources []*any.Any, cache Cache) bool {
 	}
 
 	wg.Wait()
-	return sendNack.Load()
+	return failures
+}
+
+// FetchErrorPrefix prefixes the error of the NACK of the ECDS responses whose Wasm modules can't be fetched. It is
+// followed by a "<name>: <reason>" line for each extension config, so that istiod can tell them apart.
+const FetchErrorPrefix = "Error fetching Wasm module of extension config(s) "
+
+// FetchErrorMessage returns the error of the NACK of the ECDS response, given the errors of ConvertWasmExtensionConfig.
+func FetchErrorMessage(failures map[string]error) string {
+	names := make([]string, 0, len(failures))
+	for name := range failures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		lines = append(lines, name+": "+failures[name].Error())
+	}
+	return FetchErrorPrefix + strings.Join(lines, "\n")
 }
 
-func convert(resource *any.Any, cache Cache) (newExtensionConfig *any.Any, sendNack bool) {
+// convert rewrites the remote load of the Wasm module of the extension config, if any, to the local file it is
+// downloaded to. It returns an error if the module could not be fetched, unless the plugin fails open.
+func convert(resource *any.Any, cache Cache) (name string, newExtensionConfig *any.Any, err error) {
 	ec := &core.TypedExtensionConfig{}
 	newExtensionConfig = resource
-	sendNack = false
 	status := noRemoteLoad
 	defer func() {
 		wasmConfigConversionCount.
 			With(resultTag.Value(status)).
 			Increment()
 	}()
-	if err := ptypes.UnmarshalAny(resource, ec); err != nil {
-		wasmLog.Debugf("failed to unmarshal extension config resource: %v", err)
+	if uerr := ptypes.UnmarshalAny(resource, ec); uerr != nil {
+		wasmLog.Debugf("failed to unmarshal extension config resource: %v", uerr)
 		return
 	}
+	name = ec.GetName()
 
 	// Currently Wasm filter can only be configured using typed struct via EnvoyFilter.
 	wasmLog.Debugf("original extension config resource %+v", ec)
@@ -85,8 +116,8 @@ func convert(resource *any.Any, cache Cache) (newExtensionConfig *any.Any, sendN
 	}
 	wasmStruct := &udpa.TypedStruct{}
 	wasmTypedConfig := ec.GetTypedConfig()
-	if err := ptypes.UnmarshalAny(wasmTypedConfig, wasmStruct); err != nil {
-		wasmLog.Debugf("failed to unmarshal typed config for wasm filter: %v", err)
+	if uerr := ptypes.UnmarshalAny(wasmTypedConfig, wasmStruct); uerr != nil {
+		wasmLog.Debugf("failed to unmarshal typed config for wasm filter: %v", uerr)
 		return
 	}
 
@@ -96,7 +127,7 @@ func convert(resource *any.Any, cache Cache) (newExtensionConfig *any.Any, sendN
 	}
 
 	wasmHTTPFilterConfig := &wasm.Wasm{}
-	if err := conversion.StructToMessage(wasmStruct.Value, wasmHTTPFilterConfig); err != nil {
+	if cerr := conversion.StructToMessage(wasmStruct.Value, wasmHTTPFilterConfig); cerr != nil {
 		wasmLog.Debugf("failed to convert extension config struct %+v to Wasm HTTP filter", wasmStruct)
 		return
 	}
@@ -109,7 +140,11 @@ func convert(resource *any.Any, cache Cache) (newExtensionConfig *any.Any, sendN
 	// Wasm plugin configuration has remote load. From this point, any failure should result as a Nack,
 	// unless the plugin is marked as fail open.
 	failOpen := wasmHTTPFilterConfig.Config.GetFailOpen()
-	sendNack = !failOpen
+	fail := func(e error) {
+		if !failOpen {
+			err = e
+		}
+	}
 	status = conversionSuccess
 
 	vm := wasmHTTPFilterConfig.Config.GetVmConfig()
@@ -118,16 +153,18 @@ func convert(resource *any.Any, cache Cache) (newExtensionConfig *any.Any, sendN
 	if httpURI == nil {
 		status = missRemoteFetchHint
 		wasmLog.Errorf("wasm remote fetch %+v does not have httpUri specified", remote)
+		fail(fmt.Errorf("remote load of the Wasm module has no httpUri"))
 		return
 	}
 	timeout := time.Duration(0)
 	if remote.GetHttpUri().Timeout != nil {
 		timeout = remote.GetHttpUri().Timeout.AsDuration()
 	}
-	f, err := cache.Get(httpURI.GetUri(), remote.GetSha256(), timeout)
-	if err != nil {
+	f, ferr := cache.Get(httpURI.GetUri(), remote.GetSha256(), timeout)
+	if ferr != nil {
 		status = fetchFailure
-		wasmLog.Errorf("cannot fetch Wasm module %v: %v", remote.GetHttpUri().GetUri(), err)
+		wasmLog.Errorf("cannot fetch Wasm module %v: %v", remote.GetHttpUri().GetUri(), ferr)
+		fail(fmt.Errorf("cannot fetch Wasm module %v: %v", remote.GetHttpUri().GetUri(), ferr))
 		return
 	}
 
@@ -142,25 +179,26 @@ func convert(resource *any.Any, cache Cache) (newExtensionConfig *any.Any, sendN
 		},
 	}
 
-	wasmTypedConfig, err = ptypes.MarshalAny(wasmHTTPFilterConfig)
-	if err != nil {
+	wasmTypedConfig, merr := ptypes.MarshalAny(wasmHTTPFilterConfig)
+	if merr != nil {
 		status = marshalFailure
-		wasmLog.Errorf("failed to marshal new wasm HTTP filter %+v to protobuf Any: %v", wasmHTTPFilterConfig, err)
+		wasmLog.Errorf("failed to marshal new wasm HTTP filter %+v to protobuf Any: %v", wasmHTTPFilterConfig, merr)
+		fail(fmt.Errorf("failed to marshal the Wasm HTTP filter: %v", merr))
 		return
 	}
 	ec.TypedConfig = wasmTypedConfig
 	wasmLog.Debugf("new extension config resource %+v", ec)
 
-	nec, err := ptypes.MarshalAny(ec)
-	if err != nil {
+	nec, merr := ptypes.MarshalAny(ec)
+	if merr != nil {
 		status = marshalFailure
-		wasmLog.Errorf("failed to marshal new extension config resource: %v", err)
+		wasmLog.Errorf("failed to marshal new extension config resource: %v", merr)
+		fail(fmt.Errorf("failed to marshal the extension config: %v", merr))
 		return
 	}
 
 	// At this point, we are certain that wasm module has been downloaded and config is rewritten.
 	// ECDS has been rewritten successfully and should not nack.
 	newExtensionConfig = nec
-	sendNack = false
 	return
 }
@@ -17,6 +17,7 @@ package wasm
 import (
 	"errors"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -168,6 +169,24 @@ func TestWasmConvert(t *testing.T) {
 	}
 }
 
+func TestWasmConvertFailures(t *testing.T) {
+	resources := []*any.Any{
+		util.MessageToAny(extensionConfigMap["remote-load-success"]),
+		util.MessageToAny(extensionConfigMap["remote-load-fail"]),
+		util.MessageToAny(extensionConfigMap["remote-load-fail-open"]),
+	}
+	failures := ConvertWasmExtensionConfig(resources, &mockCache{})
+	if len(failures) != 1 || failures["remote-load-fail"] == nil {
+		t.Fatalf("expected remote-load-fail to fail, got %v", failures)
+	}
+	if got := failures["remote-load-fail"].Error(); !strings.Contains(got, "download-error") {
+		t.Errorf("expected the error of remote-load-fail to report the download error, got %q", got)
+	}
+	if got, want := FetchErrorMessage(failures), FetchErrorPrefix+"remote-load-fail: "; !strings.HasPrefix(got, want) {
+		t.Errorf("got NACK message %q, want prefix %q", got, want)
+	}
+}
+
 func buildTypedStructExtensionConfig(name string, wasm *wasm.Wasm) *core.TypedExtensionConfig {
 	ws, _ := conversion.MessageToStruct(wasm)
 	return &core.TypedExtensionConfig{
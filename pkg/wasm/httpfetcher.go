@@ -30,15 +30,19 @@ type HTTPFetcher struct {
 	defaultClient *http.Client
 	// TODO(bianpengyuan): make this exponential backoff.
 	retryBackoff time.Duration
+	// auth provides the credentials of the registries serving wasm modules, if any.
+	auth *RegistryAuth
 }
 
-// NewHTTPFetcher create a new HTTP remote wasm module fetcher.
-func NewHTTPFetcher() *HTTPFetcher {
+// NewHTTPFetcher create a new HTTP remote wasm module fetcher. The requests carry the credentials of the registry
+// from auth, which can be nil.
+func NewHTTPFetcher(auth *RegistryAuth) *HTTPFetcher {
 	return &HTTPFetcher{
 		defaultClient: &http.Client{
 			Timeout: 5 * time.Second,
 		},
 		retryBackoff: defaultRetryBackoff,
+		auth:         auth,
 	}
 }
 
@@ -50,11 +54,19 @@ func (f *HTTPFetcher) Fetch(url string, timeout time.Duration) ([]byte, error) {
 			Timeout: timeout,
 		}
 	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("wasm module download failed: %v", err)
+	}
 	attempts := 0
 	var lastError error
 	for attempts < 5 {
 		attempts++
-		resp, err := c.Get(url)
+		// The credentials are looked up at each attempt, as they may have been refreshed.
+		if authorization := f.auth.Authorization(req.URL.Host); authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		resp, err := c.Do(req)
 		if err != nil {
 			lastError = err
 			wasmLog.Debugf("wasm module download request failed: %v", err)
@@ -67,7 +79,7 @@ func (f *HTTPFetcher) Fetch(url string, timeout time.Duration) ([]byte, error) {
 			return body, err
 		}
 		lastError = fmt.Errorf("wasm module download request failed: status code %v", resp.StatusCode)
-		if retryable(resp.StatusCode) {
+		if retryable(resp.StatusCode) || f.auth != nil && unauthorized(resp.StatusCode) {
 			body, _ := ioutil.ReadAll(resp.Body)
 			wasmLog.Debugf("wasm module download failed: status code %v, body %v", resp.StatusCode, string(body))
 			resp.Body.Close()
@@ -81,3 +93,8 @@ func (f *HTTPFetcher) Fetch(url string, timeout time.Duration) ([]byte, error) {
 func retryable(code int) bool {
 	return code >= 500 && !(code == 501 || code == 505 || code == 511)
 }
+
+// unauthorized returns true if the registry rejected the credentials of the request.
+func unauthorized(code int) bool {
+	return code == http.StatusUnauthorized || code == http.StatusForbidden
+}
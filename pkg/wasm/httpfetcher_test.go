@@ -16,8 +16,11 @@ package wasm
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"path/filepath"
 	"testing"
 )
 
@@ -67,7 +70,7 @@ func TestWasmHTTPFetch(t *testing.T) {
 				gotNumRequest++
 			}))
 			defer ts.Close()
-			fetcher := NewHTTPFetcher()
+			fetcher := NewHTTPFetcher(nil)
 			b, err := fetcher.Fetch(ts.URL, 0)
 			if c.wantNumRequest != gotNumRequest {
 				t.Errorf("Wasm download request got %v, want %v", gotNumRequest, c.wantNumRequest)
@@ -84,3 +87,34 @@ func TestWasmHTTPFetch(t *testing.T) {
 		})
 	}
 }
+
+func TestWasmHTTPFetchWithRegistryAuth(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Basic dXNlcjpwYXNz" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintln(w, "wasm")
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "config.json")
+	config := fmt.Sprintf(`{"auths": {%q: {"username": "user", "password": "pass"}}}`, u.Host)
+	if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := NewHTTPFetcher(NewRegistryAuth(path)).Fetch(ts.URL, 0)
+	if err != nil {
+		t.Fatalf("Wasm download got unexpected error %v", err)
+	}
+	if string(b) != "wasm\n" {
+		t.Errorf("downloaded wasm module got %v, want wasm", string(b))
+	}
+	if _, err := NewHTTPFetcher(nil).Fetch(ts.URL, 0); err == nil {
+		t.Errorf("Wasm download without credentials got no error")
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"context"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -70,6 +71,14 @@ func getRandomCsrName(secretName, namespace string) string {
 	return name
 }
 
+var (
+	// ErrCSRApproval is returned when a CSR could not be approved, e.g. when the approval of the CSRs of its
+	// signer is not allowed.
+	ErrCSRApproval = errors.New("failed to approve CSR")
+	// ErrCSRDenied is returned when a CSR was denied, or failed to be signed by its signer.
+	ErrCSRDenied = errors.New("CSR denied or failed")
+)
+
 // GenKeyCertK8sCA : Generates a key pair and gets public certificate signed by K8s_CA
 // Options are meant to sign DNS certs
 // 1. Generate a CSR
@@ -137,7 +146,7 @@ func SignCSRK8s(certClient certclient.CertificateSigningRequestInterface,
 		if errCsr != nil {
 			log.Errorf("failed to clean up CSR (%v): %v", csrName, err)
 		}
-		return nil, nil, err
+		return nil, nil, fmt.Errorf("%w (%v): %v", ErrCSRApproval, csrName, err)
 	}
 	log.Debugf("CSR (%v) is approved: %v", csrName, reqApproval)
 
@@ -274,8 +283,8 @@ func readSignedCertificate(certClient certclient.CertificateSigningRequestInterf
 				}
 				return nil, nil, err
 			}
-			if r.Status.Certificate != nil {
-				// Certificate is ready
+			if r.Status.Certificate != nil || deniedOrFailed(r) != nil {
+				// Certificate is ready, or will never be
 				reqSigned = r
 				break
 			}
@@ -293,18 +302,16 @@ func readSignedCertificate(certClient certclient.CertificateSigningRequestInterf
 	}
 	if reqSigned.Status.Certificate == nil {
 		log.Errorf("failed to read the certificate for CSR (%v), nil cert", csrName)
-		// Output the first CertificateDenied condition, if any, in the status
-		for _, c := range reqSigned.Status.Conditions {
-			if c.Type == cert.CertificateDenied {
-				log.Errorf("CertificateDenied, name: %v, uid: %v, cond-type: %v, cond: %s",
-					reqSigned.Name, reqSigned.UID, c.Type, c.String())
-				break
-			}
-		}
 		errCsr := cleanUpCertGen(certClient, csrName)
 		if errCsr != nil {
 			log.Errorf("failed to clean up CSR (%v): %v", csrName, errCsr)
 		}
+		// Output the first CertificateDenied or CertificateFailed condition, if any, in the status
+		if c := deniedOrFailed(reqSigned); c != nil {
+			log.Errorf("%v, name: %v, uid: %v, cond-type: %v, cond: %s",
+				c.Type, reqSigned.Name, reqSigned.UID, c.Type, c.String())
+			return nil, nil, fmt.Errorf("%w (%v): %s", ErrCSRDenied, csrName, c.Message)
+		}
 		return nil, nil, fmt.Errorf("failed to read the certificate for CSR (%v), nil cert", csrName)
 	}
 
@@ -381,9 +388,17 @@ func readSignedCsr(certClient certclient.CertificateSigningRequestInterface, csr
 	timer := time.After(timeout)
 	for {
 		select {
-		case r := <-watcher.ResultChan():
-			reqSigned := r.Object.(*cert.CertificateSigningRequest)
-			if reqSigned.Status.Certificate != nil {
+		case r, ok := <-watcher.ResultChan():
+			if !ok {
+				log.Errorf("watch of CSR %v closed", csrName)
+				return nil
+			}
+			reqSigned, ok := r.Object.(*cert.CertificateSigningRequest)
+			if !ok {
+				continue
+			}
+			// A denied or failed CSR is never signed, so there is no need to wait for its certificate.
+			if reqSigned.Status.Certificate != nil || deniedOrFailed(reqSigned) != nil {
 				return reqSigned
 			}
 		case <-timer:
@@ -393,6 +408,16 @@ func readSignedCsr(certClient certclient.CertificateSigningRequestInterface, csr
 	}
 }
 
+// deniedOrFailed returns the first CertificateDenied or CertificateFailed condition of the CSR, if any.
+func deniedOrFailed(r *cert.CertificateSigningRequest) *cert.CertificateSigningRequestCondition {
+	for i, c := range r.Status.Conditions {
+		if c.Type == cert.CertificateDenied || c.Type == cert.CertificateFailed {
+			return &r.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
 // Clean up the CSR
 func cleanUpCertGen(certClient certclient.CertificateSigningRequestInterface, csrName string) error {
 	// Delete CSR
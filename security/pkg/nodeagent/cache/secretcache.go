@@ -17,6 +17,8 @@ package cache
 
 import (
 	"bytes"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
@@ -112,6 +114,11 @@ type SecretManagerClient struct {
 	// Dynamically configured Trust Bundle
 	configTrustBundle []byte
 
+	// crlMutex protects crlIssuer
+	crlMutex sync.Mutex
+	// crlIssuer is the raw certificate of the issuer the served certificate revocation list was verified against.
+	crlIssuer []byte
+
 	// queue maintains all certificate rotation events that need to be triggered when they are about to expire
 	queue queue.Delayed
 	stop  chan struct{}
@@ -261,6 +268,14 @@ func (sc *SecretManagerClient) GenerateSecret(resourceName string) (secret *secu
 		}
 		sc.outputMutex.Unlock()
 	}()
+	// Attach the certificate revocation list to the workload trust anchor, so that revoked peer certificates are
+	// rejected by the proxy on both inbound and outbound mTLS.
+	defer func() {
+		if secret == nil || err != nil || resourceName != security.RootCertReqResourceName {
+			return
+		}
+		secret.CRL = sc.getCRL()
+	}()
 
 	// First try to generate secret from file.
 	if sdsFromFile, ns, err := sc.generateFileSecret(resourceName); sdsFromFile {
@@ -308,6 +323,11 @@ func (sc *SecretManagerClient) GenerateSecret(resourceName string) (secret *secu
 			// We store the oldRoot only for comparison and not for serving
 			sc.cache.SetRoot(ns.RootCert)
 			sc.CallUpdateCallback(security.RootCertReqResourceName)
+		} else if sc.crlIssuerChanged() {
+			// The certificate revocation list is served with the trust anchor, and must be verified against the
+			// issuer of the new certificate.
+			cacheLog.Info("Certificate issuer has changed, refreshing certificate revocation list")
+			sc.CallUpdateCallback(security.RootCertReqResourceName)
 		}
 	}
 
@@ -510,11 +530,16 @@ func (sc *SecretManagerClient) generateNewSecret(resourceName string) (*security
 	}
 
 	cacheLog.Debugf("constructed host name for CSR: %s", csrHostName.String())
+	rsaKeySize := keySize
+	if sc.configOptions.WorkloadRSAKeySize > 0 {
+		rsaKeySize = sc.configOptions.WorkloadRSAKeySize
+	}
 	options := pkiutil.CertOptions{
 		Host:       csrHostName.String(),
-		RSAKeySize: keySize,
+		RSAKeySize: rsaKeySize,
 		PKCS8Key:   sc.configOptions.Pkcs8Keys,
 		ECSigAlg:   pkiutil.SupportedECSignatureAlgorithms(sc.configOptions.ECCSigAlg),
+		ECCCurve:   pkiutil.SupportedEllipticCurves(sc.configOptions.ECCCurve),
 	}
 
 	// Generate the cert/key, send CSR to CA.
@@ -603,7 +628,7 @@ func (sc *SecretManagerClient) handleFileWatch() {
 				// Trigger callbacks for all resources referencing this file. This is practically always
 				// a single resource.
 				for k := range resources {
-					if k.Filename == resource {
+					if k.Filename == resource || k.Filename == filepath.Dir(resource) {
 						sc.CallUpdateCallback(k.ResourceName)
 					}
 				}
@@ -667,6 +692,94 @@ func concatCerts(certsPEM []string) []byte {
 	return certChain.Bytes()
 }
 
+// getCRL returns the certificate revocation list of the CA issuing the workload certificate, or nil if there is
+// none, the issuer is not known yet or the list is not signed by it.
+func (sc *SecretManagerClient) getCRL() []byte {
+	crlPath := sc.configOptions.CRLFilePath
+	if crlPath == "" {
+		return nil
+	}
+	// Watch the directory rather than the file, so that a list created after the agent started is picked up too.
+	if dir := filepath.Dir(crlPath); file.Exists(dir) {
+		sc.addFileWatcher(dir, security.RootCertReqResourceName)
+	}
+	issuer := sc.workloadCertIssuer()
+	sc.crlMutex.Lock()
+	sc.crlIssuer = nil
+	if issuer != nil {
+		sc.crlIssuer = issuer.Raw
+	}
+	sc.crlMutex.Unlock()
+
+	crl, err := ioutil.ReadFile(crlPath)
+	if err != nil || len(bytes.TrimSpace(crl)) == 0 {
+		return nil
+	}
+	if issuer == nil {
+		cacheLog.Debugf("issuer of the workload certificate is unknown, not serving certificate revocation list %s yet", crlPath)
+		return nil
+	}
+	// An invalid CRL would get the whole trust anchor rejected by the proxy, breaking all mTLS traffic.
+	if err := pkiutil.VerifyCRL(crl, issuer); err != nil {
+		cacheLog.Errorf("ignoring certificate revocation list %s: %v", crlPath, err)
+		return nil
+	}
+	return crl
+}
+
+// crlIssuerChanged returns true if a certificate revocation list is configured and the issuer of the workload
+// certificate differs from the one the served list was verified against.
+func (sc *SecretManagerClient) crlIssuerChanged() bool {
+	if sc.configOptions.CRLFilePath == "" {
+		return false
+	}
+	var raw []byte
+	if issuer := sc.workloadCertIssuer(); issuer != nil {
+		raw = issuer.Raw
+	}
+	sc.crlMutex.Lock()
+	defer sc.crlMutex.Unlock()
+	return !bytes.Equal(raw, sc.crlIssuer)
+}
+
+// workloadCertIssuer returns the certificate of the CA which signed the cached workload certificate, or nil if it
+// is not known.
+func (sc *SecretManagerClient) workloadCertIssuer() *x509.Certificate {
+	item := sc.cache.GetWorkload()
+	if item == nil {
+		return nil
+	}
+	chain := parsePemCerts(item.CertificateChain)
+	if len(chain) == 0 {
+		return nil
+	}
+	candidates := append(chain[1:], parsePemCerts(item.RootCert)...)
+	for _, c := range candidates {
+		if chain[0].CheckSignatureFrom(c) == nil {
+			return c
+		}
+	}
+	return nil
+}
+
+// parsePemCerts returns the certificates of a PEM bundle, skipping the ones which cannot be parsed.
+func parsePemCerts(pemCerts []byte) []*x509.Certificate {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, pemCerts = pem.Decode(pemCerts)
+		if block == nil {
+			return certs
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+			certs = append(certs, cert)
+		}
+	}
+}
+
 func (sc *SecretManagerClient) getConfigTrustBundle() []byte {
 	sc.configTrustBundleMutex.RLock()
 	defer sc.configTrustBundleMutex.RUnlock()
@@ -16,6 +16,8 @@ package cache
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/pem"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -26,12 +28,15 @@ import (
 	"testing"
 	"time"
 
+	"go.uber.org/atomic"
+
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/file"
 	"istio.io/istio/pkg/security"
 	"istio.io/istio/pkg/test/util/retry"
 	"istio.io/istio/pkg/testcerts"
 	"istio.io/istio/security/pkg/nodeagent/caclient/providers/mock"
+	pkiutil "istio.io/istio/security/pkg/pki/util"
 	"istio.io/istio/tests/util/leak"
 	"istio.io/pkg/log"
 )
@@ -594,3 +599,109 @@ func TestProxyConfigAnchors(t *testing.T) {
 		RootCert:     rootCert,
 	})
 }
+
+func TestRootCertWithCRL(t *testing.T) {
+	fakeCACli, err := mock.NewMockCAClient(time.Hour)
+	if err != nil {
+		t.Fatalf("Error creating Mock CA client: %v", err)
+	}
+	dir := t.TempDir()
+	crlPath := filepath.Join(dir, "ca-crl.pem")
+	var rootPushes atomic.Int32
+	sc := createCache(t, fakeCACli, func(resourceName string) {
+		if resourceName == security.RootCertReqResourceName {
+			rootPushes.Inc()
+		}
+	}, security.Options{CRLFilePath: crlPath})
+
+	generateCRL := func() []byte {
+		t.Helper()
+		// The mock CA issues workload certificates with the sample intermediate CA.
+		bundle, err := pkiutil.NewVerifiedKeyCertBundleFromFile("../../../../samples/certs/ca-cert.pem",
+			"../../../../samples/certs/ca-key.pem", "../../../../samples/certs/cert-chain.pem", "../../../../samples/certs/root-cert.pem")
+		if err != nil {
+			t.Fatal(err)
+		}
+		issuer, key, _, _ := bundle.GetAll()
+		crl, err := issuer.CreateCRL(rand.Reader, key, nil, time.Now(), time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crl})
+	}
+	getCRL := func() []byte {
+		t.Helper()
+		gotSecret, err := sc.GenerateSecret(security.RootCertReqResourceName)
+		if err != nil {
+			t.Fatalf("Failed to get secrets: %v", err)
+		}
+		return gotSecret.CRL
+	}
+
+	// The issuer of the workload certificate is unknown until it is generated.
+	crl := generateCRL()
+	if err := ioutil.WriteFile(crlPath, crl, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := getCRL(); got != nil {
+		t.Errorf("Got unexpected CRL: %v", string(got))
+	}
+	// The CRL is only used along with the workload trust anchor.
+	gotSecret, err := sc.GenerateSecret(security.WorkloadKeyCertResourceName)
+	if err != nil {
+		t.Fatalf("Failed to get secrets: %v", err)
+	}
+	if gotSecret.CRL != nil {
+		t.Errorf("Got unexpected CRL: %v", string(gotSecret.CRL))
+	}
+	// The trust anchor is pushed again once the issuer is known.
+	if rootPushes.Load() == 0 {
+		t.Errorf("expected the trust anchor to be pushed once the issuer is known")
+	}
+	if got := getCRL(); !bytes.Equal(got, crl) {
+		t.Errorf("Got unexpected CRL. Got: %v\n want: %v", string(got), string(crl))
+	}
+
+	// No CRL file
+	if err := os.Remove(crlPath); err != nil {
+		t.Fatal(err)
+	}
+	if got := getCRL(); got != nil {
+		t.Errorf("Got unexpected CRL: %v", string(got))
+	}
+
+	// Invalid CRL file
+	if err := ioutil.WriteFile(crlPath, []byte("invalid"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := getCRL(); got != nil {
+		t.Errorf("Got unexpected CRL: %v", string(got))
+	}
+
+	// CRL not signed by the issuer of the workload certificate
+	otherCRL, err := ioutil.ReadFile("./testdata/ca-crl.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(crlPath, otherCRL, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := getCRL(); got != nil {
+		t.Errorf("Got unexpected CRL: %v", string(got))
+	}
+
+	// Changes to the CRL file push the trust anchor, the directory being watched.
+	pushes := rootPushes.Load()
+	if err := ioutil.WriteFile(crlPath, crl, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	retry.UntilSuccessOrFail(t, func() error {
+		if rootPushes.Load() == pushes {
+			return fmt.Errorf("trust anchor not pushed on CRL change")
+		}
+		return nil
+	}, retry.Timeout(time.Second*5))
+	if got := getCRL(); !bytes.Equal(got, crl) {
+		t.Errorf("Got unexpected CRL. Got: %v\n want: %v", string(got), string(crl))
+	}
+}
@@ -221,6 +221,13 @@ func toEnvoySecret(s *security.SecretItem) *tls.Secret {
 				},
 			},
 		}
+		if len(s.CRL) > 0 {
+			secret.GetValidationContext().Crl = &core.DataSource{
+				Specifier: &core.DataSource_InlineBytes{
+					InlineBytes: s.CRL,
+				},
+			}
+		}
 	} else {
 		secret.Type = &tls.Secret_TlsCertificate{
 			TlsCertificate: &tls.TlsCertificate{
@@ -367,6 +367,9 @@ func (ca *IstioCA) sign(csrPEM []byte, subjectIDs []string, requestedLifetime ti
 	if err != nil {
 		return nil, caerror.NewError(caerror.CSRError, err)
 	}
+	if err := util.ValidateCSRPublicKey(csr); err != nil {
+		return nil, caerror.NewError(caerror.CSRError, err)
+	}
 
 	lifetime := requestedLifetime
 	// If the requested requestedLifetime is non-positive, apply the default TTL.
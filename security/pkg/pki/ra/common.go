@@ -17,7 +17,9 @@ import (
 	"fmt"
 	"time"
 
+	"google.golang.org/grpc"
 	certificatesv1beta1 "k8s.io/client-go/kubernetes/typed/certificates/v1beta1"
+	corelisters "k8s.io/client-go/listers/core/v1"
 
 	raerror "istio.io/istio/security/pkg/pki/error"
 	"istio.io/istio/security/pkg/pki/util"
@@ -44,12 +46,39 @@ type IstioRAOptions struct {
 	CaCertFile string
 	// CaSigner : To indicate custom CA Signer name when using external K8s CA
 	CaSigner string
+	// NamespaceCaSigners : CA Signer name of each namespace when using external K8s CA.
+	// Namespaces not listed here use CaSigner.
+	NamespaceCaSigners map[string]string
+	// AllowedCaSigners : CA Signer names that can be selected by CaSignerAnnotation. The annotation is ignored
+	// if it selects another signer, so that workloads cannot pick a signer they are not meant to use.
+	AllowedCaSigners []string
+	// NamespaceLister : Lister of the namespaces, used to select the CA Signer by CaSignerAnnotation
+	NamespaceLister corelisters.NamespaceLister
+	// ServiceAccountLister : Lister of the service accounts, used to select the CA Signer by CaSignerAnnotation
+	ServiceAccountLister corelisters.ServiceAccountLister
 	// VerifyAppendCA : Whether to use caCertFile containing CA root cert to verify and append to signed cert-chain
 	VerifyAppendCA bool
 	// K8sClient : K8s API client
 	K8sClient certificatesv1beta1.CertificatesV1beta1Interface
 	// TrustDomain
 	TrustDomain string
+	// CaAddress : Address of the external CA when using the Istio CA gRPC API
+	CaAddress string
+	// NamespaceCaAddresses : Address of the external CA of each namespace when using the Istio CA gRPC API.
+	// Namespaces not listed here use CaAddress.
+	NamespaceCaAddresses map[string]string
+	// HealthCheckInterval : Interval of the health checks of the external CAs when using the Istio CA gRPC API
+	HealthCheckInterval time.Duration
+	// DisableHealthCheck : Whether the health checks of the external CAs are disabled when using the Istio CA
+	// gRPC API, for the external CAs not implementing the gRPC health checking protocol
+	DisableHealthCheck bool
+	// CaTokenFile : File containing the token sent as bearer token to the external CAs when using the Istio CA
+	// gRPC API. It is read for each request, so that the token can be rotated.
+	CaTokenFile string
+	// ClusterID : Cluster ID sent to the external CAs when using the Istio CA gRPC API
+	ClusterID string
+	// DialOptions : gRPC dial options used to connect to the external CAs when using the Istio CA gRPC API
+	DialOptions []grpc.DialOption
 }
 
 const (
@@ -61,6 +90,10 @@ const (
 
 	// DefaultExtCACertDir : Location of external CA certificate
 	DefaultExtCACertDir string = "./etc/external-ca-cert"
+
+	// CaSignerAnnotation : Annotation of namespaces and service accounts selecting the CA Signer name
+	// of their workloads when using external K8s CA
+	CaSignerAnnotation = "ca.istio.io/signer-name"
 )
 
 // ValidateCSR : Validate all SAN extensions in csrPEM match authenticated identities
@@ -99,6 +132,13 @@ func NewIstioRA(opts *IstioRAOptions) (RegistrationAuthority, error) {
 		}
 		return istioRA, err
 	}
+	if opts.ExternalCAType == ExtCAGrpc {
+		istioRA, err := NewGrpcRA(opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create a gRPC CA: %v", err)
+		}
+		return istioRA, err
+	}
 	return nil, fmt.Errorf("invalid CA Name %s", opts.ExternalCAType)
 }
 
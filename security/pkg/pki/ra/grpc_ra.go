@@ -0,0 +1,311 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ra
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"go.uber.org/atomic"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+
+	pb "istio.io/api/security/v1alpha1"
+	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/spiffe"
+	raerror "istio.io/istio/security/pkg/pki/error"
+	"istio.io/istio/security/pkg/pki/util"
+	"istio.io/pkg/log"
+)
+
+var pkiRaLog = log.RegisterScope("pkira", "Istiod RA log", 0)
+
+const (
+	// DefaultHealthCheckInterval : Default interval of the health checks of the external CA backends
+	DefaultHealthCheckInterval = 10 * time.Second
+
+	// grpcRequestTimeout is the timeout of the signing and health check requests sent to the external CA.
+	grpcRequestTimeout = 10 * time.Second
+)
+
+// grpcBackend is an external CA serving the Istio CA gRPC API.
+type grpcBackend struct {
+	address string
+	conn    *grpc.ClientConn
+	client  pb.IstioCertificateServiceClient
+	health  healthpb.HealthClient
+	healthy *atomic.Bool
+}
+
+// GrpcRA integrated with external CAs using the Istio CA gRPC API. This allows delegating the signing of
+// workload certificates to any backend (Vault, cloud private CAs, corporate PKI...) through a thin adapter
+// implementing IstioCertificateService, without changes to the CA server. Each namespace may be served by
+// a different backend, and the backends are health checked, unless disabled, so that signing fails fast when
+// unavailable.
+type GrpcRA struct {
+	keyCertBundle util.KeyCertBundle
+	raOpts        *IstioRAOptions
+
+	defaultBackend    *grpcBackend
+	namespaceBackends map[string]*grpcBackend
+}
+
+// NewGrpcRA : Create a RA that interfaces with external CAs using the Istio CA gRPC API
+func NewGrpcRA(raOpts *IstioRAOptions) (*GrpcRA, error) {
+	keyCertBundle, err := util.NewKeyCertBundleWithRootCertFromFile(raOpts.CaCertFile)
+	if err != nil {
+		return nil, raerror.NewError(raerror.CAInitFail, fmt.Errorf("error processing Certificate Bundle for gRPC RA"))
+	}
+	if raOpts.CaAddress == "" && len(raOpts.NamespaceCaAddresses) == 0 {
+		return nil, raerror.NewError(raerror.CAIllegalConfig, fmt.Errorf("no external CA address is configured"))
+	}
+	istioRA := &GrpcRA{
+		keyCertBundle:     keyCertBundle,
+		raOpts:            raOpts,
+		namespaceBackends: map[string]*grpcBackend{},
+	}
+	// Backends are shared by address, so that namespaces using the same CA share the connection.
+	backends := map[string]*grpcBackend{}
+	getBackend := func(address string) (*grpcBackend, error) {
+		if b, f := backends[address]; f {
+			return b, nil
+		}
+		conn, err := grpc.Dial(address, raOpts.DialOptions...)
+		if err != nil {
+			return nil, raerror.NewError(raerror.CAInitFail, fmt.Errorf("failed to connect to external CA %s: %v", address, err))
+		}
+		b := &grpcBackend{
+			address: address,
+			conn:    conn,
+			client:  pb.NewIstioCertificateServiceClient(conn),
+			health:  healthpb.NewHealthClient(conn),
+			// Assume the backend is healthy until the first health check completes.
+			healthy: atomic.NewBool(true),
+		}
+		backends[address] = b
+		return b, nil
+	}
+	if raOpts.CaAddress != "" {
+		if istioRA.defaultBackend, err = getBackend(raOpts.CaAddress); err != nil {
+			istioRA.close()
+			return nil, err
+		}
+	}
+	for ns, address := range raOpts.NamespaceCaAddresses {
+		if istioRA.namespaceBackends[ns], err = getBackend(address); err != nil {
+			istioRA.close()
+			return nil, err
+		}
+	}
+	return istioRA, nil
+}
+
+// Run health checks the external CA backends until the stop channel is closed.
+func (r *GrpcRA) Run(stop <-chan struct{}) {
+	if r.raOpts.DisableHealthCheck {
+		<-stop
+		r.close()
+		return
+	}
+	interval := r.raOpts.HealthCheckInterval
+	if interval <= 0 {
+		interval = DefaultHealthCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		r.checkHealth()
+		select {
+		case <-stop:
+			r.close()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *GrpcRA) checkHealth() {
+	for _, b := range r.backends() {
+		ctx, cancel := context.WithTimeout(context.Background(), grpcRequestTimeout)
+		resp, err := b.health.Check(ctx, &healthpb.HealthCheckRequest{})
+		cancel()
+		healthy := err == nil && resp.GetStatus() == healthpb.HealthCheckResponse_SERVING
+		if b.healthy.Swap(healthy) != healthy {
+			if healthy {
+				pkiRaLog.Infof("external CA %s is healthy", b.address)
+			} else {
+				pkiRaLog.Warnf("external CA %s is unhealthy (status %v): %v", b.address, resp.GetStatus(), err)
+			}
+		}
+	}
+}
+
+// backends returns the distinct backends of the RA.
+func (r *GrpcRA) backends() []*grpcBackend {
+	seen := map[*grpcBackend]struct{}{}
+	var res []*grpcBackend
+	add := func(b *grpcBackend) {
+		if _, f := seen[b]; b == nil || f {
+			return
+		}
+		seen[b] = struct{}{}
+		res = append(res, b)
+	}
+	add(r.defaultBackend)
+	for _, b := range r.namespaceBackends {
+		add(b)
+	}
+	return res
+}
+
+func (r *GrpcRA) close() {
+	for _, b := range r.backends() {
+		_ = b.conn.Close()
+	}
+}
+
+// backendFor selects the backend for the namespace of the subject identities.
+func (r *GrpcRA) backendFor(subjectIDs []string) (*grpcBackend, error) {
+	for _, id := range subjectIDs {
+		identity, err := spiffe.ParseIdentity(id)
+		if err != nil {
+			continue
+		}
+		if b, f := r.namespaceBackends[identity.Namespace]; f {
+			return b, nil
+		}
+	}
+	if r.defaultBackend == nil {
+		return nil, raerror.NewError(raerror.CAIllegalConfig, fmt.Errorf(
+			"no external CA is configured for identities %v", subjectIDs))
+	}
+	return r.defaultBackend, nil
+}
+
+func (r *GrpcRA) grpcSign(csrPEM []byte, subjectIDs []string, requestedLifetime time.Duration, forCA bool) ([]string, error) {
+	lifetime, err := preSign(r.raOpts, csrPEM, subjectIDs, requestedLifetime, forCA)
+	if err != nil {
+		return nil, err
+	}
+	b, err := r.backendFor(subjectIDs)
+	if err != nil {
+		return nil, err
+	}
+	if !b.healthy.Load() {
+		return nil, raerror.NewError(raerror.CANotReady, fmt.Errorf("external CA %s is unhealthy", b.address))
+	}
+	md, err := r.requestMetadata()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(metadata.NewOutgoingContext(context.Background(), md), grpcRequestTimeout)
+	defer cancel()
+	resp, err := b.client.CreateCertificate(ctx, &pb.IstioCertificateRequest{
+		Csr:              string(csrPEM),
+		ValidityDuration: int64(lifetime.Seconds()),
+	})
+	if err != nil {
+		return nil, raerror.NewError(raerror.CertGenError, fmt.Errorf("external CA %s failed to sign the CSR: %v", b.address, err))
+	}
+	// The chain contains at least the leaf and the root certificates.
+	if len(resp.CertChain) <= 1 {
+		return nil, raerror.NewError(raerror.CertGenError, fmt.Errorf("external CA %s returned an invalid cert chain", b.address))
+	}
+	if r.raOpts.VerifyAppendCA {
+		if err := verifyCertChain(resp.CertChain, r.keyCertBundle.GetRootCertPem()); err != nil {
+			return nil, raerror.NewError(raerror.CertGenError, fmt.Errorf("external CA %s returned an untrusted cert chain: %v", b.address, err))
+		}
+	}
+	return resp.CertChain, nil
+}
+
+// requestMetadata returns the metadata authenticating istiod to the external CAs.
+func (r *GrpcRA) requestMetadata() (metadata.MD, error) {
+	md := metadata.MD{}
+	if r.raOpts.ClusterID != "" {
+		md.Set("ClusterID", r.raOpts.ClusterID)
+	}
+	if r.raOpts.CaTokenFile != "" {
+		token, err := ioutil.ReadFile(r.raOpts.CaTokenFile)
+		if err != nil {
+			return nil, raerror.NewError(raerror.CANotReady, fmt.Errorf("failed to read the token for the external CA: %v", err))
+		}
+		md.Set("authorization", security.BearerTokenPrefix+strings.TrimSpace(string(token)))
+	}
+	return md, nil
+}
+
+// verifyCertChain verifies that the leaf certificate of the chain is trusted by the given root certificate.
+func verifyCertChain(certChain []string, rootCertPem []byte) error {
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(rootCertPem) {
+		return fmt.Errorf("failed to parse the root certificate")
+	}
+	leaf, err := util.ParsePemEncodedCertificate([]byte(certChain[0]))
+	if err != nil {
+		return err
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certChain[1:] {
+		intermediates.AppendCertsFromPEM([]byte(cert))
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err
+}
+
+// Sign takes a PEM-encoded CSR, subject IDs and lifetime, and returns a certificate signed by the external CA,
+// followed by the intermediate certificates returned by the external CA, if any.
+func (r *GrpcRA) Sign(csrPEM []byte, subjectIDs []string, requestedLifetime time.Duration, forCA bool) ([]byte, error) {
+	certChain, err := r.grpcSign(csrPEM, subjectIDs, requestedLifetime, forCA)
+	if err != nil {
+		return nil, err
+	}
+	// The root certificate is distributed separately from the key cert bundle.
+	return []byte(joinPem(certChain[:len(certChain)-1])), nil
+}
+
+// SignWithCertChain is similar to Sign but returns the leaf cert and the entire cert chain.
+func (r *GrpcRA) SignWithCertChain(csrPEM []byte, subjectIDs []string, ttl time.Duration, forCA bool) ([]byte, error) {
+	certChain, err := r.grpcSign(csrPEM, subjectIDs, ttl, forCA)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(joinPem(certChain)), nil
+}
+
+// GetCAKeyCertBundle returns the KeyCertBundle for the CA.
+func (r *GrpcRA) GetCAKeyCertBundle() util.KeyCertBundle {
+	return r.keyCertBundle
+}
+
+func joinPem(certs []string) string {
+	var sb strings.Builder
+	for _, cert := range certs {
+		sb.WriteString(cert)
+		if !strings.HasSuffix(cert, "\n") {
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,199 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ra
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/security/pkg/nodeagent/test/mock"
+	raerror "istio.io/istio/security/pkg/pki/error"
+	pkiutil "istio.io/istio/security/pkg/pki/util"
+)
+
+func createFakeCsrForNamespace(t *testing.T, ns string) ([]byte, string) {
+	t.Helper()
+	id := spiffe.Identity{TrustDomain: "cluster.local", Namespace: ns, ServiceAccount: "default"}.String()
+	csrPEM, _, err := pkiutil.GenCSR(pkiutil.CertOptions{
+		Host:       id,
+		RSAKeySize: 2048,
+	})
+	if err != nil {
+		t.Fatalf("failed to create CSR: %v", err)
+	}
+	return csrPEM, id
+}
+
+func TestGrpcRA(t *testing.T) {
+	defaultCA, err := mock.NewCAServer(0)
+	if err != nil {
+		t.Fatalf("failed to create CA server: %v", err)
+	}
+	defer defaultCA.GRPCServer.Stop()
+	fooCA, err := mock.NewCAServer(0)
+	if err != nil {
+		t.Fatalf("failed to create CA server: %v", err)
+	}
+	defer fooCA.GRPCServer.Stop()
+
+	defaultRoot := defaultCA.KeyCertBundle.GetRootCertPem()
+	fooRoot := fooCA.KeyCertBundle.GetRootCertPem()
+	caCertFile := filepath.Join(t.TempDir(), "root-cert.pem")
+	if err := ioutil.WriteFile(caCertFile, append(append([]byte{}, defaultRoot...), fooRoot...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	istioRA, err := NewIstioRA(&IstioRAOptions{
+		ExternalCAType:       ExtCAGrpc,
+		DefaultCertTTL:       time.Hour,
+		MaxCertTTL:           24 * time.Hour,
+		CaCertFile:           caCertFile,
+		VerifyAppendCA:       true,
+		CaAddress:            defaultCA.URL,
+		NamespaceCaAddresses: map[string]string{"foo": fooCA.URL},
+		DialOptions:          []grpc.DialOption{grpc.WithInsecure()},
+	})
+	if err != nil {
+		t.Fatalf("failed to create gRPC RA: %v", err)
+	}
+	grpcRA := istioRA.(*GrpcRA)
+	defer grpcRA.close()
+
+	cases := []struct {
+		ns       string
+		wantRoot []byte
+	}{
+		{ns: "foo", wantRoot: fooRoot},
+		{ns: "bar", wantRoot: defaultRoot},
+	}
+	for _, tc := range cases {
+		t.Run(tc.ns, func(t *testing.T) {
+			csrPEM, id := createFakeCsrForNamespace(t, tc.ns)
+			certChain, err := istioRA.SignWithCertChain(csrPEM, []string{id}, 0, false)
+			if err != nil {
+				t.Fatalf("failed to sign CSR: %v", err)
+			}
+			if !bytes.Contains(certChain, tc.wantRoot) {
+				t.Errorf("cert chain is not signed by the CA of namespace %s", tc.ns)
+			}
+			cert, err := istioRA.Sign(csrPEM, []string{id}, 0, false)
+			if err != nil {
+				t.Fatalf("failed to sign CSR: %v", err)
+			}
+			if bytes.Contains(cert, tc.wantRoot) {
+				t.Errorf("signed cert unexpectedly includes the root certificate")
+			}
+		})
+	}
+
+	t.Run("unhealthy backend", func(t *testing.T) {
+		fooCA.GRPCServer.Stop()
+		grpcRA.checkHealth()
+
+		csrPEM, id := createFakeCsrForNamespace(t, "foo")
+		_, err := istioRA.Sign(csrPEM, []string{id}, 0, false)
+		if err == nil {
+			t.Fatalf("expected signing to fail with an unhealthy backend")
+		}
+		if e, ok := err.(*raerror.Error); !ok || e.ErrorType() != "CA_NOT_READY" {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		// Other namespaces are not affected.
+		csrPEM, id = createFakeCsrForNamespace(t, "bar")
+		if _, err := istioRA.Sign(csrPEM, []string{id}, 0, false); err != nil {
+			t.Errorf("failed to sign CSR: %v", err)
+		}
+	})
+}
+
+func TestGrpcRAUntrustedChain(t *testing.T) {
+	server, err := mock.NewCAServer(0)
+	if err != nil {
+		t.Fatalf("failed to create CA server: %v", err)
+	}
+	defer server.GRPCServer.Stop()
+
+	istioRA, err := NewIstioRA(&IstioRAOptions{
+		ExternalCAType: ExtCAGrpc,
+		DefaultCertTTL: time.Hour,
+		MaxCertTTL:     24 * time.Hour,
+		CaCertFile:     TestCACertFile,
+		VerifyAppendCA: true,
+		CaAddress:      server.URL,
+		DialOptions:    []grpc.DialOption{grpc.WithInsecure()},
+	})
+	if err != nil {
+		t.Fatalf("failed to create gRPC RA: %v", err)
+	}
+	defer istioRA.(*GrpcRA).close()
+
+	csrPEM, id := createFakeCsrForNamespace(t, "default")
+	if _, err := istioRA.Sign(csrPEM, []string{id}, 0, false); err == nil {
+		t.Errorf("expected signing to fail with an untrusted cert chain")
+	}
+}
+
+func TestGrpcRARequestMetadata(t *testing.T) {
+	var got metadata.MD
+	interceptor := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		got, _ = metadata.FromIncomingContext(ctx)
+		return handler(ctx, req)
+	}
+	server, err := mock.NewCAServer(0, grpc.UnaryInterceptor(interceptor))
+	if err != nil {
+		t.Fatalf("failed to create CA server: %v", err)
+	}
+	defer server.GRPCServer.Stop()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("fake-token\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	istioRA, err := NewIstioRA(&IstioRAOptions{
+		ExternalCAType:     ExtCAGrpc,
+		DefaultCertTTL:     time.Hour,
+		MaxCertTTL:         24 * time.Hour,
+		CaCertFile:         TestCACertFile,
+		CaAddress:          server.URL,
+		DisableHealthCheck: true,
+		CaTokenFile:        tokenFile,
+		ClusterID:          "Kubernetes",
+		DialOptions:        []grpc.DialOption{grpc.WithInsecure()},
+	})
+	if err != nil {
+		t.Fatalf("failed to create gRPC RA: %v", err)
+	}
+	defer istioRA.(*GrpcRA).close()
+
+	csrPEM, id := createFakeCsrForNamespace(t, "default")
+	if _, err := istioRA.Sign(csrPEM, []string{id}, 0, false); err != nil {
+		t.Fatalf("failed to sign CSR: %v", err)
+	}
+	if auth := got.Get("authorization"); len(auth) != 1 || auth[0] != "Bearer fake-token" {
+		t.Errorf("unexpected authorization metadata %v", auth)
+	}
+	if cluster := got.Get("ClusterID"); len(cluster) != 1 || cluster[0] != "Kubernetes" {
+		t.Errorf("unexpected cluster ID metadata %v", cluster)
+	}
+}
@@ -21,6 +21,7 @@ import (
 	cert "k8s.io/api/certificates/v1beta1"
 	certclient "k8s.io/client-go/kubernetes/typed/certificates/v1beta1"
 
+	"istio.io/istio/pkg/spiffe"
 	"istio.io/istio/security/pkg/k8s/chiron"
 	raerror "istio.io/istio/security/pkg/pki/error"
 	"istio.io/istio/security/pkg/pki/util"
@@ -47,9 +48,53 @@ func NewKubernetesRA(raOpts *IstioRAOptions) (*KubernetesRA, error) {
 	return istioRA, nil
 }
 
-func (r *KubernetesRA) kubernetesSign(csrPEM []byte, csrName string, caCertFile string) ([]byte, error) {
+// signerFor selects the K8s CA signer of the subject identities. The signer is selected, in order of precedence,
+// by the CaSignerAnnotation of the service account, the CaSignerAnnotation of the namespace, the signer
+// configured for the namespace in NamespaceCaSigners, and finally CaSigner. The annotations are only honored
+// for the signers of AllowedCaSigners.
+func (r *KubernetesRA) signerFor(subjectIDs []string) string {
+	for _, id := range subjectIDs {
+		identity, err := spiffe.ParseIdentity(id)
+		if err != nil {
+			continue
+		}
+		if r.raOpts.ServiceAccountLister != nil {
+			sa, err := r.raOpts.ServiceAccountLister.ServiceAccounts(identity.Namespace).Get(identity.ServiceAccount)
+			if err == nil && r.allowedSigner(sa.Annotations[CaSignerAnnotation], "service account "+identity.Namespace+"/"+sa.Name) {
+				return sa.Annotations[CaSignerAnnotation]
+			}
+		}
+		if r.raOpts.NamespaceLister != nil {
+			ns, err := r.raOpts.NamespaceLister.Get(identity.Namespace)
+			if err == nil && r.allowedSigner(ns.Annotations[CaSignerAnnotation], "namespace "+ns.Name) {
+				return ns.Annotations[CaSignerAnnotation]
+			}
+		}
+		if signer, f := r.raOpts.NamespaceCaSigners[identity.Namespace]; f {
+			return signer
+		}
+	}
+	return r.raOpts.CaSigner
+}
+
+// allowedSigner returns true if the signer selected by the CaSignerAnnotation of the resource is allowed.
+func (r *KubernetesRA) allowedSigner(signer string, resource string) bool {
+	if signer == "" {
+		return false
+	}
+	for _, allowed := range r.raOpts.AllowedCaSigners {
+		if signer == allowed {
+			return true
+		}
+	}
+	pkiRaLog.Warnf("ignored signer %s selected by the %s annotation of %s: not an allowed signer", signer, CaSignerAnnotation, resource)
+	return false
+}
+
+func (r *KubernetesRA) kubernetesSign(csrPEM []byte, csrName string, caSigner string, caCertFile string) ([]byte, error) {
+	start := time.Now()
 	csrSpec := &cert.CertificateSigningRequestSpec{
-		SignerName: &r.raOpts.CaSigner,
+		SignerName: &caSigner,
 		Request:    csrPEM,
 		Groups:     []string{"system:authenticated"},
 		Usages: []cert.KeyUsage{
@@ -60,6 +105,7 @@ func (r *KubernetesRA) kubernetesSign(csrPEM []byte, csrName string, caCertFile
 		},
 	}
 	certChain, _, err := chiron.SignCSRK8s(r.csrInterface.CertificateSigningRequests(), csrName, csrSpec, "", caCertFile, false)
+	recordK8sSigning(caSigner, time.Since(start), err)
 	if err != nil {
 		return nil, raerror.NewError(raerror.CertGenError, err)
 	}
@@ -73,7 +119,7 @@ func (r *KubernetesRA) Sign(csrPEM []byte, subjectIDs []string, requestedLifetim
 		return nil, err
 	}
 	csrName := chiron.GenCsrName()
-	return r.kubernetesSign(csrPEM, csrName, r.raOpts.CaCertFile)
+	return r.kubernetesSign(csrPEM, csrName, r.signerFor(subjectIDs), r.raOpts.CaCertFile)
 }
 
 // SignWithCertChain is similar to Sign but returns the leaf cert and the entire cert chain.
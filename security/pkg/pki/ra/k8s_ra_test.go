@@ -19,8 +19,10 @@ import (
 	"time"
 
 	cert "k8s.io/api/certificates/v1beta1"
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes/fake"
 	kt "k8s.io/client-go/testing"
 
@@ -118,7 +120,7 @@ func TestK8sSign(t *testing.T) {
 	if err != nil {
 		t.Errorf("Validation CSR failed")
 	}
-	_, err = r.kubernetesSign(csrPEM, csrName, r.raOpts.CaCertFile)
+	_, err = r.kubernetesSign(csrPEM, csrName, r.raOpts.CaSigner, r.raOpts.CaCertFile)
 	if err != nil {
 		t.Errorf("K8s CA Signing CSR failed")
 	}
@@ -146,3 +148,54 @@ func TestValidateCSR(t *testing.T) {
 		t.Errorf("Test 2: CSR Validation failed")
 	}
 }
+
+func TestK8sSignerFor(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	r, err := createFakeK8sRA(client)
+	if err != nil {
+		t.Fatalf("failed to create K8s RA: %v", err)
+	}
+	factory := informers.NewSharedInformerFactory(client, 0)
+	namespaces := factory.Core().V1().Namespaces().Informer().GetIndexer()
+	serviceAccounts := factory.Core().V1().ServiceAccounts().Informer().GetIndexer()
+	_ = namespaces.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "annotated",
+		Annotations: map[string]string{CaSignerAnnotation: "example.com/namespace-signer"},
+	}})
+	_ = serviceAccounts.Add(&v1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+		Name:        "annotated",
+		Namespace:   "annotated",
+		Annotations: map[string]string{CaSignerAnnotation: "example.com/workload-signer"},
+	}})
+	_ = serviceAccounts.Add(&v1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+		Name:        "escalating",
+		Namespace:   "annotated",
+		Annotations: map[string]string{CaSignerAnnotation: "kubernetes.io/kube-apiserver-client"},
+	}})
+	r.raOpts.NamespaceLister = factory.Core().V1().Namespaces().Lister()
+	r.raOpts.ServiceAccountLister = factory.Core().V1().ServiceAccounts().Lister()
+	r.raOpts.AllowedCaSigners = []string{"example.com/workload-signer", "example.com/namespace-signer"}
+	r.raOpts.NamespaceCaSigners = map[string]string{
+		"configured": "example.com/configured-signer",
+		"annotated":  "example.com/configured-signer",
+	}
+
+	cases := []struct {
+		namespace      string
+		serviceAccount string
+		want           string
+	}{
+		{namespace: "annotated", serviceAccount: "annotated", want: "example.com/workload-signer"},
+		{namespace: "annotated", serviceAccount: "default", want: "example.com/namespace-signer"},
+		// Signers out of the allowlist are ignored.
+		{namespace: "annotated", serviceAccount: "escalating", want: "example.com/namespace-signer"},
+		{namespace: "configured", serviceAccount: "default", want: "example.com/configured-signer"},
+		{namespace: "default", serviceAccount: "default", want: r.raOpts.CaSigner},
+	}
+	for _, tc := range cases {
+		id := spiffe.Identity{TrustDomain: "cluster.local", Namespace: tc.namespace, ServiceAccount: tc.serviceAccount}.String()
+		if got := r.signerFor([]string{id}); got != tc.want {
+			t.Errorf("%s: got signer %q, want %q", id, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ra
+
+import (
+	"errors"
+	"time"
+
+	"istio.io/istio/security/pkg/k8s/chiron"
+	"istio.io/pkg/monitoring"
+)
+
+var (
+	signerTag = monitoring.MustCreateLabel("signer")
+	resultTag = monitoring.MustCreateLabel("result")
+
+	k8sSigningLatency = monitoring.NewDistribution(
+		"citadel_ra_k8s_csr_signing_latency",
+		"Time in seconds between the submission of a CSR to the K8s CSR API and the issuance of the certificate. "+
+			"The result is success, approval_error if istiod could not approve the CSR, denied if the signer "+
+			"denied or failed it, or error.",
+		[]float64{.1, .5, 1, 3, 5, 10, 20, 30, 60},
+		monitoring.WithLabels(signerTag, resultTag),
+	)
+)
+
+func init() {
+	monitoring.MustRegister(
+		k8sSigningLatency,
+	)
+}
+
+func recordK8sSigning(signer string, latency time.Duration, err error) {
+	result := "success"
+	switch {
+	case err == nil:
+	case errors.Is(err, chiron.ErrCSRApproval):
+		result = "approval_error"
+		pkiRaLog.Warnf("failed to approve the CSR of signer %s, check that istiod is allowed to approve it: %v", signer, err)
+	case errors.Is(err, chiron.ErrCSRDenied):
+		result = "denied"
+		pkiRaLog.Warnf("the CSR was denied or failed by signer %s: %v", signer, err)
+	default:
+		result = "error"
+	}
+	k8sSigningLatency.With(signerTag.Value(signer), resultTag.Value(result)).Record(latency.Seconds())
+}
@@ -17,11 +17,13 @@ package util
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
 	"reflect"
+	"time"
 )
 
 const (
@@ -46,6 +48,22 @@ func ParsePemEncodedCertificate(certBytes []byte) (*x509.Certificate, error) {
 	return cert, nil
 }
 
+// VerifyCRL checks that the given PEM or DER encoded certificate revocation list is signed by the
+// issuer and has not expired.
+func VerifyCRL(crlBytes []byte, issuer *x509.Certificate) error {
+	crl, err := x509.ParseCRL(crlBytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse certificate revocation list: %v", err)
+	}
+	if err := issuer.CheckCRLSignature(crl); err != nil {
+		return fmt.Errorf("certificate revocation list is not signed by %v: %v", issuer.Subject, err)
+	}
+	if crl.HasExpired(time.Now()) {
+		return fmt.Errorf("certificate revocation list expired at %v", crl.TBSCertList.NextUpdate)
+	}
+	return nil
+}
+
 // ParsePemEncodedCSR constructs a `x509.CertificateRequest` object using the
 // given PEM-encoded certificate signing request.
 func ParsePemEncodedCSR(csrBytes []byte) (*x509.CertificateRequest, error) {
@@ -60,6 +78,24 @@ func ParsePemEncodedCSR(csrBytes []byte) (*x509.CertificateRequest, error) {
 	return csr, nil
 }
 
+// ValidateCSRPublicKey checks that the public key of the CSR is supported for signing: either a RSA key
+// of at least 2048 bits, or an EC key on one of the supported curves.
+func ValidateCSRPublicKey(csr *x509.CertificateRequest) error {
+	switch pub := csr.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minimumRsaKeySize {
+			return fmt.Errorf("RSA key size %d is smaller than the minimum size %d", pub.N.BitLen(), minimumRsaKeySize)
+		}
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() && pub.Curve != elliptic.P384() {
+			return fmt.Errorf("unsupported elliptic curve %s", pub.Curve.Params().Name)
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", csr.PublicKey)
+	}
+	return nil
+}
+
 // ParsePemEncodedKey takes a PEM-encoded key and parsed the bytes into a `crypto.PrivateKey`.
 func ParsePemEncodedKey(keyBytes []byte) (crypto.PrivateKey, error) {
 	kb, _ := pem.Decode(keyBytes)
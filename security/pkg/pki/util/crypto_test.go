@@ -24,6 +24,7 @@ import (
 	"crypto/x509"
 	"reflect"
 	"testing"
+	"time"
 )
 
 const (
@@ -342,3 +343,108 @@ func TestIsSupportedECPrivateKey(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateCSRPublicKey(t *testing.T) {
+	rsa1024Key, _ := rsa.GenerateKey(rand.Reader, 1024)
+	rsa2048Key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	p224Key, _ := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	p256Key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p384Key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	_, ed25519Key, _ := ed25519.GenerateKey(nil)
+
+	cases := map[string]struct {
+		key     crypto.Signer
+		isValid bool
+	}{
+		"RSA 1024": {key: rsa1024Key},
+		"RSA 2048": {key: rsa2048Key, isValid: true},
+		"EC P224":  {key: p224Key},
+		"EC P256":  {key: p256Key, isValid: true},
+		"EC P384":  {key: p384Key, isValid: true},
+		"ED25519":  {key: ed25519Key},
+	}
+
+	for id, tc := range cases {
+		csrBytes, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, tc.key)
+		if err != nil {
+			t.Fatalf("%s: failed to create CSR: %v", id, err)
+		}
+		csr, err := x509.ParseCertificateRequest(csrBytes)
+		if err != nil {
+			t.Fatalf("%s: failed to parse CSR: %v", id, err)
+		}
+		if err := ValidateCSRPublicKey(csr); (err == nil) != tc.isValid {
+			t.Errorf("%s: unexpected validation result: %v", id, err)
+		}
+	}
+}
+
+func TestVerifyCRL(t *testing.T) {
+	genCA := func() (*x509.Certificate, crypto.PrivateKey) {
+		certPem, keyPem, err := GenCertKeyFromOptions(CertOptions{
+			Host:         "citadel.testing.istio.io",
+			Org:          "MyOrg",
+			NotBefore:    time.Now(),
+			TTL:          time.Hour,
+			IsCA:         true,
+			IsSelfSigned: true,
+			RSAKeySize:   2048,
+		})
+		if err != nil {
+			t.Fatalf("failed to generate CA cert: %v", err)
+		}
+		cert, err := ParsePemEncodedCertificate(certPem)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := ParsePemEncodedKey(keyPem)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert, key
+	}
+	issuer, issuerKey := genCA()
+	other, _ := genCA()
+	now := time.Now()
+	crl, err := issuer.CreateCRL(rand.Reader, issuerKey, nil, now, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create CRL: %v", err)
+	}
+	expired, err := issuer.CreateCRL(rand.Reader, issuerKey, nil, now.Add(-2*time.Hour), now.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create CRL: %v", err)
+	}
+
+	cases := map[string]struct {
+		crl       []byte
+		issuer    *x509.Certificate
+		expectErr bool
+	}{
+		"signed by issuer": {
+			crl:    crl,
+			issuer: issuer,
+		},
+		"signed by another CA": {
+			crl:       crl,
+			issuer:    other,
+			expectErr: true,
+		},
+		"expired": {
+			crl:       expired,
+			issuer:    issuer,
+			expectErr: true,
+		},
+		"invalid": {
+			crl:       []byte("invalid"),
+			issuer:    issuer,
+			expectErr: true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if err := VerifyCRL(c.crl, c.issuer); (err != nil) != c.expectErr {
+				t.Errorf("VerifyCRL() got error %v, expect error %v", err, c.expectErr)
+			}
+		})
+	}
+}
@@ -43,10 +43,32 @@ import (
 type SupportedECSignatureAlgorithms string
 
 const (
-	// only ECDSA using P256 is currently supported
+	// only ECDSA is currently supported
 	EcdsaSigAlg SupportedECSignatureAlgorithms = "ECDSA"
 )
 
+// SupportedEllipticCurves are the curves of the EC keys generated with the EcdsaSigAlg
+// signature algorithm
+type SupportedEllipticCurves string
+
+const (
+	// P256Curve is the default curve
+	P256Curve SupportedEllipticCurves = "P256"
+	P384Curve SupportedEllipticCurves = "P384"
+)
+
+// ellipticCurve returns the curve for the given name, defaulting to P256.
+func ellipticCurve(name SupportedEllipticCurves) (elliptic.Curve, error) {
+	switch name {
+	case "", P256Curve:
+		return elliptic.P256(), nil
+	case P384Curve:
+		return elliptic.P384(), nil
+	default:
+		return nil, fmt.Errorf("unsupported elliptic curve %q", name)
+	}
+}
+
 // CertOptions contains options for generating a new certificate.
 type CertOptions struct {
 	// Comma-separated hostnames and IPs to generate a certificate for.
@@ -97,6 +119,9 @@ type CertOptions struct {
 	// when generating private keys. Currently only ECDSA is supported.
 	// If empty, RSA is used, otherwise ECC is used.
 	ECSigAlg SupportedECSignatureAlgorithms
+
+	// The curve of the EC key when ECSigAlg is set. If empty, P256 is used.
+	ECCCurve SupportedEllipticCurves
 }
 
 // GenCertKeyFromOptions generates a X.509 certificate and a private key with the given options.
@@ -111,7 +136,11 @@ func GenCertKeyFromOptions(options CertOptions) (pemCert []byte, pemKey []byte,
 
 		switch options.ECSigAlg {
 		case EcdsaSigAlg:
-			ecPriv, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			curve, err := ellipticCurve(options.ECCCurve)
+			if err != nil {
+				return nil, nil, fmt.Errorf("cert generation fails at EC key generation (%v)", err)
+			}
+			ecPriv, err = ecdsa.GenerateKey(curve, rand.Reader)
 			if err != nil {
 				return nil, nil, fmt.Errorf("cert generation fails at EC key generation (%v)", err)
 			}
@@ -45,6 +45,30 @@ func TestGenCSR(t *testing.T) {
 				ECSigAlg: EcdsaSigAlg,
 			},
 		},
+		"GenCSR with RSA 4096": {
+			csrOptions: CertOptions{
+				Host:       "test_ca.com",
+				Org:        "MyOrg",
+				RSAKeySize: 4096,
+			},
+		},
+		"GenCSR with EC P384": {
+			csrOptions: CertOptions{
+				Host:     "test_ca.com",
+				Org:      "MyOrg",
+				ECSigAlg: EcdsaSigAlg,
+				ECCCurve: P384Curve,
+			},
+		},
+		"GenCSR with EC errors due to invalid curve": {
+			csrOptions: CertOptions{
+				Host:     "test_ca.com",
+				Org:      "MyOrg",
+				ECSigAlg: EcdsaSigAlg,
+				ECCCurve: "P521",
+			},
+			err: errors.New("EC key generation failed (unsupported elliptic curve \"P521\")"),
+		},
 		"GenCSR with EC errors due to invalid signature algorithm": {
 			csrOptions: CertOptions{
 				Host:     "test_ca.com",
@@ -79,6 +103,9 @@ func TestGenCSR(t *testing.T) {
 		if err = csr.CheckSignature(); err != nil {
 			t.Errorf("%s: csr signature is invalid", id)
 		}
+		if err = ValidateCSRPublicKey(csr); err != nil {
+			t.Errorf("%s: csr public key is not supported: %v", id, err)
+		}
 		if csr.Subject.Organization[0] != "MyOrg" {
 			t.Errorf("%s: csr subject does not match", id)
 		}
@@ -41,13 +41,24 @@ var _ security.Authenticator = &JwtAuthenticator{}
 // K8S is created with --service-account-issuer, service-account-signing-key-file and service-account-api-audiences
 // which enable OIDC.
 func NewJwtAuthenticator(jwtRule *v1beta1.JWTRule, trustDomain string) (*JwtAuthenticator, error) {
-	issuer := jwtRule.GetIssuer()
-	jwksURL := jwtRule.GetJwksUri()
+	verifier, err := newIDTokenVerifier(jwtRule.GetIssuer(), jwtRule.GetJwksUri())
+	if err != nil {
+		return nil, err
+	}
+	return &JwtAuthenticator{
+		trustDomain: trustDomain,
+		verifier:    verifier,
+		audiences:   jwtRule.Audiences,
+	}, nil
+}
+
+// newIDTokenVerifier returns a verifier of the ID tokens of the issuer, using the keys of jwksURL or else of the
+// OIDC discovery of the issuer.
+func newIDTokenVerifier(issuer, jwksURL string) (*oidc.IDTokenVerifier, error) {
 	// The key of a JWT issuer may change, so the key may need to be updated.
 	// Based on https://godoc.org/github.com/coreos/go-oidc#NewRemoteKeySet,
 	// the oidc library handles caching and cache invalidation. Thus, the verifier
 	// is only created once in the constructor.
-	var verifier *oidc.IDTokenVerifier
 	if len(jwksURL) == 0 {
 		// OIDC discovery is used if jwksURL is not set.
 		provider, err := oidc.NewProvider(context.Background(), issuer)
@@ -55,16 +66,10 @@ func NewJwtAuthenticator(jwtRule *v1beta1.JWTRule, trustDomain string) (*JwtAuth
 		if err != nil {
 			return nil, fmt.Errorf("failed at creating an OIDC provider for %v: %v", issuer, err)
 		}
-		verifier = provider.Verifier(&oidc.Config{SkipClientIDCheck: true})
-	} else {
-		keySet := oidc.NewRemoteKeySet(context.Background(), jwksURL)
-		verifier = oidc.NewVerifier(issuer, keySet, &oidc.Config{SkipClientIDCheck: true})
+		return provider.Verifier(&oidc.Config{SkipClientIDCheck: true}), nil
 	}
-	return &JwtAuthenticator{
-		trustDomain: trustDomain,
-		verifier:    verifier,
-		audiences:   jwtRule.Audiences,
-	}, nil
+	keySet := oidc.NewRemoteKeySet(context.Background(), jwksURL)
+	return oidc.NewVerifier(issuer, keySet, &oidc.Config{SkipClientIDCheck: true}), nil
 }
 
 // Authenticate - based on the old OIDC authenticator for mesh expansion.
@@ -0,0 +1,168 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authenticate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	oidc "github.com/coreos/go-oidc"
+
+	"istio.io/istio/pkg/security"
+)
+
+const (
+	XDSJwtAuthenticatorType = "XDSJwtAuthenticator"
+)
+
+// XDSJwtIssuer is an issuer of the tokens of the proxies running out of the mesh, such as edge appliances or
+// standalone Envoys, with the rules authorizing its tokens to act as proxy identities.
+// An example of json string for a list of XDSJwtIssuer is:
+// `[{"issuer": "https://edge.example.com", "audiences": ["istiod"], "rules": [{"subjects": ["edge-*"], "namespace": "edge"}]}]`.
+type XDSJwtIssuer struct {
+	Issuer  string `json:"issuer"`
+	JwksURI string `json:"jwks_uri,omitempty"`
+	// Audiences are the audiences accepted in the tokens, at least one is required.
+	Audiences []string `json:"audiences"`
+	// Rules are evaluated in order, the first rule matching the subject of the token granting its identity.
+	// Tokens matching none of the rules are rejected.
+	Rules []XDSJwtAuthorizationRule `json:"rules"`
+}
+
+// XDSJwtAuthorizationRule maps the tokens of some subjects to a proxy identity.
+type XDSJwtAuthorizationRule struct {
+	// Subjects are the subjects of the tokens matched by the rule. A subject ending with "*" matches the
+	// subjects with its prefix, and "*" matches any subject.
+	Subjects []string `json:"subjects"`
+	// Namespace is the namespace of the identity, which the proxy must run as.
+	Namespace string `json:"namespace"`
+	// ServiceAccount is the service account of the identity. It defaults to the subject of the token.
+	ServiceAccount string `json:"serviceAccount,omitempty"`
+}
+
+// ParseXDSJwtIssuers parses a json list of XDSJwtIssuer.
+func ParseXDSJwtIssuers(value string) ([]XDSJwtIssuer, error) {
+	var issuers []XDSJwtIssuer
+	if err := json.Unmarshal([]byte(value), &issuers); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal xDS JWT issuers: %v", err)
+	}
+	for _, issuer := range issuers {
+		if issuer.Issuer == "" {
+			return nil, errors.New("xDS JWT issuer has no issuer")
+		}
+		// Without audiences, the tokens of the issuer meant for any other service would be accepted.
+		if len(issuer.Audiences) == 0 {
+			return nil, fmt.Errorf("xDS JWT issuer %s has no audiences", issuer.Issuer)
+		}
+		if len(issuer.Rules) == 0 {
+			return nil, fmt.Errorf("xDS JWT issuer %s has no authorization rules", issuer.Issuer)
+		}
+		for _, rule := range issuer.Rules {
+			if len(rule.Subjects) == 0 || rule.Namespace == "" {
+				return nil, fmt.Errorf("xDS JWT issuer %s has a rule without subjects or namespace", issuer.Issuer)
+			}
+		}
+	}
+	return issuers, nil
+}
+
+// XDSJwtAuthenticator authenticates the proxies connecting to the xDS server with the tokens of an issuer, and
+// authorizes them to act as the identities granted by the rules of the issuer. The namespace and service account of
+// the identity are checked against the ones of the proxy when the xDS identity check is enabled.
+type XDSJwtAuthenticator struct {
+	trustDomain string
+	issuer      XDSJwtIssuer
+	verifier    *oidc.IDTokenVerifier
+}
+
+var _ security.Authenticator = &XDSJwtAuthenticator{}
+
+// NewXDSJwtAuthenticator creates an authenticator of the tokens of the issuer.
+func NewXDSJwtAuthenticator(issuer XDSJwtIssuer, trustDomain string) (*XDSJwtAuthenticator, error) {
+	verifier, err := newIDTokenVerifier(issuer.Issuer, issuer.JwksURI)
+	if err != nil {
+		return nil, err
+	}
+	return &XDSJwtAuthenticator{
+		trustDomain: trustDomain,
+		issuer:      issuer,
+		verifier:    verifier,
+	}, nil
+}
+
+func (j *XDSJwtAuthenticator) Authenticate(ctx context.Context) (*security.Caller, error) {
+	bearerToken, err := security.ExtractBearerToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ID token extraction error: %v", err)
+	}
+
+	idToken, err := j.verifier.Verify(ctx, bearerToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify the JWT token of %s (error %v)", j.issuer.Issuer, err)
+	}
+	payload := &JwtPayload{}
+	if err := idToken.Claims(&payload); err != nil {
+		return nil, fmt.Errorf("failed to extract claims from ID token: %v", err)
+	}
+	if !checkAudience(payload.Aud, j.issuer.Audiences) {
+		return nil, fmt.Errorf("invalid audiences %v", payload.Aud)
+	}
+	ns, sa, err := j.authorize(payload.Sub)
+	if err != nil {
+		return nil, err
+	}
+
+	return &security.Caller{
+		AuthSource: security.AuthSourceIDToken,
+		Identities: []string{fmt.Sprintf(IdentityTemplate, j.trustDomain, ns, sa)},
+	}, nil
+}
+
+// authorize returns the namespace and service account of the identity granted to the subject.
+func (j *XDSJwtAuthenticator) authorize(sub string) (string, string, error) {
+	if sub == "" {
+		return "", "", fmt.Errorf("token of %s has no subject", j.issuer.Issuer)
+	}
+	for _, rule := range j.issuer.Rules {
+		if !matchSubject(sub, rule.Subjects) {
+			continue
+		}
+		sa := rule.ServiceAccount
+		if sa == "" {
+			sa = sub
+		}
+		if strings.Contains(sa, "/") {
+			return "", "", fmt.Errorf("invalid service account %q granted to subject %v", sa, sub)
+		}
+		return rule.Namespace, sa, nil
+	}
+	return "", "", fmt.Errorf("subject %v is not authorized by the rules of %s", sub, j.issuer.Issuer)
+}
+
+func matchSubject(sub string, subjects []string) bool {
+	for _, s := range subjects {
+		if s == sub || (strings.HasSuffix(s, "*") && strings.HasPrefix(sub, strings.TrimSuffix(s, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+func (j *XDSJwtAuthenticator) AuthenticatorType() string {
+	return XDSJwtAuthenticatorType
+}
@@ -0,0 +1,165 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authenticate
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+	jose "gopkg.in/square/go-jose.v2"
+
+	"istio.io/istio/pkg/security"
+)
+
+func TestParseXDSJwtIssuers(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		expectErr bool
+	}{
+		{
+			name:  "valid issuers",
+			value: `[{"issuer": "foo", "jwks_uri": "baz", "audiences": ["istiod"], "rules": [{"subjects": ["edge-*"], "namespace": "edge"}]}]`,
+		},
+		{
+			name:      "issuer without audiences",
+			value:     `[{"issuer": "foo", "jwks_uri": "baz", "rules": [{"subjects": ["edge-*"], "namespace": "edge"}]}]`,
+			expectErr: true,
+		},
+		{
+			name:      "invalid json",
+			value:     `{"issuer": "foo"}`,
+			expectErr: true,
+		},
+		{
+			name:      "issuer without rules",
+			value:     `[{"issuer": "foo", "jwks_uri": "baz", "audiences": ["istiod"]}]`,
+			expectErr: true,
+		},
+		{
+			name:      "rule without namespace",
+			value:     `[{"issuer": "foo", "audiences": ["istiod"], "rules": [{"subjects": ["edge-*"]}]}]`,
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseXDSJwtIssuers(tt.value)
+			gotErr := err != nil
+			if gotErr != tt.expectErr {
+				t.Errorf("expect error is %v while actual error is %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestXDSJwtAuthenticate(t *testing.T) {
+	// Create a JWKS server
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 512)
+	if err != nil {
+		t.Fatalf("failed to generate a private key: %v", err)
+	}
+	key := jose.JSONWebKey{Algorithm: string(jose.RS256), Key: rsaKey}
+	keySet := jose.JSONWebKeySet{}
+	keySet.Keys = append(keySet.Keys, key.Public())
+	server := httptest.NewServer(&jwksServer{key: keySet})
+	defer server.Close()
+
+	issuers, err := ParseXDSJwtIssuers(`[{"issuer": "` + server.URL + `", "jwks_uri": "` + server.URL + `", "audiences": ["istiod"],
+		"rules": [{"subjects": ["gateway"], "namespace": "edge", "serviceAccount": "edge-gateway"},
+			{"subjects": ["appliance-*"], "namespace": "edge"}]}]`)
+	if err != nil {
+		t.Fatalf("failed to parse the xDS JWT issuers: %v", err)
+	}
+	authenticator, err := NewXDSJwtAuthenticator(issuers[0], "cluster.local")
+	if err != nil {
+		t.Fatalf("failed to create the xDS JWT authenticator: %v", err)
+	}
+
+	token := func(sub string, aud string, exp time.Duration) string {
+		expStr := strconv.FormatInt(time.Now().Add(exp).Unix(), 10)
+		claims := `{"iss": "` + server.URL + `", "aud": ["` + aud + `"], "sub": "` + sub + `", "exp": ` + expStr + `}`
+		token, err := generateJWT(&key, []byte(claims))
+		if err != nil {
+			t.Fatalf("failed to generate JWT: %v", err)
+		}
+		return token
+	}
+
+	tests := map[string]struct {
+		token      string
+		expectErr  bool
+		expectedID string
+	}{
+		"No bearer token": {
+			expectErr: true,
+		},
+		"Subject with a service account": {
+			token:      token("gateway", "istiod", time.Hour),
+			expectedID: fmt.Sprintf(IdentityTemplate, "cluster.local", "edge", "edge-gateway"),
+		},
+		"Subject matching a prefix": {
+			token:      token("appliance-1", "istiod", time.Hour),
+			expectedID: fmt.Sprintf(IdentityTemplate, "cluster.local", "edge", "appliance-1"),
+		},
+		"Unauthorized subject": {
+			token:     token("system:serviceaccount:default:foo", "istiod", time.Hour),
+			expectErr: true,
+		},
+		"Token with wrong audience": {
+			token:     token("gateway", "wrong-audience", time.Hour),
+			expectErr: true,
+		},
+		"Expired token": {
+			token:     token("gateway", "istiod", -time.Hour),
+			expectErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			md := metadata.MD{}
+			if tc.token != "" {
+				md.Append("authorization", bearerTokenPrefix+tc.token)
+			}
+			ctx := metadata.NewIncomingContext(context.Background(), md)
+
+			actualCaller, err := authenticator.Authenticate(ctx)
+			gotErr := err != nil
+			if gotErr != tc.expectErr {
+				t.Fatalf("gotErr (%v) whereas expectErr (%v)", err, tc.expectErr)
+			}
+			if gotErr {
+				return
+			}
+			expectedCaller := &security.Caller{
+				AuthSource: security.AuthSourceIDToken,
+				Identities: []string{tc.expectedID},
+			}
+			if !reflect.DeepEqual(actualCaller, expectedCaller) {
+				t.Errorf("unexpected caller (want %v but got %v)", expectedCaller, actualCaller)
+			}
+		})
+	}
+}
@@ -16,6 +16,7 @@ package ca
 
 import (
 	"fmt"
+	"net"
 	"time"
 
 	"golang.org/x/net/context"
@@ -51,6 +52,8 @@ type Server struct {
 	Authenticators []security.Authenticator
 	ca             CertificateAuthority
 	serverCertTTL  time.Duration
+	// CertIssued, if set, is notified of the certificates issued, with the address of the caller.
+	CertIssued func(peerAddr net.Addr, cert []byte)
 }
 
 func getConnectionAddress(ctx context.Context) string {
@@ -87,6 +90,11 @@ func (s *Server) CreateCertificate(ctx context.Context, request *pb.IstioCertifi
 		s.monitoring.GetCertSignError(signErr.(*caerror.Error).ErrorType()).Increment()
 		return nil, status.Errorf(signErr.(*caerror.Error).HTTPErrorCode(), "CSR signing error (%v)", signErr.(*caerror.Error))
 	}
+	if s.CertIssued != nil {
+		if peerInfo, ok := peer.FromContext(ctx); ok {
+			s.CertIssued(peerInfo.Addr, cert)
+		}
+	}
 	respCertChain := []string{string(cert)}
 	if len(certChainBytes) != 0 {
 		respCertChain = append(respCertChain, string(certChainBytes))
@@ -84,3 +84,7 @@ func TestFuzzParseInputs(t *testing.T) {
 func TestFuzzParseAndBuildSchema(t *testing.T) {
 	runRegressionTest(t, "FuzzParseAndBuildSchema", FuzzParseAndBuildSchema)
 }
+
+func TestFuzzSimulation(t *testing.T) {
+	runRegressionTest(t, "FuzzSimulation", FuzzSimulation)
+}
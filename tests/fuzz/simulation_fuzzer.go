@@ -0,0 +1,216 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// nolint: golint // Avoid it complaining about the Fuzz function name; it is required
+package fuzz
+
+import (
+	"fmt"
+	"strings"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/core/v1alpha3"
+	"istio.io/istio/pilot/pkg/simulation"
+	"istio.io/istio/pkg/test"
+)
+
+var (
+	simulationModes     = []string{"", "STRICT", "PERMISSIVE", "DISABLE"}
+	simulationPorts     = []int{8000, 9000}
+	simulationProtocols = []string{"HTTP", "TCP"}
+	simulationTLSModes  = []simulation.TLSMode{simulation.Plaintext, simulation.MTLS}
+	simulationCalls     = []simulation.Protocol{simulation.HTTP, simulation.TCP}
+)
+
+// simulationInput consumes the raw fuzzer input to make decisions. Once the input is exhausted, all
+// decisions will pick the first option.
+type simulationInput struct {
+	data []byte
+}
+
+func (s *simulationInput) pick(n int) int {
+	if len(s.data) == 0 {
+		return 0
+	}
+	b := s.data[0]
+	s.data = s.data[1:]
+	return int(b) % n
+}
+
+func (s *simulationInput) bool() bool {
+	return s.pick(2) == 1
+}
+
+// simulationConfig describes a generated set of configuration for a workload with the label app=foo.
+type simulationConfig struct {
+	// mode is the workload level PeerAuthentication mode. If empty, no PeerAuthentication is created.
+	mode string
+	// portModes contains the port level PeerAuthentication modes.
+	portModes map[int]string
+	// servicePorts contains the ServiceEntry ports and their protocols. If empty, no ServiceEntry is created.
+	servicePorts map[int]string
+	// sidecarPorts contains the Sidecar ingress ports and their protocols. If empty, no Sidecar is created.
+	sidecarPorts map[int]string
+}
+
+func newSimulationConfig(in *simulationInput) simulationConfig {
+	c := simulationConfig{
+		mode:         simulationModes[in.pick(len(simulationModes))],
+		portModes:    map[int]string{},
+		servicePorts: map[int]string{},
+		sidecarPorts: map[int]string{},
+	}
+	for _, port := range simulationPorts {
+		if c.mode != "" {
+			// Port level settings cannot be unset, so skip the empty mode
+			if m := simulationModes[in.pick(len(simulationModes))]; m != "" {
+				c.portModes[port] = m
+			}
+		}
+		if in.bool() {
+			c.servicePorts[port] = simulationProtocols[in.pick(len(simulationProtocols))]
+		}
+		if in.bool() {
+			c.sidecarPorts[port] = simulationProtocols[in.pick(len(simulationProtocols))]
+		}
+	}
+	return c
+}
+
+// effectiveMode returns the mTLS mode that should be enforced on the given port.
+func (c simulationConfig) effectiveMode(port int) string {
+	if m, f := c.portModes[port]; f {
+		return m
+	}
+	if c.mode == "" {
+		return "PERMISSIVE"
+	}
+	return c.mode
+}
+
+func (c simulationConfig) String() string {
+	sb := strings.Builder{}
+	if c.mode != "" {
+		sb.WriteString(fmt.Sprintf(`apiVersion: security.istio.io/v1beta1
+kind: PeerAuthentication
+metadata:
+  name: default
+spec:
+  selector:
+    matchLabels:
+      app: foo
+  mtls:
+    mode: %s
+`, c.mode))
+		if len(c.portModes) > 0 {
+			sb.WriteString("  portLevelMtls:\n")
+			for _, port := range simulationPorts {
+				if m, f := c.portModes[port]; f {
+					sb.WriteString(fmt.Sprintf("    %d:\n      mode: %s\n", port, m))
+				}
+			}
+		}
+		sb.WriteString("---\n")
+	}
+	if len(c.servicePorts) > 0 {
+		sb.WriteString(`apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: se
+spec:
+  hosts:
+  - foo.bar
+  endpoints:
+  - address: 1.1.1.1
+    labels:
+      app: foo
+  location: MESH_INTERNAL
+  resolution: STATIC
+  ports:
+`)
+		for _, port := range simulationPorts {
+			if p, f := c.servicePorts[port]; f {
+				sb.WriteString(fmt.Sprintf("  - name: port-%d\n    number: %d\n    protocol: %s\n", port, port, p))
+			}
+		}
+		sb.WriteString("---\n")
+	}
+	if len(c.sidecarPorts) > 0 {
+		sb.WriteString(`apiVersion: networking.istio.io/v1alpha3
+kind: Sidecar
+metadata:
+  name: sidecar
+spec:
+  workloadSelector:
+    labels:
+      app: foo
+  egress:
+  - hosts:
+    - "*/*"
+  ingress:
+`)
+		for _, port := range simulationPorts {
+			if p, f := c.sidecarPorts[port]; f {
+				sb.WriteString(fmt.Sprintf("  - defaultEndpoint: 127.0.0.1:%d\n    port:\n      name: port-%d\n      number: %d\n      protocol: %s\n",
+					port, port, port, p))
+			}
+		}
+		sb.WriteString("---\n")
+	}
+	return sb.String()
+}
+
+func newSimulationCalls(in *simulationInput) []simulation.Call {
+	calls := []simulation.Call{}
+	count := 1 + in.pick(8)
+	for i := 0; i < count; i++ {
+		calls = append(calls, simulation.Call{
+			Port:     simulationPorts[in.pick(len(simulationPorts))],
+			Protocol: simulationCalls[in.pick(len(simulationCalls))],
+			TLS:      simulationTLSModes[in.pick(len(simulationTLSModes))],
+			CallMode: simulation.CallModeInbound,
+		})
+	}
+	return calls
+}
+
+// FuzzSimulation generates random combinations of PeerAuthentication, Sidecar, and ServiceEntry configuration
+// along with random inbound calls, and verifies invariants of the generated listeners hold.
+func FuzzSimulation(data []byte) int {
+	in := &simulationInput{data: data}
+	cfg := newSimulationConfig(in)
+	calls := newSimulationCalls(in)
+	var results []simulation.Result
+	err := test.Wrap(func(t test.Failer) {
+		cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{ConfigString: cfg.String()})
+		proxy := cg.SetupProxy(&model.Proxy{Metadata: &model.NodeMetadata{Labels: map[string]string{"app": "foo"}}})
+		sim := simulation.NewSimulationFromConfigGen(t, cg, proxy)
+		for _, c := range calls {
+			results = append(results, sim.Run(c))
+		}
+	})
+	if err != nil {
+		return 0
+	}
+	for i, c := range calls {
+		r := results[i]
+		if r.Error == simulation.ErrMultipleFilterChain {
+			panic(fmt.Sprintf("call %+v matched multiple filter chains for config:\n%v", c, cfg))
+		}
+		if cfg.effectiveMode(c.Port) == "STRICT" && c.TLS == simulation.Plaintext && r.Error == nil {
+			panic(fmt.Sprintf("STRICT port admitted plaintext call %+v to %v for config:\n%v", c, r.ClusterMatched, cfg))
+		}
+	}
+	return 1
+}
@@ -289,6 +289,7 @@ func gatherInfo(client kube.ExtendedClient, config *config.BugReportConfig, reso
 			getFromCluster(content.GetCoredumps, cp, filepath.Join(proxyDir, "cores"), &mandatoryWg)
 			getFromCluster(content.GetNetstat, cp, proxyDir, &mandatoryWg)
 			getFromCluster(content.GetProxyInfo, cp, archive.ProxyOutputPath(tempDir, namespace, pod), &optionalWg)
+			getFromCluster(content.GetTrafficSimulations, cp, proxyDir, &optionalWg)
 			getProxyLogs(client, config, resources, p, namespace, pod, container, &optionalWg)
 
 		case resources.IsDiscoveryContainer(params.ClusterVersion, namespace, pod, container):
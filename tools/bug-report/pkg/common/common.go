@@ -57,6 +57,7 @@ var versionMap = map[string]*resourceNames{
 			"debug/resourcesz",
 			"debug/authorizationz",
 			"debug/push_status",
+			"debug/snapshotz",
 			"debug/inject",
 		},
 		proxyDebugURLs: []string{
@@ -0,0 +1,227 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package content
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/golang/protobuf/ptypes"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"istio.io/istio/istioctl/pkg/util/configdump"
+	"istio.io/istio/pilot/pkg/simulation"
+	configkube "istio.io/istio/pkg/config/kube"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/tools/bug-report/pkg/kubectlcmd"
+)
+
+const (
+	simulationConfigDumpURL = "config_dump"
+	simulationsFile         = "simulations"
+)
+
+// GetTrafficSimulations runs the traffic simulation against the configuration of the proxy for each port of the
+// Services of its namespace: an outbound call to the Service, and an inbound call for the Services selecting the
+// pod. The verdict of each call is returned, so that the reported behavior can be reproduced without access to
+// the cluster.
+func GetTrafficSimulations(p *Params) (map[string]string, error) {
+	if p.Namespace == "" || p.Pod == "" {
+		return nil, fmt.Errorf("getTrafficSimulations requires namespace and pod")
+	}
+	if p.DryRun {
+		return retMap(simulationsFile, "", nil)
+	}
+	dump, err := kubectlcmd.EnvoyGet(p.Client, p.Namespace, p.Pod, simulationConfigDumpURL, p.DryRun)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := proxyResources(dump)
+	if err != nil {
+		return nil, err
+	}
+	pod, err := p.Client.Kube().CoreV1().Pods(p.Namespace).Get(context.TODO(), p.Pod, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	services, err := p.Client.Kube().CoreV1().Services(p.Namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	out := &strings.Builder{}
+	w := tabwriter.NewWriter(out, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(w, "SERVICE\tPORT\tMODE\tTLS\tLISTENER\tFILTER CHAIN\tROUTE\tCLUSTER\tERROR")
+	for i := range services.Items {
+		svc := &services.Items[i]
+		for _, call := range simulationCalls(pod, svc) {
+			res, err := runSimulation(resources, pod.Status.PodIP, call)
+			errText := "-"
+			if err != nil {
+				errText = err.Error()
+			} else if res.Error != nil {
+				errText = res.Error.Error()
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", svc.Name, call.Port, call.CallMode, call.TLS,
+				orNone(res.ListenerMatched), orNone(res.FilterChainMatched), orNone(res.RouteMatched),
+				orNone(res.ClusterMatched), errText)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return retMap(simulationsFile, out.String(), nil)
+}
+
+// simulationResources holds the configuration of a proxy read from its config dump.
+type simulationResources struct {
+	listeners []*listener.Listener
+	clusters  []*cluster.Cluster
+	routes    []*route.RouteConfiguration
+}
+
+func proxyResources(dump string) (*simulationResources, error) {
+	cd := &configdump.Wrapper{}
+	if err := cd.UnmarshalJSON([]byte(dump)); err != nil {
+		return nil, err
+	}
+	res := &simulationResources{}
+
+	listenerDump, err := cd.GetDynamicListenerDump(false)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range listenerDump.DynamicListeners {
+		ll := &listener.Listener{}
+		if err := ptypes.UnmarshalAny(l.ActiveState.Listener, ll); err != nil {
+			return nil, err
+		}
+		res.listeners = append(res.listeners, ll)
+	}
+
+	clusterDump, err := cd.GetClusterConfigDump()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range clusterDump.StaticClusters {
+		cc := &cluster.Cluster{}
+		if err := ptypes.UnmarshalAny(c.Cluster, cc); err != nil {
+			return nil, err
+		}
+		res.clusters = append(res.clusters, cc)
+	}
+	for _, c := range clusterDump.DynamicActiveClusters {
+		cc := &cluster.Cluster{}
+		if err := ptypes.UnmarshalAny(c.Cluster, cc); err != nil {
+			return nil, err
+		}
+		res.clusters = append(res.clusters, cc)
+	}
+
+	routeDump, err := cd.GetDynamicRouteDump(false)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range routeDump.DynamicRouteConfigs {
+		rr := &route.RouteConfiguration{}
+		if err := ptypes.UnmarshalAny(r.RouteConfig, rr); err != nil {
+			return nil, err
+		}
+		res.routes = append(res.routes, rr)
+	}
+	return res, nil
+}
+
+// simulationCalls returns the calls simulated for the ports of the Service: an outbound call to each port, unless
+// the Service is headless, and plaintext and mTLS inbound calls to each target port if the Service selects the pod.
+func simulationCalls(pod *v1.Pod, svc *v1.Service) []simulation.Call {
+	var calls []simulation.Call
+	selectsPod := len(svc.Spec.Selector) > 0 &&
+		labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(pod.Labels))
+	for _, port := range svc.Spec.Ports {
+		proto := simulation.TCP
+		instance := configkube.ConvertProtocol(port.Port, port.Name, port.Protocol, port.AppProtocol)
+		if instance.IsHTTP2() {
+			proto = simulation.HTTP2
+		} else if instance.IsHTTP() {
+			proto = simulation.HTTP
+		}
+		if svc.Spec.ClusterIP != "" && svc.Spec.ClusterIP != v1.ClusterIPNone {
+			calls = append(calls, simulation.Call{
+				Address:    svc.Spec.ClusterIP,
+				Port:       int(port.Port),
+				Protocol:   proto,
+				TLS:        simulation.Plaintext,
+				HostHeader: fmt.Sprintf("%s.%s.svc.cluster.local", svc.Name, svc.Namespace),
+				CallMode:   simulation.CallModeOutbound,
+			})
+		}
+		if !selectsPod {
+			continue
+		}
+		targetPort := targetPortNumber(pod, port)
+		for _, tls := range []simulation.TLSMode{simulation.Plaintext, simulation.MTLS} {
+			calls = append(calls, simulation.Call{
+				Port:     targetPort,
+				Protocol: proto,
+				TLS:      tls,
+				CallMode: simulation.CallModeInbound,
+			})
+		}
+	}
+	return calls
+}
+
+// targetPortNumber resolves the container port of the pod targeted by the Service port.
+func targetPortNumber(pod *v1.Pod, port v1.ServicePort) int {
+	if port.TargetPort.IntValue() != 0 {
+		return port.TargetPort.IntValue()
+	}
+	if port.TargetPort.StrVal != "" {
+		for _, c := range pod.Spec.Containers {
+			for _, cp := range c.Ports {
+				if cp.Name == port.TargetPort.StrVal {
+					return int(cp.ContainerPort)
+				}
+			}
+		}
+	}
+	return int(port.Port)
+}
+
+// runSimulation runs a single call. The simulation reports failures through a test.Failer, which are returned as
+// an error.
+func runSimulation(res *simulationResources, podIP string, call simulation.Call) (simulation.Result, error) {
+	var result simulation.Result
+	err := test.Wrap(func(t test.Failer) {
+		sim := simulation.NewSimulationFromConfig(t, res.listeners, res.clusters, res.routes, []string{podIP})
+		result = sim.Run(call)
+	})
+	return result, err
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
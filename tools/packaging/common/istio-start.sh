@@ -53,6 +53,15 @@ ISTIO_PILOT_PORT=${ISTIO_PILOT_PORT:-15012}
 # If set, override the default
 CONTROL_PLANE_AUTH_POLICY=${ISTIO_CP_AUTH:-"MUTUAL_TLS"}
 
+# On VMs with several network interfaces, ISTIO_SVC_INTERFACE selects the interface whose address is advertised
+if [ -z "${ISTIO_SVC_IP:-}" ] && [ -n "${ISTIO_SVC_INTERFACE:-}" ]; then
+  ISTIO_SVC_IP=$(ip -o addr show dev "${ISTIO_SVC_INTERFACE}" scope global | awk '{split($4, a, "/"); print a[1]; exit}')
+  if [ -z "${ISTIO_SVC_IP}" ]; then
+    echo "No address found for the network interface ${ISTIO_SVC_INTERFACE}" >&2
+    exit 1
+  fi
+fi
+
 if [ -z "${ISTIO_SVC_IP:-}" ]; then
   ISTIO_SVC_IP=$(hostname --all-ip-addresses | cut -d ' ' -f 1)
 fi